@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockSessionDir takes a non-blocking exclusive flock on the session's lock
+// file. The lock is released when the file is closed or the plugin exits.
+func lockSessionDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(sessionLockPath(dir), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errSessionLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
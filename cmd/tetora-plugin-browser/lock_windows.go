@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ERROR_SHARING_VIOLATION: another process has the file open without sharing.
+const errSharingViolation syscall.Errno = 32
+
+// lockSessionDir opens the session's lock file without sharing, so another
+// plugin cannot open it until the holder closes it or exits.
+func lockSessionDir(dir string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(sessionLockPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if errors.Is(err, errSharingViolation) {
+			return nil, errSessionLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), sessionLockPath(dir)), nil
+}
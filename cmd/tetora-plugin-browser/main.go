@@ -12,12 +12,15 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,9 +52,10 @@ type jsonRPCError struct {
 // --- CDP Types ---
 
 type cdpRequest struct {
-	ID     int             `json:"id"`
-	Method string          `json:"method"`
-	Params json.RawMessage `json:"params,omitempty"`
+	ID        int             `json:"id"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
 }
 
 type cdpResponse struct {
@@ -61,27 +65,70 @@ type cdpResponse struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
+
+	// Events carry no ID, only a method and params.
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
 }
 
 // --- Browser Session ---
 
+// browserTab is a page target attached to the browser connection in flat
+// session mode (Target.attachToTarget with flatten=true).
+type browserTab struct {
+	targetID  string
+	sessionID string
+}
+
+// browserSession is one isolated Chrome instance with its own user-data-dir.
+// Each Tetora sandbox/session id gets its own browserSession so cookies,
+// storage, and tabs never leak between sessions.
 type browserSession struct {
+	id           string
 	chromePath   string
 	chromeCmd    *exec.Cmd
+	userDataDir  string
+	lock         *os.File // held while the session runs; see lockSessionDir
 	wsURL        string
-	wsConn       io.ReadWriteCloser
+	wsConn       cdpTransport
 	mu           sync.Mutex
 	pending      map[int]chan cdpResponse
 	nextID       int32
-	targetID     string // current tab/target ID
-	sessionID    string // CDP session ID
+	tabs         map[string]*browserTab // targetID → attached tab
+	targetID     string                 // active tab/target ID
+	sessionID    string                 // CDP session ID of the active tab
 	readLoopDone chan struct{}
 	dead         int32 // atomic: set to 1 when readLoop exits (Chrome crash / WS disconnect)
+	reconnectMu  sync.Mutex
+	reconnecting int32 // atomic: set while reconnect re-attaches tabs
+	eventMu      sync.Mutex
+	eventSubs    map[chan cdpResponse][]string // subscriber → CDP event methods
 }
 
+const defaultSessionID = "default"
+
 var (
+	// globalSession is the session targeted by the request currently being handled.
+	// Requests are processed sequentially by the stdin loop, so handlers can use it directly.
 	globalSession *browserSession
+	sessions      = make(map[string]*browserSession)
 	sessionMu     sync.Mutex
+
+	// profileRoot holds one user-data-dir per browser session.
+	profileRoot = filepath.Join(os.TempDir(), "tetora-browser")
+
+	// downloadRoot receives files fetched by browser_download. Unlike profiles,
+	// downloads outlive their session so the caller can pick them up.
+	downloadRoot = filepath.Join(os.TempDir(), "tetora-browser-downloads")
+
+	// uploadRoot is the only directory browser_upload_file may hand files
+	// from, so a page cannot be given the config, keys or other host files.
+	// Defaults to downloadRoot.
+	uploadRoot string
+
+	// dialCDP opens the debugger connection; replaced in tests.
+	dialCDP = func(url string) (cdpTransport, error) { return dialWebSocket(url) }
 )
 
 // --- Main ---
@@ -90,12 +137,27 @@ func main() {
 	// Parse CLI args for Chrome path.
 	chromePath := findChrome()
 	for i := 1; i < len(os.Args)-1; i++ {
-		if os.Args[i] == "--chrome-path" {
+		switch os.Args[i] {
+		case "--chrome-path":
 			chromePath = os.Args[i+1]
+		case "--profile-dir":
+			profileRoot = os.Args[i+1]
+		case "--download-dir":
+			downloadRoot = os.Args[i+1]
+		case "--upload-dir":
+			uploadRoot = os.Args[i+1]
 		}
 	}
+	if uploadRoot == "" {
+		uploadRoot = downloadRoot
+	}
+
+	logDebug("browser plugin starting", "chromePath", chromePath, "profileRoot", profileRoot, "downloadRoot", downloadRoot, "uploadRoot", uploadRoot)
 
-	logDebug("browser plugin starting", "chromePath", chromePath)
+	// Kill Chrome processes left behind by a previous plugin instance.
+	if n := cleanupOrphanedChrome(profileRoot); n > 0 {
+		logDebug("cleaned up orphaned chrome sessions", "count", n)
+	}
 
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Buffer(make([]byte, 0, 2*1024*1024), 2*1024*1024)
@@ -116,9 +178,12 @@ func main() {
 	}
 
 	// Cleanup on exit.
-	if globalSession != nil {
-		globalSession.close()
+	sessionMu.Lock()
+	for id, sess := range sessions {
+		sess.close()
+		delete(sessions, id)
 	}
+	sessionMu.Unlock()
 }
 
 func handleRequest(req jsonRPCRequest, chromePath string) {
@@ -146,18 +211,18 @@ func handleToolExecute(req jsonRPCRequest, chromePath string) {
 		return
 	}
 
-	// Ensure browser session is initialized.
-	sessionMu.Lock()
-	if globalSession == nil {
-		sess, err := newBrowserSession(chromePath)
-		if err != nil {
-			sessionMu.Unlock()
-			writeError(req.ID, -32000, fmt.Sprintf("failed to start browser: %v", err))
-			return
-		}
-		globalSession = sess
+	// Every tool accepts an optional "session" to select an isolated browser.
+	var target struct {
+		Session string `json:"session"`
 	}
-	sessionMu.Unlock()
+	json.Unmarshal(params.Input, &target)
+
+	sess, err := getOrCreateSession(sanitizeSessionID(target.Session), chromePath)
+	if err != nil {
+		writeError(req.ID, -32000, fmt.Sprintf("failed to start browser: %v", err))
+		return
+	}
+	globalSession = sess
 
 	// Route to the appropriate tool handler.
 	switch params.Name {
@@ -175,6 +240,18 @@ func handleToolExecute(req jsonRPCRequest, chromePath string) {
 		handleContent(req.ID, params.Input)
 	case "browser_wait":
 		handleWait(req.ID, params.Input)
+	case "browser_new_tab":
+		handleNewTab(req.ID, params.Input)
+	case "browser_switch_tab":
+		handleSwitchTab(req.ID, params.Input)
+	case "browser_list_tabs":
+		handleListTabs(req.ID, params.Input)
+	case "browser_close_tab":
+		handleCloseTab(req.ID, params.Input)
+	case "browser_download":
+		handleDownload(req.ID, params.Input)
+	case "browser_upload_file":
+		handleUploadFile(req.ID, params.Input)
 	default:
 		writeError(req.ID, -32601, fmt.Sprintf("unknown tool: %s", params.Name))
 	}
@@ -264,6 +341,23 @@ func handleClick(reqID int, input json.RawMessage) {
 		return
 	}
 
+	x, y, err := globalSession.clickSelector(args.Selector)
+	if err != nil {
+		writeError(reqID, -32000, err.Error())
+		return
+	}
+
+	writeResult(reqID, map[string]any{
+		"ok":       true,
+		"selector": args.Selector,
+		"x":        x,
+		"y":        y,
+	})
+}
+
+// clickSelector clicks the center of the first element matching selector in
+// the active tab and returns the click coordinates.
+func (s *browserSession) clickSelector(selector string) (float64, float64, error) {
 	// Find the element using Runtime.evaluate to get node.
 	script := fmt.Sprintf(`
 		(function() {
@@ -272,15 +366,14 @@ func handleClick(reqID int, input json.RawMessage) {
 			const rect = el.getBoundingClientRect();
 			return {x: rect.x + rect.width/2, y: rect.y + rect.height/2};
 		})()
-	`, strconv.Quote(args.Selector))
+	`, strconv.Quote(selector))
 
-	evalResp, err := globalSession.sendCDP("Runtime.evaluate", map[string]any{
+	evalResp, err := s.sendCDP("Runtime.evaluate", map[string]any{
 		"expression":    script,
 		"returnByValue": true,
 	})
 	if err != nil {
-		writeError(reqID, -32000, fmt.Sprintf("evaluate failed: %v", err))
-		return
+		return 0, 0, fmt.Errorf("evaluate failed: %v", err)
 	}
 
 	var evalResult struct {
@@ -289,47 +382,37 @@ func handleClick(reqID int, input json.RawMessage) {
 		} `json:"result"`
 	}
 	if err := json.Unmarshal(evalResp, &evalResult); err != nil {
-		writeError(reqID, -32000, fmt.Sprintf("parse eval response: %v", err))
-		return
+		return 0, 0, fmt.Errorf("parse eval response: %v", err)
 	}
 
 	if errMsg, ok := evalResult.Result.Value["error"].(string); ok {
-		writeError(reqID, -32000, errMsg)
-		return
+		return 0, 0, errors.New(errMsg)
 	}
 
 	x, _ := evalResult.Result.Value["x"].(float64)
 	y, _ := evalResult.Result.Value["y"].(float64)
 
 	// Send mouse click at (x, y).
-	if _, err := globalSession.sendCDP("Input.dispatchMouseEvent", map[string]any{
+	if _, err := s.sendCDP("Input.dispatchMouseEvent", map[string]any{
 		"type":   "mousePressed",
 		"x":      x,
 		"y":      y,
 		"button": "left",
 		"clickCount": 1,
 	}); err != nil {
-		writeError(reqID, -32000, fmt.Sprintf("mouse press failed: %v", err))
-		return
+		return 0, 0, fmt.Errorf("mouse press failed: %v", err)
 	}
 
-	if _, err := globalSession.sendCDP("Input.dispatchMouseEvent", map[string]any{
+	if _, err := s.sendCDP("Input.dispatchMouseEvent", map[string]any{
 		"type":   "mouseReleased",
 		"x":      x,
 		"y":      y,
 		"button": "left",
 		"clickCount": 1,
 	}); err != nil {
-		writeError(reqID, -32000, fmt.Sprintf("mouse release failed: %v", err))
-		return
+		return 0, 0, fmt.Errorf("mouse release failed: %v", err)
 	}
-
-	writeResult(reqID, map[string]any{
-		"ok":       true,
-		"selector": args.Selector,
-		"x":        x,
-		"y":        y,
-	})
+	return x, y, nil
 }
 
 func handleType(reqID int, input json.RawMessage) {
@@ -524,15 +607,489 @@ func handleWait(reqID int, input json.RawMessage) {
 	}
 }
 
+// --- Tab Handlers ---
+
+func handleNewTab(reqID int, input json.RawMessage) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(input, &args)
+	if args.URL == "" {
+		args.URL = "about:blank"
+	}
+
+	resp, err := globalSession.sendBrowserCDP("Target.createTarget", map[string]any{"url": args.URL})
+	if err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("create tab failed: %v", err))
+		return
+	}
+	var created struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := json.Unmarshal(resp, &created); err != nil || created.TargetID == "" {
+		writeError(reqID, -32000, "create tab failed: no targetId in response")
+		return
+	}
+
+	if err := globalSession.attachTab(created.TargetID); err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("attach tab failed: %v", err))
+		return
+	}
+	globalSession.activateTab(created.TargetID)
+
+	writeResult(reqID, map[string]any{
+		"ok":      true,
+		"tabId":   created.TargetID,
+		"url":     args.URL,
+		"session": globalSession.id,
+	})
+}
+
+func handleSwitchTab(reqID int, input json.RawMessage) {
+	var args struct {
+		TabID string `json:"tabId"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		writeError(reqID, -32602, "invalid input: "+err.Error())
+		return
+	}
+	if args.TabID == "" {
+		writeError(reqID, -32602, "tabId is required")
+		return
+	}
+
+	// Tabs opened by the page itself (window.open, target=_blank) are not
+	// attached yet; attach lazily on first switch.
+	if _, ok := globalSession.tabs[args.TabID]; !ok {
+		if err := globalSession.attachTab(args.TabID); err != nil {
+			writeError(reqID, -32000, fmt.Sprintf("attach tab failed: %v", err))
+			return
+		}
+	}
+	if _, err := globalSession.sendBrowserCDP("Target.activateTarget", map[string]any{"targetId": args.TabID}); err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("switch tab failed: %v", err))
+		return
+	}
+	globalSession.activateTab(args.TabID)
+
+	writeResult(reqID, map[string]any{
+		"ok":      true,
+		"tabId":   args.TabID,
+		"session": globalSession.id,
+	})
+}
+
+func handleListTabs(reqID int, input json.RawMessage) {
+	tabs, err := globalSession.listTabs()
+	if err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("list tabs failed: %v", err))
+		return
+	}
+	writeResult(reqID, map[string]any{
+		"ok":      true,
+		"session": globalSession.id,
+		"tabs":    tabs,
+	})
+}
+
+func handleCloseTab(reqID int, input json.RawMessage) {
+	var args struct {
+		TabID string `json:"tabId"`
+	}
+	json.Unmarshal(input, &args)
+	if args.TabID == "" {
+		args.TabID = globalSession.targetID
+	}
+	if args.TabID == "" {
+		writeError(reqID, -32602, "tabId is required")
+		return
+	}
+
+	if _, err := globalSession.sendBrowserCDP("Target.closeTarget", map[string]any{"targetId": args.TabID}); err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("close tab failed: %v", err))
+		return
+	}
+	delete(globalSession.tabs, args.TabID)
+
+	// Fall back to any remaining attached tab so follow-up calls keep working.
+	if globalSession.targetID == args.TabID {
+		globalSession.targetID, globalSession.sessionID = "", ""
+		for id := range globalSession.tabs {
+			globalSession.activateTab(id)
+			break
+		}
+	}
+
+	writeResult(reqID, map[string]any{
+		"ok":          true,
+		"tabId":       args.TabID,
+		"activeTabId": globalSession.targetID,
+	})
+}
+
+// --- Download / Upload Handlers ---
+
+func handleDownload(reqID int, input json.RawMessage) {
+	var args struct {
+		URL      string `json:"url"`      // fetch directly
+		Selector string `json:"selector"` // or click an element that starts the download
+		Timeout  int    `json:"timeout"`  // milliseconds
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		writeError(reqID, -32602, "invalid input: "+err.Error())
+		return
+	}
+	if (args.URL == "") == (args.Selector == "") {
+		writeError(reqID, -32602, "exactly one of url or selector is required")
+		return
+	}
+	timeout := time.Duration(args.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	dl, err := globalSession.download(args.URL, args.Selector, timeout)
+	if err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("download failed: %v", err))
+		return
+	}
+
+	writeResult(reqID, map[string]any{
+		"ok":       true,
+		"path":     dl.path,
+		"filename": filepath.Base(dl.path),
+		"bytes":    dl.bytes,
+		"url":      dl.url,
+	})
+}
+
+// downloadedFile describes a file saved by browser_download.
+type downloadedFile struct {
+	path  string
+	bytes int64
+	url   string
+}
+
+// download routes the active tab's downloads into a fresh directory under
+// downloadRoot, triggers one by URL or click, and waits for it to finish.
+func (s *browserSession) download(url, selector string, timeout time.Duration) (*downloadedFile, error) {
+	if err := os.MkdirAll(downloadRoot, 0o700); err != nil {
+		return nil, fmt.Errorf("create download dir: %w", err)
+	}
+	// One directory per download so Chrome never renames on name clashes.
+	dir, err := os.MkdirTemp(downloadRoot, s.id+"-")
+	if err != nil {
+		return nil, fmt.Errorf("create download dir: %w", err)
+	}
+
+	if _, err := s.sendCDP("Page.setDownloadBehavior", map[string]any{
+		"behavior":     "allow",
+		"downloadPath": dir,
+	}); err != nil {
+		os.Remove(dir)
+		return nil, fmt.Errorf("set download behavior: %w", err)
+	}
+
+	events, cancel := s.subscribe("Page.downloadWillBegin", "Page.downloadProgress")
+	defer cancel()
+
+	if url != "" {
+		// Navigating to an attachment starts a download and leaves the page
+		// in place; Chrome reports the aborted navigation as an error text.
+		if _, err := s.sendCDP("Page.navigate", map[string]any{"url": url}); err != nil {
+			return nil, fmt.Errorf("navigate: %w", err)
+		}
+	} else if _, _, err := s.clickSelector(selector); err != nil {
+		return nil, err
+	}
+
+	var guid, filename, srcURL string
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case ev := <-events:
+			var p struct {
+				GUID              string `json:"guid"`
+				URL               string `json:"url"`
+				SuggestedFilename string `json:"suggestedFilename"`
+				State             string `json:"state"` // inProgress, completed, canceled
+				ReceivedBytes     int64  `json:"receivedBytes"`
+			}
+			json.Unmarshal(ev.Params, &p)
+			switch ev.Method {
+			case "Page.downloadWillBegin":
+				if guid == "" {
+					guid, filename, srcURL = p.GUID, p.SuggestedFilename, p.URL
+				}
+			case "Page.downloadProgress":
+				if p.GUID != guid {
+					continue
+				}
+				switch p.State {
+				case "completed":
+					path := findDownloadedFile(dir, filename)
+					if path == "" {
+						return nil, fmt.Errorf("download completed but no file in %s", dir)
+					}
+					size := p.ReceivedBytes
+					if fi, err := os.Stat(path); err == nil {
+						size = fi.Size()
+					}
+					return &downloadedFile{path: path, bytes: size, url: srcURL}, nil
+				case "canceled":
+					return nil, fmt.Errorf("download canceled")
+				}
+			}
+		case <-timer.C:
+			if guid == "" {
+				return nil, fmt.Errorf("no download started within %s", timeout)
+			}
+			return nil, fmt.Errorf("download did not finish within %s", timeout)
+		}
+	}
+}
+
+// findDownloadedFile returns the saved file in dir, preferring the name Chrome
+// suggested. Chrome may sanitize that name, so fall back to the only file present.
+func findDownloadedFile(dir, suggested string) string {
+	if suggested != "" {
+		p := filepath.Join(dir, filepath.Base(suggested))
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasSuffix(e.Name(), ".crdownload") {
+			return filepath.Join(dir, e.Name())
+		}
+	}
+	return ""
+}
+
+func handleUploadFile(reqID int, input json.RawMessage) {
+	var args struct {
+		Selector string   `json:"selector"` // CSS selector of an <input type=file>
+		Files    []string `json:"files"`    // paths inside the upload directory
+		Path     string   `json:"path"`     // shorthand for a single file
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		writeError(reqID, -32602, "invalid input: "+err.Error())
+		return
+	}
+	if args.Path != "" {
+		args.Files = append(args.Files, args.Path)
+	}
+	if args.Selector == "" {
+		writeError(reqID, -32602, "selector is required")
+		return
+	}
+	if len(args.Files) == 0 {
+		writeError(reqID, -32602, "files is required")
+		return
+	}
+
+	// Chrome reads the files itself, so hand it absolute paths that exist.
+	files := make([]string, 0, len(args.Files))
+	for _, f := range args.Files {
+		abs, err := resolveUploadPath(f)
+		if err != nil {
+			writeError(reqID, -32602, err.Error())
+			return
+		}
+		files = append(files, abs)
+	}
+
+	if err := globalSession.setFileInput(args.Selector, files); err != nil {
+		writeError(reqID, -32000, fmt.Sprintf("upload failed: %v", err))
+		return
+	}
+
+	writeResult(reqID, map[string]any{
+		"ok":       true,
+		"selector": args.Selector,
+		"files":    files,
+	})
+}
+
+// resolveUploadPath returns the real path of a file to upload. Relative paths
+// are taken from uploadRoot; symlinks are resolved before checking that the
+// file is inside it.
+func resolveUploadPath(f string) (string, error) {
+	root, err := filepath.EvalSymlinks(uploadRoot)
+	if err != nil {
+		return "", fmt.Errorf("upload directory %s: %v", uploadRoot, err)
+	}
+	if !filepath.IsAbs(f) {
+		f = filepath.Join(uploadRoot, f)
+	}
+	real, err := filepath.EvalSymlinks(f)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", f)
+	}
+	if rel, err := filepath.Rel(root, real); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("file %s is outside the upload directory %s", f, uploadRoot)
+	}
+	if fi, err := os.Stat(real); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("file not found: %s", f)
+	}
+	return real, nil
+}
+
+// setFileInput resolves selector in the active tab and sets its files.
+func (s *browserSession) setFileInput(selector string, files []string) error {
+	resp, err := s.sendCDP("DOM.getDocument", map[string]any{"depth": 0})
+	if err != nil {
+		return fmt.Errorf("get document: %w", err)
+	}
+	var doc struct {
+		Root struct {
+			NodeID int `json:"nodeId"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(resp, &doc); err != nil || doc.Root.NodeID == 0 {
+		return fmt.Errorf("get document: no root node")
+	}
+
+	resp, err = s.sendCDP("DOM.querySelector", map[string]any{
+		"nodeId":   doc.Root.NodeID,
+		"selector": selector,
+	})
+	if err != nil {
+		return fmt.Errorf("query selector: %w", err)
+	}
+	var found struct {
+		NodeID int `json:"nodeId"`
+	}
+	json.Unmarshal(resp, &found)
+	if found.NodeID == 0 {
+		return fmt.Errorf("element not found")
+	}
+
+	if _, err := s.sendCDP("DOM.setFileInputFiles", map[string]any{
+		"files":  files,
+		"nodeId": found.NodeID,
+	}); err != nil {
+		return fmt.Errorf("set file input: %w", err)
+	}
+	return nil
+}
+
+// tabInfo describes a page target for browser_list_tabs.
+type tabInfo struct {
+	TabID  string `json:"tabId"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Active bool   `json:"active"`
+}
+
+func (s *browserSession) listTabs() ([]tabInfo, error) {
+	resp, err := s.sendBrowserCDP("Target.getTargets", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		TargetInfos []struct {
+			TargetID string `json:"targetId"`
+			Type     string `json:"type"`
+			Title    string `json:"title"`
+			URL      string `json:"url"`
+		} `json:"targetInfos"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, fmt.Errorf("parse targets: %w", err)
+	}
+
+	tabs := []tabInfo{}
+	for _, ti := range result.TargetInfos {
+		if ti.Type != "page" {
+			continue
+		}
+		tabs = append(tabs, tabInfo{
+			TabID:  ti.TargetID,
+			URL:    ti.URL,
+			Title:  ti.Title,
+			Active: ti.TargetID == s.targetID,
+		})
+	}
+	return tabs, nil
+}
+
 // --- Browser Session Management ---
 
-func newBrowserSession(chromePath string) (*browserSession, error) {
+// getOrCreateSession returns the browser for the given session id,
+// launching a fresh Chrome (or replacing a dead one) when needed.
+func getOrCreateSession(id, chromePath string) (*browserSession, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	if sess, ok := sessions[id]; ok {
+		if atomic.LoadInt32(&sess.dead) == 0 {
+			return sess, nil
+		}
+		// Chrome may still be alive with only the WebSocket gone.
+		if err := sess.reconnect(); err == nil {
+			return sess, nil
+		}
+		logDebug("replacing dead browser session", "session", id)
+		sess.close()
+		delete(sessions, id)
+	}
+
+	sess, err := newBrowserSession(chromePath, id)
+	if err != nil {
+		return nil, err
+	}
+	sessions[id] = sess
+	return sess, nil
+}
+
+// sanitizeSessionID maps a caller-supplied session id to a safe directory name.
+func sanitizeSessionID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return defaultSessionID
+	}
+	var b strings.Builder
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	out := b.String()
+	if len(out) > 64 {
+		out = out[:64]
+	}
+	return out
+}
+
+func newBrowserSession(chromePath, id string) (*browserSession, error) {
 	// Find a free port for Chrome's remote debugging.
 	port, err := findFreePort()
 	if err != nil {
 		return nil, fmt.Errorf("find free port: %w", err)
 	}
 
+	// Each session gets its own profile so cookies and storage stay isolated.
+	userDataDir := filepath.Join(profileRoot, id)
+	if err := os.MkdirAll(userDataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create user data dir: %w", err)
+	}
+	// Another plugin instance sharing the profile root may own this session.
+	lock, err := lockSessionDir(userDataDir)
+	if errors.Is(err, errSessionLocked) {
+		return nil, fmt.Errorf("session %q is in use by another browser plugin", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("lock user data dir: %w", err)
+	}
+
 	// Launch Chrome in headless mode with remote debugging.
 	cmd := exec.Command(chromePath,
 		"--headless",
@@ -540,6 +1097,7 @@ func newBrowserSession(chromePath string) (*browserSession, error) {
 		"--no-sandbox",
 		"--disable-dev-shm-usage",
 		"--remote-debugging-port="+port,
+		"--user-data-dir="+userDataDir,
 		"about:blank",
 	)
 
@@ -547,10 +1105,14 @@ func newBrowserSession(chromePath string) (*browserSession, error) {
 	cmd.Stderr = io.Discard
 
 	if err := cmd.Start(); err != nil {
+		lock.Close()
 		return nil, fmt.Errorf("start chrome: %w", err)
 	}
 
-	logDebug("chrome launched", "pid", cmd.Process.Pid)
+	// Record the PID so a restarted plugin can reap this process if we die uncleanly.
+	os.WriteFile(filepath.Join(userDataDir, pidFileName), []byte(strconv.Itoa(cmd.Process.Pid)), 0o600)
+
+	logDebug("chrome launched", "pid", cmd.Process.Pid, "session", id)
 
 	// Wait for Chrome to be ready (retry /json/version endpoint).
 	var wsURL string
@@ -572,49 +1134,133 @@ func newBrowserSession(chromePath string) (*browserSession, error) {
 
 	if wsURL == "" {
 		cmd.Process.Kill()
+		cmd.Wait()
+		lock.Close()
+		os.RemoveAll(userDataDir)
 		return nil, fmt.Errorf("chrome did not start in time")
 	}
 
 	logDebug("chrome ready", "wsURL", wsURL)
 
 	// Connect to WebSocket.
-	wsConn, err := dialWebSocket(wsURL)
+	wsConn, err := dialCDP(wsURL)
 	if err != nil {
 		cmd.Process.Kill()
+		cmd.Wait()
+		lock.Close()
+		os.RemoveAll(userDataDir)
 		return nil, fmt.Errorf("websocket connect: %w", err)
 	}
 
 	sess := &browserSession{
+		id:           id,
 		chromePath:   chromePath,
 		chromeCmd:    cmd,
+		userDataDir:  userDataDir,
+		lock:         lock,
 		wsURL:        wsURL,
 		wsConn:       wsConn,
 		pending:      make(map[int]chan cdpResponse),
+		tabs:         make(map[string]*browserTab),
 		readLoopDone: make(chan struct{}),
 	}
 
 	// Start WebSocket read loop.
 	go sess.readLoop()
 
-	// Enable Page domain.
-	if _, err := sess.sendCDP("Page.enable", nil); err != nil {
+	// Attach to the initial about:blank page (or open one if Chrome has none).
+	tabs, err := sess.listTabs()
+	if err != nil {
 		sess.close()
-		return nil, fmt.Errorf("enable page: %w", err)
+		return nil, fmt.Errorf("list targets: %w", err)
 	}
-
-	// Enable Runtime domain.
-	if _, err := sess.sendCDP("Runtime.enable", nil); err != nil {
+	var initial string
+	if len(tabs) > 0 {
+		initial = tabs[0].TabID
+	} else {
+		resp, err := sess.sendBrowserCDP("Target.createTarget", map[string]any{"url": "about:blank"})
+		if err != nil {
+			sess.close()
+			return nil, fmt.Errorf("create initial tab: %w", err)
+		}
+		var created struct {
+			TargetID string `json:"targetId"`
+		}
+		json.Unmarshal(resp, &created)
+		initial = created.TargetID
+	}
+	if err := sess.attachTab(initial); err != nil {
 		sess.close()
-		return nil, fmt.Errorf("enable runtime: %w", err)
+		return nil, err
 	}
+	sess.activateTab(initial)
 
-	logDebug("browser session ready")
+	logDebug("browser session ready", "session", id, "tab", initial)
 	return sess, nil
 }
 
+// attachTab attaches to a page target in flat mode and enables the domains
+// the tool handlers rely on.
+func (s *browserSession) attachTab(targetID string) error {
+	resp, err := s.sendBrowserCDP("Target.attachToTarget", map[string]any{
+		"targetId": targetID,
+		"flatten":  true,
+	})
+	if err != nil {
+		return fmt.Errorf("attach to target: %w", err)
+	}
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(resp, &attached); err != nil || attached.SessionID == "" {
+		return fmt.Errorf("attach to target: no sessionId in response")
+	}
+
+	// Enable Page and Runtime domains on the new tab session.
+	for _, method := range []string{"Page.enable", "Runtime.enable"} {
+		if _, err := s.send(method, nil, attached.SessionID); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+	}
+
+	s.tabs[targetID] = &browserTab{targetID: targetID, sessionID: attached.SessionID}
+	return nil
+}
+
+// activateTab makes an attached tab the target of subsequent page-level commands.
+func (s *browserSession) activateTab(targetID string) {
+	if tab, ok := s.tabs[targetID]; ok {
+		s.targetID = tab.targetID
+		s.sessionID = tab.sessionID
+	}
+}
+
+// sendCDP sends a page-level command to the active tab.
 func (s *browserSession) sendCDP(method string, params any) (json.RawMessage, error) {
+	resp, err := s.send(method, params, s.sessionID)
+	if errors.Is(err, errSessionDropped) && s.reconnect() == nil {
+		// The tab was re-attached under a new CDP session; retry once.
+		return s.send(method, params, s.sessionID)
+	}
+	return resp, err
+}
+
+// sendBrowserCDP sends a browser-level command (Target.*) without a tab session.
+func (s *browserSession) sendBrowserCDP(method string, params any) (json.RawMessage, error) {
+	resp, err := s.send(method, params, "")
+	if errors.Is(err, errSessionDropped) && s.reconnect() == nil {
+		return s.send(method, params, "")
+	}
+	return resp, err
+}
+
+// errSessionDropped means the debugger connection went away; the command may
+// succeed after reconnect.
+var errSessionDropped = errors.New("browser session dead (Chrome crashed or WebSocket disconnected)")
+
+func (s *browserSession) send(method string, params any, sessionID string) (json.RawMessage, error) {
 	if atomic.LoadInt32(&s.dead) != 0 {
-		return nil, fmt.Errorf("browser session dead (Chrome crashed or WebSocket disconnected)")
+		return nil, errSessionDropped
 	}
 
 	id := int(atomic.AddInt32(&s.nextID, 1))
@@ -629,9 +1275,10 @@ func (s *browserSession) sendCDP(method string, params any) (json.RawMessage, er
 	}
 
 	req := cdpRequest{
-		ID:     id,
-		Method: method,
-		Params: paramBytes,
+		ID:        id,
+		Method:    method,
+		Params:    paramBytes,
+		SessionID: sessionID,
 	}
 
 	reqData, err := json.Marshal(req)
@@ -642,6 +1289,7 @@ func (s *browserSession) sendCDP(method string, params any) (json.RawMessage, er
 	ch := make(chan cdpResponse, 1)
 	s.mu.Lock()
 	s.pending[id] = ch
+	conn, done := s.wsConn, s.readLoopDone
 	s.mu.Unlock()
 
 	defer func() {
@@ -651,8 +1299,8 @@ func (s *browserSession) sendCDP(method string, params any) (json.RawMessage, er
 	}()
 
 	// Write to WebSocket.
-	if _, err := s.wsConn.Write(reqData); err != nil {
-		return nil, fmt.Errorf("write to websocket: %w", err)
+	if err := conn.WriteMessage(reqData); err != nil {
+		return nil, fmt.Errorf("write to websocket: %w (%w)", err, errSessionDropped)
 	}
 
 	// Wait for response with timeout.
@@ -665,28 +1313,31 @@ func (s *browserSession) sendCDP(method string, params any) (json.RawMessage, er
 			return nil, fmt.Errorf("cdp error: %s", resp.Error.Message)
 		}
 		return resp.Result, nil
-	case <-s.readLoopDone:
-		return nil, fmt.Errorf("browser session dead during %s (Chrome crashed or WebSocket disconnected)", method)
+	case <-done:
+		return nil, fmt.Errorf("during %s: %w", method, errSessionDropped)
 	case <-timer.C:
 		return nil, fmt.Errorf("cdp timeout (method=%s)", method)
 	}
 }
 
 func (s *browserSession) readLoop() {
-	defer close(s.readLoopDone)
+	s.mu.Lock()
+	conn, done := s.wsConn, s.readLoopDone
+	s.mu.Unlock()
+
+	defer close(done)
 	defer atomic.StoreInt32(&s.dead, 1)
 
-	buf := make([]byte, 4*1024*1024) // 4MB buffer for large messages
 	for {
-		n, err := s.wsConn.Read(buf)
+		msg, err := conn.ReadMessage()
 		if err != nil {
 			logDebug("websocket read error (marking session dead)", "error", err)
 			return
 		}
 
 		var resp cdpResponse
-		if err := json.Unmarshal(buf[:n], &resp); err != nil {
-			logDebug("invalid cdp response", "error", err, "len", n)
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			logDebug("invalid cdp response", "error", err, "len", len(msg))
 			continue
 		}
 
@@ -698,9 +1349,102 @@ func (s *browserSession) readLoop() {
 			if ok {
 				ch <- resp
 			}
+		} else if resp.Method != "" {
+			s.dispatchEvent(resp)
+		}
+	}
+}
+
+// subscribe returns a channel receiving the given CDP events until the
+// returned cancel func is called. Events are dropped if the subscriber falls
+// more than a few behind, so the read loop never blocks.
+func (s *browserSession) subscribe(methods ...string) (<-chan cdpResponse, func()) {
+	ch := make(chan cdpResponse, 32)
+	s.eventMu.Lock()
+	if s.eventSubs == nil {
+		s.eventSubs = make(map[chan cdpResponse][]string)
+	}
+	s.eventSubs[ch] = methods
+	s.eventMu.Unlock()
+	return ch, func() {
+		s.eventMu.Lock()
+		delete(s.eventSubs, ch)
+		s.eventMu.Unlock()
+	}
+}
+
+func (s *browserSession) dispatchEvent(ev cdpResponse) {
+	s.eventMu.Lock()
+	defer s.eventMu.Unlock()
+	for ch, methods := range s.eventSubs {
+		for _, m := range methods {
+			if m != ev.Method {
+				continue
+			}
+			select {
+			case ch <- ev:
+			default:
+				logDebug("dropping cdp event for slow subscriber", "method", ev.Method)
+			}
+			break
+		}
+	}
+}
+
+// reconnect re-dials the debugger WebSocket after the connection dropped while
+// Chrome kept running, then re-attaches every known tab and restores the
+// active one. Flat CDP sessions do not survive a new connection.
+func (s *browserSession) reconnect() error {
+	if atomic.LoadInt32(&s.reconnecting) != 0 {
+		return errSessionDropped // re-attach itself hit a dropped connection
+	}
+	s.reconnectMu.Lock()
+	defer s.reconnectMu.Unlock()
+	if atomic.LoadInt32(&s.dead) == 0 {
+		return nil // another caller already reconnected
+	}
+	if s.wsURL == "" {
+		return errSessionDropped
+	}
+
+	conn, err := dialCDP(s.wsURL)
+	if err != nil {
+		logDebug("websocket reconnect failed", "session", s.id, "error", err)
+		return fmt.Errorf("reconnect: %w", err)
+	}
+
+	s.mu.Lock()
+	old := s.wsConn
+	s.wsConn = conn
+	s.readLoopDone = make(chan struct{})
+	s.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	atomic.StoreInt32(&s.dead, 0)
+	go s.readLoop()
+
+	atomic.StoreInt32(&s.reconnecting, 1)
+	defer atomic.StoreInt32(&s.reconnecting, 0)
+
+	active := s.targetID
+	previous := s.tabs
+	s.tabs = make(map[string]*browserTab)
+	for targetID := range previous {
+		if err := s.attachTab(targetID); err != nil {
+			logDebug("re-attach tab failed", "session", s.id, "tab", targetID, "error", err)
+		}
+	}
+	if _, ok := s.tabs[active]; !ok {
+		for targetID := range s.tabs {
+			active = targetID
+			break
 		}
-		// Ignore events (no ID) for now.
 	}
+	s.activateTab(active)
+
+	logDebug("websocket reconnected", "session", s.id, "tabs", len(s.tabs))
+	return nil
 }
 
 func (s *browserSession) close() {
@@ -718,120 +1462,80 @@ func (s *browserSession) close() {
 	case <-time.After(2 * time.Second):
 	}
 
-	logDebug("browser session closed")
-}
-
-// --- WebSocket Dialer (minimal HTTP upgrade) ---
-
-func dialWebSocket(url string) (io.ReadWriteCloser, error) {
-	// Parse ws://host:port/path
-	if !strings.HasPrefix(url, "ws://") {
-		return nil, fmt.Errorf("unsupported websocket URL: %s", url)
+	if s.lock != nil {
+		s.lock.Close()
 	}
-	url = strings.TrimPrefix(url, "ws://")
-	parts := strings.SplitN(url, "/", 2)
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid websocket URL")
+	// Session profiles are ephemeral; drop them with the browser.
+	if s.userDataDir != "" {
+		os.RemoveAll(s.userDataDir)
 	}
-	host := parts[0]
-	path := "/" + parts[1]
 
-	// TCP connect.
-	conn, err := http.DefaultTransport.(*http.Transport).Dial("tcp", host)
-	if err != nil {
-		return nil, fmt.Errorf("tcp dial: %w", err)
-	}
+	logDebug("browser session closed", "session", s.id)
+}
 
-	// Send WebSocket upgrade request.
-	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGVzdA==\r\nSec-WebSocket-Version: 13\r\n\r\n", path, host)
-	if _, err := conn.Write([]byte(req)); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("write upgrade request: %w", err)
-	}
+// --- Orphan Cleanup ---
 
-	// Read response (simple check for "101 Switching Protocols").
-	buf := make([]byte, 4096)
-	n, err := conn.Read(buf)
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("read upgrade response: %w", err)
-	}
-	if !bytes.Contains(buf[:n], []byte("101")) {
-		conn.Close()
-		return nil, fmt.Errorf("websocket upgrade failed: %s", string(buf[:n]))
-	}
+// pidFileName is written into each session's user-data-dir while Chrome runs.
+const pidFileName = "tetora-chrome.pid"
 
-	return &wsConn{conn: conn}, nil
-}
+// lockFileName is locked by the plugin instance that owns a session for as
+// long as the session runs, so other instances can tell it is live.
+const lockFileName = "tetora-chrome.lock"
 
-type wsConn struct {
-	conn   io.ReadWriteCloser
-	readMu sync.Mutex
-}
+var errSessionLocked = errors.New("session locked by another process")
 
-func (w *wsConn) Read(p []byte) (int, error) {
-	w.readMu.Lock()
-	defer w.readMu.Unlock()
+func sessionLockPath(dir string) string { return filepath.Join(dir, lockFileName) }
 
-	// Read WebSocket frame header (simple unmask).
-	header := make([]byte, 2)
-	if _, err := io.ReadFull(w.conn, header); err != nil {
-		return 0, err
+// cleanupOrphanedChrome kills Chrome processes recorded under root by a previous
+// plugin instance (e.g. after the plugin was killed) and removes their profiles.
+// Sessions whose lock is held belong to a running plugin and are left alone.
+// Returns the number of session directories cleaned up.
+func cleanupOrphanedChrome(root string) int {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0
 	}
-
-	payloadLen := int(header[1] & 0x7F)
-	if payloadLen == 126 {
-		extLen := make([]byte, 2)
-		if _, err := io.ReadFull(w.conn, extLen); err != nil {
-			return 0, err
+	cleaned := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
 		}
-		payloadLen = int(extLen[0])<<8 | int(extLen[1])
-	} else if payloadLen == 127 {
-		extLen := make([]byte, 8)
-		if _, err := io.ReadFull(w.conn, extLen); err != nil {
-			return 0, err
+		dir := filepath.Join(root, e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, pidFileName))
+		if err != nil {
+			continue
 		}
-		payloadLen = int(extLen[4])<<24 | int(extLen[5])<<16 | int(extLen[6])<<8 | int(extLen[7])
-	}
-
-	// Always read the full frame payload to prevent stream corruption.
-	// If the payload exceeds the caller's buffer, allocate a temporary buffer
-	// so leftover bytes don't get misinterpreted as the next frame header.
-	if payloadLen > len(p) {
-		tmp := make([]byte, payloadLen)
-		if _, err := io.ReadFull(w.conn, tmp); err != nil {
-			return 0, err
+		lock, err := lockSessionDir(dir)
+		if err != nil {
+			continue // live owner, or the lock cannot be taken: not ours to reap
 		}
-		copy(p, tmp)
-		return len(p), nil
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+			if isOwnChrome(pid, dir) {
+				if proc, err := os.FindProcess(pid); err == nil {
+					proc.Kill()
+				}
+			}
+		}
+		lock.Close()
+		os.RemoveAll(dir)
+		cleaned++
 	}
-
-	return io.ReadFull(w.conn, p[:payloadLen])
+	return cleaned
 }
 
-func (w *wsConn) Write(p []byte) (int, error) {
-	// Build WebSocket frame (text, no mask).
-	frame := make([]byte, 0, len(p)+10)
-	frame = append(frame, 0x81) // FIN + text frame
-
-	if len(p) < 126 {
-		frame = append(frame, byte(len(p)))
-	} else if len(p) < 65536 {
-		frame = append(frame, 126, byte(len(p)>>8), byte(len(p)))
-	} else {
-		frame = append(frame, 127, 0, 0, 0, 0, byte(len(p)>>24), byte(len(p)>>16), byte(len(p)>>8), byte(len(p)))
+// isOwnChrome guards against PID reuse: the process is only treated as ours if
+// its command line names the session's user-data-dir. The command line comes
+// from /proc, or from ps where there is no /proc (macOS); a process that
+// cannot be matched either way is never killed.
+func isOwnChrome(pid int, userDataDir string) bool {
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil && runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		cmdline, err = exec.Command("ps", "-ww", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
 	}
-	frame = append(frame, p...)
-
-	_, err := w.conn.Write(frame)
 	if err != nil {
-		return 0, err
+		return false
 	}
-	return len(p), nil
-}
-
-func (w *wsConn) Close() error {
-	return w.conn.Close()
+	return bytes.Contains(cmdline, []byte("--user-data-dir="+userDataDir))
 }
 
 // --- Port & Chrome Detection ---
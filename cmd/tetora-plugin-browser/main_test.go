@@ -1,9 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -15,10 +25,13 @@ import (
 type mockCDPConn struct {
 	mu          sync.Mutex
 	writeBuffer []byte
-	readBuffer  []byte
-	readPos     int
+	inbox       [][]byte // queued CDP messages, one per ReadMessage
 	closed      bool
 	responses   map[int]cdpResponse // pre-configured responses
+
+	// onRequest, if set, returns extra messages (e.g. CDP events) to queue
+	// after the response to req.
+	onRequest func(req cdpRequest) [][]byte
 }
 
 func newMockCDPConn() *mockCDPConn {
@@ -27,39 +40,34 @@ func newMockCDPConn() *mockCDPConn {
 	}
 }
 
-func (m *mockCDPConn) Read(p []byte) (int, error) {
+func (m *mockCDPConn) ReadMessage() ([]byte, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.closed {
-		return 0, io.EOF
-	}
-
 	// Wait for data to be available.
-	for len(m.readBuffer)-m.readPos == 0 {
+	for len(m.inbox) == 0 {
+		if m.closed {
+			return nil, io.EOF
+		}
 		m.mu.Unlock()
 		time.Sleep(10 * time.Millisecond)
 		m.mu.Lock()
-		if m.closed {
-			return 0, io.EOF
-		}
 	}
-
-	n := copy(p, m.readBuffer[m.readPos:])
-	m.readPos += n
-	if m.readPos >= len(m.readBuffer) {
-		m.readBuffer = nil
-		m.readPos = 0
+	if m.closed {
+		return nil, io.EOF
 	}
-	return n, nil
+
+	msg := m.inbox[0]
+	m.inbox = m.inbox[1:]
+	return msg, nil
 }
 
-func (m *mockCDPConn) Write(p []byte) (int, error) {
+func (m *mockCDPConn) WriteMessage(p []byte) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.closed {
-		return 0, io.EOF
+		return io.EOF
 	}
 
 	m.writeBuffer = append(m.writeBuffer, p...)
@@ -67,21 +75,25 @@ func (m *mockCDPConn) Write(p []byte) (int, error) {
 	// Parse CDP request and send pre-configured response.
 	var req cdpRequest
 	if err := json.Unmarshal(p, &req); err == nil {
-		if resp, ok := m.responses[req.ID]; ok {
-			respData, _ := json.Marshal(resp)
-			m.readBuffer = append(m.readBuffer, respData...)
-		} else {
+		resp, ok := m.responses[req.ID]
+		if !ok {
 			// Default success response.
-			resp := cdpResponse{
+			resp = cdpResponse{
 				ID:     req.ID,
 				Result: json.RawMessage(`{"ok":true}`),
 			}
-			respData, _ := json.Marshal(resp)
-			m.readBuffer = append(m.readBuffer, respData...)
+			if req.Method == "Target.attachToTarget" {
+				resp.Result = json.RawMessage(`{"sessionId":"S-` + strconv.Itoa(req.ID) + `"}`)
+			}
+		}
+		respData, _ := json.Marshal(resp)
+		m.inbox = append(m.inbox, respData)
+		if m.onRequest != nil {
+			m.inbox = append(m.inbox, m.onRequest(req)...)
 		}
 	}
 
-	return len(p), nil
+	return nil
 }
 
 func (m *mockCDPConn) Close() error {
@@ -104,6 +116,7 @@ func setupMockBrowserSession() *browserSession {
 	sess := &browserSession{
 		wsConn:       conn,
 		pending:      make(map[int]chan cdpResponse),
+		tabs:         make(map[string]*browserTab),
 		readLoopDone: make(chan struct{}),
 	}
 	go sess.readLoop()
@@ -384,46 +397,465 @@ func TestCDPSessionError(t *testing.T) {
 }
 
 func TestWebSocketFraming(t *testing.T) {
-	// Test WebSocket frame encode/decode.
-	var buf bytes.Buffer
-	conn := &wsConn{conn: &mockReadWriteCloser{buf: &buf}}
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &wsConn{conn: client, br: bufio.NewReader(client)}
 
 	testData := []byte(`{"test":"data"}`)
-	n, err := conn.Write(testData)
-	if err != nil {
-		t.Fatalf("write failed: %v", err)
-	}
-	if n != len(testData) {
-		t.Errorf("expected %d bytes written, got %d", len(testData), n)
+	go conn.WriteMessage(testData)
+
+	frame := make([]byte, 2+4+len(testData))
+	if _, err := io.ReadFull(server, frame); err != nil {
+		t.Fatalf("read frame: %v", err)
 	}
 
-	// Verify frame structure (FIN + text opcode + length + payload).
-	frame := buf.Bytes()
+	// Verify frame structure (FIN + text opcode, mask bit + length, mask key, masked payload).
 	if frame[0] != 0x81 {
 		t.Errorf("expected FIN + text frame (0x81), got 0x%02x", frame[0])
 	}
-	if int(frame[1]) != len(testData) {
-		t.Errorf("expected length %d, got %d", len(testData), frame[1])
+	if frame[1]&0x80 == 0 {
+		t.Error("client frame must be masked")
 	}
-	if !bytes.Equal(frame[2:], testData) {
-		t.Errorf("payload mismatch")
+	if int(frame[1]&0x7F) != len(testData) {
+		t.Errorf("expected length %d, got %d", len(testData), frame[1]&0x7F)
+	}
+	var mask [4]byte
+	copy(mask[:], frame[2:6])
+	payload := frame[6:]
+	maskBytes(mask, payload)
+	if !bytes.Equal(payload, testData) {
+		t.Errorf("payload mismatch: %q", payload)
 	}
 }
 
-// --- Mock ReadWriteCloser ---
+// serverFrame builds an unmasked server-to-client frame.
+func serverFrame(fin bool, opcode byte, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) < 65536:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	return append(frame, payload...)
+}
 
-type mockReadWriteCloser struct {
-	buf *bytes.Buffer
+func TestWebSocketFragmentedMessageWithPing(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &wsConn{conn: client, br: bufio.NewReader(client)}
+
+	go func() {
+		server.Write(serverFrame(false, wsOpText, []byte(`{"id":1,`)))
+		server.Write(serverFrame(true, wsOpPing, []byte("hi")))
+		server.Write(serverFrame(true, wsOpContinuation, []byte(`"result":{}}`)))
+	}()
+
+	// The pong must be drained from the pipe while ReadMessage runs.
+	pong := make(chan []byte, 1)
+	go func() {
+		frame := make([]byte, 2+4+2)
+		io.ReadFull(server, frame)
+		pong <- frame
+	}()
+
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if string(msg) != `{"id":1,"result":{}}` {
+		t.Errorf("reassembled message = %q", msg)
+	}
+
+	frame := <-pong
+	if frame[0] != 0x80|wsOpPong {
+		t.Errorf("expected pong frame, got 0x%02x", frame[0])
+	}
+	var mask [4]byte
+	copy(mask[:], frame[2:6])
+	maskBytes(mask, frame[6:])
+	if string(frame[6:]) != "hi" {
+		t.Errorf("pong payload = %q, want ping payload echoed", frame[6:])
+	}
 }
 
-func (m *mockReadWriteCloser) Read(p []byte) (int, error) {
-	return m.buf.Read(p)
+func TestWebSocketLargeFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &wsConn{conn: client, br: bufio.NewReader(client)}
+
+	// Larger than 16MB: needs the full 64-bit length field.
+	big := bytes.Repeat([]byte("a"), 17<<20)
+	go server.Write(serverFrame(true, wsOpText, big))
+
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if len(msg) != len(big) {
+		t.Errorf("message length = %d, want %d", len(msg), len(big))
+	}
 }
 
-func (m *mockReadWriteCloser) Write(p []byte) (int, error) {
-	return m.buf.Write(p)
+func TestWebSocketCloseFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := &wsConn{conn: client, br: bufio.NewReader(client)}
+
+	go func() {
+		server.Write(serverFrame(true, wsOpClose, []byte{0x03, 0xE8}))
+		io.Copy(io.Discard, server) // swallow the echoed close
+	}()
+
+	if _, err := conn.ReadMessage(); !errors.Is(err, errWSClosed) {
+		t.Errorf("expected errWSClosed, got %v", err)
+	}
 }
 
-func (m *mockReadWriteCloser) Close() error {
-	return nil
+func TestWebSocketHandshake(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+		// A frame sent immediately after the upgrade must not be lost.
+		brw.Write(serverFrame(true, wsOpText, []byte(`{"id":7}`)))
+		brw.Flush()
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	conn, err := dialWebSocket("ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/x")
+	if err != nil {
+		t.Fatalf("dialWebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if string(msg) != `{"id":7}` {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestBrowserSessionReconnect(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+	sess.wsURL = "ws://127.0.0.1:1/devtools/browser/x"
+	sess.tabs["tab-1"] = &browserTab{targetID: "tab-1", sessionID: "old-sess"}
+	sess.activateTab("tab-1")
+
+	fresh := newMockCDPConn()
+	origDial := dialCDP
+	dialCDP = func(string) (cdpTransport, error) { return fresh, nil }
+	defer func() { dialCDP = origDial }()
+
+	// Chrome drops the WebSocket mid-task.
+	sess.wsConn.Close()
+	<-sess.readLoopDone
+
+	if _, err := sess.sendCDP("Page.navigate", map[string]any{"url": "about:blank"}); err != nil {
+		t.Fatalf("sendCDP after drop: %v", err)
+	}
+	if sess.sessionID == "old-sess" || sess.sessionID == "" {
+		t.Errorf("active tab should be re-attached under a new session, got %q", sess.sessionID)
+	}
+
+	fresh.mu.Lock()
+	sent := string(fresh.writeBuffer)
+	fresh.mu.Unlock()
+	for _, want := range []string{"Target.attachToTarget", `"targetId":"tab-1"`, "Page.navigate", `"sessionId":"` + sess.sessionID + `"`} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("expected %s in CDP traffic after reconnect, got: %s", want, sent)
+		}
+	}
+}
+
+func TestBrowserNewTab(t *testing.T) {
+	globalSession = setupMockBrowserSession()
+	defer func() { globalSession.close(); globalSession = nil }()
+
+	mockConn := globalSession.wsConn.(*mockCDPConn)
+	mockConn.setResponse(1, cdpResponse{ID: 1, Result: json.RawMessage(`{"targetId":"tab-2"}`)})
+	mockConn.setResponse(2, cdpResponse{ID: 2, Result: json.RawMessage(`{"sessionId":"sess-2"}`)})
+
+	handleNewTab(1, json.RawMessage(`{"url":"https://example.com"}`))
+
+	if globalSession.targetID != "tab-2" {
+		t.Errorf("active tab = %q, want tab-2", globalSession.targetID)
+	}
+	if globalSession.sessionID != "sess-2" {
+		t.Errorf("active session = %q, want sess-2", globalSession.sessionID)
+	}
+
+	mockConn.mu.Lock()
+	sent := string(mockConn.writeBuffer)
+	mockConn.mu.Unlock()
+	for _, want := range []string{"Target.createTarget", "Target.attachToTarget", `"flatten":true`, `"sessionId":"sess-2"`} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("expected %s in CDP traffic, got: %s", want, sent)
+		}
+	}
+}
+
+func TestBrowserSendCDPUsesActiveTabSession(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+
+	sess.tabs["tab-1"] = &browserTab{targetID: "tab-1", sessionID: "sess-1"}
+	sess.activateTab("tab-1")
+
+	if _, err := sess.sendCDP("Page.navigate", map[string]any{"url": "about:blank"}); err != nil {
+		t.Fatalf("sendCDP: %v", err)
+	}
+	if _, err := sess.sendBrowserCDP("Target.getTargets", nil); err != nil {
+		t.Fatalf("sendBrowserCDP: %v", err)
+	}
+
+	mockConn := sess.wsConn.(*mockCDPConn)
+	mockConn.mu.Lock()
+	sent := string(mockConn.writeBuffer)
+	mockConn.mu.Unlock()
+
+	if strings.Count(sent, `"sessionId":"sess-1"`) != 1 {
+		t.Errorf("expected only the page-level command to carry the tab session, got: %s", sent)
+	}
+}
+
+func TestBrowserListTabs(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+	sess.targetID = "b"
+
+	mockConn := sess.wsConn.(*mockCDPConn)
+	mockConn.setResponse(1, cdpResponse{ID: 1, Result: json.RawMessage(`{"targetInfos":[
+		{"targetId":"a","type":"page","title":"A","url":"https://a.test"},
+		{"targetId":"w","type":"service_worker","url":"https://a.test/sw.js"},
+		{"targetId":"b","type":"page","title":"B","url":"https://b.test"}]}`)})
+
+	tabs, err := sess.listTabs()
+	if err != nil {
+		t.Fatalf("listTabs: %v", err)
+	}
+	if len(tabs) != 2 {
+		t.Fatalf("expected 2 page tabs, got %d", len(tabs))
+	}
+	if tabs[0].Active || !tabs[1].Active {
+		t.Errorf("expected only tab b active, got %+v", tabs)
+	}
+}
+
+func TestBrowserDownload(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+	sess.id = "dl"
+	defer func(root string) { downloadRoot = root }(downloadRoot)
+	downloadRoot = t.TempDir()
+
+	mockConn := sess.wsConn.(*mockCDPConn)
+	var downloadDir string
+	mockConn.onRequest = func(req cdpRequest) [][]byte {
+		switch req.Method {
+		case "Page.setDownloadBehavior":
+			var p struct {
+				DownloadPath string `json:"downloadPath"`
+			}
+			json.Unmarshal(req.Params, &p)
+			downloadDir = p.DownloadPath
+		case "Page.navigate":
+			// Chrome writes the file, then reports begin → completed.
+			os.WriteFile(filepath.Join(downloadDir, "report.pdf"), []byte("%PDF-1.4"), 0o600)
+			return [][]byte{
+				[]byte(`{"method":"Page.downloadWillBegin","params":{"guid":"g1","url":"https://example.com/report","suggestedFilename":"report.pdf"}}`),
+				[]byte(`{"method":"Page.downloadProgress","params":{"guid":"g1","state":"inProgress","receivedBytes":4}}`),
+				[]byte(`{"method":"Page.downloadProgress","params":{"guid":"g1","state":"completed","receivedBytes":8}}`),
+			}
+		}
+		return nil
+	}
+
+	dl, err := sess.download("https://example.com/report", "", 2*time.Second)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if dl.path != filepath.Join(downloadDir, "report.pdf") {
+		t.Errorf("path = %q, want file in %q", dl.path, downloadDir)
+	}
+	if !strings.HasPrefix(dl.path, downloadRoot) {
+		t.Errorf("path %q not under download root %q", dl.path, downloadRoot)
+	}
+	if dl.bytes != 8 {
+		t.Errorf("bytes = %d, want 8", dl.bytes)
+	}
+}
+
+func TestBrowserDownloadCanceled(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+	defer func(root string) { downloadRoot = root }(downloadRoot)
+	downloadRoot = t.TempDir()
+
+	sess.wsConn.(*mockCDPConn).onRequest = func(req cdpRequest) [][]byte {
+		if req.Method != "Page.navigate" {
+			return nil
+		}
+		return [][]byte{
+			[]byte(`{"method":"Page.downloadWillBegin","params":{"guid":"g1","suggestedFilename":"a.zip"}}`),
+			[]byte(`{"method":"Page.downloadProgress","params":{"guid":"g1","state":"canceled"}}`),
+		}
+	}
+
+	if _, err := sess.download("https://example.com/a.zip", "", 2*time.Second); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+	mockConn := sess.wsConn.(*mockCDPConn)
+	mockConn.mu.Lock()
+	mockConn.onRequest = nil
+	mockConn.mu.Unlock()
+	if _, err := sess.download("https://example.com/none", "", 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "no download started") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
+func TestBrowserSetFileInput(t *testing.T) {
+	sess := setupMockBrowserSession()
+	defer sess.close()
+
+	mockConn := sess.wsConn.(*mockCDPConn)
+	mockConn.setResponse(1, cdpResponse{ID: 1, Result: json.RawMessage(`{"root":{"nodeId":1}}`)})
+	mockConn.setResponse(2, cdpResponse{ID: 2, Result: json.RawMessage(`{"nodeId":42}`)})
+
+	if err := sess.setFileInput("input[type=file]", []string{"/tmp/a.pdf"}); err != nil {
+		t.Fatalf("setFileInput: %v", err)
+	}
+
+	mockConn.mu.Lock()
+	sent := string(mockConn.writeBuffer)
+	mockConn.mu.Unlock()
+	for _, want := range []string{"DOM.getDocument", "DOM.querySelector", "DOM.setFileInputFiles", `"nodeId":42`, `"/tmp/a.pdf"`} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("expected %s in CDP traffic, got: %s", want, sent)
+		}
+	}
+
+	// A selector that matches nothing returns nodeId 0.
+	mockConn.setResponse(4, cdpResponse{ID: 4, Result: json.RawMessage(`{"root":{"nodeId":1}}`)})
+	mockConn.setResponse(5, cdpResponse{ID: 5, Result: json.RawMessage(`{"nodeId":0}`)})
+	if err := sess.setFileInput("#missing", []string{"/tmp/a.pdf"}); err == nil {
+		t.Error("expected error for missing element")
+	}
+}
+
+func TestResolveUploadPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "uploads")
+	os.MkdirAll(filepath.Join(root, "sub"), 0o700)
+	os.WriteFile(filepath.Join(root, "sub", "a.pdf"), []byte("x"), 0o600)
+	secret := filepath.Join(base, "config.json")
+	os.WriteFile(secret, []byte("{}"), 0o600)
+	os.Symlink(secret, filepath.Join(root, "link.json"))
+
+	old := uploadRoot
+	uploadRoot = root
+	defer func() { uploadRoot = old }()
+
+	realRoot, _ := filepath.EvalSymlinks(root)
+	for _, f := range []string{"sub/a.pdf", filepath.Join(root, "sub", "a.pdf")} {
+		if got, err := resolveUploadPath(f); err != nil || got != filepath.Join(realRoot, "sub", "a.pdf") {
+			t.Errorf("resolveUploadPath(%q) = %q, %v", f, got, err)
+		}
+	}
+	for _, f := range []string{secret, "../config.json", "link.json", "sub", "missing.pdf"} {
+		if got, err := resolveUploadPath(f); err == nil {
+			t.Errorf("resolveUploadPath(%q) = %q, want error", f, got)
+		}
+	}
+}
+
+func TestFindDownloadedFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "partial.crdownload"), nil, 0o600)
+	os.WriteFile(filepath.Join(dir, "invoice_1_.pdf"), []byte("x"), 0o600)
+
+	// Suggested name was sanitized by Chrome; fall back to the finished file.
+	if got := findDownloadedFile(dir, "invoice/1?.pdf"); filepath.Base(got) != "invoice_1_.pdf" {
+		t.Errorf("findDownloadedFile = %q", got)
+	}
+	if got := findDownloadedFile(dir, "invoice_1_.pdf"); got != filepath.Join(dir, "invoice_1_.pdf") {
+		t.Errorf("findDownloadedFile exact = %q", got)
+	}
+}
+
+func TestSanitizeSessionID(t *testing.T) {
+	cases := map[string]string{
+		"":              defaultSessionID,
+		"  ":            defaultSessionID,
+		"sandbox-42":    "sandbox-42",
+		"../etc/passwd": "___etc_passwd",
+		"a b/c":         "a_b_c",
+	}
+	for in, want := range cases {
+		if got := sanitizeSessionID(in); got != want {
+			t.Errorf("sanitizeSessionID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCleanupOrphanedChrome(t *testing.T) {
+	root := t.TempDir()
+	stale := filepath.Join(root, "stale")
+	os.MkdirAll(stale, 0o700)
+	// PID that is practically guaranteed not to exist.
+	os.WriteFile(filepath.Join(stale, pidFileName), []byte("999999999"), 0o600)
+	// Directory without a PID file is left alone.
+	os.MkdirAll(filepath.Join(root, "other"), 0o700)
+	// A session still locked by a running plugin instance is left alone.
+	live := filepath.Join(root, "live")
+	os.MkdirAll(live, 0o700)
+	os.WriteFile(filepath.Join(live, pidFileName), []byte(strconv.Itoa(os.Getpid())), 0o600)
+	lock, err := lockSessionDir(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+
+	if n := cleanupOrphanedChrome(root); n != 1 {
+		t.Errorf("expected 1 session cleaned, got %d", n)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected stale profile removed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "other")); err != nil {
+		t.Errorf("expected unrelated dir kept: %v", err)
+	}
+	if _, err := os.Stat(live); err != nil {
+		t.Errorf("expected live session kept: %v", err)
+	}
+}
+
+func TestIsOwnChrome(t *testing.T) {
+	// This test process does not run with the session's user-data-dir.
+	if isOwnChrome(os.Getpid(), t.TempDir()) {
+		t.Error("unrelated process treated as the session's chrome")
+	}
+	if isOwnChrome(999999999, t.TempDir()) {
+		t.Error("missing process treated as the session's chrome")
+	}
 }
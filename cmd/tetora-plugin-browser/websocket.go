@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// --- WebSocket Transport (RFC 6455 client) ---
+//
+// Chrome's DevTools endpoint speaks plain ws:// on localhost. This client
+// covers what CDP traffic needs: masked client frames, 64-bit payload lengths,
+// fragmented messages with continuation frames, and ping/pong/close control
+// frames interleaved with data.
+
+// cdpTransport carries whole CDP messages over the debugger connection.
+type cdpTransport interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(p []byte) error
+	Close() error
+}
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	// wsMaxMessageSize bounds a reassembled message. Full-page screenshots of
+	// long pages are base64 encoded and can run to tens of megabytes.
+	wsMaxMessageSize = 256 << 20
+
+	// wsGUID is the fixed key suffix from RFC 6455 §1.3.
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var errWSClosed = errors.New("websocket closed by peer")
+
+// wsConn is a client-side WebSocket connection. ReadMessage must be called
+// from a single goroutine; WriteMessage is safe for concurrent use.
+type wsConn struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// dialWebSocket performs the HTTP upgrade handshake against a ws:// URL.
+func dialWebSocket(rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "ws" || u.Host == "" {
+		return nil, fmt.Errorf("unsupported websocket URL: %s", rawURL)
+	}
+
+	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("tcp dial: %w", err)
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		u.RequestURI(), u.Host, key)
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write upgrade request: %w", err)
+	}
+
+	// The buffered reader is kept: Chrome may send frames right behind the
+	// 101 response, and they must not be lost.
+	br := bufio.NewReaderSize(conn, 64*1024)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read upgrade response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket upgrade failed: %s", resp.Status)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), wsAcceptKey(key); got != want {
+		conn.Close()
+		return nil, fmt.Errorf("websocket upgrade failed: bad Sec-WebSocket-Accept")
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// ReadMessage returns the next complete text or binary message, reassembling
+// fragments and answering control frames along the way.
+func (w *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	inMessage := false
+	for {
+		fin, opcode, payload, err := w.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := w.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			// Echo the status code back, then report the close.
+			w.writeFrame(wsOpClose, payload)
+			return nil, errWSClosed
+		case wsOpText, wsOpBinary:
+			if inMessage {
+				return nil, fmt.Errorf("websocket protocol error: new message before previous finished")
+			}
+			inMessage = true
+			msg = payload
+		case wsOpContinuation:
+			if !inMessage {
+				return nil, fmt.Errorf("websocket protocol error: unexpected continuation frame")
+			}
+			if len(msg)+len(payload) > wsMaxMessageSize {
+				return nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessageSize)
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("websocket protocol error: unknown opcode %#x", opcode)
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame and returns its unmasked payload.
+func (w *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(w.br, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(w.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(w.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		err = fmt.Errorf("websocket protocol error: invalid control frame")
+		return
+	}
+	if length > wsMaxMessageSize {
+		err = fmt.Errorf("websocket frame exceeds %d bytes", wsMaxMessageSize)
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(w.br, mask[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(w.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return
+}
+
+// WriteMessage sends p as a single masked text frame.
+func (w *wsConn) WriteMessage(p []byte) error {
+	return w.writeFrame(wsOpText, p)
+}
+
+func (w *wsConn) writeFrame(opcode byte, p []byte) error {
+	frame := make([]byte, 0, len(p)+14)
+	frame = append(frame, 0x80|opcode) // FIN + opcode
+
+	// Clients must mask every frame (RFC 6455 §5.3).
+	switch {
+	case len(p) < 126:
+		frame = append(frame, 0x80|byte(len(p)))
+	case len(p) < 65536:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(p)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(p)))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return fmt.Errorf("generate mask: %w", err)
+	}
+	frame = append(frame, mask[:]...)
+	start := len(frame)
+	frame = append(frame, p...)
+	maskBytes(mask, frame[start:])
+
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	_, err := w.conn.Write(frame)
+	return err
+}
+
+func (w *wsConn) Close() error {
+	return w.conn.Close()
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
@@ -5,7 +5,9 @@ package main
 //
 // This is a standalone binary that communicates with the Tetora daemon
 // via JSON-RPC over stdin/stdout. It manages Docker containers for
-// sandboxed task execution.
+// sandboxed task execution. Podman (including rootless) works too: it is
+// picked automatically when no Docker daemon is reachable, or forced with
+// --runtime podman.
 //
 // Build: go build ./cmd/tetora-plugin-docker-sandbox/
 
@@ -15,6 +17,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -48,6 +53,9 @@ type container struct {
 	SessionID string
 	Image     string
 	CreatedAt time.Time
+
+	mu       sync.Mutex
+	OOMKills int // last oom_kill count seen in the container's cgroup
 }
 
 type containerStore struct {
@@ -68,6 +76,16 @@ func (cs *containerStore) get(sandboxID string) (*container, bool) {
 	return c, ok
 }
 
+func (cs *containerStore) list() []*container {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	out := make([]*container, 0, len(cs.containers))
+	for _, c := range cs.containers {
+		out = append(out, c)
+	}
+	return out
+}
+
 func (cs *containerStore) put(sandboxID string, c *container) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -87,10 +105,17 @@ var defaultImage = "ubuntu:22.04"
 // --- Main ---
 
 func main() {
-	// Parse CLI args for default image override.
+	// Parse CLI args for default image and runtime overrides.
 	for i := 1; i < len(os.Args)-1; i++ {
-		if os.Args[i] == "--image" {
+		switch os.Args[i] {
+		case "--image":
 			defaultImage = os.Args[i+1]
+		case "--runtime":
+			runtimeFlag = os.Args[i+1]
+		case "--max-output":
+			if n, err := strconv.Atoi(os.Args[i+1]); err == nil && n > 0 {
+				maxOutputBytes = n
+			}
 		}
 	}
 
@@ -138,6 +163,9 @@ func handleRequest(req jsonRPCRequest, store *containerStore) {
 	case "sandbox/destroy":
 		handleDestroy(req, store)
 
+	case "sandbox/stats":
+		handleStats(req, store)
+
 	default:
 		writeError(req.ID, -32601, fmt.Sprintf("method not found: %s", req.Method))
 	}
@@ -146,19 +174,25 @@ func handleRequest(req jsonRPCRequest, store *containerStore) {
 // --- Health ---
 
 func handleHealth(req jsonRPCRequest) {
-	// Check if docker is available.
-	out, err := exec.Command("docker", "info", "--format", "{{.ServerVersion}}").Output()
+	rt, err := currentRuntime()
 	if err != nil {
 		writeResult(req.ID, map[string]any{
 			"available": false,
-			"error":     fmt.Sprintf("docker not available: %v", err),
+			"error":     err.Error(),
 		})
 		return
 	}
-	writeResult(req.ID, map[string]any{
+	result := map[string]any{
 		"available":     true,
-		"dockerVersion": strings.TrimSpace(string(out)),
-	})
+		"runtime":       rt.Name,
+		"version":       rt.Version,
+		"rootless":      rt.Rootless,
+		"cgroupVersion": rt.CgroupVersion,
+	}
+	if rt.Name == "docker" {
+		result["dockerVersion"] = rt.Version
+	}
+	writeResult(req.ID, result)
 }
 
 // --- Create ---
@@ -182,6 +216,12 @@ func handleCreate(req jsonRPCRequest, store *containerStore) {
 		return
 	}
 
+	rt, err := currentRuntime()
+	if err != nil {
+		writeError(req.ID, -32000, err.Error())
+		return
+	}
+
 	image := params.Image
 	if image == "" {
 		image = defaultImage
@@ -192,19 +232,16 @@ func handleCreate(req jsonRPCRequest, store *containerStore) {
 		network = "none"
 	}
 
-	// Build docker create args.
+	// Build create args.
 	args := []string{"create",
 		"--label", "tetora.sessionId=" + params.SessionID,
 		"--label", "tetora.managed=true",
 		"--network", network,
 	}
 
-	if params.MemLimit != "" {
-		args = append(args, "--memory", params.MemLimit)
-	}
-	if params.CPULimit != "" {
-		args = append(args, "--cpus", params.CPULimit)
-	}
+	limitArgs, ignored := rt.resourceArgs(params.MemLimit, params.CPULimit)
+	args = append(args, limitArgs...)
+	args = append(args, rt.userArgs(params.Workspace != "")...)
 
 	// Mount workspace if provided.
 	if params.Workspace != "" {
@@ -215,9 +252,9 @@ func handleCreate(req jsonRPCRequest, store *containerStore) {
 	args = append(args, image, "sleep", "infinity")
 
 	// Create container.
-	out, err := exec.Command("docker", args...).Output()
+	out, err := rt.command(args...).Output()
 	if err != nil {
-		writeError(req.ID, -32000, fmt.Sprintf("docker create failed: %v", err))
+		writeError(req.ID, -32000, fmt.Sprintf("%s create failed: %v", rt.Name, err))
 		return
 	}
 
@@ -227,10 +264,10 @@ func handleCreate(req jsonRPCRequest, store *containerStore) {
 	}
 
 	// Start the container.
-	if err := exec.Command("docker", "start", containerID).Run(); err != nil {
+	if err := rt.command("start", containerID).Run(); err != nil {
 		// Cleanup on failure.
-		exec.Command("docker", "rm", "-f", containerID).Run()
-		writeError(req.ID, -32000, fmt.Sprintf("docker start failed: %v", err))
+		rt.command("rm", "-f", containerID).Run()
+		writeError(req.ID, -32000, fmt.Sprintf("%s start failed: %v", rt.Name, err))
 		return
 	}
 
@@ -241,19 +278,26 @@ func handleCreate(req jsonRPCRequest, store *containerStore) {
 		CreatedAt: time.Now(),
 	})
 
-	writeResult(req.ID, map[string]any{
+	result := map[string]any{
 		"sandboxId": containerID,
 		"image":     image,
-	})
+		"runtime":   rt.Name,
+	}
+	if len(ignored) > 0 {
+		// Rootless hosts without the needed cgroup controllers cannot enforce these.
+		result["limitsIgnored"] = ignored
+	}
+	writeResult(req.ID, result)
 }
 
 // --- Exec ---
 
 func handleExec(req jsonRPCRequest, store *containerStore) {
 	var params struct {
-		SandboxID string `json:"sandboxId"`
-		Command   string `json:"command"`
-		Timeout   int    `json:"timeout"` // seconds
+		SandboxID      string `json:"sandboxId"`
+		Command        string `json:"command"`
+		Timeout        int    `json:"timeout"`        // seconds
+		MaxOutputBytes int    `json:"maxOutputBytes"` // per stream; 0 = plugin default
 	}
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		writeError(req.ID, -32602, "invalid params: "+err.Error())
@@ -269,11 +313,18 @@ func handleExec(req jsonRPCRequest, store *containerStore) {
 		return
 	}
 
-	if _, ok := store.get(params.SandboxID); !ok {
+	c, ok := store.get(params.SandboxID)
+	if !ok {
 		writeError(req.ID, -32000, fmt.Sprintf("sandbox %q not found", params.SandboxID))
 		return
 	}
 
+	rt, err := currentRuntime()
+	if err != nil {
+		writeError(req.ID, -32000, err.Error())
+		return
+	}
+
 	timeout := params.Timeout
 	if timeout <= 0 {
 		timeout = 120
@@ -281,16 +332,21 @@ func handleExec(req jsonRPCRequest, store *containerStore) {
 
 	// Execute command in container using sh -c.
 	args := []string{"exec", params.SandboxID, "sh", "-c", params.Command}
-	cmd := exec.Command("docker", args...)
+	cmd := rt.command(args...)
 
-	// Set up timeout.
+	// Set up timeout and output caps.
 	done := make(chan error, 1)
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	limit := params.MaxOutputBytes
+	if limit <= 0 {
+		limit = maxOutputBytes
+	}
+	stdout := &cappedBuffer{max: limit}
+	stderr := &cappedBuffer{max: limit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
-		writeError(req.ID, -32000, fmt.Sprintf("docker exec start failed: %v", err))
+		writeError(req.ID, -32000, fmt.Sprintf("%s exec start failed: %v", rt.Name, err))
 		return
 	}
 
@@ -305,24 +361,40 @@ func handleExec(req jsonRPCRequest, store *containerStore) {
 			if exitErr, ok := err.(*exec.ExitError); ok {
 				exitCode = exitErr.ExitCode()
 			} else {
-				writeError(req.ID, -32000, fmt.Sprintf("docker exec failed: %v", err))
+				writeError(req.ID, -32000, fmt.Sprintf("%s exec failed: %v", rt.Name, err))
 				return
 			}
 		}
-		writeResult(req.ID, map[string]any{
-			"stdout":   stdout.String(),
-			"stderr":   stderr.String(),
-			"exitCode": exitCode,
-		})
+		result := execResult(stdout, stderr, exitCode)
+		// 137 = SIGKILL; inside a memory-limited sandbox that is usually the OOM killer.
+		if exitCode == 137 && wasOOMKilled(rt, c) {
+			result["killed"] = "oom"
+		}
+		writeResult(req.ID, result)
 
 	case <-time.After(time.Duration(timeout) * time.Second):
 		cmd.Process.Kill()
-		writeResult(req.ID, map[string]any{
-			"stdout":   stdout.String(),
-			"stderr":   "execution timed out",
-			"exitCode": -1,
-		})
+		// 124 matches timeout(1), so callers that only look at the code still see a timeout.
+		result := execResult(stdout, stderr, 124)
+		result["killed"] = "timeout"
+		writeResult(req.ID, result)
+	}
+}
+
+// execResult builds the sandbox/exec reply, flagging streams that hit the cap.
+func execResult(stdout, stderr *cappedBuffer, exitCode int) map[string]any {
+	result := map[string]any{
+		"stdout":   stdout.String(),
+		"stderr":   stderr.String(),
+		"exitCode": exitCode,
+	}
+	if n := stdout.Dropped(); n > 0 {
+		result["stdoutTruncated"] = n
+	}
+	if n := stderr.Dropped(); n > 0 {
+		result["stderrTruncated"] = n
 	}
+	return result
 }
 
 // --- Copy In ---
@@ -348,10 +420,22 @@ func handleCopyIn(req jsonRPCRequest, store *containerStore) {
 		return
 	}
 
-	// docker cp hostPath containerID:containerPath
-	out, err := exec.Command("docker", "cp", params.HostPath, params.SandboxID+":"+params.ContainerPath).CombinedOutput()
+	rt, err := currentRuntime()
 	if err != nil {
-		writeError(req.ID, -32000, fmt.Sprintf("docker cp in failed: %v: %s", err, string(out)))
+		writeError(req.ID, -32000, err.Error())
+		return
+	}
+
+	// podman cp refuses a destination whose parent is missing where docker
+	// creates it in some cases; create the parent so both behave the same.
+	if dir := path.Dir(strings.TrimSuffix(params.ContainerPath, "/")); dir != "/" && dir != "." {
+		rt.command("exec", params.SandboxID, "mkdir", "-p", dir).Run()
+	}
+
+	// cp hostPath containerID:containerPath
+	out, err := rt.command("cp", params.HostPath, params.SandboxID+":"+params.ContainerPath).CombinedOutput()
+	if err != nil {
+		writeError(req.ID, -32000, fmt.Sprintf("%s cp in failed: %v: %s", rt.Name, err, string(out)))
 		return
 	}
 
@@ -381,10 +465,19 @@ func handleCopyOut(req jsonRPCRequest, store *containerStore) {
 		return
 	}
 
-	// docker cp containerID:containerPath hostPath
-	out, err := exec.Command("docker", "cp", params.SandboxID+":"+params.ContainerPath, params.HostPath).CombinedOutput()
+	rt, err := currentRuntime()
 	if err != nil {
-		writeError(req.ID, -32000, fmt.Sprintf("docker cp out failed: %v: %s", err, string(out)))
+		writeError(req.ID, -32000, err.Error())
+		return
+	}
+
+	// Same as copy_in: make the host parent exist for either runtime.
+	os.MkdirAll(filepath.Dir(filepath.Clean(params.HostPath)), 0o755)
+
+	// cp containerID:containerPath hostPath
+	out, err := rt.command("cp", params.SandboxID+":"+params.ContainerPath, params.HostPath).CombinedOutput()
+	if err != nil {
+		writeError(req.ID, -32000, fmt.Sprintf("%s cp out failed: %v: %s", rt.Name, err, string(out)))
 		return
 	}
 
@@ -408,7 +501,9 @@ func handleDestroy(req jsonRPCRequest, store *containerStore) {
 	}
 
 	// Force remove container (ignore errors — container may already be gone).
-	exec.Command("docker", "rm", "-f", params.SandboxID).Run()
+	if rt, err := currentRuntime(); err == nil {
+		rt.command("rm", "-f", params.SandboxID).Run()
+	}
 	store.remove(params.SandboxID)
 
 	writeResult(req.ID, map[string]any{"ok": true})
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- Container Runtime ---
+
+// containerRuntime is the CLI used to drive containers: docker or podman.
+// Both accept the same create/start/exec/cp/rm verbs; the differences this
+// plugin cares about are rootless user mapping and which resource limits the
+// host's cgroup setup can actually enforce.
+type containerRuntime struct {
+	Name          string // "docker" or "podman"
+	Path          string // binary to exec (may be a podman-docker shim named docker)
+	Version       string
+	Rootless      bool
+	CgroupVersion int // 1 or 2; 0 if unknown
+}
+
+// runtimeFlag is the --runtime flag: "docker", "podman", or "" for auto-detect.
+var runtimeFlag string
+
+var (
+	rtMu     sync.Mutex
+	rtCached *containerRuntime
+)
+
+// currentRuntime returns the detected runtime, retrying detection until it
+// succeeds so a daemon started after the plugin is still picked up.
+func currentRuntime() (*containerRuntime, error) {
+	rtMu.Lock()
+	defer rtMu.Unlock()
+	if rtCached != nil {
+		return rtCached, nil
+	}
+	rt, err := detectRuntime(runtimeFlag)
+	if err != nil {
+		return nil, err
+	}
+	rtCached = rt
+	return rt, nil
+}
+
+// detectRuntime probes the requested runtime, or docker then podman when pref
+// is empty. A docker binary that is really the podman-docker shim is treated
+// as podman.
+func detectRuntime(pref string) (*containerRuntime, error) {
+	switch pref {
+	case "docker", "podman":
+		return probeRuntime(pref)
+	case "", "auto":
+		var errs []string
+		for _, name := range []string{"docker", "podman"} {
+			rt, err := probeRuntime(name)
+			if err == nil {
+				return rt, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return nil, fmt.Errorf("no container runtime available (%s)", strings.Join(errs, "; "))
+	default:
+		return nil, fmt.Errorf("unknown runtime %q (want docker or podman)", pref)
+	}
+}
+
+func probeRuntime(name string) (*containerRuntime, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in PATH", name)
+	}
+	if name == "docker" {
+		if out, err := exec.Command(path, "--version").Output(); err == nil &&
+			strings.Contains(strings.ToLower(string(out)), "podman") {
+			name = "podman"
+		}
+	}
+
+	rt := &containerRuntime{Name: name, Path: path}
+	if name == "podman" {
+		out, err := exec.Command(path, "info", "--format",
+			"{{.Version.Version}}|{{.Host.CgroupsVersion}}|{{.Host.Security.Rootless}}").Output()
+		if err != nil {
+			return nil, fmt.Errorf("podman info failed: %v", err)
+		}
+		rt.Version, rt.CgroupVersion, rt.Rootless = parsePodmanInfo(string(out))
+		return rt, nil
+	}
+
+	out, err := exec.Command(path, "info", "--format",
+		"{{.ServerVersion}}|{{.CgroupVersion}}|{{json .SecurityOptions}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker daemon not accessible: %v", err)
+	}
+	rt.Version, rt.CgroupVersion, rt.Rootless = parseDockerInfo(string(out))
+	return rt, nil
+}
+
+// parsePodmanInfo parses "version|v2|true".
+func parsePodmanInfo(s string) (version string, cgroup int, rootless bool) {
+	parts := strings.SplitN(strings.TrimSpace(s), "|", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parseCgroupVersion(parts[1]), parts[2] == "true"
+}
+
+// parseDockerInfo parses `version|2|["name=seccomp,...","name=rootless"]`.
+func parseDockerInfo(s string) (version string, cgroup int, rootless bool) {
+	parts := strings.SplitN(strings.TrimSpace(s), "|", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	var opts []string
+	json.Unmarshal([]byte(parts[2]), &opts)
+	for _, o := range opts {
+		if o == "name=rootless" {
+			rootless = true
+		}
+	}
+	return parts[0], parseCgroupVersion(parts[1]), rootless
+}
+
+func parseCgroupVersion(s string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "v"))
+	return n
+}
+
+// command builds an exec.Cmd for the runtime binary.
+func (rt *containerRuntime) command(args ...string) *exec.Cmd {
+	return exec.Command(rt.Path, args...)
+}
+
+// userArgs returns create flags for user mapping. Rootless podman maps the
+// container's root to the invoking user; keep-id keeps the host UID inside
+// the container so files written to the mounted workspace stay owned by us.
+func (rt *containerRuntime) userArgs(hasWorkspace bool) []string {
+	if rt.Name == "podman" && rt.Rootless && hasWorkspace {
+		return []string{"--userns=keep-id"}
+	}
+	return nil
+}
+
+// resourceArgs returns create flags for the requested limits, dropping any the
+// host cannot enforce. Rootless containers on cgroup v1 cannot be limited at
+// all; on cgroup v2 only controllers delegated to the user are available.
+// ignored lists the limits that were dropped.
+func (rt *containerRuntime) resourceArgs(memLimit, cpuLimit string) (args, ignored []string) {
+	var controllers map[string]bool
+	if rt.Rootless {
+		if rt.CgroupVersion != 2 {
+			if memLimit != "" {
+				ignored = append(ignored, "memLimit")
+			}
+			if cpuLimit != "" {
+				ignored = append(ignored, "cpuLimit")
+			}
+			return nil, ignored
+		}
+		controllers = delegatedControllers()
+	}
+	allowed := func(ctrl string) bool { return controllers == nil || controllers[ctrl] }
+
+	if memLimit != "" {
+		if allowed("memory") {
+			args = append(args, "--memory", memLimit)
+		} else {
+			ignored = append(ignored, "memLimit")
+		}
+	}
+	if cpuLimit != "" {
+		if allowed("cpu") {
+			args = append(args, "--cpus", cpuLimit)
+		} else {
+			ignored = append(ignored, "cpuLimit")
+		}
+	}
+	return args, ignored
+}
+
+// cgroupRoot is overridden in tests.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// delegatedControllers reads the cgroup v2 controllers systemd delegated to
+// the current user. It returns nil when that cannot be determined, in which
+// case limits are passed through and the runtime reports any failure.
+func delegatedControllers() map[string]bool {
+	uid := os.Getuid()
+	path := filepath.Join(cgroupRoot, "user.slice",
+		fmt.Sprintf("user-%d.slice", uid), fmt.Sprintf("user@%d.service", uid), "cgroup.controllers")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	controllers := make(map[string]bool)
+	for _, c := range strings.Fields(string(data)) {
+		controllers[c] = true
+	}
+	return controllers
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRuntimeInfo(t *testing.T) {
+	v, cg, rootless := parsePodmanInfo("4.9.3|v2|true\n")
+	if v != "4.9.3" || cg != 2 || !rootless {
+		t.Errorf("podman: got %q %d %v", v, cg, rootless)
+	}
+
+	v, cg, rootless = parseDockerInfo(`24.0.7|2|["name=seccomp,profile=builtin","name=rootless","name=cgroupns"]`)
+	if v != "24.0.7" || cg != 2 || !rootless {
+		t.Errorf("docker rootless: got %q %d %v", v, cg, rootless)
+	}
+
+	v, cg, rootless = parseDockerInfo(`20.10.21|1|["name=apparmor"]`)
+	if v != "20.10.21" || cg != 1 || rootless {
+		t.Errorf("docker rootful: got %q %d %v", v, cg, rootless)
+	}
+}
+
+func TestResourceArgs(t *testing.T) {
+	// Point the delegation lookup at a fake cgroup tree with only memory delegated.
+	root := t.TempDir()
+	uid := os.Getuid()
+	dir := filepath.Join(root, "user.slice", fmt.Sprintf("user-%d.slice", uid), fmt.Sprintf("user@%d.service", uid))
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("memory pids\n"), 0o644)
+	old := cgroupRoot
+	cgroupRoot = root
+	defer func() { cgroupRoot = old }()
+
+	tests := []struct {
+		name        string
+		rt          containerRuntime
+		wantArgs    []string
+		wantIgnored []string
+	}{
+		{"rootful", containerRuntime{Name: "docker", CgroupVersion: 1},
+			[]string{"--memory", "512m", "--cpus", "1"}, nil},
+		{"rootless v1", containerRuntime{Name: "podman", Rootless: true, CgroupVersion: 1},
+			nil, []string{"memLimit", "cpuLimit"}},
+		{"rootless v2 partial delegation", containerRuntime{Name: "podman", Rootless: true, CgroupVersion: 2},
+			[]string{"--memory", "512m"}, []string{"cpuLimit"}},
+	}
+	for _, tt := range tests {
+		args, ignored := tt.rt.resourceArgs("512m", "1")
+		if !reflect.DeepEqual(args, tt.wantArgs) || !reflect.DeepEqual(ignored, tt.wantIgnored) {
+			t.Errorf("%s: got args=%v ignored=%v, want %v %v", tt.name, args, ignored, tt.wantArgs, tt.wantIgnored)
+		}
+	}
+}
+
+func TestUserArgs(t *testing.T) {
+	rootless := containerRuntime{Name: "podman", Rootless: true}
+	if got := rootless.userArgs(true); !reflect.DeepEqual(got, []string{"--userns=keep-id"}) {
+		t.Errorf("rootless podman with workspace: %v", got)
+	}
+	if got := rootless.userArgs(false); got != nil {
+		t.Errorf("rootless podman without workspace: %v", got)
+	}
+	if got := (&containerRuntime{Name: "docker", Rootless: true}).userArgs(true); got != nil {
+		t.Errorf("rootless docker: %v", got)
+	}
+}
+
+func TestDetectRuntimeUnknown(t *testing.T) {
+	if _, err := detectRuntime("lxc"); err == nil {
+		t.Error("expected error for unknown runtime")
+	}
+}
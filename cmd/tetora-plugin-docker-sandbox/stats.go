@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- Output Caps ---
+
+// maxOutputBytes is the default per-stream cap for sandbox/exec output
+// (--max-output). A request may lower or raise it with maxOutputBytes.
+var maxOutputBytes = 1 << 20
+
+// cappedBuffer keeps the first max bytes written and counts the rest, so a
+// runaway command cannot balloon the plugin's memory or the JSON-RPC reply.
+// It is safe to read while the command is still writing (timeout path).
+type cappedBuffer struct {
+	mu      sync.Mutex
+	max     int
+	buf     []byte
+	dropped int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := b.max - len(b.buf)
+	if room > len(p) {
+		room = len(p)
+	}
+	if room > 0 {
+		b.buf = append(b.buf, p[:room]...)
+	}
+	b.dropped += int64(len(p) - room)
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+func (b *cappedBuffer) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// --- OOM Detection ---
+
+// oomKillCount reads the oom_kill counter of the container's cgroup v2
+// memory controller. It returns -1 when unavailable (cgroup v1, no cat).
+func oomKillCount(rt *containerRuntime, sandboxID string) int {
+	out, err := rt.command("exec", sandboxID, "cat", "/sys/fs/cgroup/memory.events").Output()
+	if err != nil {
+		return -1
+	}
+	return parseOOMKills(string(out))
+}
+
+func parseOOMKills(memoryEvents string) int {
+	for _, line := range strings.Split(memoryEvents, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), " "); ok && k == "oom_kill" {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// wasOOMKilled decides whether an exec that died with SIGKILL (exit 137) was
+// killed by the OOM killer. Exec'd processes do not set the container's
+// OOMKilled flag, so the cgroup counter is compared with the last one seen;
+// the container flag covers runtimes where the counter cannot be read.
+func wasOOMKilled(rt *containerRuntime, c *container) bool {
+	if n := oomKillCount(rt, c.ID); n >= 0 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		killed := n > c.OOMKills
+		c.OOMKills = n
+		return killed
+	}
+	out, err := rt.command("inspect", "--format", "{{.State.OOMKilled}}", c.ID).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// --- Stats ---
+
+// containerStats is one container's live resource usage.
+type containerStats struct {
+	SandboxID        string  `json:"sandboxId"`
+	SessionID        string  `json:"sessionId,omitempty"`
+	CPUPercent       float64 `json:"cpuPercent"`
+	MemoryBytes      int64   `json:"memoryBytes"`
+	MemoryLimitBytes int64   `json:"memoryLimitBytes,omitempty"`
+	MemoryPercent    float64 `json:"memoryPercent"`
+	PIDs             int     `json:"pids"`
+	DiskBytes        int64   `json:"diskBytes"` // writable layer
+	Error            string  `json:"error,omitempty"`
+}
+
+func handleStats(req jsonRPCRequest, store *containerStore) {
+	var params struct {
+		SandboxID string `json:"sandboxId"` // empty = all sandboxes
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			writeError(req.ID, -32602, "invalid params: "+err.Error())
+			return
+		}
+	}
+
+	var targets []*container
+	if params.SandboxID != "" {
+		c, ok := store.get(params.SandboxID)
+		if !ok {
+			writeError(req.ID, -32000, fmt.Sprintf("sandbox %q not found", params.SandboxID))
+			return
+		}
+		targets = append(targets, c)
+	} else {
+		targets = store.list()
+	}
+
+	rt, err := currentRuntime()
+	if err != nil {
+		writeError(req.ID, -32000, err.Error())
+		return
+	}
+
+	stats := make([]containerStats, 0, len(targets))
+	for _, c := range targets {
+		stats = append(stats, collectStats(rt, c))
+	}
+	writeResult(req.ID, map[string]any{"containers": stats})
+}
+
+// collectStats reads usage with the runtime's one-shot stats and the
+// container's writable layer size. The template fields are shared by docker
+// and podman.
+func collectStats(rt *containerRuntime, c *container) containerStats {
+	s := containerStats{SandboxID: c.ID, SessionID: c.SessionID}
+
+	out, err := rt.command("stats", "--no-stream", "--format",
+		"{{.CPUPerc}}|{{.MemUsage}}|{{.MemPerc}}|{{.PIDs}}", c.ID).Output()
+	if err != nil {
+		s.Error = fmt.Sprintf("%s stats failed: %v", rt.Name, err)
+		return s
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(out)), "|", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	s.CPUPercent = parsePercent(parts[0])
+	usage, limit, _ := strings.Cut(parts[1], "/")
+	s.MemoryBytes = parseSize(usage)
+	s.MemoryLimitBytes = parseSize(limit)
+	s.MemoryPercent = parsePercent(parts[2])
+	s.PIDs, _ = strconv.Atoi(strings.TrimSpace(parts[3]))
+
+	if out, err := rt.command("ps", "-a", "--size", "--filter", "id="+c.ID, "--format", "{{.Size}}").Output(); err == nil {
+		// "12.3kB (virtual 77.8MB)": the first figure is the writable layer.
+		size, _, _ := strings.Cut(strings.TrimSpace(string(out)), " (")
+		s.DiskBytes = parseSize(size)
+	}
+	return s
+}
+
+func parsePercent(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	return f
+}
+
+// parseSize parses the human sizes printed by docker/podman: "10.5MiB",
+// "1GiB", "12.3kB", "77.8MB", "0B".
+func parseSize(s string) int64 {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0
+	}
+	units := map[string]float64{
+		"": 1, "B": 1,
+		"kB": 1e3, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+		"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+	}
+	mult, ok := units[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0
+	}
+	return int64(n * mult)
+}
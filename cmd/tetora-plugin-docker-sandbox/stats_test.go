@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{max: 5}
+	b.Write([]byte("abc"))
+	n, err := b.Write([]byte("defgh"))
+	if n != 5 || err != nil {
+		t.Fatalf("Write must report full length so the command is not killed by EPIPE: n=%d err=%v", n, err)
+	}
+	if b.String() != "abcde" || b.Dropped() != 3 {
+		t.Errorf("got %q dropped=%d, want \"abcde\" dropped=3", b.String(), b.Dropped())
+	}
+
+	res := execResult(b, &cappedBuffer{max: 5}, 0)
+	if res["stdoutTruncated"] != int64(3) {
+		t.Errorf("stdoutTruncated = %v", res["stdoutTruncated"])
+	}
+	if _, ok := res["stderrTruncated"]; ok {
+		t.Error("stderr was not truncated")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"0B":     0,
+		"512B":   512,
+		"12.5kB": 12500,
+		"1.5MiB": 1572864,
+		"77.8MB": 77800000,
+		" 2GiB ": 2 << 30,
+		"bogus":  0,
+		"10XB":   0,
+	}
+	for in, want := range tests {
+		if got := parseSize(in); got != want {
+			t.Errorf("parseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+	if got := parsePercent(" 12.34% "); got != 12.34 {
+		t.Errorf("parsePercent = %v", got)
+	}
+}
+
+func TestParseOOMKills(t *testing.T) {
+	events := strings.Join([]string{"low 0", "high 0", "max 12", "oom 3", "oom_kill 2", "oom_group_kill 0"}, "\n")
+	if got := parseOOMKills(events); got != 2 {
+		t.Errorf("parseOOMKills = %d, want 2", got)
+	}
+	if got := parseOOMKills("cat: no such file"); got != -1 {
+		t.Errorf("parseOOMKills(garbage) = %d, want -1", got)
+	}
+}
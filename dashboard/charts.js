@@ -649,6 +649,25 @@ function openWfRun(runId) {
   });
 }
 
+// Diagram export: a fenced Mermaid block pastes straight into Markdown notes.
+async function copyWfRunMermaid() {
+  if (!currentWfRun) return;
+  try {
+    var resp = await fetch('/workflow-runs/' + encodeURIComponent(currentWfRun) + '/diagram?format=mermaid', {credentials:'same-origin'});
+    if (!resp.ok) throw new Error('HTTP ' + resp.status);
+    var text = await resp.text();
+    await navigator.clipboard.writeText('```mermaid\n' + text + '```\n');
+    toast('Mermaid diagram copied');
+  } catch (e) {
+    toast('Copy failed: ' + e.message);
+  }
+}
+
+function downloadWfRunDiagram(format) {
+  if (!currentWfRun) return;
+  window.location.href = '/workflow-runs/' + encodeURIComponent(currentWfRun) + '/diagram?format=' + format + '&download=1';
+}
+
 // Step results list below DAG
 function renderWfStepList(data) {
   var container = document.getElementById('wf-step-list');
@@ -1392,3 +1411,63 @@ function escAttr(s) {
 }
 
 // --- End Workflow Visualization ---
+
+// --- Trace Waterfall ---
+// One row per event of a trace, offset by start time and sized by duration.
+// Point events (audit entries, notifications) are drawn as markers.
+
+function openTraceModal(traceId) {
+  document.getElementById('trace-modal').classList.add('open');
+  document.getElementById('trace-waterfall').innerHTML = '';
+  if (traceId) {
+    document.getElementById('trace-id').value = traceId;
+    loadTrace(traceId);
+  } else {
+    document.getElementById('trace-id').focus();
+  }
+}
+
+function closeTraceModal() {
+  document.getElementById('trace-modal').classList.remove('open');
+}
+
+async function loadTrace(traceId) {
+  var el = document.getElementById('trace-waterfall');
+  if (!traceId) return;
+  el.innerHTML = '<div class="trace-empty">Loading...</div>';
+  try {
+    var data = await fetchJSON('/traces/' + encodeURIComponent(traceId));
+    el.innerHTML = renderTraceWaterfall(data.events || []);
+  } catch (e) {
+    el.innerHTML = '<div class="trace-empty">' + esc(e.message) + '</div>';
+  }
+}
+
+function renderTraceWaterfall(events) {
+  if (!events.length) return '<div class="trace-empty">No events</div>';
+  var t0 = Infinity, t1 = -Infinity;
+  events.forEach(function(ev) {
+    var s = Date.parse(ev.startedAt);
+    t0 = Math.min(t0, s);
+    t1 = Math.max(t1, s + (ev.durationMs || 0));
+  });
+  var total = Math.max(t1 - t0, 1);
+  var rows = events.map(function(ev) {
+    var offset = Date.parse(ev.startedAt) - t0;
+    var left = (offset / total * 100).toFixed(2);
+    var bar = ev.durationMs > 0
+      ? '<div class="trace-bar trace-' + esc(ev.kind) + '" style="left:' + left + '%;width:' + Math.max(ev.durationMs / total * 100, 0.5).toFixed(2) + '%"></div>'
+      : '<div class="trace-mark trace-' + esc(ev.kind) + '" style="left:' + left + '%"></div>';
+    var failed = ev.status === 'error' || /^[45]\d\d$/.test(ev.status || '');
+    var title = [ev.startedAt, ev.status, ev.detail].filter(Boolean).join('\n');
+    return '<div class="trace-row" title="' + escAttr(title) + '">' +
+      '<div class="trace-label"><span class="trace-kind">' + esc(ev.kind) + '</span> ' + esc(ev.name) + '</div>' +
+      '<div class="trace-track">' + bar + '</div>' +
+      '<div class="trace-meta' + (failed ? ' trace-failed' : '') + '">' +
+        esc(ev.status || '') + (ev.durationMs > 0 ? ' · ' + formatDuration(ev.durationMs) : '') + ' · +' + formatDuration(offset) +
+      '</div></div>';
+  });
+  return '<div class="trace-summary">' + events.length + ' events · ' + formatDuration(t1 - t0) + '</div>' + rows.join('');
+}
+
+// --- End Trace Waterfall ---
@@ -292,6 +292,25 @@ async function fetchAPI(url, opts) {
   return fetchJSON(url, opts);
 }
 
+// --- Dashboard Role ---
+// authInfo is the signed-in login from /api/auth/me. Viewers get a read-only
+// dashboard: run and add controls are hidden (the server refuses them anyway).
+let authInfo = null;
+
+async function loadAuthInfo() {
+  try { authInfo = await fetchJSON('/api/auth/me'); } catch (e) { return; }
+  const caps = authInfo.capabilities || {};
+  document.body.classList.toggle('read-only', caps.dispatch === false);
+  const traceBtn = document.getElementById('trace-btn');
+  if (traceBtn && caps.audit === false) traceBtn.style.display = 'none';
+  const badge = document.getElementById('role-badge');
+  if (badge && authInfo.role && authInfo.role !== 'admin') {
+    badge.textContent = authInfo.account ? authInfo.account + ' (' + authInfo.role + ')' : authInfo.role;
+    badge.style.display = '';
+  }
+}
+loadAuthInfo();
+
 function toast(msg) {
   const el = document.getElementById('toast');
   el.textContent = msg;
@@ -321,7 +340,7 @@ function _dismissAlert(id) {
   _renderAlerts();
 }
 
-function checkAlerts(cost, doingTasks) {
+function checkAlerts(cost, doingTasks, health) {
   var alerts = [];
   var now = Date.now();
   var TIMEOUT_MS = 30 * 60 * 1000;
@@ -363,6 +382,22 @@ function checkAlerts(cost, doingTasks) {
     });
   }
 
+  // GitOps: a rejected commit or failed fetch, and local edits to managed files
+  var gitops = health && health.gitops;
+  if (gitops) {
+    if (gitops.error) {
+      alerts.push({ id: 'gitops-error', level: 'error', msg: 'GitOps: ' + gitops.error.split('\n')[0] });
+    }
+    if (Array.isArray(gitops.drift) && gitops.drift.length > 0) {
+      var drifted = gitops.drift.map(function(d) { return d.path; });
+      alerts.push({
+        id: 'gitops-drift-' + gitops.revision,
+        level: 'warn',
+        msg: 'GitOps drift: ' + drifted.length + ' file' + (drifted.length === 1 ? '' : 's') + ' edited locally (' + drifted.slice(0, 3).join(', ') + (drifted.length > 3 ? ', ...' : '') + ')'
+      });
+    }
+  }
+
   // Remove stale dismissals (alert no longer active)
   var activeIds = new Set(alerts.map(function(a) { return a.id; }));
   _dismissedAlerts.forEach(function(id) {
@@ -456,7 +491,7 @@ async function refresh() {
     loadAgentScorecard();
 
     // Alert banner
-    checkAlerts(cost, doingTasks);
+    checkAlerts(cost, doingTasks, health);
 
     // Onboarding banner
     var ob = document.getElementById('onboarding-banner');
@@ -132,7 +132,7 @@ function refreshOperationsSubTab(sub) {
   if (sub === 'workflows') { refreshWorkflowRuns(); loadWorkflowDefs(); }
   if (sub === 'tasks') refreshBoard();
   if (sub === 'capabilities') refreshCapabilities();
-  if (sub === 'files') loadMemoryBrowser();
+  if (sub === 'files') { loadMemoryBrowser(); loadMemoryGraph(); }
 }
 
 function refreshSettingsSubTab(sub) {
@@ -457,8 +457,9 @@ function renderChatBubble(m) {
   var isUser = m.role === 'user';
   var isSys = m.role === 'system';
   var bubbleCls = isUser ? 'chat-bubble-user' : isSys ? 'chat-bubble-system' : 'chat-bubble-agent';
-  var label = isUser ? 'You' : isSys ? 'System' : chatSessionRole;
-  var labelColor = isUser ? 'var(--accent)' : isSys ? 'var(--muted)' : chatRoleColor(chatSessionRole);
+  var agent = m.agent || chatSessionRole; // group sessions name the replying agent
+  var label = isUser ? 'You' : isSys ? 'System' : agent;
+  var labelColor = isUser ? 'var(--accent)' : isSys ? 'var(--muted)' : chatRoleColor(agent);
   var contentHTML = isUser
     ? '<div style="white-space:pre-wrap;word-break:break-word">' + esc(m.content || '') + '</div>'
     : '<div class="md-rendered">' + renderMarkdown(m.content || '') + '</div>';
@@ -126,6 +126,10 @@ function inlineFormat(text) {
   text = text.replace(/\*\*(.+?)\*\*/g, '<strong>$1</strong>');
   // Italic.
   text = text.replace(/\*(.+?)\*/g, '<em>$1</em>');
+  // Charts from chart_render. Only the chart route is loaded as an image.
+  text = text.replace(/!\[([^\]]*)\]\((\/api\/charts\/[^)\s"]+)\)/g, function(_, alt, url) {
+    return '<img class="md-chart" src="' + url + '" alt="' + alt.replace(/"/g, '&quot;') + '" loading="lazy">';
+  });
   // Links. Restrict href to http(s) or relative paths to block javascript: /
   // data: scheme XSS (renderMarkdown is also used for task/memory views where
   // input may not be fully trusted).
@@ -681,7 +685,7 @@ async function viewSession(shortId, fullId) {
       const isUser = m.role === 'user';
       const isSys = m.role === 'system';
       const bgColor = isUser ? 'color-mix(in srgb, var(--accent) 12%, var(--surface))' : isSys ? 'var(--surface)' : 'color-mix(in srgb, var(--green) 10%, var(--surface))';
-      const label = isUser ? 'USER' : isSys ? 'SYSTEM' : (s.agent || 'AGENT');
+      const label = isUser ? 'USER' : isSys ? 'SYSTEM' : esc(m.agent || s.agent || 'AGENT');
       const labelColor = isUser ? 'var(--accent)' : isSys ? 'var(--muted)' : 'var(--green)';
       const costInfo = m.costUsd > 0 ? ` | ${costFmt(m.costUsd)}` : '';
       const modelInfo = m.model ? ` | ${esc(m.model)}` : '';
@@ -148,8 +148,8 @@ function refreshTriggers() {
       if (!triggers.length) { el.innerHTML = '<div style="color:var(--muted);padding:12px">No workflow triggers configured. Click "+ Create" to add one.</div>'; return; }
       var html = '';
       triggers.forEach(function(t) {
-        var typeBadge = t.type === 'cron' ? '&#9200; cron' : t.type === 'event' ? '&#9889; event' : '&#128279; webhook';
-        var typeColor = t.type === 'cron' ? '#60a5fa' : t.type === 'event' ? '#fbbf24' : '#a78bfa';
+        var typeBadge = t.type === 'cron' ? '&#9200; cron' : t.type === 'event' ? '&#9889; event' : t.type === 'file' ? '&#128193; file' : '&#128279; webhook';
+        var typeColor = t.type === 'cron' ? '#60a5fa' : t.type === 'event' ? '#fbbf24' : t.type === 'file' ? '#34d399' : '#a78bfa';
         var detail = '';
         if (t.type === 'cron' && t.nextCron) {
           detail = 'Next: ' + t.nextCron.substring(0, 19).replace('T', ' ');
@@ -278,6 +278,8 @@ function openTriggerModal(editName) {
     document.getElementById('trig-tz').value = '';
     document.getElementById('trig-event').value = '';
     document.getElementById('trig-webhook').value = '';
+    document.getElementById('trig-paths').value = '';
+    document.getElementById('trig-debounce').value = '';
     document.getElementById('trig-cooldown').value = '';
     document.getElementById('trig-vars').value = '';
     document.getElementById('trig-enabled').checked = true;
@@ -295,6 +297,7 @@ function updateTriggerTypeFields() {
   document.getElementById('trig-cron-fields').style.display = type === 'cron' ? '' : 'none';
   document.getElementById('trig-event-fields').style.display = type === 'event' ? '' : 'none';
   document.getElementById('trig-webhook-fields').style.display = type === 'webhook' ? '' : 'none';
+  document.getElementById('trig-file-fields').style.display = type === 'file' ? '' : 'none';
 }
 
 function saveTrigger() {
@@ -314,6 +317,10 @@ function saveTrigger() {
     payload.trigger.event = document.getElementById('trig-event').value.trim();
   } else if (type === 'webhook') {
     payload.trigger.webhook = document.getElementById('trig-webhook').value.trim();
+  } else if (type === 'file') {
+    payload.trigger.paths = document.getElementById('trig-paths').value.split('\n').map(function(p) { return p.trim(); }).filter(Boolean);
+    payload.trigger.events = ['create', 'modify', 'delete'].filter(function(e) { return document.getElementById('trig-ev-' + e).checked; });
+    payload.trigger.debounce = document.getElementById('trig-debounce').value.trim();
   }
   var varsText = document.getElementById('trig-vars').value.trim();
   if (varsText) {
@@ -454,6 +461,7 @@ function refreshSettings() {
       refreshNotificationsTable();
       refreshDiscordSettings();
       refreshHooksStatus();
+      refreshTOTPStatus();
     })
     .catch(function(e) {
       document.getElementById('settings-loading').innerHTML = '<span style="color:var(--red)">Failed to load: ' + e + '</span>';
@@ -766,6 +774,81 @@ function removeHooksFromDashboard() {
     });
 }
 
+// --- Two-Factor Authentication (dashboard password login) ---
+
+function refreshTOTPStatus() {
+  fetchJSON('/api/auth/totp').then(function(d) {
+    var section = document.getElementById('settings-totp-section');
+    section.style.display = d.available ? '' : 'none';
+    if (!d.available) return;
+    var el = document.getElementById('settings-totp');
+    var who = d.account ? esc(d.account) : 'the main password';
+    var st = d.status || {};
+    if (st.enabled) {
+      el.innerHTML = '<div class="totp-box">' +
+        '<div><span style="color:var(--green)">Enabled</span> for ' + who +
+        (st.enabledAt ? ' &middot; since ' + esc(st.enabledAt.slice(0, 10)) : '') +
+        ' &middot; ' + st.recoveryCodes + ' recovery code(s) left</div>' +
+        '<div class="totp-row"><input id="totp-code" class="totp-input" placeholder="Code or recovery code" autocomplete="one-time-code">' +
+        '<button class="btn" onclick="disableTOTP()" style="background:var(--red);color:#fff">Disable</button></div>' +
+        '<div id="totp-result" class="totp-result"></div></div>';
+      return;
+    }
+    el.innerHTML = '<div class="totp-box">' +
+      '<div><span style="color:var(--yellow)">Not enabled</span> for ' + who +
+      ' &mdash; sign-in asks only for the password.</div>' +
+      '<div class="totp-row"><button class="btn" onclick="startTOTPSetup()">Set up authenticator app</button></div>' +
+      '<div id="totp-result" class="totp-result"></div></div>';
+  }).catch(function() {
+    document.getElementById('settings-totp-section').style.display = 'none';
+  });
+}
+
+function startTOTPSetup() {
+  fetchJSON('/api/auth/totp/setup', {method: 'POST'}).then(function(d) {
+    document.getElementById('settings-totp').innerHTML = '<div class="totp-box">' +
+      '<div>Scan this QR code with your authenticator app, or add the key by hand:</div>' +
+      (d.qr ? '<div class="totp-qr">' + d.qr + '</div>' : '') +
+      '<div class="totp-secret">' + esc(d.secret.replace(/(.{4})/g, '$1 ').trim()) + '</div>' +
+      '<div class="totp-uri">' + esc(d.uri) + '</div>' +
+      '<div class="totp-row"><input id="totp-code" class="totp-input" placeholder="6-digit code" autocomplete="one-time-code">' +
+      '<button class="btn" onclick="confirmTOTPSetup()">Verify &amp; enable</button>' +
+      '<button class="btn" onclick="refreshTOTPStatus()">Cancel</button></div>' +
+      '<div id="totp-result" class="totp-result"></div></div>';
+  }).catch(function(e) {
+    toast('2FA setup failed: ' + e.message);
+  });
+}
+
+function confirmTOTPSetup() {
+  var code = document.getElementById('totp-code').value;
+  fetchJSON('/api/auth/totp/verify', {
+    method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify({code: code})
+  }).then(function(d) {
+    document.getElementById('settings-totp').innerHTML = '<div class="totp-box">' +
+      '<div><span style="color:var(--green)">Two-factor authentication enabled.</span> ' +
+      'Save these recovery codes now; each works once if you lose your device, and they will not be shown again.</div>' +
+      '<div class="totp-codes">' + d.recoveryCodes.map(esc).join('<br>') + '</div>' +
+      '<div class="totp-row"><button class="btn" onclick="refreshTOTPStatus()">Done</button></div></div>';
+  }).catch(function(e) {
+    document.getElementById('totp-result').innerHTML = '<span style="color:var(--red)">' + esc(e.message) + '</span>';
+  });
+}
+
+function disableTOTP() {
+  var code = document.getElementById('totp-code').value;
+  if (!code) { document.getElementById('totp-code').focus(); return; }
+  if (!confirm('Turn off two-factor authentication for this login?')) return;
+  fetchJSON('/api/auth/totp/disable', {
+    method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify({code: code})
+  }).then(function() {
+    toast('Two-factor authentication disabled');
+    refreshTOTPStatus();
+  }).catch(function(e) {
+    document.getElementById('totp-result').innerHTML = '<span style="color:var(--red)">' + esc(e.message) + '</span>';
+  });
+}
+
 function renderSettingsKV(el, items) {
   var html = '<div style="display:grid;grid-template-columns:1fr 1fr;gap:1px;background:var(--border);border:1px solid var(--border);border-radius:8px;overflow:hidden">';
   items.forEach(function(item) {
@@ -1420,6 +1503,217 @@ async function saveMemoryFile() {
 
 function escapeHtml(s) { var d = document.createElement('div'); d.textContent = s; return d.innerHTML; }
 
+// --- Memory Graph ---
+// A force-directed view of /memory/graph: memory keys, knowledge files and
+// contacts, linked where one references another.
+var _mg = { nodes: [], edges: [], byId: {}, selected: null, hover: null, drag: null, alpha: 0, raf: 0 };
+var MG_COLORS = { memory: '--accent', knowledge: '--accent2', contact: '--green' };
+
+async function loadMemoryGraph() {
+  var canvas = document.getElementById('memory-graph-canvas');
+  if (!canvas) return;
+  var types = Array.from(document.querySelectorAll('.mg-type:checked')).map(function(el) { return el.value; });
+  var meta = document.getElementById('memory-graph-meta');
+  var g;
+  try {
+    g = await fetchJSON('/memory/graph?types=' + encodeURIComponent(types.join(',')));
+  } catch(e) { meta.textContent = 'unavailable'; return; }
+  if (!types.length) g = { nodes: [], edges: [], stats: { orphans: 0 } };
+
+  // Keep the positions of nodes that were already laid out.
+  var old = _mg.byId, w = canvas.clientWidth || 600, h = canvas.clientHeight || 440;
+  _mg.byId = {};
+  _mg.nodes = (g.nodes || []).map(function(n) {
+    var prev = old[n.id];
+    n.x = prev ? prev.x : w / 2 + (Math.random() - 0.5) * w * 0.6;
+    n.y = prev ? prev.y : h / 2 + (Math.random() - 0.5) * h * 0.6;
+    n.vx = 0; n.vy = 0;
+    _mg.byId[n.id] = n;
+    return n;
+  });
+  _mg.edges = (g.edges || []).filter(function(e) { return _mg.byId[e.source] && _mg.byId[e.target]; });
+  if (_mg.selected && !_mg.byId[_mg.selected.id]) _mg.selected = null;
+  meta.textContent = _mg.nodes.length + ' nodes · ' + _mg.edges.length + ' links · ' + (g.stats.orphans || 0) + ' orphans';
+  bindMemoryGraph(canvas);
+  kickMemoryGraph(1);
+  renderMemoryGraphDetail();
+}
+
+function kickMemoryGraph(alpha) {
+  _mg.alpha = Math.max(_mg.alpha, alpha);
+  if (!_mg.raf) _mg.raf = requestAnimationFrame(stepMemoryGraph);
+}
+
+function stepMemoryGraph() {
+  _mg.raf = 0;
+  var canvas = document.getElementById('memory-graph-canvas');
+  if (!canvas || !canvas.offsetParent) return; // hidden tab: stop until shown again
+  var nodes = _mg.nodes, a = _mg.alpha, cx = canvas.clientWidth / 2, cy = canvas.clientHeight / 2;
+  for (var i = 0; i < nodes.length; i++) {
+    var p = nodes[i];
+    for (var j = i + 1; j < nodes.length; j++) {
+      var q = nodes[j], dx = p.x - q.x, dy = p.y - q.y, d2 = dx * dx + dy * dy || 0.01;
+      if (d2 > 90000) continue;
+      var f = 900 / d2 * a;
+      p.vx += dx * f; p.vy += dy * f; q.vx -= dx * f; q.vy -= dy * f;
+    }
+    p.vx += (cx - p.x) * 0.004 * a; p.vy += (cy - p.y) * 0.004 * a;
+  }
+  _mg.edges.forEach(function(e) {
+    var s = _mg.byId[e.source], t = _mg.byId[e.target];
+    var dx = t.x - s.x, dy = t.y - s.y, d = Math.sqrt(dx * dx + dy * dy) || 1;
+    var f = (d - 70) / d * 0.05 * a;
+    s.vx += dx * f; s.vy += dy * f; t.vx -= dx * f; t.vy -= dy * f;
+  });
+  nodes.forEach(function(n) {
+    if (n === _mg.drag) { n.vx = 0; n.vy = 0; return; }
+    n.vx *= 0.8; n.vy *= 0.8;
+    n.x = Math.max(8, Math.min(canvas.clientWidth - 8, n.x + n.vx));
+    n.y = Math.max(8, Math.min(canvas.clientHeight - 8, n.y + n.vy));
+  });
+  _mg.alpha *= 0.97;
+  drawMemoryGraph();
+  if (_mg.alpha > 0.02 || _mg.drag) _mg.raf = requestAnimationFrame(stepMemoryGraph);
+}
+
+function memoryGraphRadius(n) { return 4 + Math.min(10, Math.sqrt(n.degree) * 2); }
+
+function drawMemoryGraph() {
+  var canvas = document.getElementById('memory-graph-canvas');
+  if (!canvas) return;
+  var dpr = window.devicePixelRatio || 1, w = canvas.clientWidth, h = canvas.clientHeight;
+  if (canvas.width !== w * dpr || canvas.height !== h * dpr) { canvas.width = w * dpr; canvas.height = h * dpr; }
+  var ctx = canvas.getContext('2d'), css = getComputedStyle(document.documentElement);
+  ctx.setTransform(dpr, 0, 0, dpr, 0, 0);
+  ctx.clearRect(0, 0, w, h);
+  var orphansOnly = document.getElementById('mg-orphans').checked;
+  var sel = _mg.selected, near = {};
+  if (sel) {
+    near[sel.id] = true;
+    _mg.edges.forEach(function(e) { if (e.source === sel.id) near[e.target] = true; if (e.target === sel.id) near[e.source] = true; });
+  }
+  var dim = function(n) { return (orphansOnly && !n.orphan) || (sel && !near[n.id]); };
+
+  ctx.lineWidth = 1;
+  _mg.edges.forEach(function(e) {
+    var s = _mg.byId[e.source], t = _mg.byId[e.target];
+    ctx.globalAlpha = dim(s) || dim(t) ? 0.08 : 0.45;
+    ctx.strokeStyle = css.getPropertyValue('--muted').trim();
+    ctx.setLineDash(e.kind === 'mention' ? [3, 3] : []);
+    ctx.beginPath(); ctx.moveTo(s.x, s.y); ctx.lineTo(t.x, t.y); ctx.stroke();
+    if (e.kind === 'link') { // arrowhead toward the target
+      var ang = Math.atan2(t.y - s.y, t.x - s.x), r = memoryGraphRadius(t) + 2;
+      var ax = t.x - Math.cos(ang) * r, ay = t.y - Math.sin(ang) * r;
+      ctx.beginPath(); ctx.moveTo(ax, ay);
+      ctx.lineTo(ax - Math.cos(ang - 0.4) * 6, ay - Math.sin(ang - 0.4) * 6);
+      ctx.lineTo(ax - Math.cos(ang + 0.4) * 6, ay - Math.sin(ang + 0.4) * 6);
+      ctx.closePath(); ctx.fillStyle = ctx.strokeStyle; ctx.fill();
+    }
+  });
+  ctx.setLineDash([]);
+
+  ctx.font = '11px ' + css.getPropertyValue('--font-body');
+  _mg.nodes.forEach(function(n) {
+    var color = css.getPropertyValue(MG_COLORS[n.type] || '--muted').trim(), r = memoryGraphRadius(n);
+    ctx.globalAlpha = dim(n) ? 0.15 : 1;
+    ctx.beginPath(); ctx.arc(n.x, n.y, r, 0, Math.PI * 2);
+    if (n.orphan) {
+      ctx.setLineDash([2, 2]); ctx.strokeStyle = color; ctx.lineWidth = 1.5; ctx.stroke(); ctx.setLineDash([]);
+    } else {
+      ctx.fillStyle = color; ctx.fill();
+    }
+    if (n === sel || n === _mg.hover) { ctx.strokeStyle = css.getPropertyValue('--text').trim(); ctx.lineWidth = 2; ctx.stroke(); }
+    if (!dim(n) && (n.degree >= 3 || n === sel || n === _mg.hover || near[n.id])) {
+      ctx.fillStyle = css.getPropertyValue('--text').trim();
+      ctx.fillText(n.label, n.x + r + 3, n.y + 4);
+    }
+  });
+  ctx.globalAlpha = 1;
+}
+
+function memoryGraphNodeAt(canvas, ev) {
+  var rect = canvas.getBoundingClientRect(), x = ev.clientX - rect.left, y = ev.clientY - rect.top;
+  for (var i = _mg.nodes.length - 1; i >= 0; i--) {
+    var n = _mg.nodes[i], r = memoryGraphRadius(n) + 3;
+    if ((n.x - x) * (n.x - x) + (n.y - y) * (n.y - y) <= r * r) return n;
+  }
+  return null;
+}
+
+function bindMemoryGraph(canvas) {
+  if (canvas.dataset.bound) return;
+  canvas.dataset.bound = '1';
+  var moved = false;
+  canvas.addEventListener('mousedown', function(ev) {
+    _mg.drag = memoryGraphNodeAt(canvas, ev);
+    moved = false;
+    if (_mg.drag) kickMemoryGraph(0.3);
+  });
+  canvas.addEventListener('mousemove', function(ev) {
+    if (_mg.drag) {
+      var rect = canvas.getBoundingClientRect();
+      _mg.drag.x = ev.clientX - rect.left; _mg.drag.y = ev.clientY - rect.top;
+      moved = true;
+      kickMemoryGraph(0.3);
+      return;
+    }
+    var n = memoryGraphNodeAt(canvas, ev);
+    if (n !== _mg.hover) { _mg.hover = n; canvas.style.cursor = n ? 'pointer' : 'grab'; drawMemoryGraph(); }
+  });
+  window.addEventListener('mouseup', function() { _mg.drag = null; });
+  canvas.addEventListener('click', function(ev) {
+    if (moved) return;
+    _mg.selected = memoryGraphNodeAt(canvas, ev);
+    renderMemoryGraphDetail();
+    drawMemoryGraph();
+  });
+  var detail = document.getElementById('memory-graph-detail');
+  detail.addEventListener('click', function(ev) {
+    var link = ev.target.closest('[data-node]');
+    if (link) { _mg.selected = _mg.byId[link.getAttribute('data-node')] || null; renderMemoryGraphDetail(); drawMemoryGraph(); return; }
+    var del = ev.target.closest('[data-delete]');
+    if (del) deleteMemoryGraphNode(del.getAttribute('data-delete'));
+  });
+}
+
+function renderMemoryGraphDetail() {
+  var el = document.getElementById('memory-graph-detail');
+  if (!el) return;
+  var n = _mg.selected;
+  if (!n) { el.innerHTML = '<div class="search-empty">Click a node to see its links. Orphans (dashed) are referenced by nothing and reference nothing.</div>'; return; }
+  var row = function(k, v) { return v ? '<div><span style="color:var(--muted)">' + k + ':</span> ' + escapeHtml(String(v)) + '</div>' : ''; };
+  var list = function(title, ids) {
+    if (!ids.length) return '';
+    return '<div style="margin-top:10px;font-weight:600">' + title + '</div>' + ids.map(function(e) {
+      var m = _mg.byId[e.id];
+      return '<span class="mg-link" data-node="' + escapeHtml(e.id) + '">' + escapeHtml(m.type + ': ' + m.label) + (e.kind === 'mention' ? ' <em>(mention)</em>' : '') + '</span>';
+    }).join('');
+  };
+  var out = [], inc = [];
+  _mg.edges.forEach(function(e) {
+    if (e.source === n.id) out.push({ id: e.target, kind: e.kind });
+    if (e.target === n.id) inc.push({ id: e.source, kind: e.kind });
+  });
+  var html = '<div style="font-weight:600;font-size:14px;word-break:break-all">' + escapeHtml(n.label) + '</div>' +
+    row('Type', n.type) + row('Size', n.size + ' bytes') + row('Priority', n.priority) +
+    row('Updated', n.updatedAt) + row('Last accessed', n.lastAccessed || (n.type === 'memory' ? 'never' : '')) +
+    (n.orphan ? '<div style="color:var(--yellow);margin-top:6px">Orphan: nothing links here and it links nowhere.</div>' : '') +
+    list('References', out) + list('Referenced by', inc);
+  if (n.type === 'memory') html += '<button class="btn btn-del" style="margin-top:12px" data-delete="' + escapeHtml(n.label) + '">Delete memory</button>';
+  el.innerHTML = html;
+}
+
+async function deleteMemoryGraphNode(key) {
+  if (!confirm('Delete memory "' + key + '"?')) return;
+  try {
+    var resp = await fetch(API + '/memory/default/' + encodeURIComponent(key), { method: 'DELETE' });
+    if (!resp.ok) throw new Error('HTTP ' + resp.status);
+    toast('Memory "' + key + '" deleted');
+    _mg.selected = null;
+    loadMemoryGraph();
+  } catch(e) { toast('Delete failed: ' + e.message); }
+}
+
 // Event delegation for memory tree and editor
 (function() {
   document.addEventListener('click', function(e) {
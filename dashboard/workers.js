@@ -689,6 +689,7 @@ async function submitDispatch(e) {
         `<span>Cost: ${costFmt(t.costUsd || 0)}</span>`,
         `<span>Model: ${esc(t.model)}</span>`,
         `<span>${formatDuration(result.durationMs || 0)}</span>`,
+        t.traceId ? `<span><a href="#" onclick="openTraceModal('${escAttr(t.traceId)}');return false">Trace</a></span>` : '',
       ].join('');
 
       const tabFull = document.getElementById('tab-full');
@@ -622,7 +622,7 @@ function renderPropertyPanel(stepId) {
 
   var fields = [
     { key: 'id', label: 'ID', type: 'text', required: true, hint: 'Unique step identifier (alphanumeric + hyphens)' },
-    { key: 'type', label: 'Type', type: 'type-select', options: ['dispatch','skill','condition','parallel','tool_call','delay','notify','external','handoff'], hint: 'dispatch=Agent task, skill=Run skill, condition=Branch, handoff=Agent relay' },
+    { key: 'type', label: 'Type', type: 'type-select', options: ['dispatch','skill','condition','parallel','tool_call','delay','notify','external','handoff','review'], hint: 'dispatch=Agent task, skill=Run skill, condition=Branch, handoff=Agent relay, review=Draft and reviewer rounds' },
     { key: 'agent', label: 'Agent', type: 'agent-select', hint: 'Which agent executes this step' },
     { key: 'prompt', label: 'Prompt', type: 'textarea', hint: 'Task instructions for the agent. Supports {{variables}}' },
     { key: 'skill', label: 'Skill', type: 'skill-select', hint: 'Pre-defined skill to execute' },
@@ -634,6 +634,11 @@ function renderPropertyPanel(stepId) {
     { key: 'then', label: 'Then → Step', type: 'step-select', hint: 'Step to run when condition is true' },
     { key: 'else', label: 'Else → Step', type: 'step-select', hint: 'Step to run when condition is false' },
     { key: 'handoffFrom', label: 'Handoff From', type: 'step-select', hint: 'Source step whose output is passed to this agent' },
+    { key: 'reviewer', label: 'Reviewer', type: 'agent-select', hint: 'Agent that critiques the draft until it approves' },
+    { key: 'reviewPrompt', label: 'Review Criteria', type: 'textarea', hint: 'What the reviewer checks. Supports {{variables}}' },
+    { key: 'maxRounds', label: 'Max Rounds', type: 'number', hint: 'Review rounds before the last draft is kept (default 3, max 10)' },
+    { key: 'requireApproval', label: 'Require Approval', type: 'checkbox', hint: 'Fail the step if the reviewer never approves' },
+    { key: 'artifact', label: 'Artifact', type: 'text', placeholder: 'report.md', hint: 'File name of the final draft under outputs/reviews/' },
     { key: 'toolName', label: 'Tool Name', type: 'tool-select', hint: 'Registered tool to invoke' },
     { key: 'delay', label: 'Delay', type: 'delay', hint: 'Wait duration before proceeding: 30s, 5m, 1h' },
     { key: 'notifyMsg', label: 'Notify Message', type: 'text', hint: 'Message to send as notification. Supports {{variables}}' },
@@ -687,6 +692,11 @@ function renderPropertyPanel(stepId) {
     visibleFields = fields.filter(function(f) {
       return ['id','type','agent','prompt','handoffFrom','model','timeout','budget','dependsOn','retryMax','onError'].includes(f.key);
     });
+  } else if (stype === 'review') {
+    visibleFields = fields.filter(function(f) {
+      return ['id','type','agent','prompt','reviewer','reviewPrompt','maxRounds','requireApproval','artifact',
+              'model','timeout','budget','dependsOn','retryMax','onError'].includes(f.key);
+    });
   }
 
   var html = '<div style="padding:12px">';
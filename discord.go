@@ -14,8 +14,12 @@ import (
 	"time"
 
 	"tetora/internal/audit"
+	"tetora/internal/chart"
 	tetoraConfig "tetora/internal/config"
 	"tetora/internal/discord"
+	"tetora/internal/docgen"
+	"tetora/internal/feedback"
+	"tetora/internal/handover"
 	"tetora/internal/provider"
 	"tetora/internal/history"
 	"tetora/internal/log"
@@ -148,8 +152,8 @@ func newDiscordBot(cfg *Config, state *dispatchState, sem, childSem chan struct{
 			"defaultTool", cfg.Discord.Terminal.DefaultTool)
 	}
 
-	// P28.0: Initialize approval gate.
-	if cfg.ApprovalGates.Enabled {
+	// P28.0: Initialize approval gate (also carries spend approvals).
+	if cfg.ApprovalGates.Enabled || cfg.Estimate.ApprovalThreshold > 0 {
 		if ch := db.notifyChannelID(); ch != "" {
 			db.approvalGate = newDiscordApprovalGate(db, ch)
 		}
@@ -474,7 +478,41 @@ func (db *DiscordBot) handleMessage(msg discord.Message) {
 		return
 	}
 
-	// Per-channel route binding (highest priority).
+	// Handover: a conversation handed to the owner bypasses the agents.
+	if db.interceptHandover(msg, text) {
+		return
+	}
+
+	// Links in the message go to the reading list (readLater.autoSave).
+	collectReadLaterLinks(db.cfg, "discord", text)
+
+	// FAQ: curated questions are answered instantly without an agent.
+	if db.answerFAQ(msg, text) {
+		return
+	}
+
+	// Intent shortcuts: a known short utterance runs its skill or tool directly.
+	if db.answerIntent(msg, text) {
+		return
+	}
+
+	// A bare link gets a summary instead of an agent run.
+	if db.unfurlLink(msg, text) {
+		return
+	}
+
+	// Group session: the channel's agents answer instead of a routed one.
+	if db.answerGroup(msg, text) {
+		return
+	}
+
+	// Routing table (highest priority). Applies with or without smart dispatch.
+	if route := db.matchRouteTable(msg, text); route != nil {
+		db.executeRoute(msg, text, *route)
+		return
+	}
+
+	// Per-channel route binding.
 	// For threads, also check parent channel's route binding.
 	if route, ok := db.cfg.Discord.Routes[msg.ChannelID]; ok && route.Agent != "" {
 		db.handleDirectRoute(msg, text, route.Agent)
@@ -546,12 +584,22 @@ func (db *DiscordBot) handleCommand(msg discord.Message, cmdText string) {
 		db.cmdMode(msg)
 	case "new":
 		db.cmdNewSession(msg)
+	case "group":
+		db.cmdGroup(msg, args)
+	case "translate":
+		db.cmdTranslate(msg, args)
+	case "style":
+		db.sendMessage(msg.ChannelID, modulationCommand(channelSessionKey("discord", msg.ChannelID), "!style", args))
+	case "intents":
+		db.sendMessage(msg.ChannelID, intentsCommand("!intents", args))
 	case "compact":
 		db.cmdCompactSession(msg)
 	case "context", "ctx":
 		db.cmdContext(msg)
 	case "cancel":
 		db.cmdCancel(msg)
+	case "emergency":
+		db.sendMessage(msg.ChannelID, emergencyCommand(db.cfg, "!emergency", args, "discord:"+msg.Author.Username))
 	case "chat":
 		if args == "" {
 			db.sendMessage(msg.ChannelID, "Usage: `!chat <agent-name>`")
@@ -1283,7 +1331,7 @@ func (db *DiscordBot) cmdAsk(msg discord.Message, prompt string) {
 
 	result := runSingleTask(ctx, db.cfg, task, db.sem, db.childSem, agentName)
 
-	output := result.Output
+	output := chatReply(result)
 	if result.Status != "success" {
 		output = result.Error
 		if output == "" {
@@ -1506,9 +1554,14 @@ func (db *DiscordBot) cmdHelp(msg discord.Message) {
 			{Name: "!cloud [agent]", Value: "Switch back to cloud models"},
 			{Name: "!mode", Value: "Show inference mode summary"},
 			{Name: "!new", Value: "Start a new session (clear context)"},
+			{Name: "!group <agents...> [--moderator a] | off", Value: "Share this channel's session between agents (@name picks who answers)"},
+			{Name: "!intents [suggest | learn <n> | rm <id>]", Value: "Shortcuts that run a skill or tool without an agent"},
+			{Name: "!translate on [agent lang] [reply lang] | off", Value: "Auto-translate this channel's messages for the agents and their replies back"},
+			{Name: "!style [on|off]", Value: "Let replies adapt to your mood and time pressure"},
 			{Name: "!compact", Value: "Summarize & carry forward current session"},
 			{Name: "!context / !ctx", Value: "Show session context usage (tokens, %)"},
 			{Name: "!cancel", Value: "Cancel all running tasks"},
+			{Name: "!emergency [stop [reason] | resume [code]]", Value: "Halt all execution, cron, triggers and paid runs until resumed"},
 			{Name: "!chat <agent>", Value: "Lock this channel to an agent (skip dispatch)"},
 			{Name: "!end", Value: "Unlock channel, resume smart dispatch"},
 			{Name: "!ask <prompt>", Value: "Quick question (no routing, no session)"},
@@ -1563,11 +1616,163 @@ func (db *DiscordBot) handleDirectRoute(msg discord.Message, prompt string, agen
 	db.executeRoute(msg, prompt, route)
 }
 
+// matchRouteTable checks the routing table for a message, including the parent
+// channel of a thread. Rules naming an agent the channel's workspace does not
+// have are ignored.
+func (db *DiscordBot) matchRouteTable(msg discord.Message, text string) *RouteResult {
+	if len(routeRules(db.cfg)) == 0 {
+		return nil
+	}
+	req := RouteRequest{
+		Prompt:    text,
+		Source:    "discord",
+		ChannelID: msg.ChannelID,
+		GuildID:   msg.GuildID,
+		UserID:    msg.Author.ID,
+	}
+	if msg.GuildID != "" {
+		req.ParentID = db.resolveThreadParent(msg.ChannelID)
+	}
+	route := matchRouteTable(db.cfg, req)
+	if route == nil {
+		return nil
+	}
+	cfg, _ := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	if _, ok := cfg.Agents[route.Agent]; !ok {
+		log.Warn("discord routing rule matched unknown agent", "agent", route.Agent, "channel", msg.ChannelID)
+		return nil
+	}
+	return route
+}
+
+// interceptHandover relays the message to the owner when the channel is
+// handed over, or opens a handover when the user asks for a human.
+func (db *DiscordBot) interceptHandover(msg discord.Message, text string) bool {
+	desk := globalHandoverDesk
+	if desk == nil {
+		return false
+	}
+	in := handover.Inbound{
+		Platform: "discord",
+		ChatID:   msg.ChannelID,
+		UserID:   msg.Author.ID,
+		UserName: msg.Author.Username,
+		Text:     text,
+		Context: func() string {
+			sess, err := findChannelSession(db.cfg.HistoryDB, channelSessionKey("discord", msg.ChannelID))
+			if err != nil || sess == nil {
+				return ""
+			}
+			return buildSessionContext(db.cfg.HistoryDB, sess.ID, db.cfg.Handover.ContextMessagesOrDefault())
+		},
+	}
+	reply, handled := desk.Intercept(in)
+	if reply != "" {
+		db.sendMessage(msg.ChannelID, reply)
+	}
+	return handled
+}
+
+// answerFAQ replies with a canned answer when the message matches an FAQ entry.
+func (db *DiscordBot) answerFAQ(msg discord.Message, text string) bool {
+	svc := globalFAQ
+	if svc == nil {
+		return false
+	}
+	m, ok := svc.Answer(context.Background(), text, "discord")
+	if !ok {
+		return false
+	}
+	log.Info("discord faq answered", "faq", m.Entry.ID, "method", m.Method, "score", fmt.Sprintf("%.2f", m.Score))
+	db.sendMessage(msg.ChannelID, m.Entry.Answer)
+	return true
+}
+
+// answerIntent runs the shortcut the message matches, without an agent. The
+// reply is sent asynchronously.
+func (db *DiscordBot) answerIntent(msg discord.Message, text string) bool {
+	svc := globalIntents
+	if svc == nil {
+		return false
+	}
+	m, ok := svc.Match(text)
+	if !ok {
+		return false
+	}
+	db.sendTyping(msg.ChannelID)
+	go func() {
+		ctx := trace.WithID(context.Background(), trace.NewID("discord"))
+		db.sendMessage(msg.ChannelID, svc.Execute(ctx, m))
+	}()
+	return true
+}
+
+// unfurlLink summarizes a link posted on its own when unfurling is on for
+// the channel. The summary is sent asynchronously.
+func (db *DiscordBot) unfurlLink(msg discord.Message, text string) bool {
+	cfg, _ := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	link, knowledge, ok := unfurlTarget(cfg, "discord", msg.ChannelID, text)
+	if !ok {
+		return false
+	}
+	db.sendTyping(msg.ChannelID)
+	go func() {
+		ctx := trace.WithID(context.Background(), trace.NewID("discord"))
+		reply, err := runUnfurl(ctx, cfg, link, knowledge, db.sem, db.childSem)
+		if err != nil {
+			log.WarnCtx(ctx, "discord unfurl failed", "url", link, "error", err)
+			reply = fmt.Sprintf("Couldn't summarize that link: %v", err)
+		}
+		db.sendMessage(msg.ChannelID, reply)
+	}()
+	return true
+}
+
+// --- Group Sessions ---
+
+// answerGroup lets the agents of the channel's group session answer the
+// message. It reports false when the channel has no group session.
+func (db *DiscordBot) answerGroup(msg discord.Message, text string) bool {
+	cfg, _ := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	sess, g := channelGroup(cfg.HistoryDB, channelSessionKey("discord", msg.ChannelID))
+	if g == nil {
+		return false
+	}
+	db.sendTyping(msg.ChannelID)
+	ctx := trace.WithID(context.Background(), trace.NewID("discord"))
+	for _, rep := range answerChannelGroup(ctx, cfg, sess, g, text, "discord", db.sem, db.childSem) {
+		output := chatReply(rep.Result)
+		if rep.Result.Status != "success" {
+			output = "Error: " + rep.Result.Error
+		}
+		output, charts := chart.ExtractImages(output, cfg.ChartsDir())
+		db.sendMessage(msg.ChannelID, truncate(fmt.Sprintf("**@%s**: %s", rep.Agent, output), 1900))
+		db.sendCharts(msg.ChannelID, charts)
+	}
+	return true
+}
+
+// cmdGroup starts, shows or ends the channel's group session.
+func (db *DiscordBot) cmdGroup(msg discord.Message, args string) {
+	cfg, _ := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	db.sendMessage(msg.ChannelID, groupCommand(cfg, "discord", channelSessionKey("discord", msg.ChannelID), "!group", args))
+}
+
+// cmdTranslate shows or changes the channel's auto-translation.
+func (db *DiscordBot) cmdTranslate(msg discord.Message, args string) {
+	if globalTranslator == nil {
+		db.sendMessage(msg.ChannelID, "Translation is not enabled (set `translate.enabled` in config).")
+		return
+	}
+	db.sendMessage(msg.ChannelID, globalTranslator.Command("discord", msg.ChannelID, args))
+}
+
 // --- Smart Dispatch ---
 
 func (db *DiscordBot) handleRoute(msg discord.Message, prompt string) {
 	ctx := trace.WithID(context.Background(), trace.NewID("discord"))
-	route := routeTask(ctx, db.cfg, RouteRequest{
+	cfg, _ := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	route := routeTask(ctx, cfg, RouteRequest{
 		Prompt:    prompt,
 		Source:    "discord",
 		ChannelID: msg.ChannelID,
@@ -1607,7 +1812,8 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 	baseCtx, baseCancel := context.WithCancel(context.Background())
 	defer baseCancel()
 	ctx := trace.WithID(baseCtx, trace.NewID("discord"))
-	dbPath := db.cfg.HistoryDB
+	cfg, clientID := channelWorkspace(db.cfg, "discord", msg.ChannelID)
+	dbPath := cfg.HistoryDB
 
 	// Generate a task ID early for Discord activity tracking.
 	activityID := newUUID()
@@ -1669,26 +1875,30 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 		db.state.mu.Unlock()
 	}
 
+	// Auto-translate: the agent sees the message in its language; the session
+	// keeps what the user wrote.
+	agentPrompt, turn := translateIncoming(ctx, "discord", msg.ChannelID, prompt)
+
 	// Context-aware prompt.
 	// Skip text injection when:
 	//  - Provider has native session support (e.g. claude-code), OR
 	//  - Session has messages → CLI will --continue with native conversation history.
 	// Both cases already have full context; injecting again would double it.
-	contextPrompt := prompt
+	contextPrompt := agentPrompt
 	canResume := sess != nil && sess.MessageCount > 0
 	if sess != nil {
-		providerName := resolveProviderName(db.cfg, Task{Agent: route.Agent}, route.Agent)
+		providerName := resolveProviderName(cfg, Task{Agent: route.Agent}, route.Agent)
 		if !providerHasNativeSession(providerName) && !canResume {
-			sessionCtx := buildSessionContext(dbPath, sess.ID, db.cfg.Session.ContextMessagesOrDefault())
+			sessionCtx := buildSessionContext(dbPath, sess.ID, cfg.Session.ContextMessagesOrDefault())
 			// New session with no history — carry forward context from the archived predecessor.
 			if sessionCtx == "" {
 				if prev, err := findLastArchivedChannelSession(dbPath, chKey); err == nil && prev != nil {
-					sessionCtx = buildSessionContext(dbPath, prev.ID, db.cfg.Session.ContextMessagesOrDefault())
+					sessionCtx = buildSessionContext(dbPath, prev.ID, cfg.Session.ContextMessagesOrDefault())
 					log.InfoCtx(ctx, "auto-continuing from archived session",
 						"prevSession", prev.ID[:8], "channel", chKey)
 				}
 			}
-			contextPrompt = wrapWithContext(sessionCtx, prompt)
+			contextPrompt = wrapWithContext(sessionCtx, agentPrompt)
 		}
 		now := time.Now().Format(time.RFC3339)
 		addSessionMessage(dbPath, SessionMessage{
@@ -1730,14 +1940,15 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 
 	// Build and run task. Pre-set ID so it matches the activityID used for dashboard tracking.
 	task := Task{ID: activityID, Prompt: contextPrompt, Agent: route.Agent, Source: "route:discord"}
-	fillDefaults(db.cfg, &task)
+	fillDefaults(cfg, &task)
+	task.ClientID = clientID
 	if sess != nil {
 		task.SessionID = sess.ID
 		task.PersistSession = true // channel sessions persist for --continue on next message
 		task.Resume = canResume    // resume if session already has conversation history
 	}
 	if task.Agent != "" {
-		if soulPrompt, err := loadAgentPrompt(db.cfg, task.Agent); err == nil && soulPrompt != "" {
+		if soulPrompt, err := loadAgentPrompt(cfg, task.Agent); err == nil && soulPrompt != "" {
 			task.SystemPrompt = soulPrompt
 		}
 	}
@@ -1746,9 +1957,9 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 	// first turn's system prompt (which carried the summary) is already in conversation
 	// history — re-injecting would multiply token cost per message for no added context.
 	// The memory key is not deleted; the next compact overwrites it.
-	if db.cfg.Session.Compaction.Strategy == "fresh-session" && !canResume {
+	if cfg.Session.Compaction.Strategy == "fresh-session" && !canResume {
 		memKey := "session_compact_" + sanitizeKey(agent+"_"+chKey)
-		if summary, err := getMemory(db.cfg, agent, memKey); err == nil && summary != "" {
+		if summary, err := getMemory(cfg, agent, memKey); err == nil && summary != "" {
 			task.SystemPrompt += "\n\n## Previous Session Summary (READ BEFORE REPLYING)\n\n" +
 				"The visible conversation history starts fresh — the previous session was compacted " +
 				"into the summary below. **If the user's message references context not visible in " +
@@ -1763,7 +1974,7 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 		task.PermissionMode = "bypassPermissions"
 	}
 
-	task.Prompt = expandPrompt(task.Prompt, "", db.cfg.HistoryDB, route.Agent, db.cfg.KnowledgeDir, db.cfg)
+	task.Prompt = expandPrompt(task.Prompt, "", cfg.HistoryDB, route.Agent, cfg.KnowledgeDir, cfg)
 
 	// P28.0: Attach approval gate.
 	if db.approvalGate != nil {
@@ -1819,7 +2030,7 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 
 	// Start progress message for live Discord updates.
 	// Controlled by showProgress config (default: true).
-	showProgress := db.cfg.Discord.ShowProgress == nil || *db.cfg.Discord.ShowProgress
+	showProgress := cfg.Discord.ShowProgress == nil || *cfg.Discord.ShowProgress
 	var progressMsgID string
 	var progressStopCh chan struct{}
 	var progressBuilder *discord.ProgressBuilder
@@ -1864,7 +2075,11 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 	}
 
 	taskStart := time.Now()
-	result := runSingleTask(taskCtx, db.cfg, task, db.sem, db.childSem, route.Agent)
+	result := runSingleTask(taskCtx, cfg, task, db.sem, db.childSem, route.Agent)
+	observeIntent(task.ID, prompt)
+	if result.Status == "success" {
+		result.Output = translateReply(ctx, turn, result.Output)
+	}
 
 	// Stop progress updater and clean up progress message.
 	if progressStopCh != nil {
@@ -1887,7 +2102,7 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 		} else {
 			// On success: if output fits in one message, edit progress in-place (no flicker).
 			// Otherwise delete and re-send as chunks.
-			output := result.Output
+			output := chatReply(result)
 			if strings.TrimSpace(output) == "" {
 				// Session mode: result.Output is empty but progressBuilder may have accumulated content
 				if progressBuilder != nil {
@@ -1936,7 +2151,7 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 		}
 	}
 
-	recordHistory(db.cfg.HistoryDB, task.ID, task.Name, task.Source, route.Agent, task, result,
+	recordHistory(cfg.HistoryDB, task.ID, task.Name, task.Source, route.Agent, task, result,
 		taskStart.Format(time.RFC3339), time.Now().Format(time.RFC3339), result.OutputFile)
 
 	// Refresh session reference: compact may have archived sess while runSingleTask was blocking.
@@ -1979,19 +2194,19 @@ func (db *DiscordBot) executeRoute(msg discord.Message, prompt string, route Rou
 			})
 		}
 
-		maybeCompactSession(db.cfg, dbPath, sess.ID, chKey, agent, sess.MessageCount+2, sess.TotalTokensIn+result.TokensIn, db.sem, db.childSem, func(s string) { db.sendMessage(msg.ChannelID, s) })
+		maybeCompactSession(cfg, dbPath, sess.ID, chKey, agent, sess.MessageCount+2, sess.TotalTokensIn+result.TokensIn, db.sem, db.childSem, func(s string) { db.sendMessage(msg.ChannelID, s) })
 	}
 
 	if result.Status == "success" {
-		setMemory(db.cfg, route.Agent, "last_route_output", truncate(result.Output, 500))
-		setMemory(db.cfg, route.Agent, "last_route_prompt", truncate(prompt, 200))
-		setMemory(db.cfg, route.Agent, "last_route_time", time.Now().Format(time.RFC3339))
+		setMemory(cfg, route.Agent, "last_route_output", truncate(result.Output, 500))
+		setMemory(cfg, route.Agent, "last_route_prompt", truncate(prompt, 200))
+		setMemory(cfg, route.Agent, "last_route_time", time.Now().Format(time.RFC3339))
 	}
 
-	audit.Log(dbPath, "route.dispatch", "discord",
+	audit.LogCtx(ctx, dbPath, "route.dispatch", "discord",
 		fmt.Sprintf("agent=%s method=%s session=%s", route.Agent, route.Method, task.SessionID), "")
 
-	sendWebhooks(db.cfg, result.Status, webhook.Payload{
+	sendWebhooks(ctx, cfg, result.Status, webhook.Payload{
 		JobID: task.ID, Name: task.Name, Source: task.Source,
 		Status: result.Status, Cost: result.CostUSD, Duration: result.DurationMs,
 		Model: result.Model, Output: truncate(result.Output, 500), Error: truncate(result.Error, 300),
@@ -2033,7 +2248,7 @@ func (db *DiscordBot) sendRouteResponse(channelID string, route *RouteResult, re
 	}
 
 	if !skipOutput {
-		output := result.Output
+		output := chatReply(result)
 		if result.Status != "success" {
 			output = result.Error
 			if output == "" {
@@ -2065,6 +2280,9 @@ func (db *DiscordBot) sendRouteResponse(channelID string, route *RouteResult, re
 			}
 		}
 
+		// Charts the reply references are uploaded as images after the text.
+		output, charts := chart.ExtractImages(output, db.cfg.ChartsDir())
+
 		// Send output as plain text messages (split into 1900-char chunks).
 		// Hard cap at 16000 chars (~8 messages) to prevent Discord flooding.
 		const maxChunk = 1900 // leave room for markdown formatting
@@ -2103,11 +2321,21 @@ func (db *DiscordBot) sendRouteResponse(channelID string, route *RouteResult, re
 			db.sendMessage(channelID, chunk)
 			chunkCount++
 		}
+		db.sendCharts(channelID, charts)
 	}
 
 	// Query today's cumulative token usage (this task already recorded before this call).
 	todayIn, todayOut := history.TodayTotalTokens(db.cfg.HistoryDB)
 
+	// Feedback buttons go under successful results.
+	var components []discord.Component
+	if db.cfg.Feedback.Buttons && result.Status == "success" {
+		components = []discord.Component{discord.ActionRow(
+			discord.Button("feedback_up:"+task.ID, "\U0001F44D", discord.ButtonStyleSecondary),
+			discord.Button("feedback_down:"+task.ID, "\U0001F44E", discord.ButtonStyleSecondary),
+		)}
+	}
+
 	// Send metadata as a small embed at the end, as a reply to the original message.
 	db.sendEmbedReply(channelID, replyMsgID, discord.Embed{
 		Color: color,
@@ -2120,7 +2348,24 @@ func (db *DiscordBot) sendRouteResponse(channelID string, route *RouteResult, re
 		},
 		Footer:    &discord.EmbedFooter{Text: fmt.Sprintf("Task: %s", task.ID[:8])},
 		Timestamp: time.Now().Format(time.RFC3339),
-	})
+	}, components...)
+}
+
+// sendCharts uploads chart images referenced by a reply.
+func (db *DiscordBot) sendCharts(channelID string, charts []chart.Image) {
+	for _, img := range charts {
+		data, err := os.ReadFile(img.Path)
+		if err != nil {
+			log.Warn("discord: read chart failed", "path", img.Path, "err", err)
+			continue
+		}
+		f := docgen.File{Name: filepath.Base(img.Path), ContentType: img.ContentType(), Data: data}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := docgen.Discord(ctx, db.cfg.Discord.BotToken, channelID, img.Title, f); err != nil {
+			log.Warn("discord: send chart failed", "path", img.Path, "err", err)
+		}
+		cancel()
+	}
 }
 
 // --- Voice (from discord_voice.go) ---
@@ -2788,7 +3033,7 @@ func (db *DiscordBot) handleThreadRoute(msg discord.Message, prompt string, bind
 		setMemory(db.cfg, role, "last_thread_time", time.Now().Format(time.RFC3339))
 	}
 
-	audit.Log(dbPath, "thread.dispatch", "discord",
+	audit.LogCtx(ctx, dbPath, "thread.dispatch", "discord",
 		fmt.Sprintf("agent=%s thread=%s session=%s", role, msg.ChannelID, task.SessionID), "")
 
 	// Send response embed.
@@ -3779,6 +4024,19 @@ func handleModalSubmit(ctx context.Context, db *DiscordBot, w http.ResponseWrite
 
 // --- Built-in Component Handlers ---
 
+// discordFeedbackResponse records a feedback button press and acknowledges
+// it to the presser only.
+func discordFeedbackResponse(ctx context.Context, db *DiscordBot, taskID string, score int, userID string) discord.InteractionResponse {
+	text := "Thanks for the feedback."
+	if _, err := recordTaskFeedback(ctx, db.cfg, feedback.Entry{TaskID: taskID, Score: score, Source: "discord", Author: "discord:" + userID}); err != nil {
+		text = "Feedback failed: " + err.Error()
+	}
+	return discord.InteractionResponse{
+		Type: discord.InteractionResponseMessage,
+		Data: &discord.InteractionResponseData{Content: text, Flags: 64},
+	}
+}
+
 // handleBuiltinComponent handles common built-in component custom_id patterns.
 func handleBuiltinComponent(ctx context.Context, db *DiscordBot, data discord.InteractionData, userID string) discord.InteractionResponse {
 	customID := data.CustomID
@@ -3787,7 +4045,7 @@ func handleBuiltinComponent(ctx context.Context, db *DiscordBot, data discord.In
 	if strings.HasPrefix(customID, "gate_approve:") {
 		reqID := strings.TrimPrefix(customID, "gate_approve:")
 		if db.approvalGate != nil {
-			db.approvalGate.handleGateCallback(reqID, true)
+			db.approvalGate.handleGateCallback(reqID, true, "discord:"+userID)
 		}
 		return discord.InteractionResponse{
 			Type: discord.InteractionResponseUpdateMessage,
@@ -3803,7 +4061,7 @@ func handleBuiltinComponent(ctx context.Context, db *DiscordBot, data discord.In
 			reqID, toolName := parts[0], parts[1]
 			if db.approvalGate != nil {
 				db.approvalGate.AutoApprove(toolName)
-				db.approvalGate.handleGateCallback(reqID, true)
+				db.approvalGate.handleGateCallback(reqID, true, "discord:"+userID)
 			}
 			return discord.InteractionResponse{
 				Type: discord.InteractionResponseUpdateMessage,
@@ -3816,7 +4074,7 @@ func handleBuiltinComponent(ctx context.Context, db *DiscordBot, data discord.In
 	if strings.HasPrefix(customID, "gate_reject:") {
 		reqID := strings.TrimPrefix(customID, "gate_reject:")
 		if db.approvalGate != nil {
-			db.approvalGate.handleGateCallback(reqID, false)
+			db.approvalGate.handleGateCallback(reqID, false, "discord:"+userID)
 		}
 		return discord.InteractionResponse{
 			Type: discord.InteractionResponseUpdateMessage,
@@ -3826,6 +4084,14 @@ func handleBuiltinComponent(ctx context.Context, db *DiscordBot, data discord.In
 		}
 	}
 
+	// Feedback buttons under a task's result.
+	if taskID, ok := strings.CutPrefix(customID, "feedback_up:"); ok {
+		return discordFeedbackResponse(ctx, db, taskID, 1, userID)
+	}
+	if taskID, ok := strings.CutPrefix(customID, "feedback_down:"); ok {
+		return discordFeedbackResponse(ctx, db, taskID, -1, userID)
+	}
+
 	// Pattern: "approve:{taskID}" / "reject:{taskID}"
 	if strings.HasPrefix(customID, "approve:") {
 		taskID := strings.TrimPrefix(customID, "approve:")
@@ -4119,8 +4385,8 @@ func (db *DiscordBot) sendEmbed(channelID string, embed discord.Embed) {
 	db.api.SendEmbed(channelID, embed)
 }
 
-func (db *DiscordBot) sendEmbedReply(channelID, replyToID string, embed discord.Embed) {
-	db.api.SendEmbedReply(channelID, replyToID, embed)
+func (db *DiscordBot) sendEmbedReply(channelID, replyToID string, embed discord.Embed, components ...discord.Component) {
+	db.api.SendEmbedReply(channelID, replyToID, embed, components...)
 }
 
 func (db *DiscordBot) sendTyping(channelID string) {
@@ -4295,15 +4561,21 @@ type discordApprovalGate struct {
 	bot          *DiscordBot
 	channelID    string
 	mu           sync.Mutex
-	pending      map[string]chan bool
+	pending      map[string]chan gateDecision
 	autoApproved map[string]bool // tool name → always approved
 }
 
+// gateDecision is a button press on an approval request.
+type gateDecision struct {
+	approved bool
+	by       string // "discord:<userID>"
+}
+
 func newDiscordApprovalGate(bot *DiscordBot, channelID string) *discordApprovalGate {
 	g := &discordApprovalGate{
 		bot:          bot,
 		channelID:    channelID,
-		pending:      make(map[string]chan bool),
+		pending:      make(map[string]chan gateDecision),
 		autoApproved: make(map[string]bool),
 	}
 	// Copy config-level auto-approve tools.
@@ -4327,7 +4599,7 @@ func (g *discordApprovalGate) IsAutoApproved(toolName string) bool {
 }
 
 func (g *discordApprovalGate) RequestApproval(ctx context.Context, req ApprovalRequest) (bool, error) {
-	ch := make(chan bool, 1)
+	ch := make(chan gateDecision, 1)
 	g.mu.Lock()
 	g.pending[req.ID] = ch
 	g.mu.Unlock()
@@ -4338,31 +4610,37 @@ func (g *discordApprovalGate) RequestApproval(ctx context.Context, req ApprovalR
 	}()
 
 	text := fmt.Sprintf("**Approval needed**\n\nTool: `%s`\n%s", req.Tool, req.Summary)
-	components := []discord.Component{{
-		Type: discord.ComponentTypeActionRow,
-		Components: []discord.Component{
-			{Type: discord.ComponentTypeButton, Style: discord.ButtonStyleSuccess, Label: "Approve", CustomID: "gate_approve:" + req.ID},
-			{Type: discord.ComponentTypeButton, Style: discord.ButtonStylePrimary, Label: "Always", CustomID: "gate_always:" + req.ID + ":" + req.Tool},
-			{Type: discord.ComponentTypeButton, Style: discord.ButtonStyleDanger, Label: "Reject", CustomID: "gate_reject:" + req.ID},
-		},
-	}}
+	if req.Preview != "" {
+		text += "\n```\n" + req.Preview + "\n```"
+	}
+	buttons := []discord.Component{
+		{Type: discord.ComponentTypeButton, Style: discord.ButtonStyleSuccess, Label: "Approve", CustomID: "gate_approve:" + req.ID},
+	}
+	if !req.External {
+		buttons = append(buttons, discord.Component{Type: discord.ComponentTypeButton, Style: discord.ButtonStylePrimary, Label: "Always", CustomID: "gate_always:" + req.ID + ":" + req.Tool})
+	}
+	buttons = append(buttons, discord.Component{Type: discord.ComponentTypeButton, Style: discord.ButtonStyleDanger, Label: "Reject", CustomID: "gate_reject:" + req.ID})
+	components := []discord.Component{{Type: discord.ComponentTypeActionRow, Components: buttons}}
 	g.bot.sendMessageWithComponents(g.channelID, text, components)
 
 	select {
-	case approved := <-ch:
-		return approved, nil
+	case d := <-ch:
+		if req.OnDecision != nil {
+			req.OnDecision(d.approved, d.by)
+		}
+		return d.approved, nil
 	case <-ctx.Done():
 		return false, fmt.Errorf("approval timed out: %v", ctx.Err())
 	}
 }
 
-func (g *discordApprovalGate) handleGateCallback(reqID string, approved bool) {
+func (g *discordApprovalGate) handleGateCallback(reqID string, approved bool, by string) {
 	g.mu.Lock()
 	ch, ok := g.pending[reqID]
 	g.mu.Unlock()
 	if ok {
 		select {
-		case ch <- approved:
+		case ch <- gateDecision{approved: approved, by: by}:
 		default:
 		}
 	}
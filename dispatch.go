@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 	"sync"
 	"time"
 
+	"tetora/internal/abtest"
 	"tetora/internal/audit"
 	"tetora/internal/db"
 	
@@ -24,15 +26,19 @@ import (
 	dtypes "tetora/internal/dispatch"
 	"tetora/internal/discord"
 	"tetora/internal/estimate"
+	"tetora/internal/feedback"
 	"tetora/internal/history"
 	"tetora/internal/hooks"
 	"tetora/internal/log"
 	"tetora/internal/prompt"
 	"tetora/internal/provider"
+	"tetora/internal/respcache"
 	"tetora/internal/sandbox"
+	"tetora/internal/scheduling"
 	"tetora/internal/skill"
 	"tetora/internal/taskboard"
 	"tetora/internal/telemetry"
+	"tetora/internal/tools"
 	"tetora/internal/trace"
 	"tetora/internal/webhook"
 	"tetora/internal/workspace"
@@ -56,13 +62,15 @@ const (
 // --- Webhook Helpers ---
 
 // sendWebhooks converts cfg.Webhooks to []webhook.Config and posts the event payload
-// to all matching endpoints.
-func sendWebhooks(cfg *Config, event string, payload webhook.Payload) {
+// to all matching endpoints. The payload is also published on the MQTT brokers
+// configured for results.
+func sendWebhooks(ctx context.Context, cfg *Config, event string, payload webhook.Payload) {
 	whs := make([]webhook.Config, len(cfg.Webhooks))
 	for i, w := range cfg.Webhooks {
 		whs[i] = webhook.Config{URL: w.URL, Events: w.Events, Headers: w.Headers}
 	}
-	webhook.Send(whs, event, payload)
+	webhook.SendCtx(ctx, whs, event, payload)
+	publishMQTTResult(event, payload)
 }
 
 // webhookMatchesEvent checks whether a WebhookConfig should fire for the given event.
@@ -426,6 +434,68 @@ func selectSem(sem, childSem chan struct{}, depth int) chan struct{} {
 	return sem
 }
 
+// acquireTaskSlot takes a slot in s for task, queued behind waiters of
+// higher priority (see dtypes.SlotQueue) and, for top-level tasks, through
+// the slot pressure guard. The returned context is cancelled with
+// dtypes.ErrPreempted when taskPriority.preempt is on and an interactive
+// task needs the slot of this low-priority one; after maxPreemptions a task
+// keeps its slot. release must be called once the task is done.
+func acquireTaskSlot(ctx context.Context, cfg *Config, task Task, s chan struct{}, preemptions int) (context.Context, func(), string, error) {
+	var guard *dtypes.SlotPressureGuard
+	if task.Depth == 0 && cfg.Runtime.SlotPressureGuard != nil {
+		guard = cfg.Runtime.SlotPressureGuard.(*dtypes.SlotPressureGuard)
+	}
+	prio := dtypes.TaskPriority(cfg.TaskPriority, task)
+	q := dtypes.SlotQueueFor(s)
+	var warning string
+	err := q.Acquire(ctx, prio, cfg.TaskPriority.Preempt, func(ctx context.Context) error {
+		if guard != nil {
+			ar, err := guard.AcquireSlot(ctx, s, task.Source)
+			if err != nil {
+				return err
+			}
+			warning = ar.Warning
+			return nil
+		}
+		select {
+		case s <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		return ctx, nil, "", err
+	}
+
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	var preempt context.CancelCauseFunc
+	if cfg.TaskPriority.Preempt && preemptions < cfg.TaskPriority.MaxPreemptionsOrDefault() {
+		preempt = cancel
+	}
+	unhold := q.Hold(prio, preempt)
+	release := func() {
+		unhold()
+		cancel(nil)
+		if guard != nil {
+			guard.ReleaseSlot()
+		}
+		<-s
+	}
+	return taskCtx, release, warning, nil
+}
+
+// requeuePreempted prepares a preempted task to run again from the start.
+// It keeps its ID; a fresh session avoids reusing the interrupted one unless
+// the task resumes or persists its session on purpose.
+func requeuePreempted(ctx context.Context, task Task, n int) Task {
+	log.InfoCtx(ctx, "task preempted, requeued", "taskId", task.ID[:8], "name", task.Name, "preemptions", n)
+	if !task.Resume && !task.PersistSession {
+		task.SessionID = newUUID()
+	}
+	return task
+}
+
 func dispatch(ctx context.Context, cfg *Config, tasks []Task, state *dispatchState, sem, childSem chan struct{}, skipActive ...bool) *DispatchResult {
 	ctx, cancel := context.WithCancel(ctx)
 	// skipActive: true when called from a sub-agent context; skips state.active management
@@ -459,8 +529,8 @@ func dispatch(ctx context.Context, cfg *Config, tasks []Task, state *dispatchSta
 		go func(t Task) {
 			defer wg.Done()
 			s := selectSem(sem, childSem, t.Depth)
-			if t.Depth == 0 && cfg.Runtime.SlotPressureGuard != nil {
-				ar, err := cfg.Runtime.SlotPressureGuard.(*dtypes.SlotPressureGuard).AcquireSlot(ctx, s, t.Source)
+			for n := 0; ; n++ {
+				taskCtx, release, slotWarning, err := acquireTaskSlot(ctx, cfg, t, s, n)
 				if err != nil {
 					results <- TaskResult{
 						ID: t.ID, Name: t.Name, Status: "cancelled",
@@ -468,26 +538,20 @@ func dispatch(ctx context.Context, cfg *Config, tasks []Task, state *dispatchSta
 					}
 					return
 				}
-				defer cfg.Runtime.SlotPressureGuard.(*dtypes.SlotPressureGuard).ReleaseSlot()
-				defer func() { <-s }()
 				var r TaskResult
 				if t.ReviewLoop {
-					r = dispatchDevQALoop(ctx, cfg, t, state, sem, childSem)
+					r = dispatchDevQALoop(taskCtx, cfg, t, state, sem, childSem)
 				} else {
-					r = runTask(ctx, cfg, t, state)
+					r = runTask(taskCtx, cfg, t, state)
 				}
-				r.SlotWarning = ar.Warning
-				results <- r
-			} else {
-				s <- struct{}{}
-				defer func() { <-s }()
-				var r TaskResult
-				if t.ReviewLoop {
-					r = dispatchDevQALoop(ctx, cfg, t, state, sem, childSem)
-				} else {
-					r = runTask(ctx, cfg, t, state)
+				release()
+				if errors.Is(context.Cause(taskCtx), dtypes.ErrPreempted) {
+					t = requeuePreempted(ctx, t, n+1)
+					continue
 				}
+				r.SlotWarning = slotWarning
 				results <- r
+				return
 			}
 		}(task)
 	}
@@ -555,6 +619,239 @@ func annotateManifestWithHint(m *prompt.Manifest, task Task, hintUsed bool) {
 
 // runSingleTask runs one task using the shared semaphore. Used by cron engine.
 func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem chan struct{}, agentName string) TaskResult {
+	if r, halted := emergencyRefusal(task); halted {
+		return r
+	}
+	ctx, release := bindEmergency(ctx)
+	defer release()
+	if task.TraceID == "" {
+		task.TraceID = trace.IDFromContext(ctx)
+	}
+	ctx, end := startTaskSpan(ctx, task)
+	assignVariant(cfg, &task, agentName)
+	result := executeSingleTask(ctx, cfg, task, sem, childSem, agentName, 0)
+	for n := 1; result.Status == "preempted"; n++ {
+		task = requeuePreempted(ctx, task, n)
+		result = executeSingleTask(ctx, cfg, task, sem, childSem, agentName, n)
+	}
+	if result.TraceID == "" {
+		result.TraceID = task.TraceID
+	}
+	recordVariantRun(ctx, cfg, task, agentName, result)
+	end(result.Status, taskSpanDetail(result))
+	return result
+}
+
+// --- Response cache ---
+
+// responseCacheSlot is a task's place in the response cache: the key it was
+// looked up under and, on a hit, the cached entry. A nil slot means caching
+// is off for the task.
+type responseCacheSlot struct {
+	cache *respcache.Cache
+	key   string
+	ttl   time.Duration
+	entry *respcache.Entry
+}
+
+// lookupResponseCache checks the response cache for task when its cron job
+// or agent opts in (responseCache "on" or a TTL; the task's setting wins).
+// Tasks continuing a session, or serving an A/B variant, are never cached.
+func lookupResponseCache(ctx context.Context, cfg *Config, task Task, agentName string) *responseCacheSlot {
+	setting := task.ResponseCache
+	if setting == "" {
+		setting = cfg.Agents[agentName].ResponseCache
+	}
+	ttl, ok := cfg.ResponseCache.TTLFor(setting)
+	if !ok || task.Resume || task.Variant != "" {
+		return nil
+	}
+	c := globalResponseCache
+	if app := appFromCtx(ctx); app != nil && app.ResponseCache != nil {
+		c = app.ResponseCache
+	}
+	if c == nil {
+		return nil
+	}
+	slot := &responseCacheSlot{cache: c, ttl: ttl, key: respcache.Key(respcache.KeyInput{
+		Prompt:         task.Prompt,
+		Model:          task.Model,
+		Agent:          agentName,
+		Tools:          task.AllowedTools,
+		MCP:            task.MCP,
+		PermissionMode: task.PermissionMode,
+	})}
+	slot.entry, ok = c.Get(slot.key, agentName, time.Now())
+	hit := "miss"
+	if ok {
+		hit = "hit"
+		log.InfoCtx(ctx, "response cache hit", "taskId", task.ID[:8], "name", task.Name,
+			"agent", agentName, "saved", slot.entry.CostUSD, "hits", slot.entry.Hits)
+	}
+	if metricsGlobal != nil {
+		metricsGlobal.CounterInc("tetora_response_cache_total", agentName, hit)
+		if ok {
+			metricsGlobal.CounterAdd("tetora_response_cache_saved_usd", slot.entry.CostUSD, agentName)
+		}
+	}
+	return slot
+}
+
+func (s *responseCacheSlot) hit() bool { return s != nil && s.entry != nil }
+
+// result returns the cached response as a free provider result, or nil on a
+// miss.
+func (s *responseCacheSlot) result() *ProviderResult {
+	if !s.hit() {
+		return nil
+	}
+	return &ProviderResult{Output: s.entry.Output, Provider: "cache"}
+}
+
+// store caches a successful response after a miss.
+func (s *responseCacheSlot) store(ctx context.Context, task Task, agentName string, result TaskResult) {
+	if s == nil || s.hit() || result.Status != "success" {
+		return
+	}
+	err := s.cache.Put(respcache.Entry{
+		Key:       s.key,
+		Agent:     agentName,
+		Model:     result.Model,
+		Source:    task.Source,
+		Prompt:    task.Prompt,
+		Output:    result.Output,
+		CostUSD:   result.CostUSD,
+		TokensIn:  result.TokensIn,
+		TokensOut: result.TokensOut,
+	}, s.ttl, time.Now())
+	if err != nil {
+		log.WarnCtx(ctx, "response cache store failed", "taskId", task.ID[:8], "error", err)
+	}
+}
+
+// --- A/B experiments ---
+
+// assignVariant picks the variant of the agent's experiment that serves
+// task: the one the task already names, else one weighted by the variants'
+// traffic shares, sticky per session. Tasks of agents without an experiment
+// carry no variant.
+func assignVariant(cfg *Config, task *Task, agentName string) {
+	exp := cfg.Agents[agentName].Experiment
+	if agentName == "" || exp == nil {
+		task.Variant = ""
+		return
+	}
+	if task.Variant != "" && exp.Variant(task.Variant) != nil {
+		return
+	}
+	task.Variant = ""
+	key := task.SessionID
+	if key == "" {
+		key = task.ID
+	}
+	if v := abtest.Pick(*exp, key); v != nil {
+		task.Variant = v.Name
+	}
+}
+
+// recordVariantRun stores the outcome of a task a variant served, for
+// /stats/ab. Tasks that did not run (deferred, suppressed, cancelled) are
+// left out.
+func recordVariantRun(ctx context.Context, cfg *Config, task Task, agentName string, result TaskResult) {
+	exp := cfg.Agents[agentName].Experiment
+	if task.Variant == "" || exp == nil {
+		return
+	}
+	switch result.Status {
+	case "deferred", "suppressed", "cancelled":
+		return
+	}
+	err := abtest.Record(historyDBForTask(cfg, task), abtest.Run{
+		TaskID:     task.ID,
+		Agent:      agentName,
+		Experiment: exp.NameOrDefault(),
+		Variant:    task.Variant,
+		Model:      result.Model,
+		Status:     result.Status,
+		CostUSD:    result.CostUSD,
+		DurationMs: result.DurationMs,
+	})
+	if err != nil {
+		log.WarnCtx(ctx, "record variant run failed", "taskId", task.ID, "variant", task.Variant, "error", err)
+	}
+}
+
+// promoteVariant makes a variant the agent's own setup and ends the
+// experiment: the variant's soul file replaces the agent's (kept as .bak),
+// its model becomes the agent's model, and the experiment is removed from
+// config.json.
+func promoteVariant(cfg *Config, configPath, agentName, variantName string) error {
+	ac, ok := cfg.Agents[agentName]
+	if !ok {
+		return fmt.Errorf("agent %q not found", agentName)
+	}
+	if ac.Experiment == nil {
+		return fmt.Errorf("agent %q has no experiment", agentName)
+	}
+	v := ac.Experiment.Variant(variantName)
+	if v == nil {
+		return fmt.Errorf("experiment %q has no variant %q", ac.Experiment.NameOrDefault(), variantName)
+	}
+	if v.SoulFile != "" {
+		src := v.SoulFile
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(cfg.AgentsDir, agentName, src)
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("read variant soul: %w", err)
+		}
+		dst := resolveWorkspace(cfg, agentName).SoulFile
+		if old, err := os.ReadFile(dst); err == nil {
+			if err := os.WriteFile(dst+".bak", old, 0o644); err != nil {
+				return fmt.Errorf("back up soul: %w", err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return fmt.Errorf("write soul: %w", err)
+		}
+	}
+	return updateConfigField(configPath, func(raw map[string]any) {
+		agents, _ := raw["agents"].(map[string]any)
+		a, _ := agents[agentName].(map[string]any)
+		if a == nil {
+			return
+		}
+		if v.Model != "" {
+			a["model"] = v.Model
+		}
+		delete(a, "experiment")
+	})
+}
+
+// --- Feedback ---
+
+// recordTaskFeedback stores the owner's thumbs up or down on a task's result
+// and, when a variant served the task, on that variant's run as well.
+func recordTaskFeedback(ctx context.Context, cfg *Config, e feedback.Entry) (*feedback.Entry, error) {
+	saved, err := feedback.Record(cfg.HistoryDB, e)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := abtest.Feedback(cfg.HistoryDB, saved.TaskID, saved.Score, saved.Comment); err != nil && !errors.Is(err, abtest.ErrUnknownTask) {
+		log.WarnCtx(ctx, "record variant feedback failed", "taskId", saved.TaskID, "error", err)
+	}
+	audit.LogCtx(ctx, cfg.HistoryDB, "feedback.record", saved.Source,
+		fmt.Sprintf("task=%s agent=%s score=%d author=%s", saved.TaskID, saved.Agent, saved.Score, saved.Author), "")
+	return saved, nil
+}
+
+// executeSingleTask runs task once. preemptions counts earlier runs cut
+// short by an interactive task; see acquireTaskSlot.
+func executeSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem chan struct{}, agentName string, preemptions int) TaskResult {
 	// Register worker origin (if not already registered by cron layer).
 	if cfg.Runtime.HookRecv != nil && task.SessionID != "" {
 		cfg.Runtime.HookRecv.(*hookReceiver).RegisterOriginIfAbsent(task.SessionID, &workerOrigin{
@@ -565,6 +862,11 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 		})
 	}
 
+	// Agent schedule windows: hold the task until the agent may run.
+	if deferred, ok := deferOutsideSchedule(ctx, cfg, task, agentName); ok {
+		return deferred
+	}
+
 	// Apply trust level.
 	applyTrustToTask(cfg, &task, agentName)
 
@@ -610,23 +912,24 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 		}
 	}
 
+	// Spend approval: wait for the owner before taking a slot, so a pending
+	// approval never blocks other tasks.
+	if err := checkSpendApproval(ctx, cfg, task, agentName); err != nil {
+		return TaskResult{
+			ID: task.ID, Name: task.Name, Status: "error",
+			Error: "spend_approval: " + err.Error(), Model: task.Model, SessionID: task.SessionID,
+		}
+	}
+
 	s := selectSem(sem, childSem, task.Depth)
-	var slotWarning string
-	if task.Depth == 0 && cfg.Runtime.SlotPressureGuard != nil {
-		ar, err := cfg.Runtime.SlotPressureGuard.(*dtypes.SlotPressureGuard).AcquireSlot(ctx, s, task.Source)
-		if err != nil {
-			return TaskResult{
-				ID: task.ID, Name: task.Name, Status: "cancelled",
-				Error: "slot acquisition cancelled: " + err.Error(), Model: task.Model, SessionID: task.SessionID,
-			}
+	ctx, release, slotWarning, err := acquireTaskSlot(ctx, cfg, task, s, preemptions)
+	if err != nil {
+		return TaskResult{
+			ID: task.ID, Name: task.Name, Status: "cancelled",
+			Error: "slot acquisition cancelled: " + err.Error(), Model: task.Model, SessionID: task.SessionID,
 		}
-		defer cfg.Runtime.SlotPressureGuard.(*dtypes.SlotPressureGuard).ReleaseSlot()
-		defer func() { <-s }()
-		slotWarning = ar.Warning
-	} else {
-		s <- struct{}{}
-		defer func() { <-s }()
 	}
+	defer release()
 
 	// Signal that this task has acquired a slot and is about to execute.
 	if task.OnStart != nil {
@@ -714,7 +1017,11 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 		}
 	}
 	start := time.Now()
-	pr := executeWithProvider(taskCtx, cfg, task, agentName, cfg.Runtime.ProviderRegistry.(*providerRegistry), eventCh)
+	cache := lookupResponseCache(ctx, cfg, task, agentName)
+	pr := cache.result()
+	if pr == nil {
+		pr = executeWithProvider(taskCtx, cfg, task, agentName, cfg.Runtime.ProviderRegistry.(*providerRegistry), eventCh)
+	}
 	if eventCh != nil {
 		close(eventCh)
 	}
@@ -733,6 +1040,7 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 		ProviderMs: pr.ProviderMs,
 		Provider:   pr.Provider,
 		Agent:       agentName,
+		Cached:     cache.hit(),
 	}
 	if result.SessionID == "" {
 		result.SessionID = task.SessionID
@@ -741,6 +1049,9 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 	if taskCtx.Err() == context.DeadlineExceeded {
 		result.Status = "timeout"
 		result.Error = fmt.Sprintf("timed out after %v", timeout)
+	} else if errors.Is(context.Cause(ctx), dtypes.ErrPreempted) {
+		result.Status = "preempted"
+		result.Error = dtypes.ErrPreempted.Error()
 	} else if ctx.Err() != nil {
 		result.Status = "cancelled"
 		result.Error = "cancelled"
@@ -772,16 +1083,16 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 	// Parse agent completion status from structured markers in output.
 	if result.Status == "success" {
 		result.CompletionStat, result.Concerns, result.BlockedReason = parseCompletionStatus(result.Output)
+		result.Citations = extractCitations(cfg, agentName, result.Output)
 	}
+	cache.store(ctx, task, agentName, result)
 
 	// Offline queue: if all providers are unavailable, enqueue for later retry.
 	if result.Status == "error" && isAllProvidersUnavailable(result.Error) && cfg.OfflineQueue.Enabled {
-		if !isQueueFull(historyDBForTask(cfg, task), cfg.OfflineQueue.MaxItemsOrDefault()) {
-			if err := enqueueTask(historyDBForTask(cfg, task), task, agentName, 0); err == nil {
-				result.Status = "queued"
-				log.InfoCtx(ctx, "task queued for offline retry",
-					"taskId", task.ID[:8], "name", task.Name)
-			}
+		if err := enqueueOfflineTask(ctx, cfg, task, agentName); err == nil {
+			result.Status = "queued"
+			log.InfoCtx(ctx, "task queued for offline retry",
+				"taskId", task.ID[:8], "name", task.Name)
 		}
 	}
 
@@ -840,19 +1151,64 @@ func runSingleTask(ctx context.Context, cfg *Config, task Task, sem, childSem ch
 	}
 
 	// Note: history recording for runSingleTask is handled by the caller (cron.go).
+	reconcileSpendApproval(cfg, task, result)
 
 	result.SlotWarning = slotWarning
 	return result
 }
 
+// runTask runs a dispatched task and records it as a span on its trace.
 func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState) TaskResult {
+	if r, halted := emergencyRefusal(task); halted {
+		return r
+	}
+	ctx, release := bindEmergency(ctx)
+	defer release()
 	// Propagate trace ID from context to task.
 	if task.TraceID == "" {
 		task.TraceID = trace.IDFromContext(ctx)
 	}
+	ctx, end := startTaskSpan(ctx, task)
+	assignVariant(cfg, &task, task.Agent)
+	result := executeTask(ctx, cfg, task, state)
+	if result.TraceID == "" {
+		result.TraceID = task.TraceID
+	}
+	recordVariantRun(ctx, cfg, task, task.Agent, result)
+	end(result.Status, taskSpanDetail(result))
+	return result
+}
 
+// startTaskSpan opens the task's span on its trace timeline, carrying
+// task.TraceID into ctx for tasks restored without one.
+func startTaskSpan(ctx context.Context, task Task) (context.Context, func(status, detail string)) {
+	if task.TraceID != "" && trace.IDFromContext(ctx) == "" {
+		ctx = trace.WithID(ctx, task.TraceID)
+	}
+	name := task.Name
+	if task.Agent != "" {
+		name += " (" + task.Agent + ")"
+	}
+	return ctx, trace.Start(ctx, trace.KindTask, name)
+}
+
+// taskSpanDetail summarizes a task result for its trace span.
+func taskSpanDetail(r TaskResult) string {
+	detail := fmt.Sprintf("id=%s model=%s cost=$%.4f", r.ID, r.Model, r.CostUSD)
+	if r.Error != "" {
+		detail += " error=" + truncate(r.Error, 200)
+	}
+	return detail
+}
+
+func executeTask(ctx context.Context, cfg *Config, task Task, state *dispatchState) TaskResult {
 	agentName := task.Agent
 
+	// Agent schedule windows: hold the task until the agent may run.
+	if deferred, ok := deferOutsideSchedule(ctx, cfg, task, agentName); ok {
+		return deferred
+	}
+
 	// --- P19.5: Unified Presence/Typing Indicators --- Start typing in source channel.
 	presence := globalPresence
 	if appCtx := appFromCtx(ctx); appCtx != nil && appCtx.Presence != nil {
@@ -959,6 +1315,14 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 		task.Model = budgetResult.DowngradeModel
 	}
 
+	// Spend approval: over-threshold tasks wait for the owner before running.
+	if err := checkSpendApproval(ctx, cfg, task, agentName); err != nil {
+		return TaskResult{
+			ID: task.ID, Name: task.Name, Status: "error",
+			Error: "spend_approval: " + err.Error(), Model: task.Model, SessionID: task.SessionID,
+		}
+	}
+
 	providerName := resolveProviderName(cfg, task, agentName)
 
 	log.DebugCtx(ctx, "task start",
@@ -1010,6 +1374,7 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 		attemptErrors   []string
 	)
 	totalStart := time.Now()
+	cache := lookupResponseCache(ctx, cfg, task, agentName)
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		// Backoff + context check before retries.
@@ -1040,7 +1405,9 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 		}
 
 		// Reuse complexity from tiered prompt builder for tool trimming.
-		if complexity == dtypes.Simple {
+		if cached := cache.result(); cached != nil {
+			pr = cached
+		} else if complexity == dtypes.Simple {
 			pr = executeWithProvider(taskCtx, cfg, task, agentName, cfg.Runtime.ProviderRegistry.(*providerRegistry), eventCh)
 		} else {
 			pr = executeWithProviderAndTools(taskCtx, cfg, task, agentName, cfg.Runtime.ProviderRegistry.(*providerRegistry), eventCh, state.broker)
@@ -1063,6 +1430,7 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 			Provider:   pr.Provider,
 			Agent:      agentName,
 			Attempts:   attempt + 1,
+			Cached:     cache.hit(),
 		}
 		if result.SessionID == "" {
 			result.SessionID = task.SessionID
@@ -1072,6 +1440,10 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 			result.Status = "timeout"
 			result.Error = fmt.Sprintf("timed out after %v", timeout)
 			break // Don't retry timeouts.
+		} else if errors.Is(context.Cause(ctx), dtypes.ErrPreempted) {
+			result.Status = "preempted"
+			result.Error = dtypes.ErrPreempted.Error()
+			break
 		} else if ctx.Err() != nil {
 			result.Status = "cancelled"
 			result.Error = "dispatch cancelled"
@@ -1100,32 +1472,29 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 
 	// Last-line defense: strip echoed prompt headers before any downstream surface (history, webhooks, Discord, HTTP) sees the output.
 	result.Output = stripPostTaskSections(result.Output)
+	cache.store(ctx, task, agentName, result)
 
 	// Offline queue: if all providers are unavailable, enqueue for later retry.
 	if result.Status == "error" && isAllProvidersUnavailable(result.Error) && cfg.OfflineQueue.Enabled {
-		if !isQueueFull(historyDBForTask(cfg, task), cfg.OfflineQueue.MaxItemsOrDefault()) {
-			if err := enqueueTask(historyDBForTask(cfg, task), task, agentName, 0); err == nil {
-				result.Status = "queued"
-				log.InfoCtx(ctx, "task queued for offline retry",
-					"taskId", task.ID[:8], "name", task.Name)
-
-				// Publish SSE queued event.
-				state.publishSSE(SSEEvent{
-					Type:      SSEQueued,
-					TaskID:    task.ID,
-					SessionID: task.SessionID,
-					Data: map[string]any{
-						"name":  task.Name,
-						"role":  agentName,
-						"error": result.Error,
-					},
-				})
-				emitAgentState(state.broker, agentName, "waiting")
-			} else {
-				log.WarnCtx(ctx, "failed to enqueue task", "taskId", task.ID[:8], "error", err)
-			}
+		if err := enqueueOfflineTask(ctx, cfg, task, agentName); err == nil {
+			result.Status = "queued"
+			log.InfoCtx(ctx, "task queued for offline retry",
+				"taskId", task.ID[:8], "name", task.Name)
+
+			// Publish SSE queued event.
+			state.publishSSE(SSEEvent{
+				Type:      SSEQueued,
+				TaskID:    task.ID,
+				SessionID: task.SessionID,
+				Data: map[string]any{
+					"name":  task.Name,
+					"role":  agentName,
+					"error": result.Error,
+				},
+			})
+			emitAgentState(state.broker, agentName, "waiting")
 		} else {
-			log.WarnCtx(ctx, "offline queue full, task not enqueued", "taskId", task.ID[:8])
+			log.WarnCtx(ctx, "failed to enqueue task", "taskId", task.ID[:8], "error", err)
 		}
 	}
 
@@ -1215,8 +1584,11 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 		}
 	}
 
+	// Store actual cost next to the pre-run estimate for approved tasks.
+	reconcileSpendApproval(cfg, task, result)
+
 	// Webhook notifications.
-	sendWebhooks(cfg, result.Status, webhook.Payload{
+	sendWebhooks(ctx, cfg, result.Status, webhook.Payload{
 		JobID:    task.ID,
 		Name:     task.Name,
 		Source:   task.Source,
@@ -1297,11 +1669,210 @@ func runTask(ctx context.Context, cfg *Config, task Task, state *dispatchState)
 	// Discord thread-per-task: post result to thread.
 	if doDiscordNotify {
 		state.discordBot.notifier.NotifyComplete(task, result)
+		trace.Record(ctx, trace.Event{Kind: trace.KindNotify, Name: "discord thread", Status: "sent"})
 	}
 
 	return result
 }
 
+// --- Spend Approval ---
+
+// spendApprovalTool is the pseudo tool name carried by spend approval requests.
+// Pressing "Always" on a gate auto-approves future spend requests on that channel.
+const spendApprovalTool = "spend_approval"
+
+// checkSpendApproval blocks until the owner approves a task whose estimated
+// cost exceeds cfg.Estimate.ApprovalThreshold. It returns nil when no approval
+// is needed or approval was granted. Every decision is recorded so the estimate
+// can later be reconciled against actual spend.
+func checkSpendApproval(ctx context.Context, cfg *Config, task Task, agentName string) error {
+	threshold := cfg.Estimate.ApprovalThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	est := estimateTaskCost(cfg, task, agentName)
+	if est.EstimatedCostUSD <= threshold {
+		return nil
+	}
+
+	approvals := globalApprovalManager
+	if appCtx := appFromCtx(ctx); appCtx != nil && appCtx.Approvals != nil {
+		approvals = appCtx.Approvals
+	}
+	channel, gate := approvals.Resolve(cfg.Estimate.ApprovalChannel, task.ApprovalGate)
+
+	rec := cost.SpendApproval{
+		TaskID:       task.ID,
+		TaskName:     task.Name,
+		Agent:        agentName,
+		Model:        est.Model,
+		Channel:      channel,
+		ThresholdUSD: threshold,
+		EstimatedUSD: est.EstimatedCostUSD,
+	}
+
+	var err error
+	switch {
+	case gate == nil:
+		rec.Decision = cost.DecisionUnavailable
+		err = fmt.Errorf("estimated $%.2f exceeds threshold $%.2f and no approval channel is available",
+			est.EstimatedCostUSD, threshold)
+	case gate.IsAutoApproved(spendApprovalTool):
+		rec.Decision = cost.DecisionApproved
+	default:
+		gateCtx, cancel := context.WithTimeout(ctx, cfg.Estimate.ApprovalTimeoutOrDefault())
+		approved, gateErr := gate.RequestApproval(gateCtx, ApprovalRequest{
+			ID:   trace.NewID("spend"),
+			Tool: spendApprovalTool,
+			Summary: fmt.Sprintf("Task %q (agent %s, model %s) is estimated at $%.2f, above the $%.2f approval threshold.\n%s",
+				task.Name, agentName, est.Model, est.EstimatedCostUSD, threshold, est.Breakdown),
+			TaskID: task.ID,
+			Role:   agentName,
+		})
+		cancel()
+		switch {
+		case gateErr != nil:
+			rec.Decision = cost.DecisionTimeout
+			err = fmt.Errorf("estimated $%.2f not approved: %v", est.EstimatedCostUSD, gateErr)
+		case !approved:
+			rec.Decision = cost.DecisionRejected
+			err = fmt.Errorf("estimated $%.2f rejected by owner", est.EstimatedCostUSD)
+		default:
+			rec.Decision = cost.DecisionApproved
+		}
+	}
+
+	if recErr := cost.RecordSpendApproval(historyDBForTask(cfg, task), rec); recErr != nil {
+		log.WarnCtx(ctx, "record spend approval failed", "taskId", task.ID, "error", recErr)
+	}
+	log.InfoCtx(ctx, "spend approval", "taskId", task.ID,
+		"estimate", fmt.Sprintf("$%.2f", est.EstimatedCostUSD), "threshold", fmt.Sprintf("$%.2f", threshold),
+		"channel", channel, "decision", rec.Decision)
+	return err
+}
+
+// reconcileSpendApproval stores the actual cost of a task that went through spend approval.
+func reconcileSpendApproval(cfg *Config, task Task, result TaskResult) {
+	if cfg.Estimate.ApprovalThreshold <= 0 || result.Status == "queued" {
+		return
+	}
+	if err := cost.ReconcileSpendApproval(historyDBForTask(cfg, task), task.ID, result.CostUSD); err != nil {
+		log.Warn("reconcile spend approval failed", "taskId", task.ID, "error", err)
+	}
+}
+
+// --- Agent Schedule Windows ---
+
+// agentScheduleNow reports whether agentName may run at now and, if not,
+// when its next run window opens. Agents without a schedule always run.
+func agentScheduleNow(cfg *Config, agentName string, now time.Time) (bool, time.Time) {
+	rc, ok := cfg.Agents[agentName]
+	if !ok || rc.Schedule == nil || len(rc.Schedule.Windows) == 0 {
+		return true, time.Time{}
+	}
+	loc := time.Local
+	if rc.Schedule.TZ != "" {
+		if l, err := time.LoadLocation(rc.Schedule.TZ); err == nil {
+			loc = l
+		}
+	}
+	windows := make([]scheduling.Window, 0, len(rc.Schedule.Windows))
+	for _, w := range rc.Schedule.Windows {
+		windows = append(windows, scheduling.Window{Days: w.Days, Start: w.Start, End: w.End})
+	}
+	now = now.In(loc)
+	if scheduling.InWindows(windows, now) {
+		return true, time.Time{}
+	}
+	return false, scheduling.NextWindowStart(windows, now)
+}
+
+// deferOutsideSchedule holds a task whose agent is outside its run windows and
+// returns a "deferred" result. ok is false when the task may run now.
+func deferOutsideSchedule(ctx context.Context, cfg *Config, task Task, agentName string) (TaskResult, bool) {
+	allowed, next := agentScheduleNow(cfg, agentName, time.Now())
+	if allowed {
+		return TaskResult{}, false
+	}
+	if next.IsZero() {
+		return TaskResult{
+			ID: task.ID, Name: task.Name, Status: "error",
+			Error: fmt.Sprintf("agent %q has no valid schedule window", agentName), Model: task.Model, SessionID: task.SessionID,
+		}, true
+	}
+	taskJSON, err := dtypes.MarshalTask(task)
+	if err == nil {
+		err = dtypes.DeferTask(cfg.HistoryDB, taskJSON, task.Source, agentName, next)
+	}
+	if err != nil {
+		log.WarnCtx(ctx, "defer task failed", "taskId", task.ID, "agent", agentName, "error", err)
+		return TaskResult{
+			ID: task.ID, Name: task.Name, Status: "error",
+			Error: fmt.Sprintf("agent %q is outside its schedule and the task could not be deferred: %v", agentName, err),
+			Model: task.Model, SessionID: task.SessionID,
+		}, true
+	}
+	log.InfoCtx(ctx, "task deferred to next schedule window", "taskId", task.ID, "agent", agentName,
+		"runAfter", next.Format(time.RFC3339))
+	return TaskResult{
+		ID: task.ID, Name: task.Name, Status: "deferred",
+		Output:    fmt.Sprintf("Agent %s is outside its schedule; task deferred until %s.", agentName, next.Format("Mon 2006-01-02 15:04 MST")),
+		Model:     task.Model,
+		SessionID: task.SessionID,
+		Agent:     agentName,
+	}, true
+}
+
+// deferredDrainer dispatches deferred tasks once their run window opens.
+type deferredDrainer struct {
+	cfg      *Config
+	sem      chan struct{}
+	childSem chan struct{}
+	notifyFn func(string)
+}
+
+func (d *deferredDrainer) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.tick(ctx)
+		}
+	}
+}
+
+func (d *deferredDrainer) tick(ctx context.Context) {
+	for _, item := range dtypes.ClaimDueDeferred(d.cfg.HistoryDB, time.Now()) {
+		if ctx.Err() != nil {
+			return
+		}
+		var task Task
+		if err := json.Unmarshal([]byte(item.TaskJSON), &task); err != nil {
+			log.Error("deferred: bad task JSON", "id", item.ID, "error", err)
+			continue
+		}
+		task.ID = newUUID()
+		go d.dispatch(ctx, task, item.AgentName)
+	}
+}
+
+func (d *deferredDrainer) dispatch(ctx context.Context, task Task, agentName string) {
+	start := time.Now()
+	result := runSingleTask(ctx, d.cfg, task, d.sem, d.childSem, agentName)
+	if result.Status == "deferred" {
+		return // window closed again; re-deferred by runSingleTask
+	}
+	recordHistory(historyDBForTask(d.cfg, task), task.ID, task.Name, task.Source, agentName, task, result,
+		start.Format(time.RFC3339), time.Now().Format(time.RFC3339), result.OutputFile)
+	if d.notifyFn != nil {
+		d.notifyFn(fmt.Sprintf("Deferred task %q (%s) finished: %s", task.Name, agentName, result.Status))
+		trace.Record(ctx, trace.Event{Kind: trace.KindNotify, Name: "owner", Status: "sent", Detail: "deferred task finished"})
+	}
+}
+
 // --- Dispatch Dev↔QA Loop ---
 
 // dispatchDevQALoop runs the Dev↔QA retry loop for the main dispatch path.
@@ -1435,7 +2006,7 @@ func retryTask(ctx context.Context, cfg *Config, taskID string, state *dispatchS
 		state.mu.Unlock()
 	}
 
-	audit.Log(historyDBForTask(cfg, task), "task.retry", task.Source,
+	audit.LogCtx(ctx, historyDBForTask(cfg, task), "task.retry", task.Source,
 		fmt.Sprintf("original=%s new=%s status=%s", taskID, task.ID, result.Status), "")
 
 	return &result, nil
@@ -1464,7 +2035,7 @@ func rerouteTask(ctx context.Context, cfg *Config, taskID string, state *dispatc
 		state.mu.Unlock()
 	}
 
-	audit.Log(historyDBForTask(cfg, ft.task), "task.reroute", "reroute",
+	audit.LogCtx(ctx, historyDBForTask(cfg, ft.task), "task.reroute", "reroute",
 		fmt.Sprintf("original=%s role=%s status=%s", taskID, result.Route.Agent, result.Task.Status), "")
 
 	return result, nil
@@ -1833,8 +2404,9 @@ func saveTaskOutput(baseDir string, jobID string, stdout []byte) string {
 	return filename
 }
 
-// cleanupOutputs removes output files older than the given number of days.
-func cleanupOutputs(baseDir string, days int) {
+// cleanupOutputs removes output files older than the given number of days,
+// except the file names in keep.
+func cleanupOutputs(baseDir string, days int, keep map[string]bool) {
 	outputDir := filepath.Join(baseDir, "outputs")
 	entries, err := os.ReadDir(outputDir)
 	if err != nil {
@@ -1842,7 +2414,7 @@ func cleanupOutputs(baseDir string, days int) {
 	}
 	cutoff := time.Now().AddDate(0, 0, -days)
 	for _, e := range entries {
-		if e.IsDir() {
+		if e.IsDir() || keep[e.Name()] {
 			continue
 		}
 		info, err := e.Info()
@@ -2364,7 +2936,31 @@ type RouteRequest = dtypes.RouteRequest
 type RouteResult = dtypes.RouteResult
 type SmartDispatchResult = dtypes.SmartDispatchResult
 
-// --- Binding Classification (Highest Priority) ---
+// --- Routing Table (Highest Priority) ---
+
+// setRouteTable installs the main config's routing table for every channel it
+// serves, including bots still holding an older config. Workspaces use their
+// own config's table. Called on startup and on each reload.
+func setRouteTable(cfg *Config) {
+	dtypes.SetRouteTable(cfg.Routing.Rules)
+}
+
+// routeRules delegates to internal/dispatch.RouteRules.
+func routeRules(cfg *Config) []RouteRule {
+	return dtypes.RouteRules(cfg)
+}
+
+// matchRouteTable delegates to internal/dispatch.MatchRouteTable.
+func matchRouteTable(cfg *Config, req RouteRequest) *RouteResult {
+	return dtypes.MatchRouteTable(cfg, req)
+}
+
+// explainRoute delegates to internal/dispatch.ExplainRoute (dry run, no LLM).
+func explainRoute(cfg *Config, req RouteRequest) *RouteResult {
+	return dtypes.ExplainRoute(cfg, req)
+}
+
+// --- Binding Classification ---
 
 // checkBindings delegates to internal/dispatch.CheckBindings.
 func checkBindings(cfg *Config, req RouteRequest) *RouteResult {
@@ -2533,12 +3129,12 @@ func smartDispatch(ctx context.Context, cfg *Config, prompt string, source strin
 	}
 
 	// Step 6: Audit log.
-	audit.Log(historyDBForTask(cfg, task), "route.dispatch", source,
+	audit.LogCtx(ctx, historyDBForTask(cfg, task), "route.dispatch", source,
 		fmt.Sprintf("role=%s method=%s confidence=%s attempts=%d prompt=%s",
 			route.Agent, route.Method, route.Confidence, attempts, truncate(prompt, 100)), "")
 
 	// Webhook notifications.
-	sendWebhooks(cfg, result.Status, webhook.Payload{
+	sendWebhooks(ctx, cfg, result.Status, webhook.Payload{
 		JobID:    task.ID,
 		Name:     task.Name,
 		Source:   task.Source,
@@ -2702,17 +3298,56 @@ func shouldReview(cfg *Config, routeResult *RouteResult, taskCost float64) bool
 // Merged from dispatch_tools.go
 // ============================================================
 
-// safeToolExec wraps tool execution with panic recovery.
+// withToolAgent tells tool handlers which agent the call runs for, so
+// knowledge_search can keep to the agent's knowledge scope.
+func withToolAgent(ctx context.Context, agentName string) context.Context {
+	return tools.WithAgent(ctx, agentName)
+}
+
+// withToolCostMeter lets MCP and plugin tool handlers report what a call
+// cost on paid services behind them.
+func withToolCostMeter(ctx context.Context) (context.Context, *tools.CostMeter) {
+	return tools.WithCostMeter(ctx)
+}
+
+// safeToolExec wraps tool execution with panic recovery, records the call
+// on the trace timeline and scores it against the integration behind the
+// tool.
 func safeToolExec(ctx context.Context, cfg *Config, tool *ToolDef, input json.RawMessage) (output string, err error) {
+	end := trace.Start(ctx, trace.KindTool, tool.Name)
 	defer func() {
 		if rv := recover(); rv != nil {
 			err = fmt.Errorf("tool %q panicked: %v", tool.Name, rv)
 			log.Error("tool panic recovered", "tool", tool.Name, "panic", fmt.Sprintf("%v", rv))
 		}
+		if err != nil {
+			end("error", err.Error())
+		} else {
+			end("success", truncate(string(input), 200))
+		}
+		recordIntegrationCall(tool.Name, err)
 	}()
 	return tool.Handler(ctx, cfg, input)
 }
 
+// recordToolCost records what a tool call reported spending downstream, so
+// it shows in cost stats and counts towards budgets before the task ends.
+func recordToolCost(cfg *Config, task Task, agentName, server, tool string, usd float64) {
+	dbPath := historyDBForTask(cfg, task)
+	if dbPath == "" {
+		return
+	}
+	if err := history.InsertToolCost(dbPath, history.ToolCost{
+		Server:  server,
+		Tool:    tool,
+		Agent:   agentName,
+		TaskID:  task.ID,
+		CostUSD: usd,
+	}); err != nil {
+		log.Warn("record tool cost failed", "tool", tool, "error", err)
+	}
+}
+
 // --- Agentic Loop ---
 
 // truncateToolOutput truncates tool output to the given limit.
@@ -2814,9 +3449,15 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 	// Token/cost accumulators across iterations.
 	var totalTokensIn, totalTokensOut int
 	var totalCostUSD float64
+	var toolCostUSD float64 // reported by MCP servers and plugins, recorded in tool_costs
 	var totalProviderMs int64
 	var taskBudgetWarnLogged bool // soft-limit: log once and continue instead of stopping
 
+	// Tool-call budget for the whole task; 0 = unlimited.
+	callLimit := toolCallLimit(cfg, task.Agent)
+	toolCalls := 0
+	budgetViolationLogged := false
+
 	for i := 0; i < maxIter; i++ {
 		// Check context deadline before each iteration.
 		if ctx.Err() != nil {
@@ -2899,7 +3540,7 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 		for _, tc := range result.ToolCalls {
 			// Check tool policy - is tool allowed for this agent?
 			if task.Agent != "" && !isToolAllowed(cfg, task.Agent, tc.Name) {
-				log.WarnCtx(ctx, "tool call blocked by policy", "tool", tc.Name, "agent", task.Agent)
+				recordToolViolation(ctx, cfg, task, "denied", tc.Name, fmt.Sprintf("tool %s not allowed by policy", tc.Name))
 				toolResults = append(toolResults, ToolResult{
 					ToolUseID: tc.ID,
 					Content:   fmt.Sprintf("error: tool %q not allowed by policy for agent %q", tc.Name, task.Agent),
@@ -2908,6 +3549,21 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 				continue
 			}
 
+			// Tool-call budget: refuse further calls once it is spent.
+			if callLimit > 0 && toolCalls >= callLimit {
+				if !budgetViolationLogged {
+					budgetViolationLogged = true
+					recordToolViolation(ctx, cfg, task, "budget", tc.Name, fmt.Sprintf("tool call budget of %d exhausted", callLimit))
+				}
+				toolResults = append(toolResults, ToolResult{
+					ToolUseID: tc.ID,
+					Content:   fmt.Sprintf("error: tool call budget of %d for this task is exhausted; answer with what you have", callLimit),
+					IsError:   true,
+				})
+				continue
+			}
+			toolCalls++
+
 			// Check for loop using enhanced detector.
 			isLoop, loopMsg := detector.Check(tc.Name, tc.Input)
 			if isLoop {
@@ -2944,8 +3600,18 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 				continue
 			}
 
+			// External actions: previewed and approved on every call.
+			if err := checkExternalAction(ctx, cfg, task, rootTC); err != nil {
+				toolResults = append(toolResults, ToolResult{
+					ToolUseID: tc.ID,
+					Content:   fmt.Sprintf("[REJECTED: %v]", err),
+					IsError:   true,
+				})
+				continue
+			}
+
 			// P28.0: Pre-execution approval gate.
-			if needsApproval(cfg, tc.Name) && task.ApprovalGate != nil && !task.ApprovalGate.IsAutoApproved(tc.Name) {
+			if !isExternalAction(cfg, tc.Name) && needsApproval(cfg, tc.Name) && task.ApprovalGate != nil && !task.ApprovalGate.IsAutoApproved(tc.Name) {
 				approved, gateErr := requestToolApproval(ctx, cfg, task, rootTC)
 				if gateErr != nil || !approved {
 					toolResults = append(toolResults, ToolResult{
@@ -2962,7 +3628,7 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 			if !ok {
 				toolResults = append(toolResults, ToolResult{
 					ToolUseID: tc.ID,
-					Content:   fmt.Sprintf("error: tool %q not found", tc.Name),
+					Content:   "error: " + toolNotFound(tc.Name).Error(),
 					IsError:   true,
 				})
 				continue
@@ -2973,10 +3639,15 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 			if toolTimeout <= 0 {
 				toolTimeout = 30 * time.Second
 			}
-			toolCtx, toolCancel := context.WithTimeout(ctx, toolTimeout)
+			meterCtx, meter := withToolCostMeter(withToolAgent(ctx, agentName))
+			toolCtx, toolCancel := context.WithTimeout(meterCtx, toolTimeout)
 			toolStart := time.Now()
 			output, err := safeToolExec(toolCtx, cfg, tool, tc.Input)
 			toolCancel()
+			if server, usd := meter.Cost(); usd > 0 {
+				toolCostUSD += usd
+				recordToolCost(cfg, task, agentName, server, tc.Name, usd)
+			}
 			toolDuration := time.Since(toolStart)
 			if toolCtx.Err() == context.DeadlineExceeded && err == nil {
 				err = fmt.Errorf("tool %q timed out after %v", tc.Name, toolTimeout)
@@ -2991,6 +3662,11 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 			}
 			toolResults = append(toolResults, tr)
 
+			// Intent learning: remember the call behind a routed chat message.
+			if globalIntents != nil && !tr.IsError && strings.HasPrefix(task.Source, "route:") {
+				globalIntents.RecordToolCall(task.ID, tc.Name, tc.Input)
+			}
+
 			// Record tool usage for reranking popularity bonus.
 			if cfg.Runtime.ToolRegistry != nil {
 				if reg, ok := cfg.Runtime.ToolRegistry.(*ToolRegistry); ok {
@@ -3069,11 +3745,11 @@ func executeWithProviderAndTools(ctx context.Context, cfg *Config, task Task, ag
 		}
 
 		// Per-task budget soft limit: log once for analysis, then continue.
-		if task.Budget > 0 && totalCostUSD >= task.Budget && !taskBudgetWarnLogged {
+		if task.Budget > 0 && totalCostUSD+toolCostUSD >= task.Budget && !taskBudgetWarnLogged {
 			taskBudgetWarnLogged = true
 			log.WarnCtx(ctx, "task budget soft-limit exceeded (continuing)",
 				"budget", task.Budget,
-				"spent", totalCostUSD,
+				"spent", totalCostUSD+toolCostUSD,
 				"role", task.Agent,
 				"task_id", task.ID,
 				"task_prompt_preview", task.Prompt[:min(120, len(task.Prompt))],
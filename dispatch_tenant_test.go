@@ -4,8 +4,11 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- Tests for multi-tenant client ID validation ---
@@ -370,3 +373,206 @@ func TestConfigOutputsDirFor_NoClientsDir(t *testing.T) {
 		t.Errorf("OutputsDirFor with empty ClientsDir = %q, want BaseDir %q", got, cfg.BaseDir)
 	}
 }
+
+// --- Tests for multi-workspace ---
+
+func workspaceTestConfig() *Config {
+	return &Config{
+		APIToken:        "main-token",
+		DefaultClientID: "cli_default",
+		Workspaces: map[string]TenantConfig{
+			"home":  {APIToken: "home-token", Channels: []string{"discord:111"}},
+			"shop":  {ClientID: "cli_side-shop", APIToken: "shop-token"},
+			"quiet": {},
+		},
+	}
+}
+
+func TestWorkspaceMiddleware_TokenPinsClient(t *testing.T) {
+	cfg := workspaceTestConfig()
+	var gotClientID string
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClientID = getClientID(r)
+	})
+	handler := workspaceMiddleware(cfg, clientMiddleware(cfg.DefaultClientID, inner))
+
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"home-token", "cli_home"},
+		{"shop-token", "cli_side-shop"},
+		{"main-token", "cli_default"},
+	}
+	for _, tt := range tests {
+		gotClientID = ""
+		req := httptest.NewRequest("POST", "/dispatch", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("token %q: expected 200, got %d", tt.token, rr.Code)
+		}
+		if gotClientID != tt.want {
+			t.Errorf("token %q: client = %q, want %q", tt.token, gotClientID, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceMiddleware_RejectsCrossWorkspace(t *testing.T) {
+	cfg := workspaceTestConfig()
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not have been called")
+	})
+	handler := workspaceMiddleware(cfg, clientMiddleware(cfg.DefaultClientID, inner))
+
+	// Workspace token asking for another client.
+	req := httptest.NewRequest("POST", "/dispatch", nil)
+	req.Header.Set("Authorization", "Bearer home-token")
+	req.Header.Set("X-Client-ID", "cli_side-shop")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("cross-workspace X-Client-ID: expected 403, got %d", rr.Code)
+	}
+
+	// Workspace token on a daemon-wide endpoint.
+	req = httptest.NewRequest("GET", "/api/config", nil)
+	req.Header.Set("Authorization", "Bearer home-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("unscoped path: expected 403, got %d", rr.Code)
+	}
+}
+
+func TestWorkspaceToken_CannotCancelOtherClientTask(t *testing.T) {
+	cfg := workspaceTestConfig()
+	cfg.BaseDir = t.TempDir()
+	cfg.ClientsDir = filepath.Join(cfg.BaseDir, "clients")
+	dm := newDispatchManager(4, 12)
+	state := newDispatchState()
+	dm.register(cfg.DefaultClientID, state, make(chan struct{}, 4), make(chan struct{}, 12))
+	cancelled := false
+	state.running["task-1"] = &taskState{task: Task{ID: "task-1", Name: "main"}, startAt: time.Now(),
+		cancelFn: func() { cancelled = true }}
+
+	s := &Server{cfg: cfg, state: state, dispatchMgr: dm, cron: &CronEngine{}}
+	mux := http.NewServeMux()
+	s.registerDispatchRoutes(mux)
+	handler := workspaceMiddleware(cfg, clientMiddleware(cfg.DefaultClientID, mux))
+
+	req := httptest.NewRequest("GET", "/tasks/running", nil)
+	req.Header.Set("Authorization", "Bearer home-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "task-1") {
+		t.Errorf("running tasks for workspace token = %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/cancel/task-1", nil)
+	req.Header.Set("Authorization", "Bearer home-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || cancelled {
+		t.Errorf("workspace cancel of main task: status %d, cancelled %v", rr.Code, cancelled)
+	}
+
+	// Cron jobs belong to the main config.
+	if s.cronForClient("cli_home") != nil || s.cronForClient(cfg.DefaultClientID) != s.cron {
+		t.Error("cron engine should only be reachable by the default client")
+	}
+
+	req = httptest.NewRequest("POST", "/cancel/task-1", nil)
+	req.Header.Set("Authorization", "Bearer main-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !cancelled {
+		t.Errorf("main cancel: status %d, cancelled %v", rr.Code, cancelled)
+	}
+}
+
+func TestAuthMiddleware_AcceptsWorkspaceToken(t *testing.T) {
+	cfg := workspaceTestConfig()
+	handler := authMiddleware(cfg, nil, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for token, want := range map[string]int{
+		"home-token":  http.StatusOK,
+		"main-token":  http.StatusOK,
+		"wrong-token": http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest("POST", "/dispatch", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("token %q: expected %d, got %d", token, want, rr.Code)
+		}
+	}
+}
+
+func TestConfigWorkspaceLookup(t *testing.T) {
+	cfg := workspaceTestConfig()
+	if name, ok := cfg.WorkspaceByChannel("discord", "111"); !ok || name != "home" {
+		t.Errorf("WorkspaceByChannel(discord, 111) = %q, %v", name, ok)
+	}
+	if _, ok := cfg.WorkspaceByChannel("discord", "222"); ok {
+		t.Error("unbound channel should not match a workspace")
+	}
+	if _, ok := cfg.WorkspaceByToken(""); ok {
+		t.Error("empty token must not match a workspace without a token")
+	}
+	if got := cfg.WorkspaceClientID("shop"); got != "cli_side-shop" {
+		t.Errorf("WorkspaceClientID(shop) = %q", got)
+	}
+}
+
+func TestLoadWorkspaceConfig_Isolated(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	mainCfg := `{
+  "agents": {"ruri": {"model": "sonnet"}},
+  "workspaces": {"shop": {"apiToken": "shop-token"}},
+  "defaultModel": "sonnet"
+}`
+	if err := os.WriteFile(configPath, []byte(mainCfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	base, err := tryLoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load base: %v", err)
+	}
+
+	clientID := base.WorkspaceClientID("shop")
+	os.MkdirAll(base.ClientDir(clientID), 0o755)
+	overlay := `{"agents": {"clerk": {"model": "haiku"}}, "defaultModel": "haiku"}`
+	if err := os.WriteFile(base.WorkspaceConfigPath(clientID), []byte(overlay), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := loadWorkspaceConfig(configPath, base, clientID)
+	if err != nil {
+		t.Fatalf("loadWorkspaceConfig: %v", err)
+	}
+	if _, ok := ws.Agents["ruri"]; ok {
+		t.Error("main agent leaked into workspace")
+	}
+	if _, ok := ws.Agents["clerk"]; !ok {
+		t.Error("workspace agent missing")
+	}
+	if ws.DefaultModel != "haiku" {
+		t.Errorf("DefaultModel = %q, want overlay value haiku", ws.DefaultModel)
+	}
+	if ws.HistoryDB != base.HistoryDBFor(clientID) {
+		t.Errorf("HistoryDB = %q, want %q", ws.HistoryDB, base.HistoryDBFor(clientID))
+	}
+	if !strings.HasPrefix(ws.AgentsDir, base.ClientDir(clientID)) {
+		t.Errorf("AgentsDir = %q, want under client dir", ws.AgentsDir)
+	}
+	if ws.SmartDispatch.Coordinator != "clerk" {
+		t.Errorf("Coordinator = %q, want clerk", ws.SmartDispatch.Coordinator)
+	}
+	if len(ws.Workspaces) != 0 {
+		t.Error("workspace config should not carry nested workspaces")
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"tetora/internal/config"
 	dtypes "tetora/internal/dispatch"
+	"tetora/internal/provider"
 )
 // --- newUUID tests ---
 
@@ -1561,6 +1564,8 @@ func TestIsTransientError(t *testing.T) {
 		{"service unavailable", true},
 		{"too many requests", true},
 		{"rate limit exceeded", true},
+		{"HTTP 429: {\"error\":\"slow down\"}", true},
+		{"HTTP 529: overloaded_error", true},
 		{"timeout waiting for response", true},
 
 		// Non-transient errors (should NOT trigger failover).
@@ -1706,6 +1711,70 @@ func TestBuildProviderCandidates(t *testing.T) {
 			t.Errorf("expected [claude], got %v", candidates)
 		}
 	})
+
+	t.Run("role provider chain replaces provider and fallbacks", func(t *testing.T) {
+		cfg := &Config{
+			DefaultProvider:   "claude",
+			FallbackProviders: []string{"local"},
+			Agents: map[string]AgentConfig{
+				"dev": {
+					Provider:          "openai",
+					FallbackProviders: []string{"gemini"},
+					ProviderChain:     []string{"claude-code", "anthropic/claude-sonnet-4", "openrouter/openai/gpt-4o"},
+				},
+			},
+		}
+		candidates := buildProviderCandidates(cfg, Task{}, "dev")
+		expected := []string{"claude-code", "anthropic/claude-sonnet-4", "openrouter/openai/gpt-4o", "local"}
+		if strings.Join(candidates, ",") != strings.Join(expected, ",") {
+			t.Fatalf("expected %v, got %v", expected, candidates)
+		}
+		if p, m := splitProviderStep(candidates[2]); p != "openrouter" || m != "openai/gpt-4o" {
+			t.Errorf("splitProviderStep = %q, %q", p, m)
+		}
+
+		// An explicit task provider bypasses the chain.
+		candidates = buildProviderCandidates(cfg, Task{Provider: "codex"}, "dev")
+		if strings.Join(candidates, ",") != "codex,gemini,local" {
+			t.Errorf("task provider: got %v", candidates)
+		}
+	})
+}
+
+func TestExecuteWithProvider_ChainFailsOverWithModel(t *testing.T) {
+	cfg := &Config{
+		Agents: map[string]AgentConfig{
+			"dev": {ProviderChain: []string{"primary", "backup/backup-model"}},
+		},
+	}
+	primary := &chainTestProvider{err: "HTTP 429: rate limited"}
+	backup := &chainTestProvider{}
+	reg := newProviderRegistry()
+	reg.Register("primary", primary)
+	reg.Register("backup", backup)
+
+	res := executeWithProvider(context.Background(), cfg, Task{ID: "t1", Model: "sonnet", Timeout: "1m"}, "dev", reg, nil)
+	if res.IsError || res.Provider != "backup" {
+		t.Fatalf("result = %+v", res)
+	}
+	if primary.model != "sonnet" || backup.model != "backup-model" {
+		t.Errorf("models: primary %q, backup %q", primary.model, backup.model)
+	}
+}
+
+type chainTestProvider struct {
+	err   string
+	model string
+}
+
+func (p *chainTestProvider) Name() string { return "chain-test" }
+
+func (p *chainTestProvider) Execute(_ context.Context, req provider.Request) (*provider.Result, error) {
+	p.model = req.Model
+	if p.err != "" {
+		return &provider.Result{IsError: true, Error: p.err}, nil
+	}
+	return &provider.Result{Output: "ok"}, nil
 }
 
 // --- from classify_test.go ---
@@ -2644,3 +2713,304 @@ func TestResolveTaskComplexity_InvalidHint_FallsBackAndWarns(t *testing.T) {
 		t.Errorf("invalid hint fallback mismatch: got %v want %v", got, want)
 	}
 }
+
+// --- Spend approval tests ---
+
+type fakeSpendGate struct {
+	approve  bool
+	err      error
+	requests int
+}
+
+func (g *fakeSpendGate) RequestApproval(ctx context.Context, req ApprovalRequest) (bool, error) {
+	g.requests++
+	return g.approve, g.err
+}
+func (g *fakeSpendGate) AutoApprove(toolName string)         {}
+func (g *fakeSpendGate) IsAutoApproved(toolName string) bool { return false }
+
+func TestCheckSpendApproval(t *testing.T) {
+	cfg := &Config{DefaultModel: "sonnet"}
+	cfg.Estimate.ApprovalThreshold = 0.000001
+	task := Task{ID: "t1", Name: "big", Prompt: strings.Repeat("work ", 200)}
+
+	approve := &fakeSpendGate{approve: true}
+	task.ApprovalGate = approve
+	if err := checkSpendApproval(context.Background(), cfg, task, ""); err != nil {
+		t.Fatalf("approved: unexpected error: %v", err)
+	}
+	if approve.requests != 1 {
+		t.Fatalf("approved: requests = %d, want 1", approve.requests)
+	}
+
+	reject := &fakeSpendGate{approve: false}
+	task.ApprovalGate = reject
+	if err := checkSpendApproval(context.Background(), cfg, task, ""); err == nil {
+		t.Fatal("rejected: expected error")
+	}
+
+	task.ApprovalGate = nil
+	if err := checkSpendApproval(context.Background(), cfg, task, ""); err == nil {
+		t.Fatal("no channel: expected task to be blocked")
+	}
+
+	// Below threshold never asks.
+	cfg.Estimate.ApprovalThreshold = 1000
+	task.ApprovalGate = reject
+	reject.requests = 0
+	if err := checkSpendApproval(context.Background(), cfg, task, ""); err != nil {
+		t.Fatalf("below threshold: unexpected error: %v", err)
+	}
+	if reject.requests != 0 {
+		t.Fatalf("below threshold: requests = %d, want 0", reject.requests)
+	}
+}
+
+// --- External action approval tests ---
+
+// fakeActionGate records the last request and answers as the given user.
+type fakeActionGate struct {
+	approve bool
+	by      string
+	last    ApprovalRequest
+}
+
+func (g *fakeActionGate) RequestApproval(ctx context.Context, req ApprovalRequest) (bool, error) {
+	g.last = req
+	if req.OnDecision != nil {
+		req.OnDecision(g.approve, g.by)
+	}
+	return g.approve, nil
+}
+func (g *fakeActionGate) AutoApprove(toolName string)         {}
+func (g *fakeActionGate) IsAutoApproved(toolName string) bool { return true }
+
+func TestCheckExternalAction(t *testing.T) {
+	cfg := &Config{}
+	cfg.ApprovalGates.ExternalActions = []string{"email_send", "ha_*"}
+	mail := ToolCall{Name: "email_send", Input: json.RawMessage(
+		`{"to":["ceo@example.com"],"subject":"Offer","body":"Please find the offer attached.","attachment":"offer.pdf"}`)}
+
+	// Not listed: no approval, even with approval gates off.
+	if err := checkExternalAction(context.Background(), cfg, Task{}, ToolCall{Name: "email_read"}); err != nil {
+		t.Fatalf("unlisted tool: %v", err)
+	}
+
+	// Listed tools are asked every time, even when the gate auto-approves.
+	gate := &fakeActionGate{approve: true, by: "discord:42"}
+	task := Task{ID: "t1", Agent: "kokuyou", ApprovalGate: gate}
+	if err := checkExternalAction(context.Background(), cfg, task, mail); err != nil {
+		t.Fatalf("approved: %v", err)
+	}
+	req := gate.last
+	if !req.External || req.Tool != "email_send" || req.TaskID != "t1" {
+		t.Errorf("request = %+v", req)
+	}
+	for _, want := range []string{"to: ceo@example.com", "subject: Offer", "Please find the offer attached.", `"attachment": "offer.pdf"`} {
+		if !strings.Contains(req.Preview, want) {
+			t.Errorf("preview missing %q:\n%s", want, req.Preview)
+		}
+	}
+
+	gate.approve, gate.by = false, "telegram:@owner"
+	err := checkExternalAction(context.Background(), cfg, task, ToolCall{Name: "ha_call_service", Input: json.RawMessage(`{"domain":"lock","service":"unlock"}`)})
+	if err == nil || !strings.Contains(err.Error(), "telegram:@owner") {
+		t.Fatalf("rejected: err = %v", err)
+	}
+	if !strings.Contains(gate.last.Preview, `"service": "unlock"`) {
+		t.Errorf("JSON preview = %s", gate.last.Preview)
+	}
+
+	// No approval channel: refused rather than run unapproved.
+	if err := checkExternalAction(context.Background(), cfg, Task{ID: "t2"}, mail); err == nil {
+		t.Fatal("no channel: expected the action to be refused")
+	}
+}
+
+func TestCheckExternalAction_PublishingTools(t *testing.T) {
+	// Publishing tools need approval even when externalActions is empty.
+	cfg := &Config{}
+	gate := &fakeActionGate{approve: true, by: "discord:42"}
+	text := strings.Repeat("Threads are split before posting. ", 12) + "\n---\nThe end."
+	input, _ := json.Marshal(map[string]string{"text": text, "at": "2026-10-20T09:00:00+09:00"})
+	tc := ToolCall{Name: "tweet_schedule", Input: input}
+	if err := checkExternalAction(context.Background(), cfg, Task{ID: "t1", ApprovalGate: gate}, tc); err != nil {
+		t.Fatalf("approved: %v", err)
+	}
+	for _, want := range []string{"at: 2026-10-20T09:00:00+09:00", "tweets: 3", "posting. 1/3", "The end. 3/3"} {
+		if !strings.Contains(gate.last.Preview, want) {
+			t.Errorf("preview missing %q:\n%s", want, gate.last.Preview)
+		}
+	}
+	if isExternalAction(cfg, "tweet_draft") || isExternalAction(cfg, "tweet_stats") {
+		t.Error("composing drafts and reading stats should not need approval")
+	}
+}
+
+func TestCheckExternalAction_PayloadTooLong(t *testing.T) {
+	// A payload the approval message cannot show in full is refused
+	// without asking, rather than approved from a truncated preview.
+	cfg := &Config{}
+	cfg.ApprovalGates.ExternalActions = []string{"email_send"}
+	gate := &fakeActionGate{approve: true, by: "discord:42"}
+	body := strings.Repeat("請", previewLimit) + "\nBCC the competitor."
+	input, _ := json.Marshal(map[string]string{"to": "ceo@example.com", "body": body})
+	err := checkExternalAction(context.Background(), cfg, Task{ID: "t1", ApprovalGate: gate}, ToolCall{Name: "email_send", Input: input})
+	if err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("err = %v, want payload refused", err)
+	}
+	if gate.last.ID != "" {
+		t.Errorf("approval was requested for a truncated preview: %+v", gate.last)
+	}
+
+	// Previews are cut on a rune boundary.
+	preview, complete := fencePreview(body)
+	if complete || !utf8.ValidString(preview) || utf8.RuneCountInString(preview) != previewLimit+len("...") {
+		t.Errorf("fencePreview = %d runes, complete %v, valid %v", utf8.RuneCountInString(preview), complete, utf8.ValidString(preview))
+	}
+}
+
+func TestCheckExternalAction_SSHExec(t *testing.T) {
+	cfg := &Config{}
+	cfg.SSH.Hosts = map[string]config.SSHHostConfig{
+		"nas":  {Allow: []string{"df *"}},
+		"home": {Allow: []string{"uptime"}, Trust: "auto", Agents: []string{"kokuyou"}},
+	}
+	gate := &fakeActionGate{approve: true, by: "discord:42"}
+	task := Task{ID: "t1", Agent: "ruri", ApprovalGate: gate}
+	ssh := func(host, command string) ToolCall {
+		input, _ := json.Marshal(map[string]string{"host": host, "command": command})
+		return ToolCall{Name: "ssh_exec", Input: input}
+	}
+
+	// Suggest hosts (the default) wait for the owner.
+	if err := checkExternalAction(context.Background(), cfg, task, ssh("nas", "df -h")); err != nil {
+		t.Fatalf("approved: %v", err)
+	}
+	if gate.last.Summary != "Run on nas: df -h" {
+		t.Errorf("summary = %q", gate.last.Summary)
+	}
+
+	// Agents outside the host's list are refused; listed ones run unasked.
+	if err := checkExternalAction(context.Background(), cfg, task, ssh("home", "uptime")); err == nil || !strings.Contains(err.Error(), "may not use") {
+		t.Errorf("other agent: %v", err)
+	}
+	gate.last = ApprovalRequest{}
+	task.Agent = "kokuyou"
+	if err := checkExternalAction(context.Background(), cfg, task, ssh("home", "uptime")); err != nil || gate.last.Tool != "" {
+		t.Errorf("auto host: %v, asked %+v", err, gate.last)
+	}
+
+	// Commands outside the allowlist are left to the tool to refuse.
+	if err := checkExternalAction(context.Background(), cfg, task, ssh("nas", "reboot")); err != nil || gate.last.Tool != "" {
+		t.Errorf("unlisted command: %v, asked %+v", err, gate.last)
+	}
+}
+
+func TestCheckExternalAction_K8sMutation(t *testing.T) {
+	cfg := &Config{}
+	gate := &fakeActionGate{approve: false, by: "discord:42"}
+	input, _ := json.Marshal(map[string]any{"cluster": "prod", "kind": "deployments", "name": "web", "replicas": 0})
+	err := checkExternalAction(context.Background(), cfg, Task{ID: "t1", ApprovalGate: gate}, ToolCall{Name: "k8s_scale", Input: input})
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("rejected scale: %v", err)
+	}
+	if gate.last.Summary != "Scale deployments/web on prod to 0 replicas" {
+		t.Errorf("summary = %q", gate.last.Summary)
+	}
+	if isExternalAction(cfg, "k8s_get") || isExternalAction(cfg, "k8s_logs") {
+		t.Error("reading a cluster should not need approval")
+	}
+}
+
+func TestCheckExternalAction_DBQuery(t *testing.T) {
+	cfg := &Config{}
+	cfg.Databases.Connections = map[string]config.DBConnectionConfig{
+		"shop": {Driver: "sqlite", DSN: "shop.db", Agents: []string{"analyst"}},
+	}
+	input, _ := json.Marshal(map[string]string{"connection": "shop", "query": "SELECT 1"})
+	tc := ToolCall{Name: "db_query", Input: input}
+	if err := checkExternalAction(context.Background(), cfg, Task{ID: "t1", Agent: "ruri"}, tc); err == nil || !strings.Contains(err.Error(), "may not query") {
+		t.Errorf("other agent: %v", err)
+	}
+	if err := checkExternalAction(context.Background(), cfg, Task{ID: "t1", Agent: "analyst"}, tc); err != nil {
+		t.Errorf("listed agent: %v", err)
+	}
+}
+
+func TestAssignVariant(t *testing.T) {
+	cfg := &Config{Agents: map[string]AgentConfig{
+		"ruri":  {Experiment: &config.AgentExperimentConfig{Variants: []config.AgentVariantConfig{{Name: "a"}, {Name: "b"}}}},
+		"plain": {},
+	}}
+	task := Task{ID: "t1", SessionID: "s1", Variant: "stale"}
+	assignVariant(cfg, &task, "plain")
+	if task.Variant != "" {
+		t.Errorf("agent without experiment got variant %q", task.Variant)
+	}
+	assignVariant(cfg, &task, "ruri")
+	if task.Variant != "a" && task.Variant != "b" {
+		t.Fatalf("variant = %q", task.Variant)
+	}
+	// The same session keeps its variant; a named variant is kept.
+	again := Task{ID: "t2", SessionID: "s1"}
+	assignVariant(cfg, &again, "ruri")
+	if again.Variant != task.Variant {
+		t.Errorf("session moved from %q to %q", task.Variant, again.Variant)
+	}
+	pinned := Task{ID: "t3", SessionID: "s1", Variant: "b"}
+	if assignVariant(cfg, &pinned, "ruri"); pinned.Variant != "b" {
+		t.Errorf("named variant replaced by %q", pinned.Variant)
+	}
+}
+
+// --- Agent schedule window tests ---
+
+func TestAgentScheduleNow(t *testing.T) {
+	cfg := &Config{Agents: map[string]AgentConfig{
+		"scraper": {Schedule: &AgentScheduleConfig{TZ: "UTC", Windows: []AgentScheduleWindow{{Start: "02:00", End: "05:00"}}}},
+		"free":    {},
+	}}
+
+	noon := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	if ok, _ := agentScheduleNow(cfg, "free", noon); !ok {
+		t.Error("agent without schedule should always run")
+	}
+	ok, next := agentScheduleNow(cfg, "scraper", noon)
+	if ok {
+		t.Fatal("scraper should not run at noon")
+	}
+	if want := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("next window = %v, want %v", next, want)
+	}
+	if ok, _ := agentScheduleNow(cfg, "scraper", time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)); !ok {
+		t.Error("scraper should run at 03:00")
+	}
+}
+
+func TestDeferOutsideSchedule(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := initDeferredDB(dbPath); err != nil {
+		t.Fatalf("initDeferredDB: %v", err)
+	}
+	// A window that never contains "now": one minute, one hour ahead.
+	start := time.Now().UTC().Add(time.Hour)
+	cfg := &Config{HistoryDB: dbPath, Agents: map[string]AgentConfig{
+		"night": {Schedule: &AgentScheduleConfig{TZ: "UTC", Windows: []AgentScheduleWindow{{
+			Start: start.Format("15:04"), End: start.Add(time.Minute).Format("15:04"),
+		}}}},
+	}}
+
+	task := Task{ID: "t-defer", Name: "scrape", Prompt: "go"}
+	result, deferred := deferOutsideSchedule(context.Background(), cfg, task, "night")
+	if !deferred || result.Status != "deferred" {
+		t.Fatalf("expected deferred result, got deferred=%v status=%q", deferred, result.Status)
+	}
+	items := queryDeferred(dbPath, "night")
+	if len(items) != 1 {
+		t.Fatalf("expected 1 deferred task, got %d", len(items))
+	}
+	if _, deferred := deferOutsideSchedule(context.Background(), cfg, task, "other"); deferred {
+		t.Error("unscheduled agent should not be deferred")
+	}
+}
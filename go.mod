@@ -2,4 +2,7 @@ module tetora
 
 go 1.25.0
 
-require golang.org/x/sys v0.42.0 // indirect
+require (
+	github.com/tetratelabs/wazero v1.9.0
+	golang.org/x/sys v0.42.0
+)
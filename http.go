@@ -5,13 +5,15 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"crypto/tls"
 	"embed"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
@@ -29,32 +31,71 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"tetora/internal/abtest"
 	"tetora/internal/audit"
 	tetoraConfig "tetora/internal/config"
 	
+	"tetora/internal/cfgfile"
+	"tetora/internal/chart"
 	"tetora/internal/cli"
 	"tetora/internal/cost"
 	"tetora/internal/db"
 	"tetora/internal/discord"
+	"tetora/internal/docwatch"
+	"tetora/internal/emergency"
+	"tetora/internal/faq"
+	"tetora/internal/feedback"
+	"tetora/internal/handover"
 	dispatchpkg "tetora/internal/dispatch"
 	"tetora/internal/history"
 	"tetora/internal/httpapi"
+	"tetora/internal/intent"
 	"tetora/internal/knowledge"
+	"tetora/internal/leader"
+	"tetora/internal/life/profile"
 	"tetora/internal/log"
+	"tetora/internal/messaging/simulate"
 	"tetora/internal/messaging/webhook"
+	"tetora/internal/integration/forge"
+	"tetora/internal/integration/health"
+	"tetora/internal/integration/mailin"
+	"tetora/internal/integration/readlater"
+	"tetora/internal/integration/social"
+	"tetora/internal/integration/tasksync"
+	"tetora/internal/integration/twitter"
+	"tetora/internal/gitops"
+	"tetora/internal/mirror"
+	"tetora/internal/monitor"
+	"tetora/internal/memory"
+	"tetora/internal/pipeline"
+	"tetora/internal/mtls"
+	"tetora/internal/oidc"
 	"tetora/internal/pairing"
+	"tetora/internal/prompttmpl"
 	"tetora/internal/provider"
+	"tetora/internal/qr"
+	"tetora/internal/replica"
+	"tetora/internal/respcache"
 	"tetora/internal/pwa"
 	"tetora/internal/quickaction"
+	"tetora/internal/redact"
 	"tetora/internal/session"
+	"tetora/internal/siem"
+	"tetora/internal/skill"
 	"tetora/internal/sla"
 	"tetora/internal/sprite"
+	"tetora/internal/statesync"
 	"tetora/internal/store"
 	"tetora/internal/team"
+	"tetora/internal/timeline"
+	"tetora/internal/totp"
 	"tetora/internal/trace"
+	"tetora/internal/translate"
 	"tetora/internal/upload"
+	"tetora/internal/validate"
 	"tetora/internal/version"
 	"tetora/internal/voice"
+	"tetora/internal/workqueue"
 )
 
 // isValidOutputFilename checks that a filename contains only safe characters.
@@ -91,34 +132,31 @@ func jsonError(w http.ResponseWriter, msg string, code int) {
 // authMiddleware checks Bearer token on API endpoints.
 // Skips auth for /healthz, /dashboard, and static assets.
 // If token is empty, auth is disabled (backward compatible).
-func authMiddleware(cfg *Config, secMon *securityMonitor, next http.Handler) http.Handler {
+// A live SSO session from sso (nil when SSO is off) also authenticates.
+func authMiddleware(cfg *Config, sso *oidc.Manager, secMon *securityMonitor, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if cfg.APIToken == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Skip auth for health check, metrics, dashboard, Slack events, WhatsApp webhook, Discord interactions, LINE webhook, Teams webhook, Signal webhook, Google Chat webhook, and iMessage webhook.
+		// Skip auth for health check, metrics, dashboard, Slack events and interactions, WhatsApp webhook, Telegram webhook, Discord interactions, LINE webhook, Teams webhook, Signal webhook, Google Chat webhook, iMessage webhook, the Mailgun inbound route, the code forge webhooks and integration re-enable links (checked against their one-time token).
 		p := r.URL.Path
-		if p == "/" || p == "/healthz" || p == "/metrics" || p == "/dashboard" || strings.HasPrefix(p, "/dashboard/") || p == "/slack/events" || p == "/api/whatsapp/webhook" || p == "/api/discord/interactions" || strings.HasPrefix(p, "/api/line/") || strings.HasPrefix(p, "/api/teams/") || strings.HasPrefix(p, "/api/signal/") || strings.HasPrefix(p, "/api/gchat/") || strings.HasPrefix(p, "/api/imessage/") || p == "/api/docs" || p == "/api/spec" || strings.HasPrefix(p, "/hooks/") || isHooksPath(p) || (strings.HasPrefix(p, "/api/oauth/") && strings.HasSuffix(p, "/callback")) || strings.HasPrefix(p, "/api/callbacks/") {
+		if p == "/" || p == "/healthz" || p == "/metrics" || p == "/dashboard" || strings.HasPrefix(p, "/dashboard/") || p == "/slack/events" || p == "/slack/interactions" || p == "/api/whatsapp/webhook" || (cfg.Telegram.WebhookMode && p == cfg.Telegram.WebhookPathOrDefault()) || p == "/api/discord/interactions" || strings.HasPrefix(p, "/api/line/") || strings.HasPrefix(p, "/api/teams/") || strings.HasPrefix(p, "/api/signal/") || strings.HasPrefix(p, "/api/gchat/") || strings.HasPrefix(p, "/api/imessage/") || p == "/api/mail/inbound" || p == "/api/github/webhook" || p == "/api/gitops/webhook" || (strings.HasPrefix(p, "/api/forge/") && strings.HasSuffix(p, "/webhook")) || (p == "/api/integrations/health/enable" && r.Method == http.MethodGet && r.URL.Query().Get("token") != "") || p == "/api/docs" || p == "/api/spec" || strings.HasPrefix(p, "/hooks/") || isHooksPath(p) || (strings.HasPrefix(p, "/api/oauth/") && strings.HasSuffix(p, "/callback")) || strings.HasPrefix(p, "/api/callbacks/") {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Allow requests with valid dashboard session cookie (same-origin API calls from dashboard).
-		if cookie, err := r.Cookie("tetora_session"); err == nil {
-			secret := cfg.DashboardAuth.Password
-			if secret == "" {
-				secret = cfg.DashboardAuth.Token
-			}
-			if secret != "" && validateDashboardCookie(cookie.Value, secret) {
-				next.ServeHTTP(w, r)
-				return
-			}
+		// Allow requests with a dashboard login (same-origin API calls from dashboard).
+		if _, _, ok := dashboardLogin(cfg, sso, r); ok {
+			next.ServeHTTP(w, r)
+			return
 		}
 
 		// Allow same-origin requests from dashboard (Referer-based).
-		if ref := r.Header.Get("Referer"); ref != "" {
+		// Not with SSO or role accounts: the Referer would let any caller
+		// skip role checks.
+		if ref := r.Header.Get("Referer"); ref != "" && sso == nil && len(cfg.DashboardAuth.Accounts) == 0 {
 			if strings.Contains(ref, "/dashboard") {
 				next.ServeHTTP(w, r)
 				return
@@ -126,9 +164,13 @@ func authMiddleware(cfg *Config, secMon *securityMonitor, next http.Handler) htt
 		}
 
 		auth := r.Header.Get("Authorization")
+		if _, ok := cfg.WorkspaceByToken(strings.TrimPrefix(auth, "Bearer ")); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
 		if auth == "" || auth != "Bearer "+cfg.APIToken {
 			ip := clientIP(r)
-			audit.Log(cfg.HistoryDB, "api.auth.fail", "http", p, ip)
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "api.auth.fail", "http", p, ip)
 			if secMon != nil {
 				secMon.recordEvent(ip, "auth.fail")
 			}
@@ -142,6 +184,35 @@ func authMiddleware(cfg *Config, secMon *securityMonitor, next http.Handler) htt
 	})
 }
 
+// clientCertMiddleware requires a verified client certificate when mutual TLS
+// is on, except on exempt paths, and puts the certificate's identity on the
+// request context so audit entries record who made the call.
+func clientCertMiddleware(ts *mtls.Server, dbPath string, next http.Handler) http.Handler {
+	if ts == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The unix socket is only reachable by the daemon's own user.
+		if !ts.Mutual() || httpapi.FromUnixSocket(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, err := ts.Authenticate(r.TLS)
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(audit.WithIdentity(r.Context(), id)))
+			return
+		}
+		if ts.Exempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		audit.LogCtx(r.Context(), dbPath, "api.mtls.fail", "http", r.URL.Path+": "+err.Error(), clientIP(r))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"client certificate required"}`))
+	})
+}
+
 // --- Multi-tenant client identification ---
 
 // contextKey is used for context value keys to avoid collisions.
@@ -189,6 +260,96 @@ func clientMiddleware(defaultClientID string, next http.Handler) http.Handler {
 	})
 }
 
+// workspaceMiddleware pins requests authenticated with a workspace token to
+// that workspace's client ID. Such a token cannot be used to reach another
+// client; the main API token may still select any client via X-Client-ID.
+func workspaceMiddleware(cfg *Config, next http.Handler) http.Handler {
+	if len(cfg.Workspaces) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, ok := cfg.WorkspaceByToken(strings.TrimPrefix(auth, "Bearer "))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isWorkspaceScopedPath(r.URL.Path) {
+			http.Error(w, `{"error":"endpoint not available to workspace tokens"}`, http.StatusForbidden)
+			return
+		}
+		clientID := cfg.WorkspaceClientID(name)
+		if id := r.Header.Get("X-Client-ID"); id != "" && id != clientID {
+			http.Error(w, `{"error":"workspace token cannot access another client"}`, http.StatusForbidden)
+			return
+		}
+		r.Header.Set("X-Client-ID", clientID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// standbyMiddleware makes a standby daemon read-only: requests that could
+// change state are refused with 503 and pointed at the active daemon until
+// this one is promoted, or at the primary on a replica, which never is.
+// Dashboard login, drain and the emergency stop stay available.
+func standbyMiddleware(el *leader.Elector, next http.Handler) http.Handler {
+	if el == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case r.URL.Path == "/dashboard/login", r.URL.Path == "/dashboard/logout",
+			strings.HasPrefix(r.URL.Path, "/dashboard/oidc/"), r.URL.Path == "/api/admin/drain",
+			r.URL.Path == "/emergency/stop":
+			next.ServeHTTP(w, r)
+			return
+		}
+		if el.IsActive() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		st := el.Status()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if st.Role == "replica" {
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "replica is read-only; send writes to the primary",
+				"primary": st.LeaderAddr,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":      "standby node is read-only; send writes to the active node",
+			"leader":     st.Leader,
+			"leaderAddr": st.LeaderAddr,
+		})
+	})
+}
+
+// isWorkspaceScopedPath reports whether an endpoint resolves its data per client.
+// Workspace tokens are limited to these so they cannot read daemon-wide state.
+func isWorkspaceScopedPath(p string) bool {
+	switch p {
+	case "/dispatch", "/route", "/review", "/tasks", "/tasks/running", "/history", "/healthz":
+		return true
+	}
+	for _, prefix := range []string{"/dispatch/", "/route/", "/cancel/", "/history/"} {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // getClientID extracts the client ID from request context (set by clientMiddleware).
 func getClientID(r *http.Request) string {
 	if id, ok := r.Context().Value(clientIDKey).(string); ok {
@@ -232,8 +393,148 @@ func validateDashboardCookie(cookie, secret string) bool {
 	return time.Since(time.Unix(tsInt, 0)) < 24*time.Hour
 }
 
+// dashboardSecret is the main dashboard password, or the token when no
+// password is set. Empty means there is no admin password login.
+func dashboardSecret(cfg *Config) string {
+	if cfg.DashboardAuth.Password != "" {
+		return cfg.DashboardAuth.Password
+	}
+	return cfg.DashboardAuth.Token
+}
+
+// dashboardAccountCookie signs a session cookie for a dashboard account as
+// name:timestamp:hmac. The MAC is keyed by the account's own password, so
+// the name cannot be swapped for another account's.
+func dashboardAccountCookie(name, password string) string {
+	return name + ":" + dashboardAuthCookie(dashboardCookieKey(name, password))
+}
+
+// globalTOTP holds dashboard two-factor enrollments; nil unless
+// dashboardAuth.totp.enabled.
+var globalTOTP *totp.Manager
+
+// dashboardCookieKey is the key a password login's cookies are signed with.
+// login is the account name, "" for the main password. Once the login has
+// enrolled a second factor its secret is mixed in, so the password alone
+// cannot mint a session and sessions from before enrollment stop validating.
+func dashboardCookieKey(login, password string) string {
+	if secret := globalTOTP.Secret(login); secret != "" {
+		return password + ":" + secret
+	}
+	return password
+}
+
+// dashboardLoginPassword is the password of a password login, "" when the
+// login does not exist.
+func dashboardLoginPassword(cfg *Config, login string) string {
+	if login == "" {
+		return dashboardSecret(cfg)
+	}
+	for _, acct := range cfg.DashboardAuth.Accounts {
+		if acct.Name == login {
+			return acct.Password
+		}
+	}
+	return ""
+}
+
+// dashboardSessionCookie signs a fresh tetora_session value for a password login.
+func dashboardSessionCookie(cfg *Config, login string) string {
+	if login == "" {
+		return dashboardAuthCookie(dashboardCookieKey("", dashboardSecret(cfg)))
+	}
+	return dashboardAccountCookie(login, dashboardLoginPassword(cfg, login))
+}
+
+// setDashboardSession sets the tetora_session cookie.
+func setDashboardSession(w http.ResponseWriter, cfg *Config, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "tetora_session",
+		Value:    value,
+		Path:     "/",
+		MaxAge:   86400, // 24h
+		HttpOnly: true,
+		Secure:   cfg.TLSEnabled,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// dashboardCookieRole validates a tetora_session cookie and returns the
+// account it was issued to and its role. The main password login has no
+// account name and is admin.
+func dashboardCookieRole(cfg *Config, value string) (name, role string, ok bool) {
+	if parts := strings.SplitN(value, ":", 3); len(parts) == 3 {
+		for _, acct := range cfg.DashboardAuth.Accounts {
+			if acct.Name == parts[0] && acct.Password != "" &&
+				validateDashboardCookie(parts[1]+":"+parts[2], dashboardCookieKey(acct.Name, acct.Password)) {
+				return acct.Name, acct.Role, true
+			}
+		}
+		return "", "", false
+	}
+	if secret := dashboardSecret(cfg); secret != "" && validateDashboardCookie(value, dashboardCookieKey("", secret)) {
+		return "", oidc.RoleAdmin, true
+	}
+	return "", "", false
+}
+
+// totpPendingCookie carries a login that passed the password step to the
+// second-factor step, as login:timestamp:hmac. It is good for totpPendingTTL.
+const (
+	totpPendingCookie = "tetora_2fa"
+	totpPendingTTL    = 5 * time.Minute
+)
+
+// dashboardPendingCookie signs a pending second-factor cookie for login.
+func dashboardPendingCookie(cfg *Config, login string) string {
+	return login + ":" + dashboardAuthCookie("2fa:"+dashboardCookieKey(login, dashboardLoginPassword(cfg, login)))
+}
+
+// dashboardPendingLogin validates a pending second-factor cookie and
+// returns the login it was issued to.
+func dashboardPendingLogin(cfg *Config, value string) (login string, ok bool) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	password := dashboardLoginPassword(cfg, parts[0])
+	if password == "" || !validateDashboardCookie(parts[1]+":"+parts[2], "2fa:"+dashboardCookieKey(parts[0], password)) {
+		return "", false
+	}
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) >= totpPendingTTL {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// dashboardLogin returns who r is signed in to the dashboard as, from an SSO
+// session or a password session cookie, and that login's role.
+func dashboardLogin(cfg *Config, sso *oidc.Manager, r *http.Request) (user, role string, ok bool) {
+	if sess := ssoSession(sso, r); sess != nil {
+		return sess.Email, sess.Role, true
+	}
+	if cookie, err := r.Cookie("tetora_session"); err == nil {
+		return dashboardCookieRole(cfg, cookie.Value)
+	}
+	return "", "", false
+}
+
+// dashboardCapabilities tells the dashboard which controls role may use.
+// The server enforces the same rules; this only lets the UI hide the rest.
+func dashboardCapabilities(role string) map[string]bool {
+	return map[string]bool{
+		"dispatch": oidc.Allows(role, http.MethodPost, "/dispatch"),
+		"cron":     oidc.Allows(role, http.MethodPost, "/cron"),
+		"budget":   oidc.Allows(role, http.MethodPost, "/budget/pause"),
+		"config":   oidc.Allows(role, http.MethodPost, "/api/config"),
+		"audit":    oidc.Allows(role, http.MethodGet, "/audit"),
+	}
+}
+
 // dashboardAuthMiddleware protects /dashboard paths when dashboard auth is enabled.
-func dashboardAuthMiddleware(cfg *Config, next http.Handler) http.Handler {
+// Requests carrying a dashboard login are held to that login's role on every path.
+func dashboardAuthMiddleware(cfg *Config, sso *oidc.Manager, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !cfg.DashboardAuth.Enabled {
 			next.ServeHTTP(w, r)
@@ -241,14 +542,26 @@ func dashboardAuthMiddleware(cfg *Config, next http.Handler) http.Handler {
 		}
 
 		p := r.URL.Path
+		user, role, loggedIn := dashboardLogin(cfg, sso, r)
+		if loggedIn && !oidc.Allows(role, r.Method, p) {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.forbidden", "http",
+				fmt.Sprintf("user=%s role=%s %s %s", user, role, r.Method, p), clientIP(r))
+			jsonError(w, "your dashboard role ("+role+") does not allow this", http.StatusForbidden)
+			return
+		}
+
 		// Only protect dashboard paths.
 		if p != "/dashboard" && !strings.HasPrefix(p, "/dashboard/") {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Allow login page through.
-		if p == "/dashboard/login" {
+		// Allow login, SSO and logout pages through.
+		if p == "/dashboard/login" || p == "/dashboard/logout" || strings.HasPrefix(p, "/dashboard/oidc/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if loggedIn {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -259,23 +572,39 @@ func dashboardAuthMiddleware(cfg *Config, next http.Handler) http.Handler {
 			return
 		}
 
-		// Check cookie.
-		secret := cfg.DashboardAuth.Password
-		if secret == "" {
-			secret = cfg.DashboardAuth.Token
-		}
-		if cookie, err := r.Cookie("tetora_session"); err == nil {
-			if validateDashboardCookie(cookie.Value, secret) {
-				next.ServeHTTP(w, r)
-				return
-			}
+		// Not authenticated — redirect to login, or straight to the
+		// provider when SSO is the only way in.
+		if sso != nil && dashboardSecret(cfg) == "" && len(cfg.DashboardAuth.Accounts) == 0 {
+			http.Redirect(w, r, "/dashboard/oidc/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
 		}
-
-		// Not authenticated — redirect to login.
 		http.Redirect(w, r, "/dashboard/login", http.StatusFound)
 	})
 }
 
+// ssoCookie holds the dashboard SSO session ID; ssoStateCookie binds an
+// in-flight login to the browser that started it.
+const (
+	ssoCookie      = "tetora_sso"
+	ssoStateCookie = "tetora_oidc_state"
+)
+
+// ssoSession returns the live SSO session r carries, or nil.
+func ssoSession(sso *oidc.Manager, r *http.Request) *oidc.Session {
+	if sso == nil {
+		return nil
+	}
+	cookie, err := r.Cookie(ssoCookie)
+	if err != nil {
+		return nil
+	}
+	sess, ok := sso.Session(cookie.Value)
+	if !ok {
+		return nil
+	}
+	return sess
+}
+
 // --- Login Rate Limiter ---
 
 type loginAttempt struct {
@@ -351,14 +680,7 @@ func (ll *loginLimiter) cleanup() {
 }
 
 func clientIP(r *http.Request) string {
-	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
-		return strings.TrimSpace(strings.SplitN(fwd, ",", 2)[0])
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
+	return httpapi.ClientIP(r)
 }
 
 // --- IP Allowlist ---
@@ -425,7 +747,7 @@ func ipAllowlistMiddleware(al *ipAllowlist, dbPath string, next http.Handler) ht
 
 		ip := clientIP(r)
 		if !al.contains(ip) {
-			audit.Log(dbPath, "api.ip.blocked", "http", r.URL.Path, ip)
+			audit.LogCtx(r.Context(), dbPath, "api.ip.blocked", "http", r.URL.Path, ip)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusForbidden)
 			w.Write([]byte(`{"error":"forbidden"}`))
@@ -515,7 +837,7 @@ func rateLimitMiddleware(cfg *Config, rl *apiRateLimiter, next http.Handler) htt
 
 		ip := clientIP(r)
 		if !rl.allow(ip) {
-			audit.Log(cfg.HistoryDB, "api.ratelimit", "http", p, ip)
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "api.ratelimit", "http", p, ip)
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("Retry-After", "60")
 			w.WriteHeader(http.StatusTooManyRequests)
@@ -587,6 +909,12 @@ func startHTTPServer(s *Server) *http.Server {
 	mux := http.NewServeMux()
 	s.limiter = newLoginLimiter()
 	s.apiLimiter = newAPIRateLimiter(cfg.RateLimit.MaxPerMin)
+	if cfg.DashboardAuth.Enabled && cfg.DashboardAuth.OIDC.Enabled {
+		s.sso = oidc.NewManager(cfg.DashboardAuth.OIDC, cfg.HistoryDB)
+	}
+	if cfg.DashboardAuth.Enabled && cfg.DashboardAuth.TOTP.Enabled && cfg.HistoryDB != "" {
+		globalTOTP = totp.NewManager(cfg.HistoryDB, cfg.DashboardAuth.TOTP.Issuer)
+	}
 	allowlist := parseAllowlist(cfg.AllowedIPs)
 
 	// Initialize Canvas Engine.
@@ -605,10 +933,14 @@ func startHTTPServer(s *Server) *http.Server {
 		var handlers []httpapi.WebhookHandler
 		if s.slackBot != nil {
 			handlers = append(handlers, httpapi.WebhookHandler{Path: "/slack/events", Handler: s.slackBot.EventHandler})
+			handlers = append(handlers, httpapi.WebhookHandler{Path: "/slack/interactions", Handler: s.slackBot.InteractionHandler})
 		}
 		if s.whatsappBot != nil {
 			handlers = append(handlers, httpapi.WebhookHandler{Path: "/api/whatsapp/webhook", Handler: s.whatsappBot.WebhookHandler})
 		}
+		if s.telegramBot != nil && cfg.Telegram.WebhookMode {
+			handlers = append(handlers, httpapi.WebhookHandler{Path: cfg.Telegram.WebhookPathOrDefault(), Handler: s.telegramBot.WebhookHandler})
+		}
 		if s.state.discordBot != nil && cfg.Discord.PublicKey != "" {
 			discordBot := s.state.discordBot
 			handlers = append(handlers, httpapi.WebhookHandler{Path: "/api/discord/interactions", Handler: func(w http.ResponseWriter, r *http.Request) {
@@ -719,6 +1051,36 @@ func startHTTPServer(s *Server) *http.Server {
 			} else {
 				checks["heartbeat"] = map[string]any{"enabled": false}
 			}
+			if s.app != nil && s.app.Leader != nil {
+				checks["ha"] = s.app.Leader.Status()
+			}
+			if s.app != nil && s.app.Replica != nil {
+				st := s.app.Replica.Status()
+				checks["replica"] = st
+				if st.Error != "" {
+					if cs, ok := checks["status"].(string); ok {
+						checks["status"] = degradeStatus(cs, "degraded")
+					}
+				}
+			}
+			if s.app != nil && s.app.Replication != nil {
+				st := s.app.Replication.Status()
+				checks["replication"] = st
+				if st.Error != "" {
+					if cs, ok := checks["status"].(string); ok {
+						checks["status"] = degradeStatus(cs, "degraded")
+					}
+				}
+			}
+			if s.app != nil && s.app.GitOps != nil {
+				st := s.app.GitOps.Status()
+				checks["gitops"] = st
+				if st.Error != "" {
+					if cs, ok := checks["status"].(string); ok {
+						checks["status"] = degradeStatus(cs, "degraded")
+					}
+				}
+			}
 			return checks
 		},
 		WriteMetrics: func(w http.ResponseWriter) bool {
@@ -734,15 +1096,44 @@ func startHTTPServer(s *Server) *http.Server {
 	httpapi.RegisterCronRoutes(mux, httpapi.CronDeps{
 		Available:    s.cron != nil,
 		ListJobs:     func() any { return s.cron.ListJobs() },
-		AddJob: func(raw json.RawMessage) error {
-			var jc CronJobConfig
-			if err := json.Unmarshal(raw, &jc); err != nil {
-				return err
+		AddJob: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var req struct {
+				CronJobConfig
+				When string `json:"when"`
+			}
+			if err := json.Unmarshal(raw, &req); err != nil {
+				return "", err
+			}
+			jc, interpreted := req.CronJobConfig, ""
+			if req.When != "" && jc.Schedule == "" {
+				w, source, err := parseWhen(ctx, s.Cfg(), req.When, jc.TZ)
+				if err != nil {
+					return "", fmt.Errorf("when: %v", err)
+				}
+				jc.Schedule, jc.Once = w.Cron, w.Once()
+				interpreted = describeWhen(w, source, jc.TZ)
 			}
 			if jc.ID == "" || jc.Schedule == "" {
-				return fmt.Errorf("id and schedule are required")
+				return "", fmt.Errorf("id and schedule (or when) are required")
+			}
+			return interpreted, s.cron.AddJob(jc)
+		},
+		ParseWhen: func(ctx context.Context, text, tz string) (any, error) {
+			w, source, err := parseWhen(ctx, s.Cfg(), text, tz)
+			if err != nil {
+				return nil, err
 			}
-			return s.cron.AddJob(jc)
+			res := map[string]any{
+				"cron":        w.Cron,
+				"once":        w.Once(),
+				"summary":     w.Summary,
+				"source":      source,
+				"interpreted": describeWhen(w, source, tz),
+			}
+			if w.Once() {
+				res["at"] = w.At.Format(time.RFC3339)
+			}
+			return res, nil
 		},
 		GetJobConfig: func(id string) any { return s.cron.GetJobConfig(id) },
 		UpdateJob: func(id string, raw json.RawMessage) error {
@@ -772,6 +1163,9 @@ func startHTTPServer(s *Server) *http.Server {
 			stats, e := history.QueryCostStats(dbPath)
 			return stats.Today, stats.Week, stats.Month, e
 		},
+		QueryToolCosts: func(dbPath string, days int) (any, error) {
+			return history.QueryToolCostBreakdown(dbPath, days)
+		},
 		QueryDailyStats: func(dbPath string, days int) (any, error) {
 			return history.QueryDailyStats(dbPath, days)
 		},
@@ -789,7 +1183,7 @@ func startHTTPServer(s *Server) *http.Server {
 		CostAlertAction:      func() string { return s.Cfg().CostAlert.Action },
 		Budgets:              func() cost.BudgetConfig { return s.Cfg().Budgets },
 		SetBudgetPaused:      setBudgetPaused,
-		ConfigPath:           func() string { return s.Cfg().BaseDir },
+		ConfigPath:           func() string { return s.Cfg().ConfigFile() },
 		SLAConfig:            func() sla.SLAConfig { return s.Cfg().SLA },
 		AgentNames: func() []string {
 			c := s.Cfg()
@@ -835,7 +1229,11 @@ func startHTTPServer(s *Server) *http.Server {
 		}
 		if cfg.TaskBoard.AutoDispatch.Enabled {
 			disp := newTaskBoardDispatcher(taskBoardEngine, cfg, s.sem, s.childSem, s.state)
-			disp.Start()
+			if s.app != nil {
+				s.app.Leader.WhenActive(disp.Start)
+			} else {
+				disp.Start()
+			}
 			s.taskBoardDispatcher = disp
 		}
 	}
@@ -1055,7 +1453,7 @@ func startHTTPServer(s *Server) *http.Server {
 			if err := updateAgentTrustLevel(cfg, agent, level); err != nil {
 				return nil, err
 			}
-			configPath := filepath.Join(cfg.BaseDir, "config.json")
+			configPath := cfg.ConfigFile()
 			if err := saveAgentTrustLevel(configPath, agent, level); err != nil {
 				log.Warn("persist trust level failed", "agent", agent, "error", err)
 			}
@@ -1090,7 +1488,11 @@ func startHTTPServer(s *Server) *http.Server {
 		ListMemory:      func(role string) (any, error) { return listMemory(cfg, role) },
 		GetMemory:       func(role, key string) (string, error) { return getMemory(cfg, role, key) },
 		SetMemory:       func(agent, key, value string) error { return setMemory(cfg, agent, key, value) },
+		SetMemoryAging: func(key string, ttl time.Duration, confidence float64) error {
+			return setMemoryAging(cfg, key, ttl, confidence)
+		},
 		DeleteMemory:    func(role, key string) error { return deleteMemory(cfg, role, key) },
+		MemoryGraph:     func(types []string) (any, error) { return buildMemoryGraph(cfg, types) },
 		FindConfigPath:  findConfigPath,
 		HistoryDB:       func() string { return s.Cfg().HistoryDB },
 	})
@@ -1101,7 +1503,7 @@ func startHTTPServer(s *Server) *http.Server {
 			sessions, total, err := querySessions(cfg.HistoryDB, q)
 			return sessions, total, err
 		},
-		CreateSession: func(agent, title, ip string) (any, error) {
+		CreateSession: func(agent, title string, agents []string, moderator, ip string) (any, error) {
 			now := time.Now().Format(time.RFC3339)
 			sess := Session{
 				ID:        newUUID(),
@@ -1115,13 +1517,34 @@ func startHTTPServer(s *Server) *http.Server {
 			if sess.Title == "" {
 				sess.Title = "New chat with " + agent
 			}
+			if sess.Title == "New chat with "+agent && len(agents) > 1 {
+				sess.Title = "Group chat: " + strings.Join(agents, ", ")
+			}
 			if err := createSession(cfg.HistoryDB, sess); err != nil {
 				return nil, err
 			}
+			if len(agents) > 0 {
+				if err := session.SetParticipants(cfg.HistoryDB, sess.ID, agents, moderator); err != nil {
+					return nil, err
+				}
+			}
 			audit.Log(cfg.HistoryDB, "session.create", "http",
-				fmt.Sprintf("session=%s role=%s", sess.ID, sess.Agent), ip)
+				fmt.Sprintf("session=%s role=%s agents=%s", sess.ID, sess.Agent, strings.Join(agents, ",")), ip)
 			return sess, nil
 		},
+		GetParticipants: func(id string) (any, error) {
+			if g := querySessionGroup(cfg.HistoryDB, id); g != nil {
+				return g, nil
+			}
+			return session.Group{SessionID: id, Agents: []string{}}, nil
+		},
+		SetParticipants: func(id string, agents []string, moderator string) error {
+			sess, err := querySessionByID(cfg.HistoryDB, id)
+			if err != nil || sess == nil {
+				return fmt.Errorf("session not found")
+			}
+			return session.SetParticipants(cfg.HistoryDB, id, agents, moderator)
+		},
 		GetSessionDetail: func(id string) (any, error) {
 			detail, err := querySessionDetail(cfg.HistoryDB, id)
 			if err != nil {
@@ -1138,7 +1561,7 @@ func startHTTPServer(s *Server) *http.Server {
 		SearchHistory: func(sessionID, query string, limit int) (any, error) {
 			return session.SearchSessionHistory(cfg.HistoryDB, sessionID, query, limit)
 		},
-		SendMessage: func(r *http.Request, sessionID, prompt string, async bool) (any, int, error) {
+		SendMessage: func(r *http.Request, sessionID, prompt, model string, async bool) (any, int, error) {
 			sess, err := querySessionByID(cfg.HistoryDB, sessionID)
 			if err != nil || sess == nil {
 				return nil, http.StatusNotFound, fmt.Errorf("session not found")
@@ -1176,6 +1599,31 @@ func startHTTPServer(s *Server) *http.Server {
 				}
 			}
 
+			// Group session: the addressed agents (or the moderator's pick) answer in turn.
+			if g := querySessionGroup(cfg.HistoryDB, sessionID); g != nil {
+				traceID := trace.IDFromContext(r.Context())
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "session.message", "http",
+					fmt.Sprintf("session=%s agents=%s", sessionID, strings.Join(g.Agents, ",")), clientIP(r))
+				if async {
+					go runGroupTurn(trace.WithID(context.Background(), traceID), cfg, sess, g, prompt, "chat", s.sem, s.childSem)
+					return map[string]any{
+						"sessionId": sessionID,
+						"status":    "running",
+					}, http.StatusAccepted, nil
+				}
+				var replies []map[string]any
+				for _, rep := range runGroupTurn(trace.WithID(context.Background(), traceID), cfg, sess, g, prompt, "chat", s.sem, s.childSem) {
+					replies = append(replies, map[string]any{
+						"agent":  rep.Agent,
+						"result": rep.Result,
+					})
+				}
+				return map[string]any{
+					"sessionId": sessionID,
+					"replies":   replies,
+				}, http.StatusOK, nil
+			}
+
 			task := Task{
 				Prompt:    prompt,
 				Agent:     sess.Agent,
@@ -1184,6 +1632,9 @@ func startHTTPServer(s *Server) *http.Server {
 			}
 			fillDefaults(cfg, &task)
 			task.SessionID = sessionID // Override fillDefaults' new UUID.
+			if model != "" {
+				task.Model = model
+			}
 
 			if async {
 				taskID := task.ID
@@ -1218,7 +1669,7 @@ func startHTTPServer(s *Server) *http.Server {
 					updateSessionStats(cfg.HistoryDB, sessionID, result.CostUSD, result.TokensIn, result.TokensOut, 1)
 				}()
 
-				audit.Log(cfg.HistoryDB, "session.message.async", "http",
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "session.message.async", "http",
 					fmt.Sprintf("session=%s role=%s task=%s", sessionID, sess.Agent, taskID), clientIP(r))
 				return map[string]any{
 					"taskId":    taskID,
@@ -1258,7 +1709,7 @@ func startHTTPServer(s *Server) *http.Server {
 			})
 			updateSessionStats(cfg.HistoryDB, sessionID, result.CostUSD, result.TokensIn, result.TokensOut, 1)
 
-			audit.Log(cfg.HistoryDB, "session.message", "http",
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "session.message", "http",
 				fmt.Sprintf("session=%s role=%s", sessionID, sess.Agent), clientIP(r))
 			return result, http.StatusOK, nil
 		},
@@ -1346,7 +1797,7 @@ func startHTTPServer(s *Server) *http.Server {
 				}()
 			}
 
-			audit.Log(cfg.HistoryDB, "session.mirror", "http",
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "session.mirror", "http",
 				fmt.Sprintf("session=%s role=%s len=%d", sessionID, req.Role, len(req.Content)), clientIP(r))
 			return map[string]any{
 				"status":    "ok",
@@ -1613,6 +2064,9 @@ func startHTTPServer(s *Server) *http.Server {
 			}
 			return msgs, err
 		},
+		RunDiagram: func(historyDB, runID, format string) (string, error) {
+			return workflowRunDiagram(cfg, historyDB, runID, format)
+		},
 		ImportWorkflow: func(body json.RawMessage) (string, int, []string, error) {
 			var pkg struct {
 				TetoraExport string          `json:"tetoraExport"`
@@ -2021,8 +2475,33 @@ func startHTTPServer(s *Server) *http.Server {
 			if content, err := loadAgentPrompt(cfg, name); err == nil {
 				result["soulContent"] = content
 			}
+			if rc.Schedule != nil {
+				allowed, next := agentScheduleNow(cfg, name, time.Now())
+				status := map[string]any{
+					"allowedNow": allowed,
+					"deferred":   len(queryDeferred(cfg.HistoryDB, name)),
+				}
+				if !next.IsZero() {
+					status["nextWindow"] = next.Format(time.RFC3339)
+				}
+				result["schedule"] = rc.Schedule
+				result["scheduleStatus"] = status
+			}
+			if len(rc.Personas) > 0 {
+				result["personas"] = rc.Personas
+			}
 			return result, true
 		},
+		PreviewPersona: func(name string, at time.Time, source, channel string) (map[string]any, bool) {
+			cfg := s.cfg
+			if _, ok := cfg.Agents[name]; !ok {
+				return nil, false
+			}
+			return agentPersonaPreview(cfg, name, at, source, channel), true
+		},
+		Simulate: func(ctx context.Context, name, prompt, trustLevel string) (any, error) {
+			return simulateAgent(ctx, s.cfg, name, prompt, trustLevel)
+		},
 		CreateAgent: func(name, model, permMode, desc, soulFile, soulContent string) error {
 			cfg := s.cfg
 			if soulContent != "" {
@@ -2108,7 +2587,7 @@ func startHTTPServer(s *Server) *http.Server {
 	teamStore := team.NewStorage(cfg.BaseDir)
 	httpapi.RegisterTeamRoutes(mux, httpapi.TeamDeps{
 		Store:      teamStore,
-		ConfigPath: filepath.Join(cfg.BaseDir, "config.json"),
+		ConfigPath: cfg.ConfigFile(),
 		AgentsDir: func() string {
 			if cfg.AgentsDir != "" {
 				return cfg.AgentsDir
@@ -2145,16 +2624,20 @@ func startHTTPServer(s *Server) *http.Server {
 			return knowledge.InitDir(cfg.BaseDir)
 		},
 		HistoryDB: func() string { return s.Cfg().HistoryDB },
-		SearchKnowledge: func(dir, query string, limit int) ([]httpapi.KnowledgeSearchResult, error) {
-			idx, err := knowledge.BuildIndex(dir)
-			if err != nil {
-				return nil, err
+		SearchKnowledge: func(dir, query, agent string, limit int) ([]httpapi.KnowledgeSearchResult, error) {
+			// The watcher keeps a shared index current; otherwise scan per query.
+			idx := globalKnowledgeIndex
+			if idx == nil || dir != s.Cfg().KnowledgeDir {
+				var err error
+				if idx, err = knowledge.BuildIndex(dir); err != nil {
+					return nil, err
+				}
 			}
-			results := idx.Search(query, limit)
+			results := idx.SearchScope(query, limit, knowledgeScope(s.Cfg(), agent))
 			out := make([]httpapi.KnowledgeSearchResult, len(results))
 			for i, r := range results {
 				out[i] = httpapi.KnowledgeSearchResult{
-					Filename: r.Filename, Snippet: r.Snippet,
+					Filename: r.Filename, Heading: r.Heading, Snippet: r.Snippet,
 					Score: r.Score, LineStart: r.LineStart,
 				}
 			}
@@ -2457,6 +2940,36 @@ func startHTTPServer(s *Server) *http.Server {
 	})
 	s.registerHookRoutes(mux)
 	s.registerPlanReviewRoutes(mux)
+	s.registerHandoverRoutes(mux)
+	s.registerFAQRoutes(mux)
+	s.registerIntentRoutes(mux)
+	s.registerTranslateRoutes(mux)
+	s.registerRedactionRoutes(mux)
+	s.registerValidateRoutes(mux)
+	s.registerChannelSimulateRoutes(mux)
+	s.registerUserProfileRoutes(mux)
+	s.registerMonitorRoutes(mux)
+	s.registerKnowledgeWatchRoutes(mux)
+	s.registerPipelineRoutes(mux)
+	s.registerMemoryReviewRoutes(mux)
+	s.registerWorkQueueRoutes(mux)
+	s.registerTwitterRoutes(mux)
+	s.registerSocialRoutes(mux)
+	s.registerReadLaterRoutes(mux)
+	s.registerMailInRoutes(mux)
+	s.registerSyncRoutes(mux)
+	s.registerMirrorRoutes(mux)
+	s.registerGitOpsRoutes(mux)
+	s.registerMQTTRoutes(mux)
+	s.registerForgeRoutes(mux)
+	s.registerTaskSyncRoutes(mux)
+	s.registerTimelineRoutes(mux)
+	s.registerEmergencyRoutes(mux)
+	s.registerIntegrationHealthRoutes(mux)
+	s.registerCapabilityRoutes(mux)
+	s.registerReplicaRoutes(mux)
+	s.registerSSORoutes(mux)
+	s.registerTOTPRoutes(mux)
 	registerDocsRoutesVia(mux)
 	httpapi.RegisterClaudeMCPRoutes(mux)
 
@@ -2477,12 +2990,32 @@ func startHTTPServer(s *Server) *http.Server {
 	mux.HandleFunc("/dashboard/sprites/", handleSprite)
 	mux.HandleFunc("/dashboard", handleDashboard)
 
-	// Middleware chain: recovery → trace → body size → rate limit → dashboard auth → IP allowlist → API auth → client ID → mux
-	handler := recoveryMiddleware(trace.Middleware(bodySizeMiddleware(rateLimitMiddleware(cfg, s.apiLimiter,
-		dashboardAuthMiddleware(cfg,
+	if cfg.TLSEnabled {
+		ts, err := mtls.New(cfg.TLS)
+		if err != nil {
+			log.Error("https server tls setup failed", "error", err)
+			os.Exit(1)
+		}
+		s.tlsServer = ts
+	}
+
+	if err := httpapi.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		log.Warn("http.trustedProxies", "error", err)
+	}
+
+	// Middleware chain: recovery → base path → trace → body size → rate limit → dashboard auth → IP allowlist → client cert → API auth → standby → workspace → client ID → mux
+	var leaderEl *leader.Elector
+	if s.app != nil {
+		leaderEl = s.app.Leader
+	}
+	handler := recoveryMiddleware(httpapi.BasePath(cfg.HTTP.Prefix(), trace.Middleware(bodySizeMiddleware(rateLimitMiddleware(cfg, s.apiLimiter,
+		dashboardAuthMiddleware(cfg, s.sso,
 			ipAllowlistMiddleware(allowlist, cfg.HistoryDB,
-				authMiddleware(cfg, s.secMon,
-					clientMiddleware(cfg.DefaultClientID, mux))))))))
+				clientCertMiddleware(s.tlsServer, cfg.HistoryDB,
+					authMiddleware(cfg, s.sso, s.secMon,
+						standbyMiddleware(leaderEl,
+							workspaceMiddleware(cfg,
+								clientMiddleware(cfg.DefaultClientID, mux))))))))))))
 
 	srv := &http.Server{Addr: cfg.ListenAddr, Handler: handler}
 
@@ -2512,18 +3045,16 @@ func startHTTPServer(s *Server) *http.Server {
 	}
 
 	// Start with TLS if configured.
-	if cfg.TLSEnabled {
-		srv.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		}
+	if s.tlsServer != nil {
+		srv.TLSConfig = s.tlsServer.TLSConfig()
 		go func() {
-			ln.Close() // release pre-bound listener; TLS needs its own
-			if err := srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != http.ErrServerClosed {
+			// Certificates come from TLSConfig, so SIGHUP can rotate them.
+			if err := srv.ServeTLS(ln, "", ""); err != http.ErrServerClosed {
 				log.Error("https server error", "error", err)
 				os.Exit(1)
 			}
 		}()
-		log.Info("https server listening", "addr", cfg.ListenAddr)
+		log.Info("https server listening", "addr", cfg.ListenAddr, "mutualTLS", s.tlsServer.Mutual())
 	} else {
 		go func() {
 			if err := srv.Serve(ln); err != http.ErrServerClosed {
@@ -2533,6 +3064,23 @@ func startHTTPServer(s *Server) *http.Server {
 		}()
 		log.Info("http server listening", "addr", cfg.ListenAddr)
 	}
+
+	// Local clients such as the CLI also reach the API over a unix socket,
+	// which needs no TLS and works whatever listenAddr is bound to.
+	// Shutdown closes it along with the TCP listener.
+	if sock := cfg.HTTP.SocketPath(cfg.BaseDir); sock != "" {
+		uln, err := httpapi.ListenUnix(sock)
+		if err != nil {
+			log.Warn("http unix socket disabled", "path", sock, "error", err)
+		} else {
+			go func() {
+				if err := srv.Serve(uln); err != nil && err != http.ErrServerClosed {
+					log.Warn("http unix socket error", "error", err)
+				}
+			}()
+			log.Info("http unix socket listening", "path", sock)
+		}
+	}
 	return srv
 }
 
@@ -2585,19 +3133,61 @@ h1{font-size:1.2rem;margin-bottom:1rem;text-align:center;color:#a78bfa}
 <div class="card"><h1>Tetora Dashboard</h1>
 <div class="err">Too many attempts, try again later</div></div></body></html>`
 
-// --- Security Monitor ---
+// dashboardLoginTOTPHTML asks for the second factor after the password;
+// <!--err--> is replaced with an error message or nothing.
+const dashboardLoginTOTPHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1">
+<title>Tetora - Login</title>
+<style>
+*{margin:0;padding:0;box-sizing:border-box}
+body{font-family:system-ui,sans-serif;background:#0a0a0f;color:#e0e0e0;display:flex;align-items:center;justify-content:center;min-height:100vh}
+.card{background:#14141e;border:1px solid #2a2a3a;border-radius:12px;padding:2rem;width:320px}
+h1{font-size:1.2rem;margin-bottom:1rem;text-align:center;color:#a78bfa}
+p{font-size:.85rem;color:#9ca3af;margin-bottom:1rem;text-align:center}
+.err{color:#f87171;font-size:.85rem;margin-bottom:1rem;text-align:center}
+input[type=text]{width:100%;padding:.6rem .8rem;background:#1a1a2e;border:1px solid #333;border-radius:6px;color:#e0e0e0;font-size:1rem;letter-spacing:.15em;text-align:center;margin-bottom:1rem}
+input:focus{outline:none;border-color:#a78bfa}
+button{width:100%;padding:.6rem;background:#a78bfa;color:#0a0a0f;border:none;border-radius:6px;font-size:.9rem;font-weight:600;cursor:pointer}
+button:hover{background:#8b5cf6}
+</style></head><body>
+<div class="card"><h1>Tetora Dashboard</h1>
+<p>Enter the code from your authenticator app, or one of your recovery codes.</p>
+<!--err-->
+<form method="POST"><input type="text" name="code" placeholder="123456" autocomplete="one-time-code" autofocus required>
+<button type="submit">Verify</button></form></div></body></html>`
 
-// securityMonitor tracks security-related events and sends alerts
-// when suspicious patterns are detected (e.g. auth failure bursts).
-type securityMonitor struct {
-	mu            sync.Mutex
-	events        map[string][]time.Time // ip -> event timestamps
-	lastAlert     map[string]time.Time   // ip -> last alert time (dedup)
-	threshold     int                    // number of failures to trigger alert
-	windowMin     int                    // window in minutes
-	alertCooldown time.Duration          // min time between alerts for same IP
-	notifyFn      func(string)           // notification callback
-}
+// dashboardSSOButtonHTML is added to the login page when SSO is enabled.
+const dashboardSSOButtonHTML = `<a href="/dashboard/oidc/login" style="display:block;margin-top:1rem;padding:.6rem;border:1px solid #a78bfa;border-radius:6px;color:#a78bfa;text-align:center;text-decoration:none;font-size:.9rem;font-weight:600">Sign in with SSO</a>`
+
+// dashboardSSOErrorHTML reports a failed SSO login; %s is the escaped reason.
+const dashboardSSOErrorHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1">
+<title>Tetora - Login</title>
+<style>
+*{margin:0;padding:0;box-sizing:border-box}
+body{font-family:system-ui,sans-serif;background:#0a0a0f;color:#e0e0e0;display:flex;align-items:center;justify-content:center;min-height:100vh}
+.card{background:#14141e;border:1px solid #2a2a3a;border-radius:12px;padding:2rem;width:320px}
+h1{font-size:1.2rem;margin-bottom:1rem;text-align:center;color:#a78bfa}
+.err{color:#f87171;font-size:.85rem;margin-bottom:1rem;text-align:center}
+a{display:block;text-align:center;color:#a78bfa;font-size:.9rem}
+</style></head><body>
+<div class="card"><h1>Tetora Dashboard</h1>
+<div class="err">%s</div>
+<a href="/dashboard/oidc/login">Try again</a></div></body></html>`
+
+// --- Security Monitor ---
+
+// securityMonitor tracks security-related events and sends alerts
+// when suspicious patterns are detected (e.g. auth failure bursts).
+type securityMonitor struct {
+	mu            sync.Mutex
+	events        map[string][]time.Time // ip -> event timestamps
+	lastAlert     map[string]time.Time   // ip -> last alert time (dedup)
+	threshold     int                    // number of failures to trigger alert
+	windowMin     int                    // window in minutes
+	alertCooldown time.Duration          // min time between alerts for same IP
+	notifyFn      func(string)           // notification callback
+}
 
 func newSecurityMonitor(cfg *Config, notifyFn func(string)) *securityMonitor {
 	if !cfg.SecurityAlert.Enabled || notifyFn == nil {
@@ -3018,16 +3608,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		}
 		ctx := r.Context()
 		result := handleIncomingWebhook(ctx, cfg, name, r, state, sem, childSem)
-		w.Header().Set("Content-Type", "application/json")
-		switch result.Status {
-		case "error":
-			w.WriteHeader(http.StatusBadRequest)
-		case "disabled":
-			w.WriteHeader(http.StatusNotFound)
-		default:
-			w.WriteHeader(http.StatusOK)
-		}
-		json.NewEncoder(w).Encode(result)
+		writeIncomingWebhookResult(w, cfg.IncomingWebhooks[name], result)
 	})
 
 	mux.HandleFunc("/webhooks/incoming", func(w http.ResponseWriter, r *http.Request) {
@@ -3044,9 +3625,15 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			Filter    string `json:"filter,omitempty"`
 			Workflow  string `json:"workflow,omitempty"`
 			HasSecret bool   `json:"hasSecret"`
+			ResponseMode string `json:"responseMode"`
+			Transform map[string]string `json:"transform,omitempty"`
 		}
 		var list []webhookInfo
 		for name, wh := range cfg.IncomingWebhooks {
+			mode := "async"
+			if wh.IsSync() {
+				mode = "sync"
+			}
 			list = append(list, webhookInfo{
 				Name:      name,
 				Agent:      wh.Agent,
@@ -3055,6 +3642,8 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				Filter:    wh.Filter,
 				Workflow:  wh.Workflow,
 				HasSecret: wh.Secret != "",
+				ResponseMode: mode,
+				Transform: wh.Transform,
 			})
 		}
 		if list == nil {
@@ -3063,6 +3652,63 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		json.NewEncoder(w).Encode(list)
 	})
 
+	// POST /webhooks/incoming/{name}/preview — run the transform, filter and
+	// template on a sample payload (the request body) without dispatching.
+	mux.HandleFunc("/webhooks/incoming/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/webhooks/incoming/"), "/preview")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			http.Error(w, `{"error":"use POST /webhooks/incoming/{name}/preview"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		whCfg, ok := cfg.IncomingWebhooks[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf(`{"error":"webhook %q not found"}`, name), http.StatusNotFound)
+			return
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"parse payload: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(previewIncomingWebhook(name, whCfg, payload))
+	})
+
+	// GET /traces/{id} — timeline of everything recorded under one trace ID.
+	mux.HandleFunc("/traces/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if cfg.HistoryDB == "" {
+			http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
+			return
+		}
+		traceID := strings.TrimPrefix(r.URL.Path, "/traces/")
+		if traceID == "" || strings.Contains(traceID, "/") {
+			http.Error(w, `{"error":"invalid path, use /traces/{id}"}`, http.StatusBadRequest)
+			return
+		}
+		events, err := trace.Events(cfg.HistoryDB, traceID)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(events) == 0 {
+			jsonError(w, "trace not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"traceId": traceID,
+			"events":  events,
+		})
+	})
+
 	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
 		if cfg.HistoryDB == "" {
 			http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
@@ -3101,6 +3747,25 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		})
 	})
 
+	// GET /audit/export — delivery status of the external audit sinks.
+	mux.HandleFunc("/audit/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		globalAuditExportMu.RLock()
+		sinks := globalAuditExport.Status()
+		globalAuditExportMu.RUnlock()
+		if sinks == nil {
+			sinks = []siem.SinkStatus{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled": s.Cfg().Security.AuditExport.Enabled,
+			"sinks":   sinks,
+		})
+	})
+
 	// --- Retention & Data ---
 	mux.HandleFunc("/retention", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -3121,6 +3786,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 					"reflections": retentionDays(cfg.Retention.Reflections, 60),
 					"sla":         retentionDays(cfg.Retention.SLA, 90),
 					"trustEvents": retentionDays(cfg.Retention.TrustEvents, 90),
+					"traces":      retentionDays(cfg.Retention.Traces, 14),
 					"handoffs":    retentionDays(cfg.Retention.Handoffs, 60),
 					"queue":       retentionDays(cfg.Retention.Queue, 7),
 					"versions":    retentionDays(cfg.Retention.Versions, 180),
@@ -3140,7 +3806,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		audit.Log(cfg.HistoryDB, "retention.cleanup", "http", "", clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "retention.cleanup", "http", "", clientIP(r))
 		results := runRetention(cfg)
 		json.NewEncoder(w).Encode(map[string]any{"results": results})
 	})
@@ -3150,8 +3816,29 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
 			return
 		}
+		if user := r.URL.Query().Get("user"); user != "" {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "data.export", "http", "user="+user, clientIP(r))
+			res, err := exportUserData(cfg, user)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// The archive holds personal data; it is handed over, not kept.
+			defer os.Remove(res.Filename)
+			f, err := os.Open(res.Filename)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer f.Close()
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(res.Filename)))
+			w.Header().Set("Content-Length", strconv.FormatInt(res.SizeBytes, 10))
+			io.Copy(w, f)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-		audit.Log(cfg.HistoryDB, "data.export", "http", "", clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "data.export", "http", "", clientIP(r))
 		data, err := exportData(cfg)
 		if err != nil {
 			jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -3176,7 +3863,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		audit.Log(cfg.HistoryDB, "data.purge", "http", "before="+before, clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "data.purge", "http", "before="+before, clientIP(r))
 		results, err := purgeDataBefore(cfg, before)
 		if err != nil {
 			jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -3185,6 +3872,33 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		json.NewEncoder(w).Encode(map[string]any{"results": results})
 	})
 
+	mux.HandleFunc("/data/erase", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"DELETE only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		user := r.URL.Query().Get("user")
+		if user == "" {
+			http.Error(w, `{"error":"user parameter required"}`, http.StatusBadRequest)
+			return
+		}
+		dryRun := r.URL.Query().Get("dryRun") == "true"
+		if !dryRun && r.Header.Get("X-Confirm-Erase") != "true" {
+			http.Error(w, `{"error":"X-Confirm-Erase: true header required"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !dryRun {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "data.erase", "http", "user="+user, clientIP(r))
+		}
+		m, path, err := eraseUserData(cfg, user, dryRun)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"manifest": m, "manifestFile": path})
+	})
+
 	// --- Backup (archived) ---
 	mux.HandleFunc("/backup", func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"backup not available"}`, http.StatusNotImplemented)
@@ -3197,9 +3911,15 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			return
 		}
 
+		expected := dashboardSecret(cfg)
+
 		if r.Method == http.MethodGet {
+			if s.sso != nil && expected == "" && len(cfg.DashboardAuth.Accounts) == 0 {
+				http.Redirect(w, r, "/dashboard/oidc/login", http.StatusFound)
+				return
+			}
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.Write([]byte(dashboardLoginHTML))
+			w.Write(s.loginPage(dashboardLoginHTML))
 			return
 		}
 
@@ -3208,7 +3928,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 
 			// Rate limit check.
 			if s.limiter.isLocked(ip) {
-				audit.Log(cfg.HistoryDB, "dashboard.login.ratelimit", "http", "", ip)
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login.ratelimit", "http", "", ip)
 				if s.secMon != nil {
 					s.secMon.recordEvent(ip, "login.ratelimit")
 				}
@@ -3219,44 +3939,57 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			}
 
 			r.ParseForm()
+			if _, ok := r.PostForm["code"]; ok {
+				s.loginSecondFactor(w, r, ip)
+				return
+			}
 			password := r.FormValue("password")
 
-			expected := cfg.DashboardAuth.Password
-			if expected == "" {
-				expected = cfg.DashboardAuth.Token
+			// The main password signs in as admin; an account password as
+			// that account's role.
+			var login string
+			matched := expected != "" && password == expected
+			if !matched {
+				for _, acct := range cfg.DashboardAuth.Accounts {
+					if acct.Password != "" && password == acct.Password {
+						login, matched = acct.Name, true
+						break
+					}
+				}
 			}
-
-			if password != expected {
+			if !matched {
 				s.limiter.recordFailure(ip)
-				audit.Log(cfg.HistoryDB, "dashboard.login.fail", "http", "", ip)
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login.fail", "http", "", ip)
 				if s.secMon != nil {
 					s.secMon.recordEvent(ip, "login.fail")
 				}
 				w.Header().Set("Content-Type", "text/html; charset=utf-8")
 				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(dashboardLoginFailHTML))
+				w.Write(s.loginPage(dashboardLoginFailHTML))
+				return
+			}
+
+			// With a second factor enrolled, the password only gets the
+			// browser as far as the code prompt.
+			if globalTOTP.Enabled(login) {
+				http.SetCookie(w, &http.Cookie{
+					Name:     totpPendingCookie,
+					Value:    dashboardPendingCookie(cfg, login),
+					Path:     "/dashboard/login",
+					MaxAge:   int(totpPendingTTL.Seconds()),
+					HttpOnly: true,
+					Secure:   cfg.TLSEnabled,
+					SameSite: http.SameSiteStrictMode,
+				})
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte(strings.Replace(dashboardLoginTOTPHTML, "<!--err-->", "", 1)))
 				return
 			}
 
 			// Success — clear rate limit.
 			s.limiter.recordSuccess(ip)
-
-			// Set session cookie.
-			secret := expected
-			cookieVal := dashboardAuthCookie(secret)
-			cookie := &http.Cookie{
-				Name:     "tetora_session",
-				Value:    cookieVal,
-				Path:     "/dashboard",
-				MaxAge:   86400, // 24h
-				HttpOnly: true,
-				SameSite: http.SameSiteStrictMode,
-			}
-			if cfg.TLSEnabled {
-				cookie.Secure = true
-			}
-			http.SetCookie(w, cookie)
-			audit.Log(cfg.HistoryDB, "dashboard.login", "http", "", ip)
+			setDashboardSession(w, cfg, dashboardSessionCookie(cfg, login))
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login", "http", dashboardLoginDetail(cfg, login), ip)
 			http.Redirect(w, r, "/dashboard", http.StatusFound)
 			return
 		}
@@ -3264,6 +3997,63 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
 	})
 
+	// --- Config schema ---
+	mux.HandleFunc("/config/schema", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		json.NewEncoder(w).Encode(tetoraConfig.Schema())
+	})
+
+	// GET lints the config files on disk; POST lints the document in the
+	// body (JSON, or ?format=yaml|toml), so the dashboard editor can check a
+	// config before saving it.
+	mux.HandleFunc("/config/validate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		issues := []tetoraConfig.Issue{}
+		switch r.Method {
+		case http.MethodGet:
+			configPath := cfg.ConfigFile()
+			layers, err := tetoraConfig.Layers(configPath, cfg.Profile)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, p := range append([]string{configPath}, layers...) {
+				found, err := tetoraConfig.LintFile(p)
+				if err != nil {
+					jsonError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				name, _ := filepath.Rel(filepath.Dir(configPath), p)
+				for i := range found {
+					found[i].File = name
+				}
+				issues = append(issues, found...)
+			}
+		case http.MethodPost:
+			data, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			format := cfgfile.JSON
+			if q := r.URL.Query().Get("format"); q != "" {
+				if format, err = cfgfile.ParseFormat(q); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			issues = append(issues, tetoraConfig.LintDoc(data, format)...)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"valid": !tetoraConfig.HasErrors(issues), "issues": issues})
+	})
+
 	// --- Config & Workflow Versioning ---
 	mux.HandleFunc("/config/versions", func(w http.ResponseWriter, r *http.Request) {
 		if cfg.HistoryDB == "" {
@@ -3294,7 +4084,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				Reason string `json:"reason"`
 			}
 			json.NewDecoder(r.Body).Decode(&req)
-			configPath := filepath.Join(cfg.BaseDir, "config.json")
+			configPath := cfg.ConfigFile()
 			if err := version.SnapshotConfig(cfg.HistoryDB, configPath, "api", req.Reason); err != nil {
 				jsonError(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -3328,7 +4118,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		// POST /config/versions/{id}/restore
 		if r.Method == http.MethodPost && strings.HasSuffix(path, "/restore") {
 			versionID := strings.TrimSuffix(path, "/restore")
-			configPath := filepath.Join(cfg.BaseDir, "config.json")
+			configPath := cfg.ConfigFile()
 			if _, err := version.RestoreConfig(cfg.HistoryDB, configPath, versionID); err != nil {
 				jsonError(w, err.Error(), http.StatusBadRequest)
 				return
@@ -3412,6 +4202,26 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		json.NewEncoder(w).Encode(s.pluginHost.List())
 	})
 
+	// Re-read the config file and apply plugin/MCP changes, like SIGHUP.
+	mux.HandleFunc("/api/plugins/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		res, err := s.reloadConfigFile()
+		if err != nil {
+			jsonError(w, "config reload failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "plugins.reload", "http",
+			fmt.Sprintf("plugins started=%d stopped=%d restarted=%d failed=%d; mcp started=%d stopped=%d restarted=%d failed=%d",
+				len(res.Plugins.Started), len(res.Plugins.Stopped), len(res.Plugins.Restarted), len(res.Plugins.Failed),
+				len(res.MCP.Started), len(res.MCP.Stopped), len(res.MCP.Restarted), len(res.MCP.Failed)),
+			clientIP(r))
+		json.NewEncoder(w).Encode(res)
+	})
+
 	mux.HandleFunc("/api/plugins/", func(w http.ResponseWriter, r *http.Request) {
 		// Parse /api/plugins/{name}/{action}
 		path := strings.TrimPrefix(r.URL.Path, "/api/plugins/")
@@ -3461,10 +4271,34 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
 				return
 			}
-			json.NewEncoder(w).Encode(s.pluginHost.Health(name))
+			health := s.pluginHost.Health(name)
+			// The sandbox plugin also reports which container runtime it drives.
+			if sm := s.state.sandboxMgr; sm != nil && sm.PluginName() == name && health["healthy"] == true {
+				if rt, err := sm.Runtime(); err == nil {
+					health["sandbox"] = rt
+				}
+			}
+			json.NewEncoder(w).Encode(health)
+
+		case "stats":
+			if r.Method != http.MethodGet {
+				http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			sm := s.state.sandboxMgr
+			if sm == nil || sm.PluginName() != name {
+				jsonError(w, "stats are only available for the sandbox plugin", http.StatusBadRequest)
+				return
+			}
+			stats, err := sm.Stats(r.URL.Query().Get("sandboxId"))
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"containers": stats})
 
 		default:
-			http.Error(w, `{"error":"unknown action, use start, stop, or health"}`, http.StatusBadRequest)
+			http.Error(w, `{"error":"unknown action, use start, stop, health, or stats"}`, http.StatusBadRequest)
 		}
 	})
 
@@ -3505,7 +4339,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		case http.MethodPost:
 			switch action {
 			case "approve":
-				audit.Log(cfg.HistoryDB, "skill.approve", "http",
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.approve", "http",
 					fmt.Sprintf("name=%s", name), clientIP(r))
 				if err := approveSkill(cfg, name); err != nil {
 					jsonError(w, err.Error(), http.StatusBadRequest)
@@ -3517,7 +4351,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				json.NewEncoder(w).Encode(map[string]string{"status": "approved", "name": name})
 
 			case "reject":
-				audit.Log(cfg.HistoryDB, "skill.reject", "http",
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.reject", "http",
 					fmt.Sprintf("name=%s", name), clientIP(r))
 				if err := rejectSkill(cfg, name); err != nil {
 					jsonError(w, err.Error(), http.StatusBadRequest)
@@ -3537,7 +4371,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				http.Error(w, `{"error":"DELETE /api/skills/store/<name>"}`, http.StatusBadRequest)
 				return
 			}
-			audit.Log(cfg.HistoryDB, "skill.delete", "http",
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.delete", "http",
 				fmt.Sprintf("name=%s", name), clientIP(r))
 			if err := deleteFileSkill(cfg, name); err != nil {
 				jsonError(w, err.Error(), http.StatusNotFound)
@@ -3550,6 +4384,84 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		}
 	})
 
+	// --- Skill Marketplace API ---
+	mux.HandleFunc("/api/skills/market", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"results":   skill.Search(r.Context(), cfg.SkillStore.Indexes, r.URL.Query().Get("q")),
+			"installed": skill.ListInstalled(toSkillAppConfig(cfg)),
+		})
+	})
+
+	mux.HandleFunc("/api/skills/market/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Source  string `json:"source"` // skill name, archive URL or repo.git#ref
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			SHA256  string `json:"sha256"`
+			Approve bool   `json:"approve"`
+			Pinned  bool   `json:"pinned"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		scfg := toSkillAppConfig(cfg)
+
+		switch action := strings.TrimPrefix(r.URL.Path, "/api/skills/market/"); action {
+		case "install":
+			if req.Source == "" {
+				jsonError(w, "source required", http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.install", "http",
+				fmt.Sprintf("source=%s version=%s", req.Source, req.Version), clientIP(r))
+			rel, err := skill.Resolve(r.Context(), cfg.SkillStore.Indexes, req.Source, req.Version, req.SHA256)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rec, err := skill.InstallPackage(r.Context(), scfg, rel, skill.InstallOptions{
+				Source:  req.Source,
+				Pinned:  req.Version != "",
+				Approve: req.Approve,
+			})
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			json.NewEncoder(w).Encode(rec)
+
+		case "upgrade":
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.upgrade", "http",
+				fmt.Sprintf("name=%s version=%s", req.Name, req.Version), clientIP(r))
+			json.NewEncoder(w).Encode(map[string]any{
+				"results": skill.Upgrade(r.Context(), scfg, req.Name, req.Version),
+			})
+
+		case "pin":
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "skill.pin", "http",
+				fmt.Sprintf("name=%s pinned=%v", req.Name, req.Pinned), clientIP(r))
+			if err := skill.SetPinned(scfg, req.Name, req.Pinned); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"name": req.Name, "pinned": req.Pinned})
+
+		default:
+			http.Error(w, `{"error":"unknown action, use install, upgrade or pin"}`, http.StatusNotFound)
+		}
+	})
+
 	mux.HandleFunc("/api/skills/usage", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
@@ -3751,7 +4663,14 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				"homeAssistant": false,
 				"gmail":         false,
 				"calendar":      cfg.Calendar.Enabled,
-				"twitter":       false,
+				"twitter":       cfg.Twitter.Enabled,
+				"social":        len(cfg.Social.Accounts) > 0,
+				"readLater":     cfg.ReadLater.Enabled(),
+				"mailIn":        cfg.MailIn.Enabled,
+				"mail":          cfg.Mail.Enabled,
+				"sync":          cfg.Sync.Enabled,
+				"mirror":        cfg.Mirror.Enabled,
+				"gitops":        cfg.GitOps.Enabled,
 				"browserRelay":  cfg.BrowserRelay.Enabled,
 				"notebookLM":    cfg.NotebookLM.Enabled,
 			},
@@ -3775,6 +4694,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				"autoDispatch":    cfg.TaskBoard.AutoDispatch.Enabled,
 				"maxRetries":      cfg.TaskBoard.MaxRetries,
 				"defaultWorkflow": cfg.TaskBoard.DefaultWorkflow,
+				"sync":            cfg.TaskBoard.Sync.Provider,
 			},
 			"heartbeat": map[string]any{
 				"enabled":          cfg.Heartbeat.Enabled,
@@ -3835,7 +4755,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		}
 
 		configPath := findConfigPath()
-		data, err := os.ReadFile(configPath)
+		data, err := cfgfile.ReadJSON(configPath)
 		if err != nil {
 			jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -3871,7 +4791,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+		if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 			jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -3879,7 +4799,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		// Reload config in-memory via SIGHUP.
 		signalSelfReload()
 
-		audit.Log(cfg.HistoryDB, "config.toggle", "dashboard",
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "config.toggle", "dashboard",
 			fmt.Sprintf("%s=%v", req.Key, req.Value), "")
 
 		respVal, err := json.Marshal(req.Value)
@@ -4020,7 +4940,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				return
 			}
 			signalSelfReload()
-			audit.Log(cfg.HistoryDB, "config.provider.save", "dashboard",
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "config.provider.save", "dashboard",
 				fmt.Sprintf("provider=%s type=%s", req.Name, req.Config.Type), "")
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok", "name": req.Name})
 
@@ -4036,7 +4956,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 				return
 			}
 			signalSelfReload()
-			audit.Log(cfg.HistoryDB, "config.provider.delete", "dashboard", fmt.Sprintf("provider=%s", name), "")
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "config.provider.delete", "dashboard", fmt.Sprintf("provider=%s", name), "")
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok", "name": name})
 
 		default:
@@ -4172,7 +5092,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 			cfg2.InferenceMode = modeStr
 
 			signalSelfReload()
-			audit.Log(cfg.HistoryDB, "config.inference_mode", "dashboard",
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "config.inference_mode", "dashboard",
 				fmt.Sprintf("mode=%s switched=%d pinned=%d", req.Mode, switched, pinned), "")
 
 			json.NewEncoder(w).Encode(map[string]any{
@@ -4205,7 +5125,7 @@ func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
 		active := len(state.running)
 		state.mu.Unlock()
 
-		audit.Log(cfg.HistoryDB, "admin.drain", "http", fmt.Sprintf("active=%d", active), clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "admin.drain", "http", fmt.Sprintf("active=%d", active), clientIP(r))
 		log.Info("drain requested via API", "activeAgents", active)
 
 		// Signal the main loop to begin draining (if channel is wired up).
@@ -4592,41 +5512,2773 @@ func (s *Server) registerPlanReviewRoutes(mux *http.ServeMux) {
 			})
 		}
 
-		audit.Log(cfg.HistoryDB, "plan_review."+action, "http",
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "plan_review."+action, "http",
 			fmt.Sprintf("id=%s reviewer=%s", reviewID, body.Reviewer), clientIP(r))
 
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok", "action": action})
 	})
 }
 
-// --- Discord Plan Review Formatting ---
+// --- Handover Routes ---
 
-// buildPlanReviewEmbed creates a rich Discord embed for plan review.
-func buildPlanReviewEmbed(review *PlanReview) discord.Embed {
-	color := 0x3498db // Blue for pending
+// globalHandoverDesk is the package-level handover desk, set when handover.enabled.
+var globalHandoverDesk *handover.Desk
 
-	// Truncate plan text for Discord embed (max 4096 chars for description).
-	planPreview := review.PlanText
-	if len(planPreview) > 3500 {
-		planPreview = planPreview[:3500] + "\n\n... (truncated, see dashboard for full plan)"
-	}
+func (s *Server) registerHandoverRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
 
-	embed := discord.Embed{
-		Title:       "Plan Review Required",
-		Description: planPreview,
-		Color:       color,
-		Fields: []discord.EmbedField{
-			{Name: "Session", Value: truncate(review.SessionID, 36), Inline: true},
-		},
-		Timestamp: review.CreatedAt,
-	}
+	// GET /api/handovers?status=open — list handovers.
+	mux.HandleFunc("/api/handovers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalHandoverDesk == nil {
+			jsonError(w, "handover not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		list, err := globalHandoverDesk.List(r.URL.Query().Get("status"))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []handover.Handover{}
+		}
+		json.NewEncoder(w).Encode(list)
+	})
 
-	if review.Agent != "" {
-		embed.Fields = append(embed.Fields, discord.EmbedField{
-			Name: "Agent", Value: review.Agent, Inline: true,
-		})
-	}
-	if review.WorkerName != "" {
+	// GET  /api/handovers/{id}         — handover with transcript.
+	// POST /api/handovers/{id}/reply   — send an owner reply {"text": "..."}.
+	// POST /api/handovers/{id}/release — hand the conversation back to the agents.
+	mux.HandleFunc("/api/handovers/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalHandoverDesk == nil {
+			jsonError(w, "handover not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/handovers/"), "/")
+		id := parts[0]
+		if id == "" || len(parts) > 2 {
+			http.Error(w, `{"error":"invalid path, use /api/handovers/{id}[/reply|/release]"}`, http.StatusBadRequest)
+			return
+		}
+
+		if len(parts) == 1 {
+			if r.Method != http.MethodGet {
+				http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			h, err := globalHandoverDesk.Get(id)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			msgs, _ := globalHandoverDesk.Transcript(id)
+			json.NewEncoder(w).Encode(map[string]any{"handover": h, "messages": msgs})
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		switch parts[1] {
+		case "reply":
+			var body struct {
+				Text string `json:"text"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+				jsonError(w, "text is required", http.StatusBadRequest)
+				return
+			}
+			delivered, err := globalHandoverDesk.Reply(id, "owner", body.Text)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "handover.reply", "http", "id="+id, clientIP(r))
+			json.NewEncoder(w).Encode(map[string]any{"status": "ok", "delivered": delivered})
+		case "release":
+			h, err := globalHandoverDesk.Release(id, "http")
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "handover.release", "http", "id="+id, clientIP(r))
+			json.NewEncoder(w).Encode(h)
+		default:
+			http.Error(w, `{"error":"action must be reply or release"}`, http.StatusBadRequest)
+		}
+	})
+}
+
+// dashboardLoginDetail is the audit detail of a password login.
+func dashboardLoginDetail(cfg *Config, login string) string {
+	for _, acct := range cfg.DashboardAuth.Accounts {
+		if acct.Name == login && login != "" {
+			return fmt.Sprintf("account=%s role=%s", acct.Name, acct.Role)
+		}
+	}
+	return ""
+}
+
+// loginSecondFactor handles the code step of a password login with
+// two-factor authentication. Wrong codes count against the same lockout
+// as wrong passwords.
+func (s *Server) loginSecondFactor(w http.ResponseWriter, r *http.Request, ip string) {
+	cfg := s.cfg
+	c, err := r.Cookie(totpPendingCookie)
+	if err != nil {
+		http.Redirect(w, r, "/dashboard/login", http.StatusFound)
+		return
+	}
+	login, ok := dashboardPendingLogin(cfg, c.Value)
+	if !ok {
+		http.SetCookie(w, &http.Cookie{Name: totpPendingCookie, Path: "/dashboard/login", MaxAge: -1})
+		http.Redirect(w, r, "/dashboard/login", http.StatusFound)
+		return
+	}
+
+	recovery, err := globalTOTP.Verify(login, r.FormValue("code"))
+	if err != nil {
+		s.limiter.recordFailure(ip)
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login.totp.fail", "http", dashboardLoginDetail(cfg, login), ip)
+		if s.secMon != nil {
+			s.secMon.recordEvent(ip, "login.fail")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(strings.Replace(dashboardLoginTOTPHTML, "<!--err-->", `<div class="err">Invalid code</div>`, 1)))
+		return
+	}
+
+	s.limiter.recordSuccess(ip)
+	http.SetCookie(w, &http.Cookie{Name: totpPendingCookie, Path: "/dashboard/login", MaxAge: -1})
+	setDashboardSession(w, cfg, dashboardSessionCookie(cfg, login))
+	detail := strings.TrimSpace(dashboardLoginDetail(cfg, login) + " 2fa=totp")
+	if recovery {
+		detail = strings.TrimSpace(dashboardLoginDetail(cfg, login) + " 2fa=recovery-code")
+	}
+	audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login", "http", detail, ip)
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+// --- Dashboard SSO Routes ---
+
+// loginPage returns a login page, with an SSO button when SSO is enabled.
+func (s *Server) loginPage(page string) []byte {
+	if s.sso == nil {
+		return []byte(page)
+	}
+	return []byte(strings.Replace(page, "</form>", "</form>"+dashboardSSOButtonHTML, 1))
+}
+
+// ssoOrigin is the scheme and host the browser reached the dashboard on.
+func ssoOrigin(cfg *Config, r *http.Request) string {
+	if u, err := url.Parse(cfg.DashboardAuth.OIDC.RedirectURL); err == nil && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	scheme := "http"
+	if r.TLS != nil || cfg.TLSEnabled {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); (p == "http" || p == "https") && httpapi.FromTrustedProxy(r) {
+		scheme = p
+	}
+	return scheme + "://" + r.Host + cfg.HTTP.Prefix()
+}
+
+func (s *Server) registerSSORoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	ssoError := func(w http.ResponseWriter, msg string, code int) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, dashboardSSOErrorHTML, html.EscapeString(msg))
+	}
+
+	// GET /dashboard/oidc/login[?next=/path] — start a login at the provider.
+	mux.HandleFunc("/dashboard/oidc/login", func(w http.ResponseWriter, r *http.Request) {
+		if s.sso == nil {
+			http.Redirect(w, r, "/dashboard/login", http.StatusFound)
+			return
+		}
+		next := r.URL.Query().Get("next")
+		if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+			next = "/dashboard"
+		}
+		redirectURL := cfg.DashboardAuth.OIDC.RedirectURL
+		if redirectURL == "" {
+			redirectURL = ssoOrigin(cfg, r) + "/dashboard/oidc/callback"
+		}
+		authURL, state, err := s.sso.AuthURL(r.Context(), redirectURL, next)
+		if err != nil {
+			log.Warn("dashboard sso: start login failed", "error", err)
+			ssoError(w, "Identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     ssoStateCookie,
+			Value:    state,
+			Path:     "/dashboard/oidc/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   cfg.TLSEnabled,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	})
+
+	// GET /dashboard/oidc/callback — finish a login and open a session.
+	mux.HandleFunc("/dashboard/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+		if s.sso == nil {
+			http.Redirect(w, r, "/dashboard/login", http.StatusFound)
+			return
+		}
+		ip := clientIP(r)
+		q := r.URL.Query()
+		http.SetCookie(w, &http.Cookie{Name: ssoStateCookie, Path: "/dashboard/oidc/", MaxAge: -1})
+
+		fail := func(reason, msg string, code int) {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login.sso.fail", "http", reason, ip)
+			if s.secMon != nil {
+				s.secMon.recordEvent(ip, "login.fail")
+			}
+			ssoError(w, msg, code)
+		}
+		if e := q.Get("error"); e != "" {
+			fail("provider: "+e, "Sign-in was cancelled or refused by the identity provider", http.StatusUnauthorized)
+			return
+		}
+		state := q.Get("state")
+		if c, err := r.Cookie(ssoStateCookie); err != nil || state == "" || c.Value != state {
+			fail("state mismatch", "Sign-in expired, please try again", http.StatusBadRequest)
+			return
+		}
+		sess, next, err := s.sso.Exchange(r.Context(), state, q.Get("code"))
+		if errors.Is(err, oidc.ErrForbidden) {
+			fail("forbidden", "Your account is not allowed to use this dashboard", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Warn("dashboard sso: login failed", "error", err)
+			fail(err.Error(), "Sign-in failed", http.StatusUnauthorized)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     ssoCookie,
+			Value:    sess.ID,
+			Path:     "/",
+			MaxAge:   int(time.Until(sess.ExpiresAt).Seconds()),
+			HttpOnly: true,
+			Secure:   cfg.TLSEnabled,
+			SameSite: http.SameSiteLaxMode,
+		})
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.login.sso", "http",
+			fmt.Sprintf("sub=%s email=%s role=%s", sess.Subject, sess.Email, sess.Role), ip)
+		http.Redirect(w, r, next, http.StatusFound)
+	})
+
+	// /dashboard/logout — revoke the session and sign out at the provider.
+	mux.HandleFunc("/dashboard/logout", func(w http.ResponseWriter, r *http.Request) {
+		end := ""
+		if c, err := r.Cookie(ssoCookie); err == nil && s.sso != nil {
+			if sess, ok := s.sso.Session(c.Value); ok {
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.logout", "http", "email="+sess.Email, clientIP(r))
+			}
+			end = s.sso.Logout(r.Context(), c.Value, ssoOrigin(cfg, r)+"/dashboard/login")
+		}
+		http.SetCookie(w, &http.Cookie{Name: ssoCookie, Path: "/", MaxAge: -1})
+		http.SetCookie(w, &http.Cookie{Name: "tetora_session", Path: "/", MaxAge: -1})
+		http.SetCookie(w, &http.Cookie{Name: "tetora_session", Path: "/dashboard", MaxAge: -1})
+		if end == "" {
+			end = "/dashboard/login"
+		}
+		http.Redirect(w, r, end, http.StatusFound)
+	})
+
+	// GET /api/auth/me — who the dashboard is signed in as.
+	mux.HandleFunc("/api/auth/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if sess := ssoSession(s.sso, r); sess != nil {
+			json.NewEncoder(w).Encode(map[string]any{
+				"method": "sso", "subject": sess.Subject, "email": sess.Email, "name": sess.Name,
+				"role": sess.Role, "groups": sess.Groups, "expiresAt": sess.ExpiresAt,
+				"capabilities": dashboardCapabilities(sess.Role),
+			})
+			return
+		}
+		if cookie, err := r.Cookie("tetora_session"); err == nil {
+			if name, role, ok := dashboardCookieRole(cfg, cookie.Value); ok {
+				json.NewEncoder(w).Encode(map[string]any{
+					"method": "password", "account": name, "role": role, "totp": globalTOTP.Enabled(name),
+					"capabilities": dashboardCapabilities(role),
+				})
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"method": "token", "role": oidc.RoleAdmin, "capabilities": dashboardCapabilities(oidc.RoleAdmin),
+		})
+	})
+}
+
+// --- Dashboard Two-Factor Routes ---
+
+func (s *Server) registerTOTPRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// passwordLogin returns the password login r is signed in with. Second
+	// factors belong to password logins; SSO leaves them to the provider.
+	passwordLogin := func(r *http.Request) (string, bool) {
+		if ssoSession(s.sso, r) != nil {
+			return "", false
+		}
+		c, err := r.Cookie("tetora_session")
+		if err != nil {
+			return "", false
+		}
+		name, _, ok := dashboardCookieRole(cfg, c.Value)
+		return name, ok
+	}
+
+	// begin runs the checks every mutating endpoint shares and returns the
+	// login and the submitted code.
+	begin := func(w http.ResponseWriter, r *http.Request) (login, code string, ok bool) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return "", "", false
+		}
+		if globalTOTP == nil {
+			jsonError(w, "two-factor authentication is not enabled (dashboardAuth.totp.enabled)", http.StatusServiceUnavailable)
+			return "", "", false
+		}
+		login, ok = passwordLogin(r)
+		if !ok {
+			jsonError(w, "two-factor authentication applies to dashboard password logins only", http.StatusBadRequest)
+			return "", "", false
+		}
+		if s.limiter.isLocked(clientIP(r)) {
+			jsonError(w, "too many attempts, try again later", http.StatusTooManyRequests)
+			return "", "", false
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return "", "", false
+			}
+		}
+		return login, body.Code, true
+	}
+
+	// badCode counts a wrong code against the login lockout.
+	badCode := func(w http.ResponseWriter, r *http.Request, login string) {
+		ip := clientIP(r)
+		s.limiter.recordFailure(ip)
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.totp.fail", "http", dashboardLoginDetail(cfg, login), ip)
+		if s.secMon != nil {
+			s.secMon.recordEvent(ip, "login.fail")
+		}
+		jsonError(w, totp.ErrInvalidCode.Error(), http.StatusUnauthorized)
+	}
+
+	// GET /api/auth/totp — the current login's enrollment.
+	mux.HandleFunc("/api/auth/totp", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		login, ok := passwordLogin(r)
+		if globalTOTP == nil || !ok {
+			json.NewEncoder(w).Encode(map[string]any{"available": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"available": true, "account": login, "status": globalTOTP.Status(login)})
+	})
+
+	// POST /api/auth/totp/setup — start enrollment; returns the secret, the
+	// otpauth:// URI and the URI as an SVG QR code to scan.
+	mux.HandleFunc("/api/auth/totp/setup", func(w http.ResponseWriter, r *http.Request) {
+		login, _, ok := begin(w, r)
+		if !ok {
+			return
+		}
+		account := login
+		if account == "" {
+			account = "admin"
+		}
+		secret, uri, err := globalTOTP.Setup(login, account)
+		if errors.Is(err, totp.ErrEnrolled) {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := map[string]any{"secret": secret, "uri": uri}
+		if code, err := qr.Encode(uri); err == nil {
+			resp["qr"] = string(code.SVG(200))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// POST /api/auth/totp/verify {"code"} — confirm enrollment with a first
+	// code; returns the recovery codes, shown only this once.
+	mux.HandleFunc("/api/auth/totp/verify", func(w http.ResponseWriter, r *http.Request) {
+		login, code, ok := begin(w, r)
+		if !ok {
+			return
+		}
+		codes, err := globalTOTP.Confirm(login, code)
+		switch {
+		case errors.Is(err, totp.ErrInvalidCode):
+			badCode(w, r, login)
+			return
+		case errors.Is(err, totp.ErrEnrolled), errors.Is(err, totp.ErrNoPending):
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// The session key now includes the secret; reissue this session.
+		setDashboardSession(w, cfg, dashboardSessionCookie(cfg, login))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.totp.enable", "http", dashboardLoginDetail(cfg, login), clientIP(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"recoveryCodes": codes})
+	})
+
+	// POST /api/auth/totp/disable {"code"} — turn two-factor off; takes a
+	// current or recovery code.
+	mux.HandleFunc("/api/auth/totp/disable", func(w http.ResponseWriter, r *http.Request) {
+		login, code, ok := begin(w, r)
+		if !ok {
+			return
+		}
+		if _, err := globalTOTP.Verify(login, code); err != nil {
+			if errors.Is(err, totp.ErrNotEnrolled) {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			badCode(w, r, login)
+			return
+		}
+		if err := globalTOTP.Disable(login); err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setDashboardSession(w, cfg, dashboardSessionCookie(cfg, login))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "dashboard.totp.disable", "http", dashboardLoginDetail(cfg, login), clientIP(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"status": "disabled"})
+	})
+}
+
+// --- FAQ Routes ---
+
+// globalFAQ is the package-level FAQ service, set when faq.enabled.
+var globalFAQ *faq.Service
+
+func (s *Server) registerFAQRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	type faqBody struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+
+	// GET  /api/faq — list entries, most used first.
+	// POST /api/faq — add an entry {"question": "...", "answer": "..."}.
+	mux.HandleFunc("/api/faq", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalFAQ == nil {
+			jsonError(w, "faq not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(globalFAQ.List())
+		case http.MethodPost:
+			var body faqBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			e, err := globalFAQ.Add(body.Question, body.Answer)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "faq.add", "http", fmt.Sprintf("id=%d", e.ID), clientIP(r))
+			json.NewEncoder(w).Encode(e)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET /api/faq/suggestions — clusters of repeated unanswered questions.
+	mux.HandleFunc("/api/faq/suggestions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalFAQ == nil {
+			jsonError(w, "faq not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		sugg := globalFAQ.Suggest()
+		if sugg == nil {
+			sugg = []faq.Suggestion{}
+		}
+		json.NewEncoder(w).Encode(sugg)
+	})
+
+	// POST /api/faq/match — dry run {"text": "..."}; nothing is recorded.
+	mux.HandleFunc("/api/faq/match", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalFAQ == nil {
+			jsonError(w, "faq not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+			jsonError(w, "text is required", http.StatusBadRequest)
+			return
+		}
+		m, ok := globalFAQ.Lookup(r.Context(), body.Text)
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]any{"matched": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"matched": true, "match": m})
+	})
+
+	// PUT    /api/faq/{id} — replace question and answer.
+	// DELETE /api/faq/{id} — remove the entry.
+	mux.HandleFunc("/api/faq/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalFAQ == nil {
+			jsonError(w, "faq not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/faq/"))
+		if err != nil {
+			http.Error(w, `{"error":"invalid path, use /api/faq/{id}"}`, http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			e, ok := globalFAQ.Get(id)
+			if !ok {
+				jsonError(w, "not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(e)
+		case http.MethodPut:
+			var body faqBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			e, err := globalFAQ.Update(id, body.Question, body.Answer)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "faq.update", "http", fmt.Sprintf("id=%d", id), clientIP(r))
+			json.NewEncoder(w).Encode(e)
+		case http.MethodDelete:
+			if err := globalFAQ.Delete(id); err != nil {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "faq.delete", "http", fmt.Sprintf("id=%d", id), clientIP(r))
+			json.NewEncoder(w).Encode(map[string]any{"status": "deleted"})
+		default:
+			http.Error(w, `{"error":"GET, PUT or DELETE only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// --- Intent Shortcut Routes ---
+
+// globalIntents is the package-level intent shortcut service, set when intents.enabled.
+var globalIntents *intent.Service
+
+func (s *Server) registerIntentRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET  /api/intents — list configured and learned shortcuts.
+	// POST /api/intents — add a learned shortcut {"name", "patterns", "tool" or "skill", "args", "agent", "reply"}.
+	mux.HandleFunc("/api/intents", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalIntents == nil {
+			jsonError(w, "intents not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(globalIntents.List())
+		case http.MethodPost:
+			var body intent.Shortcut
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			sc, err := globalIntents.Add(body)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "intent.add", "http", fmt.Sprintf("id=%d name=%s", sc.ID, sc.Name), clientIP(r))
+			json.NewEncoder(w).Encode(sc)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET /api/intents/suggestions — utterances confirmed often enough to learn.
+	mux.HandleFunc("/api/intents/suggestions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalIntents == nil {
+			jsonError(w, "intents not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		sugg := globalIntents.Suggest()
+		if sugg == nil {
+			sugg = []intent.Suggestion{}
+		}
+		json.NewEncoder(w).Encode(sugg)
+	})
+
+	// POST /api/intents/suggestions/accept — learn a suggestion as returned by GET.
+	mux.HandleFunc("/api/intents/suggestions/accept", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalIntents == nil {
+			jsonError(w, "intents not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body intent.Suggestion
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Utterance == "" || body.Tool == "" {
+			jsonError(w, "utterance and tool are required", http.StatusBadRequest)
+			return
+		}
+		sc, err := globalIntents.Accept(body)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "intent.add", "http", fmt.Sprintf("id=%d name=%s", sc.ID, sc.Name), clientIP(r))
+		json.NewEncoder(w).Encode(sc)
+	})
+
+	// POST /api/intents/match — dry run {"text": "..."}; nothing is executed.
+	mux.HandleFunc("/api/intents/match", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalIntents == nil {
+			jsonError(w, "intents not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+			jsonError(w, "text is required", http.StatusBadRequest)
+			return
+		}
+		m, ok := globalIntents.Match(body.Text)
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]any{"matched": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"matched": true, "match": m})
+	})
+
+	// DELETE /api/intents/{id} — remove a learned shortcut.
+	mux.HandleFunc("/api/intents/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"DELETE only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalIntents == nil {
+			jsonError(w, "intents not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/intents/"))
+		if err != nil {
+			http.Error(w, `{"error":"invalid path, use /api/intents/{id}"}`, http.StatusBadRequest)
+			return
+		}
+		if err := globalIntents.Delete(id); err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "intent.delete", "http", fmt.Sprintf("id=%d", id), clientIP(r))
+		json.NewEncoder(w).Encode(map[string]any{"status": "deleted"})
+	})
+}
+
+// --- Translation Routes ---
+
+// globalTranslator is the package-level translation service, set when translate.enabled.
+var globalTranslator *translate.Service
+
+func (s *Server) registerTranslateRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// POST /api/translate — translate {"text": "...", "to": "en", "from": "ja"}; from is optional.
+	mux.HandleFunc("/api/translate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalTranslator == nil {
+			jsonError(w, "translation not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Text string `json:"text"`
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		res, err := globalTranslator.Translate(r.Context(), body.Text, body.From, body.To)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+
+	// GET  /api/translate/glossary — list glossary entries.
+	// POST /api/translate/glossary — add or replace {"term", "lang", "translation", "note"}.
+	mux.HandleFunc("/api/translate/glossary", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalTranslator == nil {
+			jsonError(w, "translation not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(globalTranslator.Glossary())
+		case http.MethodPost:
+			var body translate.Term
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			t, err := globalTranslator.SetTerm(body.Term, body.Lang, body.Translation, body.Note)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "translate.glossary.set", "http", fmt.Sprintf("id=%d", t.ID), clientIP(r))
+			json.NewEncoder(w).Encode(t)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// DELETE /api/translate/glossary/{id} — remove a glossary entry.
+	mux.HandleFunc("/api/translate/glossary/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"DELETE only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalTranslator == nil {
+			jsonError(w, "translation not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/translate/glossary/"))
+		if err != nil {
+			http.Error(w, `{"error":"invalid path, use /api/translate/glossary/{id}"}`, http.StatusBadRequest)
+			return
+		}
+		if err := globalTranslator.DeleteTerm(id); err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "translate.glossary.delete", "http", fmt.Sprintf("id=%d", id), clientIP(r))
+		json.NewEncoder(w).Encode(map[string]any{"status": "deleted"})
+	})
+}
+
+// --- Redaction Routes ---
+
+func (s *Server) registerRedactionRoutes(mux *http.ServeMux) {
+	// GET  /security/redaction — current redaction settings.
+	// POST /security/redaction — dry-run {"text", "agent", "patterns": [{"name", "pattern"}]}
+	// against the configured detectors plus any extra patterns. Works while
+	// redaction is disabled so rules can be tried before turning it on.
+	mux.HandleFunc("/security/redaction", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		globalRedactorMu.RLock()
+		rc := globalRedaction
+		globalRedactorMu.RUnlock()
+		switch r.Method {
+		case http.MethodGet:
+			patterns := []string{}
+			for _, p := range rc.Patterns {
+				patterns = append(patterns, p.Name)
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"enabled":   rc.Enabled,
+				"detectors": rc.Detectors,
+				"patterns":  patterns,
+				"targets":   rc.Targets,
+				"bypass":    rc.Bypass,
+			})
+		case http.MethodPost:
+			var body struct {
+				Text     string                    `json:"text"`
+				Agent    string                    `json:"agent"`
+				Patterns []tetoraConfig.RedactionPattern `json:"patterns"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			for _, p := range body.Patterns {
+				if _, err := redact.Compile(p); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			trial := rc
+			trial.Patterns = append(append([]tetoraConfig.RedactionPattern(nil), rc.Patterns...), body.Patterns...)
+			red, _ := redact.New(trial, s.cfg.Retention.PIIPatterns)
+			matches := red.Find(body.Text)
+			if matches == nil {
+				matches = []redact.Match{}
+			}
+			bypassed := red.Bypassed(body.Agent)
+			json.NewEncoder(w).Encode(map[string]any{
+				"enabled":  rc.Enabled,
+				"bypassed": bypassed,
+				"redacted": red.For(body.Agent, body.Text),
+				"matches":  matches,
+			})
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// registerValidateRoutes serves editor validation for drafts that are not
+// saved yet.
+func (s *Server) registerValidateRoutes(mux *http.ServeMux) {
+	// POST /validate — {"kind": "soul"|"workflow"|"skill", "content", "agent"}
+	// returns diagnostics with line and column positions and a token
+	// estimate. agent names the agent a soul belongs to. Nothing is stored.
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Kind    string `json:"kind"`
+			Content string `json:"content"`
+			Agent   string `json:"agent"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		res, err := validate.Check(body.Kind, body.Content, body.Agent, validateEnv(s.Cfg()))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// validateEnv returns what drafts are checked against: the configured
+// agents, workflow triggers and soul budget, the installed skills and the
+// registered tools.
+func validateEnv(cfg *Config) validate.Env {
+	env := validate.Env{
+		Agents:   make(map[string]bool, len(cfg.Agents)),
+		Skills:   make(map[string]bool),
+		Triggers: cfg.WorkflowTriggers,
+		SoulMax:  cfg.PromptBudget.SoulMaxOrDefault(),
+	}
+	for name := range cfg.Agents {
+		env.Agents[name] = true
+	}
+	for _, sk := range listSkills(cfg) {
+		env.Skills[sk.Name] = true
+	}
+	if cfg.Runtime.ToolRegistry != nil {
+		env.Tools = make(map[string]bool)
+		for _, t := range cfg.Runtime.ToolRegistry.(*ToolRegistry).List() {
+			env.Tools[t.Name] = true
+		}
+	}
+	return env
+}
+
+// --- Channel Simulation Routes ---
+
+func (s *Server) registerChannelSimulateRoutes(mux *http.ServeMux) {
+	// POST /api/channels/simulate — {"channel", "message", "user", "chat", "live", "timeout"}
+	// runs a signed synthetic inbound event through the channel's real webhook
+	// handler. Platform API calls are recorded, not sent; without "live" the
+	// agent is not run either.
+	mux.HandleFunc("/api/channels/simulate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			simulate.Request
+			Timeout string `json:"timeout"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		timeout := 30 * time.Second
+		if body.Live {
+			timeout = 10 * time.Minute
+		}
+		if body.Timeout != "" {
+			d, err := time.ParseDuration(body.Timeout)
+			if err != nil || d <= 0 {
+				jsonError(w, "invalid timeout", http.StatusBadRequest)
+				return
+			}
+			timeout = d
+		}
+		cfg := s.Cfg()
+		rt := newMessagingRuntime(cfg, s.state, s.sem, s.childSem)
+		res, err := simulate.Run(r.Context(), simulate.Platforms{Slack: &cfg.Slack, LINE: &cfg.LINE, WhatsApp: &cfg.WhatsApp}, rt, body.Request, timeout)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.Log(cfg.HistoryDB, "channel.simulate", "http",
+			fmt.Sprintf("channel=%s live=%v status=%d", res.Channel, res.Live, res.Status), clientIP(r))
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// --- User Profile Routes ---
+
+// globalUserProfiles is the package-level user profile service, set when userProfile.enabled.
+var globalUserProfiles *profile.Service
+
+func (s *Server) registerUserProfileRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/users/modulation?channel=discord:123 — whether replies adapt to
+	// the user's mood and time pressure, and the recent adjustments.
+	// PUT /api/users/modulation — turn it on or off {"channel", "enabled"}.
+	mux.HandleFunc("/api/users/modulation", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalUserProfiles == nil {
+			jsonError(w, "user profiles not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			channel := r.URL.Query().Get("channel")
+			if channel == "" {
+				jsonError(w, "channel is required", http.StatusBadRequest)
+				return
+			}
+			userID, err := globalUserProfiles.ResolveUser(channel)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			recent, err := globalUserProfiles.Modulations(userID, 20)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"userId":           userID,
+				"channel":          channel,
+				"enabled":          globalUserProfiles.ModulationOn(userID),
+				"adaptPersonality": cfg.UserProfile.AdaptPersonality,
+				"recent":           recent,
+			})
+		case http.MethodPut:
+			var body struct {
+				Channel string `json:"channel"`
+				Enabled bool   `json:"enabled"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Channel == "" {
+				jsonError(w, "invalid JSON, need channel", http.StatusBadRequest)
+				return
+			}
+			userID, err := globalUserProfiles.ResolveUser(body.Channel)
+			if err == nil {
+				err = globalUserProfiles.SetModulation(userID, body.Enabled)
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "user.modulation", "http", fmt.Sprintf("user=%s enabled=%t", userID, body.Enabled), clientIP(r))
+			json.NewEncoder(w).Encode(map[string]any{"userId": userID, "enabled": body.Enabled})
+		default:
+			http.Error(w, `{"error":"GET or PUT only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// --- Knowledge Watch Routes ---
+
+// globalKnowledgeWatch watches knowledgeDir and vaultDir, set when
+// knowledgeWatch.enabled. globalKnowledgeIndex is the search index it keeps
+// current.
+var (
+	globalKnowledgeWatch *docwatch.Service
+	globalKnowledgeIndex *knowledge.Index
+)
+
+func (s *Server) registerKnowledgeWatchRoutes(mux *http.ServeMux) {
+	// GET /api/knowledge/watch — watched directories and recent changes.
+	mux.HandleFunc("/api/knowledge/watch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalKnowledgeWatch == nil {
+			jsonError(w, "knowledge watch not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(globalKnowledgeWatch.Status())
+	})
+}
+
+// --- Monitor Routes ---
+
+// globalMonitors is the package-level watchlist service, set when monitors.enabled.
+var globalMonitors *monitor.Service
+
+func (s *Server) registerMonitorRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/monitors — configured targets with their last check.
+	mux.HandleFunc("/api/monitors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMonitors == nil {
+			jsonError(w, "monitors not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(globalMonitors.Status())
+	})
+
+	// POST /api/monitors/{name}/check   — run a check now.
+	// GET  /api/monitors/{name}/changes — recent changes (?limit=N).
+	mux.HandleFunc("/api/monitors/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalMonitors == nil {
+			jsonError(w, "monitors not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/monitors/"), "/")
+		if name == "" {
+			http.Error(w, `{"error":"invalid path, use /api/monitors/{name}/check|changes"}`, http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "check":
+			if r.Method != http.MethodPost {
+				http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			res, err := globalMonitors.Check(r.Context(), name)
+			if errors.Is(err, monitor.ErrNotFound) {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "monitor.check", "http", fmt.Sprintf("name=%s changed=%v", name, res.Changed), clientIP(r))
+			json.NewEncoder(w).Encode(res)
+		case "changes":
+			if r.Method != http.MethodGet {
+				http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			changes, err := monitor.Changes(cfg.HistoryDB, name, limit)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if changes == nil {
+				changes = []monitor.Change{}
+			}
+			json.NewEncoder(w).Encode(changes)
+		default:
+			http.Error(w, `{"error":"invalid path, use /api/monitors/{name}/check|changes"}`, http.StatusBadRequest)
+		}
+	})
+}
+
+// --- Memory Review Routes ---
+
+// globalMemoryReviewer is the package-level staleness reviewer, set when memory.review.enabled.
+var globalMemoryReviewer *memory.Reviewer
+
+func (s *Server) registerMemoryReviewRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /memory/review — proposals waiting for confirmation.
+	mux.HandleFunc("/memory/review", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMemoryReviewer == nil {
+			jsonError(w, "memory review not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		pending := globalMemoryReviewer.Pending()
+		if pending == nil {
+			pending = []memory.Proposal{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"pending": pending})
+	})
+
+	// POST /memory/review/run   — review due entries now.
+	// POST /memory/review/{key} — settle a proposal: {"action":"accept"|"keep"}.
+	mux.HandleFunc("/memory/review/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMemoryReviewer == nil {
+			jsonError(w, "memory review not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/memory/review/")
+		if key == "run" {
+			res, err := globalMemoryReviewer.Run(r.Context())
+			if errors.Is(err, memory.ErrReviewRunning) {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "memory.review", "http",
+				fmt.Sprintf("reviewed=%d applied=%d proposed=%d", res.Reviewed, res.Applied, res.Proposed), clientIP(r))
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		var body struct {
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		err := globalMemoryReviewer.Resolve(key, body.Action)
+		if errors.Is(err, memory.ErrNoProposal) {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "memory.review.resolve", "http",
+			fmt.Sprintf("key=%s action=%s", key, body.Action), clientIP(r))
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+}
+
+// --- Pipeline Routes ---
+
+// globalPipelines is the package-level data pipeline service, set when pipelines.enabled.
+var globalPipelines *pipeline.Service
+
+// pipelineError maps store errors to HTTP statuses.
+func pipelineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pipeline.ErrNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, pipeline.ErrExists):
+		jsonError(w, err.Error(), http.StatusConflict)
+	default:
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) registerPipelineRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET  /api/pipelines — stored pipelines with their next scheduled run.
+	// POST /api/pipelines — create a pipeline; ?dryRun=1 previews it without storing.
+	mux.HandleFunc("/api/pipelines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalPipelines == nil {
+			jsonError(w, "pipelines not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			list, err := pipeline.List(globalPipelines.DBPath())
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			type item struct {
+				pipeline.Pipeline
+				NextRun string `json:"nextRun,omitempty"`
+			}
+			out := make([]item, 0, len(list))
+			for _, p := range list {
+				it := item{Pipeline: p}
+				if next, ok := globalPipelines.NextRun(p.Name); ok && !next.IsZero() {
+					it.NextRun = next.UTC().Format(time.RFC3339)
+				}
+				out = append(out, it)
+			}
+			json.NewEncoder(w).Encode(out)
+
+		case http.MethodPost:
+			var p pipeline.Pipeline
+			if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&p); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := p.Validate(); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if r.URL.Query().Get("dryRun") == "1" {
+				run, err := globalPipelines.Preview(r.Context(), p)
+				if err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				json.NewEncoder(w).Encode(run)
+				return
+			}
+			created, err := pipeline.Create(globalPipelines.DBPath(), p)
+			if err != nil {
+				pipelineError(w, err)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "pipeline.create", "http", "name="+p.Name, clientIP(r))
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET    /api/pipelines/{name}      — one pipeline.
+	// PUT    /api/pipelines/{name}      — replace its definition.
+	// DELETE /api/pipelines/{name}      — delete it and its run history.
+	// POST   /api/pipelines/{name}/run  — run it now; ?dryRun=1 fetches and renders without dispatching.
+	// GET    /api/pipelines/{name}/runs — recent runs (?limit=N).
+	mux.HandleFunc("/api/pipelines/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalPipelines == nil {
+			jsonError(w, "pipelines not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		dbPath := globalPipelines.DBPath()
+		name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/pipelines/"), "/")
+		if name == "" {
+			http.Error(w, `{"error":"invalid path, use /api/pipelines/{name}[/run|runs]"}`, http.StatusBadRequest)
+			return
+		}
+		switch action {
+		case "":
+			switch r.Method {
+			case http.MethodGet:
+				p, err := pipeline.Get(dbPath, name)
+				if err != nil {
+					pipelineError(w, err)
+					return
+				}
+				json.NewEncoder(w).Encode(p)
+			case http.MethodPut:
+				var p pipeline.Pipeline
+				if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&p); err != nil {
+					jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				p.Name = name
+				if err := p.Validate(); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				updated, err := pipeline.Update(dbPath, p)
+				if err != nil {
+					pipelineError(w, err)
+					return
+				}
+				globalPipelines.Reschedule(name)
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "pipeline.update", "http", "name="+name, clientIP(r))
+				json.NewEncoder(w).Encode(updated)
+			case http.MethodDelete:
+				if err := pipeline.Delete(dbPath, name); err != nil {
+					pipelineError(w, err)
+					return
+				}
+				globalPipelines.Reschedule(name)
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "pipeline.delete", "http", "name="+name, clientIP(r))
+				json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "name": name})
+			default:
+				http.Error(w, `{"error":"GET, PUT or DELETE only"}`, http.StatusMethodNotAllowed)
+			}
+
+		case "run":
+			if r.Method != http.MethodPost {
+				http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			dryRun := r.URL.Query().Get("dryRun") == "1"
+			run, err := globalPipelines.Run(r.Context(), name, dryRun)
+			if err != nil {
+				pipelineError(w, err)
+				return
+			}
+			if !dryRun {
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "pipeline.run", "http", fmt.Sprintf("name=%s status=%s", name, run.Status), clientIP(r))
+			}
+			json.NewEncoder(w).Encode(run)
+
+		case "runs":
+			if r.Method != http.MethodGet {
+				http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			runs, err := pipeline.Runs(dbPath, name, limit)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(runs)
+
+		default:
+			http.Error(w, `{"error":"invalid path, use /api/pipelines/{name}[/run|runs]"}`, http.StatusBadRequest)
+		}
+	})
+}
+
+// globalWorkQueue is the package-level shared work queue, set when workQueue.enabled.
+var globalWorkQueue *workqueue.Service
+
+func (s *Server) registerWorkQueueRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET  /api/workqueue — pending tasks, leases per node and live nodes.
+	// POST /api/workqueue — enqueue a task for any node {"name", "prompt", "agent", ...}.
+	mux.HandleFunc("/api/workqueue", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalWorkQueue == nil {
+			jsonError(w, "work queue not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			st, err := globalWorkQueue.Stats(r.Context())
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			json.NewEncoder(w).Encode(st)
+
+		case http.MethodPost:
+			var task Task
+			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if strings.TrimSpace(task.Prompt) == "" {
+				jsonError(w, "prompt is required", http.StatusBadRequest)
+				return
+			}
+			if task.Agent != "" {
+				if _, ok := cfg.Agents[task.Agent]; !ok {
+					jsonError(w, fmt.Sprintf("agent %q not found in config", task.Agent), http.StatusBadRequest)
+					return
+				}
+			}
+			// Defaults are filled in by the node that runs the task.
+			task.Source = "http"
+			job, err := globalWorkQueue.Enqueue(r.Context(), task, task.Agent)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "workqueue.enqueue", "http",
+				fmt.Sprintf("jobId=%s name=%s agent=%s", job.ID, task.Name, task.Agent), clientIP(r))
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(job)
+
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET /api/workqueue/{id} — a task's state, and its result once done.
+	mux.HandleFunc("/api/workqueue/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalWorkQueue == nil {
+			jsonError(w, "work queue not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		job, err := globalWorkQueue.Get(r.Context(), strings.TrimPrefix(r.URL.Path, "/api/workqueue/"))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if job == nil {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(job)
+	})
+}
+
+// globalTwitter is the package-level Twitter/X draft queue, set when twitter.enabled.
+var globalTwitter *twitter.Queue
+
+// registerTwitterRoutes serves the draft queue to the dashboard. Drafts
+// composed and scheduled here are the owner's own actions: only agents'
+// publishing tool calls go through external-action approval.
+func (s *Server) registerTwitterRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// POST /api/twitter/preview — split text into a thread without saving {"text", "numbered"}.
+	mux.HandleFunc("/api/twitter/preview", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Text     string `json:"text"`
+			Numbered *bool  `json:"numbered"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := twitter.SplitThread(req.Text, cfg.Twitter.MaxTweetLength(), req.Numbered == nil || *req.Numbered)
+		lengths := make([]int, len(parts))
+		for i, p := range parts {
+			lengths[i] = twitter.WeightedLength(p)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"parts": parts, "lengths": lengths, "max": cfg.Twitter.MaxTweetLength()})
+	})
+
+	// GET  /api/twitter/drafts — drafts, newest first (?status=&limit=).
+	// POST /api/twitter/drafts — compose {"text", "replyTo", "numbered"}; with "at"
+	//                            (RFC3339 or "optimal") it is also scheduled.
+	mux.HandleFunc("/api/twitter/drafts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalTwitter == nil {
+			jsonError(w, "twitter not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			drafts, err := globalTwitter.List(r.URL.Query().Get("status"), limit)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(drafts)
+
+		case http.MethodPost:
+			var req struct {
+				Text     string  `json:"text"`
+				ReplyTo  string  `json:"replyTo"`
+				Numbered *bool   `json:"numbered"`
+				At       *string `json:"at"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			var at time.Time
+			if req.At != nil {
+				var err error
+				if at, err = globalTwitter.ResolveTime(*req.At, time.Now()); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			d, err := globalTwitter.Compose(req.Text, req.ReplyTo, "dashboard", req.Numbered == nil || *req.Numbered)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "twitter.draft", "http", fmt.Sprintf("draftId=%s parts=%d", d.ID, len(d.Parts)), clientIP(r))
+			if req.At != nil {
+				if d, err = globalTwitter.Schedule(d.ID, at); err != nil {
+					jsonError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "twitter.schedule", "http", fmt.Sprintf("draftId=%s at=%s", d.ID, d.ScheduledAt), clientIP(r))
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(d)
+
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET    /api/twitter/drafts/{id}          — one draft.
+	// DELETE /api/twitter/drafts/{id}          — cancel it.
+	// POST   /api/twitter/drafts/{id}/schedule — schedule it {"at": RFC3339 | "optimal"}.
+	// POST   /api/twitter/drafts/{id}/post     — post it now.
+	mux.HandleFunc("/api/twitter/drafts/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalTwitter == nil {
+			jsonError(w, "twitter not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/twitter/drafts/"), "/")
+		if id == "" {
+			http.Error(w, `{"error":"invalid path, use /api/twitter/drafts/{id}[/schedule|post]"}`, http.StatusBadRequest)
+			return
+		}
+		var d *twitter.Draft
+		var err error
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			if d, err = globalTwitter.Get(id); err == nil && d == nil {
+				err = twitter.ErrNotFound
+			}
+		case action == "" && r.Method == http.MethodDelete:
+			if d, err = globalTwitter.Cancel(id); err == nil {
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "twitter.cancel", "http", "draftId="+id, clientIP(r))
+			}
+		case action == "schedule" && r.Method == http.MethodPost:
+			var req struct {
+				At string `json:"at"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			at, terr := globalTwitter.ResolveTime(req.At, time.Now())
+			if terr != nil {
+				jsonError(w, terr.Error(), http.StatusBadRequest)
+				return
+			}
+			if d, err = globalTwitter.Schedule(id, at); err == nil {
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "twitter.schedule", "http", fmt.Sprintf("draftId=%s at=%s", id, d.ScheduledAt), clientIP(r))
+			}
+		case action == "post" && r.Method == http.MethodPost:
+			d, err = globalTwitter.PostNow(r.Context(), id)
+			if d != nil {
+				outcome := "posted"
+				if err != nil {
+					outcome = "failed"
+				}
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "twitter."+outcome, "http",
+					fmt.Sprintf("draftId=%s parts=%d posted=%s", id, len(d.Parts), strings.Join(d.PostedIDs, ",")), clientIP(r))
+			}
+			if err != nil && d != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		default:
+			http.Error(w, `{"error":"GET or DELETE /{id}, POST /{id}/schedule or /{id}/post"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, twitter.ErrNotFound):
+			jsonError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, twitter.ErrNotPending):
+			jsonError(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(d)
+		}
+	})
+
+	// GET /api/twitter/stats — engagement of posted tweets (?days=N, ?refresh=1
+	// fetches current metrics first), per hour of day, and the next optimal time.
+	mux.HandleFunc("/api/twitter/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalTwitter == nil {
+			jsonError(w, "twitter not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Query().Get("refresh") == "1" {
+			if err := globalTwitter.RefreshStats(r.Context()); err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+		stats, err := globalTwitter.Stats(days)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		json.NewEncoder(w).Encode(map[string]any{
+			"posts":       stats,
+			"byHour":      twitter.EngagementByHour(stats, now.Location()),
+			"optimalNext": globalTwitter.OptimalTime(now).Format(time.RFC3339),
+		})
+	})
+}
+
+// globalSocial is the package-level Bluesky/Mastodon service, set when
+// social accounts are configured.
+var globalSocial *social.Service
+
+// registerSocialRoutes serves the social mentions inbox. Posting is left to
+// agents' social_* tools, which go through external-action approval.
+func (s *Server) registerSocialRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/social/mentions — the inbox, newest first (?account=&unread=1&limit=).
+	mux.HandleFunc("/api/social/mentions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSocial == nil {
+			jsonError(w, "no social accounts configured", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		mentions, err := globalSocial.Mentions(q.Get("account"), q.Get("unread") == "1", limit)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(mentions)
+	})
+
+	// POST /api/social/mentions/read — mark mentions read {"account", "ids"};
+	// without ids, all of the account's mentions.
+	mux.HandleFunc("/api/social/mentions/read", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSocial == nil {
+			jsonError(w, "no social accounts configured", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Account string   `json:"account"`
+			IDs     []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := cfg.Social.Account(req.Account); !ok {
+			jsonError(w, "unknown account: "+req.Account, http.StatusNotFound)
+			return
+		}
+		if err := globalSocial.MarkRead(req.Account, req.IDs); err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+	})
+
+	// POST /api/social/{account}/refresh — fetch the account's new mentions now.
+	mux.HandleFunc("/api/social/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		account, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/social/"), "/")
+		if account == "" || action != "refresh" {
+			http.Error(w, `{"error":"invalid path, use /api/social/{account}/refresh"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSocial == nil {
+			jsonError(w, "no social accounts configured", http.StatusServiceUnavailable)
+			return
+		}
+		added, err := globalSocial.FetchMentions(r.Context(), account)
+		switch {
+		case errors.Is(err, social.ErrUnknownAccount):
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "social.refresh", "http", fmt.Sprintf("account=%s new=%d", account, len(added)), clientIP(r))
+		json.NewEncoder(w).Encode(map[string]any{"new": added})
+	})
+}
+
+// globalReadLater is the package-level read-later service, set when
+// readLater is configured.
+var globalReadLater *readlater.Service
+
+// registerReadLaterRoutes serves the reading list, saving, sync and the
+// saved-vs-read insight.
+func (s *Server) registerReadLaterRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/readlater — the reading list, newest first (?unread=1&limit=).
+	// POST /api/readlater — save a link {"url", "title"}.
+	mux.HandleFunc("/api/readlater", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalReadLater == nil {
+			jsonError(w, "read-later not configured", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			items, err := globalReadLater.Items(q.Get("unread") == "1", limit)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(items)
+		case http.MethodPost:
+			var req struct {
+				URL   string `json:"url"`
+				Title string `json:"title"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			links := readlater.Links(req.URL)
+			if len(links) != 1 {
+				jsonError(w, "url must be a single http(s) link", http.StatusBadRequest)
+				return
+			}
+			item, added, err := globalReadLater.Save(r.Context(), links[0], req.Title, "http")
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			if added {
+				audit.LogCtx(r.Context(), cfg.HistoryDB, "readlater.save", "http", item.URL, clientIP(r))
+			}
+			json.NewEncoder(w).Encode(map[string]any{"item": item, "added": added})
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// POST /api/readlater/sync — pull reading progress and highlights now.
+	mux.HandleFunc("/api/readlater/sync", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalReadLater == nil {
+			jsonError(w, "read-later not configured", http.StatusServiceUnavailable)
+			return
+		}
+		res, err := globalReadLater.Sync(r.Context())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "readlater.sync", "http",
+			fmt.Sprintf("documents=%d highlights=%d read=%d", res.Documents, res.Highlights, res.Read), clientIP(r))
+		json.NewEncoder(w).Encode(res)
+	})
+
+	// GET /api/readlater/insight — saved vs read over ?days= (default 7).
+	mux.HandleFunc("/api/readlater/insight", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalReadLater == nil {
+			jsonError(w, "read-later not configured", http.StatusServiceUnavailable)
+			return
+		}
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+		in, err := globalReadLater.Insight(time.Now(), days)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"insight": in, "text": in.Format()})
+	})
+}
+
+// globalTaskSync is the package-level Jira/Linear task sync, set when
+// taskBoard.sync is configured.
+var globalTaskSync *tasksync.Service
+
+// registerTaskSyncRoutes serves the task sync status and manual syncs.
+func (s *Server) registerTaskSyncRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/tasks/sync — last run, linked tickets, errors and conflicts.
+	// POST /api/tasks/sync — sync now.
+	mux.HandleFunc("/api/tasks/sync", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalTaskSync == nil {
+			jsonError(w, "task sync not configured", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			st, err := globalTaskSync.Status()
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(st)
+		case http.MethodPost:
+			res, err := globalTaskSync.Sync(r.Context())
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "tasksync.sync", "http",
+				fmt.Sprintf("created=%d pushed=%d pulled=%d conflicts=%d errors=%d", res.Created, res.Pushed, res.Pulled, res.Conflicts, res.Errors), clientIP(r))
+			json.NewEncoder(w).Encode(res)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// globalResponseCache is the package-level response cache, set at daemon
+// start when a history DB is configured.
+var globalResponseCache *respcache.Cache
+
+// globalMailIn is the package-level inbound email service, set when mailIn
+// is enabled.
+var globalMailIn *mailin.Service
+
+// registerMailInRoutes serves the Mailgun inbound route. It is outside API
+// auth and verified by the Mailgun signature instead.
+func (s *Server) registerMailInRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// POST /api/mail/inbound — a Mailgun route forwarding to this URL
+	// (forward("https://.../api/mail/inbound")). Accepted mail is handled in
+	// the background; replays are deduplicated by Message-ID.
+	mux.HandleFunc("/api/mail/inbound", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMailIn == nil || cfg.MailIn.Mailgun.SigningKey == "" {
+			jsonError(w, "inbound email not configured", http.StatusServiceUnavailable)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 32<<20)
+		if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+			jsonError(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !mailin.VerifyMailgun(cfg.MailIn.Mailgun.SigningKey, r.FormValue("timestamp"), r.FormValue("token"), r.FormValue("signature"), time.Now()) {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "mailin.rejected", "http", "bad signature", clientIP(r))
+			jsonError(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		m, err := mailin.ParseMailgun(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !cfg.MailIn.SenderAllowed(m.From) || (cfg.MailIn.RequireDKIM && !m.DKIMPass) {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "mailin.rejected", "http",
+				fmt.Sprintf("from=%s dkim=%t", m.From, m.DKIMPass), clientIP(r))
+			// 406 tells Mailgun not to retry.
+			jsonError(w, "sender not allowed", http.StatusNotAcceptable)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "mailin.received", "http",
+			fmt.Sprintf("from=%s subject=%s", m.From, truncate(m.Subject, 100)), clientIP(r))
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			if _, err := globalMailIn.Handle(ctx, m, "mailgun"); err != nil {
+				log.Warn("mailin: handle failed", "from", m.From, "error", err)
+			}
+		}()
+		json.NewEncoder(w).Encode(map[string]any{"status": "accepted", "action": globalMailIn.ActionFor(m)})
+	})
+}
+
+// globalSync is the package-level state sync service, set when sync is
+// enabled.
+var globalSync *statesync.Service
+
+// registerSyncRoutes serves state sync: the sealed exchange endpoint the peer
+// posts to, and status and manual runs for this instance.
+func (s *Server) registerSyncRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// POST /api/sync — an exchange from the peer. The body and the response
+	// are sealed with sync.key; API auth applies as usual.
+	mux.HandleFunc("/api/sync", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSync == nil {
+			jsonError(w, "sync not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<20))
+		if err != nil {
+			jsonError(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		out, res, err := globalSync.Handle(body)
+		if err != nil {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "sync.rejected", "http", err.Error(), clientIP(r))
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "sync.serve", "http",
+			fmt.Sprintf("peer=%s received=%d applied=%d sent=%d", res.Peer, res.Pulled, res.Applied, res.Pushed), clientIP(r))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(out)
+	})
+
+	// GET /api/sync/status — configuration and the last exchange started here.
+	mux.HandleFunc("/api/sync/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSync == nil {
+			json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled":   true,
+			"peer":      cfg.Sync.Peer,
+			"direction": cfg.Sync.DirectionOrDefault(),
+			"interval":  cfg.Sync.IntervalOrDefault().String(),
+			"datasets":  cfg.Sync.DatasetsOrDefault(),
+			"last":      globalSync.Last(),
+		})
+	})
+
+	// POST /api/sync/run — exchange with the peer now.
+	mux.HandleFunc("/api/sync/run", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalSync == nil {
+			jsonError(w, "sync not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		res, err := globalSync.Run(r.Context())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "sync.run", "http",
+			fmt.Sprintf("peer=%s pushed=%d pulled=%d applied=%d", res.Peer, res.Pushed, res.Pulled, res.Applied), clientIP(r))
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// globalMirror is the package-level mirror service, set when mirror is
+// enabled.
+var globalMirror *mirror.Service
+
+// registerMirrorRoutes serves the mirror: the item listing and conditional
+// push the peer calls, and status and manual syncs for this instance.
+func (s *Server) registerMirrorRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/mirror/items?kinds=agents,skills — this instance's items with
+	// their content and hashes.
+	// POST /api/mirror/items — items pushed by the peer, each written only if
+	// this instance still has the hash in its base.
+	mux.HandleFunc("/api/mirror/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if globalMirror == nil {
+			jsonError(w, "mirror not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			var kinds []string
+			if q := r.URL.Query().Get("kinds"); q != "" {
+				kinds = strings.Split(q, ",")
+			}
+			list, err := globalMirror.Items(kinds)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(list)
+		case http.MethodPost:
+			var req mirror.ApplyRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&req); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			resp := globalMirror.Apply(&req)
+			counts := map[string]int{}
+			for _, res := range resp.Results {
+				counts[res.Status]++
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "mirror.serve", "http",
+				fmt.Sprintf("peer=%s items=%d applied=%d conflicts=%d errors=%d", req.Node, len(req.Items), counts["applied"], counts["conflict"], counts["error"]), clientIP(r))
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET /api/mirror/status — configuration and the last sync started here.
+	mux.HandleFunc("/api/mirror/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMirror == nil {
+			json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled":   true,
+			"peer":      cfg.Mirror.Peer,
+			"direction": cfg.Mirror.DirectionOrDefault(),
+			"interval":  cfg.Mirror.IntervalOrDefault().String(),
+			"kinds":     cfg.Mirror.KindsOrDefault(),
+			"conflict":  cfg.Mirror.ConflictOrDefault(),
+			"last":      globalMirror.Last(),
+		})
+	})
+
+	// POST /api/mirror/run — sync with the peer now. The optional body
+	// overrides the direction, conflict policy and kinds, or asks for a dry
+	// run that only reports what would be copied.
+	mux.HandleFunc("/api/mirror/run", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMirror == nil {
+			jsonError(w, "mirror not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Direction string   `json:"direction"`
+			Conflict  string   `json:"conflict"`
+			Kinds     []string `json:"kinds"`
+			DryRun    bool     `json:"dryRun"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		res, err := globalMirror.Run(r.Context(), mirror.Options{Direction: body.Direction, Conflict: body.Conflict, Kinds: body.Kinds, DryRun: body.DryRun})
+		if err != nil {
+			if res == nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+			} else {
+				jsonError(w, err.Error(), http.StatusBadGateway)
+			}
+			return
+		}
+		if !res.DryRun {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "mirror.run", "http",
+				fmt.Sprintf("peer=%s pushed=%d pulled=%d conflicts=%d failed=%d", res.Peer, len(res.Pushed), len(res.Pulled), len(res.Conflicts), len(res.Failed)), clientIP(r))
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// globalGitOps is the package-level GitOps reconciler, set when gitops is
+// enabled.
+var globalGitOps *gitops.Service
+
+// registerGitOpsRoutes serves the GitOps reconciler: its status, manual
+// syncs and the push webhook of the repo's host.
+func (s *Server) registerGitOpsRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/gitops/status — the applied revision, the last check and
+	// local edits to the managed files.
+	mux.HandleFunc("/api/gitops/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalGitOps == nil {
+			json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(globalGitOps.Status())
+	})
+
+	// POST /api/gitops/sync — fetch and apply the branch now, over local
+	// edits too. {"dryRun": true} only reports what would change.
+	mux.HandleFunc("/api/gitops/sync", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalGitOps == nil {
+			jsonError(w, "gitops not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			DryRun bool `json:"dryRun"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		res, err := globalGitOps.Sync(r.Context(), body.DryRun)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if !res.DryRun {
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "gitops.sync", "http",
+				fmt.Sprintf("revision=%s changed=%d deleted=%d", res.Revision, len(res.Changed), len(res.Deleted)), clientIP(r))
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+
+	// POST /api/gitops/webhook — push notification from GitHub, GitLab or
+	// any sender signing with gitops.webhookSecret. The reconciler checks
+	// the repo in the background.
+	mux.HandleFunc("/api/gitops/webhook", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalGitOps == nil || cfg.GitOps.WebhookSecret == "" {
+			jsonError(w, "gitops webhook not configured", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			jsonError(w, "read body", http.StatusBadRequest)
+			return
+		}
+		if !verifyWebhookSignature(r, body, cfg.GitOps.WebhookSecret) {
+			jsonError(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		globalGitOps.Trigger()
+		audit.Log(cfg.HistoryDB, "gitops.webhook", "http", "", clientIP(r))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"accepted"}`))
+	})
+}
+
+// registerMQTTRoutes serves the state of the MQTT broker connections.
+func (s *Server) registerMQTTRoutes(mux *http.ServeMux) {
+	// GET /api/mqtt/status — connection, subscriptions and message counts per broker.
+	mux.HandleFunc("/api/mqtt/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalMQTT == nil {
+			json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled": true,
+			"brokers": globalMQTT.Status(),
+		})
+	})
+}
+
+// globalForges is the package-level forge service, set when a code forge
+// account is configured.
+var globalForges *forge.Service
+
+// registerForgeRoutes serves the code forge webhooks. They are outside API
+// auth and verified with each account's webhook secret instead.
+func (s *Server) registerForgeRoutes(mux *http.ServeMux) {
+	// POST /api/forge/{account}/webhook — a GitHub, GitLab or Gitea webhook
+	// for the account. Each delivery becomes a "<type>:<event>.<action>"
+	// event for workflow triggers, proactive rules and the dashboard.
+	mux.HandleFunc("/api/forge/", func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/forge/"), "/webhook")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			w.Header().Set("Content-Type", "application/json")
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		s.handleForgeWebhook(w, r, name)
+	})
+	// POST /api/github/webhook — the webhook of the github config block.
+	mux.HandleFunc("/api/github/webhook", func(w http.ResponseWriter, r *http.Request) {
+		s.handleForgeWebhook(w, r, "github")
+	})
+}
+
+func (s *Server) handleForgeWebhook(w http.ResponseWriter, r *http.Request, name string) {
+	cfg := s.cfg
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var acct tetoraConfig.ForgeAccount
+	for _, a := range cfg.ForgeAccounts() {
+		if a.Name == name {
+			acct = a
+			break
+		}
+	}
+	if acct.Name == "" || acct.WebhookSecret == "" {
+		jsonError(w, "forge webhook not configured: "+name, http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 25<<20))
+	if err != nil {
+		jsonError(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !forge.Verify(acct, r.Header, body) {
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "forge.rejected", "http", name+": bad signature", clientIP(r))
+		jsonError(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if forge.Ping(r.Header) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "pong"})
+		return
+	}
+	// GitHub webhooks set to application/x-www-form-urlencoded send the
+	// JSON in a payload field; the signature covers the form.
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			jsonError(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = []byte(form.Get("payload"))
+	}
+	ev, err := forge.ParseEvent(acct, r.Header, body)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ev.Repo != "" && !acct.RepoAllowed(ev.Repo) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ignored", "reason": "repo not served by " + name})
+		return
+	}
+	log.Info("forge webhook", "account", name, "event", ev.Type, "repo", ev.Repo, "delivery", ev.Data["delivery"])
+	sse := SSEEvent{Type: ev.Type, Data: ev.Data}
+	if s.state != nil && s.state.broker != nil {
+		s.state.broker.Publish("_triggers", sse)
+		s.state.broker.Publish(SSEDashboardKey, sse)
+	}
+	if s.proactiveEngine != nil {
+		go s.proactiveEngine.HandleEvent(sse)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"status": "accepted", "event": ev.Type})
+}
+
+// registerTimelineRoutes serves the day replay: everything that happened on
+// a date, in order.
+func (s *Server) registerTimelineRoutes(mux *http.ServeMux) {
+	// GET /timeline?date=YYYY-MM-DD — tasks, notifications, memory writes,
+	// lessons and reminders of the day with its costs. format=markdown
+	// returns the daily note section instead of JSON.
+	mux.HandleFunc("/timeline", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		date, err := timeline.ParseDate(r.URL.Query().Get("date"), time.Now())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		day, err := buildTimeline(s.Cfg(), date)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if f := r.URL.Query().Get("format"); f == "markdown" || f == "md" {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			io.WriteString(w, day.Markdown())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(day)
+	})
+
+	// POST /timeline/export?date=YYYY-MM-DD — write the day's timeline into
+	// its daily note.
+	mux.HandleFunc("/timeline/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		cfg := s.Cfg()
+		date, err := timeline.ParseDate(r.URL.Query().Get("date"), time.Now())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		day, err := buildTimeline(cfg, date)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		path, err := exportTimeline(cfg, day)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"date": day.Date, "path": path, "events": len(day.Events)})
+	})
+}
+
+// requestIdentity names who made r: the signed-in or token identity, else
+// "http".
+func requestIdentity(r *http.Request) string {
+	if id := audit.IdentityFrom(r.Context()); id != "" {
+		return id
+	}
+	return "http"
+}
+
+// registerEmergencyRoutes serves the emergency stop.
+func (s *Server) registerEmergencyRoutes(mux *http.ServeMux) {
+	// GET /emergency — whether the stop is engaged, and the running tasks.
+	mux.HandleFunc("/emergency", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalEmergency == nil {
+			jsonError(w, "emergency stop is not available", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"state":   globalEmergency.State(),
+			"running": globalEmergency.Running(),
+		})
+	})
+
+	// POST /emergency/stop {"reason"} — cancel everything running and
+	// pause cron, proactive rules, workflow triggers and the budget.
+	mux.HandleFunc("/emergency/stop", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&body)
+		st, err := emergencyStop(r.Context(), s.Cfg(), "http", requestIdentity(r), body.Reason, clientIP(r))
+		if err != nil && !st.Engaged {
+			jsonError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "stopped", "state": st})
+	})
+
+	// POST /emergency/resume {"confirm"} — without a confirmation code,
+	// answers 428 with a new one; with it, releases the stop.
+	mux.HandleFunc("/emergency/resume", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalEmergency == nil {
+			jsonError(w, "emergency stop is not available", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Confirm string `json:"confirm"`
+		}
+		json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&body)
+		if body.Confirm == "" {
+			code, expires, err := globalEmergency.RequestRelease()
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusPreconditionRequired)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":     "confirmation required: repeat the request with this code as confirm",
+				"confirm":   code,
+				"expiresAt": expires.Format(time.RFC3339),
+			})
+			return
+		}
+		prev, err := emergencyResume(r.Context(), s.Cfg(), "http", requestIdentity(r), body.Confirm, clientIP(r))
+		switch {
+		case errors.Is(err, emergency.ErrNotEngaged):
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, emergency.ErrConfirm):
+			jsonError(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "resumed", "stoppedSince": prev.Since})
+	})
+}
+
+// registerIntegrationHealthRoutes serves integration health scores and
+// re-enables disabled integrations.
+func (s *Server) registerIntegrationHealthRoutes(mux *http.ServeMux) {
+	// GET /api/integrations/health — score and state of every integration
+	// called since start.
+	mux.HandleFunc("/api/integrations/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		st := globalIntegrationHealth.Statuses()
+		if st == nil {
+			st = []health.Status{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled":      globalIntegrationHealth != nil,
+			"integrations": st,
+		})
+	})
+
+	// GET /api/integrations/health/enable?name=&token= — the link sent
+	// when an integration is disabled; the one-time token stands in for
+	// API auth. POST {"name"} — re-enable from the API or dashboard.
+	mux.HandleFunc("/api/integrations/health/enable", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var name, token string
+		switch r.Method {
+		case http.MethodGet:
+			name, token = r.URL.Query().Get("name"), r.URL.Query().Get("token")
+			if token == "" {
+				jsonError(w, "token is required", http.StatusBadRequest)
+				return
+			}
+		case http.MethodPost:
+			var body struct {
+				Name string `json:"name"`
+			}
+			json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&body)
+			name = body.Name
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalIntegrationHealth == nil {
+			jsonError(w, "integration health is not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		if name == "" {
+			jsonError(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if !globalIntegrationHealth.Enable(name, token) {
+			if token != "" {
+				jsonError(w, "integration is not disabled, or the link has expired", http.StatusNotFound)
+			} else {
+				jsonError(w, "integration is not disabled", http.StatusConflict)
+			}
+			return
+		}
+		audit.Log(s.Cfg().HistoryDB, "integration.enable", requestIdentity(r), "integration="+name, clientIP(r))
+		json.NewEncoder(w).Encode(map[string]any{"status": "enabled", "name": name})
+	})
+}
+
+// apiVersion is the version of the HTTP API, raised when a change breaks
+// existing clients.
+const apiVersion = 1
+
+// feature is an optional subsystem in /capabilities, with the endpoint
+// clients use for it.
+type feature struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path,omitempty"`
+}
+
+// capabilities is what GET /capabilities reports.
+type capabilities struct {
+	Version      string             `json:"version"`
+	Versions     map[string]any     `json:"versions"`
+	Role         string             `json:"role"` // "active", "standby" or "replica"
+	Channels     map[string]bool    `json:"channels"`
+	Integrations map[string]bool    `json:"integrations"`
+	Features     map[string]feature `json:"features"`
+	Auth         map[string]bool    `json:"auth"`
+	Providers    []string           `json:"providers"`
+	Agents       []string           `json:"agents"`
+	Tools        []string           `json:"tools"`
+	MCPServers   []string           `json:"mcpServers"`
+	Plugins      []string           `json:"plugins"`
+}
+
+// capabilities reports which subsystems, channels, integrations and tools
+// this deployment has, so clients can adapt their UI instead of probing
+// endpoints.
+func (s *Server) capabilities() capabilities {
+	cfg := s.Cfg()
+	c := capabilities{
+		Version: tetoraVersion,
+		Versions: map[string]any{
+			"tetora": tetoraVersion,
+			"api":    apiVersion,
+			"mcp":    mcpProtocolVersion,
+			"go":     runtime.Version(),
+			"os":     runtime.GOOS + "/" + runtime.GOARCH,
+		},
+		Role:         "active",
+		Channels:     channelsEnabled(cfg),
+		Integrations: integrationsEnabled(cfg),
+		Auth: map[string]bool{
+			"apiToken":  cfg.APIToken != "",
+			"dashboard": cfg.DashboardAuth.Enabled,
+			"sso":       s.sso != nil,
+			"totp":      cfg.DashboardAuth.TOTP.Enabled,
+		},
+		Providers:  sortedNames(cfg.Providers),
+		Agents:     sortedNames(cfg.Agents),
+		Tools:      []string{},
+		MCPServers: sortedNames(cfg.MCPServers),
+		Plugins:    sortedNames(cfg.Plugins),
+	}
+	if cfg.Replica.Enabled() {
+		c.Role = "replica"
+	} else if s.app != nil && !s.app.Leader.IsActive() {
+		c.Role = "standby"
+	}
+	if reg, ok := cfg.Runtime.ToolRegistry.(*ToolRegistry); ok {
+		for _, t := range reg.List() {
+			c.Tools = append(c.Tools, t.Name)
+		}
+		sort.Strings(c.Tools)
+	}
+	voice := cfg.Voice
+	c.Features = map[string]feature{
+		"workflows":         {true, "/workflows"},
+		"cron":              {true, "/cron"},
+		"sessions":          {true, "/sessions"},
+		"memory":            {true, "/memory"},
+		"knowledge":         {true, "/knowledge/search"},
+		"timeline":          {true, "/timeline"},
+		"validate":          {true, "/validate"},
+		"emergencyStop":     {globalEmergency != nil, "/emergency"},
+		"budgets":           {true, "/budget"},
+		"integrationHealth": {globalIntegrationHealth != nil, "/api/integrations/health"},
+		"taskBoard":         {cfg.TaskBoard.Enabled, "/api/tasks"},
+		"taskSync":          {globalTaskSync != nil, "/api/tasks/sync"},
+		"workQueue":         {cfg.WorkQueue.Enabled, "/api/workqueue"},
+		"knowledgeWatch":    {cfg.KnowledgeWatch.Enabled, "/api/knowledge/watch"},
+		"embedding":         {cfg.Embedding.Enabled, "/api/embedding/search"},
+		"monitors":          {cfg.Monitors.Enabled, "/api/monitors"},
+		"pipelines":         {cfg.Pipelines.Enabled, "/api/pipelines"},
+		"faq":               {cfg.FAQ.Enabled, "/api/faq"},
+		"intents":           {cfg.Intents.Enabled, "/api/intents"},
+		"handover":          {cfg.Handover.Enabled, "/api/handovers"},
+		"translate":         {cfg.Translate.Enabled, "/api/translate"},
+		"proactive":         {cfg.Proactive.Enabled, "/api/proactive/rules"},
+		"push":              {cfg.Push.Enabled, "/api/push/subscribe"},
+		"canvas":            {cfg.Canvas.Enabled, "/api/canvas/list"},
+		"voiceTranscribe":   {voice.STT.Enabled, "/api/voice/transcribe"},
+		"voiceSynthesize":   {voice.TTS.Enabled, "/api/voice/synthesize"},
+		"voiceWake":         {voice.Wake.Enabled, "/ws/voice/wake"},
+		"voiceRealtime":     {voice.Realtime.Enabled, "/ws/voice/realtime"},
+		"skillStore":        {cfg.Store.Enabled, "/api/store/browse"},
+		"mcpBridge":         {cfg.MCPBridge.Enabled, "/mcp"},
+		"approvalGates":     {cfg.ApprovalGates.Enabled, ""},
+		"reflection":        {cfg.Reflection.Enabled, "/reflections"},
+		"family":            {cfg.Family.Enabled, ""},
+		"ha":                {cfg.HA.Enabled, ""},
+		"replica":           {cfg.Replica.Enabled(), "/api/replica/status"},
+		"replication":       {cfg.Ops.Replication.Enabled, ""},
+		"sync":              {cfg.Sync.Enabled, "/api/sync/status"},
+		"mirror":            {cfg.Mirror.Enabled, "/api/mirror/status"},
+		"gitops":            {cfg.GitOps.Enabled, "/api/gitops/status"},
+	}
+	return c
+}
+
+// sortedNames returns the keys of m sorted, and an empty list rather than nil.
+func sortedNames[V any](m map[string]V) []string {
+	return append([]string{}, slices.Sorted(maps.Keys(m))...)
+}
+
+// registerCapabilityRoutes serves the capability discovery endpoint.
+func (s *Server) registerCapabilityRoutes(mux *http.ServeMux) {
+	// GET /capabilities — the version, role, channels, integrations,
+	// features and tools of this deployment.
+	mux.HandleFunc("/capabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(s.capabilities())
+	})
+}
+
+// registerReplicaRoutes serves history DB snapshots to replicas, and a
+// replica's own status.
+func (s *Server) registerReplicaRoutes(mux *http.ServeMux) {
+	cfg := s.cfg
+
+	// GET /api/replica/snapshot — a consistent copy of the history DB, or 304
+	// when the replica's If-None-Match is still current.
+	mux.HandleFunc("/api/replica/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if cfg.HistoryDB == "" {
+			jsonError(w, "no history DB", http.StatusNotFound)
+			return
+		}
+		replica.ServeSnapshot(w, r, cfg.HistoryDB)
+	})
+
+	// GET /api/replica/status — the replica's copy and lag; role "primary"
+	// on a daemon that is not a replica.
+	mux.HandleFunc("/api/replica/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if s.app == nil || s.app.Replica == nil {
+			json.NewEncoder(w).Encode(map[string]any{"role": "primary"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"role": "replica", "replica": s.app.Replica.Status()})
+	})
+}
+
+// --- Discord Plan Review Formatting ---
+
+// buildPlanReviewEmbed creates a rich Discord embed for plan review.
+func buildPlanReviewEmbed(review *PlanReview) discord.Embed {
+	color := 0x3498db // Blue for pending
+
+	// Truncate plan text for Discord embed (max 4096 chars for description).
+	planPreview := review.PlanText
+	if len(planPreview) > 3500 {
+		planPreview = planPreview[:3500] + "\n\n... (truncated, see dashboard for full plan)"
+	}
+
+	embed := discord.Embed{
+		Title:       "Plan Review Required",
+		Description: planPreview,
+		Color:       color,
+		Fields: []discord.EmbedField{
+			{Name: "Session", Value: truncate(review.SessionID, 36), Inline: true},
+		},
+		Timestamp: review.CreatedAt,
+	}
+
+	if review.Agent != "" {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name: "Agent", Value: review.Agent, Inline: true,
+		})
+	}
+	if review.WorkerName != "" {
 		embed.Fields = append(embed.Fields, discord.EmbedField{
 			Name: "Worker", Value: review.WorkerName, Inline: true,
 		})
@@ -4649,7 +8301,6 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 	state := s.state
 	sem := s.sem
 	childSem := s.childSem
-	cron := s.cron
 
 	// --- Dashboard SSE Stream ---
 	mux.HandleFunc("/events/dashboard", func(w http.ResponseWriter, r *http.Request) {
@@ -4740,6 +8391,22 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		}
 	})
 
+	// Charts saved by chart_render, referenced from replies as Markdown images.
+	mux.HandleFunc(chart.URLPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		p, ok := chart.Resolve(s.Cfg().ChartsDir(), r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeFile(w, r, p)
+	})
+
 	// Portrait upload: POST /api/agents/{name}/portrait
 	//                  DELETE /api/agents/{name}/portrait
 	mux.HandleFunc("/api/agents/", func(w http.ResponseWriter, r *http.Request) {
@@ -4822,102 +8489,369 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		if items == nil {
 			items = []QueueItem{}
 		}
-		json.NewEncoder(w).Encode(map[string]any{
-			"items":   items,
-			"count":   len(items),
-			"pending": countPendingQueue(cfg.HistoryDB),
-		})
+		json.NewEncoder(w).Encode(map[string]any{
+			"items":   items,
+			"count":   len(items),
+			"pending": countPendingQueue(cfg.HistoryDB),
+		})
+	})
+
+	mux.HandleFunc("/queue/", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/queue/")
+
+		// POST /queue/{id}/retry
+		if strings.HasSuffix(path, "/retry") {
+			if r.Method != http.MethodPost {
+				http.Error(w, "POST only", http.StatusMethodNotAllowed)
+				return
+			}
+			idStr := strings.TrimSuffix(path, "/retry")
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+				return
+			}
+			item := queryQueueItem(cfg.HistoryDB, id)
+			if item == nil {
+				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+				return
+			}
+			if item.Status != "pending" && item.Status != "failed" {
+				jsonError(w, fmt.Sprintf("item status is %q, must be pending or failed", item.Status), http.StatusConflict)
+				return
+			}
+
+			// Deserialize and re-dispatch.
+			var task Task
+			if err := json.Unmarshal([]byte(item.TaskJSON), &task); err != nil {
+				http.Error(w, `{"error":"invalid task in queue"}`, http.StatusInternalServerError)
+				return
+			}
+			task.ID = newUUID()
+			task.SessionID = newUUID()
+			task.Source = "queue-retry:" + task.Source
+
+			updateQueueStatus(cfg.HistoryDB, id, "processing", "")
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "queue.retry", "http", fmt.Sprintf("queueId=%d", id), clientIP(r))
+
+			go func() {
+				ctx := trace.WithID(context.Background(), trace.NewID("queue"))
+				result := runSingleTask(ctx, cfg, task, sem, childSem, item.AgentName)
+				if result.Status == "success" {
+					updateQueueStatus(cfg.HistoryDB, id, "completed", "")
+				} else {
+					incrementQueueRetry(cfg.HistoryDB, id, "failed", result.Error)
+				}
+				startAt := time.Now().Add(-time.Duration(result.DurationMs) * time.Millisecond)
+				recordHistory(cfg.HistoryDB, task.ID, task.Name, task.Source, item.AgentName, task, result,
+					startAt.Format(time.RFC3339), time.Now().Format(time.RFC3339), result.OutputFile)
+			}()
+
+			w.Write([]byte(fmt.Sprintf(`{"status":"retrying","taskId":%q}`, task.ID)))
+			return
+		}
+
+		// GET, PATCH or DELETE /queue/{id}
+		id, err := strconv.Atoi(path)
+		if err != nil {
+			http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			item := queryQueueItem(cfg.HistoryDB, id)
+			if item == nil {
+				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(item)
+
+		case http.MethodDelete:
+			item := queryQueueItem(cfg.HistoryDB, id)
+			if item == nil {
+				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+				return
+			}
+			if err := deleteQueueItem(cfg.HistoryDB, id); err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "queue.delete", "http", fmt.Sprintf("queueId=%d", id), clientIP(r))
+			w.Write([]byte(`{"status":"deleted"}`))
+
+		case http.MethodPatch:
+			// PATCH /queue/{id} — reprioritize or reschedule a pending item.
+			var body struct {
+				Priority  *int    `json:"priority"`
+				NotBefore *string `json:"notBefore"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			item := queryQueueItem(cfg.HistoryDB, id)
+			if item == nil {
+				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+				return
+			}
+			if item.Status != "pending" {
+				jsonError(w, fmt.Sprintf("item status is %q, must be pending", item.Status), http.StatusConflict)
+				return
+			}
+			priority, notBefore := item.Priority, item.NotBefore
+			if body.Priority != nil {
+				priority = *body.Priority
+			}
+			if body.NotBefore != nil {
+				notBefore = ""
+				if *body.NotBefore != "" {
+					t, err := time.Parse(time.RFC3339, *body.NotBefore)
+					if err != nil {
+						jsonError(w, "notBefore must be RFC3339", http.StatusBadRequest)
+						return
+					}
+					notBefore = t.Local().Format(time.RFC3339)
+				}
+			}
+			if err := rescheduleQueueItem(cfg.HistoryDB, id, priority, notBefore); err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "queue.reschedule", "http",
+				fmt.Sprintf("queueId=%d priority=%d notBefore=%s", id, priority, notBefore), clientIP(r))
+			json.NewEncoder(w).Encode(queryQueueItem(cfg.HistoryDB, id))
+
+		default:
+			http.Error(w, "GET, PATCH or DELETE only", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// --- Response Cache ---
+	// GET /cache — hit/miss stats and entries (?agent=&prompt=&limit=).
+	// DELETE /cache — invalidate everything, or entries matching ?agent= and ?prompt= (prefix).
+	mux.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		if globalResponseCache == nil {
+			jsonError(w, "response cache not available", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		f := respcache.Filter{Agent: q.Get("agent"), Prompt: q.Get("prompt")}
+		switch r.Method {
+		case http.MethodGet:
+			stats, err := globalResponseCache.Stats()
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			entries, err := globalResponseCache.Entries(f, limit)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"stats": stats, "entries": entries})
+		case http.MethodDelete:
+			n, err := globalResponseCache.Invalidate(f)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "cache.invalidate", "http",
+				fmt.Sprintf("agent=%s prompt=%s deleted=%d", f.Agent, truncate(f.Prompt, 50), n), clientIP(r))
+			json.NewEncoder(w).Encode(map[string]any{"deleted": n})
+		default:
+			http.Error(w, `{"error":"GET or DELETE only"}`, http.StatusMethodNotAllowed)
+		}
+	})
+
+	// DELETE /cache/{key} — invalidate one entry.
+	mux.HandleFunc("/cache/", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"DELETE only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if globalResponseCache == nil {
+			jsonError(w, "response cache not available", http.StatusServiceUnavailable)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/cache/")
+		if key == "" {
+			jsonError(w, "key required", http.StatusBadRequest)
+			return
+		}
+		n, err := globalResponseCache.Invalidate(respcache.Filter{Key: key})
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n == 0 {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "cache.invalidate", "http", "key="+key, clientIP(r))
+		json.NewEncoder(w).Encode(map[string]any{"deleted": n})
 	})
 
-	mux.HandleFunc("/queue/", func(w http.ResponseWriter, r *http.Request) {
+	// --- Feedback ---
+	// POST /feedback — {"taskId","score":1|-1|"up"|"down","comment"}: the owner's verdict on a task's result.
+	// GET /feedback — recorded verdicts, newest first (?agent=&score=up|down&since=&limit=).
+	mux.HandleFunc("/feedback", func(w http.ResponseWriter, r *http.Request) {
 		cfg := s.Cfg()
 		w.Header().Set("Content-Type", "application/json")
-		path := strings.TrimPrefix(r.URL.Path, "/queue/")
-
-		// POST /queue/{id}/retry
-		if strings.HasSuffix(path, "/retry") {
-			if r.Method != http.MethodPost {
-				http.Error(w, "POST only", http.StatusMethodNotAllowed)
-				return
+		if cfg.HistoryDB == "" {
+			jsonError(w, "history DB not configured", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			f := feedback.Filter{Agent: q.Get("agent"), Since: q.Get("since")}
+			if v := q.Get("score"); v != "" {
+				score, err := feedback.ParseScore(v)
+				if err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				f.Score = score
 			}
-			idStr := strings.TrimSuffix(path, "/retry")
-			id, err := strconv.Atoi(idStr)
+			if n, err := strconv.Atoi(q.Get("limit")); err == nil {
+				f.Limit = n
+			}
+			entries, err := feedback.List(cfg.HistoryDB, f)
 			if err != nil {
-				http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+				jsonError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			item := queryQueueItem(cfg.HistoryDB, id)
-			if item == nil {
-				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			json.NewEncoder(w).Encode(entries)
+		case http.MethodPost:
+			var body struct {
+				TaskID  string          `json:"taskId"`
+				Score   json.RawMessage `json:"score"`
+				Comment string          `json:"comment"`
+				Source  string          `json:"source"` // "dashboard", "cli"; default "http"
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&body); err != nil || body.TaskID == "" {
+				jsonError(w, "taskId and score required", http.StatusBadRequest)
 				return
 			}
-			if item.Status != "pending" && item.Status != "failed" {
-				jsonError(w, fmt.Sprintf("item status is %q, must be pending or failed", item.Status), http.StatusConflict)
+			if body.Source == "" {
+				body.Source = "http"
+			}
+			score, err := feedback.ParseScore(strings.Trim(string(body.Score), `"`))
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-
-			// Deserialize and re-dispatch.
-			var task Task
-			if err := json.Unmarshal([]byte(item.TaskJSON), &task); err != nil {
-				http.Error(w, `{"error":"invalid task in queue"}`, http.StatusInternalServerError)
+			e, err := recordTaskFeedback(r.Context(), cfg, feedback.Entry{
+				TaskID: body.TaskID, Score: score, Comment: body.Comment, Source: body.Source, Author: clientIP(r),
+			})
+			switch {
+			case errors.Is(err, feedback.ErrUnknownTask):
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			case err != nil:
+				jsonError(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			task.ID = newUUID()
-			task.SessionID = newUUID()
-			task.Source = "queue-retry:" + task.Source
-
-			updateQueueStatus(cfg.HistoryDB, id, "processing", "")
-			audit.Log(cfg.HistoryDB, "queue.retry", "http", fmt.Sprintf("queueId=%d", id), clientIP(r))
-
-			go func() {
-				ctx := trace.WithID(context.Background(), trace.NewID("queue"))
-				result := runSingleTask(ctx, cfg, task, sem, childSem, item.AgentName)
-				if result.Status == "success" {
-					updateQueueStatus(cfg.HistoryDB, id, "completed", "")
-				} else {
-					incrementQueueRetry(cfg.HistoryDB, id, "failed", result.Error)
-				}
-				startAt := time.Now().Add(-time.Duration(result.DurationMs) * time.Millisecond)
-				recordHistory(cfg.HistoryDB, task.ID, task.Name, task.Source, item.AgentName, task, result,
-					startAt.Format(time.RFC3339), time.Now().Format(time.RFC3339), result.OutputFile)
-			}()
+			json.NewEncoder(w).Encode(e)
+		default:
+			http.Error(w, `{"error":"GET or POST only"}`, http.StatusMethodNotAllowed)
+		}
+	})
 
-			w.Write([]byte(fmt.Sprintf(`{"status":"retrying","taskId":%q}`, task.ID)))
+	// --- A/B Experiments ---
+	// GET /stats/ab — configured experiments and per-variant results (?agent=&experiment=).
+	mux.HandleFunc("/stats/ab", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
 			return
 		}
-
-		// GET /queue/{id} or DELETE /queue/{id}
-		id, err := strconv.Atoi(path)
+		if cfg.HistoryDB == "" {
+			jsonError(w, "history DB not configured", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		stats, err := abtest.Stats(cfg.HistoryDB, q.Get("agent"), q.Get("experiment"))
 		if err != nil {
-			http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+			jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			item := queryQueueItem(cfg.HistoryDB, id)
-			if item == nil {
-				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
-				return
+		experiments := map[string]any{}
+		for name, ac := range cfg.Agents {
+			if ac.Experiment != nil && (q.Get("agent") == "" || q.Get("agent") == name) {
+				experiments[name] = ac.Experiment
 			}
-			json.NewEncoder(w).Encode(item)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"experiments": experiments, "variants": stats})
+	})
 
-		case http.MethodDelete:
-			item := queryQueueItem(cfg.HistoryDB, id)
-			if item == nil {
-				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
-				return
-			}
-			if err := deleteQueueItem(cfg.HistoryDB, id); err != nil {
-				jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			audit.Log(cfg.HistoryDB, "queue.delete", "http", fmt.Sprintf("queueId=%d", id), clientIP(r))
-			w.Write([]byte(`{"status":"deleted"}`))
+	// POST /stats/ab/feedback — {"taskId","score":1|-1,"comment"}: the owner's verdict on a task.
+	mux.HandleFunc("/stats/ab/feedback", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			TaskID  string `json:"taskId"`
+			Score   int    `json:"score"`
+			Comment string `json:"comment"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&body); err != nil || body.TaskID == "" {
+			jsonError(w, "taskId and score required", http.StatusBadRequest)
+			return
+		}
+		run, err := abtest.Feedback(cfg.HistoryDB, body.TaskID, body.Score, body.Comment)
+		switch {
+		case errors.Is(err, abtest.ErrUnknownTask):
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(run)
+	})
 
-		default:
-			http.Error(w, "GET or DELETE only", http.StatusMethodNotAllowed)
+	// POST /stats/ab/promote — {"agent","variant"}: adopt the variant's soul and model, ending the experiment.
+	mux.HandleFunc("/stats/ab/promote", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.Cfg()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
 		}
+		var body struct {
+			Agent   string `json:"agent"`
+			Variant string `json:"variant"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&body); err != nil || body.Agent == "" || body.Variant == "" {
+			jsonError(w, "agent and variant required", http.StatusBadRequest)
+			return
+		}
+		configPath := findConfigPath()
+		if configPath == "" {
+			jsonError(w, "config path not found", http.StatusInternalServerError)
+			return
+		}
+		if err := promoteVariant(cfg, configPath, body.Agent, body.Variant); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "ab.promote", "http",
+			fmt.Sprintf("agent=%s variant=%s", body.Agent, body.Variant), clientIP(r))
+		signalSelfReload()
+		json.NewEncoder(w).Encode(map[string]any{"status": "promoted", "agent": body.Agent, "variant": body.Variant})
 	})
 
 	// --- Dispatch ---
@@ -4926,10 +8860,9 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 			http.Error(w, "POST only", http.StatusMethodNotAllowed)
 			return
 		}
-		cfg := s.Cfg()
-
-		// Resolve per-client dispatch state and semaphores.
+		// Resolve per-client config, dispatch state and semaphores.
 		clientID := getClientID(r)
+		cfg := s.cfgForClient(clientID)
 		cState, cSem, cChildSem := s.resolveClientDispatch(clientID)
 
 		// Allow sub-agent dispatches to run concurrently with parent tasks.
@@ -5027,7 +8960,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 			}
 		}
 
-		audit.Log(auditDB, "dispatch", "http",
+		audit.LogCtx(r.Context(), auditDB, "dispatch", "http",
 			fmt.Sprintf("%d tasks (client=%s)", len(tasks), clientID), clientIP(r))
 
 		// Decouple from HTTP request lifecycle so client disconnect doesn't kill in-flight tasks.
@@ -5170,7 +9103,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 			"kind", kind, "url", req.PRURL, "prompt_len", len(task.Prompt), "post_comment", req.PostComment)
 
 		auditDB := s.resolveHistoryDB(cfg, clientID)
-		audit.Log(auditDB, "review", "http",
+		audit.LogCtx(r.Context(), auditDB, "review", "http",
 			fmt.Sprintf("agent=%s url=%s (client=%s)", agent, req.PRURL, clientID), clientIP(r))
 
 		// skipActive=true: reviews don't set cState.active, so dispatch must not enforce it.
@@ -5247,7 +9180,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		if ts, ok := cState.running[id]; ok && ts.cancelFn != nil {
 			ts.cancelFn()
 			cState.mu.Unlock()
-			audit.Log(auditDB, "task.cancel", "http",
+			audit.LogCtx(r.Context(), auditDB, "task.cancel", "http",
 				fmt.Sprintf("id=%s (dispatch)", id), clientIP(r))
 			w.Write([]byte(`{"status":"cancelling"}`))
 			return
@@ -5255,9 +9188,9 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		cState.mu.Unlock()
 
 		// Try cron engine.
-		if cron != nil {
+		if cron := s.cronForClient(clientID); cron != nil {
 			if err := cron.CancelJob(id); err == nil {
-				audit.Log(auditDB, "job.cancel", "http",
+				audit.LogCtx(r.Context(), auditDB, "job.cancel", "http",
 					fmt.Sprintf("id=%s (cron)", id), clientIP(r))
 				w.Write([]byte(`{"status":"cancelling"}`))
 				return
@@ -5324,7 +9257,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		cState.mu.Unlock()
 
 		// From cron engine.
-		if cron != nil {
+		if cron := s.cronForClient(clientID); cron != nil {
 			for _, j := range cron.ListJobs() {
 				if !j.Running {
 					continue
@@ -5457,7 +9390,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 			return
 		}
 
-		audit.Log(cfg.HistoryDB, "file.upload", "http", uploaded.Name, clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "file.upload", "http", uploaded.Name, clientIP(r))
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(uploaded)
 	})
@@ -5489,11 +9422,16 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 				http.Error(w, `{"error":"name and content are required"}`, http.StatusBadRequest)
 				return
 			}
+			if errs := prompttmpl.Validate(body.Name, body.Content, promptLoader(cfg)); len(errs) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"error": "invalid prompt template", "errors": errs})
+				return
+			}
 			if err := writePrompt(cfg, body.Name, body.Content); err != nil {
 				jsonError(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			audit.Log(cfg.HistoryDB, "prompt.create", "http", body.Name, clientIP(r))
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "prompt.create", "http", body.Name, clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok", "name": body.Name})
 
 		default:
@@ -5510,6 +9448,39 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		}
 		w.Header().Set("Content-Type", "application/json")
 
+		// POST /prompts/{name}/render — dry run with {"vars": {...}}.
+		if name, ok := strings.CutSuffix(name, "/render"); ok {
+			if r.Method != http.MethodPost {
+				http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+				return
+			}
+			var body struct {
+				Vars map[string]string `json:"vars"`
+			}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+					return
+				}
+			}
+			content, err := readPrompt(cfg, name)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			res, err := prompttmpl.RenderContent(name, content, promptLoader(cfg), body.Vars)
+			if errs, ok := err.(prompttmpl.Errors); ok {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"error": "cannot render prompt", "errors": errs})
+				return
+			} else if err != nil {
+				jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+
 		switch r.Method {
 		case "GET":
 			content, err := readPrompt(cfg, name)
@@ -5517,14 +9488,21 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 				jsonError(w, err.Error(), http.StatusNotFound)
 				return
 			}
-			json.NewEncoder(w).Encode(map[string]string{"name": name, "content": content})
+			resp := map[string]any{"name": name, "content": content}
+			if t, _ := prompttmpl.Parse(name, content); t != nil {
+				resp["meta"] = t.Meta
+			}
+			if errs := prompttmpl.Validate(name, content, promptLoader(cfg)); len(errs) > 0 {
+				resp["errors"] = errs
+			}
+			json.NewEncoder(w).Encode(resp)
 
 		case "DELETE":
 			if err := deletePrompt(cfg, name); err != nil {
 				jsonError(w, err.Error(), http.StatusNotFound)
 				return
 			}
-			audit.Log(cfg.HistoryDB, "prompt.delete", "http", name, clientIP(r))
+			audit.LogCtx(r.Context(), cfg.HistoryDB, "prompt.delete", "http", name, clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
 		default:
@@ -5610,7 +9588,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 				jsonError(w, err.Error(), http.StatusNotFound)
 				return
 			}
-			audit.Log(actionAuditDB, "task.retry", "http",
+			audit.LogCtx(r.Context(), actionAuditDB, "task.retry", "http",
 				fmt.Sprintf("original=%s status=%s", taskID, result.Status), clientIP(r))
 			json.NewEncoder(w).Encode(result)
 
@@ -5624,7 +9602,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 				jsonError(w, err.Error(), status)
 				return
 			}
-			audit.Log(actionAuditDB, "task.reroute", "http",
+			audit.LogCtx(r.Context(), actionAuditDB, "task.reroute", "http",
 				fmt.Sprintf("original=%s role=%s status=%s", taskID, result.Route.Agent, result.Task.Status), clientIP(r))
 			json.NewEncoder(w).Encode(result)
 
@@ -5656,12 +9634,35 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 		json.NewEncoder(w).Encode(route)
 	})
 
+	// Dry-run routing: which rule would handle a message, without running
+	// anything or calling the LLM classifier.
+	mux.HandleFunc("/route/test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var req RouteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+		if req.Source == "" {
+			req.Source = "http"
+		}
+		cfg := s.cfgForClient(getClientID(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"route": explainRoute(cfg, req),
+			"rules": len(routeRules(cfg)),
+		})
+	})
+
 	mux.HandleFunc("/route/", func(w http.ResponseWriter, r *http.Request) {
 		// Handle /route/classify separately (already registered above, but paths
 		// with trailing content after /route/ that aren't "classify" are async IDs).
 		path := strings.TrimPrefix(r.URL.Path, "/route/")
-		if path == "classify" {
-			return // handled by /route/classify handler
+		if path == "classify" || path == "test" {
+			return // handled by /route/classify and /route/test handlers
 		}
 
 		if r.Method != http.MethodGet {
@@ -5696,7 +9697,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 	})
 
 	mux.HandleFunc("/route", func(w http.ResponseWriter, r *http.Request) {
-		cfg := s.Cfg()
+		cfg := s.cfgForClient(getClientID(r))
 		if r.Method == http.MethodGet {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]any{
@@ -5723,7 +9724,7 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 			http.Error(w, `{"error":"prompt is required"}`, http.StatusBadRequest)
 			return
 		}
-		audit.Log(cfg.HistoryDB, "route.request", "http",
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "route.request", "http",
 			truncate(body.Prompt, 100), clientIP(r))
 
 		if body.Async {
@@ -5784,11 +9785,80 @@ func (s *Server) registerDispatchRoutes(mux *http.ServeMux) {
 // IncomingWebhookResult is the response from processing an incoming webhook.
 type IncomingWebhookResult struct {
 	Name     string `json:"name"`
-	Status   string `json:"status"`   // "accepted", "filtered", "error", "disabled"
+	Status   string `json:"status"`   // "accepted", "filtered", "error", "disabled", "handover"; sync: "done", "pending"
 	TaskID   string `json:"taskId,omitempty"`
 	Agent    string `json:"agent,omitempty"`
 	Workflow string `json:"workflow,omitempty"`
 	Message  string `json:"message,omitempty"`
+
+	// Sync webhooks: the finished task or workflow run.
+	TaskStatus string  `json:"taskStatus,omitempty"` // "success", "error", "timeout", ...
+	Output     string  `json:"output,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	CostUSD    float64 `json:"costUsd,omitempty"`
+	DurationMs int64   `json:"durationMs,omitempty"`
+
+	payload map[string]any    // request payload, for the response template
+	vars    map[string]any    // transform variables, for the response template
+	steps   map[string]string // workflow step outputs by step ID
+}
+
+// writeIncomingWebhookResult writes the reply to a /hooks/ request. A finished
+// sync webhook with a response template gets the rendered template; anything
+// else gets the result as JSON.
+func writeIncomingWebhookResult(w http.ResponseWriter, whCfg IncomingWebhookConfig, result IncomingWebhookResult) {
+	code := http.StatusOK
+	switch result.Status {
+	case "error":
+		code = http.StatusBadRequest
+	case "disabled":
+		code = http.StatusNotFound
+	case "pending":
+		code = http.StatusAccepted
+	case "done":
+		rc := whCfg.Response
+		if rc == nil {
+			rc = &IncomingWebhookResponse{}
+		}
+		if result.TaskStatus != "success" {
+			code = http.StatusInternalServerError
+			if rc.ErrorStatus != 0 {
+				code = rc.ErrorStatus
+			}
+		} else if rc.Status != 0 {
+			code = rc.Status
+		}
+		if rc.Body != "" {
+			ct := rc.ContentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			res := map[string]any{
+				"status":     result.TaskStatus,
+				"output":     result.Output,
+				"error":      result.Error,
+				"taskId":     result.TaskID,
+				"agent":      result.Agent,
+				"workflow":   result.Workflow,
+				"costUsd":    result.CostUSD,
+				"durationMs": float64(result.DurationMs),
+			}
+			if result.steps != nil {
+				steps := make(map[string]any, len(result.steps))
+				for id, out := range result.steps {
+					steps[id] = out
+				}
+				res["steps"] = steps
+			}
+			w.Header().Set("Content-Type", ct)
+			w.WriteHeader(code)
+			io.WriteString(w, webhook.RenderResponse(rc.Body, result.payload, result.vars, res, strings.Contains(ct, "json")))
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(result)
 }
 
 // --- Signature Verification (delegated to internal/messaging/webhook) ---
@@ -5856,7 +9926,7 @@ func handleIncomingWebhook(ctx context.Context, cfg *Config, name string, r *htt
 	// Verify signature.
 	if !verifyWebhookSignature(r, body, whCfg.Secret) {
 		log.Warn("incoming webhook signature mismatch", "name", name)
-		audit.Log(cfg.HistoryDB, "webhook.incoming.auth_fail", "http", name, clientIP(r))
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "webhook.incoming.auth_fail", "http", name, clientIP(r))
 		return IncomingWebhookResult{
 			Name: name, Status: "error",
 			Message: "signature verification failed",
@@ -5872,31 +9942,90 @@ func handleIncomingWebhook(ctx context.Context, cfg *Config, name string, r *htt
 		}
 	}
 
+	// Extract transform variables. A failed expression leaves its variable unset.
+	vars, err := webhook.Transform(whCfg.Transform, payload)
+	if err != nil {
+		log.WarnCtx(ctx, "incoming webhook transform failed", "name", name, "error", err)
+	}
+
 	// Apply filter.
-	if !evaluateFilter(whCfg.Filter, payload) {
+	if !webhook.EvaluateFilterVars(whCfg.Filter, payload, vars) {
 		log.DebugCtx(ctx, "incoming webhook filtered out", "name", name, "filter", whCfg.Filter)
 		return IncomingWebhookResult{Name: name, Status: "filtered"}
 	}
 
-	// Build prompt from template.
-	prompt := whCfg.Template
-	if prompt != "" {
-		prompt = expandPayloadTemplate(prompt, payload)
-	} else {
-		// Default: pretty-print the entire payload.
-		b, _ := json.MarshalIndent(payload, "", "  ")
-		prompt = fmt.Sprintf("Process this webhook event (%s):\n\n%s", name, string(b))
+	prompt := buildWebhookPrompt(name, whCfg, payload, vars)
+
+	// Handover: while the owner has this webhook's conversation, hold dispatch
+	// and relay the event to them instead.
+	if reply, handled := globalHandoverDesk.Intercept(handover.Inbound{
+		Platform: "webhook",
+		ChatID:   name,
+		UserName: name,
+		Text:     prompt,
+	}); handled {
+		audit.LogCtx(r.Context(), cfg.HistoryDB, "webhook.incoming.handover", "http", name, clientIP(r))
+		return IncomingWebhookResult{Name: name, Status: "handover", Message: reply}
 	}
 
 	log.InfoCtx(ctx, "incoming webhook accepted", "name", name, "agent", whCfg.Agent)
-	audit.Log(cfg.HistoryDB, "webhook.incoming", "http",
+	audit.LogCtx(r.Context(), cfg.HistoryDB, "webhook.incoming", "http",
 		fmt.Sprintf("name=%s agent=%s", name, whCfg.Agent), clientIP(r))
 
 	// Trigger workflow or dispatch.
+	var result IncomingWebhookResult
 	if whCfg.Workflow != "" {
-		return triggerWebhookWorkflow(ctx, cfg, name, whCfg, payload, prompt, state, sem, childSem)
+		result = triggerWebhookWorkflow(ctx, cfg, name, whCfg, payload, vars, prompt, state, sem, childSem)
+	} else {
+		result = triggerWebhookDispatch(ctx, cfg, name, whCfg, prompt, state, sem, childSem)
+	}
+	result.payload = payload
+	result.vars = vars
+	return result
+}
+
+// buildWebhookPrompt expands the webhook's template with the payload and
+// transform variables. Without a template, the prompt is the whole payload.
+func buildWebhookPrompt(name string, whCfg IncomingWebhookConfig, payload, vars map[string]any) string {
+	if whCfg.Template != "" {
+		return webhook.ExpandTemplateVars(whCfg.Template, payload, vars)
+	}
+	b, _ := json.MarshalIndent(payload, "", "  ")
+	return fmt.Sprintf("Process this webhook event (%s):\n\n%s", name, string(b))
+}
+
+// IncomingWebhookPreview is what a webhook would do with a sample payload.
+type IncomingWebhookPreview struct {
+	Name     string         `json:"name"`
+	Vars     map[string]any `json:"vars"`
+	Errors   []string       `json:"errors,omitempty"` // transform expressions that failed
+	Filter   string         `json:"filter,omitempty"`
+	Matched  bool           `json:"matched"` // whether the filter lets the payload through
+	Prompt   string         `json:"prompt"`
+	Agent    string         `json:"agent,omitempty"`
+	Workflow string         `json:"workflow,omitempty"`
+}
+
+// previewIncomingWebhook runs the transform, filter and template of a webhook
+// on a sample payload without dispatching anything.
+func previewIncomingWebhook(name string, whCfg IncomingWebhookConfig, payload map[string]any) IncomingWebhookPreview {
+	vars, err := webhook.Transform(whCfg.Transform, payload)
+	p := IncomingWebhookPreview{
+		Name:     name,
+		Vars:     vars,
+		Filter:   whCfg.Filter,
+		Matched:  webhook.EvaluateFilterVars(whCfg.Filter, payload, vars),
+		Prompt:   buildWebhookPrompt(name, whCfg, payload, vars),
+		Agent:    whCfg.Agent,
+		Workflow: whCfg.Workflow,
 	}
-	return triggerWebhookDispatch(ctx, cfg, name, whCfg, prompt, state, sem, childSem)
+	if p.Vars == nil {
+		p.Vars = map[string]any{}
+	}
+	if err != nil {
+		p.Errors = strings.Split(err.Error(), "\n")
+	}
+	return p
 }
 
 // triggerWebhookDispatch dispatches a task to the specified agent.
@@ -5915,9 +10044,11 @@ func triggerWebhookDispatch(ctx context.Context, cfg *Config, name string, whCfg
 		trace.WithID(context.Background(), trace.IDFromContext(ctx)),
 		10*time.Minute,
 	)
+	done := make(chan TaskResult, 1)
 	go func() {
 		defer bgCancel()
 		result := runSingleTask(bgCtx, cfg, task, sem, childSem, whCfg.Agent)
+		done <- result
 
 		// Record history.
 		start := time.Now().Add(-time.Duration(result.DurationMs) * time.Millisecond)
@@ -5931,17 +10062,49 @@ func triggerWebhookDispatch(ctx context.Context, cfg *Config, name string, whCfg
 			"status", result.Status, "cost", result.CostUSD)
 	}()
 
-	return IncomingWebhookResult{
+	res := IncomingWebhookResult{
 		Name:   name,
 		Status: "accepted",
 		TaskID: task.ID,
 		Agent:   whCfg.Agent,
 	}
+	if !whCfg.IsSync() {
+		return res
+	}
+	select {
+	case tr := <-done:
+		res.Status = "done"
+		res.TaskStatus = tr.Status
+		res.Output = tr.Output
+		res.Error = tr.Error
+		res.CostUSD = tr.CostUSD
+		res.DurationMs = tr.DurationMs
+	case <-waitWebhookResult(ctx, whCfg):
+		res.Status = "pending"
+		res.Message = "still running after maxWait; see task history for the result"
+	}
+	return res
+}
+
+// waitWebhookResult is closed when a sync webhook should stop waiting: after
+// maxWait, or when the caller goes away. The task keeps running either way.
+func waitWebhookResult(ctx context.Context, whCfg IncomingWebhookConfig) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		t := time.NewTimer(whCfg.MaxWaitDuration())
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+		close(ch)
+	}()
+	return ch
 }
 
 // triggerWebhookWorkflow loads and executes a workflow.
 func triggerWebhookWorkflow(ctx context.Context, cfg *Config, name string, whCfg IncomingWebhookConfig,
-	payload map[string]any, prompt string, state *dispatchState, sem, childSem chan struct{}) IncomingWebhookResult {
+	payload, transformVars map[string]any, prompt string, state *dispatchState, sem, childSem chan struct{}) IncomingWebhookResult {
 
 	wf, err := loadWorkflowByName(cfg, whCfg.Workflow)
 	if err != nil {
@@ -5971,25 +10134,56 @@ func triggerWebhookWorkflow(ctx context.Context, cfg *Config, name string, whCfg
 			vars["payload_"+k] = fmt.Sprintf("%v", val)
 		}
 	}
+	// Transform variables, as vars_<name>.
+	for k, v := range transformVars {
+		if v != nil {
+			vars["vars_"+k] = webhook.FormatValue(v)
+		}
+	}
 
 	// Run async.
 	bgCtx, bgCancel := context.WithTimeout(
 		trace.WithID(context.Background(), trace.IDFromContext(ctx)),
 		10*time.Minute,
 	)
+	done := make(chan *WorkflowRun, 1)
 	go func() {
 		defer bgCancel()
 		run := executeWorkflow(bgCtx, cfg, wf, vars, state, sem, childSem)
 		log.InfoCtx(bgCtx, "incoming webhook workflow done", "name", name,
 			"workflow", whCfg.Workflow, "status", run.Status, "cost", run.TotalCost)
+		done <- run
 	}()
 
-	return IncomingWebhookResult{
+	res := IncomingWebhookResult{
 		Name:     name,
 		Status:   "accepted",
 		Agent:     whCfg.Agent,
 		Workflow: whCfg.Workflow,
 	}
+	if !whCfg.IsSync() {
+		return res
+	}
+	select {
+	case run := <-done:
+		res.Status = "done"
+		res.TaskStatus = run.Status
+		res.Error = run.Error
+		res.CostUSD = run.TotalCost
+		res.DurationMs = run.DurationMs
+		// The output is that of the last step that produced one.
+		res.steps = make(map[string]string)
+		for _, step := range wf.Steps {
+			if sr, ok := run.StepResults[step.ID]; ok && sr.Output != "" {
+				res.steps[step.ID] = sr.Output
+				res.Output = sr.Output
+			}
+		}
+	case <-waitWebhookResult(ctx, whCfg):
+		res.Status = "pending"
+		res.Message = "still running after maxWait; see the workflow run for the result"
+	}
+	return res
 }
 
 //go:embed docs/apidocs.html
@@ -6137,6 +10331,34 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/api/tasks/sync"] = map[string]any{
+		"get": opGet("Task sync status", "Core",
+			"State of the taskboard sync with Jira or Linear: last run, linked tickets, and links with errors or conflicts.",
+			nil,
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"provider":  prop("string", "jira or linear"),
+				"lastRun":   prop("string", "RFC3339 time of the last sync"),
+				"lastError": prop("string", "Why the last sync stopped, if it did"),
+				"last":      map[string]any{"type": "object", "description": "Counts from the last sync"},
+				"linked":    prop("integer", "Tasks linked to a ticket"),
+				"errors":    schemaArray(map[string]any{"type": "object"}),
+				"conflicts": schemaArray(map[string]any{"type": "object"}),
+			}}),
+			resp401(),
+		),
+		"post": opPost("Sync tasks now", "Core",
+			"File new tasks as tickets and copy title and status changes both ways.",
+			nil,
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"created":   prop("integer", "Tasks filed as tickets"),
+				"pushed":    prop("integer", "Tickets updated from their task"),
+				"pulled":    prop("integer", "Tasks updated from their ticket"),
+				"conflicts": prop("integer", "Links changed on both sides"),
+			}}),
+			resp401(),
+		),
+	}
+
 	// ---- Health ----
 
 	paths["/healthz"] = map[string]any{
@@ -6174,18 +10396,61 @@ func buildPaths() map[string]any {
 		),
 	}
 
-	paths["/history/{id}"] = map[string]any{
-		"get": opGet("Get history entry", "History",
-			"Get a single execution history entry by ID.",
-			[]map[string]any{pathParam("id", "integer", "History entry ID")},
-			resp200(ref("JobRun")),
-			resp401(), resp404(),
+	paths["/history/{id}"] = map[string]any{
+		"get": opGet("Get history entry", "History",
+			"Get a single execution history entry by ID.",
+			[]map[string]any{pathParam("id", "integer", "History entry ID")},
+			resp200(ref("JobRun")),
+			resp401(), resp404(),
+		),
+	}
+
+	paths["/history/cost"] = map[string]any{
+		"get": opGet("History cost summary", "History",
+			"Cost today, this week and this month for the requesting client.",
+			nil,
+			resp200(map[string]any{"type": "object"}),
+			resp401(),
+		),
+	}
+
+	paths["/history/fails"] = map[string]any{
+		"get": opGet("Recent failures", "History",
+			"Non-success runs within the last N days.",
+			[]map[string]any{
+				queryParam("job_id", "string", "Filter by job ID"),
+				queryParam("days", "integer", "Look-back window in days (default 3)"),
+				queryParam("limit", "integer", "Maximum results (default 20)"),
+			},
+			resp200(map[string]any{"type": "array", "items": ref("JobRun")}),
+			resp401(),
+		),
+	}
+
+	paths["/history/streaks"] = map[string]any{
+		"get": opGet("Failure streaks", "History",
+			"Jobs whose most recent runs failed consecutively.",
+			[]map[string]any{queryParam("threshold", "integer", "Minimum streak length (default 3)")},
+			resp200(map[string]any{"type": "array", "items": map[string]any{"type": "object"}}),
+			resp401(),
+		),
+	}
+
+	paths["/history/trace/{jobId}"] = map[string]any{
+		"get": opGet("Job trace", "History",
+			"Most recent runs of a job, newest first.",
+			[]map[string]any{
+				pathParam("jobId", "string", "Job ID"),
+				queryParam("limit", "integer", "Maximum results (default 10)"),
+			},
+			resp200(map[string]any{"type": "array", "items": ref("JobRun")}),
+			resp401(),
 		),
 	}
 
 	paths["/stats/cost"] = map[string]any{
 		"get": opGet("Cost statistics", "Stats",
-			"Get cost statistics summary (today, week, month, total).",
+			"Get cost statistics summary (today, week, month, total). Totals include costs reported by MCP servers and plugins for their tool calls; tools breaks them down per server and tool over 30 days.",
 			nil,
 			resp200(map[string]any{"type": "object"}),
 			resp401(),
@@ -6201,6 +10466,37 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/timeline"] = map[string]any{
+		"get": opGet("Day timeline", "Stats",
+			"Replay a day: the tasks run, notifications sent, memory written, lessons drawn and reminders fired, in chronological order, with a summary of counts and costs (model spend, tool spend and spend per agent). Memory writes are memory files last modified that day.",
+			[]map[string]any{
+				queryParam("date", "string", "YYYY-MM-DD, today or yesterday (default today, local time)"),
+				queryParam("format", "string", "json (default) or markdown, the daily note section"),
+			},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"date":    prop("string", "YYYY-MM-DD"),
+				"summary": map[string]any{"type": "object"},
+				"events": schemaArray(map[string]any{"type": "object", "properties": map[string]any{
+					"time":    prop("string", "RFC3339, local time"),
+					"kind":    map[string]any{"type": "string", "enum": []string{"task", "notification", "memory", "lesson", "reminder"}},
+					"title":   prop("string", "Task name, notification text, memory key, lesson or reminder text"),
+					"status":  prop("string", "Task status, notification priority, memory created/updated or reminder status"),
+					"costUsd": prop("number", "Task cost"),
+				}}),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/timeline/export"] = map[string]any{
+		"post": opPost("Export day timeline", "Stats",
+			"Write the day's timeline into its daily note (dailyNotes.enabled), replacing an earlier timeline section.",
+			nil,
+			resp200(map[string]any{"type": "object"}),
+			resp401(),
+		),
+	}
+
 	paths["/stats/metrics"] = map[string]any{
 		"get": opGet("Performance metrics", "Stats",
 			"Get performance metrics (success rate, latency, throughput) per agent.",
@@ -6231,6 +10527,79 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/feedback"] = map[string]any{
+		"get": opGet("List task feedback", "Stats",
+			"The owner's thumbs up/down verdicts on task results, newest first.",
+			[]map[string]any{
+				queryParam("agent", "string", "Filter by agent"),
+				queryParam("score", "string", "up or down"),
+				queryParam("since", "string", "RFC 3339 time; verdicts updated at or after it"),
+				queryParam("limit", "integer", "Max entries (default 50, max 1000)"),
+			},
+			resp200(schemaArray(map[string]any{"type": "object"})),
+			resp400(), resp401(),
+		),
+		"post": opPost("Rate a task result", "Stats",
+			"Record the owner's verdict on a task: score 1/\"up\" or -1/\"down\". A later verdict replaces the earlier one; tasks served by an A/B variant are rated there too.",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"taskId":  prop("string", "Task ID"),
+					"score":   prop("string", "1, -1, \"up\" or \"down\""),
+					"comment": prop("string", "Optional comment"),
+					"source":  prop("string", "Where the verdict was given (default \"http\")"),
+				},
+			}),
+			resp200(map[string]any{"type": "object"}),
+			resp400(), resp401(), resp404(),
+		),
+	}
+	paths["/stats/ab"] = map[string]any{
+		"get": opGet("A/B experiment results", "Stats",
+			"Configured agent experiments and per-variant success rate, cost, latency and owner feedback.",
+			[]map[string]any{
+				queryParam("agent", "string", "Filter by agent"),
+				queryParam("experiment", "string", "Filter by experiment name"),
+			},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"experiments": prop("object", "Experiment config per agent"),
+				"variants":    prop("array", "Results per agent, experiment and variant"),
+			}}),
+			resp401(),
+		),
+	}
+	paths["/stats/ab/feedback"] = map[string]any{
+		"post": opPost("Rate a task served by a variant", "Stats",
+			"Record the owner's verdict on a task: score 1 (good) or -1 (bad).",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"taskId":  prop("string", "Task ID"),
+					"score":   prop("integer", "1 or -1"),
+					"comment": prop("string", "Optional comment"),
+				},
+			}),
+			resp200(map[string]any{"type": "object"}),
+			resp400(), resp401(), resp404(),
+		),
+	}
+	paths["/stats/ab/promote"] = map[string]any{
+		"post": opPost("Promote an A/B variant", "Stats",
+			"Adopt a variant's soul file and model as the agent's own and remove the experiment (admin).",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"agent":   prop("string", "Agent name"),
+					"variant": prop("string", "Variant to promote"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"status": prop("string", ""),
+			}}),
+			resp400(), resp401(),
+		),
+	}
+
 	// ---- Sessions ----
 
 	paths["/sessions"] = map[string]any{
@@ -6257,9 +10626,11 @@ func buildPaths() map[string]any {
 			reqBody(map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"role":   prop("string", "Agent name"),
-					"title":  prop("string", "Session title"),
-					"source": prop("string", "Source identifier"),
+					"role":      prop("string", "Agent name"),
+					"title":     prop("string", "Session title"),
+					"source":    prop("string", "Source identifier"),
+					"agents":    schemaArray(prop("string", "")),
+					"moderator": prop("string", "Group session agent that picks who answers un-mentioned messages"),
 				},
 			}),
 			resp200(ref("Session")),
@@ -6290,16 +10661,40 @@ func buildPaths() map[string]any {
 			reqBody(map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"content": prop("string", "Message content"),
-					"model":   prop("string", "Override model for this message"),
+					"prompt": prop("string", "Message content"),
+					"model":  prop("string", "Override model for this message"),
+					"async":  prop("boolean", "Return 202 with the task ID instead of waiting; the reply streams on /sessions/{id}/stream"),
 				},
-				"required": []string{"content"},
+				"required": []string{"prompt"},
 			}),
 			resp200(ref("SessionMessage")),
 			resp400(), resp401(), resp404(),
 		),
 	}
 
+	participants := map[string]any{"type": "object", "properties": map[string]any{
+		"sessionId": prop("string", ""),
+		"agents":    schemaArray(prop("string", "")),
+		"moderator": prop("string", ""),
+	}}
+	paths["/sessions/{id}/participants"] = map[string]any{
+		"get": opGet("Get session participants", "Sessions",
+			"List the agents taking part in a group session. Empty for a single-agent session.",
+			[]map[string]any{pathParam("id", "string", "Session ID")},
+			resp200(participants),
+			resp401(),
+		),
+		"put": map[string]any{
+			"tags":    []string{"Sessions"},
+			"summary": "Set session participants",
+			"description": "Replace the agents taking part in a session. Messages @-mention agents by name; " +
+				"otherwise the moderator, if any, picks who answers. An empty list ends the group.",
+			"parameters":  []map[string]any{pathParam("id", "string", "Session ID")},
+			"requestBody": reqBody(participants),
+			"responses":   mergeResponses(resp200(participants), resp400(), resp401(), resp404()),
+		},
+	}
+
 	paths["/sessions/{id}/compact"] = map[string]any{
 		"post": opPost("Compact session", "Sessions",
 			"Compact session history to reduce token usage.",
@@ -6370,6 +10765,37 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/validate"] = map[string]any{
+		"post": opPost("Validate an editor draft", "Workflows",
+			"Check a soul, workflow or skill draft without saving it. Returns diagnostics with line and column positions (unknown variables, agents, skills and tools, invalid trigger schedules, truncated souls) and an estimate of the prompt tokens the draft adds. Diagnostics are returned with 200; only an unknown kind or malformed request is a 400.",
+			reqBody(map[string]any{
+				"type":     "object",
+				"required": []string{"kind", "content"},
+				"properties": map[string]any{
+					"kind":    map[string]any{"type": "string", "enum": validate.Kinds},
+					"content": prop("string", "Draft text: SOUL.md, workflow JSON or SKILL.md"),
+					"agent":   prop("string", "Agent the soul belongs to (optional)"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"kind":  prop("string", "Kind checked"),
+				"valid": prop("boolean", "True when no diagnostic is an error"),
+				"diagnostics": schemaArray(map[string]any{"type": "object", "properties": map[string]any{
+					"severity":  map[string]any{"type": "string", "enum": []string{"error", "warning", "info"}},
+					"code":      prop("string", "Stable identifier, e.g. unknown-variable"),
+					"message":   prop("string", ""),
+					"line":      prop("integer", "1-based line; omitted when the problem is outside the draft"),
+					"column":    prop("integer", "1-based column in characters"),
+					"endLine":   prop("integer", ""),
+					"endColumn": prop("integer", ""),
+					"path":      prop("string", "JSON path of the workflow field, e.g. steps[1].agent"),
+				}}),
+				"tokens": prop("integer", "Estimated prompt tokens"),
+			}}),
+			resp400(), resp401(),
+		),
+	}
+
 	paths["/workflows/{name}/run"] = map[string]any{
 		"post": opPost("Run workflow", "Workflows",
 			"Execute a workflow. Supports live, dry-run, and shadow modes.",
@@ -6412,6 +10838,27 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/workflow-runs/{id}/diagram"] = map[string]any{
+		"get": opGet("Get workflow run diagram", "Workflows",
+			"Render a run's steps, handoffs, timings and statuses as a Mermaid flowchart or a Graphviz digraph. Set download=1 to get it as a file.",
+			[]map[string]any{
+				pathParam("id", "string", "Workflow run ID"),
+				queryParam("format", "string", "mermaid (default) or dot"),
+				queryParam("download", "string", "Any value: send as an attachment"),
+			},
+			map[string]any{
+				"200": map[string]any{
+					"description": "Diagram source",
+					"content": map[string]any{
+						"text/vnd.mermaid":  map[string]any{"schema": map[string]any{"type": "string"}},
+						"text/vnd.graphviz": map[string]any{"schema": map[string]any{"type": "string"}},
+					},
+				},
+			},
+			resp400(), resp401(), resp404(),
+		),
+	}
+
 	// ---- Knowledge ----
 
 	paths["/knowledge"] = map[string]any{
@@ -6435,6 +10882,15 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/api/knowledge/watch"] = map[string]any{
+		"get": opGet("Knowledge watch status", "Knowledge",
+			"Directories watched by knowledgeWatch, their file counts and the latest changes, newest first. 503 when knowledgeWatch is disabled.",
+			nil,
+			resp200(map[string]any{"type": "object"}),
+			resp401(),
+		),
+	}
+
 	// ---- Infrastructure ----
 
 	paths["/circuits"] = map[string]any{
@@ -6483,6 +10939,15 @@ func buildPaths() map[string]any {
 			resp200(ref("QueueItem")),
 			resp401(), resp404(),
 		),
+		"patch": opPost("Reschedule queue item", "Infrastructure",
+			"Change the priority or earliest drain time of a pending queue item. An empty notBefore clears it.",
+			reqBody(map[string]any{"type": "object", "properties": map[string]any{
+				"priority":  prop("integer", "Priority (higher = sooner)"),
+				"notBefore": prop("string", "Earliest drain time (RFC3339)"),
+			}}),
+			resp200(ref("QueueItem")),
+			resp401(), resp404(), resp409("item not pending"),
+		),
 		"delete": opDelete("Delete queue item", "Infrastructure",
 			"Remove an item from the offline queue.",
 			[]map[string]any{pathParam("id", "integer", "Queue item ID")},
@@ -6505,6 +10970,44 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/cache"] = map[string]any{
+		"get": opGet("Response cache", "Infrastructure",
+			"Hit/miss counts since start, cost saved and cached entries (without output), most recently used first.",
+			[]map[string]any{
+				queryParam("agent", "string", "Only entries of this agent"),
+				queryParam("prompt", "string", "Only entries whose prompt starts with this"),
+				queryParam("limit", "integer", "Maximum entries (default 100)"),
+			},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"stats":   map[string]any{"type": "object", "description": "entries, hits, misses, hitRate, savedUsd, byAgent"},
+				"entries": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+			}}),
+			resp401(),
+		),
+		"delete": opDelete("Invalidate response cache", "Infrastructure",
+			"Delete every cached response, or those matching agent and prompt prefix.",
+			[]map[string]any{
+				queryParam("agent", "string", "Only entries of this agent"),
+				queryParam("prompt", "string", "Only entries whose prompt starts with this"),
+			},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"deleted": prop("integer", "Entries deleted"),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/cache/{key}"] = map[string]any{
+		"delete": opDelete("Invalidate cached response", "Infrastructure",
+			"Delete one cached response by key.",
+			[]map[string]any{pathParam("key", "string", "Cache key")},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"deleted": prop("integer", "Entries deleted"),
+			}}),
+			resp401(), resp404(),
+		),
+	}
+
 	paths["/budget"] = map[string]any{
 		"get": opGet("Budget status", "Infrastructure",
 			"Get current budget utilization across global, agent, and workflow scopes.",
@@ -6536,6 +11039,108 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/emergency"] = map[string]any{
+		"get": opGet("Emergency stop status", "Infrastructure",
+			"Get the emergency stop state and the number of tasks currently running.",
+			nil,
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"state":   prop("object", "Engaged, since, by, reason, cancelled and budgetPaused"),
+				"running": prop("integer", "Tasks currently running"),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/emergency/stop"] = map[string]any{
+		"post": opPost("Engage emergency stop", "Infrastructure",
+			"Cancel every running task and workflow, halt cron, proactive rules and triggers, and pause paid execution until the stop is resumed. Allowed in standby.",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"reason": prop("string", "Why the stop was engaged (audited)"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"status": prop("string", "stopped"),
+				"state":  prop("object", "Emergency stop state"),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/emergency/resume"] = map[string]any{
+		"post": opPost("Resume after emergency stop", "Infrastructure",
+			"Release the emergency stop. A request without confirm returns 428 with a one-time code valid for five minutes; repeat the request with that code to resume.",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"confirm": prop("string", "Confirmation code from the previous 428 response"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"status":       prop("string", "resumed"),
+				"stoppedSince": prop("string", "When the stop was engaged"),
+			}}),
+			resp401(), resp409("emergency stop is not engaged"),
+		),
+	}
+
+	paths["/capabilities"] = map[string]any{
+		"get": opGet("Deployment capabilities", "Health",
+			"What this deployment has, so clients can adapt their UI: versions, HA role, configured channels and integrations, optional features with their endpoints, sign-in methods, and the names of providers, agents, tools, MCP servers and plugins.",
+			nil,
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"version":      prop("string", "Tetora version"),
+				"versions":     prop("object", "tetora, api, mcp, go and os versions"),
+				"role":         prop("string", "active, standby or replica"),
+				"channels":     prop("object", "Chat channel name to configured"),
+				"integrations": prop("object", "Integration name to configured"),
+				"features":     prop("object", "Feature name to {enabled, path}"),
+				"auth":         prop("object", "apiToken, dashboard, sso and totp"),
+				"tools":        schemaArray(prop("string", "Tool name")),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/api/integrations/health"] = map[string]any{
+		"get": opGet("Integration health", "Infrastructure",
+			"Score (successes among recent tool calls) and state of every integration called since start. Integrations whose calls keep failing are disabled for a while and their tools hidden from agents.",
+			nil,
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"enabled":      prop("boolean", "Whether integration health scoring is on"),
+				"integrations": schemaArray(map[string]any{"type": "object"}),
+			}}),
+			resp401(),
+		),
+	}
+
+	paths["/api/integrations/health/enable"] = map[string]any{
+		"get": opGet("Re-enable an integration by link", "Infrastructure",
+			"The link sent when an integration is disabled. Its one-time token replaces API auth.",
+			[]map[string]any{
+				queryParam("name", "string", "Integration name"),
+				queryParam("token", "string", "One-time token from the notification"),
+			},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"status": prop("string", "enabled"),
+			}}),
+		),
+		"post": opPost("Re-enable an integration", "Infrastructure",
+			"Bring a disabled integration back before its time out ends and clear its record.",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": prop("string", "Integration name"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"status": prop("string", "enabled"),
+			}}),
+			resp401(), resp409("integration is not disabled"),
+		),
+	}
+
 	// ---- Cron ----
 
 	paths["/cron"] = map[string]any{
@@ -6547,6 +11152,28 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/cron/parse"] = map[string]any{
+		"post": opPost("Read a schedule phrase", "Cron",
+			"Turn a natural-language schedule (\"every weekday at 9am\", \"in 20 minutes\", \"first Monday of the month\") into a cron expression, without creating a job. Phrases the rule parser cannot read are passed to a small model. POST /cron also accepts the phrase as `when` in place of `schedule`.",
+			reqBody(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"text": prop("string", "Schedule phrase"),
+					"tz":   prop("string", "IANA timezone (default: server local time)"),
+				},
+			}),
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"cron":        prop("string", "5-field cron expression"),
+				"once":        prop("boolean", "True for a one-off time; the job is disabled after it runs"),
+				"at":          prop("string", "One-off time (RFC 3339)"),
+				"summary":     prop("string", "How the phrase was read"),
+				"interpreted": prop("string", "Summary with the cron expression and next run"),
+				"source":      prop("string", "\"rule\" or \"model\""),
+			}}),
+			resp400(), resp401(),
+		),
+	}
+
 	paths["/cron/{id}/trigger"] = map[string]any{
 		"post": opPost("Trigger cron job", "Cron",
 			"Manually trigger a cron job to run immediately.",
@@ -6626,7 +11253,7 @@ func buildPaths() map[string]any {
 
 	paths["/roles/{name}"] = map[string]any{
 		"get": opGet("Get agent", "Agents",
-			"Get a single agent configuration by name.",
+			"Get a single agent configuration by name. Agents with run windows also report schedule and scheduleStatus (allowedNow, nextWindow, deferred count).",
 			[]map[string]any{pathParam("name", "string", "Agent name")},
 			resp200(ref("AgentConfig")),
 			resp401(), resp404(),
@@ -6655,6 +11282,48 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/roles/{name}/simulate"] = map[string]any{
+		"post": map[string]any{
+			"tags":        []string{"Agents"},
+			"summary":     "Simulate an agent",
+			"description": "Run the agent against a prompt in plan-only mode. The agent runs read-only without tools and answers with the tool calls and actions it would take; none are executed. Each step is checked against the agent's tool policy, tool-call budget, trust level and approval gates and gets an outcome: run, approval, observe, denied, over-budget or unknown-tool. trustLevel reviews the plan at another trust level without changing the config. When the agent does not answer with a plan, plan is null and output holds its reply.",
+			"parameters":  []map[string]any{pathParam("name", "string", "Agent name")},
+			"requestBody": reqBody(map[string]any{
+				"type":     "object",
+				"required": []string{"prompt"},
+				"properties": map[string]any{
+					"prompt":     prop("string", "Request to simulate"),
+					"trustLevel": map[string]any{"type": "string", "enum": []string{"observe", "suggest", "auto"}, "description": "Trust level to review the plan at (default: the agent's)"},
+				},
+			}),
+			"responses": mergeResponses(
+				resp200(map[string]any{"type": "object", "properties": map[string]any{
+					"agent":      prop("string", ""),
+					"trustLevel": prop("string", "Trust level the plan was reviewed at"),
+					"model":      prop("string", ""),
+					"plan": map[string]any{"type": "object", "properties": map[string]any{
+						"understanding": prop("string", "What the agent took the request to be"),
+						"steps": schemaArray(map[string]any{"type": "object", "properties": map[string]any{
+							"tool":    prop("string", "Tool it would call"),
+							"input":   map[string]any{"type": "object", "description": "Arguments it would pass"},
+							"action":  prop("string", "Any other action, such as a command or file edit"),
+							"reason":  prop("string", ""),
+							"outcome": map[string]any{"type": "string", "enum": []string{"run", "approval", "observe", "denied", "over-budget", "unknown-tool"}},
+							"note":    prop("string", "Why the step has its outcome"),
+						}}),
+						"response":  prop("string", "Reply it would send if every step succeeded"),
+						"questions": schemaArray(prop("string", "")),
+					}},
+					"outcomes":   map[string]any{"type": "object", "description": "Number of steps per outcome"},
+					"output":     prop("string", "Raw reply when it is not a plan"),
+					"costUsd":    prop("number", ""),
+					"durationMs": prop("integer", ""),
+				}}),
+				resp400(), resp401(), resp404(),
+			),
+		},
+	}
+
 	paths["/roles/archetypes"] = map[string]any{
 		"get": opGet("List agent archetypes", "Agents",
 			"List available agent archetype templates.",
@@ -6769,6 +11438,64 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/audit/export"] = map[string]any{
+		"get": opGet("Audit export status", "Audit",
+			"Delivery counters of the external audit sinks (security.auditExport).",
+			nil,
+			resp200(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"enabled": prop("boolean", "Whether audit export is enabled"),
+					"sinks":   prop("array", "Per-sink sent, failed and dropped counts, spool size and last error"),
+				},
+			}),
+			resp401(),
+		),
+	}
+
+	paths["/api/channels/simulate"] = map[string]any{
+		"post": opPost("Simulate inbound channel message", "Core",
+			"Run a synthetic, signed inbound event through the Slack, LINE or WhatsApp webhook handler to check routing and agent permissions. Platform API calls are recorded instead of sent; the agent only runs when live is true.",
+			reqBody(map[string]any{
+				"type":     "object",
+				"required": []string{"channel", "message"},
+				"properties": map[string]any{
+					"channel": prop("string", "slack, line or whatsapp"),
+					"message": prop("string", "Message text"),
+					"user":    prop("string", "Sender ID (default: a placeholder)"),
+					"chat":    prop("string", "Slack channel or LINE group ID"),
+					"live":    prop("boolean", "Run the routed agent instead of a stub reply"),
+					"timeout": prop("string", "How long to wait for the bot (default 30s, 10m when live)"),
+				},
+			}),
+			resp200(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"status": prop("integer", "Webhook handler HTTP status"),
+					"routes": prop("array", "Agents chosen by the router"),
+					"tasks":  prop("array", "Dispatches with agent, model and permission mode"),
+					"calls":  prop("array", "Recorded platform API calls and reply text"),
+				},
+			}),
+			resp400(), resp401(),
+		),
+	}
+
+	paths["/traces/{id}"] = map[string]any{
+		"get": opGet("Trace timeline", "Audit",
+			"Audit entries, task runs, tool calls, notifications and webhook deliveries recorded under one trace ID (the X-Trace-Id response header), oldest first.",
+			[]map[string]any{pathParam("id", "string", "Trace ID")},
+			resp200(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"traceId": prop("string", "Trace ID"),
+					"events":  schemaArray(ref("TraceEvent")),
+				},
+			}),
+			resp401(), resp404(),
+		),
+	}
+
 	// --- Retention & Data ---
 
 	paths["/retention"] = map[string]any{
@@ -6800,8 +11527,12 @@ func buildPaths() map[string]any {
 
 	paths["/data/export"] = map[string]any{
 		"get": opGet("Export all data", "Data",
-			"Exports all user data as JSON (GDPR right of access). Includes history, sessions, memory, audit log, and reflections.",
-			nil,
+			"Exports all user data as JSON (GDPR right of access). Includes history, sessions, memory, audit log, and reflections. With user, returns a ZIP archive of everything tied to that user profile, with a signed manifest.json.",
+			[]map[string]any{{
+				"name": "user", "in": "query", "required": false,
+				"description": "User profile ID to export",
+				"schema":      map[string]any{"type": "string"},
+			}},
 			resp200(map[string]any{"type": "object", "description": "Full data export"}),
 			resp401(),
 		),
@@ -6822,6 +11553,26 @@ func buildPaths() map[string]any {
 		),
 	}
 
+	paths["/data/erase"] = map[string]any{
+		"delete": opDelete("Erase a user's data", "Data",
+			"Deletes everything tied to a user profile (profile, channels, sessions, files, and life data) in one transaction and returns a signed manifest of the affected rows. Requires X-Confirm-Erase: true header unless dryRun is set.",
+			[]map[string]any{{
+				"name": "user", "in": "query", "required": true,
+				"description": "User profile ID to erase",
+				"schema":      map[string]any{"type": "string"},
+			}, {
+				"name": "dryRun", "in": "query", "required": false,
+				"description": "Only return the manifest of what would be deleted",
+				"schema":      map[string]any{"type": "boolean"},
+			}},
+			resp200(map[string]any{"type": "object", "properties": map[string]any{
+				"manifest":     map[string]any{"type": "object", "description": "Tables, rowids and SHA-256 digests of the erased rows, and the files removed"},
+				"manifestFile": prop("string", "Where the manifest was written"),
+			}}),
+			resp401(),
+		),
+	}
+
 	paths["/backup"] = map[string]any{
 		"get": opGet("Download backup", "Audit",
 			"Download a tar.gz backup of the Tetora data directory.",
@@ -7108,6 +11859,8 @@ func buildComponents() map[string]any {
 			"createdAt":  prop("string", "Created timestamp (RFC3339)"),
 			"updatedAt":  prop("string", "Updated timestamp (RFC3339)"),
 			"error":      prop("string", "Error message"),
+			"notBefore":  prop("string", "Not drained before this time (RFC3339)"),
+			"dedupKey":   prop("string", "Hash of agent and prompt used to fold duplicates"),
 		},
 	}
 
@@ -7240,6 +11993,20 @@ func buildComponents() map[string]any {
 		},
 	}
 
+	schemas["TraceEvent"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":         prop("integer", "Event ID"),
+			"traceId":    prop("string", "Trace ID"),
+			"kind":       prop("string", "http, audit, task, tool, notify or webhook"),
+			"name":       prop("string", "Request, action, task, tool or destination"),
+			"status":     prop("string", "Outcome (HTTP status, task status, sent, error)"),
+			"detail":     prop("string", "Event detail"),
+			"startedAt":  prop("string", "Start time (RFC3339, milliseconds)"),
+			"durationMs": prop("integer", "Span duration; 0 for point events"),
+		},
+	}
+
 	schemas["Error"] = map[string]any{
 		"type": "object",
 		"properties": map[string]any{
@@ -10,14 +10,19 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"tetora/internal/audit"
+	"tetora/internal/httpapi"
+	"tetora/internal/oidc"
 	"tetora/internal/quiet"
 	"tetora/internal/quickaction"
+	"tetora/internal/totp"
 )
 
 // ---------------------------------------------------------------------------
@@ -220,9 +225,36 @@ func TestClientIP_MultipleIPs(t *testing.T) {
 		Header:     http.Header{"X-Forwarded-For": []string{"203.0.113.50, 70.41.3.18, 150.172.238.178"}},
 		RemoteAddr: "127.0.0.1:9999",
 	}
+	// The proxy appends the address it saw; earlier entries are client-supplied.
 	got := clientIP(r)
-	if got != "203.0.113.50" {
-		t.Errorf("clientIP with multiple IPs = %q, want %q", got, "203.0.113.50")
+	if got != "150.172.238.178" {
+		t.Errorf("clientIP with multiple IPs = %q, want %q", got, "150.172.238.178")
+	}
+}
+
+func TestClientIP_UntrustedPeerIgnoresHeader(t *testing.T) {
+	r := &http.Request{
+		Header:     http.Header{"X-Forwarded-For": []string{"1.2.3.4"}},
+		RemoteAddr: "198.51.100.7:9999",
+	}
+	got := clientIP(r)
+	if got != "198.51.100.7" {
+		t.Errorf("clientIP from untrusted peer = %q, want %q", got, "198.51.100.7")
+	}
+}
+
+func TestClientIP_TrustedProxyChain(t *testing.T) {
+	if err := httpapi.SetTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer httpapi.SetTrustedProxies(nil)
+	r := &http.Request{
+		Header:     http.Header{"X-Forwarded-For": []string{"6.6.6.6, 203.0.113.9, 10.1.2.3"}},
+		RemoteAddr: "10.0.0.2:9999",
+	}
+	got := clientIP(r)
+	if got != "203.0.113.9" {
+		t.Errorf("clientIP through proxy chain = %q, want %q", got, "203.0.113.9")
 	}
 }
 
@@ -470,8 +502,8 @@ func TestClientIP_XForwardedForTrimmed(t *testing.T) {
 		RemoteAddr: "127.0.0.1:9999",
 	}
 	got := clientIP(r)
-	if got != "1.2.3.4" {
-		t.Errorf("clientIP = %q, want %q", got, "1.2.3.4")
+	if got != "5.6.7.8" {
+		t.Errorf("clientIP = %q, want %q", got, "5.6.7.8")
 	}
 }
 
@@ -853,7 +885,7 @@ func TestDashboardAuthMiddleware_AllowsPWAAssets(t *testing.T) {
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
-	handler := dashboardAuthMiddleware(cfg, inner)
+	handler := dashboardAuthMiddleware(cfg, nil, inner)
 
 	paths := []string{"/dashboard/manifest.json", "/dashboard/sw.js", "/dashboard/icon.svg"}
 	for _, p := range paths {
@@ -866,6 +898,177 @@ func TestDashboardAuthMiddleware_AllowsPWAAssets(t *testing.T) {
 	}
 }
 
+func TestDashboardAuthMiddleware_SSOOnly(t *testing.T) {
+	cfg := &Config{
+		DashboardAuth: DashboardAuthConfig{
+			Enabled: true,
+			OIDC:    OIDCConfig{Enabled: true, Issuer: "https://idp.example", ClientID: "tetora"},
+		},
+	}
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := dashboardAuthMiddleware(cfg, oidc.NewManager(cfg.DashboardAuth.OIDC, ""), inner)
+
+	// Without a password, a cookie signed with the empty secret must not pass.
+	req := httptest.NewRequest("GET", "/dashboard?tab=tasks", nil)
+	req.AddCookie(&http.Cookie{Name: "tetora_session", Value: dashboardAuthCookie("")})
+	req.AddCookie(&http.Cookie{Name: ssoCookie, Value: "unknown"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/dashboard/oidc/login?next=%2Fdashboard%3Ftab%3Dtasks" {
+		t.Errorf("got %d to %q, want redirect to SSO login", rr.Code, rr.Header().Get("Location"))
+	}
+
+	for _, p := range []string{"/dashboard/oidc/login", "/dashboard/oidc/callback", "/dashboard/logout"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", p, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("path %s returned %d, expected 200 (bypass)", p, rr.Code)
+		}
+	}
+}
+
+func TestDashboardAuthMiddleware_Accounts(t *testing.T) {
+	cfg := &Config{
+		APIToken: "api-token",
+		DashboardAuth: DashboardAuthConfig{
+			Enabled:  true,
+			Password: "admin-pw",
+			Accounts: []DashboardAccount{
+				{Name: "watcher", Password: "view-pw", Role: "viewer"},
+				{Name: "ops", Password: "ops-pw", Role: "operator"},
+			},
+		},
+	}
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := dashboardAuthMiddleware(cfg, nil, authMiddleware(cfg, nil, nil, inner))
+
+	viewer := dashboardAccountCookie("watcher", "view-pw")
+	operator := dashboardAccountCookie("ops", "ops-pw")
+	admin := dashboardAuthCookie("admin-pw")
+	tests := []struct {
+		cookie, method, path string
+		want                 int
+	}{
+		{viewer, "GET", "/dashboard", http.StatusOK},
+		{viewer, "GET", "/tasks", http.StatusOK},
+		{viewer, "POST", "/dispatch", http.StatusForbidden},
+		{viewer, "POST", "/cron/j1/trigger", http.StatusForbidden},
+		{viewer, "POST", "/budget/pause", http.StatusForbidden},
+		{operator, "POST", "/dispatch", http.StatusOK},
+		{operator, "POST", "/budget/pause", http.StatusOK},
+		{operator, "POST", "/api/config/toggle", http.StatusForbidden},
+		{admin, "POST", "/api/config/toggle", http.StatusOK},
+		// A viewer cookie relabelled as the operator account is not valid.
+		{"ops:" + strings.SplitN(viewer, ":", 2)[1], "POST", "/dispatch", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.AddCookie(&http.Cookie{Name: "tetora_session", Value: tt.cookie})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s with %q: got %d, want %d", tt.method, tt.path, tt.cookie, rr.Code, tt.want)
+		}
+	}
+
+	// With role accounts, a dashboard Referer alone no longer authenticates.
+	req := httptest.NewRequest("POST", "/dispatch", nil)
+	req.Header.Set("Referer", "http://localhost/dashboard")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Referer-only request: got %d, want 401", rr.Code)
+	}
+
+	if caps := dashboardCapabilities("viewer"); caps["dispatch"] || caps["cron"] || caps["budget"] {
+		t.Errorf("viewer capabilities = %v", caps)
+	}
+	if caps := dashboardCapabilities("operator"); !caps["dispatch"] || !caps["cron"] || !caps["budget"] || caps["config"] {
+		t.Errorf("operator capabilities = %v", caps)
+	}
+}
+
+func TestDashboardLogin_TOTP(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := totp.InitDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	globalTOTP = totp.NewManager(dbPath, "")
+	t.Cleanup(func() { globalTOTP = nil })
+	cfg := &Config{
+		HistoryDB: dbPath,
+		DashboardAuth: DashboardAuthConfig{
+			Enabled:  true,
+			Password: "admin-pw",
+			Accounts: []DashboardAccount{{Name: "ops", Password: "ops-pw", Role: "operator"}},
+		},
+	}
+
+	before := dashboardAccountCookie("ops", "ops-pw")
+	secret, _, err := globalTOTP.Setup("ops", "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := totp.Code(secret, time.Now())
+	recovery, err := globalTOTP.Confirm("ops", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enrolling invalidates sessions signed with the password alone; other
+	// logins are unaffected.
+	if _, _, ok := dashboardCookieRole(cfg, before); ok {
+		t.Error("pre-enrollment session still valid")
+	}
+	if _, role, ok := dashboardCookieRole(cfg, dashboardSessionCookie(cfg, "")); !ok || role != "admin" {
+		t.Error("main password session invalid")
+	}
+
+	pending := dashboardPendingCookie(cfg, "ops")
+	if login, ok := dashboardPendingLogin(cfg, pending); !ok || login != "ops" {
+		t.Fatalf("pending login = %q, %v", login, ok)
+	}
+	if _, ok := dashboardPendingLogin(cfg, dashboardSessionCookie(cfg, "ops")); ok {
+		t.Error("session cookie accepted as a pending second factor")
+	}
+
+	s := &Server{cfg: cfg, limiter: newLoginLimiter()}
+	post := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/dashboard/login", strings.NewReader("code="+code))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: totpPendingCookie, Value: pending})
+		req.ParseForm()
+		rr := httptest.NewRecorder()
+		s.loginSecondFactor(rr, req, "10.0.0.9")
+		return rr
+	}
+
+	if rr := post("not-a-code"); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Invalid code") {
+		t.Errorf("wrong code: %d %s", rr.Code, rr.Body.String())
+	}
+	if s.limiter.attempts["10.0.0.9"] == nil || s.limiter.attempts["10.0.0.9"].failures != 1 {
+		t.Error("wrong code not counted by the login limiter")
+	}
+
+	rr := post(recovery[0])
+	if rr.Code != http.StatusFound {
+		t.Fatalf("recovery code login: %d", rr.Code)
+	}
+	var session string
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "tetora_session" {
+			session = c.Value
+		}
+	}
+	if name, role, ok := dashboardCookieRole(cfg, session); !ok || name != "ops" || role != "operator" {
+		t.Errorf("session after second factor = %q %q %v", name, role, ok)
+	}
+}
+
 // --- from quiet_test.go ---
 
 func TestIsQuietHours_Disabled(t *testing.T) {
@@ -1529,6 +1732,77 @@ func TestHandleIncomingWebhook_DefaultPrompt(t *testing.T) {
 	}
 }
 
+func TestHandleIncomingWebhook_Sync(t *testing.T) {
+	cfg := testWebhookConfig(map[string]IncomingWebhookConfig{
+		"form": {
+			Agent: "黒曜", ResponseMode: "sync", MaxWait: "20s",
+			Response: &IncomingWebhookResponse{ErrorStatus: 502, Body: `{"form":"{{payload.id}}","status":"{{result.status}}"}`},
+		},
+	})
+	r := httptest.NewRequest("POST", "/hooks/form", strings.NewReader(`{"id":"f-1"}`))
+	sem := make(chan struct{}, 5)
+	result := handleIncomingWebhook(context.Background(), cfg, "form", r, nil, sem, nil)
+	if result.Status != "done" || result.TaskID == "" {
+		t.Fatalf("expected done with a task, got %+v", result)
+	}
+	// No provider is configured, so the task fails.
+	if result.TaskStatus == "success" {
+		t.Fatalf("unexpected success: %+v", result)
+	}
+
+	rr := httptest.NewRecorder()
+	writeIncomingWebhookResult(rr, cfg.IncomingWebhooks["form"], result)
+	if rr.Code != 502 {
+		t.Errorf("expected errorStatus 502, got %d", rr.Code)
+	}
+	want := fmt.Sprintf(`{"form":"f-1","status":"%s"}`, result.TaskStatus)
+	if rr.Body.String() != want {
+		t.Errorf("body %s, want %s", rr.Body.String(), want)
+	}
+}
+
+func TestWriteIncomingWebhookResult(t *testing.T) {
+	sync := IncomingWebhookConfig{ResponseMode: "sync", Response: &IncomingWebhookResponse{
+		Status: 201, ContentType: "text/plain", Body: "{{result.output}} ({{result.steps.draft}})",
+	}}
+	done := IncomingWebhookResult{Name: "form", Status: "done", TaskStatus: "success", Output: "Thanks!",
+		steps: map[string]string{"draft": "Thanks"}}
+
+	rr := httptest.NewRecorder()
+	writeIncomingWebhookResult(rr, sync, done)
+	if rr.Code != 201 || rr.Header().Get("Content-Type") != "text/plain" || rr.Body.String() != "Thanks! (Thanks)" {
+		t.Errorf("template: %d %q %q", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	// Without a template the result is returned as JSON.
+	rr = httptest.NewRecorder()
+	writeIncomingWebhookResult(rr, IncomingWebhookConfig{ResponseMode: "sync"}, done)
+	var got IncomingWebhookResult
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil || rr.Code != 200 || got.Output != "Thanks!" {
+		t.Errorf("default: %d %+v %v", rr.Code, got, err)
+	}
+
+	// maxWait passed: 202 with the task ID, never the template.
+	rr = httptest.NewRecorder()
+	writeIncomingWebhookResult(rr, sync, IncomingWebhookResult{Name: "form", Status: "pending", TaskID: "t1"})
+	if rr.Code != http.StatusAccepted || !strings.Contains(rr.Body.String(), `"taskId":"t1"`) {
+		t.Errorf("pending: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestIncomingWebhookMaxWait(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":      30 * time.Second,
+		"bogus": 30 * time.Second,
+		"45s":   45 * time.Second,
+		"1h":    10 * time.Minute,
+	} {
+		if got := (IncomingWebhookConfig{MaxWait: in}).MaxWaitDuration(); got != want {
+			t.Errorf("MaxWait %q = %v, want %v", in, got, want)
+		}
+	}
+}
+
 // --- HTTP Endpoint Tests ---
 
 func TestIncomingWebhookHTTPEndpoint(t *testing.T) {
@@ -1639,7 +1913,7 @@ func TestTriggerWebhookWorkflow_NotFound(t *testing.T) {
 		Workflow: "nonexistent",
 	}
 	result := triggerWebhookWorkflow(context.Background(), cfg, "test", whCfg,
-		map[string]any{}, "prompt", nil, nil, nil)
+		map[string]any{}, nil, "prompt", nil, nil, nil)
 	if result.Status != "error" {
 		t.Errorf("expected error, got %q", result.Status)
 	}
@@ -1695,6 +1969,54 @@ func TestHandleIncomingWebhook_FilterPassAndTemplateExpand(t *testing.T) {
 	}
 }
 
+func TestHandleIncomingWebhook_TransformFilter(t *testing.T) {
+	cfg := testWebhookConfig(map[string]IncomingWebhookConfig{
+		"gh": {
+			Agent:     "黒曜",
+			Transform: map[string]string{"isBug": "contains(pull_request.labels[*].name, 'bug')"},
+			Filter:    "vars.isBug",
+		},
+	})
+	r := httptest.NewRequest("POST", "/hooks/gh", strings.NewReader(`{"pull_request":{"labels":[{"name":"docs"}]}}`))
+	result := handleIncomingWebhook(context.Background(), cfg, "gh", r, nil, nil, nil)
+	if result.Status != "filtered" {
+		t.Errorf("expected filtered, got %q: %s", result.Status, result.Message)
+	}
+}
+
+func TestPreviewIncomingWebhook(t *testing.T) {
+	whCfg := IncomingWebhookConfig{
+		Agent: "黒曜",
+		Transform: map[string]string{
+			"title":  ".pull_request.title",
+			"labels": "join(', ', pull_request.labels[*].name)",
+			"bad":    "join(', ', pull_request)",
+		},
+		Filter:   "vars.labels != ''",
+		Template: "Review {{vars.title}} [{{vars.labels}}] from {{payload.sender}}",
+	}
+	payload := map[string]any{
+		"sender": "octo",
+		"pull_request": map[string]any{
+			"title":  "Add feature X",
+			"labels": []any{map[string]any{"name": "bug"}, map[string]any{"name": "ui"}},
+		},
+	}
+	p := previewIncomingWebhook("gh", whCfg, payload)
+	if !p.Matched {
+		t.Error("expected filter to match")
+	}
+	if p.Prompt != "Review Add feature X [bug, ui] from octo" {
+		t.Errorf("prompt = %q", p.Prompt)
+	}
+	if p.Vars["title"] != "Add feature X" {
+		t.Errorf("vars = %v", p.Vars)
+	}
+	if len(p.Errors) != 1 || !strings.Contains(p.Errors[0], "transform bad") {
+		t.Errorf("errors = %v", p.Errors)
+	}
+}
+
 func testConfig() *Config {
 	return &Config{
 		ListenAddr: "127.0.0.1:7777",
@@ -1759,12 +2081,14 @@ func TestBuildOpenAPISpec_AllEndpoints(t *testing.T) {
 		"/sessions",
 		"/sessions/{id}",
 		"/sessions/{id}/message",
+		"/sessions/{id}/participants",
 		"/sessions/{id}/stream",
 		"/workflows",
 		"/workflows/{name}",
 		"/workflows/{name}/run",
 		"/workflow-runs",
 		"/workflow-runs/{id}",
+		"/workflow-runs/{id}/diagram",
 		"/knowledge",
 		"/knowledge/search",
 		"/circuits",
@@ -1781,6 +2105,7 @@ func TestBuildOpenAPISpec_AllEndpoints(t *testing.T) {
 		"/roles/{name}",
 		"/route",
 		"/audit",
+		"/traces/{id}",
 		"/backup",
 		"/stats/cost",
 		"/stats/sla",
@@ -1972,3 +2297,52 @@ func TestBuildComponents_ErrorSchema(t *testing.T) {
 		t.Error("Error schema 'error' field should be required")
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	cfg := testConfigWithTools(echoTool())
+	cfg.APIToken = "tok"
+	cfg.Discord.Enabled = true
+	cfg.Agents = map[string]AgentConfig{"ruri": {}, "kokuyou": {}}
+	cfg.TaskBoard.Enabled = true
+	s := &Server{cfg: cfg}
+	mux := http.NewServeMux()
+	s.registerCapabilityRoutes(mux)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/capabilities", nil))
+	if rr.Code != 200 {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	var got capabilities
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != tetoraVersion || got.Versions["api"] != float64(apiVersion) || got.Role != "active" {
+		t.Errorf("version %q, versions %v, role %q", got.Version, got.Versions, got.Role)
+	}
+	if !got.Channels["discord"] || got.Channels["telegram"] {
+		t.Errorf("channels = %v", got.Channels)
+	}
+	if _, ok := got.Integrations["forges"]; !ok {
+		t.Errorf("integrations = %v", got.Integrations)
+	}
+	if f := got.Features["taskBoard"]; !f.Enabled || f.Path != "/api/tasks" {
+		t.Errorf("taskBoard = %+v", f)
+	}
+	if got.Features["knowledgeWatch"].Enabled {
+		t.Error("knowledgeWatch reported enabled")
+	}
+	if !got.Auth["apiToken"] || got.Auth["sso"] {
+		t.Errorf("auth = %v", got.Auth)
+	}
+	if !slices.Equal(got.Agents, []string{"kokuyou", "ruri"}) || !slices.Equal(got.Tools, []string{"echo"}) || got.Plugins == nil {
+		t.Errorf("agents %v, tools %v, plugins %v", got.Agents, got.Tools, got.Plugins)
+	}
+
+	cfg.Replica.Primary = "http://primary:7777"
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/capabilities", nil))
+	if !strings.Contains(rr.Body.String(), `"role":"replica"`) {
+		t.Errorf("replica role: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,212 @@
+// Package abtest runs A/B experiments on agents.
+//
+// An agent's experiment lists variants that differ in soul file or model,
+// each with a traffic weight. The dispatcher picks a variant per task (sticky
+// per session, so a conversation keeps one voice), and the outcome of every
+// run is kept in the ab_runs table of the history DB: status, cost, latency,
+// and the owner's thumbs up or down when given. Stats compares the variants
+// of an experiment so the winner can be promoted into the agent's config.
+package abtest
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+)
+
+// Pick returns the variant assigned to key (a session or task ID), weighted
+// by the variants' weights, or nil when the experiment has no variants. The
+// same key always gets the same variant while the variants are unchanged.
+func Pick(exp config.AgentExperimentConfig, key string) *config.AgentVariantConfig {
+	total := 0
+	for _, v := range exp.Variants {
+		total += weight(v)
+	}
+	if total == 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(exp.NameOrDefault() + "\x00" + key))
+	n := int(h.Sum32() % uint32(total))
+	for i := range exp.Variants {
+		if n -= weight(exp.Variants[i]); n < 0 {
+			return &exp.Variants[i]
+		}
+	}
+	return nil
+}
+
+func weight(v config.AgentVariantConfig) int {
+	if v.Weight < 0 {
+		return 0
+	}
+	if v.Weight == 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// InitDB creates the ab_runs table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS ab_runs (
+		task_id TEXT PRIMARY KEY,
+		agent TEXT NOT NULL,
+		experiment TEXT NOT NULL,
+		variant TEXT NOT NULL,
+		model TEXT DEFAULT '',
+		status TEXT DEFAULT '',
+		cost_usd REAL DEFAULT 0,
+		duration_ms INTEGER DEFAULT 0,
+		feedback INTEGER DEFAULT 0,
+		comment TEXT DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_ab_runs_experiment ON ab_runs(agent, experiment);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Run is the outcome of one task served by a variant.
+type Run struct {
+	TaskID     string  `json:"taskId"`
+	Agent      string  `json:"agent"`
+	Experiment string  `json:"experiment"`
+	Variant    string  `json:"variant"`
+	Model      string  `json:"model,omitempty"`
+	Status     string  `json:"status"`
+	CostUSD    float64 `json:"costUsd"`
+	DurationMs int64   `json:"durationMs"`
+	Feedback   int     `json:"feedback,omitempty"` // 1 up, -1 down, 0 none
+	Comment    string  `json:"comment,omitempty"`
+	CreatedAt  string  `json:"createdAt"`
+}
+
+// Record stores a finished run. Recording a task again replaces its outcome
+// but keeps any feedback.
+func Record(dbPath string, r Run) error {
+	if dbPath == "" {
+		return nil
+	}
+	if r.CreatedAt == "" {
+		r.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return db.ExecArgs(dbPath, `INSERT INTO ab_runs (task_id, agent, experiment, variant, model, status, cost_usd, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET variant = excluded.variant, model = excluded.model, status = excluded.status,
+			cost_usd = excluded.cost_usd, duration_ms = excluded.duration_ms`,
+		r.TaskID, r.Agent, r.Experiment, r.Variant, r.Model, r.Status, r.CostUSD, r.DurationMs, r.CreatedAt)
+}
+
+// ErrUnknownTask is returned for feedback on a task no variant served.
+var ErrUnknownTask = errors.New("task was not part of an experiment")
+
+// Feedback records the owner's verdict on a task: score 1 (good) or -1
+// (bad), with an optional comment. Later feedback replaces earlier.
+func Feedback(dbPath, taskID string, score int, comment string) (*Run, error) {
+	if score != 1 && score != -1 {
+		return nil, fmt.Errorf("score must be 1 or -1")
+	}
+	rows, err := db.QueryArgs(dbPath, `SELECT `+runColumns+` FROM ab_runs WHERE task_id = ?`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrUnknownTask
+	}
+	if err := db.ExecArgs(dbPath, `UPDATE ab_runs SET feedback = ?, comment = ? WHERE task_id = ?`, score, comment, taskID); err != nil {
+		return nil, err
+	}
+	r := runFromRow(rows[0])
+	r.Feedback, r.Comment = score, comment
+	return &r, nil
+}
+
+const runColumns = `task_id, agent, experiment, variant, model, status, cost_usd, duration_ms, feedback, comment, created_at`
+
+func runFromRow(row map[string]any) Run {
+	return Run{
+		TaskID:     db.Str(row["task_id"]),
+		Agent:      db.Str(row["agent"]),
+		Experiment: db.Str(row["experiment"]),
+		Variant:    db.Str(row["variant"]),
+		Model:      db.Str(row["model"]),
+		Status:     db.Str(row["status"]),
+		CostUSD:    db.Float(row["cost_usd"]),
+		DurationMs: int64(db.Int(row["duration_ms"])),
+		Feedback:   db.Int(row["feedback"]),
+		Comment:    db.Str(row["comment"]),
+		CreatedAt:  db.Str(row["created_at"]),
+	}
+}
+
+// VariantStats compares one variant's runs.
+type VariantStats struct {
+	Agent        string  `json:"agent"`
+	Experiment   string  `json:"experiment"`
+	Variant      string  `json:"variant"`
+	Tasks        int     `json:"tasks"`
+	Successes    int     `json:"successes"`
+	SuccessRate  float64 `json:"successRate"`
+	CostUSD      float64 `json:"costUsd"`
+	AvgCostUSD   float64 `json:"avgCostUsd"`
+	AvgLatencyMs int64   `json:"avgLatencyMs"`
+	Up           int     `json:"feedbackUp"`
+	Down         int     `json:"feedbackDown"`
+	// FeedbackScore is (up - down) / rated tasks, from -1 to 1; 0 when unrated.
+	FeedbackScore float64 `json:"feedbackScore"`
+}
+
+// Stats returns per-variant results, optionally for one agent and
+// experiment, ordered by agent, experiment and variant.
+func Stats(dbPath, agent, experiment string) ([]VariantStats, error) {
+	var where []string
+	var args []any
+	if agent != "" {
+		where, args = append(where, "agent = ?"), append(args, agent)
+	}
+	if experiment != "" {
+		where, args = append(where, "experiment = ?"), append(args, experiment)
+	}
+	sql := `SELECT agent, experiment, variant, COUNT(*) AS tasks,
+		SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) AS successes,
+		COALESCE(SUM(cost_usd), 0) AS cost, COALESCE(AVG(duration_ms), 0) AS latency,
+		SUM(CASE WHEN feedback > 0 THEN 1 ELSE 0 END) AS up,
+		SUM(CASE WHEN feedback < 0 THEN 1 ELSE 0 END) AS down
+		FROM ab_runs`
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " GROUP BY agent, experiment, variant ORDER BY agent, experiment, variant"
+	rows, err := db.QueryArgs(dbPath, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]VariantStats, 0, len(rows))
+	for _, row := range rows {
+		s := VariantStats{
+			Agent:        db.Str(row["agent"]),
+			Experiment:   db.Str(row["experiment"]),
+			Variant:      db.Str(row["variant"]),
+			Tasks:        db.Int(row["tasks"]),
+			Successes:    db.Int(row["successes"]),
+			CostUSD:      db.Float(row["cost"]),
+			AvgLatencyMs: int64(db.Float(row["latency"])),
+			Up:           db.Int(row["up"]),
+			Down:         db.Int(row["down"]),
+		}
+		if s.Tasks > 0 {
+			s.SuccessRate = float64(s.Successes) / float64(s.Tasks)
+			s.AvgCostUSD = s.CostUSD / float64(s.Tasks)
+		}
+		if rated := s.Up + s.Down; rated > 0 {
+			s.FeedbackScore = float64(s.Up-s.Down) / float64(rated)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package abtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestPick(t *testing.T) {
+	exp := config.AgentExperimentConfig{Name: "tone", Variants: []config.AgentVariantConfig{
+		{Name: "a", Weight: 3},
+		{Name: "b"},
+		{Name: "off", Weight: -1},
+	}}
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[Pick(exp, fmt.Sprintf("task-%d", i)).Name]++
+	}
+	if counts["off"] != 0 || counts["a"] < 2700 || counts["a"] > 3300 {
+		t.Errorf("split = %v, want about 3000/1000", counts)
+	}
+	if Pick(exp, "session-1").Name != Pick(exp, "session-1").Name {
+		t.Error("assignment is not sticky")
+	}
+	if Pick(config.AgentExperimentConfig{}, "x") != nil {
+		t.Error("empty experiment picked a variant")
+	}
+}
+
+func TestStats(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	runs := []Run{
+		{TaskID: "t1", Variant: "a", Status: "success", CostUSD: 0.10, DurationMs: 1000},
+		{TaskID: "t2", Variant: "a", Status: "error", CostUSD: 0.02, DurationMs: 3000},
+		{TaskID: "t3", Variant: "b", Status: "success", CostUSD: 0.01, DurationMs: 500},
+	}
+	for _, r := range runs {
+		r.Agent, r.Experiment = "ruri", "tone"
+		if err := Record(dbPath, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Record(dbPath, Run{TaskID: "t9", Agent: "kokuyou", Experiment: "default", Variant: "x", Status: "success"})
+
+	if _, err := Feedback(dbPath, "t1", 1, "great"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Feedback(dbPath, "t2", -1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Feedback(dbPath, "nope", 1, ""); err != ErrUnknownTask {
+		t.Errorf("unknown task: %v", err)
+	}
+	if _, err := Feedback(dbPath, "t1", 5, ""); err == nil {
+		t.Error("score 5 accepted")
+	}
+	// Recording a task again keeps its feedback.
+	Record(dbPath, Run{TaskID: "t1", Agent: "ruri", Experiment: "tone", Variant: "a", Status: "success", CostUSD: 0.10, DurationMs: 1000})
+
+	stats, err := Stats(dbPath, "ruri", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	a, b := stats[0], stats[1]
+	if a.Variant != "a" || a.Tasks != 2 || a.SuccessRate != 0.5 || a.AvgLatencyMs != 2000 || a.Up != 1 || a.Down != 1 || a.FeedbackScore != 0 {
+		t.Errorf("a = %+v", a)
+	}
+	if a.AvgCostUSD < 0.0599 || a.AvgCostUSD > 0.0601 {
+		t.Errorf("a avg cost = %v", a.AvgCostUSD)
+	}
+	if b.Variant != "b" || b.Tasks != 1 || b.SuccessRate != 1 || b.FeedbackScore != 0 {
+		t.Errorf("b = %+v", b)
+	}
+	if all, _ := Stats(dbPath, "", ""); len(all) != 3 {
+		t.Errorf("all stats = %+v", all)
+	}
+}
@@ -2,12 +2,14 @@
 package audit
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"tetora/internal/db"
 	tlog "tetora/internal/log"
+	"tetora/internal/trace"
 )
 
 // Entry represents a row in the audit_log table.
@@ -18,6 +20,7 @@ type Entry struct {
 	Source    string `json:"source"`
 	Detail    string `json:"detail"`
 	IP        string `json:"ip"`
+	Identity  string `json:"identity,omitempty"` // client certificate identity (mTLS)
 }
 
 // RoutingHistoryEntry represents a parsed route.dispatch audit log entry.
@@ -33,7 +36,11 @@ type RoutingHistoryEntry struct {
 
 // AgentRoutingStats aggregates routing stats for a single agent.
 type AgentRoutingStats struct {
-	Total int `json:"total"`
+	Total        int            `json:"total"`
+	Failovers    int            `json:"failovers,omitempty"`    // provider failovers among recent route.failover entries
+	FailoverTo   map[string]int `json:"failoverTo,omitempty"`   // failovers by target provider step
+	FeedbackUp   int            `json:"feedbackUp,omitempty"`   // owner thumbs up on the agent's results, all time
+	FeedbackDown int            `json:"feedbackDown,omitempty"` // owner thumbs down on the agent's results, all time
 }
 
 // entry holds a single audit log item for the batched writer.
@@ -44,6 +51,7 @@ type entry struct {
 	source string
 	detail string
 	ip     string
+	ident  string
 }
 
 // Chan is a buffered channel for non-blocking audit log writes.
@@ -107,8 +115,8 @@ func flush(entries []entry) {
 		var stmts []string
 		for _, e := range batch {
 			stmts = append(stmts, fmt.Sprintf(
-				`INSERT INTO audit_log (timestamp, action, source, detail, ip) VALUES ('%s','%s','%s','%s','%s')`,
-				e.ts, e.action, e.source, e.detail, e.ip,
+				`INSERT INTO audit_log (timestamp, action, source, detail, ip, identity) VALUES ('%s','%s','%s','%s','%s','%s')`,
+				e.ts, e.action, e.source, e.detail, e.ip, e.ident,
 			))
 		}
 		sql := strings.Join(stmts, ";\n")
@@ -127,28 +135,70 @@ func Init(dbPath string) error {
   action TEXT NOT NULL,
   source TEXT NOT NULL DEFAULT '',
   detail TEXT DEFAULT '',
-  ip TEXT DEFAULT ''
+  ip TEXT DEFAULT '',
+  identity TEXT DEFAULT ''
 );
 CREATE INDEX IF NOT EXISTS idx_audit_log_ts ON audit_log(timestamp);
 CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);`
 
-	return db.Exec(dbPath, sql)
+	if err := db.Exec(dbPath, sql); err != nil {
+		return err
+	}
+	// Migration: identity column for existing DBs.
+	if err := db.Exec(dbPath, `ALTER TABLE audit_log ADD COLUMN identity TEXT DEFAULT '';`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return err
+	}
+	return nil
 }
 
+type identityKey struct{}
+
+// WithIdentity returns ctx carrying the access identity of the caller, such
+// as the name mapped from an mTLS client certificate. LogCtx records it.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFrom returns the identity set by WithIdentity, or "".
+func IdentityFrom(ctx context.Context) string {
+	id, _ := ctx.Value(identityKey{}).(string)
+	return id
+}
+
+// RedactFn scrubs personal data from entry details before they are queued.
+// Nil = stored as is.
+var RedactFn func(text string) string
+
+// ExportFn, if set, receives every logged entry (after redaction) for
+// forwarding off-box. It must not block.
+var ExportFn func(Entry)
+
 // Log records an action to the audit_log table.
 // Non-blocking: entries are queued to the batched writer.
 func Log(dbPath, action, source, detail, ip string) {
+	logEntry(dbPath, action, source, detail, ip, "")
+}
+
+func logEntry(dbPath, action, source, detail, ip, identity string) {
 	if dbPath == "" {
 		return
 	}
+	if RedactFn != nil && detail != "" {
+		detail = RedactFn(detail)
+	}
+	ts := time.Now().UTC().Format(time.RFC3339)
+	if ExportFn != nil {
+		ExportFn(Entry{Timestamp: ts, Action: action, Source: source, Detail: detail, IP: ip, Identity: identity})
+	}
 	select {
 	case Chan <- entry{
 		dbPath: dbPath,
-		ts:     db.Escape(time.Now().UTC().Format(time.RFC3339)),
+		ts:     db.Escape(ts),
 		action: db.Escape(action),
 		source: db.Escape(source),
 		detail: db.Escape(db.Truncate(detail, 500)),
 		ip:     db.Escape(ip),
+		ident:  db.Escape(identity),
 	}:
 	default:
 		// Channel full — drop entry rather than block the caller.
@@ -156,6 +206,13 @@ func Log(dbPath, action, source, detail, ip string) {
 	}
 }
 
+// LogCtx is Log for callers holding a request context: the entry records the
+// context's identity and is also placed on the timeline of the context's trace.
+func LogCtx(ctx context.Context, dbPath, action, source, detail, ip string) {
+	logEntry(dbPath, action, source, detail, ip, IdentityFrom(ctx))
+	trace.Record(ctx, trace.Event{Kind: trace.KindAudit, Name: action, Detail: detail})
+}
+
 // Query returns recent audit log entries with a total count.
 func Query(dbPath string, limit, offset int) ([]Entry, int, error) {
 	if limit <= 0 {
@@ -175,7 +232,7 @@ func Query(dbPath string, limit, offset int) ([]Entry, int, error) {
 
 	// Query entries.
 	sql := fmt.Sprintf(
-		`SELECT id, timestamp, action, source, detail, ip
+		`SELECT id, timestamp, action, source, detail, ip, identity
 		 FROM audit_log ORDER BY id DESC LIMIT %d OFFSET %d`,
 		limit, offset)
 	rows, err := db.Query(dbPath, sql)
@@ -192,6 +249,7 @@ func Query(dbPath string, limit, offset int) ([]Entry, int, error) {
 			Source:    db.Str(row["source"]),
 			Detail:    db.Str(row["detail"]),
 			IP:        db.Str(row["ip"]),
+			Identity:  db.Str(row["identity"]),
 		})
 	}
 	return entries, total, nil
@@ -219,8 +277,29 @@ func ParseRouteDetail(detail string) (role, method, confidence, prompt string) {
 	return
 }
 
+// ParseFailoverDetail extracts agent, source and target provider, and reason
+// from a route.failover detail field.
+// Format: "role=X from=A to=B reason=..."
+func ParseFailoverDetail(detail string) (role, from, to, reason string) {
+	parts := strings.SplitN(detail, " reason=", 2)
+	if len(parts) == 2 {
+		reason = parts[1]
+	}
+	for _, token := range strings.Fields(parts[0]) {
+		if strings.HasPrefix(token, "role=") {
+			role = strings.TrimPrefix(token, "role=")
+		} else if strings.HasPrefix(token, "from=") {
+			from = strings.TrimPrefix(token, "from=")
+		} else if strings.HasPrefix(token, "to=") {
+			to = strings.TrimPrefix(token, "to=")
+		}
+	}
+	return
+}
+
 // QueryRoutingStats queries audit_log for route.dispatch events and returns
-// a list of routing history entries and per-agent stats.
+// a list of routing history entries and per-agent stats. The last limit
+// route.failover events are counted into each agent's failover stats.
 func QueryRoutingStats(dbPath string, limit int) ([]RoutingHistoryEntry, map[string]*AgentRoutingStats, error) {
 	if limit <= 0 {
 		limit = 50
@@ -263,6 +342,29 @@ func QueryRoutingStats(dbPath string, limit int) ([]RoutingHistoryEntry, map[str
 		}
 	}
 
+	rows, err = db.Query(dbPath, fmt.Sprintf(
+		`SELECT detail FROM audit_log WHERE action='route.failover'
+		 ORDER BY id DESC LIMIT %d`, limit))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		role, _, to, _ := ParseFailoverDetail(db.Str(row["detail"]))
+		if role == "" {
+			continue
+		}
+		stats, ok := byRole[role]
+		if !ok {
+			stats = &AgentRoutingStats{}
+			byRole[role] = stats
+		}
+		if stats.FailoverTo == nil {
+			stats.FailoverTo = make(map[string]int)
+		}
+		stats.Failovers++
+		stats.FailoverTo[to]++
+	}
+
 	return history, byRole, nil
 }
 
@@ -110,6 +110,18 @@ func TestParseRouteDetail_PromptContainsPromptKeyword(t *testing.T) {
 	}
 }
 
+func TestParseFailoverDetail(t *testing.T) {
+	role, from, to, reason := audit.ParseFailoverDetail(
+		"role=dev from=claude-code to=anthropic/claude-sonnet-4 reason=HTTP 429: rate limited",
+	)
+	if role != "dev" || from != "claude-code" || to != "anthropic/claude-sonnet-4" {
+		t.Errorf("got role=%q from=%q to=%q", role, from, to)
+	}
+	if reason != "HTTP 429: rate limited" {
+		t.Errorf("reason: want %q, got %q", "HTTP 429: rate limited", reason)
+	}
+}
+
 // ---- Log tests ----
 
 func TestLog_EmptyDBPath_NoPanic(t *testing.T) {
@@ -0,0 +1,450 @@
+// Package cfgfile reads and writes config documents in JSON, YAML and TOML.
+//
+// Documents are parsed into an ordered tree, so converting between formats
+// keeps keys in the order they were written. The daemon itself only ever
+// decodes JSON: other formats are converted to JSON when read, and JSON is
+// converted back to the file's format when Tetora edits a config file.
+//
+// YAML support covers what config files use: block and flow mappings and
+// sequences, quoted and plain scalars, block scalars and comments. Anchors,
+// aliases, tags and multiple documents are rejected.
+package cfgfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format is a config file syntax.
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// Extensions lists the config file extensions in lookup order.
+var Extensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// FormatOf returns the format of a file by its extension. Unknown
+// extensions are JSON.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	}
+	return JSON
+}
+
+// ParseFormat returns the format named s ("json", "yaml", "yml", "toml").
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "json":
+		return JSON, nil
+	case "yaml", "yml":
+		return YAML, nil
+	case "toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("unknown config format %q (want json, yaml or toml)", s)
+}
+
+// Find returns the first existing file in dir named base with one of
+// Extensions, or "" if there is none.
+func Find(dir, base string) string {
+	for _, ext := range Extensions {
+		p := filepath.Join(dir, base+ext)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Sibling returns the path of the file next to path that adds suffix to its
+// name, in any format: Sibling("/x/config.yaml", ".local") finds
+// /x/config.local.yaml, /x/config.local.json and so on, preferring path's
+// own format. It returns "" if there is none.
+func Sibling(path, suffix string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext) + suffix
+	if _, err := os.Stat(base + ext); err == nil {
+		return base + ext
+	}
+	return Find(filepath.Dir(base), filepath.Base(base))
+}
+
+// Object is a mapping that keeps its keys in document order, along with
+// the source line of each key.
+type Object struct {
+	keys   []string
+	values map[string]any
+	lines  map[string]int
+}
+
+// NewObject returns an empty Object.
+func NewObject() *Object {
+	return &Object{values: map[string]any{}, lines: map[string]int{}}
+}
+
+// Set sets key to v. A new key goes last; an existing key keeps its place.
+func (o *Object) Set(key string, v any, line int) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+		o.lines[key] = line
+	}
+	o.values[key] = v
+}
+
+// Get returns the value of key.
+func (o *Object) Get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// Keys returns the keys in document order.
+func (o *Object) Keys() []string { return o.keys }
+
+// Len returns the number of keys.
+func (o *Object) Len() int { return len(o.keys) }
+
+// firstLine returns the lowest source line of o's keys, or 0.
+func (o *Object) firstLine() int {
+	line := 0
+	for _, k := range o.keys {
+		if l := o.lines[k]; l > 0 && (line == 0 || l < line) {
+			line = l
+		}
+	}
+	return line
+}
+
+// A Doc is a parsed config document. Values in the tree are nil, bool,
+// json.Number, string, []any and *Object.
+type Doc struct {
+	Root   *Object
+	Format Format
+}
+
+// Parse parses a config document. The top level must be a mapping.
+func Parse(data []byte, f Format) (*Doc, error) {
+	var v any
+	var err error
+	switch f {
+	case YAML:
+		v, err = parseYAML(data)
+	case TOML:
+		v, err = parseTOML(data)
+	default:
+		v, err = parseJSON(data)
+	}
+	if err != nil {
+		if f != JSON {
+			return nil, syntaxError(err)
+		}
+		return nil, err
+	}
+	if v == nil {
+		v = NewObject()
+	}
+	root, ok := v.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("config must be a mapping at the top level")
+	}
+	return &Doc{Root: root, Format: f}, nil
+}
+
+// SyntaxError is a YAML or TOML document that does not parse.
+type SyntaxError struct {
+	Line int // 0 if the error is not on a particular line
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+var errLine = regexp.MustCompile(`(?s)^line (\d+): (.*)$`)
+
+func syntaxError(err error) *SyntaxError {
+	if m := errLine.FindStringSubmatch(err.Error()); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return &SyntaxError{Line: n, Msg: m[2]}
+	}
+	return &SyntaxError{Msg: err.Error()}
+}
+
+// Encode writes the document in format f.
+func (d *Doc) Encode(f Format) ([]byte, error) {
+	switch f {
+	case YAML:
+		return encodeYAML(d.Root), nil
+	case TOML:
+		return encodeTOML(d.Root)
+	}
+	return encodeJSON(d.Root), nil
+}
+
+// JSON returns the document as indented JSON.
+func (d *Doc) JSON() []byte { return encodeJSON(d.Root) }
+
+var pathSeg = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)\]`)
+
+// Line returns the source line of the value at path, in the form config
+// lint reports ("agents.ruri.model", "webhooks[0].url"). When the exact
+// value has no line, such as an array element, the closest enclosing key's
+// line is returned. It is 0 if nothing on the path is known.
+func (d *Doc) Line(path string) int {
+	var cur any = d.Root
+	line := 0
+	for _, m := range pathSeg.FindAllStringSubmatch(path, -1) {
+		switch v := cur.(type) {
+		case *Object:
+			if m[1] == "" {
+				return line
+			}
+			next, ok := v.Get(m[1])
+			if !ok {
+				return line
+			}
+			if l := v.lines[m[1]]; l > 0 {
+				line = l
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(m[2])
+			if m[2] == "" || err != nil || i >= len(v) {
+				return line
+			}
+			cur = v[i]
+			if o, ok := cur.(*Object); ok {
+				if l := o.firstLine(); l > 0 {
+					line = l
+				}
+			}
+		default:
+			return line
+		}
+	}
+	return line
+}
+
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// Interpolate replaces ${NAME} in every string value with the variable
+// lookup returns, and ${NAME:-default} with default when it is unset or
+// empty. References to unset variables without a default are left as they
+// are. $${ is a literal ${.
+func (d *Doc) Interpolate(lookup func(string) (string, bool)) {
+	d.Root = interpolate(d.Root, lookup).(*Object)
+}
+
+func interpolate(v any, lookup func(string) (string, bool)) any {
+	switch v := v.(type) {
+	case string:
+		if !strings.Contains(v, "${") {
+			return v
+		}
+		return envRef.ReplaceAllStringFunc(v, func(ref string) string {
+			if ref == "$${" {
+				return "${"
+			}
+			m := envRef.FindStringSubmatch(ref)
+			val, ok := lookup(m[1])
+			if ok && val != "" {
+				return val
+			}
+			if strings.Contains(ref, ":-") {
+				return m[2]
+			}
+			if ok {
+				return val
+			}
+			return ref
+		})
+	case []any:
+		for i := range v {
+			v[i] = interpolate(v[i], lookup)
+		}
+	case *Object:
+		for _, k := range v.keys {
+			v.values[k] = interpolate(v.values[k], lookup)
+		}
+	}
+	return v
+}
+
+// ReadJSON reads the config file at path and returns it as JSON, converting
+// YAML and TOML. JSON files are returned unchanged.
+func ReadJSON(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := FormatOf(path)
+	if f == JSON {
+		return data, nil
+	}
+	doc, err := Parse(data, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return doc.JSON(), nil
+}
+
+// FromJSON converts a JSON config document to format f. JSON is returned
+// unchanged.
+func FromJSON(data []byte, f Format) ([]byte, error) {
+	if f == JSON {
+		return data, nil
+	}
+	doc, err := Parse(data, JSON)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Encode(f)
+}
+
+// WriteJSON writes a JSON config document to path in path's format,
+// atomically. Comments in a YAML or TOML file do not survive the rewrite.
+func WriteJSON(path string, data []byte, perm os.FileMode) error {
+	out, err := FromJSON(data, FormatOf(path))
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, out, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// --- JSON ---
+
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := jsonValue(dec, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after the end of the document")
+	}
+	return v, nil
+}
+
+func jsonValue(dec *json.Decoder, data []byte) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			o := NewObject()
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				// The offset is just past the key's closing quote.
+				line := bytes.Count(data[:dec.InputOffset()], []byte("\n")) + 1
+				v, err := jsonValue(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				o.Set(k.(string), v, line)
+			}
+			_, err := dec.Token()
+			return o, err
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				v, err := jsonValue(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+	}
+	return tok, nil
+}
+
+func encodeJSON(v any) []byte {
+	var b bytes.Buffer
+	writeJSON(&b, v, "")
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeJSON(b *bytes.Buffer, v any, indent string) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		b.WriteString(string(v))
+	case string:
+		b.WriteString(jsonString(v))
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[\n")
+		for i, e := range v {
+			b.WriteString(indent + "  ")
+			writeJSON(b, e, indent+"  ")
+			if i < len(v)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "]")
+	case *Object:
+		if v.Len() == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{\n")
+		for i, k := range v.keys {
+			b.WriteString(indent + "  " + jsonString(k) + ": ")
+			writeJSON(b, v.values[k], indent+"  ")
+			if i < len(v.keys)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+	}
+}
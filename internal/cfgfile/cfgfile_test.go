@@ -0,0 +1,269 @@
+package cfgfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const wantJSON = `{
+  "listenAddr": "127.0.0.1:8991",
+  "maxConcurrent": 3,
+  "defaultBudget": 2.5,
+  "apiToken": "${TETORA_TOKEN}",
+  "docker": {
+    "enabled": false
+  },
+  "agents": {
+    "ruri": {
+      "model": "sonnet",
+      "description": "Coordinator: routes work",
+      "tools": [
+        "read",
+        "write"
+      ]
+    }
+  },
+  "webhooks": [
+    {
+      "url": "https://example.com/hook",
+      "events": [
+        "done"
+      ]
+    },
+    {
+      "url": "https://example.com/b#frag"
+    }
+  ],
+  "prompt": "line one\nline two\n",
+  "quoted": "it's \"fine\"",
+  "port": "8080"
+}
+`
+
+func TestParseFormats(t *testing.T) {
+	yaml := `# Tetora config
+listenAddr: 127.0.0.1:8991
+maxConcurrent: 3
+defaultBudget: 2.5   # USD
+apiToken: ${TETORA_TOKEN}
+docker: {enabled: false}
+agents:
+  ruri:
+    model: sonnet
+    description: "Coordinator: routes work"
+    tools: [read, write]
+webhooks:
+- url: https://example.com/hook
+  events:
+    - done
+- url: https://example.com/b#frag
+prompt: |
+  line one
+  line two
+quoted: 'it''s "fine"'
+port: "8080"
+`
+	toml := `# Tetora config
+listenAddr = "127.0.0.1:8991"
+maxConcurrent = 3
+defaultBudget = 2.5 # USD
+apiToken = "${TETORA_TOKEN}"
+docker = { enabled = false }
+
+[agents.ruri]
+model = 'sonnet'
+description = "Coordinator: routes work"
+tools = [
+  "read",
+  "write", # trailing comma
+]
+
+[[webhooks]]
+url = "https://example.com/hook"
+events = ["done"]
+
+[[webhooks]]
+url = "https://example.com/b#frag"
+
+[empty]
+`
+	for _, tc := range []struct {
+		name string
+		data string
+		f    Format
+	}{
+		{"json", wantJSON, JSON},
+		{"yaml", yaml, YAML},
+	} {
+		doc, err := Parse([]byte(tc.data), tc.f)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := string(doc.JSON()); got != wantJSON {
+			t.Errorf("%s as JSON:\n%s", tc.name, got)
+		}
+	}
+
+	// TOML has no block strings here and keeps its own key order, so
+	// compare it as a map.
+	doc, err := Parse([]byte(toml), TOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]any
+	json.Unmarshal(doc.JSON(), &got)
+	json.Unmarshal([]byte(wantJSON), &want)
+	delete(want, "prompt")
+	delete(want, "quoted")
+	delete(want, "port")
+	want["empty"] = map[string]any{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toml as JSON:\n%s", doc.JSON())
+	}
+	if line := doc.Line("agents.ruri.tools"); line != 11 {
+		t.Errorf("toml line = %d, want 11", line)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	doc, err := Parse([]byte(wantJSON), JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []Format{YAML, TOML} {
+		out, err := doc.Encode(f)
+		if err != nil {
+			t.Fatalf("encode %s: %v", f, err)
+		}
+		back, err := Parse(out, f)
+		if err != nil {
+			t.Fatalf("parse %s: %v\n%s", f, err, out)
+		}
+		var got, want any
+		json.Unmarshal(back.JSON(), &got)
+		json.Unmarshal([]byte(wantJSON), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s round trip:\n%s", f, out)
+		}
+	}
+	nulls, _ := Parse([]byte(`{"a": [1, null]}`), JSON)
+	if _, err := nulls.Encode(TOML); err == nil {
+		t.Error("expected an error for null in a TOML array")
+	}
+}
+
+func TestYAMLLines(t *testing.T) {
+	doc, err := Parse([]byte("a: 1\nagents:\n  ruri:\n\n    model: x\nlist:\n  - name: one\n  - name: two\n"), YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{"a": 1, "agents.ruri.model": 5, "list[1].name": 8, "list[1]": 8, "agents.ruri.nope": 3} {
+		if got := doc.Line(path); got != want {
+			t.Errorf("Line(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		f    Format
+		data string
+		want string
+	}{
+		{YAML, "a: &x 1\nb: *x\n", "line 1: anchors"},
+		{YAML, "a: 1\na: 2\n", "line 2: duplicate key"},
+		{YAML, "a:\n\tb: 1\n", "line 2: tabs"},
+		{YAML, "a: 1\n   b: 2\n", "line 2: unexpected indentation"},
+		{YAML, "a: \"open\n", "line 1: unterminated"},
+		{YAML, "a: 1\n---\nb: 2\n", "multiple YAML documents"},
+		{YAML, "- a\n- b\n", "mapping at the top level"},
+		{TOML, "a = 1\na = 2\n", "line 2: duplicate key a"},
+		{TOML, "a = bare\n", "line 1: invalid value \"bare\""},
+		{TOML, "[t]\n[t]\n", "line 2: table [t] is defined twice"},
+		{TOML, "a = inf\n", "cannot be represented in JSON"},
+	} {
+		_, err := Parse([]byte(tc.data), tc.f)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %q: err = %v, want %q", tc.f, tc.data, err, tc.want)
+		}
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	doc, err := Parse([]byte(`n: ~
+b: True
+o: 0o17
+h: 0x1F
+f: .5
+s: 1.2.3
+y: yes
+folded: >
+  one
+  two
+
+  three
+keep: |+
+  x
+
+strip: |-
+  y
+flow: {a: [1, "b, c"], d: }
+`), YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"n":null,"b":true,"o":15,"h":31,"f":0.5,"s":"1.2.3","y":"yes","folded":"one two\nthree\n","keep":"x\n\n","strip":"y","flow":{"a":[1,"b, c"],"d":null}}`
+	var v any
+	json.Unmarshal(doc.JSON(), &v)
+	got, _ := json.Marshal(v)
+	var w any
+	json.Unmarshal([]byte(want), &w)
+	wantOut, _ := json.Marshal(w)
+	if string(got) != string(wantOut) {
+		t.Errorf("got  %s\nwant %s", got, wantOut)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	doc, _ := Parse([]byte(`{"a": "${HOME_DIR}/x", "b": ["${MISSING}", "${MISSING:-def}", "$${HOME_DIR}"], "c": {"d": "${EMPTY:-fallback}"}, "n": 1}`), JSON)
+	env := map[string]string{"HOME_DIR": "/home/t", "EMPTY": ""}
+	doc.Interpolate(func(k string) (string, bool) { v, ok := env[k]; return v, ok })
+	var got map[string]any
+	json.Unmarshal(doc.JSON(), &got)
+	want := map[string]any{
+		"a": "/home/t/x",
+		"b": []any{"${MISSING}", "def", "${HOME_DIR}"},
+		"c": map[string]any{"d": "fallback"},
+		"n": float64(1),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestWriteJSONAndSibling(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := WriteJSON(path, []byte(`{"maxConcurrent": 2, "agents": {"ruri": {"model": "opus"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "maxConcurrent: 2\nagents:\n  ruri:\n    model: opus\n" {
+		t.Errorf("yaml = %q", data)
+	}
+	js, err := ReadJSON(path)
+	if err != nil || !strings.Contains(string(js), `"model": "opus"`) {
+		t.Errorf("ReadJSON = %s, %v", js, err)
+	}
+	if got := Find(dir, "config"); got != path {
+		t.Errorf("Find = %q", got)
+	}
+	os.WriteFile(filepath.Join(dir, "config.local.toml"), nil, 0o600)
+	if got := Sibling(path, ".local"); got != filepath.Join(dir, "config.local.toml") {
+		t.Errorf("Sibling = %q", got)
+	}
+}
@@ -0,0 +1,630 @@
+package cfgfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tomlParser struct {
+	s       string
+	pos     int
+	root    *Object
+	cur     *Object
+	defined map[*Object]bool // tables opened by a [header]
+	frozen  map[*Object]bool // inline tables, which cannot be extended
+	aot     map[string]bool  // arrays of tables, by dotted path
+}
+
+func parseTOML(data []byte) (any, error) {
+	p := &tomlParser{
+		s:       strings.TrimPrefix(string(data), "\ufeff"),
+		root:    NewObject(),
+		defined: map[*Object]bool{},
+		frozen:  map[*Object]bool{},
+		aot:     map[string]bool{},
+	}
+	p.cur = p.root
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return p.root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.header()
+		} else {
+			err = p.keyValue(p.cur)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) eof() bool  { return p.pos >= len(p.s) }
+func (p *tomlParser) peek() byte { return p.s[p.pos] }
+
+func (p *tomlParser) line() int { return strings.Count(p.s[:p.pos], "\n") + 1 }
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: "+format, append([]any{p.line()}, args...)...)
+}
+
+// skipSpace skips spaces, tabs and comments, and newlines too if nl is set.
+func (p *tomlParser) skipSpace(nl bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case nl && (c == '\n' || c == '\r'):
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace(false)
+	if p.eof() {
+		return nil
+	}
+	if strings.HasPrefix(p.s[p.pos:], "\n") || strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		return nil
+	}
+	return p.errorf("unexpected %q at end of line", p.rest())
+}
+
+// rest returns the remainder of the current line, for error messages.
+func (p *tomlParser) rest() string {
+	s := p.s[p.pos:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func (p *tomlParser) header() error {
+	line := p.line()
+	array := strings.HasPrefix(p.s[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.skipSpace(false)
+	keys, err := p.keys()
+	if err != nil {
+		return err
+	}
+	p.skipSpace(false)
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.s[p.pos:], closing) {
+		return p.errorf("expected %q after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.walk(p.root, keys[:len(keys)-1], line, true)
+	if err != nil {
+		return err
+	}
+	name := strings.Join(keys, ".")
+	last := keys[len(keys)-1]
+	existing, exists := parent.Get(last)
+	if array {
+		t := NewObject()
+		switch v := existing.(type) {
+		case nil:
+			if exists {
+				return p.errorf("%s is already defined", name)
+			}
+			parent.Set(last, []any{t}, line)
+		case []any:
+			if !p.aot[strings.Join(keys, "\x00")] {
+				return p.errorf("%s is already defined as an array", name)
+			}
+			parent.Set(last, append(v, t), line)
+		default:
+			return p.errorf("%s is already defined", name)
+		}
+		p.aot[strings.Join(keys, "\x00")] = true
+		p.cur = t
+		return nil
+	}
+	switch v := existing.(type) {
+	case nil:
+		if exists {
+			return p.errorf("%s is already defined", name)
+		}
+		t := NewObject()
+		parent.Set(last, t, line)
+		p.defined[t] = true
+		p.cur = t
+	case *Object:
+		if p.defined[v] || p.frozen[v] {
+			return p.errorf("table [%s] is defined twice", name)
+		}
+		p.defined[v] = true
+		p.cur = v
+	default:
+		return p.errorf("%s is already defined", name)
+	}
+	return nil
+}
+
+// walk follows keys down from o, creating tables as needed. In headers,
+// a key naming an array of tables refers to its last element.
+func (p *tomlParser) walk(o *Object, keys []string, line int, header bool) (*Object, error) {
+	for i, k := range keys {
+		v, ok := o.Get(k)
+		if !ok {
+			t := NewObject()
+			o.Set(k, t, line)
+			o = t
+			continue
+		}
+		switch v := v.(type) {
+		case *Object:
+			if p.frozen[v] {
+				return nil, p.errorf("cannot extend inline table %s", strings.Join(keys[:i+1], "."))
+			}
+			o = v
+		case []any:
+			t, isTable := any(nil), false
+			if len(v) > 0 {
+				t = v[len(v)-1]
+				_, isTable = t.(*Object)
+			}
+			if !header || !isTable {
+				return nil, p.errorf("%s is already defined as an array", strings.Join(keys[:i+1], "."))
+			}
+			o = t.(*Object)
+		default:
+			return nil, p.errorf("%s is already defined as a value", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return o, nil
+}
+
+func (p *tomlParser) keyValue(o *Object) error {
+	line := p.line()
+	keys, err := p.keys()
+	if err != nil {
+		return err
+	}
+	p.skipSpace(false)
+	if p.eof() || p.peek() != '=' {
+		return p.errorf("expected '=' after key %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace(false)
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	t, err := p.walk(o, keys[:len(keys)-1], line, false)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, dup := t.Get(last); dup {
+		return fmt.Errorf("line %d: duplicate key %s", line, strings.Join(keys, "."))
+	}
+	t.Set(last, v, line)
+	return nil
+}
+
+func isBareKey(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) keys() ([]string, error) {
+	var keys []string
+	for {
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			if strings.HasPrefix(p.s[p.pos:], `"""`) || strings.HasPrefix(p.s[p.pos:], "'''") {
+				return nil, p.errorf("multi-line strings cannot be keys")
+			}
+			k, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		case isBareKey(c):
+			start := p.pos
+			for !p.eof() && isBareKey(p.peek()) {
+				p.pos++
+			}
+			keys = append(keys, p.s[start:p.pos])
+		default:
+			return nil, p.errorf("expected a key, got %q", p.rest())
+		}
+		p.skipSpace(false)
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.skipSpace(false)
+	}
+}
+
+var (
+	tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[+-]\d{2}:\d{2})?)?$|^\d{2}:\d{2}(:\d{2}(\.\d+)?)?$`)
+	tomlInt      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlPrefixed = regexp.MustCompile(`^0(x[0-9a-fA-F](_?[0-9a-fA-F])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	tomlFloat    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	tomlTime     = regexp.MustCompile(`^ \d{2}:\d{2}`)
+)
+
+func (p *tomlParser) value() (any, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.peek() {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		p.pos++
+		arr := []any{}
+		for {
+			p.skipSpace(true)
+			if p.eof() {
+				return nil, p.errorf("unterminated array")
+			}
+			if p.peek() == ']' {
+				p.pos++
+				return arr, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			p.skipSpace(true)
+			if !p.eof() && p.peek() == ',' {
+				p.pos++
+			} else if p.eof() || p.peek() != ']' {
+				return nil, p.errorf("expected ',' or ']' in array")
+			}
+		}
+	case '{':
+		p.pos++
+		t := NewObject()
+		for first := true; ; first = false {
+			p.skipSpace(false)
+			if p.eof() {
+				return nil, p.errorf("unterminated inline table")
+			}
+			if p.peek() == '}' && first {
+				p.pos++
+				break
+			}
+			if err := p.keyValue(t); err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.eof() && p.peek() == ',' {
+				p.pos++
+				continue
+			}
+			if !p.eof() && p.peek() == '}' {
+				p.pos++
+				break
+			}
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+		p.freeze(t)
+		return t, nil
+	}
+
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n,]}#", p.peek()) < 0 {
+		p.pos++
+	}
+	tok := p.s[start:p.pos]
+	// A date and a time may be separated by a space.
+	if len(tok) == 10 && tomlTime.MatchString(p.s[p.pos:]) {
+		p.pos++
+		for !p.eof() && strings.IndexByte(" \t\r\n,]}#", p.peek()) < 0 {
+			p.pos++
+		}
+		tok = p.s[start:p.pos]
+	}
+	switch {
+	case tok == "true":
+		return true, nil
+	case tok == "false":
+		return false, nil
+	case tomlDateTime.MatchString(tok):
+		return tok, nil
+	case tomlPrefixed.MatchString(tok):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		n, err := strconv.ParseInt(strings.ReplaceAll(tok[2:], "_", ""), base, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s out of range", tok)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case tomlInt.MatchString(tok):
+		n, err := strconv.ParseInt(strings.ReplaceAll(tok, "_", ""), 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s out of range", tok)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case tomlFloat.MatchString(tok):
+		f, err := strconv.ParseFloat(strings.ReplaceAll(tok, "_", ""), 64)
+		if err != nil {
+			return nil, p.errorf("number %s out of range", tok)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case strings.HasSuffix(tok, "inf") || strings.HasSuffix(tok, "nan"):
+		return nil, p.errorf("%s cannot be represented in JSON", tok)
+	case tok == "":
+		return nil, p.errorf("expected a value, got %q", p.rest())
+	}
+	return nil, p.errorf("invalid value %q (strings must be quoted)", tok)
+}
+
+func (p *tomlParser) freeze(t *Object) {
+	p.frozen[t] = true
+	for _, k := range t.keys {
+		if sub, ok := t.values[k].(*Object); ok {
+			p.freeze(sub)
+		}
+	}
+}
+
+// str parses a basic, literal or multi-line string.
+func (p *tomlParser) str() (string, error) {
+	q := p.peek()
+	multi := strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(q), 3))
+	if multi {
+		p.pos += 3
+		if strings.HasPrefix(p.s[p.pos:], "\n") {
+			p.pos++
+		} else if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+			p.pos += 2
+		}
+	} else {
+		p.pos++
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == q && !multi:
+			p.pos++
+			return b.String(), nil
+		case c == q && strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(q), 3)):
+			// Up to two quotes may sit right before the closing delimiter.
+			n := 3
+			for n < 5 && p.pos+n < len(p.s) && p.s[p.pos+n] == q {
+				n++
+			}
+			b.WriteString(strings.Repeat(string(q), n-3))
+			p.pos += n
+			return b.String(), nil
+		case c == '\n' && !multi:
+			return "", p.errorf("unterminated string")
+		case c == '\\' && q == '"':
+			if err := p.escape(&b, multi); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) escape(b *strings.Builder, multi bool) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte('\x1b')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil {
+			return p.errorf("invalid escape \\%c%s", c, p.s[p.pos:p.pos+n])
+		}
+		b.WriteRune(rune(r))
+		p.pos += n
+	case ' ', '\t', '\r', '\n':
+		// A backslash ending a line of a multi-line string trims the
+		// line break and the whitespace after it.
+		rest := strings.TrimLeft(p.s[p.pos-1:], " \t")
+		if !multi || !(strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n")) {
+			return p.errorf("invalid escape \\%q", c)
+		}
+		p.pos--
+		for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+			p.pos++
+		}
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// --- encoding ---
+
+func encodeTOML(root *Object) ([]byte, error) {
+	var b bytes.Buffer
+	if err := tomlTable(&b, root, nil); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(b.Bytes(), "\n"), nil
+}
+
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKey(k[i]) {
+			return jsonString(k)
+		}
+	}
+	return k
+}
+
+func tomlPath(path []string) string {
+	parts := make([]string, len(path))
+	for i, k := range path {
+		parts[i] = tomlKey(k)
+	}
+	return strings.Join(parts, ".")
+}
+
+// isTableArray reports whether v is written as [[array]] sections.
+func isTableArray(v any) bool {
+	arr, ok := v.([]any)
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, e := range arr {
+		if _, ok := e.(*Object); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlTable writes o's plain values, then its sub-tables. TOML has no
+// null, so null values are left out.
+func tomlTable(b *bytes.Buffer, o *Object, path []string) error {
+	for _, k := range o.keys {
+		v := o.values[k]
+		if sub, ok := v.(*Object); (ok && sub.Len() > 0) || v == nil || isTableArray(v) {
+			continue
+		}
+		s, err := tomlInline(v, append(path, k))
+		if err != nil {
+			return err
+		}
+		b.WriteString(tomlKey(k) + " = " + s + "\n")
+	}
+	for _, k := range o.keys {
+		sub := append(append([]string{}, path...), k)
+		switch v := o.values[k].(type) {
+		case *Object:
+			if v.Len() == 0 {
+				continue
+			}
+			if hasPlainValues(v) {
+				b.WriteString("\n[" + tomlPath(sub) + "]\n")
+			}
+			if err := tomlTable(b, v, sub); err != nil {
+				return err
+			}
+		case []any:
+			if !isTableArray(v) {
+				continue
+			}
+			for _, e := range v {
+				b.WriteString("\n[[" + tomlPath(sub) + "]]\n")
+				if err := tomlTable(b, e.(*Object), sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasPlainValues reports whether o needs its own [header]: it has values
+// that are not sub-tables.
+func hasPlainValues(o *Object) bool {
+	for _, k := range o.keys {
+		v := o.values[k]
+		if sub, ok := v.(*Object); (ok && sub.Len() > 0) || v == nil || isTableArray(v) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func tomlInline(v any, path []string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("%s: TOML cannot represent null inside an array or inline table", strings.Join(path, "."))
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return string(v), nil
+	case string:
+		return jsonString(v), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			s, err := tomlInline(e, path)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case *Object:
+		var parts []string
+		for _, k := range v.keys {
+			if v.values[k] == nil {
+				continue
+			}
+			s, err := tomlInline(v.values[k], append(path, k))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKey(k)+" = "+s)
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("%s: unsupported value %T", strings.Join(path, "."), v)
+}
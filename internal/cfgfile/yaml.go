@@ -0,0 +1,796 @@
+package cfgfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlLine is one source line. text has the indentation, trailing comment
+// and trailing whitespace removed; it is "" for blank and comment lines.
+type yamlLine struct {
+	n      int
+	indent int
+	tab    bool // indentation contains a tab
+	text   string
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	started, ended := false, false
+	for n, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		if n == 0 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+		l := yamlLine{n: n + 1, raw: raw}
+		for l.indent < len(raw) && raw[l.indent] == ' ' {
+			l.indent++
+		}
+		rest := raw[l.indent:]
+		l.tab = strings.HasPrefix(rest, "\t") && strings.TrimSpace(rest) != ""
+		l.text = strings.TrimRight(stripYAMLComment(rest), " \t")
+		if l.indent == 0 && (l.text == "---" || strings.HasPrefix(l.text, "--- ")) {
+			if started || ended {
+				return nil, fmt.Errorf("line %d: multiple YAML documents are not supported", l.n)
+			}
+			started = true
+			if l.text = strings.TrimSpace(l.text[3:]); l.text == "" {
+				l.raw = ""
+			}
+		} else if l.indent == 0 && l.text == "..." {
+			ended = true
+			l.text, l.raw = "", ""
+		} else if l.indent == 0 && strings.HasPrefix(l.text, "%") && !started {
+			l.text, l.raw = "", ""
+		} else if ended && l.text != "" {
+			return nil, fmt.Errorf("line %d: content after the end of the document", l.n)
+		}
+		if l.text != "" {
+			started = true
+		}
+		p.lines = append(p.lines, l)
+	}
+	if !p.skip() {
+		return nil, nil
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	v, err := p.block(p.cur().indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.skip() {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.cur().n)
+	}
+	return v, nil
+}
+
+// stripYAMLComment cuts a trailing # comment, ignoring # inside quoted
+// scalars and # not preceded by whitespace.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,", s[i-1]) >= 0):
+			quote = c
+		}
+	}
+	return s
+}
+
+// skip moves past blank lines and reports whether a content line remains.
+func (p *yamlParser) skip() bool {
+	for p.i < len(p.lines) && p.lines[p.i].text == "" {
+		p.i++
+	}
+	return p.i < len(p.lines)
+}
+
+func (p *yamlParser) cur() *yamlLine { return &p.lines[p.i] }
+
+func (p *yamlParser) check() error {
+	if l := p.cur(); l.tab {
+		return fmt.Errorf("line %d: tabs are not allowed in indentation", l.n)
+	}
+	return nil
+}
+
+func isSeqEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the collection or scalar starting at the current line.
+func (p *yamlParser) block(indent int) (any, error) {
+	l := p.cur()
+	if isSeqEntry(l.text) {
+		return p.seq(indent)
+	}
+	if _, _, ok, err := splitYAMLKey(l.text); err != nil {
+		return nil, fmt.Errorf("line %d: %w", l.n, err)
+	} else if ok {
+		return p.mapping(indent)
+	}
+	p.i++
+	return p.value(l.text, l, indent, false)
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	o := NewObject()
+	for p.skip() {
+		l := p.cur()
+		if l.indent < indent {
+			break
+		}
+		if err := p.check(); err != nil {
+			return nil, err
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.n)
+		}
+		key, rest, ok, err := splitYAMLKey(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.n, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", l.n)
+		}
+		if key == "<<" {
+			return nil, fmt.Errorf("line %d: merge keys are not supported", l.n)
+		}
+		if _, dup := o.Get(key); dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.n, key)
+		}
+		p.i++
+		v, err := p.value(rest, l, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		o.Set(key, v, l.n)
+	}
+	return o, nil
+}
+
+func (p *yamlParser) seq(indent int) (any, error) {
+	arr := []any{}
+	for p.skip() {
+		l := p.cur()
+		if l.indent < indent {
+			break
+		}
+		if err := p.check(); err != nil {
+			return nil, err
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.n)
+		}
+		if !isSeqEntry(l.text) {
+			break
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest != "" {
+			_, _, isKey, err := splitYAMLKey(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.n, err)
+			}
+			if isKey || isSeqEntry(rest) {
+				// "- key: value" and "- - x" start a collection on the
+				// entry's own line; parse it as if it began there.
+				off := indent + len(l.text) - len(rest)
+				l.indent, l.text = off, rest
+				v, err := p.block(off)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+				continue
+			}
+		}
+		p.i++
+		v, err := p.value(rest, l, indent, false)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+// value parses the value text that follows "key:" or "-" on line l, along
+// with any lines it continues onto. indent is the key's or dash's column.
+func (p *yamlParser) value(rest string, l *yamlLine, indent int, inMap bool) (any, error) {
+	if rest == "" {
+		if !p.skip() {
+			return nil, nil
+		}
+		next := p.cur()
+		if next.indent > indent {
+			if err := p.check(); err != nil {
+				return nil, err
+			}
+			return p.block(next.indent)
+		}
+		if inMap && next.indent == indent && isSeqEntry(next.text) {
+			return p.seq(indent)
+		}
+		return nil, nil
+	}
+	switch rest[0] {
+	case '|', '>':
+		return p.blockScalar(rest, l, indent)
+	case '[', '{':
+		text := rest
+		for depth := flowDepth(text); depth > 0; depth = flowDepth(text) {
+			if !p.skip() {
+				return nil, fmt.Errorf("line %d: unterminated flow collection", l.n)
+			}
+			text += " " + p.cur().text
+			p.i++
+		}
+		f := &yamlFlow{s: text, line: l.n}
+		v, err := f.value(false)
+		if err != nil {
+			return nil, err
+		}
+		if f.ws(); f.pos < len(f.s) {
+			return nil, fmt.Errorf("line %d: unexpected %q after flow collection", l.n, f.s[f.pos:])
+		}
+		return v, nil
+	}
+	return yamlScalar(rest, l.n)
+}
+
+// flowDepth returns how many flow brackets in s are still open.
+func flowDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+var blockHeader = regexp.MustCompile(`^([|>])([1-9]?)([+-]?)([1-9]?)$`)
+
+func (p *yamlParser) blockScalar(header string, l *yamlLine, indent int) (any, error) {
+	m := blockHeader.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("line %d: invalid block scalar header %q", l.n, header)
+	}
+	folded, chomp := m[1] == ">", m[3]
+	content := 0
+	if d := m[2] + m[4]; d != "" {
+		n, _ := strconv.Atoi(d)
+		content = indent + n
+	}
+	var body []string
+	for ; p.i < len(p.lines); p.i++ {
+		raw := p.lines[p.i].raw
+		if strings.TrimSpace(raw) == "" {
+			body = append(body, "")
+			continue
+		}
+		ind := p.lines[p.i].indent
+		if content == 0 {
+			if ind <= indent {
+				break
+			}
+			content = ind
+		}
+		if ind < content {
+			break
+		}
+		body = append(body, raw[content:])
+	}
+	trailing := 0
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+		trailing++
+	}
+	var b strings.Builder
+	if folded {
+		blanks, prev := 0, ""
+		for i, ln := range body {
+			if ln == "" {
+				blanks++
+				continue
+			}
+			if i == blanks {
+				b.WriteString(strings.Repeat("\n", blanks))
+			} else {
+				more := strings.HasPrefix(prev, " ") || strings.HasPrefix(prev, "\t") ||
+					strings.HasPrefix(ln, " ") || strings.HasPrefix(ln, "\t")
+				switch {
+				case more:
+					b.WriteString(strings.Repeat("\n", blanks+1))
+				case blanks == 0:
+					b.WriteByte(' ')
+				default:
+					b.WriteString(strings.Repeat("\n", blanks))
+				}
+			}
+			b.WriteString(ln)
+			prev, blanks = ln, 0
+		}
+	} else {
+		b.WriteString(strings.Join(body, "\n"))
+	}
+	switch {
+	case chomp == "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	case chomp == "" && len(body) > 0:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// splitYAMLKey splits "key: value" into its key and value text. ok is
+// false if text is not a mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" {
+		return "", "", false, nil
+	}
+	switch text[0] {
+	case '"', '\'':
+		s, n, err := yamlQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(text[n:], " ")
+		if after == ":" || strings.HasPrefix(after, ": ") {
+			return s, strings.TrimSpace(after[1:]), true, nil
+		}
+		return "", "", false, nil
+	case '[', '{':
+		return "", "", false, nil
+	case '?':
+		if text == "?" || strings.HasPrefix(text, "? ") {
+			return "", "", false, fmt.Errorf("complex keys are not supported")
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+			key = strings.TrimRight(text[:i], " \t")
+			if strings.HasPrefix(key, "&") || strings.HasPrefix(key, "*") || strings.HasPrefix(key, "!") {
+				return "", "", false, fmt.Errorf("anchors, aliases and tags are not supported")
+			}
+			return key, strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+func yamlScalar(s string, line int) (any, error) {
+	switch s[0] {
+	case '"', '\'':
+		v, n, err := yamlQuoted(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(s[n:]) != "" {
+			return nil, fmt.Errorf("line %d: unexpected %q after quoted string", line, strings.TrimSpace(s[n:]))
+		}
+		return v, nil
+	case '&', '*':
+		return nil, fmt.Errorf("line %d: anchors and aliases are not supported", line)
+	case '!':
+		return nil, fmt.Errorf("line %d: tags are not supported", line)
+	case '@', '`':
+		return nil, fmt.Errorf("line %d: %q cannot start a plain scalar; quote the value", line, s[:1])
+	}
+	v, err := resolvePlain(s)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	return v, nil
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlInf   = regexp.MustCompile(`^[-+]?\.(inf|Inf|INF)$|^\.(nan|NaN|NAN)$`)
+)
+
+// resolvePlain types a plain scalar by the YAML 1.2 core schema.
+func resolvePlain(s string) (any, error) {
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	switch {
+	case yamlOct.MatchString(s), yamlHex.MatchString(s):
+		base := 8
+		if s[1] == 'x' {
+			base = 16
+		}
+		n, err := strconv.ParseInt(s[2:], base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("integer %s out of range", s)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case yamlInt.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10)), nil
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case yamlFloat.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %s out of range", s)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case yamlInf.MatchString(s):
+		return nil, fmt.Errorf("%s cannot be represented in JSON", s)
+	}
+	return s, nil
+}
+
+// yamlQuoted decodes the quoted scalar at the start of s and returns it
+// with the number of bytes consumed.
+func yamlQuoted(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if q == '\'' {
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), i + 1, nil
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape in quoted string")
+			}
+			i++
+			switch e := s[i]; e {
+			case 'x', 'u', 'U':
+				n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+				if i+n >= len(s) {
+					return "", 0, fmt.Errorf("invalid escape \\%c", e)
+				}
+				r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape \\%c%s", e, s[i+1:i+1+n])
+				}
+				b.WriteRune(rune(r))
+				i += n
+			default:
+				r, ok := yamlEscapes[e]
+				if !ok {
+					return "", 0, fmt.Errorf("invalid escape \\%c", e)
+				}
+				b.WriteString(r)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string (multi-line quoted strings are not supported)")
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+}
+
+// yamlFlow parses a flow collection such as [a, b] or {k: v}.
+type yamlFlow struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (f *yamlFlow) ws() {
+	for f.pos < len(f.s) && (f.s[f.pos] == ' ' || f.s[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: "+format, append([]any{f.line}, args...)...)
+}
+
+func (f *yamlFlow) value(inMap bool) (any, error) {
+	f.ws()
+	if f.pos >= len(f.s) {
+		return nil, f.errorf("unexpected end of flow collection")
+	}
+	switch c := f.s[f.pos]; c {
+	case '[':
+		f.pos++
+		arr := []any{}
+		for {
+			f.ws()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return arr, nil
+			}
+			v, err := f.value(false)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			if err := f.sep(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		o := NewObject()
+		for {
+			f.ws()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return o, nil
+			}
+			k, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+				if k == nil {
+					key = "null"
+				}
+			}
+			if _, dup := o.Get(key); dup {
+				return nil, f.errorf("duplicate key %q", key)
+			}
+			f.ws()
+			var v any
+			if f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				if v, err = f.value(true); err != nil {
+					return nil, err
+				}
+			}
+			o.Set(key, v, f.line)
+			if err := f.sep('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		s, n, err := yamlQuoted(f.s[f.pos:])
+		if err != nil {
+			return nil, f.errorf("%v", err)
+		}
+		f.pos += n
+		return s, nil
+	case ']', '}', ',':
+		if c == ',' || !inMap {
+			return nil, f.errorf("unexpected %q", c)
+		}
+		return nil, nil
+	}
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if c == ':' && inMap && (f.pos+1 == len(f.s) || strings.IndexByte(" ,]}", f.s[f.pos+1]) >= 0) {
+			break
+		}
+		f.pos++
+	}
+	text := strings.TrimSpace(f.s[start:f.pos])
+	if text == "" {
+		return nil, nil
+	}
+	return yamlScalar(text, f.line)
+}
+
+// sep consumes the "," between entries or the closing bracket, leaving the
+// closing bracket for the caller's loop.
+func (f *yamlFlow) sep(end byte) error {
+	f.ws()
+	if f.pos >= len(f.s) {
+		return f.errorf("unterminated flow collection")
+	}
+	switch f.s[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case end:
+		return nil
+	}
+	return f.errorf("expected ',' or %q, got %q", end, f.s[f.pos:])
+}
+
+// --- encoding ---
+
+func encodeYAML(root *Object) []byte {
+	var b bytes.Buffer
+	if root.Len() == 0 {
+		return []byte("{}\n")
+	}
+	yamlMap(&b, root, 0)
+	return b.Bytes()
+}
+
+func yamlMap(b *bytes.Buffer, o *Object, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, k := range o.keys {
+		b.WriteString(pad + yamlString(k) + ":")
+		yamlValue(b, o.values[k], indent, indent+2)
+	}
+}
+
+func yamlSeq(b *bytes.Buffer, arr []any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, e := range arr {
+		switch v := e.(type) {
+		case *Object:
+			if v.Len() > 0 {
+				var sub bytes.Buffer
+				yamlMap(&sub, v, indent+2)
+				b.WriteString(pad + "- " + sub.String()[indent+2:])
+				continue
+			}
+		case []any:
+			if len(v) > 0 {
+				b.WriteString(pad + "-\n")
+				yamlSeq(b, v, indent+2)
+				continue
+			}
+		}
+		b.WriteString(pad + "-")
+		yamlValue(b, e, indent, indent+2)
+	}
+}
+
+// yamlValue writes v after a "key:" or "-" at indent; nested blocks go at
+// child.
+func yamlValue(b *bytes.Buffer, v any, indent, child int) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString(" null\n")
+	case bool:
+		b.WriteString(" " + strconv.FormatBool(v) + "\n")
+	case json.Number:
+		b.WriteString(" " + string(v) + "\n")
+	case string:
+		if block, ok := yamlBlock(v, child); ok {
+			b.WriteString(" " + block)
+			return
+		}
+		b.WriteString(" " + yamlString(v) + "\n")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlSeq(b, v, child)
+	case *Object:
+		if v.Len() == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		yamlMap(b, v, child)
+	}
+}
+
+// yamlBlock renders a multi-line string as a literal block scalar, if it
+// can round-trip as one.
+func yamlBlock(s string, indent int) (string, bool) {
+	if !strings.Contains(strings.TrimRight(s, "\n"), "\n") {
+		return "", false
+	}
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") || strings.HasSuffix(s, "\n\n") ||
+		strings.ContainsAny(s, "\r\t") || strings.Contains(s, " \n") {
+		return "", false
+	}
+	for _, r := range s {
+		if r < 0x20 && r != '\n' || r == utf8.RuneError {
+			return "", false
+		}
+	}
+	header := "|-"
+	body := s
+	if strings.HasSuffix(s, "\n") {
+		header, body = "|", strings.TrimSuffix(s, "\n")
+	}
+	pad := strings.Repeat(" ", indent)
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for _, ln := range strings.Split(body, "\n") {
+		if ln == "" {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(pad + ln + "\n")
+		}
+	}
+	return b.String(), true
+}
+
+// yamlString writes s as a plain scalar when YAML readers would take it
+// back as the same string, and double-quoted otherwise.
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return jsonString(s)
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return jsonString(s)
+		}
+	}
+	if v, err := resolvePlain(s); err != nil || v != s {
+		return jsonString(s)
+	}
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", ".inf", "-.inf", "+.inf", ".nan":
+		return jsonString(s)
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err == nil {
+		return jsonString(s)
+	}
+	return s
+}
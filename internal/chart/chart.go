@@ -0,0 +1,378 @@
+// Package chart draws line, bar and pie charts from tabular data as SVG or
+// PNG, with the standard library alone.
+//
+// SVG keeps the labels as text in any script. PNG, which chat apps show
+// inline, draws labels with a built-in bitmap font that covers ASCII; other
+// characters are shown as "?".
+package chart
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kind is a chart type.
+type Kind string
+
+const (
+	Line Kind = "line"
+	Bar  Kind = "bar"
+	Pie  Kind = "pie"
+)
+
+// Series is a named row of values, one per label.
+type Series struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// Chart is the data and look of a chart. A pie chart draws its first series.
+type Chart struct {
+	Kind   Kind
+	Title  string
+	Labels []string // x-axis categories, or pie slices
+	Series []Series
+	Width  int // default 800
+	Height int // default 450
+}
+
+const maxPoints = 1000
+
+// Validate checks the chart and fills the default size.
+func (c *Chart) Validate() error {
+	switch c.Kind {
+	case Line, Bar, Pie:
+	case "":
+		c.Kind = Bar
+	default:
+		return fmt.Errorf("chart type must be line, bar or pie, not %q", c.Kind)
+	}
+	if len(c.Labels) == 0 || len(c.Series) == 0 {
+		return fmt.Errorf("chart needs labels and at least one series")
+	}
+	if len(c.Labels)*len(c.Series) > maxPoints {
+		return fmt.Errorf("chart has %d values; at most %d are drawn", len(c.Labels)*len(c.Series), maxPoints)
+	}
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Labels) {
+			return fmt.Errorf("series %q has %d values for %d labels", s.Name, len(s.Values), len(c.Labels))
+		}
+		for _, v := range s.Values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("series %q has a value that is not a number", s.Name)
+			}
+			if c.Kind == Pie && v < 0 {
+				return fmt.Errorf("pie slices cannot be negative")
+			}
+		}
+	}
+	if c.Width <= 0 {
+		c.Width = 800
+	}
+	if c.Height <= 0 {
+		c.Height = 450
+	}
+	c.Width, c.Height = min(max(c.Width, 200), 2000), min(max(c.Height, 150), 2000)
+	return nil
+}
+
+// ParseTable reads CSV (or tab-separated) text whose first row holds the
+// series names and whose first column holds the labels. Currency symbols,
+// thousands separators and percent signs are ignored in values.
+func ParseTable(text string) ([]string, []Series, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimSpace(text)))
+	if first, _, _ := strings.Cut(text, "\n"); strings.Count(first, "\t") > strings.Count(first, ",") {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("table: %w", err)
+	}
+	if len(rows) < 2 || len(rows[0]) < 2 {
+		return nil, nil, fmt.Errorf("table needs a header row, a label column and at least one value column")
+	}
+	series := make([]Series, len(rows[0])-1)
+	for i := range series {
+		series[i].Name = strings.TrimSpace(rows[0][i+1])
+	}
+	var labels []string
+	for n, row := range rows[1:] {
+		labels = append(labels, strings.TrimSpace(row[0]))
+		for i := range series {
+			v := 0.0
+			if i+1 < len(row) {
+				if v, err = parseNumber(row[i+1]); err != nil {
+					return nil, nil, fmt.Errorf("table row %d: %w", n+2, err)
+				}
+			}
+			series[i].Values = append(series[i].Values, v)
+		}
+	}
+	return labels, series, nil
+}
+
+func parseNumber(s string) (float64, error) {
+	clean := strings.Map(func(r rune) rune {
+		switch r {
+		case ',', ' ', '$', '€', '£', '¥', '%', ' ':
+			return -1
+		}
+		return r
+	}, s)
+	if clean == "" || clean == "-" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(clean, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return v, nil
+}
+
+// SVG renders the chart as an SVG document.
+func (c Chart) SVG() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	s := newSVG(c.Width, c.Height)
+	draw(&c, s)
+	return s.bytes(), nil
+}
+
+// PNG renders the chart as a PNG image.
+func (c Chart) PNG() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	r := newRaster(c.Width, c.Height)
+	draw(&c, r)
+	return r.png()
+}
+
+// --- Drawing ---
+
+type rgb [3]uint8
+
+var (
+	palette = []rgb{
+		{0x4E, 0x79, 0xA7}, {0xF2, 0x8E, 0x2B}, {0xE1, 0x57, 0x59}, {0x76, 0xB7, 0xB2}, {0x59, 0xA1, 0x4F},
+		{0xED, 0xC9, 0x48}, {0xB0, 0x7A, 0xA1}, {0xFF, 0x9D, 0xA7}, {0x9C, 0x75, 0x5F}, {0xBA, 0xB0, 0xAC},
+	}
+	white = rgb{0xFF, 0xFF, 0xFF}
+	ink   = rgb{0x33, 0x33, 0x33}
+	muted = rgb{0x77, 0x77, 0x77}
+	grid  = rgb{0xE4, 0xE4, 0xE4}
+)
+
+type anchor int
+
+const (
+	start anchor = iota
+	middle
+	end
+)
+
+// canvas is what both renderers draw on. Coordinates are pixels from the
+// top left; text is placed by its baseline.
+type canvas interface {
+	rect(x, y, w, h float64, c rgb)
+	line(pts [][2]float64, width float64, c rgb)
+	circle(x, y, r float64, c rgb)
+	wedge(x, y, r, a0, a1 float64, c rgb)
+	text(x, y float64, s string, size float64, c rgb, a anchor, bold bool)
+}
+
+// textWidth estimates the width of s. Both renderers size their text to
+// this, so layouts match.
+func textWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.6
+}
+
+// fit shortens s to at most width pixels.
+func fit(s string, size, width float64) string {
+	r := []rune(s)
+	n := int(width / (size * 0.6))
+	if len(r) <= n {
+		return s
+	}
+	if n < 2 {
+		return ""
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func draw(c *Chart, cv canvas) {
+	w, h := float64(c.Width), float64(c.Height)
+	cv.rect(0, 0, w, h, white)
+	top := 16.0
+	if c.Title != "" {
+		cv.text(w/2, 32, fit(c.Title, 18, w-32), 18, ink, middle, true)
+		top = 52
+	}
+	if c.Kind == Pie {
+		drawPie(c, cv, top)
+		return
+	}
+
+	// Legend row under the title when there is more than one series.
+	if len(c.Series) > 1 {
+		x := 48.0
+		for i, s := range c.Series {
+			name := fit(s.Name, 12, 160)
+			if x+textWidth(name, 12)+24 > w && x > 48 {
+				break
+			}
+			cv.rect(x, top, 12, 12, palette[i%len(palette)])
+			cv.text(x+18, top+11, name, 12, ink, start, false)
+			x += textWidth(name, 12) + 40
+		}
+		top += 28
+	}
+
+	lo, hi := 0.0, 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	ticks := niceTicks(lo, hi, 5)
+	lo, hi = ticks[0], ticks[len(ticks)-1]
+	left := 16.0
+	for _, t := range ticks {
+		left = math.Max(left, textWidth(formatNumber(t), 12)+24)
+	}
+	right, bottom := w-24, h-40
+	plotH := bottom - top
+	y := func(v float64) float64 { return bottom - (v-lo)/(hi-lo)*plotH }
+
+	for _, t := range ticks {
+		cv.line([][2]float64{{left, y(t)}, {right, y(t)}}, 1, grid)
+		cv.text(left-8, y(t)+4, formatNumber(t), 12, muted, end, false)
+	}
+
+	band := (right - left) / float64(len(c.Labels))
+	// Show every step-th label so they do not overlap.
+	widest := 0.0
+	for _, l := range c.Labels {
+		widest = math.Max(widest, textWidth(fit(l, 12, 120), 12))
+	}
+	step := int(math.Ceil((widest + 8) / band))
+	for i, l := range c.Labels {
+		if i%max(step, 1) == 0 {
+			cv.text(left+band*(float64(i)+0.5), bottom+20, fit(l, 12, math.Max(band*float64(max(step, 1))-8, 24)), 12, muted, middle, false)
+		}
+	}
+
+	switch c.Kind {
+	case Bar:
+		n := float64(len(c.Series))
+		gap := band * 0.2
+		bw := (band - gap) / n
+		for si, s := range c.Series {
+			for i, v := range s.Values {
+				x := left + band*float64(i) + gap/2 + bw*float64(si)
+				y0, y1 := y(0), y(v)
+				cv.rect(x, math.Min(y0, y1), math.Max(bw-1, 1), math.Abs(y0-y1), palette[si%len(palette)])
+			}
+		}
+	case Line:
+		for si, s := range c.Series {
+			pts := make([][2]float64, len(s.Values))
+			for i, v := range s.Values {
+				pts[i] = [2]float64{left + band*(float64(i)+0.5), y(v)}
+			}
+			col := palette[si%len(palette)]
+			cv.line(pts, 2.5, col)
+			if len(pts) <= 40 {
+				for _, p := range pts {
+					cv.circle(p[0], p[1], 3.5, col)
+				}
+			}
+		}
+	}
+	cv.line([][2]float64{{left, y(math.Max(lo, 0))}, {right, y(math.Max(lo, 0))}}, 1, muted)
+}
+
+func drawPie(c *Chart, cv canvas, top float64) {
+	w, h := float64(c.Width), float64(c.Height)
+	vals := c.Series[0].Values
+	total := 0.0
+	for _, v := range vals {
+		total += v
+	}
+	r := math.Min((h-top-24)/2, w*0.3)
+	cx, cy := 24+r, top+(h-top)/2-4
+	a := -math.Pi / 2
+	for i, v := range vals {
+		if total == 0 || v == 0 {
+			continue
+		}
+		sweep := v / total * 2 * math.Pi
+		cv.wedge(cx, cy, r, a, a+sweep, palette[i%len(palette)])
+		a += sweep
+	}
+
+	// Legend to the right, one slice per row.
+	x := cx + r + 40
+	rowH := 22.0
+	rows := int((h - top - 16) / rowH)
+	for i, l := range c.Labels {
+		if i >= rows {
+			break
+		}
+		ly := top + 8 + rowH*float64(i)
+		pct := 0.0
+		if total > 0 {
+			pct = vals[i] / total * 100
+		}
+		label := fmt.Sprintf("%s  %s (%.0f%%)", l, formatNumber(vals[i]), pct)
+		cv.rect(x, ly, 12, 12, palette[i%len(palette)])
+		cv.text(x+20, ly+11, fit(label, 13, w-x-32), 13, ink, start, false)
+	}
+}
+
+// niceTicks returns about n evenly spaced round values covering lo..hi.
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi == lo {
+		hi = lo + 1
+	}
+	raw := (hi - lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag * 10
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= m*mag {
+			step = m * mag
+			break
+		}
+	}
+	var ticks []float64
+	for t := math.Floor(lo/step) * step; t < hi+step*0.999; t += step {
+		ticks = append(ticks, math.Round(t/step)*step)
+	}
+	return ticks
+}
+
+// formatNumber writes an axis or legend value compactly: 1.5k, 2M, 0.25.
+func formatNumber(v float64) string {
+	av := math.Abs(v)
+	suffix := ""
+	switch {
+	case av >= 1e9:
+		v, suffix = v/1e9, "B"
+	case av >= 1e6:
+		v, suffix = v/1e6, "M"
+	case av >= 1e4:
+		v, suffix = v/1e3, "k"
+	}
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s + suffix
+}
@@ -0,0 +1,151 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTable(t *testing.T) {
+	labels, series, err := ParseTable("Month,Revenue,Costs\nJan,\"$1,200\",800\nFeb,1500,\nMar,900.5,950\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(labels, []string{"Jan", "Feb", "Mar"}) {
+		t.Errorf("labels = %v", labels)
+	}
+	want := []Series{{Name: "Revenue", Values: []float64{1200, 1500, 900.5}}, {Name: "Costs", Values: []float64{800, 0, 950}}}
+	if !reflect.DeepEqual(series, want) {
+		t.Errorf("series = %+v", series)
+	}
+	if _, s, err := ParseTable("a\tb\nx\t3%"); err != nil || s[0].Values[0] != 3 {
+		t.Errorf("tab-separated = %+v, %v", s, err)
+	}
+	if _, _, err := ParseTable("a,b\nx,lots"); err == nil {
+		t.Error("non-number accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Chart{
+		{Kind: "radar", Labels: []string{"a"}, Series: []Series{{Values: []float64{1}}}},
+		{Kind: Bar, Labels: []string{"a", "b"}, Series: []Series{{Values: []float64{1}}}},
+		{Kind: Pie, Labels: []string{"a"}, Series: []Series{{Values: []float64{-1}}}},
+		{Kind: Line},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	if got := niceTicks(0, 93, 5); !reflect.DeepEqual(got, []float64{0, 20, 40, 60, 80, 100}) {
+		t.Errorf("niceTicks(0, 93) = %v", got)
+	}
+	if got := niceTicks(-12, 30, 5); got[0] > -12 || got[len(got)-1] < 30 {
+		t.Errorf("niceTicks(-12, 30) = %v", got)
+	}
+	for v, want := range map[float64]string{0: "0", 2.5: "2.5", 12000: "12k", 3400000: "3.4M", -0.001: "0"} {
+		if got := formatNumber(v); got != want {
+			t.Errorf("formatNumber(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+var sample = Chart{
+	Title:  "Revenue & costs",
+	Labels: []string{"Jan", "Feb", "Mar", "Apr"},
+	Series: []Series{{Name: "Revenue", Values: []float64{1200, 1500, 900, 1800}}, {Name: "Costs", Values: []float64{800, 700, 950, 1000}}},
+}
+
+func TestSVG(t *testing.T) {
+	for _, kind := range []Kind{Line, Bar, Pie} {
+		c := sample
+		c.Kind = kind
+		data, err := c.SVG()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", kind, err)
+			}
+		}
+		if !bytes.Contains(data, []byte("Revenue &amp; costs")) {
+			t.Errorf("%s: title missing", kind)
+		}
+		if kind == Pie && !bytes.Contains(data, []byte("Apr  1800 (33%)")) {
+			t.Errorf("pie legend missing:\n%s", data)
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c := sample
+	c.Kind, c.Width, c.Height = Bar, 400, 300
+	data, err := c.PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 300 {
+		t.Errorf("size = %v", b)
+	}
+	// The first bar is drawn in the first palette color.
+	found := false
+	for y := 0; y < 300 && !found; y++ {
+		for x := 0; x < 400; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if uint8(r>>8) == palette[0][0] && uint8(g>>8) == palette[0][1] && uint8(b>>8) == palette[0][2] {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		t.Error("no bar pixels")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	rel, err := Save(dir, "Q3 revenue", "png", []byte("x"), now)
+	if err != nil || rel != filepath.Join("2026-10-15", "q3-revenue.png") {
+		t.Fatalf("Save = %q, %v", rel, err)
+	}
+	rel2, _ := Save(dir, "Q3 revenue", "png", []byte("y"), now)
+	if !strings.HasSuffix(rel2, "q3-revenue-2.png") {
+		t.Errorf("second Save = %q", rel2)
+	}
+
+	text := "Here is the trend:\n\n![Q3 revenue](" + URL(rel) + ")\n\nAnd ![gone](/api/charts/2026-10-15/missing.png) and ![x](/api/charts/../../etc/passwd)"
+	out, images := ExtractImages(text, dir)
+	if len(images) != 1 || images[0].Title != "Q3 revenue" || images[0].Path != filepath.Join(dir, rel) {
+		t.Errorf("images = %+v", images)
+	}
+	if strings.Contains(out, "q3-revenue") || !strings.Contains(out, "missing.png") || !strings.Contains(out, "passwd") {
+		t.Errorf("text = %q", out)
+	}
+	if _, ok := Resolve(dir, "../"+filepath.Base(dir)+"/"+rel); ok {
+		t.Error("path outside dir resolved")
+	}
+	os.Remove(filepath.Join(dir, rel))
+	if _, images := ExtractImages(text, dir); len(images) != 0 {
+		t.Error("deleted chart extracted")
+	}
+}
@@ -0,0 +1,125 @@
+package chart
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// URLPrefix is where the HTTP API serves saved charts. Replies reference a
+// chart as a Markdown image with this URL, which the dashboard shows and the
+// chat bots replace with an uploaded image.
+const URLPrefix = "/api/charts/"
+
+// Save writes a chart image to dir/<date>/<name>.<ext>, adding a number to
+// the name when the file exists, and returns the path relative to dir.
+func Save(dir, name, ext string, data []byte, now time.Time) (string, error) {
+	day := now.Format("2006-01-02")
+	if err := os.MkdirAll(filepath.Join(dir, day), 0o755); err != nil {
+		return "", err
+	}
+	base := slug(name)
+	for i := 1; ; i++ {
+		rel := filepath.Join(day, base+"."+ext)
+		if i > 1 {
+			rel = filepath.Join(day, fmt.Sprintf("%s-%d.%s", base, i, ext))
+		}
+		f, err := os.OpenFile(filepath.Join(dir, rel), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", err
+		}
+		return rel, f.Close()
+	}
+}
+
+// URL returns the API URL of a chart saved at rel.
+func URL(rel string) string {
+	return URLPrefix + filepath.ToSlash(rel)
+}
+
+// slug makes a file name from a title.
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "chart"
+	}
+	return b.String()
+}
+
+// Resolve returns the file of a chart URL or relative path if it lies in
+// dir and exists.
+func Resolve(dir, ref string) (string, bool) {
+	rel := filepath.FromSlash(strings.TrimPrefix(ref, URLPrefix))
+	if rel == "" || filepath.IsAbs(rel) || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	p := filepath.Join(dir, rel)
+	if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+		return "", false
+	}
+	return p, true
+}
+
+var imageRef = regexp.MustCompile(`!\[([^\]]*)\]\(` + regexp.QuoteMeta(URLPrefix) + `([^)\s]+)\)[ \t]*\n?`)
+
+// Image is a chart referenced by a reply.
+type Image struct {
+	Title string
+	Path  string
+}
+
+// ContentType returns the MIME type of the image file.
+func (i Image) ContentType() string {
+	if strings.HasSuffix(i.Path, ".svg") {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// ExtractImages removes the chart images that text references and that
+// exist in dir, for a bot to upload them after the text.
+func ExtractImages(text, dir string) (string, []Image) {
+	if dir == "" {
+		return text, nil
+	}
+	var images []Image
+	out := imageRef.ReplaceAllStringFunc(text, func(m string) string {
+		sub := imageRef.FindStringSubmatch(m)
+		p, ok := Resolve(dir, sub[2])
+		if !ok {
+			return m
+		}
+		images = append(images, Image{Title: sub[1], Path: p})
+		return ""
+	})
+	if len(images) == 0 {
+		return text, nil
+	}
+	return strings.TrimSpace(out), images
+}
@@ -0,0 +1,184 @@
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// ss is the supersampling factor: shapes are drawn at ss times the size and
+// averaged down, which smooths their edges.
+const ss = 3
+
+type raster struct {
+	img  *image.RGBA
+	w, h int
+}
+
+func newRaster(w, h int) *raster {
+	return &raster{img: image.NewRGBA(image.Rect(0, 0, w*ss, h*ss)), w: w, h: h}
+}
+
+func (r *raster) set(x, y int, c rgb) {
+	if x >= 0 && y >= 0 && x < r.w*ss && y < r.h*ss {
+		i := r.img.PixOffset(x, y)
+		r.img.Pix[i], r.img.Pix[i+1], r.img.Pix[i+2], r.img.Pix[i+3] = c[0], c[1], c[2], 0xff
+	}
+}
+
+// fill sets every pixel in the box whose center passes inside.
+func (r *raster) fill(x0, y0, x1, y1 float64, inside func(x, y float64) bool, c rgb) {
+	ix0, iy0 := max(int(math.Floor(x0*ss)), 0), max(int(math.Floor(y0*ss)), 0)
+	ix1, iy1 := min(int(math.Ceil(x1*ss)), r.w*ss), min(int(math.Ceil(y1*ss)), r.h*ss)
+	for py := iy0; py < iy1; py++ {
+		for px := ix0; px < ix1; px++ {
+			if inside((float64(px)+0.5)/ss, (float64(py)+0.5)/ss) {
+				r.set(px, py, c)
+			}
+		}
+	}
+}
+
+func (r *raster) rect(x, y, w, h float64, c rgb) {
+	r.fill(x, y, x+w, y+h, func(float64, float64) bool { return true }, c)
+}
+
+func (r *raster) line(pts [][2]float64, width float64, c rgb) {
+	hw := width / 2
+	for i := 0; i+1 < len(pts); i++ {
+		ax, ay, bx, by := pts[i][0], pts[i][1], pts[i+1][0], pts[i+1][1]
+		dx, dy := bx-ax, by-ay
+		l2 := dx*dx + dy*dy
+		r.fill(math.Min(ax, bx)-hw, math.Min(ay, by)-hw, math.Max(ax, bx)+hw, math.Max(ay, by)+hw, func(x, y float64) bool {
+			t := 0.0
+			if l2 > 0 {
+				t = math.Max(0, math.Min(1, ((x-ax)*dx+(y-ay)*dy)/l2))
+			}
+			ex, ey := x-(ax+t*dx), y-(ay+t*dy)
+			return ex*ex+ey*ey <= hw*hw
+		}, c)
+	}
+}
+
+func (r *raster) circle(x, y, rad float64, c rgb) {
+	r.fill(x-rad, y-rad, x+rad, y+rad, func(px, py float64) bool {
+		return (px-x)*(px-x)+(py-y)*(py-y) <= rad*rad
+	}, c)
+}
+
+func (r *raster) wedge(x, y, rad, a0, a1 float64, c rgb) {
+	full := a1-a0 >= 2*math.Pi-1e-9
+	r.fill(x-rad, y-rad, x+rad, y+rad, func(px, py float64) bool {
+		if (px-x)*(px-x)+(py-y)*(py-y) > rad*rad {
+			return false
+		}
+		if full {
+			return true
+		}
+		a := math.Atan2(py-y, px-x)
+		for a < a0 {
+			a += 2 * math.Pi
+		}
+		return a < a1
+	}, c)
+}
+
+// text draws s with the 5x7 bitmap font, scaled so its cell is 0.6 size
+// wide like textWidth.
+func (r *raster) text(x, y float64, s string, size float64, c rgb, a anchor, bold bool) {
+	w := textWidth(s, size)
+	switch a {
+	case middle:
+		x -= w / 2
+	case end:
+		x -= w
+	}
+	cell := size * 0.6 // one glyph column block is cell/6 wide
+	px := cell / 6
+	top := y - 7*px
+	for _, ch := range s {
+		g, ok := font[ch]
+		if !ok {
+			g = font['?']
+		}
+		for col := 0; col < 5; col++ {
+			bits := g[col]
+			for row := 0; row < 7; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				gx, gy := x+float64(col)*px, top+float64(row)*px
+				extra := 0.0
+				if bold {
+					extra = px * 0.5
+				}
+				r.rect(gx, gy, px+extra, px, c)
+			}
+		}
+		x += cell
+	}
+}
+
+// png averages the supersampled image down and encodes it.
+func (r *raster) png() ([]byte, error) {
+	out := image.NewRGBA(image.Rect(0, 0, r.w, r.h))
+	for y := 0; y < r.h; y++ {
+		for x := 0; x < r.w; x++ {
+			var sum [3]int
+			for dy := 0; dy < ss; dy++ {
+				i := r.img.PixOffset(x*ss, y*ss+dy)
+				for dx := 0; dx < ss; dx++ {
+					sum[0] += int(r.img.Pix[i])
+					sum[1] += int(r.img.Pix[i+1])
+					sum[2] += int(r.img.Pix[i+2])
+					i += 4
+				}
+			}
+			n := ss * ss
+			out.SetRGBA(x, y, color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// font is a 5x7 ASCII font: five columns per glyph, bit 0 the top row.
+var font = map[rune][5]byte{
+	' ': {0x00, 0x00, 0x00, 0x00, 0x00}, '!': {0x00, 0x00, 0x5F, 0x00, 0x00}, '"': {0x00, 0x07, 0x00, 0x07, 0x00},
+	'#': {0x14, 0x7F, 0x14, 0x7F, 0x14}, '$': {0x24, 0x2A, 0x7F, 0x2A, 0x12}, '%': {0x23, 0x13, 0x08, 0x64, 0x62},
+	'&': {0x36, 0x49, 0x55, 0x22, 0x50}, '\'': {0x00, 0x05, 0x03, 0x00, 0x00}, '(': {0x00, 0x1C, 0x22, 0x41, 0x00},
+	')': {0x00, 0x41, 0x22, 0x1C, 0x00}, '*': {0x08, 0x2A, 0x1C, 0x2A, 0x08}, '+': {0x08, 0x08, 0x3E, 0x08, 0x08},
+	',': {0x00, 0x50, 0x30, 0x00, 0x00}, '-': {0x08, 0x08, 0x08, 0x08, 0x08}, '.': {0x00, 0x60, 0x60, 0x00, 0x00},
+	'/': {0x20, 0x10, 0x08, 0x04, 0x02}, '0': {0x3E, 0x51, 0x49, 0x45, 0x3E}, '1': {0x00, 0x42, 0x7F, 0x40, 0x00},
+	'2': {0x42, 0x61, 0x51, 0x49, 0x46}, '3': {0x21, 0x41, 0x45, 0x4B, 0x31}, '4': {0x18, 0x14, 0x12, 0x7F, 0x10},
+	'5': {0x27, 0x45, 0x45, 0x45, 0x39}, '6': {0x3C, 0x4A, 0x49, 0x49, 0x30}, '7': {0x01, 0x71, 0x09, 0x05, 0x03},
+	'8': {0x36, 0x49, 0x49, 0x49, 0x36}, '9': {0x06, 0x49, 0x49, 0x29, 0x1E}, ':': {0x00, 0x36, 0x36, 0x00, 0x00},
+	';': {0x00, 0x56, 0x36, 0x00, 0x00}, '<': {0x08, 0x14, 0x22, 0x41, 0x00}, '=': {0x14, 0x14, 0x14, 0x14, 0x14},
+	'>': {0x00, 0x41, 0x22, 0x14, 0x08}, '?': {0x02, 0x01, 0x51, 0x09, 0x06}, '@': {0x32, 0x49, 0x79, 0x41, 0x3E},
+	'A': {0x7E, 0x11, 0x11, 0x11, 0x7E}, 'B': {0x7F, 0x49, 0x49, 0x49, 0x36}, 'C': {0x3E, 0x41, 0x41, 0x41, 0x22},
+	'D': {0x7F, 0x41, 0x41, 0x22, 0x1C}, 'E': {0x7F, 0x49, 0x49, 0x49, 0x41}, 'F': {0x7F, 0x09, 0x09, 0x01, 0x01},
+	'G': {0x3E, 0x41, 0x41, 0x51, 0x32}, 'H': {0x7F, 0x08, 0x08, 0x08, 0x7F}, 'I': {0x00, 0x41, 0x7F, 0x41, 0x00},
+	'J': {0x20, 0x40, 0x41, 0x3F, 0x01}, 'K': {0x7F, 0x08, 0x14, 0x22, 0x41}, 'L': {0x7F, 0x40, 0x40, 0x40, 0x40},
+	'M': {0x7F, 0x02, 0x04, 0x02, 0x7F}, 'N': {0x7F, 0x04, 0x08, 0x10, 0x7F}, 'O': {0x3E, 0x41, 0x41, 0x41, 0x3E},
+	'P': {0x7F, 0x09, 0x09, 0x09, 0x06}, 'Q': {0x3E, 0x41, 0x51, 0x21, 0x5E}, 'R': {0x7F, 0x09, 0x19, 0x29, 0x46},
+	'S': {0x46, 0x49, 0x49, 0x49, 0x31}, 'T': {0x01, 0x01, 0x7F, 0x01, 0x01}, 'U': {0x3F, 0x40, 0x40, 0x40, 0x3F},
+	'V': {0x1F, 0x20, 0x40, 0x20, 0x1F}, 'W': {0x7F, 0x20, 0x18, 0x20, 0x7F}, 'X': {0x63, 0x14, 0x08, 0x14, 0x63},
+	'Y': {0x03, 0x04, 0x78, 0x04, 0x03}, 'Z': {0x61, 0x51, 0x49, 0x45, 0x43}, '[': {0x00, 0x7F, 0x41, 0x41, 0x00},
+	'\\': {0x02, 0x04, 0x08, 0x10, 0x20}, ']': {0x00, 0x41, 0x41, 0x7F, 0x00}, '^': {0x04, 0x02, 0x01, 0x02, 0x04},
+	'_': {0x40, 0x40, 0x40, 0x40, 0x40}, '`': {0x00, 0x01, 0x02, 0x04, 0x00}, 'a': {0x20, 0x54, 0x54, 0x54, 0x78},
+	'b': {0x7F, 0x48, 0x44, 0x44, 0x38}, 'c': {0x38, 0x44, 0x44, 0x44, 0x20}, 'd': {0x38, 0x44, 0x44, 0x48, 0x7F},
+	'e': {0x38, 0x54, 0x54, 0x54, 0x18}, 'f': {0x08, 0x7E, 0x09, 0x01, 0x02}, 'g': {0x0C, 0x52, 0x52, 0x52, 0x3E},
+	'h': {0x7F, 0x08, 0x04, 0x04, 0x78}, 'i': {0x00, 0x44, 0x7D, 0x40, 0x00}, 'j': {0x20, 0x40, 0x44, 0x3D, 0x00},
+	'k': {0x7F, 0x10, 0x28, 0x44, 0x00}, 'l': {0x00, 0x41, 0x7F, 0x40, 0x00}, 'm': {0x7C, 0x04, 0x18, 0x04, 0x78},
+	'n': {0x7C, 0x08, 0x04, 0x04, 0x78}, 'o': {0x38, 0x44, 0x44, 0x44, 0x38}, 'p': {0x7C, 0x14, 0x14, 0x14, 0x08},
+	'q': {0x08, 0x14, 0x14, 0x18, 0x7C}, 'r': {0x7C, 0x08, 0x04, 0x04, 0x08}, 's': {0x48, 0x54, 0x54, 0x54, 0x20},
+	't': {0x04, 0x3F, 0x44, 0x40, 0x20}, 'u': {0x3C, 0x40, 0x40, 0x20, 0x7C}, 'v': {0x1C, 0x20, 0x40, 0x20, 0x1C},
+	'w': {0x3C, 0x40, 0x30, 0x40, 0x3C}, 'x': {0x44, 0x28, 0x10, 0x28, 0x44}, 'y': {0x0C, 0x50, 0x50, 0x50, 0x3C},
+	'z': {0x44, 0x64, 0x54, 0x4C, 0x44}, '{': {0x00, 0x08, 0x36, 0x41, 0x00}, '|': {0x00, 0x00, 0x7F, 0x00, 0x00},
+	'}': {0x00, 0x41, 0x36, 0x08, 0x00}, '~': {0x02, 0x01, 0x02, 0x04, 0x02}, '…': {0x40, 0x00, 0x40, 0x00, 0x40},
+}
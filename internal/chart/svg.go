@@ -0,0 +1,76 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func newSVG(w, h int) *svgCanvas {
+	s := &svgCanvas{}
+	fmt.Fprintf(&s.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif">`+"\n", w, h, w, h)
+	return s
+}
+
+func (s *svgCanvas) bytes() []byte {
+	s.buf.WriteString("</svg>\n")
+	return s.buf.Bytes()
+}
+
+func (c rgb) hex() string { return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]) }
+
+func (s *svgCanvas) rect(x, y, w, h float64, c rgb) {
+	fmt.Fprintf(&s.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, c.hex())
+}
+
+func (s *svgCanvas) line(pts [][2]float64, width float64, c rgb) {
+	s.buf.WriteString(`<polyline points="`)
+	for i, p := range pts {
+		if i > 0 {
+			s.buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&s.buf, "%.1f,%.1f", p[0], p[1])
+	}
+	fmt.Fprintf(&s.buf, `" fill="none" stroke="%s" stroke-width="%.1f" stroke-linejoin="round" stroke-linecap="round"/>`+"\n", c.hex(), width)
+}
+
+func (s *svgCanvas) circle(x, y, r float64, c rgb) {
+	fmt.Fprintf(&s.buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, r, c.hex())
+}
+
+func (s *svgCanvas) wedge(x, y, r, a0, a1 float64, c rgb) {
+	if a1-a0 >= 2*math.Pi-1e-9 {
+		s.circle(x, y, r, c)
+		return
+	}
+	large := 0
+	if a1-a0 > math.Pi {
+		large = 1
+	}
+	fmt.Fprintf(&s.buf, `<path d="M%.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 %d 1 %.1f,%.1f Z" fill="%s" stroke="#ffffff" stroke-width="1"/>`+"\n",
+		x, y, x+r*math.Cos(a0), y+r*math.Sin(a0), r, r, large, x+r*math.Cos(a1), y+r*math.Sin(a1), c.hex())
+}
+
+func (s *svgCanvas) text(x, y float64, str string, size float64, c rgb, a anchor, bold bool) {
+	if str == "" {
+		return
+	}
+	fmt.Fprintf(&s.buf, `<text x="%.1f" y="%.1f" font-size="%.0f" fill="%s"`, x, y, size, c.hex())
+	switch a {
+	case middle:
+		s.buf.WriteString(` text-anchor="middle"`)
+	case end:
+		s.buf.WriteString(` text-anchor="end"`)
+	}
+	if bold {
+		s.buf.WriteString(` font-weight="bold"`)
+	}
+	s.buf.WriteByte('>')
+	xml.EscapeText(&s.buf, []byte(str))
+	s.buf.WriteString("</text>\n")
+}
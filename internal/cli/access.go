@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"tetora/internal/cfgfile"
 )
 
 // CmdAccess implements `tetora access <list|add|remove> [path]`.
@@ -146,7 +148,7 @@ func appendDirJSON(existing []string, add string) json.RawMessage {
 
 // removeConfigKey deletes a top-level key from config.json.
 func removeConfigKey(configPath, key string) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -159,5 +161,5 @@ func removeConfigKey(configPath, key string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
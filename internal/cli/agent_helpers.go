@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/version"
 )
 
@@ -227,7 +228,7 @@ func GenerateSoulContent(archetype *AgentArchetype, agentName string) string {
 // If agentJSON is nil, the agent is removed. Otherwise it is added/updated.
 // Preserves all other config fields by reading/modifying/writing the raw JSON.
 func UpdateConfigAgents(configPath, agentName string, agentJSON json.RawMessage) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -265,7 +266,7 @@ func UpdateConfigAgents(configPath, agentName string, agentJSON json.RawMessage)
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return err
 	}
 
@@ -276,7 +277,7 @@ func UpdateConfigAgents(configPath, agentName string, agentJSON json.RawMessage)
 
 // UpdateConfigField sets a top-level field in config.json using raw JSON manipulation.
 func UpdateConfigField(configPath, field string, value json.RawMessage) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -292,12 +293,12 @@ func UpdateConfigField(configPath, field string, value json.RawMessage) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 // UpdateConfigSmartDispatchDefault sets smartDispatch.defaultAgent in the config file.
 func UpdateConfigSmartDispatchDefault(configPath, agentName string) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -323,14 +324,14 @@ func UpdateConfigSmartDispatchDefault(configPath, agentName string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 // SnapshotAfterChange snapshots config after a CLI change.
 // Silently ignores errors (best-effort).
 func SnapshotAfterChange(configPath, changedBy, reason string) {
 	// Quick load to find historyDB.
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return
 	}
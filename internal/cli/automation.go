@@ -0,0 +1,552 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/version"
+)
+
+// automationBundleVersion is the bundle format written by export.
+const automationBundleVersion = 1
+
+// automationBundle is the file "tetora automation export" writes: cron jobs
+// from jobs.json plus outgoing webhooks, incoming webhooks and workflow
+// triggers from config.json. Entries are kept as raw JSON objects so fields
+// this command does not know about survive the round trip.
+//
+// Secrets are replaced by ${NAME} placeholders and NAME is listed in Env.
+// Import fills every listed placeholder from the environment, so a bundle
+// can be shared or committed and the values supplied per install. Env may
+// also list names added by hand to parameterize other fields (workdirs,
+// agent names) for promotion between environments.
+type automationBundle struct {
+	Version          int                       `json:"version"`
+	ExportedAt       string                    `json:"exportedAt,omitempty"`
+	Env              []string                  `json:"env,omitempty"`
+	Jobs             []map[string]any          `json:"jobs,omitempty"`
+	Webhooks         []map[string]any          `json:"webhooks,omitempty"`
+	IncomingWebhooks map[string]map[string]any `json:"incomingWebhooks,omitempty"`
+	WorkflowTriggers []map[string]any          `json:"workflowTriggers,omitempty"`
+}
+
+// CmdAutomation exports and imports automation definitions as one bundle.
+//
+// Usage:
+//
+//	tetora automation export [-o bundle.json] [--env-file secrets.env]
+//	tetora automation import <bundle.json> [--mode merge|replace] [--env-file secrets.env] [--dry-run]
+func CmdAutomation(args []string) {
+	if len(args) == 0 {
+		automationUsage()
+	}
+	switch args[0] {
+	case "export":
+		cmdAutomationExport(args[1:])
+	case "import":
+		cmdAutomationImport(args[1:])
+	default:
+		automationUsage()
+	}
+}
+
+func automationUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: tetora automation <export|import>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "  export [-o file] [--env-file file]   Bundle cron jobs, webhooks and workflow triggers")
+	fmt.Fprintln(os.Stderr, "                                       (secrets become ${VAR} placeholders; --env-file saves their values)")
+	fmt.Fprintln(os.Stderr, "  import <file> [--mode merge|replace] [--env-file file] [--dry-run]")
+	fmt.Fprintln(os.Stderr, "                                       Add the bundle's entries; merge skips existing ones, replace overwrites them")
+	os.Exit(1)
+}
+
+func cmdAutomationExport(args []string) {
+	var outPath, envPath string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o", "--output":
+			if i+1 < len(args) {
+				outPath = args[i+1]
+				i++
+			}
+		case "--env-file":
+			if i+1 < len(args) {
+				envPath = args[i+1]
+				i++
+			}
+		}
+	}
+
+	configPath := FindConfigPath()
+	cfg := LoadCLIConfig(configPath)
+	cfgRaw := readRawJSON(configPath, true)
+	jobsRaw := readRawJSON(cfg.JobsFile, false)
+
+	bundle, secrets := buildAutomationBundle(cfgRaw, jobsRaw)
+	bundle.ExportedAt = time.Now().UTC().Format(time.RFC3339)
+	out, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding bundle: %v\n", err)
+		os.Exit(1)
+	}
+	out = append(out, '\n')
+
+	if outPath == "" || outPath == "-" {
+		os.Stdout.Write(out)
+	} else if err := os.WriteFile(outPath, out, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	if envPath != "" && len(secrets) > 0 {
+		var b strings.Builder
+		for _, name := range bundle.Env {
+			fmt.Fprintf(&b, "%s=%s\n", name, secrets[name])
+		}
+		if err := os.WriteFile(envPath, []byte(b.String()), 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing env file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d jobs, %d webhooks, %d incoming webhooks, %d workflow triggers",
+		len(bundle.Jobs), len(bundle.Webhooks), len(bundle.IncomingWebhooks), len(bundle.WorkflowTriggers))
+	if outPath != "" && outPath != "-" {
+		fmt.Fprintf(os.Stderr, " to %s", outPath)
+	}
+	fmt.Fprintln(os.Stderr)
+	if len(bundle.Env) > 0 {
+		fmt.Fprintf(os.Stderr, "Secrets were replaced by placeholders; set these before importing: %s\n", strings.Join(bundle.Env, ", "))
+		if envPath != "" {
+			fmt.Fprintf(os.Stderr, "Their current values are in %s (keep it out of version control).\n", envPath)
+		}
+	}
+}
+
+func cmdAutomationImport(args []string) {
+	var srcPath, envPath string
+	mode := "merge"
+	dryRun := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--mode":
+			if i+1 < len(args) {
+				mode = args[i+1]
+				i++
+			}
+		case "--env-file":
+			if i+1 < len(args) {
+				envPath = args[i+1]
+				i++
+			}
+		case "--dry-run":
+			dryRun = true
+		default:
+			srcPath = args[i]
+		}
+	}
+	if srcPath == "" {
+		automationUsage()
+	}
+	if mode != "merge" && mode != "replace" {
+		fmt.Fprintf(os.Stderr, "Error: unknown mode %q (must be merge or replace)\n", mode)
+		os.Exit(1)
+	}
+	if envPath != "" {
+		if _, err := os.Stat(envPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: env file: %v\n", err)
+			os.Exit(1)
+		}
+		if err := config.LoadDotEnv(envPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading env file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading bundle: %v\n", err)
+		os.Exit(1)
+	}
+	var bundle automationBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing bundle: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle.Version != automationBundleVersion {
+		fmt.Fprintf(os.Stderr, "Error: unsupported bundle version %d (want %d)\n", bundle.Version, automationBundleVersion)
+		os.Exit(1)
+	}
+	if err := expandAutomationBundle(&bundle, os.Getenv); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	configPath := FindConfigPath()
+	cfg := LoadCLIConfig(configPath)
+	cfgRaw := readRawJSON(configPath, true)
+	jobsRaw := readRawJSON(cfg.JobsFile, false)
+
+	res := applyAutomationBundle(&bundle, cfgRaw, jobsRaw, mode)
+	res.Warnings = append(res.Warnings, automationWarnings(&bundle, cfg)...)
+
+	fmt.Printf("Import automation: %s (mode=%s)\n", srcPath, mode)
+	for _, w := range res.Warnings {
+		fmt.Println("  warning: " + w)
+	}
+	if len(res.Actions) == 0 {
+		fmt.Println("  No changes needed.")
+		return
+	}
+	fmt.Println("Actions:")
+	for _, a := range res.Actions {
+		fmt.Println(a)
+	}
+	fmt.Printf("\nSummary: %d added, %d replaced, %d skipped\n", res.Added, res.Replaced, res.Skipped)
+	if dryRun {
+		fmt.Println("\n(dry-run: no changes written)")
+		return
+	}
+
+	fmt.Println()
+	if res.JobsChanged {
+		writeRawJSON(cfg.JobsFile, jobsRaw)
+		fmt.Printf("Jobs updated: %s (picked up by a running daemon automatically)\n", cfg.JobsFile)
+	}
+	if res.ConfigChanged {
+		writeRawJSON(configPath, cfgRaw)
+		version.SnapshotConfig(cfg.HistoryDB, configPath, "cli", "automation import "+filepath.Base(srcPath))
+		fmt.Printf("Config updated: %s\n", configPath)
+		if resp, err := cfg.NewAPIClient().Post("/api/plugins/reload", ""); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == 200 {
+				fmt.Println("Running daemon reloaded its config.")
+				return
+			}
+		}
+		fmt.Println("Restart the daemon (or send it SIGHUP) to apply the config changes.")
+	}
+}
+
+// buildAutomationBundle collects the automation entries of a config and a
+// jobs file. It returns the bundle and the secret values its placeholders
+// stand for.
+func buildAutomationBundle(cfgRaw, jobsRaw map[string]any) (*automationBundle, map[string]string) {
+	b := &automationBundle{Version: automationBundleVersion}
+	secrets := make(map[string]string)
+	placeholder := func(name, value string) string {
+		// A $VAR reference is not a secret; the daemon resolves it at load.
+		if value == "" || strings.HasPrefix(value, "$") {
+			return value
+		}
+		base := envName(name)
+		name = base
+		for i := 2; ; i++ {
+			if v, taken := secrets[name]; !taken || v == value {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		if _, ok := secrets[name]; !ok {
+			b.Env = append(b.Env, name)
+			secrets[name] = value
+		}
+		return "${" + name + "}"
+	}
+
+	for _, j := range rawObjects(jobsRaw["jobs"]) {
+		b.Jobs = append(b.Jobs, j)
+	}
+
+	// Outgoing webhook URLs often embed a token (Slack, Discord), so they
+	// are treated as secrets along with header values.
+	for i, wh := range rawObjects(cfgRaw["webhooks"]) {
+		prefix := fmt.Sprintf("TETORA_WEBHOOK_%d", i+1)
+		if u, ok := wh["url"].(string); ok {
+			wh["url"] = placeholder(prefix+"_URL", u)
+		}
+		if headers, ok := wh["headers"].(map[string]any); ok {
+			keys := sortedKeys(headers)
+			for _, k := range keys {
+				if v, ok := headers[k].(string); ok {
+					headers[k] = placeholder(prefix+"_"+k, v)
+				}
+			}
+		}
+		b.Webhooks = append(b.Webhooks, wh)
+	}
+
+	if incoming, ok := cfgRaw["incomingWebhooks"].(map[string]any); ok {
+		b.IncomingWebhooks = make(map[string]map[string]any)
+		for _, name := range sortedKeys(incoming) {
+			wh, ok := incoming[name].(map[string]any)
+			if !ok {
+				continue
+			}
+			if s, ok := wh["secret"].(string); ok {
+				wh["secret"] = placeholder("TETORA_INCOMING_"+name+"_SECRET", s)
+			}
+			b.IncomingWebhooks[name] = wh
+		}
+	}
+
+	for _, t := range rawObjects(cfgRaw["workflowTriggers"]) {
+		b.WorkflowTriggers = append(b.WorkflowTriggers, t)
+	}
+	return b, secrets
+}
+
+// expandAutomationBundle replaces the ${NAME} placeholders of every name in
+// b.Env with values from getenv. It fails listing any that are unset.
+func expandAutomationBundle(b *automationBundle, getenv func(string) string) error {
+	if len(b.Env) == 0 {
+		return nil
+	}
+	var missing []string
+	pairs := make([]string, 0, 2*len(b.Env))
+	for _, name := range b.Env {
+		v := getenv(name)
+		if v == "" {
+			missing = append(missing, name)
+		}
+		pairs = append(pairs, "${"+name+"}", v)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("bundle needs these environment variables, which are not set: %s (export them or pass --env-file)",
+			strings.Join(missing, ", "))
+	}
+	r := strings.NewReplacer(pairs...)
+	var expand func(v any) any
+	expand = func(v any) any {
+		switch t := v.(type) {
+		case string:
+			return r.Replace(t)
+		case map[string]any:
+			for k, e := range t {
+				t[k] = expand(e)
+			}
+		case []any:
+			for i, e := range t {
+				t[i] = expand(e)
+			}
+		}
+		return v
+	}
+	for _, j := range b.Jobs {
+		expand(j)
+	}
+	for _, wh := range b.Webhooks {
+		expand(wh)
+	}
+	for _, wh := range b.IncomingWebhooks {
+		expand(wh)
+	}
+	for _, t := range b.WorkflowTriggers {
+		expand(t)
+	}
+	return nil
+}
+
+// automationImportResult describes what applyAutomationBundle changed.
+type automationImportResult struct {
+	Actions                    []string
+	Warnings                   []string
+	Added, Replaced, Skipped   int
+	ConfigChanged, JobsChanged bool
+}
+
+// applyAutomationBundle merges an expanded bundle into the raw config and
+// jobs file. Entries match by job ID, webhook URL, incoming webhook name and
+// trigger name; existing ones are skipped in merge mode and overwritten in
+// replace mode. Entries not in the bundle are never removed.
+func applyAutomationBundle(b *automationBundle, cfgRaw, jobsRaw map[string]any, mode string) *automationImportResult {
+	res := &automationImportResult{}
+
+	// mergeList merges entries into the list at raw[key], matching on field.
+	// show formats the matched value for the action list.
+	mergeList := func(raw map[string]any, key, field, kind string, entries []map[string]any, show func(string) string) bool {
+		list, _ := raw[key].([]any)
+		at := make(map[string]int)
+		for i, e := range list {
+			if m, ok := e.(map[string]any); ok {
+				at[fmt.Sprint(m[field])] = i
+			}
+		}
+		changed := false
+		for _, e := range entries {
+			id := fmt.Sprint(e[field])
+			if i, ok := at[id]; ok {
+				if mode == "merge" {
+					res.Skipped++
+					res.Actions = append(res.Actions, fmt.Sprintf("  skip %s %s (already exists)", kind, show(id)))
+					continue
+				}
+				list[i] = e
+				res.Replaced++
+				res.Actions = append(res.Actions, fmt.Sprintf("  replace %s %s", kind, show(id)))
+			} else {
+				at[id] = len(list)
+				list = append(list, e)
+				res.Added++
+				res.Actions = append(res.Actions, fmt.Sprintf("  add %s %s", kind, show(id)))
+			}
+			changed = true
+		}
+		if changed {
+			raw[key] = list
+		}
+		return changed
+	}
+
+	quote := func(id string) string { return fmt.Sprintf("%q", id) }
+	res.JobsChanged = mergeList(jobsRaw, "jobs", "id", "job", b.Jobs, quote)
+	// Webhook URLs are secrets; only their host is printed.
+	if mergeList(cfgRaw, "webhooks", "url", "webhook", b.Webhooks, redactWebhookURL) {
+		res.ConfigChanged = true
+	}
+
+	if len(b.IncomingWebhooks) > 0 {
+		incoming, _ := cfgRaw["incomingWebhooks"].(map[string]any)
+		if incoming == nil {
+			incoming = make(map[string]any)
+		}
+		changed := false
+		for _, name := range sortedKeys(b.IncomingWebhooks) {
+			if _, exists := incoming[name]; exists {
+				if mode == "merge" {
+					res.Skipped++
+					res.Actions = append(res.Actions, fmt.Sprintf("  skip incoming webhook %q (already exists)", name))
+					continue
+				}
+				res.Replaced++
+				res.Actions = append(res.Actions, fmt.Sprintf("  replace incoming webhook %q", name))
+			} else {
+				res.Added++
+				res.Actions = append(res.Actions, fmt.Sprintf("  add incoming webhook %q", name))
+			}
+			incoming[name] = b.IncomingWebhooks[name]
+			changed = true
+		}
+		if changed {
+			cfgRaw["incomingWebhooks"] = incoming
+			res.ConfigChanged = true
+		}
+	}
+
+	if mergeList(cfgRaw, "workflowTriggers", "name", "workflow trigger", b.WorkflowTriggers, quote) {
+		res.ConfigChanged = true
+	}
+	return res
+}
+
+// automationWarnings points out bundle entries that refer to agents or
+// workflows this install does not have.
+func automationWarnings(b *automationBundle, cfg *CLIConfig) []string {
+	var warnings []string
+	agent := func(kind, id string, name any) {
+		if s, ok := name.(string); ok && s != "" {
+			if _, exists := cfg.Agents[s]; !exists {
+				warnings = append(warnings, fmt.Sprintf("%s %q uses agent %q, which is not configured here", kind, id, s))
+			}
+		}
+	}
+	for _, j := range b.Jobs {
+		agent("job", fmt.Sprint(j["id"]), j["agent"])
+	}
+	for _, name := range sortedKeys(b.IncomingWebhooks) {
+		agent("incoming webhook", name, b.IncomingWebhooks[name]["agent"])
+	}
+	for _, t := range b.WorkflowTriggers {
+		wf, _ := t["workflowName"].(string)
+		if wf == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.BaseDir, "workflows", wf+".json")); err != nil {
+			warnings = append(warnings, fmt.Sprintf("workflow trigger %q runs workflow %q, which does not exist here", fmt.Sprint(t["name"]), wf))
+		}
+	}
+	return warnings
+}
+
+// redactWebhookURL shortens a webhook URL to its host for display.
+func redactWebhookURL(u string) string {
+	rest := u
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest + "/…"
+}
+
+// envName turns s into an environment variable name.
+func envName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// rawObjects returns the objects of a raw JSON array.
+func rawObjects(v any) []map[string]any {
+	arr, _ := v.([]any)
+	out := make([]map[string]any, 0, len(arr))
+	for _, e := range arr {
+		if m, ok := e.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readRawJSON reads a JSON object file. A missing file is an empty object
+// unless required.
+func readRawJSON(path string, required bool) map[string]any {
+	raw := make(map[string]any)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return raw
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return raw
+}
+
+func writeRawJSON(path string, raw map[string]any) {
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeRaw(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAutomationBundleRoundTrip(t *testing.T) {
+	cfgRaw := decodeRaw(t, `{
+		"webhooks": [{"url": "https://hooks.slack.com/services/T0/B0/secret", "headers": {"Authorization": "Bearer abc", "X-Env": "$HOOK_ENV"}, "events": ["error"]}],
+		"incomingWebhooks": {"github": {"agent": "kokuyou", "secret": "gh-secret", "template": "PR {{payload.number}}"}},
+		"workflowTriggers": [{"name": "nightly", "workflowName": "release", "trigger": {"type": "cron", "cron": "0 3 * * *"}}]
+	}`)
+	jobsRaw := decodeRaw(t, `{"jobs": [{"id": "digest", "name": "Digest", "enabled": true, "schedule": "0 9 * * *", "task": {"prompt": "Summarize", "workdir": "${WORKDIR}"}}]}`)
+
+	bundle, secrets := buildAutomationBundle(cfgRaw, jobsRaw)
+	data, _ := json.Marshal(bundle)
+	out := string(data)
+	for _, leaked := range []string{"hooks.slack.com", "Bearer abc", "gh-secret"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("bundle contains secret %q: %s", leaked, out)
+		}
+	}
+	wantEnv := []string{"TETORA_WEBHOOK_1_URL", "TETORA_WEBHOOK_1_AUTHORIZATION", "TETORA_INCOMING_GITHUB_SECRET"}
+	if strings.Join(bundle.Env, ",") != strings.Join(wantEnv, ",") {
+		t.Errorf("env = %v, want %v", bundle.Env, wantEnv)
+	}
+	if secrets["TETORA_INCOMING_GITHUB_SECRET"] != "gh-secret" {
+		t.Errorf("secrets = %v", secrets)
+	}
+	// $VAR references are resolved by the daemon and stay as they are.
+	if h := bundle.Webhooks[0]["headers"].(map[string]any); h["X-Env"] != "$HOOK_ENV" {
+		t.Errorf("reference header = %v", h["X-Env"])
+	}
+
+	// Import on another install: placeholders come from its environment,
+	// including names added to env by hand.
+	var imported automationBundle
+	json.Unmarshal(data, &imported)
+	imported.Env = append(imported.Env, "WORKDIR")
+	env := map[string]string{
+		"TETORA_WEBHOOK_1_URL":           "https://hooks.slack.com/services/T1/B1/prod",
+		"TETORA_WEBHOOK_1_AUTHORIZATION": "Bearer prod",
+		"TETORA_INCOMING_GITHUB_SECRET":  "prod-secret",
+	}
+	if err := expandAutomationBundle(&imported, func(k string) string { return env[k] }); err == nil ||
+		!strings.Contains(err.Error(), "WORKDIR") {
+		t.Fatalf("missing variable not reported: %v", err)
+	}
+	env["WORKDIR"] = "/srv/tetora"
+	if err := expandAutomationBundle(&imported, func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+
+	dstCfg := decodeRaw(t, `{"agents": {"kokuyou": {}}, "workflowTriggers": [{"name": "nightly", "workflowName": "old"}]}`)
+	dstJobs := decodeRaw(t, `{"jobs": []}`)
+	res := applyAutomationBundle(&imported, dstCfg, dstJobs, "merge")
+	if res.Added != 3 || res.Skipped != 1 || res.Replaced != 0 || !res.ConfigChanged || !res.JobsChanged {
+		t.Errorf("merge result = %+v", res)
+	}
+	job := dstJobs["jobs"].([]any)[0].(map[string]any)
+	if job["task"].(map[string]any)["workdir"] != "/srv/tetora" {
+		t.Errorf("job = %v", job)
+	}
+	wh := dstCfg["webhooks"].([]any)[0].(map[string]any)
+	if wh["url"] != env["TETORA_WEBHOOK_1_URL"] || wh["headers"].(map[string]any)["Authorization"] != "Bearer prod" {
+		t.Errorf("webhook = %v", wh)
+	}
+	if in := dstCfg["incomingWebhooks"].(map[string]any)["github"].(map[string]any); in["secret"] != "prod-secret" {
+		t.Errorf("incoming webhook = %v", in)
+	}
+	for _, a := range res.Actions {
+		if strings.Contains(a, "prod") {
+			t.Errorf("action prints a secret: %s", a)
+		}
+	}
+	if trig := dstCfg["workflowTriggers"].([]any)[0].(map[string]any); trig["workflowName"] != "old" {
+		t.Errorf("merge overwrote an existing trigger: %v", trig)
+	}
+
+	// Replace overwrites matching entries and adds nothing twice.
+	res = applyAutomationBundle(&imported, dstCfg, dstJobs, "replace")
+	if res.Added != 0 || res.Replaced != 4 {
+		t.Errorf("replace result = %+v", res)
+	}
+	if trig := dstCfg["workflowTriggers"].([]any)[0].(map[string]any); trig["workflowName"] != "release" {
+		t.Errorf("replace kept the old trigger: %v", trig)
+	}
+	if n := len(dstCfg["webhooks"].([]any)); n != 1 {
+		t.Errorf("%d webhooks after replace", n)
+	}
+}
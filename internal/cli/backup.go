@@ -4,31 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-)
-
-// CmdBackup — backup feature archived.
-func CmdBackup(_ []string) {
-	fmt.Fprintln(os.Stderr, "backup: not available (feature archived)")
-	os.Exit(1)
-}
 
-// CmdRestore — restore feature archived.
-func CmdRestore(_ []string) {
-	fmt.Fprintln(os.Stderr, "restore: not available (feature archived)")
-	os.Exit(1)
-}
-
-// CmdBackupList — backup list feature archived.
-func CmdBackupList(_ []string) {
-	fmt.Fprintln(os.Stderr, "backup list: not available (feature archived)")
-}
+	"tetora/internal/cfgfile"
+)
 
 // FindBaseDir returns the tetora base directory (~/.tetora).
 func FindBaseDir() string {
 	if exe, err := os.Executable(); err == nil {
 		candidate := filepath.Join(filepath.Dir(exe), "..")
 		if abs, err := filepath.Abs(candidate); err == nil {
-			if _, err := os.Stat(filepath.Join(abs, "config.json")); err == nil {
+			if cfgfile.Find(abs, "config") != "" {
 				return abs
 			}
 		}
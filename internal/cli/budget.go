@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/cost"
 )
 
@@ -32,7 +33,7 @@ func cmdBudgetShow() {
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	resp, err := api.Get("/budget")
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
@@ -113,7 +114,7 @@ func cmdBudgetPause() {
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	resp, err := api.Post("/budget/pause", "")
 	if err == nil && resp.StatusCode == 200 {
 		resp.Body.Close()
@@ -135,7 +136,7 @@ func cmdBudgetResume() {
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	resp, err := api.Post("/budget/resume", "")
 	if err == nil && resp.StatusCode == 200 {
 		resp.Body.Close()
@@ -156,7 +157,7 @@ func cmdBudgetResume() {
 // setBudgetPaused updates the budgets.paused field in config.json using raw
 // JSON manipulation to preserve all other config fields.
 func setBudgetPaused(configPath string, paused bool) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -188,5 +189,5 @@ func setBudgetPaused(configPath string, paused bool) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// channelSimResult is a CLI-local copy of simulate.Result.
+type channelSimResult struct {
+	Channel string   `json:"channel"`
+	Path    string   `json:"path"`
+	Status  int      `json:"status"`
+	Live    bool     `json:"live"`
+	Routes  []string `json:"routes"`
+	Tasks   []struct {
+		Agent          string  `json:"agent"`
+		Model          string  `json:"model"`
+		PermissionMode string  `json:"permissionMode"`
+		Source         string  `json:"source"`
+		Status         string  `json:"status"`
+		CostUSD        float64 `json:"costUsd"`
+	} `json:"tasks"`
+	Calls []struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		Text   string `json:"text"`
+	} `json:"calls"`
+	TimedOut   bool  `json:"timedOut"`
+	DurationMs int64 `json:"durationMs"`
+}
+
+func CmdChannel(args []string) {
+	if len(args) == 0 || args[0] != "simulate" || len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: tetora channel simulate <slack|line|whatsapp> --message \"...\" [options]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "  Runs a signed synthetic inbound message through the channel's real webhook")
+		fmt.Fprintln(os.Stderr, "  handler and shows how it was routed. Nothing is sent to the platform.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "  --message, -m TEXT   Message text (required)")
+		fmt.Fprintln(os.Stderr, "  --user ID            Sender ID (default: a placeholder)")
+		fmt.Fprintln(os.Stderr, "  --chat ID            Slack channel or LINE group ID")
+		fmt.Fprintln(os.Stderr, "  --live               Run the routed agent instead of a stub reply")
+		fmt.Fprintln(os.Stderr, "  --timeout DURATION   How long to wait (default 30s, 10m with --live)")
+		fmt.Fprintln(os.Stderr, "  --json               Print the raw result")
+		os.Exit(1)
+	}
+	cmdChannelSimulate(args[1], args[2:])
+}
+
+func cmdChannelSimulate(channel string, args []string) {
+	req := map[string]any{"channel": channel}
+	timeout := 30 * time.Second
+	asJSON := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--message", "-m", "--user", "--chat", "--timeout":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "%s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			v := args[i+1]
+			i++
+			switch args[i-1] {
+			case "--message", "-m":
+				req["message"] = v
+			case "--user":
+				req["user"] = v
+			case "--chat":
+				req["chat"] = v
+			case "--timeout":
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					fmt.Fprintf(os.Stderr, "Invalid --timeout %q\n", v)
+					os.Exit(1)
+				}
+				req["timeout"] = v
+				timeout = d
+			}
+		case "--live":
+			req["live"] = true
+			if _, ok := req["timeout"]; !ok {
+				timeout = 10 * time.Minute
+			}
+		case "--json":
+			asJSON = true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option %q\n", args[i])
+			os.Exit(1)
+		}
+	}
+	if req["message"] == nil {
+		fmt.Fprintln(os.Stderr, "--message is required")
+		os.Exit(1)
+	}
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	api := cfg.NewAPIClient()
+	api.Client.Timeout = timeout + 30*time.Second
+	resp, err := api.PostJSON("/api/channels/simulate", req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (is the daemon running?)\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		os.Exit(1)
+	}
+	if asJSON {
+		os.Stdout.Write(body)
+		return
+	}
+	var res channelSimResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
+		os.Exit(1)
+	}
+	printChannelSim(res)
+}
+
+func printChannelSim(res channelSimResult) {
+	mode := "dry run"
+	if res.Live {
+		mode = "live"
+	}
+	fmt.Printf("Simulated %s message (%s, %dms)\n\n", res.Channel, mode, res.DurationMs)
+	fmt.Printf("  Webhook:  POST %s -> %d\n", res.Path, res.Status)
+	if res.Status != 200 {
+		fmt.Println("\nThe handler rejected the event; check the daemon log.")
+		return
+	}
+	if len(res.Routes) > 0 {
+		fmt.Printf("  Routed:   %s\n", strings.Join(res.Routes, ", "))
+	} else {
+		fmt.Println("  Routed:   (router not consulted)")
+	}
+	for _, t := range res.Tasks {
+		fmt.Printf("  Agent:    %s", t.Agent)
+		if t.Model != "" {
+			fmt.Printf("  model=%s", t.Model)
+		}
+		if t.PermissionMode != "" {
+			fmt.Printf("  permission=%s", t.PermissionMode)
+		}
+		fmt.Printf("  status=%s", t.Status)
+		if t.CostUSD > 0 {
+			fmt.Printf("  cost=$%.4f", t.CostUSD)
+		}
+		fmt.Println()
+	}
+	if len(res.Tasks) == 0 {
+		fmt.Println("  Agent:    (no task dispatched)")
+	}
+	if len(res.Calls) > 0 {
+		fmt.Printf("\nReplies (recorded, not sent):\n")
+		for _, c := range res.Calls {
+			fmt.Printf("  %s %s\n", c.Method, c.URL)
+			if c.Text != "" {
+				for _, line := range strings.Split(c.Text, "\n") {
+					fmt.Printf("    | %s\n", line)
+				}
+			}
+		}
+	}
+	if res.TimedOut {
+		fmt.Println("\nTimed out waiting for the bot to finish; results may be incomplete.")
+	}
+}
@@ -0,0 +1,525 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tetora/internal/db"
+)
+
+// chatMaxFileBytes caps each file attached with /files.
+const chatMaxFileBytes = 200 << 10
+
+// chatREPL is one "tetora chat" session.
+type chatREPL struct {
+	api     *APIClient
+	cfg     *CLIConfig
+	session Session
+	model   string   // /model override; empty uses the agent's model
+	files   []string // attached with /files, sent with the next message
+	out     io.Writer
+}
+
+func CmdChat(args []string) {
+	var role, sessionID, model string
+	for i := 0; i < len(args); i++ {
+		next := func() string {
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "%s needs a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			return args[i]
+		}
+		switch args[i] {
+		case "--role", "--agent", "-r":
+			role = next()
+		case "--session", "-s":
+			sessionID = next()
+		case "--model", "-m":
+			model = next()
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: tetora chat [--role <agent>] [--session <id>] [--model <model>]")
+			os.Exit(1)
+		}
+	}
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	api := cfg.Daemon()
+	if api == nil {
+		fmt.Fprintf(os.Stderr, "Error: daemon not running (%s); tetora chat needs a running daemon\n", cfg.ListenAddr)
+		os.Exit(1)
+	}
+	api.Client.Timeout = 30 * time.Second
+
+	c := &chatREPL{api: api, cfg: cfg, model: model, out: os.Stdout}
+	var err error
+	if sessionID != "" {
+		err = c.resume(sessionID)
+	} else {
+		err = c.start(role)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	c.run(os.Stdin)
+}
+
+// start opens a new session with role, or the default agent.
+func (c *chatREPL) start(role string) error {
+	if role == "" {
+		role = c.cfg.DefaultAgent
+	}
+	if role == "" {
+		return fmt.Errorf("no --role given and no defaultAgent configured (agents: %s)", strings.Join(chatAgentNames(c.cfg), ", "))
+	}
+	var sess Session
+	if err := c.postJSON("/sessions", map[string]string{"agent": role}, &sess); err != nil {
+		return err
+	}
+	c.session = sess
+	fmt.Fprintf(c.out, "New chat with %s (session %s). /help for commands.\n", sess.Agent, shortID(sess.ID))
+	return nil
+}
+
+// resume continues an existing session and shows its last messages.
+func (c *chatREPL) resume(id string) error {
+	var detail SessionDetail
+	if err := c.api.GetJSON("/sessions/"+url.PathEscape(id), &detail); err != nil {
+		return err
+	}
+	c.session = detail.Session
+	fmt.Fprintf(c.out, "Resuming %q with %s (session %s, %d messages). /help for commands.\n",
+		detail.Session.Title, detail.Session.Agent, shortID(detail.Session.ID), len(detail.Messages))
+	msgs := detail.Messages
+	if len(msgs) > 6 {
+		msgs = msgs[len(msgs)-6:]
+	}
+	for _, m := range msgs {
+		fmt.Fprintf(c.out, "\033[2m%s: %s\033[0m\n", m.Role, db.Truncate(strings.ReplaceAll(m.Content, "\n", " "), 300))
+	}
+	return nil
+}
+
+// run reads lines until EOF or /quit. A line ending in a backslash continues
+// on the next line.
+func (c *chatREPL) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var pending strings.Builder
+	c.prompt(false)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasSuffix(line, `\`) {
+			pending.WriteString(strings.TrimSuffix(line, `\`) + "\n")
+			c.prompt(true)
+			continue
+		}
+		pending.WriteString(line)
+		text := strings.TrimSpace(pending.String())
+		pending.Reset()
+
+		if strings.HasPrefix(text, "/") {
+			if quit := c.command(text); quit {
+				return
+			}
+		} else if text != "" {
+			c.send(text)
+		}
+		c.prompt(false)
+	}
+	fmt.Fprintln(c.out)
+}
+
+func (c *chatREPL) prompt(continued bool) {
+	if continued {
+		fmt.Fprint(c.out, "... ")
+		return
+	}
+	label := c.session.Agent
+	if c.model != "" {
+		label += "/" + c.model
+	}
+	if n := len(c.files); n > 0 {
+		label += fmt.Sprintf(" +%d file(s)", n)
+	}
+	fmt.Fprintf(c.out, "\033[1m%s>\033[0m ", label)
+}
+
+// command runs a slash command and reports whether to quit.
+func (c *chatREPL) command(line string) bool {
+	fields := strings.Fields(line)
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "/quit", "/exit", "/q":
+		return true
+	case "/help", "/?":
+		fmt.Fprint(c.out, `Commands:
+  /role <agent>     start a new session with another agent
+  /model [name]     use a model for the next messages (no name: agent default)
+  /files <path>...  attach files to the next message (/files: list, /files clear)
+  /compact          compact this session's context
+  /new              start a new session with the same agent
+  /session          show the session ID (resume with tetora chat --session <id>)
+  /quit             leave; the session stays in tetora session list
+End a line with \ to continue on the next line.
+`)
+	case "/role", "/agent":
+		if len(args) != 1 {
+			fmt.Fprintf(c.out, "Usage: /role <agent> (agents: %s)\n", strings.Join(chatAgentNames(c.cfg), ", "))
+			break
+		}
+		if err := c.start(args[0]); err != nil {
+			fmt.Fprintf(c.out, "Error: %v\n", err)
+		}
+	case "/new":
+		if err := c.start(c.session.Agent); err != nil {
+			fmt.Fprintf(c.out, "Error: %v\n", err)
+		}
+	case "/model":
+		if len(args) == 0 {
+			c.model = ""
+			fmt.Fprintln(c.out, "Using the agent's default model.")
+		} else {
+			c.model = args[0]
+			fmt.Fprintf(c.out, "Using %s for the next messages.\n", c.model)
+		}
+	case "/compact":
+		if err := c.postJSON("/sessions/"+url.PathEscape(c.session.ID)+"/compact", nil, nil); err != nil {
+			fmt.Fprintf(c.out, "Error: %v\n", err)
+		} else {
+			fmt.Fprintln(c.out, "Compacting session context in the background.")
+		}
+	case "/files", "/file", "/attach":
+		c.attach(args)
+	case "/session":
+		fmt.Fprintf(c.out, "Session %s (%s)\n", c.session.ID, c.session.Agent)
+	default:
+		fmt.Fprintf(c.out, "Unknown command %s; /help lists them.\n", cmd)
+	}
+	return false
+}
+
+// attach adds files to the next message, after checking they are readable
+// text of reasonable size.
+func (c *chatREPL) attach(args []string) {
+	if len(args) == 0 {
+		if len(c.files) == 0 {
+			fmt.Fprintln(c.out, "No files attached. /files <path>... attaches files to the next message.")
+		}
+		for _, f := range c.files {
+			fmt.Fprintf(c.out, "  %s\n", f)
+		}
+		return
+	}
+	if len(args) == 1 && args[0] == "clear" {
+		c.files = nil
+		fmt.Fprintln(c.out, "Attachments cleared.")
+		return
+	}
+	for _, arg := range args {
+		matches, _ := filepath.Glob(arg)
+		if len(matches) == 0 {
+			matches = []string{arg}
+		}
+		for _, path := range matches {
+			if _, err := readChatFile(path); err != nil {
+				fmt.Fprintf(c.out, "Skipped %s: %v\n", path, err)
+				continue
+			}
+			c.files = append(c.files, path)
+			fmt.Fprintf(c.out, "Attached %s\n", path)
+		}
+	}
+}
+
+func readChatFile(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", errors.New("is a directory")
+	}
+	if fi.Size() > chatMaxFileBytes {
+		return "", fmt.Errorf("larger than %d KB", chatMaxFileBytes>>10)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", errors.New("not a text file")
+	}
+	return string(b), nil
+}
+
+// buildChatPrompt appends the attached files to text.
+func buildChatPrompt(text string, files []string) (string, error) {
+	if len(files) == 0 {
+		return text, nil
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	for _, path := range files {
+		content, err := readChatFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(&b, "\n\n<file path=%q>\n%s\n</file>", path, strings.TrimRight(content, "\n"))
+	}
+	return b.String(), nil
+}
+
+// send posts one message and streams the reply. Ctrl-C cancels the reply
+// and returns to the prompt.
+func (c *chatREPL) send(text string) {
+	prompt, err := buildChatPrompt(text, c.files)
+	if err != nil {
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return
+	}
+	c.files = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Subscribe before sending so no chunk is missed.
+	events := make(chan chatEvent, 64)
+	connected := make(chan error, 1)
+	go chatStream(ctx, c.api, "/sessions/"+url.PathEscape(c.session.ID)+"/stream", connected, events)
+	streaming := true
+	select {
+	case err := <-connected:
+		streaming = err == nil
+	case <-time.After(5 * time.Second):
+		streaming = false
+	}
+
+	var sent struct {
+		TaskID string `json:"taskId"`
+	}
+	body := map[string]any{"prompt": prompt, "async": true}
+	if c.model != "" {
+		body["model"] = c.model
+	}
+	if err := c.postJSON("/sessions/"+url.PathEscape(c.session.ID)+"/message", body, &sent); err != nil {
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	printed := false
+	var final *chatEvent
+	for streaming && final == nil {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				streaming = false
+				break
+			}
+			// Providers tag chunks with the session; only the final event
+			// carries the task, which tells this reply from another one.
+			if (ev.Type == "completed" || ev.Type == "error") && ev.TaskID != sent.TaskID {
+				continue
+			}
+			switch ev.Type {
+			case "output_chunk":
+				if ev.Chunk != "" && (ev.ChunkType == "" || ev.ChunkType == "text") {
+					fmt.Fprint(c.out, ev.Chunk)
+					printed = true
+				}
+			case "tool_call":
+				if ev.Name != "" {
+					fmt.Fprintf(c.out, "\033[2m[%s]\033[0m ", ev.Name)
+				}
+			case "completed", "error":
+				final = &ev
+			}
+		case <-interrupt:
+			c.postJSON("/cancel/"+url.PathEscape(sent.TaskID), nil, nil)
+			fmt.Fprintln(c.out, "\n[cancelled]")
+			return
+		}
+	}
+
+	// Without streaming, or when the provider did not stream text, show the
+	// stored reply.
+	if !printed {
+		if reply := c.waitReply(sent.TaskID); reply != nil {
+			fmt.Fprint(c.out, reply.Content)
+			if final == nil {
+				final = &chatEvent{Type: "completed", CostUSD: reply.CostUSD}
+				if reply.Role != "assistant" {
+					final.Type = "error"
+				}
+			}
+		}
+	}
+	fmt.Fprintln(c.out)
+	if final != nil {
+		if final.Type == "error" {
+			fmt.Fprintf(c.out, "\033[31m[%s] %s\033[0m\n", final.Status, final.Error)
+		} else {
+			fmt.Fprintf(c.out, "\033[2m[$%.4f · %.1fs]\033[0m\n", final.CostUSD, float64(final.DurationMs)/1000)
+		}
+	}
+}
+
+// waitReply polls the session for the message recorded for taskID.
+func (c *chatREPL) waitReply(taskID string) *SessionMessage {
+	deadline := time.Now().Add(15 * time.Minute)
+	for delay := 200 * time.Millisecond; time.Now().Before(deadline); delay = min(delay*2, 3*time.Second) {
+		var detail SessionDetail
+		if err := c.api.GetJSON("/sessions/"+url.PathEscape(c.session.ID), &detail); err == nil {
+			for i := len(detail.Messages) - 1; i >= 0; i-- {
+				if m := detail.Messages[i]; m.TaskID == taskID && m.Role != "user" {
+					return &m
+				}
+			}
+		}
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// postJSON posts payload (nil for an empty body) and decodes the response
+// into v when v is not nil.
+func (c *chatREPL) postJSON(path string, payload, v any) error {
+	var resp *http.Response
+	var err error
+	if payload == nil {
+		resp, err = c.api.Post(path, "")
+	} else {
+		resp, err = c.api.PostJSON(path, payload)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &APIError{Status: resp.StatusCode, Message: msg, Body: body}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// chatEvent is the part of an SSE event the REPL uses.
+type chatEvent struct {
+	Type       string
+	TaskID     string
+	Chunk      string
+	ChunkType  string
+	Name       string
+	Status     string
+	Error      string
+	CostUSD    float64
+	DurationMs float64
+}
+
+// chatStream follows an SSE stream, reporting on connected once the server
+// has accepted the subscription and sending events until the stream ends.
+func chatStream(ctx context.Context, api *APIClient, path string, connected chan<- error, events chan<- chatEvent) {
+	defer close(events)
+	req, err := http.NewRequestWithContext(ctx, "GET", api.BaseURL+path, nil)
+	if err != nil {
+		connected <- err
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if api.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Token)
+	}
+	if api.ClientID != "" {
+		req.Header.Set("X-Client-ID", api.ClientID)
+	}
+	resp, err := (&http.Client{Transport: api.Client.Transport}).Do(req)
+	if err != nil {
+		connected <- err
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		connected <- fmt.Errorf("stream: HTTP %d", resp.StatusCode)
+		return
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			// The server's ": connected" comment follows the subscription.
+			connected <- nil
+			first = false
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var raw struct {
+			Type   string         `json:"type"`
+			TaskID string         `json:"taskId"`
+			Data   map[string]any `json:"data"`
+		}
+		if json.Unmarshal([]byte(data), &raw) != nil {
+			continue
+		}
+		ev := chatEvent{
+			Type:       raw.Type,
+			TaskID:     raw.TaskID,
+			Chunk:      JSONStrSafe(raw.Data["chunk"]),
+			ChunkType:  JSONStrSafe(raw.Data["chunkType"]),
+			Name:       JSONStrSafe(raw.Data["name"]),
+			Status:     JSONStrSafe(raw.Data["status"]),
+			Error:      JSONStrSafe(raw.Data["error"]),
+			CostUSD:    JSONFloatSafe(raw.Data["costUsd"]),
+			DurationMs: JSONFloatSafe(raw.Data["durationMs"]),
+		}
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if first {
+		connected <- errors.New("stream closed")
+	}
+}
+
+func chatAgentNames(cfg *CLIConfig) []string {
+	names := make([]string, 0, len(cfg.Agents))
+	for name := range cfg.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
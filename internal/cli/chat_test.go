@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChatREPL(t *testing.T) {
+	var (
+		posted   = make(chan struct{}, 1)
+		sent     []map[string]any
+		compacts int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Agent string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Agent != "helper" {
+			http.Error(w, `{"error":"agent not found"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"sess-0001-abcd","agent":"helper","source":"chat","status":"active"}`))
+	})
+	mux.HandleFunc("/sessions/sess-0001-abcd/stream", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ": connected to sess-0001-abcd\n\n")
+		w.(http.Flusher).Flush()
+		<-posted // the message arrives only after the subscription
+		fmt.Fprint(w, "data: {\"type\":\"output_chunk\",\"taskId\":\"sess-0001-abcd\",\"data\":{\"chunk\":\"Hello \",\"chunkType\":\"text\"}}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"output_chunk\",\"data\":{\"chunk\":\"{\\\"raw\\\":1}\",\"chunkType\":\"thinking\"}}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"output_chunk\",\"data\":{\"chunk\":\"there.\"}}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"completed\",\"taskId\":\"other\",\"data\":{\"status\":\"success\"}}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"completed\",\"taskId\":\"task-1\",\"data\":{\"status\":\"success\",\"costUsd\":0.0123,\"durationMs\":1500}}\n\n")
+	})
+	mux.HandleFunc("/sessions/sess-0001-abcd/message", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body)
+		posted <- struct{}{}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"taskId":"task-1","sessionId":"sess-0001-abcd","status":"running"}`))
+	})
+	mux.HandleFunc("/sessions/sess-0001-abcd/compact", func(w http.ResponseWriter, r *http.Request) {
+		compacts++
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("buy milk\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "image.bin"), []byte{0xff, 0xfe, 0x00}, 0o644)
+
+	var out strings.Builder
+	c := &chatREPL{
+		api: NewAPIClient(strings.TrimPrefix(srv.URL, "http://"), ""),
+		cfg: &CLIConfig{DefaultAgent: "helper"},
+		out: &out,
+	}
+	if err := c.start(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.start("ghost"); err == nil || !strings.Contains(err.Error(), "agent not found") {
+		t.Errorf("unknown agent: %v", err)
+	}
+
+	input := strings.Join([]string{
+		"/model haiku",
+		"/files " + notes + " " + filepath.Join(dir, "image.bin"),
+		"what should I \\",
+		"do today?",
+		"/compact",
+		"/quit",
+		"never sent",
+	}, "\n")
+	c.run(strings.NewReader(input))
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages", len(sent))
+	}
+	wantPrompt := "what should I \ndo today?\n\n<file path=\"" + notes + "\">\nbuy milk\n</file>"
+	if sent[0]["prompt"] != wantPrompt || sent[0]["model"] != "haiku" || sent[0]["async"] != true {
+		t.Errorf("message = %q", sent[0])
+	}
+	text := out.String()
+	for _, want := range []string{"New chat with helper (session sess-000", "Using haiku", "Attached " + notes,
+		"Skipped " + filepath.Join(dir, "image.bin") + ": not a text file", "helper/haiku +1 file(s)>", "Hello there.\n", "[$0.0123 · 1.5s]",
+		"Compacting"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `{"raw":1}`) {
+		t.Errorf("non-text chunk printed:\n%s", text)
+	}
+	if compacts != 1 {
+		t.Errorf("compacts = %d", compacts)
+	}
+	if len(c.files) != 0 {
+		t.Errorf("attachments not cleared after sending: %q", c.files)
+	}
+}
+
+func TestBuildChatPrompt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	os.WriteFile(path, []byte("package a\n\n"), 0o644)
+	got, err := buildChatPrompt("Review this.", []string{path})
+	want := "Review this.\n\n<file path=\"" + path + "\">\npackage a\n</file>"
+	if err != nil || got != want {
+		t.Errorf("buildChatPrompt = %q, %v\nwant %q", got, err, want)
+	}
+	os.Remove(path)
+	if _, err := buildChatPrompt("x", []string{path}); err == nil {
+		t.Error("missing file accepted")
+	}
+}
@@ -9,29 +9,35 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"tetora/internal/cfgfile"
 )
 
 // TetoraVersion is set by main.go before calling any CLI function.
 var TetoraVersion = "dev"
 
-// FindConfigPath discovers the config.json file path.
+// FindConfigPath discovers the config file path.
 // Resolution order:
 //  1. <executable>/../config.json (Homebrew layout)
 //  2. ~/.tetora/config.json
 //  3. config.json (current directory)
+//
+// In each directory config.json is tried first, then config.yaml,
+// config.yml and config.toml.
 func FindConfigPath() string {
 	if exe, err := os.Executable(); err == nil {
-		candidate := filepath.Join(filepath.Dir(exe), "..", "config.json")
-		if abs, err := filepath.Abs(candidate); err == nil {
-			if _, err := os.Stat(abs); err == nil {
-				return abs
+		if abs, err := filepath.Abs(filepath.Join(filepath.Dir(exe), "..")); err == nil {
+			if p := cfgfile.Find(abs, "config"); p != "" {
+				return p
 			}
 		}
 	}
 	home, _ := os.UserHomeDir()
-	candidate := filepath.Join(home, ".tetora", "config.json")
-	if _, err := os.Stat(candidate); err == nil {
-		return candidate
+	if p := cfgfile.Find(filepath.Join(home, ".tetora"), "config"); p != "" {
+		return p
+	}
+	if p := cfgfile.Find(".", "config"); p != "" {
+		return p
 	}
 	return "config.json"
 }
@@ -39,6 +45,7 @@ func FindConfigPath() string {
 // --- API Client ---
 
 // APIClient creates an HTTP client for daemon communication.
+// Requests on a nil *APIClient (see CLIConfig.Daemon) fail with ErrNoDaemon.
 type APIClient struct {
 	Client   *http.Client
 	BaseURL  string
@@ -57,6 +64,9 @@ func NewAPIClient(listenAddr, token string) *APIClient {
 }
 
 func (c *APIClient) Do(method, path string, body io.Reader) (*http.Response, error) {
+	if c == nil {
+		return nil, ErrNoDaemon
+	}
 	req, err := http.NewRequest(method, c.BaseURL+path, body)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"tetora/internal/cfgfile"
+	"tetora/internal/config"
+	"tetora/internal/skill"
 )
 
 // CLIConfig is a lightweight config struct with only CLI-relevant fields.
@@ -42,6 +46,7 @@ type CLIConfig struct {
 	DashboardAuth         json.RawMessage            `json:"dashboardAuth,omitempty"`
 	Logging               LoggingInfo                `json:"logging,omitempty"`
 	Skills                json.RawMessage            `json:"skills,omitempty"`
+	SkillStore            skill.SkillStoreConfig     `json:"skillStore,omitempty"`
 	Webhooks              json.RawMessage            `json:"webhooks,omitempty"`
 	IncomingWebhooks      map[string]json.RawMessage `json:"incomingWebhooks,omitempty"`
 	Trust                 json.RawMessage            `json:"trust,omitempty"`
@@ -66,11 +71,18 @@ type CLIConfig struct {
 	EncryptionKey         string                     `json:"encryptionKey,omitempty"`
 	ClientsDir            string                     `json:"clientsDir,omitempty"`
 	DefaultClientID       string                     `json:"defaultClientID,omitempty"`
+	Workspaces            map[string]TenantInfo      `json:"workspaces,omitempty"`
+	ActiveWorkspace       string                     `json:"activeWorkspace,omitempty"`
 	Review                ReviewInfo                 `json:"review,omitempty"`
+	HTTP                  HTTPInfo                   `json:"http,omitempty"`
+	GitOps                config.GitOpsConfig        `json:"gitops,omitempty"`
 
 	// Resolved paths (not from JSON).
 	BaseDir    string `json:"-"`
 	ConfigPath string `json:"-"`
+	Profile    string `json:"-"`
+
+	daemonUp *bool // cached result of the daemon probe; see Daemon
 }
 
 // AgentInfo is a CLI-local version of AgentConfig.
@@ -85,10 +97,12 @@ type AgentInfo struct {
 	Provider          string        `json:"provider,omitempty"`
 	Docker            *bool         `json:"docker,omitempty"`
 	FallbackProviders []string      `json:"fallbackProviders,omitempty"`
+	ProviderChain     []string      `json:"providerChain,omitempty"`
 	TrustLevel        string        `json:"trustLevel,omitempty"`
 	ToolPolicy        json.RawMessage `json:"tools,omitempty"`
 	ToolProfile       string        `json:"toolProfile,omitempty"`
 	Workspace         WorkspaceInfo `json:"workspace,omitempty"`
+	ResponseCache     string        `json:"responseCache,omitempty"`
 }
 
 // WorkspaceInfo mirrors WorkspaceConfig for CLI display.
@@ -99,6 +113,13 @@ type WorkspaceInfo struct {
 	Sandbox    json.RawMessage `json:"sandbox,omitempty"`
 }
 
+// TenantInfo mirrors TenantConfig (a named multi-workspace entry).
+type TenantInfo struct {
+	ClientID string   `json:"clientId,omitempty"`
+	APIToken string   `json:"apiToken,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+}
+
 // QuietHoursInfo mirrors QuietHoursConfig.
 type QuietHoursInfo struct {
 	Enabled bool   `json:"enabled"`
@@ -108,6 +129,11 @@ type QuietHoursInfo struct {
 	Digest  bool   `json:"digest,omitempty"`
 }
 
+// HTTPInfo mirrors the HTTPConfig fields the CLI needs to reach the daemon.
+type HTTPInfo struct {
+	Socket string `json:"socket,omitempty"`
+}
+
 // BudgetInfo mirrors BudgetConfig for CLI budget display.
 type BudgetInfo struct {
 	Global        BudgetLimits            `json:"global,omitempty"`
@@ -165,24 +191,46 @@ func LoadCLIConfig(path string) *CLIConfig {
 func TryLoadCLIConfig(path string) (*CLIConfig, error) {
 	if path == "" {
 		// Binary at ~/.tetora/bin/tetora → config at ~/.tetora/config.json
+		// (or config.yaml / config.toml).
 		if exe, err := os.Executable(); err == nil {
-			candidate := filepath.Join(filepath.Dir(exe), "..", "config.json")
-			if abs, err := filepath.Abs(candidate); err == nil {
-				candidate = abs
-			}
-			if _, err := os.Stat(candidate); err == nil {
-				path = candidate
+			dir := filepath.Join(filepath.Dir(exe), "..")
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
 			}
+			path = cfgfile.Find(dir, "config")
+		}
+		if path == "" {
+			path = cfgfile.Find(".", "config")
 		}
 		if path == "" {
 			path = "config.json"
 		}
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
+	config.LoadDotEnv(filepath.Join(filepath.Dir(path), ".env"))
+	data, raw, err := config.ReadDoc(path)
+	if raw == nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config: %s: %w", filepath.Base(path), err)
+	}
+
+	// Merge the same overlays the daemon does, so the CLI sees its config.
+	profile := config.ActiveProfile()
+	layers, err := config.Layers(path, profile)
+	if err != nil {
+		return nil, fmt.Errorf("config overlays: %w", err)
+	}
+	for _, p := range layers {
+		overlay, _, err := config.ReadDoc(p)
+		if err != nil {
+			return nil, fmt.Errorf("parse config overlay: %s: %w", filepath.Base(p), err)
+		}
+		if data, err = config.MergeJSON(data, overlay); err != nil {
+			return nil, fmt.Errorf("merge config overlay %s: %w", filepath.Base(p), err)
+		}
+	}
 
 	var cfg CLIConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
@@ -191,6 +239,7 @@ func TryLoadCLIConfig(path string) (*CLIConfig, error) {
 
 	cfg.BaseDir = filepath.Dir(path)
 	cfg.ConfigPath = path
+	cfg.Profile = profile
 
 	// Essential defaults.
 	if cfg.ListenAddr == "" {
@@ -263,6 +312,23 @@ func (cfg *CLIConfig) HistoryDBFor(clientID string) string {
 }
 
 // NewAPIClientFromConfig creates an API client from CLIConfig.
+// It prefers the daemon's unix socket when one is listening.
+// Requests are scoped to the active workspace, if one is selected.
 func (cfg *CLIConfig) NewAPIClient() *APIClient {
-	return NewAPIClient(cfg.ListenAddr, cfg.APIToken)
+	api := NewAPIClient(cfg.ListenAddr, cfg.APIToken)
+	if sock := cfg.SocketPath(); sock != "" {
+		api.Client.Transport = socketTransport(sock)
+	}
+	if cfg.ActiveWorkspace != "" {
+		api.ClientID = cfg.WorkspaceClientID(cfg.ActiveWorkspace)
+	}
+	return api
+}
+
+// WorkspaceClientID returns the client ID a named workspace runs under.
+func (cfg *CLIConfig) WorkspaceClientID(name string) string {
+	if ws, ok := cfg.Workspaces[name]; ok && ws.ClientID != "" {
+		return ws.ClientID
+	}
+	return "cli_" + name
 }
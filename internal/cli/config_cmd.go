@@ -5,8 +5,8 @@
 // These three functions/constants are tightly coupled to root's migration table
 // and cannot be cleanly extracted without moving migrate.go to internal/.
 // configMigrate below calls the daemon's /api/config/migrate endpoint instead.
-// All other subcommands (show, set, validate, history, rollback, diff, snapshot,
-// show-version, versions) are fully self-contained here.
+// All other subcommands (show, set, validate, lint, schema, convert, history,
+// rollback, diff, snapshot, show-version, versions) are fully self-contained here.
 
 package cli
 
@@ -20,6 +20,8 @@ import (
 	"strconv"
 	"strings"
 
+	"tetora/internal/cfgfile"
+	"tetora/internal/config"
 	"tetora/internal/cron"
 	"tetora/internal/version"
 )
@@ -33,8 +35,9 @@ type validateRateLimitConfig struct {
 }
 
 type validateTLSConfig struct {
-	CertFile string `json:"certFile,omitempty"`
-	KeyFile  string `json:"keyFile,omitempty"`
+	CertFile     string `json:"certFile,omitempty"`
+	KeyFile      string `json:"keyFile,omitempty"`
+	ClientCAFile string `json:"clientCAFile,omitempty"`
 }
 
 type validateSecurityAlertConfig struct {
@@ -53,6 +56,16 @@ type validateDashboardAuthConfig struct {
 	Enabled  bool   `json:"enabled"`
 	Password string `json:"password,omitempty"`
 	Token    string `json:"token,omitempty"`
+	Accounts []struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	} `json:"accounts,omitempty"`
+	OIDC struct {
+		Enabled  bool   `json:"enabled"`
+		Issuer   string `json:"issuer,omitempty"`
+		ClientID string `json:"clientId,omitempty"`
+	} `json:"oidc,omitempty"`
 }
 
 type validateWebhookConfig struct {
@@ -108,7 +121,7 @@ type validateFullConfig struct {
 
 func CmdConfig(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: tetora config <show|set|validate|migrate|history|rollback|diff|snapshot|show-version|versions>")
+		fmt.Println("Usage: tetora config <show|set|validate|lint|schema|convert|profiles|migrate|history|rollback|diff|snapshot|show-version|versions>")
 		return
 	}
 	// Try version-related subcommands first.
@@ -117,7 +130,9 @@ func CmdConfig(args []string) {
 	}
 	switch args[0] {
 	case "show":
-		configShow()
+		configShow(args[1:])
+	case "profiles":
+		configProfiles()
 	case "set":
 		if len(args) < 3 {
 			fmt.Println("Usage: tetora config set <key> <value>")
@@ -126,6 +141,13 @@ func CmdConfig(args []string) {
 		configSet(args[1], strings.Join(args[2:], " "))
 	case "validate":
 		configValidate()
+	case "lint":
+		configLint(args[1:])
+	case "schema":
+		out, _ := json.MarshalIndent(config.Schema(), "", "  ")
+		fmt.Println(string(out))
+	case "convert":
+		configConvert(args[1:])
 	case "migrate":
 		configMigrate(args[1:])
 	default:
@@ -173,10 +195,176 @@ func configMigrate(args []string) {
 	}
 }
 
-// configShow prints config with secrets masked.
-func configShow() {
+// configLint checks config files against the config schema and prints
+// each problem with its line and key. With no arguments it lints
+// the config file and the overlays merged over it: config.d, the active
+// profile and the .local override. It exits 1 on errors.
+func configLint(args []string) {
+	asJSON := false
+	var paths []string
+	for _, a := range args {
+		if a == "--json" {
+			asJSON = true
+		} else {
+			paths = append(paths, a)
+		}
+	}
+	if len(paths) == 0 {
+		configPath := FindConfigPath()
+		layers, err := config.Layers(configPath, config.ActiveProfile())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		paths = append([]string{configPath}, layers...)
+	}
+
+	issues := []config.Issue{}
+	for _, p := range paths {
+		found, err := config.LintFile(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		issues = append(issues, found...)
+	}
+
+	if asJSON {
+		out, _ := json.MarshalIndent(map[string]any{"valid": !config.HasErrors(issues), "issues": issues}, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		for _, i := range issues {
+			fmt.Println(i)
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: OK\n", strings.Join(paths, ", "))
+		}
+	}
+	if config.HasErrors(issues) {
+		os.Exit(1)
+	}
+}
+
+// configConvert rewrites a config file in another format. ${VAR}
+// references are kept as written; comments are not carried over. With
+// --write the converted file replaces the original, which is kept as .bak,
+// since config.json would otherwise still be found first.
+func configConvert(args []string) {
+	usage := "Usage: tetora config convert [file] --to <json|yaml|toml> [--output <file> | --write]"
+	var src, to, output string
+	write := false
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--to" && i+1 < len(args):
+			i++
+			to = args[i]
+		case (a == "--output" || a == "-o") && i+1 < len(args):
+			i++
+			output = args[i]
+		case a == "--write":
+			write = true
+		case strings.HasPrefix(a, "-") || src != "":
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		default:
+			src = a
+		}
+	}
+	if src == "" {
+		src = FindConfigPath()
+	}
+	var format cfgfile.Format
+	switch {
+	case to != "":
+		f, err := cfgfile.ParseFormat(to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		format = f
+	case output != "":
+		format = cfgfile.FormatOf(output)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	doc, err := cfgfile.Parse(data, cfgfile.FormatOf(src))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", src, err)
+		os.Exit(1)
+	}
+	out, err := doc.Encode(format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if write {
+		output = strings.TrimSuffix(src, filepath.Ext(src)) + "." + string(format)
+	}
+	if output == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if output == src {
+		fmt.Fprintf(os.Stderr, "Error: %s is already %s\n", src, format)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(output); err == nil {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists\n", output)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(output, out, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !write {
+		fmt.Printf("Wrote %s\n", output)
+		return
+	}
+	if err := os.Rename(src, src+".bak"); err != nil {
+		os.Remove(output)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Converted %s to %s (original kept as %s.bak).\n", src, output, src)
+	fmt.Println("Restart the daemon (or send it SIGHUP) to apply the config changes.")
+}
+
+// configShow prints config with secrets masked. With --effective it prints
+// the config the daemon runs with: the config file with config.d, the active
+// profile and the .local override merged in, and ${VAR} references expanded.
+func configShow(args []string) {
+	effective := false
+	for _, a := range args {
+		if a == "--effective" {
+			effective = true
+		}
+	}
+
 	configPath := FindConfigPath()
-	data, err := os.ReadFile(configPath)
+	var data []byte
+	var err error
+	if effective {
+		var layers []string
+		profile := config.ActiveProfile()
+		data, layers, err = config.ReadEffective(configPath, profile, true)
+		if err == nil {
+			if profile == "" {
+				profile = "(none)"
+			}
+			fmt.Fprintf(os.Stderr, "# profile: %s\n# layers: %s\n", profile,
+				strings.Join(append([]string{configPath}, layers...), ", "))
+		}
+	} else {
+		data, err = cfgfile.ReadJSON(configPath)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
 		os.Exit(1)
@@ -195,6 +383,33 @@ func configShow() {
 	fmt.Println(string(out))
 }
 
+// configProfiles lists the profiles defined in config.d and the overlay
+// files each one adds. The active profile is marked with "*".
+func configProfiles() {
+	configPath := FindConfigPath()
+	active := config.ActiveProfile()
+	profiles := config.Profiles(configPath)
+	if len(profiles) == 0 {
+		fmt.Printf("No profiles. Create %s/<name>/ next to %s to add one.\n",
+			config.OverlayDir, filepath.Base(configPath))
+		return
+	}
+	for _, name := range profiles {
+		mark := " "
+		if name == active {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, name)
+		dir := filepath.Join(filepath.Dir(configPath), config.OverlayDir, name)
+		layers, _ := config.Layers(configPath, name)
+		for _, p := range layers {
+			if filepath.Dir(p) == dir {
+				fmt.Printf("    %s\n", filepath.Join(config.OverlayDir, name, filepath.Base(p)))
+			}
+		}
+	}
+}
+
 // maskSecrets replaces known secret values with "***".
 func maskSecrets(m map[string]any) {
 	secretKeys := []string{"apiToken", "botToken", "password", "token", "apiKey"}
@@ -225,7 +440,7 @@ func maskSecrets(m map[string]any) {
 // configSet updates a single config field using dot-path notation.
 func configSet(key, value string) {
 	configPath := FindConfigPath()
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
 		os.Exit(1)
@@ -281,7 +496,7 @@ func configSet(key, value string) {
 		fmt.Fprintf(os.Stderr, "Error encoding config: %v\n", err)
 		os.Exit(1)
 	}
-	if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing config: %v\n", err)
 		os.Exit(1)
 	}
@@ -298,14 +513,23 @@ func configValidate() {
 	configPath := FindConfigPath()
 
 	// Load full config including validate-only fields.
-	data, err := os.ReadFile(configPath)
-	if err != nil {
+	data, raw, err := config.ReadDoc(configPath)
+	if raw == nil {
 		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
 		os.Exit(1)
 	}
+	format := cfgfile.FormatOf(configPath)
 	var cfg validateFullConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	if err == nil {
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing config: %v\n", err)
+		for _, i := range config.LintDoc(raw, format) {
+			if i.Level == "error" {
+				fmt.Fprintf(os.Stderr, "  %s\n", i)
+			}
+		}
 		os.Exit(1)
 	}
 	// Resolve paths so we can stat files.
@@ -316,6 +540,9 @@ func configValidate() {
 	if cfg.TLS.KeyFile != "" && !filepath.IsAbs(cfg.TLS.KeyFile) {
 		cfg.TLS.KeyFile = filepath.Join(baseDir, cfg.TLS.KeyFile)
 	}
+	if cfg.TLS.ClientCAFile != "" && !filepath.IsAbs(cfg.TLS.ClientCAFile) {
+		cfg.TLS.ClientCAFile = filepath.Join(baseDir, cfg.TLS.ClientCAFile)
+	}
 	cfg.TLSEnabled = cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
 
 	// Also load CLIConfig for resolved HistoryDB/JobsFile paths.
@@ -339,6 +566,19 @@ func configValidate() {
 	fmt.Println("=== Config Validation ===")
 	fmt.Println()
 
+	// Schema: unknown keys and wrong types, with their line.
+	lintIssues := config.LintDoc(raw, format)
+	for _, i := range lintIssues {
+		level := "WARN"
+		if i.Level == "error" {
+			level = "ERROR"
+		}
+		check(false, level, fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message))
+	}
+	if len(lintIssues) == 0 {
+		check(true, "", "schema: no unknown keys or type errors")
+	}
+
 	// Claude binary.
 	claudePath := lcfg.ClaudePath
 	if claudePath == "" {
@@ -370,8 +610,25 @@ func configValidate() {
 
 	// Dashboard auth.
 	if cfg.DashboardAuth.Enabled {
-		hasCreds := (cfg.DashboardAuth.Password != "") || (cfg.DashboardAuth.Token != "")
+		oidc := cfg.DashboardAuth.OIDC
+		accounts := cfg.DashboardAuth.Accounts
+		hasCreds := (cfg.DashboardAuth.Password != "") || (cfg.DashboardAuth.Token != "") || oidc.Enabled || len(accounts) > 0
 		check(hasCreds, "ERROR", "dashboardAuth credentials set")
+		seen := map[string]bool{cfg.DashboardAuth.Password: true, cfg.DashboardAuth.Token: true}
+		for i, a := range accounts {
+			validRole := a.Role == "viewer" || a.Role == "operator" || a.Role == "admin"
+			check(a.Name != "" && !strings.Contains(a.Name, ":") && a.Password != "" && validRole, "ERROR",
+				fmt.Sprintf("dashboardAuth.accounts[%d] has name, password and role viewer|operator|admin", i))
+			check(!seen[a.Password], "ERROR", fmt.Sprintf("dashboardAuth.accounts[%d] password is unique", i))
+			seen[a.Password] = true
+		}
+		if len(accounts) > 0 {
+			check(cfg.APIToken != "", "WARN", "apiToken set (account roles only apply to API calls when it is)")
+		}
+		if oidc.Enabled {
+			check(oidc.Issuer != "" && oidc.ClientID != "", "ERROR", "dashboardAuth.oidc issuer and clientId set")
+			check(cfg.APIToken != "", "WARN", "apiToken set (SSO roles only apply to API calls when it is)")
+		}
 	}
 
 	// Agents — check soul files exist.
@@ -461,6 +718,10 @@ func configValidate() {
 		check(certErr == nil, "ERROR", fmt.Sprintf("tls.certFile: %s", cfg.TLS.CertFile))
 		_, keyErr := os.Stat(cfg.TLS.KeyFile)
 		check(keyErr == nil, "ERROR", fmt.Sprintf("tls.keyFile: %s", cfg.TLS.KeyFile))
+		if cfg.TLS.ClientCAFile != "" {
+			_, caErr := os.Stat(cfg.TLS.ClientCAFile)
+			check(caErr == nil, "ERROR", fmt.Sprintf("tls.clientCAFile: %s (client certificates required)", cfg.TLS.ClientCAFile))
+		}
 	} else {
 		fmt.Println("  --    TLS disabled (HTTP only)")
 	}
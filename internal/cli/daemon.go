@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// daemonProbeTimeout bounds how long commands wait to find out whether a
+// daemon is running before falling back to direct database access.
+const daemonProbeTimeout = 500 * time.Millisecond
+
+// CLI modes, selected with TETORA_CLI_MODE.
+const (
+	cliModeAuto   = "auto"   // use the daemon when one answers, else the databases
+	cliModeDaemon = "daemon" // require the daemon
+	cliModeDirect = "direct" // never contact the daemon
+)
+
+func cliMode() string {
+	switch m := strings.ToLower(strings.TrimSpace(os.Getenv("TETORA_CLI_MODE"))); m {
+	case cliModeDaemon, cliModeDirect:
+		return m
+	default:
+		return cliModeAuto
+	}
+}
+
+// SocketPath returns the daemon's unix socket, mirroring HTTPConfig.SocketPath.
+func (cfg *CLIConfig) SocketPath() string {
+	switch s := strings.TrimSpace(cfg.HTTP.Socket); s {
+	case "off", "none", "-":
+		return ""
+	case "":
+		if cfg.BaseDir == "" {
+			return ""
+		}
+		return filepath.Join(cfg.BaseDir, "tetora.sock")
+	default:
+		if !filepath.IsAbs(s) && cfg.BaseDir != "" {
+			s = filepath.Join(cfg.BaseDir, s)
+		}
+		return s
+	}
+}
+
+// socketTransport dials the daemon's unix socket, falling back to TCP when
+// nothing listens on it (socket disabled, or a daemon on another host).
+func socketTransport(sock string) *http.Transport {
+	var d net.Dialer
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if c, err := d.DialContext(ctx, "unix", sock); err == nil {
+				return c, nil
+			}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// ErrNoDaemon is returned by requests on the nil client Daemon returns when
+// no daemon is running.
+var ErrNoDaemon = errors.New("daemon not running")
+
+// Daemon returns a client for the running daemon, or nil when none answers,
+// in which case the caller reads the databases directly. Going through the
+// daemon avoids racing its writes to SQLite. The probe runs once per process;
+// TETORA_CLI_MODE=direct skips it and TETORA_CLI_MODE=daemon exits when no
+// daemon is up.
+func (cfg *CLIConfig) Daemon() *APIClient {
+	mode := cliMode()
+	if mode == cliModeDirect {
+		return nil
+	}
+	if cfg.daemonUp == nil {
+		up := cfg.probeDaemon()
+		cfg.daemonUp = &up
+	}
+	if !*cfg.daemonUp {
+		if mode == cliModeDaemon {
+			fmt.Fprintf(os.Stderr, "Error: daemon not reachable at %s (TETORA_CLI_MODE=daemon)\n", cfg.ListenAddr)
+			os.Exit(1)
+		}
+		return nil
+	}
+	return cfg.NewAPIClient()
+}
+
+func (cfg *CLIConfig) probeDaemon() bool {
+	api := cfg.NewAPIClient()
+	api.Client.Timeout = daemonProbeTimeout
+	resp, err := api.Get("/healthz")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound
+}
+
+// APIError is a non-2xx response from the daemon.
+type APIError struct {
+	Status  int
+	Message string
+	Body    []byte // raw response body, for endpoints that return details
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("daemon: %s (HTTP %d)", e.Message, e.Status)
+}
+
+// IsNotFound reports whether err is a 404 from the daemon.
+func IsNotFound(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// GetJSON fetches path and decodes the JSON response into v. Non-2xx
+// responses are returned as *APIError carrying the server's message.
+func (c *APIClient) GetJSON(path string, v any) error {
+	resp, err := c.Get(path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &APIError{Status: resp.StatusCode, Message: msg, Body: body}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
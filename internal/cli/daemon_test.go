@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveUnix starts a fake daemon on <dir>/tetora.sock.
+func serveUnix(t *testing.T, dir string, h http.Handler) {
+	t.Helper()
+	ln, err := net.Listen("unix", filepath.Join(dir, "tetora.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+}
+
+// shortTempDir keeps socket paths under the platform's sun_path limit.
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "tcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// deadAddr returns a loopback address nothing listens on.
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestDaemon_UnixSocket(t *testing.T) {
+	t.Setenv("TETORA_CLI_MODE", "")
+	dir := shortTempDir(t)
+	var gotClient string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) })
+	mux.HandleFunc("/history/cost", func(w http.ResponseWriter, r *http.Request) {
+		gotClient = r.Header.Get("X-Client-ID")
+		w.Write([]byte(`{"today":1.5,"week":2,"month":3}`))
+	})
+	mux.HandleFunc("/history/7", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	})
+	serveUnix(t, dir, mux)
+
+	cfg := &CLIConfig{BaseDir: dir, ListenAddr: deadAddr(t)}
+	api := historyAPI(cfg, "cli_acme")
+	if api == nil {
+		t.Fatal("daemon on the unix socket not detected")
+	}
+	var stats struct{ Today, Week, Month float64 }
+	if err := api.GetJSON("/history/cost", &stats); err != nil || stats.Today != 1.5 {
+		t.Fatalf("GetJSON = %+v, %v", stats, err)
+	}
+	if gotClient != "cli_acme" {
+		t.Errorf("X-Client-ID = %q", gotClient)
+	}
+
+	err := api.GetJSON("/history/7", &stats)
+	var apiErr *APIError
+	if !IsNotFound(err) || !errors.As(err, &apiErr) || apiErr.Message != "not found" {
+		t.Errorf("404 error = %v", err)
+	}
+}
+
+func TestDaemon_NoneRunning(t *testing.T) {
+	t.Setenv("TETORA_CLI_MODE", "")
+	cfg := &CLIConfig{BaseDir: shortTempDir(t), ListenAddr: deadAddr(t)}
+	api := cfg.Daemon()
+	if api != nil {
+		t.Fatal("Daemon() returned a client with no daemon running")
+	}
+	if _, err := api.Get("/trust"); !errors.Is(err, ErrNoDaemon) {
+		t.Errorf("nil client Get error = %v", err)
+	}
+}
+
+func TestDaemon_DirectMode(t *testing.T) {
+	t.Setenv("TETORA_CLI_MODE", "direct")
+	dir := shortTempDir(t)
+	serveUnix(t, dir, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("direct mode contacted the daemon")
+	}))
+	cfg := &CLIConfig{BaseDir: dir, ListenAddr: deadAddr(t)}
+	if cfg.Daemon() != nil {
+		t.Error("Daemon() returned a client in direct mode")
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	cases := []struct {
+		socket, want string
+	}{
+		{"", "/srv/tetora/tetora.sock"},
+		{"off", ""},
+		{"run/t.sock", "/srv/tetora/run/t.sock"},
+		{"/tmp/t.sock", "/tmp/t.sock"},
+	}
+	for _, c := range cases {
+		cfg := &CLIConfig{BaseDir: "/srv/tetora", HTTP: HTTPInfo{Socket: c.socket}}
+		if got := cfg.SocketPath(); got != filepath.FromSlash(c.want) {
+			t.Errorf("SocketPath(%q) = %q, want %q", c.socket, got, c.want)
+		}
+	}
+}
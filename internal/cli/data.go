@@ -8,10 +8,23 @@ import (
 	"time"
 
 	"tetora/internal/db"
+	"tetora/internal/export"
 )
 
+// DataDeps holds the root-package callbacks for the per-user commands,
+// which need the full config and the at-rest encryption keys.
+type DataDeps struct {
+	// ExportUser writes everything tied to a user to an archive.
+	ExportUser func(userID string) (*export.UserResult, error)
+	// EraseUser deletes everything tied to a user and returns the signed
+	// manifest and where it was written.
+	EraseUser func(userID string, dryRun bool) (*export.Manifest, string, error)
+	// VerifyManifest checks a manifest's signature.
+	VerifyManifest func(m *export.Manifest) bool
+}
+
 // CmdData implements `tetora data`.
-func CmdData(args []string) {
+func CmdData(args []string, deps DataDeps) {
 	if len(args) == 0 {
 		printDataUsage()
 		return
@@ -23,7 +36,11 @@ func CmdData(args []string) {
 	case "cleanup":
 		cmdDataCleanup(args[1:])
 	case "export":
-		cmdDataExport(args[1:])
+		cmdDataExport(args[1:], deps)
+	case "erase":
+		cmdDataErase(args[1:], deps)
+	case "verify":
+		cmdDataVerify(args[1:], deps)
 	case "purge":
 		cmdDataPurge(args[1:])
 	default:
@@ -43,12 +60,19 @@ Commands:
   status             Show retention config and database row counts
   cleanup [--dry-run] Run retention cleanup (delete expired data)
   export [--output F] Export all user data as JSON (GDPR)
+  export --user ID [--output F]
+                     Export everything tied to one user as a ZIP archive
+  erase --user ID [--dry-run] [--confirm]
+                     Delete everything tied to one user
+  verify FILE        Check the signature of an export or erasure manifest
   purge --before DATE Permanently delete all data before date
 
 Examples:
   tetora data status
   tetora data cleanup --dry-run
   tetora data export --output my-data.json
+  tetora data export --user 3f2a... --output alice.zip
+  tetora data erase --user 3f2a... --confirm
   tetora data purge --before 2025-01-01 --confirm
 `)
 }
@@ -63,6 +87,7 @@ type dataRetentionConfig struct {
 	Reflections int      `json:"reflections"`
 	SLA         int      `json:"sla"`
 	TrustEvents int      `json:"trustEvents"`
+	Traces      int      `json:"traces"`
 	Handoffs    int      `json:"handoffs"`
 	Queue       int      `json:"queue"`
 	Versions    int      `json:"versions"`
@@ -104,6 +129,7 @@ func cmdDataStatus() {
 	fmt.Printf("  reflections:  %d days\n", dataRetentionDays(r.Reflections, 60))
 	fmt.Printf("  sla:          %d days\n", dataRetentionDays(r.SLA, 90))
 	fmt.Printf("  trustEvents:  %d days\n", dataRetentionDays(r.TrustEvents, 90))
+	fmt.Printf("  traces:       %d days\n", dataRetentionDays(r.Traces, 14))
 	fmt.Printf("  handoffs:     %d days\n", dataRetentionDays(r.Handoffs, 60))
 	fmt.Printf("  queue:        %d days\n", dataRetentionDays(r.Queue, 7))
 	fmt.Printf("  versions:     %d days\n", dataRetentionDays(r.Versions, 180))
@@ -160,12 +186,18 @@ func cmdDataCleanup(args []string) {
 	fmt.Println("Done.")
 }
 
-func cmdDataExport(args []string) {
+func cmdDataExport(args []string, deps DataDeps) {
 	fs := flag.NewFlagSet("data export", flag.ExitOnError)
 	output := fs.String("output", "", "output file path (default: stdout)")
 	format := fs.String("format", "json", "export format (json)")
+	user := fs.String("user", "", "export only the data tied to this user ID")
 	fs.Parse(args) //nolint:errcheck
 
+	if *user != "" {
+		cmdDataExportUser(*user, *output, deps)
+		return
+	}
+
 	if *format != "json" {
 		fmt.Fprintf(os.Stderr, "Unsupported format: %s (only json is supported)\n", *format)
 		os.Exit(1)
@@ -190,6 +222,94 @@ func cmdDataExport(args []string) {
 	}
 }
 
+func cmdDataExportUser(userID, output string, deps DataDeps) {
+	res, err := deps.ExportUser(userID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		os.Exit(1)
+	}
+	path := res.Filename
+	if output != "" {
+		if err := os.Rename(res.Filename, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Move archive failed: %v\n", err)
+			os.Exit(1)
+		}
+		path = output
+	}
+	fmt.Printf("Exported %d rows and %d files for %s to %s (%d bytes)\n",
+		res.Manifest.Rows(), len(res.Manifest.Files), userID, path, res.SizeBytes)
+	printManifestTables(res.Manifest)
+	if res.Manifest.Signature == "" {
+		fmt.Println("Manifest is unsigned (set apiToken to sign it).")
+	}
+}
+
+func cmdDataErase(args []string, deps DataDeps) {
+	fs := flag.NewFlagSet("data erase", flag.ExitOnError)
+	user := fs.String("user", "", "user ID to erase")
+	dryRun := fs.Bool("dry-run", false, "show what would be deleted without deleting")
+	confirm := fs.Bool("confirm", false, "confirm destructive operation")
+	fs.Parse(args) //nolint:errcheck
+
+	if *user == "" {
+		fmt.Fprintf(os.Stderr, "Error: --user is required\n")
+		os.Exit(1)
+	}
+	if !*dryRun && !*confirm {
+		fmt.Fprintf(os.Stderr, "WARNING: This will permanently delete all data tied to user %s.\n", *user)
+		fmt.Fprintf(os.Stderr, "Run with --dry-run to see what would be deleted, or add --confirm to proceed.\n")
+		os.Exit(1)
+	}
+
+	m, path, err := deps.EraseUser(*user, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erase failed: %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Printf("[dry-run] Would delete %d rows and %d files for %s:\n", m.Rows(), len(m.Files), *user)
+	} else {
+		fmt.Printf("Erased %d rows and %d files for %s:\n", m.Rows(), len(m.Files), *user)
+	}
+	printManifestTables(m)
+	for _, f := range m.Files {
+		if f.Error != "" {
+			fmt.Printf("  %-20s ERROR: %s\n", f.Path, f.Error)
+		}
+	}
+	if path != "" {
+		fmt.Printf("Manifest written to %s\n", path)
+	}
+}
+
+func cmdDataVerify(args []string, deps DataDeps) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: tetora data verify <manifest.json|export.zip>\n")
+		os.Exit(1)
+	}
+	m, err := export.LoadManifest(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if m.Signature == "" {
+		fmt.Fprintf(os.Stderr, "Manifest is unsigned.\n")
+		os.Exit(1)
+	}
+	if !deps.VerifyManifest(m) {
+		fmt.Fprintf(os.Stderr, "Signature does NOT match (modified, or signed with another apiToken).\n")
+		os.Exit(1)
+	}
+	fmt.Printf("Signature OK: %s of user %s at %s, %d rows, %d files.\n",
+		m.Action, m.UserID, m.CreatedAt, m.Rows(), len(m.Files))
+}
+
+func printManifestTables(m *export.Manifest) {
+	for _, t := range m.Tables {
+		fmt.Printf("  %-20s %d rows\n", t.Table, t.Rows)
+	}
+}
+
 func cmdDataPurge(args []string) {
 	fs := flag.NewFlagSet("data purge", flag.ExitOnError)
 	before := fs.String("before", "", "delete all data before this date (YYYY-MM-DD)")
@@ -284,6 +404,7 @@ func runRetentionCLI(cfg *CLIConfig) []dataRetentionResult {
 		{"reflections", "created_at", r.Reflections, 60},
 		{"sla_checks", "checked_at", r.SLA, 90},
 		{"trust_events", "created_at", r.TrustEvents, 90},
+		{"trace_events", "started_at", r.Traces, 14},
 		{"handoffs", "created_at", r.Handoffs, 60},
 		{"agent_messages", "created_at", r.Handoffs, 60},
 		{"offline_queue", "created_at", r.Queue, 7},
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"tetora/internal/cfgfile"
 )
 
 // CmdDiscord is the main entry point for `tetora discord`.
@@ -366,7 +368,7 @@ func discordGetWebhookChannels(cfg *CLIConfig) []NotificationChannel {
 // a Discord notification channel in config.json, preserving all other fields.
 // It also syncs cfg.discord.webhooks so named channels are accessible by key.
 func discordUpdateNotificationsConfig(configPath, name string, rc *NotificationChannel) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -450,7 +452,7 @@ func discordUpdateNotificationsConfig(configPath, name string, rc *NotificationC
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 // discordSendTestWebhook sends a test message to a Discord webhook URL.
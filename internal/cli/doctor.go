@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"tetora/internal/db"
+	"tetora/internal/gitops"
 )
 
 // CmdDoctor implements `tetora doctor`.
@@ -197,6 +198,35 @@ func CmdDoctor() {
 		doctorSuggest(false, "Workspace", fmt.Sprintf("not found: %s — run 'tetora init'", cfg.WorkspaceDir))
 	}
 
+	// 16. GitOps drift: local edits to files applied from the repo
+	if cfg.GitOps.Enabled {
+		var st gitops.Status
+		if api := cfg.Daemon(); api == nil || api.GetJSON("/api/gitops/status", &st) != nil || !st.Enabled {
+			st = gitops.Status{}
+			rev, drift, err := gitops.CheckDrift(gitops.StateDir(configPath))
+			if err != nil {
+				st.Error = err.Error()
+			} else {
+				st.Revision, st.Drift = rev, drift
+			}
+		}
+		switch {
+		case st.Error != "":
+			doctorCheck(false, "GitOps", strings.SplitN(st.Error, "\n", 2)[0])
+			ok = false
+		case st.Revision == "":
+			doctorSuggest(false, "GitOps", "nothing applied yet from "+cfg.GitOps.Repo)
+		case len(st.Drift) > 0:
+			doctorSuggest(false, "GitOps", fmt.Sprintf("%s: %d file(s) edited locally", shortRev(st.Revision), len(st.Drift)))
+			for _, d := range st.Drift {
+				fmt.Printf("      %s %s\n", d.State, d.Path)
+			}
+			suggestions = append(suggestions, "Commit local edits to the GitOps repo, or put the repo's files back with 'tetora gitops sync'")
+		default:
+			doctorCheck(true, "GitOps", shortRev(st.Revision)+" applied, no drift")
+		}
+	}
+
 	fmt.Println()
 	if ok && len(suggestions) == 0 {
 		fmt.Println("All checks passed.")
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"tetora/internal/db"
+)
+
+// faqEntryCLI mirrors faq.Entry for decoding API responses.
+type faqEntryCLI struct {
+	ID        int    `json:"id"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Hits      int    `json:"hits"`
+	LastHitAt string `json:"lastHitAt"`
+}
+
+func CmdFAQ(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		cmdFAQList()
+	case "add":
+		q, a, ok := strings.Cut(strings.Join(args[1:], " "), "|")
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Usage: tetora faq add <question> | <answer>")
+			os.Exit(1)
+		}
+		cmdFAQAdd(q, a)
+	case "edit":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora faq edit <id> <question> | <answer>")
+			os.Exit(1)
+		}
+		q, a, ok := strings.Cut(strings.Join(args[2:], " "), "|")
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Usage: tetora faq edit <id> <question> | <answer>")
+			os.Exit(1)
+		}
+		cmdFAQEdit(args[1], q, a)
+	case "rm", "remove", "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora faq rm <id>")
+			os.Exit(1)
+		}
+		cmdFAQRemove(args[1])
+	case "suggest":
+		cmdFAQSuggest()
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tetora faq <list|add|edit|rm|suggest>")
+		os.Exit(1)
+	}
+}
+
+func cmdFAQList() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "GET", "/api/faq", nil)
+
+	var list []faqEntryCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No FAQ entries.")
+		return
+	}
+
+	fmt.Printf("%-5s %-6s %-40s %s\n", "ID", "Hits", "Question", "Answer")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, e := range list {
+		fmt.Printf("%-5d %-6d %-40s %s\n", e.ID, e.Hits, db.Truncate(e.Question, 40), db.Truncate(e.Answer, 50))
+	}
+}
+
+func cmdFAQAdd(question, answer string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "POST", "/api/faq", map[string]string{
+		"question": strings.TrimSpace(question),
+		"answer":   strings.TrimSpace(answer),
+	})
+	var e faqEntryCLI
+	json.Unmarshal(body, &e)
+	fmt.Printf("Added FAQ #%d.\n", e.ID)
+}
+
+func cmdFAQEdit(id, question, answer string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	handoverRequest(cfg, "PUT", "/api/faq/"+id, map[string]string{
+		"question": strings.TrimSpace(question),
+		"answer":   strings.TrimSpace(answer),
+	})
+	fmt.Printf("Updated FAQ #%s.\n", id)
+}
+
+func cmdFAQRemove(id string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	handoverRequest(cfg, "DELETE", "/api/faq/"+id, nil)
+	fmt.Printf("Removed FAQ #%s.\n", id)
+}
+
+func cmdFAQSuggest() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "GET", "/api/faq/suggestions", nil)
+
+	var list []struct {
+		Question string   `json:"question"`
+		Count    int      `json:"count"`
+		Examples []string `json:"examples"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No repeated unanswered questions.")
+		return
+	}
+	for _, s := range list {
+		fmt.Printf("(%dx) %s\n", s.Count, s.Question)
+	}
+	fmt.Println("\nAdd one with: tetora faq add <question> | <answer>")
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"tetora/internal/db"
+)
+
+// feedbackEntryCLI mirrors feedback.Entry for decoding API responses.
+type feedbackEntryCLI struct {
+	TaskID    string `json:"taskId"`
+	Agent     string `json:"agent"`
+	Score     int    `json:"score"`
+	Comment   string `json:"comment"`
+	Source    string `json:"source"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func CmdFeedback(args []string) {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		q := url.Values{}
+		for _, a := range args[min(len(args), 1):] {
+			switch a {
+			case "up", "down":
+				q.Set("score", a)
+			default:
+				q.Set("agent", a)
+			}
+		}
+		cmdFeedbackList(q)
+		return
+	}
+	if len(args) < 2 || (args[1] != "up" && args[1] != "down") {
+		fmt.Fprintln(os.Stderr, "Usage: tetora feedback <taskId> <up|down> [comment...]")
+		fmt.Fprintln(os.Stderr, "       tetora feedback list [agent] [up|down]")
+		os.Exit(1)
+	}
+	cmdFeedbackGive(args[0], args[1], strings.Join(args[2:], " "))
+}
+
+func cmdFeedbackGive(taskID, score, comment string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "POST", "/feedback", map[string]string{
+		"taskId":  taskID,
+		"score":   score,
+		"comment": comment,
+		"source":  "cli",
+	})
+	var e feedbackEntryCLI
+	json.Unmarshal(body, &e)
+	fmt.Printf("Recorded %s on task %s (%s).\n", score, e.TaskID, e.Agent)
+}
+
+func cmdFeedbackList(q url.Values) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "GET", "/feedback?"+q.Encode(), nil)
+
+	var list []feedbackEntryCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No feedback.")
+		return
+	}
+
+	fmt.Printf("%-11s %-12s %-5s %-10s %-20s %s\n", "Task", "Agent", "Score", "Source", "Updated", "Comment")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, e := range list {
+		score := "up"
+		if e.Score < 0 {
+			score = "down"
+		}
+		fmt.Printf("%-11s %-12s %-5s %-10s %-20s %s\n", db.Truncate(e.TaskID, 8), e.Agent, score, e.Source, e.UpdatedAt, db.Truncate(e.Comment, 40))
+	}
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"tetora/internal/gitops"
+)
+
+// CmdGitOps implements `tetora gitops`.
+func CmdGitOps(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: tetora gitops <status|sync|drift> [options]")
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  status  Show the repo, the applied revision and the last check")
+		fmt.Println("  sync    Fetch the repo and apply it now [--dry-run] [--json]")
+		fmt.Println("  drift   List managed files edited locally; exits 1 if there are any")
+		return
+	}
+	switch args[0] {
+	case "status":
+		gitopsStatus(args[1:])
+	case "sync":
+		gitopsSync(args[1:])
+	case "drift":
+		gitopsDrift(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown action: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// gitopsStatus implements `tetora gitops status`.
+func gitopsStatus(args []string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	var st gitops.Status
+	if err := cfg.NewAPIClient().GetJSON("/api/gitops/status", &st); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (is the daemon running?)\n", err)
+		os.Exit(1)
+	}
+	if len(args) > 0 && args[0] == "--json" {
+		json.NewEncoder(os.Stdout).Encode(st)
+		return
+	}
+	if !st.Enabled {
+		fmt.Println("GitOps is not enabled (set gitops.enabled in config).")
+		return
+	}
+	branch := st.Branch
+	if st.Path != "" {
+		branch += ", " + st.Path + "/"
+	}
+	fmt.Printf("Repo:       %s (%s)\n", st.Repo, branch)
+	fmt.Printf("Interval:   %s\n", st.Interval)
+	fmt.Printf("Self-heal:  %t\n", st.SelfHeal)
+	if st.Revision == "" {
+		fmt.Println("Applied:    nothing yet")
+	} else {
+		fmt.Printf("Applied:    %s %s (%s, %d files)\n", shortRev(st.Revision), st.Subject, st.AppliedAt, st.Files)
+	}
+	if st.LastCheck != "" {
+		fetched := ""
+		if st.Fetched != "" && st.Fetched != st.Revision {
+			fetched = ", branch at " + shortRev(st.Fetched)
+		}
+		fmt.Printf("Checked:    %s%s\n", st.LastCheck, fetched)
+	}
+	if st.Error != "" {
+		fmt.Printf("Error:      %s\n", st.Error)
+	}
+	printDrift(st.Drift)
+}
+
+// gitopsSync implements `tetora gitops sync`: the daemon fetches the branch
+// and applies it now, over local edits too.
+func gitopsSync(args []string) {
+	req := map[string]any{}
+	asJSON := false
+	for _, a := range args {
+		switch a {
+		case "--dry-run", "-n":
+			req["dryRun"] = true
+		case "--json":
+			asJSON = true
+		case "--help", "-h":
+			fmt.Println("Usage: tetora gitops sync [--dry-run] [--json]")
+			fmt.Println()
+			fmt.Println("Fetches the branch, validates it and applies it, putting back managed")
+			fmt.Println("files edited locally. --dry-run lists what would change.")
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option %q\n", a)
+			os.Exit(1)
+		}
+	}
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	api := cfg.NewAPIClient()
+	api.Client.Timeout = 6 * time.Minute
+	resp, err := api.PostJSON("/api/gitops/sync", req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (is the daemon running?)\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		os.Exit(1)
+	}
+	if asJSON {
+		os.Stdout.Write(body)
+		return
+	}
+	var res gitops.Result
+	if err := json.Unmarshal(body, &res); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
+		os.Exit(1)
+	}
+	verb := "Applied"
+	if res.DryRun {
+		verb = "Dry run of"
+	}
+	fmt.Printf("%s %s %s: %d changed, %d deleted\n", verb, shortRev(res.Revision), res.Subject, len(res.Changed), len(res.Deleted))
+	for _, p := range res.Changed {
+		fmt.Printf("  ~ %s\n", p)
+	}
+	for _, p := range res.Deleted {
+		fmt.Printf("  - %s\n", p)
+	}
+	if res.Snapshot != "" {
+		fmt.Printf("Replaced files saved in %s\n", res.Snapshot)
+	}
+}
+
+// gitopsDrift implements `tetora gitops drift`. It asks the daemon, or reads
+// the applied state directly when the daemon is not running.
+func gitopsDrift(args []string) {
+	configPath := FindConfigPath()
+	cfg := LoadCLIConfig(configPath)
+	var st gitops.Status
+	if api := cfg.Daemon(); api == nil || api.GetJSON("/api/gitops/status", &st) != nil || !st.Enabled {
+		rev, drift, err := gitops.CheckDrift(gitops.StateDir(configPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		st = gitops.Status{Revision: rev, Drift: drift}
+	}
+	if st.Drift == nil {
+		st.Drift = []gitops.Drift{}
+	}
+	if len(args) > 0 && args[0] == "--json" {
+		json.NewEncoder(os.Stdout).Encode(st.Drift)
+	} else if st.Revision == "" {
+		fmt.Println("Nothing has been applied from a GitOps repo.")
+	} else if len(st.Drift) == 0 {
+		fmt.Printf("No drift from %s.\n", shortRev(st.Revision))
+	} else {
+		printDrift(st.Drift)
+	}
+	if len(st.Drift) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDrift(drift []gitops.Drift) {
+	if len(drift) == 0 {
+		return
+	}
+	fmt.Printf("Drift:      %d file(s) edited locally\n", len(drift))
+	for _, d := range drift {
+		fmt.Printf("  %-9s %s (%s)\n", d.State, d.Path, d.Live)
+	}
+}
+
+// shortRev abbreviates a commit hash for display.
+func shortRev(rev string) string {
+	if len(rev) > 7 {
+		return rev[:7]
+	}
+	return rev
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tetora/internal/db"
+)
+
+// handoverCLI mirrors handover.Handover for decoding API responses.
+type handoverCLI struct {
+	ID          string `json:"id"`
+	Platform    string `json:"platform"`
+	ChatID      string `json:"chatId"`
+	UserName    string `json:"userName"`
+	Reason      string `json:"reason"`
+	Status      string `json:"status"`
+	RequestedAt string `json:"requestedAt"`
+	ReleasedBy  string `json:"releasedBy"`
+}
+
+func CmdHandover(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		status := "open"
+		if len(args) > 1 && args[1] == "--all" {
+			status = ""
+		}
+		cmdHandoverList(status)
+	case "reply":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora handover reply <id> <text>")
+			os.Exit(1)
+		}
+		cmdHandoverReply(args[1], strings.Join(args[2:], " "))
+	case "release":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora handover release <id>")
+			os.Exit(1)
+		}
+		cmdHandoverRelease(args[1])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tetora handover <list [--all]|reply|release>")
+		os.Exit(1)
+	}
+}
+
+func cmdHandoverList(status string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	path := "/api/handovers"
+	if status != "" {
+		path += "?status=" + status
+	}
+	body := handoverRequest(cfg, "GET", path, nil)
+
+	var list []handoverCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No handovers.")
+		return
+	}
+
+	fmt.Printf("%-14s %-9s %-9s %-20s %-20s %s\n", "ID", "Status", "Platform", "Chat", "Requested", "Message")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, h := range list {
+		chat := h.ChatID
+		if h.UserName != "" && h.UserName != h.ChatID {
+			chat = h.UserName + "@" + h.ChatID
+		}
+		fmt.Printf("%-14s %-9s %-9s %-20s %-20s %s\n",
+			h.ID, h.Status, h.Platform, db.Truncate(chat, 20), h.RequestedAt, db.Truncate(h.Reason, 40))
+	}
+}
+
+func cmdHandoverReply(id, text string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "POST", "/api/handovers/"+id+"/reply", map[string]string{"text": text})
+
+	var res struct {
+		Delivered bool `json:"delivered"`
+	}
+	json.Unmarshal(body, &res)
+	if res.Delivered {
+		fmt.Printf("Reply sent to %s.\n", id)
+	} else {
+		fmt.Printf("Reply recorded on %s (channel cannot receive replies).\n", id)
+	}
+}
+
+func cmdHandoverRelease(id string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	handoverRequest(cfg, "POST", "/api/handovers/"+id+"/release", map[string]string{})
+	fmt.Printf("Handover %s released; agents are back on.\n", id)
+}
+
+// handoverRequest calls the daemon and exits on any failure.
+func handoverRequest(cfg *CLIConfig, method, path string, payload any) []byte {
+	api := cfg.NewAPIClient()
+	var body io.Reader
+	if payload != nil {
+		b, _ := json.Marshal(payload)
+		body = strings.NewReader(string(b))
+	}
+	resp, err := api.Do(method, path, body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: cannot reach daemon at %s: %v\n", cfg.ListenAddr, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		fmt.Fprintf(os.Stderr, "error: HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(data)))
+		os.Exit(1)
+	}
+	return data
+}
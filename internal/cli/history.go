@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -101,14 +102,30 @@ func historyList(args []string, clientID string) {
 		}
 	}
 
-	dbPath := resolveHistoryDB(cfg, clientID)
-	q := history.HistoryQuery{
-		JobID:  jobID,
-		Status: status,
-		From:   from,
-		Limit:  limit,
+	var runs []history.JobRun
+	var total int
+	var err error
+	if api := historyAPI(cfg, clientID); api != nil {
+		v := url.Values{"limit": {strconv.Itoa(limit)}}
+		for k, val := range map[string]string{"job_id": jobID, "status": status, "from": from} {
+			if val != "" {
+				v.Set(k, val)
+			}
+		}
+		var res struct {
+			Runs  []history.JobRun `json:"runs"`
+			Total int              `json:"total"`
+		}
+		err = api.GetJSON("/history?"+v.Encode(), &res)
+		runs, total = res.Runs, res.Total
+	} else {
+		runs, total, err = history.QueryFiltered(resolveHistoryDB(cfg, clientID), history.HistoryQuery{
+			JobID:  jobID,
+			Status: status,
+			From:   from,
+			Limit:  limit,
+		})
 	}
-	runs, total, err := history.QueryFiltered(dbPath, q)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -147,7 +164,15 @@ func historyShow(idStr string, clientID string) {
 		os.Exit(1)
 	}
 
-	run, err := history.QueryByID(resolveHistoryDB(cfg, clientID), id)
+	var run *history.JobRun
+	if api := historyAPI(cfg, clientID); api != nil {
+		run = new(history.JobRun)
+		if err = api.GetJSON("/history/"+strconv.Itoa(id), run); IsNotFound(err) {
+			run, err = nil, nil
+		}
+	} else {
+		run, err = history.QueryByID(resolveHistoryDB(cfg, clientID), id)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -182,7 +207,13 @@ func historyCost(clientID string) {
 		os.Exit(1)
 	}
 
-	stats, err := history.QueryCostStats(resolveHistoryDB(cfg, clientID))
+	var stats history.CostStats
+	var err error
+	if api := historyAPI(cfg, clientID); api != nil {
+		err = api.GetJSON("/history/cost", &stats)
+	} else {
+		stats, err = history.QueryCostStats(resolveHistoryDB(cfg, clientID))
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -228,11 +259,21 @@ func historyFails(args []string, clientID string) {
 		}
 	}
 
-	runs, err := history.QueryRecentFails(resolveHistoryDB(cfg, clientID), history.FailQuery{
-		JobID: jobID,
-		Days:  days,
-		Limit: limit,
-	})
+	var runs []history.JobRun
+	var err error
+	if api := historyAPI(cfg, clientID); api != nil {
+		v := url.Values{"days": {strconv.Itoa(days)}, "limit": {strconv.Itoa(limit)}}
+		if jobID != "" {
+			v.Set("job_id", jobID)
+		}
+		err = api.GetJSON("/history/fails?"+v.Encode(), &runs)
+	} else {
+		runs, err = history.QueryRecentFails(resolveHistoryDB(cfg, clientID), history.FailQuery{
+			JobID: jobID,
+			Days:  days,
+			Limit: limit,
+		})
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -273,7 +314,13 @@ func historyStreak(args []string, clientID string) {
 		}
 	}
 
-	results, err := history.QueryConsecutiveFails(resolveHistoryDB(cfg, clientID), threshold)
+	var results []history.ConsecutiveFailResult
+	var err error
+	if api := historyAPI(cfg, clientID); api != nil {
+		err = api.GetJSON("/history/streaks?threshold="+strconv.Itoa(threshold), &results)
+	} else {
+		results, err = history.QueryConsecutiveFails(resolveHistoryDB(cfg, clientID), threshold)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -309,7 +356,13 @@ func historyTrace(jobID string, args []string, clientID string) {
 		}
 	}
 
-	runs, err := history.QueryJobTrace(resolveHistoryDB(cfg, clientID), jobID, limit)
+	var runs []history.JobRun
+	var err error
+	if api := historyAPI(cfg, clientID); api != nil {
+		err = api.GetJSON("/history/trace/"+url.PathEscape(jobID)+"?limit="+strconv.Itoa(limit), &runs)
+	} else {
+		runs, err = history.QueryJobTrace(resolveHistoryDB(cfg, clientID), jobID, limit)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -331,6 +384,17 @@ func historyTrace(jobID string, args []string, clientID string) {
 	fmt.Printf("\nJob: %s\n", runs[0].Name)
 }
 
+// historyAPI returns a daemon client scoped to clientID, or nil to read the
+// history DB directly. An empty clientID means the daemon's default client,
+// matching resolveHistoryDB.
+func historyAPI(cfg *CLIConfig, clientID string) *APIClient {
+	api := cfg.Daemon()
+	if api != nil {
+		api.ClientID = clientID
+	}
+	return api
+}
+
 // resolveHistoryDB returns the history DB path for a given client ID.
 // If clientID is empty or matches the default, returns cfg.HistoryDB.
 func resolveHistoryDB(cfg *CLIConfig, clientID string) string {
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"tetora/internal/cfgfile"
 )
 
 // CmdImportConfig imports agents, channels, and settings from another config.json.
@@ -58,7 +60,7 @@ func CmdImportConfig(args []string) {
 	configPath := FindConfigPath()
 	configDir := filepath.Dir(configPath)
 
-	dstData, err := os.ReadFile(configPath)
+	dstData, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading current config (%s): %v\n", configPath, err)
 		os.Exit(1)
@@ -182,7 +184,7 @@ func CmdImportConfig(args []string) {
 		fmt.Fprintf(os.Stderr, "Error marshaling config: %v\n", err)
 		os.Exit(1)
 	}
-	if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing config: %v\n", err)
 		os.Exit(1)
 	}
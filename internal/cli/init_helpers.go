@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"tetora/internal/cfgfile"
 )
 
 // RandomListenAddr picks a random available port on 127.0.0.1 and returns
@@ -48,7 +50,7 @@ func DetectClaude() string {
 // writes it back. The mutation receives the decoded top-level map and may
 // modify it in place. Preserves all other fields via raw JSON round-trip.
 func MutateConfig(configPath string, mutate func(raw map[string]any)) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return err
 	}
@@ -61,5 +63,5 @@ func MutateConfig(configPath string, mutate func(raw map[string]any)) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
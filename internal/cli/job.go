@@ -3,26 +3,31 @@ package cli
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"tetora/internal/cron"
 	"tetora/internal/history"
+	"tetora/internal/scheduling"
 )
 
 func CmdJob(args []string) {
 	if len(args) == 0 {
 		fmt.Println("Usage: tetora job <list|add|enable|disable|remove|trigger> [id]")
+		fmt.Println("       tetora job add [--when \"every weekday at 9am\"] [--business-days] [--holidays JP]")
 		return
 	}
 	switch args[0] {
 	case "list", "ls":
 		jobList()
 	case "add":
-		jobAdd()
+		jobAdd(args[1:])
 	case "enable":
 		if len(args) < 2 {
 			fmt.Println("Usage: tetora job enable <id>")
@@ -89,14 +94,49 @@ func jobList() {
 		if avg, ok := avgCosts[j.ID]; ok {
 			avgStr = fmt.Sprintf("$%.2f", avg)
 		}
+		schedule := j.Schedule
+		if j.Once {
+			schedule += " (once)"
+		}
+		if j.BusinessDaysOnly {
+			schedule += " (business days)"
+		} else if j.Holidays != "" {
+			schedule += " (not on holidays)"
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-			status, j.ID, j.Name, j.Schedule, role, model, avgStr)
+			status, j.ID, j.Name, schedule, role, model, avgStr)
 	}
 	w.Flush()
 	fmt.Printf("\n%d jobs total\n", len(jf.Jobs))
 }
 
-func jobAdd() {
+func jobAdd(args []string) {
+	when, holidays := "", ""
+	businessDays := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--when" && i+1 < len(args):
+			when = args[i+1]
+			i++
+			continue
+		case args[i] == "--holidays" && i+1 < len(args):
+			holidays = args[i+1]
+			i++
+			continue
+		case args[i] == "--business-days":
+			businessDays = true
+			continue
+		}
+		fmt.Println("Usage: tetora job add [--when \"every weekday at 9am\"] [--business-days] [--holidays JP|<ics url>]")
+		return
+	}
+	if holidays != "" {
+		if _, err := scheduling.HolidaySource(holidays); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
 	scanner := bufio.NewScanner(os.Stdin)
 	prompt := func(label, defaultVal string) string {
 		if defaultVal != "" {
@@ -121,19 +161,31 @@ func jobAdd() {
 		return
 	}
 	name := prompt("Display name", id)
-	schedule := prompt("Cron schedule (m h dom mon dow)", "")
-	if schedule == "" {
+	if when == "" {
+		when = prompt(`Schedule (cron "m h dom mon dow" or e.g. "every weekday at 9am")`, "")
+	}
+	if when == "" {
 		fmt.Println("Schedule is required.")
 		return
 	}
+	tz := prompt("Timezone", "Asia/Taipei")
 
-	// Validate schedule.
-	if _, err := cron.Parse(schedule); err != nil {
+	cfg := LoadCLIConfig(FindConfigPath())
+	sched, err := readJobSchedule(cfg, when, tz)
+	if err != nil {
 		fmt.Printf("Invalid schedule: %v\n", err)
 		return
 	}
-
-	tz := prompt("Timezone", "Asia/Taipei")
+	fmt.Printf("  Schedule: %s\n", sched.Interpreted)
+	if sched.Once {
+		fmt.Println("  One-off: the job is disabled after it runs.")
+	}
+	if sched.Cron != strings.Join(strings.Fields(when), " ") &&
+		strings.ToLower(prompt("Use this schedule? [Y/n]", "y")) != "y" {
+		fmt.Println("Cancelled.")
+		return
+	}
+	schedule := sched.Cron
 	promptText := prompt("Prompt", "")
 	if promptText == "" {
 		fmt.Println("Prompt is required.")
@@ -185,11 +237,13 @@ func jobAdd() {
 			Budget:         budget,
 			PermissionMode: permMode,
 		},
-		Notify: notify,
+		Notify:           notify,
+		Once:             sched.Once,
+		BusinessDaysOnly: businessDays,
+		Holidays:         holidays,
 	}
 
 	// Load, append, save.
-	cfg := LoadCLIConfig(FindConfigPath())
 	jf := LoadJobsFile(cfg.JobsFile)
 
 	// Check duplicate ID.
@@ -276,3 +330,53 @@ func jobTrigger(id string) {
 		fmt.Printf("Error: %v\n", result["error"])
 	}
 }
+
+// jobSchedule is the schedule read for a new job.
+type jobSchedule struct {
+	Cron        string `json:"cron"`
+	Once        bool   `json:"once"`
+	Interpreted string `json:"interpreted"`
+}
+
+// readJobSchedule reads a cron expression or a schedule phrase in tz.
+// Phrases the local parser cannot read are sent to the daemon, which asks a
+// model.
+func readJobSchedule(cfg *CLIConfig, text, tz string) (jobSchedule, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return jobSchedule{}, fmt.Errorf("bad timezone %q", tz)
+	}
+	now := time.Now().In(loc)
+	w, err := cron.ParseWhen(text, now)
+	if err == nil {
+		s := jobSchedule{Cron: w.Cron, Once: w.Once(), Interpreted: w.Summary}
+		if !w.Once() {
+			s.Interpreted += fmt.Sprintf(" (%s)", w.Cron)
+			if next := w.Next(now); !next.IsZero() {
+				s.Interpreted += ", next run " + next.Format("Mon 2 Jan 15:04")
+			}
+		}
+		return s, nil
+	}
+	if !errors.Is(err, cron.ErrUnrecognized) {
+		return jobSchedule{}, err
+	}
+
+	resp, perr := cfg.NewAPIClient().PostJSON("/cron/parse", map[string]string{"text": text, "tz": tz})
+	if perr != nil {
+		return jobSchedule{}, fmt.Errorf("%v (start the daemon to have a model read it)", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		jobSchedule
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != http.StatusOK {
+		if out.Error != "" {
+			return jobSchedule{}, errors.New(out.Error)
+		}
+		return jobSchedule{}, fmt.Errorf("daemon: %s", resp.Status)
+	}
+	return out.jobSchedule, nil
+}
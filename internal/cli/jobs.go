@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"tetora/internal/config"
 )
 
 // JobsFile is the top-level structure of jobs.json.
@@ -31,22 +33,31 @@ type JobConfig struct {
 	IdleMinHours    float64    `json:"idleMinHours,omitempty"`
 	IdleMinMinutes  int        `json:"idleMinMinutes,omitempty"`
 	CooldownHours   float64    `json:"cooldownHours,omitempty"`
+	Once            bool       `json:"once,omitempty"`
+
+	BusinessDaysOnly bool                 `json:"businessDaysOnly,omitempty"`
+	Holidays         string               `json:"holidays,omitempty"`
+	QuietHours       *config.QuietWindow  `json:"quietHours,omitempty"`
+	User             string               `json:"user,omitempty"`
+	Retention        *config.JobRetention `json:"retention,omitempty"`
+	Note             string               `json:"note,omitempty"`
 }
 
 // TaskConfig mirrors CronTaskConfig.
 type TaskConfig struct {
-	Prompt         string   `json:"prompt"`
-	PromptFile     string   `json:"promptFile,omitempty"`
-	Workdir        string   `json:"workdir,omitempty"`
-	Model          string   `json:"model,omitempty"`
-	Provider       string   `json:"provider,omitempty"`
-	Docker         *bool    `json:"docker,omitempty"`
-	Timeout        string   `json:"timeout,omitempty"`
-	Budget         float64  `json:"budget,omitempty"`
-	PermissionMode string   `json:"permissionMode,omitempty"`
-	MCP            []string `json:"mcp,omitempty"`
-	AddDirs        []string `json:"addDirs,omitempty"`
-	ScopeBoundary  string   `json:"scopeBoundary,omitempty"` // diagnostic_only | implement_allowed | test_only | review_only
+	Prompt         string            `json:"prompt"`
+	PromptFile     string            `json:"promptFile,omitempty"`
+	PromptVars     map[string]string `json:"promptVars,omitempty"`
+	Workdir        string            `json:"workdir,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	Provider       string            `json:"provider,omitempty"`
+	Docker         *bool             `json:"docker,omitempty"`
+	Timeout        string            `json:"timeout,omitempty"`
+	Budget         float64           `json:"budget,omitempty"`
+	PermissionMode string            `json:"permissionMode,omitempty"`
+	MCP            []string          `json:"mcp,omitempty"`
+	AddDirs        []string          `json:"addDirs,omitempty"`
+	ScopeBoundary  string            `json:"scopeBoundary,omitempty"` // diagnostic_only | implement_allowed | test_only | review_only
 }
 
 // JobStatus mirrors CronJobInfo (API response from /cron).
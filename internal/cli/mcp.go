@@ -10,6 +10,8 @@ import (
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"tetora/internal/cfgfile"
 )
 
 // MCPConfigInfo represents summary info about an MCP server config.
@@ -266,7 +268,7 @@ func deleteMCPConfig(cfg *CLIConfig, configPath, name string) error {
 // updateConfigMCPs updates a single MCP config in config.json.
 // If config is nil, the MCP entry is removed. Otherwise it is added/updated.
 func updateConfigMCPs(configPath, mcpName string, config json.RawMessage) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -297,7 +299,7 @@ func updateConfigMCPs(configPath, mcpName string, config json.RawMessage) error
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 func testMCPConfig(raw json.RawMessage) (bool, string) {
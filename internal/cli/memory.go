@@ -1,31 +1,61 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"tetora/internal/db"
 )
 
 // MemoryEntry represents a key-value memory entry.
 type MemoryEntry struct {
-	Key       string `json:"key"`
-	Value     string `json:"value"`
-	Priority  string `json:"priority,omitempty"`
-	UpdatedAt string `json:"updatedAt"`
+	Key        string  `json:"key"`
+	Value      string  `json:"value"`
+	Priority   string  `json:"priority,omitempty"`
+	UpdatedAt  string  `json:"updatedAt"`
+	ExpiresAt  string  `json:"expiresAt,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Role       string  `json:"role,omitempty"`
+	Namespace  string  `json:"namespace,omitempty"`
+	CreatedAt  string  `json:"createdAt,omitempty"`
+}
+
+// memoryProposal mirrors memory.Proposal.
+type memoryProposal struct {
+	Key        string  `json:"key"`
+	Verdict    string  `json:"verdict"`
+	Confidence float64 `json:"confidence"`
+	Value      string  `json:"value"`
+	Reason     string  `json:"reason"`
+	Priority   string  `json:"priority"`
+	Current    string  `json:"current"`
 }
 
 func CmdMemory(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: tetora memory <list|get|set|delete> [options]")
+		fmt.Println("Usage: tetora memory <list|get|set|delete|review|export|import> [options]")
 		fmt.Println()
 		fmt.Println("Commands:")
-		fmt.Println("  list   [--agent AGENT]              List memory entries")
+		fmt.Println("  list   [--agent AGENT] [--namespace NS]")
+		fmt.Println("                                      List memory entries")
 		fmt.Println("  get    <key> --agent AGENT          Get a memory value")
 		fmt.Println("  set    <key> <value> --agent AGENT  Set a memory value")
+		fmt.Println("         [--ttl 90d] [--confidence 0.8]")
 		fmt.Println("  delete <key> --agent AGENT          Delete a memory entry")
+		fmt.Println("  review [run | accept <key> | keep <key>]")
+		fmt.Println("                                      Staleness review proposals (needs the daemon)")
+		fmt.Println("  export [--agent AGENT] [--namespace NS] [--format jsonl] [-o FILE]")
+		fmt.Println("                                      Write entries as JSON lines")
+		fmt.Println("  import <file|dir|-> [--agent AGENT] [--namespace NS] [--format jsonl|notes]")
+		fmt.Println("         [--overwrite] [--dry-run]    Add entries, skipping duplicates")
 		return
 	}
 	switch args[0] {
@@ -37,6 +67,12 @@ func CmdMemory(args []string) {
 		memorySet(args[1:])
 	case "delete", "rm":
 		memoryDelete(args[1:])
+	case "review":
+		memoryReview(args[1:])
+	case "export":
+		memoryExport(args[1:])
+	case "import":
+		memoryImport(args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown memory action: %s\n", args[0])
 		os.Exit(1)
@@ -59,7 +95,8 @@ func ParseRoleFlag(args []string) (string, []string) {
 }
 
 func memoryList(args []string) {
-	role, _ := ParseRoleFlag(args)
+	role, rest := ParseRoleFlag(args)
+	namespace, _ := parseNamespaceFlag(rest)
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	entries, err := listMemory(cfg, role)
@@ -67,6 +104,7 @@ func memoryList(args []string) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	entries = filterMemoryNamespace(entries, namespace)
 
 	if len(entries) == 0 {
 		if role != "" {
@@ -78,14 +116,22 @@ func memoryList(args []string) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "KEY\tVALUE\tUPDATED")
+	fmt.Fprintln(w, "KEY\tVALUE\tCONF\tEXPIRES\tUPDATED")
+	now := time.Now()
 	for _, e := range entries {
 		val := e.Value
 		if len(val) > 60 {
 			val = val[:60] + "..."
 		}
 		val = strings.ReplaceAll(val, "\n", " ")
-		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, val, e.UpdatedAt)
+		expires := "-"
+		if t, err := time.Parse(time.RFC3339, e.ExpiresAt); err == nil {
+			expires = t.Local().Format("2006-01-02")
+			if !now.Before(t) {
+				expires = "expired"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\n", e.Key, val, e.Confidence, expires, e.UpdatedAt)
 	}
 	w.Flush()
 }
@@ -116,9 +162,36 @@ func memoryGet(args []string) {
 }
 
 func memorySet(args []string) {
-	role, remaining := ParseRoleFlag(args)
+	role, rest := ParseRoleFlag(args)
+	var (
+		remaining  []string
+		expiresAt  string
+		confidence float64
+	)
+	for i := 0; i < len(rest); i++ {
+		switch {
+		case rest[i] == "--ttl" && i+1 < len(rest):
+			ttl, err := parseMemoryTTL(rest[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			expiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+			i++
+		case rest[i] == "--confidence" && i+1 < len(rest):
+			v, err := strconv.ParseFloat(rest[i+1], 64)
+			if err != nil || v <= 0 || v > 1 {
+				fmt.Fprintln(os.Stderr, "Error: --confidence must be a number in (0, 1]")
+				os.Exit(1)
+			}
+			confidence = v
+			i++
+		default:
+			remaining = append(remaining, rest[i])
+		}
+	}
 	if len(remaining) < 2 {
-		fmt.Fprintln(os.Stderr, "Usage: tetora memory set <key> <value> --agent AGENT")
+		fmt.Fprintln(os.Stderr, "Usage: tetora memory set <key> <value> --agent AGENT [--ttl 90d] [--confidence 0.8]")
 		os.Exit(1)
 	}
 	if role == "" {
@@ -129,13 +202,97 @@ func memorySet(args []string) {
 	value := strings.Join(remaining[1:], " ")
 	cfg := LoadCLIConfig(FindConfigPath())
 
-	if err := setMemory(cfg, role, key, value); err != nil {
+	var fields map[string]string
+	if expiresAt != "" || confidence > 0 {
+		fields = map[string]string{}
+		if expiresAt != "" {
+			fields["expires_at"] = expiresAt
+		}
+		if confidence > 0 {
+			fields["confidence"] = strconv.FormatFloat(confidence, 'f', -1, 64)
+		}
+	}
+	if err := setMemory(cfg, role, key, value, fields); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("Set %s.%s\n", role, key)
 }
 
+// memoryReview lists, runs and settles staleness review proposals through
+// the daemon.
+func memoryReview(args []string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "":
+		var out struct {
+			Pending []memoryProposal `json:"pending"`
+		}
+		if err := json.Unmarshal(handoverRequest(cfg, "GET", "/memory/review", nil), &out); err != nil {
+			fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+			os.Exit(1)
+		}
+		if len(out.Pending) == 0 {
+			fmt.Println("No memory changes waiting for confirmation.")
+			return
+		}
+		for _, p := range out.Pending {
+			fmt.Printf("%s [%s] → %s (confidence %.2f)\n", p.Key, p.Priority, p.Verdict, p.Confidence)
+			if p.Reason != "" {
+				fmt.Printf("  why: %s\n", p.Reason)
+			}
+			fmt.Printf("  now: %s\n", db.Truncate(strings.ReplaceAll(strings.TrimSpace(p.Current), "\n", " "), 200))
+			if p.Value != "" {
+				fmt.Printf("  new: %s\n", db.Truncate(strings.ReplaceAll(p.Value, "\n", " "), 200))
+			}
+		}
+		fmt.Println("\nSettle with: tetora memory review accept|keep <key>")
+	case "run":
+		var res struct {
+			Reviewed int     `json:"reviewed"`
+			Applied  int     `json:"applied"`
+			Proposed int     `json:"proposed"`
+			CostUSD  float64 `json:"costUsd"`
+		}
+		if err := json.Unmarshal(handoverRequest(cfg, "POST", "/memory/review/run", nil), &res); err != nil {
+			fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Reviewed %d entries: %d updated, %d waiting for confirmation ($%.4f).\n",
+			res.Reviewed, res.Applied, res.Proposed, res.CostUSD)
+	case "accept", "keep":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Usage: tetora memory review %s <key>\n", action)
+			os.Exit(1)
+		}
+		handoverRequest(cfg, "POST", "/memory/review/"+url.PathEscape(args[1]), map[string]string{"action": action})
+		if action == "accept" {
+			fmt.Printf("Applied the review of %s.\n", args[1])
+		} else {
+			fmt.Printf("Kept %s as it is.\n", args[1])
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tetora memory review [run | accept <key> | keep <key>]")
+		os.Exit(1)
+	}
+}
+
+// parseMemoryTTL parses "90d" or a Go duration such as "12h".
+func parseMemoryTTL(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(24*time.Hour)), nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid ttl %q (use e.g. \"90d\" or \"12h\")", s)
+}
+
 func memoryDelete(args []string) {
 	role, remaining := ParseRoleFlag(args)
 	if len(remaining) < 1 {
@@ -163,35 +320,59 @@ func sanitizeMemoryKey(key string) string {
 	return r.Replace(key)
 }
 
-func parseMemoryFrontmatter(data []byte) (priority string, body string) {
+// splitMemoryFile separates the frontmatter lines ("name: value") from the body.
+func splitMemoryFile(data []byte) (front []string, body string) {
 	s := string(data)
 	if !strings.HasPrefix(s, "---\n") {
-		return "P1", s
+		return nil, s
 	}
 	end := strings.Index(s[4:], "\n---\n")
 	if end < 0 {
-		return "P1", s
+		return nil, s
 	}
-	front := s[4 : 4+end]
-	body = s[4+end+5:]
-	priority = "P1"
-	for _, line := range strings.Split(front, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "priority:") {
-			val := strings.TrimSpace(strings.TrimPrefix(line, "priority:"))
-			if val == "P0" || val == "P1" || val == "P2" {
-				priority = val
-			}
+	for _, line := range strings.Split(s[4:4+end], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			front = append(front, line)
+		}
+	}
+	return front, s[4+end+5:]
+}
+
+// frontmatterValue returns the value of a frontmatter field, or "".
+func frontmatterValue(front []string, name string) string {
+	for _, line := range front {
+		if v, ok := strings.CutPrefix(line, name+":"); ok {
+			return strings.TrimSpace(v)
 		}
 	}
+	return ""
+}
+
+// setFrontmatterValue replaces or appends a frontmatter field.
+func setFrontmatterValue(front []string, name, value string) []string {
+	for i, line := range front {
+		if strings.HasPrefix(line, name+":") {
+			front[i] = name + ": " + value
+			return front
+		}
+	}
+	return append(front, name+": "+value)
+}
+
+func parseMemoryFrontmatter(data []byte) (priority string, body string) {
+	front, body := splitMemoryFile(data)
+	priority = "P1"
+	if val := frontmatterValue(front, "priority"); val == "P0" || val == "P1" || val == "P2" {
+		priority = val
+	}
 	return priority, body
 }
 
-func buildMemoryFrontmatter(priority, body string) string {
-	if priority == "" || priority == "P1" {
+func buildMemoryFile(front []string, body string) string {
+	if len(front) == 0 {
 		return body
 	}
-	return "---\npriority: " + priority + "\n---\n" + body
+	return "---\n" + strings.Join(front, "\n") + "\n---\n" + body
 }
 
 func getMemory(cfg *CLIConfig, role, key string) (string, error) {
@@ -204,20 +385,33 @@ func getMemory(cfg *CLIConfig, role, key string) (string, error) {
 	return body, nil
 }
 
-func setMemory(cfg *CLIConfig, role, key, value string) error {
+// setMemory writes an entry, keeping its existing frontmatter and setting
+// the given fields (e.g. expires_at, confidence).
+func setMemory(cfg *CLIConfig, role, key, value string, fields map[string]string) error {
 	dir := filepath.Join(cfg.WorkspaceDir, "memory")
 	os.MkdirAll(dir, 0o755)
 	path := filepath.Join(dir, sanitizeMemoryKey(key)+".md")
 
-	pri := ""
+	var front []string
 	if existing, err := os.ReadFile(path); err == nil {
-		pri, _ = parseMemoryFrontmatter(existing)
+		front, _ = splitMemoryFile(existing)
+	} else {
+		front = []string{"created_at: " + time.Now().UTC().Format(time.RFC3339)}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		front = setFrontmatterValue(front, name, fields[name])
 	}
 
-	content := buildMemoryFrontmatter(pri, value)
-	return os.WriteFile(path, []byte(content), 0o644)
+	return os.WriteFile(path, []byte(buildMemoryFile(front, value)), 0o644)
 }
 
+// listMemory lists the entries visible to role (all when empty): shared
+// entries and those owned by role.
 func listMemory(cfg *CLIConfig, role string) ([]MemoryEntry, error) {
 	dir := filepath.Join(cfg.WorkspaceDir, "memory")
 	entries, err := os.ReadDir(dir)
@@ -238,17 +432,31 @@ func listMemory(cfg *CLIConfig, role string) ([]MemoryEntry, error) {
 		if err != nil {
 			continue
 		}
+		front, _ := splitMemoryFile(data)
 		priority, body := parseMemoryFrontmatter(data)
+		entryRole := frontmatterValue(front, "role")
+		if role != "" && entryRole != "" && entryRole != role {
+			continue
+		}
 		info, _ := e.Info()
 		updatedAt := ""
 		if info != nil {
 			updatedAt = info.ModTime().Format(time.RFC3339)
 		}
+		confidence, err := strconv.ParseFloat(frontmatterValue(front, "confidence"), 64)
+		if err != nil || confidence <= 0 || confidence > 1 {
+			confidence = 1
+		}
 		result = append(result, MemoryEntry{
-			Key:       key,
-			Value:     body,
-			Priority:  priority,
-			UpdatedAt: updatedAt,
+			Key:        key,
+			Value:      body,
+			Priority:   priority,
+			UpdatedAt:  updatedAt,
+			ExpiresAt:  frontmatterValue(front, "expires_at"),
+			Confidence: confidence,
+			Role:       entryRole,
+			Namespace:  frontmatterValue(front, "namespace"),
+			CreatedAt:  frontmatterValue(front, "created_at"),
 		})
 	}
 	return result, nil
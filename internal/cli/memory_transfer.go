@@ -0,0 +1,422 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memoryRecord is one line of a JSONL memory export.
+type memoryRecord struct {
+	Key        string  `json:"key"`
+	Value      string  `json:"value"`
+	Role       string  `json:"role,omitempty"`
+	Namespace  string  `json:"namespace,omitempty"`
+	Priority   string  `json:"priority,omitempty"`
+	CreatedAt  string  `json:"createdAt,omitempty"`
+	UpdatedAt  string  `json:"updatedAt,omitempty"`
+	ExpiresAt  string  `json:"expiresAt,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// memoryImportOptions controls how records are merged into the store.
+type memoryImportOptions struct {
+	Role      string // assign every record to this agent
+	Namespace string // put every record in this namespace
+	Overwrite bool   // replace entries whose key exists with different content
+	DryRun    bool
+}
+
+// memoryImportStats counts what an import did (or would do).
+type memoryImportStats struct {
+	Added      int
+	Updated    int
+	Retagged   int // same content, new role or namespace
+	Duplicates int
+	Conflicts  []string
+}
+
+var memoryNamespaceRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// parseNamespaceFlag extracts --namespace (or -n) from args and returns the rest.
+func parseNamespaceFlag(args []string) (string, []string) {
+	ns := ""
+	var remaining []string
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "--namespace" || args[i] == "-n") && i+1 < len(args) {
+			ns = args[i+1]
+			i++
+		} else {
+			remaining = append(remaining, args[i])
+		}
+	}
+	return ns, remaining
+}
+
+func filterMemoryNamespace(entries []MemoryEntry, ns string) []MemoryEntry {
+	if ns == "" {
+		return entries
+	}
+	var out []MemoryEntry
+	for _, e := range entries {
+		if e.Namespace == ns {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func memoryExport(args []string) {
+	role, rest := ParseRoleFlag(args)
+	ns, rest := parseNamespaceFlag(rest)
+	format, output := "jsonl", ""
+	for i := 0; i < len(rest); i++ {
+		switch {
+		case rest[i] == "--format" && i+1 < len(rest):
+			format = rest[i+1]
+			i++
+		case (rest[i] == "-o" || rest[i] == "--output") && i+1 < len(rest):
+			output = rest[i+1]
+			i++
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: tetora memory export [--agent AGENT] [--namespace NS] [--format jsonl] [-o FILE]")
+			os.Exit(1)
+		}
+	}
+	if format != "jsonl" {
+		fmt.Fprintf(os.Stderr, "Error: unsupported format %q (use jsonl)\n", format)
+		os.Exit(1)
+	}
+	cfg := LoadCLIConfig(FindConfigPath())
+
+	entries, err := listMemory(cfg, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var selected []MemoryEntry
+	for _, e := range filterMemoryNamespace(entries, ns) {
+		if role == "" || e.Role == role {
+			selected = append(selected, e)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeMemoryRecords(w, selected); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output != "" {
+		fmt.Printf("Exported %d entries to %s\n", len(selected), output)
+	}
+}
+
+func memoryImport(args []string) {
+	role, rest := ParseRoleFlag(args)
+	ns, rest := parseNamespaceFlag(rest)
+	var (
+		opts   = memoryImportOptions{Role: role, Namespace: ns}
+		format string
+		paths  []string
+	)
+	for i := 0; i < len(rest); i++ {
+		switch {
+		case rest[i] == "--format" && i+1 < len(rest):
+			format = rest[i+1]
+			i++
+		case rest[i] == "--overwrite":
+			opts.Overwrite = true
+		case rest[i] == "--dry-run":
+			opts.DryRun = true
+		default:
+			paths = append(paths, rest[i])
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tetora memory import <file|dir|-> [--agent AGENT] [--namespace NS] [--format jsonl|notes] [--overwrite] [--dry-run]")
+		os.Exit(1)
+	}
+	if ns != "" && !memoryNamespaceRe.MatchString(ns) {
+		fmt.Fprintf(os.Stderr, "Error: invalid namespace %q (lowercase letters, digits, '-', '_')\n", ns)
+		os.Exit(1)
+	}
+	cfg := LoadCLIConfig(FindConfigPath())
+	if role != "" {
+		if _, ok := cfg.Agents[role]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown agent %q\n", role)
+			os.Exit(1)
+		}
+	}
+
+	var recs []memoryRecord
+	for _, p := range paths {
+		f := format
+		if f == "" {
+			f = "jsonl"
+			if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+				f = "notes"
+			}
+		}
+		var (
+			got []memoryRecord
+			err error
+		)
+		switch f {
+		case "jsonl":
+			got, err = readMemoryRecordsFrom(p)
+		case "notes":
+			got, err = readNoteRecords(p)
+		default:
+			err = fmt.Errorf("unsupported format %q (use jsonl or notes)", f)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", p, err)
+			os.Exit(1)
+		}
+		recs = append(recs, got...)
+	}
+
+	st, err := importMemoryRecords(cfg, recs, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	verb := "Imported"
+	if opts.DryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d of %d records: %d added, %d updated, %d retagged, %d duplicates skipped",
+		verb, st.Added+st.Updated+st.Retagged, len(recs), st.Added, st.Updated, st.Retagged, st.Duplicates)
+	if len(st.Conflicts) > 0 {
+		fmt.Printf(", %d conflicts skipped (use --overwrite): %s", len(st.Conflicts), strings.Join(st.Conflicts, ", "))
+	}
+	fmt.Println()
+}
+
+// writeMemoryRecords writes entries as JSON lines.
+func writeMemoryRecords(w io.Writer, entries []MemoryEntry) error {
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		rec := memoryRecord{
+			Key:       e.Key,
+			Value:     e.Value,
+			Role:      e.Role,
+			Namespace: e.Namespace,
+			CreatedAt: e.CreatedAt,
+			UpdatedAt: e.UpdatedAt,
+			ExpiresAt: e.ExpiresAt,
+		}
+		if e.Priority != "P1" {
+			rec.Priority = e.Priority
+		}
+		if e.Confidence < 1 {
+			rec.Confidence = e.Confidence
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMemoryRecordsFrom(path string) ([]memoryRecord, error) {
+	if path == "-" {
+		return readMemoryRecords(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readMemoryRecords(f)
+}
+
+// readMemoryRecords parses JSON lines, skipping blank lines.
+func readMemoryRecords(r io.Reader) ([]memoryRecord, error) {
+	var recs []memoryRecord
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec memoryRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if rec.Key == "" || strings.TrimSpace(rec.Value) == "" {
+			return nil, fmt.Errorf("line %d: key and value are required", n)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+// readNoteRecords turns a Markdown or text file, or every such file under a
+// directory, into one record each, keyed by its path without extension.
+func readNoteRecords(path string) ([]memoryRecord, error) {
+	var recs []memoryRecord
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(p)
+		if ext != ".md" && ext != ".txt" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, body := splitMemoryFile(data)
+		if strings.TrimSpace(body) == "" {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil || rel == "." {
+			rel = filepath.Base(p)
+		}
+		key := strings.ReplaceAll(strings.TrimSuffix(filepath.ToSlash(rel), ext), "/", "-")
+		recs = append(recs, memoryRecord{Key: key, Value: strings.TrimSpace(body) + "\n"})
+		return nil
+	})
+	return recs, err
+}
+
+// importMemoryRecords merges records into the store. An entry with the same
+// key and content is a duplicate (or is retagged when the record moves it to
+// another role or namespace); one with the same key but other content is a
+// conflict unless opts.Overwrite is set. A new key whose content already
+// exists under another key is a duplicate too.
+func importMemoryRecords(cfg *CLIConfig, recs []memoryRecord, opts memoryImportOptions) (memoryImportStats, error) {
+	var st memoryImportStats
+	existing, err := listMemory(cfg, "")
+	if err != nil {
+		return st, err
+	}
+	byKey := make(map[string]MemoryEntry, len(existing))
+	content := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		byKey[e.Key] = e
+		content[normalizeMemoryValue(e.Value)] = true
+	}
+
+	for _, rec := range recs {
+		if opts.Role != "" {
+			rec.Role = opts.Role
+		}
+		if opts.Namespace != "" {
+			rec.Namespace = opts.Namespace
+		}
+		if err := validateMemoryRecord(rec); err != nil {
+			return st, err
+		}
+		key := sanitizeMemoryKey(rec.Key)
+		norm := normalizeMemoryValue(rec.Value)
+		cur, exists := byKey[key]
+
+		fields := map[string]string{}
+		if rec.Role != "" {
+			fields["role"] = rec.Role
+		}
+		if rec.Namespace != "" {
+			fields["namespace"] = rec.Namespace
+		}
+		value := rec.Value
+		switch {
+		case exists && normalizeMemoryValue(cur.Value) == norm:
+			if (rec.Role == "" || rec.Role == cur.Role) && (rec.Namespace == "" || rec.Namespace == cur.Namespace) {
+				st.Duplicates++
+				continue
+			}
+			value = cur.Value
+			st.Retagged++
+		case exists && !opts.Overwrite:
+			st.Conflicts = append(st.Conflicts, key)
+			continue
+		case exists:
+			st.Updated++
+		case content[norm]:
+			st.Duplicates++
+			continue
+		default:
+			if rec.Priority == "P0" || rec.Priority == "P2" {
+				fields["priority"] = rec.Priority
+			}
+			if rec.CreatedAt != "" {
+				fields["created_at"] = rec.CreatedAt
+			}
+			st.Added++
+		}
+		if rec.ExpiresAt != "" {
+			fields["expires_at"] = rec.ExpiresAt
+		}
+		if rec.Confidence > 0 && rec.Confidence <= 1 {
+			fields["confidence"] = strconv.FormatFloat(rec.Confidence, 'f', -1, 64)
+		}
+
+		content[norm] = true
+		next := MemoryEntry{Key: key, Value: value, Role: cur.Role, Namespace: cur.Namespace}
+		if rec.Role != "" {
+			next.Role = rec.Role
+		}
+		if rec.Namespace != "" {
+			next.Namespace = rec.Namespace
+		}
+		byKey[key] = next
+		if opts.DryRun {
+			continue
+		}
+		if err := setMemory(cfg, rec.Role, key, value, fields); err != nil {
+			return st, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return st, nil
+}
+
+// validateMemoryRecord rejects fields that would not survive as single
+// frontmatter lines.
+func validateMemoryRecord(rec memoryRecord) error {
+	if rec.Namespace != "" && !memoryNamespaceRe.MatchString(rec.Namespace) {
+		return fmt.Errorf("%s: invalid namespace %q", rec.Key, rec.Namespace)
+	}
+	if strings.ContainsAny(rec.Role, "\r\n") || strings.ContainsAny(rec.Key, "\r\n") {
+		return fmt.Errorf("%q: key and role must be single-line", rec.Key)
+	}
+	for _, ts := range []string{rec.CreatedAt, rec.ExpiresAt} {
+		if _, err := time.Parse(time.RFC3339, ts); ts != "" && err != nil {
+			return fmt.Errorf("%s: invalid timestamp %q", rec.Key, ts)
+		}
+	}
+	return nil
+}
+
+// normalizeMemoryValue collapses whitespace so formatting-only differences
+// do not defeat deduplication.
+func normalizeMemoryValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
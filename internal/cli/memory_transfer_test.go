@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryRecordsRoundTrip(t *testing.T) {
+	entries := []MemoryEntry{
+		{Key: "editor", Value: "Uses vim\n", Priority: "P1", Confidence: 1},
+		{Key: "office", Value: "Floor 3", Priority: "P0", Confidence: 0.6, Role: "kokuyou", Namespace: "work", ExpiresAt: "2027-01-01T00:00:00Z"},
+	}
+	var buf bytes.Buffer
+	if err := writeMemoryRecords(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"priority":"P1"`) || strings.Contains(buf.String(), `"confidence":1`) {
+		t.Errorf("defaults should be omitted:\n%s", buf.String())
+	}
+	recs, err := readMemoryRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[1].Role != "kokuyou" || recs[1].Namespace != "work" || recs[1].Priority != "P0" || recs[1].Confidence != 0.6 {
+		t.Errorf("records = %+v", recs)
+	}
+
+	if _, err := readMemoryRecords(strings.NewReader("\n{\"key\":\"a\",\"value\":\"x\"}\n{\"key\":\"b\"}\n")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("missing value error = %v", err)
+	}
+}
+
+func TestImportMemoryRecords(t *testing.T) {
+	cfg := &CLIConfig{WorkspaceDir: t.TempDir()}
+	setMemory(cfg, "", "editor", "Uses vim", nil)
+	setMemory(cfg, "", "office", "Floor 3", nil)
+
+	recs := []memoryRecord{
+		{Key: "editor", Value: "Uses   vim\n"},                           // duplicate
+		{Key: "office", Value: "Floor 3", Namespace: "work"},             // retagged
+		{Key: "stack", Value: "Go", Priority: "P0"},                      // added
+		{Key: "lang", Value: "Uses vim"},                                 // duplicate by content
+		{Key: "editor", Value: "Uses emacs"},                             // conflict
+		{Key: "home", Value: "Kyoto", CreatedAt: "2024-05-01T00:00:00Z"}, // added
+	}
+
+	st, err := importMemoryRecords(cfg, recs, memoryImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Added != 2 || st.Retagged != 1 || st.Duplicates != 2 || len(st.Conflicts) != 1 {
+		t.Errorf("dry-run stats = %+v", st)
+	}
+	if got, _ := getMemory(cfg, "", "stack"); got != "" {
+		t.Errorf("dry run wrote stack = %q", got)
+	}
+
+	st, err = importMemoryRecords(cfg, recs, memoryImportOptions{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Added != 2 || st.Updated != 1 || len(st.Conflicts) != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	entries, _ := listMemory(cfg, "")
+	byKey := map[string]MemoryEntry{}
+	for _, e := range entries {
+		byKey[e.Key] = e
+	}
+	if byKey["editor"].Value != "Uses emacs" || byKey["office"].Namespace != "work" || byKey["stack"].Priority != "P0" || byKey["home"].CreatedAt != "2024-05-01T00:00:00Z" {
+		t.Errorf("entries = %+v", byKey)
+	}
+	if _, ok := byKey["lang"]; ok {
+		t.Error("duplicate content imported under a new key")
+	}
+
+	// Moving an entry to a role hides it from other agents.
+	st, err = importMemoryRecords(cfg, []memoryRecord{{Key: "home", Value: "Kyoto"}}, memoryImportOptions{Role: "hisui", Namespace: "personal"})
+	if err != nil || st.Retagged != 1 {
+		t.Fatalf("retag = %+v, %v", st, err)
+	}
+	for _, e := range mustListMemory(t, cfg, "kokuyou") {
+		if e.Key == "home" {
+			t.Error("role-owned entry visible to another agent")
+		}
+	}
+	if e := findMemoryEntry(mustListMemory(t, cfg, "hisui"), "home"); e == nil || e.Role != "hisui" || e.Namespace != "personal" {
+		t.Errorf("retagged entry = %+v", e)
+	}
+
+	for _, bad := range []memoryRecord{
+		{Key: "x", Value: "v", Namespace: "Work Stuff"},
+		{Key: "x", Value: "v", Role: "a\nrole: b"},
+		{Key: "x", Value: "v", ExpiresAt: "tomorrow"},
+	} {
+		if _, err := importMemoryRecords(cfg, []memoryRecord{bad}, memoryImportOptions{}); err == nil {
+			t.Errorf("invalid record accepted: %+v", bad)
+		}
+	}
+}
+
+func TestReadNoteRecords(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "people"), 0o755)
+	os.MkdirAll(filepath.Join(dir, ".obsidian"), 0o755)
+	os.WriteFile(filepath.Join(dir, "people", "alice.md"), []byte("---\ntags: friend\n---\nLikes tea\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "todo.txt"), []byte("Buy milk"), 0o644)
+	os.WriteFile(filepath.Join(dir, "empty.md"), []byte("  \n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "photo.png"), []byte("png"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".obsidian", "app.md"), []byte("config"), 0o644)
+
+	recs, err := readNoteRecords(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Key != "people-alice" || recs[0].Value != "Likes tea\n" || recs[1].Key != "todo" {
+		t.Errorf("records = %+v", recs)
+	}
+}
+
+func mustListMemory(t *testing.T, cfg *CLIConfig, role string) []MemoryEntry {
+	t.Helper()
+	entries, err := listMemory(cfg, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func findMemoryEntry(entries []MemoryEntry, key string) *MemoryEntry {
+	for i := range entries {
+		if entries[i].Key == key {
+			return &entries[i]
+		}
+	}
+	return nil
+}
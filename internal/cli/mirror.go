@@ -17,12 +17,14 @@ import (
 // CmdMirror implements `tetora mirror`.
 func CmdMirror(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: tetora mirror <start|send|watch> [options]")
+		fmt.Println("Usage: tetora mirror <start|send|watch|sync|status> [options]")
 		fmt.Println()
 		fmt.Println("Commands:")
 		fmt.Println("  start   Create a mirror session and print hook config")
 		fmt.Println("  send    Send a message to a mirror session (reads from stdin)")
 		fmt.Println("  watch   Watch a Discord channel session in real-time")
+		fmt.Println("  sync    Sync agents, prompts, skills and workflows with the peer instance")
+		fmt.Println("  status  Show the mirror peer and the last sync")
 		return
 	}
 	switch args[0] {
@@ -32,6 +34,10 @@ func CmdMirror(args []string) {
 		mirrorSend(args[1:])
 	case "watch":
 		mirrorWatch(args[1:])
+	case "sync":
+		mirrorSync(args[1:])
+	case "status":
+		mirrorStatus(args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown action: %s\n", args[0])
 		os.Exit(1)
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"tetora/internal/mirror"
+)
+
+// mirrorSync implements `tetora mirror sync`: the daemon syncs with its
+// mirror peer now and the result is printed.
+func mirrorSync(args []string) {
+	req := map[string]any{}
+	asJSON := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--push":
+			req["direction"] = "push"
+		case "--pull":
+			req["direction"] = "pull"
+		case "--dry-run", "-n":
+			req["dryRun"] = true
+		case "--prefer", "--kinds":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "%s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			if args[i-1] == "--prefer" {
+				if args[i] != "local" && args[i] != "remote" {
+					fmt.Fprintf(os.Stderr, "Invalid --prefer %q (want local or remote)\n", args[i])
+					os.Exit(1)
+				}
+				req["conflict"] = args[i]
+			} else {
+				req["kinds"] = strings.Split(args[i], ",")
+			}
+		case "--json":
+			asJSON = true
+		case "--help", "-h":
+			fmt.Println("Usage: tetora mirror sync [--push|--pull] [--dry-run] [--prefer local|remote] [--kinds agents,prompts,skills,workflows] [--json]")
+			fmt.Println()
+			fmt.Println("Copies items changed on one side to the other. Items changed on both")
+			fmt.Println("sides are reported as conflicts and left alone, unless --prefer picks")
+			fmt.Println("the side that wins.")
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown option %q\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	api := cfg.NewAPIClient()
+	api.Client.Timeout = 3 * time.Minute
+	resp, err := api.PostJSON("/api/mirror/run", req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (is the daemon running?)\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		os.Exit(1)
+	}
+	if asJSON {
+		os.Stdout.Write(body)
+		return
+	}
+	var res mirror.Result
+	if err := json.Unmarshal(body, &res); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
+		os.Exit(1)
+	}
+	printMirrorResult(&res)
+	if len(res.Conflicts) > 0 || len(res.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printMirrorResult(res *mirror.Result) {
+	verb := "Synced"
+	if res.DryRun {
+		verb = "Dry run"
+	}
+	fmt.Printf("%s with %s (%s): %d in sync, %d pushed, %d pulled, %d conflicts\n",
+		verb, res.Peer, res.Direction, res.InSync, len(res.Pushed), len(res.Pulled), len(res.Conflicts))
+	for _, k := range res.Pushed {
+		fmt.Printf("  -> %s\n", k)
+	}
+	for _, k := range res.Pulled {
+		fmt.Printf("  <- %s\n", k)
+	}
+	for _, c := range res.Conflicts {
+		reason := c.Reason
+		if reason == "" {
+			reason = "changed on both sides"
+		}
+		fmt.Printf("  !! %s: %s (local %s, remote %s)\n", c.Item, reason, shortHash(c.Local), shortHash(c.Remote))
+	}
+	for _, f := range res.Failed {
+		fmt.Printf("  xx %s\n", f)
+	}
+	if len(res.Conflicts) > 0 && !res.DryRun {
+		fmt.Println()
+		fmt.Println("Resolve conflicts with: tetora mirror sync --prefer local|remote")
+	}
+}
+
+// shortHash abbreviates an item hash for display; "" is a deleted item.
+func shortHash(h string) string {
+	if h == "" {
+		return "deleted"
+	}
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// mirrorStatus implements `tetora mirror status`.
+func mirrorStatus(args []string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	var st struct {
+		Enabled   bool           `json:"enabled"`
+		Peer      string         `json:"peer"`
+		Direction string         `json:"direction"`
+		Interval  string         `json:"interval"`
+		Kinds     []string       `json:"kinds"`
+		Conflict  string         `json:"conflict"`
+		Last      *mirror.Result `json:"last"`
+	}
+	if err := cfg.NewAPIClient().GetJSON("/api/mirror/status", &st); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (is the daemon running?)\n", err)
+		os.Exit(1)
+	}
+	if len(args) > 0 && args[0] == "--json" {
+		json.NewEncoder(os.Stdout).Encode(st)
+		return
+	}
+	if !st.Enabled {
+		fmt.Println("Mirror is not enabled (set mirror.enabled in config).")
+		return
+	}
+	peer := st.Peer
+	if peer == "" {
+		peer = "(none; answers the peer's syncs)"
+	}
+	interval := st.Interval
+	if interval == "0s" {
+		interval = "on demand"
+	}
+	fmt.Printf("Peer:       %s\n", peer)
+	fmt.Printf("Direction:  %s\n", st.Direction)
+	fmt.Printf("Interval:   %s\n", interval)
+	fmt.Printf("Kinds:      %s\n", strings.Join(st.Kinds, ", "))
+	fmt.Printf("Conflicts:  %s\n", st.Conflict)
+	if st.Last == nil {
+		fmt.Println("Last sync:  never")
+		return
+	}
+	fmt.Printf("Last sync:  %s\n", st.Last.At)
+	if st.Last.Error != "" {
+		fmt.Printf("  Error: %s\n", st.Last.Error)
+		return
+	}
+	printMirrorResult(st.Last)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"tetora/internal/db"
+)
+
+// monitorCLI mirrors monitor.Status for decoding API responses.
+type monitorCLI struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	URL         string `json:"url"`
+	Enabled     bool   `json:"enabled"`
+	Interval    string `json:"interval"`
+	LastChecked string `json:"lastChecked"`
+	LastChanged string `json:"lastChanged"`
+	LastError   string `json:"lastError"`
+	Failures    int    `json:"failures"`
+}
+
+// monitorChangeCLI mirrors monitor.Change.
+type monitorChangeCLI struct {
+	ID        int     `json:"id"`
+	Diff      string  `json:"diff"`
+	Summary   string  `json:"summary"`
+	CostUSD   float64 `json:"costUsd"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+func CmdMonitor(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		cmdMonitorList()
+	case "check":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora monitor check <name>")
+			os.Exit(1)
+		}
+		cmdMonitorCheck(args[1])
+	case "history":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora monitor history <name> [--limit N]")
+			os.Exit(1)
+		}
+		limit := 10
+		if len(args) > 3 && args[2] == "--limit" {
+			if n, err := strconv.Atoi(args[3]); err == nil {
+				limit = n
+			}
+		}
+		cmdMonitorHistory(args[1], limit)
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tetora monitor <list|check|history>")
+		os.Exit(1)
+	}
+}
+
+func cmdMonitorList() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "GET", "/api/monitors", nil)
+
+	var list []monitorCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No monitors configured.")
+		return
+	}
+
+	fmt.Printf("%-20s %-5s %-8s %-20s %-20s %s\n", "Name", "Type", "Every", "Last Checked", "Last Changed", "Status")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, m := range list {
+		status := "ok"
+		switch {
+		case !m.Enabled:
+			status = "disabled"
+		case m.LastError != "":
+			status = fmt.Sprintf("failing (%d): %s", m.Failures, db.Truncate(m.LastError, 30))
+		case m.LastChecked == "":
+			status = "pending"
+		}
+		fmt.Printf("%-20s %-5s %-8s %-20s %-20s %s\n",
+			db.Truncate(m.Name, 20), m.Type, m.Interval, m.LastChecked, m.LastChanged, status)
+	}
+}
+
+func cmdMonitorCheck(name string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "POST", "/api/monitors/"+url.PathEscape(name)+"/check", map[string]string{})
+
+	var res struct {
+		Baseline bool              `json:"baseline"`
+		Changed  bool              `json:"changed"`
+		Value    string            `json:"value"`
+		Change   *monitorChangeCLI `json:"change"`
+	}
+	json.Unmarshal(body, &res)
+	switch {
+	case res.Baseline:
+		fmt.Printf("Baseline recorded for %s: %s\n", name, db.Truncate(res.Value, 200))
+	case res.Changed && res.Change != nil:
+		fmt.Printf("%s changed:\n%s\n\n%s\n", name, res.Change.Summary, res.Change.Diff)
+	default:
+		fmt.Printf("%s unchanged.\n", name)
+	}
+}
+
+func cmdMonitorHistory(name string, limit int) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	path := fmt.Sprintf("/api/monitors/%s/changes?limit=%d", url.PathEscape(name), limit)
+	body := handoverRequest(cfg, "GET", path, nil)
+
+	var list []monitorChangeCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Printf("No changes recorded for %s.\n", name)
+		return
+	}
+	for _, c := range list {
+		fmt.Printf("[%s] $%.4f\n%s\n\n", c.CreatedAt, c.CostUSD, c.Summary)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strings"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/config"
 )
 
@@ -264,7 +265,7 @@ func writeOnboardingAgent(scanner *bufio.Scanner, configPath, configDir, soulCon
 
 	// Detect provider from main config.
 	provider := "claude-code"
-	if rawCfgData, err := os.ReadFile(configPath); err == nil {
+	if rawCfgData, err := cfgfile.ReadJSON(configPath); err == nil {
 		var rawCfg map[string]any
 		if json.Unmarshal(rawCfgData, &rawCfg) == nil {
 			if _, hasPath := rawCfg["claudePath"]; !hasPath {
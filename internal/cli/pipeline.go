@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"tetora/internal/db"
+)
+
+// pipelineCLI mirrors the fields of pipeline.Pipeline the CLI shows.
+type pipelineCLI struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Enabled  *bool  `json:"enabled"`
+	Fetch    struct {
+		Type string `json:"type"`
+	} `json:"fetch"`
+	Dispatch struct {
+		Type string `json:"type"`
+	} `json:"dispatch"`
+	NextRun string `json:"nextRun"`
+}
+
+// pipelineRunCLI mirrors pipeline.Run.
+type pipelineRunCLI struct {
+	Trigger   string  `json:"trigger"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error"`
+	Text      string  `json:"text"`
+	Prompt    string  `json:"prompt"`
+	Output    string  `json:"output"`
+	CostUSD   float64 `json:"costUsd"`
+	StartedAt string  `json:"startedAt"`
+}
+
+func CmdPipeline(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		cmdPipelineList()
+	case "run":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora pipeline run <name> [--dry-run]")
+			os.Exit(1)
+		}
+		cmdPipelineRun(args[1], len(args) > 2 && args[2] == "--dry-run")
+	case "runs", "history":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora pipeline runs <name> [--limit N]")
+			os.Exit(1)
+		}
+		limit := 10
+		if len(args) > 3 && args[2] == "--limit" {
+			if n, err := strconv.Atoi(args[3]); err == nil {
+				limit = n
+			}
+		}
+		cmdPipelineRuns(args[1], limit)
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tetora pipeline <list|run|runs>")
+		os.Exit(1)
+	}
+}
+
+func cmdPipelineList() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	body := handoverRequest(cfg, "GET", "/api/pipelines", nil)
+
+	var list []pipelineCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Println("No pipelines defined.")
+		return
+	}
+
+	fmt.Printf("%-20s %-16s %-8s %-8s %s\n", "Name", "Schedule", "Fetch", "Dispatch", "Next Run")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, p := range list {
+		schedule, next := p.Schedule, p.NextRun
+		if schedule == "" {
+			schedule = "manual"
+		}
+		if p.Enabled != nil && !*p.Enabled {
+			next = "disabled"
+		}
+		fetch, dispatch := p.Fetch.Type, p.Dispatch.Type
+		if fetch == "" {
+			fetch = "http"
+		}
+		if dispatch == "" {
+			dispatch = "agent"
+		}
+		fmt.Printf("%-20s %-16s %-8s %-8s %s\n", db.Truncate(p.Name, 20), schedule, fetch, dispatch, next)
+	}
+}
+
+func cmdPipelineRun(name string, dryRun bool) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	path := "/api/pipelines/" + url.PathEscape(name) + "/run"
+	if dryRun {
+		path += "?dryRun=1"
+	}
+	body := handoverRequest(cfg, "POST", path, map[string]string{})
+
+	var run pipelineRunCLI
+	if err := json.Unmarshal(body, &run); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %s\n", name, run.Status)
+	if run.Error != "" {
+		fmt.Printf("error: %s\n", run.Error)
+	}
+	if run.Text != "" {
+		fmt.Printf("\n--- text ---\n%s\n", run.Text)
+	}
+	if run.Prompt != "" {
+		fmt.Printf("\n--- prompt ---\n%s\n", run.Prompt)
+	}
+	if run.Output != "" {
+		fmt.Printf("\n--- output ($%.4f) ---\n%s\n", run.CostUSD, run.Output)
+	}
+}
+
+func cmdPipelineRuns(name string, limit int) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	path := fmt.Sprintf("/api/pipelines/%s/runs?limit=%d", url.PathEscape(name), limit)
+	body := handoverRequest(cfg, "GET", path, nil)
+
+	var list []pipelineRunCLI
+	if err := json.Unmarshal(body, &list); err != nil {
+		fmt.Fprintf(os.Stderr, "error: decode response: %v\n", err)
+		os.Exit(1)
+	}
+	if len(list) == 0 {
+		fmt.Printf("No runs recorded for %s.\n", name)
+		return
+	}
+	for _, r := range list {
+		detail := r.Output
+		if r.Error != "" {
+			detail = r.Error
+		}
+		fmt.Printf("[%s] %-9s %-8s $%.4f %s\n", r.StartedAt, r.Status, r.Trigger, r.CostUSD, db.Truncate(strings.ReplaceAll(detail, "\n", " "), 80))
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"tetora/internal/cfgfile"
 )
 
 // CmdProject handles the `tetora project` subcommand.
@@ -111,7 +113,7 @@ func runProjectAdd(args []string) {
 
 	// 4. Check TaskBoard status
 	configPath := FindConfigPath()
-	if configData, err := os.ReadFile(configPath); err == nil {
+	if configData, err := cfgfile.ReadJSON(configPath); err == nil {
 		if !isTaskBoardEnabled(configData) {
 			fmt.Println("\n  \033[33mWarning: Task Board might not be enabled. You can enable it with 'tetora init'.\033[0m")
 		}
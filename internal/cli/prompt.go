@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +12,8 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+
+	"tetora/internal/prompttmpl"
 )
 
 // PromptInfo represents a prompt template file.
@@ -21,7 +25,7 @@ type PromptInfo struct {
 
 func CmdPrompt(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: tetora prompt <list|show|add|edit|remove> [name]")
+		fmt.Println("Usage: tetora prompt <list|show|render|add|edit|remove> [name]")
 		return
 	}
 	switch args[0] {
@@ -33,6 +37,12 @@ func CmdPrompt(args []string) {
 			return
 		}
 		promptShow(args[1])
+	case "render":
+		if len(args) < 2 {
+			fmt.Println("Usage: tetora prompt render <name> [--var key=value ...] [--json]")
+			return
+		}
+		promptRender(args[1], args[2:])
 	case "add":
 		if len(args) < 2 {
 			fmt.Println("Usage: tetora prompt add <name>")
@@ -89,6 +99,68 @@ func promptShow(name string) {
 	fmt.Print(content)
 }
 
+// varFlags collects repeated --var key=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string { return "" }
+
+func (v varFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	v[key] = value
+	return nil
+}
+
+// promptRender renders a prompt template without running it. Validation
+// errors are listed one per line and exit non-zero.
+func promptRender(name string, args []string) {
+	fs := flag.NewFlagSet("prompt render", flag.ExitOnError)
+	vars := varFlags{}
+	fs.Var(vars, "var", "template variable as key=value (repeatable)")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Parse(args) //nolint:errcheck
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	load := func(n string) (string, error) { return readPrompt(cfg, n) }
+	res, err := prompttmpl.Render(strings.TrimSuffix(name, ".md"), load, vars)
+	if err != nil {
+		if errs, ok := err.(prompttmpl.Errors); ok {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s\n", e)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	if *asJSON {
+		out, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if res.Model != "" || res.Role != "" {
+		fmt.Fprintf(os.Stderr, "# model: %s  role: %s\n", orDash(res.Model), orDash(res.Role))
+	}
+	fmt.Print(res.Text)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// warnPromptErrors prints the template problems of a saved prompt.
+func warnPromptErrors(cfg *CLIConfig, name, content string) {
+	load := func(n string) (string, error) { return readPrompt(cfg, n) }
+	for _, e := range prompttmpl.Validate(name, content, load) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", e)
+	}
+}
+
 func promptAdd(name string) {
 	cfg := LoadCLIConfig(FindConfigPath())
 
@@ -132,6 +204,7 @@ func promptAdd(name string) {
 		os.Exit(1)
 	}
 	fmt.Printf("Prompt %q saved.\n", name)
+	warnPromptErrors(cfg, name, content)
 }
 
 func promptEdit(name string) {
@@ -159,6 +232,7 @@ func promptEdit(name string) {
 		os.Exit(1)
 	}
 	fmt.Printf("Prompt %q updated.\n", name)
+	warnPromptErrors(cfg, name, content)
 }
 
 func promptRemove(name string) {
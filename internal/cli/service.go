@@ -9,6 +9,8 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"tetora/internal/config"
 )
 
 func CmdService(args []string) {
@@ -29,6 +31,23 @@ func CmdService(args []string) {
 	}
 }
 
+// serviceProfileArg returns the --profile argument that keeps the installed
+// daemon on the profile active at install time, or "" without one.
+func serviceProfileArg() string {
+	if p := config.ActiveProfile(); p != "" {
+		return " --profile " + p
+	}
+	return ""
+}
+
+// plistProfileArgs is serviceProfileArg as launchd ProgramArguments entries.
+func plistProfileArgs() string {
+	if p := config.ActiveProfile(); p != "" {
+		return "\n        <string>--profile</string>\n        <string>" + p + "</string>"
+	}
+	return ""
+}
+
 // --- macOS launchd ---
 
 // PlistLabel is the macOS LaunchAgent identifier for the Tetora daemon.
@@ -67,7 +86,7 @@ func launchdInstall() {
     <key>ProgramArguments</key>
     <array>
         <string>%s</string>
-        <string>serve</string>
+        <string>serve</string>%s
     </array>
     <key>RunAtLoad</key>
     <true/>
@@ -89,7 +108,7 @@ func launchdInstall() {
         <string>%s</string>
     </dict>
 </dict>
-</plist>`, PlistLabel, exe,
+</plist>`, PlistLabel, exe, plistProfileArgs(),
 		filepath.Join(logDir, "tetora.log"),
 		filepath.Join(logDir, "tetora.err"),
 		tetoraDir,
@@ -275,14 +294,14 @@ After=network.target
 
 [Service]
 Type=simple
-ExecStart=%s serve
+ExecStart=%s serve%s
 WorkingDirectory=%s
 Restart=on-failure
 RestartSec=5
 
 [Install]
 WantedBy=default.target
-`, exe, tetoraDir)
+`, exe, serviceProfileArg(), tetoraDir)
 
 	if err := os.WriteFile(unitPath, []byte(unit), 0o644); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing unit file: %v\n", err)
@@ -2,7 +2,10 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -139,9 +142,28 @@ func sessionList(args []string) {
 		}
 	}
 
-	sessions, total, err := querySessions(cfg.HistoryDB, SessionQuery{
-		Agent: role, Status: status, Limit: limit,
-	})
+	var sessions []Session
+	var total int
+	var err error
+	if api := cfg.Daemon(); api != nil {
+		v := url.Values{"limit": {strconv.Itoa(limit)}}
+		if role != "" {
+			v.Set("role", role)
+		}
+		if status != "" {
+			v.Set("status", status)
+		}
+		var res struct {
+			Sessions []Session `json:"sessions"`
+			Total    int       `json:"total"`
+		}
+		err = api.GetJSON("/sessions?"+v.Encode(), &res)
+		sessions, total = res.Sessions, res.Total
+	} else {
+		sessions, total, err = querySessions(cfg.HistoryDB, SessionQuery{
+			Agent: role, Status: status, Limit: limit,
+		})
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -284,7 +306,24 @@ func sessionShow(id string) {
 		os.Exit(1)
 	}
 
-	detail, err := querySessionDetail(cfg.HistoryDB, id)
+	var detail *SessionDetail
+	var err error
+	if api := cfg.Daemon(); api != nil {
+		detail = new(SessionDetail)
+		err = api.GetJSON("/sessions/"+url.PathEscape(id), detail)
+		var apiErr *APIError
+		if IsNotFound(err) {
+			detail, err = nil, nil
+		} else if errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+			var res struct {
+				Matches []Session `json:"matches"`
+			}
+			json.Unmarshal(apiErr.Body, &res)
+			err = &ErrAmbiguousSession{Prefix: id, Matches: res.Matches}
+		}
+	} else {
+		detail, err = querySessionDetail(cfg.HistoryDB, id)
+	}
 	if err != nil {
 		if ambig, ok := err.(*ErrAmbiguousSession); ok {
 			fmt.Fprintf(os.Stderr, "Ambiguous session ID, multiple matches:\n")
@@ -14,6 +14,7 @@ import (
 // toSkillAppConfig builds a skill.AppConfig from CLIConfig.
 func toSkillAppConfig(cfg *CLIConfig) *skill.AppConfig {
 	return &skill.AppConfig{
+		SkillStore:   cfg.SkillStore,
 		WorkspaceDir: cfg.WorkspaceDir,
 		HistoryDB:    cfg.HistoryDB,
 		BaseDir:      cfg.BaseDir,
@@ -22,7 +23,7 @@ func toSkillAppConfig(cfg *CLIConfig) *skill.AppConfig {
 
 func CmdSkill(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: tetora skill <list|run|test|store|approve|reject|install|search|scan|init|log|stats|diagnostics> [name]")
+		fmt.Println("Usage: tetora skill <list|run|test|store|approve|reject|install|search|upgrade|pin|unpin|installed|pack|scan|init|log|stats|diagnostics> [name]")
 		fmt.Println()
 		fmt.Println("Commands:")
 		fmt.Println("  list                                   List all skills (config + file-based)")
@@ -31,8 +32,12 @@ func CmdSkill(args []string) {
 		fmt.Println("  store                                  List file-based skills (store)")
 		fmt.Println("  approve <name>                         Approve a pending skill")
 		fmt.Println("  reject  <name>                         Reject (delete) a pending skill")
-		fmt.Println("  install <url>                          Install a skill from URL")
-		fmt.Println("  search  <query>                        Search skill registry")
+		fmt.Println("  install <name[@ver]|url|repo.git#ref>  Install a skill package [--sha256 HEX] [--approve]")
+		fmt.Println("  search  [query]                        Search skill indexes (or skill-registry.json)")
+		fmt.Println("  upgrade [name] [version]               Upgrade installed packages (pinned ones need a version)")
+		fmt.Println("  pin|unpin <name>                       Hold an installed package at its version")
+		fmt.Println("  installed                              List skills installed from packages")
+		fmt.Println("  pack    <dir> [--out DIR]              Build a package archive and print its index entry")
 		fmt.Println("  scan    <name>                         Security scan a skill")
 		fmt.Println("  init   [name]                          AI interview to generate SKILL.md")
 		fmt.Println("  log    <name> [flags]                  Record a skill execution event")
@@ -71,16 +76,28 @@ func CmdSkill(args []string) {
 		skillRejectCmd(args[1])
 	case "install":
 		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Usage: tetora skill install <url>")
+			fmt.Fprintln(os.Stderr, "Usage: tetora skill install <name[@version]|archive-url|repo.git#ref> [--sha256 HEX] [--approve]")
 			os.Exit(1)
 		}
-		skillInstallCmd(args[1])
+		skillInstallCmd(args[1], args[2:])
 	case "search":
+		skillSearchCmd(strings.Join(args[1:], " "))
+	case "upgrade":
+		skillUpgradeCmd(args[1:])
+	case "pin", "unpin":
 		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Usage: tetora skill search <query>")
+			fmt.Fprintf(os.Stderr, "Usage: tetora skill %s <name>\n", args[0])
 			os.Exit(1)
 		}
-		skillSearchCmd(strings.Join(args[1:], " "))
+		skillPinCmd(args[1], args[0] == "pin")
+	case "installed":
+		skillInstalledCmd()
+	case "pack":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tetora skill pack <dir> [--out DIR]")
+			os.Exit(1)
+		}
+		skillPackCmd(args[1], args[2:])
 	case "scan":
 		if len(args) < 2 {
 			fmt.Fprintln(os.Stderr, "Usage: tetora skill scan <name>")
@@ -123,19 +140,106 @@ func CmdSkill(args []string) {
 
 // --- P27.1: CLI wrappers ---
 
-func skillInstallCmd(url string) {
+func skillInstallCmd(source string, flags []string) {
 	cfg := LoadCLIConfig(FindConfigPath())
-	input, _ := json.Marshal(map[string]any{"url": url, "auto_approve": false})
-	result, err := skill.ToolSkillInstall(context.Background(), toSkillAppConfig(cfg), input)
+	// Single-file JSON packages keep going through the skill_install tool.
+	if strings.HasSuffix(source, ".json") {
+		input, _ := json.Marshal(map[string]any{"url": source, "auto_approve": false})
+		result, err := skill.ToolSkillInstall(context.Background(), toSkillAppConfig(cfg), input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(result)
+		return
+	}
+
+	var sum string
+	approve := false
+	for i := 0; i < len(flags); i++ {
+		switch {
+		case flags[i] == "--approve":
+			approve = true
+		case flags[i] == "--sha256" && i+1 < len(flags):
+			sum = flags[i+1]
+			i++
+		case strings.HasPrefix(flags[i], "--sha256="):
+			sum = strings.TrimPrefix(flags[i], "--sha256=")
+		}
+	}
+	version := ""
+	if !skill.IsPackageSource(source) {
+		source, version, _ = strings.Cut(source, "@")
+	}
+	ctx := context.Background()
+	rel, err := skill.Resolve(ctx, cfg.SkillStore.Indexes, source, version, sum)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println(result)
+	if rel.SHA256 == "" {
+		fmt.Fprintln(os.Stderr, "Warning: no --sha256 given; the package is not checksum-verified.")
+	}
+	rec, err := skill.InstallPackage(ctx, toSkillAppConfig(cfg), rel, skill.InstallOptions{
+		Source:  source,
+		Pinned:  version != "",
+		Approve: approve,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	printInstalled(cfg, rec)
+}
+
+// printInstalled summarizes a package install.
+func printInstalled(cfg *CLIConfig, rec *skill.Installed) {
+	fmt.Printf("Installed %s %s (risk: %s, sha256 %s)\n", rec.Release.Name, rec.Release.Version, rec.Risk, rec.Release.SHA256[:12])
+	switch rec.Test {
+	case "passed":
+		fmt.Println("  test passed")
+	case "skipped":
+		fmt.Println("  test skipped until approved")
+	}
+	if rec.Pinned {
+		fmt.Println("  pinned; \"tetora skill upgrade\" leaves it alone")
+	}
+	for _, m := range skill.LoadAllFileSkillMetas(toSkillAppConfig(cfg)) {
+		if m.Name == rec.Release.Name && !m.Approved {
+			fmt.Printf("  pending approval: tetora skill approve %s\n", m.Name)
+		}
+	}
 }
 
 func skillSearchCmd(query string) {
 	cfg := LoadCLIConfig(FindConfigPath())
+	if len(cfg.SkillStore.Indexes) > 0 {
+		results := skill.Search(context.Background(), cfg.SkillStore.Indexes, query)
+		if len(results) == 0 {
+			fmt.Println("No matching skills.")
+			return
+		}
+		installed := make(map[string]string)
+		for _, rec := range skill.ListInstalled(toSkillAppConfig(cfg)) {
+			installed[rec.Release.Name] = rec.Release.Version
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tINSTALLED\tDESCRIPTION")
+		for _, e := range results {
+			desc := e.Description
+			if len(desc) > 50 {
+				desc = desc[:50] + "..."
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, e.Version, orDash(installed[e.Name]), desc)
+		}
+		w.Flush()
+		return
+	}
+	if query == "" {
+		fmt.Fprintln(os.Stderr, "Usage: tetora skill search <query>")
+		os.Exit(1)
+	}
+
 	input, _ := json.Marshal(map[string]any{"query": query})
 	result, err := skill.ToolSkillSearch(context.Background(), toSkillAppConfig(cfg), input)
 	if err != nil {
@@ -163,6 +267,99 @@ func skillSearchCmd(query string) {
 	}
 }
 
+// skillUpgradeCmd upgrades one packaged skill (optionally to a version) or
+// every unpinned one.
+func skillUpgradeCmd(args []string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	name, version := "", ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if len(args) > 1 {
+		version = args[1]
+	}
+	results := skill.Upgrade(context.Background(), toSkillAppConfig(cfg), name, version)
+	upgraded, failed := 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case "upgraded":
+			fmt.Printf("%-20s %s -> %s\n", r.Name, r.From, r.To)
+			upgraded++
+		case "current":
+			fmt.Printf("%-20s up to date (%s)\n", r.Name, r.From)
+		case "pinned":
+			fmt.Printf("%-20s pinned at %s; run \"tetora skill upgrade %s <version>\" to move it\n", r.Name, r.From, r.Name)
+		case "skipped":
+			fmt.Printf("%-20s skipped: %s\n", r.Name, r.Error)
+		default:
+			fmt.Printf("%-20s error: %s\n", r.Name, r.Error)
+			failed++
+		}
+	}
+	if upgraded == 0 {
+		fmt.Println("Nothing upgraded.")
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func skillPinCmd(name string, pinned bool) {
+	cfg := LoadCLIConfig(FindConfigPath())
+	if err := skill.SetPinned(toSkillAppConfig(cfg), name, pinned); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if pinned {
+		fmt.Printf("Skill %q pinned.\n", name)
+	} else {
+		fmt.Printf("Skill %q unpinned.\n", name)
+	}
+}
+
+func skillInstalledCmd() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	recs := skill.ListInstalled(toSkillAppConfig(cfg))
+	if len(recs) == 0 {
+		fmt.Println("No skills installed from packages.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tRISK\tTEST\tSOURCE\tINSTALLED AT")
+	for _, rec := range recs {
+		version := rec.Release.Version
+		if rec.Pinned {
+			version += " (pinned)"
+		}
+		installedAt := rec.InstalledAt
+		if len(installedAt) > 10 {
+			installedAt = installedAt[:10]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", rec.Release.Name, version, rec.Risk, rec.Test, rec.Source, installedAt)
+	}
+	w.Flush()
+}
+
+func skillPackCmd(dir string, flags []string) {
+	outDir := "."
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == "--out" && i+1 < len(flags) {
+			outDir = flags[i+1]
+			i++
+		}
+	}
+	entry, archive, digest, err := skill.Pack(dir, outDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", archive)
+	fmt.Printf("Tree digest (sha256 for a git entry): %s\n\n", digest)
+	fmt.Println("Index entry:")
+	out, _ := json.MarshalIndent(entry, "", "  ")
+	fmt.Println(string(out))
+}
+
 func skillScanCmd(name string) {
 	cfg := LoadCLIConfig(FindConfigPath())
 	input, _ := json.Marshal(map[string]any{"name": name})
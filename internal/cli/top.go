@@ -0,0 +1,796 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// topPollInterval is how often "tetora top" refreshes without SSE events.
+const topPollInterval = 5 * time.Second
+
+// topTask mirrors the fields of GET /tasks/running the console shows.
+type topTask struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Agent   string `json:"agent"`
+	Model   string `json:"model"`
+	Elapsed string `json:"elapsed"`
+}
+
+// topJob mirrors the fields of cron.JobInfo the console shows.
+type topJob struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Enabled  bool      `json:"enabled"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"nextRun"`
+	Errors   int       `json:"errors"`
+}
+
+// topRun mirrors the fields of history.JobRun the console shows.
+type topRun struct {
+	Name      string  `json:"name"`
+	Source    string  `json:"source"`
+	Status    string  `json:"status"`
+	CostUSD   float64 `json:"costUsd"`
+	StartedAt string  `json:"startedAt"`
+}
+
+type topChannel struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Status  string `json:"status"`
+}
+
+// topSnapshot is one poll of the daemon.
+type topSnapshot struct {
+	Running    []topTask
+	Queued     int
+	History    []topRun
+	CostToday  float64
+	DailyLimit float64
+	Jobs       []topJob
+	Channels   []topChannel
+	Err        error
+	At         time.Time
+}
+
+// fetchTopSnapshot polls the endpoints behind the console. Sections whose
+// endpoint fails are left empty; Err is the first failure.
+func fetchTopSnapshot(api *APIClient) topSnapshot {
+	s := topSnapshot{At: time.Now()}
+	keep := func(err error) {
+		if err != nil && s.Err == nil {
+			s.Err = err
+		}
+	}
+
+	keep(api.GetJSON("/tasks/running", &s.Running))
+
+	var queue struct {
+		Pending int `json:"pending"`
+	}
+	keep(api.GetJSON("/queue", &queue))
+	s.Queued = queue.Pending
+
+	var hist struct {
+		Runs []topRun `json:"runs"`
+	}
+	keep(api.GetJSON("/history?limit=8", &hist))
+	s.History = hist.Runs
+
+	var cost map[string]any
+	keep(api.GetJSON("/stats/cost", &cost))
+	s.CostToday = JSONFloatSafe(cost["today"])
+	s.DailyLimit = JSONFloatSafe(cost["dailyLimit"])
+
+	// Cron may be disabled (503); that is not an error worth showing.
+	if err := api.GetJSON("/cron", &s.Jobs); err != nil && !isUnavailable(err) {
+		keep(err)
+	}
+	jobs := s.Jobs[:0]
+	for _, j := range s.Jobs {
+		if j.Enabled {
+			jobs = append(jobs, j)
+		}
+	}
+	s.Jobs = jobs
+	sort.SliceStable(s.Jobs, func(i, k int) bool {
+		a, b := s.Jobs[i], s.Jobs[k]
+		if a.Running != b.Running {
+			return a.Running
+		}
+		if a.NextRun.IsZero() != b.NextRun.IsZero() {
+			return !a.NextRun.IsZero()
+		}
+		return a.NextRun.Before(b.NextRun)
+	})
+
+	var integ struct {
+		Channels []topChannel `json:"channels"`
+	}
+	keep(api.GetJSON("/api/integrations/status", &integ))
+	for _, c := range integ.Channels {
+		if c.Enabled {
+			s.Channels = append(s.Channels, c)
+		}
+	}
+	return s
+}
+
+func isUnavailable(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.Status == http.StatusServiceUnavailable
+}
+
+// topJobRows is how many cron jobs the console lists at once.
+const topJobRows = 6
+
+// Console panes that take the cursor.
+const (
+	topPaneTasks = iota
+	topPaneJobs
+)
+
+// topModel is the console state, redrawn after every change.
+type topModel struct {
+	snap    topSnapshot
+	live    bool // dashboard SSE stream connected
+	pane    int
+	taskSel int
+	jobSel  int
+	flash   string
+	tail    *topTail
+	width   int
+	height  int
+}
+
+// topTail is the streamed output of one task.
+type topTail struct {
+	id     string
+	name   string
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	text strings.Builder
+	done string // final status once the stream ends
+}
+
+func (t *topTail) append(chunk string, replace bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if replace {
+		t.text.Reset()
+	}
+	t.text.WriteString(chunk)
+}
+
+func (t *topTail) finish(status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done = status
+}
+
+// lines returns the last n lines of output.
+func (t *topTail) lines(n int) ([]string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := strings.Split(strings.TrimRight(t.text.String(), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, t.done
+}
+
+// clamp keeps the selections inside the current lists.
+func (m *topModel) clamp() {
+	m.taskSel = clampIndex(m.taskSel, len(m.snap.Running))
+	m.jobSel = clampIndex(m.jobSel, len(m.snap.Jobs))
+}
+
+func clampIndex(i, n int) int {
+	if i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i
+}
+
+func (m *topModel) selectedTask() *topTask {
+	if m.taskSel < len(m.snap.Running) {
+		return &m.snap.Running[m.taskSel]
+	}
+	return nil
+}
+
+func (m *topModel) selectedJob() *topJob {
+	if m.jobSel < len(m.snap.Jobs) {
+		return &m.snap.Jobs[m.jobSel]
+	}
+	return nil
+}
+
+const (
+	topReset   = "\033[0m"
+	topBold    = "\033[1m"
+	topDim     = "\033[2m"
+	topRed     = "\033[31m"
+	topGreen   = "\033[32m"
+	topYellow  = "\033[33m"
+	topReverse = "\033[7m"
+)
+
+// render returns the screen as lines, each at most m.width columns of text.
+func (m *topModel) render() []string {
+	var out []string
+	add := func(format string, args ...any) {
+		out = append(out, fmt.Sprintf(format, args...))
+	}
+	row := func(selected bool, text string) {
+		text = topFit(text, m.width)
+		if selected {
+			text = topReverse + text + topReset
+		}
+		out = append(out, text)
+	}
+	header := func(title string, pane int) {
+		if pane >= 0 && m.pane == pane {
+			add("%s%s▸ %s%s", topBold, topYellow, title, topReset)
+		} else {
+			add("%s  %s%s", topBold, title, topReset)
+		}
+	}
+	s := m.snap
+
+	// Summary line.
+	live := topDim + "polling" + topReset
+	if m.live {
+		live = topGreen + "live" + topReset
+	}
+	cost := fmt.Sprintf("$%.2f today", s.CostToday)
+	if s.DailyLimit > 0 {
+		color := ""
+		if s.CostToday >= s.DailyLimit {
+			color = topRed
+		} else if s.CostToday >= s.DailyLimit*0.8 {
+			color = topYellow
+		}
+		cost = fmt.Sprintf("%s$%.2f / $%.2f today%s", color, s.CostToday, s.DailyLimit, topReset)
+	}
+	add("%stetora top%s  %s  running %d  queued %d  %s  %s",
+		topBold, topReset, s.At.Format("15:04:05"), len(s.Running), s.Queued, cost, live)
+	if s.Err != nil {
+		add("%s%s%s", topRed, topFit("error: "+s.Err.Error(), m.width), topReset)
+	}
+	add("")
+
+	header("Running tasks", topPaneTasks)
+	if len(s.Running) == 0 {
+		add("%s  (idle)%s", topDim, topReset)
+	}
+	for i, t := range s.Running {
+		who := t.Agent
+		if who == "" {
+			who = t.Source
+		}
+		row(m.pane == topPaneTasks && i == m.taskSel,
+			fmt.Sprintf("  %-10s %-28s %-12s %-10s %s", shortID(t.ID), topFit(t.Name, 28), topFit(who, 12), topFit(t.Model, 10), t.Elapsed))
+	}
+	add("")
+
+	header("Cron", topPaneJobs)
+	if len(s.Jobs) == 0 {
+		add("%s  (no enabled jobs)%s", topDim, topReset)
+	}
+	// Show topJobRows jobs, scrolled to keep the selection visible.
+	first := max(m.jobSel-topJobRows+1, 0)
+	for i := first; i < len(s.Jobs); i++ {
+		j := s.Jobs[i]
+		if i >= first+topJobRows {
+			add("%s  … %d more%s", topDim, len(s.Jobs)-i, topReset)
+			break
+		}
+		next := "-"
+		switch {
+		case j.Running:
+			next = "running"
+		case !j.NextRun.IsZero():
+			next = "in " + FormatDuration(time.Until(j.NextRun))
+		}
+		errs := ""
+		if j.Errors > 0 {
+			errs = fmt.Sprintf("  ✗%d", j.Errors)
+		}
+		row(m.pane == topPaneJobs && i == m.jobSel,
+			fmt.Sprintf("  %-24s %-16s %s%s", topFit(j.Name, 24), topFit(j.Schedule, 16), next, errs))
+	}
+	add("")
+
+	header("Recent", -1)
+	for _, r := range s.History {
+		status := r.Status
+		switch status {
+		case "success":
+			status = topGreen + "ok     " + topReset
+		default:
+			status = topRed + fmt.Sprintf("%-7s", topFit(status, 7)) + topReset
+		}
+		add("  %s %s", status, topFit(fmt.Sprintf("%-28s %-10s $%.4f  %s",
+			topFit(r.Name, 28), topFit(r.Source, 10), r.CostUSD, FormatTimeAgo(r.StartedAt)), m.width-10))
+	}
+	if len(s.History) == 0 {
+		add("%s  (none)%s", topDim, topReset)
+	}
+
+	if len(s.Channels) > 0 {
+		var parts []string
+		for _, c := range s.Channels {
+			mark := topGreen + "●" + topReset
+			if c.Status != "connected" {
+				mark = topRed + "●" + topReset
+			}
+			parts = append(parts, mark+" "+c.Name)
+		}
+		add("")
+		add("  Channels: %s", strings.Join(parts, "  "))
+	}
+
+	if m.tail != nil {
+		add("")
+		lines, done := m.tail.lines(max(m.height-len(out)-4, 3))
+		title := fmt.Sprintf("Output of %s (%s)", m.tail.name, shortID(m.tail.id))
+		if done != "" {
+			title += " — " + done
+		}
+		header(title, -1)
+		if len(lines) == 0 && done == "" {
+			add("%s  waiting for output…%s", topDim, topReset)
+		}
+		for _, l := range lines {
+			add("  %s", topFit(l, m.width-2))
+		}
+	}
+
+	// Footer, pinned to the last line of the screen; none when printing once.
+	if m.height == 0 {
+		return out
+	}
+	footer := "↑↓ select  tab switch pane  c cancel task  t tail output  r run cron job  space refresh  q quit"
+	if m.flash != "" {
+		footer = m.flash
+	}
+	for len(out) < m.height-1 {
+		out = append(out, "")
+	}
+	if len(out) > m.height-1 && m.height > 1 {
+		out = out[:m.height-1]
+	}
+	return append(out, topDim+topFit(footer, m.width)+topReset)
+}
+
+// topFit truncates s to n display columns (runes).
+func topFit(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	if n == 1 {
+		return "…"
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// shortID abbreviates a task or session ID for display.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// Keys the console understands.
+const (
+	topKeyUp      = "up"
+	topKeyDown    = "down"
+	topKeyTab     = "tab"
+	topKeyQuit    = "quit"
+	topKeyCancel  = "cancel"
+	topKeyTail    = "tail"
+	topKeyRun     = "run"
+	topKeyRefresh = "refresh"
+	topKeyEscape  = "escape"
+)
+
+// topKeys decodes one read from a raw-mode terminal into keys.
+func topKeys(b []byte) []string {
+	var keys []string
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case 0x1b:
+			if i+2 < len(b) && b[i+1] == '[' {
+				switch b[i+2] {
+				case 'A':
+					keys = append(keys, topKeyUp)
+				case 'B':
+					keys = append(keys, topKeyDown)
+				}
+				i += 2
+				continue
+			}
+			keys = append(keys, topKeyEscape)
+		case 'k':
+			keys = append(keys, topKeyUp)
+		case 'j':
+			keys = append(keys, topKeyDown)
+		case '\t':
+			keys = append(keys, topKeyTab)
+		case 'q', 3: // 3 = Ctrl-C, which raw mode delivers as a byte
+			keys = append(keys, topKeyQuit)
+		case 'c':
+			keys = append(keys, topKeyCancel)
+		case 't':
+			keys = append(keys, topKeyTail)
+		case 'r':
+			keys = append(keys, topKeyRun)
+		case ' ':
+			keys = append(keys, topKeyRefresh)
+		}
+	}
+	return keys
+}
+
+// CmdTop runs the interactive console.
+func CmdTop(args []string) {
+	once := false
+	interval := topPollInterval
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--once":
+			once = true
+		case "--interval":
+			if i+1 < len(args) {
+				if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
+					interval = time.Duration(n) * time.Second
+				}
+				i++
+			}
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: tetora top [--once] [--interval <seconds>]")
+			os.Exit(1)
+		}
+	}
+
+	cfg := LoadCLIConfig(FindConfigPath())
+	api := cfg.Daemon()
+	if api == nil {
+		fmt.Fprintf(os.Stderr, "Error: daemon not running (%s); tetora top needs a running daemon\n", cfg.ListenAddr)
+		os.Exit(1)
+	}
+
+	m := &topModel{snap: fetchTopSnapshot(api)}
+	m.width, m.height = termSize()
+	if once {
+		m.height = 0
+		for _, l := range m.render() {
+			fmt.Println(l)
+		}
+		return
+	}
+
+	// Raw mode for single keypresses. Where it is unavailable (Windows), the
+	// console still refreshes and Ctrl-C quits.
+	saved, rawErr := menuSetRawMode()
+	if rawErr == nil {
+		defer menuRestoreMode(saved)
+	}
+	fmt.Print("\033[?1049h\033[?25l") // alternate screen, hide cursor
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	refresh := make(chan struct{}, 1)
+	poke := func() {
+		select {
+		case refresh <- struct{}{}:
+		default:
+		}
+	}
+	liveCh := make(chan bool, 1)
+	go topWatchEvents(ctx, api, poke, liveCh)
+
+	keys := make(chan string, 16)
+	if rawErr == nil {
+		go func() {
+			buf := make([]byte, 16)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					close(keys)
+					return
+				}
+				for _, k := range topKeys(buf[:n]) {
+					keys <- k
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	redraw := time.NewTicker(250 * time.Millisecond) // tail output and countdowns
+	defer redraw.Stop()
+	var flashAt, sizedAt time.Time
+
+	draw := func() {
+		if time.Since(sizedAt) > time.Second {
+			m.width, m.height = termSize()
+			sizedAt = time.Now()
+		}
+		if m.flash != "" && time.Since(flashAt) > 4*time.Second {
+			m.flash = ""
+		}
+		m.clamp()
+		var b strings.Builder
+		b.WriteString("\033[H")
+		for i, l := range m.render() {
+			if i > 0 {
+				b.WriteString("\r\n")
+			}
+			b.WriteString(l)
+			b.WriteString("\033[K")
+		}
+		b.WriteString("\033[J")
+		os.Stdout.WriteString(b.String())
+	}
+	flash := func(format string, args ...any) {
+		m.flash = fmt.Sprintf(format, args...)
+		flashAt = time.Now()
+	}
+	stopTail := func() {
+		if m.tail != nil {
+			m.tail.cancel()
+			m.tail = nil
+		}
+	}
+	defer stopTail()
+
+	draw()
+	for {
+		select {
+		case <-ticker.C:
+			m.snap = fetchTopSnapshot(api)
+		case <-refresh:
+			// Events come in bursts; let them settle before polling.
+			time.Sleep(200 * time.Millisecond)
+			m.snap = fetchTopSnapshot(api)
+		case m.live = <-liveCh:
+		case <-redraw.C:
+		case k, ok := <-keys:
+			if !ok {
+				return
+			}
+			switch k {
+			case topKeyQuit:
+				return
+			case topKeyUp:
+				if m.pane == topPaneTasks {
+					m.taskSel--
+				} else {
+					m.jobSel--
+				}
+			case topKeyDown:
+				if m.pane == topPaneTasks {
+					m.taskSel++
+				} else {
+					m.jobSel++
+				}
+			case topKeyTab:
+				m.pane = (m.pane + 1) % 2
+			case topKeyRefresh:
+				m.snap = fetchTopSnapshot(api)
+			case topKeyEscape:
+				stopTail()
+			case topKeyCancel:
+				t := m.selectedTask()
+				if m.pane != topPaneTasks || t == nil {
+					flash("select a running task to cancel")
+					break
+				}
+				if err := topPost(api, "/cancel/"+url.PathEscape(t.ID)); err != nil {
+					flash("cancel %s: %v", t.Name, err)
+				} else {
+					flash("cancelling %s", t.Name)
+				}
+				poke()
+			case topKeyTail:
+				t := m.selectedTask()
+				if m.pane != topPaneTasks || t == nil {
+					flash("select a running task to tail")
+					break
+				}
+				if m.tail != nil && m.tail.id == t.ID {
+					stopTail()
+					break
+				}
+				stopTail()
+				tctx, tcancel := context.WithCancel(ctx)
+				m.tail = &topTail{id: t.ID, name: t.Name, cancel: tcancel}
+				go topStreamTask(tctx, api, m.tail)
+			case topKeyRun:
+				j := m.selectedJob()
+				if m.pane != topPaneJobs || j == nil {
+					flash("select a cron job (tab) to run")
+					break
+				}
+				if err := topPost(api, "/cron/"+url.PathEscape(j.ID)+"/run"); err != nil {
+					flash("run %s: %v", j.Name, err)
+				} else {
+					flash("triggered %s", j.Name)
+				}
+				poke()
+			}
+		}
+		draw()
+	}
+}
+
+// topPost sends an empty POST and returns the server's error, if any.
+func topPost(api *APIClient, path string) error {
+	resp, err := api.Post(path, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return fmt.Errorf("%s", e.Error)
+	}
+	return nil
+}
+
+// topStream opens an SSE stream and calls fn with each event until the
+// stream ends or fn returns false.
+func topStream(ctx context.Context, api *APIClient, path string, fn func(typ string, data map[string]any) bool) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", api.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if api.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Token)
+	}
+	if api.ClientID != "" {
+		req.Header.Set("X-Client-ID", api.ClientID)
+	}
+	// Same transport as api (unix socket), without the request timeout.
+	resp, err := (&http.Client{Transport: api.Client.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 256*1024), 256*1024)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Type string `json:"type"`
+			Data any    `json:"data"`
+		}
+		if json.Unmarshal([]byte(line), &ev) != nil {
+			continue
+		}
+		data, _ := ev.Data.(map[string]any)
+		if !fn(ev.Type, data) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// topWatchEvents follows the dashboard event stream and pokes a refresh on
+// every event, reconnecting until ctx ends. live reports the connection state.
+func topWatchEvents(ctx context.Context, api *APIClient, poke func(), live chan<- bool) {
+	setLive := func(v bool) {
+		select {
+		case live <- v:
+		default:
+		}
+	}
+	for ctx.Err() == nil {
+		topStream(ctx, api, "/events/dashboard", func(typ string, _ map[string]any) bool {
+			setLive(true)
+			if typ != "heartbeat" && typ != "output_chunk" {
+				poke()
+			}
+			return true
+		})
+		setLive(false)
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// topStreamTask copies a task's streamed output into t.
+func topStreamTask(ctx context.Context, api *APIClient, t *topTail) {
+	err := topStream(ctx, api, "/dispatch/"+url.PathEscape(t.id)+"/stream", func(typ string, data map[string]any) bool {
+		switch typ {
+		case "output_chunk":
+			chunk, _ := data["chunk"].(string)
+			replace, _ := data["replace"].(bool)
+			t.append(chunk, replace)
+		case "tool_call":
+			if name, _ := data["name"].(string); name != "" {
+				t.append("\n[tool] "+name+"\n", false)
+			}
+		case "completed":
+			t.finish("completed")
+			return false
+		case "error":
+			msg, _ := data["error"].(string)
+			t.finish(strings.TrimSpace("error " + msg))
+			return false
+		}
+		return true
+	})
+	if err != nil && ctx.Err() == nil {
+		t.finish("stream closed: " + err.Error())
+	}
+}
+
+// termSize returns the terminal's columns and rows, defaulting to 80x24.
+func termSize() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(string(out), &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return cols, rows
+		}
+	}
+	cols, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	rows, _ := strconv.Atoi(os.Getenv("LINES"))
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+	return cols, rows
+}
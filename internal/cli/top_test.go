@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopKeys(t *testing.T) {
+	got := topKeys([]byte("\x1b[A\x1b[Bjk\tctr q\x03\x1bx"))
+	want := []string{topKeyUp, topKeyDown, topKeyDown, topKeyUp, topKeyTab, topKeyCancel, topKeyTail,
+		topKeyRun, topKeyRefresh, topKeyQuit, topKeyQuit, topKeyEscape}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("topKeys = %q, want %q", got, want)
+	}
+}
+
+func TestTopSnapshotAndRender(t *testing.T) {
+	next := time.Now().Add(90 * time.Minute).UTC().Format(time.RFC3339)
+	routes := map[string]string{
+		"/tasks/running":           `[{"id":"abcdef123456","name":"nightly-report","source":"cron","model":"sonnet","elapsed":"42s"}]`,
+		"/queue":                   `{"items":[],"count":0,"pending":3}`,
+		"/history":                 `{"runs":[{"name":"triage","source":"discord","status":"error","costUsd":0.12,"startedAt":"2026-01-02T03:04:05Z"}]}`,
+		"/stats/cost":              `{"today":4.5,"dailyLimit":5}`,
+		"/cron":                    `[{"id":"later","name":"later","enabled":true,"nextRun":"` + next + `"},{"id":"off","name":"off","enabled":false},{"id":"now","name":"busy","enabled":true,"running":true}]`,
+		"/api/integrations/status": `{"channels":[{"name":"telegram","enabled":true,"status":"connected"},{"name":"slack","enabled":false,"status":"not_configured"}]}`,
+	}
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posted = append(posted, r.URL.Path)
+			if strings.HasPrefix(r.URL.Path, "/cancel/") {
+				http.Error(w, `{"error":"task not found or not running"}`, http.StatusNotFound)
+			}
+			return
+		}
+		if r.URL.Path == "/dispatch/abcdef123456/stream" {
+			fmt.Fprint(w, "data: {\"type\":\"output_chunk\",\"data\":{\"chunk\":\"line one\\nline\"}}\n\n")
+			fmt.Fprint(w, "data: {\"type\":\"output_chunk\",\"data\":{\"chunk\":\" two\\n\"}}\n\n")
+			fmt.Fprint(w, "data: {\"type\":\"completed\"}\n\n")
+			return
+		}
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	api := NewAPIClient(strings.TrimPrefix(srv.URL, "http://"), "")
+
+	snap := fetchTopSnapshot(api)
+	if snap.Err != nil {
+		t.Fatalf("snapshot error: %v", snap.Err)
+	}
+	if len(snap.Running) != 1 || snap.Queued != 3 || snap.CostToday != 4.5 || len(snap.History) != 1 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if len(snap.Jobs) != 2 || snap.Jobs[0].Name != "busy" || snap.Jobs[1].Name != "later" {
+		t.Errorf("jobs (enabled, running first) = %+v", snap.Jobs)
+	}
+	if len(snap.Channels) != 1 || snap.Channels[0].Name != "telegram" {
+		t.Errorf("channels = %+v", snap.Channels)
+	}
+
+	if err := topPost(api, "/cron/later/run"); err != nil {
+		t.Errorf("run: %v", err)
+	}
+	if err := topPost(api, "/cancel/gone"); err == nil || err.Error() != "task not found or not running" {
+		t.Errorf("cancel error = %v", err)
+	}
+	if !reflect.DeepEqual(posted, []string{"/cron/later/run", "/cancel/gone"}) {
+		t.Errorf("posted = %q", posted)
+	}
+
+	tail := &topTail{id: "abcdef123456", name: "nightly-report"}
+	topStreamTask(context.Background(), api, tail)
+	if lines, done := tail.lines(10); !reflect.DeepEqual(lines, []string{"line one", "line two"}) || done != "completed" {
+		t.Errorf("tail = %q, %q", lines, done)
+	}
+
+	m := &topModel{snap: snap, pane: topPaneJobs, jobSel: 1, tail: tail, width: 100, height: 40}
+	screen := m.render()
+	if len(screen) != 40 || !strings.Contains(screen[39], "q quit") {
+		t.Fatalf("screen has %d lines, footer %q", len(screen), screen[len(screen)-1])
+	}
+	text := strings.Join(screen, "\n")
+	for _, want := range []string{"running 1  queued 3", "$4.50 / $5.00 today", "abcdef12", "nightly-report",
+		topReverse + "  later", "in 1h", "triage", "●" + topReset + " telegram", "line two", "— completed"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("screen lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestTopFit(t *testing.T) {
+	if got := topFit("日本語のテキスト", 4); got != "日本語…" {
+		t.Errorf("topFit = %q", got)
+	}
+	if got := topFit("a\nb", 5); got != "a b" {
+		t.Errorf("topFit newline = %q", got)
+	}
+}
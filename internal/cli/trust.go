@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/db"
+	"tetora/internal/trust"
 )
 
 // --- Trust Level Constants ---
@@ -31,6 +33,8 @@ type TrustStatus struct {
 	NextLevel          string `json:"nextLevel,omitempty"`
 	TotalTasks         int    `json:"totalTasks"`
 	LastUpdated        string `json:"lastUpdated,omitempty"`
+	ToolViolations     int    `json:"toolViolations"`
+	LastViolation      string `json:"lastViolation,omitempty"`
 }
 
 func CmdTrust(args []string) {
@@ -64,7 +68,7 @@ func cmdTrustShow() {
 	cfg := LoadCLIConfig("")
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	resp, err := api.Get("/trust")
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
@@ -91,10 +95,14 @@ func printTrustStatuses(statuses []TrustStatus) {
 	fmt.Println(strings.Repeat("-", 55))
 
 	for _, s := range statuses {
-		status := ""
+		var notes []string
 		if s.PromoteReady {
-			status = fmt.Sprintf("-> %s ready", s.NextLevel)
+			notes = append(notes, fmt.Sprintf("-> %s ready", s.NextLevel))
 		}
+		if s.ToolViolations > 0 {
+			notes = append(notes, fmt.Sprintf("%d tool violations (7d): %s", s.ToolViolations, s.LastViolation))
+		}
+		status := strings.Join(notes, "; ")
 		fmt.Printf("%-10s %-10s %-8d %-12d %s\n",
 			s.Agent, s.Level, s.ConsecutiveSuccess, s.TotalTasks, status)
 	}
@@ -109,7 +117,7 @@ func cmdTrustSet(role, level string) {
 	cfg := LoadCLIConfig("")
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	payload := fmt.Sprintf(`{"level":"%s"}`, level)
 	resp, err := api.Post("/trust/"+role, payload)
 	if err == nil && resp.StatusCode == 200 {
@@ -125,7 +133,7 @@ func cmdTrustSet(role, level string) {
 	}
 
 	oldLevel := resolveTrustLevelCLI(cfg, role)
-	configPath := filepath.Join(cfg.BaseDir, "config.json")
+	configPath := cfg.ConfigPath
 	if err := saveAgentTrustLevel(configPath, role, level); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -140,7 +148,7 @@ func cmdTrustEvents(role string) {
 	cfg := LoadCLIConfig("")
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	path := "/trust-events"
 	if role != "" {
 		path += "?role=" + role
@@ -301,6 +309,8 @@ func getAllTrustStatuses(cfg *CLIConfig) []TrustStatus {
 			lastUpdated = JSONStrSafe(events[0]["created_at"])
 		}
 
+		violations, lastViolation := trust.QueryViolations(cfg.HistoryDB, role, time.Now().Add(-trust.ViolationWindow))
+
 		statuses = append(statuses, TrustStatus{
 			Agent:              role,
 			Level:              level,
@@ -309,13 +319,15 @@ func getAllTrustStatuses(cfg *CLIConfig) []TrustStatus {
 			NextLevel:          next,
 			TotalTasks:         totalTasks,
 			LastUpdated:        lastUpdated,
+			ToolViolations:     violations,
+			LastViolation:      lastViolation,
 		})
 	}
 	return statuses
 }
 
 func saveAgentTrustLevel(configPath, agentName, newLevel string) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -342,5 +354,5 @@ func saveAgentTrustLevel(configPath, agentName, newLevel string) error {
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
@@ -70,7 +70,7 @@ func CmdUsage(args []string) {
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	if tryUsageFromAPI(api, period, showModel, showRole, days) {
 		return
 	}
@@ -225,7 +225,7 @@ func cmdUsageTokens(args []string) {
 	}
 
 	// Try daemon API first.
-	api := cfg.NewAPIClient()
+	api := cfg.Daemon()
 	resp, err := api.Get(fmt.Sprintf("/api/tokens/summary?days=%d", days))
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
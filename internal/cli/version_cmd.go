@@ -7,6 +7,7 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"tetora/internal/config"
 	"tetora/internal/version"
 )
 
@@ -56,7 +57,7 @@ func HandleWorkflowVersionSubcommands(action string, args []string) bool {
 // PrintUsageVersioning returns help text for version-related commands.
 func PrintUsageVersioning() string {
 	var b strings.Builder
-	b.WriteString("  history                                    Show version history\n")
+	b.WriteString("  history [--effective]                      Show version history\n")
 	b.WriteString("  rollback <version-id>                      Restore to a previous version\n")
 	b.WriteString("  diff <version1> <version2>                 Compare two versions\n")
 	b.WriteString("  snapshot [--reason \"...\"]                   Create a manual snapshot\n")
@@ -71,14 +72,19 @@ func configHistory(args []string) {
 	cfg := LoadCLIConfig(FindConfigPath())
 
 	limit := 20
+	entityType, entityName := "config", "config.json"
 	for i := 0; i < len(args); i++ {
-		if args[i] == "--limit" && i+1 < len(args) {
+		switch {
+		case args[i] == "--limit" && i+1 < len(args):
 			fmt.Sscanf(args[i+1], "%d", &limit)
 			i++
+		case args[i] == "--effective":
+			// Versions of the merged config for the active profile.
+			entityType, entityName = version.EffectiveConfigType, version.EffectiveConfigName(cfg.Profile)
 		}
 	}
 
-	versions, err := version.QueryVersions(cfg.HistoryDB, "config", "config.json", limit)
+	versions, err := version.QueryVersions(cfg.HistoryDB, entityType, entityName, limit)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -161,6 +167,15 @@ func configSnapshot(args []string) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	// With overlays, also snapshot the merged config they produce.
+	data, layers, err := config.ReadEffective(configPath, cfg.Profile, false)
+	if err == nil && len(layers) > 0 {
+		err = version.SnapshotEffectiveConfig(cfg.HistoryDB, cfg.Profile, string(data), "cli", reason)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: effective config: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println("Config snapshot created.")
 }
 
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 )
 
 // incomingWebhookConfig is a CLI-local copy of root's IncomingWebhookConfig.
@@ -16,12 +17,29 @@ type incomingWebhookConfig struct {
 	Filter   string `json:"filter,omitempty"`
 	Workflow string `json:"workflow,omitempty"`
 	Enabled  *bool  `json:"enabled,omitempty"`
+
+	Transform map[string]string `json:"transform,omitempty"`
+
+	ResponseMode string `json:"responseMode,omitempty"`
+	MaxWait      string `json:"maxWait,omitempty"`
+	Response     *struct {
+		Body string `json:"body"`
+	} `json:"response,omitempty"`
 }
 
 func (c incomingWebhookConfig) isEnabled() bool {
 	return c.Enabled == nil || *c.Enabled
 }
 
+// maxWait mirrors root's IncomingWebhookConfig.MaxWaitDuration.
+func (c incomingWebhookConfig) maxWait() time.Duration {
+	d, err := time.ParseDuration(c.MaxWait)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return min(d, 10*time.Minute)
+}
+
 // incomingWebhookResult is a CLI-local copy of root's IncomingWebhookResult.
 type incomingWebhookResult struct {
 	Name     string `json:"name"`
@@ -30,6 +48,11 @@ type incomingWebhookResult struct {
 	Agent    string `json:"agent,omitempty"`
 	Workflow string `json:"workflow,omitempty"`
 	Message  string `json:"message,omitempty"`
+
+	TaskStatus string  `json:"taskStatus,omitempty"`
+	Output     string  `json:"output,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	CostUSD    float64 `json:"costUsd,omitempty"`
 }
 
 func CmdWebhook(args []string) {
@@ -50,6 +73,24 @@ func CmdWebhook(args []string) {
 			payload = args[2]
 		}
 		cmdWebhookTest(args[1], payload)
+	case "preview":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Usage: tetora webhook preview <name> [json-payload|@file]\n")
+			os.Exit(1)
+		}
+		payload := `{"test":true}`
+		if len(args) >= 3 {
+			payload = args[2]
+		}
+		if path, ok := strings.CutPrefix(payload, "@"); ok {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			payload = string(b)
+		}
+		cmdWebhookPreview(args[1], payload)
 	case "show":
 		if len(args) < 2 {
 			fmt.Fprintf(os.Stderr, "Usage: tetora webhook show <name>\n")
@@ -57,7 +98,7 @@ func CmdWebhook(args []string) {
 		}
 		cmdWebhookShow(args[1])
 	default:
-		fmt.Fprintf(os.Stderr, "Usage: tetora webhook <list|show|test>\n")
+		fmt.Fprintf(os.Stderr, "Usage: tetora webhook <list|show|test|preview>\n")
 		os.Exit(1)
 	}
 }
@@ -79,6 +120,7 @@ func cmdWebhookList() {
 			Filter    string `json:"filter,omitempty"`
 			Workflow  string `json:"workflow,omitempty"`
 			HasSecret bool   `json:"hasSecret"`
+			ResponseMode string `json:"responseMode"`
 		}
 		if json.Unmarshal(body, &list) == nil {
 			if len(list) == 0 {
@@ -97,6 +139,9 @@ func cmdWebhookList() {
 					secret = "yes"
 				}
 				fmt.Printf("  %-20s  agent=%-8s  status=%-8s  secret=%s\n", wh.Name, wh.Agent, status, secret)
+				if wh.ResponseMode == "sync" {
+					fmt.Printf("  %20s  response: sync\n", "")
+				}
 				if wh.Filter != "" {
 					fmt.Printf("  %20s  filter: %s\n", "", wh.Filter)
 				}
@@ -170,6 +215,18 @@ func cmdWebhookShow(name string) {
 	if wh.Workflow != "" {
 		fmt.Printf("  Workflow: %s\n", wh.Workflow)
 	}
+	if wh.ResponseMode == "sync" {
+		fmt.Printf("  Response: sync, max wait %s\n", wh.maxWait())
+		if wh.Response != nil && wh.Response.Body != "" {
+			fmt.Printf("  Response body:\n    %s\n", strings.ReplaceAll(wh.Response.Body, "\n", "\n    "))
+		}
+	}
+	if len(wh.Transform) > 0 {
+		fmt.Printf("  Transform:\n")
+		for _, k := range sortedKeys(wh.Transform) {
+			fmt.Printf("    %-16s %s\n", k, wh.Transform[k])
+		}
+	}
 	if wh.Template != "" {
 		fmt.Printf("  Template:\n    %s\n", strings.ReplaceAll(wh.Template, "\n", "\n    "))
 	}
@@ -189,7 +246,8 @@ func cmdWebhookTest(name, payload string) {
 	if cfg.IncomingWebhooks != nil {
 		json.Unmarshal(mustMarshalRawMap(cfg.IncomingWebhooks), &webhooks)
 	}
-	if _, ok := webhooks[name]; !ok {
+	wh, ok := webhooks[name]
+	if !ok {
 		fmt.Fprintf(os.Stderr, "Webhook %q not found.\n", name)
 		fmt.Fprintf(os.Stderr, "Available: %s\n", strings.Join(webhookNames(webhooks), ", "))
 		os.Exit(1)
@@ -203,6 +261,9 @@ func cmdWebhookTest(name, payload string) {
 	}
 
 	api := cfg.NewAPIClient()
+	if wh.ResponseMode == "sync" {
+		api.Client.Timeout = wh.maxWait() + 10*time.Second
+	}
 	resp, err := api.PostJSON("/hooks/"+name, parsed)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error sending test webhook: %v\n", err)
@@ -212,7 +273,7 @@ func cmdWebhookTest(name, payload string) {
 	body, _ := io.ReadAll(resp.Body)
 
 	var result incomingWebhookResult
-	if json.Unmarshal(body, &result) == nil {
+	if json.Unmarshal(body, &result) == nil && result.Status != "" {
 		fmt.Printf("Status: %s\n", result.Status)
 		if result.TaskID != "" {
 			fmt.Printf("Task:   %s\n", result.TaskID[:8])
@@ -223,9 +284,74 @@ func cmdWebhookTest(name, payload string) {
 		if result.Message != "" {
 			fmt.Printf("Message: %s\n", result.Message)
 		}
+		if result.TaskStatus != "" {
+			fmt.Printf("Result: %s ($%.4f)\n", result.TaskStatus, result.CostUSD)
+			if result.Error != "" {
+				fmt.Printf("Error:  %s\n", result.Error)
+			}
+			if result.Output != "" {
+				fmt.Printf("\n%s\n", result.Output)
+			}
+		}
 	} else {
-		fmt.Println(string(body))
+		// A sync webhook's response template.
+		fmt.Printf("HTTP %d %s\n\n%s\n", resp.StatusCode, resp.Header.Get("Content-Type"), string(body))
+	}
+}
+
+// cmdWebhookPreview shows the variables, filter verdict and prompt a webhook
+// would produce for a sample payload, without dispatching anything.
+func cmdWebhookPreview(name, payload string) {
+	cfg := LoadCLIConfig(FindConfigPath())
+
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid JSON payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	api := cfg.NewAPIClient()
+	resp, err := api.PostJSON("/webhooks/incoming/"+name+"/preview", parsed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		fmt.Fprintf(os.Stderr, "HTTP %d: %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+
+	var p struct {
+		Vars    map[string]any `json:"vars"`
+		Errors  []string       `json:"errors"`
+		Filter  string         `json:"filter"`
+		Matched bool           `json:"matched"`
+		Prompt  string         `json:"prompt"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(p.Vars) > 0 {
+		fmt.Println("Variables:")
+		for _, k := range sortedKeys(p.Vars) {
+			v, _ := json.Marshal(p.Vars[k])
+			fmt.Printf("  %-16s %s\n", k, v)
+		}
+	}
+	for _, e := range p.Errors {
+		fmt.Printf("  error: %s\n", e)
+	}
+	if p.Filter != "" {
+		verdict := "passes"
+		if !p.Matched {
+			verdict = "filtered out"
+		}
+		fmt.Printf("Filter: %s (%s)\n", p.Filter, verdict)
 	}
+	fmt.Printf("\nPrompt:\n%s\n", p.Prompt)
 }
 
 func webhookNames(webhooks map[string]incomingWebhookConfig) []string {
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tetora/internal/cfgfile"
+)
+
+// CmdWorkspace handles the "tetora workspace" CLI command.
+func CmdWorkspace(args []string) {
+	if len(args) == 0 {
+		printWorkspaceUsage()
+		return
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		cmdWorkspaceList()
+	case "create":
+		cmdWorkspaceCreate(args[1:])
+	case "switch":
+		cmdWorkspaceSwitch(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown workspace subcommand: %s\n", args[0])
+		printWorkspaceUsage()
+		os.Exit(1)
+	}
+}
+
+func printWorkspaceUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: tetora workspace <command>
+
+Commands:
+  list                   List workspaces (* marks the active one)
+  create <name>          Create an isolated workspace
+  switch <name>          Make CLI commands target a workspace ("default" = main)
+
+Flags (create):
+  --token                Generate a bearer token scoped to the workspace
+  --channel PLATFORM:ID  Route a channel to the workspace (repeatable, e.g. discord:123)`)
+}
+
+func cmdWorkspaceList() {
+	cfg := LoadCLIConfig(FindConfigPath())
+	if len(cfg.Workspaces) == 0 {
+		fmt.Println("No workspaces. Create one with: tetora workspace create <name>")
+		return
+	}
+
+	names := make([]string, 0, len(cfg.Workspaces))
+	for name := range cfg.Workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("  %-20s %-24s %-6s %s\n", "NAME", "CLIENT ID", "TOKEN", "CHANNELS")
+	for _, name := range names {
+		ws := cfg.Workspaces[name]
+		marker := " "
+		if name == cfg.ActiveWorkspace {
+			marker = "*"
+		}
+		token := "-"
+		if ws.APIToken != "" {
+			token = "yes"
+		}
+		channels := strings.Join(ws.Channels, ",")
+		if channels == "" {
+			channels = "-"
+		}
+		fmt.Printf("%s %-20s %-24s %-6s %s\n", marker, name, cfg.WorkspaceClientID(name), token, channels)
+	}
+}
+
+func cmdWorkspaceCreate(args []string) {
+	var name string
+	var genToken bool
+	var channels []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--token":
+			genToken = true
+		case "--channel":
+			if i+1 < len(args) {
+				i++
+				channels = append(channels, args[i])
+			}
+		default:
+			if name == "" {
+				name = args[i]
+			}
+		}
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Usage: tetora workspace create <name> [--token] [--channel PLATFORM:ID]")
+		os.Exit(1)
+	}
+	if !validWorkspaceName(name) {
+		fmt.Fprintln(os.Stderr, "Error: workspace name must be 1-28 lowercase letters, digits or hyphens")
+		os.Exit(1)
+	}
+	for _, ch := range channels {
+		if platform, id, ok := strings.Cut(ch, ":"); !ok || platform == "" || id == "" {
+			fmt.Fprintf(os.Stderr, "Error: invalid channel %q (want PLATFORM:ID)\n", ch)
+			os.Exit(1)
+		}
+	}
+
+	configPath := FindConfigPath()
+	cfg := LoadCLIConfig(configPath)
+	if _, exists := cfg.Workspaces[name]; exists {
+		fmt.Fprintf(os.Stderr, "Error: workspace %q already exists\n", name)
+		os.Exit(1)
+	}
+
+	ws := TenantInfo{ClientID: "cli_" + name, Channels: channels}
+	if genToken {
+		tokenBytes := make([]byte, 32)
+		rand.Read(tokenBytes)
+		ws.APIToken = hex.EncodeToString(tokenBytes)
+	}
+
+	// Lay out the client directory. The config overlay starts empty: the
+	// workspace inherits daemon settings but has no agents until some are added.
+	clientDir := filepath.Join(cfg.ClientsDir, ws.ClientID)
+	for _, sub := range []string{"dbs", "agents", "workspace", "knowledge"} {
+		if err := os.MkdirAll(filepath.Join(clientDir, sub), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	overlayPath := cfgfile.Find(clientDir, "config")
+	if overlayPath == "" {
+		overlayPath = filepath.Join(clientDir, "config.json")
+		if err := os.WriteFile(overlayPath, []byte("{\n  \"agents\": {}\n}\n"), 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	workspaces := cfg.Workspaces
+	if workspaces == nil {
+		workspaces = make(map[string]TenantInfo)
+	}
+	workspaces[name] = ws
+	raw, _ := json.Marshal(workspaces)
+	if err := UpdateConfigField(configPath, "workspaces", raw); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Workspace %q created (client %s).\n", name, ws.ClientID)
+	fmt.Printf("  Config overlay: %s\n", overlayPath)
+	if ws.APIToken != "" {
+		fmt.Printf("  API token:      %s\n", ws.APIToken)
+	}
+	fmt.Println("Restart the daemon to serve it: tetora restart")
+}
+
+func cmdWorkspaceSwitch(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: tetora workspace switch <name|default>")
+		os.Exit(1)
+	}
+	name := args[0]
+
+	configPath := FindConfigPath()
+	cfg := LoadCLIConfig(configPath)
+	if name == "default" {
+		name = ""
+	} else if _, ok := cfg.Workspaces[name]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: workspace %q not found\n", name)
+		os.Exit(1)
+	}
+
+	raw, _ := json.Marshal(name)
+	if err := UpdateConfigField(configPath, "activeWorkspace", raw); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if name == "" {
+		fmt.Println("Switched to the default workspace.")
+		return
+	}
+	fmt.Printf("Switched to workspace %q.\n", name)
+}
+
+// validWorkspaceName mirrors the client ID rules: "cli_" + name must be a valid client ID.
+func validWorkspaceName(name string) bool {
+	if len(name) == 0 || len(name) > 28 {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -53,7 +53,7 @@ func SubActions(cmd string) []string {
 	case "history":
 		return []string{"list", "show", "cost"}
 	case "config":
-		return []string{"show", "set", "validate", "migrate", "history", "rollback", "diff", "snapshot", "show-version", "versions"}
+		return []string{"show", "set", "validate", "lint", "schema", "convert", "profiles", "migrate", "history", "rollback", "diff", "snapshot", "show-version", "versions"}
 	case "prompt":
 		return []string{"list", "show", "add", "edit", "remove"}
 	case "memory":
@@ -81,7 +81,7 @@ func SubActions(cmd string) []string {
 	case "service":
 		return []string{"install", "uninstall", "status"}
 	case "data":
-		return []string{"status", "cleanup", "export", "purge"}
+		return []string{"status", "cleanup", "export", "erase", "verify", "purge"}
 	case "plugin":
 		return []string{"list", "start", "stop"}
 	case "task":
@@ -155,7 +155,9 @@ func SubActionDescriptions(cmd string) map[string]string {
 	case "config":
 		return map[string]string{
 			"show": "Show current config", "set": "Set a config value",
-			"validate": "Validate config file", "migrate": "Run config migration",
+			"validate": "Validate config file", "lint": "Check config files against the schema",
+			"schema": "Print the config JSON Schema", "convert": "Convert the config file between JSON, YAML and TOML",
+			"profiles": "List config profiles", "migrate": "Run config migration",
 			"history": "Show config version history", "rollback": "Restore to a previous version",
 			"diff": "Compare two versions", "snapshot": "Create a manual snapshot",
 			"show-version": "Show full content of a version", "versions": "List all versioned entities",
@@ -225,6 +227,7 @@ func SubActionDescriptions(cmd string) map[string]string {
 		return map[string]string{
 			"status": "Show retention config and row counts", "cleanup": "Run retention cleanup",
 			"export": "Export all user data (GDPR)", "purge": "Delete data before a date",
+			"erase": "Delete everything tied to a user", "verify": "Check a data manifest's signature",
 		}
 	case "service":
 		return map[string]string{
@@ -4,15 +4,20 @@
 package config
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
 
+	"tetora/internal/cfgfile"
+
 	// Type aliases for configs defined in internal packages.
 	"tetora/internal/cost"
 	"tetora/internal/estimate"
+	"tetora/internal/integration/notes"
+	"tetora/internal/integration/twitter"
 	linebot "tetora/internal/messaging/line"
 	"tetora/internal/messaging/gchat"
 	"tetora/internal/messaging/imessage"
@@ -87,10 +92,12 @@ type SlackBotConfig = slack.Config
 // Integration configs (archived in PR1 — fields kept as json.RawMessage to avoid breaking existing config.json).
 type GmailConfig = json.RawMessage
 type SpotifyConfig = json.RawMessage
-type TwitterConfig = json.RawMessage
 type PodcastConfig = json.RawMessage
 type HomeAssistantConfig = json.RawMessage
-type NotesConfig = json.RawMessage
+
+// Restored integrations.
+type TwitterConfig = twitter.Config
+type NotesConfig = notes.Config
 
 // --- Config ---
 
@@ -106,6 +113,7 @@ type Config struct {
 	DefaultAgent          string                     `json:"defaultAgent,omitempty"`
 	DefaultWorkdir        string                     `json:"defaultWorkdir"`
 	ListenAddr            string                     `json:"listenAddr"`
+	HTTP                  HTTPConfig                 `json:"http,omitempty"`
 	Telegram              TelegramConfig             `json:"telegram"`
 	MCPConfigs            map[string]json.RawMessage `json:"mcpConfigs"`
 	MCPServers            map[string]MCPServerConfig `json:"mcpServers,omitempty"`
@@ -131,6 +139,14 @@ type Config struct {
 	DefaultProvider       string                     `json:"defaultProvider,omitempty"`
 	Docker                DockerConfig               `json:"docker,omitempty"`
 	SmartDispatch         SmartDispatchConfig        `json:"smartDispatch,omitempty"`
+	Routing               RoutingConfig              `json:"routing,omitempty"`
+	Handover              HandoverConfig             `json:"handover,omitempty"`
+	FAQ                   FAQConfig                  `json:"faq,omitempty"`
+	Intents               IntentsConfig              `json:"intents,omitempty"`
+	Unfurl                UnfurlConfig               `json:"unfurl,omitempty"`
+	Monitors              MonitorsConfig             `json:"monitors,omitempty"`
+	KnowledgeWatch        KnowledgeWatchConfig       `json:"knowledgeWatch,omitempty"`
+	Pipelines             PipelinesConfig            `json:"pipelines,omitempty"`
 	Slack                 SlackBotConfig             `json:"slack,omitempty"`
 	Discord               DiscordBotConfig           `json:"discord,omitempty"`
 	WhatsApp              WhatsAppConfig             `json:"whatsapp,omitempty"`
@@ -157,12 +173,21 @@ type Config struct {
 	ClaudeProvider        string                     `json:"claudeProvider,omitempty"` // "claude-code" | "anthropic" — how to run Claude models
 	SLA                   SLAConfig                  `json:"sla,omitempty"`
 	OfflineQueue          OfflineQueueConfig         `json:"offlineQueue,omitempty"`
+	WorkQueue             WorkQueueConfig            `json:"workQueue,omitempty"`
+	HA                    HAConfig                   `json:"ha,omitempty"`
+	Replica               ReplicaConfig              `json:"replica,omitempty"`
+	ResponseCache         ResponseCacheConfig        `json:"responseCache,omitempty"`
+	Sync                  SyncConfig                 `json:"sync,omitempty"`
+	Mirror                MirrorConfig               `json:"mirror,omitempty"`
+	GitOps                GitOpsConfig               `json:"gitops,omitempty"`
 	Budgets               BudgetConfig               `json:"budgets,omitempty"`
 	DiskBudgetGB          float64                    `json:"diskBudgetGB,omitempty"`
 	DiskWarnMB            int                        `json:"diskWarnMB,omitempty"`
 	DiskBlockMB           int                        `json:"diskBlockMB,omitempty"`
 	Reflection            ReflectionConfig           `json:"reflection,omitempty"`
+	Feedback              FeedbackConfig             `json:"feedback,omitempty"`
 	DeepMemoryExtract    DeepMemoryExtractConfig    `json:"deepMemoryExtract,omitempty"`
+	Memory                MemoryConfig                     `json:"memory,omitempty"`
 	SkillEvolve          SkillEvolveConfig          `json:"skillEvolve,omitempty"`
 	NotifyIntel           NotifyIntelConfig          `json:"notifyIntel,omitempty"`
 	Trust                 TrustConfig                `json:"trust,omitempty"`
@@ -178,8 +203,10 @@ type Config struct {
 	AccessControl         AccessControlConfig              `json:"accessControl,omitempty"`
 	AgentComm             AgentCommConfig                  `json:"agentComm,omitempty"`
 	SlotPressure          SlotPressureConfig               `json:"slotPressure,omitempty"`
+	TaskPriority          TaskPriorityConfig               `json:"taskPriority,omitempty"`
 	Canvas                CanvasConfig                     `json:"canvas,omitempty"`
 	Plugins               map[string]PluginConfig          `json:"plugins,omitempty"`
+	PluginRegistry        string                           `json:"pluginRegistry,omitempty"` // index URL for "tetora plugin install <name>"
 	Sandbox               SandboxConfig                    `json:"sandbox,omitempty"`
 	TaskBoard             TaskBoardConfig                  `json:"taskBoard,omitempty"`
 	Review                ReviewConfig                     `json:"review,omitempty"`
@@ -195,10 +222,23 @@ type Config struct {
 	Notes                 NotesConfig                      `json:"notes,omitempty"`
 	HomeAssistant         HomeAssistantConfig              `json:"homeAssistant,omitempty"`
 	Device                DeviceConfig                     `json:"device,omitempty"`
+	SSH                   SSHConfig                        `json:"ssh,omitempty"`
+	Kubernetes            KubernetesConfig                 `json:"kubernetes,omitempty"`
+	Databases             DatabasesConfig                  `json:"databases,omitempty"`
+	Spreadsheets          SpreadsheetsConfig               `json:"spreadsheets,omitempty"`
+	Documents             DocumentsConfig                  `json:"documents,omitempty"`
 	IMessage              IMessageConfig                   `json:"imessage,omitempty"`
 	Gmail                 GmailConfig                      `json:"gmail,omitempty"`
 	Calendar              CalendarConfig                   `json:"calendar,omitempty"`
 	Twitter               TwitterConfig                    `json:"twitter,omitempty"`
+	Social                SocialConfig                     `json:"social,omitempty"`
+	ReadLater             ReadLaterConfig                  `json:"readLater,omitempty"`
+	MailIn                MailInConfig                     `json:"mailIn,omitempty"`
+	Mail                  MailConfig                       `json:"mail,omitempty"`
+	MQTT                  MQTTConfig                       `json:"mqtt,omitempty"`
+	GitHub                GitHubConfig                     `json:"github,omitempty"`
+	Forges                ForgesConfig                     `json:"forges,omitempty"`
+	IntegrationHealth     IntegrationHealthConfig          `json:"integrationHealth,omitempty"`
 	WritingStyle          WritingStyleConfig               `json:"writingStyle,omitempty"`
 	Citation              CitationConfig                   `json:"citation,omitempty"`
 	BrowserRelay          BrowserRelayConfig               `json:"browserRelay,omitempty"`
@@ -217,7 +257,9 @@ type Config struct {
 	YouTube               YouTubeConfig                    `json:"youtube,omitempty"`
 	Podcast               PodcastConfig                    `json:"podcast,omitempty"`
 	Family                FamilyConfig                     `json:"family,omitempty"`
+	WorkCalendar          WorkCalendarConfig               `json:"workCalendar,omitempty"`
 	EncryptionKey         string                           `json:"encryptionKey,omitempty"`
+	Encryption            EncryptionConfig                 `json:"encryption,omitempty"`
 	StreamToChannels      bool                             `json:"streamToChannels,omitempty"`
 	ApprovalGates         ApprovalGateConfig               `json:"approvalGates,omitempty"`
 	TimeTracking          TimeTrackingConfig               `json:"timeTracking,omitempty"`
@@ -237,8 +279,16 @@ type Config struct {
 	ClientsDir      string `json:"clientsDir,omitempty"`
 	DefaultClientID string `json:"defaultClientID,omitempty"`
 
+	// Multi-workspace: named clients with their own config overlay, agents and DBs.
+	Workspaces      map[string]TenantConfig `json:"workspaces,omitempty"`
+	ActiveWorkspace string                  `json:"activeWorkspace,omitempty"` // CLI default
+
 	// Runtime fields — set after load, not serialized.
 	BaseDir         string            `json:"-"`
+	ConfigPath      string            `json:"-"` // file the config was loaded from
+	Profile         string            `json:"-"` // --profile / TETORA_PROFILE, "" for none
+	ConfigLayers    []string          `json:"-"` // overlay files merged over ConfigPath, in order
+	ClientID        string            `json:"-"` // workspace client the config serves; "" for the main config
 	MCPMu           sync.RWMutex     `json:"-"`
 	MCPPaths        map[string]string `json:"-"`
 	TLSEnabled      bool              `json:"-"`
@@ -318,3 +368,82 @@ func (c *Config) OutputsDirFor(clientID string) string {
 	}
 	return c.ClientDir(clientID)
 }
+
+// ChartsDir returns where chart_render saves images.
+func (c *Config) ChartsDir() string {
+	return filepath.Join(c.BaseDir, "outputs", "charts")
+}
+
+// InvitesDir returns where calendar_invite saves .ics files.
+func (c *Config) InvitesDir() string {
+	return filepath.Join(c.BaseDir, "outputs", "invites")
+}
+
+// ReviewsDir returns where workflow review steps write their final drafts.
+func (c *Config) ReviewsDir() string {
+	return filepath.Join(c.BaseDir, "outputs", "reviews")
+}
+
+// ForgeAccounts returns every code forge account: the github block, when
+// enabled, followed by forges.accounts.
+func (c *Config) ForgeAccounts() []ForgeAccount {
+	var out []ForgeAccount
+	if c.GitHub.Enabled {
+		out = append(out, c.GitHub.Account())
+	}
+	return append(out, c.Forges.Accounts...)
+}
+
+// WorkspaceClientID returns the client ID a named workspace runs under.
+func (c *Config) WorkspaceClientID(name string) string {
+	if ws, ok := c.Workspaces[name]; ok && ws.ClientID != "" {
+		return ws.ClientID
+	}
+	return "cli_" + name
+}
+
+// ConfigFile returns the file the config was loaded from: config.json,
+// config.yaml or config.toml in BaseDir.
+func (c *Config) ConfigFile() string {
+	if c.ConfigPath != "" {
+		return c.ConfigPath
+	}
+	return filepath.Join(c.BaseDir, "config.json")
+}
+
+// WorkspaceConfigPath returns the config overlay file for a workspace client,
+// in whichever format it was written.
+func (c *Config) WorkspaceConfigPath(clientID string) string {
+	dir := filepath.Join(c.ClientsDir, clientID)
+	if p := cfgfile.Find(dir, "config"); p != "" {
+		return p
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// WorkspaceByToken returns the name of the workspace owning a bearer token.
+func (c *Config) WorkspaceByToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for name, ws := range c.Workspaces {
+		if ws.APIToken != "" && subtle.ConstantTimeCompare([]byte(ws.APIToken), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// WorkspaceByChannel returns the name of the workspace bound to a channel,
+// e.g. WorkspaceByChannel("discord", "123").
+func (c *Config) WorkspaceByChannel(platform, channelID string) (string, bool) {
+	key := platform + ":" + channelID
+	for name, ws := range c.Workspaces {
+		for _, ch := range ws.Channels {
+			if ch == key {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
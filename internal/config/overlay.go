@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tetora/internal/cfgfile"
+)
+
+// ProfileEnv names the environment variable that selects a config profile.
+// `tetora --profile <name>` sets it for the command and everything it starts.
+const ProfileEnv = "TETORA_PROFILE"
+
+// OverlayDir is the directory next to the config file that holds overlay
+// files. Files directly in it apply to every profile; each subdirectory is a
+// profile.
+const OverlayDir = "config.d"
+
+// ActiveProfile returns the profile selected by the environment, or "".
+func ActiveProfile() string {
+	return strings.TrimSpace(os.Getenv(ProfileEnv))
+}
+
+// Layers returns the files deep-merged over the config file at path, in the
+// order they apply: config.d/* by name, then config.d/<profile>/* by name
+// when a profile is given, then the config.local override. Only files with a
+// config extension are used. A profile without a directory is an error, so
+// a typo does not silently start the daemon with the base config.
+func Layers(path, profile string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(path), OverlayDir)
+	layers, err := overlayFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if profile != "" {
+		if profile != filepath.Base(profile) || strings.HasPrefix(profile, ".") {
+			return nil, fmt.Errorf("invalid profile name %q", profile)
+		}
+		pdir := filepath.Join(dir, profile)
+		if fi, err := os.Stat(pdir); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("profile %q: no %s directory", profile, filepath.Join(OverlayDir, profile))
+		}
+		files, err := overlayFiles(pdir)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, files...)
+	}
+	if local := cfgfile.Sibling(path, ".local"); local != "" {
+		layers = append(layers, local)
+	}
+	return layers, nil
+}
+
+// overlayFiles lists the config files directly in dir, sorted by name.
+// A missing dir has none.
+func overlayFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !isConfigExt(filepath.Ext(name)) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files, nil
+}
+
+func isConfigExt(ext string) bool {
+	for _, e := range cfgfile.Extensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Profiles returns the names of the profiles defined next to the config
+// file at path, sorted.
+func Profiles(path string) []string {
+	entries, _ := os.ReadDir(filepath.Join(filepath.Dir(path), OverlayDir))
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadEffective reads the config file at path and deep-merges its Layers on
+// top, returning the merged config as JSON along with the layers applied.
+// With expand false, ${VAR} references are kept as written, which is how
+// config versions store them.
+func ReadEffective(path, profile string, expand bool) (data []byte, layers []string, err error) {
+	data, _, err = readDoc(path, expand)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers, err = Layers(path, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range layers {
+		overlay, _, err := readDoc(p, expand)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+		if data, err = MergeJSON(data, overlay); err != nil {
+			return nil, nil, fmt.Errorf("merge %s: %w", filepath.Base(p), err)
+		}
+	}
+	if len(layers) > 0 {
+		var b bytes.Buffer
+		if json.Indent(&b, data, "", "  ") == nil {
+			data = append(b.Bytes(), '\n')
+		}
+	}
+	return data, layers, nil
+}
+
+// MergeJSON merges two JSON documents. Values in overlay take precedence.
+// Objects are merged recursively (key-level); all other types are replaced.
+func MergeJSON(base, overlay []byte) ([]byte, error) {
+	var dst, src map[string]any
+	if err := json.Unmarshal(base, &dst); err != nil {
+		return nil, fmt.Errorf("unmarshal base: %w", err)
+	}
+	if err := json.Unmarshal(overlay, &src); err != nil {
+		return nil, fmt.Errorf("unmarshal override: %w", err)
+	}
+	if dst == nil {
+		dst = map[string]any{}
+	}
+	MergeMaps(dst, src)
+	return json.Marshal(dst)
+}
+
+// MergeMaps recursively merges src into dst. src values win; nested maps
+// are merged at key level rather than replaced wholesale.
+func MergeMaps(dst, src map[string]any) {
+	for k, srcVal := range src {
+		dstVal, exists := dst[k]
+		if !exists {
+			dst[k] = srcVal
+			continue
+		}
+		srcMap, srcOK := srcVal.(map[string]any)
+		dstMap, dstOK := dstVal.(map[string]any)
+		if srcOK && dstOK {
+			MergeMaps(dstMap, srcMap)
+		} else {
+			dst[k] = srcVal
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadEffective(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"apiToken": "${TETORA_TEST_TOKEN}", "telegram": {"enabled": true, "chatID": 1}}`), 0o600)
+	os.MkdirAll(filepath.Join(dir, OverlayDir, "staging"), 0o755)
+	os.MkdirAll(filepath.Join(dir, OverlayDir, "prod"), 0o755)
+	os.WriteFile(filepath.Join(dir, OverlayDir, "b.json"), []byte(`{"telegram": {"chatID": 2}}`), 0o600)
+	os.WriteFile(filepath.Join(dir, OverlayDir, "a.yaml"), []byte("telegram:\n  chatID: 3\n  pollTimeout: 10\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, OverlayDir, "prod", "chat.toml"), []byte("[telegram]\nchatID = 4\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "config.local.json"), []byte(`{"telegram": {"pollTimeout": 20}}`), 0o600)
+	t.Setenv("TETORA_TEST_TOKEN", "s3cret")
+
+	type doc struct {
+		APIToken string `json:"apiToken"`
+		Telegram struct {
+			Enabled     bool  `json:"enabled"`
+			ChatID      int64 `json:"chatID"`
+			PollTimeout int   `json:"pollTimeout"`
+		} `json:"telegram"`
+	}
+	read := func(profile string, expand bool) (doc, []string) {
+		t.Helper()
+		data, layers, err := ReadEffective(path, profile, expand)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d doc
+		if err := json.Unmarshal(data, &d); err != nil {
+			t.Fatalf("%v: %s", err, data)
+		}
+		for i, l := range layers {
+			layers[i], _ = filepath.Rel(dir, l)
+		}
+		return d, layers
+	}
+
+	d, layers := read("", true)
+	if strings.Join(layers, " ") != "config.d/a.yaml config.d/b.json config.local.json" {
+		t.Errorf("layers = %v", layers)
+	}
+	if d.APIToken != "s3cret" || !d.Telegram.Enabled || d.Telegram.ChatID != 2 || d.Telegram.PollTimeout != 20 {
+		t.Errorf("effective = %+v", d)
+	}
+
+	d, layers = read("prod", false)
+	if len(layers) != 4 || layers[2] != "config.d/prod/chat.toml" {
+		t.Errorf("prod layers = %v", layers)
+	}
+	if d.APIToken != "${TETORA_TEST_TOKEN}" || d.Telegram.ChatID != 4 || d.Telegram.PollTimeout != 20 {
+		t.Errorf("prod effective = %+v", d)
+	}
+
+	if _, layers = read("staging", true); len(layers) != 3 {
+		t.Errorf("staging layers = %v", layers)
+	}
+	if got := Profiles(path); strings.Join(got, " ") != "prod staging" {
+		t.Errorf("Profiles = %v", got)
+	}
+	for _, bad := range []string{"qa", "../prod", ".hidden"} {
+		if _, err := Layers(path, bad); err == nil {
+			t.Errorf("Layers(%q) succeeded", bad)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/log"
 )
 
@@ -66,26 +67,56 @@ func ResolveEnvRef(value, fieldName string) string {
 // ResolveSecrets resolves $ENV_VAR references in secret config fields.
 func ResolveSecrets(cfg *Config) {
 	cfg.APIToken = ResolveEnvRef(cfg.APIToken, "apiToken")
+	for name, ws := range cfg.Workspaces {
+		if ws.APIToken != "" {
+			ws.APIToken = ResolveEnvRef(ws.APIToken, "workspaces."+name+".apiToken")
+			cfg.Workspaces[name] = ws
+		}
+	}
 	if cfg.DashboardAuth.Password != "" {
 		cfg.DashboardAuth.Password = ResolveEnvRef(cfg.DashboardAuth.Password, "dashboardAuth.password")
 	}
 	if cfg.DashboardAuth.Token != "" {
 		cfg.DashboardAuth.Token = ResolveEnvRef(cfg.DashboardAuth.Token, "dashboardAuth.token")
 	}
+	for i, acct := range cfg.DashboardAuth.Accounts {
+		if acct.Password != "" {
+			cfg.DashboardAuth.Accounts[i].Password = ResolveEnvRef(acct.Password, fmt.Sprintf("dashboardAuth.accounts[%d].password", i))
+		}
+	}
+	if cfg.DashboardAuth.OIDC.ClientSecret != "" {
+		cfg.DashboardAuth.OIDC.ClientSecret = ResolveEnvRef(cfg.DashboardAuth.OIDC.ClientSecret, "dashboardAuth.oidc.clientSecret")
+	}
 	for i, wh := range cfg.Webhooks {
 		for k, v := range wh.Headers {
 			cfg.Webhooks[i].Headers[k] = ResolveEnvRef(v, fmt.Sprintf("webhooks[%d].headers.%s", i, k))
 		}
 	}
+	for i, sink := range cfg.Security.AuditExport.Sinks {
+		for k, v := range sink.Headers {
+			cfg.Security.AuditExport.Sinks[i].Headers[k] = ResolveEnvRef(v, fmt.Sprintf("security.auditExport.sinks[%d].headers.%s", i, k))
+		}
+	}
 	for i := range cfg.Notifications {
 		cfg.Notifications[i].WebhookURL = ResolveEnvRef(cfg.Notifications[i].WebhookURL, fmt.Sprintf("notifications[%d].webhookUrl", i))
 	}
+	for i, t := range cfg.Monitors.Watch {
+		for k, v := range t.Headers {
+			cfg.Monitors.Watch[i].Headers[k] = ResolveEnvRef(v, fmt.Sprintf("monitors.watch[%d].headers.%s", i, k))
+		}
+	}
+	if cfg.WorkQueue.Password != "" {
+		cfg.WorkQueue.Password = ResolveEnvRef(cfg.WorkQueue.Password, "workQueue.password")
+	}
 	if cfg.TLS.CertFile != "" {
 		cfg.TLS.CertFile = ResolveEnvRef(cfg.TLS.CertFile, "tls.certFile")
 	}
 	if cfg.TLS.KeyFile != "" {
 		cfg.TLS.KeyFile = ResolveEnvRef(cfg.TLS.KeyFile, "tls.keyFile")
 	}
+	if cfg.TLS.ClientCAFile != "" {
+		cfg.TLS.ClientCAFile = ResolveEnvRef(cfg.TLS.ClientCAFile, "tls.clientCAFile")
+	}
 	for name, pc := range cfg.Providers {
 		if pc.APIKey != "" {
 			pc.APIKey = ResolveEnvRef(pc.APIKey, fmt.Sprintf("providers.%s.apiKey", name))
@@ -134,11 +165,59 @@ func ResolveSecrets(cfg *Config) {
 		cfg.Tools.Vision.APIKey = ResolveEnvRef(cfg.Tools.Vision.APIKey, "tools.vision.apiKey")
 	}
 	cfg.OAuth.EncryptionKey = ResolveEnvRef(cfg.OAuth.EncryptionKey, "oauth.encryptionKey")
+	cfg.Encryption.MasterKey = ResolveEnvRef(cfg.Encryption.MasterKey, "encryption.masterKey")
 	for name, svc := range cfg.OAuth.Services {
 		svc.ClientID = ResolveEnvRef(svc.ClientID, fmt.Sprintf("oauth.services.%s.clientId", name))
 		svc.ClientSecret = ResolveEnvRef(svc.ClientSecret, fmt.Sprintf("oauth.services.%s.clientSecret", name))
 		cfg.OAuth.Services[name] = svc
 	}
+	for i, a := range cfg.Social.Accounts {
+		a.AppPassword = ResolveEnvRef(a.AppPassword, fmt.Sprintf("social.accounts.%s.appPassword", a.Name))
+		a.AccessToken = ResolveEnvRef(a.AccessToken, fmt.Sprintf("social.accounts.%s.accessToken", a.Name))
+		cfg.Social.Accounts[i] = a
+	}
+	for i, a := range cfg.Calendar.Accounts {
+		a.Password = ResolveEnvRef(a.Password, fmt.Sprintf("calendar.accounts.%s.password", a.Name))
+		cfg.Calendar.Accounts[i] = a
+	}
+	cfg.ReadLater.Token = ResolveEnvRef(cfg.ReadLater.Token, "readLater.token")
+	cfg.Translate.APIKey = ResolveEnvRef(cfg.Translate.APIKey, "translate.apiKey")
+	cfg.MailIn.IMAP.Password = ResolveEnvRef(cfg.MailIn.IMAP.Password, "mailIn.imap.password")
+	cfg.MailIn.Mailgun.SigningKey = ResolveEnvRef(cfg.MailIn.Mailgun.SigningKey, "mailIn.mailgun.signingKey")
+	for i, a := range cfg.Mail.Accounts {
+		a.Password = ResolveEnvRef(a.Password, fmt.Sprintf("mail.accounts.%s.password", a.Name))
+		cfg.Mail.Accounts[i] = a
+	}
+	for i, b := range cfg.MQTT.Brokers {
+		b.Password = ResolveEnvRef(b.Password, fmt.Sprintf("mqtt.brokers.%s.password", b.Name))
+		cfg.MQTT.Brokers[i] = b
+	}
+	cfg.GitHub.Token = ResolveEnvRef(cfg.GitHub.Token, "github.token")
+	cfg.GitHub.WebhookSecret = ResolveEnvRef(cfg.GitHub.WebhookSecret, "github.webhookSecret")
+	for i, a := range cfg.Forges.Accounts {
+		a.Token = ResolveEnvRef(a.Token, fmt.Sprintf("forges.accounts.%s.token", a.Name))
+		a.WebhookSecret = ResolveEnvRef(a.WebhookSecret, fmt.Sprintf("forges.accounts.%s.webhookSecret", a.Name))
+		cfg.Forges.Accounts[i] = a
+	}
+	cfg.TaskBoard.Sync.Token = ResolveEnvRef(cfg.TaskBoard.Sync.Token, "taskBoard.sync.token")
+	cfg.Notes.Notion.Token = ResolveEnvRef(cfg.Notes.Notion.Token, "notes.notion.token")
+	for i, r := range cfg.Ops.BackupRemotes {
+		name := r.NameOrBucket()
+		r.AccessKey = ResolveEnvRef(r.AccessKey, fmt.Sprintf("ops.backupRemotes.%s.accessKey", name))
+		r.SecretKey = ResolveEnvRef(r.SecretKey, fmt.Sprintf("ops.backupRemotes.%s.secretKey", name))
+		r.CustomerKey = ResolveEnvRef(r.CustomerKey, fmt.Sprintf("ops.backupRemotes.%s.customerKey", name))
+		cfg.Ops.BackupRemotes[i] = r
+	}
+	cfg.Sync.PeerToken = ResolveEnvRef(cfg.Sync.PeerToken, "sync.peerToken")
+	cfg.Sync.Key = ResolveEnvRef(cfg.Sync.Key, "sync.key")
+	cfg.Mirror.PeerToken = ResolveEnvRef(cfg.Mirror.PeerToken, "mirror.peerToken")
+	cfg.GitOps.Token = ResolveEnvRef(cfg.GitOps.Token, "gitops.token")
+	cfg.GitOps.WebhookSecret = ResolveEnvRef(cfg.GitOps.WebhookSecret, "gitops.webhookSecret")
+	cfg.Replica.PrimaryToken = ResolveEnvRef(cfg.Replica.PrimaryToken, "replica.primaryToken")
+	for name, c := range cfg.Databases.Connections {
+		c.Password = ResolveEnvRef(c.Password, "databases.connections."+name+".password")
+		cfg.Databases.Connections[name] = c
+	}
 	if cfg.TaskManager.Todoist.APIKey != "" {
 		cfg.TaskManager.Todoist.APIKey = ResolveEnvRef(cfg.TaskManager.Todoist.APIKey, "taskManager.todoist.apiKey")
 	}
@@ -153,6 +232,9 @@ func ResolveSecrets(cfg *Config) {
 	if cfg.Telegram.BotToken != "" {
 		cfg.Telegram.BotToken = ResolveEnvRef(cfg.Telegram.BotToken, "telegram.botToken")
 	}
+	if cfg.Telegram.WebhookSecret != "" {
+		cfg.Telegram.WebhookSecret = ResolveEnvRef(cfg.Telegram.WebhookSecret, "telegram.webhookSecret")
+	}
 	if cfg.Slack.BotToken != "" {
 		cfg.Slack.BotToken = ResolveEnvRef(cfg.Slack.BotToken, "slack.botToken")
 	}
@@ -215,10 +297,33 @@ func ResolveMCPPaths(cfg *Config) {
 	}
 }
 
+// ReadDoc reads a JSON, YAML or TOML config file and returns it as JSON,
+// with ${VAR} and ${VAR:-default} references in string values expanded
+// from the environment. raw is the file as written, for LintDoc; it is
+// returned even when the file does not parse.
+func ReadDoc(path string) (data, raw []byte, err error) {
+	return readDoc(path, true)
+}
+
+func readDoc(path string, expand bool) (data, raw []byte, err error) {
+	raw, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := cfgfile.Parse(raw, cfgfile.FormatOf(path))
+	if err != nil {
+		return nil, raw, err
+	}
+	if expand {
+		doc.Interpolate(os.LookupEnv)
+	}
+	return doc.JSON(), raw, nil
+}
+
 // LoadForVersioning is a lightweight config loader for versioning hooks.
 // It only resolves historyDB path. Returns nil if loading fails.
 func LoadForVersioning(configPath string) *Config {
-	data, err := os.ReadFile(configPath)
+	data, _, err := ReadDoc(configPath)
 	if err != nil {
 		return nil
 	}
@@ -227,6 +332,7 @@ func LoadForVersioning(configPath string) *Config {
 		return nil
 	}
 	cfg.BaseDir = filepath.Dir(configPath)
+	cfg.ConfigPath = configPath
 	if cfg.HistoryDB == "" {
 		cfg.HistoryDB = "history.db"
 	}
@@ -3,9 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
+
+	"tetora/internal/cfgfile"
 )
 
 // configFileMu protects concurrent writes to the config file on disk.
@@ -17,7 +17,7 @@ func SaveProviders(configPath, name string, pc ProviderConfig) error {
 	configFileMu.Lock()
 	defer configFileMu.Unlock()
 
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -52,7 +52,7 @@ func SaveProviders(configPath, name string, pc ProviderConfig) error {
 		return fmt.Errorf("marshal config: %w", err)
 	}
 
-	if err := writeFileAtomic(configPath, append(out, '\n')); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return fmt.Errorf("write config: %w", err)
 	}
 	return nil
@@ -63,7 +63,7 @@ func DeleteProvider(configPath, name string) error {
 	configFileMu.Lock()
 	defer configFileMu.Unlock()
 
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -84,7 +84,7 @@ func DeleteProvider(configPath, name string) error {
 		return fmt.Errorf("marshal config: %w", err)
 	}
 
-	if err := writeFileAtomic(configPath, append(out, '\n')); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return fmt.Errorf("write config: %w", err)
 	}
 	return nil
@@ -96,7 +96,7 @@ func SaveInferenceMode(configPath, mode string, agents map[string]AgentConfig) e
 	configFileMu.Lock()
 	defer configFileMu.Unlock()
 
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -136,23 +136,8 @@ func SaveInferenceMode(configPath, mode string, agents map[string]AgentConfig) e
 		return fmt.Errorf("marshal config: %w", err)
 	}
 
-	if err := writeFileAtomic(configPath, append(out, '\n')); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return fmt.Errorf("write config: %w", err)
 	}
 	return nil
 }
-
-// writeFileAtomic writes data to a .tmp file in the same directory as dst,
-// then renames it to dst. os.Rename is atomic on the same filesystem (POSIX),
-// so a crash mid-write leaves dst either fully updated or fully intact.
-func writeFileAtomic(dst string, data []byte) error {
-	tmp := filepath.Join(filepath.Dir(dst), filepath.Base(dst)+".tmp")
-	if err := os.WriteFile(tmp, data, 0o600); err != nil {
-		return err
-	}
-	if err := os.Rename(tmp, dst); err != nil {
-		os.Remove(tmp) // best-effort cleanup
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,591 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tetora/internal/cfgfile"
+)
+
+// --- JSON Schema and lint ---
+//
+// The schema and the linter are both derived from the Config struct by
+// reflection, so they cannot drift from what loadConfig accepts.
+
+// legacyKeys are keys the custom UnmarshalJSON methods accept besides the
+// struct fields.
+var legacyKeys = map[reflect.Type][]string{
+	reflect.TypeOf(Config{}):              {"roles"},
+	reflect.TypeOf(SmartDispatchConfig{}): {"defaultRole"},
+	reflect.TypeOf(RoutingRule{}):         {"role"},
+	reflect.TypeOf(RoutingBinding{}):      {"role"},
+	reflect.TypeOf(DiscordRouteConfig{}):  {"role"},
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// opaque reports whether values of t decode themselves in ways the linter
+// cannot know, so anything is accepted.
+func opaque(t reflect.Type) bool {
+	if _, ok := legacyKeys[t]; ok {
+		return false
+	}
+	return t == rawMessageType || t.Kind() == reflect.Interface ||
+		reflect.PointerTo(t).Implements(unmarshalerType)
+}
+
+// textual reports whether values of t are decoded from a JSON string.
+func textual(t reflect.Type) bool {
+	return !opaque(t) && reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+type schemaField struct {
+	name     string
+	typ      reflect.Type
+	asString bool // `json:",string"`
+}
+
+// fields returns the JSON fields of struct type t, with embedded structs
+// flattened as encoding/json does.
+func fields(t reflect.Type) []schemaField {
+	var out []schemaField
+	seen := map[string]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := f.Type
+			if f.Anonymous && name == "" {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			out = append(out, schemaField{name: name, typ: ft, asString: strings.Contains(opts, "string")})
+		}
+	}
+	walk(t)
+	return out
+}
+
+// Schema returns a JSON Schema describing config.json.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	root := structSchema(reflect.TypeOf(Config{}), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "Tetora config"
+	root["$defs"] = defs
+	return root
+}
+
+func schemaFor(t reflect.Type, defs map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if opaque(t) {
+		return map[string]any{}
+	}
+	if textual(t) {
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{"type": []string{"array", "null"}, "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+		name := t.String() // package-qualified, so equal names do not collide
+		if _, ok := defs[name]; !ok {
+			defs[name] = map[string]any{} // placeholder breaks cycles
+			defs[name] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	for _, f := range fields(t) {
+		s := schemaFor(f.typ, defs)
+		if f.asString {
+			s = map[string]any{"type": "string"}
+		}
+		props[f.name] = s
+	}
+	for _, k := range legacyKeys[t] {
+		if _, ok := props[k]; !ok {
+			props[k] = map[string]any{"deprecated": true}
+		}
+	}
+	if t == reflect.TypeOf(Config{}) {
+		props["$schema"] = map[string]any{"type": "string"}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"patternProperties":    map[string]any{"^_": map[string]any{}}, // "_comment" and the like
+		"additionalProperties": false,
+	}
+}
+
+// Issue is a problem found in a config document.
+type Issue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Path    string `json:"path"`
+	Level   string `json:"level"` // "error" or "warning"
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	loc := strconv.Itoa(i.Line)
+	if i.Col > 0 {
+		loc += ":" + strconv.Itoa(i.Col)
+	}
+	if i.File != "" {
+		loc = i.File + ":" + loc
+	}
+	path := i.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", loc, i.Level, path, i.Message)
+}
+
+// HasErrors reports whether any issue is an error. Warnings, such as
+// unknown keys, do not stop a config from loading.
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Level == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// LintFile lints the config document at path, in the format its extension
+// names.
+func LintFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	issues := LintDoc(data, cfgfile.FormatOf(path))
+	for i := range issues {
+		issues[i].File = path
+	}
+	return issues, nil
+}
+
+// LintDoc is Lint for a document in format f. YAML and TOML documents are
+// checked in their JSON form and issues are placed on the line of the key
+// they concern, without a column.
+func LintDoc(data []byte, f cfgfile.Format) []Issue {
+	if f == cfgfile.JSON {
+		return Lint(data)
+	}
+	doc, err := cfgfile.Parse(data, f)
+	if err != nil {
+		i := Issue{Level: "error", Message: err.Error()}
+		var se *cfgfile.SyntaxError
+		if errors.As(err, &se) {
+			i.Line, i.Message = se.Line, se.Msg
+		}
+		return []Issue{i}
+	}
+	issues := Lint(doc.JSON())
+	for n := range issues {
+		issues[n].Line, issues[n].Col = doc.Line(issues[n].Path), 0
+	}
+	return issues
+}
+
+// Lint checks a config document against Config: syntax errors, values of
+// the wrong type (errors, since the config will not load) and unknown or
+// repeated keys (warnings, since they are ignored). Keys starting with "_"
+// are comments.
+func Lint(data []byte) []Issue {
+	l := &linter{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	l.dec.UseNumber()
+	if err := l.value(reflect.TypeOf(Config{}), ""); err != nil {
+		l.syntax(err)
+		return l.issues
+	}
+	if _, err := l.dec.Token(); err != io.EOF {
+		l.add(l.dec.InputOffset(), "", "error", "unexpected data after the end of the config")
+	}
+	return l.issues
+}
+
+type linter struct {
+	data   []byte
+	dec    *json.Decoder
+	issues []Issue
+}
+
+// errSyntax stops the walk; the decoder's error has been recorded.
+var errSyntax = errors.New("syntax error")
+
+func (l *linter) add(offset int64, path, level, msg string) {
+	line, col := position(l.data, offset)
+	l.issues = append(l.issues, Issue{Line: line, Col: col, Path: path, Level: level, Message: msg})
+}
+
+func (l *linter) syntax(err error) {
+	if errors.Is(err, errSyntax) {
+		return
+	}
+	off := l.dec.InputOffset()
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		off = se.Offset
+	}
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		l.add(int64(len(l.data)), "", "error", "unexpected end of file")
+		return
+	}
+	l.add(off, "", "error", "invalid JSON: "+err.Error())
+}
+
+// position converts a byte offset to a 1-based line and column.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// next returns the next token and the offset it starts at.
+func (l *linter) next() (json.Token, int64, error) {
+	off := l.dec.InputOffset()
+	for off < int64(len(l.data)) {
+		switch l.data[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+			continue
+		}
+		break
+	}
+	tok, err := l.dec.Token()
+	if err != nil {
+		return nil, off, err
+	}
+	return tok, off, nil
+}
+
+func jsonKind(tok json.Token) string {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			return "object"
+		}
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return "value"
+}
+
+// value checks the next value against t.
+func (l *linter) value(t reflect.Type, path string) error {
+	tok, off, err := l.next()
+	if err != nil {
+		l.syntax(err)
+		return errSyntax
+	}
+	if tok == nil {
+		return nil // null leaves the field unset
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	want := ""
+	switch {
+	case opaque(t):
+		return l.skip(tok)
+	case textual(t):
+		want = "string"
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			want = "string"
+		case reflect.Bool:
+			want = "boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			want = "integer"
+		case reflect.Float32, reflect.Float64:
+			want = "number"
+		case reflect.Slice, reflect.Array:
+			want = "array"
+			if t.Elem().Kind() == reflect.Uint8 {
+				want = "string"
+			}
+		case reflect.Map, reflect.Struct:
+			want = "object"
+		default:
+			return l.skip(tok)
+		}
+	}
+
+	got := jsonKind(tok)
+	if want == "integer" && got == "number" {
+		if _, err := tok.(json.Number).Int64(); err != nil {
+			l.add(off, path, "error", fmt.Sprintf("expected an integer, got %s", tok))
+		}
+		return nil
+	}
+	if got != want {
+		msg := fmt.Sprintf("expected %s, got %s", article(want), got)
+		if s, ok := tok.(string); ok && (want == "integer" || want == "number" || want == "boolean") {
+			msg += fmt.Sprintf(" %q (remove the quotes)", s)
+		}
+		l.add(off, path, "error", msg)
+		return l.skip(tok)
+	}
+
+	switch want {
+	case "array":
+		for i := 0; l.dec.More(); i++ {
+			if err := l.value(t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err := l.dec.Token()
+		return err
+	case "object":
+		if t.Kind() == reflect.Map {
+			return l.mapObject(t, path)
+		}
+		return l.structObject(t, path)
+	}
+	return nil
+}
+
+func article(kind string) string {
+	if strings.IndexByte("aeiou", kind[0]) >= 0 {
+		return "an " + kind
+	}
+	return "a " + kind
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (l *linter) mapObject(t reflect.Type, path string) error {
+	seen := map[string]bool{}
+	for l.dec.More() {
+		tok, off, err := l.next()
+		if err != nil {
+			l.syntax(err)
+			return errSyntax
+		}
+		key := tok.(string)
+		if seen[key] {
+			l.add(off, join(path, key), "warning", "duplicate key; the last value wins")
+		}
+		seen[key] = true
+		if err := l.value(t.Elem(), join(path, key)); err != nil {
+			return err
+		}
+	}
+	_, err := l.dec.Token()
+	return err
+}
+
+func (l *linter) structObject(t reflect.Type, path string) error {
+	byName := map[string]schemaField{}
+	var names []string
+	for _, f := range fields(t) {
+		byName[f.name] = f
+		names = append(names, f.name)
+	}
+	legacy := map[string]bool{}
+	for _, k := range legacyKeys[t] {
+		legacy[k] = true
+	}
+	seen := map[string]bool{}
+	for l.dec.More() {
+		tok, off, err := l.next()
+		if err != nil {
+			l.syntax(err)
+			return errSyntax
+		}
+		key := tok.(string)
+		if seen[key] {
+			l.add(off, join(path, key), "warning", "duplicate key; the last value wins")
+		}
+		seen[key] = true
+
+		f, ok := byName[key]
+		if !ok {
+			// encoding/json also matches field names case-insensitively.
+			for _, n := range names {
+				if strings.EqualFold(n, key) {
+					f, ok = byName[n], true
+					l.add(off, join(path, key), "warning", fmt.Sprintf("key should be spelled %q", n))
+					break
+				}
+			}
+		}
+		switch {
+		case ok && f.asString:
+			err = l.value(reflect.TypeOf(""), join(path, key))
+		case ok:
+			err = l.value(f.typ, join(path, key))
+		default:
+			if !legacy[key] && !strings.HasPrefix(key, "_") && !(path == "" && key == "$schema") {
+				msg := "unknown key; it is ignored"
+				if s := suggest(key, names); s != "" {
+					msg = fmt.Sprintf("unknown key (did you mean %q?); it is ignored", s)
+				}
+				l.add(off, join(path, key), "warning", msg)
+			}
+			err = l.skipNext()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := l.dec.Token()
+	return err
+}
+
+// skipNext consumes the next value.
+func (l *linter) skipNext() error {
+	tok, _, err := l.next()
+	if err != nil {
+		l.syntax(err)
+		return errSyntax
+	}
+	return l.skip(tok)
+}
+
+// skip consumes the rest of a value whose first token was tok.
+func (l *linter) skip(tok json.Token) error {
+	d, ok := tok.(json.Delim)
+	if !ok || d == '}' || d == ']' {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := l.dec.Token()
+		if err != nil {
+			l.syntax(err)
+			return errSyntax
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// suggest returns the name closest to key, if it is close enough to be a
+// likely typo.
+func suggest(key string, names []string) string {
+	best, bestDist := "", 0
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	lk := strings.ToLower(key)
+	for _, n := range sorted {
+		d := editDistance(lk, strings.ToLower(n))
+		if best == "" || d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	if best == "" || bestDist > 2 || bestDist*3 > len(key) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and swaps of adjacent letters each count one.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"tetora/internal/cfgfile"
+)
+
+func TestLint(t *testing.T) {
+	doc := `{
+  "$schema": "./config.schema.json",
+  "_comment": "personal settings",
+  "maxConcurent": 4,
+  "listenAddr": 8080,
+  "defaultBudget": "2.5",
+  "roles": {},
+  "agents": {
+    "ruri": {"modle": "opus", "model": "sonnet"}
+  },
+  "smartDispatch": {"defaultRole": "ruri"},
+  "telegram": {"enabled": true, "enabled": false}
+}`
+	issues := Lint([]byte(doc))
+	want := []string{
+		"4:3: warning: maxConcurent: unknown key (did you mean \"maxConcurrent\"?)",
+		"5:17: error: listenAddr: expected a string, got number",
+		"6:20: error: defaultBudget: expected a number, got string \"2.5\" (remove the quotes)",
+		"9:14: warning: agents.ruri.modle: unknown key (did you mean \"model\"?)",
+		"12:33: warning: telegram.enabled: duplicate key",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %v", issues)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(issues[i].String(), w) {
+			t.Errorf("issue %d = %q, want prefix %q", i, issues[i], w)
+		}
+	}
+	if !HasErrors(issues) {
+		t.Error("HasErrors = false")
+	}
+
+	if got := Lint([]byte(`{"agents": {"a": {"model": "x"}}, "maxConcurrent": 2.5}`)); len(got) != 1 || got[0].Path != "maxConcurrent" {
+		t.Errorf("fractional int: %v", got)
+	}
+	if got := Lint([]byte("{\n  \"listenAddr\": \"x\",\n}")); len(got) != 1 || got[0].Line != 2 || got[0].Level != "error" {
+		t.Errorf("syntax error: %v", got)
+	}
+	if got := Lint([]byte(`{"listenAddr": "x"`)); len(got) != 1 || !strings.Contains(got[0].Message, "end of") {
+		t.Errorf("truncated: %v", got)
+	}
+}
+
+func TestLintExamples(t *testing.T) {
+	for _, p := range []string{"../../examples/config.example.json", "../../config.local.example.json"} {
+		issues, err := LintFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range issues {
+			t.Errorf("%s", i)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+	props := s["properties"].(map[string]any)
+	if props["maxConcurrent"].(map[string]any)["type"] != "integer" {
+		t.Errorf("maxConcurrent = %v", props["maxConcurrent"])
+	}
+	agents := props["agents"].(map[string]any)
+	ref := agents["additionalProperties"].(map[string]any)["$ref"].(string)
+	defs := s["$defs"].(map[string]any)
+	def, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+	if !ok {
+		t.Fatalf("agents ref %q not in $defs", ref)
+	}
+	if _, ok := def["properties"].(map[string]any)["model"]; !ok {
+		t.Errorf("agent schema has no model: %v", def)
+	}
+}
+
+func TestLintDoc(t *testing.T) {
+	yaml := "maxConcurrent: 4\nagents:\n  ruri:\n    modle: opus\nlistenAddr: 8080\n"
+	issues := LintDoc([]byte(yaml), cfgfile.YAML)
+	want := []string{
+		"4: warning: agents.ruri.modle: unknown key",
+		"5: error: listenAddr: expected a string, got number",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %v", issues)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(issues[i].String(), w) {
+			t.Errorf("issue %d = %q, want prefix %q", i, issues[i], w)
+		}
+	}
+	if got := LintDoc([]byte("a = \n"), cfgfile.TOML); len(got) != 1 || got[0].Line != 1 || got[0].Level != "error" {
+		t.Errorf("toml syntax error: %v", got)
+	}
+}
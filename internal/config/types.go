@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,6 +25,7 @@ type AgentConfig struct {
 	Provider          string          `json:"provider,omitempty"`
 	Docker            *bool           `json:"docker,omitempty"`
 	FallbackProviders []string        `json:"fallbackProviders,omitempty"`
+	ProviderChain     []string        `json:"providerChain,omitempty"` // ordered "provider" or "provider/model" steps; replaces provider + fallbackProviders
 	TrustLevel        string          `json:"trustLevel,omitempty"`
 	ToolPolicy        AgentToolPolicy `json:"tools,omitempty"`
 	ToolProfile       string          `json:"toolProfile,omitempty"`
@@ -35,6 +39,83 @@ type AgentConfig struct {
 	WorkdirMode           string          `json:"workdirMode,omitempty"`           // "default" | "output_only" | "workspace"
 	// Deprecated: OutputOnly is kept for backward compat; WorkdirMode takes precedence.
 	OutputOnly            bool            `json:"outputOnly,omitempty"`            // if true, use AgentOutputBase as workdir
+	Schedule              *AgentScheduleConfig `json:"schedule,omitempty"`         // run windows; nil = any time
+	ResponseCache         string               `json:"responseCache,omitempty"`    // "on", a TTL ("6h") or "off"; see ResponseCacheConfig
+	Experiment            *AgentExperimentConfig `json:"experiment,omitempty"`     // A/B test of soul files and models; nil = none
+	Personas              []AgentPersonaConfig   `json:"personas,omitempty"`       // tone fragments by time or context; first match wins
+	Knowledge             *AgentKnowledgeConfig  `json:"knowledge,omitempty"`      // knowledgeDir subset the agent searches; nil = all
+}
+
+// AgentKnowledgeConfig narrows the knowledge an agent searches and cites to
+// files under Dirs (relative to knowledgeDir) or tagged with one of Tags in
+// their frontmatter. It keeps answers on topic; it is not access control,
+// since an agent with file tools can still read the whole directory.
+type AgentKnowledgeConfig struct {
+	Dirs []string `json:"dirs,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// AgentPersonaConfig is a soul fragment appended to the agent's soul when all
+// of its conditions hold. A persona without conditions always matches, which
+// makes it a fallback when listed last.
+type AgentPersonaConfig struct {
+	Name     string                `json:"name"`
+	Fragment string                `json:"fragment,omitempty"` // inline text
+	File     string                `json:"file,omitempty"`     // relative to the agent's directory (agentsDir/<agent>); used when fragment is empty
+	TZ       string                `json:"tz,omitempty"`       // IANA zone for windows; empty = local
+	Windows  []AgentScheduleWindow `json:"windows,omitempty"`  // any of; empty = any time
+	Sources  []string              `json:"sources,omitempty"`  // task sources, "prefix*" allowed (e.g. "route:telegram", "cron"); empty = any
+	Channels []string              `json:"channels,omitempty"` // chat session keys, "prefix*" allowed (e.g. "tg:-100123", "discord:*"); empty = any
+}
+
+// AgentExperimentConfig splits an agent's tasks between variants that differ
+// in soul file or model. Each task is assigned one variant, sticky per
+// session, and its outcome is recorded under the experiment's name.
+type AgentExperimentConfig struct {
+	Name     string               `json:"name,omitempty"` // groups results; default "default"
+	Variants []AgentVariantConfig `json:"variants"`
+}
+
+// AgentVariantConfig is one arm of an experiment. Empty fields fall back to
+// the agent's own soul and model.
+type AgentVariantConfig struct {
+	Name     string `json:"name"`
+	SoulFile string `json:"soulFile,omitempty"` // relative to the agent's directory (agentsDir/<agent>)
+	Model    string `json:"model,omitempty"`
+	Weight   int    `json:"weight,omitempty"` // share of traffic; default 1
+}
+
+// NameOrDefault returns the experiment name (default "default").
+func (e AgentExperimentConfig) NameOrDefault() string {
+	if e.Name == "" {
+		return "default"
+	}
+	return e.Name
+}
+
+// Variant returns the named variant, or nil.
+func (e AgentExperimentConfig) Variant(name string) *AgentVariantConfig {
+	for i := range e.Variants {
+		if e.Variants[i].Name == name {
+			return &e.Variants[i]
+		}
+	}
+	return nil
+}
+
+// AgentScheduleConfig restricts when an agent may run. Tasks dispatched
+// outside every window are deferred to the start of the next one.
+type AgentScheduleConfig struct {
+	TZ      string                `json:"tz,omitempty"` // IANA zone; empty = local
+	Windows []AgentScheduleWindow `json:"windows"`
+}
+
+// AgentScheduleWindow is a recurring weekly run window. End before Start
+// wraps past midnight (e.g. 23:00-02:00).
+type AgentScheduleWindow struct {
+	Days  []string `json:"days,omitempty"`  // "mon".."sun", "weekdays", "weekends"; empty = every day
+	Start string   `json:"start,omitempty"` // "HH:MM"; empty = 00:00
+	End   string   `json:"end,omitempty"`   // "HH:MM", exclusive; empty = end of day
 }
 
 type ProviderConfig struct {
@@ -55,10 +136,75 @@ type CostAlertConfig struct {
 }
 
 type DashboardAuthConfig struct {
-	Enabled  bool   `json:"enabled"`
-	Username string `json:"username,omitempty"`
-	Password string `json:"password,omitempty"`
-	Token    string `json:"token,omitempty"`
+	Enabled  bool                `json:"enabled"`
+	Username string              `json:"username,omitempty"`
+	Password string              `json:"password,omitempty"`
+	Token    string              `json:"token,omitempty"`
+	Accounts []DashboardAccount  `json:"accounts,omitempty"` // extra password logins with a fixed role
+	OIDC     OIDCConfig          `json:"oidc,omitempty"`
+	TOTP     DashboardTOTPConfig `json:"totp,omitempty"`
+}
+
+// DashboardTOTPConfig lets password logins enroll an authenticator app as a
+// second factor. Enrolled logins must enter a code after their password.
+type DashboardTOTPConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Issuer  string `json:"issuer,omitempty"` // name shown in authenticator apps (default "Tetora")
+}
+
+// DashboardAccount is a dashboard password login limited to one role. The
+// main password/token login is always admin.
+type DashboardAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password"` // $ENV_VAR supported
+	Role     string `json:"role"`     // "viewer" | "operator" | "admin"
+}
+
+// OIDCConfig enables OpenID Connect single sign-on for the dashboard.
+type OIDCConfig struct {
+	Enabled       bool              `json:"enabled,omitempty"`
+	Issuer        string            `json:"issuer,omitempty"` // e.g. https://accounts.google.com
+	ClientID      string            `json:"clientId,omitempty"`
+	ClientSecret  string            `json:"clientSecret,omitempty"`  // $ENV_VAR supported; empty for public clients
+	RedirectURL   string            `json:"redirectUrl,omitempty"`   // default <request origin>/dashboard/oidc/callback
+	Scopes        []string          `json:"scopes,omitempty"`        // default openid, profile, email (+ groups claim scope if set)
+	GroupsClaim   string            `json:"groupsClaim,omitempty"`   // ID token claim listing groups (default "groups")
+	Roles         map[string]string `json:"roles,omitempty"`         // group or verified email -> "viewer" | "operator" | "admin"
+	DefaultRole   string            `json:"defaultRole,omitempty"`   // role without a mapping (default "viewer"; "none" denies)
+	AllowedGroups []string          `json:"allowedGroups,omitempty"` // if set, users must be in one of these groups
+	SessionTTL    string            `json:"sessionTtl,omitempty"`    // default "8h"
+}
+
+// GroupsClaimOrDefault returns the claim read for group membership.
+func (c OIDCConfig) GroupsClaimOrDefault() string {
+	if c.GroupsClaim != "" {
+		return c.GroupsClaim
+	}
+	return "groups"
+}
+
+// ScopesOrDefault returns the scopes requested at login.
+func (c OIDCConfig) ScopesOrDefault() []string {
+	if len(c.Scopes) > 0 {
+		return c.Scopes
+	}
+	return []string{"openid", "profile", "email"}
+}
+
+// DefaultRoleOrDefault returns the role of users no mapping matches, or "viewer".
+func (c OIDCConfig) DefaultRoleOrDefault() string {
+	if c.DefaultRole != "" {
+		return c.DefaultRole
+	}
+	return "viewer"
+}
+
+// SessionTTLOrDefault returns how long a dashboard session lasts, or 8h.
+func (c OIDCConfig) SessionTTLOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.SessionTTL); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Hour
 }
 
 type QuietHoursConfig struct {
@@ -81,6 +227,43 @@ type NotificationChannel struct {
 	WebhookURL  string   `json:"webhookUrl"`
 	Events      []string `json:"events,omitempty"`
 	MinPriority string   `json:"minPriority,omitempty"`
+	Account     string   `json:"account,omitempty"` // social account for "bluesky"/"mastodon", broker for "mqtt"; defaults to Name
+	Topic       string   `json:"topic,omitempty"`   // topic for "mqtt"
+}
+
+// HTTPConfig adapts the HTTP server to running behind a reverse proxy.
+type HTTPConfig struct {
+	BasePath       string   `json:"basePath,omitempty"`       // path prefix the proxy serves Tetora under, e.g. "/tetora"
+	TrustedProxies []string `json:"trustedProxies,omitempty"` // IPs or CIDRs whose X-Forwarded-* headers are honored; default loopback
+	Socket         string   `json:"socket,omitempty"`         // unix socket for local clients such as the CLI; default "<baseDir>/tetora.sock", "off" disables
+}
+
+// Prefix returns BasePath as "/x" without a trailing slash, or "" for the root.
+func (c HTTPConfig) Prefix() string {
+	p := strings.Trim(strings.TrimSpace(c.BasePath), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// SocketPath returns the unix socket the daemon also serves the API on, or ""
+// when disabled. Relative paths are resolved against baseDir.
+func (c HTTPConfig) SocketPath(baseDir string) string {
+	switch s := strings.TrimSpace(c.Socket); s {
+	case "off", "none", "-":
+		return ""
+	case "":
+		if baseDir == "" {
+			return ""
+		}
+		return filepath.Join(baseDir, "tetora.sock")
+	default:
+		if !filepath.IsAbs(s) && baseDir != "" {
+			s = filepath.Join(baseDir, s)
+		}
+		return s
+	}
 }
 
 type RateLimitConfig struct {
@@ -91,6 +274,17 @@ type RateLimitConfig struct {
 type TLSConfig struct {
 	CertFile string `json:"certFile,omitempty"`
 	KeyFile  string `json:"keyFile,omitempty"`
+
+	// Mutual TLS. With ClientCAFile set, every request except /healthz and
+	// ClientCertExempt must present a client certificate signed by one of its CAs.
+	ClientCAFile     string            `json:"clientCAFile,omitempty"`     // PEM bundle of client CAs
+	ClientIdentities map[string]string `json:"clientIdentities,omitempty"` // "cn:x", "dns:x", "email:x", "uri:x", "ip:x" → identity; when set, other certs are refused
+	ClientCertExempt []string          `json:"clientCertExempt,omitempty"` // paths served without a client cert, exact or "prefix*"
+}
+
+// MutualTLS reports whether client certificates are required.
+func (c TLSConfig) MutualTLS() bool {
+	return c.CertFile != "" && c.KeyFile != "" && c.ClientCAFile != ""
 }
 
 type SecurityAlertConfig struct {
@@ -110,6 +304,18 @@ type MCPServerConfig struct {
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
 	Enabled *bool             `json:"enabled,omitempty"`
+	// ToolCosts estimates the USD cost of one call per tool name ("*" for
+	// any tool), for servers wrapping paid APIs that do not report a cost
+	// in the tools/call result themselves.
+	ToolCosts map[string]float64 `json:"toolCosts,omitempty"`
+}
+
+// ToolCost returns the configured per-call cost estimate for tool.
+func (c MCPServerConfig) ToolCost(tool string) float64 {
+	if v, ok := c.ToolCosts[tool]; ok {
+		return v
+	}
+	return c.ToolCosts["*"]
 }
 
 // --- SmartDispatch ---
@@ -217,11 +423,374 @@ func (b *RoutingBinding) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// --- Routing Table ---
+
+// RoutingConfig is the declarative channel routing table. Rules are evaluated
+// in order and the first match wins; it takes precedence over smartDispatch
+// bindings/rules and discord.routes.
+type RoutingConfig struct {
+	Rules []RouteRule `json:"rules,omitempty"`
+}
+
+// RouteRule matches an incoming message. Every non-empty field must match;
+// a rule with no match fields is a catch-all.
+type RouteRule struct {
+	Name     string   `json:"name,omitempty"`
+	Channel  string   `json:"channel,omitempty"`  // platform: "discord", "telegram", "slack", "http", ...
+	ChatID   string   `json:"chatId,omitempty"`   // channel / chat / room ID
+	GuildID  string   `json:"guildId,omitempty"`  // discord server
+	UserID   string   `json:"userId,omitempty"`
+	Keywords []string `json:"keywords,omitempty"` // any keyword, case-insensitive substring
+	Agent    string   `json:"agent"`
+}
+
+// Label returns the rule name, or its 1-based position when unnamed.
+func (r RouteRule) Label(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// --- Handover ---
+
+// HandoverConfig lets people on inbound channels ask for the owner. While a
+// conversation is handed over, agents stand down and messages are relayed.
+type HandoverConfig struct {
+	Enabled         bool     `json:"enabled,omitempty"`
+	Triggers        []string `json:"triggers,omitempty"`        // case-insensitive phrases; default "talk to the owner", "talk to a human", ...
+	Ack             string   `json:"ack,omitempty"`             // reply sent to the user when a handover opens
+	ContextMessages int      `json:"contextMessages,omitempty"` // recent messages included in the owner notification (default 10)
+}
+
+// ContextMessagesOrDefault returns the configured context size, or 10.
+func (c HandoverConfig) ContextMessagesOrDefault() int {
+	if c.ContextMessages > 0 {
+		return c.ContextMessages
+	}
+	return 10
+}
+
+// FAQConfig answers curated questions instantly, before smart dispatch runs.
+type FAQConfig struct {
+	Enabled           bool    `json:"enabled,omitempty"`
+	Threshold         float64 `json:"threshold,omitempty"`         // fuzzy match score 0-1 (default 0.8)
+	Semantic          bool    `json:"semantic,omitempty"`          // also match by embedding (requires embedding.enabled)
+	SemanticThreshold float64 `json:"semanticThreshold,omitempty"` // cosine similarity (default 0.85)
+	MaxLength         int     `json:"maxLength,omitempty"`         // longer messages skip the FAQ (default 200 chars)
+	SuggestAfter      int     `json:"suggestAfter,omitempty"`      // similar unanswered questions before suggesting an entry (default 3; -1 disables)
+}
+
+// ThresholdOrDefault returns the fuzzy match threshold, or 0.8.
+func (c FAQConfig) ThresholdOrDefault() float64 {
+	if c.Threshold > 0 {
+		return c.Threshold
+	}
+	return 0.8
+}
+
+// SemanticThresholdOrDefault returns the embedding match threshold, or 0.85.
+func (c FAQConfig) SemanticThresholdOrDefault() float64 {
+	if c.SemanticThreshold > 0 {
+		return c.SemanticThreshold
+	}
+	return 0.85
+}
+
+// MaxLengthOrDefault returns the longest message checked against the FAQ, or 200.
+func (c FAQConfig) MaxLengthOrDefault() int {
+	if c.MaxLength > 0 {
+		return c.MaxLength
+	}
+	return 200
+}
+
+// SuggestAfterOrDefault returns how many similar unanswered questions trigger
+// a suggestion, or 3. Zero means suggestions are disabled.
+func (c FAQConfig) SuggestAfterOrDefault() int {
+	switch {
+	case c.SuggestAfter < 0:
+		return 0
+	case c.SuggestAfter == 0:
+		return 3
+	}
+	return c.SuggestAfter
+}
+
+// IntentsConfig maps short utterances straight to a skill or tool, before
+// smart dispatch and without an LLM round trip.
+type IntentsConfig struct {
+	Enabled    bool             `json:"enabled,omitempty"`
+	Shortcuts  []IntentShortcut `json:"shortcuts,omitempty"`
+	MaxWords   int              `json:"maxWords,omitempty"`   // longer messages are never matched or learned (default 8)
+	Learn      bool             `json:"learn,omitempty"`      // record the tool call of short routed messages to suggest shortcuts
+	LearnAfter int              `json:"learnAfter,omitempty"` // thumbs-up routings of the same utterance and call before suggesting it (default 2)
+}
+
+// IntentShortcut is one utterance→action mapping. Patterns match the whole
+// message, ignoring case, spacing and trailing punctuation; "{name}" captures
+// text, "{name:number}" a number and "{name:word}" one word.
+type IntentShortcut struct {
+	ID       int            `json:"id,omitempty"` // learned shortcuts only
+	Name     string         `json:"name"`
+	Patterns []string       `json:"patterns"`
+	Skill    string         `json:"skill,omitempty"`
+	Tool     string         `json:"tool,omitempty"`
+	Args     map[string]any `json:"args,omitempty"`  // skill vars or tool input; "{name}" in strings is replaced by the capture
+	Agent    string         `json:"agent,omitempty"` // tool policy and trust level to apply (default: none)
+	Reply    string         `json:"reply,omitempty"` // reply template; "{output}" is the result (default "{output}")
+}
+
+// MaxWordsOrDefault returns the longest utterance, in words, that is matched, or 8.
+func (c IntentsConfig) MaxWordsOrDefault() int {
+	if c.MaxWords > 0 {
+		return c.MaxWords
+	}
+	return 8
+}
+
+// LearnAfterOrDefault returns how many confirmed routings make a suggestion, or 2.
+func (c IntentsConfig) LearnAfterOrDefault() int {
+	if c.LearnAfter > 0 {
+		return c.LearnAfter
+	}
+	return 2
+}
+
+// UnfurlConfig summarizes web pages whose URL is pasted alone in chat.
+type UnfurlConfig struct {
+	Enabled   bool                           `json:"enabled,omitempty"`
+	Agent     string                         `json:"agent,omitempty"`     // agent that writes summaries (default smartDispatch.defaultAgent)
+	Model     string                         `json:"model,omitempty"`     // default "haiku"
+	Budget    float64                        `json:"budget,omitempty"`    // USD per summary (default 0.02)
+	MaxBytes  int                            `json:"maxBytes,omitempty"`  // page download cap (default 1 MiB)
+	Knowledge bool                           `json:"knowledge,omitempty"` // save summaries to the knowledge base
+	Channels  map[string]UnfurlChannelConfig `json:"channels,omitempty"`  // "discord:<channelId>", "telegram:<chatId>" or a bare platform
+}
+
+// UnfurlChannelConfig overrides unfurl settings for one channel or platform.
+type UnfurlChannelConfig struct {
+	Enabled   *bool `json:"enabled,omitempty"`
+	Knowledge *bool `json:"knowledge,omitempty"`
+}
+
+// ModelOrDefault returns the summary model, or "haiku".
+func (c UnfurlConfig) ModelOrDefault() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return "haiku"
+}
+
+// BudgetOrDefault returns the per-summary budget, or $0.02.
+func (c UnfurlConfig) BudgetOrDefault() float64 {
+	if c.Budget > 0 {
+		return c.Budget
+	}
+	return 0.02
+}
+
+// MaxBytesOrDefault returns the page download cap, or 1 MiB.
+func (c UnfurlConfig) MaxBytesOrDefault() int {
+	if c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return 1 << 20
+}
+
+// ForChannel resolves whether links are unfurled in a chat and whether the
+// summaries go to the knowledge base. A "platform:chatID" entry wins over a
+// bare "platform" entry, which wins over the top-level settings.
+func (c UnfurlConfig) ForChannel(platform, chatID string) (enabled, knowledge bool) {
+	enabled, knowledge = c.Enabled, c.Knowledge
+	for _, key := range []string{platform, platform + ":" + chatID} {
+		ch, ok := c.Channels[key]
+		if !ok {
+			continue
+		}
+		if ch.Enabled != nil {
+			enabled = *ch.Enabled
+		}
+		if ch.Knowledge != nil {
+			knowledge = *ch.Knowledge
+		}
+	}
+	return enabled, knowledge
+}
+
+// MonitorsConfig watches web pages, JSON APIs and feeds on a schedule and
+// reports what changed.
+type MonitorsConfig struct {
+	Enabled  bool            `json:"enabled,omitempty"`
+	Agent    string          `json:"agent,omitempty"`    // agent that summarizes changes (default smartDispatch.defaultAgent)
+	Model    string          `json:"model,omitempty"`    // default "haiku"
+	Budget   float64         `json:"budget,omitempty"`   // USD per summary (default 0.05)
+	Interval string          `json:"interval,omitempty"` // default check interval (default "1h", minimum "1m")
+	Notify   []string        `json:"notify,omitempty"`   // default targets for every monitor (default ["owner"])
+	Watch    []MonitorTarget `json:"watch,omitempty"`
+}
+
+// MonitorTarget is one watched URL.
+type MonitorTarget struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type,omitempty"`     // "page" (default), "json" or "rss"
+	URL      string            `json:"url"`
+	Selector string            `json:"selector,omitempty"` // page: CSS selector (default the whole page text)
+	Path     string            `json:"path,omitempty"`     // json: JSONPath, e.g. "$.data.items[0].price"
+	Browser  bool              `json:"browser,omitempty"`  // page: render with the browser plugin instead of fetching HTML
+	Headers  map[string]string `json:"headers,omitempty"`  // request headers; values support $ENV_VAR
+	Interval string            `json:"interval,omitempty"` // overrides the default interval
+	Notify   []string          `json:"notify,omitempty"`   // "owner", "discord:<channelId>", "telegram:<chatId>", "webhook:<notification name>", "dashboard"
+	Prompt   string            `json:"prompt,omitempty"`   // extra instructions for the change summary
+	Enabled  *bool             `json:"enabled,omitempty"`
+}
+
+// IsEnabled reports whether the target is checked (default true).
+func (t MonitorTarget) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// TypeOrDefault returns the target type, or "page".
+func (t MonitorTarget) TypeOrDefault() string {
+	if t.Type != "" {
+		return t.Type
+	}
+	return "page"
+}
+
+// ModelOrDefault returns the summary model, or "haiku".
+func (c MonitorsConfig) ModelOrDefault() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return "haiku"
+}
+
+// BudgetOrDefault returns the per-summary budget, or $0.05.
+func (c MonitorsConfig) BudgetOrDefault() float64 {
+	if c.Budget > 0 {
+		return c.Budget
+	}
+	return 0.05
+}
+
+// IntervalFor returns how often t is checked: its own interval, else the
+// default, else one hour. Intervals under a minute are raised to a minute.
+func (c MonitorsConfig) IntervalFor(t MonitorTarget) time.Duration {
+	d := time.Hour
+	for _, s := range []string{c.Interval, t.Interval} {
+		if v, err := time.ParseDuration(s); err == nil && v > 0 {
+			d = v
+		}
+	}
+	if d < time.Minute {
+		d = time.Minute
+	}
+	return d
+}
+
+// NotifyFor returns where changes to t are reported.
+func (c MonitorsConfig) NotifyFor(t MonitorTarget) []string {
+	if len(t.Notify) > 0 {
+		return t.Notify
+	}
+	if len(c.Notify) > 0 {
+		return c.Notify
+	}
+	return []string{"owner"}
+}
+
+// KnowledgeWatchConfig watches knowledgeDir and vaultDir for changed files,
+// updates the search indexes for just those files, and runs rules on them.
+type KnowledgeWatchConfig struct {
+	Enabled  bool                 `json:"enabled,omitempty"`
+	Interval string               `json:"interval,omitempty"` // poll interval (default "10s", minimum "2s")
+	Vault    *bool                `json:"vault,omitempty"`    // also watch vaultDir's .md files (default true)
+	Notify   []string             `json:"notify,omitempty"`   // default destinations of rules (default ["owner"])
+	Rules    []KnowledgeWatchRule `json:"rules,omitempty"`
+}
+
+// KnowledgeWatchRule acts on changes to matching files.
+type KnowledgeWatchRule struct {
+	Name     string   `json:"name,omitempty"`
+	Match    string   `json:"match"`              // glob on the path under the watched dir, e.g. "meeting-notes.md", "meetings/*.md"
+	Root     string   `json:"root,omitempty"`     // "knowledge" or "vault"; empty = both
+	Events   []string `json:"events,omitempty"`   // "created", "modified", "removed" (default created and modified)
+	Notify   []string `json:"notify,omitempty"`   // "owner", "discord:<channelId>", "telegram:<chatId>", "webhook:<notification name>"
+	Workflow string   `json:"workflow,omitempty"` // workflow run with file, path, event and input (the file's content)
+	Cooldown string   `json:"cooldown,omitempty"` // least time between actions for one file (default "1m")
+}
+
+// IntervalOrDefault returns the poll interval.
+func (c KnowledgeWatchConfig) IntervalOrDefault() time.Duration {
+	d := 10 * time.Second
+	if v, err := time.ParseDuration(c.Interval); err == nil && v > 0 {
+		d = v
+	}
+	if d < 2*time.Second {
+		d = 2 * time.Second
+	}
+	return d
+}
+
+// WatchVault reports whether vaultDir is watched (default true).
+func (c KnowledgeWatchConfig) WatchVault() bool {
+	return c.Vault == nil || *c.Vault
+}
+
+// NotifyFor returns where a rule reports changes: its own destinations,
+// the default ones, or the owner when the rule runs no workflow.
+func (c KnowledgeWatchConfig) NotifyFor(r KnowledgeWatchRule) []string {
+	if len(r.Notify) > 0 {
+		return r.Notify
+	}
+	if len(c.Notify) > 0 {
+		return c.Notify
+	}
+	if r.Workflow != "" {
+		return nil
+	}
+	return []string{"owner"}
+}
+
+// CooldownOrDefault returns the least time between a rule's actions for one file.
+func (r KnowledgeWatchRule) CooldownOrDefault() time.Duration {
+	if d, err := time.ParseDuration(r.Cooldown); err == nil && d >= 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// PipelinesConfig enables scheduled fetch → transform → dispatch pipelines.
+// The pipelines themselves are stored in the history DB and managed through
+// /api/pipelines.
+type PipelinesConfig struct {
+	Enabled bool     `json:"enabled,omitempty"`
+	Agent   string   `json:"agent,omitempty"`  // default agent for dispatch steps (default smartDispatch.defaultAgent)
+	Model   string   `json:"model,omitempty"`  // default model for dispatch steps (default the agent's)
+	Budget  float64  `json:"budget,omitempty"` // default USD budget per dispatch (default the agent's)
+	Notify  []string `json:"notify,omitempty"` // default destinations for results (default ["owner"])
+}
+
+// NotifyOrDefault returns where results go when a pipeline names no destination.
+func (c PipelinesConfig) NotifyOrDefault() []string {
+	if len(c.Notify) > 0 {
+		return c.Notify
+	}
+	return []string{"owner"}
+}
+
 // --- Estimate ---
 
 type EstimateConfig struct {
 	ConfirmThreshold    float64 `json:"confirmThreshold,omitempty"`
 	DefaultOutputTokens int     `json:"defaultOutputTokens,omitempty"`
+
+	// Spend approval: any single task estimated above ApprovalThreshold USD
+	// waits for explicit owner approval before it runs. 0 disables.
+	ApprovalThreshold float64 `json:"approvalThreshold,omitempty"`
+	ApprovalChannel   string  `json:"approvalChannel,omitempty"` // "discord", "telegram"; empty = task's own channel, then first available
+	ApprovalTimeout   int     `json:"approvalTimeout,omitempty"` // seconds; default 600
 }
 
 func (c EstimateConfig) ConfirmThresholdOrDefault() float64 {
@@ -238,10 +807,18 @@ func (c EstimateConfig) DefaultOutputTokensOrDefault() int {
 	return 500
 }
 
+func (c EstimateConfig) ApprovalTimeoutOrDefault() time.Duration {
+	if c.ApprovalTimeout > 0 {
+		return time.Duration(c.ApprovalTimeout) * time.Second
+	}
+	return 10 * time.Minute
+}
+
 // --- Tools ---
 
 type ToolConfig struct {
 	MaxIterations   int                    `json:"maxIterations,omitempty"`
+	MaxCallsPerTask int                    `json:"maxCallsPerTask,omitempty"` // tool calls per task for every agent; 0 = unlimited
 	Timeout         int                    `json:"timeout,omitempty"`
 	Builtin         map[string]bool        `json:"builtin,omitempty"`
 	Profiles        map[string]ToolProfile `json:"profiles,omitempty"`
@@ -280,6 +857,8 @@ type AgentToolPolicy struct {
 	DMProfile    string   `json:"dmProfile,omitempty"`    // override profile for DM context
 	Allow        []string `json:"allow,omitempty"`
 	Deny         []string `json:"deny,omitempty"`
+	Allowlist    []string `json:"allowlist,omitempty"` // only these registry tools, exact or "prefix*"; empty = no restriction
+	MaxCalls     int      `json:"maxCalls,omitempty"`  // tool calls per task; 0 = tools.maxCallsPerTask, -1 = unlimited
 	Sandbox      string   `json:"sandbox,omitempty"`
 	SandboxImage string   `json:"sandboxImage,omitempty"`
 }
@@ -365,6 +944,45 @@ type CompactionConfig struct {
 	//   ""      / "auto"   (default) — compact automatically when threshold is reached
 	//   "notify"           — send a notification to the user instead of compacting automatically
 	Mode string `json:"mode,omitempty"`
+	// Rolling context window: once a session's active messages exceed
+	// TriggerTokens, older turns are folded into the pinned summary and the
+	// last KeepTurns turns stay verbatim. Agents overrides both per agent.
+	TriggerTokens int                         `json:"triggerTokens,omitempty"` // 0 = message and compactTokens thresholds only
+	KeepTurns     int                         `json:"keepTurns,omitempty"`     // default compactKeep messages
+	Agents        map[string]CompactionPolicy `json:"agents,omitempty"`
+}
+
+// CompactionPolicy is when an agent's sessions are summarized and how many
+// recent turns (a user message and its reply) are kept verbatim.
+type CompactionPolicy struct {
+	TriggerTokens int  `json:"triggerTokens,omitempty"`
+	KeepTurns     int  `json:"keepTurns,omitempty"`
+	Disabled      bool `json:"disabled,omitempty"` // never summarize this agent's sessions automatically
+}
+
+// CompactionPolicy returns agent's policy: its override in compaction.agents,
+// then the compaction settings.
+func (c SessionConfig) CompactionPolicy(agent string) CompactionPolicy {
+	p := CompactionPolicy{TriggerTokens: c.Compaction.TriggerTokens, KeepTurns: c.Compaction.KeepTurns}
+	if o, ok := c.Compaction.Agents[agent]; ok {
+		if o.TriggerTokens > 0 {
+			p.TriggerTokens = o.TriggerTokens
+		}
+		if o.KeepTurns > 0 {
+			p.KeepTurns = o.KeepTurns
+		}
+		p.Disabled = o.Disabled
+	}
+	return p
+}
+
+// CompactKeepFor returns how many recent messages compaction keeps verbatim
+// in agent's sessions: two per kept turn, default compactKeep.
+func (c SessionConfig) CompactKeepFor(agent string) int {
+	if p := c.CompactionPolicy(agent); p.KeepTurns > 0 {
+		return 2 * p.KeepTurns
+	}
+	return c.CompactKeepOrDefault()
 }
 
 // --- Logging ---
@@ -642,6 +1260,20 @@ type ApprovalGateConfig struct {
 	Timeout          int      `json:"timeout,omitempty"`
 	Tools            []string `json:"tools,omitempty"`
 	AutoApproveTools []string `json:"autoApproveTools,omitempty"`
+	// ExternalActions lists tools with side effects outside Tetora (sending
+	// mail, posting, unlocking a door). Each call is previewed and approved,
+	// whatever the agent's trust level, enabled or auto-approve settings.
+	// Entries are tool names or prefixes ending in "*".
+	ExternalActions []string `json:"externalActions,omitempty"`
+	Channel         string   `json:"channel,omitempty"` // preferred channel for tasks without a chat ("telegram", "discord")
+}
+
+// TimeoutOrDefault returns how long to wait for an approval (default 120s).
+func (c ApprovalGateConfig) TimeoutOrDefault() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout) * time.Second
+	}
+	return 120 * time.Second
 }
 
 // --- Writing / Citation ---
@@ -699,10 +1331,70 @@ type RSSConfig struct {
 	Feeds   []string `json:"feeds,omitempty"`
 }
 
+// TranslateConfig configures the translate tool, the glossary and channels
+// whose messages are translated for the agents and back.
 type TranslateConfig struct {
-	Enabled  bool   `json:"enabled"`
-	Provider string `json:"provider,omitempty"`
-	APIKey   string `json:"apiKey,omitempty"`
+	Enabled   bool                              `json:"enabled"`
+	Provider  string                            `json:"provider,omitempty"`  // "lingva" (default), "deepl" or "llm"
+	APIKey    string                            `json:"apiKey,omitempty"`    // DeepL key, $ENV_VAR supported
+	Agent     string                            `json:"agent,omitempty"`     // llm: agent that translates (default smartDispatch.defaultAgent)
+	Model     string                            `json:"model,omitempty"`     // llm: default "haiku"
+	Budget    float64                           `json:"budget,omitempty"`    // llm: USD per translation (default 0.02)
+	AgentLang string                            `json:"agentLang,omitempty"` // language agents read and write in translated channels (default "en")
+	Channels  map[string]TranslateChannelConfig `json:"channels,omitempty"`  // "discord:<channelId>", "telegram:<chatId>" or a bare platform
+}
+
+// TranslateChannelConfig turns auto-translation on for one channel or platform.
+type TranslateChannelConfig struct {
+	Enabled   *bool  `json:"enabled,omitempty"`
+	AgentLang string `json:"agentLang,omitempty"`
+	ReplyLang string `json:"replyLang,omitempty"` // language of replies (default: the language of each message)
+}
+
+// ModelOrDefault returns the llm provider's model, or "haiku".
+func (c TranslateConfig) ModelOrDefault() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return "haiku"
+}
+
+// BudgetOrDefault returns the llm provider's budget per translation, or $0.02.
+func (c TranslateConfig) BudgetOrDefault() float64 {
+	if c.Budget > 0 {
+		return c.Budget
+	}
+	return 0.02
+}
+
+// AgentLangOrDefault returns the language agents work in, or "en".
+func (c TranslateConfig) AgentLangOrDefault() string {
+	if c.AgentLang != "" {
+		return c.AgentLang
+	}
+	return "en"
+}
+
+// ForChannel resolves whether a chat is auto-translated and in which
+// languages. A "platform:chatID" entry wins over a bare "platform" entry.
+func (c TranslateConfig) ForChannel(platform, chatID string) (enabled bool, agentLang, replyLang string) {
+	agentLang = c.AgentLangOrDefault()
+	for _, key := range []string{platform, platform + ":" + chatID} {
+		ch, ok := c.Channels[key]
+		if !ok {
+			continue
+		}
+		if ch.Enabled != nil {
+			enabled = *ch.Enabled
+		}
+		if ch.AgentLang != "" {
+			agentLang = ch.AgentLang
+		}
+		if ch.ReplyLang != "" {
+			replyLang = ch.ReplyLang
+		}
+	}
+	return enabled && c.Enabled, agentLang, replyLang
 }
 
 // --- UserProfile ---
@@ -716,13 +1408,15 @@ type UserProfileConfig struct {
 // --- Ops ---
 
 type OpsConfig struct {
-	BackupSchedule string             `json:"backupSchedule,omitempty"`
-	BackupRetain   int                `json:"backupRetain,omitempty"`
-	BackupDir      string             `json:"backupDir,omitempty"`
-	HealthNotify   bool               `json:"healthNotify,omitempty"`
-	HealthCheckURL string             `json:"healthCheckUrl,omitempty"`
-	ExportEnabled  bool               `json:"exportEnabled,omitempty"`
-	MessageQueue   MessageQueueConfig `json:"messageQueue,omitempty"`
+	BackupSchedule string               `json:"backupSchedule,omitempty"`
+	BackupRetain   int                  `json:"backupRetain,omitempty"`
+	BackupDir      string               `json:"backupDir,omitempty"`
+	HealthNotify   bool                 `json:"healthNotify,omitempty"`
+	HealthCheckURL string               `json:"healthCheckUrl,omitempty"`
+	ExportEnabled  bool                 `json:"exportEnabled,omitempty"`
+	MessageQueue   MessageQueueConfig   `json:"messageQueue,omitempty"`
+	BackupRemotes  []BackupRemoteConfig `json:"backupRemotes,omitempty"`
+	Replication    ReplicationConfig    `json:"replication,omitempty"`
 }
 
 func (c OpsConfig) BackupRetainOrDefault() int {
@@ -739,6 +1433,67 @@ func (c OpsConfig) BackupDirResolved(baseDir string) string {
 	return baseDir + "/backups"
 }
 
+// ReplicationConfig streams history DB changes to a backup remote or a
+// directory for point-in-time restore.
+type ReplicationConfig struct {
+	Enabled          bool   `json:"enabled"`
+	Remote           string `json:"remote,omitempty"`           // name in backupRemotes
+	Path             string `json:"path,omitempty"`             // directory instead of a remote
+	Interval         string `json:"interval,omitempty"`         // default "10s"
+	SnapshotInterval string `json:"snapshotInterval,omitempty"` // default "24h"
+	RetainDays       int    `json:"retainDays,omitempty"`       // default: backupRetain
+}
+
+// IntervalOrDefault returns how often changes are shipped (default 10s).
+func (c ReplicationConfig) IntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+// SnapshotIntervalOrDefault returns how often a new snapshot starts a new
+// generation (default 24h).
+func (c ReplicationConfig) SnapshotIntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.SnapshotInterval); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// BackupRemoteConfig is an object-storage bucket each backup is copied to.
+type BackupRemoteConfig struct {
+	Name string `json:"name,omitempty"` // default: the bucket name
+	ObjectStoreConfig
+	RetainDays        int  `json:"retainDays,omitempty"`        // default: backupRetain
+	Outputs           bool `json:"outputs,omitempty"`           // also mirror the outputs directory
+	OutputsRetainDays int  `json:"outputsRetainDays,omitempty"` // 0 keeps mirrored outputs
+}
+
+// NameOrBucket returns the remote's name, or its bucket when unnamed.
+func (c BackupRemoteConfig) NameOrBucket() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Bucket
+}
+
+// ObjectStoreConfig points at a bucket in S3, an S3-compatible store
+// (Backblaze B2, Cloudflare R2, MinIO) or Google Cloud Storage.
+type ObjectStoreConfig struct {
+	Provider     string `json:"provider,omitempty"` // "s3" (default), "b2" or "gcs"
+	Endpoint     string `json:"endpoint,omitempty"` // S3-compatible endpoint URL; default from provider
+	Region       string `json:"region,omitempty"`
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix,omitempty"`
+	AccessKey    string `json:"accessKey,omitempty"`    // $ENV_VAR supported; an HMAC key for GCS
+	SecretKey    string `json:"secretKey,omitempty"`    // $ENV_VAR supported
+	OAuthService string `json:"oauthService,omitempty"` // GCS: oauth service whose token is used instead of HMAC keys
+	Encryption   string `json:"encryption,omitempty"`   // server-side encryption: "AES256" or "aws:kms"
+	KMSKeyID     string `json:"kmsKeyId,omitempty"`     // KMS key for "aws:kms", or a Cloud KMS key name for GCS
+	CustomerKey  string `json:"customerKey,omitempty"`  // base64 AES-256 key for customer-provided encryption; $ENV_VAR supported
+}
+
 type MessageQueueConfig struct {
 	Enabled       bool   `json:"enabled"`
 	RetryAttempts int    `json:"retryAttempts,omitempty"`
@@ -996,6 +1751,7 @@ type SandboxConfig struct {
 	MemLimit     string `json:"memLimit,omitempty"`
 	CPULimit     string `json:"cpuLimit,omitempty"`
 	Network      string `json:"network,omitempty"`
+	MaxOutput    int    `json:"maxOutputBytes,omitempty"` // per-stream exec output cap; 0 = plugin default (1 MiB)
 }
 
 func (c SandboxConfig) DefaultImageOrDefault() string {
@@ -1021,6 +1777,24 @@ type PluginConfig struct {
 	Env       map[string]string `json:"env,omitempty"`
 	AutoStart bool              `json:"autoStart,omitempty"`
 	Tools     []string          `json:"tools,omitempty"`
+
+	// Runtime selects how the plugin runs: "process" (default, stdio
+	// subprocess) or "wasm" (in-process WebAssembly module).
+	Runtime  string `json:"runtime,omitempty"`
+	Module   string `json:"module,omitempty"`   // wasm: path to the .wasm file
+	Manifest string `json:"manifest,omitempty"` // wasm: capability manifest; default <module>.json
+
+	// Set by "tetora plugin install": the installed package version, where
+	// it came from (manifest URL/path or registry name), and whether
+	// "tetora plugin upgrade" must leave it at this version.
+	Version string `json:"version,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Pinned  bool   `json:"pinned,omitempty"`
+}
+
+// IsWasm reports whether the plugin runs as a WebAssembly module.
+func (c PluginConfig) IsWasm() bool {
+	return c.Runtime == "wasm"
 }
 
 // OAuth.
@@ -1040,6 +1814,32 @@ type OAuthServiceConfig struct {
 	Scopes       []string          `json:"scopes"`
 	RedirectURL  string            `json:"redirectUrl,omitempty"`
 	ExtraParams  map[string]string `json:"extraParams,omitempty"`
+	TokenAuth    string            `json:"tokenAuth,omitempty"` // "basic" sends client credentials as HTTP Basic auth
+}
+
+// Encryption at rest.
+
+// EncryptionConfig turns on envelope encryption of session messages,
+// memory values, OAuth tokens and uploaded files. Data keys are kept in the
+// history DB, wrapped with the master key.
+type EncryptionConfig struct {
+	MasterKey        string `json:"masterKey,omitempty"`        // $ENV_VAR supported
+	MasterKeyFile    string `json:"masterKeyFile,omitempty"`    // file holding the master key, e.g. a mounted secret
+	SealUploadsAfter string `json:"sealUploadsAfter,omitempty"` // default "1h"
+}
+
+// Enabled reports whether a master key is configured.
+func (c EncryptionConfig) Enabled() bool {
+	return c.MasterKey != "" || c.MasterKeyFile != ""
+}
+
+// SealUploadsAfterOrDefault returns how long uploads stay readable by the
+// task that received them before they are encrypted (default 1h).
+func (c EncryptionConfig) SealUploadsAfterOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.SealUploadsAfter); err == nil && d >= 0 {
+		return d
+	}
+	return time.Hour
 }
 
 // Embedding.
@@ -1148,6 +1948,81 @@ func (c InjectionDefenseConfig) CacheTTLOrDefault() time.Duration {
 type SecurityConfig struct {
 	InjectionDefense InjectionDefenseConfig `json:"injectionDefense,omitempty"`
 	DangerousOps     DangerousOpsConfig     `json:"dangerousOps,omitempty"`
+	Redaction        RedactionConfig        `json:"redaction,omitempty"`
+	AuditExport      AuditExportConfig      `json:"auditExport,omitempty"`
+}
+
+// AuditExportConfig forwards audit log entries to external collectors such
+// as a SIEM, in addition to the local audit_log table.
+type AuditExportConfig struct {
+	Enabled  bool              `json:"enabled,omitempty"`
+	Sinks    []AuditSinkConfig `json:"sinks,omitempty"`
+	SpoolDir string            `json:"spoolDir,omitempty"` // default {baseDir}/audit-spool
+}
+
+// AuditSinkConfig is one collector. Entries that cannot be delivered after
+// retries are spooled to disk and resent once the sink is back.
+type AuditSinkConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "syslog" or "http"
+
+	// syslog: RFC 5424 over TCP with octet-counting framing.
+	Address  string `json:"address,omitempty"`  // host:port
+	TLS      bool   `json:"tls,omitempty"`      // syslog over TLS (RFC 5425)
+	CAFile   string `json:"caFile,omitempty"`   // extra CA for the collector's certificate
+	Facility int    `json:"facility,omitempty"` // default 13 (log audit)
+
+	// http: batched POST.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"` // values may be $ENV refs
+	Index   string            `json:"index,omitempty"`   // elastic index / splunk sourcetype; default "tetora-audit"
+
+	// Format: syslog "rfc5424" (default) or "cef"; http "json" (default,
+	// a JSON array), "splunk" (HEC events) or "elastic" (_bulk).
+	Format string `json:"format,omitempty"`
+
+	Actions        []string `json:"actions,omitempty"`        // action prefixes to send; empty = all
+	ExcludeActions []string `json:"excludeActions,omitempty"` // action prefixes never sent
+
+	BatchSize     int    `json:"batchSize,omitempty"`     // default 100
+	FlushInterval string `json:"flushInterval,omitempty"` // default "5s"
+	MaxSpoolMB    int    `json:"maxSpoolMB,omitempty"`    // default 64
+}
+
+// RedactionConfig scrubs personal data and secrets from prompts and outputs
+// before they are stored or sent to notification channels.
+type RedactionConfig struct {
+	Enabled   bool               `json:"enabled,omitempty"`
+	Detectors []string           `json:"detectors,omitempty"` // built-ins: "email", "phone", "apiKey", "creditCard"; empty = all
+	Patterns  []RedactionPattern `json:"patterns,omitempty"`  // extra regexes; retention.piiPatterns are added too
+	Targets   []string           `json:"targets,omitempty"`   // "history", "sessions", "audit", "notifications"; empty = all
+	Bypass    []string           `json:"bypass,omitempty"`    // agents whose content is kept unredacted
+}
+
+// RedactionPattern is a custom redaction rule. Matches are replaced with
+// "[REDACTED:<name>]".
+type RedactionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Targets the redaction pipeline can apply to.
+var RedactionTargets = []string{"history", "sessions", "audit", "notifications"}
+
+// Applies reports whether redaction is on for target.
+func (c RedactionConfig) Applies(target string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Targets) == 0 {
+		return true
+	}
+	for _, t := range c.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
 }
 
 // Trust.
@@ -1181,6 +2056,7 @@ type TaskBoardConfig struct {
 	GitWorkflow     GitWorkflowConfig       `json:"gitWorkflow,omitempty"`
 	IdleAnalyze     bool                    `json:"idleAnalyze,omitempty"`
 	ProblemScan     bool                    `json:"problemScan,omitempty"`
+	Sync            TaskSyncConfig          `json:"sync,omitempty"`
 }
 
 func (c TaskBoardConfig) MaxRetriesOrDefault() int {
@@ -1197,7 +2073,54 @@ func (c TaskBoardConfig) MaxExecutionsOrDefault() int {
 	return 3
 }
 
-type ReviewConfig struct {
+// TaskSyncConfig keeps taskboard tasks in step with Jira or Linear issues:
+// new tasks become tickets, and title and status changes flow both ways.
+type TaskSyncConfig struct {
+	Provider  string            `json:"provider,omitempty"`  // "jira" or "linear"; empty turns sync off
+	URL       string            `json:"url,omitempty"`       // Jira site, e.g. https://acme.atlassian.net (linear: API base, default https://api.linear.app)
+	Email     string            `json:"email,omitempty"`     // Jira Cloud account email; without it token is a Server/Data Center personal access token
+	Token     string            `json:"token,omitempty"`     // Jira API token or Linear API key; $ENV_VAR supported
+	Project   string            `json:"project,omitempty"`   // Jira project key or Linear team key
+	IssueType string            `json:"issueType,omitempty"` // Jira issue type of new tickets (default "Task")
+	Projects  []string          `json:"projects,omitempty"`  // taskboard projects synced (default all)
+	Statuses  map[string]string `json:"statuses,omitempty"`  // taskboard status -> tracker status name, e.g. "review": "In Review"
+	Interval  string            `json:"interval,omitempty"`  // time between syncs (default "5m")
+	Conflict  string            `json:"conflict,omitempty"`  // when both sides changed: "newest" (default), "tetora" or "tracker"
+}
+
+// Enabled reports whether a tracker is configured.
+func (c TaskSyncConfig) Enabled() bool { return c.Provider != "" }
+
+// IntervalOrDefault returns the time between syncs.
+func (c TaskSyncConfig) IntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d >= time.Minute {
+		return d
+	}
+	return 5 * time.Minute
+}
+
+// ConflictOrDefault returns the conflict policy.
+func (c TaskSyncConfig) ConflictOrDefault() string {
+	if c.Conflict == "" {
+		return "newest"
+	}
+	return c.Conflict
+}
+
+// IssueTypeOrDefault returns the Jira issue type of new tickets.
+func (c TaskSyncConfig) IssueTypeOrDefault() string {
+	if c.IssueType == "" {
+		return "Task"
+	}
+	return c.IssueType
+}
+
+// Syncs reports whether tasks of a taskboard project are synced.
+func (c TaskSyncConfig) Syncs(project string) bool {
+	return len(c.Projects) == 0 || slices.Contains(c.Projects, project)
+}
+
+type ReviewConfig struct {
 	Queues       map[string][]string `json:"queues,omitempty"`
 	DefaultAgent string              `json:"defaultAgent,omitempty"`
 	MaxDiffLines int                 `json:"maxDiffLines,omitempty"`
@@ -1323,6 +2246,23 @@ type TriggerSpec struct {
 	TZ      string `json:"tz,omitempty"`
 	Event   string `json:"event,omitempty"`
 	Webhook string `json:"webhook,omitempty"`
+
+	// File triggers: path globs such as "~/Inbox/*.pdf" or
+	// "/data/drop/**/*.csv", the events that fire ("create", "modify",
+	// "delete"; default create and modify), and how long a file must go
+	// unchanged before it fires (default "2s").
+	Paths    []string `json:"paths,omitempty"`
+	Events   []string `json:"events,omitempty"`
+	Debounce string   `json:"debounce,omitempty"`
+}
+
+// DebounceOrDefault is how long a watched file must go unchanged before a
+// file trigger fires.
+func (t TriggerSpec) DebounceOrDefault() time.Duration {
+	if d, err := time.ParseDuration(t.Debounce); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Second
 }
 
 // Workspace.
@@ -1334,6 +2274,16 @@ type WorkspaceConfig struct {
 	Sandbox    *SandboxMode `json:"sandbox,omitempty"`
 }
 
+// Multi-workspace.
+
+// TenantConfig binds a named workspace to an isolated client. Requests are
+// routed to it by bearer token or by the channel they arrive on.
+type TenantConfig struct {
+	ClientID string   `json:"clientId,omitempty"` // defaults to "cli_<name>"
+	APIToken string   `json:"apiToken,omitempty"` // bearer token scoped to this workspace
+	Channels []string `json:"channels,omitempty"` // "discord:<channelID>"
+}
+
 type SandboxMode struct {
 	Mode string `json:"mode"`
 }
@@ -1341,9 +2291,43 @@ type SandboxMode struct {
 // Misc service configs.
 
 type OfflineQueueConfig struct {
-	Enabled  bool   `json:"enabled,omitempty"`
-	TTL      string `json:"ttl,omitempty"`
-	MaxItems int    `json:"maxItems,omitempty"`
+	Enabled     bool           `json:"enabled,omitempty"`
+	TTL         string         `json:"ttl,omitempty"`
+	MaxItems    int            `json:"maxItems,omitempty"`
+	DedupWindow string         `json:"dedupWindow,omitempty"` // same agent+prompt queued again within this folds into the pending item (default "10m", "0" = off)
+	Concurrency int            `json:"concurrency,omitempty"` // items drained at once (default 3)
+	PerAgent    int            `json:"perAgent,omitempty"`    // items drained at once per agent (default 1)
+	AgentLimits map[string]int `json:"agentLimits,omitempty"` // per-agent overrides of perAgent
+}
+
+// DedupWindowOrDefault returns the dedup window (default 10m; 0 disables).
+func (c OfflineQueueConfig) DedupWindowOrDefault() time.Duration {
+	if c.DedupWindow != "" {
+		if d, err := time.ParseDuration(c.DedupWindow); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 10 * time.Minute
+}
+
+// ConcurrencyOrDefault returns how many items are drained at once (default 3).
+func (c OfflineQueueConfig) ConcurrencyOrDefault() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return 3
+}
+
+// AgentLimit returns how many of agent's items are drained at once
+// (agentLimits, then perAgent, default 1).
+func (c OfflineQueueConfig) AgentLimit(agent string) int {
+	if n := c.AgentLimits[agent]; n > 0 {
+		return n
+	}
+	if c.PerAgent > 0 {
+		return c.PerAgent
+	}
+	return 1
 }
 
 // TtlOrDefault returns the TTL duration (default 1h).
@@ -1364,6 +2348,335 @@ func (c OfflineQueueConfig) MaxItemsOrDefault() int {
 	return 100
 }
 
+// WorkQueueConfig turns on the shared work queue for running several
+// daemons against one Redis: tasks enqueued on any node are claimed by an
+// idle node under a lease that its heartbeats keep alive, and re-delivered
+// when the lease runs out.
+type WorkQueueConfig struct {
+	Enabled     bool   `json:"enabled,omitempty"`
+	Backend     string `json:"backend,omitempty"`     // "redis" (only backend for now)
+	Addr        string `json:"addr,omitempty"`        // default "127.0.0.1:6379"
+	Password    string `json:"password,omitempty"`    // $ENV_VAR supported
+	DB          int    `json:"db,omitempty"`
+	TLS         bool   `json:"tls,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`      // key prefix (default "tetora:wq")
+	Node        string `json:"node,omitempty"`        // this node's name (default hostname)
+	Concurrency int    `json:"concurrency,omitempty"` // tasks this node claims at once (default 1)
+	Lease       string `json:"lease,omitempty"`       // default "2m"
+	Heartbeat   string `json:"heartbeat,omitempty"`   // lease renewal interval (default lease/3)
+	MaxAttempts int    `json:"maxAttempts,omitempty"` // deliveries before a task is dead (default 3)
+	ResultTTL   string `json:"resultTtl,omitempty"`   // how long finished tasks are kept (default "24h")
+}
+
+// AddrOrDefault returns the Redis address (default 127.0.0.1:6379).
+func (c WorkQueueConfig) AddrOrDefault() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	return "127.0.0.1:6379"
+}
+
+// PrefixOrDefault returns the key prefix (default "tetora:wq").
+func (c WorkQueueConfig) PrefixOrDefault() string {
+	if c.Prefix != "" {
+		return strings.TrimSuffix(c.Prefix, ":")
+	}
+	return "tetora:wq"
+}
+
+// NodeOrDefault returns this node's name (default the hostname).
+func (c WorkQueueConfig) NodeOrDefault() string {
+	if c.Node != "" {
+		return c.Node
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "tetora"
+}
+
+// ConcurrencyOrDefault returns how many tasks this node runs at once (default 1).
+func (c WorkQueueConfig) ConcurrencyOrDefault() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return 1
+}
+
+// LeaseOrDefault returns the lease duration (default 2m, at least 5s).
+func (c WorkQueueConfig) LeaseOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Lease); err == nil && d > 0 {
+		return max(d, 5*time.Second)
+	}
+	return 2 * time.Minute
+}
+
+// HeartbeatOrDefault returns the lease renewal interval (default a third of
+// the lease, never more than half of it).
+func (c WorkQueueConfig) HeartbeatOrDefault() time.Duration {
+	lease := c.LeaseOrDefault()
+	if d, err := time.ParseDuration(c.Heartbeat); err == nil && d > 0 {
+		return min(d, lease/2)
+	}
+	return lease / 3
+}
+
+// MaxAttemptsOrDefault returns how many times a task is delivered (default 3).
+func (c WorkQueueConfig) MaxAttemptsOrDefault() int {
+	if c.MaxAttempts > 0 {
+		return c.MaxAttempts
+	}
+	return 3
+}
+
+// ResultTTLOrDefault returns how long finished tasks are kept (default 24h).
+func (c WorkQueueConfig) ResultTTLOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.ResultTTL); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// HAConfig runs two daemons as active and standby. Both compete for a lease
+// (a shared file or a row in the history DB): the holder is active, the other
+// serves a read-only API and is promoted when the holder stops renewing.
+type HAConfig struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	Node       string `json:"node,omitempty"`       // this daemon's name (default hostname:port)
+	Lock       string `json:"lock,omitempty"`       // "file" (default) or "db"
+	LockFile   string `json:"lockFile,omitempty"`   // lease file for "file" (default <baseDir>/leader.json)
+	Heartbeat  string `json:"heartbeat,omitempty"`  // lease renewal interval (default "5s")
+	StaleAfter string `json:"staleAfter,omitempty"` // a lease not renewed for this long is taken over (default "30s")
+}
+
+// NodeOrDefault returns this daemon's name (default hostname:port of listenAddr).
+func (c HAConfig) NodeOrDefault(listenAddr string) string {
+	if c.Node != "" {
+		return c.Node
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "tetora"
+	}
+	if _, port, err := net.SplitHostPort(listenAddr); err == nil && port != "" {
+		return host + ":" + port
+	}
+	return host
+}
+
+// LockFileOrDefault returns the lease file path.
+func (c HAConfig) LockFileOrDefault(baseDir string) string {
+	if c.LockFile != "" {
+		return c.LockFile
+	}
+	return filepath.Join(baseDir, "leader.json")
+}
+
+// HeartbeatOrDefault returns the lease renewal interval (default 5s, at least 1s).
+func (c HAConfig) HeartbeatOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Heartbeat); err == nil && d > 0 {
+		return max(d, time.Second)
+	}
+	return 5 * time.Second
+}
+
+// StaleAfterOrDefault returns how long an unrenewed lease stays held
+// (default 30s, at least three heartbeats).
+func (c HAConfig) StaleAfterOrDefault() time.Duration {
+	hb := c.HeartbeatOrDefault()
+	if d, err := time.ParseDuration(c.StaleAfter); err == nil && d > 0 {
+		return max(d, 3*hb)
+	}
+	return max(30*time.Second, 3*hb)
+}
+
+// ReplicaConfig runs this daemon as a read-only replica of a primary: the
+// primary's history DB is copied on a schedule and served read-only, and
+// nothing that does work is started.
+type ReplicaConfig struct {
+	Primary      string `json:"primary,omitempty"`      // the primary's URL; empty = not a replica
+	PrimaryToken string `json:"primaryToken,omitempty"` // the primary's apiToken, $ENV_VAR supported
+	PollInterval string `json:"pollInterval,omitempty"` // default "1m", min "10s"
+}
+
+// Enabled reports whether this daemon is a replica.
+func (c ReplicaConfig) Enabled() bool { return c.Primary != "" }
+
+// PollIntervalOrDefault returns how often the primary is checked for changes
+// (default 1m, at least 10s).
+func (c ReplicaConfig) PollIntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.PollInterval); err == nil && d > 0 {
+		return max(d, 10*time.Second)
+	}
+	return time.Minute
+}
+
+// SyncConfig syncs memory and personal data with a second instance (e.g. a
+// server and a laptop). Records are sealed with a key derived from a shared
+// passphrase, so only the two instances can read them. The instance with a
+// peer starts each exchange; the other only answers.
+type SyncConfig struct {
+	Enabled   bool     `json:"enabled,omitempty"`
+	Peer      string   `json:"peer,omitempty"`      // the other instance's URL; empty = only answer its exchanges
+	PeerToken string   `json:"peerToken,omitempty"` // the other instance's apiToken, $ENV_VAR supported
+	Key       string   `json:"key,omitempty"`       // shared passphrase, $ENV_VAR supported
+	Direction string   `json:"direction,omitempty"` // "both" (default), "push" or "pull", seen from this instance
+	Interval  string   `json:"interval,omitempty"`  // default "15m", min "1m"
+	Datasets  []string `json:"datasets,omitempty"`  // "memory", "profile", "contacts", "habits" (default all)
+}
+
+// DirectionOrDefault returns which way this instance syncs.
+func (c SyncConfig) DirectionOrDefault() string {
+	switch c.Direction {
+	case "push", "pull":
+		return c.Direction
+	}
+	return "both"
+}
+
+// IntervalOrDefault returns how often the peer is synced (default 15m, at
+// least 1m).
+func (c SyncConfig) IntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return max(d, time.Minute)
+	}
+	return 15 * time.Minute
+}
+
+// DatasetsOrDefault returns the synced datasets.
+func (c SyncConfig) DatasetsOrDefault() []string {
+	if len(c.Datasets) > 0 {
+		return c.Datasets
+	}
+	return []string{"memory", "profile", "contacts", "habits"}
+}
+
+// MirrorConfig keeps agents, prompts, skills and workflows the same on two
+// Tetora instances (a desktop and a server) over the API. Every item has a
+// hash of its content; comparing both sides with the hash they last agreed
+// on tells a change on one side from a conflicting change on both. The
+// instance with a peer starts each sync; the other only answers.
+type MirrorConfig struct {
+	Enabled   bool     `json:"enabled,omitempty"`
+	Peer      string   `json:"peer,omitempty"`      // the other instance's URL; empty = only answer its syncs
+	PeerToken string   `json:"peerToken,omitempty"` // the other instance's apiToken, $ENV_VAR supported
+	Direction string   `json:"direction,omitempty"` // "both" (default), "push" or "pull", seen from this instance
+	Interval  string   `json:"interval,omitempty"`  // time between syncs, min "1m"; empty = only "tetora mirror sync"
+	Kinds     []string `json:"kinds,omitempty"`     // "agents", "prompts", "skills", "workflows" (default all)
+	Conflict  string   `json:"conflict,omitempty"`  // items changed on both sides: "skip" (default, reported), "local" or "remote"
+}
+
+// DirectionOrDefault returns which way this instance mirrors.
+func (c MirrorConfig) DirectionOrDefault() string {
+	switch c.Direction {
+	case "push", "pull":
+		return c.Direction
+	}
+	return "both"
+}
+
+// IntervalOrDefault returns the time between syncs, or 0 when the peer is
+// only synced on demand.
+func (c MirrorConfig) IntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return max(d, time.Minute)
+	}
+	return 0
+}
+
+// KindsOrDefault returns the mirrored kinds of item.
+func (c MirrorConfig) KindsOrDefault() []string {
+	if len(c.Kinds) > 0 {
+		return c.Kinds
+	}
+	return []string{"agents", "prompts", "skills", "workflows"}
+}
+
+// ConflictOrDefault returns what happens to items changed on both sides.
+func (c MirrorConfig) ConflictOrDefault() string {
+	switch c.Conflict {
+	case "local", "remote":
+		return c.Conflict
+	}
+	return "skip"
+}
+
+// GitOpsConfig reconciles this instance from a git repository holding its
+// config, souls, prompts, skills and workflows. The repo is laid out like the
+// Tetora directory: config.json (or .yaml, .toml), config.d/, agents/<name>/,
+// prompts/, skills/<name>/ and workflows/. Each new commit is validated,
+// snapshotted and applied as a whole; local edits to the files it manages are
+// reported as drift.
+type GitOpsConfig struct {
+	Enabled       bool   `json:"enabled,omitempty"`
+	Repo          string `json:"repo,omitempty"`          // clone URL or local path
+	Branch        string `json:"branch,omitempty"`        // default "main"
+	Path          string `json:"path,omitempty"`          // directory in the repo with the files; default the root
+	Token         string `json:"token,omitempty"`         // HTTPS access token for a private repo, $ENV_VAR supported
+	Interval      string `json:"interval,omitempty"`      // poll interval, min "30s" (default "5m"); "off" = webhook only
+	WebhookSecret string `json:"webhookSecret,omitempty"` // secret of POST /api/gitops/webhook, $ENV_VAR supported
+	SelfHeal      bool   `json:"selfHeal,omitempty"`      // re-apply the repo over local edits instead of only reporting them
+}
+
+// BranchOrDefault returns the branch that is applied.
+func (c GitOpsConfig) BranchOrDefault() string {
+	if c.Branch != "" {
+		return c.Branch
+	}
+	return "main"
+}
+
+// IntervalOrDefault returns the poll interval, or 0 when the repo is only
+// fetched on webhook deliveries and manual syncs.
+func (c GitOpsConfig) IntervalOrDefault() time.Duration {
+	if c.Interval == "off" {
+		return 0
+	}
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return max(d, 30*time.Second)
+	}
+	return 5 * time.Minute
+}
+
+// ResponseCacheConfig bounds the response cache. Caching is opt-in per agent
+// (agents.<name>.responseCache) or per cron job (task.responseCache).
+type ResponseCacheConfig struct {
+	TTL        string `json:"ttl,omitempty"`        // default TTL of "on" settings (default "1h")
+	MaxEntries int    `json:"maxEntries,omitempty"` // entries kept, least recently used evicted first (default 500)
+}
+
+// TTLOrDefault returns the TTL used by settings of "on".
+func (c ResponseCacheConfig) TTLOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.TTL); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// MaxEntriesOrDefault returns how many responses are kept.
+func (c ResponseCacheConfig) MaxEntriesOrDefault() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return 500
+}
+
+// TTLFor resolves a per-agent or per-job responseCache setting: "on" (or
+// "true") caches for the default TTL, a duration such as "6h" for that long,
+// and "", "off" or anything unparseable not at all.
+func (c ResponseCacheConfig) TTLFor(setting string) (time.Duration, bool) {
+	switch strings.ToLower(strings.TrimSpace(setting)) {
+	case "", "off", "false":
+		return 0, false
+	case "on", "true":
+		return c.TTLOrDefault(), true
+	}
+	if d, err := time.ParseDuration(setting); err == nil && d > 0 {
+		return d, true
+	}
+	return 0, false
+}
+
 type ReflectionConfig struct {
 	Enabled       bool    `json:"enabled"`
 	TriggerOnFail bool    `json:"triggerOnFail,omitempty"`
@@ -1378,6 +2691,13 @@ func (c ReflectionConfig) MinCostOrDefault() float64 {
 	return 0.03
 }
 
+// FeedbackConfig controls how the owner rates task results. Ratings are
+// always accepted through the API and CLI; Buttons also appends thumbs
+// up/down buttons to chat replies.
+type FeedbackConfig struct {
+	Buttons bool `json:"buttons,omitempty"`
+}
+
 // DeepMemoryExtractConfig controls automatic knowledge extraction after high-quality tasks.
 type DeepMemoryExtractConfig struct {
 	Enabled            bool    `json:"enabled"`
@@ -1423,6 +2743,94 @@ func (c *DeepMemoryExtractConfig) DailyBudgetOrDefault() float64 {
 	return c.DailyBudgetUSD
 }
 
+// MemoryConfig controls how memory entries age. Entries may carry an expiry
+// and a confidence score; expired or low-confidence entries stay on disk but
+// are no longer injected into prompts.
+type MemoryConfig struct {
+	DefaultTTLDays int                `json:"defaultTTLDays,omitempty"` // expiry given to new entries (default none)
+	MinConfidence  float64            `json:"minConfidence,omitempty"`  // entries below this are not injected (default 0.5)
+	Review         MemoryReviewConfig `json:"review,omitempty"`
+}
+
+// MemoryReviewConfig schedules the staleness review: an agent re-validates
+// entries that have not been checked for a while. Changes to important
+// entries wait for the owner's confirmation.
+type MemoryReviewConfig struct {
+	Enabled    bool    `json:"enabled,omitempty"`
+	Agent      string  `json:"agent,omitempty"`      // reviewing agent (default smartDispatch.defaultAgent)
+	Model      string  `json:"model,omitempty"`      // default "haiku"
+	Budget     float64 `json:"budget,omitempty"`     // USD per review run (default 0.10)
+	Interval   string  `json:"interval,omitempty"`   // time between runs (default "24h", minimum "1h")
+	MinAgeDays int     `json:"minAgeDays,omitempty"` // entries unchecked for this long are reviewed (default 30)
+	MaxPerRun  int     `json:"maxPerRun,omitempty"`  // entries per run (default 10)
+	Confirm    string  `json:"confirm,omitempty"`    // changes needing confirmation: "important" (P0, default), "all" or "none"
+}
+
+// MinConfidenceOrDefault returns the injection threshold, or 0.5.
+func (c MemoryConfig) MinConfidenceOrDefault() float64 {
+	if c.MinConfidence > 0 {
+		return c.MinConfidence
+	}
+	return 0.5
+}
+
+// ModelOrDefault returns the review model, or "haiku".
+func (c MemoryReviewConfig) ModelOrDefault() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return "haiku"
+}
+
+// BudgetOrDefault returns the per-run budget, or $0.10.
+func (c MemoryReviewConfig) BudgetOrDefault() float64 {
+	if c.Budget > 0 {
+		return c.Budget
+	}
+	return 0.10
+}
+
+// IntervalOrDefault returns the time between runs, at least an hour.
+func (c MemoryReviewConfig) IntervalOrDefault() time.Duration {
+	d := 24 * time.Hour
+	if v, err := time.ParseDuration(c.Interval); err == nil && v > 0 {
+		d = v
+	}
+	if d < time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// MinAgeOrDefault returns how long an entry goes unchecked before review.
+func (c MemoryReviewConfig) MinAgeOrDefault() time.Duration {
+	days := c.MinAgeDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// MaxPerRunOrDefault returns the number of entries reviewed per run, or 10.
+func (c MemoryReviewConfig) MaxPerRunOrDefault() int {
+	if c.MaxPerRun > 0 {
+		return c.MaxPerRun
+	}
+	return 10
+}
+
+// NeedsConfirm reports whether a change to an entry of the given priority
+// waits for the owner.
+func (c MemoryReviewConfig) NeedsConfirm(priority string) bool {
+	switch c.Confirm {
+	case "none":
+		return false
+	case "all":
+		return true
+	}
+	return priority == "P0"
+}
+
 type NotifyIntelConfig struct {
 	BatchInterval string `json:"notifyBatch,omitempty"`
 }
@@ -1434,12 +2842,50 @@ type IncomingWebhookConfig struct {
 	Filter   string `json:"filter,omitempty"`
 	Workflow string `json:"workflow,omitempty"`
 	Enabled  *bool  `json:"enabled,omitempty"`
+
+	// Transform extracts named variables from the payload, usable as
+	// {{vars.name}} in the template and as vars.name in the filter.
+	Transform map[string]string `json:"transform,omitempty"` // name → JMESPath or jq-style (".a.b") expression
+
+	ResponseMode string                   `json:"responseMode,omitempty"` // "async" (default) or "sync": wait for the result
+	MaxWait      string                   `json:"maxWait,omitempty"`      // sync: longest wait, e.g. "45s" (default 30s, max 10m)
+	Response     *IncomingWebhookResponse `json:"response,omitempty"`     // sync: response mapping; default is the JSON result
+}
+
+// IncomingWebhookResponse shapes the reply of a sync incoming webhook.
+type IncomingWebhookResponse struct {
+	Status      int    `json:"status,omitempty"`      // HTTP status when the task succeeded (default 200)
+	ErrorStatus int    `json:"errorStatus,omitempty"` // HTTP status when it failed (default 500)
+	ContentType string `json:"contentType,omitempty"` // default "application/json"
+	Body        string `json:"body"`                  // template with {{result.xxx}} and {{payload.xxx}}
 }
 
 func (c IncomingWebhookConfig) IsEnabled() bool {
 	return c.Enabled == nil || *c.Enabled
 }
 
+// IsSync reports whether the webhook waits for the task result.
+func (c IncomingWebhookConfig) IsSync() bool {
+	return c.ResponseMode == "sync"
+}
+
+// MaxWaitDuration returns how long a sync webhook waits for the result.
+func (c IncomingWebhookConfig) MaxWaitDuration() time.Duration {
+	d, err := time.ParseDuration(c.MaxWait)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return min(d, 10*time.Minute)
+}
+
+// JobRetention is a cron job's own retention for its runs. It overrides
+// retention.outputs for the job's output files, and can pin the runs worth
+// keeping past retention.outputs and retention.history.
+type JobRetention struct {
+	Outputs   int `json:"outputs,omitempty"`   // days to keep the job's output files; 0 = retention.outputs
+	PinMonths int `json:"pinMonths,omitempty"` // keep the last successful run and every failed run for N months
+}
+
 type RetentionConfig struct {
 	History        int      `json:"history,omitempty"`
 	Sessions       int      `json:"sessions,omitempty"`
@@ -1449,6 +2895,7 @@ type RetentionConfig struct {
 	Reflections    int      `json:"reflections,omitempty"`
 	SLA            int      `json:"sla,omitempty"`
 	TrustEvents    int      `json:"trustEvents,omitempty"`
+	Traces         int      `json:"traces,omitempty"`
 	Handoffs       int      `json:"handoffs,omitempty"`
 	Queue          int      `json:"queue,omitempty"`
 	Versions       int      `json:"versions,omitempty"`
@@ -1476,6 +2923,21 @@ type SlotPressureConfig struct {
 	MonitorInterval       string `json:"monitorInterval,omitempty"`
 }
 
+// TaskPriorityConfig orders tasks waiting for a dispatch slot.
+type TaskPriorityConfig struct {
+	Preempt        bool              `json:"preempt,omitempty"`        // interactive tasks requeue running low-priority ones when all slots are busy
+	MaxPreemptions int               `json:"maxPreemptions,omitempty"` // times a task may be preempted before it runs to completion (default 2)
+	Sources        map[string]string `json:"sources,omitempty"`        // source prefix → low|normal|high|interactive
+}
+
+// MaxPreemptionsOrDefault returns MaxPreemptions or the default (2).
+func (c TaskPriorityConfig) MaxPreemptionsOrDefault() int {
+	if c.MaxPreemptions > 0 {
+		return c.MaxPreemptions
+	}
+	return 2
+}
+
 type CanvasConfig struct {
 	Enabled         bool   `json:"enabled,omitempty"`
 	MaxIframeHeight string `json:"maxIframeHeight,omitempty"`
@@ -1650,11 +3112,291 @@ type DeviceConfig struct {
 	LocationEnabled  bool   `json:"location,omitempty"`
 }
 
+// SSHConfig defines the machines agents may run commands on with ssh_exec.
+// Commands run through the system ssh client with key authentication only
+// and strict host key checking.
+type SSHConfig struct {
+	Hosts   map[string]SSHHostConfig `json:"hosts,omitempty"`   // keyed by the name agents use
+	Timeout string                   `json:"timeout,omitempty"` // per command, default "30s"
+}
+
+// SSHHostConfig is one ssh_exec target. Allow lists the permitted commands:
+// an exact command line, or a prefix ending in "*" ("df *", "docker ps*");
+// nothing is allowed by default. Trust is "observe" (dry run only), "suggest"
+// (the owner approves each command, the default) or "auto" (allowed commands
+// run directly).
+type SSHHostConfig struct {
+	Host       string   `json:"host"`                 // hostname or address
+	Port       int      `json:"port,omitempty"`       // default 22
+	User       string   `json:"user"`                 // remote login
+	KeyFile    string   `json:"keyFile"`              // private key; "~/" expanded
+	KnownHosts string   `json:"knownHosts,omitempty"` // known_hosts file; default ssh's own
+	Allow      []string `json:"allow,omitempty"`
+	Trust      string   `json:"trust,omitempty"`
+	Agents     []string `json:"agents,omitempty"`  // agents that may use the host; empty = all
+	Timeout    string   `json:"timeout,omitempty"` // overrides ssh.timeout
+}
+
+// TrustOrDefault returns the host's trust level (default "suggest").
+func (h SSHHostConfig) TrustOrDefault() string {
+	if h.Trust == "" {
+		return "suggest"
+	}
+	return h.Trust
+}
+
+// PortOrDefault returns the SSH port (default 22).
+func (h SSHHostConfig) PortOrDefault() int {
+	if h.Port > 0 {
+		return h.Port
+	}
+	return 22
+}
+
+// TimeoutOrDefault returns how long one command may run on the host: its
+// own timeout, else ssh.timeout, else 30s. tools.toolTimeout still bounds
+// the whole call.
+func (c SSHConfig) TimeoutOrDefault(h SSHHostConfig) time.Duration {
+	for _, s := range []string{h.Timeout, c.Timeout} {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 30 * time.Second
+}
+
+// KubernetesConfig defines the clusters the k8s tools may query. Tools run
+// the kubectl binary against each cluster's kubeconfig. They are read-only
+// unless a cluster enables mutate, and every mutation needs the owner's
+// approval.
+type KubernetesConfig struct {
+	Clusters map[string]KubeClusterConfig `json:"clusters,omitempty"`
+	Kubectl  string                       `json:"kubectl,omitempty"` // default "kubectl"
+	Timeout  string                       `json:"timeout,omitempty"` // per call, default "20s"
+}
+
+// KubeClusterConfig is one k8s tool target.
+type KubeClusterConfig struct {
+	Kubeconfig string   `json:"kubeconfig,omitempty"` // default kubectl's own; "~/" expanded
+	Context    string   `json:"context,omitempty"`    // default the kubeconfig's current context
+	Namespaces []string `json:"namespaces,omitempty"` // allowed namespaces, first is the default; empty = all, default "default"
+	Mutate     bool     `json:"mutate,omitempty"`     // allow restart, scale and pod deletion (approved per call)
+}
+
+// KubectlOrDefault returns the kubectl binary (default "kubectl").
+func (c KubernetesConfig) KubectlOrDefault() string {
+	if c.Kubectl == "" {
+		return "kubectl"
+	}
+	return c.Kubectl
+}
+
+// TimeoutOrDefault returns how long one kubectl call may take (default 20s).
+func (c KubernetesConfig) TimeoutOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return 20 * time.Second
+}
+
+// DatabasesConfig defines the connections the db_query tool may read.
+// Queries run through the sqlite3, psql or mysql client, must be a single
+// read-only statement, and execute inside a read-only transaction that is
+// rolled back.
+type DatabasesConfig struct {
+	Connections map[string]DBConnectionConfig `json:"connections,omitempty"`
+	MaxRows     int                           `json:"maxRows,omitempty"`  // rows returned per query, default 200
+	MaxBytes    int                           `json:"maxBytes,omitempty"` // client output read per query, default 1MB
+	Timeout     string                        `json:"timeout,omitempty"`  // per query, default "30s"
+}
+
+// DBConnectionConfig is one db_query connection. SQLite takes a file path
+// in dsn; Postgres a connection URI in dsn or the host fields; MySQL the
+// host fields. Password supports $ENV_VAR.
+type DBConnectionConfig struct {
+	Driver      string   `json:"driver"`        // "sqlite", "postgres" or "mysql"
+	DSN         string   `json:"dsn,omitempty"` // sqlite file ("~/" expanded) or postgres URI
+	Host        string   `json:"host,omitempty"`
+	Port        int      `json:"port,omitempty"` // default the client's
+	User        string   `json:"user,omitempty"`
+	Password    string   `json:"password,omitempty"` // passed through the client's environment
+	Database    string   `json:"database,omitempty"`
+	Description string   `json:"description,omitempty"` // what the data is, shown to agents
+	Agents      []string `json:"agents,omitempty"`      // agents that may query it; empty = all
+}
+
+// MaxRowsOrDefault returns the row limit per query (default 200).
+func (c DatabasesConfig) MaxRowsOrDefault() int {
+	if c.MaxRows > 0 {
+		return c.MaxRows
+	}
+	return 200
+}
+
+// MaxBytesOrDefault returns how much client output one query may produce
+// (default 1MB).
+func (c DatabasesConfig) MaxBytesOrDefault() int {
+	if c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return 1 << 20
+}
+
+// TimeoutOrDefault returns how long one query may run (default 30s).
+func (c DatabasesConfig) TimeoutOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// SpreadsheetsConfig enables the sheet_read, sheet_append and sheet_update
+// tools. Google Sheets are reached through an OAuth service whose scopes
+// include https://www.googleapis.com/auth/spreadsheets; local .xlsx files
+// must lie inside Dirs.
+type SpreadsheetsConfig struct {
+	Enabled      bool     `json:"enabled,omitempty"`
+	OAuthService string   `json:"oauthService,omitempty"` // default "google"
+	Dirs         []string `json:"dirs,omitempty"`         // where .xlsx files may be read and written; default the file manager's storage dir
+	MaxRows      int      `json:"maxRows,omitempty"`      // rows returned per read, default 200
+}
+
+// OAuthServiceOrDefault returns the OAuth service used for Google Sheets.
+func (c SpreadsheetsConfig) OAuthServiceOrDefault() string {
+	if c.OAuthService != "" {
+		return c.OAuthService
+	}
+	return "google"
+}
+
+// DirsOrDefault returns the directories local spreadsheets may live in,
+// defaulting to the file manager's storage directory.
+func (c SpreadsheetsConfig) DirsOrDefault(baseDir string, fm FileManagerConfig) []string {
+	if len(c.Dirs) > 0 {
+		return c.Dirs
+	}
+	return []string{fm.StorageDirOrDefault(baseDir)}
+}
+
+// MaxRowsOrDefault returns the row limit per read (default 200).
+func (c SpreadsheetsConfig) MaxRowsOrDefault() int {
+	if c.MaxRows > 0 {
+		return c.MaxRows
+	}
+	return 200
+}
+
+// DocumentsConfig enables the document_render and document_send tools,
+// which turn Markdown task outputs into PDF or DOCX files and deliver them.
+// Email goes through an OAuth service whose scopes include
+// https://www.googleapis.com/auth/gmail.send.
+type DocumentsConfig struct {
+	Enabled         bool                              `json:"enabled,omitempty"`
+	Dir             string                            `json:"dir,omitempty"`             // where documents are saved; default {baseDir}/outputs/documents
+	Templates       map[string]DocumentTemplateConfig `json:"templates,omitempty"`       // by name
+	DefaultTemplate string                            `json:"defaultTemplate,omitempty"` // default "default" when defined
+	OAuthService    string                            `json:"oauthService,omitempty"`    // default "google"
+}
+
+// DocumentTemplateConfig is the letterhead of a document. Header and Footer
+// may use {title}, {date}, {page} and {pages}.
+type DocumentTemplateConfig struct {
+	Logo     string `json:"logo,omitempty"`     // PNG or JPEG path, "~/" expanded
+	Header   string `json:"header,omitempty"`   // top right of every page
+	Footer   string `json:"footer,omitempty"`   // default "{page} / {pages}"
+	Accent   string `json:"accent,omitempty"`   // heading color "#RRGGBB"
+	PageSize string `json:"pageSize,omitempty"` // "A4" (default) or "Letter"
+}
+
+// DirOrDefault returns the directory documents are saved in.
+func (c DocumentsConfig) DirOrDefault(baseDir string) string {
+	if c.Dir != "" {
+		return c.Dir
+	}
+	return filepath.Join(baseDir, "outputs", "documents")
+}
+
+// Template returns the named template, or the default one when name is
+// empty. The zero template is returned when no default is configured.
+func (c DocumentsConfig) Template(name string) (DocumentTemplateConfig, error) {
+	if name == "" {
+		name = c.DefaultTemplate
+		if name == "" {
+			return c.Templates["default"], nil
+		}
+	}
+	t, ok := c.Templates[name]
+	if !ok {
+		return t, fmt.Errorf("unknown document template %q", name)
+	}
+	return t, nil
+}
+
+// OAuthServiceOrDefault returns the OAuth service used for email.
+func (c DocumentsConfig) OAuthServiceOrDefault() string {
+	if c.OAuthService != "" {
+		return c.OAuthService
+	}
+	return "google"
+}
+
+// CalendarConfig configures the calendars agents, scheduling and briefings
+// read and write. Without accounts, calendarId of the "google" OAuth service
+// is used.
 type CalendarConfig struct {
-	Enabled    bool   `json:"enabled"`
-	CalendarID string `json:"calendarId,omitempty"`
-	TimeZone   string `json:"timeZone,omitempty"`
-	MaxResults int    `json:"maxResults,omitempty"`
+	Enabled    bool              `json:"enabled"`
+	CalendarID string            `json:"calendarId,omitempty"`
+	TimeZone   string            `json:"timeZone,omitempty"`
+	MaxResults int               `json:"maxResults,omitempty"`
+	Accounts   []CalendarAccount `json:"accounts,omitempty"`
+	Default    string            `json:"default,omitempty"` // account new events go to; default the first writable one
+}
+
+// CalendarAccount is one Google, CalDAV (Nextcloud, Fastmail, iCloud) or
+// ICS feed calendar.
+type CalendarAccount struct {
+	Name         string `json:"name"`                   // unique, used by tools
+	Type         string `json:"type"`                   // "google", "caldav" or "ics"
+	CalendarID   string `json:"calendarId,omitempty"`   // google: calendar ID (default "primary")
+	OAuthService string `json:"oauthService,omitempty"` // google: OAuth service (default "google")
+	URL          string `json:"url,omitempty"`          // caldav: calendar collection URL; ics: feed URL or file path
+	Username     string `json:"username,omitempty"`     // caldav
+	Password     string `json:"password,omitempty"`     // caldav app password, $ENV_VAR supported
+	ReadOnly     bool   `json:"readOnly,omitempty"`     // never write to this calendar (ics feeds always are)
+	Refresh      string `json:"refresh,omitempty"`      // ics: how often the feed is fetched (default "15m", min "1m")
+}
+
+// AccountsOrDefault returns the configured accounts, or the single Google
+// calendar of calendarId when there are none.
+func (c CalendarConfig) AccountsOrDefault() []CalendarAccount {
+	if len(c.Accounts) > 0 {
+		return c.Accounts
+	}
+	return []CalendarAccount{{Name: "google", Type: "google", CalendarID: c.CalendarID}}
+}
+
+// Account returns the account named name.
+func (c CalendarConfig) Account(name string) (CalendarAccount, bool) {
+	for _, a := range c.AccountsOrDefault() {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return CalendarAccount{}, false
+}
+
+// Writable reports whether events can be created in the account.
+func (a CalendarAccount) Writable() bool {
+	return a.Type != "ics" && !a.ReadOnly
+}
+
+// RefreshOrDefault returns how often an ICS feed is fetched (default 15m,
+// at least 1m).
+func (a CalendarAccount) RefreshOrDefault() time.Duration {
+	if d, err := time.ParseDuration(a.Refresh); err == nil && d > 0 {
+		return max(d, time.Minute)
+	}
+	return 15 * time.Minute
 }
 
 type FileManagerConfig struct {
@@ -1698,6 +3440,39 @@ type FamilyConfig struct {
 	DefaultRateLimit int     `json:"defaultRateLimit,omitempty"`
 }
 
+// WorkCalendarConfig defines the working days cron jobs with
+// businessDaysOnly or holidays run on. Users overrides it per family member
+// when family.enabled is set.
+type WorkCalendarConfig struct {
+	Holidays string                      `json:"holidays,omitempty"` // country code ("JP"), ICS URL or .ics file; empty = no holidays
+	Weekend  []string                    `json:"weekend,omitempty"`  // "mon".."sun"; default ["sat","sun"]
+	Refresh  string                      `json:"refresh,omitempty"`  // holiday calendar refresh interval; default "24h"
+	Users    map[string]WorkCalendarUser `json:"users,omitempty"`    // by user ID
+}
+
+// WorkCalendarUser is one family member's calendar. Empty fields fall back
+// to the WorkCalendarConfig ones.
+type WorkCalendarUser struct {
+	Holidays   string       `json:"holidays,omitempty"`
+	Weekend    []string     `json:"weekend,omitempty"`
+	QuietHours *QuietWindow `json:"quietHours,omitempty"` // defers notifications of this user's jobs
+}
+
+// QuietWindow is a daily range, in the job's time zone, during which
+// notifications are held. An End earlier than Start wraps past midnight.
+type QuietWindow struct {
+	Start string `json:"start"` // "HH:MM"
+	End   string `json:"end"`   // "HH:MM"
+}
+
+// RefreshOrDefault returns the holiday calendar refresh interval.
+func (c WorkCalendarConfig) RefreshOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.Refresh); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
 func (c FamilyConfig) MaxUsersOrDefault() int {
 	if c.MaxUsers > 0 {
 		return c.MaxUsers
@@ -1768,3 +3543,463 @@ func (c *SkillEvolveConfig) BudgetOrDefault() float64 {
 	}
 	return c.Budget
 }
+
+// ReadLaterConfig configures the read-later integration. Links are saved
+// to the service from chat (autoSave) or by agents, highlights are pulled
+// back into the knowledge base, and a weekly insight compares what was
+// saved with what was read.
+type ReadLaterConfig struct {
+	Service      string   `json:"service,omitempty"`      // "readwise" or "omnivore"
+	Token        string   `json:"token,omitempty"`        // Readwise access token or Omnivore API key, $ENV_VAR supported
+	Server       string   `json:"server,omitempty"`       // Omnivore API URL (self-hosted; required for omnivore)
+	AutoSave     bool     `json:"autoSave,omitempty"`     // save links posted in Discord/Telegram chats
+	Tags         []string `json:"tags,omitempty"`         // tags for saved links (default ["tetora"])
+	SyncInterval string   `json:"syncInterval,omitempty"` // highlight sync (default "6h", min "15m")
+	Knowledge    *bool    `json:"knowledge,omitempty"`    // write highlights to the knowledge base (default true)
+	Insight      string   `json:"insight,omitempty"`      // weekly insight cron schedule (default "0 18 * * 0"; "off" disables)
+}
+
+// Enabled reports whether a read-later service is configured.
+func (c ReadLaterConfig) Enabled() bool {
+	return c.Service != "" && c.Token != ""
+}
+
+// TagsOrDefault returns the tags added to saved links.
+func (c ReadLaterConfig) TagsOrDefault() []string {
+	if len(c.Tags) > 0 {
+		return c.Tags
+	}
+	return []string{"tetora"}
+}
+
+// SyncIntervalOrDefault returns how often highlights and reading progress
+// are pulled (default 6h, at least 15m).
+func (c ReadLaterConfig) SyncIntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.SyncInterval); err == nil && d > 0 {
+		return max(d, 15*time.Minute)
+	}
+	return 6 * time.Hour
+}
+
+// KnowledgeEnabled reports whether highlights are written to the knowledge
+// base.
+func (c ReadLaterConfig) KnowledgeEnabled() bool {
+	return c.Knowledge == nil || *c.Knowledge
+}
+
+// InsightOrDefault returns the cron schedule of the weekly insight, or ""
+// when it is off.
+func (c ReadLaterConfig) InsightOrDefault() string {
+	switch c.Insight {
+	case "":
+		return "0 18 * * 0"
+	case "off":
+		return ""
+	}
+	return c.Insight
+}
+
+// MailInConfig configures the inbound email address. Emails forwarded to it
+// by an allowed sender become task board tasks, or a summary with a
+// suggested reply for the owner. Mail is read from an IMAP mailbox or
+// posted by a Mailgun route.
+type MailInConfig struct {
+	Enabled        bool                `json:"enabled,omitempty"`
+	Address        string              `json:"address,omitempty"`        // the forwarding address; "+task"/"+reply" tags pick the action
+	AllowedSenders []string            `json:"allowedSenders,omitempty"` // addresses or "@domain"; nothing is accepted when empty
+	RequireDKIM    bool                `json:"requireDkim,omitempty"`    // also require a passing DKIM result
+	Action         string              `json:"action,omitempty"`         // "task" (default) or "reply"
+	Agent          string              `json:"agent,omitempty"`          // agent writing reply summaries (default smartDispatch.defaultAgent)
+	Assignee       string              `json:"assignee,omitempty"`       // task board assignee of created tasks
+	Project        string              `json:"project,omitempty"`        // task board project of created tasks
+	IMAP           MailInIMAPConfig    `json:"imap,omitempty"`
+	Mailgun        MailInMailgunConfig `json:"mailgun,omitempty"`
+}
+
+// MailInIMAPConfig is the mailbox polled for forwarded mail.
+type MailInIMAPConfig struct {
+	Server       string `json:"server,omitempty"`       // host:port, TLS (e.g. "imap.gmail.com:993")
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`     // $ENV_VAR supported
+	Mailbox      string `json:"mailbox,omitempty"`      // default "INBOX"
+	PollInterval string `json:"pollInterval,omitempty"` // default "2m", min "30s"
+}
+
+// MailInMailgunConfig verifies posts from a Mailgun route.
+type MailInMailgunConfig struct {
+	SigningKey string `json:"signingKey,omitempty"` // HTTP webhook signing key, $ENV_VAR supported
+}
+
+// SenderAllowed reports whether mail from addr is accepted.
+func (c MailInConfig) SenderAllowed(addr string) bool {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	if addr == "" {
+		return false
+	}
+	for _, a := range c.AllowedSenders {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == addr || (strings.HasPrefix(a, "@") && strings.HasSuffix(addr, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActionOrDefault returns what a forwarded email turns into when the
+// address has no tag.
+func (c MailInConfig) ActionOrDefault() string {
+	if c.Action == "reply" {
+		return "reply"
+	}
+	return "task"
+}
+
+// Enabled reports whether a mailbox is configured for polling.
+func (c MailInIMAPConfig) Enabled() bool {
+	return c.Server != "" && c.Username != ""
+}
+
+// MailboxOrDefault returns the polled mailbox.
+func (c MailInIMAPConfig) MailboxOrDefault() string {
+	if c.Mailbox != "" {
+		return c.Mailbox
+	}
+	return "INBOX"
+}
+
+// PollIntervalOrDefault returns how often the mailbox is checked (default
+// 2m, at least 30s).
+func (c MailInIMAPConfig) PollIntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.PollInterval); err == nil && d > 0 {
+		return max(d, 30*time.Second)
+	}
+	return 2 * time.Minute
+}
+
+// MailConfig configures the mailboxes agents read and send from with the
+// email_* tools: any IMAP/SMTP provider, signing in with an app password or
+// OAuth2 (XOAUTH2), Gmail included.
+type MailConfig struct {
+	Enabled    bool          `json:"enabled,omitempty"`
+	Accounts   []MailAccount `json:"accounts,omitempty"`
+	Default    string        `json:"default,omitempty"`    // account email_send uses when none is named (default the first that can send)
+	MaxResults int           `json:"maxResults,omitempty"` // messages per listing (default 20)
+}
+
+// MailAccount is one mailbox.
+type MailAccount struct {
+	Name         string `json:"name"`                   // unique, used by tools
+	Address      string `json:"address"`                // the account's email address, used as sender
+	DisplayName  string `json:"displayName,omitempty"`  // sender name
+	IMAP         string `json:"imap,omitempty"`         // host:port, TLS (e.g. "imap.fastmail.com:993")
+	SMTP         string `json:"smtp,omitempty"`         // host:port; port 465 is TLS, others use STARTTLS
+	Username     string `json:"username,omitempty"`     // default address
+	Password     string `json:"password,omitempty"`     // app password, $ENV_VAR supported
+	OAuthService string `json:"oauthService,omitempty"` // sign in with this OAuth service's token instead of a password
+	Mailbox      string `json:"mailbox,omitempty"`      // default "INBOX"
+	ReadOnly     bool   `json:"readOnly,omitempty"`     // never send from this account
+}
+
+// Account returns the account named name.
+func (c MailConfig) Account(name string) (MailAccount, bool) {
+	for _, a := range c.Accounts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return MailAccount{}, false
+}
+
+// MaxResultsOrDefault returns the number of messages listed (default 20).
+func (c MailConfig) MaxResultsOrDefault() int {
+	if c.MaxResults > 0 {
+		return c.MaxResults
+	}
+	return 20
+}
+
+// UsernameOrDefault returns the login name (default the address).
+func (a MailAccount) UsernameOrDefault() string {
+	if a.Username != "" {
+		return a.Username
+	}
+	return a.Address
+}
+
+// MailboxOrDefault returns the mailbox that is read.
+func (a MailAccount) MailboxOrDefault() string {
+	if a.Mailbox != "" {
+		return a.Mailbox
+	}
+	return "INBOX"
+}
+
+// CanSend reports whether mail can be sent from the account.
+func (a MailAccount) CanSend() bool {
+	return a.SMTP != "" && !a.ReadOnly
+}
+
+// MQTTConfig connects to MQTT brokers, for IoT devices and anything else
+// that speaks MQTT. Messages on subscribed topics become "mqtt:<topic>"
+// events for workflow and proactive triggers, and task results can be
+// published to a topic.
+type MQTTConfig struct {
+	Enabled bool         `json:"enabled,omitempty"`
+	Brokers []MQTTBroker `json:"brokers,omitempty"`
+}
+
+// MQTTBroker is one broker connection.
+type MQTTBroker struct {
+	Name      string             `json:"name"`               // unique, used by notification channels
+	URL       string             `json:"url"`                // mqtt://host:1883 or mqtts://host:8883 (also tcp://, ssl://, tls://)
+	ClientID  string             `json:"clientId,omitempty"` // default "tetora-<name>"
+	Username  string             `json:"username,omitempty"`
+	Password  string             `json:"password,omitempty"`  // $ENV_VAR supported
+	KeepAlive string             `json:"keepAlive,omitempty"` // default "60s"
+	TLS       MQTTTLS            `json:"tls,omitempty"`       // used with mqtts://
+	Subscribe []MQTTSubscription `json:"subscribe,omitempty"`
+	Results   *MQTTResults       `json:"results,omitempty"` // publish task results
+}
+
+// MQTTTLS configures TLS for mqtts:// brokers.
+type MQTTTLS struct {
+	CAFile             string `json:"caFile,omitempty"`   // PEM CA bundle; default system roots
+	CertFile           string `json:"certFile,omitempty"` // client certificate, for brokers that require one
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// MQTTSubscription is a topic filter to subscribe to. Filters may use the
+// MQTT wildcards "+" (one level) and "#" (all remaining levels).
+type MQTTSubscription struct {
+	Topic string `json:"topic"`
+	QoS   int    `json:"qos,omitempty"` // 0 or 1
+}
+
+// MQTTResults publishes a JSON message for each finished task.
+type MQTTResults struct {
+	Topic  string   `json:"topic"`            // "{status}" is replaced by the task status, e.g. "tetora/tasks/{status}"
+	Events []string `json:"events,omitempty"` // statuses to publish ("success", "error", "timeout", "cancelled", "all"); empty = all
+	QoS    int      `json:"qos,omitempty"`
+	Retain bool     `json:"retain,omitempty"`
+}
+
+// Broker returns the broker named name.
+func (c MQTTConfig) Broker(name string) (MQTTBroker, bool) {
+	for _, b := range c.Brokers {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return MQTTBroker{}, false
+}
+
+// ClientIDOrDefault returns the client ID the broker knows Tetora by.
+func (b MQTTBroker) ClientIDOrDefault() string {
+	if b.ClientID != "" {
+		return b.ClientID
+	}
+	return "tetora-" + b.Name
+}
+
+// KeepAliveOrDefault returns the keep-alive interval (default 60s).
+func (b MQTTBroker) KeepAliveOrDefault() time.Duration {
+	if d, err := time.ParseDuration(b.KeepAlive); err == nil && d >= time.Second {
+		return d
+	}
+	return 60 * time.Second
+}
+
+// SocialConfig configures Bluesky and Mastodon accounts. Each account can be
+// posted to by agents (social_post, social_reply), used as a notification
+// channel, and polled for mentions, which land in the social inbox.
+type SocialConfig struct {
+	Accounts     []SocialAccount `json:"accounts,omitempty"`
+	PollInterval string          `json:"pollInterval,omitempty"` // mention polling (default "5m", min "1m")
+}
+
+// SocialAccount is one Bluesky or Mastodon account.
+type SocialAccount struct {
+	Name           string `json:"name"`                     // unique, used by tools and notifications
+	Type           string `json:"type"`                     // "bluesky" or "mastodon"
+	Server         string `json:"server,omitempty"`         // Mastodon instance URL; Bluesky PDS (default https://bsky.social)
+	Handle         string `json:"handle,omitempty"`         // Bluesky handle or DID
+	AppPassword    string `json:"appPassword,omitempty"`    // Bluesky app password, $ENV_VAR supported
+	AccessToken    string `json:"accessToken,omitempty"`    // Mastodon access token, $ENV_VAR supported
+	MaxLength      int    `json:"maxLength,omitempty"`      // default 300 (Bluesky) or 500 (Mastodon)
+	Mentions       *bool  `json:"mentions,omitempty"`       // poll mentions (default true)
+	NotifyMentions bool   `json:"notifyMentions,omitempty"` // tell the owner about new mentions
+}
+
+// PollIntervalOrDefault returns how often mentions are fetched (default 5m,
+// at least 1m).
+func (c SocialConfig) PollIntervalOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.PollInterval); err == nil && d > 0 {
+		return max(d, time.Minute)
+	}
+	return 5 * time.Minute
+}
+
+// Account returns the account named name.
+func (c SocialConfig) Account(name string) (SocialAccount, bool) {
+	for _, a := range c.Accounts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return SocialAccount{}, false
+}
+
+// MentionsEnabled reports whether the account's mentions are polled.
+func (a SocialAccount) MentionsEnabled() bool {
+	return a.Mentions == nil || *a.Mentions
+}
+
+// MaxLengthOrDefault returns the longest post, in characters.
+func (a SocialAccount) MaxLengthOrDefault() int {
+	if a.MaxLength > 0 {
+		return a.MaxLength
+	}
+	if a.Type == "mastodon" {
+		return 500
+	}
+	return 300
+}
+
+// GitHubConfig connects one GitHub account. It is shorthand for a forge
+// account named "github" (see ForgesConfig) and keeps its webhook at
+// /api/github/webhook. It signs in with a personal access token, or as a
+// GitHub App installation when appId is set.
+type GitHubConfig struct {
+	Enabled        bool     `json:"enabled,omitempty"`
+	Token          string   `json:"token,omitempty"`          // personal access token, $ENV_VAR supported
+	AppID          int64    `json:"appId,omitempty"`          // GitHub App ID, instead of a token
+	InstallationID int64    `json:"installationId,omitempty"` // the App's installation on the account or org
+	PrivateKeyFile string   `json:"privateKeyFile,omitempty"` // the App's PEM private key
+	APIURL         string   `json:"apiUrl,omitempty"`         // default https://api.github.com; GitHub Enterprise Server: https://host/api/v3
+	Repos          []string `json:"repos,omitempty"`          // "owner/name" the tools and webhook accept; empty = any
+	WebhookSecret  string   `json:"webhookSecret,omitempty"`  // verifies webhook deliveries, $ENV_VAR supported
+	MaxDiffBytes   int      `json:"maxDiffBytes,omitempty"`   // forge_pr_diff cut-off (default 100000)
+}
+
+// Account returns the forge account the github block stands for.
+func (c GitHubConfig) Account() ForgeAccount {
+	url := strings.TrimSuffix(strings.TrimSuffix(c.APIURL, "/"), "/api/v3")
+	if url == "https://api.github.com" {
+		url = ""
+	}
+	return ForgeAccount{
+		Name:           "github",
+		Type:           "github",
+		URL:            url,
+		Token:          c.Token,
+		AppID:          c.AppID,
+		InstallationID: c.InstallationID,
+		PrivateKeyFile: c.PrivateKeyFile,
+		Repos:          c.Repos,
+		WebhookSecret:  c.WebhookSecret,
+		MaxDiffBytes:   c.MaxDiffBytes,
+	}
+}
+
+// ForgesConfig connects code forges (GitHub, GitLab and Gitea or Forgejo)
+// for the forge_* tools and webhooks. Each repository is served by the
+// account that lists it in repos, so self-hosted and hosted forges can be
+// mixed.
+type ForgesConfig struct {
+	Accounts []ForgeAccount `json:"accounts,omitempty"`
+}
+
+// ForgeAccount is one account on a forge.
+type ForgeAccount struct {
+	Name           string   `json:"name"`                     // unique; used by tools and in /api/forge/<name>/webhook
+	Type           string   `json:"type"`                     // "github", "gitlab" or "gitea" (Forgejo too)
+	URL            string   `json:"url,omitempty"`            // server URL; default https://github.com or https://gitlab.com, required for gitea
+	Token          string   `json:"token,omitempty"`          // API token, $ENV_VAR supported
+	AppID          int64    `json:"appId,omitempty"`          // github: GitHub App ID, instead of a token
+	InstallationID int64    `json:"installationId,omitempty"` // github: the App's installation
+	PrivateKeyFile string   `json:"privateKeyFile,omitempty"` // github: the App's PEM private key
+	Repos          []string `json:"repos,omitempty"`          // repos ("owner/name", GitLab "group/subgroup/name") served by this account; empty = any
+	WebhookSecret  string   `json:"webhookSecret,omitempty"`  // verifies webhook deliveries, $ENV_VAR supported
+	MaxDiffBytes   int      `json:"maxDiffBytes,omitempty"`   // forge_pr_diff cut-off (default 100000)
+}
+
+// APIURL returns the account's REST API base URL.
+func (a ForgeAccount) APIURL() string {
+	u := strings.TrimSuffix(a.URL, "/")
+	switch a.Type {
+	case "github":
+		if u == "" || u == "https://github.com" {
+			return "https://api.github.com"
+		}
+		return u + "/api/v3"
+	case "gitlab":
+		if u == "" {
+			u = "https://gitlab.com"
+		}
+		return u + "/api/v4"
+	}
+	return u + "/api/v1"
+}
+
+// Serves reports whether the account lists repo. Names compare
+// case-insensitively, as the forges do.
+func (a ForgeAccount) Serves(repo string) bool {
+	for _, r := range a.Repos {
+		if strings.EqualFold(r, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// RepoAllowed reports whether the account may be used for repo: it lists
+// the repo, or lists none.
+func (a ForgeAccount) RepoAllowed(repo string) bool {
+	return len(a.Repos) == 0 || a.Serves(repo)
+}
+
+// MaxDiffBytesOrDefault returns the longest diff forge_pr_diff returns.
+func (a ForgeAccount) MaxDiffBytesOrDefault() int {
+	if a.MaxDiffBytes > 0 {
+		return a.MaxDiffBytes
+	}
+	return 100000
+}
+
+// IntegrationHealthConfig scores the integrations agents reach through
+// tools (mail, calendar, forges, social and so on) and takes one out of
+// service for a while when it keeps failing, so agents stop spending turns
+// on it. Default is enabled.
+type IntegrationHealthConfig struct {
+	Enabled       *bool  `json:"enabled,omitempty"`
+	FailThreshold int    `json:"failThreshold,omitempty"` // consecutive failures that disable an integration (default 5)
+	MinScore      int    `json:"minScore,omitempty"`      // score (0-100) under which a full window disables it (default 25)
+	Window        int    `json:"window,omitempty"`        // recent calls the score covers (default 20)
+	DisableFor    string `json:"disableFor,omitempty"`    // first time out (default "15m"), doubled on each repeat
+	MaxDisableFor string `json:"maxDisableFor,omitempty"` // longest time out (default "6h")
+}
+
+// EnabledOrDefault returns true when Enabled is unset (default) or explicitly true.
+func (c IntegrationHealthConfig) EnabledOrDefault() bool {
+	if c.Enabled == nil {
+		return true
+	}
+	return *c.Enabled
+}
+
+// DisableForOrDefault returns the first time out of a failing integration.
+func (c IntegrationHealthConfig) DisableForOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.DisableFor); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
+
+// MaxDisableForOrDefault returns the longest time out.
+func (c IntegrationHealthConfig) MaxDisableForOrDefault() time.Duration {
+	if d, err := time.ParseDuration(c.MaxDisableFor); err == nil && d > 0 {
+		return d
+	}
+	return 6 * time.Hour
+}
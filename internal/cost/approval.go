@@ -0,0 +1,130 @@
+package cost
+
+import (
+	"fmt"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// --- Spend Approval ---
+
+// Spend approval decisions.
+const (
+	DecisionApproved    = "approved"
+	DecisionRejected    = "rejected"
+	DecisionTimeout     = "timeout"
+	DecisionUnavailable = "unavailable" // no approval channel was reachable
+)
+
+// SpendApproval records an owner decision for a task whose estimated cost
+// exceeded the approval threshold. ActualUSD is filled in after the task
+// finishes so estimates can be reconciled against real spend.
+type SpendApproval struct {
+	ID           int     `json:"id"`
+	TaskID       string  `json:"taskId"`
+	TaskName     string  `json:"taskName"`
+	Agent        string  `json:"agent"`
+	Model        string  `json:"model"`
+	Channel      string  `json:"channel"`
+	Decision     string  `json:"decision"`
+	ThresholdUSD float64 `json:"thresholdUsd"`
+	EstimatedUSD float64 `json:"estimatedUsd"`
+	ActualUSD    float64 `json:"actualUsd"`
+	CreatedAt    string  `json:"createdAt"`
+	ReconciledAt string  `json:"reconciledAt,omitempty"`
+}
+
+// DeltaUSD returns actual minus estimated cost (0 until reconciled).
+func (a SpendApproval) DeltaUSD() float64 {
+	if a.ReconciledAt == "" {
+		return 0
+	}
+	return a.ActualUSD - a.EstimatedUSD
+}
+
+// InitSpendApprovalDB creates the spend_approvals table.
+func InitSpendApprovalDB(dbPath string) error {
+	if dbPath == "" {
+		return nil
+	}
+	sql := `CREATE TABLE IF NOT EXISTS spend_approvals (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id TEXT NOT NULL,
+  task_name TEXT DEFAULT '',
+  agent TEXT DEFAULT '',
+  model TEXT DEFAULT '',
+  channel TEXT DEFAULT '',
+  decision TEXT NOT NULL,
+  threshold_usd REAL DEFAULT 0,
+  estimated_usd REAL DEFAULT 0,
+  actual_usd REAL DEFAULT 0,
+  created_at TEXT NOT NULL,
+  reconciled_at TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_spend_approvals_task ON spend_approvals(task_id);
+CREATE INDEX IF NOT EXISTS idx_spend_approvals_time ON spend_approvals(created_at);`
+	return db.Exec(dbPath, sql)
+}
+
+// RecordSpendApproval stores an approval decision.
+func RecordSpendApproval(dbPath string, a SpendApproval) error {
+	if dbPath == "" {
+		return nil
+	}
+	if a.CreatedAt == "" {
+		a.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return db.ExecArgs(dbPath,
+		`INSERT INTO spend_approvals (task_id, task_name, agent, model, channel, decision, threshold_usd, estimated_usd, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.TaskID, a.TaskName, a.Agent, a.Model, a.Channel, a.Decision,
+		a.ThresholdUSD, a.EstimatedUSD, a.CreatedAt)
+}
+
+// ReconcileSpendApproval stores the actual cost for an approved task.
+// It is a no-op when the task never went through spend approval.
+func ReconcileSpendApproval(dbPath, taskID string, actualUSD float64) error {
+	if dbPath == "" || taskID == "" {
+		return nil
+	}
+	return db.ExecArgs(dbPath,
+		`UPDATE spend_approvals SET actual_usd = ?, reconciled_at = ?
+		 WHERE task_id = ? AND decision = ? AND reconciled_at = ''`,
+		actualUSD, time.Now().UTC().Format(time.RFC3339), taskID, DecisionApproved)
+}
+
+// QuerySpendApprovals returns the most recent approval records, newest first.
+func QuerySpendApprovals(dbPath string, limit int) ([]SpendApproval, error) {
+	if dbPath == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := db.Query(dbPath, fmt.Sprintf(
+		`SELECT id, task_id, task_name, agent, model, channel, decision, threshold_usd,
+		        estimated_usd, actual_usd, created_at, reconciled_at
+		 FROM spend_approvals ORDER BY id DESC LIMIT %d`, limit))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SpendApproval, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, SpendApproval{
+			ID:           db.Int(row["id"]),
+			TaskID:       db.Str(row["task_id"]),
+			TaskName:     db.Str(row["task_name"]),
+			Agent:        db.Str(row["agent"]),
+			Model:        db.Str(row["model"]),
+			Channel:      db.Str(row["channel"]),
+			Decision:     db.Str(row["decision"]),
+			ThresholdUSD: db.Float(row["threshold_usd"]),
+			EstimatedUSD: db.Float(row["estimated_usd"]),
+			ActualUSD:    db.Float(row["actual_usd"]),
+			CreatedAt:    db.Str(row["created_at"]),
+			ReconciledAt: db.Str(row["reconciled_at"]),
+		})
+	}
+	return out, nil
+}
@@ -3,12 +3,13 @@ package cost
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/db"
+	"tetora/internal/history"
 )
 
 // --- Budget Config Types ---
@@ -122,7 +123,10 @@ func QuerySpend(dbPath, role string) (daily, weekly, monthly float64) {
 	daily = db.Float(rows[0]["today"])
 	weekly = db.Float(rows[0]["week"])
 	monthly = db.Float(rows[0]["month"])
-	return
+
+	// Tool calls that spent money downstream count against the same budgets.
+	toolDaily, toolWeekly, toolMonthly := history.QueryToolSpend(dbPath, role)
+	return daily + toolDaily, weekly + toolWeekly, monthly + toolMonthly
 }
 
 // QueryWorkflowRunSpend returns the total cost of an active workflow run.
@@ -459,7 +463,7 @@ func FormatBudgetSummary(status *BudgetStatus) string {
 // SetBudgetPaused updates the budgets.paused field in a config.json file.
 // It uses raw JSON manipulation to preserve all other config fields.
 func SetBudgetPaused(configPath string, paused bool) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -490,5 +494,5 @@ func SetBudgetPaused(configPath string, paused bool) error {
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	return os.WriteFile(configPath, out, 0o600)
+	return cfgfile.WriteJSON(configPath, out, 0o600)
 }
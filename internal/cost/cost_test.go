@@ -338,3 +338,45 @@ func containsStr(s, substr string) bool {
 	}
 	return false
 }
+
+func TestSpendApprovalReconcile(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "approvals.db")
+	if err := InitSpendApprovalDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []SpendApproval{
+		{TaskID: "task-1", Agent: "research", Decision: DecisionApproved, ThresholdUSD: 1, EstimatedUSD: 2.5},
+		{TaskID: "task-2", Agent: "research", Decision: DecisionRejected, ThresholdUSD: 1, EstimatedUSD: 4},
+	} {
+		if err := RecordSpendApproval(dbPath, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ReconcileSpendApproval(dbPath, "task-1", 3.0); err != nil {
+		t.Fatal(err)
+	}
+	// Rejected tasks never run, so reconciliation must not touch them.
+	if err := ReconcileSpendApproval(dbPath, "task-2", 9.0); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := QuerySpendApprovals(dbPath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(got))
+	}
+	byTask := map[string]SpendApproval{}
+	for _, a := range got {
+		byTask[a.TaskID] = a
+	}
+	if a := byTask["task-1"]; a.ReconciledAt == "" || a.ActualUSD != 3.0 || a.DeltaUSD() != 0.5 {
+		t.Errorf("task-1 not reconciled correctly: %+v", a)
+	}
+	if a := byTask["task-2"]; a.ReconciledAt != "" || a.ActualUSD != 0 {
+		t.Errorf("rejected task-2 should stay unreconciled: %+v", a)
+	}
+}
@@ -0,0 +1,159 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+	"tetora/internal/quiet"
+	"tetora/internal/scheduling"
+	"tetora/internal/trace"
+)
+
+// --- Work Calendar ---
+
+// jobCalendar is the calendar a job runs under: its own settings, then its
+// family member's workCalendar.users entry, then workCalendar.
+type jobCalendar struct {
+	businessDays bool
+	holidays     string // holiday source; empty = none
+	weekend      []string
+	quiet        *config.QuietWindow
+}
+
+func (ce *Engine) jobCalendar(j *cronJob) jobCalendar {
+	wc := ce.cfg.WorkCalendar
+	c := jobCalendar{businessDays: j.BusinessDaysOnly, weekend: wc.Weekend, quiet: j.QuietHours}
+	holidays := wc.Holidays
+	if u, ok := wc.Users[j.User]; ok && j.User != "" && ce.cfg.Family.Enabled {
+		if u.Holidays != "" {
+			holidays = u.Holidays
+		}
+		if len(u.Weekend) > 0 {
+			c.weekend = u.Weekend
+		}
+		if c.quiet == nil {
+			c.quiet = u.QuietHours
+		}
+	}
+	switch {
+	case j.Holidays != "":
+		c.holidays = j.Holidays
+	case j.BusinessDaysOnly:
+		c.holidays = holidays
+	}
+	return c
+}
+
+// calendarSkip returns why a run scheduled at t (in the job's location)
+// should be skipped, or "" to run it.
+func (ce *Engine) calendarSkip(j *cronJob, t time.Time) string {
+	c := ce.jobCalendar(j)
+	if c.businessDays && scheduling.IsWeekend(c.weekend, t.Weekday()) {
+		return "weekend"
+	}
+	if c.holidays != "" && ce.holidays != nil {
+		if name, ok := ce.holidays.Holiday(c.holidays, t); ok {
+			return "holiday: " + name
+		}
+	}
+	return ""
+}
+
+// prefetchHolidays loads the holiday calendars enabled jobs use, so tick can
+// look them up without network calls under ce.mu.
+func (ce *Engine) prefetchHolidays(ctx context.Context) {
+	if ce.holidays == nil {
+		return
+	}
+	sources := map[string]bool{}
+	ce.mu.RLock()
+	for _, j := range ce.jobs {
+		if j.Enabled {
+			if src := ce.jobCalendar(j).holidays; src != "" {
+				sources[src] = true
+			}
+		}
+	}
+	ce.mu.RUnlock()
+	for src := range sources {
+		if err := ce.holidays.Ensure(ctx, src); err != nil {
+			log.Warn("cron holiday calendar unavailable", "source", src, "error", err)
+		}
+	}
+}
+
+// ValidateCalendar checks a job's calendar fields.
+func ValidateCalendar(jc JobConfig) error {
+	if jc.Holidays != "" {
+		if _, err := scheduling.HolidaySource(jc.Holidays); err != nil {
+			return err
+		}
+	}
+	if q := jc.QuietHours; q != nil {
+		if err := scheduling.ValidateWindow(scheduling.Window{Start: q.Start, End: q.End}); err != nil || q.Start == "" || q.End == "" {
+			return fmt.Errorf("quietHours: start and end must be HH:MM")
+		}
+	}
+	return nil
+}
+
+// --- Deferred Notifications ---
+
+type deferredNotice struct {
+	at    time.Time
+	jobID string
+	msg   string
+}
+
+// notifyJobOwner sends a job's owner notification, holding it while the
+// job's quiet hours last. Held notices are kept in memory only.
+func (ce *Engine) notifyJobOwner(ctx context.Context, j *cronJob, msg string) {
+	if q := ce.jobCalendar(j).quiet; q != nil {
+		if until, ok := scheduling.QuietUntil(q.Start, q.End, time.Now().In(j.loc)); ok {
+			ce.deferMu.Lock()
+			ce.deferred = append(ce.deferred, deferredNotice{at: until, jobID: j.ID, msg: msg})
+			ce.deferMu.Unlock()
+			log.InfoCtx(ctx, "cron notification held for quiet hours", "jobId", j.ID, "until", until.Format(time.RFC3339))
+			return
+		}
+	}
+	ce.notifyOwner(ctx, msg)
+}
+
+// notifyOwner sends msg unless global quiet hours are on, in which case it
+// goes to the digest when enabled.
+func (ce *Engine) notifyOwner(ctx context.Context, msg string) {
+	if ce.notifyFn == nil {
+		return
+	}
+	if quiet.IsQuietHours(ce.quietCfg()) {
+		if ce.cfg.QuietHours.Digest && ce.env.QuietGlobal != nil {
+			ce.env.QuietGlobal.Enqueue(msg)
+		}
+		return
+	}
+	ce.notifyFn(msg)
+	trace.Record(ctx, trace.Event{Kind: trace.KindNotify, Name: "owner", Status: "sent", Detail: truncate(msg, 200)})
+}
+
+// flushDeferred sends held notices whose quiet hours have ended.
+func (ce *Engine) flushDeferred(ctx context.Context, now time.Time) {
+	ce.deferMu.Lock()
+	var due []deferredNotice
+	kept := ce.deferred[:0]
+	for _, n := range ce.deferred {
+		if now.Before(n.at) {
+			kept = append(kept, n)
+		} else {
+			due = append(due, n)
+		}
+	}
+	ce.deferred = kept
+	ce.deferMu.Unlock()
+	for _, n := range due {
+		ce.notifyOwner(ctx, n.msg)
+	}
+}
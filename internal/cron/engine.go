@@ -16,7 +16,9 @@ import (
 	"tetora/internal/dispatch"
 	"tetora/internal/history"
 	"tetora/internal/log"
+	"tetora/internal/prompttmpl"
 	"tetora/internal/quiet"
+	"tetora/internal/scheduling"
 	"tetora/internal/session"
 	"tetora/internal/trace"
 	"tetora/internal/webhook"
@@ -57,22 +59,32 @@ type JobConfig struct {
 	Trigger           string   `json:"trigger,omitempty"`           // "idle" = idle-triggered mode
 	IdleMinMinutes    int      `json:"idleMinMinutes,omitempty"`    // idle trigger: fire after N minutes idle (default 30)
 	CooldownHours     float64  `json:"cooldownHours,omitempty"`     // idle trigger: min hours between triggers (default 20)
+	Once              bool     `json:"once,omitempty"`              // disable after the first scheduled run (one-off times)
+	BusinessDaysOnly  bool     `json:"businessDaysOnly,omitempty"`  // skip weekends and holidays (workCalendar)
+	Holidays          string   `json:"holidays,omitempty"`          // skip holidays of this calendar (country code, ICS URL or file); overrides workCalendar
+	QuietHours        *config.QuietWindow `json:"quietHours,omitempty"` // hold notifications until the window ends
+	User              string   `json:"user,omitempty"`              // family member whose workCalendar.users entry applies
+	Retention         *config.JobRetention `json:"retention,omitempty"` // per-job output retention and pinned runs
+	Note              string   `json:"note,omitempty"`              // append successful output to this note (notes.enabled); {{date}} = run date
 }
 
 // TaskConfig holds the execution parameters for a cron task.
 type TaskConfig struct {
-	Prompt         string   `json:"prompt"`
-	PromptFile     string   `json:"promptFile,omitempty"` // file in ~/.tetora/prompts/ (overrides prompt)
-	Workdir        string   `json:"workdir,omitempty"`
-	Model          string   `json:"model,omitempty"`
-	Provider       string   `json:"provider,omitempty"`
-	Docker         *bool    `json:"docker,omitempty"`
-	Timeout        string   `json:"timeout,omitempty"`
-	Budget         float64  `json:"budget,omitempty"`
-	PermissionMode string   `json:"permissionMode,omitempty"`
-	MCP            string   `json:"mcp,omitempty"`
-	AddDirs        []string `json:"addDirs,omitempty"`
-	ScopeBoundary  string   `json:"scopeBoundary,omitempty"` // diagnostic_only | implement_allowed | test_only | review_only
+	Prompt         string            `json:"prompt"`
+	PromptFile     string            `json:"promptFile,omitempty"` // file in ~/.tetora/prompts/ (overrides prompt)
+	PromptVars     map[string]string `json:"promptVars,omitempty"` // variables of the promptFile template
+	Workdir        string            `json:"workdir,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	Provider       string            `json:"provider,omitempty"`
+	Docker         *bool             `json:"docker,omitempty"`
+	Timeout        string            `json:"timeout,omitempty"`
+	Budget         float64           `json:"budget,omitempty"`
+	PermissionMode string            `json:"permissionMode,omitempty"`
+	MCP            string            `json:"mcp,omitempty"`
+	AddDirs        []string          `json:"addDirs,omitempty"`
+	ScopeBoundary  string            `json:"scopeBoundary,omitempty"` // diagnostic_only | implement_allowed | test_only | review_only
+	Priority       string            `json:"priority,omitempty"`      // low|normal|high|interactive; cron jobs default to low
+	ResponseCache  string            `json:"responseCache,omitempty"` // "on", a TTL ("6h") or "off"; empty = the agent's setting
 }
 
 // --- Runtime Job State ---
@@ -137,8 +149,8 @@ type Env struct {
 	// LoadAgentPrompt loads the system prompt for a named agent.
 	LoadAgentPrompt func(cfg *config.Config, agentName string) (string, error)
 
-	// ResolvePromptFile loads prompt content from a file reference.
-	ResolvePromptFile func(cfg *config.Config, promptFile string) (string, error)
+	// ResolvePromptFile renders a prompt library file with the job's variables.
+	ResolvePromptFile func(cfg *config.Config, promptFile string, vars map[string]string) (*prompttmpl.Result, error)
 
 	// ExpandPrompt expands template variables in a prompt string.
 	ExpandPrompt func(prompt, jobID, dbPath, agentName, knowledgeDir string, cfg *config.Config) string
@@ -159,7 +171,10 @@ type Env struct {
 	RunWarRoomAutoUpdate func(ctx context.Context, cfg *config.Config) error
 
 	// SendWebhooks delivers outgoing webhook notifications.
-	SendWebhooks func(cfg *config.Config, event string, payload webhook.Payload)
+	SendWebhooks func(ctx context.Context, cfg *config.Config, event string, payload webhook.Payload)
+
+	// SaveNote appends a successful run's output to the job's note.
+	SaveNote func(ctx context.Context, cfg *config.Config, note, jobName, output string) error
 
 	// NewUUID generates a new UUID string.
 	NewUUID func() string
@@ -178,6 +193,10 @@ type Env struct {
 
 	// RunSkillEvolveScan scans for high-failure-rate skills and generates LLM rewrite proposals.
 	RunSkillEvolveScan func(ctx context.Context) error
+
+	// Halted reports whether the emergency stop is engaged. While it is,
+	// no job starts. May be nil.
+	Halted func() bool
 }
 
 // --- Engine ---
@@ -215,6 +234,10 @@ type Engine struct {
 	idleCacheLast time.Time
 
 	jobsFileMtime time.Time
+
+	holidays *scheduling.HolidayCalendar
+	deferMu  sync.Mutex
+	deferred []deferredNotice // owner notices held for job quiet hours
 }
 
 // NewEngine constructs an Engine. env.Executor must be non-nil for job execution.
@@ -229,6 +252,7 @@ func NewEngine(cfg *config.Config, sem, childSem chan struct{}, notifyFn func(st
 		notifyFn: notifyFn,
 		env:      env,
 		stopCh:   make(chan struct{}),
+		holidays: scheduling.NewHolidayCalendar(cfg.WorkCalendar.RefreshOrDefault()),
 	}
 }
 
@@ -323,20 +347,32 @@ func (ce *Engine) checkJobsReload() {
 	log.Info("cron hot-reloaded jobs.json", "total", len(ce.jobs), "enabled", ce.countEnabled())
 }
 
+// ReadJobsFile reads and parses jobs.json. A missing file is reported with
+// an error satisfying os.IsNotExist.
+func ReadJobsFile(path string) (JobsFile, error) {
+	var jf JobsFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return jf, err
+		}
+		return jf, fmt.Errorf("read jobs: %w", err)
+	}
+	if err := json.Unmarshal(data, &jf); err != nil {
+		return jf, fmt.Errorf("parse jobs: %w", err)
+	}
+	return jf, nil
+}
+
 // LoadJobs reads and parses jobs.json, replacing the in-memory job list.
 func (ce *Engine) LoadJobs() error {
-	data, err := os.ReadFile(ce.cfg.JobsFile)
+	jf, err := ReadJobsFile(ce.cfg.JobsFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Info("no jobs file, starting with 0 jobs", "path", ce.cfg.JobsFile)
 			return nil
 		}
-		return fmt.Errorf("read jobs: %w", err)
-	}
-
-	var jf JobsFile
-	if err := json.Unmarshal(data, &jf); err != nil {
-		return fmt.Errorf("parse jobs: %w", err)
+		return err
 	}
 
 	ce.mu.Lock()
@@ -438,6 +474,10 @@ func (ce *Engine) Stop() {
 	}
 }
 
+func (ce *Engine) halted() bool {
+	return ce.env.Halted != nil && ce.env.Halted()
+}
+
 func (ce *Engine) checkBudget() (exceeded bool, reason string) {
 	if ce.cfg.CostAlert.DailyLimit <= 0 && ce.cfg.CostAlert.WeeklyLimit <= 0 {
 		return false, ""
@@ -610,6 +650,10 @@ func (ce *Engine) hasRunningJobs(excludeID string) bool {
 func (ce *Engine) tick(ctx context.Context) {
 	ce.checkJobsReload()
 
+	if ce.halted() {
+		return
+	}
+
 	if ce.env.QuietGlobal != nil {
 		ce.env.QuietGlobal.CheckTransition(ce.quietCfg(), ce.notifyFn)
 	}
@@ -656,6 +700,9 @@ func (ce *Engine) tick(ctx context.Context) {
 	lastFinished := ce.cachedLastFinished()
 
 	now := time.Now()
+	ce.flushDeferred(ctx, now)
+	ce.prefetchHolidays(ctx)
+
 	ce.mu.Lock()
 	defer ce.mu.Unlock()
 
@@ -846,6 +893,12 @@ func (ce *Engine) tick(ctx context.Context) {
 			continue
 		}
 
+		if why := ce.calendarSkip(j, nowLocal); why != "" {
+			j.nextRun = NextRunAfter(j.expr, j.loc, nowLocal)
+			log.Info("cron job skipped by work calendar", "jobId", j.ID, "name", j.Name, "reason", why)
+			continue
+		}
+
 		if j.IdleMinHours > 0 {
 			if ce.hasRunningJobs(j.ID) {
 				continue
@@ -865,6 +918,7 @@ func (ce *Engine) tick(ctx context.Context) {
 
 		j.runCount++
 		j.running = true
+		ce.retireOnceLocked(j)
 		jobCtx, jobCancel := context.WithCancel(ctx)
 		j.cancelFn = jobCancel
 		ce.jobWg.Add(1)
@@ -875,6 +929,19 @@ func (ce *Engine) tick(ctx context.Context) {
 	}
 }
 
+// retireOnceLocked disables a one-off job as its run starts, so a restart
+// or reload cannot fire it again. Caller must hold ce.mu.
+func (ce *Engine) retireOnceLocked(j *cronJob) {
+	if !j.Once {
+		return
+	}
+	j.Enabled = false
+	if err := ce.saveToFileLocked(); err != nil {
+		log.Warn("cron one-off job: save failed", "jobId", j.ID, "error", err)
+	}
+	log.Info("cron one-off job fired, disabled", "jobId", j.ID)
+}
+
 // countUserSessions returns the number of active user sessions.
 func (ce *Engine) countUserSessions() int {
 	return session.CountUserSessions(ce.cfg.HistoryDB)
@@ -919,6 +986,9 @@ func (ce *Engine) runWarRoomAutoUpdateAsync(ctx context.Context, j *cronJob) {
 }
 
 func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
+	if trace.IDFromContext(ctx) == "" {
+		ctx = trace.WithID(ctx, trace.NewID("cron"))
+	}
 	// Outer panic fence: declared first → runs LAST (defers are LIFO). Catches
 	// panics from both the job body and the cleanup defer below, so a crash
 	// inside ce.mu.Lock() / NextRunAfter can't escape the goroutine.
@@ -1017,6 +1087,8 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 		MCP:            j.Task.MCP,
 		AddDirs:        j.Task.AddDirs,
 		ScopeBoundary:  j.Task.ScopeBoundary,
+		Priority:       j.Task.Priority,
+		ResponseCache:  j.Task.ResponseCache,
 		Source:         jobSource,
 	}
 	if ce.env.FillDefaults != nil {
@@ -1048,10 +1120,17 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 
 	// Resolve promptFile if specified.
 	if j.Task.PromptFile != "" && ce.env.ResolvePromptFile != nil {
-		if content, err := ce.env.ResolvePromptFile(ce.cfg, j.Task.PromptFile); err != nil {
+		if res, err := ce.env.ResolvePromptFile(ce.cfg, j.Task.PromptFile, j.Task.PromptVars); err != nil {
 			log.WarnCtx(ctx, "cron job promptFile error", "jobId", j.ID, "promptFile", j.Task.PromptFile, "error", err)
-		} else if content != "" {
-			task.Prompt = content
+		} else if res.Text != "" {
+			task.Prompt = res.Text
+			// The prompt's frontmatter model applies unless the job sets one.
+			if j.Task.Model == "" && res.Model != "" {
+				task.Model = res.Model
+			}
+			if res.Role != "" && j.Agent != "" && res.Role != j.Agent {
+				log.WarnCtx(ctx, "cron job agent differs from prompt role", "jobId", j.ID, "agent", j.Agent, "role", res.Role)
+			}
 		}
 	}
 
@@ -1243,6 +1322,13 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 	}
 	ce.mu.Unlock()
 
+	// Briefings and reports kept as notes.
+	if j.Note != "" && result.Status == "success" && result.Output != "" && ce.env.SaveNote != nil {
+		if err := ce.env.SaveNote(ctx, ce.cfg, j.Note, j.Name, result.Output); err != nil {
+			log.WarnCtx(ctx, "cron note save failed", "jobId", j.ID, "note", j.Note, "error", err)
+		}
+	}
+
 	// Notifications.
 	cronNotifyEnabled := ce.cfg.CronNotify == nil || *ce.cfg.CronNotify
 	if cronNotifyEnabled {
@@ -1257,15 +1343,7 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 				msg = fmt.Sprintf("Cron %s\n%s (%s)\nError: %s",
 					j.Name, result.Status, dur.Round(time.Second), truncate(result.Error, 300))
 			}
-
-			qcfg := ce.quietCfg()
-			if quiet.IsQuietHours(qcfg) {
-				if ce.cfg.QuietHours.Digest && ce.env.QuietGlobal != nil {
-					ce.env.QuietGlobal.Enqueue(msg)
-				}
-			} else {
-				ce.notifyFn(msg)
-			}
+			ce.notifyJobOwner(ctx, j, msg)
 		}
 
 		if j.NotifyChannel != "" {
@@ -1283,15 +1361,23 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 			if strings.HasPrefix(channelName, "id:") {
 				channelID := strings.TrimPrefix(channelName, "id:")
 				if ce.cfg.Discord.Enabled && ce.cfg.Discord.BotToken != "" {
+					end := trace.Start(ctx, trace.KindNotify, "discord:"+channelID)
 					if err := discordSendBotChannel(ce.cfg.Discord.BotToken, channelID, msg); err != nil {
 						log.WarnCtx(ctx, "cron discord notify failed", "jobId", j.ID, "channel", channelID, "error", err)
+						end("error", err.Error())
+					} else {
+						end("sent", "")
 					}
 				}
 			} else {
 				for _, ch := range ce.cfg.Notifications {
 					if ch.Type == "discord" && ch.Name == channelName {
+						end := trace.Start(ctx, trace.KindNotify, "discord:"+channelName)
 						if err := discordSendWebhook(ch.WebhookURL, msg); err != nil {
 							log.WarnCtx(ctx, "cron discord notify failed", "jobId", j.ID, "channel", channelName, "error", err)
+							end("error", err.Error())
+						} else {
+							end("sent", "")
 						}
 						break
 					}
@@ -1300,7 +1386,7 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 		}
 
 		if ce.env.SendWebhooks != nil {
-			ce.env.SendWebhooks(ce.cfg, result.Status, webhook.Payload{
+			ce.env.SendWebhooks(ctx, ce.cfg, result.Status, webhook.Payload{
 				JobID:    j.ID,
 				Name:     j.Name,
 				Source:   "cron",
@@ -1327,7 +1413,7 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 		} else {
 			for _, targetID := range chainTargets {
 				log.InfoCtx(ctx, "cron job chain trigger", "jobId", j.ID, "target", targetID, "depth", j.chainDepth+1)
-				audit.Log(ce.cfg.HistoryDB, "job.chain", "cron",
+				audit.LogCtx(ctx, ce.cfg.HistoryDB, "job.chain", "cron",
 					fmt.Sprintf("%s → %s (depth=%d, trigger=%s)", j.ID, targetID, j.chainDepth+1, result.Status), "")
 				if err := ce.runChainJob(ce.ctx, targetID, j.chainDepth+1); err != nil {
 					log.ErrorCtx(ctx, "cron chain trigger failed", "target", targetID, "error", err)
@@ -1339,6 +1425,9 @@ func (ce *Engine) runJob(ctx context.Context, j *cronJob) {
 
 // RunJobByID manually triggers a cron job.
 func (ce *Engine) RunJobByID(_ context.Context, id string) error {
+	if ce.halted() {
+		return fmt.Errorf("emergency stop engaged")
+	}
 	ce.mu.Lock()
 	var target *cronJob
 	for _, j := range ce.jobs {
@@ -1595,6 +1684,9 @@ func (ce *Engine) AddJob(jc JobConfig) error {
 	if err != nil {
 		return fmt.Errorf("bad schedule: %w", err)
 	}
+	if err := ValidateCalendar(jc); err != nil {
+		return err
+	}
 
 	ce.mu.Lock()
 	defer ce.mu.Unlock()
@@ -1633,6 +1725,9 @@ func (ce *Engine) UpdateJob(id string, jc JobConfig) error {
 	if err != nil {
 		return fmt.Errorf("bad schedule: %w", err)
 	}
+	if err := ValidateCalendar(jc); err != nil {
+		return err
+	}
 
 	ce.mu.Lock()
 	defer ce.mu.Unlock()
@@ -1730,6 +1825,7 @@ func (ce *Engine) StartupReplay(ctx context.Context) {
 	ce.mu.RUnlock()
 
 	var missed []*cronJob
+	slots := map[*cronJob]time.Time{}
 	for _, j := range jobs {
 		if !j.Enabled {
 			continue
@@ -1756,6 +1852,7 @@ func (ce *Engine) StartupReplay(ctx context.Context) {
 		log.Info("cron startup replay: missed run detected",
 			"jobId", j.ID, "name", j.Name, "scheduledAt", scheduledAt.Format(time.RFC3339))
 		missed = append(missed, j)
+		slots[j] = scheduledAt
 	}
 
 	if len(missed) == 0 {
@@ -1770,6 +1867,7 @@ func (ce *Engine) StartupReplay(ctx context.Context) {
 			return
 		case <-time.After(30 * time.Second):
 		}
+		ce.prefetchHolidays(ctx)
 
 		for _, j := range missed {
 			select {
@@ -1777,6 +1875,14 @@ func (ce *Engine) StartupReplay(ctx context.Context) {
 				return
 			default:
 			}
+			ce.mu.RLock()
+			why := ce.calendarSkip(j, slots[j].In(j.loc))
+			ce.mu.RUnlock()
+			if why != "" {
+				log.Info("cron startup replay: missed run falls outside work calendar, skipping",
+					"jobId", j.ID, "reason", why)
+				continue
+			}
 
 			ce.mu.Lock()
 			maxRuns := j.effectiveMaxConcurrentRuns()
@@ -1789,6 +1895,7 @@ func (ce *Engine) StartupReplay(ctx context.Context) {
 			j.runCount++
 			j.running = true
 			j.replayed = true
+			ce.retireOnceLocked(j)
 			jobCtx, jobCancel := context.WithCancel(ctx)
 			j.cancelFn = jobCancel
 			ce.mu.Unlock()
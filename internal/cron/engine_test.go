@@ -1,8 +1,15 @@
 package cron
 
 import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"tetora/internal/config"
 )
 
 // TestSameMinuteSuppression verifies the sameMinute guard logic used to
@@ -84,3 +91,126 @@ func TestSameMinuteSuppression(t *testing.T) {
 		})
 	}
 }
+
+func TestRetireOnce(t *testing.T) {
+	cfg := &config.Config{JobsFile: filepath.Join(t.TempDir(), "jobs.json")}
+	ce := NewEngine(cfg, nil, nil, nil, Env{})
+	at := time.Now().Add(time.Hour)
+	for _, jc := range []JobConfig{
+		{ID: "remind", Enabled: true, Schedule: PinExpr(at), Once: true},
+		{ID: "daily", Enabled: true, Schedule: "0 9 * * *"},
+	} {
+		if err := ce.AddJob(jc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ce.mu.Lock()
+	for _, j := range ce.jobs {
+		ce.retireOnceLocked(j)
+	}
+	ce.mu.Unlock()
+
+	data, _ := os.ReadFile(cfg.JobsFile)
+	var jf JobsFile
+	if err := json.Unmarshal(data, &jf); err != nil {
+		t.Fatal(err)
+	}
+	if len(jf.Jobs) != 2 || jf.Jobs[0].Enabled || !jf.Jobs[1].Enabled {
+		t.Errorf("saved jobs = %+v", jf.Jobs)
+	}
+}
+
+func TestCalendarSkip(t *testing.T) {
+	cfg := &config.Config{
+		WorkCalendar: config.WorkCalendarConfig{
+			Holidays: "JP",
+			Users: map[string]config.WorkCalendarUser{
+				"mia": {Holidays: "TW", Weekend: []string{"fri", "sat"}, QuietHours: &config.QuietWindow{Start: "21:00", End: "07:00"}},
+			},
+		},
+		Family: config.FamilyConfig{Enabled: true},
+	}
+	ce := NewEngine(cfg, nil, nil, nil, Env{})
+	ce.holidays.Fetch = func(ctx context.Context, src string) ([]byte, error) {
+		day := "20261103" // Culture Day
+		if strings.Contains(src, "taiwan") {
+			day = "20261109"
+		}
+		return []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:" + day + "\r\nSUMMARY:Holiday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), nil
+	}
+	for _, src := range []string{"JP", "TW"} {
+		if err := ce.holidays.Ensure(context.Background(), src); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := func(mo time.Month, d int) time.Time { return time.Date(2026, mo, d, 9, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		job  JobConfig
+		at   time.Time
+		skip bool
+	}{
+		{"weekday", JobConfig{BusinessDaysOnly: true}, day(11, 2), false},
+		{"saturday", JobConfig{BusinessDaysOnly: true}, day(11, 7), true},
+		{"holiday", JobConfig{BusinessDaysOnly: true}, day(11, 3), true},
+		{"plain job on holiday", JobConfig{}, day(11, 3), false},
+		{"holidays only", JobConfig{Holidays: "JP"}, day(11, 3), true},
+		{"holidays only on saturday", JobConfig{Holidays: "JP"}, day(11, 7), false},
+		{"user weekend", JobConfig{BusinessDaysOnly: true, User: "mia"}, day(11, 6), true},
+		{"user sunday", JobConfig{BusinessDaysOnly: true, User: "mia"}, day(11, 8), false},
+		{"user holidays", JobConfig{BusinessDaysOnly: true, User: "mia"}, day(11, 9), true},
+		{"not the user's holiday", JobConfig{BusinessDaysOnly: true, User: "mia"}, day(11, 3), false},
+		{"job overrides user", JobConfig{BusinessDaysOnly: true, User: "mia", Holidays: "JP"}, day(11, 3), true},
+	}
+	for _, tc := range tests {
+		j := &cronJob{JobConfig: tc.job, loc: time.UTC}
+		if got := ce.calendarSkip(j, tc.at) != ""; got != tc.skip {
+			t.Errorf("%s: skip = %v, want %v", tc.name, got, tc.skip)
+		}
+	}
+
+	cfg.Family.Enabled = false
+	if ce.calendarSkip(&cronJob{JobConfig: JobConfig{BusinessDaysOnly: true, User: "mia"}, loc: time.UTC}, day(11, 8)) == "" {
+		t.Error("user overrides applied with family mode off")
+	}
+
+	if err := ValidateCalendar(JobConfig{QuietHours: &config.QuietWindow{Start: "22:00"}}); err == nil {
+		t.Error("quiet hours without end accepted")
+	}
+	if err := ValidateCalendar(JobConfig{Holidays: "Atlantis"}); err == nil {
+		t.Error("unknown holiday calendar accepted")
+	}
+}
+
+func TestDeferredNotify(t *testing.T) {
+	var sent []string
+	ce := NewEngine(&config.Config{}, nil, nil, func(msg string) { sent = append(sent, msg) }, Env{})
+	now := time.Now().UTC()
+	// A quiet window that covers now and ends within the hour.
+	quietHours := &config.QuietWindow{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(30 * time.Minute).Format("15:04"),
+	}
+	j := &cronJob{JobConfig: JobConfig{ID: "report", QuietHours: quietHours}, loc: time.UTC}
+
+	ce.notifyJobOwner(context.Background(), j, "done")
+	if len(sent) != 0 || len(ce.deferred) != 1 {
+		t.Fatalf("sent = %v, deferred = %d", sent, len(ce.deferred))
+	}
+	ce.flushDeferred(context.Background(), now)
+	if len(sent) != 0 {
+		t.Error("flushed before quiet hours ended")
+	}
+	ce.flushDeferred(context.Background(), now.Add(31*time.Minute))
+	if len(sent) != 1 || sent[0] != "done" || len(ce.deferred) != 0 {
+		t.Errorf("sent = %v, deferred = %d", sent, len(ce.deferred))
+	}
+
+	j.QuietHours = nil
+	ce.notifyJobOwner(context.Background(), j, "now")
+	if len(sent) != 2 {
+		t.Errorf("notification without quiet hours held: %v", sent)
+	}
+}
@@ -0,0 +1,520 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrecognized is returned by ParseWhen for phrases it cannot read.
+// Callers may fall back to a model; other errors describe a phrase that was
+// read but cannot be scheduled.
+var ErrUnrecognized = errors.New("schedule not recognized")
+
+// When is a schedule read from a natural-language phrase.
+type When struct {
+	Cron    string    // 5-field expression
+	At      time.Time // one-off time; Cron then matches only its minute and date
+	Summary string    // how the phrase was read, e.g. "every weekday at 09:00"
+}
+
+// Once reports whether the schedule is a one-off time.
+func (w When) Once() bool { return !w.At.IsZero() }
+
+// Next returns the first run after now, in now's location.
+func (w When) Next(now time.Time) time.Time {
+	if w.Once() {
+		return w.At
+	}
+	expr, err := Parse(w.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return NextRunAfter(expr, now.Location(), now)
+}
+
+// PinExpr returns the expression matching t's minute on t's date. A job on it
+// fires once a year, so one-off jobs pair it with JobConfig.Once.
+func PinExpr(t time.Time) string {
+	return fmt.Sprintf("%d %d %d %d *", t.Minute(), t.Hour(), t.Day(), int(t.Month()))
+}
+
+// maxAhead bounds one-off times to what NextRunAfter can find.
+const maxAhead = 365 * 24 * time.Hour
+
+var (
+	relativeRe = regexp.MustCompile(`^in (an?|half an|\d+) ?(minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w)$`)
+	minutesRe  = regexp.MustCompile(`^every (?:(\d+) )?(?:minutes?|mins?)$`)
+	hoursRe    = regexp.MustCompile(`^(?:every (?:(\d+) )?(?:hours?|hrs?)|hourly)(?: at :(\d{2}))?$`)
+	clockRe    = regexp.MustCompile(`(?:\bat )?\b(noon|midnight|\d{1,2}(?::\d{2})? ?(?:am|pm)|\d{1,2}:\d{2})\b|\bat (\d{1,2})\b`)
+	monthlyRe  = regexp.MustCompile(`^(?:(?:every|each) month|monthly)(?: on(?: the)? (\d{1,2})(?:st|nd|rd|th)?)?$|^(?:on )?the (\d{1,2})(?:st|nd|rd|th)? of (?:every|each) month$`)
+	nthDayRe   = regexp.MustCompile(`^(?:on )?(?:the |every )?(first|second|third|fourth|1st|2nd|3rd|4th) ([a-z]+) of (?:the|every|each) month$`)
+	isoDateRe  = regexp.MustCompile(`^(?:on )?(\d{4})-(\d{2})-(\d{2})$`)
+	monthDayRe = regexp.MustCompile(`^(?:on )?([a-z]+) (\d{1,2})(?:st|nd|rd|th)?(?: (\d{4}))?$`)
+	dayMonthRe = regexp.MustCompile(`^(?:on )?(?:the )?(\d{1,2})(?:st|nd|rd|th)? (?:of )?([a-z]+)(?: (\d{4}))?$`)
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var monthNames = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var partsOfDay = map[string]int{"morning": 9, "afternoon": 14, "evening": 18, "night": 21}
+
+var ordinals = map[string]int{"first": 1, "1st": 1, "second": 2, "2nd": 2, "third": 3, "3rd": 3, "fourth": 4, "4th": 4}
+
+type clock struct{ hour, minute int }
+
+func (c clock) String() string { return fmt.Sprintf("%02d:%02d", c.hour, c.minute) }
+
+// ParseWhen reads an English schedule phrase relative to now, whose location
+// is used for clock times. It understands recurring phrases ("every weekday
+// at 9am", "every 15 minutes", "first Monday of the month", "on the 1st of
+// every month at 8:30") and one-off ones ("in 20 minutes", "tomorrow at
+// noon", "next friday 5pm", "on 2026-12-24 at 18:00"). Recurring days
+// without a time default to 09:00. A plain cron expression passes through.
+func ParseWhen(text string, now time.Time) (When, error) {
+	s := normalizeWhen(text)
+	if s == "" {
+		return When{}, ErrUnrecognized
+	}
+	if len(strings.Fields(s)) == 5 {
+		if w, err := FromCron(s); err == nil {
+			return w, nil
+		}
+	}
+	now = now.Truncate(time.Second)
+	if w, ok, err := parseRelative(s, now); ok {
+		return w, err
+	}
+	if w, ok, err := parseInterval(s); ok {
+		return w, err
+	}
+
+	rest, clocks, err := extractClocks(s)
+	if err != nil {
+		return When{}, err
+	}
+	rest, partHour := cutPartOfDay(rest)
+	if len(clocks) == 0 && partHour >= 0 {
+		clocks = []clock{{hour: partHour}}
+	}
+	if w, ok, err := parseRecurring(rest, clocks); ok {
+		return w, err
+	}
+	return parseOneOff(rest, clocks, now)
+}
+
+func normalizeWhen(text string) string {
+	s := strings.ToLower(text)
+	s = strings.NewReplacer("a.m.", "am", "p.m.", "pm", "o'clock", "", ",", " ").Replace(s)
+	s = strings.TrimRight(strings.TrimSpace(s), ".!?")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func parseRelative(s string, now time.Time) (When, bool, error) {
+	m := relativeRe.FindStringSubmatch(s)
+	if m == nil {
+		return When{}, false, nil
+	}
+	unit := time.Minute
+	switch m[2][0] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	switch m[1] {
+	case "a", "an":
+		d = unit
+	case "half an":
+		d = unit / 2
+	default:
+		n, _ := strconv.Atoi(m[1])
+		if n <= 0 || time.Duration(n) > maxAhead/unit {
+			return When{}, true, fmt.Errorf("%q is out of range", s)
+		}
+		d = time.Duration(n) * unit
+	}
+	w, err := OnceAt(now.Add(d).Round(time.Minute), now)
+	if err == nil {
+		w.Summary += " (" + s + ")"
+	}
+	return w, true, err
+}
+
+func parseInterval(s string) (When, bool, error) {
+	if m := minutesRe.FindStringSubmatch(s); m != nil {
+		n := 1
+		if m[1] != "" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		switch {
+		case n == 1:
+			return When{Cron: "* * * * *", Summary: "every minute"}, true, nil
+		case n <= 0 || n >= 60 || 60%n != 0:
+			return When{}, true, fmt.Errorf("every %d minutes does not divide the hour; use a cron expression", n)
+		}
+		return When{Cron: fmt.Sprintf("*/%d * * * *", n), Summary: fmt.Sprintf("every %d minutes", n)}, true, nil
+	}
+	if m := hoursRe.FindStringSubmatch(s); m != nil {
+		n, minute := 1, 0
+		if m[1] != "" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		if m[2] != "" {
+			minute, _ = strconv.Atoi(m[2])
+		}
+		if n <= 0 || n >= 24 || 24%n != 0 {
+			return When{}, true, fmt.Errorf("every %d hours does not divide the day; use a cron expression", n)
+		}
+		if minute > 59 {
+			return When{}, true, fmt.Errorf("bad minute :%02d", minute)
+		}
+		w := When{Cron: fmt.Sprintf("%d * * * *", minute), Summary: "every hour"}
+		if n > 1 {
+			w = When{Cron: fmt.Sprintf("%d */%d * * *", minute, n), Summary: fmt.Sprintf("every %d hours", n)}
+		}
+		if minute > 0 {
+			w.Summary += fmt.Sprintf(" at :%02d", minute)
+		}
+		return w, true, nil
+	}
+	return When{}, false, nil
+}
+
+// extractClocks removes clock times ("at 9am", "17:30", "noon") from s.
+func extractClocks(s string) (string, []clock, error) {
+	var clocks []clock
+	for _, m := range clockRe.FindAllStringSubmatch(s, -1) {
+		raw := m[1]
+		if raw == "" {
+			raw = m[2]
+		}
+		c, err := parseClock(raw)
+		if err != nil {
+			return "", nil, err
+		}
+		clocks = append(clocks, c)
+	}
+	rest := clockRe.ReplaceAllString(s, " ")
+	rest = strings.Join(strings.Fields(rest), " ")
+	rest = strings.TrimSuffix(strings.TrimSuffix(rest, " and"), " at")
+	return rest, clocks, nil
+}
+
+func parseClock(s string) (clock, error) {
+	switch s {
+	case "noon":
+		return clock{hour: 12}, nil
+	case "midnight":
+		return clock{}, nil
+	}
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		suffix = s[len(s)-2:]
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	hs, ms, _ := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return clock{}, fmt.Errorf("bad time %q", s)
+	}
+	m := 0
+	if ms != "" {
+		if m, err = strconv.Atoi(ms); err != nil || m > 59 {
+			return clock{}, fmt.Errorf("bad time %q", s+suffix)
+		}
+	}
+	if suffix != "" {
+		if h < 1 || h > 12 {
+			return clock{}, fmt.Errorf("bad time %q", s+suffix)
+		}
+		h %= 12
+		if suffix == "pm" {
+			h += 12
+		}
+	} else if h > 23 {
+		return clock{}, fmt.Errorf("bad time %q", s)
+	}
+	return clock{hour: h, minute: m}, nil
+}
+
+// cutPartOfDay strips a trailing "morning", "in the evening" and so on, and
+// returns its default hour (-1 when there is none).
+func cutPartOfDay(s string) (string, int) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return s, -1
+	}
+	h, ok := partsOfDay[words[len(words)-1]]
+	if !ok {
+		return s, -1
+	}
+	words = words[:len(words)-1]
+	if n := len(words); n >= 2 && words[n-2] == "in" && words[n-1] == "the" {
+		words = words[:n-2]
+	}
+	return strings.Join(words, " "), h
+}
+
+func parseRecurring(s string, clocks []clock) (When, bool, error) {
+	dom, dow, days := "*", "*", ""
+	switch s {
+	case "every", "every day", "everyday", "each day", "daily":
+		days = "every day"
+	case "every weekday", "weekdays", "on weekdays", "every workday":
+		dow, days = "1-5", "every weekday"
+	case "every weekend", "weekends", "on weekends", "every weekend day":
+		dow, days = "0,6", "every Saturday and Sunday"
+	default:
+		if list, ok := parseWeekdayList(s); ok {
+			dow, days = list.cron(), "every "+list.String()
+		} else if m := monthlyRe.FindStringSubmatch(s); m != nil {
+			d := 1
+			if n := m[1] + m[2]; n != "" {
+				d, _ = strconv.Atoi(n)
+			}
+			if d < 1 || d > 31 {
+				return When{}, true, fmt.Errorf("bad day of month %d", d)
+			}
+			dom, days = strconv.Itoa(d), fmt.Sprintf("on day %d of every month", d)
+		} else if m := nthDayRe.FindStringSubmatch(s); m != nil {
+			wd, ok := weekdayNames[m[2]]
+			if !ok {
+				return When{}, false, nil
+			}
+			n := ordinals[m[1]]
+			dom, dow = fmt.Sprintf("%d-%d", 7*n-6, 7*n), strconv.Itoa(int(wd))
+			days = fmt.Sprintf("on the %s %s of every month", ordinalWord(n), wd)
+		} else {
+			return When{}, false, nil
+		}
+	}
+
+	if len(clocks) == 0 {
+		clocks = []clock{{hour: 9}}
+	}
+	hours := make([]string, len(clocks))
+	times := make([]string, len(clocks))
+	for i, c := range clocks {
+		if c.minute != clocks[0].minute {
+			return When{}, true, fmt.Errorf("times %s and %s need separate jobs", clocks[0], c)
+		}
+		hours[i] = strconv.Itoa(c.hour)
+		times[i] = c.String()
+	}
+	return When{
+		Cron:    fmt.Sprintf("%d %s %s * %s", clocks[0].minute, strings.Join(hours, ","), dom, dow),
+		Summary: days + " at " + joinAnd(times),
+	}, true, nil
+}
+
+type weekdaySet []time.Weekday
+
+func (ws weekdaySet) cron() string {
+	parts := make([]string, len(ws))
+	for i, d := range ws {
+		parts[i] = strconv.Itoa(int(d))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (ws weekdaySet) String() string {
+	names := make([]string, len(ws))
+	for i, d := range ws {
+		names[i] = d.String()
+	}
+	return joinAnd(names)
+}
+
+// parseWeekdayList reads "every monday and thursday", "on fridays" or
+// "tue thu". Singular names need "every" or "each"; a bare "monday" is a
+// one-off date.
+func parseWeekdayList(s string) (weekdaySet, bool) {
+	words := strings.Fields(s)
+	recurring := false
+	if len(words) > 0 && (words[0] == "every" || words[0] == "each" || words[0] == "on") {
+		recurring = words[0] != "on"
+		words = words[1:]
+	}
+	seen := map[time.Weekday]bool{}
+	var set weekdaySet
+	for _, w := range words {
+		if w == "and" || w == "&" {
+			continue
+		}
+		name := w
+		if strings.HasSuffix(w, "days") {
+			name = strings.TrimSuffix(w, "s")
+			recurring = true
+		}
+		d, ok := weekdayNames[name]
+		if !ok {
+			return nil, false
+		}
+		if !seen[d] {
+			seen[d] = true
+			set = append(set, d)
+		}
+	}
+	if len(set) == 0 || !recurring {
+		return nil, false
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	return set, true
+}
+
+func parseOneOff(s string, clocks []clock, now time.Time) (When, error) {
+	if len(clocks) > 1 {
+		return When{}, fmt.Errorf("%w: a one-off time takes a single clock time", ErrUnrecognized)
+	}
+	c, hasTime := clock{hour: 9}, len(clocks) == 1
+	if hasTime {
+		c = clocks[0]
+	}
+	loc := now.Location()
+	y, mo, d := now.Date()
+	at := func(y int, mo time.Month, d int) time.Time { return time.Date(y, mo, d, c.hour, c.minute, 0, 0, loc) }
+
+	switch {
+	case s == "" || s == "today":
+		if !hasTime {
+			return When{}, ErrUnrecognized
+		}
+		t := at(y, mo, d)
+		if s == "" && !t.After(now) {
+			t = at(y, mo, d+1)
+		}
+		return OnceAt(t, now)
+	case s == "tonight":
+		if !hasTime {
+			c = clock{hour: 21}
+		}
+		return OnceAt(at(y, mo, d), now)
+	case s == "tomorrow":
+		return OnceAt(at(y, mo, d+1), now)
+	case s == "the day after tomorrow" || s == "day after tomorrow":
+		return OnceAt(at(y, mo, d+2), now)
+	}
+
+	words := strings.Fields(s)
+	if len(words) <= 2 {
+		next := false
+		if len(words) == 2 {
+			switch words[0] {
+			case "next":
+				next = true
+			case "on", "this":
+			default:
+				words = nil
+			}
+			if words != nil {
+				words = words[1:]
+			}
+		}
+		if len(words) == 1 {
+			if wd, ok := weekdayNames[words[0]]; ok {
+				ahead := (int(wd) - int(now.Weekday()) + 7) % 7
+				t := at(y, mo, d+ahead)
+				if next && ahead == 0 || !t.After(now) {
+					t = at(y, mo, d+ahead+7)
+				}
+				return OnceAt(t, now)
+			}
+		}
+	}
+
+	if m := isoDateRe.FindStringSubmatch(s); m != nil {
+		yy, _ := strconv.Atoi(m[1])
+		mm, _ := strconv.Atoi(m[2])
+		dd, _ := strconv.Atoi(m[3])
+		return onDate(yy, time.Month(mm), dd, true, at, now)
+	}
+	var monthName, dayStr, yearStr string
+	if m := monthDayRe.FindStringSubmatch(s); m != nil {
+		monthName, dayStr, yearStr = m[1], m[2], m[3]
+	} else if m := dayMonthRe.FindStringSubmatch(s); m != nil {
+		dayStr, monthName, yearStr = m[1], m[2], m[3]
+	}
+	if month, ok := monthNames[monthName]; ok {
+		dd, _ := strconv.Atoi(dayStr)
+		if yearStr == "" {
+			return onDate(y, month, dd, false, at, now)
+		}
+		yy, _ := strconv.Atoi(yearStr)
+		return onDate(yy, month, dd, true, at, now)
+	}
+	return When{}, ErrUnrecognized
+}
+
+// onDate schedules a calendar date; without an explicit year a date that
+// has passed means next year's.
+func onDate(y int, mo time.Month, d int, yearGiven bool, at func(int, time.Month, int) time.Time, now time.Time) (When, error) {
+	t := at(y, mo, d)
+	if t.Month() != mo || t.Day() != d {
+		return When{}, fmt.Errorf("no such date: %s %d", mo, d)
+	}
+	if !yearGiven && !t.After(now) {
+		t = at(y+1, mo, d)
+	}
+	return OnceAt(t, now)
+}
+
+// FromCron wraps a cron expression, checking that it parses.
+func FromCron(expr string) (When, error) {
+	expr = strings.Join(strings.Fields(expr), " ")
+	if _, err := Parse(expr); err != nil {
+		return When{}, err
+	}
+	return When{Cron: expr, Summary: "on cron schedule " + expr}, nil
+}
+
+// OnceAt is a one-off schedule at t, which must lie within a year after now.
+func OnceAt(t, now time.Time) (When, error) {
+	switch {
+	case !t.After(now):
+		return When{}, fmt.Errorf("%s is in the past", t.Format("Mon 2 Jan 2006 15:04"))
+	case t.Sub(now) > maxAhead:
+		return When{}, fmt.Errorf("%s is more than a year ahead", t.Format("2 Jan 2006"))
+	}
+	return When{Cron: PinExpr(t), At: t, Summary: "once on " + t.Format("Mon 2 Jan 2006 at 15:04")}, nil
+}
+
+func ordinalWord(n int) string {
+	return [...]string{"", "first", "second", "third", "fourth"}[n]
+}
+
+func joinAnd(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
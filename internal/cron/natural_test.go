@@ -0,0 +1,111 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWhenRecurring(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Taipei")
+	now := time.Date(2026, 10, 15, 10, 0, 40, 0, loc) // Thursday
+
+	tests := []struct {
+		in, cron, summary string
+	}{
+		{"every weekday at 9am", "0 9 * * 1-5", "every weekday at 09:00"},
+		{"Every day at 17:30.", "30 17 * * *", "every day at 17:30"},
+		{"every morning", "0 9 * * *", "every day at 09:00"},
+		{"at 7:15 pm every weekend", "15 19 * * 0,6", "every Saturday and Sunday at 19:15"},
+		{"every monday, wednesday and friday at noon", "0 12 * * 1,3,5", "every Monday, Wednesday and Friday at 12:00"},
+		{"on fridays 6pm", "0 18 * * 5", "every Friday at 18:00"},
+		{"every tue and thu at 9am and 5pm", "0 9,17 * * 2,4", "every Tuesday and Thursday at 09:00 and 17:00"},
+		{"first Monday of the month", "0 9 1-7 * 1", "on the first Monday of every month at 09:00"},
+		{"the 3rd friday of every month at 10:30", "30 10 15-21 * 5", "on the third Friday of every month at 10:30"},
+		{"monthly on the 15th at 8am", "0 8 15 * *", "on day 15 of every month at 08:00"},
+		{"on the 1st of every month", "0 9 1 * *", "on day 1 of every month at 09:00"},
+		{"every 15 minutes", "*/15 * * * *", "every 15 minutes"},
+		{"every minute", "* * * * *", "every minute"},
+		{"hourly at :05", "5 * * * *", "every hour at :05"},
+		{"every 6 hours", "0 */6 * * *", "every 6 hours"},
+		{"30 8 * * 1-5", "30 8 * * 1-5", "on cron schedule 30 8 * * 1-5"},
+	}
+	for _, tc := range tests {
+		w, err := ParseWhen(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseWhen(%q): %v", tc.in, err)
+			continue
+		}
+		if w.Once() || w.Cron != tc.cron || w.Summary != tc.summary {
+			t.Errorf("ParseWhen(%q) = %q %q, want %q %q", tc.in, w.Cron, w.Summary, tc.cron, tc.summary)
+		}
+		if _, err := Parse(w.Cron); err != nil {
+			t.Errorf("ParseWhen(%q) produced invalid cron %q: %v", tc.in, w.Cron, err)
+		}
+	}
+
+	w, _ := ParseWhen("first monday of the month at 9am", now)
+	if next := w.Next(now); !next.Equal(time.Date(2026, 11, 2, 9, 0, 0, 0, loc)) {
+		t.Errorf("next first Monday = %v", next)
+	}
+}
+
+func TestParseWhenOnce(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Taipei")
+	now := time.Date(2026, 10, 15, 10, 0, 40, 0, loc) // Thursday
+	at := func(mo time.Month, d, h, m int) time.Time { return time.Date(2026, mo, d, h, m, 0, 0, loc) }
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"in 20 minutes", at(10, 15, 10, 21)},
+		{"in an hour", at(10, 15, 11, 1)},
+		{"in half an hour", at(10, 15, 10, 31)},
+		{"in 2 days", at(10, 17, 10, 1)},
+		{"at 9am", at(10, 16, 9, 0)},
+		{"at 14:00", at(10, 15, 14, 0)},
+		{"today at 5 pm", at(10, 15, 17, 0)},
+		{"tonight", at(10, 15, 21, 0)},
+		{"tomorrow", at(10, 16, 9, 0)},
+		{"tomorrow evening", at(10, 16, 18, 0)},
+		{"tomorrow at midnight", at(10, 16, 0, 0)},
+		{"next friday 5pm", at(10, 16, 17, 0)},
+		{"on thursday at 9", at(10, 22, 9, 0)},
+		{"next thursday", at(10, 22, 9, 0)},
+		{"on 2026-12-24 at 18:00", at(12, 24, 18, 0)},
+		{"nov 3rd at 10am", at(11, 3, 10, 0)},
+		{"3 november", at(11, 3, 9, 0)},
+		{"march 1", time.Date(2027, 3, 1, 9, 0, 0, 0, loc)},
+	}
+	for _, tc := range tests {
+		w, err := ParseWhen(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseWhen(%q): %v", tc.in, err)
+			continue
+		}
+		if !w.At.Equal(tc.want) || w.Cron != PinExpr(tc.want) {
+			t.Errorf("ParseWhen(%q) = %v %q, want %v", tc.in, w.At, w.Cron, tc.want)
+		}
+	}
+
+	w, _ := ParseWhen("in 20 minutes", now)
+	if w.Summary != "once on Thu 15 Oct 2026 at 10:21 (in 20 minutes)" {
+		t.Errorf("summary = %q", w.Summary)
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	now := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	for _, in := range []string{"", "whenever you like", "last friday of the month", "every fortnight", "monday at 9 and 10"} {
+		if _, err := ParseWhen(in, now); !errors.Is(err, ErrUnrecognized) {
+			t.Errorf("ParseWhen(%q) = %v, want ErrUnrecognized", in, err)
+		}
+	}
+	// Read but not schedulable: no model fallback.
+	for _, in := range []string{"every 7 minutes", "every 5 hours", "today at 9am", "on 2025-01-01", "feb 30", "in 400 days", "at 25:00", "every day at 9am and 5:30pm"} {
+		if _, err := ParseWhen(in, now); err == nil || errors.Is(err, ErrUnrecognized) {
+			t.Errorf("ParseWhen(%q) = %v, want a specific error", in, err)
+		}
+	}
+}
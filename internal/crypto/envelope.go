@@ -0,0 +1,310 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Envelope encryption: values are sealed with a random data key, and data
+// keys are stored wrapped (encrypted) with a master key kept outside the
+// database. Replacing the master key only rewraps the data keys; rotating
+// the data key re-encrypts the values.
+//
+// A sealed string is "tenc1:<key id>:<base64 nonce+ciphertext>". A sealed
+// file starts with "TENC1", the key id length and the key id, followed by
+// the nonce and ciphertext.
+
+const (
+	sealedPrefix = "tenc1:"
+	fileMagic    = "TENC1"
+)
+
+// ErrUnknownKey is returned when a value was sealed with a data key the
+// keyring does not hold.
+var ErrUnknownKey = errors.New("sealed with an unknown data key")
+
+// DataKey is a data key as stored: wrapped with the master key.
+type DataKey struct {
+	ID        string    `json:"id"`
+	Wrapped   string    `json:"wrapped"`
+	CreatedAt time.Time `json:"createdAt"`
+	Active    bool      `json:"active"`
+}
+
+// Keyring holds unwrapped data keys. New values are sealed with the active
+// key; any key in the ring opens values sealed with it.
+type Keyring struct {
+	mu     sync.RWMutex
+	master cipher.AEAD
+	keys   map[string]cipher.AEAD
+	raw    map[string][]byte
+	active string
+}
+
+// NewKeyring unwraps keys with master. It fails if master is empty or
+// does not unwrap every key, which means it is not the key they were
+// wrapped with.
+func NewKeyring(master string, keys []DataKey) (*Keyring, error) {
+	if master == "" {
+		return nil, errors.New("empty master key")
+	}
+	m, err := newGCM(sha256.Sum256([]byte(master)))
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{master: m, keys: map[string]cipher.AEAD{}, raw: map[string][]byte{}}
+	for _, dk := range keys {
+		raw, err := k.unwrap(dk)
+		if err != nil {
+			return nil, fmt.Errorf("data key %s: %w", dk.ID, err)
+		}
+		if err := k.add(dk.ID, raw); err != nil {
+			return nil, err
+		}
+		if dk.Active {
+			k.active = dk.ID
+		}
+	}
+	return k, nil
+}
+
+func newGCM(key [32]byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("aes cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keyring) add(id string, raw []byte) error {
+	if len(raw) != 32 {
+		return fmt.Errorf("data key %s: want 32 bytes, got %d", id, len(raw))
+	}
+	gcm, err := newGCM([32]byte(raw))
+	if err != nil {
+		return err
+	}
+	k.keys[id], k.raw[id] = gcm, raw
+	return nil
+}
+
+func (k *Keyring) unwrap(dk DataKey) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(dk.Wrapped)
+	if err != nil || len(data) < k.master.NonceSize() {
+		return nil, errors.New("malformed wrapped key")
+	}
+	n := k.master.NonceSize()
+	raw, err := k.master.Open(nil, data[:n], data[n:], []byte(dk.ID))
+	if err != nil {
+		return nil, errors.New("master key does not unwrap it")
+	}
+	return raw, nil
+}
+
+func (k *Keyring) wrap(id string, raw []byte) (string, error) {
+	nonce := make([]byte, k.master.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(k.master.Seal(nonce, nonce, raw, []byte(id))), nil
+}
+
+// Generate creates a data key, makes it the active key and returns it
+// wrapped for storage.
+func (k *Keyring) Generate() (DataKey, error) {
+	raw := make([]byte, 32)
+	idb := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return DataKey{}, err
+	}
+	if _, err := io.ReadFull(rand.Reader, idb); err != nil {
+		return DataKey{}, err
+	}
+	id := hex.EncodeToString(idb)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	wrapped, err := k.wrap(id, raw)
+	if err != nil {
+		return DataKey{}, err
+	}
+	if err := k.add(id, raw); err != nil {
+		return DataKey{}, err
+	}
+	k.active = id
+	return DataKey{ID: id, Wrapped: wrapped, CreatedAt: time.Now().UTC(), Active: true}, nil
+}
+
+// Rewrap returns every data key wrapped with master instead, for storing
+// after the master key is replaced.
+func (k *Keyring) Rewrap(master string) ([]DataKey, error) {
+	if master == "" {
+		return nil, errors.New("empty master key")
+	}
+	m, err := newGCM(sha256.Sum256([]byte(master)))
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.master = m
+	var out []DataKey
+	for id, raw := range k.raw {
+		wrapped, err := k.wrap(id, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, DataKey{ID: id, Wrapped: wrapped, Active: id == k.active})
+	}
+	return out, nil
+}
+
+// ActiveID returns the ID of the key new values are sealed with.
+func (k *Keyring) ActiveID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *Keyring) activeKey() (string, cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.active == "" {
+		return "", nil, errors.New("no active data key")
+	}
+	return k.active, k.keys[k.active], nil
+}
+
+func (k *Keyring) key(id string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	gcm, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	return gcm, nil
+}
+
+// Seal encrypts s with the active key. Empty strings stay empty.
+func (k *Keyring) Seal(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	id, gcm, err := k.activeKey()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	ct := gcm.Seal(nonce, nonce, []byte(s), []byte(id))
+	return sealedPrefix + id + ":" + base64.RawStdEncoding.EncodeToString(ct), nil
+}
+
+// Open decrypts a sealed string. Values that are not sealed are returned
+// unchanged.
+func (k *Keyring) Open(s string) (string, error) {
+	if !IsSealed(s) {
+		return s, nil
+	}
+	id, enc, ok := strings.Cut(strings.TrimPrefix(s, sealedPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed sealed value")
+	}
+	data, err := base64.RawStdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", errors.New("malformed sealed value")
+	}
+	gcm, err := k.key(id)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed sealed value")
+	}
+	n := gcm.NonceSize()
+	pt, err := gcm.Open(nil, data[:n], data[n:], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("open sealed value: %w", err)
+	}
+	return string(pt), nil
+}
+
+// SealBytes encrypts file contents with the active key.
+func (k *Keyring) SealBytes(b []byte) ([]byte, error) {
+	id, gcm, err := k.activeKey()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(fileMagic)+1+len(id)+gcm.NonceSize()+len(b)+gcm.Overhead())
+	out = append(out, fileMagic...)
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, b, []byte(id)), nil
+}
+
+// OpenBytes decrypts file contents sealed by SealBytes. Contents that are
+// not sealed are returned unchanged.
+func (k *Keyring) OpenBytes(b []byte) ([]byte, error) {
+	id, ok := SealedBytesKeyID(b)
+	if !ok {
+		return b, nil
+	}
+	gcm, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+	rest := b[len(fileMagic)+1+len(id):]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("malformed sealed file")
+	}
+	n := gcm.NonceSize()
+	pt, err := gcm.Open(nil, rest[:n], rest[n:], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("open sealed file: %w", err)
+	}
+	return pt, nil
+}
+
+// IsSealed reports whether s was sealed by a Keyring.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix)
+}
+
+// SealedKeyID returns the ID of the data key s was sealed with, or "".
+func SealedKeyID(s string) string {
+	if !IsSealed(s) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(s, sealedPrefix), ":")
+	return id
+}
+
+// SealedBytesKeyID returns the ID of the data key file contents were
+// sealed with, and whether they are sealed at all.
+func SealedBytesKeyID(b []byte) (string, bool) {
+	if !bytes.HasPrefix(b, []byte(fileMagic)) || len(b) < len(fileMagic)+1 {
+		return "", false
+	}
+	n := int(b[len(fileMagic)])
+	if n == 0 || len(b) < len(fileMagic)+1+n {
+		return "", false
+	}
+	return string(b[len(fileMagic)+1 : len(fileMagic)+1+n]), true
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyringSealOpen(t *testing.T) {
+	k, err := NewKeyring("master", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Seal("x"); err == nil {
+		t.Error("expected error sealing without an active key")
+	}
+	dk, err := k.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := k.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || SealedKeyID(sealed) != dk.ID || strings.Contains(sealed, "secret") {
+		t.Errorf("sealed = %q", sealed)
+	}
+	if got, err := k.Open(sealed); err != nil || got != "secret" {
+		t.Errorf("open = %q, %v", got, err)
+	}
+	if got, _ := k.Open("plain text"); got != "plain text" {
+		t.Errorf("open plain = %q", got)
+	}
+	if s, _ := k.Seal(""); s != "" {
+		t.Errorf("seal empty = %q", s)
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := k.Open(tampered); err == nil {
+		t.Error("expected error opening a tampered value")
+	}
+
+	file, err := k.SealBytes([]byte("file contents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := SealedBytesKeyID(file); !ok || id != dk.ID {
+		t.Errorf("file key id = %q, %v", id, ok)
+	}
+	if got, err := k.OpenBytes(file); err != nil || string(got) != "file contents" {
+		t.Errorf("open file = %q, %v", got, err)
+	}
+	if got, _ := k.OpenBytes([]byte("plain")); string(got) != "plain" {
+		t.Errorf("open plain file = %q", got)
+	}
+}
+
+func TestKeyringReloadAndRotate(t *testing.T) {
+	k, _ := NewKeyring("master", nil)
+	old, _ := k.Generate()
+	sealed, _ := k.Seal("v1")
+
+	if _, err := NewKeyring("wrong", []DataKey{old}); err == nil {
+		t.Error("expected error unwrapping with the wrong master key")
+	}
+	k2, err := NewKeyring("master", []DataKey{old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k2.Open(sealed); err != nil || got != "v1" {
+		t.Errorf("reloaded open = %q, %v", got, err)
+	}
+
+	// A new data key becomes active; the old one still opens old values.
+	next, _ := k2.Generate()
+	if k2.ActiveID() != next.ID {
+		t.Errorf("active = %s, want %s", k2.ActiveID(), next.ID)
+	}
+	if got, _ := k2.Open(sealed); got != "v1" {
+		t.Errorf("open with old key = %q", got)
+	}
+
+	// Rewrapping under a new master key keeps the data keys.
+	keys, err := k2.Rewrap("new master")
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("rewrap = %v, %v", keys, err)
+	}
+	k3, err := NewKeyring("new master", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k3.ActiveID() != next.ID {
+		t.Errorf("active after rewrap = %s", k3.ActiveID())
+	}
+	if got, _ := k3.Open(sealed); got != "v1" {
+		t.Errorf("open after rewrap = %q", got)
+	}
+
+	k4, _ := NewKeyring("master", nil)
+	if _, err := k4.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("open with unknown key: %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+// Package dbquery runs read-only SQL for the db_query tool.
+//
+// Queries go through the database's own command-line client (sqlite3, psql
+// or mysql), so no drivers are linked in. Read-only is enforced twice: Check
+// accepts a single SELECT-like statement and refuses words that write or
+// reach outside the database, and the statement runs inside a read-only
+// transaction that is rolled back, in a session that stays read-only
+// afterwards (SQLite is also opened read-only with query_only set). Rows
+// and client output are capped, and Format renders the result with a short
+// summary of its columns.
+package dbquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+)
+
+// Client binaries, replaced in tests.
+var (
+	sqliteBin = "sqlite3"
+	psqlBin   = "psql"
+	mysqlBin  = "mysql"
+)
+
+// readVerbs may start a statement.
+var readVerbs = []string{"SELECT", "WITH", "EXPLAIN", "SHOW", "DESCRIBE", "DESC", "VALUES", "TABLE"}
+
+// deniedWords write, change the session, or read and write outside the
+// database. Quoted identifiers and string literals are not checked.
+var deniedWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "DROP": true,
+	"ALTER": true, "CREATE": true, "TRUNCATE": true, "RENAME": true, "GRANT": true, "REVOKE": true,
+	"ATTACH": true, "DETACH": true, "VACUUM": true, "REINDEX": true, "COPY": true, "CALL": true,
+	"EXEC": true, "EXECUTE": true, "DO": true, "LOCK": true, "SET": true, "PRAGMA": true, "INTO": true,
+	"LOAD": true, "HANDLER": true, "BEGIN": true, "START": true, "COMMIT": true, "ROLLBACK": true,
+	"SAVEPOINT": true, "RELEASE": true, "PREPARE": true, "DEALLOCATE": true, "LISTEN": true, "NOTIFY": true,
+	"NEXTVAL": true, "SETVAL": true, "SLEEP": true, "PG_SLEEP": true, "BENCHMARK": true,
+	"LOAD_FILE": true, "LOAD_EXTENSION": true, "READFILE": true, "WRITEFILE": true, "EDIT": true,
+	"FTS3_TOKENIZER": true, "PG_READ_FILE": true, "PG_READ_BINARY_FILE": true, "PG_LS_DIR": true,
+	"LO_IMPORT": true, "LO_EXPORT": true, "DBLINK": true, "DBLINK_EXEC": true,
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true,
+}
+
+// Check accepts a single read-only statement for driver and returns it
+// normalized: comments removed, whitespace outside literals collapsed and
+// the trailing semicolon dropped. The normalized text is what runs, so
+// nothing hidden in a comment reaches the client.
+func Check(driver, query string) (string, error) {
+	var out strings.Builder
+	var words []string
+	ended, spaced := false, true
+	space := func() {
+		if !spaced {
+			out.WriteByte(' ')
+			spaced = true
+		}
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		if ended && !isSpace(c) && !strings.HasPrefix(query[i:], "--") && !strings.HasPrefix(query[i:], "/*") {
+			return "", errors.New("only one statement is allowed")
+		}
+		switch {
+		case strings.HasPrefix(query[i:], "--") || c == '#' && driver == "mysql":
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+			space()
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", errors.New("unterminated comment")
+			}
+			i += end + 4
+			space()
+		case c == '\'' || c == '"' || c == '`' || c == '[' && driver == "sqlite":
+			j, err := literalEnd(driver, query, i)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(query[i:j])
+			i, spaced = j, false
+		case c == '$' && driver == "postgres":
+			// Dollar quoting ($$...$$, $tag$...$tag$) would hide quotes and
+			// separators from this scanner.
+			return "", errors.New("dollar-quoted strings are not allowed")
+		case c == ';':
+			ended = true
+			i++
+		case c == '\\':
+			return "", errors.New("backslash outside a string is not allowed")
+		case isSpace(c):
+			space()
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(query) && (isWordByte(query[j]) || query[j] == '$') {
+				j++
+			}
+			words = append(words, strings.ToUpper(query[i:j]))
+			out.WriteString(query[i:j])
+			i, spaced = j, false
+		default:
+			out.WriteByte(c)
+			i, spaced = i+1, false
+		}
+	}
+	if len(words) == 0 {
+		return "", errors.New("query is required")
+	}
+	if !slices.Contains(readVerbs, words[0]) {
+		return "", fmt.Errorf("only read queries are allowed (%s), not %s", strings.Join(readVerbs, ", "), words[0])
+	}
+	for _, w := range words {
+		if deniedWords[w] {
+			return "", fmt.Errorf("%s is not allowed in a read-only query", w)
+		}
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// literalEnd returns the index just past the quoted string or identifier
+// starting at query[i]. Quotes are escaped by doubling them. MySQL strings
+// and PostgreSQL plain and E-prefixed strings may also take backslash escapes,
+// depending on the server (NO_BACKSLASH_ESCAPES, standard_conforming_strings),
+// so such a literal is read both ways and refused unless both end in the
+// same place: otherwise a separator the server sees could look quoted here.
+func literalEnd(driver, query string, i int) (int, error) {
+	c := query[i]
+	if c == '[' {
+		end := strings.IndexByte(query[i+1:], ']')
+		if end < 0 {
+			return 0, errors.New("unterminated quoted identifier")
+		}
+		return i + end + 2, nil
+	}
+	end := quotedEnd(query, i, false)
+	if driver == "mysql" && c != '`' || driver == "postgres" && c == '\'' {
+		if quotedEnd(query, i, true) != end {
+			return 0, errors.New("backslash escapes before a quote are not allowed in strings; double the quote instead")
+		}
+	}
+	if end < 0 {
+		return 0, errors.New("unterminated quoted string")
+	}
+	return end, nil
+}
+
+// quotedEnd returns the index just past the literal quoted by query[i], or
+// -1 if it is not closed. backslash makes a backslash escape the next byte.
+func quotedEnd(query string, i int, backslash bool) int {
+	q := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch {
+		case backslash && query[j] == '\\':
+			j++
+		case query[j] == q:
+			if j+1 < len(query) && query[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// AgentAllowed reports whether agent may query the connection.
+func AgentAllowed(c config.DBConnectionConfig, agent string) bool {
+	return len(c.Agents) == 0 || slices.Contains(c.Agents, agent)
+}
+
+// Command returns the client invocation that runs stmt, a checked
+// statement, in a read-only transaction on c, and the environment it adds.
+func Command(c config.DBConnectionConfig, stmt string, timeout time.Duration) (bin string, args, env []string, err error) {
+	switch c.Driver {
+	case "sqlite":
+		if c.DSN == "" {
+			return "", nil, nil, errors.New("sqlite connection needs dsn (the database file)")
+		}
+		return sqliteBin, []string{"-readonly", "-bail", "-csv", "-header", expandHome(c.DSN),
+			"PRAGMA query_only = ON; BEGIN; " + stmt + "; ROLLBACK;"}, nil, nil
+	case "postgres":
+		args = []string{"-X", "-q", "-w", "--csv", "-v", "ON_ERROR_STOP=1",
+			"-c", "BEGIN READ ONLY", "-c", stmt, "-c", "ROLLBACK"}
+		if c.DSN != "" {
+			args = append(args, "-d", c.DSN)
+		} else {
+			args = appendFlag(args, "-h", c.Host)
+			if c.Port > 0 {
+				args = append(args, "-p", strconv.Itoa(c.Port))
+			}
+			args = appendFlag(args, "-U", c.User)
+			args = appendFlag(args, "-d", c.Database)
+		}
+		env = []string{
+			"PGOPTIONS=-c default_transaction_read_only=on -c statement_timeout=" + strconv.FormatInt(timeout.Milliseconds(), 10),
+			"PGCONNECT_TIMEOUT=10",
+		}
+		if c.Password != "" {
+			env = append(env, "PGPASSWORD="+c.Password)
+		}
+		return psqlBin, args, env, nil
+	case "mysql":
+		args = []string{"--batch", "--connect-timeout=10"}
+		args = appendFlag(args, "--host", c.Host)
+		if c.Port > 0 {
+			args = append(args, "--port", strconv.Itoa(c.Port))
+		}
+		args = appendFlag(args, "--user", c.User)
+		args = appendFlag(args, "--database", c.Database)
+		args = append(args, "--execute", "SET SESSION TRANSACTION READ ONLY; START TRANSACTION READ ONLY; "+stmt+"; ROLLBACK;")
+		if c.Password != "" {
+			env = []string{"MYSQL_PWD=" + c.Password}
+		}
+		return mysqlBin, args, env, nil
+	}
+	return "", nil, nil, fmt.Errorf("unknown driver %q (use sqlite, postgres or mysql)", c.Driver)
+}
+
+func appendFlag(args []string, flag, value string) []string {
+	if value == "" {
+		return args
+	}
+	return append(args, flag, value)
+}
+
+// Result is a query's rows, all values as the client printed them.
+type Result struct {
+	Connection string     `json:"connection"`
+	Columns    []string   `json:"columns"`
+	Rows       [][]string `json:"rows"`
+	Truncated  bool       `json:"truncated,omitempty"` // rows or output over the limits were dropped
+	DurationMs int64      `json:"durationMs"`
+}
+
+// Query checks query and runs it on the named connection.
+func Query(ctx context.Context, cfg config.DatabasesConfig, name, query string) (*Result, error) {
+	c, ok := cfg.Connections[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown connection %q", name)
+	}
+	stmt, err := Check(c.Driver, query)
+	if err != nil {
+		return nil, err
+	}
+	timeout := cfg.TimeoutOrDefault()
+	bin, args, env, err := Command(c, stmt, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	stdout := &capBuffer{max: cfg.MaxBytesOrDefault()}
+	stderr := &capBuffer{max: 4096}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = time.Second
+	start := time.Now()
+	runErr := cmd.Run()
+	res := &Result{Connection: name, DurationMs: time.Since(start).Milliseconds()}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("query timed out after %s", timeout)
+	case runErr != nil:
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		return nil, fmt.Errorf("%s: %s", c.Driver, msg)
+	}
+
+	records := parse(c.Driver, stdout.Bytes())
+	if stdout.truncated && len(records) > 0 {
+		// The last record may be cut short.
+		records = records[:len(records)-1]
+		res.Truncated = true
+	}
+	if len(records) > 0 {
+		res.Columns, records = records[0], records[1:]
+	}
+	if limit := cfg.MaxRowsOrDefault(); len(records) > limit {
+		records = records[:limit]
+		res.Truncated = true
+	}
+	res.Rows = records
+	return res, nil
+}
+
+// parse reads the client's output: CSV from sqlite3 and psql, tab-separated
+// with backslash escapes from mysql. A malformed tail is dropped.
+func parse(driver string, out []byte) [][]string {
+	var records [][]string
+	if driver == "mysql" {
+		for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+			if line == "" && len(records) == 0 {
+				continue
+			}
+			fields := strings.Split(line, "\t")
+			for i, f := range fields {
+				fields[i] = unescapeMySQL(f)
+			}
+			records = append(records, fields)
+		}
+		return records
+	}
+	r := csv.NewReader(bytes.NewReader(out))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	for {
+		rec, err := r.Read()
+		if err != nil {
+			return records
+		}
+		records = append(records, rec)
+	}
+}
+
+var mysqlEscapes = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\0`, "\x00")
+
+func unescapeMySQL(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return mysqlEscapes.Replace(s)
+}
+
+// cellLimit caps one value in Format's table.
+const cellLimit = 200
+
+// Format renders the result as a Markdown table preceded by a summary: the
+// row and column counts and, for numeric columns, their range and mean over
+// the returned rows.
+func Format(r *Result) string {
+	var b strings.Builder
+	if len(r.Columns) == 0 {
+		return fmt.Sprintf("%s: no rows (%dms)", r.Connection, r.DurationMs)
+	}
+	fmt.Fprintf(&b, "%s: %d rows × %d columns (%dms)", r.Connection, len(r.Rows), len(r.Columns), r.DurationMs)
+	if r.Truncated {
+		b.WriteString(", truncated at the limit; narrow the query or aggregate")
+	}
+	b.WriteString("\n")
+	for i, col := range r.Columns {
+		if s, ok := numericSummary(r.Rows, i); ok {
+			fmt.Fprintf(&b, "- %s: %s\n", col, s)
+		}
+	}
+
+	b.WriteString("\n| " + strings.Join(escapeCells(r.Columns), " | ") + " |\n|")
+	b.WriteString(strings.Repeat(" --- |", len(r.Columns)) + "\n")
+	for _, row := range r.Rows {
+		b.WriteString("| " + strings.Join(escapeCells(row), " | ") + " |\n")
+	}
+	return b.String()
+}
+
+// numericSummary describes column i when every non-empty value is a number.
+func numericSummary(rows [][]string, i int) (string, bool) {
+	var n, nulls int
+	var lo, hi, sum float64
+	for _, row := range rows {
+		if i >= len(row) || row[i] == "" || row[i] == "NULL" {
+			nulls++
+			continue
+		}
+		v, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			return "", false
+		}
+		if n == 0 || v < lo {
+			lo = v
+		}
+		if n == 0 || v > hi {
+			hi = v
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return "", false
+	}
+	s := fmt.Sprintf("min %s, max %s, mean %s", fmtNum(lo), fmtNum(hi), fmtNum(sum/float64(n)))
+	if nulls > 0 {
+		s += fmt.Sprintf(", %d empty", nulls)
+	}
+	return s, true
+}
+
+func fmtNum(v float64) string { return strconv.FormatFloat(v, 'g', 6, 64) }
+
+func escapeCells(cells []string) []string {
+	out := make([]string, len(cells))
+	for i, c := range cells {
+		c = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(c)
+		if len(c) > cellLimit {
+			n := cellLimit
+			for n > 0 && !utf8.RuneStart(c[n]) {
+				n--
+			}
+			c = c[:n] + "…"
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// capBuffer keeps the first max bytes written to it.
+type capBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+	if room := c.max - c.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
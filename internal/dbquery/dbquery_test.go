@@ -0,0 +1,121 @@
+package dbquery
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		driver, query, want, err string
+	}{
+		{"sqlite", "SELECT 1;", "SELECT 1", ""},
+		{"sqlite", "  select *\n  from orders -- recent\n where total > 10 ;  -- done", "select * from orders where total > 10", ""},
+		{"postgres", "WITH t AS (SELECT 1) SELECT * FROM t", "WITH t AS (SELECT 1) SELECT * FROM t", ""},
+		{"postgres", "SELECT data #>> '{a,b}' FROM docs", "SELECT data #>> '{a,b}' FROM docs", ""},
+		{"sqlite", "SELECT 'a;  b', \"update\" FROM t", "SELECT 'a;  b', \"update\" FROM t", ""},
+		{"sqlite", "SELECT 'it''s'", "SELECT 'it''s'", ""},
+		{"mysql", "SELECT 1 # note\nFROM dual", "SELECT 1 FROM dual", ""},
+		{"sqlite", "SELECT 1; DROP TABLE t", "", "one statement"},
+		{"sqlite", "DELETE FROM t", "", "not DELETE"},
+		{"postgres", "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", "", "DELETE is not allowed"},
+		{"postgres", "SELECT * INTO backup FROM t", "", "INTO is not allowed"},
+		{"mysql", "SELECT * FROM t FOR UPDATE", "", "UPDATE is not allowed"},
+		{"sqlite", "SELECT writefile('/tmp/x', 'y')", "", "WRITEFILE"},
+		{"postgres", "SELECT pg_read_file('/etc/passwd')", "", "PG_READ_FILE"},
+		{"mysql", "SELECT 1 \\! rm -rf /", "", "backslash"},
+		{"sqlite", "/* DROP */ SELECT 1 /* x", "", "unterminated comment"},
+		{"sqlite", "SELECT 'open", "", "unterminated"},
+		{"sqlite", "-- nothing", "", "required"},
+		{"mysql", "/*!50000 DROP TABLE t */ SELECT 1", "SELECT 1", ""},
+		{"mysql", `SELECT 'it\'s', "a\\b"`, "", "backslash escapes"},
+		{"mysql", `SELECT 'a\\n', "x""y"`, `SELECT 'a\\n', "x""y"`, ""},
+		{"mysql", `SELECT 'a\'' ; DROP TABLE t; -- '`, "", "backslash escapes"},
+		{"mysql", `SELECT 'a\' ; DROP TABLE t; -- '`, "", "backslash escapes"},
+		{"postgres", `SELECT E'a\''; COMMIT; DROP TABLE t; --'`, "", "backslash escapes"},
+		{"postgres", `SELECT 'a\'; COMMIT; DROP TABLE t; --'`, "", "backslash escapes"},
+		{"postgres", `SELECT "a\" FROM t`, `SELECT "a\" FROM t`, ""},
+		{"postgres", `SELECT $$'$$; COMMIT; DROP TABLE t; --'`, "", "dollar-quoted"},
+		{"sqlite", `SELECT 'a\'`, `SELECT 'a\'`, ""},
+		{"sqlite", `SELECT [']; DROP TABLE t; --'] FROM t`, "", "one statement"},
+	} {
+		got, err := Check(tc.driver, tc.query)
+		if tc.err == "" && (err != nil || got != tc.want) || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Check(%q) = %q, %v", tc.query, got, err)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	_, args, env, _ := Command(config.DBConnectionConfig{Driver: "postgres", Host: "db", Port: 5433, User: "analyst", Database: "shop", Password: "pw"}, "SELECT 1", 30*time.Second)
+	want := []string{"-X", "-q", "-w", "--csv", "-v", "ON_ERROR_STOP=1", "-c", "BEGIN READ ONLY", "-c", "SELECT 1", "-c", "ROLLBACK",
+		"-h", "db", "-p", "5433", "-U", "analyst", "-d", "shop"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("psql args = %q", args)
+	}
+	if !strings.Contains(env[0], "default_transaction_read_only=on") || !strings.Contains(env[0], "statement_timeout=30000") || env[2] != "PGPASSWORD=pw" {
+		t.Errorf("psql env = %q", env)
+	}
+	_, args, env, _ = Command(config.DBConnectionConfig{Driver: "mysql", Host: "db", User: "analyst", Database: "shop", Password: "pw"}, "SELECT 1", time.Second)
+	if args[len(args)-1] != "SET SESSION TRANSACTION READ ONLY; START TRANSACTION READ ONLY; SELECT 1; ROLLBACK;" || env[0] != "MYSQL_PWD=pw" {
+		t.Errorf("mysql = %q %q", args, env)
+	}
+	if _, _, _, err := Command(config.DBConnectionConfig{Driver: "oracle"}, "SELECT 1", time.Second); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
+
+func TestQuerySQLite(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not available")
+	}
+	path := filepath.Join(t.TempDir(), "shop.db")
+	setup := "CREATE TABLE orders (id INTEGER, customer TEXT, total REAL);"
+	for i := 1; i <= 5; i++ {
+		setup += fmt.Sprintf("INSERT INTO orders VALUES (%d, 'c|%d', %d0.5);", i, i, i)
+	}
+	if out, err := exec.Command("sqlite3", path, setup).CombinedOutput(); err != nil {
+		t.Fatalf("setup: %v %s", err, out)
+	}
+	cfg := config.DatabasesConfig{MaxRows: 3, Connections: map[string]config.DBConnectionConfig{
+		"shop": {Driver: "sqlite", DSN: path},
+	}}
+	ctx := context.Background()
+
+	res, err := Query(ctx, cfg, "shop", "SELECT id, customer, total FROM orders ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Columns, []string{"id", "customer", "total"}) || len(res.Rows) != 3 || !res.Truncated {
+		t.Fatalf("result = %+v", res)
+	}
+	out := Format(res)
+	for _, want := range []string{"3 rows × 3 columns", "truncated", "- id: min 1, max 3, mean 2", "| 1 | c\\|1 | 10.5 |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("format missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := Query(ctx, cfg, "shop", "SELECT nope FROM orders"); err == nil || !strings.Contains(err.Error(), "no such column") {
+		t.Errorf("bad column: %v", err)
+	}
+	if _, err := Query(ctx, cfg, "shop", "DELETE FROM orders"); err == nil {
+		t.Error("delete accepted")
+	}
+	// The client itself is read-only too.
+	bin, args, _, _ := Command(cfg.Connections["shop"], "DELETE FROM orders", time.Second)
+	if err := exec.Command(bin, args...).Run(); err == nil {
+		t.Error("read-only client wrote")
+	}
+	if res, _ := Query(ctx, cfg, "shop", "SELECT COUNT(*) AS n FROM orders"); res == nil || res.Rows[0][0] != "5" {
+		t.Errorf("count = %+v", res)
+	}
+}
@@ -222,9 +222,13 @@ func (c *Client) SendEmbedWithContent(channelID, content string, embed Embed) {
 	c.Post(fmt.Sprintf("/channels/%s/messages", channelID), payload)
 }
 
-// SendEmbedReply sends an embed as a reply to a specific message.
-func (c *Client) SendEmbedReply(channelID, replyToID string, embed Embed) {
+// SendEmbedReply sends an embed as a reply to a specific message, with
+// optional interactive components.
+func (c *Client) SendEmbedReply(channelID, replyToID string, embed Embed, components ...Component) {
 	payload := map[string]any{"embeds": []Embed{embed}}
+	if len(components) > 0 {
+		payload["components"] = components
+	}
 	if replyToID != "" {
 		payload["message_reference"] = MessageRef{MessageID: replyToID, FailIfNotExists: false}
 	}
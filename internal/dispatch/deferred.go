@@ -0,0 +1,116 @@
+package dispatch
+
+import (
+	"fmt"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// --- Deferred Tasks ---
+
+// DeferredTask is a task held back until its agent's next run window opens.
+type DeferredTask struct {
+	ID        int    `json:"id"`
+	TaskJSON  string `json:"taskJson"`
+	AgentName string `json:"agent"`
+	Source    string `json:"source"`
+	RunAfter  string `json:"runAfter"` // RFC3339
+	Status    string `json:"status"`   // pending, dispatched
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// InitDeferredDB creates the deferred_tasks table if it does not exist.
+func InitDeferredDB(dbPath string) error {
+	sql := `
+CREATE TABLE IF NOT EXISTS deferred_tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_json TEXT NOT NULL,
+    agent TEXT DEFAULT '',
+    source TEXT DEFAULT '',
+    run_after TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_deferred_status ON deferred_tasks(status, run_after);
+`
+	if err := db.Exec(dbPath, sql); err != nil {
+		return fmt.Errorf("init deferred_tasks: %w", err)
+	}
+	return nil
+}
+
+// DeferTask stores a task to be dispatched once runAfter has passed.
+func DeferTask(dbPath, taskJSON, source, agentName string, runAfter time.Time) error {
+	if dbPath == "" {
+		return fmt.Errorf("no db path")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	return db.ExecArgs(dbPath,
+		`INSERT INTO deferred_tasks (task_json, agent, source, run_after, status, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, 'pending', ?, ?)`,
+		taskJSON, agentName, source, runAfter.UTC().Format(time.RFC3339), now, now)
+}
+
+// ClaimDueDeferred returns pending tasks whose run_after has passed and marks
+// them dispatched so a concurrent tick cannot pick them up twice.
+func ClaimDueDeferred(dbPath string, now time.Time) []DeferredTask {
+	if dbPath == "" {
+		return nil
+	}
+	rows, err := db.QueryArgs(dbPath,
+		`SELECT id, task_json, agent, source, run_after, status, created_at, updated_at
+		 FROM deferred_tasks WHERE status = 'pending' AND run_after <= ? ORDER BY run_after ASC, id ASC`,
+		now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil
+	}
+	var out []DeferredTask
+	ts := now.UTC().Format(time.RFC3339)
+	for _, row := range rows {
+		t := deferredFromRow(row)
+		if err := db.ExecArgs(dbPath,
+			`UPDATE deferred_tasks SET status = 'dispatched', updated_at = ? WHERE id = ? AND status = 'pending'`,
+			ts, t.ID); err != nil {
+			continue
+		}
+		t.Status = "dispatched"
+		t.UpdatedAt = ts
+		out = append(out, t)
+	}
+	return out
+}
+
+// QueryDeferred returns pending deferred tasks, optionally filtered by agent.
+func QueryDeferred(dbPath, agentName string) []DeferredTask {
+	if dbPath == "" {
+		return nil
+	}
+	rows, err := db.QueryArgs(dbPath,
+		`SELECT id, task_json, agent, source, run_after, status, created_at, updated_at
+		 FROM deferred_tasks WHERE status = 'pending' AND (? = '' OR agent = ?) ORDER BY run_after ASC, id ASC`,
+		agentName, agentName)
+	if err != nil {
+		return nil
+	}
+	out := make([]DeferredTask, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, deferredFromRow(row))
+	}
+	return out
+}
+
+func deferredFromRow(row map[string]any) DeferredTask {
+	return DeferredTask{
+		ID:        db.Int(row["id"]),
+		TaskJSON:  db.Str(row["task_json"]),
+		AgentName: db.Str(row["agent"]),
+		Source:    db.Str(row["source"]),
+		RunAfter:  db.Str(row["run_after"]),
+		Status:    db.Str(row["status"]),
+		CreatedAt: db.Str(row["created_at"]),
+		UpdatedAt: db.Str(row["updated_at"]),
+	}
+}
@@ -23,10 +23,15 @@ type ApprovalGate interface {
 
 // ApprovalRequest describes a tool call pending user approval.
 type ApprovalRequest struct {
-	ID      string          `json:"id"`
-	Tool    string          `json:"tool"`
-	Input   json.RawMessage `json:"input"`
-	Summary string          `json:"summary"` // human-readable description
-	TaskID  string          `json:"taskId"`
-	Role    string          `json:"role"`
+	ID       string          `json:"id"`
+	Tool     string          `json:"tool"`
+	Input    json.RawMessage `json:"input"`
+	Summary  string          `json:"summary"` // human-readable description
+	TaskID   string          `json:"taskId"`
+	Role     string          `json:"role"`
+	Preview  string          `json:"preview,omitempty"`  // rendered payload of an external action
+	External bool            `json:"external,omitempty"` // external action: asked every time, no "Always" option
+
+	// OnDecision, when set, is told who approved or rejected the request.
+	OnDecision func(approved bool, by string) `json:"-"`
 }
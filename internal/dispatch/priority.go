@@ -0,0 +1,241 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"tetora/internal/config"
+)
+
+// Priority orders tasks waiting for a slot in a dispatch semaphore.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityInteractive
+)
+
+var priorityNames = [...]string{"low", "normal", "high", "interactive"}
+
+func (p Priority) String() string {
+	if p < PriorityLow || p > PriorityInteractive {
+		return "normal"
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority parses "low", "normal", "high" or "interactive".
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priorityNames {
+		if s == name {
+			return Priority(i), true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// batchSources are sources of unattended work that yield to everything else.
+var batchSources = []string{"cron", "taskboard", "queue", "workflow:", "proactive", "reflection"}
+
+// TaskPriority resolves a task's priority: its own Priority field, then the
+// longest matching source prefix in cfg.Sources, then the source's default —
+// interactive for chat sessions, low for cron and other batch sources,
+// normal otherwise.
+func TaskPriority(cfg config.TaskPriorityConfig, t Task) Priority {
+	if p, ok := ParsePriority(t.Priority); ok {
+		return p
+	}
+	best := -1
+	p := PriorityNormal
+	for prefix, name := range cfg.Sources {
+		if len(prefix) > best && strings.HasPrefix(t.Source, prefix) {
+			if v, ok := ParsePriority(name); ok {
+				best, p = len(prefix), v
+			}
+		}
+	}
+	if best >= 0 {
+		return p
+	}
+	if IsInteractiveSource(t.Source) {
+		return PriorityInteractive
+	}
+	for _, prefix := range batchSources {
+		if strings.HasPrefix(t.Source, prefix) {
+			return PriorityLow
+		}
+	}
+	return PriorityNormal
+}
+
+// ErrPreempted is the cancellation cause of a low-priority task whose slot
+// was taken back for an interactive one.
+var ErrPreempted = errors.New("preempted by an interactive task")
+
+// SlotQueue orders waiters for one semaphore by priority, FIFO within a
+// priority, and tracks slot holders so an interactive waiter can preempt a
+// low-priority one.
+type SlotQueue struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	seq     uint64
+	waiters []*slotWaiter // sorted, head first
+	holders []*slotHolder // in start order
+}
+
+type slotWaiter struct {
+	prio   Priority
+	seq    uint64
+	wake   chan struct{}
+	cancel context.CancelFunc // set while the waiter is head and taking the slot
+}
+
+type slotHolder struct {
+	prio   Priority
+	cancel context.CancelCauseFunc // nil when the holder cannot be preempted
+}
+
+var slotQueues sync.Map // chan struct{} -> *SlotQueue
+
+// SlotQueueFor returns the queue shared by every user of sem.
+func SlotQueueFor(sem chan struct{}) *SlotQueue {
+	if q, ok := slotQueues.Load(sem); ok {
+		return q.(*SlotQueue)
+	}
+	q, _ := slotQueues.LoadOrStore(sem, &SlotQueue{sem: sem})
+	return q.(*SlotQueue)
+}
+
+// Acquire waits its turn and takes a slot with take, which must honor ctx.
+// Only the head of the queue calls take; when a higher-priority waiter
+// arrives, the head's take is cancelled and retried once it is head again.
+// With preempt, an interactive waiter arriving while the semaphore is full
+// cancels the most recently started preemptible low-priority holder.
+func (q *SlotQueue) Acquire(ctx context.Context, prio Priority, preempt bool, take func(context.Context) error) error {
+	w := &slotWaiter{prio: prio, wake: make(chan struct{}, 1)}
+	q.mu.Lock()
+	q.seq++
+	w.seq = q.seq
+	q.insert(w)
+	if preempt && prio == PriorityInteractive && len(q.sem) == cap(q.sem) {
+		q.preemptLocked()
+	}
+	q.mu.Unlock()
+	defer q.remove(w)
+
+	for {
+		q.mu.Lock()
+		if q.waiters[0] != w {
+			q.mu.Unlock()
+			select {
+			case <-w.wake:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		headCtx, cancel := context.WithCancel(ctx)
+		w.cancel = cancel
+		q.mu.Unlock()
+
+		err := take(headCtx)
+		cancel()
+		q.mu.Lock()
+		w.cancel = nil
+		q.mu.Unlock()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Demoted by a higher-priority waiter: wait to be head again.
+	}
+}
+
+// Hold registers a slot holder started after Acquire. cancel, when non-nil,
+// lets an interactive waiter preempt a low-priority holder. The returned
+// func must be called when the slot is released.
+func (q *SlotQueue) Hold(prio Priority, cancel context.CancelCauseFunc) (release func()) {
+	h := &slotHolder{prio: prio, cancel: cancel}
+	q.mu.Lock()
+	q.holders = append(q.holders, h)
+	q.mu.Unlock()
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for i, x := range q.holders {
+			if x == h {
+				q.holders = append(q.holders[:i], q.holders[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Waiting returns the number of waiters at each priority.
+func (q *SlotQueue) Waiting() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make(map[string]int)
+	for _, w := range q.waiters {
+		out[w.prio.String()]++
+	}
+	return out
+}
+
+func (q *SlotQueue) insert(w *slotWaiter) {
+	i := sort.Search(len(q.waiters), func(i int) bool { return q.waiters[i].prio < w.prio })
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = w
+	if i == 0 && len(q.waiters) > 1 {
+		if prev := q.waiters[1]; prev.cancel != nil {
+			prev.cancel()
+		}
+	}
+	if i == 0 {
+		notify(w.wake)
+	}
+}
+
+func (q *SlotQueue) remove(w *slotWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, x := range q.waiters {
+		if x == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			if i == 0 && len(q.waiters) > 0 {
+				notify(q.waiters[0].wake)
+			}
+			return
+		}
+	}
+}
+
+// preemptLocked cancels the newest preemptible low-priority holder, which
+// loses the least work. The holder is dropped so it is not preempted twice.
+func (q *SlotQueue) preemptLocked() {
+	for i := len(q.holders) - 1; i >= 0; i-- {
+		h := q.holders[i]
+		if h.prio == PriorityLow && h.cancel != nil {
+			h.cancel(ErrPreempted)
+			q.holders = append(q.holders[:i], q.holders[i+1:]...)
+			return
+		}
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,179 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+func TestTaskPriority(t *testing.T) {
+	cfg := config.TaskPriorityConfig{Sources: map[string]string{
+		"workflow:":        "normal",
+		"workflow:release": "high",
+		"webhook:":         "bogus",
+	}}
+	tests := []struct {
+		task Task
+		want Priority
+	}{
+		{Task{Source: "route:discord:123"}, PriorityInteractive},
+		{Task{Source: "cron"}, PriorityLow},
+		{Task{Source: "cron", Priority: "HIGH"}, PriorityHigh},
+		{Task{Source: "taskboard"}, PriorityLow},
+		{Task{Source: "http"}, PriorityNormal},
+		{Task{Source: "workflow:daily"}, PriorityNormal},
+		{Task{Source: "workflow:release"}, PriorityHigh},
+		{Task{Source: "webhook:github"}, PriorityNormal},
+		{Task{Source: "chat", Priority: "nonsense"}, PriorityInteractive},
+	}
+	for _, tt := range tests {
+		if got := TaskPriority(cfg, tt.task); got != tt.want {
+			t.Errorf("TaskPriority(%+v) = %s, want %s", tt.task, got, tt.want)
+		}
+	}
+}
+
+// takeFrom returns a take func for SlotQueue.Acquire on sem.
+func takeFrom(sem chan struct{}) func(context.Context) error {
+	return func(ctx context.Context) error {
+		select {
+		case sem <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitQueued polls until q has n waiters.
+func waitQueued(t *testing.T, q *SlotQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		total := 0
+		for _, c := range q.Waiting() {
+			total += c
+		}
+		if total == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiters, want %d", total, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlotQueue_PriorityOrder(t *testing.T) {
+	sem := make(chan struct{}, 1)
+	q := SlotQueueFor(sem)
+	if SlotQueueFor(sem) != q {
+		t.Fatal("SlotQueueFor returned a different queue for the same semaphore")
+	}
+	sem <- struct{}{} // full
+
+	order := make(chan string, 4)
+	start := func(name string, prio Priority) {
+		go func() {
+			if err := q.Acquire(context.Background(), prio, false, takeFrom(sem)); err != nil {
+				t.Errorf("%s: %v", name, err)
+				return
+			}
+			order <- name
+		}()
+	}
+	// The low waiter is head and blocked in take when the others arrive.
+	start("low", PriorityLow)
+	waitQueued(t, q, 1)
+	start("normal-1", PriorityNormal)
+	waitQueued(t, q, 2)
+	start("normal-2", PriorityNormal)
+	waitQueued(t, q, 3)
+	start("interactive", PriorityInteractive)
+	waitQueued(t, q, 4)
+
+	want := []string{"interactive", "normal-1", "normal-2", "low"}
+	for _, name := range want {
+		<-sem // free the slot for the next waiter
+		select {
+		case got := <-order:
+			if got != name {
+				t.Fatalf("got slot: %s, want %s", got, name)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s", name)
+		}
+	}
+}
+
+func TestSlotQueue_CancelledWaiter(t *testing.T) {
+	sem := make(chan struct{}, 1)
+	q := SlotQueueFor(sem)
+	sem <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- q.Acquire(ctx, PriorityHigh, false, takeFrom(sem)) }()
+	waitQueued(t, q, 1)
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire = %v, want context.Canceled", err)
+	}
+	waitQueued(t, q, 0)
+
+	// The next waiter is not stuck behind the cancelled one.
+	<-sem
+	if err := q.Acquire(context.Background(), PriorityLow, false, takeFrom(sem)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlotQueue_Preemption(t *testing.T) {
+	sem := make(chan struct{}, 2)
+	q := SlotQueueFor(sem)
+
+	// Two running tasks: a normal one and a preemptible low one.
+	hold := func(prio Priority) (context.Context, func()) {
+		if err := q.Acquire(context.Background(), prio, true, takeFrom(sem)); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancelCause(context.Background())
+		release := q.Hold(prio, cancel)
+		return ctx, func() { release(); <-sem }
+	}
+	normalCtx, releaseNormal := hold(PriorityNormal)
+	lowCtx, releaseLow := hold(PriorityLow)
+	defer releaseNormal()
+
+	// Without preemption an interactive waiter just queues.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Acquire(ctx, PriorityInteractive, false, takeFrom(sem)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire without preempt = %v", err)
+	}
+	if lowCtx.Err() != nil {
+		t.Fatal("low task cancelled without preempt")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- q.Acquire(context.Background(), PriorityInteractive, true, takeFrom(sem)) }()
+	select {
+	case <-lowCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("low task not preempted")
+	}
+	if !errors.Is(context.Cause(lowCtx), ErrPreempted) {
+		t.Errorf("cause = %v, want ErrPreempted", context.Cause(lowCtx))
+	}
+	if normalCtx.Err() != nil {
+		t.Error("normal task was preempted")
+	}
+	releaseLow() // the preempted task gives its slot back
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	<-sem
+}
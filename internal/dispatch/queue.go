@@ -1,6 +1,8 @@
 package dispatch
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os/exec"
@@ -8,6 +10,7 @@ import (
 	"time"
 
 	"tetora/internal/db"
+	"tetora/internal/log"
 )
 
 // --- Queue Item ---
@@ -18,14 +21,31 @@ type QueueItem struct {
 	TaskJSON   string `json:"taskJson"`
 	AgentName  string `json:"agent"`
 	Source     string `json:"source"`
-	Priority   int    `json:"priority"`   // higher = processed sooner (0 = normal)
-	Status     string `json:"status"`     // pending, processing, completed, expired, failed
+	Priority   int    `json:"priority"` // higher = processed sooner (0 = normal)
+	Status     string `json:"status"`   // pending, processing, completed, expired, failed
 	RetryCount int    `json:"retryCount"`
+	NotBefore  string `json:"notBefore,omitempty"` // RFC3339; not drained earlier
+	DedupKey   string `json:"dedupKey,omitempty"`  // QueueDedupKey of agent and prompt
 	CreatedAt  string `json:"createdAt"`
 	UpdatedAt  string `json:"updatedAt"`
 	Error      string `json:"error,omitempty"`
 }
 
+// QueuePriority maps a task priority onto the queue's integer priority:
+// normal is 0, low is below it, high and interactive above.
+func QueuePriority(p Priority) int {
+	return int(p) - int(PriorityNormal)
+}
+
+// QueueDedupKey identifies queued tasks that would do the same work: the
+// same prompt for the same agent.
+func QueueDedupKey(agentName, prompt string) string {
+	sum := sha256.Sum256([]byte(agentName + "\x00" + strings.TrimSpace(prompt)))
+	return hex.EncodeToString(sum[:8])
+}
+
+const queueColumns = `id, task_json, agent, source, priority, status, retry_count, not_before, dedup_key, created_at, updated_at, error`
+
 // MaxQueueRetries is the maximum number of retry attempts for a queued task.
 const MaxQueueRetries = 3
 
@@ -52,7 +72,17 @@ CREATE INDEX IF NOT EXISTS idx_queue_status ON offline_queue(status);
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("init offline_queue: %s: %w", string(out), err)
 	}
-	return nil
+
+	// Migrations: add columns if missing.
+	for _, col := range []string{
+		`ALTER TABLE offline_queue ADD COLUMN not_before TEXT DEFAULT '';`,
+		`ALTER TABLE offline_queue ADD COLUMN dedup_key TEXT DEFAULT '';`,
+	} {
+		if err := db.Exec(dbPath, col); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			log.Warn("offline_queue migration failed", "sql", col, "error", err)
+		}
+	}
+	return db.Exec(dbPath, `CREATE INDEX IF NOT EXISTS idx_queue_dedup ON offline_queue(dedup_key);`)
 }
 
 // --- Enqueue ---
@@ -60,26 +90,53 @@ CREATE INDEX IF NOT EXISTS idx_queue_status ON offline_queue(status);
 // EnqueueTask adds a task to the offline queue for later retry.
 // The task is serialized as JSON; agentName and priority are stored alongside it.
 func EnqueueTask(dbPath string, taskJSON string, source string, agentName string, priority int) error {
+	_, _, err := EnqueueItem(dbPath, QueueItem{
+		TaskJSON: taskJSON, AgentName: agentName, Source: source, Priority: priority,
+	}, 0)
+	return err
+}
+
+// EnqueueItem adds item (task, agent, source, priority, notBefore and
+// dedupKey) to the offline queue and returns its ID. When item.DedupKey is
+// set and an item with that key is still pending or processing, having been
+// queued within window, nothing is added: that item's ID is returned with
+// duplicate set, and it is raised to item's priority if that is higher.
+func EnqueueItem(dbPath string, item QueueItem, window time.Duration) (id int, duplicate bool, err error) {
 	if dbPath == "" {
-		return fmt.Errorf("no db path")
+		return 0, false, fmt.Errorf("no db path")
 	}
 
-	now := time.Now().Format(time.RFC3339)
-	sql := fmt.Sprintf(
-		`INSERT INTO offline_queue (task_json, agent, source, priority, status, retry_count, created_at, updated_at)
-		 VALUES ('%s','%s','%s',%d,'pending',0,'%s','%s')`,
-		db.Escape(taskJSON),
-		db.Escape(agentName),
-		db.Escape(source),
-		priority,
-		db.Escape(now),
-		db.Escape(now),
-	)
-	cmd := exec.Command("sqlite3", dbPath, sql)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("enqueue: %s: %w", string(out), err)
+	now := time.Now()
+	ts := db.Escape(now.Format(time.RFC3339))
+	dup := "0"
+	if item.DedupKey != "" && window > 0 {
+		dup = fmt.Sprintf(`(SELECT id FROM offline_queue WHERE dedup_key = '%s'
+			AND status IN ('pending','processing') AND created_at >= '%s' ORDER BY id LIMIT 1)`,
+			db.Escape(item.DedupKey), db.Escape(now.Add(-window).Format(time.RFC3339)))
 	}
-	return nil
+	// One sqlite3 run, so the duplicate check and the insert cannot interleave
+	// with another enqueue.
+	sql := fmt.Sprintf(`BEGIN IMMEDIATE;
+CREATE TEMP TABLE dup AS SELECT COALESCE(%s, 0) AS id;
+UPDATE offline_queue SET priority = %d, updated_at = '%s'
+	WHERE id = (SELECT id FROM dup) AND priority < %d;
+INSERT INTO offline_queue (task_json, agent, source, priority, status, retry_count, not_before, dedup_key, created_at, updated_at)
+	SELECT '%s','%s','%s',%d,'pending',0,'%s','%s','%s','%s' WHERE (SELECT id FROM dup) = 0;
+SELECT CASE WHEN (SELECT id FROM dup) = 0 THEN last_insert_rowid() ELSE (SELECT id FROM dup) END AS id,
+	(SELECT id FROM dup) > 0 AS duplicate;
+COMMIT;`,
+		dup,
+		item.Priority, ts, item.Priority,
+		db.Escape(item.TaskJSON), db.Escape(item.AgentName), db.Escape(item.Source), item.Priority,
+		db.Escape(item.NotBefore), db.Escape(item.DedupKey), ts, ts)
+	rows, err := db.Query(dbPath, sql)
+	if err != nil {
+		return 0, false, fmt.Errorf("enqueue: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, false, fmt.Errorf("enqueue: no result")
+	}
+	return queueJSONInt(rows[0]["id"]), queueJSONInt(rows[0]["duplicate"]) == 1, nil
 }
 
 // --- Dequeue ---
@@ -87,14 +144,30 @@ func EnqueueTask(dbPath string, taskJSON string, source string, agentName string
 // DequeueNext retrieves the oldest pending item (respecting priority) and marks it "processing".
 // Returns nil if no pending items.
 func DequeueNext(dbPath string) *QueueItem {
+	return DequeueNextExcept(dbPath, nil)
+}
+
+// DequeueNextExcept is DequeueNext skipping items that are scheduled for
+// later (notBefore) or belong to one of the busy agents.
+func DequeueNextExcept(dbPath string, busyAgents []string) *QueueItem {
 	if dbPath == "" {
 		return nil
 	}
 
+	where := fmt.Sprintf(`status = 'pending' AND (not_before = '' OR not_before <= '%s')`,
+		db.Escape(time.Now().Format(time.RFC3339)))
+	if len(busyAgents) > 0 {
+		quoted := make([]string, len(busyAgents))
+		for i, a := range busyAgents {
+			quoted[i] = "'" + db.Escape(a) + "'"
+		}
+		where += " AND agent NOT IN (" + strings.Join(quoted, ",") + ")"
+	}
+
 	// Select highest-priority, oldest pending item.
-	selectSQL := `SELECT id, task_json, agent, source, priority, status, retry_count, created_at, updated_at, error
+	selectSQL := `SELECT ` + queueColumns + `
 		FROM offline_queue
-		WHERE status = 'pending'
+		WHERE ` + where + `
 		ORDER BY priority DESC, id ASC
 		LIMIT 1`
 
@@ -133,9 +206,9 @@ func QueryQueue(dbPath, status string) []QueueItem {
 	}
 
 	sql := fmt.Sprintf(
-		`SELECT id, task_json, agent, source, priority, status, retry_count, created_at, updated_at, error
+		`SELECT %s
 		 FROM offline_queue %s
-		 ORDER BY priority DESC, id ASC`, where)
+		 ORDER BY priority DESC, id ASC`, queueColumns, where)
 
 	rows, err := db.Query(dbPath, sql)
 	if err != nil {
@@ -155,8 +228,8 @@ func QueryQueueItem(dbPath string, id int) *QueueItem {
 		return nil
 	}
 	sql := fmt.Sprintf(
-		`SELECT id, task_json, agent, source, priority, status, retry_count, created_at, updated_at, error
-		 FROM offline_queue WHERE id = %d`, id)
+		`SELECT %s
+		 FROM offline_queue WHERE id = %d`, queueColumns, id)
 	rows, err := db.Query(dbPath, sql)
 	if err != nil || len(rows) == 0 {
 		return nil
@@ -193,6 +266,32 @@ func IncrementQueueRetry(dbPath string, id int, status, errMsg string) {
 	cmd.CombinedOutput() //nolint:errcheck
 }
 
+// RescheduleQueueItem sets a pending item's priority and notBefore
+// (RFC3339, "" = as soon as possible).
+func RescheduleQueueItem(dbPath string, id, priority int, notBefore string) error {
+	if dbPath == "" {
+		return fmt.Errorf("no db path")
+	}
+	now := time.Now().Format(time.RFC3339)
+	return db.Exec(dbPath, fmt.Sprintf(
+		`UPDATE offline_queue SET priority = %d, not_before = '%s', updated_at = '%s' WHERE id = %d AND status = 'pending'`,
+		priority, db.Escape(notBefore), db.Escape(now), id))
+}
+
+// DeferQueueItem puts an item back to pending with one more retry, not to
+// be drained before notBefore.
+func DeferQueueItem(dbPath string, id int, notBefore time.Time, errMsg string) {
+	if dbPath == "" {
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+	sql := fmt.Sprintf(
+		`UPDATE offline_queue SET status = 'pending', error = '%s', retry_count = retry_count + 1, not_before = '%s', updated_at = '%s' WHERE id = %d`,
+		db.Escape(errMsg), db.Escape(notBefore.Format(time.RFC3339)), db.Escape(now), id)
+	cmd := exec.Command("sqlite3", dbPath, sql)
+	cmd.CombinedOutput() //nolint:errcheck
+}
+
 // --- Delete ---
 
 // DeleteQueueItem removes a queue item by ID.
@@ -211,6 +310,7 @@ func DeleteQueueItem(dbPath string, id int) error {
 // --- Cleanup ---
 
 // CleanupExpiredQueue marks pending items older than ttl as "expired".
+// Items scheduled with notBefore expire ttl after that time instead.
 // Returns the number of expired items.
 func CleanupExpiredQueue(dbPath string, ttl time.Duration) int {
 	if dbPath == "" {
@@ -222,8 +322,8 @@ func CleanupExpiredQueue(dbPath string, ttl time.Duration) int {
 
 	// Count before updating.
 	countSQL := fmt.Sprintf(
-		`SELECT COUNT(*) as cnt FROM offline_queue WHERE status = 'pending' AND created_at < '%s'`,
-		db.Escape(cutoff))
+		`SELECT COUNT(*) as cnt FROM offline_queue WHERE status = 'pending' AND created_at < '%s' AND not_before < '%s'`,
+		db.Escape(cutoff), db.Escape(cutoff))
 	rows, err := db.Query(dbPath, countSQL)
 	if err != nil || len(rows) == 0 {
 		return 0
@@ -236,8 +336,8 @@ func CleanupExpiredQueue(dbPath string, ttl time.Duration) int {
 	// Update expired items.
 	updateSQL := fmt.Sprintf(
 		`UPDATE offline_queue SET status = 'expired', error = 'TTL exceeded', updated_at = '%s'
-		 WHERE status = 'pending' AND created_at < '%s'`,
-		db.Escape(now), db.Escape(cutoff))
+		 WHERE status = 'pending' AND created_at < '%s' AND not_before < '%s'`,
+		db.Escape(now), db.Escape(cutoff), db.Escape(cutoff))
 	cmd := exec.Command("sqlite3", dbPath, updateSQL)
 	cmd.CombinedOutput() //nolint:errcheck
 
@@ -302,6 +402,8 @@ func queueItemFromRow(row map[string]any) QueueItem {
 		Priority:   queueJSONInt(row["priority"]),
 		Status:     queueJSONStr(row["status"]),
 		RetryCount: queueJSONInt(row["retry_count"]),
+		NotBefore:  queueJSONStr(row["not_before"]),
+		DedupKey:   queueJSONStr(row["dedup_key"]),
 		CreatedAt:  queueJSONStr(row["created_at"]),
 		UpdatedAt:  queueJSONStr(row["updated_at"]),
 		Error:      queueJSONStr(row["error"]),
@@ -9,12 +9,13 @@ type RouteRequest struct {
 	UserID    string `json:"userId,omitempty"`    // user ID (telegram, discord, etc.)
 	ChannelID string `json:"channelId,omitempty"` // channel/chat ID (slack, telegram group, etc.)
 	GuildID   string `json:"guildId,omitempty"`   // guild/server ID (discord)
+	ParentID  string `json:"parentId,omitempty"`  // parent channel of a thread (discord)
 }
 
 // RouteResult represents the outcome of smart dispatch routing.
 type RouteResult struct {
 	Agent      string `json:"agent"`            // selected agent
-	Method     string `json:"method"`           // "keyword", "llm", "default"
+	Method     string `json:"method"`           // "table", "binding", "keyword", "llm", "default"
 	Confidence string `json:"confidence"`       // "high", "medium", "low"
 	Reason     string `json:"reason,omitempty"` // why this agent was selected
 }
@@ -52,6 +52,36 @@ func CheckBindings(cfg *config.Config, req RouteRequest) *RouteResult {
 	return nil
 }
 
+// routeStatic runs the rule-based tiers of RouteTask. It returns nil when
+// none of them picks an agent that exists in cfg.
+func routeStatic(ctx context.Context, cfg *config.Config, req RouteRequest) *RouteResult {
+	// Tier 0: Declarative routing table.
+	if result := MatchRouteTable(cfg, req); result != nil {
+		if _, ok := cfg.Agents[result.Agent]; ok {
+			return result
+		}
+		log.WarnCtx(ctx, "routing table matched agent not in config, falling through", "agent", result.Agent)
+	}
+
+	// Tier 1: Check bindings.
+	if result := CheckBindings(cfg, req); result != nil {
+		if _, ok := cfg.Agents[result.Agent]; ok {
+			return result
+		}
+		log.WarnCtx(ctx, "binding matched agent not in config, falling through", "agent", result.Agent)
+	}
+
+	// Tier 2: Keyword matching.
+	if result := ClassifyByKeywords(cfg, req.Prompt, nil); result != nil {
+		if _, ok := cfg.Agents[result.Agent]; ok {
+			return result
+		}
+		log.WarnCtx(ctx, "keyword matched agent not in config, falling through", "agent", result.Agent)
+	}
+
+	return nil
+}
+
 // inAllowed returns true if agent is in the allowed list, or the list is empty/nil.
 func inAllowed(agent string, allowed []string) bool {
 	if len(allowed) == 0 {
@@ -261,23 +291,11 @@ If no agent is clearly appropriate, use %q as the default.`,
 }
 
 // RouteTask determines which agent should handle the given prompt.
-// Priority: bindings → keywords → LLM/coordinator fallback.
+// Priority: routing table → bindings → keywords → LLM/coordinator fallback.
 // The TaskExecutor is used only for the LLM classification path.
 func RouteTask(ctx context.Context, cfg *config.Config, req RouteRequest, exec TaskExecutor) *RouteResult {
-	// Tier 1: Check bindings (highest priority).
-	if result := CheckBindings(cfg, req); result != nil {
-		if _, ok := cfg.Agents[result.Agent]; ok {
-			return result
-		}
-		log.WarnCtx(ctx, "binding matched agent not in config, falling through", "agent", result.Agent)
-	}
-
-	// Tier 2: Keyword matching.
-	if result := ClassifyByKeywords(cfg, req.Prompt, nil); result != nil {
-		if _, ok := cfg.Agents[result.Agent]; ok {
-			return result
-		}
-		log.WarnCtx(ctx, "keyword matched agent not in config, falling through", "agent", result.Agent)
+	if result := routeStatic(ctx, cfg, req); result != nil {
+		return result
 	}
 
 	// Tier 3: Fallback mode.
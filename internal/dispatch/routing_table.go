@@ -0,0 +1,104 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"tetora/internal/config"
+)
+
+// liveRules holds the routing table of the most recently loaded main config.
+// Channel bots keep the *config.Config they were started with, so the table
+// is read from here rather than from cfg once the daemon has installed one.
+// Workspace configs are rebuilt on every reload and keep their own table.
+var liveRules atomic.Pointer[[]config.RouteRule]
+
+// SetRouteTable installs the main config's routing table. The daemon calls it
+// on startup and after every config reload, which is what makes rule edits
+// take effect without a restart.
+func SetRouteTable(rules []config.RouteRule) {
+	liveRules.Store(&rules)
+}
+
+// RouteRules returns the routing table in effect for cfg: the installed one
+// for the main config if any, otherwise cfg's own.
+func RouteRules(cfg *config.Config) []config.RouteRule {
+	if cfg.ClientID != "" {
+		return cfg.Routing.Rules
+	}
+	if p := liveRules.Load(); p != nil {
+		return *p
+	}
+	return cfg.Routing.Rules
+}
+
+// MatchRouteTable returns the first routing table rule matching req, or nil.
+func MatchRouteTable(cfg *config.Config, req RouteRequest) *RouteResult {
+	source := strings.TrimPrefix(req.Source, "route:")
+	lower := strings.ToLower(req.Prompt)
+	for i, rule := range RouteRules(cfg) {
+		if rule.Agent == "" {
+			continue
+		}
+		if rule.Channel != "" && rule.Channel != source {
+			continue
+		}
+		if rule.ChatID != "" && rule.ChatID != req.ChannelID && rule.ChatID != req.ParentID {
+			continue
+		}
+		if rule.GuildID != "" && rule.GuildID != req.GuildID {
+			continue
+		}
+		if rule.UserID != "" && rule.UserID != req.UserID {
+			continue
+		}
+		reason := fmt.Sprintf("matched routing rule %s", rule.Label(i))
+		if len(rule.Keywords) > 0 {
+			kw := matchKeyword(lower, rule.Keywords)
+			if kw == "" {
+				continue
+			}
+			reason += fmt.Sprintf(" (keyword %q)", kw)
+		}
+		return &RouteResult{
+			Agent:      rule.Agent,
+			Method:     "table",
+			Confidence: "high",
+			Reason:     reason,
+		}
+	}
+	return nil
+}
+
+func matchKeyword(lowerPrompt string, keywords []string) string {
+	for _, kw := range keywords {
+		if kw != "" && strings.Contains(lowerPrompt, strings.ToLower(kw)) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// ExplainRoute is a dry run of RouteTask: it evaluates the routing table,
+// bindings and keywords exactly as RouteTask does but never calls the LLM
+// classifier. When routing would fall through to the classifier the result
+// has Method "llm" and an empty Agent.
+func ExplainRoute(cfg *config.Config, req RouteRequest) *RouteResult {
+	if result := routeStatic(context.Background(), cfg, req); result != nil {
+		return result
+	}
+	if cfg.SmartDispatch.Fallback == "coordinator" {
+		return &RouteResult{
+			Agent:      cfg.SmartDispatch.DefaultAgent,
+			Method:     "coordinator",
+			Confidence: "high",
+			Reason:     "fallback mode set to coordinator",
+		}
+	}
+	return &RouteResult{
+		Method: "llm",
+		Reason: fmt.Sprintf("no rule matched; the LLM classifier would decide (default %q)", cfg.SmartDispatch.DefaultAgent),
+	}
+}
@@ -0,0 +1,113 @@
+package dispatch
+
+import (
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func routingTableCfg() *config.Config {
+	cfg := scopedTestCfg()
+	cfg.SmartDispatch.Bindings = []config.RoutingBinding{
+		{Channel: "discord", ChannelID: "ops", Agent: "hisui"},
+	}
+	cfg.Routing.Rules = []config.RouteRule{
+		{Name: "ops-deploys", Channel: "discord", ChatID: "ops", Keywords: []string{"deploy"}, Agent: "kokuyou"},
+		{Channel: "telegram", UserID: "42", Agent: "spinel"},
+		{Name: "alerts", ChatID: "alerts", Agent: "hisui"},
+	}
+	return cfg
+}
+
+func TestMatchRouteTable(t *testing.T) {
+	cfg := routingTableCfg()
+
+	tests := []struct {
+		name      string
+		req       RouteRequest
+		wantAgent string // "" = no match
+	}{
+		{"all fields match", RouteRequest{Prompt: "Deploy v2", Source: "discord", ChannelID: "ops"}, "kokuyou"},
+		{"keyword missing", RouteRequest{Prompt: "status?", Source: "discord", ChannelID: "ops"}, ""},
+		{"wrong platform", RouteRequest{Prompt: "deploy", Source: "slack", ChannelID: "ops"}, ""},
+		{"thread parent", RouteRequest{Prompt: "deploy", Source: "discord", ChannelID: "t1", ParentID: "ops"}, "kokuyou"},
+		{"route: prefix", RouteRequest{Prompt: "hi", Source: "route:telegram", UserID: "42"}, "spinel"},
+		{"any platform", RouteRequest{Prompt: "hi", Source: "slack", ChannelID: "alerts"}, "hisui"},
+		{"no rule", RouteRequest{Prompt: "hi", Source: "http"}, ""},
+	}
+	for _, tt := range tests {
+		got := MatchRouteTable(cfg, tt.req)
+		switch {
+		case tt.wantAgent == "" && got != nil:
+			t.Errorf("%s: expected no match, got %s (%s)", tt.name, got.Agent, got.Reason)
+		case tt.wantAgent != "" && got == nil:
+			t.Errorf("%s: expected %s, got no match", tt.name, tt.wantAgent)
+		case got != nil && (got.Agent != tt.wantAgent || got.Method != "table"):
+			t.Errorf("%s: got %s/%s, want %s/table", tt.name, got.Agent, got.Method, tt.wantAgent)
+		}
+	}
+}
+
+func TestExplainRoute_TablePrecedesBindings(t *testing.T) {
+	cfg := routingTableCfg()
+
+	// The binding also matches channel "ops", but the table rule comes first.
+	got := ExplainRoute(cfg, RouteRequest{Prompt: "deploy now", Source: "discord", ChannelID: "ops"})
+	if got.Agent != "kokuyou" || got.Method != "table" {
+		t.Errorf("got %s/%s, want kokuyou/table", got.Agent, got.Method)
+	}
+
+	// No keyword: the table skips, the binding catches it.
+	got = ExplainRoute(cfg, RouteRequest{Prompt: "status", Source: "discord", ChannelID: "ops"})
+	if got.Agent != "hisui" || got.Method != "binding" {
+		t.Errorf("got %s/%s, want hisui/binding", got.Agent, got.Method)
+	}
+
+	// Nothing static matches: reports the LLM fallback without running it.
+	got = ExplainRoute(cfg, RouteRequest{Prompt: "hello", Source: "http"})
+	if got.Method != "llm" || got.Agent != "" {
+		t.Errorf("got %s/%s, want llm fallback", got.Agent, got.Method)
+	}
+}
+
+func TestExplainRoute_UnknownAgentFallsThrough(t *testing.T) {
+	cfg := routingTableCfg()
+	cfg.Routing.Rules = []config.RouteRule{{ChatID: "x", Agent: "ghost"}}
+	cfg.SmartDispatch.Fallback = "coordinator"
+
+	got := ExplainRoute(cfg, RouteRequest{Prompt: "hi", Source: "discord", ChannelID: "x"})
+	if got.Method != "coordinator" || got.Agent != "hisui" {
+		t.Errorf("got %s/%s, want hisui/coordinator", got.Agent, got.Method)
+	}
+}
+
+func TestSetRouteTable_OverridesStaleConfig(t *testing.T) {
+	defer liveRules.Store(nil)
+
+	stale := routingTableCfg()
+	SetRouteTable([]config.RouteRule{{Name: "new", Channel: "slack", Agent: "spinel"}})
+
+	got := MatchRouteTable(stale, RouteRequest{Prompt: "hi", Source: "slack"})
+	if got == nil || got.Agent != "spinel" {
+		t.Fatalf("expected reloaded rule to match, got %+v", got)
+	}
+	if got := MatchRouteTable(stale, RouteRequest{Prompt: "hi", Source: "slack", ChannelID: "alerts"}); got == nil || got.Agent != "spinel" {
+		t.Errorf("old rules should no longer apply, got %+v", got)
+	}
+}
+
+func TestSetRouteTable_WorkspaceKeepsOwnRules(t *testing.T) {
+	defer liveRules.Store(nil)
+
+	SetRouteTable([]config.RouteRule{{Name: "main", Channel: "slack", Agent: "spinel"}})
+	ws := routingTableCfg()
+	ws.ClientID = "cli_home"
+
+	got := MatchRouteTable(ws, RouteRequest{Prompt: "hi", Source: "slack", ChannelID: "alerts"})
+	if got == nil || got.Agent == "spinel" {
+		t.Errorf("workspace should use its own rules, got %+v", got)
+	}
+	if n := len(RouteRules(ws)); n != len(ws.Routing.Rules) {
+		t.Errorf("RouteRules(workspace) = %d rules, want %d", n, len(ws.Routing.Rules))
+	}
+}
@@ -1,6 +1,10 @@
 package dispatch
 
-import "time"
+import (
+	"time"
+
+	"tetora/internal/knowledge"
+)
 
 // Task represents a single unit of work to be dispatched.
 type Task struct {
@@ -31,6 +35,9 @@ type Task struct {
 	AllowedTools   []string `json:"allowedTools,omitempty"`   // CLI --allowedTools (skill-derived + explicit)
 	ScopeBoundary  string   `json:"scopeBoundary,omitempty"`  // diagnostic_only | implement_allowed | test_only | review_only
 	ComplexityHint string   `json:"complexityHint,omitempty"` // simple|standard|complex; empty = auto-classify
+	Priority       string   `json:"priority,omitempty"`       // low|normal|high|interactive; empty = by source
+	ResponseCache  string   `json:"responseCache,omitempty"`  // "on", a TTL or "off"; empty = the agent's setting
+	Variant        string   `json:"variant,omitempty"`        // A/B variant of the agent's experiment; empty = assigned at dispatch
 
 	// Runtime fields (not serialized).
 	ChannelNotifier ChannelNotifier    `json:"-"` // messaging channel notifier
@@ -74,10 +81,14 @@ type TaskResult struct {
 	TrustLevel   string `json:"trustLevel,omitempty"`
 	Agent        string `json:"agent,omitempty"`
 	SlotWarning  string `json:"slotWarning,omitempty"`
+	Cached       bool   `json:"cached,omitempty"` // served from the response cache
 	// Completion status fields (agent self-assessment).
 	CompletionStat CompletionStatus `json:"completionStatus,omitempty"` // agent's self-assessed completion quality
 	Concerns       string           `json:"concerns,omitempty"`         // DONE_WITH_CONCERNS reason
 	BlockedReason  string           `json:"blockedReason,omitempty"`    // BLOCKED / NEEDS_CONTEXT reason
+	// Knowledge files the output cites through [source: …] markers, checked
+	// against the agent's knowledge scope.
+	Citations []knowledge.Citation `json:"citations,omitempty"`
 	// Dev↔QA loop fields (populated when ReviewLoop is enabled).
 	QAApproved *bool  `json:"qaApproved,omitempty"` // nil=no review, true=passed, false=failed
 	QAComment  string `json:"qaComment,omitempty"`  // reviewer feedback
@@ -0,0 +1,199 @@
+// Package docgen renders Markdown task outputs as styled PDF and DOCX
+// documents (invoices, reports, briefs) and delivers them by email or to a
+// chat channel.
+//
+// Both formats are written with the standard library alone. PDFs use the
+// standard Helvetica and Courier fonts, which cover Western European text;
+// other characters are replaced, so DOCX is the format for other scripts.
+// A Style carries the template: a logo and header text on every page, a
+// footer with page numbers, and the heading color.
+package docgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	PDF  Format = "pdf"
+	DOCX Format = "docx"
+)
+
+// ParseFormat accepts "pdf" and "docx" in any case; empty means PDF.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return PDF, nil
+	case PDF, DOCX:
+		return f, nil
+	}
+	return "", fmt.Errorf("format must be pdf or docx, not %q", s)
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == DOCX {
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+	return "application/pdf"
+}
+
+// Style is the template a document is rendered with. Header and Footer may
+// use {title}, {date}, {page} and {pages}.
+type Style struct {
+	Title    string    // default the text of the first heading
+	Header   string    // shown at the top right of every page
+	Footer   string    // shown at the bottom of every page; default "{page} / {pages}"
+	Logo     []byte    // PNG or JPEG shown at the top left of every page
+	Accent   string    // heading color "#RRGGBB"; default "#1F4E79"
+	PageSize string    // "A4" (default) or "Letter"
+	Date     time.Time // for {date}; default now
+}
+
+// Render renders markdown in the given format.
+func Render(format Format, markdown string, st Style) ([]byte, error) {
+	blocks := Parse(markdown)
+	if st.Title == "" {
+		st.Title = Title(blocks)
+	}
+	if st.Date.IsZero() {
+		st.Date = time.Now()
+	}
+	if st.Footer == "" {
+		st.Footer = "{page} / {pages}"
+	}
+	switch format {
+	case PDF:
+		return renderPDF(blocks, st)
+	case DOCX:
+		return renderDOCX(blocks, st)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Title returns the plain text of the first heading, or "".
+func Title(blocks []Block) string {
+	for _, b := range blocks {
+		if b.Kind == Heading {
+			return PlainText(b.Text)
+		}
+	}
+	return ""
+}
+
+// expand fills the placeholders of a header or footer. A negative page
+// leaves {page} and {pages} for the caller (DOCX fills them with fields).
+func (st Style) expand(s string, page, pages int) string {
+	r := []string{"{title}", st.Title, "{date}", st.Date.Format("2006-01-02")}
+	if page >= 0 {
+		r = append(r, "{page}", strconv.Itoa(page), "{pages}", strconv.Itoa(pages))
+	}
+	return strings.NewReplacer(r...).Replace(s)
+}
+
+// accentRGB parses the accent color.
+func (st Style) accentRGB() [3]float64 {
+	s := strings.TrimPrefix(st.Accent, "#")
+	if v, err := strconv.ParseUint(s, 16, 32); err == nil && len(s) == 6 {
+		return [3]float64{float64(v>>16) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255}
+	}
+	return [3]float64{0x1f / 255.0, 0x4e / 255.0, 0x79 / 255.0}
+}
+
+// accentHex returns the accent color as "RRGGBB".
+func (st Style) accentHex() string {
+	c := st.accentRGB()
+	return fmt.Sprintf("%02X%02X%02X", int(c[0]*255+0.5), int(c[1]*255+0.5), int(c[2]*255+0.5))
+}
+
+// letter reports whether the page size is US Letter.
+func (st Style) letter() bool {
+	return strings.EqualFold(st.PageSize, "letter")
+}
+
+// Save writes a document to dir/<date>/<name>.<format>, adding a number to
+// the name when the file exists, and returns its path.
+func Save(dir, name string, format Format, data []byte, now time.Time) (string, error) {
+	day := filepath.Join(dir, now.Format("2006-01-02"))
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		return "", err
+	}
+	base := slug(strings.TrimSuffix(name, filepath.Ext(name)))
+	for i := 1; ; i++ {
+		p := filepath.Join(day, base+"."+string(format))
+		if i > 1 {
+			p = filepath.Join(day, fmt.Sprintf("%s-%d.%s", base, i, format))
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			os.Remove(p)
+			return "", err
+		}
+		return p, f.Close()
+	}
+}
+
+// slug makes a file name from a title.
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "document"
+	}
+	return b.String()
+}
+
+// ResolvePath returns the absolute path of a saved document, which must lie
+// inside dir. Relative paths are taken from dir.
+func ResolvePath(dir, p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	p = filepath.Clean(p)
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Clean(dir)
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	if rel, err := filepath.Rel(root, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in the documents directory %s", p, dir)
+	}
+	return p, nil
+}
+
+// FormatOf returns the format of a saved document from its extension.
+func FormatOf(p string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(p), "."))
+}
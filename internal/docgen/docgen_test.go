@@ -0,0 +1,290 @@
+package docgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const invoice = `# Invoice 2026-014
+
+**Billed to:** Jane Doe\
+Due *within 30 days*. See [terms](https://example.com/terms).
+
+| Item | Qty | Amount |
+|------|:---:|-------:|
+| Garden work | 3 | €120.00 |
+| Pipe \| fitting | 1 | €45.50 |
+
+1. Pay by transfer
+2. Quote the invoice number
+   on the payment
+- nested
+  - deeper
+
+> Thank you!
+
+` + "```\nIBAN DE00 1234\n```" + `
+
+---
+Setext title
+------------
+`
+
+func TestParse(t *testing.T) {
+	blocks := Parse(invoice)
+	var kinds []Kind
+	for _, b := range blocks {
+		kinds = append(kinds, b.Kind)
+	}
+	want := []Kind{Heading, Paragraph, Table, ListItem, ListItem, ListItem, ListItem, Quote, Code, Rule, Heading}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+	if blocks[1].Text != "**Billed to:** Jane Doe\nDue *within 30 days*. See [terms](https://example.com/terms)." {
+		t.Errorf("paragraph = %q", blocks[1].Text)
+	}
+	if tb := blocks[2]; !reflect.DeepEqual(tb.Rows[2], []string{"Pipe | fitting", "1", "€45.50"}) || !reflect.DeepEqual(tb.Align, []string{"", "center", "right"}) {
+		t.Errorf("table = %+v", tb)
+	}
+	if b := blocks[4]; b.Num != 2 || b.Text != "Quote the invoice number on the payment" {
+		t.Errorf("list item = %+v", b)
+	}
+	if blocks[6].Level != 1 || blocks[8].Text != "IBAN DE00 1234" || blocks[10].Level != 2 || Title(blocks) != "Invoice 2026-014" {
+		t.Errorf("blocks = %+v", blocks)
+	}
+}
+
+func TestInline(t *testing.T) {
+	got := Inline("a **b *c*** `x*y` [l **k**](u) 5 * 3 snake_case_name \\*lit\\* ![alt](i.png)")
+	want := []Span{
+		{Text: "a "}, {Text: "b ", Bold: true}, {Text: "c", Bold: true, Italic: true}, {Text: " "},
+		{Text: "x*y", Code: true}, {Text: " "}, {Text: "l ", Link: "u"}, {Text: "k", Bold: true, Link: "u"},
+		{Text: " 5 * 3 snake_case_name *lit* alt"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inline =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func testLogo() []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// pdfText inflates the content streams of a PDF.
+func pdfText(t *testing.T, data []byte) string {
+	t.Helper()
+	var out strings.Builder
+	for _, m := range regexp.MustCompile(`(?s)/FlateDecode >>\nstream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(zr)
+		out.Write(b)
+	}
+	return out.String()
+}
+
+func TestPDF(t *testing.T) {
+	st := Style{Header: "ACME · {title}", Footer: "Page {page} of {pages}", Logo: testLogo(), Date: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)}
+	data, err := Render(PDF, invoice+strings.Repeat("\nfiller paragraph with enough words to wrap around the line\n", 80), st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+	// Every xref offset must point at its object.
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if !bytes.HasPrefix(data[off:], []byte(strconv.Itoa(i+1)+" 0 obj")) {
+			t.Fatalf("xref entry %d points at %q", i+1, data[off:off+10])
+		}
+	}
+	pages := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(data)
+	if n, _ := strconv.Atoi(string(pages[1])); n < 2 {
+		t.Errorf("pages = %d, want a page break", n)
+	}
+
+	text := pdfText(t, data)
+	for _, want := range []string{"(Invoice 2026-014) Tj", "(\x80120.00) Tj", "(Page 1 of ", "(ACME \xb7 Invoice 2026-014) Tj", "/Im1 Do", "(Pipe | fitting) Tj"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("PDF text lacks %q", want)
+		}
+	}
+	if !bytes.Contains(data, []byte("/URI (https://example.com/terms)")) {
+		t.Error("link annotation missing")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap(Inline("one **two** three"), textWidth(fBold, 10, []byte("one two")), 10, fRegular)
+	if len(lines) != 2 || len(lines[0].words) != 2 || lines[0].words[1].font != fBold || !lines[0].words[0].space {
+		t.Errorf("lines = %+v", lines)
+	}
+	long := wrap([]Span{{Text: strings.Repeat("x", 100)}}, 100, 10, fRegular)
+	for _, l := range long {
+		if l.w > 100 {
+			t.Errorf("line %.1f wider than 100", l.w)
+		}
+	}
+	if got := columnWidths([]float64{50, 600, 40}, 400); got[0] != 50 || got[2] != 40 || got[1] != 310 {
+		t.Errorf("columnWidths = %v", got)
+	}
+}
+
+func TestDOCX(t *testing.T) {
+	data, err := Render(DOCX, invoice, Style{Header: "ACME", Footer: "{page}/{pages} · {title}", Logo: testLogo(), PageSize: "Letter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+			dec := xml.NewDecoder(bytes.NewReader(b))
+			for {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+			}
+		}
+	}
+	doc := parts["word/document.xml"]
+	for _, want := range []string{`<w:pStyle w:val="Heading1"/>`, `<w:b/></w:rPr><w:t xml:space="preserve">Billed to:</w:t>`, `<w:br/>`,
+		`<w:hyperlink r:id="rIdL1">`, `<w:jc w:val="right"/>`, `Pipe | fitting`, `<w:pgSz w:w="12240"`, `r:id="rIdHeader"`} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document.xml lacks %s", want)
+		}
+	}
+	if !strings.Contains(parts["word/_rels/document.xml.rels"], `Target="https://example.com/terms" TargetMode="External"`) {
+		t.Error("hyperlink relationship missing")
+	}
+	if ftr := parts["word/footer1.xml"]; !strings.Contains(ftr, `w:instr=" PAGE "`) || !strings.Contains(ftr, `w:instr=" NUMPAGES "`) || !strings.Contains(ftr, "Invoice 2026-014") {
+		t.Errorf("footer = %s", ftr)
+	}
+	if _, ok := parts["word/media/logo.png"]; !ok {
+		t.Error("logo missing")
+	}
+}
+
+type fakeOAuth struct{ body []byte }
+
+func (f *fakeOAuth) Request(ctx context.Context, service, method, u string, body io.Reader) (*http.Response, error) {
+	f.body, _ = io.ReadAll(body)
+	req, _ := http.NewRequestWithContext(ctx, method, u, nil)
+	return http.DefaultClient.Do(req)
+}
+
+func TestSend(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path+" "+r.Header.Get("Authorization"))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sendDocument"):
+			r.ParseMultipartForm(1 << 20)
+			f, h, _ := r.FormFile("document")
+			b, _ := io.ReadAll(f)
+			got = append(got, r.FormValue("chat_id")+" "+h.Filename+" "+string(b))
+			io.WriteString(w, `{"ok":true}`)
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"Missing Permissions"}`)
+		case strings.HasSuffix(r.URL.Path, "getUploadURLExternal"):
+			json.NewEncoder(w).Encode(map[string]any{"ok": true, "upload_url": "http://" + r.Host + "/upload", "file_id": "F1"})
+		case r.URL.Path == "/upload":
+		case strings.HasSuffix(r.URL.Path, "completeUploadExternal"):
+			b, _ := io.ReadAll(r.Body)
+			got = append(got, string(b))
+			io.WriteString(w, `{"ok":true}`)
+		case strings.HasSuffix(r.URL.Path, "/messages/send"):
+			io.WriteString(w, `{"id":"m1"}`)
+		}
+	}))
+	defer srv.Close()
+	GmailAPI, TelegramAPI, DiscordAPI, SlackAPI = srv.URL, srv.URL, srv.URL, srv.URL
+
+	ctx := context.Background()
+	f := File{Name: "report.pdf", ContentType: "application/pdf", Data: []byte("%PDF")}
+	if err := Telegram(ctx, "T0K", 42, "Monthly report", f); err != nil {
+		t.Fatal(err)
+	}
+	if got[1] != "42 report.pdf %PDF" {
+		t.Errorf("telegram = %q", got)
+	}
+	if err := Discord(ctx, "B0T", "123", "here", f); err == nil || !strings.Contains(err.Error(), "Missing Permissions") {
+		t.Errorf("discord error = %v", err)
+	}
+	if err := Slack(ctx, "xoxb", "C1", "Report", f); err != nil {
+		t.Fatal(err)
+	}
+	if last := got[len(got)-1]; !strings.Contains(last, `"channel_id":"C1"`) || !strings.Contains(last, `"id":"F1"`) {
+		t.Errorf("slack complete = %s", last)
+	}
+
+	oauth := &fakeOAuth{}
+	if err := Email(ctx, oauth, "google", []string{"a@example.com"}, "Invoice\r\nBcc: x@evil.test", "Attached.", f); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct{ Raw string }
+	json.Unmarshal(oauth.body, &payload)
+	raw, _ := base64.URLEncoding.DecodeString(payload.Raw)
+	if !strings.Contains(string(raw), "Subject: Invoice Bcc: x@evil.test\r\n") || !strings.Contains(string(raw), `filename=report.pdf`) {
+		t.Errorf("raw message:\n%s", raw)
+	}
+	if err := Email(ctx, oauth, "google", []string{"not an address"}, "x", "", f); err == nil {
+		t.Error("bad recipient accepted")
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	p1, err := Save(dir, "Invoice 2026/014: ACME", PDF, []byte("a"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, _ := Save(dir, "Invoice 2026/014: ACME", PDF, []byte("b"), now)
+	if !strings.HasSuffix(p1, "2026-10-15/invoice-2026-014-acme.pdf") || !strings.HasSuffix(p2, "invoice-2026-014-acme-2.pdf") {
+		t.Errorf("paths = %s, %s", p1, p2)
+	}
+	if p, err := ResolvePath(dir, "2026-10-15/invoice-2026-014-acme.pdf"); err != nil || p != p1 {
+		t.Errorf("ResolvePath = %s, %v", p, err)
+	}
+	if _, err := ResolvePath(dir+"/2026-10-15", "../../../etc/passwd"); err == nil {
+		t.Error("path outside the directory accepted")
+	}
+}
@@ -0,0 +1,364 @@
+package docgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	wordNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	drawingNS = `xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+		`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"`
+	relNS = `http://schemas.openxmlformats.org/officeDocument/2006/relationships`
+)
+
+var fieldRe = regexp.MustCompile(`\{pages?\}`)
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// xmlText escapes text for XML, dropping characters XML cannot hold.
+func xmlText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s)
+	return xmlEscaper.Replace(s)
+}
+
+// docxWriter builds word/document.xml and collects hyperlink relationships.
+type docxWriter struct {
+	st    Style
+	body  strings.Builder
+	links []string // hyperlink targets, relationship IDs rIdL1...
+}
+
+func (w *docxWriter) runs(spans []Span, extra string) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		props := extra
+		if sp.Bold {
+			props += "<w:b/>"
+		}
+		if sp.Italic {
+			props += "<w:i/>"
+		}
+		if sp.Code {
+			props += `<w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:shd w:val="clear" w:color="auto" w:fill="F2F2F2"/>`
+		}
+		if sp.Link != "" {
+			props += `<w:color w:val="0563C1"/><w:u w:val="single"/>`
+		}
+		var r strings.Builder
+		for i, part := range strings.Split(sp.Text, "\n") {
+			if i > 0 {
+				r.WriteString("<w:br/>")
+			}
+			r.WriteString(`<w:t xml:space="preserve">` + xmlText(part) + "</w:t>")
+		}
+		run := "<w:r>"
+		if props != "" {
+			run += "<w:rPr>" + props + "</w:rPr>"
+		}
+		run += r.String() + "</w:r>"
+		if sp.Link != "" {
+			w.links = append(w.links, sp.Link)
+			run = fmt.Sprintf(`<w:hyperlink r:id="rIdL%d">%s</w:hyperlink>`, len(w.links), run)
+		}
+		b.WriteString(run)
+	}
+	return b.String()
+}
+
+func (w *docxWriter) para(style, props, runs string) {
+	w.body.WriteString("<w:p>")
+	if style != "" || props != "" {
+		w.body.WriteString("<w:pPr>")
+		if style != "" {
+			w.body.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+		}
+		w.body.WriteString(props + "</w:pPr>")
+	}
+	w.body.WriteString(runs + "</w:p>")
+}
+
+func (w *docxWriter) block(b Block) {
+	switch b.Kind {
+	case Heading:
+		w.para("Heading"+strconv.Itoa(min(max(b.Level, 1), 4)), "", w.runs(Inline(b.Text), ""))
+	case Paragraph:
+		w.para("", "", w.runs(Inline(b.Text), ""))
+	case ListItem:
+		marker := "•"
+		if b.Num > 0 {
+			marker = strconv.Itoa(b.Num) + "."
+		}
+		indent := 360 * (b.Level + 1)
+		w.para("ListParagraph", fmt.Sprintf(`<w:ind w:left="%d" w:hanging="360"/>`, indent),
+			`<w:r><w:t xml:space="preserve">`+marker+"</w:t></w:r><w:r><w:tab/></w:r>"+w.runs(Inline(b.Text), ""))
+	case Quote:
+		w.para("Quote", "", w.runs(Inline(b.Text), ""))
+	case Code:
+		w.para("Code", "", w.runs([]Span{{Text: b.Text}}, ""))
+	case Rule:
+		w.para("", `<w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="BFBFBF"/></w:pBdr>`, "")
+	case Table:
+		w.table(b)
+	}
+}
+
+func (w *docxWriter) table(b Block) {
+	cols := 0
+	for _, row := range b.Rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return
+	}
+	w.body.WriteString(`<w:tbl><w:tblPr><w:tblW w:w="5000" w:type="pct"/><w:tblBorders>`)
+	for _, side := range []string{"top", "left", "bottom", "right", "insideH", "insideV"} {
+		w.body.WriteString(`<w:` + side + ` w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/>`)
+	}
+	w.body.WriteString(`</w:tblBorders><w:tblCellMar><w:left w:w="80" w:type="dxa"/><w:right w:w="80" w:type="dxa"/></w:tblCellMar></w:tblPr><w:tblGrid>`)
+	for range cols {
+		fmt.Fprintf(&w.body, `<w:gridCol w:w="%d"/>`, 9000/cols)
+	}
+	w.body.WriteString("</w:tblGrid>")
+	for r, row := range b.Rows {
+		w.body.WriteString("<w:tr>")
+		if r == 0 {
+			w.body.WriteString("<w:trPr><w:tblHeader/></w:trPr>")
+		}
+		for c := range cols {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			w.body.WriteString("<w:tc><w:tcPr>")
+			if r == 0 {
+				w.body.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="F2F2F2"/>`)
+			}
+			w.body.WriteString("</w:tcPr>")
+			props := `<w:spacing w:before="40" w:after="40"/>`
+			if c < len(b.Align) && b.Align[c] != "" {
+				props += `<w:jc w:val="` + b.Align[c] + `"/>`
+			}
+			extra := ""
+			if r == 0 {
+				extra = "<w:b/>"
+			}
+			w.para("", props, w.runs(Inline(cell), extra))
+			w.body.WriteString("</w:tc>")
+		}
+		w.body.WriteString("</w:tr>")
+	}
+	// Word needs a paragraph between adjacent tables.
+	w.body.WriteString(`</w:tbl><w:p/>`)
+}
+
+// headerFooter renders a header or footer part. {page} and {pages} become
+// Word fields so they stay right as the document is edited.
+func (w *docxWriter) headerFooter(tag, text string, logo *docxLogo) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	fmt.Fprintf(&b, `<w:%s %s %s><w:p><w:pPr>`, tag, wordNS, drawingNS)
+	if tag == "ftr" {
+		b.WriteString(`<w:jc w:val="center"/>`)
+	} else {
+		// The right tab stop sits at the right margin.
+		pos := 9638
+		if w.st.letter() {
+			pos = 9972
+		}
+		fmt.Fprintf(&b, `<w:pBdr><w:bottom w:val="single" w:sz="4" w:space="4" w:color="BFBFBF"/></w:pBdr><w:tabs><w:tab w:val="right" w:pos="%d"/></w:tabs>`, pos)
+	}
+	b.WriteString(`</w:pPr>`)
+	if logo != nil {
+		fmt.Fprintf(&b, `<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0"><wp:extent cx="%d" cy="%d"/><wp:docPr id="1" name="Logo"/>`+
+			`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic><pic:nvPicPr><pic:cNvPr id="1" name="logo.%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+			`<pic:blipFill><a:blip r:embed="rIdLogo"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+			`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr></pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`,
+			logo.cx, logo.cy, logo.ext, logo.cx, logo.cy)
+	}
+	text = w.st.expand(text, -1, 0)
+	if text != "" {
+		rPr := `<w:rPr><w:color w:val="666666"/><w:sz w:val="17"/></w:rPr>`
+		if tag == "hdr" {
+			b.WriteString(`<w:r><w:tab/></w:r>`)
+		}
+		run := func(t string) {
+			if t != "" {
+				fmt.Fprintf(&b, `<w:r>%s<w:t xml:space="preserve">%s</w:t></w:r>`, rPr, xmlText(t))
+			}
+		}
+		last := 0
+		for _, m := range fieldRe.FindAllStringIndex(text, -1) {
+			run(text[last:m[0]])
+			field := "PAGE"
+			if text[m[0]:m[1]] == "{pages}" {
+				field = "NUMPAGES"
+			}
+			fmt.Fprintf(&b, `<w:fldSimple w:instr=" %s "><w:r>%s<w:t>1</w:t></w:r></w:fldSimple>`, field, rPr)
+			last = m[1]
+		}
+		run(text[last:])
+	}
+	fmt.Fprintf(&b, `</w:p></w:%s>`, tag)
+	return b.String()
+}
+
+type docxLogo struct {
+	data   []byte
+	ext    string // "png" or "jpeg"
+	cx, cy int    // size in EMU
+}
+
+// docxLogoFrom keeps a PNG or JPEG as is and re-encodes other images as
+// PNG, sized 0.35in high.
+func docxLogoFrom(data []byte) (*docxLogo, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("logo: %w", err)
+	}
+	l := &docxLogo{data: data, ext: format}
+	if format != "png" && format != "jpeg" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		l.data, l.ext = buf.Bytes(), "png"
+	}
+	b := img.Bounds()
+	l.cy = 320040
+	l.cx = l.cy * b.Dx() / max(b.Dy(), 1)
+	if l.cx > 2032000 {
+		l.cx, l.cy = 2032000, 2032000*b.Dy()/max(b.Dx(), 1)
+	}
+	return l, nil
+}
+
+type docxPart struct {
+	name string
+	data string
+}
+
+func renderDOCX(blocks []Block, st Style) ([]byte, error) {
+	w := &docxWriter{st: st}
+	var logo *docxLogo
+	if len(st.Logo) > 0 {
+		var err error
+		if logo, err = docxLogoFrom(st.Logo); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range blocks {
+		w.block(b)
+	}
+	pgSz := `<w:pgSz w:w="11906" w:h="16838"/>`
+	if st.letter() {
+		pgSz = `<w:pgSz w:w="12240" w:h="15840"/>`
+	}
+	hasHeader := logo != nil || st.Header != ""
+
+	var rels strings.Builder
+	rels.WriteString(`<Relationship Id="rIdStyles" Type="` + relNS + `/styles" Target="styles.xml"/>`)
+	rels.WriteString(`<Relationship Id="rIdFooter" Type="` + relNS + `/footer" Target="footer1.xml"/>`)
+	if hasHeader {
+		rels.WriteString(`<Relationship Id="rIdHeader" Type="` + relNS + `/header" Target="header1.xml"/>`)
+	}
+	for i, link := range w.links {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdL%d" Type="%s/hyperlink" Target="%s" TargetMode="External"/>`, i+1, relNS, xmlText(link))
+	}
+
+	sect := `<w:sectPr>`
+	if hasHeader {
+		sect += `<w:headerReference w:type="default" r:id="rIdHeader"/>`
+	}
+	sect += `<w:footerReference w:type="default" r:id="rIdFooter"/>` + pgSz +
+		`<w:pgMar w:top="1418" w:right="1134" w:bottom="1134" w:left="1134" w:header="567" w:footer="567" w:gutter="0"/></w:sectPr>`
+
+	const xmlHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	contentTypes := `<Override PartName="/word/footer1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"/>`
+	if hasHeader {
+		contentTypes += `<Override PartName="/word/header1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/>`
+	}
+	parts := []docxPart{
+		{"[Content_Types].xml", xmlHead + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Default Extension="png" ContentType="image/png"/><Default Extension="jpeg" ContentType="image/jpeg"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+			`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+			contentTypes +
+			`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xmlHead + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + relNS + `/officeDocument" Target="word/document.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+			`</Relationships>`},
+		{"docProps/core.xml", xmlHead + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+			`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+			`<dc:title>` + xmlText(st.Title) + `</dc:title><dc:creator>Tetora</dc:creator>` +
+			`<dcterms:created xsi:type="dcterms:W3CDTF">` + st.Date.UTC().Format("2006-01-02T15:04:05Z") + `</dcterms:created></cp:coreProperties>`},
+		{"word/_rels/document.xml.rels", xmlHead + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`},
+		{"word/document.xml", xmlHead + `<w:document ` + wordNS + `><w:body>` + w.body.String() + sect + `</w:body></w:document>`},
+		{"word/styles.xml", xmlHead + docxStyles(st.accentHex())},
+		{"word/footer1.xml", w.headerFooter("ftr", st.Footer, nil)},
+	}
+	if hasHeader {
+		parts = append(parts, docxPart{"word/header1.xml", w.headerFooter("hdr", st.Header, logo)})
+		if logo != nil {
+			parts = append(parts,
+				docxPart{"word/_rels/header1.xml.rels", xmlHead + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+					`<Relationship Id="rIdLogo" Type="` + relNS + `/image" Target="media/logo.` + logo.ext + `"/></Relationships>`},
+				docxPart{"word/media/logo." + logo.ext, string(logo.data)})
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(p.data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// docxStyles returns the style sheet: Calibri body text, headings in the
+// accent color, and the list, quote and code paragraph styles.
+func docxStyles(accent string) string {
+	heading := func(n int, size int) string {
+		return fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Heading%d"><w:name w:val="heading %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:keepNext/><w:spacing w:before="%d" w:after="80"/><w:outlineLvl w:val="%d"/></w:pPr><w:rPr><w:b/><w:color w:val="%s"/><w:sz w:val="%d"/></w:rPr></w:style>`,
+			n, n, 360-60*n, n-1, accent, size)
+	}
+	return `<w:styles ` + wordNS + `>` +
+		`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/><w:sz w:val="21"/><w:color w:val="222222"/></w:rPr></w:rPrDefault>` +
+		`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+		`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+		heading(1, 40) + heading(2, 32) + heading(3, 26) + heading(4, 23) +
+		`<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:after="40"/></w:pPr></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:pBdr><w:left w:val="single" w:sz="18" w:space="8" w:color="` + accent + `"/></w:pBdr><w:ind w:left="284"/></w:pPr><w:rPr><w:i/><w:color w:val="666666"/></w:rPr></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F2F2F2"/><w:spacing w:after="120" w:line="240" w:lineRule="auto"/></w:pPr>` +
+		`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="18"/></w:rPr></w:style>` +
+		`</w:styles>`
+}
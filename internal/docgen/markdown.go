@@ -0,0 +1,323 @@
+package docgen
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a Block.
+type Kind int
+
+const (
+	Paragraph Kind = iota
+	Heading
+	ListItem
+	Quote
+	Code
+	Table
+	Rule
+)
+
+// Block is one block of a parsed Markdown document. Text holds inline
+// Markdown, except for Code blocks where it is the raw code; a "\n" in
+// inline text is a hard line break.
+type Block struct {
+	Kind  Kind
+	Level int // heading level 1-6; list nesting depth from 0
+	Num   int // number of an ordered list item; 0 for a bullet
+	Text  string
+	Rows  [][]string // table cells as inline Markdown, header row first
+	Align []string   // table column alignment: "", "center" or "right"
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listRe     = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	ruleRe     = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_]))*\s*$`)
+	fenceRe    = regexp.MustCompile("^\\s*(```+|~~~+)")
+	tableSepRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Parse splits Markdown into blocks. It covers what agents write in
+// reports: ATX and setext headings, paragraphs, nested bullet and numbered
+// lists, block quotes, fenced code, pipe tables and rules.
+func Parse(markdown string) []Block {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	var blocks []Block
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, Block{Kind: Paragraph, Text: joinLines(para)})
+			para = nil
+		}
+	}
+	// continuing reports whether the last block is a list item or quote
+	// that an indented or lazy line continues.
+	continuing := func() *Block {
+		if len(para) > 0 || len(blocks) == 0 {
+			return nil
+		}
+		if b := &blocks[len(blocks)-1]; b.Kind == ListItem || b.Kind == Quote {
+			return b
+		}
+		return nil
+	}
+	lastBlank := true
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		hardBreak := strings.HasSuffix(lines[i], "  ")
+
+		switch {
+		case trimmed == "":
+			flush()
+			lastBlank = true
+			continue
+
+		case fenceRe.MatchString(line):
+			flush()
+			fence := fenceRe.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				code = append(code, strings.TrimRight(lines[i], " \t"))
+			}
+			blocks = append(blocks, Block{Kind: Code, Text: strings.Join(code, "\n")})
+
+		case len(para) > 0 && (strings.Trim(trimmed, "=") == "" || strings.Trim(trimmed, "-") == ""):
+			level := 1
+			if trimmed[0] == '-' {
+				level = 2
+			}
+			blocks = append(blocks, Block{Kind: Heading, Level: level, Text: joinLines(para)})
+			para = nil
+
+		case headingRe.MatchString(trimmed):
+			flush()
+			m := headingRe.FindStringSubmatch(trimmed)
+			blocks = append(blocks, Block{Kind: Heading, Level: len(m[1]), Text: m[2]})
+
+		case ruleRe.MatchString(line) && len(strings.ReplaceAll(trimmed, " ", "")) >= 3:
+			flush()
+			blocks = append(blocks, Block{Kind: Rule})
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			if b := continuing(); b != nil && b.Kind == Quote && !lastBlank {
+				b.Text = joinLines([]string{b.Text, text})
+			} else {
+				blocks = append(blocks, Block{Kind: Quote, Text: text})
+			}
+
+		case listRe.MatchString(line) && (len(para) == 0 || !isDigit(trimmed[0])):
+			flush()
+			m := listRe.FindStringSubmatch(line)
+			indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+			b := Block{Kind: ListItem, Level: min(indent/2, 5), Text: m[3]}
+			if n, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err == nil {
+				b.Num = max(n, 1)
+			}
+			blocks = append(blocks, b)
+
+		case strings.Contains(trimmed, "|") && i+1 < len(lines) && tableSepRe.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			flush()
+			t := Block{Kind: Table, Rows: [][]string{splitRow(trimmed)}}
+			for _, sep := range splitRow(strings.TrimSpace(lines[i+1])) {
+				switch {
+				case strings.HasPrefix(sep, ":") && strings.HasSuffix(sep, ":"):
+					t.Align = append(t.Align, "center")
+				case strings.HasSuffix(sep, ":"):
+					t.Align = append(t.Align, "right")
+				default:
+					t.Align = append(t.Align, "")
+				}
+			}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				t.Rows = append(t.Rows, splitRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+			blocks = append(blocks, t)
+
+		default:
+			if b := continuing(); b != nil && (!lastBlank || b.Kind == ListItem && line != trimmed) {
+				b.Text = joinLines([]string{b.Text, trimmed})
+				break
+			}
+			if hardBreak {
+				trimmed += "\n"
+			}
+			para = append(para, trimmed)
+		}
+		lastBlank = false
+	}
+	flush()
+	return blocks
+}
+
+// joinLines joins the lines of a paragraph with spaces, keeping hard breaks
+// (lines marked with a trailing "\n", or ending in a backslash).
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte(' ')
+		}
+		if strings.HasSuffix(l, `\`) && i < len(lines)-1 {
+			l = strings.TrimSuffix(l, `\`) + "\n"
+		}
+		b.WriteString(l)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// Span is a run of inline text with one style.
+type Span struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Code   bool
+	Link   string // target URL of a link
+}
+
+var linkRe = regexp.MustCompile(`^(!?)\[([^\]]*)\]\(\s*<?([^)\s>]*)>?(?:\s+"[^"]*")?\s*\)`)
+
+// Inline splits inline Markdown into styled spans: **bold**, *italic*,
+// `code`, [links](url) and backslash escapes. Images become their alt text.
+// A delimiter that cannot open or close emphasis is kept as text.
+func Inline(s string) []Span {
+	var spans []Span
+	var cur strings.Builder
+	var bold, italic bool
+	flush := func() {
+		if cur.Len() > 0 {
+			spans = append(spans, Span{Text: cur.String(), Bold: bold, Italic: italic})
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>", s[i+1]) >= 0:
+			cur.WriteByte(s[i+1])
+			i += 2
+
+		case c == '`':
+			n := runLen(s, i, '`')
+			end := strings.Index(s[i+n:], strings.Repeat("`", n))
+			if end < 0 {
+				cur.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			flush()
+			spans = append(spans, Span{Text: strings.TrimSpace(s[i+n : i+n+end]), Bold: bold, Italic: italic, Code: true})
+			i += 2*n + end
+
+		case c == '[' || c == '!' && i+1 < len(s) && s[i+1] == '[':
+			m := linkRe.FindStringSubmatch(s[i:])
+			if m == nil {
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+			if m[1] == "!" {
+				cur.WriteString(m[2])
+			} else {
+				flush()
+				for _, sp := range Inline(m[2]) {
+					sp.Bold, sp.Italic, sp.Link = sp.Bold || bold, sp.Italic || italic, m[3]
+					spans = append(spans, sp)
+				}
+			}
+			i += len(m[0])
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLen(s, i, c)
+			delim := s[i : i+n]
+			prevSpace := i == 0 || s[i-1] == ' '
+			nextSpace := i+n >= len(s) || s[i+n] == ' '
+			intraword := c == '_' && i > 0 && isWordByte(s[i-1]) && i+n < len(s) && isWordByte(s[i+n])
+			if c == '~' {
+				// Strikethrough is dropped; the text stays.
+				if n == 2 {
+					i += n
+					continue
+				}
+				cur.WriteString(delim)
+				i += n
+				continue
+			}
+			open := !nextSpace && strings.Contains(s[i+n:], delim)
+			closes := !prevSpace
+			switch {
+			case intraword || n > 3:
+				cur.WriteString(delim)
+			case n == 3 && (bold && italic && closes || !bold && !italic && open):
+				flush()
+				bold, italic = !bold, !italic
+			case n == 2 && (bold && closes || !bold && open):
+				flush()
+				bold = !bold
+			case n == 1 && (italic && closes || !italic && open):
+				flush()
+				italic = !italic
+			default:
+				cur.WriteString(delim)
+			}
+			i += n
+
+		default:
+			cur.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return spans
+}
+
+// PlainText returns inline Markdown without its markup.
+func PlainText(s string) string {
+	var b strings.Builder
+	for _, sp := range Inline(s) {
+		b.WriteString(sp.Text)
+	}
+	return b.String()
+}
+
+func runLen(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
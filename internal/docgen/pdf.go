@@ -0,0 +1,717 @@
+package docgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+)
+
+type pdfFont int
+
+const (
+	fRegular pdfFont = iota
+	fBold
+	fItalic
+	fBoldItalic
+	fMono
+)
+
+var pdfFontNames = [...]string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique", "Courier"}
+
+// Glyph widths of ASCII 32-126 in 1/1000 em, from the standard AFM files.
+// The oblique faces share the upright widths; Courier is 600 throughout.
+var (
+	helveticaWidths = [95]uint16{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]uint16{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsi maps the characters of WinAnsiEncoding outside Latin-1.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// substitutes spell out common symbols the standard fonts lack.
+var substitutes = map[rune]string{
+	'→': "->", '←': "<-", '⇒': "=>", '≤': "<=", '≥': ">=", '≠': "!=", '≈': "~",
+	'✓': "v", '✔': "v", '✗': "x", '✘': "x", '−': "-", '\t': "    ",
+}
+
+// encodeWinAnsi converts text to WinAnsiEncoding. Emoji are dropped and
+// other characters outside the encoding become "?".
+func encodeWinAnsi(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case winAnsi[r] != 0:
+			b = append(b, winAnsi[r])
+		case substitutes[r] != "":
+			b = append(b, substitutes[r]...)
+		case r < 0x20, r >= 0x1f000, r >= 0xfe00 && r <= 0xfe0f, r == 0x200d, r >= 0x2600 && r <= 0x27bf:
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// glyphWidth returns the width of an encoded byte in 1/1000 em.
+func glyphWidth(f pdfFont, c byte) float64 {
+	bold := f == fBold || f == fBoldItalic
+	switch {
+	case f == fMono:
+		return 600
+	case c >= 32 && c <= 126 && bold:
+		return float64(helveticaBoldWidths[c-32])
+	case c >= 32 && c <= 126:
+		return float64(helveticaWidths[c-32])
+	case c == 0x85 || c == 0x97 || c == 0x89 || c == 0x99:
+		return 1000
+	case c == 0x91 || c == 0x92:
+		if bold {
+			return 278
+		}
+		return 222
+	case c == 0x93 || c == 0x94:
+		if bold {
+			return 500
+		}
+		return 333
+	case c == 0x95:
+		return 350
+	case c >= 0xc0 && c <= 0xde:
+		return 722
+	}
+	return 556
+}
+
+func textWidth(f pdfFont, size float64, b []byte) float64 {
+	w := 0.0
+	for _, c := range b {
+		w += glyphWidth(f, c)
+	}
+	return w * size / 1000
+}
+
+func spanFont(sp Span) pdfFont {
+	switch {
+	case sp.Code:
+		return fMono
+	case sp.Bold && sp.Italic:
+		return fBoldItalic
+	case sp.Bold:
+		return fBold
+	case sp.Italic:
+		return fItalic
+	}
+	return fRegular
+}
+
+// word is a unit of line breaking: text without spaces in one font.
+type word struct {
+	text  []byte
+	font  pdfFont
+	link  string
+	space bool // a space follows
+	br    bool // a hard line break
+	w     float64
+}
+
+type textLine struct {
+	words []word
+	w     float64
+}
+
+// wrap breaks styled text into lines of at most width points. Every span
+// is set in base when it has no style of its own.
+func wrap(spans []Span, width, size float64, base pdfFont) []textLine {
+	var words []word
+	for _, sp := range spans {
+		f := spanFont(sp)
+		if f == fRegular {
+			f = base
+		} else if base == fBold && f == fItalic {
+			f = fBoldItalic
+		} else if base == fItalic && f == fBold {
+			f = fBoldItalic
+		}
+		for j, part := range strings.Split(sp.Text, "\n") {
+			if j > 0 {
+				words = append(words, word{br: true})
+			}
+			fields := strings.Split(part, " ")
+			for k, fw := range fields {
+				if fw == "" {
+					// A space at the start of a span, or a double space.
+					if n := len(words); n > 0 && !words[n-1].br {
+						words[n-1].space = true
+					}
+					continue
+				}
+				enc := encodeWinAnsi(fw)
+				words = append(words, word{text: enc, font: f, link: sp.Link, space: k < len(fields)-1, w: textWidth(f, size, enc)})
+			}
+		}
+	}
+
+	var lines []textLine
+	var cur textLine
+	push := func() {
+		lines = append(lines, cur)
+		cur = textLine{}
+	}
+	for _, w := range words {
+		if w.br {
+			push()
+			continue
+		}
+		gap := 0.0
+		if n := len(cur.words); n > 0 && cur.words[n-1].space {
+			gap = textWidth(cur.words[n-1].font, size, []byte{' '})
+		}
+		if len(cur.words) > 0 && cur.w+gap+w.w > width {
+			push()
+			gap = 0
+		}
+		// Split a word wider than the line.
+		for w.w > width && len(w.text) > 1 {
+			n := len(w.text) - 1
+			for n > 1 && textWidth(w.font, size, w.text[:n]) > width-cur.w {
+				n--
+			}
+			head := w
+			head.text, head.space = w.text[:n], false
+			head.w = textWidth(w.font, size, head.text)
+			cur.words = append(cur.words, head)
+			cur.w += head.w
+			push()
+			w.text = w.text[n:]
+			w.w = textWidth(w.font, size, w.text)
+		}
+		cur.words = append(cur.words, w)
+		cur.w += gap + w.w
+	}
+	if len(cur.words) > 0 || len(lines) == 0 {
+		push()
+	}
+	return lines
+}
+
+type pdfAnnot struct {
+	x1, y1, x2, y2 float64
+	uri            string
+}
+
+type pdfPage struct {
+	body   bytes.Buffer
+	annots []pdfAnnot
+}
+
+// pdfDoc lays out blocks top to bottom, starting a page when one is full.
+// Coordinates are PDF points from the bottom left; y is the top of the next
+// content.
+type pdfDoc struct {
+	st          Style
+	w, h        float64
+	left, right float64
+	top, bottom float64
+	accent      [3]float64
+	pages       []*pdfPage
+	page        *pdfPage
+	y           float64
+}
+
+const (
+	bodySize    = 10.5
+	bodyLeading = 15
+)
+
+var (
+	textColor  = [3]float64{0.13, 0.13, 0.13}
+	mutedColor = [3]float64{0.4, 0.4, 0.4}
+	linkColor  = [3]float64{0.02, 0.33, 0.76}
+	ruleColor  = [3]float64{0.75, 0.75, 0.75}
+	fillColor  = [3]float64{0.95, 0.95, 0.95}
+)
+
+func pdfNum(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func pdfString(b []byte) string {
+	var out strings.Builder
+	out.WriteByte('(')
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(c)
+	}
+	out.WriteByte(')')
+	return out.String()
+}
+
+func (d *pdfDoc) newPage() {
+	d.page = &pdfPage{}
+	d.pages = append(d.pages, d.page)
+	d.y = d.top
+}
+
+// need starts a new page unless h points fit above the bottom margin.
+func (d *pdfDoc) need(h float64) {
+	if d.y-h < d.bottom && d.y < d.top {
+		d.newPage()
+	}
+}
+
+func (d *pdfDoc) text(x, y float64, f pdfFont, size float64, c [3]float64, b []byte) {
+	fmt.Fprintf(&d.page.body, "BT %s %s %s rg /F%d %s Tf %s %s Td %s Tj ET\n",
+		pdfNum(c[0]), pdfNum(c[1]), pdfNum(c[2]), f+1, pdfNum(size), pdfNum(x), pdfNum(y), pdfString(b))
+}
+
+func (d *pdfDoc) rect(x, y, w, h float64, fill [3]float64) {
+	fmt.Fprintf(&d.page.body, "%s %s %s rg %s %s %s %s re f\n",
+		pdfNum(fill[0]), pdfNum(fill[1]), pdfNum(fill[2]), pdfNum(x), pdfNum(y), pdfNum(w), pdfNum(h))
+}
+
+func (d *pdfDoc) line(x1, y1, x2, y2, width float64, c [3]float64) {
+	fmt.Fprintf(&d.page.body, "%s %s %s RG %s w %s %s m %s %s l S\n",
+		pdfNum(c[0]), pdfNum(c[1]), pdfNum(c[2]), pdfNum(width), pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2))
+}
+
+// baseline returns the baseline of a text line whose box starts at y.
+func baseline(y, size, leading float64) float64 {
+	return y - (leading+size)/2 + 0.22*size
+}
+
+// drawLine draws one wrapped line with its box top at y.
+func (d *pdfDoc) drawLine(l textLine, x, y, size, leading float64, c [3]float64) {
+	by := baseline(y, size, leading)
+	// Words in the same font and link are set as one run.
+	for i := 0; i < len(l.words); {
+		first := l.words[i]
+		run := append([]byte(nil), first.text...)
+		j := i + 1
+		for ; j < len(l.words) && l.words[j].font == first.font && l.words[j].link == first.link; j++ {
+			if l.words[j-1].space {
+				run = append(run, ' ')
+			}
+			run = append(run, l.words[j].text...)
+		}
+		if j < len(l.words) && l.words[j-1].space {
+			run = append(run, ' ')
+		}
+		w := textWidth(first.font, size, run)
+		col := c
+		if first.link != "" {
+			col = linkColor
+			d.page.annots = append(d.page.annots, pdfAnnot{x, by - 0.25*size, x + w, by + 0.8*size, first.link})
+		}
+		d.text(x, by, first.font, size, col, run)
+		x += w
+		i = j
+	}
+}
+
+// flow draws wrapped lines, breaking pages between lines.
+func (d *pdfDoc) flow(lines []textLine, x, size, leading float64, c [3]float64, before func(y float64)) {
+	for _, l := range lines {
+		d.need(leading)
+		if before != nil {
+			before(d.y)
+		}
+		d.drawLine(l, x, d.y, size, leading, c)
+		d.y -= leading
+	}
+}
+
+func (d *pdfDoc) block(b Block, prev *Block) {
+	width := d.right - d.left
+	gap := 8.0
+	if prev == nil {
+		gap = 0
+	} else if prev.Kind == ListItem && b.Kind == ListItem {
+		gap = 2
+	}
+
+	switch b.Kind {
+	case Heading:
+		sizes := []float64{20, 16, 13, 11.5, bodySize, bodySize}
+		size := sizes[min(max(b.Level, 1), 6)-1]
+		col := textColor
+		if b.Level <= 2 {
+			col = d.accent
+		}
+		if prev != nil {
+			gap = size * 0.9
+		}
+		lines := wrap(Inline(b.Text), width, size, fBold)
+		leading := size * 1.3
+		d.y -= gap
+		// Keep a heading with the start of what follows it.
+		d.need(float64(len(lines))*leading + 2*bodyLeading)
+		d.flow(lines, d.left, size, leading, col, nil)
+		if b.Level == 1 {
+			d.line(d.left, d.y-2, d.right, d.y-2, 0.8, d.accent)
+			d.y -= 6
+		}
+		d.y -= 2
+
+	case Paragraph:
+		d.y -= gap
+		d.flow(wrap(Inline(b.Text), width, bodySize, fRegular), d.left, bodySize, bodyLeading, textColor, nil)
+
+	case ListItem:
+		d.y -= gap
+		indent := 16 * float64(b.Level+1)
+		marker := []byte{0x95}
+		if b.Num > 0 {
+			marker = []byte(strconv.Itoa(b.Num) + ".")
+		}
+		lines := wrap(Inline(b.Text), width-indent, bodySize, fRegular)
+		d.need(bodyLeading)
+		mw := textWidth(fRegular, bodySize, marker)
+		d.text(d.left+indent-5-mw, baseline(d.y, bodySize, bodyLeading), fRegular, bodySize, textColor, marker)
+		d.flow(lines, d.left+indent, bodySize, bodyLeading, textColor, nil)
+
+	case Quote:
+		d.y -= gap
+		lines := wrap(Inline(b.Text), width-14, bodySize, fItalic)
+		d.flow(lines, d.left+14, bodySize, bodyLeading, mutedColor, func(y float64) {
+			d.rect(d.left+2, y-bodyLeading, 2.5, bodyLeading, d.accent)
+		})
+
+	case Code:
+		d.y -= gap
+		const size, leading = 8.5, 11.5
+		perLine := max(int((width-12)/(size*0.6)), 1)
+		var lines []textLine
+		for _, l := range strings.Split(b.Text, "\n") {
+			enc := encodeWinAnsi(strings.ReplaceAll(l, "\t", "    "))
+			for len(enc) > perLine {
+				lines = append(lines, textLine{words: []word{{text: enc[:perLine], font: fMono}}})
+				enc = enc[perLine:]
+			}
+			lines = append(lines, textLine{words: []word{{text: enc, font: fMono}}})
+		}
+		d.need(leading + 4)
+		d.rect(d.left, d.y-4, width, 4, fillColor)
+		d.y -= 4
+		d.flow(lines, d.left+6, size, leading, textColor, func(y float64) {
+			d.rect(d.left, y-leading, width, leading, fillColor)
+		})
+		d.rect(d.left, d.y-4, width, 4, fillColor)
+		d.y -= 4
+
+	case Table:
+		d.y -= gap
+		d.table(b)
+
+	case Rule:
+		d.y -= gap + 4
+		d.need(1)
+		d.line(d.left, d.y, d.right, d.y, 0.6, ruleColor)
+		d.y -= 4
+	}
+}
+
+func (d *pdfDoc) table(b Block) {
+	const size, leading, pad = 9.5, 13, 4.0
+	cols := 0
+	for _, row := range b.Rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return
+	}
+	cells := make([][][]Span, len(b.Rows))
+	natural := make([]float64, cols)
+	for r, row := range b.Rows {
+		cells[r] = make([][]Span, cols)
+		for c := range cols {
+			if c < len(row) {
+				cells[r][c] = Inline(row[c])
+			}
+			f := fRegular
+			if r == 0 {
+				f = fBold
+			}
+			for _, l := range wrap(cells[r][c], 1e9, size, f) {
+				natural[c] = max(natural[c], l.w+2*pad)
+			}
+		}
+	}
+	widths := columnWidths(natural, d.right-d.left)
+
+	layout := func(r int) ([][]textLine, float64) {
+		f := fRegular
+		if r == 0 {
+			f = fBold
+		}
+		lines := make([][]textLine, cols)
+		n := 1
+		for c := range cols {
+			lines[c] = wrap(cells[r][c], widths[c]-2*pad, size, f)
+			n = max(n, len(lines[c]))
+		}
+		return lines, float64(n)*leading + 2*pad
+	}
+	draw := func(r int) {
+		lines, h := layout(r)
+		d.need(h)
+		if r > 0 && d.y == d.top {
+			// Repeat the header row on a new page.
+			hl, hh := layout(0)
+			d.drawRow(hl, hh, widths, b.Align, true)
+			lines, h = layout(r)
+		}
+		d.drawRow(lines, h, widths, b.Align, r == 0)
+	}
+	for r := range b.Rows {
+		draw(r)
+	}
+}
+
+func (d *pdfDoc) drawRow(lines [][]textLine, h float64, widths []float64, align []string, header bool) {
+	const size, leading, pad = 9.5, 13, 4.0
+	x := d.left
+	for c, cl := range lines {
+		if header {
+			d.rect(x, d.y-h, widths[c], h, fillColor)
+		}
+		y := d.y - pad
+		for _, l := range cl {
+			lx := x + pad
+			if c < len(align) {
+				switch align[c] {
+				case "right":
+					lx = x + widths[c] - pad - l.w
+				case "center":
+					lx = x + (widths[c]-l.w)/2
+				}
+			}
+			d.drawLine(l, lx, y, size, leading, textColor)
+			y -= leading
+		}
+		fmt.Fprintf(&d.page.body, "%s %s %s RG 0.5 w %s %s %s %s re S\n",
+			pdfNum(ruleColor[0]), pdfNum(ruleColor[1]), pdfNum(ruleColor[2]), pdfNum(x), pdfNum(d.y-h), pdfNum(widths[c]), pdfNum(h))
+		x += widths[c]
+	}
+	d.y -= h
+}
+
+// columnWidths fits columns into avail: narrow columns keep their natural
+// width and the rest share what is left. A table narrower than avail is
+// widened proportionally.
+func columnWidths(natural []float64, avail float64) []float64 {
+	widths := make([]float64, len(natural))
+	total := 0.0
+	for _, n := range natural {
+		total += n
+	}
+	if total <= avail {
+		for c, n := range natural {
+			widths[c] = n * avail / total
+		}
+		return widths
+	}
+	fixed := make([]bool, len(natural))
+	remaining, open := avail, len(natural)
+	for changed := true; changed && open > 0; {
+		changed = false
+		share := remaining / float64(open)
+		for c, n := range natural {
+			if !fixed[c] && n <= share {
+				widths[c], fixed[c] = n, true
+				remaining -= n
+				open--
+				changed = true
+			}
+		}
+	}
+	for c := range natural {
+		if !fixed[c] {
+			widths[c] = remaining / float64(open)
+		}
+	}
+	return widths
+}
+
+// decorate draws the header and footer of page n.
+func (d *pdfDoc) decorate(p *pdfPage, n int, logo *pdfImage) []byte {
+	var b bytes.Buffer
+	saved := d.page
+	d.page = &pdfPage{}
+	headerText := encodeWinAnsi(d.st.expand(d.st.Header, n, len(d.pages)))
+	if logo != nil {
+		h := 26.0
+		w := h * float64(logo.w) / float64(logo.h)
+		if w > 160 {
+			w, h = 160, 160*float64(logo.h)/float64(logo.w)
+		}
+		fmt.Fprintf(&d.page.body, "q %s 0 0 %s %s %s cm /Im1 Do Q\n", pdfNum(w), pdfNum(h), pdfNum(d.left), pdfNum(d.h-46))
+	}
+	if len(headerText) > 0 {
+		d.text(d.right-textWidth(fRegular, 8.5, headerText), d.h-46, fRegular, 8.5, mutedColor, headerText)
+	}
+	if logo != nil || len(headerText) > 0 {
+		d.line(d.left, d.h-52, d.right, d.h-52, 0.5, ruleColor)
+	}
+	if footer := encodeWinAnsi(d.st.expand(d.st.Footer, n, len(d.pages))); len(footer) > 0 {
+		d.text((d.w-textWidth(fRegular, 8.5, footer))/2, 30, fRegular, 8.5, mutedColor, footer)
+	}
+	b.Write(d.page.body.Bytes())
+	b.Write(p.body.Bytes())
+	d.page = saved
+	return b.Bytes()
+}
+
+type pdfImage struct {
+	w, h int
+	data []byte // zlib-compressed RGB
+}
+
+// loadImage decodes a PNG or JPEG logo into RGB, flattening transparency
+// onto white.
+func loadImage(data []byte) (*pdfImage, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("logo: %w", err)
+	}
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			a := uint32(c.A)
+			blend := func(v uint8) byte { return byte((uint32(v)*a + 255*(255-a)) / 255) }
+			rgb = append(rgb, blend(c.R), blend(c.G), blend(c.B))
+		}
+	}
+	return &pdfImage{w: bounds.Dx(), h: bounds.Dy(), data: deflate(rgb)}, nil
+}
+
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func renderPDF(blocks []Block, st Style) ([]byte, error) {
+	d := &pdfDoc{st: st, w: 595.28, h: 841.89, accent: st.accentRGB()}
+	if st.letter() {
+		d.w, d.h = 612, 792
+	}
+	d.left, d.right, d.top, d.bottom = 56, d.w-56, d.h-72, 60
+	var logo *pdfImage
+	if len(st.Logo) > 0 {
+		var err error
+		if logo, err = loadImage(st.Logo); err != nil {
+			return nil, err
+		}
+	}
+
+	d.newPage()
+	for i := range blocks {
+		var prev *Block
+		if i > 0 {
+			prev = &blocks[i-1]
+		}
+		d.block(blocks[i], prev)
+	}
+
+	// Objects: 1 catalog, 2 page tree, 3 info, 4-8 fonts, 9 logo, then a
+	// page and its content stream per page.
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s", len(offsets), body)
+		if stream != nil {
+			fmt.Fprintf(&out, "\nstream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream")
+		}
+		out.WriteString("\nendobj\n")
+	}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	firstPage := 10
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)), nil)
+	obj(fmt.Sprintf("<< /Title %s /Producer (Tetora) /CreationDate (D:%s) >>",
+		pdfString(encodeWinAnsi(st.Title)), st.Date.UTC().Format("20060102150405Z")), nil)
+	for _, name := range pdfFontNames {
+		obj("<< /Type /Font /Subtype /Type1 /BaseFont /"+name+" /Encoding /WinAnsiEncoding >>", nil)
+	}
+	if logo != nil {
+		obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			logo.w, logo.h, len(logo.data)), logo.data)
+	} else {
+		obj("null", nil)
+	}
+
+	resources := "<< /Font << /F1 4 0 R /F2 5 0 R /F3 6 0 R /F4 7 0 R /F5 8 0 R >>"
+	if logo != nil {
+		resources += " /XObject << /Im1 9 0 R >>"
+	}
+	resources += " >>"
+	for i, p := range d.pages {
+		var annots strings.Builder
+		for _, a := range p.annots {
+			fmt.Fprintf(&annots, "<< /Type /Annot /Subtype /Link /Border [0 0 0] /Rect [%s %s %s %s] /A << /S /URI /URI %s >> >> ",
+				pdfNum(a.x1), pdfNum(a.y1), pdfNum(a.x2), pdfNum(a.y2), pdfString([]byte(a.uri)))
+		}
+		page := fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R",
+			pdfNum(d.w), pdfNum(d.h), resources, firstPage+2*i+1)
+		if annots.Len() > 0 {
+			page += " /Annots [" + strings.TrimSpace(annots.String()) + "]"
+		}
+		obj(page+" >>", nil)
+		content := deflate(d.decorate(p, i+1, logo))
+		obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(content)), content)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,240 @@
+package docgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/integration/oauthif"
+)
+
+// API base URLs; tests point them at fake servers.
+var (
+	GmailAPI    = "https://gmail.googleapis.com/gmail/v1"
+	TelegramAPI = "https://api.telegram.org"
+	DiscordAPI  = "https://discord.com/api/v10"
+	SlackAPI    = "https://slack.com/api"
+)
+
+// File is a rendered document to deliver.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// Email sends the file as an attachment from the owner's Gmail account,
+// through an OAuth service whose scopes include
+// https://www.googleapis.com/auth/gmail.send.
+func Email(ctx context.Context, oauth oauthif.Requester, service string, to []string, subject, body string, f File) error {
+	for _, addr := range to {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid recipient %q", addr)
+		}
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", subject), mw.Boundary())
+	text, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64(text, []byte(body))
+	att, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(f.ContentType, map[string]string{"name": f.Name})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": f.Name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64(att, f.Data)
+	mw.Close()
+
+	payload, _ := json.Marshal(map[string]string{"raw": base64.URLEncoding.EncodeToString(msg.Bytes())})
+	resp, err := oauth.Request(ctx, service, http.MethodPost, GmailAPI+"/users/me/messages/send", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("gmail: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("gmail: %s", e.Error.Message)
+		}
+		return fmt.Errorf("gmail: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		io.WriteString(w, enc[:76]+"\r\n")
+		enc = enc[76:]
+	}
+	io.WriteString(w, enc+"\r\n")
+}
+
+// upload posts a multipart form with the file under fileField.
+func upload(ctx context.Context, u string, header http.Header, fields map[string]string, fileField string, f File) ([]byte, int, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {mime.FormatMediaType("form-data", map[string]string{"name": fileField, "filename": f.Name})},
+		"Content-Type":        {f.ContentType},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	fw.Write(f.Data)
+	mw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, resp.StatusCode, nil
+}
+
+// Telegram sends the file to a chat as a document, or as a photo when it
+// is a PNG or JPEG image.
+func Telegram(ctx context.Context, token string, chatID int64, caption string, f File) error {
+	method, field := "sendDocument", "document"
+	if f.ContentType == "image/png" || f.ContentType == "image/jpeg" {
+		method, field = "sendPhoto", "photo"
+	}
+	data, _, err := upload(ctx, TelegramAPI+"/bot"+token+"/"+method, nil,
+		map[string]string{"chat_id": strconv.FormatInt(chatID, 10), "caption": truncate(caption, 1024)}, field, f)
+	if err != nil {
+		// The token is part of the URL the error quotes.
+		return fmt.Errorf("telegram: %s", strings.ReplaceAll(err.Error(), token, "<token>"))
+	}
+	var r struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if json.Unmarshal(data, &r) != nil || !r.OK {
+		if r.Description == "" {
+			r.Description = truncate(string(data), 200)
+		}
+		return fmt.Errorf("telegram: %s", r.Description)
+	}
+	return nil
+}
+
+// Discord posts the file to a channel as the bot.
+func Discord(ctx context.Context, token, channelID, content string, f File) error {
+	payload, _ := json.Marshal(map[string]string{"content": truncate(content, 2000)})
+	data, status, err := upload(ctx, DiscordAPI+"/channels/"+url.PathEscape(channelID)+"/messages",
+		http.Header{"Authorization": {"Bot " + token}}, map[string]string{"payload_json": string(payload)}, "files[0]", f)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	if status != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &e)
+		return fmt.Errorf("discord: HTTP %d %s", status, e.Message)
+	}
+	return nil
+}
+
+// Slack uploads the file to a channel as the bot, in the three steps of
+// Slack's external upload flow.
+func Slack(ctx context.Context, token, channel, comment string, f File) error {
+	call := func(method string, form url.Values, body []byte, out any) error {
+		contentType := "application/x-www-form-urlencoded"
+		if body != nil {
+			contentType = "application/json; charset=utf-8"
+		} else {
+			body = []byte(form.Encode())
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, SlackAPI+"/"+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		var r struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("%s: HTTP %d", method, resp.StatusCode)
+		}
+		if !r.OK {
+			return fmt.Errorf("%s: %s", method, r.Error)
+		}
+		return json.Unmarshal(data, out)
+	}
+
+	var ticket struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	if err := call("files.getUploadURLExternal", url.Values{"filename": {f.Name}, "length": {strconv.Itoa(len(f.Data))}}, nil, &ticket); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	if _, status, err := upload(ctx, ticket.UploadURL, http.Header{"Authorization": {"Bearer " + token}}, nil, "file", f); err != nil {
+		return fmt.Errorf("slack: upload: %w", err)
+	} else if status != http.StatusOK {
+		return fmt.Errorf("slack: upload: HTTP %d", status)
+	}
+	complete, _ := json.Marshal(map[string]any{
+		"files":           []map[string]string{{"id": ticket.FileID, "title": f.Name}},
+		"channel_id":      channel,
+		"initial_comment": comment,
+	})
+	var done struct{}
+	if err := call("files.completeUploadExternal", nil, complete, &done); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,389 @@
+// Package docwatch watches the knowledge directory and the notes vault for
+// changed files.
+//
+// The directories are polled rather than watched through OS notifications,
+// so they work the same on every platform and on network drives. Each poll
+// compares file sizes and modification times with the previous one. A file
+// is reported once it has not been written to for a couple of seconds, so an
+// editor saving in several writes produces one change. Every change updates
+// the search indexes for that file only, and rules can notify or run a
+// workflow when specific documents change.
+package docwatch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+// Roots of watched files.
+const (
+	RootKnowledge = "knowledge"
+	RootVault     = "vault"
+)
+
+// Change kinds.
+const (
+	Created  = "created"
+	Modified = "modified"
+	Removed  = "removed"
+)
+
+const (
+	settle        = 2 * time.Second // quiet time before a written file counts as changed
+	maxInputBytes = 64 << 10        // file content passed to workflows
+	maxRecent     = 50
+)
+
+// Deps holds the root-package callbacks the service needs.
+type Deps struct {
+	// Reindex updates the search indexes for one changed file.
+	Reindex func(ctx context.Context, c Change) error
+
+	// RunWorkflow starts the named workflow with vars.
+	RunWorkflow func(ctx context.Context, name string, vars map[string]string) error
+
+	// Notify delivers text to the owner through the notification chain.
+	Notify func(text string)
+}
+
+// Sender delivers a report to one destination of a kind, e.g. the
+// "discord" sender receives the channel ID of "discord:<channelId>".
+type Sender func(dest, text string) error
+
+// Change is one changed file.
+type Change struct {
+	Root  string    `json:"root"` // RootKnowledge or RootVault
+	Dir   string    `json:"dir"`  // the watched directory
+	Path  string    `json:"path"` // slash-separated, relative to Dir
+	Kind  string    `json:"kind"` // Created, Modified or Removed
+	At    time.Time `json:"at"`
+	Rules []string  `json:"rules,omitempty"` // rules that acted on it
+}
+
+// File returns the change's absolute path.
+func (c Change) File() string { return filepath.Join(c.Dir, filepath.FromSlash(c.Path)) }
+
+type stamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Status is the public view of the watcher.
+type Status struct {
+	Enabled  bool              `json:"enabled"`
+	Interval string            `json:"interval"`
+	Dirs     map[string]string `json:"dirs"`  // root → directory
+	Files    map[string]int    `json:"files"` // root → watched files
+	Recent   []Change          `json:"recent"`
+}
+
+// Service polls the watched directories.
+type Service struct {
+	deps Deps
+
+	mu      sync.Mutex
+	cfg     *config.Config
+	senders map[string]Sender
+	snaps   map[string]map[string]stamp // dir → path → stamp
+	lastRun map[string]time.Time        // rule + "\x00" + path → last action
+	recent  []Change
+}
+
+// New creates a service for cfg.KnowledgeWatch. Call Start to begin polling.
+func New(cfg *config.Config, deps Deps) *Service {
+	return &Service{
+		deps:    deps,
+		cfg:     cfg,
+		senders: make(map[string]Sender),
+		snaps:   make(map[string]map[string]stamp),
+		lastRun: make(map[string]time.Time),
+	}
+}
+
+// RegisterSender sets how reports reach destinations of kind ("discord",
+// "telegram", "webhook").
+func (s *Service) RegisterSender(kind string, fn Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.senders[kind] = fn
+}
+
+// SetConfig swaps in a reloaded config.
+func (s *Service) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+func (s *Service) config() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+// Start records the current files and then polls until ctx is done.
+// Changes made while the daemon was stopped are not reported.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		s.Poll(ctx, time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.config().KnowledgeWatch.IntervalOrDefault()):
+				s.Poll(ctx, time.Now())
+			}
+		}
+	}()
+}
+
+// roots returns the watched directories by root.
+func roots(cfg *config.Config) map[string]string {
+	out := map[string]string{}
+	if cfg.KnowledgeDir != "" {
+		out[RootKnowledge] = cfg.KnowledgeDir
+	}
+	if cfg.VaultDir != "" && cfg.KnowledgeWatch.WatchVault() {
+		out[RootVault] = cfg.VaultDir
+	}
+	return out
+}
+
+// Poll scans the watched directories once and handles what changed since the
+// last scan. The first scan only records the files. It returns the changes.
+func (s *Service) Poll(ctx context.Context, now time.Time) []Change {
+	cfg := s.config()
+	var changes []Change
+	for root, dir := range roots(cfg) {
+		files, err := scan(root, dir)
+		if err != nil {
+			log.Warn("knowledge watch: scan failed", "dir", dir, "error", err)
+			continue
+		}
+		s.mu.Lock()
+		prev, seen := s.snaps[dir]
+		s.mu.Unlock()
+		if !seen {
+			s.mu.Lock()
+			s.snaps[dir] = files
+			s.mu.Unlock()
+			continue
+		}
+		next := make(map[string]stamp, len(files))
+		for p, st := range files {
+			old, had := prev[p]
+			if had && old.size == st.size && old.modTime.Equal(st.modTime) {
+				next[p] = st
+				continue
+			}
+			if now.Sub(st.modTime) < settle {
+				// Still being written: compare again next poll.
+				if had {
+					next[p] = old
+				}
+				continue
+			}
+			next[p] = st
+			kind := Modified
+			if !had {
+				kind = Created
+			}
+			changes = append(changes, Change{Root: root, Dir: dir, Path: p, Kind: kind, At: now})
+		}
+		for p := range prev {
+			if _, ok := files[p]; !ok {
+				changes = append(changes, Change{Root: root, Dir: dir, Path: p, Kind: Removed, At: now})
+			}
+		}
+		s.mu.Lock()
+		s.snaps[dir] = next
+		s.mu.Unlock()
+	}
+	for i := range changes {
+		s.handle(ctx, cfg, &changes[i])
+	}
+	return changes
+}
+
+// scan lists the files a root indexes: every file of the knowledge
+// directory and its subdirectories, or the notes of the vault. Hidden files
+// and directories are skipped.
+func scan(root, dir string) (map[string]stamp, error) {
+	files := map[string]stamp{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != dir {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || (root != RootKnowledge && !strings.EqualFold(filepath.Ext(p), ".md")) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files[filepath.ToSlash(rel)] = stamp{info.Size(), info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// handle reindexes a changed file and runs the rules that match it.
+func (s *Service) handle(ctx context.Context, cfg *config.Config, c *Change) {
+	log.Info("knowledge watch: file changed", "root", c.Root, "path", c.Path, "kind", c.Kind)
+	if s.deps.Reindex != nil {
+		if err := s.deps.Reindex(ctx, *c); err != nil {
+			log.Warn("knowledge watch: reindex failed", "path", c.Path, "error", err)
+		}
+	}
+
+	kw := cfg.KnowledgeWatch
+	for i, r := range kw.Rules {
+		if !Matches(r, *c) {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		key := name + "\x00" + c.Root + ":" + c.Path
+		s.mu.Lock()
+		last, ok := s.lastRun[key]
+		if ok && c.At.Sub(last) < r.CooldownOrDefault() {
+			s.mu.Unlock()
+			continue
+		}
+		s.lastRun[key] = c.At
+		s.mu.Unlock()
+
+		c.Rules = append(c.Rules, name)
+		s.act(ctx, kw, r, name, *c)
+	}
+
+	s.mu.Lock()
+	s.recent = append(s.recent, *c)
+	if len(s.recent) > maxRecent {
+		s.recent = s.recent[len(s.recent)-maxRecent:]
+	}
+	s.mu.Unlock()
+}
+
+// Matches reports whether rule r applies to change c. A pattern without a
+// slash also matches the file name in any folder.
+func Matches(r config.KnowledgeWatchRule, c Change) bool {
+	if r.Match == "" || (r.Root != "" && r.Root != c.Root) {
+		return false
+	}
+	events := r.Events
+	if len(events) == 0 {
+		events = []string{Created, Modified}
+	}
+	wanted := false
+	for _, e := range events {
+		if strings.EqualFold(e, c.Kind) {
+			wanted = true
+			break
+		}
+	}
+	if !wanted {
+		return false
+	}
+	if ok, _ := path.Match(r.Match, c.Path); ok {
+		return true
+	}
+	if !strings.Contains(r.Match, "/") {
+		ok, _ := path.Match(r.Match, path.Base(c.Path))
+		return ok
+	}
+	return false
+}
+
+// act runs a rule's workflow and sends its report.
+func (s *Service) act(ctx context.Context, kw config.KnowledgeWatchConfig, r config.KnowledgeWatchRule, name string, c Change) {
+	text := fmt.Sprintf("[knowledge] %s %s", c.Path, c.Kind)
+	if c.Root == RootVault {
+		text = fmt.Sprintf("[vault] %s %s", c.Path, c.Kind)
+	}
+	if r.Workflow != "" && s.deps.RunWorkflow != nil {
+		vars := map[string]string{
+			"file":  c.Path,
+			"path":  c.File(),
+			"event": c.Kind,
+			"root":  c.Root,
+			"rule":  name,
+		}
+		if c.Kind != Removed {
+			if data, err := os.ReadFile(c.File()); err == nil {
+				if len(data) > maxInputBytes {
+					data = data[:maxInputBytes]
+				}
+				vars["input"] = string(data)
+			}
+		}
+		if err := s.deps.RunWorkflow(ctx, r.Workflow, vars); err != nil {
+			log.Warn("knowledge watch: workflow failed to start", "rule", name, "workflow", r.Workflow, "error", err)
+			text += fmt.Sprintf("\nworkflow %s failed to start: %v", r.Workflow, err)
+		} else {
+			text += "\nstarted workflow " + r.Workflow
+		}
+	}
+	for _, dest := range kw.NotifyFor(r) {
+		kind, arg, _ := strings.Cut(dest, ":")
+		if kind == "owner" {
+			if s.deps.Notify != nil {
+				s.deps.Notify(text)
+			}
+			continue
+		}
+		s.mu.Lock()
+		send := s.senders[kind]
+		s.mu.Unlock()
+		if send == nil {
+			log.Warn("knowledge watch: no sender for destination", "rule", name, "dest", dest)
+			continue
+		}
+		if err := send(arg, text); err != nil {
+			log.Warn("knowledge watch: delivery failed", "rule", name, "dest", dest, "error", err)
+		}
+	}
+}
+
+// Status reports the watched directories and the latest changes, newest first.
+func (s *Service) Status() Status {
+	cfg := s.config()
+	st := Status{
+		Enabled:  cfg.KnowledgeWatch.Enabled,
+		Interval: cfg.KnowledgeWatch.IntervalOrDefault().String(),
+		Dirs:     roots(cfg),
+		Files:    map[string]int{},
+		Recent:   []Change{},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for root, dir := range st.Dirs {
+		st.Files[root] = len(s.snaps[dir])
+	}
+	for i := len(s.recent) - 1; i >= 0; i-- {
+		st.Recent = append(st.Recent, s.recent[i])
+	}
+	return st
+}
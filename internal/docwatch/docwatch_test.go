@@ -0,0 +1,119 @@
+package docwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+func TestPoll(t *testing.T) {
+	knowledgeDir, vaultDir := t.TempDir(), t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	write := func(path, content string, mod time.Time) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mod, mod)
+	}
+	write(filepath.Join(knowledgeDir, "meeting-notes.md"), "v1", old)
+	write(filepath.Join(knowledgeDir, "faq.md"), "faq", old)
+	write(filepath.Join(vaultDir, "daily", "2026-10-15.md"), "today", old)
+	write(filepath.Join(vaultDir, ".obsidian", "app.md"), "config", old)
+	write(filepath.Join(vaultDir, "photo.png"), "png", old)
+
+	cfg := &config.Config{
+		KnowledgeDir: knowledgeDir,
+		VaultDir:     vaultDir,
+		KnowledgeWatch: config.KnowledgeWatchConfig{
+			Enabled: true,
+			Rules: []config.KnowledgeWatchRule{
+				{Name: "summarize", Match: "meeting-notes.md", Workflow: "summarize-meeting"},
+				{Name: "journal", Match: "daily/*.md", Root: RootVault, Events: []string{Created}},
+			},
+		},
+	}
+	var reindexed []string
+	var workflows []map[string]string
+	var notes []string
+	s := New(cfg, Deps{
+		Reindex: func(ctx context.Context, c Change) error {
+			reindexed = append(reindexed, c.Root+":"+c.Path+":"+c.Kind)
+			return nil
+		},
+		RunWorkflow: func(ctx context.Context, name string, vars map[string]string) error {
+			vars["workflow"] = name
+			workflows = append(workflows, vars)
+			return nil
+		},
+		Notify: func(text string) { notes = append(notes, text) },
+	})
+	ctx := context.Background()
+	now := time.Now()
+
+	if changes := s.Poll(ctx, now); len(changes) != 0 {
+		t.Fatalf("baseline reported %+v", changes)
+	}
+	if st := s.Status(); st.Files[RootKnowledge] != 2 || st.Files[RootVault] != 1 {
+		t.Errorf("watched files = %v", st.Files)
+	}
+
+	write(filepath.Join(knowledgeDir, "meeting-notes.md"), "v2 notes", now.Add(-time.Minute))
+	write(filepath.Join(vaultDir, "daily", "2026-10-16.md"), "tomorrow", now.Add(-time.Minute))
+	write(filepath.Join(knowledgeDir, "draft.md"), "typing", now) // still being written
+	os.Remove(filepath.Join(knowledgeDir, "faq.md"))
+
+	changes := s.Poll(ctx, now)
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if len(reindexed) != 3 {
+		t.Errorf("reindexed = %v", reindexed)
+	}
+	if len(workflows) != 1 || workflows[0]["workflow"] != "summarize-meeting" || workflows[0]["input"] != "v2 notes" || workflows[0]["event"] != Modified {
+		t.Errorf("workflows = %+v", workflows)
+	}
+	// The journal rule notifies the owner; the workflow rule has no destinations.
+	if len(notes) != 1 || notes[0] != "[vault] daily/2026-10-16.md created" {
+		t.Errorf("notes = %q", notes)
+	}
+
+	// The draft settles; the meeting notes change again within the cooldown.
+	write(filepath.Join(knowledgeDir, "meeting-notes.md"), "v3", now.Add(-time.Minute))
+	changes = s.Poll(ctx, now.Add(10*time.Second))
+	if len(changes) != 2 {
+		t.Fatalf("second poll = %+v", changes)
+	}
+	if len(workflows) != 1 {
+		t.Error("workflow rerun within the cooldown")
+	}
+	if st := s.Status(); len(st.Recent) != 5 || st.Recent[0].Kind == "" {
+		t.Errorf("recent = %+v", st.Recent)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	c := Change{Root: RootVault, Path: "meetings/2026/standup.md", Kind: Modified}
+	tests := []struct {
+		rule config.KnowledgeWatchRule
+		want bool
+	}{
+		{config.KnowledgeWatchRule{Match: "standup.md"}, true},
+		{config.KnowledgeWatchRule{Match: "*.md"}, true},
+		{config.KnowledgeWatchRule{Match: "meetings/*.md"}, false},
+		{config.KnowledgeWatchRule{Match: "meetings/*/*.md"}, true},
+		{config.KnowledgeWatchRule{Match: "standup.md", Root: RootKnowledge}, false},
+		{config.KnowledgeWatchRule{Match: "standup.md", Events: []string{Removed}}, false},
+		{config.KnowledgeWatchRule{}, false},
+	}
+	for _, tc := range tests {
+		if got := Matches(tc.rule, c); got != tc.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tc.rule, got, tc.want)
+		}
+	}
+}
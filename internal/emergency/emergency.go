@@ -0,0 +1,195 @@
+// Package emergency implements the emergency stop: a switch that, once
+// engaged, cancels every running task and keeps anything new from starting
+// until it is released with a confirmation code. Its state is kept in a file
+// so a restarted daemon stays stopped.
+package emergency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConfirmTTL is how long a resume confirmation code is valid.
+const ConfirmTTL = 5 * time.Minute
+
+var (
+	ErrNotEngaged = errors.New("emergency stop is not engaged")
+	ErrConfirm    = errors.New("confirmation code is missing, wrong or expired")
+)
+
+// State is the switch's persisted state.
+type State struct {
+	Engaged      bool   `json:"engaged"`
+	Since        string `json:"since,omitempty"` // RFC3339
+	By           string `json:"by,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Cancelled    int    `json:"cancelled,omitempty"`    // tasks running when it was engaged
+	BudgetPaused bool   `json:"budgetPaused,omitempty"` // the stop paused the budget and resumes it
+}
+
+// Switch is the emergency stop. The zero value is not usable; use Open.
+type Switch struct {
+	path string
+
+	mu      sync.Mutex
+	state   State
+	halt    context.Context // cancelled while engaged
+	release context.CancelFunc
+	code    string
+	codeExp time.Time
+
+	engaged atomic.Bool
+	running atomic.Int64
+}
+
+// Open loads the switch state from path. A missing or unreadable file
+// leaves the switch released.
+func Open(path string) *Switch {
+	s := &Switch{path: path}
+	s.halt, s.release = context.WithCancel(context.Background())
+	if data, err := os.ReadFile(path); err == nil {
+		var st State
+		if json.Unmarshal(data, &st) == nil && st.Engaged {
+			s.state = st
+			s.engaged.Store(true)
+			s.release()
+		}
+	}
+	return s
+}
+
+// Engaged reports whether the stop is engaged. It is safe on a nil Switch.
+func (s *Switch) Engaged() bool {
+	return s != nil && s.engaged.Load()
+}
+
+// Running is the number of tasks currently bound to the switch.
+func (s *Switch) Running() int {
+	return int(s.running.Load())
+}
+
+// State returns the current state.
+func (s *Switch) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Bind returns a context that is cancelled when the stop is engaged, and
+// counts the task it runs as running until cancel is called.
+func (s *Switch) Bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	s.mu.Lock()
+	halt := s.halt
+	s.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	if halt.Err() != nil {
+		cancel()
+	}
+	stop := context.AfterFunc(halt, cancel)
+	s.running.Add(1)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			s.running.Add(-1)
+		})
+	}
+}
+
+// Engage engages the stop, cancelling every bound task. It reports false
+// when the stop was already engaged, in which case the state is unchanged.
+func (s *Switch) Engage(by, reason string) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state.Engaged {
+		return s.state, false, nil
+	}
+	s.state = State{
+		Engaged:   true,
+		Since:     time.Now().Format(time.RFC3339),
+		By:        by,
+		Reason:    reason,
+		Cancelled: s.Running(),
+	}
+	s.engaged.Store(true)
+	s.release()
+	s.code = ""
+	return s.state, true, s.saveLocked()
+}
+
+// NoteBudgetPaused records that the stop paused the budget, so releasing
+// it resumes the budget too.
+func (s *Switch) NoteBudgetPaused() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.Engaged {
+		return ErrNotEngaged
+	}
+	s.state.BudgetPaused = true
+	return s.saveLocked()
+}
+
+// RequestRelease issues the confirmation code that Release needs. Each
+// request replaces the previous code.
+func (s *Switch) RequestRelease() (code string, expires time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.Engaged {
+		return "", time.Time{}, ErrNotEngaged
+	}
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	s.code = hex.EncodeToString(b)
+	s.codeExp = time.Now().Add(ConfirmTTL)
+	return s.code, s.codeExp, nil
+}
+
+// Release releases the stop when code is the current confirmation code and
+// returns the state it was released from.
+func (s *Switch) Release(code string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.Engaged {
+		return State{}, ErrNotEngaged
+	}
+	if s.code == "" || code != s.code || time.Now().After(s.codeExp) {
+		return State{}, ErrConfirm
+	}
+	prev := s.state
+	s.state = State{}
+	s.code = ""
+	s.halt, s.release = context.WithCancel(context.Background())
+	s.engaged.Store(false)
+	return prev, s.saveLocked()
+}
+
+func (s *Switch) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	if !s.state.Engaged {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove emergency state: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("save emergency state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngageCancelsBoundTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emergency.json")
+	s := Open(path)
+	if s.Engaged() {
+		t.Fatal("new switch is engaged")
+	}
+
+	ctx, done := s.Bind(context.Background())
+	defer done()
+	if s.Running() != 1 {
+		t.Fatalf("running = %d", s.Running())
+	}
+
+	st, first, err := s.Engage("http", "agent loops")
+	if err != nil || !first {
+		t.Fatalf("Engage = %v, %v", first, err)
+	}
+	if st.Cancelled != 1 || st.By != "http" || st.Reason != "agent loops" {
+		t.Errorf("state = %+v", st)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("bound task was not cancelled")
+	}
+	if _, first, _ := s.Engage("chat", ""); first {
+		t.Error("second Engage reported first")
+	}
+
+	// A task bound while engaged starts cancelled.
+	late, lateDone := s.Bind(context.Background())
+	defer lateDone()
+	if late.Err() == nil {
+		t.Error("task bound while engaged is not cancelled")
+	}
+
+	// The stop survives a restart.
+	if r := Open(path); !r.Engaged() || r.State().Reason != "agent loops" {
+		t.Errorf("reopened state = %+v", r.State())
+	}
+}
+
+func TestReleaseNeedsConfirmation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emergency.json")
+	s := Open(path)
+	if _, _, err := s.RequestRelease(); err == nil {
+		t.Error("RequestRelease succeeded while released")
+	}
+	s.Engage("http", "")
+	s.NoteBudgetPaused()
+
+	if _, err := s.Release(""); !errors.Is(err, ErrConfirm) {
+		t.Errorf("Release without code = %v", err)
+	}
+	code, _, err := s.RequestRelease()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Release(code + "x"); !errors.Is(err, ErrConfirm) {
+		t.Errorf("Release with wrong code = %v", err)
+	}
+	prev, err := s.Release(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prev.BudgetPaused || s.Engaged() {
+		t.Errorf("prev = %+v, engaged = %v", prev, s.Engaged())
+	}
+	if _, err := s.Release(code); !errors.Is(err, ErrNotEngaged) {
+		t.Errorf("second Release = %v", err)
+	}
+	if Open(path).Engaged() {
+		t.Error("released stop is engaged after a restart")
+	}
+
+	ctx, done := s.Bind(context.Background())
+	defer done()
+	if ctx.Err() != nil {
+		t.Error("task bound after release is cancelled")
+	}
+}
@@ -0,0 +1,441 @@
+package export
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/db"
+	tlog "tetora/internal/log"
+)
+
+// --- Per-user export and erasure ---
+
+// Source is a table holding personal data and how its rows are tied to a
+// user. Rows match when Column equals the user ID or, when Via is set, when
+// Column is one of the values Via selects. Via is a query with a single %s
+// for the escaped user ID.
+type Source struct {
+	Table  string
+	Column string
+	Via    string
+	// File names a column holding the absolute path of a file that belongs
+	// to the row.
+	File string
+}
+
+// channelsOf selects the channel keys linked to a user profile.
+const channelsOf = `SELECT channel_key FROM channel_user_map WHERE user_id = '%s'`
+
+// UserSources lists every table with rows tied to a user. Tables that do
+// not exist in the database, or lack the column, are skipped; several come
+// from modules older databases may still hold.
+var UserSources = []Source{
+	{Table: "user_profiles", Column: "id"},
+	{Table: "channel_user_map", Column: "user_id"},
+	{Table: "user_preferences", Column: "user_id"},
+	{Table: "user_mood_log", Column: "user_id"},
+	{Table: "user_modulation_log", Column: "user_id"},
+	{Table: "sessions", Column: "channel_key", Via: channelsOf},
+	{Table: "session_messages", Column: "session_id", Via: `SELECT id FROM sessions WHERE channel_key IN (` + channelsOf + `)`},
+	{Table: "managed_files", Column: "user_id", File: "storage_path"},
+	{Table: "reminders", Column: "user_id"},
+	{Table: "family_users", Column: "user_id"},
+	{Table: "user_permissions", Column: "user_id"},
+	{Table: "goals", Column: "user_id"},
+	{Table: "habits", Column: "scope"},
+	{Table: "habit_logs", Column: "scope"},
+	{Table: "health_data", Column: "scope"},
+	{Table: "expenses", Column: "user_id"},
+	{Table: "expense_budgets", Column: "user_id"},
+	{Table: "price_watches", Column: "user_id"},
+}
+
+// UserOptions configures ExportUser and EraseUser.
+type UserOptions struct {
+	// SigningKey signs the manifest with HMAC-SHA256. Empty leaves it unsigned.
+	SigningKey string
+	// Decrypt returns the plaintext of a value stored in table.column.
+	// Nil exports values as stored.
+	Decrypt func(table, column, value string) string
+	// Now overrides the manifest timestamp (tests).
+	Now func() time.Time
+}
+
+// Manifest records the rows and files an export or erasure covered. The
+// signature is an HMAC-SHA256 of the manifest without it, so a manifest
+// kept after an erasure proves what was deleted without holding the data.
+type Manifest struct {
+	Action    string          `json:"action"` // "export" or "erase"
+	UserID    string          `json:"userId"`
+	CreatedAt string          `json:"createdAt"`
+	DryRun    bool            `json:"dryRun,omitempty"`
+	Tables    []TableManifest `json:"tables"`
+	Files     []FileManifest  `json:"files,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// TableManifest lists the rows of one table. SHA256 is the digest of the
+// rows as exported, in rowid order.
+type TableManifest struct {
+	Table  string  `json:"table"`
+	Rows   int     `json:"rows"`
+	RowIDs []int64 `json:"rowids"`
+	SHA256 string  `json:"sha256"`
+}
+
+// FileManifest describes a file belonging to the user.
+type FileManifest struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Rows returns the total number of rows in the manifest.
+func (m *Manifest) Rows() int {
+	n := 0
+	for _, t := range m.Tables {
+		n += t.Rows
+	}
+	return n
+}
+
+func (m *Manifest) mac(key string) string {
+	c := *m
+	c.Signature = ""
+	data, _ := json.Marshal(c)
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign sets the signature for key.
+func (m *Manifest) Sign(key string) {
+	if key == "" {
+		m.Signature = ""
+		return
+	}
+	m.Signature = m.mac(key)
+}
+
+// Verify reports whether the manifest carries a valid signature for key.
+func (m *Manifest) Verify(key string) bool {
+	if key == "" || m.Signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(m.Signature), []byte(m.mac(key)))
+}
+
+// userTable is the rows one source holds for a user.
+type userTable struct {
+	src  Source
+	rows []map[string]any
+	ids  []int64
+	data []byte
+}
+
+func hasColumn(dbPath, table, column string) bool {
+	rows, err := db.Query(dbPath, fmt.Sprintf(`SELECT count(*) AS n FROM pragma_table_info('%s') WHERE name = '%s'`, db.Escape(table), db.Escape(column)))
+	return err == nil && len(rows) > 0 && db.Int(rows[0]["n"]) > 0
+}
+
+// collectUser reads the rows every source holds for userID.
+func collectUser(dbPath, userID string, opts UserOptions) ([]userTable, error) {
+	if dbPath == "" {
+		return nil, fmt.Errorf("historyDB not configured")
+	}
+	if strings.TrimSpace(userID) == "" {
+		return nil, errors.New("user ID required")
+	}
+	esc := db.Escape(userID)
+	var out []userTable
+	for _, src := range UserSources {
+		if !hasColumn(dbPath, src.Table, src.Column) {
+			continue
+		}
+		cond := fmt.Sprintf("%s = '%s'", src.Column, esc)
+		if src.Via != "" {
+			cond = fmt.Sprintf("%s IN (%s)", src.Column, fmt.Sprintf(src.Via, esc))
+		}
+		rows, err := db.Query(dbPath, fmt.Sprintf(`SELECT rowid AS "_rowid", * FROM %s WHERE %s ORDER BY rowid`, src.Table, cond))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.Table, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		t := userTable{src: src, rows: rows}
+		for _, row := range rows {
+			t.ids = append(t.ids, int64(db.Int(row["_rowid"])))
+			delete(row, "_rowid")
+			if opts.Decrypt != nil {
+				for col, v := range row {
+					if s, ok := v.(string); ok && s != "" {
+						row[col] = opts.Decrypt(src.Table, col, s)
+					}
+				}
+			}
+		}
+		t.data, _ = json.MarshalIndent(rows, "", "  ")
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func newManifest(action, userID string, tables []userTable, opts UserOptions) *Manifest {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	m := &Manifest{Action: action, UserID: userID, CreatedAt: now().UTC().Format(time.RFC3339), Tables: []TableManifest{}}
+	for _, t := range tables {
+		sum := sha256.Sum256(t.data)
+		m.Tables = append(m.Tables, TableManifest{
+			Table: t.src.Table, Rows: len(t.rows), RowIDs: t.ids, SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	return m
+}
+
+// userFiles returns the file paths referenced by the collected rows.
+func userFiles(tables []userTable) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, t := range tables {
+		if t.src.File == "" {
+			continue
+		}
+		for _, row := range t.rows {
+			p := db.Str(row[t.src.File])
+			if p != "" && !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func hashFile(path string) (FileManifest, error) {
+	fm := FileManifest{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return fm, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fm, err
+	}
+	fm.Size, fm.SHA256 = n, hex.EncodeToString(h.Sum(nil))
+	return fm, nil
+}
+
+// UserResult describes a per-user export archive.
+type UserResult struct {
+	Filename  string    `json:"filename"`
+	SizeBytes int64     `json:"sizeBytes"`
+	Manifest  *Manifest `json:"manifest"`
+}
+
+// ExportUser writes everything tied to userID to a ZIP archive in
+// baseDir/exports: one JSON file per table under tables/, the user's files
+// under files/, and the signed manifest.json.
+func ExportUser(dbPath, baseDir, userID string, opts UserOptions) (*UserResult, error) {
+	tables, err := collectUser(dbPath, userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	m := newManifest("export", userID, tables, opts)
+
+	exportsDir := filepath.Join(baseDir, "exports")
+	if err := os.MkdirAll(exportsDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create exports dir: %w", err)
+	}
+	zipPath := filepath.Join(exportsDir, fmt.Sprintf("%s_user_%s.zip", time.Now().UTC().Format("20060102-150405"), safeName(userID)))
+	f, err := os.OpenFile(zipPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	zw := zip.NewWriter(f)
+	fail := func(err error) (*UserResult, error) {
+		zw.Close()
+		f.Close()
+		os.Remove(zipPath)
+		return nil, err
+	}
+
+	for _, t := range tables {
+		w, err := zw.Create("tables/" + t.src.Table + ".json")
+		if err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(t.data); err != nil {
+			return fail(err)
+		}
+	}
+	for i, p := range userFiles(tables) {
+		fm, err := hashFile(p)
+		if err != nil {
+			fm.Error = err.Error()
+			m.Files = append(m.Files, fm)
+			continue
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return fail(err)
+		}
+		w, err := zw.Create(fmt.Sprintf("files/%d_%s", i+1, filepath.Base(p)))
+		if err == nil {
+			_, err = io.Copy(w, src)
+		}
+		src.Close()
+		if err != nil {
+			return fail(err)
+		}
+		m.Files = append(m.Files, fm)
+	}
+
+	m.Sign(opts.SigningKey)
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return fail(err)
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	if _, err := w.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := zw.Close(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(zipPath)
+		return nil, err
+	}
+	info, err := os.Stat(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	tlog.Info("user data export complete", "user", userID, "filename", zipPath, "rows", m.Rows(), "files", len(m.Files))
+	return &UserResult{Filename: zipPath, SizeBytes: info.Size(), Manifest: m}, nil
+}
+
+// EraseUser deletes everything tied to userID in a single transaction, then
+// removes the user's files. The signed manifest is returned and, unless
+// dryRun is set, also written to baseDir/exports so there is a record of
+// the erasure. A dry run only builds the manifest.
+func EraseUser(dbPath, baseDir, userID string, dryRun bool, opts UserOptions) (*Manifest, string, error) {
+	// Hashes cover the rows as stored; there is nothing to decrypt.
+	opts.Decrypt = nil
+	tables, err := collectUser(dbPath, userID, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	m := newManifest("erase", userID, tables, opts)
+	m.DryRun = dryRun
+	files := userFiles(tables)
+	for _, p := range files {
+		fm, err := hashFile(p)
+		if err != nil {
+			fm.Error = err.Error()
+		}
+		m.Files = append(m.Files, fm)
+	}
+	if dryRun {
+		m.Sign(opts.SigningKey)
+		return m, "", nil
+	}
+
+	if len(tables) > 0 {
+		var sql strings.Builder
+		sql.WriteString("BEGIN;\n")
+		for _, t := range tables {
+			ids := make([]string, len(t.ids))
+			for i, id := range t.ids {
+				ids[i] = fmt.Sprint(id)
+			}
+			fmt.Fprintf(&sql, "DELETE FROM %s WHERE rowid IN (%s);\n", t.src.Table, strings.Join(ids, ","))
+		}
+		sql.WriteString("COMMIT;")
+		if err := db.Exec(dbPath, sql.String()); err != nil {
+			return nil, "", fmt.Errorf("erase rows: %w", err)
+		}
+	}
+	for i, p := range files {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			m.Files[i].Error = err.Error()
+		}
+	}
+
+	m.Sign(opts.SigningKey)
+	exportsDir := filepath.Join(baseDir, "exports")
+	if err := os.MkdirAll(exportsDir, 0o700); err != nil {
+		return m, "", fmt.Errorf("create exports dir: %w", err)
+	}
+	path := filepath.Join(exportsDir, fmt.Sprintf("%s_erase_%s.json", time.Now().UTC().Format("20060102-150405"), safeName(userID)))
+	data, _ := json.MarshalIndent(m, "", "  ")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return m, "", fmt.Errorf("write manifest: %w", err)
+	}
+	tlog.Info("user data erased", "user", userID, "rows", m.Rows(), "files", len(m.Files), "manifest", path)
+	return m, path, nil
+}
+
+// safeName keeps letters, digits, '-' and '_' so a user ID can be part of
+// a file name.
+func safeName(s string) string {
+	out := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+	if len(out) > 64 {
+		out = out[:64]
+	}
+	return out
+}
+
+// LoadManifest reads a manifest from an erasure record or from the
+// manifest.json inside an export archive.
+func LoadManifest(path string) (*Manifest, error) {
+	var data []byte
+	if strings.HasSuffix(path, ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		rc, err := zr.Open("manifest.json")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer rc.Close()
+		if data, err = io.ReadAll(rc); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/db"
+)
+
+func TestExportAndEraseUser(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "history.db")
+	file := filepath.Join(dir, "alice.txt")
+	os.WriteFile(file, []byte("alice's file"), 0o600)
+
+	schema := `
+CREATE TABLE user_profiles (id TEXT PRIMARY KEY, display_name TEXT);
+CREATE TABLE channel_user_map (channel_key TEXT PRIMARY KEY, user_id TEXT);
+CREATE TABLE sessions (id TEXT PRIMARY KEY, channel_key TEXT);
+CREATE TABLE session_messages (id INTEGER PRIMARY KEY, session_id TEXT, content TEXT);
+CREATE TABLE managed_files (id TEXT PRIMARY KEY, user_id TEXT, storage_path TEXT);
+CREATE TABLE contacts (id TEXT PRIMARY KEY, name TEXT);
+INSERT INTO user_profiles VALUES ('alice','Alice'),('bob','Bob');
+INSERT INTO channel_user_map VALUES ('discord:1','alice'),('discord:2','bob');
+INSERT INTO sessions VALUES ('s1','discord:1'),('s2','discord:2');
+INSERT INTO session_messages (session_id, content) VALUES ('s1','secret'),('s1','hi'),('s2','bob says');
+INSERT INTO managed_files VALUES ('f1','alice','` + file + `');
+INSERT INTO contacts VALUES ('c1','Carol');
+`
+	if err := db.Exec(dbPath, schema); err != nil {
+		t.Fatal(err)
+	}
+	opts := UserOptions{
+		SigningKey: "token",
+		Decrypt: func(table, column, value string) string {
+			if table == "session_messages" && column == "content" {
+				return strings.ToUpper(value)
+			}
+			return value
+		},
+	}
+
+	res, err := ExportUser(dbPath, dir, "alice", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Manifest.Rows(); got != 6 {
+		t.Errorf("exported rows = %d, want 6", got)
+	}
+	if len(res.Manifest.Files) != 1 || res.Manifest.Files[0].Size != int64(len("alice's file")) {
+		t.Errorf("files = %+v", res.Manifest.Files)
+	}
+	zr, err := zip.OpenReader(res.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		names[f.Name] = string(b)
+	}
+	zr.Close()
+	if msgs := names["tables/session_messages.json"]; !strings.Contains(msgs, "SECRET") || strings.Contains(msgs, "BOB") {
+		t.Errorf("session_messages.json = %s", msgs)
+	}
+	if names["files/1_alice.txt"] != "alice's file" {
+		t.Errorf("archive files = %v", names)
+	}
+	m, err := LoadManifest(res.Filename)
+	if err != nil || !m.Verify("token") || m.Verify("other") {
+		t.Errorf("archive manifest verify failed: %v", err)
+	}
+
+	dry, path, err := EraseUser(dbPath, dir, "alice", true, opts)
+	if err != nil || path != "" || !dry.DryRun || dry.Rows() != 6 {
+		t.Fatalf("dry run = %+v, %q, %v", dry, path, err)
+	}
+	if rows, _ := db.Query(dbPath, "SELECT count(*) AS n FROM session_messages"); db.Int(rows[0]["n"]) != 3 {
+		t.Error("dry run deleted rows")
+	}
+
+	erased, path, err := EraseUser(dbPath, dir, "alice", false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erased.Rows() != 6 || !erased.Verify("token") {
+		t.Errorf("erase manifest = %+v", erased)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("user file not removed")
+	}
+	rows, _ := db.Query(dbPath, `SELECT (SELECT count(*) FROM user_profiles) AS p, (SELECT count(*) FROM session_messages) AS m, (SELECT count(*) FROM contacts) AS c`)
+	if db.Int(rows[0]["p"]) != 1 || db.Int(rows[0]["m"]) != 1 || db.Int(rows[0]["c"]) != 1 {
+		t.Errorf("remaining rows = %v", rows[0])
+	}
+
+	saved, err := LoadManifest(path)
+	if err != nil || !saved.Verify("token") {
+		t.Fatalf("saved manifest: %v", err)
+	}
+	saved.Tables[0].Rows++
+	if saved.Verify("token") {
+		t.Error("tampered manifest verified")
+	}
+
+	if _, err := ExportUser(dbPath, dir, " ", opts); err == nil {
+		t.Error("expected error for an empty user ID")
+	}
+}
@@ -0,0 +1,517 @@
+// Package faq answers curated questions before any agent is involved.
+//
+// Inbound messages are matched against question→answer pairs, first with a
+// cheap fuzzy score and optionally by embedding similarity. A match is
+// answered instantly at zero cost; anything else falls through to smart
+// dispatch. Unanswered questions are recorded so that repeated, similar ones
+// can be suggested to the owner as new entries.
+package faq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/knowledge"
+	"tetora/internal/log"
+)
+
+// Entry is one curated question and its canned answer.
+type Entry struct {
+	ID        int    `json:"id"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Hits      int    `json:"hits"`
+	LastHitAt string `json:"lastHitAt,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// Match is an entry that answered a message.
+type Match struct {
+	Entry  Entry   `json:"entry"`
+	Score  float64 `json:"score"`
+	Method string  `json:"method"` // "fuzzy" or "semantic"
+}
+
+// Suggestion is a cluster of similar unanswered questions.
+type Suggestion struct {
+	Question string   `json:"question"` // most recent phrasing
+	Count    int      `json:"count"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// EmbedFunc returns the embedding vector for text.
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
+// suggestWindow bounds how far back unanswered questions are clustered.
+const suggestWindow = 7 * 24 * time.Hour
+
+// Service matches messages against the FAQ and manages its entries.
+type Service struct {
+	dbPath string
+	cfg    config.FAQConfig
+	embed  EmbedFunc
+	notify func(text string)
+
+	mu        sync.Mutex
+	entries   []Entry
+	vectors   map[int][]float32 // entry ID -> question embedding
+	suggested []string          // questions already suggested to the owner
+}
+
+// New creates a service and loads the stored entries. embed enables semantic
+// matching when cfg.Semantic is set; notify delivers suggestions to the owner.
+// Both are optional.
+func New(dbPath string, cfg config.FAQConfig, embed EmbedFunc, notify func(text string)) *Service {
+	s := &Service{
+		dbPath:  dbPath,
+		cfg:     cfg,
+		notify:  notify,
+		vectors: make(map[int][]float32),
+	}
+	if cfg.Semantic {
+		s.embed = embed
+	}
+	if err := s.reload(); err != nil {
+		log.Warn("faq: load entries failed", "error", err)
+	}
+	return s
+}
+
+// Answer checks text against the FAQ. source labels where the question came
+// from (e.g. "discord", "telegram") and is recorded with it. Commands and long
+// messages are never answered.
+func (s *Service) Answer(ctx context.Context, text, source string) (Match, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "/") || strings.HasPrefix(text, "!") ||
+		len([]rune(text)) > s.cfg.MaxLengthOrDefault() {
+		return Match{}, false
+	}
+
+	m, ok := s.Lookup(ctx, text)
+	if ok {
+		now := time.Now().UTC().Format(time.RFC3339)
+		if err := db.ExecArgs(s.dbPath,
+			`UPDATE faq_entries SET hits = hits + 1, last_hit_at = ? WHERE id = ?`, now, m.Entry.ID); err != nil {
+			log.Warn("faq: record hit failed", "id", m.Entry.ID, "error", err)
+		}
+		s.mu.Lock()
+		for i := range s.entries {
+			if s.entries[i].ID == m.Entry.ID {
+				s.entries[i].Hits++
+				s.entries[i].LastHitAt = now
+			}
+		}
+		s.mu.Unlock()
+		s.recordQuestion(text, source, m.Entry.ID)
+		return m, true
+	}
+
+	s.recordQuestion(text, source, 0)
+	s.maybeSuggest(text)
+	return Match{}, false
+}
+
+// Lookup returns the best entry for text without recording anything.
+func (s *Service) Lookup(ctx context.Context, text string) (Match, bool) {
+	s.mu.Lock()
+	entries := append([]Entry(nil), s.entries...)
+	s.mu.Unlock()
+	if len(entries) == 0 {
+		return Match{}, false
+	}
+
+	var best Match
+	for _, e := range entries {
+		if score := Similarity(text, e.Question); score > best.Score {
+			best = Match{Entry: e, Score: score, Method: "fuzzy"}
+		}
+	}
+	if best.Score >= s.cfg.ThresholdOrDefault() {
+		return best, true
+	}
+
+	if s.embed == nil {
+		return Match{}, false
+	}
+	vec, err := s.embed(ctx, text)
+	if err != nil {
+		log.Debug("faq: embed question failed", "error", err)
+		return Match{}, false
+	}
+	best = Match{}
+	for _, e := range entries {
+		ev := s.vector(ctx, e)
+		if ev == nil {
+			continue
+		}
+		if score := float64(knowledge.CosineSimilarity(vec, ev)); score > best.Score {
+			best = Match{Entry: e, Score: score, Method: "semantic"}
+		}
+	}
+	if best.Score >= s.cfg.SemanticThresholdOrDefault() {
+		return best, true
+	}
+	return Match{}, false
+}
+
+// vector returns the cached embedding of an entry's question, computing it on
+// first use.
+func (s *Service) vector(ctx context.Context, e Entry) []float32 {
+	s.mu.Lock()
+	v, ok := s.vectors[e.ID]
+	s.mu.Unlock()
+	if ok {
+		return v
+	}
+	v, err := s.embed(ctx, e.Question)
+	if err != nil {
+		log.Debug("faq: embed entry failed", "id", e.ID, "error", err)
+		return nil
+	}
+	s.mu.Lock()
+	s.vectors[e.ID] = v
+	s.mu.Unlock()
+	return v
+}
+
+// --- CRUD ---
+
+// List returns all entries, most used first.
+func (s *Service) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]Entry(nil), s.entries...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Hits > out[j].Hits })
+	return out
+}
+
+// Get returns one entry.
+func (s *Service) Get(id int) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Add stores a new entry.
+func (s *Service) Add(question, answer string) (Entry, error) {
+	question, answer = strings.TrimSpace(question), strings.TrimSpace(answer)
+	if question == "" || answer == "" {
+		return Entry{}, fmt.Errorf("question and answer are required")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	rows, err := db.QueryArgs(s.dbPath,
+		`INSERT INTO faq_entries (question, answer, created_at, updated_at) VALUES (?, ?, ?, ?);
+		 SELECT last_insert_rowid() AS id;`,
+		question, answer, now, now)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(rows) == 0 {
+		return Entry{}, fmt.Errorf("insert returned no id")
+	}
+	e := Entry{ID: db.Int(rows[0]["id"]), Question: question, Answer: answer, CreatedAt: now, UpdatedAt: now}
+	s.mu.Lock()
+	s.entries = append(s.entries, e)
+	s.mu.Unlock()
+	return e, nil
+}
+
+// Update replaces an entry's question and answer.
+func (s *Service) Update(id int, question, answer string) (Entry, error) {
+	question, answer = strings.TrimSpace(question), strings.TrimSpace(answer)
+	if question == "" || answer == "" {
+		return Entry{}, fmt.Errorf("question and answer are required")
+	}
+	e, ok := s.Get(id)
+	if !ok {
+		return Entry{}, fmt.Errorf("faq entry %d not found", id)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err := db.ExecArgs(s.dbPath,
+		`UPDATE faq_entries SET question = ?, answer = ?, updated_at = ? WHERE id = ?`,
+		question, answer, now, id); err != nil {
+		return Entry{}, err
+	}
+	e.Question, e.Answer, e.UpdatedAt = question, answer, now
+	s.mu.Lock()
+	for i := range s.entries {
+		if s.entries[i].ID == id {
+			s.entries[i] = e
+		}
+	}
+	delete(s.vectors, id)
+	s.mu.Unlock()
+	return e, nil
+}
+
+// Delete removes an entry.
+func (s *Service) Delete(id int) error {
+	if _, ok := s.Get(id); !ok {
+		return fmt.Errorf("faq entry %d not found", id)
+	}
+	if err := db.ExecArgs(s.dbPath, `DELETE FROM faq_entries WHERE id = ?`, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for i := range s.entries {
+		if s.entries[i].ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	delete(s.vectors, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) reload() error {
+	if s.dbPath == "" {
+		return nil
+	}
+	rows, err := db.Query(s.dbPath, `SELECT * FROM faq_entries ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, Entry{
+			ID:        db.Int(row["id"]),
+			Question:  db.Str(row["question"]),
+			Answer:    db.Str(row["answer"]),
+			Hits:      db.Int(row["hits"]),
+			LastHitAt: db.Str(row["last_hit_at"]),
+			CreatedAt: db.Str(row["created_at"]),
+			UpdatedAt: db.Str(row["updated_at"]),
+		})
+	}
+	s.mu.Lock()
+	s.entries = entries
+	s.mu.Unlock()
+	return nil
+}
+
+// --- Suggestions ---
+
+func (s *Service) recordQuestion(text, source string, faqID int) {
+	if s.dbPath == "" {
+		return
+	}
+	if err := db.ExecArgs(s.dbPath,
+		`INSERT INTO faq_questions (text, source, faq_id, created_at) VALUES (?, ?, ?, ?)`,
+		text, source, faqID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		log.Warn("faq: record question failed", "error", err)
+	}
+}
+
+// maybeSuggest notifies the owner once a question has been asked, unanswered,
+// often enough to be worth an entry.
+func (s *Service) maybeSuggest(text string) {
+	threshold := s.cfg.SuggestAfterOrDefault()
+	if threshold == 0 || s.notify == nil {
+		return
+	}
+	s.mu.Lock()
+	for _, q := range s.suggested {
+		if Similarity(text, q) >= s.cfg.ThresholdOrDefault() {
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.mu.Unlock()
+
+	count := 0
+	for _, q := range s.unanswered() {
+		if Similarity(text, q) >= s.cfg.ThresholdOrDefault() {
+			count++
+		}
+	}
+	if count < threshold {
+		return
+	}
+	s.mu.Lock()
+	s.suggested = append(s.suggested, text)
+	s.mu.Unlock()
+	s.notify(fmt.Sprintf("FAQ suggestion: asked %d times recently without a canned answer:\n%q\nAdd one with: /faq add %s | <answer>",
+		count, text, text))
+}
+
+// Suggest clusters recent unanswered questions that still have no matching
+// entry, largest clusters first. Only clusters of two or more are returned.
+func (s *Service) Suggest() []Suggestion {
+	threshold := s.cfg.ThresholdOrDefault()
+	var clusters []Suggestion
+	for _, q := range s.unanswered() {
+		if _, ok := s.Lookup(context.Background(), q); ok {
+			continue
+		}
+		placed := false
+		for i := range clusters {
+			if Similarity(q, clusters[i].Question) >= threshold {
+				clusters[i].Count++
+				if len(clusters[i].Examples) < 3 {
+					clusters[i].Examples = append(clusters[i].Examples, q)
+				}
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			clusters = append(clusters, Suggestion{Question: q, Count: 1, Examples: []string{q}})
+		}
+	}
+	out := clusters[:0]
+	for _, c := range clusters {
+		if c.Count >= 2 {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	return out
+}
+
+// unanswered returns recent questions no entry answered, newest first.
+func (s *Service) unanswered() []string {
+	if s.dbPath == "" {
+		return nil
+	}
+	since := time.Now().Add(-suggestWindow).UTC().Format(time.RFC3339)
+	rows, err := db.QueryArgs(s.dbPath,
+		`SELECT text FROM faq_questions WHERE faq_id = 0 AND created_at >= ? ORDER BY id DESC LIMIT 500`, since)
+	if err != nil {
+		log.Warn("faq: load questions failed", "error", err)
+		return nil
+	}
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, db.Str(row["text"]))
+	}
+	return out
+}
+
+// --- Matching ---
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "are": true, "do": true, "does": true,
+	"i": true, "you": true, "your": true, "my": true, "me": true, "we": true, "to": true,
+	"of": true, "in": true, "on": true, "for": true, "it": true, "can": true, "please": true,
+	"what": true, "when": true, "where": true, "how": true, "which": true, "there": true, "any": true,
+}
+
+// normalize lowercases text, drops punctuation and collapses whitespace.
+func normalize(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+			space = false
+		case !space && b.Len() > 0:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Similarity scores two questions from 0 to 1. It takes the better of word
+// overlap (ignoring stopwords) and character-bigram overlap, so that
+// rephrasings and languages without spaces both match.
+func Similarity(a, b string) float64 {
+	na, nb := normalize(a), normalize(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+	return max(jaccard(words(na), words(nb)), dice(bigrams(na), bigrams(nb)))
+}
+
+func words(s string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		if !stopwords[w] {
+			out[w] = true
+		}
+	}
+	return out
+}
+
+func bigrams(s string) map[string]int {
+	r := []rune(strings.ReplaceAll(s, " ", ""))
+	out := make(map[string]int)
+	for i := 0; i+1 < len(r); i++ {
+		out[string(r[i:i+2])]++
+	}
+	return out
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+func dice(a, b map[string]int) float64 {
+	total := 0
+	for _, n := range a {
+		total += n
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	inter := 0
+	for g, n := range a {
+		inter += min(n, b[g])
+	}
+	return 2 * float64(inter) / float64(total)
+}
+
+// InitDB creates the FAQ tables.
+func InitDB(dbPath string) error {
+	if dbPath == "" {
+		return nil
+	}
+	sql := `CREATE TABLE IF NOT EXISTS faq_entries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  question TEXT NOT NULL,
+  answer TEXT NOT NULL,
+  hits INTEGER DEFAULT 0,
+  last_hit_at TEXT DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS faq_questions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  text TEXT NOT NULL,
+  source TEXT DEFAULT '',
+  faq_id INTEGER DEFAULT 0,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_faq_questions_created ON faq_questions(created_at);`
+	return db.Exec(dbPath, sql)
+}
@@ -0,0 +1,128 @@
+package faq
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool // score >= 0.8
+	}{
+		{"What are your opening hours?", "what are your opening hours", true},
+		{"What are the opening hours?", "opening hours?", true},
+		{"營業時間是幾點", "營業時間是幾點?", true},
+		{"How do I reset my password?", "What are your opening hours?", false},
+		{"", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b) >= 0.8; got != tt.want {
+			t.Errorf("Similarity(%q, %q) = %.2f", tt.a, tt.b, Similarity(tt.a, tt.b))
+		}
+	}
+}
+
+func newTestService(t *testing.T, cfg config.FAQConfig, embed EmbedFunc) (*Service, *[]string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	var notes []string
+	return New(dbPath, cfg, embed, func(s string) { notes = append(notes, s) }), &notes
+}
+
+func TestServiceAnswer(t *testing.T) {
+	s, _ := newTestService(t, config.FAQConfig{Enabled: true}, nil)
+	ctx := context.Background()
+
+	e, err := s.Add("What are your opening hours?", "9am to 6pm, Monday to Friday.")
+	if err != nil || e.ID == 0 {
+		t.Fatalf("Add: %+v, %v", e, err)
+	}
+
+	m, ok := s.Answer(ctx, "what are the opening hours", "discord")
+	if !ok || m.Entry.Answer != e.Answer || m.Method != "fuzzy" {
+		t.Fatalf("Answer: ok=%v match=%+v", ok, m)
+	}
+	if _, ok := s.Answer(ctx, "Can you refactor the billing module?", "discord"); ok {
+		t.Error("unrelated question should fall through")
+	}
+	if _, ok := s.Answer(ctx, "/opening hours", "telegram"); ok {
+		t.Error("commands should never be answered")
+	}
+
+	// Hits persist across restarts.
+	reloaded := New(s.dbPath, config.FAQConfig{}, nil, nil)
+	if got, _ := reloaded.Get(e.ID); got.Hits != 1 {
+		t.Errorf("hits = %d, want 1", got.Hits)
+	}
+
+	if _, err := s.Update(e.ID, e.Question, "10am to 7pm."); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if m, _ := s.Answer(ctx, "opening hours?", "discord"); m.Entry.Answer != "10am to 7pm." {
+		t.Errorf("updated answer = %q", m.Entry.Answer)
+	}
+	if err := s.Delete(e.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := s.Answer(ctx, "opening hours?", "discord"); ok {
+		t.Error("deleted entry should not answer")
+	}
+	if err := s.Delete(e.ID); err == nil {
+		t.Error("deleting a missing entry should fail")
+	}
+}
+
+func TestServiceSemantic(t *testing.T) {
+	// A toy embedding: questions mentioning price land on the same axis.
+	embed := func(_ context.Context, text string) ([]float32, error) {
+		if strings.Contains(text, "cost") || strings.Contains(text, "price") {
+			return []float32{1, 0}, nil
+		}
+		return []float32{0, 1}, nil
+	}
+	s, _ := newTestService(t, config.FAQConfig{Enabled: true, Semantic: true}, embed)
+	s.Add("What does the plan cost?", "$10 a month.")
+
+	m, ok := s.Answer(context.Background(), "tell me the price", "discord")
+	if !ok || m.Method != "semantic" {
+		t.Fatalf("semantic match: ok=%v match=%+v", ok, m)
+	}
+}
+
+func TestServiceSuggest(t *testing.T) {
+	s, notes := newTestService(t, config.FAQConfig{Enabled: true}, nil)
+	ctx := context.Background()
+
+	for _, q := range []string{"Do you ship to Japan?", "do you ship to japan", "Do you ship to Japan??"} {
+		s.Answer(ctx, q, "discord")
+	}
+	s.Answer(ctx, "something unrelated entirely", "discord")
+
+	if len(*notes) != 1 || !strings.Contains((*notes)[0], "/faq add") {
+		t.Fatalf("notifications = %q", *notes)
+	}
+	// The same cluster is only suggested once.
+	s.Answer(ctx, "do you ship to Japan?", "discord")
+	if len(*notes) != 1 {
+		t.Errorf("duplicate suggestion: %q", *notes)
+	}
+
+	sugg := s.Suggest()
+	if len(sugg) != 1 || sugg[0].Count != 4 {
+		t.Fatalf("Suggest = %+v", sugg)
+	}
+
+	// Once answered, the cluster is no longer suggested.
+	s.Add("Do you ship to Japan?", "Yes, worldwide.")
+	if sugg := s.Suggest(); len(sugg) != 0 {
+		t.Errorf("Suggest after add = %+v", sugg)
+	}
+}
@@ -0,0 +1,182 @@
+// Package feedback stores the owner's verdict on task results.
+//
+// A verdict is a thumbs up or down with an optional comment, given from the
+// buttons under a chat reply, the dashboard or the CLI. One verdict is kept
+// per task (a later one replaces it) together with the agent that ran the
+// task, so routing stats, reflections and evaluations can read how each
+// agent's answers were received.
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// InitDB creates the task_feedback table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS task_feedback (
+		task_id TEXT PRIMARY KEY,
+		agent TEXT DEFAULT '',
+		score INTEGER NOT NULL,
+		comment TEXT DEFAULT '',
+		source TEXT DEFAULT '',
+		author TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_feedback_agent ON task_feedback(agent, updated_at);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Entry is the verdict on one task.
+type Entry struct {
+	TaskID    string `json:"taskId"`
+	Agent     string `json:"agent,omitempty"`
+	Score     int    `json:"score"` // 1 up, -1 down
+	Comment   string `json:"comment,omitempty"`
+	Source    string `json:"source,omitempty"` // "telegram", "discord", "slack", "http", "cli"
+	Author    string `json:"author,omitempty"` // who gave it, e.g. "telegram:@owner"
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// ErrUnknownTask is returned for feedback on a task with no history.
+var ErrUnknownTask = errors.New("unknown task")
+
+// ParseScore accepts 1/-1 and the words up/down, good/bad, +1.
+func ParseScore(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "+1", "up", "good", "👍":
+		return 1, nil
+	case "-1", "down", "bad", "👎":
+		return -1, nil
+	}
+	return 0, fmt.Errorf("score must be up or down, not %q", s)
+}
+
+// Record stores e, replacing an earlier verdict on the task. The agent is
+// looked up in the task's history when not given; tasks without history are
+// refused with ErrUnknownTask. A verdict without a comment keeps the earlier
+// comment, so a button press after a written comment does not erase it.
+func Record(dbPath string, e Entry) (*Entry, error) {
+	if e.Score != 1 && e.Score != -1 {
+		return nil, fmt.Errorf("score must be 1 or -1")
+	}
+	if e.TaskID == "" {
+		return nil, fmt.Errorf("taskId is required")
+	}
+	if e.Agent == "" {
+		rows, err := db.QueryArgs(dbPath, `SELECT agent FROM job_runs WHERE job_id = ? ORDER BY id DESC LIMIT 1`, e.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, ErrUnknownTask
+		}
+		e.Agent = db.Str(rows[0]["agent"])
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	e.CreatedAt, e.UpdatedAt = now, now
+	err := db.ExecArgs(dbPath, `INSERT INTO task_feedback (task_id, agent, score, comment, source, author, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id) DO UPDATE SET score = excluded.score, source = excluded.source, author = excluded.author,
+			comment = CASE WHEN excluded.comment != '' THEN excluded.comment ELSE comment END,
+			updated_at = excluded.updated_at`,
+		e.TaskID, e.Agent, e.Score, e.Comment, e.Source, e.Author, e.CreatedAt, e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return Get(dbPath, e.TaskID)
+}
+
+const columns = `task_id, agent, score, comment, source, author, created_at, updated_at`
+
+// Get returns the verdict on a task, or nil when there is none.
+func Get(dbPath, taskID string) (*Entry, error) {
+	rows, err := db.QueryArgs(dbPath, `SELECT `+columns+` FROM task_feedback WHERE task_id = ?`, taskID)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	e := entryFromRow(rows[0])
+	return &e, nil
+}
+
+// Filter selects verdicts for List.
+type Filter struct {
+	Agent string
+	Score int    // 1 or -1; 0 for both
+	Since string // RFC 3339; verdicts updated at or after it
+	Limit int    // default 50, at most 1000
+}
+
+// List returns verdicts, newest first.
+func List(dbPath string, f Filter) ([]Entry, error) {
+	var where []string
+	var args []any
+	if f.Agent != "" {
+		where, args = append(where, "agent = ?"), append(args, f.Agent)
+	}
+	if f.Score != 0 {
+		where, args = append(where, "score = ?"), append(args, f.Score)
+	}
+	if f.Since != "" {
+		where, args = append(where, "updated_at >= ?"), append(args, f.Since)
+	}
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	sql := `SELECT ` + columns + ` FROM task_feedback`
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += fmt.Sprintf(" ORDER BY updated_at DESC, task_id LIMIT %d", min(f.Limit, 1000))
+	rows, err := db.QueryArgs(dbPath, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, entryFromRow(row))
+	}
+	return out, nil
+}
+
+func entryFromRow(row map[string]any) Entry {
+	return Entry{
+		TaskID:    db.Str(row["task_id"]),
+		Agent:     db.Str(row["agent"]),
+		Score:     db.Int(row["score"]),
+		Comment:   db.Str(row["comment"]),
+		Source:    db.Str(row["source"]),
+		Author:    db.Str(row["author"]),
+		CreatedAt: db.Str(row["created_at"]),
+		UpdatedAt: db.Str(row["updated_at"]),
+	}
+}
+
+// Totals counts one agent's verdicts.
+type Totals struct {
+	Up   int `json:"up"`
+	Down int `json:"down"`
+}
+
+// AgentTotals returns the up and down counts per agent.
+func AgentTotals(dbPath string) (map[string]Totals, error) {
+	rows, err := db.Query(dbPath, `SELECT agent,
+		SUM(CASE WHEN score > 0 THEN 1 ELSE 0 END) AS up,
+		SUM(CASE WHEN score < 0 THEN 1 ELSE 0 END) AS down
+		FROM task_feedback GROUP BY agent`)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Totals, len(rows))
+	for _, row := range rows {
+		out[db.Str(row["agent"])] = Totals{Up: db.Int(row["up"]), Down: db.Int(row["down"])}
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package feedback
+
+import (
+	"path/filepath"
+	"testing"
+
+	"tetora/internal/db"
+)
+
+func TestRecord(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	db.Exec(dbPath, `CREATE TABLE job_runs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id TEXT, agent TEXT);
+		INSERT INTO job_runs (job_id, agent) VALUES ('t1', 'ruri'), ('t2', 'ruri'), ('t3', 'kokuyou');`)
+
+	if _, err := Record(dbPath, Entry{TaskID: "t1", Score: 1, Source: "telegram"}); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Record(dbPath, Entry{TaskID: "t2", Score: -1, Comment: "missed the deadline", Source: "http"})
+	if err != nil || e.Agent != "ruri" || e.Comment != "missed the deadline" {
+		t.Fatalf("t2 = %+v, %v", e, err)
+	}
+	// A later button press replaces the score but keeps the comment.
+	if e, _ = Record(dbPath, Entry{TaskID: "t2", Score: 1, Source: "discord"}); e.Score != 1 || e.Comment != "missed the deadline" || e.Source != "discord" {
+		t.Errorf("t2 again = %+v", e)
+	}
+	Record(dbPath, Entry{TaskID: "t3", Score: -1})
+	if _, err := Record(dbPath, Entry{TaskID: "nope", Score: 1}); err != ErrUnknownTask {
+		t.Errorf("unknown task: %v", err)
+	}
+	if _, err := Record(dbPath, Entry{TaskID: "t1", Score: 3}); err == nil {
+		t.Error("score 3 accepted")
+	}
+
+	totals, err := AgentTotals(dbPath)
+	if err != nil || totals["ruri"] != (Totals{Up: 2}) || totals["kokuyou"] != (Totals{Down: 1}) {
+		t.Errorf("totals = %+v, %v", totals, err)
+	}
+	if list, _ := List(dbPath, Filter{Score: -1}); len(list) != 1 || list[0].TaskID != "t3" {
+		t.Errorf("down = %+v", list)
+	}
+	if list, _ := List(dbPath, Filter{Agent: "ruri"}); len(list) != 2 {
+		t.Errorf("ruri = %+v", list)
+	}
+}
+
+func TestParseScore(t *testing.T) {
+	for in, want := range map[string]int{"up": 1, "+1": 1, "Down": -1, "-1": -1, "meh": 0} {
+		if got, _ := ParseScore(in); got != want {
+			t.Errorf("ParseScore(%q) = %d", in, got)
+		}
+	}
+}
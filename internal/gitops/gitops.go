@@ -0,0 +1,285 @@
+// Package gitops reconciles an instance from a git repository: the config
+// file and its overlays, agent souls, prompts, skills and workflows. Each new
+// commit of the branch is fetched, validated as a whole, snapshotted and
+// applied, so a bad commit changes nothing. The files applied are recorded,
+// which lets later runs delete files removed from the repo and report live
+// edits to managed files as drift.
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+	"tetora/internal/validate"
+)
+
+// Paths are where the repo's files are applied on this instance.
+type Paths struct {
+	ConfigPath   string // the config file; config.d/ is next to it
+	AgentsDir    string
+	PromptsDir   string
+	SkillsDir    string
+	WorkflowsDir string
+	StateDir     string // the checkout, the applied manifest and snapshots
+	HistoryDB    string // version history of applied config, prompts and workflows
+}
+
+// StateDir returns the GitOps state directory of the instance whose config
+// file is configPath.
+func StateDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "gitops")
+}
+
+// Deps connects the service to the daemon.
+type Deps struct {
+	// Env returns what souls and workflows are checked against. Agents
+	// are replaced by those of the repo's config when it has one.
+	Env func() validate.Env
+
+	// OnApply is called with the kinds of file ("config", "agents",
+	// "prompts", "skills", "workflows") an apply changed.
+	OnApply func(kinds []string)
+}
+
+// Result is the outcome of one reconcile.
+type Result struct {
+	Revision string   `json:"revision"`
+	Subject  string   `json:"subject,omitempty"`
+	DryRun   bool     `json:"dryRun,omitempty"`
+	UpToDate bool     `json:"upToDate,omitempty"` // the revision was already applied and nothing drifted
+	Changed  []string `json:"changed"`            // repo paths written
+	Deleted  []string `json:"deleted"`            // live files removed from the repo
+	Snapshot string   `json:"snapshot,omitempty"` // where the replaced files were saved
+}
+
+// Status is the state of the reconciler.
+type Status struct {
+	Enabled   bool    `json:"enabled"`
+	Repo      string  `json:"repo"`
+	Branch    string  `json:"branch"`
+	Path      string  `json:"path,omitempty"`
+	Interval  string  `json:"interval"`
+	SelfHeal  bool    `json:"selfHeal"`
+	Revision  string  `json:"revision,omitempty"` // applied
+	Subject   string  `json:"subject,omitempty"`
+	AppliedAt string  `json:"appliedAt,omitempty"`
+	Fetched   string  `json:"fetched,omitempty"` // tip of the branch at the last check
+	LastCheck string  `json:"lastCheck,omitempty"`
+	Files     int     `json:"files"`
+	Drift     []Drift `json:"drift"`
+	Snapshot  string  `json:"snapshot,omitempty"` // of the last apply
+	Error     string  `json:"error,omitempty"`    // of the last check
+}
+
+// Service runs the reconciler.
+type Service struct {
+	cfg     config.GitOpsConfig
+	paths   Paths
+	deps    Deps
+	trigger chan struct{}
+
+	runMu sync.Mutex // one reconcile at a time
+
+	mu        sync.Mutex
+	fetched   string
+	lastCheck time.Time
+	lastSnap  string
+	lastErr   string
+	failedRev string // rejected revision, so it is reported once
+}
+
+// New returns a reconciler for cfg.
+func New(cfg config.GitOpsConfig, paths Paths, deps Deps) (*Service, error) {
+	if cfg.Repo == "" {
+		return nil, errors.New("gitops.repo is required")
+	}
+	if cfg.Path != "" && !filepath.IsLocal(filepath.FromSlash(cfg.Path)) {
+		return nil, fmt.Errorf("gitops.path %q must be a directory inside the repo", cfg.Path)
+	}
+	if paths.ConfigPath == "" || paths.StateDir == "" {
+		return nil, errors.New("gitops: config path and state directory are required")
+	}
+	return &Service{cfg: cfg, paths: paths, deps: deps, trigger: make(chan struct{}, 1)}, nil
+}
+
+// Start launches the reconcile loop: now, then every poll interval and
+// whenever Trigger is called, until ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	interval := s.cfg.IntervalOrDefault()
+	log.Info("gitops: reconciling from repo", "repo", s.cfg.Repo, "branch", s.cfg.BranchOrDefault(), "interval", interval)
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			s.reconcile(ctx, false, false)
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// Trigger asks the running reconciler to check the repo now, as on a
+// webhook delivery. It does not wait.
+func (s *Service) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default: // a check is already pending
+	}
+}
+
+// Sync fetches the repo and applies it now, also over drift and a revision
+// rejected before. A dry run only reports what would change.
+func (s *Service) Sync(ctx context.Context, dryRun bool) (*Result, error) {
+	return s.reconcile(ctx, true, dryRun)
+}
+
+func (s *Service) reconcile(ctx context.Context, force, dryRun bool) (*Result, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	rev, subject, err := s.fetch(ctx)
+	s.mu.Lock()
+	s.lastCheck = time.Now()
+	if err == nil {
+		s.fetched = rev
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, s.fail("", fmt.Errorf("fetch: %w", err))
+	}
+	prev, err := loadManifest(s.paths.StateDir)
+	if err != nil {
+		return nil, s.fail("", err)
+	}
+	res := &Result{Revision: rev, Subject: subject, DryRun: dryRun, Changed: []string{}, Deleted: []string{}}
+
+	if !force && prev != nil && prev.Revision == rev {
+		drift := s.drift(prev)
+		if len(drift) == 0 || !s.cfg.SelfHeal {
+			s.ok()
+			res.UpToDate = len(drift) == 0
+			return res, nil
+		}
+		log.Info("gitops: re-applying over local edits", "revision", short(rev), "drifted", len(drift))
+	}
+	s.mu.Lock()
+	rejected := !force && rev == s.failedRev
+	lastErr := s.lastErr
+	s.mu.Unlock()
+	if rejected {
+		return nil, errors.New(lastErr)
+	}
+
+	root := s.root()
+	files, err := s.desired(root)
+	if err == nil {
+		err = s.check(root, files)
+	}
+	if err != nil {
+		return nil, s.fail(rev, fmt.Errorf("revision %s rejected: %w", short(rev), err))
+	}
+	changed, deleted := s.plan(files, prev)
+	for _, e := range changed {
+		res.Changed = append(res.Changed, e.Rel)
+	}
+	for _, f := range deleted {
+		res.Deleted = append(res.Deleted, f.Rel)
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	reason := fmt.Sprintf("gitops %s: %s", short(rev), subject)
+	snap, err := s.apply(rev, reason, changed, deleted)
+	if err != nil {
+		return nil, s.fail("", fmt.Errorf("apply %s: %w", short(rev), err))
+	}
+	if err := saveManifest(s.paths.StateDir, s.manifestOf(rev, subject, files)); err != nil {
+		return nil, s.fail("", fmt.Errorf("save manifest: %w", err))
+	}
+	res.Snapshot = snap
+	s.mu.Lock()
+	if snap != "" {
+		s.lastSnap = snap
+	}
+	s.mu.Unlock()
+	s.ok()
+	if len(changed)+len(deleted) > 0 {
+		log.Info("gitops: applied", "revision", short(rev), "subject", subject, "changed", len(changed), "deleted", len(deleted))
+		if s.deps.OnApply != nil {
+			s.deps.OnApply(kindsOf(changed, deleted))
+		}
+	}
+	return res, nil
+}
+
+// fail records the error of a check and returns it. A revision that was
+// rejected is remembered, so polling does not retry and log it again.
+func (s *Service) fail(rev string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err.Error() != s.lastErr {
+		log.Warn("gitops: reconcile failed", "error", err)
+	}
+	s.lastErr = err.Error()
+	s.failedRev = rev
+	return err
+}
+
+func (s *Service) ok() {
+	s.mu.Lock()
+	s.lastErr, s.failedRev = "", ""
+	s.mu.Unlock()
+}
+
+// Status reports the applied revision, the last check and the drift of the
+// live files from it.
+func (s *Service) Status() Status {
+	interval := s.cfg.IntervalOrDefault().String()
+	if s.cfg.IntervalOrDefault() == 0 {
+		interval = "off"
+	}
+	st := Status{
+		Enabled:  true,
+		Repo:     s.cfg.Repo,
+		Branch:   s.cfg.BranchOrDefault(),
+		Path:     s.cfg.Path,
+		Interval: interval,
+		SelfHeal: s.cfg.SelfHeal,
+		Drift:    []Drift{},
+	}
+	s.mu.Lock()
+	st.Fetched, st.Snapshot, st.Error = s.fetched, s.lastSnap, s.lastErr
+	if !s.lastCheck.IsZero() {
+		st.LastCheck = s.lastCheck.UTC().Format(time.RFC3339)
+	}
+	s.mu.Unlock()
+	m, err := loadManifest(s.paths.StateDir)
+	if err != nil {
+		if st.Error == "" {
+			st.Error = err.Error()
+		}
+		return st
+	}
+	if m != nil {
+		st.Revision, st.Subject, st.AppliedAt, st.Files = m.Revision, m.Subject, m.AppliedAt, len(m.Files)
+		if d := s.drift(m); d != nil {
+			st.Drift = d
+		}
+	}
+	return st
+}
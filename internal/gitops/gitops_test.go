@@ -0,0 +1,288 @@
+package gitops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/validate"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+// testRepo is a git repo in a temp dir to reconcile from.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch", "main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+	cmd.Dir = r.dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+// commit writes the files (a "" content removes one) and commits them.
+func (r *testRepo) commit(msg string, files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		p := filepath.Join(r.dir, filepath.FromSlash(name))
+		if content == "" {
+			os.Remove(p)
+			continue
+		}
+		writeTestFile(r.t, p, content)
+	}
+	r.git("add", "-A")
+	r.git("commit", "--quiet", "-m", msg)
+}
+
+func newTestService(t *testing.T, cfg config.GitOpsConfig) (*Service, Paths, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	p := Paths{
+		ConfigPath:   filepath.Join(dir, "config.json"),
+		AgentsDir:    filepath.Join(dir, "agents"),
+		PromptsDir:   filepath.Join(dir, "prompts"),
+		SkillsDir:    filepath.Join(dir, "workspace", "skills"),
+		WorkflowsDir: filepath.Join(dir, "workflows"),
+		StateDir:     filepath.Join(dir, "gitops"),
+	}
+	writeTestFile(t, p.ConfigPath, `{"agents": {}}`)
+	var applied []string
+	s, err := New(cfg, p, Deps{
+		Env:     func() validate.Env { return validate.Env{Agents: map[string]bool{}} },
+		OnApply: func(kinds []string) { applied = append(applied, kinds...) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, p, &applied
+}
+
+const testConfig = `{"configVersion": 3, "listenAddr": ":8991", "agents": {"ruri": {"model": "sonnet"}}}`
+
+const testWorkflowDoc = `{"name": "review", "steps": [{"id": "a", "agent": "ruri", "prompt": "Review"}]}`
+
+func TestGitOpsApplyAndDrift(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("initial", map[string]string{
+		"config.json":               testConfig,
+		"agents/ruri/SOUL.md":       "You are Ruri.\n",
+		"prompts/daily.md":          "Summarize the day.\n",
+		"workflows/review.json":     testWorkflowDoc,
+		"skills/lint/SKILL.md":      "# lint\n\nRuns the linter.\n",
+		"skills/lint/metadata.json": `{"name": "lint", "command": "{skillDir}/run.sh", "approved": true}`,
+		"README.md":                 "not applied\n",
+	})
+	s, p, applied := newTestService(t, config.GitOpsConfig{Repo: repo.dir})
+	ctx := context.Background()
+
+	res, err := s.Sync(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changed) != 6 || readTestFile(p.ConfigPath) != `{"agents": {}}` {
+		t.Fatalf("dry run = %+v", res)
+	}
+	if res, err = s.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changed) != 6 || res.Snapshot == "" {
+		t.Fatalf("sync = %+v", res)
+	}
+	if readTestFile(p.ConfigPath) != testConfig || readTestFile(filepath.Join(p.AgentsDir, "ruri", "SOUL.md")) != "You are Ruri.\n" ||
+		readTestFile(filepath.Join(p.WorkflowsDir, "review.json")) != testWorkflowDoc {
+		t.Error("files not applied")
+	}
+	if meta := readTestFile(filepath.Join(p.SkillsDir, "lint", "metadata.json")); !strings.Contains(meta, filepath.Join(p.SkillsDir, "lint", "run.sh")) {
+		t.Errorf("skill command not resolved: %s", meta)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(p.ConfigPath), "README.md")); err == nil {
+		t.Error("file outside the layout applied")
+	}
+	if !slices.Equal(*applied, []string{"agents", "config", "prompts", "skills", "workflows"}) {
+		t.Errorf("onApply kinds = %v", *applied)
+	}
+
+	// Usage recorded by the skill is not drift; a local edit to a soul is.
+	writeTestFile(t, filepath.Join(p.SkillsDir, "lint", "metadata.json"),
+		strings.Replace(readTestFile(filepath.Join(p.SkillsDir, "lint", "metadata.json")), "{", `{"usageCount": 3, `, 1))
+	writeTestFile(t, filepath.Join(p.AgentsDir, "ruri", "SOUL.md"), "You are someone else.\n")
+	os.Remove(filepath.Join(p.PromptsDir, "daily.md"))
+	st := s.Status()
+	if st.Revision == "" || st.Files != 6 || len(st.Drift) != 2 ||
+		st.Drift[0] != (Drift{Path: "agents/ruri/SOUL.md", Live: filepath.Join(p.AgentsDir, "ruri", "SOUL.md"), State: "modified"}) ||
+		st.Drift[1].Path != "prompts/daily.md" || st.Drift[1].State != "missing" {
+		t.Fatalf("status = %+v", st)
+	}
+	rev, drift, err := CheckDrift(p.StateDir)
+	if err != nil || rev != st.Revision || len(drift) != 2 {
+		t.Errorf("CheckDrift = %s %v %v", rev, drift, err)
+	}
+
+	// Without selfHeal, polling leaves drift alone.
+	if res, err = s.reconcile(ctx, false, false); err != nil || res.UpToDate {
+		t.Fatalf("poll = %+v, %v", res, err)
+	}
+	if readTestFile(filepath.Join(p.AgentsDir, "ruri", "SOUL.md")) != "You are someone else.\n" {
+		t.Error("drift overwritten without selfHeal")
+	}
+
+	// A new revision is applied whole, over the drift; a skill update keeps
+	// the usage recorded here.
+	repo.commit("lint v2", map[string]string{
+		"skills/lint/metadata.json": `{"name": "lint", "command": "{skillDir}/run.sh", "approved": true, "description": "v2"}`,
+	})
+	if res, err = s.reconcile(ctx, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Changed, []string{"agents/ruri/SOUL.md", "prompts/daily.md", "skills/lint/metadata.json"}) {
+		t.Errorf("changed = %v", res.Changed)
+	}
+	if meta := readTestFile(filepath.Join(p.SkillsDir, "lint", "metadata.json")); !strings.Contains(meta, `"usageCount": 3`) || !strings.Contains(meta, "v2") {
+		t.Errorf("metadata = %s", meta)
+	}
+	if st := s.Status(); st.Subject != "lint v2" || len(st.Drift) != 0 {
+		t.Errorf("status after update = %+v", st)
+	}
+}
+
+func TestGitOpsRejectsInvalidCommit(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("initial", map[string]string{
+		"config.json":           testConfig,
+		"workflows/review.json": testWorkflowDoc,
+	})
+	s, p, _ := newTestService(t, config.GitOpsConfig{Repo: repo.dir})
+	ctx := context.Background()
+	if _, err := s.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	applied := s.Status().Revision
+
+	repo.commit("break things", map[string]string{
+		"config.json":           `{"configVersion": 3, "listenAddr": 8991, "agents": {"ruri": {}}}`,
+		"workflows/review.json": `{"name": "review", "steps": [{"id": "a", "agent": "ghost", "prompt": "x"}]}`,
+		"prompts/new.md":        "new\n",
+	})
+	_, err := s.reconcile(ctx, false, false)
+	if err == nil || !strings.Contains(err.Error(), "config.json:1") || !strings.Contains(err.Error(), "workflows/review.json:1") {
+		t.Fatalf("err = %v", err)
+	}
+	if readTestFile(p.ConfigPath) != testConfig || readTestFile(filepath.Join(p.WorkflowsDir, "review.json")) != testWorkflowDoc {
+		t.Error("invalid commit partly applied")
+	}
+	if _, err := os.Stat(filepath.Join(p.PromptsDir, "new.md")); err == nil {
+		t.Error("file of an invalid commit applied")
+	}
+	if st := s.Status(); st.Revision != applied || !strings.Contains(st.Error, "rejected") {
+		t.Errorf("status = %+v", st)
+	}
+
+	// The fix is applied and the error cleared.
+	repo.commit("fix", map[string]string{"config.json": testConfig, "workflows/review.json": testWorkflowDoc})
+	res, err := s.reconcile(ctx, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Changed, []string{"prompts/new.md"}) {
+		t.Errorf("changed = %v", res.Changed)
+	}
+	if st := s.Status(); st.Error != "" || st.Subject != "fix" {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestGitOpsDeleteAndSelfHeal(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("initial", map[string]string{
+		"config.json":          testConfig,
+		"prompts/daily.md":     "Summarize the day.\n",
+		"prompts/weekly.md":    "Summarize the week.\n",
+		"skills/lint/SKILL.md": "# lint\n",
+	})
+	s, p, _ := newTestService(t, config.GitOpsConfig{Repo: repo.dir, SelfHeal: true})
+	ctx := context.Background()
+	if _, err := s.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(p.PromptsDir, "local.md"), "not managed\n")
+
+	repo.commit("drop weekly and lint", map[string]string{"prompts/weekly.md": "", "skills/lint/SKILL.md": ""})
+	res, err := s.reconcile(ctx, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Deleted, []string{"prompts/weekly.md", "skills/lint/SKILL.md"}) {
+		t.Errorf("deleted = %v", res.Deleted)
+	}
+	if _, err := os.Stat(filepath.Join(p.PromptsDir, "weekly.md")); err == nil {
+		t.Error("removed prompt still there")
+	}
+	if _, err := os.Stat(filepath.Join(p.SkillsDir, "lint")); err == nil {
+		t.Error("empty skill directory not removed")
+	}
+	if readTestFile(filepath.Join(p.PromptsDir, "local.md")) != "not managed\n" {
+		t.Error("unmanaged file touched")
+	}
+
+	// selfHeal puts edited files back on the next poll.
+	writeTestFile(t, filepath.Join(p.PromptsDir, "daily.md"), "edited\n")
+	if res, err = s.reconcile(ctx, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Changed, []string{"prompts/daily.md"}) || readTestFile(filepath.Join(p.PromptsDir, "daily.md")) != "Summarize the day.\n" {
+		t.Errorf("self-heal = %+v", res)
+	}
+
+	// The snapshot holds the edited copy.
+	saved, err := filepath.Glob(filepath.Join(res.Snapshot, "0"))
+	if err != nil || len(saved) != 1 || readTestFile(saved[0]) != "edited\n" {
+		t.Errorf("snapshot = %v", saved)
+	}
+}
+
+func TestGitOpsConfigFormat(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("yaml", map[string]string{"config.yaml": "agents: {}\n"})
+	s, _, _ := newTestService(t, config.GitOpsConfig{Repo: repo.dir})
+	if _, err := s.Sync(context.Background(), false); err == nil || !strings.Contains(err.Error(), "same format") {
+		t.Errorf("err = %v", err)
+	}
+
+	// An unmigrated config would be rewritten by the daemon on load.
+	repo.commit("old", map[string]string{"config.yaml": "", "config.json": `{"agents": {}}`})
+	if _, err := s.Sync(context.Background(), false); err == nil || !strings.Contains(err.Error(), "config migrate") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package gitops
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitTimeout bounds one git command; a clone of a large repo over a slow
+// link is the longest.
+const gitTimeout = 5 * time.Minute
+
+// git runs a git command in dir and returns its trimmed output. The access
+// token, if any, is passed as an HTTP header through the environment, so it
+// does not show in the process list or end up in the checkout's config.
+func (s *Service) git(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if s.cfg.Token != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + s.cfg.Token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if s.cfg.Token != "" {
+			msg = strings.ReplaceAll(msg, s.cfg.Token, "***")
+		}
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkoutDir is the clone of the repo.
+func (s *Service) checkoutDir() string { return filepath.Join(s.paths.StateDir, "checkout") }
+
+// fetch brings the checkout to the tip of the branch, cloning the repo the
+// first time or after gitops.repo changed, and returns the commit and its
+// subject line. Only the tip is fetched, without history.
+func (s *Service) fetch(ctx context.Context) (rev, subject string, err error) {
+	dir := s.checkoutDir()
+	branch := s.cfg.BranchOrDefault()
+	origin, err := s.git(ctx, dir, "remote", "get-url", "origin")
+	if err != nil || origin != s.cfg.Repo {
+		if err := os.RemoveAll(dir); err != nil {
+			return "", "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+			return "", "", err
+		}
+		if _, err := s.git(ctx, filepath.Dir(dir), "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", branch, "--", s.cfg.Repo, dir); err != nil {
+			return "", "", err
+		}
+	} else {
+		if _, err := s.git(ctx, dir, "fetch", "--quiet", "--depth", "1", "origin", branch); err != nil {
+			return "", "", err
+		}
+		if _, err := s.git(ctx, dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+			return "", "", err
+		}
+		// Files left behind by an interrupted checkout would be applied.
+		if _, err := s.git(ctx, dir, "clean", "--quiet", "-ffdx"); err != nil {
+			return "", "", err
+		}
+	}
+	out, err := s.git(ctx, dir, "log", "-1", "--format=%H%n%s")
+	if err != nil {
+		return "", "", err
+	}
+	rev, subject, _ = strings.Cut(out, "\n")
+	return rev, subject, nil
+}
+
+// root is the directory of the checkout holding the files.
+func (s *Service) root() string {
+	return filepath.Join(s.checkoutDir(), filepath.FromSlash(s.cfg.Path))
+}
@@ -0,0 +1,714 @@
+package gitops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/cfgfile"
+	"tetora/internal/config"
+	"tetora/internal/log"
+	"tetora/internal/migrate"
+	"tetora/internal/validate"
+	"tetora/internal/version"
+)
+
+// maxFile bounds the files that are applied; larger ones are left out.
+const maxFile = 4 << 20
+
+// keepSnapshots is how many snapshots of replaced files are kept.
+const keepSnapshots = 10
+
+// tmpSuffix marks a file being written, before it is renamed into place.
+const tmpSuffix = ".gitops-tmp"
+
+// skillDirRef stands for the skill's own directory in the command of a
+// skill's metadata.json, as in mirrored skills.
+const skillDirRef = "{skillDir}/"
+
+// usageKeys are skill metadata.json keys kept by this instance: they are
+// carried over when a skill is replaced and do not count as drift.
+var usageKeys = []string{"usageCount", "lastUsedAt"}
+
+// entry is a file of the repo and where it is applied.
+type entry struct {
+	Rel  string // path under gitops.path, slash-separated
+	Live string
+	Kind string // "config", "agents", "prompts", "skills" or "workflows"
+	Data []byte
+	Mode os.FileMode
+}
+
+// desired reads the files to apply from the repo directory root. Anything
+// not in the layout is ignored, as are hidden files and symlinks.
+func (s *Service) desired(root string) ([]entry, error) {
+	var out []entry
+	add := func(rel, live, kind string) error {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if fi.Size() > maxFile {
+			log.Warn("gitops: file too large, skipped", "file", rel, "size", fi.Size())
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0o644)
+		if fi.Mode()&0o111 != 0 {
+			mode = 0o755
+		}
+		if kind == "skills" && path.Base(rel) == "metadata.json" {
+			data = expandSkillDir(data, filepath.Dir(live))
+		}
+		out = append(out, entry{Rel: rel, Live: live, Kind: kind, Data: data, Mode: mode})
+		return nil
+	}
+
+	cfgPath := s.paths.ConfigPath
+	cfgName := filepath.Base(cfgPath)
+	cfgStem := strings.TrimSuffix(cfgName, filepath.Ext(cfgName))
+	top, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range top {
+		name := e.Name()
+		if e.IsDir() || hidden(name) || !isConfigExt(name) || strings.TrimSuffix(name, filepath.Ext(name)) != cfgStem {
+			continue
+		}
+		if name != cfgName {
+			return nil, fmt.Errorf("%s: the config file is %s; keep it in the same format", name, cfgName)
+		}
+		if err := add(name, cfgPath, "config"); err != nil {
+			return nil, err
+		}
+	}
+
+	walk := func(dir, kind string, live func(rel string, depth int) string) error {
+		base := filepath.Join(root, dir)
+		if fi, err := os.Stat(base); err != nil || !fi.IsDir() {
+			return nil
+		}
+		return filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == base {
+				return nil
+			}
+			if hidden(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			rel, _ := filepath.Rel(base, p)
+			rel = filepath.ToSlash(rel)
+			dst := live(rel, strings.Count(rel, "/"))
+			if dst == "" {
+				return nil
+			}
+			return add(dir+"/"+rel, dst, kind)
+		})
+	}
+	overlays := filepath.Join(filepath.Dir(cfgPath), config.OverlayDir)
+	if err := walk(config.OverlayDir, "config", func(rel string, depth int) string {
+		if depth > 1 || !isConfigExt(rel) {
+			return ""
+		}
+		return filepath.Join(overlays, filepath.FromSlash(rel))
+	}); err != nil {
+		return nil, err
+	}
+	if err := walk("agents", "agents", func(rel string, depth int) string {
+		if depth != 1 || !validName(path.Dir(rel)) {
+			return ""
+		}
+		return filepath.Join(s.paths.AgentsDir, filepath.FromSlash(rel))
+	}); err != nil {
+		return nil, err
+	}
+	if err := walk("prompts", "prompts", func(rel string, depth int) string {
+		if depth != 0 || path.Ext(rel) != ".md" {
+			return ""
+		}
+		return filepath.Join(s.paths.PromptsDir, rel)
+	}); err != nil {
+		return nil, err
+	}
+	if err := walk("skills", "skills", func(rel string, depth int) string {
+		if depth == 0 || !validName(strings.SplitN(rel, "/", 2)[0]) {
+			return ""
+		}
+		return filepath.Join(s.paths.SkillsDir, filepath.FromSlash(rel))
+	}); err != nil {
+		return nil, err
+	}
+	if err := walk("workflows", "workflows", func(rel string, depth int) string {
+		if depth != 0 || path.Ext(rel) != ".json" {
+			return ""
+		}
+		return filepath.Join(s.paths.WorkflowsDir, rel)
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rel < out[j].Rel })
+	return out, nil
+}
+
+func hidden(name string) bool { return strings.HasPrefix(name, ".") }
+
+func isConfigExt(name string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range cfgfile.Extensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// validName reports whether name can be an agent or skill directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= 128 && !hidden(name)
+}
+
+// expandSkillDir resolves {skillDir}/ in the command of a skill's
+// metadata.json to the skill's directory on this instance.
+func expandSkillDir(data []byte, dir string) []byte {
+	var meta map[string]any
+	if json.Unmarshal(data, &meta) != nil {
+		return data
+	}
+	cmd, ok := meta["command"].(string)
+	if !ok || !strings.HasPrefix(cmd, skillDirRef) {
+		return data
+	}
+	meta["command"] = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(cmd, skillDirRef)))
+	out, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return data
+	}
+	return append(out, '\n')
+}
+
+// isSkillMeta reports whether live is the metadata.json of a skill.
+func (s *Service) isSkillMeta(kind, live string) bool {
+	return kind == "skills" && filepath.Base(live) == "metadata.json"
+}
+
+// hashFile hashes the content of a file as it is compared: the usage a
+// skill's metadata.json records is left out.
+func (s *Service) hashFile(kind, live string, data []byte) string {
+	if s.isSkillMeta(kind, live) {
+		var meta map[string]any
+		if json.Unmarshal(data, &meta) == nil {
+			for _, k := range usageKeys {
+				delete(meta, k)
+			}
+			if b, err := json.Marshal(meta); err == nil {
+				data = b
+			}
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// keepUsage copies the usage keys of the current metadata.json of a skill
+// into the new one.
+func keepUsage(data, old []byte) []byte {
+	var meta, prev map[string]any
+	if json.Unmarshal(data, &meta) != nil || json.Unmarshal(old, &prev) != nil {
+		return data
+	}
+	changed := false
+	for _, k := range usageKeys {
+		if v, ok := prev[k]; ok {
+			meta[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	out, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return data
+	}
+	return append(out, '\n')
+}
+
+// check validates the desired files the way the daemon would load them and
+// returns every problem found in one error. Souls and workflows are checked
+// against the agents of the repo's config, or of the live config when the
+// repo has none.
+func (s *Service) check(root string, files []entry) error {
+	var env validate.Env
+	if s.deps.Env != nil {
+		env = s.deps.Env()
+	}
+	var problems []string
+	var agents map[string]config.AgentConfig
+	var cfgFile string
+	skills := map[string]bool{}
+	for _, e := range files {
+		switch {
+		case e.Kind == "config":
+			for _, i := range config.LintDoc(e.Data, cfgfile.FormatOf(e.Rel)) {
+				if i.Level == "error" {
+					i.File = e.Rel
+					problems = append(problems, i.String())
+				}
+			}
+			if e.Live == s.paths.ConfigPath {
+				cfgFile = e.Rel
+				// The daemon migrates an older config in place when it loads
+				// it, which would leave it drifted from the repo for good.
+				if doc, err := cfgfile.Parse(e.Data, cfgfile.FormatOf(e.Rel)); err == nil {
+					var raw map[string]json.RawMessage
+					if json.Unmarshal(doc.JSON(), &raw) == nil {
+						if v := migrate.GetConfigVersion(raw); v < migrate.CurrentConfigVersion {
+							problems = append(problems, fmt.Sprintf("%s: configVersion %d is older than %d; run 'tetora config migrate' on it and commit the result",
+								e.Rel, v, migrate.CurrentConfigVersion))
+						}
+					}
+				}
+			}
+		case e.Kind == "skills":
+			skills[strings.SplitN(strings.TrimPrefix(e.Rel, "skills/"), "/", 2)[0]] = true
+		}
+	}
+	if cfgFile != "" && len(problems) == 0 {
+		var err error
+		if agents, err = repoAgents(filepath.Join(root, cfgFile)); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", cfgFile, err))
+		}
+	}
+	if agents != nil {
+		env.Agents = make(map[string]bool, len(agents))
+		for name := range agents {
+			env.Agents[name] = true
+		}
+	}
+	if env.Skills != nil {
+		for name := range skills {
+			env.Skills[name] = true
+		}
+	}
+
+	diag := func(rel string, r *validate.Result) {
+		for _, d := range r.Diagnostics {
+			if d.Severity != validate.Error {
+				continue
+			}
+			loc := rel
+			if d.Line > 0 {
+				loc += ":" + strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column)
+			}
+			problems = append(problems, loc+": "+d.Message)
+		}
+	}
+	for _, e := range files {
+		var kind, agent string
+		switch {
+		case e.Kind == "agents":
+			agent = path.Dir(strings.TrimPrefix(e.Rel, "agents/"))
+			if !env.Agents[agent] || path.Base(e.Rel) != soulFile(agents, agent) {
+				continue
+			}
+			kind = "soul"
+		case e.Kind == "workflows":
+			kind = "workflow"
+		case e.Kind == "skills" && path.Base(e.Rel) == "SKILL.md":
+			kind = "skill"
+		case s.isSkillMeta(e.Kind, e.Live):
+			if !json.Valid(e.Data) {
+				problems = append(problems, e.Rel+": not valid JSON")
+			}
+			continue
+		default:
+			continue
+		}
+		r, err := validate.Check(kind, string(e.Data), agent, env)
+		if err != nil {
+			problems = append(problems, e.Rel+": "+err.Error())
+			continue
+		}
+		diag(e.Rel, r)
+	}
+	if len(problems) > 0 {
+		return checkError(problems)
+	}
+	return nil
+}
+
+func checkError(problems []string) error {
+	return fmt.Errorf("%d problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+}
+
+// repoAgents returns the agents of the config file at p in the checkout,
+// with the repo's overlays for the active profile merged in.
+func repoAgents(p string) (map[string]config.AgentConfig, error) {
+	profile := config.ActiveProfile()
+	if profile != "" && !slices.Contains(config.Profiles(p), profile) {
+		profile = ""
+	}
+	data, _, err := config.ReadEffective(p, profile, true)
+	if err != nil {
+		return nil, err
+	}
+	var c config.Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Agents == nil {
+		c.Agents = map[string]config.AgentConfig{}
+	}
+	return c.Agents, nil
+}
+
+// soulFile is the name of an agent's soul file in its directory.
+func soulFile(agents map[string]config.AgentConfig, agent string) string {
+	if a, ok := agents[agent]; ok && a.SoulFile != "" && !strings.ContainsAny(a.SoulFile, `/\`) {
+		return a.SoulFile
+	}
+	return "SOUL.md"
+}
+
+// manifest records what was applied, so files removed from the repo can be
+// deleted and live files compared for drift.
+type manifest struct {
+	Revision  string         `json:"revision"`
+	Subject   string         `json:"subject,omitempty"`
+	AppliedAt string         `json:"appliedAt"`
+	Files     []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Rel  string `json:"rel"`
+	Live string `json:"live"`
+	Kind string `json:"kind"`
+	Hash string `json:"hash"`
+}
+
+const manifestName = "applied.json"
+
+// loadManifest reads the manifest in stateDir; nil if nothing was applied.
+func loadManifest(stateDir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestName, err)
+	}
+	return &m, nil
+}
+
+func saveManifest(stateDir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(stateDir, manifestName), append(data, '\n'), 0o644)
+}
+
+// Drift is a live file that no longer matches what was applied.
+type Drift struct {
+	Path  string `json:"path"`  // in the repo
+	Live  string `json:"live"`  // on this instance
+	State string `json:"state"` // "modified" or "missing"
+}
+
+// drift compares the live files with the manifest.
+func (s *Service) drift(m *manifest) []Drift {
+	if m == nil {
+		return nil
+	}
+	var out []Drift
+	for _, f := range m.Files {
+		data, err := os.ReadFile(f.Live)
+		switch {
+		case err != nil:
+			out = append(out, Drift{Path: f.Rel, Live: f.Live, State: "missing"})
+		case s.hashFile(f.Kind, f.Live, data) != f.Hash:
+			out = append(out, Drift{Path: f.Rel, Live: f.Live, State: "modified"})
+		}
+	}
+	return out
+}
+
+// CheckDrift compares the live files with what the GitOps state in
+// stateDir last applied, without the daemon or the network. It returns the
+// applied revision, "" if nothing was applied.
+func CheckDrift(stateDir string) (string, []Drift, error) {
+	m, err := loadManifest(stateDir)
+	if err != nil || m == nil {
+		return "", nil, err
+	}
+	return m.Revision, (&Service{}).drift(m), nil
+}
+
+// plan returns the desired files that differ from the live ones and the
+// files applied before that are no longer in the repo.
+func (s *Service) plan(files []entry, prev *manifest) (changed []entry, deleted []manifestFile) {
+	want := make(map[string]bool, len(files))
+	for _, e := range files {
+		want[e.Live] = true
+		cur, err := os.ReadFile(e.Live)
+		if err != nil || s.hashFile(e.Kind, e.Live, cur) != s.hashFile(e.Kind, e.Live, e.Data) {
+			changed = append(changed, e)
+			continue
+		}
+		if fi, err := os.Stat(e.Live); err == nil && fi.Mode().Perm()&0o111 != e.Mode&0o111 {
+			changed = append(changed, e)
+		}
+	}
+	if prev != nil {
+		for _, f := range prev.Files {
+			if !want[f.Live] {
+				if _, err := os.Lstat(f.Live); err == nil {
+					deleted = append(deleted, f)
+				}
+			}
+		}
+	}
+	return changed, deleted
+}
+
+// snapshotFile is a live file saved before it was replaced or deleted.
+type snapshotFile struct {
+	Live  string      `json:"live"`
+	Saved string      `json:"saved,omitempty"` // in the snapshot directory; "" if the file did not exist
+	Mode  os.FileMode `json:"mode,omitempty"`
+}
+
+// snapshot saves the live files about to be replaced or deleted in a new
+// directory under snapshots/ and returns it. Old snapshots beyond
+// keepSnapshots are removed.
+func (s *Service) snapshot(rev string, lives []string) (string, []snapshotFile, error) {
+	root := filepath.Join(s.paths.StateDir, "snapshots")
+	dir := filepath.Join(root, time.Now().UTC().Format("20060102T150405Z")+"-"+short(rev))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", nil, err
+	}
+	var saved []snapshotFile
+	for i, live := range lives {
+		sf := snapshotFile{Live: live}
+		data, err := os.ReadFile(live)
+		if err == nil {
+			fi, _ := os.Stat(live)
+			sf.Saved, sf.Mode = strconv.Itoa(i), fi.Mode().Perm()
+			if err := os.WriteFile(filepath.Join(dir, sf.Saved), data, 0o600); err != nil {
+				return "", nil, err
+			}
+		} else if !os.IsNotExist(err) {
+			return "", nil, err
+		}
+		saved = append(saved, sf)
+	}
+	data, _ := json.MarshalIndent(saved, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "snapshot.json"), data, 0o600); err != nil {
+		return "", nil, err
+	}
+	if old, err := os.ReadDir(root); err == nil && len(old) > keepSnapshots {
+		for _, e := range old[:len(old)-keepSnapshots] {
+			os.RemoveAll(filepath.Join(root, e.Name()))
+		}
+	}
+	return dir, saved, nil
+}
+
+// restore puts the files saved in a snapshot back.
+func restore(dir string, saved []snapshotFile) error {
+	var errs []error
+	for _, sf := range saved {
+		if sf.Saved == "" {
+			if err := os.Remove(sf.Live); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, sf.Saved))
+		if err == nil {
+			err = writeAtomic(sf.Live, data, sf.Mode)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// apply writes the changed files and deletes the removed ones. Every
+// changed file is written next to its target first and only then renamed
+// into place, so a failed write changes nothing; if a rename fails, the
+// files already replaced are restored from the snapshot. It returns the
+// snapshot directory, "" if nothing changed.
+func (s *Service) apply(rev, reason string, changed []entry, deleted []manifestFile) (string, error) {
+	if len(changed) == 0 && len(deleted) == 0 {
+		return "", nil
+	}
+	var lives []string
+	for _, e := range changed {
+		lives = append(lives, e.Live)
+	}
+	for _, f := range deleted {
+		lives = append(lives, f.Live)
+	}
+	snap, saved, err := s.snapshot(rev, lives)
+	if err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+
+	var tmps []string
+	cleanup := func() {
+		for _, t := range tmps {
+			os.Remove(t)
+		}
+	}
+	for _, e := range changed {
+		data := e.Data
+		if s.isSkillMeta(e.Kind, e.Live) {
+			if old, err := os.ReadFile(e.Live); err == nil {
+				data = keepUsage(data, old)
+			}
+		}
+		tmp := e.Live + tmpSuffix
+		err := os.MkdirAll(filepath.Dir(e.Live), 0o755)
+		if err == nil {
+			err = os.WriteFile(tmp, data, e.Mode)
+		}
+		if err == nil {
+			tmps = append(tmps, tmp)
+			err = os.Chmod(tmp, e.Mode)
+		}
+		if err != nil {
+			cleanup()
+			return "", fmt.Errorf("write %s: %w", e.Rel, err)
+		}
+	}
+	for i, e := range changed {
+		if err := os.Rename(tmps[i], e.Live); err != nil {
+			cleanup()
+			if rerr := restore(snap, saved); rerr != nil {
+				log.Error("gitops: restore after failed apply", "snapshot", snap, "error", rerr)
+			}
+			return "", fmt.Errorf("replace %s: %w", e.Rel, err)
+		}
+	}
+	for _, f := range deleted {
+		if err := os.Remove(f.Live); err != nil && !os.IsNotExist(err) {
+			log.Warn("gitops: delete", "file", f.Live, "error", err)
+			continue
+		}
+		s.prune(filepath.Dir(f.Live))
+	}
+
+	if db := s.paths.HistoryDB; db != "" {
+		for _, e := range changed {
+			var err error
+			switch {
+			case e.Live == s.paths.ConfigPath:
+				err = version.SnapshotConfig(db, e.Live, "gitops", reason)
+			case e.Kind == "prompts":
+				err = version.SnapshotPrompt(db, strings.TrimSuffix(path.Base(e.Rel), ".md"), string(e.Data), "gitops", reason)
+			case e.Kind == "workflows":
+				err = version.SnapshotWorkflow(db, strings.TrimSuffix(path.Base(e.Rel), ".json"), string(e.Data), "gitops", reason)
+			}
+			if err != nil {
+				log.Warn("gitops: version snapshot", "file", e.Rel, "error", err)
+			}
+		}
+	}
+	return snap, nil
+}
+
+// prune removes dir and its parents while they are empty, up to the
+// directories the files are applied to.
+func (s *Service) prune(dir string) {
+	stop := []string{s.paths.AgentsDir, s.paths.PromptsDir, s.paths.SkillsDir, s.paths.WorkflowsDir,
+		filepath.Dir(s.paths.ConfigPath), filepath.Join(filepath.Dir(s.paths.ConfigPath), config.OverlayDir)}
+	for !slices.Contains(stop, dir) && dir != filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// writeAtomic writes data to p through a temporary file and a rename.
+func writeAtomic(p string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp := p + tmpSuffix
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// manifestOf records the desired files as applied at rev.
+func (s *Service) manifestOf(rev, subject string, files []entry) *manifest {
+	m := &manifest{Revision: rev, Subject: subject, AppliedAt: time.Now().UTC().Format(time.RFC3339), Files: []manifestFile{}}
+	for _, e := range files {
+		m.Files = append(m.Files, manifestFile{Rel: e.Rel, Live: e.Live, Kind: e.Kind, Hash: s.hashFile(e.Kind, e.Live, e.Data)})
+	}
+	return m
+}
+
+// short abbreviates a commit hash.
+func short(rev string) string {
+	if len(rev) > 7 {
+		return rev[:7]
+	}
+	return rev
+}
+
+// kindsOf lists the kinds of the changed and deleted files.
+func kindsOf(changed []entry, deleted []manifestFile) []string {
+	var kinds []string
+	for _, e := range changed {
+		if !slices.Contains(kinds, e.Kind) {
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	for _, f := range deleted {
+		if !slices.Contains(kinds, f.Kind) {
+			kinds = append(kinds, f.Kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
@@ -0,0 +1,427 @@
+// Package handover lets a person talking to Tetora ask for a human.
+//
+// When an inbound message matches a trigger phrase the conversation is
+// flagged, the owner is notified with context, and agents stand down for that
+// conversation: further messages are forwarded to the owner instead of being
+// dispatched. The owner answers through Tetora (Telegram, HTTP or CLI) and
+// releases the conversation when done, handing it back to the agents.
+package handover
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+	"tetora/internal/trace"
+)
+
+// Handover states.
+const (
+	StatusOpen     = "open"
+	StatusReleased = "released"
+)
+
+// DefaultTriggers are used when handover.triggers is not configured.
+var DefaultTriggers = []string{
+	"talk to the owner",
+	"talk to a human",
+	"speak to a human",
+	"speak to someone",
+	"real person",
+}
+
+// DefaultAck is the reply sent to the user when a handover opens.
+const DefaultAck = "I've asked the owner to take over this conversation. They'll reply here as soon as they can."
+
+// Handover is one flagged conversation.
+type Handover struct {
+	ID          string `json:"id"`
+	Platform    string `json:"platform"` // "discord", "webhook", ...
+	ChatID      string `json:"chatId"`   // channel / chat / webhook name
+	UserID      string `json:"userId,omitempty"`
+	UserName    string `json:"userName,omitempty"`
+	Reason      string `json:"reason"` // the message that triggered it
+	Status      string `json:"status"`
+	RequestedAt string `json:"requestedAt"`
+	ReleasedAt  string `json:"releasedAt,omitempty"`
+	ReleasedBy  string `json:"releasedBy,omitempty"`
+}
+
+// Message is one line of a handover transcript.
+type Message struct {
+	Direction string `json:"direction"` // "in" (from the user) or "out" (from the owner)
+	Author    string `json:"author,omitempty"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Inbound is a message arriving on a channel, before any agent sees it.
+type Inbound struct {
+	Platform string
+	ChatID   string
+	UserID   string
+	UserName string
+	Text     string
+	// Context returns recent conversation history for the owner's
+	// notification. It is only called when a handover opens. Optional.
+	Context func() string
+}
+
+// Sender delivers an owner reply to a conversation on one platform.
+type Sender func(chatID, text string) error
+
+// Desk tracks open handovers and routes messages between users and the owner.
+type Desk struct {
+	dbPath   string
+	triggers []string
+	ack      string
+	notify   func(text string)
+
+	mu      sync.RWMutex
+	open    map[string]Handover // key(platform, chatID) -> open handover
+	senders map[string]Sender
+}
+
+// NewDesk creates a desk and loads handovers left open by a previous run.
+// notify delivers text to the owner.
+func NewDesk(dbPath string, cfg config.HandoverConfig, notify func(text string)) *Desk {
+	d := &Desk{
+		dbPath:   dbPath,
+		triggers: cfg.Triggers,
+		ack:      cfg.Ack,
+		notify:   notify,
+		open:     make(map[string]Handover),
+		senders:  make(map[string]Sender),
+	}
+	if len(d.triggers) == 0 {
+		d.triggers = DefaultTriggers
+	}
+	if d.ack == "" {
+		d.ack = DefaultAck
+	}
+	if open, err := List(dbPath, StatusOpen); err != nil {
+		log.Warn("handover: load open handovers failed", "error", err)
+	} else {
+		for _, h := range open {
+			d.open[key(h.Platform, h.ChatID)] = h
+		}
+	}
+	return d
+}
+
+func key(platform, chatID string) string {
+	return platform + ":" + chatID
+}
+
+// RegisterSender sets how owner replies reach a platform.
+func (d *Desk) RegisterSender(platform string, s Sender) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.senders[platform] = s
+}
+
+// Active returns the open handover for a conversation, if any.
+func (d *Desk) Active(platform, chatID string) (Handover, bool) {
+	if d == nil {
+		return Handover{}, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	h, ok := d.open[key(platform, chatID)]
+	return h, ok
+}
+
+// Intercept is called for every inbound message before dispatch. When it
+// returns handled=true the message must not reach an agent; reply, if not
+// empty, should be sent back to the user.
+func (d *Desk) Intercept(in Inbound) (reply string, handled bool) {
+	if d == nil {
+		return "", false
+	}
+
+	if h, ok := d.Active(in.Platform, in.ChatID); ok {
+		d.record(h.ID, Message{Direction: "in", Author: in.UserName, Text: in.Text})
+		d.notifyOwner(fmt.Sprintf("[handover %s] %s: %s\nReply: /reply %s <text>",
+			h.ID, displayName(in), in.Text, h.ID))
+		return "", true
+	}
+
+	trigger := MatchTrigger(in.Text, d.triggers)
+	if trigger == "" {
+		return "", false
+	}
+
+	h := Handover{
+		ID:          trace.NewID("ho"),
+		Platform:    in.Platform,
+		ChatID:      in.ChatID,
+		UserID:      in.UserID,
+		UserName:    in.UserName,
+		Reason:      in.Text,
+		Status:      StatusOpen,
+		RequestedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := insert(d.dbPath, h); err != nil {
+		log.Warn("handover: record failed", "id", h.ID, "error", err)
+	}
+	d.mu.Lock()
+	d.open[key(h.Platform, h.ChatID)] = h
+	d.mu.Unlock()
+	d.record(h.ID, Message{Direction: "in", Author: in.UserName, Text: in.Text})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🙋 %s asked for a human on %s (%s).\n", displayName(in), in.Platform, in.ChatID)
+	fmt.Fprintf(&b, "Message: %s\n", in.Text)
+	if in.Context != nil {
+		if hist := in.Context(); hist != "" {
+			fmt.Fprintf(&b, "\nRecent conversation:\n%s\n", hist)
+		}
+	}
+	fmt.Fprintf(&b, "\nAgents are paused there. Reply: /reply %s <text> · Hand back: /release %s", h.ID, h.ID)
+	d.notifyOwner(b.String())
+
+	log.Info("handover opened", "id", h.ID, "platform", h.Platform, "chat", h.ChatID, "trigger", trigger)
+	return d.ack, true
+}
+
+// Reply sends an owner message into a handed-over conversation. Conversations
+// on platforms without a sender (e.g. webhooks) keep the reply in the
+// transcript only; delivered reports whether it reached the user.
+func (d *Desk) Reply(id, author, text string) (delivered bool, err error) {
+	h, err := d.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if h.Status != StatusOpen {
+		return false, fmt.Errorf("handover %s is %s", id, h.Status)
+	}
+	d.mu.RLock()
+	send := d.senders[h.Platform]
+	d.mu.RUnlock()
+	if send != nil {
+		if err := send(h.ChatID, text); err != nil {
+			return false, fmt.Errorf("send to %s: %w", h.Platform, err)
+		}
+		delivered = true
+	}
+	d.record(h.ID, Message{Direction: "out", Author: author, Text: text})
+	return delivered, nil
+}
+
+// Release hands a conversation back to the agents.
+func (d *Desk) Release(id, by string) (Handover, error) {
+	h, err := d.Get(id)
+	if err != nil {
+		return Handover{}, err
+	}
+	if h.Status != StatusOpen {
+		return h, fmt.Errorf("handover %s is already %s", id, h.Status)
+	}
+	h.Status = StatusReleased
+	h.ReleasedAt = time.Now().UTC().Format(time.RFC3339)
+	h.ReleasedBy = by
+	if err := db.ExecArgs(d.dbPath,
+		`UPDATE handovers SET status = ?, released_at = ?, released_by = ? WHERE id = ?`,
+		h.Status, h.ReleasedAt, h.ReleasedBy, h.ID); err != nil {
+		log.Warn("handover: release update failed", "id", h.ID, "error", err)
+	}
+	d.mu.Lock()
+	delete(d.open, key(h.Platform, h.ChatID))
+	send := d.senders[h.Platform]
+	d.mu.Unlock()
+	if send != nil {
+		send(h.ChatID, "You're back with the assistant. Thanks for your patience!")
+	}
+	log.Info("handover released", "id", h.ID, "by", by)
+	return h, nil
+}
+
+// List returns open handovers first, then recent released ones. status
+// filters when non-empty.
+func (d *Desk) List(status string) ([]Handover, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return List(d.dbPath, status)
+}
+
+// Transcript returns the messages exchanged during a handover.
+func (d *Desk) Transcript(id string) ([]Message, error) {
+	return Transcript(d.dbPath, id)
+}
+
+// Get looks up a handover by ID: open ones from memory, others from the DB.
+func (d *Desk) Get(id string) (Handover, error) {
+	d.mu.RLock()
+	for _, h := range d.open {
+		if h.ID == id {
+			d.mu.RUnlock()
+			return h, nil
+		}
+	}
+	d.mu.RUnlock()
+	rows, err := db.QueryArgs(d.dbPath, `SELECT * FROM handovers WHERE id = ?`, id)
+	if err != nil {
+		return Handover{}, err
+	}
+	if len(rows) == 0 {
+		return Handover{}, fmt.Errorf("handover %s not found", id)
+	}
+	return fromRow(rows[0]), nil
+}
+
+func (d *Desk) record(id string, m Message) {
+	if err := insertMessage(d.dbPath, id, m); err != nil {
+		log.Warn("handover: record message failed", "id", id, "error", err)
+	}
+}
+
+func (d *Desk) notifyOwner(text string) {
+	if d.notify != nil {
+		d.notify(text)
+	}
+}
+
+func displayName(in Inbound) string {
+	if in.UserName != "" {
+		return in.UserName
+	}
+	if in.UserID != "" {
+		return in.UserID
+	}
+	return "Someone"
+}
+
+// MatchTrigger returns the first trigger phrase contained in text
+// (case-insensitive), or "".
+func MatchTrigger(text string, triggers []string) string {
+	lower := strings.ToLower(text)
+	for _, t := range triggers {
+		if t != "" && strings.Contains(lower, strings.ToLower(t)) {
+			return t
+		}
+	}
+	return ""
+}
+
+// --- Storage ---
+
+// InitDB creates the handovers and handover_messages tables.
+func InitDB(dbPath string) error {
+	if dbPath == "" {
+		return nil
+	}
+	sql := `CREATE TABLE IF NOT EXISTS handovers (
+  id TEXT PRIMARY KEY,
+  platform TEXT NOT NULL,
+  chat_id TEXT NOT NULL,
+  user_id TEXT DEFAULT '',
+  user_name TEXT DEFAULT '',
+  reason TEXT DEFAULT '',
+  status TEXT NOT NULL,
+  requested_at TEXT NOT NULL,
+  released_at TEXT DEFAULT '',
+  released_by TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_handovers_status ON handovers(status);
+CREATE TABLE IF NOT EXISTS handover_messages (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  handover_id TEXT NOT NULL,
+  direction TEXT NOT NULL,
+  author TEXT DEFAULT '',
+  text TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_handover_messages_handover ON handover_messages(handover_id);`
+	return db.Exec(dbPath, sql)
+}
+
+func insert(dbPath string, h Handover) error {
+	if dbPath == "" {
+		return nil
+	}
+	return db.ExecArgs(dbPath,
+		`INSERT INTO handovers (id, platform, chat_id, user_id, user_name, reason, status, requested_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.ID, h.Platform, h.ChatID, h.UserID, h.UserName, h.Reason, h.Status, h.RequestedAt)
+}
+
+func insertMessage(dbPath, id string, m Message) error {
+	if dbPath == "" {
+		return nil
+	}
+	if m.CreatedAt == "" {
+		m.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return db.ExecArgs(dbPath,
+		`INSERT INTO handover_messages (handover_id, direction, author, text, created_at) VALUES (?, ?, ?, ?, ?)`,
+		id, m.Direction, m.Author, m.Text, m.CreatedAt)
+}
+
+// List returns handovers, open ones first and then newest first. status
+// filters when non-empty.
+func List(dbPath, status string) ([]Handover, error) {
+	if dbPath == "" {
+		return nil, nil
+	}
+	query := `SELECT * FROM handovers ORDER BY requested_at DESC LIMIT 100`
+	var args []any
+	if status != "" {
+		query = `SELECT * FROM handovers WHERE status = ? ORDER BY requested_at DESC LIMIT 100`
+		args = append(args, status)
+	}
+	rows, err := db.QueryArgs(dbPath, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Handover, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, fromRow(row))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Status == StatusOpen && out[j].Status != StatusOpen
+	})
+	return out, nil
+}
+
+// Transcript returns a handover's messages in order.
+func Transcript(dbPath, id string) ([]Message, error) {
+	if dbPath == "" {
+		return nil, nil
+	}
+	rows, err := db.QueryArgs(dbPath,
+		`SELECT direction, author, text, created_at FROM handover_messages WHERE handover_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Message{
+			Direction: db.Str(row["direction"]),
+			Author:    db.Str(row["author"]),
+			Text:      db.Str(row["text"]),
+			CreatedAt: db.Str(row["created_at"]),
+		})
+	}
+	return out, nil
+}
+
+func fromRow(row map[string]any) Handover {
+	return Handover{
+		ID:          db.Str(row["id"]),
+		Platform:    db.Str(row["platform"]),
+		ChatID:      db.Str(row["chat_id"]),
+		UserID:      db.Str(row["user_id"]),
+		UserName:    db.Str(row["user_name"]),
+		Reason:      db.Str(row["reason"]),
+		Status:      db.Str(row["status"]),
+		RequestedAt: db.Str(row["requested_at"]),
+		ReleasedAt:  db.Str(row["released_at"]),
+		ReleasedBy:  db.Str(row["released_by"]),
+	}
+}
@@ -0,0 +1,121 @@
+package handover
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestMatchTrigger(t *testing.T) {
+	tests := map[string]string{
+		"Can I TALK TO A HUMAN please?": "talk to a human",
+		"I want a real person":          "real person",
+		"what are your opening hours":   "",
+	}
+	for in, want := range tests {
+		if got := MatchTrigger(in, DefaultTriggers); got != want {
+			t.Errorf("MatchTrigger(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func newTestDesk(t *testing.T) (*Desk, string, *[]string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	var notes []string
+	d := NewDesk(dbPath, config.HandoverConfig{Enabled: true}, func(s string) { notes = append(notes, s) })
+	return d, dbPath, &notes
+}
+
+func TestDeskFlow(t *testing.T) {
+	d, dbPath, notes := newTestDesk(t)
+	var sent []string
+	d.RegisterSender("discord", func(chatID, text string) error {
+		sent = append(sent, chatID+":"+text)
+		return nil
+	})
+
+	// Ordinary messages pass through to the agents.
+	if _, handled := d.Intercept(Inbound{Platform: "discord", ChatID: "c1", Text: "hello"}); handled {
+		t.Fatal("ordinary message should not be intercepted")
+	}
+
+	// A trigger opens a handover and notifies the owner with context.
+	reply, handled := d.Intercept(Inbound{
+		Platform: "discord", ChatID: "c1", UserName: "mom", Text: "let me talk to the owner",
+		Context: func() string { return "user: where is my order?" },
+	})
+	if !handled || reply != DefaultAck {
+		t.Fatalf("trigger: handled=%v reply=%q", handled, reply)
+	}
+	h, ok := d.Active("discord", "c1")
+	if !ok {
+		t.Fatal("handover should be active")
+	}
+	if len(*notes) != 1 || !strings.Contains((*notes)[0], "where is my order?") || !strings.Contains((*notes)[0], h.ID) {
+		t.Errorf("owner notification = %q", *notes)
+	}
+
+	// While open, messages are relayed to the owner instead of dispatched.
+	if reply, handled := d.Intercept(Inbound{Platform: "discord", ChatID: "c1", UserName: "mom", Text: "hello?"}); !handled || reply != "" {
+		t.Errorf("relay: handled=%v reply=%q", handled, reply)
+	}
+	if len(*notes) != 2 || !strings.Contains((*notes)[1], "mom: hello?") {
+		t.Errorf("relay notification = %q", *notes)
+	}
+	// Other chats are unaffected.
+	if _, handled := d.Intercept(Inbound{Platform: "discord", ChatID: "c2", Text: "hi"}); handled {
+		t.Error("other chat should not be intercepted")
+	}
+
+	if delivered, err := d.Reply(h.ID, "owner", "On my way"); err != nil || !delivered {
+		t.Fatalf("Reply: delivered=%v err=%v", delivered, err)
+	}
+	if len(sent) != 1 || sent[0] != "c1:On my way" {
+		t.Errorf("sent = %q", sent)
+	}
+
+	// A fresh desk (daemon restart) picks up the open handover.
+	if _, ok := NewDesk(dbPath, config.HandoverConfig{}, nil).Active("discord", "c1"); !ok {
+		t.Error("open handover should survive a restart")
+	}
+
+	if _, err := d.Release(h.ID, "test"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, ok := d.Active("discord", "c1"); ok {
+		t.Error("handover should be released")
+	}
+	if _, handled := d.Intercept(Inbound{Platform: "discord", ChatID: "c1", Text: "thanks"}); handled {
+		t.Error("released chat should go back to the agents")
+	}
+	if _, err := d.Reply(h.ID, "owner", "late"); err == nil {
+		t.Error("reply to a released handover should fail")
+	}
+
+	msgs, err := d.Transcript(h.ID)
+	if err != nil || len(msgs) != 3 {
+		t.Fatalf("Transcript: %d messages, err=%v", len(msgs), err)
+	}
+	if msgs[2].Direction != "out" || msgs[2].Text != "On my way" {
+		t.Errorf("last message = %+v", msgs[2])
+	}
+}
+
+func TestDeskReplyWithoutSender(t *testing.T) {
+	d, _, _ := newTestDesk(t)
+	d.Intercept(Inbound{Platform: "webhook", ChatID: "inbox", Text: "please, a real person"})
+	h, ok := d.Active("webhook", "inbox")
+	if !ok {
+		t.Fatal("handover should be active")
+	}
+	delivered, err := d.Reply(h.ID, "owner", "noted")
+	if err != nil || delivered {
+		t.Errorf("webhook reply: delivered=%v err=%v", delivered, err)
+	}
+}
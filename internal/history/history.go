@@ -3,7 +3,9 @@ package history
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"tetora/internal/db"
@@ -40,6 +42,7 @@ type JobRun struct {
 	TokensOut          int    `json:"tokensOut,omitempty"`
 	Agent              string `json:"agent,omitempty"`
 	ParentID           string `json:"parentId,omitempty"`
+	Node               string `json:"node,omitempty"` // daemon that ran the task (workQueue.node)
 }
 
 type CostStats struct {
@@ -145,6 +148,7 @@ CREATE INDEX IF NOT EXISTS idx_job_runs_started ON job_runs(started_at);
 		`ALTER TABLE job_runs ADD COLUMN parent_id TEXT DEFAULT '';`,
 		`ALTER TABLE job_runs ADD COLUMN provider TEXT DEFAULT '';`,
 		`ALTER TABLE job_runs ADD COLUMN prompt_manifest_file TEXT DEFAULT '';`,
+		`ALTER TABLE job_runs ADD COLUMN node TEXT DEFAULT '';`,
 	} {
 		if err := db.Exec(dbPath, col); err != nil {
 			if !strings.Contains(err.Error(), "duplicate column") {
@@ -174,6 +178,10 @@ CREATE INDEX IF NOT EXISTS idx_cron_exec_log ON cron_execution_log(job_id, sched
 		tlog.Warn("cron_execution_log init failed", "error", err)
 	}
 
+	if err := db.Exec(dbPath, toolCostsSchema); err != nil {
+		tlog.Warn("tool_costs init failed", "error", err)
+	}
+
 	return nil
 }
 
@@ -231,10 +239,21 @@ func JobRunExistsNear(dbPath, jobID string, near time.Time) bool {
 
 // --- Insert ---
 
+// RedactFn scrubs personal data from run output before it is stored; agent
+// is the agent that ran it. Nil = stored as is.
+var RedactFn func(agent, text string) string
+
+func redact(agent, text string) string {
+	if RedactFn == nil || text == "" {
+		return text
+	}
+	return RedactFn(agent, text)
+}
+
 func InsertRun(dbPath string, run JobRun) error {
 	sql := fmt.Sprintf(
-		`INSERT INTO job_runs (job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, provider, session_id, output_file, prompt_manifest_file, tokens_in, tokens_out, agent, parent_id)
-		 VALUES ('%s','%s','%s','%s','%s','%s',%d,%f,'%s','%s','%s','%s','%s','%s','%s',%d,%d,'%s','%s')`,
+		`INSERT INTO job_runs (job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, provider, session_id, output_file, prompt_manifest_file, tokens_in, tokens_out, agent, parent_id, node)
+		 VALUES ('%s','%s','%s','%s','%s','%s',%d,%f,'%s','%s','%s','%s','%s','%s','%s',%d,%d,'%s','%s','%s')`,
 		db.Escape(run.JobID),
 		db.Escape(run.Name),
 		db.Escape(run.Source),
@@ -243,8 +262,8 @@ func InsertRun(dbPath string, run JobRun) error {
 		db.Escape(run.Status),
 		run.ExitCode,
 		run.CostUSD,
-		db.Escape(run.OutputSummary),
-		db.Escape(run.Error),
+		db.Escape(redact(run.Agent, run.OutputSummary)),
+		db.Escape(redact(run.Agent, run.Error)),
 		db.Escape(run.Model),
 		db.Escape(run.Provider),
 		db.Escape(run.SessionID),
@@ -254,6 +273,7 @@ func InsertRun(dbPath string, run JobRun) error {
 		run.TokensOut,
 		db.Escape(run.Agent),
 		db.Escape(run.ParentID),
+		db.Escape(runNode(run)),
 	)
 	return db.Exec(dbPath, sql)
 }
@@ -261,8 +281,8 @@ func InsertRun(dbPath string, run JobRun) error {
 // InsertRunCtx is like InsertRun but respects context cancellation.
 func InsertRunCtx(ctx context.Context, dbPath string, run JobRun) error {
 	sql := fmt.Sprintf(
-		`INSERT INTO job_runs (job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, provider, session_id, output_file, prompt_manifest_file, tokens_in, tokens_out, agent, parent_id)
-		 VALUES ('%s','%s','%s','%s','%s','%s',%d,%f,'%s','%s','%s','%s','%s','%s','%s',%d,%d,'%s','%s')`,
+		`INSERT INTO job_runs (job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, provider, session_id, output_file, prompt_manifest_file, tokens_in, tokens_out, agent, parent_id, node)
+		 VALUES ('%s','%s','%s','%s','%s','%s',%d,%f,'%s','%s','%s','%s','%s','%s','%s',%d,%d,'%s','%s','%s')`,
 		db.Escape(run.JobID),
 		db.Escape(run.Name),
 		db.Escape(run.Source),
@@ -271,8 +291,8 @@ func InsertRunCtx(ctx context.Context, dbPath string, run JobRun) error {
 		db.Escape(run.Status),
 		run.ExitCode,
 		run.CostUSD,
-		db.Escape(run.OutputSummary),
-		db.Escape(run.Error),
+		db.Escape(redact(run.Agent, run.OutputSummary)),
+		db.Escape(redact(run.Agent, run.Error)),
 		db.Escape(run.Model),
 		db.Escape(run.Provider),
 		db.Escape(run.SessionID),
@@ -282,10 +302,26 @@ func InsertRunCtx(ctx context.Context, dbPath string, run JobRun) error {
 		run.TokensOut,
 		db.Escape(run.Agent),
 		db.Escape(run.ParentID),
+		db.Escape(runNode(run)),
 	)
 	return db.ExecContext(ctx, dbPath, sql)
 }
 
+// node is recorded on runs that do not name the node they ran on.
+var node atomic.Value // string
+
+// SetNode sets the node name recorded with this daemon's runs, so history
+// shared between the daemons of a work queue shows which one ran a task.
+func SetNode(name string) { node.Store(name) }
+
+func runNode(run JobRun) string {
+	if run.Node != "" {
+		return run.Node
+	}
+	name, _ := node.Load().(string)
+	return name
+}
+
 // --- Query ---
 
 func Query(dbPath, jobID string, limit int) ([]JobRun, error) {
@@ -299,7 +335,7 @@ func Query(dbPath, jobID string, limit int) ([]JobRun, error) {
 	}
 
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs %s ORDER BY id DESC LIMIT %d`,
 		where, limit)
 
@@ -318,7 +354,7 @@ func Query(dbPath, jobID string, limit int) ([]JobRun, error) {
 // QueryByID returns a single job run by its ID.
 func QueryByID(dbPath string, id int) (*JobRun, error) {
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs WHERE id = %d`, id)
 	rows, err := db.Query(dbPath, sql)
 	if err != nil {
@@ -357,6 +393,7 @@ func runFromRow(row map[string]any) JobRun {
 		TokensOut:     db.Int(row["tokens_out"]),
 		Agent:         db.Str(row["agent"]),
 		ParentID:      db.Str(row["parent_id"]),
+		Node:          db.Str(row["node"]),
 	}
 }
 
@@ -386,10 +423,12 @@ func QueryCostStats(dbPath string) (CostStats, error) {
 		return CostStats{}, nil
 	}
 
+	// Costs reported by MCP servers and plugins for their tool calls.
+	toolToday, toolWeek, toolMonth := QueryToolSpend(dbPath, "")
 	return CostStats{
-		Today: db.Float(rows[0]["today"]),
-		Week:  db.Float(rows[0]["week"]),
-		Month: db.Float(rows[0]["month"]),
+		Today: db.Float(rows[0]["today"]) + toolToday,
+		Week:  db.Float(rows[0]["week"]) + toolWeek,
+		Month: db.Float(rows[0]["month"]) + toolMonth,
 	}, nil
 }
 
@@ -435,7 +474,7 @@ func QueryFiltered(dbPath string, q HistoryQuery) ([]JobRun, int, error) {
 
 	// Query page.
 	dataSQL := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs %s ORDER BY id DESC LIMIT %d OFFSET %d`,
 		where, q.Limit, q.Offset)
 
@@ -507,7 +546,7 @@ func QueryLastRun(dbPath, jobID string) *JobRun {
 		return nil
 	}
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs WHERE job_id = '%s' ORDER BY id DESC LIMIT 1`,
 		db.Escape(jobID))
 
@@ -525,7 +564,7 @@ func QueryLastRunByName(dbPath, name string) *JobRun {
 		return nil
 	}
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs WHERE name = '%s' ORDER BY id DESC LIMIT 1`,
 		db.Escape(name))
 
@@ -628,7 +667,7 @@ func QueryDigestStats(dbPath, from, to string) (total, success, fail int, cost f
 	// Failed runs details.
 	if fail > 0 {
 		failSQL := fmt.Sprintf(
-			`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+			`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 			 FROM job_runs
 			 WHERE started_at >= '%s' AND started_at < '%s' AND status NOT IN ('success', '%s')
 			 ORDER BY id DESC LIMIT 10`,
@@ -672,7 +711,7 @@ func QueryRecentFails(dbPath string, q FailQuery) ([]JobRun, error) {
 
 	where := "WHERE " + strings.Join(conditions, " AND ")
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs %s ORDER BY id DESC LIMIT %d`,
 		where, limit)
 
@@ -692,9 +731,9 @@ func QueryRecentFails(dbPath string, q FailQuery) ([]JobRun, error) {
 
 // ConsecutiveFailResult holds a job and its current consecutive-fail streak.
 type ConsecutiveFailResult struct {
-	JobID  string
-	Name   string
-	Streak int
+	JobID  string `json:"jobId"`
+	Name   string `json:"name"`
+	Streak int    `json:"streak"`
 }
 
 // QueryConsecutiveFails returns jobs whose most recent runs are all non-success,
@@ -766,7 +805,7 @@ func QueryJobTrace(dbPath, jobID string, limit int) ([]JobRun, error) {
 		limit = 10
 	}
 	sql := fmt.Sprintf(
-		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id
+		`SELECT id, job_id, name, source, started_at, finished_at, status, exit_code, cost_usd, output_summary, error, model, COALESCE(provider,'') as provider, session_id, COALESCE(output_file,'') as output_file, COALESCE(prompt_manifest_file,'') as prompt_manifest_file, COALESCE(tokens_in,0) as tokens_in, COALESCE(tokens_out,0) as tokens_out, COALESCE(agent,'') as agent, COALESCE(parent_id,'') as parent_id, COALESCE(node,'') as node
 		 FROM job_runs WHERE job_id = '%s' ORDER BY id DESC LIMIT %d`,
 		db.Escape(jobID), limit)
 
@@ -784,9 +823,17 @@ func QueryJobTrace(dbPath, jobID string, limit int) ([]JobRun, error) {
 
 // --- Cleanup ---
 
-func Cleanup(dbPath string, days int) error {
+// Cleanup deletes runs older than days, except the runs with IDs in keep.
+func Cleanup(dbPath string, days int, keep ...int) error {
 	sql := fmt.Sprintf(
 		`DELETE FROM job_runs WHERE datetime(started_at) < datetime('now','-%d days')`, days)
+	if len(keep) > 0 {
+		ids := make([]string, len(keep))
+		for i, id := range keep {
+			ids[i] = strconv.Itoa(id)
+		}
+		sql += " AND id NOT IN (" + strings.Join(ids, ",") + ")"
+	}
 	return db.Exec(dbPath, sql)
 }
 
@@ -414,3 +414,44 @@ func TestQueryProviderMetrics_SkippedNotCounted(t *testing.T) {
 		t.Errorf("ErrorRate = %f, want 0.5", m.ErrorRate)
 	}
 }
+
+func TestToolCosts(t *testing.T) {
+	skipIfNoSQLite(t)
+	dbPath := mustInitDB(t)
+	run := baseRun("j1", "task", "success", 5)
+	run.CostUSD = 1
+	run.Agent = "ruri"
+	insertRun(t, dbPath, run)
+
+	old := time.Now().AddDate(0, 0, -40).Format(time.RFC3339)
+	for _, c := range []ToolCost{
+		{Server: "maps", Tool: "mcp:maps:geocode", Agent: "ruri", CostUSD: 0.5},
+		{Server: "maps", Tool: "mcp:maps:geocode", Agent: "hisui", CostUSD: 0.25},
+		{Server: "ocr", Tool: "ocr_scan", Agent: "ruri", CostUSD: 2},
+		{Server: "ocr", Tool: "ocr_scan", Agent: "ruri", CostUSD: 9, CreatedAt: old},
+	} {
+		if err := InsertToolCost(dbPath, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if today, _, month := QueryToolSpend(dbPath, "ruri"); today != 2.5 || month != 2.5 {
+		t.Errorf("ruri spend = %v, %v", today, month)
+	}
+	stats, err := QueryCostStats(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Today != 3.75 {
+		t.Errorf("cost stats today = %v, want model and tool spend", stats.Today)
+	}
+
+	rows, err := QueryToolCostBreakdown(dbPath, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Server != "ocr" || rows[0].CostUSD != 2 ||
+		rows[1].Tool != "mcp:maps:geocode" || rows[1].Calls != 2 || rows[1].CostUSD != 0.75 {
+		t.Errorf("breakdown = %+v", rows)
+	}
+}
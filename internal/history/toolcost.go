@@ -0,0 +1,109 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// --- Tool Costs ---
+
+// ToolCost is what an MCP server or plugin reported spending on one tool
+// call, such as a paid API it called downstream. It is recorded apart from
+// job_runs.cost_usd, which holds model spend only, and both count towards
+// cost stats and budgets.
+type ToolCost struct {
+	Server    string  `json:"server"` // MCP server or plugin name
+	Tool      string  `json:"tool"`
+	Agent     string  `json:"agent,omitempty"`
+	TaskID    string  `json:"taskId,omitempty"`
+	CostUSD   float64 `json:"costUsd"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// ToolCostRow is the spend of one server's tool over a period.
+type ToolCostRow struct {
+	Server  string  `json:"server"`
+	Tool    string  `json:"tool"`
+	Calls   int     `json:"calls"`
+	CostUSD float64 `json:"costUsd"`
+}
+
+const toolCostsSchema = `
+CREATE TABLE IF NOT EXISTS tool_costs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  server TEXT NOT NULL DEFAULT '',
+  tool TEXT NOT NULL,
+  agent TEXT NOT NULL DEFAULT '',
+  task_id TEXT NOT NULL DEFAULT '',
+  cost_usd REAL NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_tool_costs_created ON tool_costs(created_at);
+`
+
+// InsertToolCost records the cost of one tool call.
+func InsertToolCost(dbPath string, c ToolCost) error {
+	if c.CreatedAt == "" {
+		c.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	sql := fmt.Sprintf(
+		`INSERT INTO tool_costs (server, tool, agent, task_id, cost_usd, created_at)
+		 VALUES ('%s','%s','%s','%s',%f,'%s')`,
+		db.Escape(c.Server),
+		db.Escape(c.Tool),
+		db.Escape(c.Agent),
+		db.Escape(c.TaskID),
+		c.CostUSD,
+		db.Escape(c.CreatedAt),
+	)
+	return db.Exec(dbPath, sql)
+}
+
+// QueryToolSpend returns the tool costs recorded today, in the last 7 days
+// and in the last 30 days, for one agent or for all when agent is "". A
+// database without the tool_costs table reports nothing.
+func QueryToolSpend(dbPath, agent string) (daily, weekly, monthly float64) {
+	if dbPath == "" {
+		return
+	}
+	filter := ""
+	if agent != "" {
+		filter = fmt.Sprintf(" WHERE agent = '%s'", db.Escape(agent))
+	}
+	rows, err := db.Query(dbPath, `SELECT
+		COALESCE(SUM(CASE WHEN date(created_at,'localtime') = date('now','localtime') THEN cost_usd ELSE 0 END), 0) as today,
+		COALESCE(SUM(CASE WHEN date(created_at,'localtime') >= date('now','localtime','-7 days') THEN cost_usd ELSE 0 END), 0) as week,
+		COALESCE(SUM(CASE WHEN date(created_at,'localtime') >= date('now','localtime','-30 days') THEN cost_usd ELSE 0 END), 0) as month
+		FROM tool_costs`+filter)
+	if err != nil || len(rows) == 0 {
+		return
+	}
+	return db.Float(rows[0]["today"]), db.Float(rows[0]["week"]), db.Float(rows[0]["month"])
+}
+
+// QueryToolCostBreakdown returns tool spend per server and tool over the
+// last days days, most expensive first.
+func QueryToolCostBreakdown(dbPath string, days int) ([]ToolCostRow, error) {
+	if days <= 0 {
+		days = 30
+	}
+	rows, err := db.Query(dbPath, fmt.Sprintf(
+		`SELECT server, tool, COUNT(*) as calls, COALESCE(SUM(cost_usd),0) as cost
+		 FROM tool_costs WHERE date(created_at,'localtime') >= date('now','localtime','-%d days')
+		 GROUP BY server, tool ORDER BY cost DESC, server, tool`, days))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ToolCostRow, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, ToolCostRow{
+			Server:  db.Str(r["server"]),
+			Tool:    db.Str(r["tool"]),
+			Calls:   db.Int(r["calls"]),
+			CostUSD: db.Float(r["cost"]),
+		})
+	}
+	return out, nil
+}
@@ -1,12 +1,15 @@
 package httpapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"tetora/internal/audit"
+	"tetora/internal/trust"
 )
 
 // ArchetypeInfo describes a builtin agent archetype.
@@ -54,6 +57,15 @@ type AgentRoleDeps struct {
 	// the agent is in use by a cron job (HTTP 409); other errors yield HTTP 500.
 	DeleteAgent func(name string) (err error, conflict bool)
 
+	// PreviewPersona reports the persona an agent would use at the given time,
+	// source and channel, or (nil, false) if the agent does not exist.
+	PreviewPersona func(name string, at time.Time, source, channel string) (map[string]any, bool)
+
+	// Simulate runs an agent against a prompt in plan-only mode and returns
+	// its reviewed plan. A non-empty trustLevel reviews the plan at that
+	// level instead of the agent's own.
+	Simulate func(ctx context.Context, name, prompt, trustLevel string) (any, error)
+
 	// HistoryDB returns the history DB path for audit logging.
 	HistoryDB func() string
 }
@@ -122,7 +134,7 @@ func (h *agentRoleHandler) handleRoles(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		audit.Log(h.d.HistoryDB(), "agent.create", "http",
+		audit.LogCtx(r.Context(), h.d.HistoryDB(), "agent.create", "http",
 			fmt.Sprintf("name=%s", body.Name), clientIP(r))
 		w.WriteHeader(http.StatusCreated)
 		w.Write([]byte(`{"status":"created"}`))
@@ -142,6 +154,18 @@ func (h *agentRoleHandler) handleRole(w http.ResponseWriter, r *http.Request) {
 	}
 	name := path
 
+	// GET /roles/<name>/persona?at=RFC3339&source=&channel=
+	if n, ok := strings.CutSuffix(path, "/persona"); ok {
+		h.handlePersonaPreview(w, r, n)
+		return
+	}
+
+	// POST /roles/<name>/simulate {"prompt", "trustLevel"}
+	if n, ok := strings.CutSuffix(path, "/simulate"); ok {
+		h.handleSimulate(w, r, n)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		result, ok := h.d.GetAgent(name)
@@ -174,7 +198,7 @@ func (h *agentRoleHandler) handleRole(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		audit.Log(h.d.HistoryDB(), "agent.update", "http",
+		audit.LogCtx(r.Context(), h.d.HistoryDB(), "agent.update", "http",
 			fmt.Sprintf("name=%s", name), clientIP(r))
 		w.Write([]byte(`{"status":"updated"}`))
 
@@ -194,7 +218,7 @@ func (h *agentRoleHandler) handleRole(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		audit.Log(h.d.HistoryDB(), "agent.delete", "http",
+		audit.LogCtx(r.Context(), h.d.HistoryDB(), "agent.delete", "http",
 			fmt.Sprintf("name=%s", name), clientIP(r))
 		w.Write([]byte(`{"status":"deleted"}`))
 
@@ -202,3 +226,66 @@ func (h *agentRoleHandler) handleRole(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "GET, PUT or DELETE only", http.StatusMethodNotAllowed)
 	}
 }
+
+func (h *agentRoleHandler) handlePersonaPreview(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.d.PreviewPersona == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	at := time.Now()
+	if v := q.Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, `{"error":"at must be RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+	result, ok := h.d.PreviewPersona(name, at, q.Get("source"), q.Get("channel"))
+	if !ok {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *agentRoleHandler) handleSimulate(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.d.Simulate == nil || !h.d.AgentExists(name) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Prompt     string `json:"prompt"`
+		TrustLevel string `json:"trustLevel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(body.Prompt) == "" {
+		http.Error(w, `{"error":"prompt is required"}`, http.StatusBadRequest)
+		return
+	}
+	if body.TrustLevel != "" && !trust.IsValidLevel(body.TrustLevel) {
+		http.Error(w, `{"error":"trustLevel must be observe, suggest or auto"}`, http.StatusBadRequest)
+		return
+	}
+	result, err := h.d.Simulate(r.Context(), name, body.Prompt, body.TrustLevel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	audit.LogCtx(r.Context(), h.d.HistoryDB(), "agent.simulate", "http",
+		fmt.Sprintf("name=%s", name), clientIP(r))
+	json.NewEncoder(w).Encode(result)
+}
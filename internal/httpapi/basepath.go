@@ -0,0 +1,190 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BasePath serves next under prefix (e.g. "/tetora") for running behind a
+// reverse proxy at a sub-path. Requests under prefix have it stripped;
+// requests without it are served as-is, for proxies that strip the prefix
+// themselves. Handlers keep using root-relative paths: redirects, cookie
+// paths, HTML links and the PWA manifest and service worker are rewritten on
+// the way out, and HTML pages get a shim that prefixes fetch, XHR and
+// EventSource URLs. An empty prefix returns next unchanged.
+func BasePath(prefix string, next http.Handler) http.Handler {
+	if prefix == "" || prefix == "/" {
+		return next
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := r.URL.Path; p == prefix || strings.HasPrefix(p, prefix+"/") {
+			r2 := new(http.Request)
+			*r2 = *r
+			u := *r.URL
+			r2.URL = &u
+			r2.URL.Path = strings.TrimPrefix(p, prefix)
+			if r2.URL.Path == "" {
+				r2.URL.Path = "/"
+			}
+			r2.URL.RawPath = ""
+			r2.RequestURI = r2.URL.RequestURI()
+			r = r2
+		}
+		bw := &basePathWriter{ResponseWriter: w, prefix: prefix}
+		defer bw.finish()
+		next.ServeHTTP(bw, r)
+	})
+}
+
+// basePathWriter rewrites headers and, for rewritable content types, buffers
+// the body so links can be prefixed before it is sent.
+type basePathWriter struct {
+	http.ResponseWriter
+	prefix      string
+	wroteHeader bool
+	status      int
+	buf         *bytes.Buffer // non-nil while buffering a rewritable body
+	kind        string
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if loc := h.Get("Location"); loc != "" {
+		h.Set("Location", w.prefixPath(loc))
+	}
+	if cookies := h.Values("Set-Cookie"); len(cookies) > 0 {
+		h.Del("Set-Cookie")
+		for _, c := range cookies {
+			h.Add("Set-Cookie", cookiePathRe.ReplaceAllString(c, "${1}"+w.prefix+"/"))
+		}
+	}
+	if h.Get("Service-Worker-Allowed") == "/" {
+		h.Set("Service-Worker-Allowed", w.prefix+"/")
+	}
+	ct, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	switch ct {
+	case "text/html":
+		w.kind = "html"
+	case "application/javascript", "application/manifest+json":
+		w.kind = "literal"
+	}
+	if w.kind != "" && h.Get("Content-Encoding") == "" && code != http.StatusNoContent && code != http.StatusNotModified {
+		h.Del("Content-Length")
+		w.status = code
+		w.buf = new(bytes.Buffer)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *basePathWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish sends a buffered body with its links prefixed.
+func (w *basePathWriter) finish() {
+	if w.buf == nil {
+		return
+	}
+	body := w.buf.Bytes()
+	if w.kind == "html" {
+		body = RewriteHTML(body, w.prefix)
+	} else {
+		body = literalPathRe.ReplaceAll(body, []byte("${1}"+w.prefix+"/${2}"))
+	}
+	w.buf = nil
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
+
+// Flush passes through for streaming responses such as SSE, which are never
+// buffered.
+func (w *basePathWriter) Flush() {
+	if w.buf != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *basePathWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (w *basePathWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// prefixPath prefixes a root-relative URL path; absolute URLs and paths
+// already under the prefix are left alone.
+func (w *basePathWriter) prefixPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") ||
+		p == w.prefix || strings.HasPrefix(p, w.prefix+"/") || strings.HasPrefix(p, w.prefix+"?") {
+		return p
+	}
+	return w.prefix + p
+}
+
+var (
+	cookiePathRe  = regexp.MustCompile(`(?i)(;\s*path=)/`)
+	literalPathRe = regexp.MustCompile(`(["'])/([^/])`)
+	htmlAttrRe    = regexp.MustCompile(`(\s(?:href|src|action)=["'])/([^/])`)
+	htmlHeadRe    = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+)
+
+// htmlScriptLiterals are root-relative paths in inline scripts that the shim
+// cannot intercept (navigation and service worker registration).
+var htmlScriptLiterals = []string{"location.href = '/", "location.href='/", "register('/", "scope: '/'"}
+
+// basePathShim prefixes root-relative URLs passed to fetch, XHR, EventSource
+// and window.open. __PREFIX__ is replaced with the base path.
+const basePathShim = `<script>(function(){var P='__PREFIX__';` +
+	`function f(u){return typeof u==='string'&&u.charAt(0)==='/'&&u.charAt(1)!=='/'&&u!==P&&u.indexOf(P+'/')!==0&&u.indexOf(P+'?')!==0?P+u:u}` +
+	`window.__tetoraBasePath=P;` +
+	`var F=window.fetch;if(F)window.fetch=function(u,o){return F.call(this,f(u),o)};` +
+	`var O=XMLHttpRequest.prototype.open;XMLHttpRequest.prototype.open=function(m,u){arguments[1]=f(u);return O.apply(this,arguments)};` +
+	`var W=window.open;window.open=function(u){arguments[0]=f(u);return W.apply(this,arguments)};` +
+	`var E=window.EventSource;if(E){var S=function(u,o){return new E(f(u),o)};S.prototype=E.prototype;S.CONNECTING=0;S.OPEN=1;S.CLOSED=2;window.EventSource=S}` +
+	`})();</script>`
+
+// RewriteHTML prefixes root-relative href, src and action attributes and the
+// known navigation literals in body, and injects the URL shim after <head>.
+func RewriteHTML(body []byte, prefix string) []byte {
+	body = htmlAttrRe.ReplaceAll(body, []byte("${1}"+prefix+"/${2}"))
+	for _, lit := range htmlScriptLiterals {
+		i := strings.Index(lit, "'/") + 1
+		body = bytes.ReplaceAll(body, []byte(lit), []byte(lit[:i]+prefix+lit[i:]))
+	}
+	if loc := htmlHeadRe.FindIndex(body); loc != nil {
+		shim := strings.Replace(basePathShim, "__PREFIX__", prefix, 1)
+		out := make([]byte, 0, len(body)+len(shim))
+		out = append(out, body[:loc[1]]...)
+		out = append(out, shim...)
+		body = append(out, body[loc[1]:]...)
+	}
+	return body
+}
@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dashboard", http.StatusFound)
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "s", Value: "1", Path: "/dashboard/login"})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<html><head><title>t</title></head><body><a href="/dashboard/x">x</a><img src='/dashboard/icon.svg'><a href="https://example.com/">e</a><script>location.href = '/workflow-runs/1';</script></body></html>`)
+	})
+	mux.HandleFunc("/dashboard/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/manifest+json")
+		io.WriteString(w, `{"start_url": "/dashboard", "scope": "/"}`)
+	})
+	mux.HandleFunc("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"path":"`+r.URL.Path+`","uri":"`+r.RequestURI+`"}`)
+	})
+	h := BasePath("/tetora/", mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/tetora/"); w.Header().Get("Location") != "/tetora/dashboard" {
+		t.Errorf("redirect Location = %q", w.Header().Get("Location"))
+	}
+
+	w := get("/tetora/dashboard")
+	body := w.Body.String()
+	for _, want := range []string{
+		`<head><script>(function(){var P='/tetora';`,
+		`href="/tetora/dashboard/x"`,
+		`src='/tetora/dashboard/icon.svg'`,
+		`href="https://example.com/"`,
+		`location.href = '/tetora/workflow-runs/1'`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard body missing %q:\n%s", want, body)
+		}
+	}
+	if c := w.Header().Get("Set-Cookie"); !strings.Contains(c, "Path=/tetora/dashboard/login") {
+		t.Errorf("Set-Cookie = %q", c)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" && cl != strconv.Itoa(len(body)) {
+		t.Errorf("Content-Length = %s, body %d", cl, len(body))
+	}
+
+	if body := get("/tetora/dashboard/manifest.json").Body.String(); body != `{"start_url": "/tetora/dashboard", "scope": "/tetora/"}` {
+		t.Errorf("manifest = %s", body)
+	}
+
+	// JSON is passed through untouched; handlers see the stripped path.
+	if body := get("/tetora/api/ping?x=1").Body.String(); body != `{"path":"/api/ping","uri":"/api/ping?x=1"}` {
+		t.Errorf("api = %s", body)
+	}
+	// A proxy that strips the prefix itself still works.
+	if body := get("/api/ping").Body.String(); body != `{"path":"/api/ping","uri":"/api/ping"}` {
+		t.Errorf("unprefixed api = %s", body)
+	}
+}
+
+func TestBasePathStreams(t *testing.T) {
+	flushed := make(chan struct{})
+	h := BasePath("/tetora", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: hi\n\n")
+		w.(http.Flusher).Flush()
+		<-flushed
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	defer close(flushed)
+
+	resp, err := http.Get(srv.URL + "/tetora/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 64)
+	n, err := resp.Body.Read(buf)
+	if err != nil || string(buf[:n]) != "data: hi\n\n" {
+		t.Errorf("first event = %q, %v", buf[:n], err)
+	}
+}
+
+func TestBasePathEmpty(t *testing.T) {
+	mux := http.NewServeMux()
+	if h := BasePath("", mux); h != http.Handler(mux) {
+		t.Error("empty prefix should return the handler unchanged")
+	}
+}
@@ -1,18 +1,105 @@
 package httpapi
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
-func clientIP(r *http.Request) string {
-	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
-		return strings.TrimSpace(strings.SplitN(fwd, ",", 2)[0])
+// trustedProxies holds the networks whose X-Forwarded-* headers are honored.
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+func init() {
+	SetTrustedProxies(nil)
+}
+
+// SetTrustedProxies sets the reverse proxies, as IPs or CIDRs, whose
+// X-Forwarded-For and X-Forwarded-Proto headers are honored. Empty means
+// loopback only. Invalid entries are skipped and reported in the error.
+func SetTrustedProxies(entries []string) error {
+	if len(entries) == 0 {
+		entries = []string{"127.0.0.0/8", "::1/128"}
+	}
+	var nets []*net.IPNet
+	var bad []string
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if !strings.Contains(e, "/") {
+			if ip := net.ParseIP(e); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		} else if _, n, err := net.ParseCIDR(e); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		bad = append(bad, e)
+	}
+	trustedProxies.Store(&nets)
+	if len(bad) > 0 {
+		return fmt.Errorf("invalid trusted proxy entries: %s", strings.Join(bad, ", "))
 	}
+	return nil
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range *trustedProxies.Load() {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return r.RemoteAddr
 	}
 	return host
 }
+
+// FromTrustedProxy reports whether r was received from a trusted proxy, so
+// its X-Forwarded-* headers can be believed.
+func FromTrustedProxy(r *http.Request) bool {
+	return isTrustedProxy(remoteHost(r))
+}
+
+// ClientIP returns the address of the client that sent r. X-Forwarded-For is
+// only honored when the peer is a trusted proxy; the client is then the
+// right-most address in it that is not itself a trusted proxy.
+func ClientIP(r *http.Request) string {
+	host := remoteHost(r)
+	if !isTrustedProxy(host) {
+		return host
+	}
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func clientIP(r *http.Request) string {
+	return ClientIP(r)
+}
@@ -14,7 +14,8 @@ import (
 type CronDeps struct {
 	Available    bool
 	ListJobs     func() any
-	AddJob       func(raw json.RawMessage) error // decode + add
+	AddJob       func(ctx context.Context, raw json.RawMessage) (string, error) // decode + add; returns how a "when" phrase was read
+	ParseWhen    func(ctx context.Context, text, tz string) (any, error)
 	GetJobConfig func(id string) any
 	UpdateJob    func(id string, raw json.RawMessage) error
 	RemoveJob    func(id string) error
@@ -44,7 +45,8 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			if err := d.AddJob(body); err != nil {
+			interpreted, err := d.AddJob(r.Context(), body)
+			if err != nil {
 				code := http.StatusBadRequest
 				if strings.Contains(err.Error(), "already exists") {
 					code = http.StatusConflict
@@ -56,12 +58,20 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 			var info struct {
 				ID       string `json:"id"`
 				Schedule string `json:"schedule"`
+				When     string `json:"when"`
 			}
 			json.Unmarshal(body, &info)
-			audit.Log(d.HistoryDB(), "job.create", "http",
-				fmt.Sprintf("id=%s schedule=%s", info.ID, info.Schedule), clientIP(r))
+			detail := fmt.Sprintf("id=%s schedule=%s", info.ID, info.Schedule)
+			if info.When != "" {
+				detail = fmt.Sprintf("id=%s when=%q", info.ID, info.When)
+			}
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.create", "http", detail, clientIP(r))
 			w.WriteHeader(http.StatusCreated)
-			w.Write([]byte(`{"status":"created"}`))
+			resp := map[string]string{"status": "created"}
+			if interpreted != "" {
+				resp["interpreted"] = interpreted
+			}
+			json.NewEncoder(w).Encode(resp)
 
 		default:
 			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
@@ -84,6 +94,22 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 		}
 
 		switch {
+		case id == "parse" && action == "" && r.Method == http.MethodPost:
+			var body struct {
+				Text string `json:"text"`
+				TZ   string `json:"tz"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+				http.Error(w, `{"error":"text is required"}`, http.StatusBadRequest)
+				return
+			}
+			res, err := d.ParseWhen(r.Context(), body.Text, body.TZ)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusUnprocessableEntity)
+				return
+			}
+			json.NewEncoder(w).Encode(res)
+
 		case action == "" && r.Method == http.MethodGet:
 			jc := d.GetJobConfig(id)
 			if jc == nil {
@@ -106,7 +132,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.update", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.update", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"updated"}`))
 
@@ -119,7 +145,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.delete", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.delete", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"removed"}`))
 
@@ -132,7 +158,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.toggle", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.toggle", "http",
 				fmt.Sprintf("id=%s enabled=%v", id, body.Enabled), clientIP(r))
 			w.Write([]byte(fmt.Sprintf(`{"status":"ok","enabled":%v}`, body.Enabled)))
 
@@ -141,7 +167,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.approve", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.approve", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"approved"}`))
 
@@ -150,7 +176,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.reject", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.reject", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"rejected"}`))
 
@@ -159,7 +185,7 @@ func RegisterCronRoutes(mux *http.ServeMux, d CronDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			audit.Log(d.HistoryDB(), "job.trigger", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "job.trigger", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"triggered"}`))
 
@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+
+	"tetora/internal/cfgfile"
 )
 
 // NotifChannel matches config.NotificationChannel for discord operations.
@@ -213,7 +214,7 @@ func ValidChannelName(name string) bool {
 // UpdateNotificationsConfig adds, updates, or removes a notification channel in config.
 // Pass ch=nil to remove.
 func UpdateNotificationsConfig(configPath, name string, ch *NotifChannel) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return err
 	}
@@ -255,7 +256,7 @@ func UpdateNotificationsConfig(configPath, name string, ch *NotifChannel) error
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 // SendTestWebhook sends a test message to a Discord webhook URL.
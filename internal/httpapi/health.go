@@ -170,7 +170,7 @@ func RegisterHealthRoutes(mux *http.ServeMux, d HealthDeps) {
 			http.Error(w, `{"error":"provider not found"}`, http.StatusNotFound)
 			return
 		}
-		audit.Log(d.HistoryDB, "circuit.reset", r.RemoteAddr, provider, "")
+		audit.LogCtx(r.Context(), d.HistoryDB, "circuit.reset", r.RemoteAddr, provider, "")
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(fmt.Sprintf(`{"provider":%q,"state":"closed"}`, provider)))
 	})
@@ -16,6 +16,10 @@ func RegisterHistoryRoutes(mux *http.ServeMux, resolveDB func(r *http.Request) s
 	h := &historyHandler{resolveDB: resolveDB}
 	mux.HandleFunc("/history", h.handleHistoryList)
 	mux.HandleFunc("/history/subtask-counts", h.handleSubtaskCounts)
+	mux.HandleFunc("/history/cost", h.handleCost)
+	mux.HandleFunc("/history/fails", h.handleFails)
+	mux.HandleFunc("/history/streaks", h.handleStreaks)
+	mux.HandleFunc("/history/trace/", h.handleTrace)
 	mux.HandleFunc("/history/", h.handleHistoryByID)
 }
 
@@ -124,3 +128,93 @@ func (h *historyHandler) handleHistoryByID(w http.ResponseWriter, r *http.Reques
 	}
 	json.NewEncoder(w).Encode(run)
 }
+
+// queryInt returns the positive integer query parameter name, or def.
+func queryInt(r *http.Request, name string, def int) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func (h *historyHandler) handleCost(w http.ResponseWriter, r *http.Request) {
+	db := h.resolveDB(r)
+	if db == "" {
+		http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	stats, err := history.QueryCostStats(db)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (h *historyHandler) handleFails(w http.ResponseWriter, r *http.Request) {
+	db := h.resolveDB(r)
+	if db == "" {
+		http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	runs, err := history.QueryRecentFails(db, history.FailQuery{
+		JobID: r.URL.Query().Get("job_id"),
+		Days:  queryInt(r, "days", 3),
+		Limit: queryInt(r, "limit", 20),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []history.JobRun{}
+	}
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (h *historyHandler) handleStreaks(w http.ResponseWriter, r *http.Request) {
+	db := h.resolveDB(r)
+	if db == "" {
+		http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	results, err := history.QueryConsecutiveFails(db, queryInt(r, "threshold", 3))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []history.ConsecutiveFailResult{}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+func (h *historyHandler) handleTrace(w http.ResponseWriter, r *http.Request) {
+	db := h.resolveDB(r)
+	if db == "" {
+		http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	jobID := strings.TrimPrefix(r.URL.Path, "/history/trace/")
+	if jobID == "" {
+		http.Error(w, `{"error":"job id required"}`, http.StatusBadRequest)
+		return
+	}
+	runs, err := history.QueryJobTrace(db, jobID, queryInt(r, "limit", 10))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []history.JobRun{}
+	}
+	json.NewEncoder(w).Encode(runs)
+}
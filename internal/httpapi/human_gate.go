@@ -127,7 +127,7 @@ func RegisterHumanGateRoutes(mux *http.ServeMux, d HumanGateDeps) {
 				return
 			}
 
-			audit.Log(d.HistoryDB(), "human_gate.cancel", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "human_gate.cancel", "http",
 				fmt.Sprintf("key=%s cancelledBy=%s reason=%s", key, body.CancelledBy, body.Reason),
 				clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "cancelled", "key": key})
@@ -156,7 +156,7 @@ func RegisterHumanGateRoutes(mux *http.ServeMux, d HumanGateDeps) {
 				return
 			}
 
-			audit.Log(d.HistoryDB(), "human_gate.retry", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "human_gate.retry", "http",
 				fmt.Sprintf("key=%s newRunId=%s", key, newRunID),
 				clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "retrying", "key": key, "newRunId": newRunID})
@@ -200,7 +200,7 @@ func RegisterHumanGateRoutes(mux *http.ServeMux, d HumanGateDeps) {
 				return
 			}
 
-			audit.Log(d.HistoryDB(), "human_gate.respond", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "human_gate.respond", "http",
 				fmt.Sprintf("key=%s action=%s respondedBy=%s", key, body.Action, body.RespondedBy),
 				clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "delivered", "key": key, "action": body.Action})
@@ -10,6 +10,7 @@ import (
 // KnowledgeSearchResult represents a matched knowledge chunk.
 type KnowledgeSearchResult struct {
 	Filename  string  `json:"filename"`
+	Heading   string  `json:"heading,omitempty"`
 	Snippet   string  `json:"snippet"`
 	Score     float64 `json:"score"`
 	LineStart int     `json:"lineStart"`
@@ -30,8 +31,9 @@ type ReflectionResult struct {
 type KnowledgeDeps struct {
 	KnowledgeDir func() string
 	HistoryDB    func() string
-	// SearchKnowledge searches the knowledge directory. Returns results.
-	SearchKnowledge func(dir, query string, limit int) ([]KnowledgeSearchResult, error)
+	// SearchKnowledge searches the knowledge directory, within the agent's
+	// knowledge scope when agent is set.
+	SearchKnowledge func(dir, query, agent string, limit int) ([]KnowledgeSearchResult, error)
 	// QueryReflections queries reflections from history DB.
 	QueryReflections func(dbPath, role string, limit int) ([]ReflectionResult, error)
 }
@@ -64,7 +66,7 @@ func (h *knowledgeHandler) handleSearch(w http.ResponseWriter, r *http.Request)
 			limit = n
 		}
 	}
-	results, err := h.deps.SearchKnowledge(h.deps.KnowledgeDir(), q, limit)
+	results, err := h.deps.SearchKnowledge(h.deps.KnowledgeDir(), q, r.URL.Query().Get("agent"), limit)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 		return
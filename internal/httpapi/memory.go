@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"tetora/internal/audit"
+	"tetora/internal/memory"
 )
 
 // MemoryDeps holds dependencies for memory and MCP config HTTP handlers.
@@ -23,6 +25,12 @@ type MemoryDeps struct {
 	GetMemory    func(role, key string) (string, error)
 	SetMemory    func(agent, key, value string) error
 	DeleteMemory func(role, key string) error
+	// SetMemoryAging sets an entry's expiry (ttl from now) and confidence;
+	// zero values leave the current setting.
+	SetMemoryAging func(key string, ttl time.Duration, confidence float64) error
+	// MemoryGraph exports memory keys, knowledge docs and contacts of the
+	// given types (all when empty) as a node/edge graph.
+	MemoryGraph func(types []string) (any, error)
 
 	FindConfigPath func() string
 	HistoryDB      func() string
@@ -60,7 +68,7 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			audit.Log(d.HistoryDB(), "mcp.save", "http", body.Name, clientIP(r))
+			audit.LogCtx(r.Context(), d.HistoryDB(), "mcp.save", "http", body.Name, clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok", "name": body.Name})
 
 		default:
@@ -99,7 +107,7 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "mcp.delete", "http", name, clientIP(r))
+			audit.LogCtx(r.Context(), d.HistoryDB(), "mcp.delete", "http", name, clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
 		case action == "test" && r.Method == "POST":
@@ -135,9 +143,11 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 
 		case "POST":
 			var body struct {
-				Agent string `json:"agent"`
-				Key   string `json:"key"`
-				Value string `json:"value"`
+				Agent      string  `json:"agent"`
+				Key        string  `json:"key"`
+				Value      string  `json:"value"`
+				TTL        string  `json:"ttl"`        // optional: "90d", "12h"
+				Confidence float64 `json:"confidence"` // optional: 0-1
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 				http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
@@ -147,11 +157,29 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 				http.Error(w, `{"error":"agent and key are required"}`, http.StatusBadRequest)
 				return
 			}
+			var ttl time.Duration
+			if body.TTL != "" {
+				var err error
+				if ttl, err = memory.ParseTTL(body.TTL); err != nil {
+					http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+					return
+				}
+			}
+			if body.Confidence < 0 || body.Confidence > 1 {
+				http.Error(w, `{"error":"confidence must be between 0 and 1"}`, http.StatusBadRequest)
+				return
+			}
 			if err := d.SetMemory(body.Agent, body.Key, body.Value); err != nil {
 				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
 				return
 			}
-			audit.Log(d.HistoryDB(), "memory.set", "http",
+			if d.SetMemoryAging != nil {
+				if err := d.SetMemoryAging(body.Key, ttl, body.Confidence); err != nil {
+					http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+					return
+				}
+			}
+			audit.LogCtx(r.Context(), d.HistoryDB(), "memory.set", "http",
 				fmt.Sprintf("agent=%s key=%s", body.Agent, body.Key), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
@@ -160,6 +188,33 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 		}
 	})
 
+	// GET /memory/graph — memory, knowledge and contacts with their
+	// cross-references (?types=memory,knowledge,contact).
+	mux.HandleFunc("/memory/graph", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		var types []string
+		for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+			switch t = strings.TrimSpace(t); t {
+			case "":
+			case "memory", "knowledge", "contact":
+				types = append(types, t)
+			default:
+				http.Error(w, fmt.Sprintf(`{"error":"unknown type '%s' (use memory, knowledge or contact)"}`, t), http.StatusBadRequest)
+				return
+			}
+		}
+		graph, err := d.MemoryGraph(types)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(graph)
+	})
+
 	mux.HandleFunc("/memory/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -188,7 +243,7 @@ func RegisterMemoryRoutes(mux *http.ServeMux, d MemoryDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
 				return
 			}
-			audit.Log(d.HistoryDB(), "memory.delete", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "memory.delete", "http",
 				fmt.Sprintf("role=%s key=%s", role, key), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
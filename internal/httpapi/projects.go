@@ -53,7 +53,7 @@ func RegisterProjectRoutes(mux *http.ServeMux, d ProjectsDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
 			}
-			audit.Log(d.HistoryDB(), "project.create", "http", "created", clientIP(r))
+			audit.LogCtx(r.Context(), d.HistoryDB(), "project.create", "http", "created", clientIP(r))
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(project)
 
@@ -134,7 +134,7 @@ func RegisterProjectRoutes(mux *http.ServeMux, d ProjectsDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
 			}
-			audit.Log(d.HistoryDB(), "project.update", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "project.update", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			json.NewEncoder(w).Encode(updated)
 
@@ -147,7 +147,7 @@ func RegisterProjectRoutes(mux *http.ServeMux, d ProjectsDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
 			}
-			audit.Log(d.HistoryDB(), "project.delete", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "project.delete", "http",
 				fmt.Sprintf("id=%s", id), clientIP(r))
 			w.Write([]byte(`{"status":"deleted"}`))
 
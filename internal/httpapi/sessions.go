@@ -2,12 +2,14 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"tetora/internal/audit"
+	"tetora/internal/session"
 )
 
 // SessionDeps holds all dependencies for session and skill HTTP handlers.
@@ -18,8 +20,16 @@ type SessionDeps struct {
 	QuerySessions func(role, status, source string, limit, offset int) (sessions any, total int, err error)
 
 	// CreateSession creates a new session. agent must exist; returns JSON-serializable session or error.
+	// A non-empty agents list makes it a group session, optionally with a moderator.
 	// ip is the client IP for audit logging.
-	CreateSession func(agent, title, ip string) (any, error)
+	CreateSession func(agent, title string, agents []string, moderator, ip string) (any, error)
+
+	// GetParticipants returns a session's participating agents and moderator.
+	GetParticipants func(sessionID string) (any, error)
+
+	// SetParticipants replaces a session's participating agents; an empty list
+	// turns it back into a single-agent session.
+	SetParticipants func(sessionID string, agents []string, moderator string) error
 
 	// GetSessionDetail returns session detail with messages, or nil if not found.
 	GetSessionDetail func(id string) (any, error)
@@ -27,9 +37,10 @@ type SessionDeps struct {
 	// ArchiveSession sets a session's status to archived.
 	ArchiveSession func(id string) error
 
-	// SendMessage sends a message to a session. async=true returns immediately without waiting for the result.
+	// SendMessage sends a message to a session. A non-empty model overrides the
+	// agent's model for this message. async=true returns immediately without waiting for the result.
 	// Returns a JSON-serializable response and HTTP status code.
-	SendMessage func(r *http.Request, sessionID, prompt string, async bool) (any, int, error)
+	SendMessage func(r *http.Request, sessionID, prompt, model string, async bool) (any, int, error)
 
 	// MirrorMessage records an external message in a session (no task execution).
 	// The body contains the raw JSON payload. Returns JSON-serializable response and HTTP status code.
@@ -69,6 +80,26 @@ type SessionDeps struct {
 
 func clientIPFromRequest(r *http.Request) string { return clientIP(r) }
 
+// checkParticipants validates a group session's agents and moderator,
+// returning a JSON error body or "".
+func checkParticipants(d SessionDeps, agents []string, moderator string) string {
+	for _, a := range agents {
+		if !d.AgentExists(a) {
+			b, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("agent %q not found", a)})
+			return string(b)
+		}
+	}
+	if moderator == "" {
+		return ""
+	}
+	for _, a := range agents {
+		if a == moderator {
+			return ""
+		}
+	}
+	return `{"error":"moderator must be one of the agents"}`
+}
+
 // RegisterSessionRoutes registers session and skill routes on the given mux.
 func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 	// --- Sessions ---
@@ -116,13 +147,18 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 
 		case http.MethodPost:
 			var body struct {
-				Agent string `json:"agent"`
-				Title string `json:"title"`
+				Agent     string   `json:"agent"`
+				Title     string   `json:"title"`
+				Agents    []string `json:"agents"`
+				Moderator string   `json:"moderator"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 				http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
 				return
 			}
+			if body.Agent == "" && len(body.Agents) > 0 {
+				body.Agent = body.Agents[0]
+			}
 			if body.Agent == "" {
 				http.Error(w, `{"error":"agent is required"}`, http.StatusBadRequest)
 				return
@@ -131,7 +167,11 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 				http.Error(w, `{"error":"agent not found"}`, http.StatusBadRequest)
 				return
 			}
-			sess, err := d.CreateSession(body.Agent, body.Title, clientIPFromRequest(r))
+			if msg := checkParticipants(d, body.Agents, body.Moderator); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			sess, err := d.CreateSession(body.Agent, body.Title, body.Agents, body.Moderator, clientIPFromRequest(r))
 			if err != nil {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 				return
@@ -186,6 +226,12 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 		// GET /sessions/{id} — get session with messages.
 		case action == "" && r.Method == http.MethodGet:
 			detail, err := d.GetSessionDetail(sessionID)
+			var ambig *session.ErrAmbiguousSession
+			if errors.As(err, &ambig) {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "matches": ambig.Matches})
+				return
+			}
 			if err != nil {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 				return
@@ -202,7 +248,7 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 				return
 			}
-			audit.Log(d.HistoryDB, "session.archive", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB, "session.archive", "http",
 				fmt.Sprintf("session=%s", sessionID), clientIPFromRequest(r))
 			w.Write([]byte(`{"status":"archived"}`))
 
@@ -210,13 +256,14 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 		case action == "message" && r.Method == http.MethodPost:
 			var body struct {
 				Prompt string `json:"prompt"`
+				Model  string `json:"model"`
 				Async  bool   `json:"async"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Prompt == "" {
 				http.Error(w, `{"error":"prompt is required"}`, http.StatusBadRequest)
 				return
 			}
-			resp, code, err := d.SendMessage(r, sessionID, body.Prompt, body.Async)
+			resp, code, err := d.SendMessage(r, sessionID, body.Prompt, body.Model, body.Async)
 			if err != nil {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), code)
 				return
@@ -224,6 +271,39 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 			w.WriteHeader(code)
 			json.NewEncoder(w).Encode(resp)
 
+		// GET /sessions/{id}/participants — agents taking part in a group session.
+		case action == "participants" && r.Method == http.MethodGet:
+			g, err := d.GetParticipants(sessionID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(g)
+
+		// PUT /sessions/{id}/participants — replace the participating agents.
+		case action == "participants" && r.Method == http.MethodPut:
+			var body struct {
+				Agents    []string `json:"agents"`
+				Moderator string   `json:"moderator"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+				return
+			}
+			if msg := checkParticipants(d, body.Agents, body.Moderator); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			if err := d.SetParticipants(sessionID, body.Agents, body.Moderator); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
+				return
+			}
+			audit.LogCtx(r.Context(), d.HistoryDB, "session.participants", "http",
+				fmt.Sprintf("session=%s agents=%s moderator=%s", sessionID, strings.Join(body.Agents, ","), body.Moderator),
+				clientIPFromRequest(r))
+			g, _ := d.GetParticipants(sessionID)
+			json.NewEncoder(w).Encode(g)
+
 		// POST /sessions/{id}/mirror — record external message (no task execution).
 		case action == "mirror" && r.Method == http.MethodPost:
 			raw, err := readBody(r)
@@ -242,7 +322,7 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 		// POST /sessions/{id}/compact — trigger context compaction.
 		case action == "compact" && r.Method == http.MethodPost:
 			d.CompactSession(sessionID)
-			audit.Log(d.HistoryDB, "session.compact", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB, "session.compact", "http",
 				fmt.Sprintf("session=%s", sessionID), clientIPFromRequest(r))
 			w.WriteHeader(http.StatusAccepted)
 			json.NewEncoder(w).Encode(map[string]string{"status": "compacting"})
@@ -325,7 +405,7 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 			}
 			json.NewDecoder(r.Body).Decode(&body)
 
-			audit.Log(d.HistoryDB, "skill.run", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB, "skill.run", "http",
 				fmt.Sprintf("name=%s", name), clientIPFromRequest(r))
 
 			result, err := d.RunSkill(r, name, body.Vars)
@@ -336,7 +416,7 @@ func RegisterSessionRoutes(mux *http.ServeMux, d SessionDeps) {
 			json.NewEncoder(w).Encode(result)
 
 		case "test":
-			audit.Log(d.HistoryDB, "skill.test", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB, "skill.test", "http",
 				fmt.Sprintf("name=%s", name), clientIPFromRequest(r))
 
 			result, err := d.TestSkill(r, name)
@@ -4,13 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"sort"
 	"strconv"
 
 	"tetora/internal/audit"
+	"tetora/internal/chart"
 	"tetora/internal/cost"
 	"tetora/internal/db"
+	"tetora/internal/feedback"
 	"tetora/internal/history"
 	"tetora/internal/log"
 	"tetora/internal/sla"
@@ -40,6 +41,10 @@ type StatsDeps struct {
 	// QueryCostStats returns today/week/month cost totals.
 	QueryCostStats func(dbPath string) (today, week, month float64, err error)
 
+	// QueryToolCosts returns MCP and plugin tool spend per server and tool
+	// over the last N days.
+	QueryToolCosts func(dbPath string, days int) (any, error)
+
 	// QueryDailyStats returns per-day cost/task stats for the last N days.
 	QueryDailyStats func(dbPath string, days int) (any, error)
 
@@ -56,7 +61,7 @@ type StatsDeps struct {
 	// Budget access.
 	Budgets         func() cost.BudgetConfig
 	SetBudgetPaused func(configPath string, paused bool) error
-	// ConfigPath returns cfg.BaseDir (the directory containing config.json).
+	// ConfigPath returns the config file (config.json, .yaml or .toml).
 	ConfigPath func() string
 
 	// SLA config.
@@ -147,6 +152,11 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 			"week":  week,
 			"month": month,
 		}
+		if d.QueryToolCosts != nil {
+			if tools, err := d.QueryToolCosts(historyDB, 30); err == nil {
+				result["tools"] = tools
+			}
+		}
 
 		// Include cost alert config if limits are set.
 		dailyLimit := d.CostAlertDailyLimit()
@@ -168,8 +178,6 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 			http.Error(w, `{"error":"history DB not configured"}`, http.StatusServiceUnavailable)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-
 		days := 7
 		if dv := r.URL.Query().Get("days"); dv != "" {
 			if n, err := strconv.Atoi(dv); err == nil && n > 0 && n <= 90 {
@@ -179,12 +187,32 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 
 		stats, err := d.QueryDailyStats(historyDB, days)
 		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
 			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 			return
 		}
 		if stats == nil {
 			stats = []history.DayStat{}
 		}
+		// ?format=png|svg draws the trend for reports and briefings;
+		// ?metric=tasks charts task outcomes instead of cost.
+		if format := r.URL.Query().Get("format"); format == "png" || format == "svg" {
+			dayStats, _ := stats.([]history.DayStat)
+			data, err := trendChart(dayStats, r.URL.Query().Get("metric"), format)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+				return
+			}
+			if format == "svg" {
+				w.Header().Set("Content-Type", "image/svg+xml")
+			} else {
+				w.Header().Set("Content-Type", "image/png")
+			}
+			w.Write(data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(stats)
 	})
 
@@ -265,6 +293,14 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 		if byRole == nil {
 			byRole = map[string]*audit.AgentRoutingStats{}
 		}
+		if totals, err := feedback.AgentTotals(historyDB); err == nil {
+			for agent, t := range totals {
+				if byRole[agent] == nil {
+					byRole[agent] = &audit.AgentRoutingStats{}
+				}
+				byRole[agent].FeedbackUp, byRole[agent].FeedbackDown = t.Up, t.Down
+			}
+		}
 
 		json.NewEncoder(w).Encode(map[string]any{
 			"history": routeHistory,
@@ -329,17 +365,35 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 		json.NewEncoder(w).Encode(status)
 	})
 
+	mux.HandleFunc("/budget/approvals", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"GET only"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		approvals, err := cost.QuerySpendApprovals(historyDB, limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		if approvals == nil {
+			approvals = []cost.SpendApproval{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approvals)
+	})
+
 	mux.HandleFunc("/budget/pause", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
 			return
 		}
-		configPath := filepath.Join(d.ConfigPath(), "config.json")
+		configPath := d.ConfigPath()
 		if err := d.SetBudgetPaused(configPath, true); err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 			return
 		}
-		audit.Log(historyDB, "budget.pause", "http", "all paid execution paused", clientIP(r))
+		audit.LogCtx(r.Context(), historyDB, "budget.pause", "http", "all paid execution paused", clientIP(r))
 		log.Warn("budget PAUSED by API request", "ip", clientIP(r))
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"status":"paused"}`))
@@ -350,12 +404,12 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 			http.Error(w, `{"error":"POST only"}`, http.StatusMethodNotAllowed)
 			return
 		}
-		configPath := filepath.Join(d.ConfigPath(), "config.json")
+		configPath := d.ConfigPath()
 		if err := d.SetBudgetPaused(configPath, false); err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
 			return
 		}
-		audit.Log(historyDB, "budget.resume", "http", "paid execution resumed", clientIP(r))
+		audit.LogCtx(r.Context(), historyDB, "budget.resume", "http", "paid execution resumed", clientIP(r))
 		log.Info("budget RESUMED by API request", "ip", clientIP(r))
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"status":"active"}`))
@@ -734,3 +788,37 @@ func RegisterStatsRoutes(mux *http.ServeMux, d StatsDeps) {
 		})
 	})
 }
+
+// trendChart draws daily stats as a cost line chart, or as succeeded and
+// failed task bars when metric is "tasks".
+func trendChart(stats []history.DayStat, metric, format string) ([]byte, error) {
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no runs in this period")
+	}
+	c := chart.Chart{Kind: chart.Line, Title: "Cost per day (USD)"}
+	var costs, ok, failed []float64
+	for _, s := range stats {
+		label := s.Date
+		if len(label) == len("2006-01-02") {
+			label = label[5:]
+		}
+		c.Labels = append(c.Labels, label)
+		costs = append(costs, s.Cost)
+		ok = append(ok, float64(s.Success))
+		failed = append(failed, float64(s.Fail))
+	}
+	switch metric {
+	case "", "cost":
+		c.Series = []chart.Series{{Name: "Cost", Values: costs}}
+	case "tasks":
+		c.Kind = chart.Bar
+		c.Title = "Tasks per day"
+		c.Series = []chart.Series{{Name: "Succeeded", Values: ok}, {Name: "Failed", Values: failed}}
+	default:
+		return nil, fmt.Errorf("metric must be cost or tasks")
+	}
+	if format == "svg" {
+		return c.SVG()
+	}
+	return c.PNG()
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ListenUnix listens on a unix socket at path for local clients such as the
+// CLI. A stale socket left by a crashed daemon is removed; a socket another
+// daemon still answers on is an error. The socket is only accessible to the
+// owner. Connections report a loopback peer address so client IP handling,
+// allowlists and rate limits treat them like local TCP clients.
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return unixListener{ln}, nil
+}
+
+type unixListener struct{ net.Listener }
+
+func (l unixListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return unixConn{c}, nil
+}
+
+type unixConn struct{ net.Conn }
+
+var loopbackAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+func (unixConn) RemoteAddr() net.Addr { return loopbackAddr }
+
+// FromUnixSocket reports whether r arrived on a listener from ListenUnix.
+func FromUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "tsock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "tetora.sock")
+
+	// A stale socket left by a crashed daemon is replaced.
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := ListenUnix(sock)
+	if err != nil {
+		t.Fatalf("ListenUnix over stale socket: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !FromUnixSocket(r) {
+			t.Error("FromUnixSocket = false")
+		}
+		io.WriteString(w, ClientIP(r))
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	if fi, err := os.Stat(sock); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v", fi.Mode().Perm(), err)
+	}
+	if _, err := ListenUnix(sock); err == nil {
+		t.Error("ListenUnix on a live socket succeeded")
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://tetora/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != "127.0.0.1" {
+		t.Errorf("ClientIP = %q, want loopback", b)
+	}
+}
+
+func TestListenUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, nil, 0o600)
+	if _, err := ListenUnix(path); err == nil {
+		t.Error("ListenUnix over a regular file succeeded")
+	}
+}
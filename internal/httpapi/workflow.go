@@ -57,6 +57,8 @@ type WorkflowDeps struct {
 	QueryHandoffs func(historyDB, runID string) (any, error)
 	// QueryAgentMessages returns messages as any (root-typed struct slice, JSON-safe).
 	QueryAgentMessages func(historyDB, runID string, limit int) (any, error)
+	// RunDiagram renders a run as a "mermaid" or "dot" diagram.
+	RunDiagram func(historyDB, runID, format string) (string, error)
 
 	// Import (from export package)
 	// ImportWorkflow validates and saves an import package. Returns (name, stepCount, validationErrors, error).
@@ -164,7 +166,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, serr), http.StatusInternalServerError)
 				return
 			}
-			audit.Log(d.HistoryDB(), "workflow.create", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.create", "http",
 				fmt.Sprintf("name=%s steps=%d", name, stepCount), clientIP(r))
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(map[string]string{"status": "created", "name": name})
@@ -199,7 +201,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 			return
 		}
 
-		audit.Log(d.HistoryDB(), "workflow.import", "http",
+		audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.import", "http",
 			fmt.Sprintf("name=%s steps=%d", name, stepCount), clientIP(r))
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"status": "imported", "name": name})
@@ -259,7 +261,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "workflow.delete", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.delete", "http",
 				fmt.Sprintf("name=%s", name), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "name": name})
 
@@ -285,7 +287,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 					delete(runBody.Variables, k)
 				}
 			}
-			audit.Log(d.HistoryDB(), "workflow.run", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.run", "http",
 				fmt.Sprintf("name=%s", name), clientIP(r))
 			// Run asynchronously — context carries trace ID.
 			d.RunWorkflow(r.Context(), name, runBody.Variables)
@@ -315,7 +317,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 					delete(dryBody.Variables, k)
 				}
 			}
-			audit.Log(d.HistoryDB(), "workflow.dry-run", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.dry-run", "http",
 				fmt.Sprintf("name=%s", name), clientIP(r))
 			run, err := d.DryRunWorkflow(r.Context(), name, dryBody.Variables)
 			if err != nil {
@@ -342,7 +344,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
 				return
 			}
-			audit.Log(d.HistoryDB(), "workflow.restore", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.restore", "http",
 				fmt.Sprintf("name=%s version=%s", name, restoreBody.VersionID), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "restored", "workflow": name, "versionId": restoreBody.VersionID})
 
@@ -407,7 +409,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 			)); err != nil {
 				log.Warn("cancel workflow run failed", "runID", runID, "error", err)
 			}
-			audit.Log(d.HistoryDB(), "workflow.cancel", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.cancel", "http",
 				fmt.Sprintf("runID=%s", runID), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "cancelled", "runId": runID})
 			return
@@ -438,7 +440,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				return
 			}
 
-			audit.Log(d.HistoryDB(), "workflow.resume", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "workflow.resume", "http",
 				fmt.Sprintf("originalRunID=%s", runID), clientIP(r))
 
 			d.ResumeWorkflow(r.Context(), runID)
@@ -456,6 +458,33 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 			return
 		}
 
+		// GET /workflow-runs/{id}/diagram?format=mermaid|dot
+		if action == "diagram" {
+			format := r.URL.Query().Get("format")
+			if format == "" {
+				format = "mermaid"
+			}
+			if format != "mermaid" && format != "dot" {
+				http.Error(w, `{"error":"format must be mermaid or dot"}`, http.StatusBadRequest)
+				return
+			}
+			diagram, err := d.RunDiagram(d.HistoryDB(), runID, format)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusNotFound)
+				return
+			}
+			ext, ctype := "mmd", "text/vnd.mermaid; charset=utf-8"
+			if format == "dot" {
+				ext, ctype = "dot", "text/vnd.graphviz; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", ctype)
+			if r.URL.Query().Get("download") != "" {
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="workflow-run-%s.%s"`, runID, ext))
+			}
+			io.WriteString(w, diagram)
+			return
+		}
+
 		run, err := d.QueryWorkflowRunByID(d.HistoryDB(), runID)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusNotFound)
@@ -559,7 +588,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 			if installedName == "" {
 				installedName = name
 			}
-			audit.Log(d.HistoryDB(), "template.install", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "template.install", "http",
 				fmt.Sprintf("template=%s installed_as=%s", name, installedName), clientIP(r))
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(map[string]string{"status": "installed", "name": installedName})
@@ -630,7 +659,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				return
 			}
 			tName, tType, tWorkflow, _ := d.DecodeTriggerConfig(body)
-			audit.Log(d.HistoryDB(), "trigger.create", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.create", "http",
 				fmt.Sprintf("name=%s type=%s workflow=%s", tName, tType, tWorkflow), clientIP(r))
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(map[string]string{"status": "created", "name": tName})
@@ -757,7 +786,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				// Seq allocated atomically with Deliver to prevent race (#R2-1).
 				d.AppendStreamingCallback(d.HistoryDB(), key, out.Seq, cbResult)
 			}
-			audit.Log(d.HistoryDB(), "callback."+status, "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "callback."+status, "http",
 				fmt.Sprintf("key=%s", key), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": status})
 			return
@@ -783,7 +812,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 			return
 		}
 		d.MarkCallbackDelivered(d.HistoryDB(), key, 0, cbResult)
-		audit.Log(d.HistoryDB(), "callback.stored", "http",
+		audit.LogCtx(r.Context(), d.HistoryDB(), "callback.stored", "http",
 			fmt.Sprintf("key=%s (no active channel)", key), clientIP(r))
 		json.NewEncoder(w).Encode(map[string]string{"status": "stored"})
 	})
@@ -829,7 +858,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), status)
 				return
 			}
-			audit.Log(d.HistoryDB(), "trigger.webhook", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.webhook", "http",
 				fmt.Sprintf("trigger=%s", webhookID), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{
 				"status":  "accepted",
@@ -848,7 +877,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "trigger.fire", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.fire", "http",
 				fmt.Sprintf("trigger=%s", name), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{
 				"status":  "accepted",
@@ -875,7 +904,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, fmt.Sprintf(`{"error":"toggle failed: %v"}`, err), http.StatusInternalServerError)
 				return
 			}
-			audit.Log(d.HistoryDB(), "trigger.toggle", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.toggle", "http",
 				fmt.Sprintf("trigger=%s enabled=%v", name, newEnabled), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]any{"status": "toggled", "name": name, "enabled": newEnabled})
 
@@ -916,7 +945,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, `{"error":"trigger not found"}`, http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "trigger.update", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.update", "http",
 				fmt.Sprintf("trigger=%s", name), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "updated", "name": name})
 
@@ -940,7 +969,7 @@ func RegisterWorkflowRoutes(mux *http.ServeMux, d WorkflowDeps) {
 				http.Error(w, `{"error":"trigger not found"}`, http.StatusNotFound)
 				return
 			}
-			audit.Log(d.HistoryDB(), "trigger.delete", "http",
+			audit.LogCtx(r.Context(), d.HistoryDB(), "trigger.delete", "http",
 				fmt.Sprintf("trigger=%s", name), clientIP(r))
 			json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "name": name})
 
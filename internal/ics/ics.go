@@ -0,0 +1,191 @@
+// Package ics writes iCalendar (RFC 5545) files for single events, such as
+// the invitations the scheduling agent sends. Any calendar (Google, Outlook,
+// Apple, Thunderbird) can import them, and invitees can accept or decline
+// when the file names an organizer. It also reads the events of published
+// calendars, such as public holiday feeds and CalDAV calendars.
+package ics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a calendar event.
+type Event struct {
+	UID         string // generated when empty
+	Title       string
+	Description string
+	Location    string
+	URL         string
+	Start, End  time.Time
+	AllDay      bool     // Start and End are dates; End is exclusive
+	Organizer   string   // email address, optionally "Name <addr>"
+	Attendees   []string // email addresses, optionally "Name <addr>"
+	Reminder    time.Duration
+}
+
+// Validate checks the event and fills the UID and a one-hour end.
+func (e *Event) Validate() error {
+	if strings.TrimSpace(e.Title) == "" {
+		return fmt.Errorf("event title is required")
+	}
+	if e.Start.IsZero() {
+		return fmt.Errorf("event start is required")
+	}
+	if e.End.IsZero() {
+		if e.AllDay {
+			e.End = e.Start.AddDate(0, 0, 1)
+		} else {
+			e.End = e.Start.Add(time.Hour)
+		}
+	}
+	if !e.End.After(e.Start) {
+		return fmt.Errorf("event must end after it starts")
+	}
+	for _, a := range append([]string{e.Organizer}, e.Attendees...) {
+		if a == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(a); err != nil {
+			return fmt.Errorf("address %q: %w", a, err)
+		}
+	}
+	if e.UID == "" {
+		b := make([]byte, 12)
+		rand.Read(b)
+		e.UID = hex.EncodeToString(b) + "@tetora"
+	}
+	return nil
+}
+
+// Method returns REQUEST for an invitation that attendees answer to the
+// organizer, or PUBLISH for an event to add to a calendar.
+func (e Event) Method() string {
+	if e.Organizer != "" && len(e.Attendees) > 0 {
+		return "REQUEST"
+	}
+	return "PUBLISH"
+}
+
+// Marshal writes the event as a VCALENDAR. Times are written in UTC, so no
+// time zone definitions are needed.
+func (e *Event) Marshal(now time.Time) ([]byte, error) {
+	return e.marshal(now, e.Method())
+}
+
+// MarshalObject writes the event as a calendar object to store on a CalDAV
+// server, which must not name a method.
+func (e *Event) MarshalObject(now time.Time) ([]byte, error) {
+	return e.marshal(now, "")
+}
+
+func (e *Event) marshal(now time.Time, method string) ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	line := func(name, value string) { fold(&b, name+":"+value) }
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Tetora//Tetora//EN")
+	line("CALSCALE", "GREGORIAN")
+	if method != "" {
+		line("METHOD", method)
+	}
+	line("BEGIN", "VEVENT")
+	line("UID", e.UID)
+	line("DTSTAMP", utc(now))
+	if e.AllDay {
+		line("DTSTART;VALUE=DATE", e.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE", e.End.Format("20060102"))
+	} else {
+		line("DTSTART", utc(e.Start))
+		line("DTEND", utc(e.End))
+	}
+	line("SUMMARY", escape(e.Title))
+	if e.Description != "" {
+		line("DESCRIPTION", escape(e.Description))
+	}
+	if e.Location != "" {
+		line("LOCATION", escape(e.Location))
+	}
+	if e.URL != "" {
+		line("URL", e.URL)
+	}
+	if e.Organizer != "" {
+		line("ORGANIZER"+person(e.Organizer), "mailto:"+address(e.Organizer))
+	}
+	for _, a := range e.Attendees {
+		line("ATTENDEE"+person(a)+";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE", "mailto:"+address(a))
+	}
+	line("SEQUENCE", "0")
+	line("STATUS", "CONFIRMED")
+	line("TRANSP", "OPAQUE")
+	if e.Reminder > 0 {
+		line("BEGIN", "VALARM")
+		line("ACTION", "DISPLAY")
+		line("DESCRIPTION", escape(e.Title))
+		line("TRIGGER", fmt.Sprintf("-PT%dM", int(e.Reminder.Minutes())))
+		line("END", "VALARM")
+	}
+	line("END", "VEVENT")
+	line("END", "VCALENDAR")
+	return []byte(b.String()), nil
+}
+
+// ContentType returns the MIME type of a calendar file, with the method
+// mail clients need to show an invitation's accept and decline buttons.
+func ContentType(data []byte) string {
+	for _, l := range strings.Split(string(data), "\n") {
+		if m, ok := strings.CutPrefix(strings.TrimSpace(l), "METHOD:"); ok {
+			return "text/calendar; charset=utf-8; method=" + m
+		}
+	}
+	return "text/calendar; charset=utf-8"
+}
+
+func utc(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+func address(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.Address
+	}
+	return s
+}
+
+// person returns the CN parameter for an address with a display name.
+func person(s string) string {
+	a, err := mail.ParseAddress(s)
+	if err != nil || a.Name == "" {
+		return ""
+	}
+	return `;CN="` + strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(a.Name) + `"`
+}
+
+// fold writes a content line, folded at 75 octets without splitting a
+// UTF-8 sequence, and ends it with CRLF.
+func fold(b *strings.Builder, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // the leading space counts
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
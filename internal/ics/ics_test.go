@@ -0,0 +1,146 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	tz := time.FixedZone("JST", 9*3600)
+	e := Event{
+		UID:         "abc@tetora",
+		Title:       "Planning; Q4, budget",
+		Description: "Agenda:\n1. Review",
+		Location:    "Room 3",
+		Start:       time.Date(2026, 10, 20, 10, 0, 0, 0, tz),
+		Organizer:   "Owner <owner@example.com>",
+		Attendees:   []string{"Ann Lee <ann@example.com>", "bob@example.com"},
+		Reminder:    15 * time.Minute,
+	}
+	data, err := e.Marshal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.ReplaceAll(string(data), "\r\n ", "") // unfold
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"METHOD:REQUEST\r\n",
+		"UID:abc@tetora\r\n",
+		"DTSTAMP:20261015T000000Z\r\n",
+		"DTSTART:20261020T010000Z\r\n",
+		"DTEND:20261020T020000Z\r\n",
+		`SUMMARY:Planning\; Q4\, budget` + "\r\n",
+		`DESCRIPTION:Agenda:\n1. Review` + "\r\n",
+		`ORGANIZER;CN="Owner":mailto:owner@example.com` + "\r\n",
+		`ATTENDEE;CN="Ann Lee";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:ann@example.com` + "\r\n",
+		"RSVP=TRUE:mailto:bob@example.com\r\n",
+		"TRIGGER:-PT15M\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in:\n%s", want, s)
+		}
+	}
+	if got := ContentType(data); got != "text/calendar; charset=utf-8; method=REQUEST" {
+		t.Errorf("ContentType = %q", got)
+	}
+}
+
+func TestAllDayAndPublish(t *testing.T) {
+	e := Event{Title: "Offsite", Start: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), AllDay: true}
+	data, err := e.Marshal(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if !strings.Contains(s, "DTSTART;VALUE=DATE:20261102\r\n") || !strings.Contains(s, "DTEND;VALUE=DATE:20261103\r\n") {
+		t.Errorf("all-day dates wrong:\n%s", s)
+	}
+	if !strings.Contains(s, "METHOD:PUBLISH\r\n") || e.UID == "" {
+		t.Errorf("method or UID wrong:\n%s", s)
+	}
+	obj, err := e.MarshalObject(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(obj), "METHOD:") || !strings.Contains(string(obj), "UID:"+e.UID+"\r\n") {
+		t.Errorf("calendar object wrong:\n%s", obj)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	start := time.Now()
+	for _, e := range []Event{
+		{Start: start},
+		{Title: "x"},
+		{Title: "x", Start: start, End: start.Add(-time.Hour)},
+		{Title: "x", Start: start, Attendees: []string{"not an address"}},
+	} {
+		if err := e.Validate(); err == nil {
+			t.Errorf("%+v accepted", e)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	var b strings.Builder
+	fold(&b, "DESCRIPTION:"+strings.Repeat("日本", 40))
+	for _, l := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line of %d octets", len(l))
+		}
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if unfolded != "DESCRIPTION:"+strings.Repeat("日本", 40)+"\r\n" {
+		t.Errorf("unfolded = %q", unfolded)
+	}
+}
+
+func TestParse(t *testing.T) {
+	e := Event{
+		Title:       "Planning; Q4, budget with a title long enough to be folded across lines",
+		Description: "Agenda:\n1. Review",
+		Start:       time.Date(2026, 10, 20, 1, 0, 0, 0, time.UTC),
+		Organizer:   "Owner <owner@example.com>",
+		Attendees:   []string{"Bob Lee <bob@example.com>", "carol@example.com"},
+		Reminder:    15 * time.Minute,
+	}
+	data, err := e.Marshal(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("events = %+v", events)
+	}
+	got := events[0]
+	if got.Title != e.Title || got.Description != e.Description || got.UID != e.UID || !got.Start.Equal(e.Start) || !got.End.Equal(e.End) {
+		t.Errorf("round trip = %+v, want %+v", got, e)
+	}
+	if got.Organizer != `"Owner" <owner@example.com>` || len(got.Attendees) != 2 || got.Attendees[0] != `"Bob Lee" <bob@example.com>` || got.Attendees[1] != "carol@example.com" {
+		t.Errorf("participants = %q, %q", got.Organizer, got.Attendees)
+	}
+
+	feed := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261103\r\nDTEND;VALUE=DATE:20261104\r\nSUMMARY:Culture Day\r\nDESCRIPTION:Public holiday\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;TZID=Asia/Tokyo:20261224T180000\r\nSUMMARY:Party\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	events, err = Parse([]byte(feed))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Parse = %+v, %v", events, err)
+	}
+	if !events[0].AllDay || events[0].Start != time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC) || events[0].Title != "Culture Day" {
+		t.Errorf("all-day event = %+v", events[0])
+	}
+	if events[1].AllDay || !events[1].Start.Equal(time.Date(2026, 12, 24, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("zoned event = %+v", events[1])
+	}
+
+	if _, err := Parse([]byte("BEGIN:VEVENT\r\nSUMMARY:x\r\nEND:VEVENT\r\n")); err == nil {
+		t.Error("event without DTSTART accepted")
+	}
+}
@@ -0,0 +1,182 @@
+package ics
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Parse reads the events of a VCALENDAR. Only the fields Event holds are
+// read; recurrence rules are ignored, so a recurring event appears once.
+// Date-time values with a TZID are read in that zone when it is known and
+// in UTC otherwise. All-day dates are returned at midnight UTC.
+func Parse(data []byte) ([]Event, error) {
+	var (
+		events []Event
+		cur    *Event
+		depth  int // nesting inside the current VEVENT, e.g. VALARM
+	)
+	for i, l := range unfold(string(data)) {
+		name, params, value, ok := splitLine(l)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			if cur != nil {
+				return nil, fmt.Errorf("line %d: nested VEVENT", i+1)
+			}
+			cur = &Event{}
+			depth = 0
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", i+1, cur.Title)
+			}
+			if cur.End.IsZero() {
+				if cur.AllDay {
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				} else {
+					cur.End = cur.Start
+				}
+			}
+			events = append(events, *cur)
+			cur = nil
+			continue
+		case name == "END":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "SUMMARY":
+			cur.Title = unescape(value)
+		case "DESCRIPTION":
+			cur.Description = unescape(value)
+		case "LOCATION":
+			cur.Location = unescape(value)
+		case "URL":
+			cur.URL = value
+		case "ORGANIZER":
+			cur.Organizer = participant(value, params)
+		case "ATTENDEE":
+			cur.Attendees = append(cur.Attendees, participant(value, params))
+		case "DTSTART", "DTEND":
+			t, allDay, err := parseTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", i+1, name, err)
+			}
+			if name == "DTSTART" {
+				cur.Start, cur.AllDay = t, allDay
+			} else {
+				cur.End = t
+			}
+		}
+	}
+	if cur != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+	return events, nil
+}
+
+// unfold joins folded content lines.
+func unfold(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimRight(l, "\r"))
+	}
+	return lines
+}
+
+// splitLine splits "NAME;PARAM=V;...:value". Parameter values may be quoted
+// and contain colons.
+func splitLine(l string) (name string, params map[string]string, value string, ok bool) {
+	quoted := false
+	colon := -1
+	for i, c := range l {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	parts := strings.Split(l[:colon], ";")
+	params = map[string]string{}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, l[colon+1:], true
+}
+
+func parseTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := time.UTC
+	if tz := params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// participant returns an ORGANIZER or ATTENDEE as "Name <addr>", or the
+// bare address without a common name.
+func participant(value string, params map[string]string) string {
+	addr := value
+	if len(addr) >= 7 && strings.EqualFold(addr[:7], "mailto:") {
+		addr = addr[7:]
+	}
+	if cn := params["CN"]; cn != "" {
+		return (&mail.Address{Name: cn, Address: addr}).String()
+	}
+	return addr
+}
+
+// unescape reverses escape.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+// Package instancelock keeps two daemons from running against the same base
+// directory.
+//
+// The lock is an OS advisory lock on <baseDir>/tetora.lock (flock on Unix, an
+// exclusive share mode on Windows), so it is released automatically when the
+// holder exits or crashes. The file also records who holds it; a record left
+// behind by a dead process is stale and is simply overwritten.
+package instancelock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the lock file created in the base directory.
+const FileName = "tetora.lock"
+
+// Info describes the daemon holding the lock.
+type Info struct {
+	PID        int    `json:"pid"`
+	Hostname   string `json:"hostname,omitempty"`
+	ListenAddr string `json:"listenAddr,omitempty"`
+	StartedAt  string `json:"startedAt"` // RFC3339
+}
+
+// HeldError is returned by Acquire when another live process holds the lock.
+type HeldError struct {
+	Path   string
+	Holder Info // zero if the holder's record could not be read
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder.PID == 0 {
+		return fmt.Sprintf("another tetora daemon holds %s", e.Path)
+	}
+	msg := fmt.Sprintf("another tetora daemon (pid %d", e.Holder.PID)
+	if e.Holder.ListenAddr != "" {
+		msg += ", listening on " + e.Holder.ListenAddr
+	}
+	if e.Holder.StartedAt != "" {
+		msg += ", started " + e.Holder.StartedAt
+	}
+	return msg + ") is already running against " + filepath.Dir(e.Path)
+}
+
+// Lock is a held instance lock.
+type Lock struct {
+	f    *os.File
+	path string
+}
+
+// Path returns the lock file path for a base directory.
+func Path(baseDir string) string {
+	return filepath.Join(baseDir, FileName)
+}
+
+// Acquire takes the lock for baseDir and records info in it. It returns a
+// *HeldError when another process holds the lock. stale is the record left by
+// a previous holder that exited without releasing, if any.
+func Acquire(baseDir string, info Info) (l *Lock, stale *Info, err error) {
+	path := Path(baseDir)
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create base dir: %w", err)
+	}
+
+	f, err := openLocked(path)
+	if err == errHeld {
+		holder, _ := ReadInfo(path)
+		return nil, nil, &HeldError{Path: path, Holder: holder}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+
+	// We hold the OS lock, so any record already in the file is stale.
+	if prev, err := readInfoFrom(f); err == nil && prev.PID != 0 {
+		stale = &prev
+	}
+
+	if info.PID == 0 {
+		info.PID = os.Getpid()
+	}
+	if info.Hostname == "" {
+		info.Hostname, _ = os.Hostname()
+	}
+	if info.StartedAt == "" {
+		info.StartedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(info)
+	if err := f.Truncate(0); err == nil {
+		_, err = f.WriteAt(append(data, '\n'), 0)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("write lock record: %w", err)
+	}
+	f.Sync()
+
+	return &Lock{f: f, path: path}, stale, nil
+}
+
+// Release clears the record and drops the lock.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// ReadInfo reads the holder record from a lock file without taking the lock.
+func ReadInfo(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+	return readInfoFrom(f)
+}
+
+func readInfoFrom(f *os.File) (Info, error) {
+	var info Info
+	buf := make([]byte, 4096)
+	n, _ := f.ReadAt(buf, 0)
+	if n == 0 {
+		return info, fmt.Errorf("empty lock record")
+	}
+	if err := json.Unmarshal(buf[:n], &info); err != nil {
+		return info, fmt.Errorf("parse lock record: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,63 @@
+package instancelock
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAcquireExclusive(t *testing.T) {
+	dir := t.TempDir()
+
+	l, stale, err := Acquire(dir, Info{ListenAddr: "127.0.0.1:8991"})
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+	if stale != nil {
+		t.Errorf("fresh dir reported stale lock: %+v", stale)
+	}
+
+	_, _, err = Acquire(dir, Info{})
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second Acquire err = %v, want *HeldError", err)
+	}
+	if held.Holder.PID != os.Getpid() || held.Holder.ListenAddr != "127.0.0.1:8991" {
+		t.Errorf("holder = %+v", held.Holder)
+	}
+	if !strings.Contains(err.Error(), "127.0.0.1:8991") {
+		t.Errorf("error should name the holder's address: %v", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	l2, stale, err := Acquire(dir, Info{})
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	defer l2.Release()
+	if stale != nil {
+		t.Errorf("clean release left a stale record: %+v", stale)
+	}
+}
+
+func TestAcquireStaleRecord(t *testing.T) {
+	dir := t.TempDir()
+	// A daemon that crashed leaves its record behind but no OS lock.
+	os.WriteFile(Path(dir), []byte(`{"pid":999999,"startedAt":"2026-01-01T00:00:00Z"}`), 0o644)
+
+	l, stale, err := Acquire(dir, Info{})
+	if err != nil {
+		t.Fatalf("Acquire over stale record: %v", err)
+	}
+	defer l.Release()
+	if stale == nil || stale.PID != 999999 {
+		t.Errorf("stale = %+v, want pid 999999", stale)
+	}
+	info, err := ReadInfo(Path(dir))
+	if err != nil || info.PID != os.Getpid() {
+		t.Errorf("record after Acquire = %+v, %v", info, err)
+	}
+}
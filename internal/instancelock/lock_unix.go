@@ -0,0 +1,27 @@
+//go:build !windows
+
+package instancelock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errHeld = errors.New("lock held")
+
+// openLocked opens path and takes a non-blocking exclusive flock on it.
+func openLocked(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errHeld
+		}
+		return nil, err
+	}
+	return f, nil
+}
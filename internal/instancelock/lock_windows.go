@@ -0,0 +1,37 @@
+//go:build windows
+
+package instancelock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errHeld = errors.New("lock held")
+
+// ERROR_SHARING_VIOLATION: another process has the file open without write sharing.
+const errSharingViolation syscall.Errno = 32
+
+// openLocked opens path without write sharing, so a second daemon cannot open
+// it until the holder closes it. Readers (ReadInfo) are still allowed.
+func openLocked(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if errors.Is(err, errSharingViolation) {
+			return nil, errHeld
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
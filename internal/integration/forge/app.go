@@ -0,0 +1,126 @@
+package forge
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// appAuth mints installation access tokens for a GitHub App and reuses each
+// until shortly before it expires.
+type appAuth struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	base           string
+
+	mu      sync.Mutex
+	tok     string
+	expires time.Time
+}
+
+func newAppAuth(acct config.ForgeAccount, base string) (*appAuth, error) {
+	data, err := os.ReadFile(acct.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("github app private key: %w", err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &appAuth{appID: acct.AppID, installationID: acct.InstallationID, key: key, base: base}, nil
+}
+
+// parsePrivateKey reads the PEM key GitHub generates for an App (PKCS#1),
+// or a PKCS#8 RSA key.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("github app private key: no PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("github app private key: %w", err)
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns the App's JSON Web Token, valid for nine minutes. It is
+// backdated a minute against clock drift.
+func (a *appAuth) jwt(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": fmt.Sprint(a.appID),
+	})
+	signed := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// token returns an installation token, minting a new one when the current
+// one expires within five minutes.
+func (a *appAuth) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.tok != "" && time.Until(a.expires) > 5*time.Minute {
+		return a.tok, nil
+	}
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", a.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.base+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("github app token: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("github app token: status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var out struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil || out.Token == "" {
+		return "", fmt.Errorf("github app token: bad response")
+	}
+	a.tok, a.expires = out.Token, out.ExpiresAt
+	return a.tok, nil
+}
@@ -0,0 +1,417 @@
+// Package forge talks to code forges (GitHub, GitLab, and Gitea or Forgejo)
+// for the forge_* tools and turns their webhook deliveries into events.
+//
+// Each configured account gets a Client for its forge type, and Service
+// routes a repository to the account that lists it, so agents name a repo
+// and the right forge answers. Agents list, read and comment on issues and
+// pull requests (GitLab merge requests), read diffs and CI checks, and open
+// pull requests from files they wrote. Webhook deliveries become
+// "<type>:<event>.<action>" events, such as "github:pull_request.opened" or
+// "gitlab:pull_request.opened", for workflow triggers and proactive rules.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+)
+
+var (
+	// ErrUnknownAccount is returned for an account name that is not
+	// configured.
+	ErrUnknownAccount = errors.New("unknown forge account")
+	// ErrRepoNotAllowed is returned for a repository no account serves.
+	ErrRepoNotAllowed = errors.New("repository is not served by a forge account")
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Issue is an issue or a pull request (a GitLab merge request).
+type Issue struct {
+	Number    int      `json:"number"` // GitLab: the iid
+	Title     string   `json:"title"`
+	State     string   `json:"state"`
+	PR        bool     `json:"pullRequest,omitempty"`
+	Draft     bool     `json:"draft,omitempty"`
+	Author    string   `json:"author"`
+	Labels    []string `json:"labels,omitempty"`
+	Comments  int      `json:"comments"`
+	URL       string   `json:"url"`
+	Body      string   `json:"body,omitempty"`
+	Head      string   `json:"head,omitempty"` // pull requests: source branch
+	Base      string   `json:"base,omitempty"` // pull requests: target branch
+	HeadSHA   string   `json:"headSha,omitempty"`
+	Merged    bool     `json:"merged,omitempty"`
+	Additions int      `json:"additions,omitempty"`
+	Deletions int      `json:"deletions,omitempty"`
+	Files     int      `json:"changedFiles,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// Comment is a comment on an issue or pull request.
+type Comment struct {
+	ID        int64  `json:"id"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	URL       string `json:"url,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// CheckRun is one CI check or commit status on a commit.
+type CheckRun struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`               // queued, in_progress or completed
+	Conclusion string `json:"conclusion,omitempty"` // success, failure, neutral, cancelled, skipped, timed_out, action_required
+	URL        string `json:"url,omitempty"`
+	Summary    string `json:"summary,omitempty"`
+}
+
+// File is a file to commit with CreatePR.
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ListOptions filters ListIssues.
+type ListOptions struct {
+	State  string // "open" (default), "closed" or "all"
+	Kind   string // "issue", "pr" or "" for both
+	Labels []string
+	Limit  int
+}
+
+// LimitOrDefault returns the number of items to list (default 20).
+func (o ListOptions) LimitOrDefault() int {
+	if o.Limit > 0 {
+		return o.Limit
+	}
+	return 20
+}
+
+// PullRequest describes a pull request to open with CreatePR.
+type PullRequest struct {
+	Title   string
+	Body    string
+	Branch  string // head branch, created from Base when it does not exist
+	Base    string // default the repo's default branch
+	Message string // commit message for Files (default Title)
+	Files   []File // committed to Branch as one commit before opening
+	Draft   bool
+}
+
+// Client talks to one forge account.
+type Client interface {
+	// ListIssues lists issues and pull requests, most recently updated
+	// first, without their bodies.
+	ListIssues(ctx context.Context, repo string, opts ListOptions) ([]Issue, error)
+	// Issue returns an issue or pull request with its comments, oldest
+	// first. pr picks the pull request on forges that number issues and
+	// pull requests separately (GitLab); the others ignore it.
+	Issue(ctx context.Context, repo string, number int, pr bool) (*Issue, []Comment, error)
+	// Diff returns a pull request's unified diff.
+	Diff(ctx context.Context, repo string, number int) (string, error)
+	// Comment comments on an issue, or on a pull request when pr is set.
+	Comment(ctx context.Context, repo string, number int, pr bool, body string) (*Comment, error)
+	// Checks returns the CI checks of a commit SHA, branch or tag.
+	Checks(ctx context.Context, repo, ref string) ([]CheckRun, error)
+	// CreateBranch creates branch at the head of from (default the repo's
+	// default branch) and returns its commit SHA.
+	CreateBranch(ctx context.Context, repo, branch, from string) (string, error)
+	// CreatePR commits p.Files to p.Branch as one commit, creating the
+	// branch from p.Base when needed, and opens a pull request from it.
+	CreatePR(ctx context.Context, repo string, p PullRequest) (*Issue, error)
+}
+
+// NewClient returns the client for acct's forge.
+func NewClient(acct config.ForgeAccount) (Client, error) {
+	switch acct.Type {
+	case "github":
+		return newGitHub(acct)
+	case "gitlab":
+		if acct.Token == "" {
+			return nil, fmt.Errorf("gitlab account %q needs a token", acct.Name)
+		}
+		return newGitLab(acct), nil
+	case "gitea", "forgejo":
+		if acct.URL == "" || acct.Token == "" {
+			return nil, fmt.Errorf("gitea account %q needs url and token", acct.Name)
+		}
+		return newGitea(acct), nil
+	}
+	return nil, fmt.Errorf("forge account %q: unknown type %q (use \"github\", \"gitlab\" or \"gitea\")", acct.Name, acct.Type)
+}
+
+// --- Service ---
+
+// Service holds a client per account and routes repositories to them.
+type Service struct {
+	accounts []config.ForgeAccount
+
+	mu      sync.Mutex
+	clients map[string]Client
+}
+
+// New creates a service for the accounts.
+func New(accounts []config.ForgeAccount) *Service {
+	return &Service{accounts: accounts, clients: make(map[string]Client)}
+}
+
+// Accounts returns the configured accounts.
+func (s *Service) Accounts() []config.ForgeAccount { return s.accounts }
+
+// Account returns the account named name.
+func (s *Service) Account(name string) (config.ForgeAccount, bool) {
+	for _, a := range s.accounts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return config.ForgeAccount{}, false
+}
+
+// Client returns the client of the named account, created on first use.
+func (s *Service) Client(name string) (Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[name]; ok {
+		return c, nil
+	}
+	acct, ok := s.Account(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAccount, name)
+	}
+	c, err := NewClient(acct)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[name] = c
+	return c, nil
+}
+
+// Resolve picks the account and repository a request is for. A named
+// account must serve the repo, and defaults to the first repo it lists.
+// Otherwise the repo goes to the account that lists it; failing that, to
+// the only account that lists no repos, or the one whose host matches a
+// repo given as a URL. Without either, the first listed repo of any
+// account is used.
+func (s *Service) Resolve(account, repo string) (config.ForgeAccount, string, error) {
+	repo, host, err := normalizeRepo(repo)
+	if err != nil {
+		return config.ForgeAccount{}, "", err
+	}
+	if account != "" {
+		acct, ok := s.Account(account)
+		if !ok {
+			return acct, "", fmt.Errorf("%w: %q", ErrUnknownAccount, account)
+		}
+		if repo == "" {
+			if len(acct.Repos) == 0 {
+				return acct, "", fmt.Errorf("repo is required")
+			}
+			return acct, acct.Repos[0], nil
+		}
+		if !acct.RepoAllowed(repo) {
+			return acct, "", fmt.Errorf("%w: %s on %s", ErrRepoNotAllowed, repo, account)
+		}
+		return acct, repo, nil
+	}
+	if repo == "" {
+		for _, a := range s.accounts {
+			if len(a.Repos) > 0 {
+				return a, a.Repos[0], nil
+			}
+		}
+		return config.ForgeAccount{}, "", fmt.Errorf("repo is required")
+	}
+	for _, a := range s.accounts {
+		if a.Serves(repo) {
+			return a, repo, nil
+		}
+	}
+	var open []config.ForgeAccount
+	for _, a := range s.accounts {
+		if len(a.Repos) == 0 && (host == "" || strings.EqualFold(webHost(a), host)) {
+			open = append(open, a)
+		}
+	}
+	if len(open) == 1 {
+		return open[0], repo, nil
+	}
+	if len(open) > 1 {
+		return config.ForgeAccount{}, "", fmt.Errorf("%s could be on several forge accounts; name the account", repo)
+	}
+	return config.ForgeAccount{}, "", fmt.Errorf("%w: %s", ErrRepoNotAllowed, repo)
+}
+
+// Open resolves a request like Resolve and returns the account's client.
+func (s *Service) Open(account, repo string) (Client, config.ForgeAccount, string, error) {
+	acct, repo, err := s.Resolve(account, repo)
+	if err != nil {
+		return nil, acct, "", err
+	}
+	c, err := s.Client(acct.Name)
+	if err != nil {
+		return nil, acct, "", err
+	}
+	return c, acct, repo, nil
+}
+
+// normalizeRepo turns "owner/name", "group/sub/name" or a repository URL
+// into a repo path, and returns the URL's host when one was given.
+func normalizeRepo(repo string) (string, string, error) {
+	repo = strings.TrimSpace(repo)
+	var host string
+	if strings.Contains(repo, "://") {
+		u, err := url.Parse(repo)
+		if err != nil {
+			return "", "", fmt.Errorf("repo %q: %w", repo, err)
+		}
+		host, repo = u.Host, u.Path
+		if i := strings.Index(repo, "/-/"); i >= 0 { // GitLab pages below a project
+			repo = repo[:i]
+		}
+	}
+	repo = strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+	if repo == "" {
+		return "", host, nil
+	}
+	parts := strings.Split(repo, "/")
+	if len(parts) < 2 || slices.Contains(parts, "") || slices.Contains(parts, "..") {
+		return "", "", fmt.Errorf("repo %q: want owner/name", repo)
+	}
+	return repo, host, nil
+}
+
+// webHost returns the host of the account's web UI.
+func webHost(a config.ForgeAccount) string {
+	if a.URL == "" {
+		switch a.Type {
+		case "github":
+			return "github.com"
+		case "gitlab":
+			return "gitlab.com"
+		}
+		return ""
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// CutDiff cuts diff to at most limit bytes at a line boundary, and reports
+// whether it did.
+func CutDiff(diff string, limit int) (string, bool) {
+	if len(diff) <= limit {
+		return diff, false
+	}
+	cut := strings.LastIndexByte(diff[:limit], '\n')
+	if cut < 0 {
+		cut = limit
+	}
+	return diff[:cut], true
+}
+
+// --- HTTP ---
+
+// request describes one REST call made by a backend.
+type request struct {
+	method string
+	url    string
+	header map[string]string // auth and media type headers
+	in     any
+	out    any
+}
+
+// call sends req and decodes the JSON response into req.out, unless it is
+// nil, returning the raw body. Error responses carry the forge's message.
+func call(ctx context.Context, name string, req request) ([]byte, error) {
+	var body io.Reader
+	if req.in != nil {
+		data, err := json.Marshal(req.in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.method, req.url, body)
+	if err != nil {
+		return nil, err
+	}
+	hr.Header.Set("Accept", "application/json")
+	if req.in != nil {
+		hr.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.header {
+		hr.Header.Set(k, v)
+	}
+	path := hr.URL.EscapedPath()
+	resp, err := httpClient.Do(hr)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s %s: %w", name, req.method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Message any    `json:"message"`
+			Error   string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil {
+			if m := fmt.Sprint(e.Message); e.Message != nil && m != "" {
+				msg = m
+			} else if e.Error != "" {
+				msg = e.Error
+			}
+		}
+		return nil, &StatusError{Code: resp.StatusCode, Msg: fmt.Sprintf("%s %s %s: status %d: %s", name, req.method, path, resp.StatusCode, msg)}
+	}
+	if req.out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, req.out); err != nil {
+			return nil, fmt.Errorf("%s %s %s: %w", name, req.method, path, err)
+		}
+	}
+	return data, nil
+}
+
+// StatusError is an error response from a forge.
+type StatusError struct {
+	Code int
+	Msg  string
+}
+
+func (e *StatusError) Error() string { return e.Msg }
+
+// StatusCode returns the HTTP status, for integration health scoring.
+func (e *StatusError) StatusCode() int { return e.Code }
+
+// isNotFound reports whether err is a 404 from the forge.
+func isNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusNotFound
+}
+
+// checkPaths refuses file paths that are empty or leave the repository.
+func checkPaths(files []File) error {
+	for _, f := range files {
+		path := strings.TrimPrefix(f.Path, "/")
+		if path == "" || slices.Contains(strings.Split(path, "/"), "..") {
+			return fmt.Errorf("invalid file path %q", f.Path)
+		}
+	}
+	return nil
+}
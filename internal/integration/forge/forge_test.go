@@ -0,0 +1,466 @@
+package forge
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestServiceResolve(t *testing.T) {
+	s := New([]config.ForgeAccount{
+		{Name: "github", Type: "github", Repos: []string{"o/app"}},
+		{Name: "work", Type: "gitlab", URL: "https://git.example.com", Repos: []string{"team/infra/deploy"}},
+		{Name: "home", Type: "gitea", URL: "https://gitea.home.lan"},
+	})
+	tests := []struct {
+		account, repo     string
+		wantAcct, wantRep string
+		wantErr           error
+	}{
+		{"", "", "github", "o/app", nil},
+		{"", "O/App", "github", "O/App", nil},
+		{"", "team/infra/deploy", "work", "team/infra/deploy", nil},
+		{"", "https://git.example.com/team/infra/deploy/-/merge_requests/4", "work", "team/infra/deploy", nil},
+		{"", "me/dotfiles", "home", "me/dotfiles", nil},
+		{"", "https://gitea.home.lan/me/dotfiles.git", "home", "me/dotfiles", nil},
+		{"work", "", "work", "team/infra/deploy", nil},
+		{"work", "o/app", "", "", ErrRepoNotAllowed},
+		{"", "https://github.com/other/repo", "", "", ErrRepoNotAllowed},
+		{"nope", "o/app", "", "", ErrUnknownAccount},
+	}
+	for _, tt := range tests {
+		acct, repo, err := s.Resolve(tt.account, tt.repo)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resolve(%q, %q) err = %v, want %v", tt.account, tt.repo, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || acct.Name != tt.wantAcct || repo != tt.wantRep {
+			t.Errorf("Resolve(%q, %q) = %s %q %v, want %s %q", tt.account, tt.repo, acct.Name, repo, err, tt.wantAcct, tt.wantRep)
+		}
+	}
+	if _, _, err := s.Resolve("", "o/../x"); err == nil {
+		t.Error("repo with .. was accepted")
+	}
+
+	two := New([]config.ForgeAccount{{Name: "a", Type: "gitea", URL: "https://a"}, {Name: "b", Type: "gitea", URL: "https://b"}})
+	if _, _, err := two.Resolve("", "me/x"); err == nil {
+		t.Error("ambiguous repo was resolved")
+	}
+	if acct, _, err := two.Resolve("", "https://b/me/x"); err != nil || acct.Name != "b" {
+		t.Errorf("Resolve by host = %s, %v", acct.Name, err)
+	}
+}
+
+func TestClientIssuesAndPullRequests(t *testing.T) {
+	var comment map[string]string
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Bad credentials"}`))
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/o/r/issues":
+			if r.URL.Query().Get("state") != "open" {
+				t.Errorf("state = %q", r.URL.Query().Get("state"))
+			}
+			w.Write([]byte(`[
+				{"number": 2, "title": "Add X", "state": "open", "user": {"login": "octo"}, "pull_request": {}, "body": "long"},
+				{"number": 1, "title": "Bug", "state": "open", "user": {"login": "amy"}, "labels": [{"name": "bug"}]}]`))
+		case "GET /repos/o/r/issues/2":
+			w.Write([]byte(`{"number": 2, "title": "Add X", "state": "open", "user": {"login": "octo"}, "pull_request": {}, "body": "Adds X"}`))
+		case "GET /repos/o/r/pulls/2":
+			if r.Header.Get("Accept") == "application/vnd.github.diff" {
+				w.Write([]byte("diff --git a/a.go b/a.go\n+one\n+two\n+three\n"))
+				return
+			}
+			w.Write([]byte(`{"number": 2, "draft": true, "additions": 3, "changed_files": 1, "head": {"ref": "feat", "sha": "abc"}, "base": {"ref": "main"}}`))
+		case "GET /repos/o/r/issues/2/comments":
+			w.Write([]byte(`[{"id": 7, "body": "LGTM", "user": {"login": "amy"}}]`))
+		case "POST /repos/o/r/issues/2/comments":
+			json.NewDecoder(r.Body).Decode(&comment)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 8, "html_url": "https://github.com/o/r/pull/2#c8", "user": {"login": "bot"}}`))
+		case "GET /repos/o/r/commits/abc/check-runs":
+			w.Write([]byte(`{"check_runs": [{"name": "test", "status": "completed", "conclusion": "failure", "output": {"title": "2 failed"}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	defer srv.Close()
+
+	c, err := NewClient(config.ForgeAccount{Name: "github", Type: "github", Token: "tok", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	prs, err := c.ListIssues(ctx, "o/r", ListOptions{Kind: "pr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 || prs[0].Number != 2 || !prs[0].PR || prs[0].Body != "" {
+		t.Errorf("prs = %+v", prs)
+	}
+
+	is, comments, err := c.Issue(ctx, "o/r", 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !is.PR || !is.Draft || is.Head != "feat" || is.HeadSHA != "abc" || is.Base != "main" || is.Files != 1 || is.Body != "Adds X" {
+		t.Errorf("issue = %+v", is)
+	}
+	if len(comments) != 1 || comments[0].Author != "amy" {
+		t.Errorf("comments = %+v", comments)
+	}
+
+	diff, err := c.Diff(ctx, "o/r", 2)
+	if err != nil || !strings.HasPrefix(diff, "diff --git a/a.go b/a.go\n") {
+		t.Errorf("diff = %q, %v", diff, err)
+	}
+	if cut, truncated := CutDiff(diff, 30); !truncated || cut != "diff --git a/a.go b/a.go\n+one" {
+		t.Errorf("CutDiff = %q, %v", cut, truncated)
+	}
+
+	cm, err := c.Comment(ctx, "o/r", 2, true, "Looks good")
+	if err != nil || cm.ID != 8 || comment["body"] != "Looks good" {
+		t.Errorf("comment = %+v, %v, sent %v", cm, err, comment)
+	}
+
+	runs, err := c.Checks(ctx, "o/r", "abc")
+	if err != nil || len(runs) != 1 || runs[0].Conclusion != "failure" || runs[0].Summary != "2 failed" {
+		t.Errorf("runs = %+v, %v", runs, err)
+	}
+
+	bad, _ := NewClient(config.ForgeAccount{Name: "github", Type: "github", Token: "wrong", URL: srv.URL})
+	if _, err := bad.ListIssues(ctx, "o/r", ListOptions{}); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("bad token err = %v", err)
+	}
+}
+
+func TestCreatePRCommitsFilesToNewBranch(t *testing.T) {
+	var calls []string
+	var tree, pr map[string]any
+	var ref map[string]string
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/o/r":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		case "GET /repos/o/r/git/ref/heads/fix-typo":
+			http.NotFound(w, r)
+		case "GET /repos/o/r/git/ref/heads/main":
+			w.Write([]byte(`{"object": {"sha": "base1"}}`))
+		case "GET /repos/o/r/git/commits/base1":
+			w.Write([]byte(`{"tree": {"sha": "tree1"}}`))
+		case "POST /repos/o/r/git/trees":
+			json.NewDecoder(r.Body).Decode(&tree)
+			w.Write([]byte(`{"sha": "tree2"}`))
+		case "POST /repos/o/r/git/commits":
+			w.Write([]byte(`{"sha": "commit2"}`))
+		case "POST /repos/o/r/git/refs":
+			json.NewDecoder(r.Body).Decode(&ref)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case "POST /repos/o/r/pulls":
+			json.NewDecoder(r.Body).Decode(&pr)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 9, "title": "Fix typo", "state": "open", "html_url": "https://github.com/o/r/pull/9", "head": {"ref": "fix-typo", "sha": "commit2"}, "base": {"ref": "main"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	defer srv.Close()
+
+	c, _ := NewClient(config.ForgeAccount{Type: "github", Token: "tok", URL: srv.URL})
+	got, err := c.CreatePR(context.Background(), "o/r", PullRequest{
+		Title:  "Fix typo",
+		Branch: "fix-typo",
+		Files:  []File{{Path: "README.md", Content: "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v (calls %v)", err, calls)
+	}
+	if got.Number != 9 || !got.PR || got.Head != "fix-typo" {
+		t.Errorf("pr = %+v", got)
+	}
+	if tree["base_tree"] != "tree1" {
+		t.Errorf("tree = %v", tree)
+	}
+	if ref["ref"] != "refs/heads/fix-typo" || ref["sha"] != "commit2" {
+		t.Errorf("ref = %v", ref)
+	}
+	if pr["head"] != "fix-typo" || pr["base"] != "main" {
+		t.Errorf("pull = %v", pr)
+	}
+
+	if _, err := c.CreatePR(context.Background(), "o/r", PullRequest{Title: "x", Branch: "fix-typo", Base: "main", Files: []File{{Path: "../etc/passwd"}}}); err == nil {
+		t.Error("path outside the repo was accepted")
+	}
+}
+
+func TestAppInstallationToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600)
+
+	minted := 0
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			parts := strings.Split(jwt, ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if len(parts) != 3 || rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if !strings.Contains(string(claims), `"iss":"7"`) {
+				t.Errorf("claims = %s", claims)
+			}
+			minted++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_inst", "expires_at": "2099-01-01T00:00:00Z"}`))
+		case "/repos/o/r":
+			if r.Header.Get("Authorization") != "token ghs_inst" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"default_branch": "trunk"}`))
+		}
+	})))
+	defer srv.Close()
+
+	c, err := newGitHub(config.ForgeAccount{Type: "github", AppID: 7, InstallationID: 42, PrivateKeyFile: keyFile, URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if b, err := c.defaultBranch(context.Background(), "o/r"); err != nil || b != "trunk" {
+			t.Fatalf("DefaultBranch = %q, %v", b, err)
+		}
+	}
+	if minted != 1 {
+		t.Errorf("minted %d tokens, want 1", minted)
+	}
+
+	if _, err := NewClient(config.ForgeAccount{Type: "github", AppID: 7, PrivateKeyFile: keyFile}); err == nil {
+		t.Error("App without installationId was accepted")
+	}
+	for _, acct := range []config.ForgeAccount{{Type: "github"}, {Type: "gitlab"}, {Type: "gitea", Token: "t"}, {Type: "svn", Token: "t"}} {
+		if _, err := NewClient(acct); err == nil {
+			t.Errorf("account %+v was accepted", acct)
+		}
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	body := []byte(`{
+		"action": "opened",
+		"repository": {"full_name": "o/r"},
+		"sender": {"login": "octo"},
+		"pull_request": {"number": 5, "title": "Add X", "state": "open", "html_url": "https://github.com/o/r/pull/5",
+			"user": {"login": "octo"}, "body": "Adds X", "draft": false, "labels": [{"name": "ui"}, {"name": "p1"}],
+			"head": {"ref": "feat", "sha": "abc"}, "base": {"ref": "main"}}
+	}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !VerifySignature("s3cret", body, sig) {
+		t.Error("valid signature rejected")
+	}
+	if VerifySignature("other", body, sig) || VerifySignature("s3cret", body, "") || VerifySignature("", body, sig) {
+		t.Error("invalid signature accepted")
+	}
+
+	gh := config.ForgeAccount{Name: "github", Type: "github", WebhookSecret: "s3cret"}
+	h := http.Header{"X-Github-Event": {"pull_request"}, "X-Github-Delivery": {"d-1"}, "X-Hub-Signature-256": {sig}}
+	if !Verify(gh, h, body) {
+		t.Error("valid delivery rejected")
+	}
+	ev, err := ParseEvent(gh, h, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "github:pull_request.opened" || ev.Repo != "o/r" {
+		t.Errorf("event = %s %s", ev.Type, ev.Repo)
+	}
+	want := map[string]any{"number": 5, "title": "Add X", "author": "octo", "head": "feat", "base": "main", "labels": "ui,p1", "pr": true, "draft": false, "delivery": "d-1", "forge": "github"}
+	for k, v := range want {
+		if ev.Data[k] != v {
+			t.Errorf("data[%s] = %#v, want %#v", k, ev.Data[k], v)
+		}
+	}
+
+	ev, err = ParseEvent(gh, http.Header{"X-Github-Event": {"issue_comment"}}, []byte(`{"action": "created", "repository": {"full_name": "o/r"},
+		"issue": {"number": 3, "title": "Bug", "pull_request": {}, "user": {"login": "amy"}},
+		"comment": {"body": "/review please", "user": {"login": "amy"}}}`))
+	if err != nil || ev.Type != "github:issue_comment.created" || ev.Data["comment"] != "/review please" || ev.Data["pr"] != true {
+		t.Errorf("comment event = %+v, %v", ev, err)
+	}
+
+	push := http.Header{"X-Github-Event": {"push"}}
+	ev, _ = ParseEvent(gh, push, []byte(`{"ref": "refs/heads/main", "repository": {"full_name": "o/r"}, "commits": [{"message": "a"}, {"message": "b"}]}`))
+	if ev.Type != "github:push" || ev.Data["branch"] != "main" || ev.Data["commits"] != 2 || ev.Data["message"] != "b" {
+		t.Errorf("push event = %+v", ev)
+	}
+
+	if _, err := ParseEvent(gh, push, []byte("not json")); err == nil {
+		t.Error("bad payload accepted")
+	}
+}
+
+func TestGiteaClient(t *testing.T) {
+	var change map[string]any
+	var pull map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/repos/me/x/issues":
+			if r.URL.Query().Get("type") != "pulls" {
+				t.Errorf("type = %q", r.URL.Query().Get("type"))
+			}
+			w.Write([]byte(`[{"number": 4, "title": "Tidy", "state": "open", "user": {"login": "me"}, "pull_request": {"merged": false}}]`))
+		case "GET /api/v1/repos/me/x/pulls/4.diff":
+			w.Write([]byte("diff --git a/x b/x\n"))
+		case "GET /api/v1/repos/me/x/commits/main/status":
+			w.Write([]byte(`{"state": "pending", "statuses": [{"context": "ci/build", "status": "failure"}, {"context": "ci/lint", "status": "pending"}]}`))
+		case "GET /api/v1/repos/me/x":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		case "GET /api/v1/repos/me/x/contents/README.md":
+			w.Write([]byte(`{"sha": "blob1"}`))
+		case "POST /api/v1/repos/me/x/contents":
+			json.NewDecoder(r.Body).Decode(&change)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case "POST /api/v1/repos/me/x/pulls":
+			json.NewDecoder(r.Body).Decode(&pull)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 5, "title": "WIP: Docs", "state": "open", "head": {"ref": "docs", "sha": "c1"}, "base": {"ref": "main"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.ForgeAccount{Name: "home", Type: "gitea", URL: srv.URL, Token: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	prs, err := c.ListIssues(ctx, "me/x", ListOptions{Kind: "pr"})
+	if err != nil || len(prs) != 1 || !prs[0].PR || prs[0].Author != "me" {
+		t.Errorf("prs = %+v, %v", prs, err)
+	}
+	if diff, err := c.Diff(ctx, "me/x", 4); err != nil || diff != "diff --git a/x b/x\n" {
+		t.Errorf("diff = %q, %v", diff, err)
+	}
+	runs, err := c.Checks(ctx, "me/x", "main")
+	if err != nil || len(runs) != 2 || runs[0].Conclusion != "failure" || runs[1].Status != "in_progress" {
+		t.Errorf("checks = %+v, %v", runs, err)
+	}
+
+	pr, err := c.CreatePR(ctx, "me/x", PullRequest{Title: "Docs", Branch: "docs", Draft: true, Files: []File{{Path: "README.md", Content: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 5 || !pr.PR || !pr.Draft {
+		t.Errorf("pr = %+v", pr)
+	}
+	files, _ := change["files"].([]any)
+	if change["branch"] != "main" || change["new_branch"] != "docs" || len(files) != 1 {
+		t.Fatalf("change = %v", change)
+	}
+	if f := files[0].(map[string]any); f["operation"] != "update" || f["sha"] != "blob1" || f["content"] != "aGk=" {
+		t.Errorf("file = %v", f)
+	}
+	if pull["title"] != "WIP: Docs" || pull["base"] != "main" {
+		t.Errorf("pull = %v", pull)
+	}
+}
+
+func TestGiteaAndGitLabWebhooks(t *testing.T) {
+	gitea := config.ForgeAccount{Name: "home", Type: "gitea", WebhookSecret: "s3cret"}
+	body := []byte(`{"action": "synchronized", "number": 4, "repository": {"full_name": "me/x"}, "sender": {"login": "me"},
+		"pull_request": {"number": 4, "title": "Tidy", "state": "open", "user": {"login": "me"}, "head": {"ref": "tidy", "sha": "c2"}, "base": {"ref": "main"}}}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	h := http.Header{"X-Gitea-Event": {"pull_request"}, "X-Gitea-Signature": {hex.EncodeToString(mac.Sum(nil))}}
+	if !Verify(gitea, h, body) {
+		t.Error("valid gitea delivery rejected")
+	}
+	if Verify(gitea, h, append(body, ' ')) {
+		t.Error("tampered gitea delivery accepted")
+	}
+	ev, err := ParseEvent(gitea, h, body)
+	if err != nil || ev.Type != "gitea:pull_request.synchronized" || ev.Data["head_sha"] != "c2" || ev.Data["forge"] != "home" {
+		t.Errorf("gitea event = %+v, %v", ev, err)
+	}
+
+	gitlab := config.ForgeAccount{Name: "work", Type: "gitlab", WebhookSecret: "s3cret"}
+	if !Verify(gitlab, http.Header{"X-Gitlab-Token": {"s3cret"}}, nil) || Verify(gitlab, http.Header{"X-Gitlab-Token": {"nope"}}, nil) {
+		t.Error("gitlab token check is wrong")
+	}
+	ev, err = ParseEvent(gitlab, http.Header{"X-Gitlab-Event-Uuid": {"u-1"}}, []byte(`{
+		"object_kind": "merge_request",
+		"user": {"username": "amy"},
+		"project": {"path_with_namespace": "team/infra/deploy"},
+		"labels": [{"title": "ops"}],
+		"object_attributes": {"iid": 12, "title": "Bump", "description": "Bumps it", "state": "opened", "action": "open",
+			"url": "https://git.example.com/team/infra/deploy/-/merge_requests/12",
+			"source_branch": "bump", "target_branch": "main", "last_commit": {"id": "c3"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "gitlab:pull_request.opened" || ev.Repo != "team/infra/deploy" {
+		t.Errorf("gitlab event = %s %s", ev.Type, ev.Repo)
+	}
+	want := map[string]any{"number": 12, "pr": true, "head": "bump", "base": "main", "head_sha": "c3", "state": "open", "labels": "ops", "sender": "amy", "delivery": "u-1", "forge": "work"}
+	for k, v := range want {
+		if ev.Data[k] != v {
+			t.Errorf("data[%s] = %#v, want %#v", k, ev.Data[k], v)
+		}
+	}
+
+	ev, _ = ParseEvent(gitlab, http.Header{}, []byte(`{"object_kind": "note", "user": {"username": "amy"},
+		"project": {"path_with_namespace": "team/app"},
+		"object_attributes": {"note": "/review", "noteable_type": "MergeRequest"},
+		"merge_request": {"iid": 3, "title": "Fix", "state": "merged"}}`))
+	if ev.Type != "gitlab:issue_comment.created" || ev.Data["comment"] != "/review" || ev.Data["pr"] != true || ev.Data["number"] != 3 || ev.Data["state"] != "closed" {
+		t.Errorf("note event = %+v", ev)
+	}
+
+	ev, _ = ParseEvent(gitlab, http.Header{}, []byte(`{"object_kind": "pipeline", "project": {"path_with_namespace": "team/app"},
+		"object_attributes": {"id": 77, "ref": "main", "sha": "c4", "status": "failed"}}`))
+	if ev.Type != "gitlab:workflow_run.completed" || ev.Data["conclusion"] != "failure" || ev.Data["name"] != "pipeline 77" {
+		t.Errorf("pipeline event = %+v", ev)
+	}
+}
@@ -0,0 +1,248 @@
+package forge
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"tetora/internal/config"
+)
+
+// gitea is the Client for Gitea and Forgejo, whose REST API mirrors
+// GitHub's closely enough to share its issue and pull request shapes. It
+// signs in with an access token.
+type gitea struct {
+	acct config.ForgeAccount
+	base string
+}
+
+func newGitea(acct config.ForgeAccount) *gitea {
+	return &gitea{acct: acct, base: acct.APIURL()}
+}
+
+func (c *gitea) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
+	return call(ctx, "gitea", request{
+		method: method,
+		url:    c.base + path,
+		header: map[string]string{"Authorization": "token " + c.acct.Token},
+		in:     in,
+		out:    out,
+	})
+}
+
+func (c *gitea) ListIssues(ctx context.Context, repo string, opts ListOptions) ([]Issue, error) {
+	limit := opts.LimitOrDefault()
+	q := url.Values{}
+	q.Set("state", cmp.Or(opts.State, "open"))
+	q.Set("limit", strconv.Itoa(min(limit, 50)))
+	switch opts.Kind {
+	case "issue":
+		q.Set("type", "issues")
+	case "pr":
+		q.Set("type", "pulls")
+	}
+	if len(opts.Labels) > 0 {
+		q.Set("labels", strings.Join(opts.Labels, ","))
+	}
+	var raw []apiIssue
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/issues?"+q.Encode(), nil, &raw); err != nil {
+		return nil, err
+	}
+	out := []Issue{}
+	for _, a := range raw {
+		is := a.issue()
+		is.Body = ""
+		out = append(out, is)
+	}
+	return out, nil
+}
+
+func (c *gitea) Issue(ctx context.Context, repo string, number int, _ bool) (*Issue, []Comment, error) {
+	var raw apiIssue
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &raw); err != nil {
+		return nil, nil, err
+	}
+	is := raw.issue()
+	if is.PR {
+		var pr apiPull
+		if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+			return nil, nil, err
+		}
+		is.Merged = pr.Merged
+		is.Head, is.HeadSHA, is.Base = pr.Head.Ref, pr.Head.SHA, pr.Base.Ref
+		is.Additions, is.Deletions, is.Files = pr.Additions, pr.Deletions, pr.ChangedFiles
+	}
+	var raws []struct {
+		ID        int64   `json:"id"`
+		Body      string  `json:"body"`
+		HTMLURL   string  `json:"html_url"`
+		User      apiUser `json:"user"`
+		CreatedAt string  `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), nil, &raws); err != nil {
+		return nil, nil, err
+	}
+	comments := make([]Comment, 0, len(raws))
+	for _, r := range raws {
+		comments = append(comments, Comment{ID: r.ID, Author: r.User.Login, Body: r.Body, URL: r.HTMLURL, CreatedAt: r.CreatedAt})
+	}
+	return &is, comments, nil
+}
+
+func (c *gitea) Diff(ctx context.Context, repo string, number int) (string, error) {
+	data, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d.diff", repo, number), nil, nil)
+	return string(data), err
+}
+
+func (c *gitea) Comment(ctx context.Context, repo string, number int, _ bool, body string) (*Comment, error) {
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("comment body is empty")
+	}
+	var out struct {
+		ID        int64   `json:"id"`
+		HTMLURL   string  `json:"html_url"`
+		User      apiUser `json:"user"`
+		CreatedAt string  `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]string{"body": body}, &out); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: out.ID, Author: out.User.Login, Body: body, URL: out.HTMLURL, CreatedAt: out.CreatedAt}, nil
+}
+
+// Checks returns the commit statuses of ref, which Gitea Actions and
+// external CI both report.
+func (c *gitea) Checks(ctx context.Context, repo, ref string) ([]CheckRun, error) {
+	var out struct {
+		Statuses []struct {
+			Context     string `json:"context"`
+			Status      string `json:"status"`
+			TargetURL   string `json:"target_url"`
+			Description string `json:"description"`
+		} `json:"statuses"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/commits/"+url.PathEscape(ref)+"/status", nil, &out); err != nil {
+		return nil, err
+	}
+	runs := make([]CheckRun, 0, len(out.Statuses))
+	for _, s := range out.Statuses {
+		run := CheckRun{Name: s.Context, Status: "completed", URL: s.TargetURL, Summary: s.Description}
+		switch s.Status {
+		case "success":
+			run.Conclusion = "success"
+		case "failure", "error":
+			run.Conclusion = "failure"
+		case "warning":
+			run.Conclusion = "neutral"
+		default: // pending
+			run.Status = "in_progress"
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (c *gitea) defaultBranch(ctx context.Context, repo string) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo, nil, &out); err != nil {
+		return "", err
+	}
+	return out.DefaultBranch, nil
+}
+
+func (c *gitea) CreateBranch(ctx context.Context, repo, branch, from string) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("branch is required")
+	}
+	if from == "" {
+		var err error
+		if from, err = c.defaultBranch(ctx, repo); err != nil {
+			return "", err
+		}
+	}
+	var out struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/branches", map[string]string{"new_branch_name": branch, "old_branch_name": from}, &out); err != nil {
+		return "", err
+	}
+	return out.Commit.ID, nil
+}
+
+// CreatePR commits the files with the contents API, which creates the
+// branch from the base in the same call, and opens a pull request. Gitea
+// has no draft flag; drafts get the "WIP:" title prefix it recognises.
+func (c *gitea) CreatePR(ctx context.Context, repo string, p PullRequest) (*Issue, error) {
+	if p.Title == "" || p.Branch == "" {
+		return nil, fmt.Errorf("title and branch are required")
+	}
+	if err := checkPaths(p.Files); err != nil {
+		return nil, err
+	}
+	if p.Base == "" {
+		var err error
+		if p.Base, err = c.defaultBranch(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+	_, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/branches/"+url.PathEscape(p.Branch), nil, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	newBranch := err != nil
+	if len(p.Files) > 0 {
+		ref := p.Branch
+		if newBranch {
+			ref = p.Base
+		}
+		files := make([]map[string]string, 0, len(p.Files))
+		for _, f := range p.Files {
+			path := strings.TrimPrefix(f.Path, "/")
+			change := map[string]string{"operation": "create", "path": path, "content": base64.StdEncoding.EncodeToString([]byte(f.Content))}
+			var cur struct {
+				SHA string `json:"sha"`
+			}
+			_, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/contents/"+path+"?ref="+url.QueryEscape(ref), nil, &cur)
+			switch {
+			case err == nil:
+				change["operation"], change["sha"] = "update", cur.SHA
+			case !isNotFound(err):
+				return nil, err
+			}
+			files = append(files, change)
+		}
+		change := map[string]any{"message": cmp.Or(p.Message, p.Title), "files": files, "branch": p.Branch}
+		if newBranch {
+			change["branch"], change["new_branch"] = p.Base, p.Branch
+		}
+		if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/contents", change, nil); err != nil {
+			return nil, err
+		}
+	} else if newBranch {
+		if _, err := c.CreateBranch(ctx, repo, p.Branch, p.Base); err != nil {
+			return nil, err
+		}
+	}
+	title := p.Title
+	if p.Draft {
+		title = "WIP: " + title
+	}
+	var pr apiPull
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/pulls", map[string]any{
+		"title": title, "body": p.Body, "head": p.Branch, "base": p.Base,
+	}, &pr); err != nil {
+		return nil, err
+	}
+	is := pr.issue()
+	is.PR, is.Draft, is.Head, is.HeadSHA, is.Base = true, p.Draft, pr.Head.Ref, pr.Head.SHA, pr.Base.Ref
+	return &is, nil
+}
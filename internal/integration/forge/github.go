@@ -0,0 +1,349 @@
+package forge
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"tetora/internal/config"
+)
+
+// gitHub is the Client for GitHub and GitHub Enterprise Server. It signs
+// in with a personal access token, or as a GitHub App installation whose
+// short-lived tokens it mints and renews itself.
+type gitHub struct {
+	acct config.ForgeAccount
+	base string
+	app  *appAuth // nil when signing in with a token
+}
+
+func newGitHub(acct config.ForgeAccount) (*gitHub, error) {
+	c := &gitHub{acct: acct, base: acct.APIURL()}
+	switch {
+	case acct.AppID != 0:
+		if acct.InstallationID == 0 || acct.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("github app needs installationId and privateKeyFile")
+		}
+		app, err := newAppAuth(acct, c.base)
+		if err != nil {
+			return nil, err
+		}
+		c.app = app
+	case acct.Token == "":
+		return nil, fmt.Errorf("github needs a token or a GitHub App (appId)")
+	}
+	return c, nil
+}
+
+// do sends a request and decodes the JSON response into out, unless out is
+// nil. accept overrides the default JSON media type.
+func (c *gitHub) do(ctx context.Context, method, path string, in, out any, accept string) ([]byte, error) {
+	auth := "Bearer " + c.acct.Token
+	if c.app != nil {
+		tok, err := c.app.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		auth = "token " + tok
+	}
+	return call(ctx, "github", request{
+		method: method,
+		url:    c.base + path,
+		header: map[string]string{
+			"Authorization":        auth,
+			"Accept":               cmp.Or(accept, "application/vnd.github+json"),
+			"X-GitHub-Api-Version": "2022-11-28",
+		},
+		in:  in,
+		out: out,
+	})
+}
+
+type apiUser struct {
+	Login string `json:"login"`
+}
+
+type apiLabel struct {
+	Name string `json:"name"`
+}
+
+type apiIssue struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	State       string     `json:"state"`
+	Body        string     `json:"body"`
+	HTMLURL     string     `json:"html_url"`
+	User        apiUser    `json:"user"`
+	Labels      []apiLabel `json:"labels"`
+	Comments    int        `json:"comments"`
+	Draft       bool       `json:"draft"`
+	CreatedAt   string     `json:"created_at"`
+	UpdatedAt   string     `json:"updated_at"`
+	PullRequest *struct{}  `json:"pull_request"`
+}
+
+func (a apiIssue) issue() Issue {
+	is := Issue{
+		Number:    a.Number,
+		Title:     a.Title,
+		State:     a.State,
+		PR:        a.PullRequest != nil,
+		Draft:     a.Draft,
+		Author:    a.User.Login,
+		Comments:  a.Comments,
+		URL:       a.HTMLURL,
+		Body:      a.Body,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+	for _, l := range a.Labels {
+		is.Labels = append(is.Labels, l.Name)
+	}
+	return is
+}
+
+type apiPull struct {
+	apiIssue
+	Merged       bool `json:"merged"`
+	Additions    int  `json:"additions"`
+	Deletions    int  `json:"deletions"`
+	ChangedFiles int  `json:"changed_files"`
+	Head         struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (c *gitHub) ListIssues(ctx context.Context, repo string, opts ListOptions) ([]Issue, error) {
+	q := url.Values{}
+	q.Set("state", cmp.Or(opts.State, "open"))
+	q.Set("sort", "updated")
+	limit := opts.LimitOrDefault()
+	q.Set("per_page", strconv.Itoa(min(limit*2, 100)))
+	if len(opts.Labels) > 0 {
+		q.Set("labels", strings.Join(opts.Labels, ","))
+	}
+	var raw []apiIssue
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/issues?"+q.Encode(), nil, &raw, ""); err != nil {
+		return nil, err
+	}
+	out := []Issue{}
+	for _, a := range raw {
+		is := a.issue()
+		if (opts.Kind == "issue" && is.PR) || (opts.Kind == "pr" && !is.PR) {
+			continue
+		}
+		is.Body = ""
+		out = append(out, is)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Issue fetches pull request details too, since GitHub numbers issues and
+// pull requests together.
+func (c *gitHub) Issue(ctx context.Context, repo string, number int, _ bool) (*Issue, []Comment, error) {
+	var raw apiIssue
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &raw, ""); err != nil {
+		return nil, nil, err
+	}
+	is := raw.issue()
+	if is.PR {
+		var pr apiPull
+		if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr, ""); err != nil {
+			return nil, nil, err
+		}
+		is.Draft, is.Merged = pr.Draft, pr.Merged
+		is.Head, is.HeadSHA, is.Base = pr.Head.Ref, pr.Head.SHA, pr.Base.Ref
+		is.Additions, is.Deletions, is.Files = pr.Additions, pr.Deletions, pr.ChangedFiles
+	}
+	var raws []struct {
+		ID        int64   `json:"id"`
+		Body      string  `json:"body"`
+		HTMLURL   string  `json:"html_url"`
+		User      apiUser `json:"user"`
+		CreatedAt string  `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", repo, number), nil, &raws, ""); err != nil {
+		return nil, nil, err
+	}
+	comments := make([]Comment, 0, len(raws))
+	for _, r := range raws {
+		comments = append(comments, Comment{ID: r.ID, Author: r.User.Login, Body: r.Body, URL: r.HTMLURL, CreatedAt: r.CreatedAt})
+	}
+	return &is, comments, nil
+}
+
+func (c *gitHub) Diff(ctx context.Context, repo string, number int) (string, error) {
+	data, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, nil, "application/vnd.github.diff")
+	return string(data), err
+}
+
+func (c *gitHub) Comment(ctx context.Context, repo string, number int, _ bool, body string) (*Comment, error) {
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("comment body is empty")
+	}
+	var out struct {
+		ID        int64   `json:"id"`
+		HTMLURL   string  `json:"html_url"`
+		User      apiUser `json:"user"`
+		CreatedAt string  `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]string{"body": body}, &out, ""); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: out.ID, Author: out.User.Login, Body: body, URL: out.HTMLURL, CreatedAt: out.CreatedAt}, nil
+}
+
+func (c *gitHub) Checks(ctx context.Context, repo, ref string) ([]CheckRun, error) {
+	var out struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+			HTMLURL    string `json:"html_url"`
+			Output     struct {
+				Title   string `json:"title"`
+				Summary string `json:"summary"`
+			} `json:"output"`
+		} `json:"check_runs"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/commits/"+url.PathEscape(ref)+"/check-runs?per_page=100", nil, &out, ""); err != nil {
+		return nil, err
+	}
+	runs := make([]CheckRun, 0, len(out.CheckRuns))
+	for _, r := range out.CheckRuns {
+		summary := r.Output.Title
+		if summary == "" {
+			summary = r.Output.Summary
+		}
+		runs = append(runs, CheckRun{Name: r.Name, Status: r.Status, Conclusion: r.Conclusion, URL: r.HTMLURL, Summary: summary})
+	}
+	return runs, nil
+}
+
+func (c *gitHub) defaultBranch(ctx context.Context, repo string) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo, nil, &out, ""); err != nil {
+		return "", err
+	}
+	return out.DefaultBranch, nil
+}
+
+func (c *gitHub) branchSHA(ctx context.Context, repo, branch string) (string, error) {
+	var out struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/git/ref/heads/"+branch, nil, &out, ""); err != nil {
+		return "", err
+	}
+	return out.Object.SHA, nil
+}
+
+func (c *gitHub) CreateBranch(ctx context.Context, repo, branch, from string) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("branch is required")
+	}
+	if from == "" {
+		var err error
+		if from, err = c.defaultBranch(ctx, repo); err != nil {
+			return "", err
+		}
+	}
+	sha, err := c.branchSHA(ctx, repo, from)
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/git/refs", map[string]string{"ref": "refs/heads/" + branch, "sha": sha}, nil, ""); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// CreatePR commits through the git data API, so any number of files land
+// as one commit without a checkout.
+func (c *gitHub) CreatePR(ctx context.Context, repo string, p PullRequest) (*Issue, error) {
+	if p.Title == "" || p.Branch == "" {
+		return nil, fmt.Errorf("title and branch are required")
+	}
+	if err := checkPaths(p.Files); err != nil {
+		return nil, err
+	}
+	if p.Base == "" {
+		var err error
+		if p.Base, err = c.defaultBranch(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+	head, err := c.branchSHA(ctx, repo, p.Branch)
+	newBranch := err != nil
+	if newBranch {
+		if head, err = c.branchSHA(ctx, repo, p.Base); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.Files) > 0 {
+		if head, err = c.commitFiles(ctx, repo, head, cmp.Or(p.Message, p.Title), p.Files); err != nil {
+			return nil, err
+		}
+	}
+	if newBranch {
+		_, err = c.do(ctx, http.MethodPost, "/repos/"+repo+"/git/refs", map[string]string{"ref": "refs/heads/" + p.Branch, "sha": head}, nil, "")
+	} else if len(p.Files) > 0 {
+		_, err = c.do(ctx, http.MethodPatch, "/repos/"+repo+"/git/refs/heads/"+p.Branch, map[string]any{"sha": head}, nil, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pr apiPull
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/pulls", map[string]any{
+		"title": p.Title, "body": p.Body, "head": p.Branch, "base": p.Base, "draft": p.Draft,
+	}, &pr, ""); err != nil {
+		return nil, err
+	}
+	is := pr.issue()
+	is.PR, is.Head, is.HeadSHA, is.Base = true, pr.Head.Ref, pr.Head.SHA, pr.Base.Ref
+	return &is, nil
+}
+
+// commitFiles creates a commit on parent that writes files and returns its
+// SHA.
+func (c *gitHub) commitFiles(ctx context.Context, repo, parent, message string, files []File) (string, error) {
+	var commit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/git/commits/"+parent, nil, &commit, ""); err != nil {
+		return "", err
+	}
+	entries := make([]map[string]string, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, map[string]string{"path": strings.TrimPrefix(f.Path, "/"), "mode": "100644", "type": "blob", "content": f.Content})
+	}
+	var tree, created struct {
+		SHA string `json:"sha"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/git/trees", map[string]any{"base_tree": commit.Tree.SHA, "tree": entries}, &tree, ""); err != nil {
+		return "", err
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/git/commits", map[string]any{
+		"message": message, "tree": tree.SHA, "parents": []string{parent},
+	}, &created, ""); err != nil {
+		return "", err
+	}
+	return created.SHA, nil
+}
@@ -0,0 +1,368 @@
+package forge
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tetora/internal/config"
+)
+
+// gitLab is the Client for GitLab.com and self-managed GitLab. It signs in
+// with a personal, group or project access token. Merge requests are the
+// pull requests; they are numbered by iid, apart from issues.
+type gitLab struct {
+	acct config.ForgeAccount
+	base string
+}
+
+func newGitLab(acct config.ForgeAccount) *gitLab {
+	return &gitLab{acct: acct, base: acct.APIURL()}
+}
+
+// project returns the API path of repo, addressed by its escaped full path.
+func project(repo string) string {
+	return "/projects/" + url.PathEscape(repo)
+}
+
+func (c *gitLab) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
+	return call(ctx, "gitlab", request{
+		method: method,
+		url:    c.base + path,
+		header: map[string]string{"PRIVATE-TOKEN": c.acct.Token},
+		in:     in,
+		out:    out,
+	})
+}
+
+type glUser struct {
+	Username string `json:"username"`
+}
+
+type glIssue struct {
+	IID          int      `json:"iid"`
+	Title        string   `json:"title"`
+	State        string   `json:"state"` // opened, closed, merged or locked
+	Description  string   `json:"description"`
+	WebURL       string   `json:"web_url"`
+	Author       glUser   `json:"author"`
+	Labels       []string `json:"labels"`
+	Notes        int      `json:"user_notes_count"`
+	Draft        bool     `json:"draft"`
+	SourceBranch string   `json:"source_branch"`
+	TargetBranch string   `json:"target_branch"`
+	SHA          string   `json:"sha"`
+	ChangesCount string   `json:"changes_count"` // "1000+" past the limit
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+func (g glIssue) issue(pr bool) Issue {
+	is := Issue{
+		Number:    g.IID,
+		Title:     g.Title,
+		State:     g.State,
+		PR:        pr,
+		Draft:     g.Draft,
+		Author:    g.Author.Username,
+		Labels:    g.Labels,
+		Comments:  g.Notes,
+		URL:       g.WebURL,
+		Body:      g.Description,
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: g.UpdatedAt,
+	}
+	switch g.State {
+	case "opened":
+		is.State = "open"
+	case "merged":
+		is.State, is.Merged = "closed", true
+	case "locked":
+		is.State = "closed"
+	}
+	if pr {
+		is.Head, is.Base, is.HeadSHA = g.SourceBranch, g.TargetBranch, g.SHA
+		is.Files, _ = strconv.Atoi(strings.TrimSuffix(g.ChangesCount, "+"))
+	}
+	return is
+}
+
+func (c *gitLab) ListIssues(ctx context.Context, repo string, opts ListOptions) ([]Issue, error) {
+	limit := opts.LimitOrDefault()
+	q := url.Values{}
+	q.Set("order_by", "updated_at")
+	q.Set("per_page", strconv.Itoa(min(limit, 100)))
+	switch opts.State {
+	case "closed":
+		q.Set("state", "closed")
+	case "all":
+		q.Set("state", "all")
+	default:
+		q.Set("state", "opened")
+	}
+	if len(opts.Labels) > 0 {
+		q.Set("labels", strings.Join(opts.Labels, ","))
+	}
+	out := []Issue{}
+	if opts.Kind != "pr" {
+		var raw []glIssue
+		if _, err := c.do(ctx, http.MethodGet, project(repo)+"/issues?"+q.Encode(), nil, &raw); err != nil {
+			return nil, err
+		}
+		for _, g := range raw {
+			out = append(out, g.issue(false))
+		}
+	}
+	if opts.Kind != "issue" {
+		if opts.State == "closed" { // closed and merged merge requests
+			q.Set("state", "all")
+		}
+		var raw []glIssue
+		if _, err := c.do(ctx, http.MethodGet, project(repo)+"/merge_requests?"+q.Encode(), nil, &raw); err != nil {
+			return nil, err
+		}
+		for _, g := range raw {
+			if opts.State == "closed" && g.State == "opened" {
+				continue
+			}
+			out = append(out, g.issue(true))
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Issue) int { return strings.Compare(b.UpdatedAt, a.UpdatedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	for i := range out {
+		out[i].Body = ""
+	}
+	return out, nil
+}
+
+func kindPath(pr bool) string {
+	if pr {
+		return "/merge_requests/"
+	}
+	return "/issues/"
+}
+
+func (c *gitLab) Issue(ctx context.Context, repo string, number int, pr bool) (*Issue, []Comment, error) {
+	path := project(repo) + kindPath(pr) + strconv.Itoa(number)
+	var raw glIssue
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &raw); err != nil {
+		return nil, nil, err
+	}
+	is := raw.issue(pr)
+	var notes []struct {
+		ID        int64  `json:"id"`
+		Body      string `json:"body"`
+		Author    glUser `json:"author"`
+		System    bool   `json:"system"`
+		CreatedAt string `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, path+"/notes?sort=asc&order_by=created_at&per_page=100", nil, &notes); err != nil {
+		return nil, nil, err
+	}
+	comments := []Comment{}
+	for _, n := range notes {
+		if n.System { // "changed the description", "added 1 commit" and the like
+			continue
+		}
+		comments = append(comments, Comment{ID: n.ID, Author: n.Author.Username, Body: n.Body, URL: fmt.Sprintf("%s#note_%d", is.URL, n.ID), CreatedAt: n.CreatedAt})
+	}
+	return &is, comments, nil
+}
+
+// Diff assembles a unified diff from the merge request's per-file diffs.
+func (c *gitLab) Diff(ctx context.Context, repo string, number int) (string, error) {
+	var sb strings.Builder
+	for page := 1; page <= 10; page++ {
+		var files []struct {
+			OldPath     string `json:"old_path"`
+			NewPath     string `json:"new_path"`
+			NewFile     bool   `json:"new_file"`
+			DeletedFile bool   `json:"deleted_file"`
+			Diff        string `json:"diff"`
+		}
+		path := fmt.Sprintf("%s/merge_requests/%d/diffs?per_page=100&page=%d", project(repo), number, page)
+		if _, err := c.do(ctx, http.MethodGet, path, nil, &files); err != nil {
+			return "", err
+		}
+		for _, f := range files {
+			from, to := "a/"+f.OldPath, "b/"+f.NewPath
+			fmt.Fprintf(&sb, "diff --git %s %s\n", from, to)
+			switch {
+			case f.NewFile:
+				sb.WriteString("new file mode 100644\n")
+				from = "/dev/null"
+			case f.DeletedFile:
+				sb.WriteString("deleted file mode 100644\n")
+				to = "/dev/null"
+			}
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n%s", from, to, f.Diff)
+			if f.Diff != "" && !strings.HasSuffix(f.Diff, "\n") {
+				sb.WriteByte('\n')
+			}
+		}
+		if len(files) < 100 {
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+func (c *gitLab) Comment(ctx context.Context, repo string, number int, pr bool, body string) (*Comment, error) {
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("comment body is empty")
+	}
+	var out struct {
+		ID        int64  `json:"id"`
+		Author    glUser `json:"author"`
+		CreatedAt string `json:"created_at"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, project(repo)+kindPath(pr)+strconv.Itoa(number)+"/notes", map[string]string{"body": body}, &out); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: out.ID, Author: out.Author.Username, Body: body, CreatedAt: out.CreatedAt}, nil
+}
+
+// Checks returns the commit statuses of ref, which include every job of
+// its GitLab CI pipelines.
+func (c *gitLab) Checks(ctx context.Context, repo, ref string) ([]CheckRun, error) {
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, project(repo)+"/repository/commits/"+url.PathEscape(ref), nil, &commit); err != nil {
+		return nil, err
+	}
+	var statuses []struct {
+		Name        string `json:"name"`
+		Status      string `json:"status"`
+		TargetURL   string `json:"target_url"`
+		Description string `json:"description"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, project(repo)+"/repository/commits/"+commit.ID+"/statuses?all=true&per_page=100", nil, &statuses); err != nil {
+		return nil, err
+	}
+	runs := make([]CheckRun, 0, len(statuses))
+	for _, s := range statuses {
+		run := CheckRun{Name: s.Name, Status: "completed", URL: s.TargetURL, Summary: s.Description}
+		switch s.Status {
+		case "running":
+			run.Status = "in_progress"
+		case "success":
+			run.Conclusion = "success"
+		case "failed":
+			run.Conclusion = "failure"
+		case "canceled":
+			run.Conclusion = "cancelled"
+		case "skipped":
+			run.Conclusion = "skipped"
+		case "manual":
+			run.Conclusion = "action_required"
+		default: // created, pending, preparing, scheduled, waiting_for_resource
+			run.Status = "queued"
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (c *gitLab) defaultBranch(ctx context.Context, repo string) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, project(repo), nil, &out); err != nil {
+		return "", err
+	}
+	return out.DefaultBranch, nil
+}
+
+func (c *gitLab) CreateBranch(ctx context.Context, repo, branch, from string) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("branch is required")
+	}
+	if from == "" {
+		var err error
+		if from, err = c.defaultBranch(ctx, repo); err != nil {
+			return "", err
+		}
+	}
+	var out struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	q := url.Values{"branch": {branch}, "ref": {from}}
+	if _, err := c.do(ctx, http.MethodPost, project(repo)+"/repository/branches?"+q.Encode(), nil, &out); err != nil {
+		return "", err
+	}
+	return out.Commit.ID, nil
+}
+
+// CreatePR commits the files with the commits API, which creates the
+// branch from the base in the same call, and opens a merge request.
+func (c *gitLab) CreatePR(ctx context.Context, repo string, p PullRequest) (*Issue, error) {
+	if p.Title == "" || p.Branch == "" {
+		return nil, fmt.Errorf("title and branch are required")
+	}
+	if err := checkPaths(p.Files); err != nil {
+		return nil, err
+	}
+	if p.Base == "" {
+		var err error
+		if p.Base, err = c.defaultBranch(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+	_, err := c.do(ctx, http.MethodGet, project(repo)+"/repository/branches/"+url.PathEscape(p.Branch), nil, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	newBranch := err != nil
+	if len(p.Files) > 0 {
+		ref := p.Branch
+		if newBranch {
+			ref = p.Base
+		}
+		actions := make([]map[string]string, 0, len(p.Files))
+		for _, f := range p.Files {
+			path := strings.TrimPrefix(f.Path, "/")
+			action := "update"
+			_, err := c.do(ctx, http.MethodHead, project(repo)+"/repository/files/"+url.PathEscape(path)+"?ref="+url.QueryEscape(ref), nil, nil)
+			if isNotFound(err) {
+				action = "create"
+			} else if err != nil {
+				return nil, err
+			}
+			actions = append(actions, map[string]string{"action": action, "file_path": path, "content": f.Content})
+		}
+		commit := map[string]any{"branch": p.Branch, "commit_message": cmp.Or(p.Message, p.Title), "actions": actions}
+		if newBranch {
+			commit["start_branch"] = p.Base
+		}
+		if _, err := c.do(ctx, http.MethodPost, project(repo)+"/repository/commits", commit, nil); err != nil {
+			return nil, err
+		}
+	} else if newBranch {
+		if _, err := c.CreateBranch(ctx, repo, p.Branch, p.Base); err != nil {
+			return nil, err
+		}
+	}
+	title := p.Title
+	if p.Draft {
+		title = "Draft: " + title
+	}
+	var mr glIssue
+	if _, err := c.do(ctx, http.MethodPost, project(repo)+"/merge_requests", map[string]any{
+		"source_branch": p.Branch, "target_branch": p.Base, "title": title, "description": p.Body,
+	}, &mr); err != nil {
+		return nil, err
+	}
+	is := mr.issue(true)
+	return &is, nil
+}
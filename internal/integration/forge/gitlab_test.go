@@ -0,0 +1,137 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestGitLabClient(t *testing.T) {
+	var note map[string]string
+	var commit, mr map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "401 Unauthorized"}`))
+			return
+		}
+		// Projects are addressed by their escaped path.
+		path, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/v4/projects/team%2Fapp")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method + " " + path {
+		case "GET /issues":
+			w.Write([]byte(`[{"iid": 1, "title": "Bug", "state": "opened", "author": {"username": "amy"}, "labels": ["bug"], "updated_at": "2026-01-01T00:00:00Z"}]`))
+		case "GET /merge_requests":
+			w.Write([]byte(`[{"iid": 1, "title": "Fix", "state": "opened", "author": {"username": "bo"}, "source_branch": "fix", "updated_at": "2026-01-02T00:00:00Z"}]`))
+		case "GET /merge_requests/1":
+			w.Write([]byte(`{"iid": 1, "title": "Fix", "state": "merged", "description": "Fixes #1", "web_url": "https://gl/team/app/-/merge_requests/1",
+				"source_branch": "fix", "target_branch": "main", "sha": "c1", "changes_count": "2", "author": {"username": "bo"}}`))
+		case "GET /merge_requests/1/notes":
+			w.Write([]byte(`[{"id": 5, "body": "added 1 commit", "system": true}, {"id": 6, "body": "LGTM", "author": {"username": "amy"}}]`))
+		case "POST /merge_requests/1/notes":
+			json.NewDecoder(r.Body).Decode(&note)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 7, "author": {"username": "bot"}}`))
+		case "GET /merge_requests/1/diffs":
+			w.Write([]byte(`[{"old_path": "a.go", "new_path": "a.go", "diff": "@@ -1 +1 @@\n-x\n+y\n"},
+				{"old_path": "b.go", "new_path": "b.go", "new_file": true, "diff": "@@ -0,0 +1 @@\n+z"}]`))
+		case "GET /repository/commits/main":
+			w.Write([]byte(`{"id": "c9"}`))
+		case "GET /repository/commits/c9/statuses":
+			w.Write([]byte(`[{"name": "test", "status": "failed"}, {"name": "deploy", "status": "manual"}, {"name": "lint", "status": "running"}]`))
+		case "GET ":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		case "GET /repository/branches/docs", "HEAD /repository/files/NEW.md":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "404 Not Found"}`))
+		case "HEAD /repository/files/README.md":
+			if r.URL.Query().Get("ref") != "main" {
+				t.Errorf("file ref = %q", r.URL.Query().Get("ref"))
+			}
+		case "POST /repository/commits":
+			json.NewDecoder(r.Body).Decode(&commit)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "c10"}`))
+		case "POST /merge_requests":
+			json.NewDecoder(r.Body).Decode(&mr)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"iid": 2, "title": "Draft: Docs", "state": "opened", "draft": true, "source_branch": "docs", "target_branch": "main"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.ForgeAccount{Name: "work", Type: "gitlab", URL: srv.URL, Token: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	items, err := c.ListIssues(ctx, "team/app", ListOptions{})
+	if err != nil || len(items) != 2 || !items[0].PR || items[0].Head != "fix" || items[1].PR || items[1].State != "open" {
+		t.Errorf("items = %+v, %v", items, err)
+	}
+
+	is, comments, err := c.Issue(ctx, "team/app", 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !is.PR || !is.Merged || is.State != "closed" || is.HeadSHA != "c1" || is.Files != 2 {
+		t.Errorf("mr = %+v", is)
+	}
+	if len(comments) != 1 || comments[0].Body != "LGTM" || comments[0].URL != "https://gl/team/app/-/merge_requests/1#note_6" {
+		t.Errorf("comments = %+v", comments)
+	}
+
+	if cm, err := c.Comment(ctx, "team/app", 1, true, "Thanks"); err != nil || cm.ID != 7 || note["body"] != "Thanks" {
+		t.Errorf("comment = %+v, %v", cm, err)
+	}
+
+	diff, err := c.Diff(ctx, "team/app", 1)
+	want := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n" +
+		"diff --git a/b.go b/b.go\nnew file mode 100644\n--- /dev/null\n+++ b/b.go\n@@ -0,0 +1 @@\n+z\n"
+	if err != nil || diff != want {
+		t.Errorf("diff = %q, %v", diff, err)
+	}
+
+	runs, err := c.Checks(ctx, "team/app", "main")
+	if err != nil || len(runs) != 3 || runs[0].Conclusion != "failure" || runs[1].Conclusion != "action_required" || runs[2].Status != "in_progress" {
+		t.Errorf("checks = %+v, %v", runs, err)
+	}
+
+	pr, err := c.CreatePR(ctx, "team/app", PullRequest{Title: "Docs", Branch: "docs", Draft: true,
+		Files: []File{{Path: "README.md", Content: "a"}, {Path: "NEW.md", Content: "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 2 || !pr.PR || !pr.Draft {
+		t.Errorf("pr = %+v", pr)
+	}
+	actions, _ := commit["actions"].([]any)
+	if commit["branch"] != "docs" || commit["start_branch"] != "main" || len(actions) != 2 {
+		t.Fatalf("commit = %v", commit)
+	}
+	if a := actions[0].(map[string]any); a["action"] != "update" {
+		t.Errorf("README action = %v", a)
+	}
+	if a := actions[1].(map[string]any); a["action"] != "create" {
+		t.Errorf("NEW action = %v", a)
+	}
+	if mr["title"] != "Draft: Docs" || mr["source_branch"] != "docs" || mr["target_branch"] != "main" {
+		t.Errorf("merge request = %v", mr)
+	}
+
+	bad, _ := NewClient(config.ForgeAccount{Type: "gitlab", URL: srv.URL, Token: "wrong"})
+	if _, err := bad.ListIssues(ctx, "team/app", ListOptions{}); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("bad token err = %v", err)
+	}
+}
@@ -0,0 +1,489 @@
+package forge
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+)
+
+// maxEventText caps the bodies carried in event data.
+const maxEventText = 4000
+
+// VerifySignature checks a delivery's X-Hub-Signature-256 header against the
+// webhook secret.
+func VerifySignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if secret == "" || !ok {
+		return false
+	}
+	return hmac.Equal([]byte(hexMAC(secret, body)), []byte(sig))
+}
+
+func hexMAC(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery against the account's webhook secret: GitHub's
+// X-Hub-Signature-256, Gitea's X-Gitea-Signature (an unprefixed HMAC) or
+// GitLab's X-Gitlab-Token, which is the secret itself.
+func Verify(acct config.ForgeAccount, h http.Header, body []byte) bool {
+	secret := acct.WebhookSecret
+	if secret == "" {
+		return false
+	}
+	switch acct.Type {
+	case "gitlab":
+		return subtle.ConstantTimeCompare([]byte(h.Get("X-Gitlab-Token")), []byte(secret)) == 1
+	case "gitea", "forgejo":
+		sig := h.Get("X-Gitea-Signature")
+		if sig == "" {
+			sig = h.Get("X-Forgejo-Signature")
+		}
+		if sig != "" {
+			return hmac.Equal([]byte(hexMAC(secret, body)), []byte(sig))
+		}
+	}
+	return VerifySignature(secret, body, h.Get("X-Hub-Signature-256"))
+}
+
+// Event is a webhook delivery turned into an event.
+type Event struct {
+	Type string         // "<type>:<event>.<action>", or "<type>:<event>" without an action
+	Repo string         // "owner/name", GitLab "group/subgroup/name"
+	Data map[string]any // flat fields for workflow and proactive triggers
+}
+
+// Ping reports whether a delivery only tests the webhook.
+func Ping(h http.Header) bool {
+	return h.Get("X-GitHub-Event") == "ping"
+}
+
+// ParseEvent turns a delivery to acct into an Event typed by the account's
+// forge type, e.g. "gitlab:pull_request.opened". GitLab deliveries are
+// renamed to GitHub's vocabulary (merge requests become pull_request, notes
+// issue_comment, pipelines workflow_run), so one trigger reads the same on
+// every forge. The data always carries forge (the account name), event,
+// action, delivery, repo and sender; issues, pull requests, comments,
+// reviews, pushes, checks and releases add their own fields, such as
+// number, title, url, author, body, head and base.
+func ParseEvent(acct config.ForgeAccount, h http.Header, body []byte) (Event, error) {
+	var (
+		ev  Event
+		err error
+	)
+	switch acct.Type {
+	case "gitlab":
+		ev, err = parseGitLab(h.Get("X-Gitlab-Event-UUID"), body)
+	case "gitea", "forgejo":
+		event := h.Get("X-Gitea-Event")
+		if event == "" {
+			event = h.Get("X-Forgejo-Event")
+		}
+		ev, err = parseGitHub(event, h.Get("X-Gitea-Delivery"), body)
+	default:
+		ev, err = parseGitHub(h.Get("X-GitHub-Event"), h.Get("X-GitHub-Delivery"), body)
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("%s webhook: %w", acct.Type, err)
+	}
+	ev.Type = acct.Type + ":" + ev.Type
+	ev.Data["forge"] = acct.Name
+	return ev, nil
+}
+
+// fields builds event data, leaving out empty values.
+type fields map[string]any
+
+func (d fields) set(k string, v any) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+		d[k] = clip(v)
+	case int:
+		if v == 0 {
+			return
+		}
+		d[k] = v
+	default:
+		d[k] = v
+	}
+}
+
+// parseGitHub parses a delivery in GitHub's format, which Gitea shares.
+func parseGitHub(event, delivery string, body []byte) (Event, error) {
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Event{}, err
+	}
+	d := fields{
+		"event":    event,
+		"action":   p.Action,
+		"delivery": delivery,
+		"repo":     p.Repository.FullName,
+		"sender":   p.Sender.Login,
+	}
+	set := d.set
+
+	issue := p.Issue
+	if p.PullRequest != nil {
+		issue = &p.PullRequest.payloadIssue
+		set("pr", true)
+		set("draft", p.PullRequest.Draft)
+		set("merged", p.PullRequest.Merged)
+		set("head", p.PullRequest.Head.Ref)
+		set("head_sha", p.PullRequest.Head.SHA)
+		set("base", p.PullRequest.Base.Ref)
+	} else if issue != nil {
+		set("pr", issue.PullRequest != nil || p.IsPull)
+	}
+	if issue != nil {
+		set("number", issue.Number)
+		set("title", issue.Title)
+		set("url", issue.HTMLURL)
+		set("author", issue.User.Login)
+		set("state", issue.State)
+		set("body", issue.Body)
+		var labels []string
+		for _, l := range issue.Labels {
+			labels = append(labels, l.Name)
+		}
+		set("labels", strings.Join(labels, ","))
+	}
+	if c := p.Comment; c != nil {
+		set("comment", c.Body)
+		set("comment_author", c.User.Login)
+		set("comment_url", c.HTMLURL)
+	}
+	if r := p.Review; r != nil {
+		set("review_state", r.State)
+		set("review", r.Body)
+		set("review_author", r.User.Login)
+	}
+	switch event {
+	case "push":
+		set("ref", p.Ref)
+		set("branch", strings.TrimPrefix(p.Ref, "refs/heads/"))
+		set("before", p.Before)
+		set("after", p.After)
+		set("commits", len(p.Commits))
+		set("compare", p.Compare+p.CompareURL)
+		if n := len(p.Commits); n > 0 {
+			set("message", p.Commits[n-1].Message)
+		}
+	case "check_run", "check_suite", "workflow_run":
+		run := p.CheckRun
+		if run == nil {
+			run = p.CheckSuite
+		}
+		if run == nil {
+			run = p.WorkflowRun
+		}
+		if run != nil {
+			set("name", run.Name)
+			set("status", run.Status)
+			set("conclusion", run.Conclusion)
+			set("head_sha", run.HeadSHA)
+			set("head", run.HeadBranch)
+			set("url", run.HTMLURL)
+		}
+	case "release":
+		if r := p.Release; r != nil {
+			set("tag", r.TagName)
+			set("name", r.Name)
+			set("url", r.HTMLURL)
+			set("body", r.Body)
+		}
+	}
+
+	typ := event
+	if p.Action != "" {
+		typ += "." + p.Action
+	}
+	return Event{Type: typ, Repo: p.Repository.FullName, Data: d}, nil
+}
+
+func clip(s string) string {
+	if utf8.RuneCountInString(s) <= maxEventText {
+		return s
+	}
+	return string([]rune(s)[:maxEventText]) + "..."
+}
+
+type payloadIssue struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	State       string     `json:"state"`
+	Body        string     `json:"body"`
+	HTMLURL     string     `json:"html_url"`
+	User        apiUser    `json:"user"`
+	Labels      []apiLabel `json:"labels"`
+	PullRequest *struct{}  `json:"pull_request"`
+}
+
+type payloadRun struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HeadSHA    string `json:"head_sha"`
+	HeadBranch string `json:"head_branch"`
+	HTMLURL    string `json:"html_url"`
+}
+
+type payload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender      apiUser       `json:"sender"`
+	Issue       *payloadIssue `json:"issue"`
+	IsPull      bool          `json:"is_pull"` // Gitea: the comment is on a pull request
+	PullRequest *struct {
+		payloadIssue
+		Draft  bool `json:"draft"`
+		Merged bool `json:"merged"`
+		Head   struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Comment *struct {
+		Body    string  `json:"body"`
+		HTMLURL string  `json:"html_url"`
+		User    apiUser `json:"user"`
+	} `json:"comment"`
+	Review *struct {
+		State string  `json:"state"`
+		Body  string  `json:"body"`
+		User  apiUser `json:"user"`
+	} `json:"review"`
+	Ref        string `json:"ref"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Compare    string `json:"compare"`
+	CompareURL string `json:"compare_url"` // Gitea
+	Commits    []struct {
+		Message string `json:"message"`
+	} `json:"commits"`
+	CheckRun    *payloadRun `json:"check_run"`
+	CheckSuite  *payloadRun `json:"check_suite"`
+	WorkflowRun *payloadRun `json:"workflow_run"`
+	Release     *struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"release"`
+}
+
+// gitLabActions renames GitLab's object actions to GitHub's.
+var gitLabActions = map[string]string{
+	"open":   "opened",
+	"close":  "closed",
+	"reopen": "reopened",
+	"merge":  "closed",
+	"create": "created",
+	"update": "edited",
+	"delete": "deleted",
+}
+
+// parseGitLab parses a GitLab delivery by its object_kind.
+func parseGitLab(delivery string, body []byte) (Event, error) {
+	var p glPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Event{}, err
+	}
+	a := p.ObjectAttributes
+	event, action := p.ObjectKind, ""
+	d := fields{
+		"delivery": delivery,
+		"repo":     p.Project.PathWithNamespace,
+		"sender":   cmp.Or(p.User.Username, p.UserUsername),
+	}
+	set := d.set
+	labels := func(ls []glLabel) {
+		var names []string
+		for _, l := range ls {
+			names = append(names, l.Title)
+		}
+		set("labels", strings.Join(names, ","))
+	}
+	state := func(s string) {
+		switch s {
+		case "opened":
+			set("state", "open")
+		case "":
+		default:
+			set("state", "closed")
+		}
+	}
+	switch p.ObjectKind {
+	case "merge_request":
+		event = "pull_request"
+		action = cmp.Or(gitLabActions[a.Action], a.Action)
+		if a.Action == "update" && a.OldRev != "" {
+			action = "synchronize" // new commits pushed
+		}
+		set("pr", true)
+		set("draft", a.Draft)
+		set("merged", a.State == "merged")
+		set("head", a.SourceBranch)
+		set("head_sha", a.LastCommit.ID)
+		set("base", a.TargetBranch)
+		set("number", a.IID)
+		set("title", a.Title)
+		set("url", a.URL)
+		state(a.State)
+		set("body", a.Description)
+		labels(p.Labels)
+	case "issue", "work_item":
+		event = "issues"
+		action = cmp.Or(gitLabActions[a.Action], a.Action)
+		set("pr", false)
+		set("number", a.IID)
+		set("title", a.Title)
+		set("url", a.URL)
+		state(a.State)
+		set("body", a.Description)
+		labels(p.Labels)
+	case "note":
+		event, action = "issue_comment", "created"
+		set("comment", a.Note)
+		set("comment_author", p.User.Username)
+		set("comment_url", a.URL)
+		target := p.Issue
+		if a.NoteableType == "MergeRequest" {
+			target = p.MergeRequest
+			set("pr", true)
+		} else {
+			set("pr", false)
+		}
+		if target != nil {
+			set("number", target.IID)
+			set("title", target.Title)
+			set("url", target.URL)
+			state(target.State)
+			set("body", target.Description)
+			if target.SourceBranch != "" {
+				set("head", target.SourceBranch)
+				set("base", target.TargetBranch)
+			}
+		}
+	case "push", "tag_push":
+		event = "push"
+		set("ref", p.Ref)
+		set("branch", strings.TrimPrefix(p.Ref, "refs/heads/"))
+		set("before", p.Before)
+		set("after", p.After)
+		set("commits", p.TotalCommits)
+		if p.Project.WebURL != "" && p.Before != "" && p.After != "" {
+			set("compare", p.Project.WebURL+"/-/compare/"+p.Before+"..."+p.After)
+		}
+		if n := len(p.Commits); n > 0 {
+			set("message", p.Commits[n-1].Message)
+		}
+	case "pipeline":
+		event = "workflow_run"
+		action = "in_progress"
+		switch a.Status {
+		case "success", "failed", "canceled", "skipped":
+			action = "completed"
+			conclusion := map[string]string{"success": "success", "failed": "failure", "canceled": "cancelled", "skipped": "skipped"}[a.Status]
+			set("status", "completed")
+			set("conclusion", conclusion)
+		case "created", "pending", "waiting_for_resource", "preparing", "scheduled":
+			action = "requested"
+			set("status", "queued")
+		default:
+			set("status", "in_progress")
+		}
+		set("name", cmp.Or(a.Name, fmt.Sprintf("pipeline %d", a.ID)))
+		set("head_sha", a.SHA)
+		set("head", a.Ref)
+		set("url", a.URL)
+	case "release":
+		action = cmp.Or(gitLabActions[p.Action], p.Action)
+		set("tag", p.Tag)
+		set("name", p.Name)
+		set("url", p.URL)
+		set("body", p.Description)
+	}
+	d["event"], d["action"] = event, action
+	typ := event
+	if action != "" {
+		typ += "." + action
+	}
+	return Event{Type: typ, Repo: p.Project.PathWithNamespace, Data: d}, nil
+}
+
+type glLabel struct {
+	Title string `json:"title"`
+}
+
+type glObject struct {
+	ID           int    `json:"id"`
+	IID          int    `json:"iid"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	State        string `json:"state"`
+	Action       string `json:"action"`
+	URL          string `json:"url"`
+	Draft        bool   `json:"draft"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	OldRev       string `json:"oldrev"`
+	LastCommit   struct {
+		ID string `json:"id"`
+	} `json:"last_commit"`
+	Note         string `json:"note"`
+	NoteableType string `json:"noteable_type"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	Ref          string `json:"ref"`
+	SHA          string `json:"sha"`
+}
+
+type glPayload struct {
+	ObjectKind string `json:"object_kind"`
+	User       glUser `json:"user"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	ObjectAttributes glObject  `json:"object_attributes"`
+	Labels           []glLabel `json:"labels"`
+	Issue            *glObject `json:"issue"`
+	MergeRequest     *glObject `json:"merge_request"`
+
+	// push and tag_push
+	UserUsername string `json:"user_username"`
+	Ref          string `json:"ref"`
+	Before       string `json:"before"`
+	After        string `json:"after"`
+	TotalCommits int    `json:"total_commits_count"`
+	Commits      []struct {
+		Message string `json:"message"`
+	} `json:"commits"`
+
+	// release
+	Action      string `json:"action"`
+	Tag         string `json:"tag"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
@@ -0,0 +1,338 @@
+// Package health scores the integrations agents reach through tools and
+// takes one out of service for a while when it keeps failing.
+//
+// Every tool call to an integration is recorded as a success or a failure.
+// The score is the share of successes among the last Window calls. An
+// integration is disabled after FailThreshold failures in a row, or when
+// its score falls under MinScore over a full window. It comes back by
+// itself when its time out ends, on probation: one more failure disables
+// it again for twice as long, up to MaxDisableFor. A success clears the
+// record, and the owner can re-enable it early.
+package health
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/provider"
+)
+
+// Options tunes the Tracker. Zero values take the defaults of
+// config.IntegrationHealthConfig.
+type Options struct {
+	FailThreshold int
+	MinScore      int
+	Window        int
+	DisableFor    time.Duration
+	MaxDisableFor time.Duration
+}
+
+// OptionsFromConfig reads the Tracker options from the config.
+func OptionsFromConfig(c config.IntegrationHealthConfig) Options {
+	return Options{
+		FailThreshold: c.FailThreshold,
+		MinScore:      c.MinScore,
+		Window:        c.Window,
+		DisableFor:    c.DisableForOrDefault(),
+		MaxDisableFor: c.MaxDisableForOrDefault(),
+	}
+}
+
+func (o Options) withDefaults() Options {
+	if o.FailThreshold <= 0 {
+		o.FailThreshold = 5
+	}
+	if o.MinScore <= 0 {
+		o.MinScore = 25
+	}
+	if o.Window <= 0 {
+		o.Window = 20
+	}
+	if o.DisableFor <= 0 {
+		o.DisableFor = 15 * time.Minute
+	}
+	if o.MaxDisableFor <= 0 {
+		o.MaxDisableFor = 6 * time.Hour
+	}
+	o.MaxDisableFor = max(o.MaxDisableFor, o.DisableFor)
+	return o
+}
+
+// Status is an integration's health.
+type Status struct {
+	Name          string `json:"name"`
+	Score         int    `json:"score"`    // successes among the recent calls, 0-100
+	Calls         int    `json:"calls"`    // recent calls the score covers
+	Failures      int    `json:"failures"` // consecutive failures
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorAt   string `json:"lastErrorAt,omitempty"`
+	LastSuccessAt string `json:"lastSuccessAt,omitempty"`
+	Disabled      bool   `json:"disabled"`
+	DisabledUntil string `json:"disabledUntil,omitempty"`
+	Strikes       int    `json:"strikes,omitempty"` // times disabled since the last success
+}
+
+// Change reports an integration being disabled or coming back.
+type Change struct {
+	Status
+	// Reason says why: the failure that disabled it, or "expired" or
+	// "manual" when it comes back.
+	Reason string
+	// Token re-enables the integration through Enable before its time
+	// out ends. Set only when it is disabled.
+	Token string
+	// For is how long it is disabled.
+	For time.Duration
+}
+
+// Tracker records tool call outcomes per integration. It is safe for
+// concurrent use.
+type Tracker struct {
+	opts     Options
+	onChange func(Change)
+
+	mu sync.Mutex
+	m  map[string]*entry
+}
+
+type entry struct {
+	outcomes  []bool // most recent last, at most Window
+	failures  int
+	lastErr   string
+	lastErrAt time.Time
+	lastOKAt  time.Time
+	until     time.Time // zero while enabled
+	strikes   int
+	token     string
+	timer     *time.Timer
+}
+
+// New returns a Tracker. onChange, if set, is called outside the lock
+// whenever an integration is disabled or comes back.
+func New(opts Options, onChange func(Change)) *Tracker {
+	return &Tracker{opts: opts.withDefaults(), onChange: onChange, m: map[string]*entry{}}
+}
+
+func (t *Tracker) entry(name string) *entry {
+	e := t.m[name]
+	if e == nil {
+		e = &entry{}
+		t.m[name] = e
+	}
+	return e
+}
+
+// Record records the outcome of a call to an integration. Errors that do
+// not point at the integration itself (see Counts) are ignored, as are
+// calls while it is disabled.
+func (t *Tracker) Record(name string, err error) {
+	if t == nil || name == "" {
+		return
+	}
+	if err != nil && !Counts(err) {
+		return
+	}
+	t.mu.Lock()
+	e := t.entry(name)
+	if !e.until.IsZero() {
+		t.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	e.outcomes = append(e.outcomes, err == nil)
+	if len(e.outcomes) > t.opts.Window {
+		e.outcomes = e.outcomes[len(e.outcomes)-t.opts.Window:]
+	}
+	if err == nil {
+		e.failures, e.strikes, e.lastOKAt = 0, 0, now
+		t.mu.Unlock()
+		return
+	}
+	e.failures++
+	e.lastErr, e.lastErrAt = err.Error(), now
+	if e.failures < t.opts.FailThreshold && (len(e.outcomes) < t.opts.Window || score(e.outcomes) >= t.opts.MinScore) {
+		t.mu.Unlock()
+		return
+	}
+	d := t.opts.DisableFor
+	for i := 0; i < e.strikes && d < t.opts.MaxDisableFor; i++ {
+		d *= 2
+	}
+	d = min(d, t.opts.MaxDisableFor)
+	e.strikes++
+	e.until = now.Add(d)
+	e.token = newToken()
+	e.timer = time.AfterFunc(d, func() { t.expire(name) })
+	c := Change{Status: t.status(name, e), Reason: e.lastErr, Token: e.token, For: d}
+	t.mu.Unlock()
+	t.notify(c)
+}
+
+// expire brings an integration back on probation when its time out ends.
+func (t *Tracker) expire(name string) {
+	t.mu.Lock()
+	e := t.m[name]
+	if e == nil || e.until.IsZero() || time.Now().Before(e.until) {
+		t.mu.Unlock()
+		return
+	}
+	t.reset(e)
+	// On probation the next failure disables it again.
+	e.failures = t.opts.FailThreshold - 1
+	c := Change{Status: t.status(name, e), Reason: "expired"}
+	t.mu.Unlock()
+	t.notify(c)
+}
+
+// Enable brings a disabled integration back before its time out ends and
+// clears its record. A non-empty token must match the one its Change
+// carried; callers that have authenticated the owner pass "". It reports
+// whether the integration was disabled.
+func (t *Tracker) Enable(name, token string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	e := t.m[name]
+	if e == nil || e.until.IsZero() {
+		t.mu.Unlock()
+		return false
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(e.token)) != 1 {
+		t.mu.Unlock()
+		return false
+	}
+	t.reset(e)
+	e.failures, e.strikes = 0, 0
+	c := Change{Status: t.status(name, e), Reason: "manual"}
+	t.mu.Unlock()
+	t.notify(c)
+	return true
+}
+
+func (t *Tracker) reset(e *entry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.until, e.token, e.outcomes = time.Time{}, "", nil
+}
+
+// Disabled reports whether an integration is disabled, and until when.
+func (t *Tracker) Disabled(name string) (time.Time, bool) {
+	if t == nil {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.m[name]
+	if e == nil || e.until.IsZero() {
+		return time.Time{}, false
+	}
+	return e.until, true
+}
+
+// Statuses returns the health of every integration called so far, by name.
+func (t *Tracker) Statuses() []Status {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Status, 0, len(t.m))
+	for name, e := range t.m {
+		out = append(out, t.status(name, e))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (t *Tracker) status(name string, e *entry) Status {
+	s := Status{
+		Name:      name,
+		Score:     100,
+		Calls:     len(e.outcomes),
+		Failures:  e.failures,
+		LastError: e.lastErr,
+		Disabled:  !e.until.IsZero(),
+		Strikes:   e.strikes,
+	}
+	if len(e.outcomes) > 0 {
+		s.Score = score(e.outcomes)
+	}
+	if !e.lastErrAt.IsZero() {
+		s.LastErrorAt = e.lastErrAt.UTC().Format(time.RFC3339)
+	}
+	if !e.lastOKAt.IsZero() {
+		s.LastSuccessAt = e.lastOKAt.UTC().Format(time.RFC3339)
+	}
+	if s.Disabled {
+		s.DisabledUntil = e.until.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+func (t *Tracker) notify(c Change) {
+	if t.onChange != nil {
+		t.onChange(c)
+	}
+}
+
+func score(outcomes []bool) int {
+	ok := 0
+	for _, o := range outcomes {
+		if o {
+			ok++
+		}
+	}
+	return ok * 100 / len(outcomes)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// unavailable are error fragments that mean the service cannot be reached
+// or will not let Tetora in, on top of provider.IsTransientError's.
+var unavailable = []string{
+	"status 401", "status 403", "http 401", "http 403",
+	"unauthorized", "forbidden", "bad credentials", "invalid token", "token expired",
+	"no such host", "dial tcp", "tls:", "certificate",
+	"not running",
+}
+
+// Counts reports whether err points at the integration itself (down, slow,
+// rate limited or refusing Tetora's credentials) rather than at the call,
+// such as bad input or a missing issue. Only those count against its
+// score. Errors with a StatusCode method are judged by the HTTP status. A
+// cancelled call counts for neither.
+func Counts(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || provider.IsTransientError(err.Error()) {
+		return true
+	}
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) {
+		code := se.StatusCode()
+		return code >= 500 || code == 429 || code == 401 || code == 403
+	}
+	lower := strings.ToLower(err.Error())
+	for _, s := range unavailable {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("request failed (%d)", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestCounts(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("number is required"), false},
+		{errors.New("issue not found"), false},
+		{context.Canceled, false},
+		{fmt.Errorf("fetch: %w", context.DeadlineExceeded), true},
+		{errors.New("dial tcp 10.0.0.1:443: connect: connection refused"), true},
+		{errors.New("twitter timeline (status 503): down"), true},
+		{errors.New("mastodon /statuses: status 401: The access token is invalid"), true},
+		{errors.New("forges are not running"), true},
+		{statusErr(404), false},
+		{statusErr(422), false},
+		{fmt.Errorf("gitlab: %w", statusErr(502)), true},
+		{statusErr(403), true},
+	} {
+		if got := Counts(tc.err); got != tc.want {
+			t.Errorf("Counts(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+// recorder collects the changes a Tracker reports.
+type recorder struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+func (r *recorder) add(c Change) {
+	r.mu.Lock()
+	r.changes = append(r.changes, c)
+	r.mu.Unlock()
+}
+
+func (r *recorder) get() []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Change(nil), r.changes...)
+}
+
+func TestTrackerConsecutiveFailures(t *testing.T) {
+	var rec recorder
+	tr := New(Options{FailThreshold: 3, DisableFor: time.Hour}, rec.add)
+	down := errors.New("connection refused")
+
+	tr.Record("mail", down)
+	tr.Record("mail", down)
+	tr.Record("mail", nil) // a success resets the streak
+	tr.Record("mail", down)
+	tr.Record("mail", errors.New("invalid input")) // not the service's fault
+	tr.Record("mail", down)
+	if _, off := tr.Disabled("mail"); off {
+		t.Fatal("disabled before the threshold")
+	}
+	tr.Record("mail", down)
+	until, off := tr.Disabled("mail")
+	if !off || time.Until(until) < 59*time.Minute {
+		t.Fatalf("Disabled = %v, %v", until, off)
+	}
+	changes := rec.get()
+	if len(changes) != 1 || !changes[0].Disabled || changes[0].Token == "" || changes[0].For != time.Hour || changes[0].Reason != "connection refused" {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if s := tr.Statuses(); len(s) != 1 || s[0].Score != 16 || s[0].Failures != 3 || s[0].Strikes != 1 {
+		t.Errorf("status = %+v", s)
+	}
+
+	// Calls while disabled are not recorded.
+	tr.Record("mail", nil)
+	if _, off := tr.Disabled("mail"); !off {
+		t.Error("success recorded while disabled")
+	}
+
+	if tr.Enable("mail", "wrong") {
+		t.Error("enabled with a wrong token")
+	}
+	if !tr.Enable("mail", changes[0].Token) {
+		t.Fatal("enable with token failed")
+	}
+	if _, off := tr.Disabled("mail"); off {
+		t.Error("still disabled")
+	}
+	if tr.Enable("mail", "") {
+		t.Error("enabled twice")
+	}
+	if changes := rec.get(); len(changes) != 2 || changes[1].Disabled || changes[1].Reason != "manual" {
+		t.Errorf("changes = %+v", changes)
+	}
+}
+
+func TestTrackerLowScore(t *testing.T) {
+	tr := New(Options{FailThreshold: 10, MinScore: 50, Window: 4}, nil)
+	down := errors.New("status 500")
+	for _, err := range []error{nil, down, nil, down} {
+		tr.Record("calendar", err)
+	}
+	if _, off := tr.Disabled("calendar"); off {
+		t.Fatal("disabled at score 50")
+	}
+	tr.Record("calendar", down) // window: down, nil, down, down
+	if _, off := tr.Disabled("calendar"); !off {
+		t.Fatalf("not disabled: %+v", tr.Statuses())
+	}
+}
+
+func TestTrackerProbation(t *testing.T) {
+	var rec recorder
+	tr := New(Options{FailThreshold: 2, DisableFor: 20 * time.Millisecond, MaxDisableFor: 30 * time.Millisecond}, rec.add)
+	down := errors.New("timeout")
+	tr.Record("forges", down)
+	tr.Record("forges", down)
+	wait := func(n int) []Change {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if c := rec.get(); len(c) >= n {
+				return c
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("changes = %+v, want %d", rec.get(), n)
+		return nil
+	}
+	changes := wait(2)
+	if changes[1].Disabled || changes[1].Reason != "expired" {
+		t.Fatalf("changes = %+v", changes)
+	}
+
+	// One failure on probation disables it again, for longer (capped).
+	tr.Record("forges", down)
+	changes = wait(3)
+	if !changes[2].Disabled || changes[2].For != 30*time.Millisecond || changes[2].Strikes != 2 {
+		t.Fatalf("changes = %+v", changes)
+	}
+	wait(4)
+
+	// A success ends probation.
+	tr.Record("forges", nil)
+	tr.Record("forges", down)
+	if _, off := tr.Disabled("forges"); off {
+		t.Error("disabled after one failure past probation")
+	}
+}
@@ -0,0 +1,261 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/mailin"
+)
+
+// maxBodyChars bounds the message body returned to agents.
+const maxBodyChars = 20000
+
+// session signs in to the account's IMAP server and opens its mailbox
+// read-only, so nothing read through it is marked seen.
+func (s *Service) session(ctx context.Context, a config.MailAccount) (*mailin.IMAPConn, func(), error) {
+	conn, err := s.dialIMAP(ctx, a.IMAP)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	} else {
+		conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	}
+	c, err := mailin.NewIMAPConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	closeFn := func() {
+		c.Cmd("LOGOUT")
+		conn.Close()
+	}
+	if a.OAuthService != "" {
+		tok, err := s.oauthToken(a)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		ir := base64.StdEncoding.EncodeToString(xoauth2(a.UsernameOrDefault(), tok))
+		if _, err := c.Cmd("AUTHENTICATE XOAUTH2 " + ir); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("imap login: %w", err)
+		}
+	} else if _, err := c.Cmd("LOGIN " + mailin.IMAPQuote(a.UsernameOrDefault()) + " " + mailin.IMAPQuote(a.Password)); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("imap login: %w", err)
+	}
+	if _, err := c.Cmd("EXAMINE " + mailin.IMAPQuote(a.MailboxOrDefault())); err != nil {
+		closeFn()
+		return nil, nil, fmt.Errorf("imap examine: %w", err)
+	}
+	return c, closeFn, nil
+}
+
+func (s *Service) oauthToken(a config.MailAccount) (string, error) {
+	if s.token == nil {
+		return "", fmt.Errorf("mail account %q: oauth is not available", a.Name)
+	}
+	tok, err := s.token(a.OAuthService)
+	if err != nil {
+		return "", fmt.Errorf("mail account %q: %w", a.Name, err)
+	}
+	return tok, nil
+}
+
+// xoauth2 returns the SASL XOAUTH2 initial response, before encoding.
+func xoauth2(user, token string) []byte {
+	return []byte("user=" + user + "\x01auth=Bearer " + token + "\x01\x01")
+}
+
+// search returns how many messages in the account match q and the newest
+// q.Max of them.
+func (s *Service) search(ctx context.Context, a config.MailAccount, q Query) (int, []Summary, error) {
+	c, closeFn, err := s.session(ctx, a)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer closeFn()
+
+	criteria, lit := searchCriteria(q)
+	resps, err := c.Cmd("UID SEARCH "+criteria, lit...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("imap search: %w", err)
+	}
+	var uids []int
+	for _, r := range resps {
+		if rest, ok := strings.CutPrefix(r.Line, "* SEARCH"); ok {
+			for _, f := range strings.Fields(rest) {
+				if n, err := strconv.Atoi(f); err == nil {
+					uids = append(uids, n)
+				}
+			}
+		}
+	}
+	total := len(uids)
+	if total == 0 {
+		return 0, nil, nil
+	}
+	// UIDs grow with arrival, so the highest are the newest.
+	sort.Ints(uids)
+	if len(uids) > q.Max {
+		uids = uids[len(uids)-q.Max:]
+	}
+	set := make([]string, len(uids))
+	for i, u := range uids {
+		set[i] = strconv.Itoa(u)
+	}
+	item := "BODY.PEEK[HEADER.FIELDS (FROM SUBJECT DATE)]"
+	if q.Snippets {
+		item = "BODY.PEEK[]"
+	}
+	resps, err = c.Cmd("UID FETCH " + strings.Join(set, ",") + " (UID FLAGS INTERNALDATE " + item + ")")
+	if err != nil {
+		return 0, nil, fmt.Errorf("imap fetch: %w", err)
+	}
+	var out []Summary
+	for _, r := range resps {
+		if !strings.Contains(r.Line, " FETCH ") || len(r.Lits) == 0 {
+			continue
+		}
+		sum, err := summarize(a.Name, r, q.Snippets)
+		if err != nil {
+			continue
+		}
+		out = append(out, sum.Summary)
+	}
+	sortNewest(out)
+	return total, out, nil
+}
+
+// searchCriteria builds the UID SEARCH arguments for q. Non-ASCII text is
+// sent as a UTF-8 literal.
+func searchCriteria(q Query) (string, [][]byte) {
+	var parts []string
+	var lit [][]byte
+	charset := ""
+	if q.Unread {
+		parts = append(parts, "UNSEEN")
+	}
+	if !q.Since.IsZero() {
+		parts = append(parts, "SINCE "+q.Since.Format("2-Jan-2006"))
+	}
+	if t := strings.TrimSpace(q.Text); t != "" {
+		if isASCII(t) {
+			parts = append(parts, "TEXT "+mailin.IMAPQuote(t))
+		} else {
+			charset = "CHARSET UTF-8 "
+			parts = append(parts, fmt.Sprintf("TEXT {%d}", len(t)))
+			lit = append(lit, []byte(t))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "ALL")
+	}
+	return charset + strings.Join(parts, " "), lit
+}
+
+// read fetches one message in full.
+func (s *Service) read(ctx context.Context, a config.MailAccount, id string) (*Message, error) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, fmt.Errorf("invalid message id %q", id)
+	}
+	c, closeFn, err := s.session(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	resps, err := c.Cmd("UID FETCH " + id + " (UID FLAGS INTERNALDATE BODY.PEEK[])")
+	if err != nil {
+		return nil, fmt.Errorf("imap fetch %s: %w", id, err)
+	}
+	for _, r := range resps {
+		if strings.Contains(r.Line, " FETCH ") && len(r.Lits) > 0 {
+			return summarize(a.Name, r, true)
+		}
+	}
+	return nil, fmt.Errorf("message %s not found in %s", id, a.Name)
+}
+
+var (
+	uidRe   = regexp.MustCompile(`\bUID (\d+)`)
+	flagsRe = regexp.MustCompile(`\bFLAGS \(([^)]*)\)`)
+	idateRe = regexp.MustCompile(`\bINTERNALDATE "([^"]+)"`)
+)
+
+// summarize turns a FETCH response into a message. With full set the
+// literal is the whole message; otherwise it holds only headers.
+func summarize(account string, r mailin.IMAPResp, full bool) (*Message, error) {
+	raw := r.Lits[len(r.Lits)-1]
+	m := &Message{Summary: Summary{Account: account, Unread: true}}
+	if u := uidRe.FindStringSubmatch(r.Line); u != nil {
+		m.ID = u[1]
+	}
+	if f := flagsRe.FindStringSubmatch(r.Line); f != nil {
+		m.Unread = !strings.Contains(f[1], `\Seen`)
+	}
+	if d := idateRe.FindStringSubmatch(r.Line); d != nil {
+		if t, err := time.Parse("2-Jan-2006 15:04:05 -0700", strings.TrimSpace(d[1])); err == nil {
+			m.date = t
+		}
+	}
+	if !full {
+		// Headers only: make it parse as a message with an empty body.
+		raw = append(append([]byte{}, raw...), "\r\n"...)
+	}
+	p, err := mailin.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	m.From = p.From
+	if p.FromName != "" {
+		m.From = p.FromName + " <" + p.From + ">"
+	}
+	m.Subject = p.Subject
+	if m.date.IsZero() {
+		m.date = p.Date
+	}
+	if !m.date.IsZero() {
+		m.Date = m.date.Format(time.RFC3339)
+	}
+	if full {
+		m.To = p.To
+		m.MessageID = p.MessageID
+		m.Body = clip(p.Text, maxBodyChars)
+		m.Snippet = snippet(p.Text)
+		for _, at := range p.Attachments {
+			m.Attachments = append(m.Attachments, at.Filename)
+		}
+	}
+	return m, nil
+}
+
+// snippet returns the start of a body on one line.
+func snippet(text string) string {
+	return clip(strings.Join(strings.Fields(text), " "), 200)
+}
+
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
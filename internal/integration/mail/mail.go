@@ -0,0 +1,251 @@
+// Package mail reads and sends mail for agents through IMAP and SMTP
+// accounts: Fastmail, iCloud, Outlook, a self-hosted server, or Gmail over
+// IMAP. Accounts sign in with an app password or an OAuth2 access token
+// (XOAUTH2). Reading never changes a message's read state.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/mailin"
+	"tetora/internal/log"
+)
+
+var (
+	// ErrUnknownAccount is returned for an account name that is not configured.
+	ErrUnknownAccount = errors.New("unknown mail account")
+	// ErrReadOnly is returned when sending from an account without SMTP or
+	// marked readOnly.
+	ErrReadOnly = errors.New("mail account cannot send")
+)
+
+// Summary is a message in a listing. ID is the message's IMAP UID in the
+// account's mailbox.
+type Summary struct {
+	Account string `json:"account"`
+	ID      string `json:"id"`
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	Date    string `json:"date,omitempty"` // RFC3339
+	Unread  bool   `json:"unread"`
+	Snippet string `json:"snippet,omitempty"`
+
+	date time.Time
+}
+
+// Message is a message read in full.
+type Message struct {
+	Summary
+	To          []string `json:"to,omitempty"`
+	MessageID   string   `json:"messageId,omitempty"`
+	Body        string   `json:"body"`
+	Attachments []string `json:"attachments,omitempty"` // file names
+}
+
+// Overview is an account's unread mail, for briefings.
+type Overview struct {
+	Account  string    `json:"account"`
+	Unread   int       `json:"unread"`
+	Messages []Summary `json:"messages,omitempty"` // newest unread first
+	Error    string    `json:"error,omitempty"`    // the account could not be read
+}
+
+// Query selects messages.
+type Query struct {
+	Text     string    // matched against headers and body; empty matches all
+	Unread   bool      // unread messages only
+	Since    time.Time // messages received on or after this day
+	Max      int       // default mail.maxResults
+	Snippets bool      // fill Summary.Snippet, which reads each message
+}
+
+// Outgoing is a message to send.
+type Outgoing struct {
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Body    string // plain text
+	ReplyTo string // ID of the message answered, in the sending account's mailbox
+}
+
+// TokenFunc returns a current OAuth2 access token for an OAuth service.
+type TokenFunc func(service string) (string, error)
+
+// Service reads and sends mail for the configured accounts.
+type Service struct {
+	cfg   config.MailConfig
+	token TokenFunc
+
+	// dialIMAP opens a TLS connection to an IMAP server; dialSMTP a plain
+	// one to an SMTP server. Replaced in tests.
+	dialIMAP func(ctx context.Context, server string) (net.Conn, error)
+	dialSMTP func(ctx context.Context, server string) (net.Conn, error)
+}
+
+// New creates the mail service. token may be nil when no account uses
+// oauthService.
+func New(cfg config.MailConfig, token TokenFunc) *Service {
+	return &Service{
+		cfg:      cfg,
+		token:    token,
+		dialIMAP: mailin.DialIMAP,
+		dialSMTP: func(ctx context.Context, server string) (net.Conn, error) {
+			d := &net.Dialer{Timeout: 15 * time.Second}
+			return d.DialContext(ctx, "tcp", server)
+		},
+	}
+}
+
+// Accounts returns the configured accounts.
+func (s *Service) Accounts() []config.MailAccount {
+	return s.cfg.Accounts
+}
+
+// accounts returns the named account, or every account that can be read.
+func (s *Service) accounts(name string) ([]config.MailAccount, error) {
+	if name != "" {
+		a, ok := s.cfg.Account(name)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownAccount, name)
+		}
+		if a.IMAP == "" {
+			return nil, fmt.Errorf("mail account %q has no imap server", name)
+		}
+		return []config.MailAccount{a}, nil
+	}
+	var out []config.MailAccount
+	for _, a := range s.cfg.Accounts {
+		if a.IMAP != "" {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// sender returns the named account, or the default one, for sending.
+func (s *Service) sender(name string) (config.MailAccount, error) {
+	if name == "" {
+		name = s.cfg.Default
+	}
+	if name == "" {
+		for _, a := range s.cfg.Accounts {
+			if a.CanSend() {
+				return a, nil
+			}
+		}
+		return config.MailAccount{}, fmt.Errorf("%w: no account has smtp configured", ErrReadOnly)
+	}
+	a, ok := s.cfg.Account(name)
+	if !ok {
+		return config.MailAccount{}, fmt.Errorf("%w %q", ErrUnknownAccount, name)
+	}
+	if !a.CanSend() {
+		return config.MailAccount{}, fmt.Errorf("%w: %s", ErrReadOnly, name)
+	}
+	return a, nil
+}
+
+// Search lists the newest messages matching q, from every account unless
+// one is named, newest first. An account that cannot be read is skipped
+// and logged; Search fails only when every account does.
+func (s *Service) Search(ctx context.Context, account string, q Query) ([]Summary, error) {
+	accts, err := s.accounts(account)
+	if err != nil {
+		return nil, err
+	}
+	if q.Max <= 0 {
+		q.Max = s.cfg.MaxResultsOrDefault()
+	}
+	results := s.each(ctx, accts, q)
+	var out []Summary
+	var lastErr error
+	failed := 0
+	for i, r := range results {
+		if r.err != nil {
+			log.Warn("mail: account unavailable", "account", accts[i].Name, "error", r.err)
+			lastErr = r.err
+			failed++
+			continue
+		}
+		out = append(out, r.msgs...)
+	}
+	if failed > 0 && failed == len(accts) {
+		return nil, lastErr
+	}
+	sortNewest(out)
+	if len(out) > q.Max {
+		out = out[:q.Max]
+	}
+	return out, nil
+}
+
+// Inbox returns the unread mail of every account unless one is named, with
+// up to max of the newest unread messages each. Accounts that cannot be
+// read are reported with their error.
+func (s *Service) Inbox(ctx context.Context, account string, max int) ([]Overview, error) {
+	accts, err := s.accounts(account)
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 {
+		max = 10
+	}
+	results := s.each(ctx, accts, Query{Unread: true, Max: max, Snippets: true})
+	out := make([]Overview, len(accts))
+	for i, r := range results {
+		out[i] = Overview{Account: accts[i].Name, Unread: r.total, Messages: r.msgs}
+		if r.err != nil {
+			out[i].Error = r.err.Error()
+		}
+	}
+	return out, nil
+}
+
+type listing struct {
+	total int
+	msgs  []Summary
+	err   error
+}
+
+// each runs q against the accounts in parallel.
+func (s *Service) each(ctx context.Context, accts []config.MailAccount, q Query) []listing {
+	results := make([]listing, len(accts))
+	var wg sync.WaitGroup
+	for i, a := range accts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			total, msgs, err := s.search(ctx, a, q)
+			results[i] = listing{total, msgs, err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Read returns a message in full. It stays unread.
+func (s *Service) Read(ctx context.Context, account, id string) (*Message, error) {
+	if account == "" && len(s.cfg.Accounts) == 1 {
+		account = s.cfg.Accounts[0].Name
+	}
+	if account == "" {
+		return nil, fmt.Errorf("account is required")
+	}
+	accts, err := s.accounts(account)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(ctx, accts[0], id)
+}
+
+func sortNewest(msgs []Summary) {
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].date.After(msgs[j].date) })
+}
@@ -0,0 +1,263 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+type fakeMsg struct {
+	raw  string
+	seen bool
+	date string // INTERNALDATE
+}
+
+// fakeIMAP serves one session of a mailbox holding msgs, keyed by UID. Only
+// LOGIN with password "pw" succeeds.
+func fakeIMAP(conn net.Conn, msgs map[string]fakeMsg, cmds *[]string, mu *sync.Mutex) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+		mu.Lock()
+		*cmds = append(*cmds, cmd)
+		mu.Unlock()
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if !strings.HasSuffix(cmd, `"pw"`) {
+				fmt.Fprintf(conn, "%s NO bad login\r\n", tag)
+				continue
+			}
+		case strings.HasPrefix(cmd, "UID SEARCH"):
+			var uids []string
+			for uid, m := range msgs {
+				if strings.Contains(cmd, "UNSEEN") && m.seen {
+					continue
+				}
+				if _, text, ok := strings.Cut(cmd, `TEXT "`); ok && !strings.Contains(strings.ToLower(m.raw), strings.ToLower(strings.TrimSuffix(text, `"`))) {
+					continue
+				}
+				uids = append(uids, uid)
+			}
+			sort.Strings(uids)
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			set := strings.Fields(cmd)[2]
+			for i, uid := range strings.Split(set, ",") {
+				m, ok := msgs[uid]
+				if !ok {
+					continue
+				}
+				body := m.raw
+				item := "BODY[]"
+				if strings.Contains(cmd, "HEADER.FIELDS") {
+					body, _, _ = strings.Cut(m.raw, "\r\n\r\n")
+					body += "\r\n"
+					item = "BODY[HEADER.FIELDS (FROM SUBJECT DATE)]"
+				}
+				flags := ""
+				if m.seen {
+					flags = `\Seen`
+				}
+				fmt.Fprintf(conn, "* %d FETCH (UID %s FLAGS (%s) INTERNALDATE %q %s {%d}\r\n%s)\r\n", i+1, uid, flags, m.date, item, len(body), body)
+			}
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK bye\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func newTestService(t *testing.T, cfg config.MailConfig, boxes map[string]map[string]fakeMsg) (*Service, *[]string) {
+	t.Helper()
+	var cmds []string
+	var mu sync.Mutex
+	s := New(cfg, nil)
+	s.dialIMAP = func(ctx context.Context, server string) (net.Conn, error) {
+		msgs, ok := boxes[server]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		client, srv := net.Pipe()
+		go fakeIMAP(srv, msgs, &cmds, &mu)
+		return client, nil
+	}
+	return s, &cmds
+}
+
+var testBoxes = map[string]map[string]fakeMsg{
+	"work:993": {
+		"3": {raw: "From: Alice <alice@example.com>\r\nSubject: Quarterly report\r\nMessage-ID: <q3@example.com>\r\n\r\nNumbers are attached.\r\n", date: "14-Oct-2026 09:00:00 +0000"},
+		"4": {raw: "From: bob@example.com\r\nSubject: Lunch\r\n\r\nNoon?\r\n", date: "15-Oct-2026 11:30:00 +0000", seen: true},
+	},
+	"home:993": {
+		"9": {raw: "From: school@example.org\r\nSubject: Field trip\r\n\r\nSign the form.\r\n", date: "15-Oct-2026 08:00:00 +0000"},
+	},
+}
+
+var testAccounts = []config.MailAccount{
+	{Name: "work", Address: "me@work.example", IMAP: "work:993", SMTP: "localhost:587", Password: "pw"},
+	{Name: "home", Address: "me@home.example", IMAP: "home:993", Password: "pw"},
+}
+
+func TestSearch(t *testing.T) {
+	s, cmds := newTestService(t, config.MailConfig{Accounts: testAccounts}, testBoxes)
+	got, err := s.Search(context.Background(), "", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, m := range got {
+		subjects = append(subjects, m.Account+":"+m.Subject)
+	}
+	if strings.Join(subjects, ",") != "work:Lunch,home:Field trip,work:Quarterly report" {
+		t.Errorf("subjects = %v", subjects)
+	}
+	if got[0].Unread || !got[1].Unread || got[2].From != "Alice <alice@example.com>" || got[2].ID != "3" {
+		t.Errorf("results = %+v", got)
+	}
+	for _, c := range *cmds {
+		if strings.HasPrefix(c, "SELECT") || strings.Contains(c, "STORE") {
+			t.Errorf("search changed the mailbox: %s", c)
+		}
+	}
+
+	got, err = s.Search(context.Background(), "work", Query{Text: "report"})
+	if err != nil || len(got) != 1 || got[0].ID != "3" {
+		t.Errorf("text search = %+v, %v", got, err)
+	}
+	if _, err := s.Search(context.Background(), "nope", Query{}); !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("unknown account err = %v", err)
+	}
+}
+
+func TestSearchCriteria(t *testing.T) {
+	c, lit := searchCriteria(Query{Unread: true, Text: "請求書"})
+	if c != "CHARSET UTF-8 UNSEEN TEXT {9}" || len(lit) != 1 || string(lit[0]) != "請求書" {
+		t.Errorf("criteria = %q %q", c, lit)
+	}
+	if c, _ := searchCriteria(Query{}); c != "ALL" {
+		t.Errorf("empty criteria = %q", c)
+	}
+}
+
+func TestInbox(t *testing.T) {
+	boxes := map[string]map[string]fakeMsg{"work:993": testBoxes["work:993"]}
+	s, _ := newTestService(t, config.MailConfig{Accounts: testAccounts}, boxes)
+	got, err := s.Inbox(context.Background(), "", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("overviews = %+v", got)
+	}
+	if got[0].Unread != 1 || len(got[0].Messages) != 1 || got[0].Messages[0].Snippet != "Numbers are attached." {
+		t.Errorf("work = %+v", got[0])
+	}
+	if got[1].Account != "home" || got[1].Error == "" {
+		t.Errorf("unreachable account = %+v", got[1])
+	}
+}
+
+func TestRead(t *testing.T) {
+	s, _ := newTestService(t, config.MailConfig{Accounts: testAccounts}, testBoxes)
+	m, err := s.Read(context.Background(), "work", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Body != "Numbers are attached." || m.MessageID != "q3@example.com" || !m.Unread {
+		t.Errorf("message = %+v", m)
+	}
+	if _, err := s.Read(context.Background(), "", "3"); err == nil {
+		t.Error("read without an account should fail when several are configured")
+	}
+}
+
+// fakeSMTP accepts one message and returns it on got.
+func fakeSMTP(conn net.Conn, got chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ready\r\n")
+	var data strings.Builder
+	var rcpts []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			fmt.Fprint(conn, "250-localhost\r\n250 AUTH PLAIN\r\n")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			fmt.Fprint(conn, "235 ok\r\n")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpts = append(rcpts, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+			fmt.Fprint(conn, "250 ok\r\n")
+		case cmd == "DATA":
+			fmt.Fprint(conn, "354 go\r\n")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			fmt.Fprint(conn, "250 queued\r\n")
+		case cmd == "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			got <- strings.Join(rcpts, ",") + "\n" + data.String()
+			return
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	s, _ := newTestService(t, config.MailConfig{Accounts: testAccounts}, testBoxes)
+	got := make(chan string, 1)
+	s.dialSMTP = func(ctx context.Context, server string) (net.Conn, error) {
+		client, srv := net.Pipe()
+		go fakeSMTP(srv, got)
+		return client, nil
+	}
+	id, err := s.Send(context.Background(), "", Outgoing{To: []string{"Alice <alice@example.com>"}, Bcc: []string{"boss@example.com"}, Body: "Thanks, got it.", ReplyTo: "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := <-got
+	for _, want := range []string{
+		"alice@example.com,boss@example.com\n",
+		"From: <me@work.example>",
+		"Subject: Re: Quarterly report",
+		"In-Reply-To: <q3@example.com>",
+		"Message-ID: <" + id + ">",
+		"Thanks, got it.",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("sent message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "Bcc") {
+		t.Errorf("bcc leaked into headers:\n%s", msg)
+	}
+
+	if _, err := s.Send(context.Background(), "home", Outgoing{To: []string{"x@example.com"}, Subject: "hi"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("send from imap-only account err = %v", err)
+	}
+}
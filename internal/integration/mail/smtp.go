@@ -0,0 +1,193 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// Send sends msg from the named account, or the default one, and returns
+// the new message's Message-ID. A reply is threaded under the message it
+// answers and takes its subject when none is given. The sent message is not
+// copied to a Sent folder; most providers do that themselves.
+func (s *Service) Send(ctx context.Context, account string, msg Outgoing) (string, error) {
+	a, err := s.sender(account)
+	if err != nil {
+		return "", err
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return "", fmt.Errorf("at least one recipient is required")
+	}
+	var parent *Message
+	if msg.ReplyTo != "" {
+		if a.IMAP == "" {
+			return "", fmt.Errorf("mail account %q has no imap server to find the message replied to", a.Name)
+		}
+		if parent, err = s.read(ctx, a, msg.ReplyTo); err != nil {
+			return "", err
+		}
+		if msg.Subject == "" {
+			msg.Subject = parent.Subject
+			if !strings.HasPrefix(strings.ToLower(msg.Subject), "re:") {
+				msg.Subject = "Re: " + msg.Subject
+			}
+		}
+	}
+	if strings.TrimSpace(msg.Subject) == "" {
+		return "", fmt.Errorf("subject is required")
+	}
+	var rcpts []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, addr := range list {
+			p, err := mail.ParseAddress(addr)
+			if err != nil {
+				return "", fmt.Errorf("invalid recipient %q", addr)
+			}
+			rcpts = append(rcpts, p.Address)
+		}
+	}
+	id, raw := compose(a, msg, parent)
+	if err := s.deliver(ctx, a, rcpts, raw); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// compose renders msg as an RFC 5322 message with a plain-text body.
+func compose(a config.MailAccount, msg Outgoing, parent *Message) (string, []byte) {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(a.Address, "@"); ok {
+		domain = d
+	}
+	var rnd [12]byte
+	rand.Read(rnd[:])
+	id := hex.EncodeToString(rnd[:]) + "@" + domain
+
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", (&mail.Address{Name: a.DisplayName, Address: a.Address}).String())
+	if len(msg.To) > 0 {
+		header("To", strings.Join(msg.To, ", "))
+	}
+	if len(msg.Cc) > 0 {
+		header("Cc", strings.Join(msg.Cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+">")
+	if parent != nil && parent.MessageID != "" {
+		header("In-Reply-To", "<"+parent.MessageID+">")
+		header("References", "<"+parent.MessageID+">")
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+	return id, b.Bytes()
+}
+
+// deliver hands raw to the account's SMTP server. Port 465 is implicit TLS;
+// other ports upgrade with STARTTLS when the server offers it.
+func (s *Service) deliver(ctx context.Context, a config.MailAccount, rcpts []string, raw []byte) error {
+	host, port, err := net.SplitHostPort(a.SMTP)
+	if err != nil {
+		return fmt.Errorf("smtp server %q: %w", a.SMTP, err)
+	}
+	conn, err := s.dialSMTP(ctx, a.SMTP)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	} else {
+		conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	}
+	if port == "465" {
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+	if port != "465" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return fmt.Errorf("smtp starttls: %w", err)
+			}
+		}
+	}
+	var auth smtp.Auth
+	if a.OAuthService != "" {
+		tok, err := s.oauthToken(a)
+		if err != nil {
+			return err
+		}
+		auth = &xoauth2Auth{user: a.UsernameOrDefault(), token: tok}
+	} else if a.Password != "" {
+		auth = smtp.PlainAuth("", a.UsernameOrDefault(), a.Password, host)
+	}
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("smtp login: %w", err)
+		}
+	}
+	if err := c.Mail(a.Address); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	for _, r := range rcpts {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("smtp recipient %s: %w", r, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return c.Quit()
+}
+
+// xoauth2Auth is SMTP AUTH XOAUTH2. Like smtp.PlainAuth it refuses to send
+// the token over an unencrypted connection to a remote host.
+type xoauth2Auth struct {
+	user, token string
+}
+
+func (x *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "XOAUTH2", xoauth2(x.user, x.token), nil
+}
+
+func (x *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server sent an error challenge; answer empty to get the
+		// final error reply.
+		return []byte{}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,250 @@
+package mailin
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+// maxPerPoll bounds the messages fetched in one poll.
+const maxPerPoll = 20
+
+// Poller checks an IMAP mailbox for unseen messages, hands them to the
+// service and marks them seen.
+type Poller struct {
+	cfg  config.MailInIMAPConfig
+	svc  *Service
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// NewPoller creates a poller for the configured mailbox over TLS.
+func NewPoller(cfg config.MailInIMAPConfig, svc *Service) *Poller {
+	p := &Poller{cfg: cfg, svc: svc}
+	p.dial = func(ctx context.Context) (net.Conn, error) {
+		return DialIMAP(ctx, cfg.Server)
+	}
+	return p
+}
+
+// DialIMAP connects to an IMAP server over TLS; server is host:port.
+func DialIMAP(ctx context.Context, server string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("imap server %q: %w", server, err)
+	}
+	d := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 15 * time.Second}, Config: &tls.Config{ServerName: host}}
+	return d.DialContext(ctx, "tcp", server)
+}
+
+// Start launches the poll loop, which checks the mailbox now and then every
+// poll interval until ctx is cancelled.
+func (p *Poller) Start(ctx context.Context) {
+	interval := p.cfg.PollIntervalOrDefault()
+	log.Info("mailin: polling mailbox", "server", p.cfg.Server, "mailbox", p.cfg.MailboxOrDefault(), "interval", interval)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if n, err := p.Poll(ctx); err != nil {
+				log.Warn("mailin: poll failed", "error", err)
+			} else if n > 0 {
+				log.Info("mailin: polled mailbox", "messages", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Poll handles the unseen messages in the mailbox and returns how many were
+// processed. Messages are marked seen once handled, rejected or found
+// unparseable; a message whose handling failed (e.g. the task board was
+// unavailable) stays unseen and is retried next poll.
+func (p *Poller) Poll(ctx context.Context) (int, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	}
+	c, err := NewIMAPConn(conn)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Cmd("LOGOUT")
+
+	if _, err := c.Cmd("LOGIN " + IMAPQuote(p.cfg.Username) + " " + IMAPQuote(p.cfg.Password)); err != nil {
+		return 0, fmt.Errorf("imap login: %w", err)
+	}
+	if _, err := c.Cmd("SELECT " + IMAPQuote(p.cfg.MailboxOrDefault())); err != nil {
+		return 0, fmt.Errorf("imap select: %w", err)
+	}
+	resps, err := c.Cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return 0, fmt.Errorf("imap search: %w", err)
+	}
+	var uids []string
+	for _, r := range resps {
+		if rest, ok := strings.CutPrefix(r.Line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	if len(uids) > maxPerPoll {
+		uids = uids[:maxPerPoll]
+	}
+
+	n := 0
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		resps, err := c.Cmd("UID FETCH " + uid + " BODY.PEEK[]")
+		if err != nil {
+			return n, fmt.Errorf("imap fetch %s: %w", uid, err)
+		}
+		var raw []byte
+		for _, r := range resps {
+			if len(r.Lits) > 0 {
+				raw = r.Lits[len(r.Lits)-1]
+			}
+		}
+		if raw == nil {
+			continue
+		}
+		seen := true
+		if m, err := Parse(raw); err != nil {
+			log.Warn("mailin: unparseable message", "uid", uid, "error", err)
+		} else if _, err := p.svc.Handle(ctx, m, "imap"); err != nil && !errors.Is(err, ErrSenderNotAllowed) {
+			log.Warn("mailin: handle failed", "uid", uid, "error", err)
+			seen = false
+		}
+		if seen {
+			if _, err := c.Cmd("UID STORE " + uid + ` +FLAGS.SILENT (\Seen)`); err != nil {
+				return n, fmt.Errorf("imap store %s: %w", uid, err)
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// --- minimal IMAP4rev1 client ---
+
+// IMAPConn is a minimal IMAP4rev1 client session, also used by the mail
+// package.
+type IMAPConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// IMAPResp is an untagged response line; Lits holds the literals it
+// carried, in order.
+type IMAPResp struct {
+	Line string
+	Lits [][]byte
+}
+
+// NewIMAPConn starts a session on conn by reading the server greeting.
+func NewIMAPConn(conn net.Conn) (*IMAPConn, error) {
+	c := &IMAPConn{conn: conn, r: bufio.NewReader(conn)}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+		return nil, fmt.Errorf("imap greeting: %s", line)
+	}
+	return c, nil
+}
+
+func (c *IMAPConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("imap read: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Cmd sends a tagged command and collects the untagged responses until the
+// tagged completion, which must be OK. A command ending in a literal
+// "{N}" passes its N bytes as literal; they are sent once the server asks
+// for them.
+func (c *IMAPConn) Cmd(command string, literal ...[]byte) ([]IMAPResp, error) {
+	c.tag++
+	tag := "T" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, fmt.Errorf("imap write: %w", err)
+	}
+	var out []IMAPResp
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return out, errors.New(rest)
+			}
+			return out, nil
+		}
+		if strings.HasPrefix(line, "+") {
+			// A continuation request: send the literal, or end an
+			// authentication exchange with an empty response.
+			var data []byte
+			if len(literal) > 0 {
+				data, literal = literal[0], literal[1:]
+			}
+			if _, err := c.conn.Write(append(data, '\r', '\n')); err != nil {
+				return nil, fmt.Errorf("imap write: %w", err)
+			}
+			continue
+		}
+		resp := IMAPResp{Line: line}
+		// A line ending in {N} is followed by N bytes of literal data and
+		// then the rest of the response.
+		for strings.HasSuffix(line, "}") {
+			i := strings.LastIndexByte(line, '{')
+			if i < 0 {
+				break
+			}
+			size, err := strconv.Atoi(line[i+1 : len(line)-1])
+			if err != nil || size < 0 {
+				break
+			}
+			lit := make([]byte, size)
+			if _, err := io.ReadFull(c.r, lit); err != nil {
+				return nil, fmt.Errorf("imap literal: %w", err)
+			}
+			resp.Lits = append(resp.Lits, lit)
+			if line, err = c.readLine(); err != nil {
+				return nil, err
+			}
+			resp.Line += " " + line
+		}
+		out = append(out, resp)
+	}
+}
+
+// IMAPQuote returns s as an IMAP quoted string.
+func IMAPQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
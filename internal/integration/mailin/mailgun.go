@@ -0,0 +1,97 @@
+package mailin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMailgunAge rejects replayed Mailgun posts older than this.
+const maxMailgunAge = 15 * time.Minute
+
+// VerifyMailgun checks a Mailgun webhook signature: the hex HMAC-SHA256 of
+// timestamp+token keyed with the webhook signing key.
+func VerifyMailgun(signingKey, timestamp, token, signature string, now time.Time) bool {
+	if signingKey == "" || timestamp == "" || token == "" || signature == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > maxMailgunAge || d < -maxMailgunAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + token))
+	want := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(strings.ToLower(signature)))
+}
+
+// ParseMailgun reads a message posted by a Mailgun route after the caller
+// has parsed the form (multipart for "store and notify" with attachments, or
+// urlencoded). The raw MIME message in body-mime is used when the route
+// forwards it; otherwise the message is rebuilt from the parsed fields.
+// DKIM comes from Mailgun's own check.
+func ParseMailgun(r *http.Request) (*Message, error) {
+	var m *Message
+	if raw := r.FormValue("body-mime"); raw != "" {
+		var err error
+		if m, err = Parse([]byte(raw)); err != nil {
+			return nil, err
+		}
+	} else {
+		m = &Message{
+			Subject: r.FormValue("subject"),
+			Text:    strings.TrimSpace(r.FormValue("body-plain")),
+		}
+		if m.Text == "" {
+			m.Text = htmlToText(r.FormValue("body-html"))
+		}
+		if from, err := mail.ParseAddress(r.FormValue("from")); err == nil {
+			m.From, m.FromName = strings.ToLower(from.Address), from.Name
+		} else {
+			m.From = strings.ToLower(strings.TrimSpace(r.FormValue("sender")))
+		}
+		m.MessageID = strings.Trim(strings.TrimSpace(r.FormValue("Message-Id")), "<>")
+		if d, err := mail.ParseDate(r.FormValue("Date")); err == nil {
+			m.Date = d
+		}
+		if r.MultipartForm != nil {
+			for i := 1; ; i++ {
+				fhs := r.MultipartForm.File["attachment-"+strconv.Itoa(i)]
+				if len(fhs) == 0 {
+					break
+				}
+				f, err := fhs[0].Open()
+				if err != nil {
+					return nil, fmt.Errorf("attachment %d: %w", i, err)
+				}
+				data, err := io.ReadAll(f)
+				f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("attachment %d: %w", i, err)
+				}
+				m.Attachments = append(m.Attachments, Attachment{
+					Filename:    fhs[0].Filename,
+					ContentType: fhs[0].Header.Get("Content-Type"),
+					Data:        data,
+				})
+			}
+		}
+	}
+	if rcpt := strings.ToLower(strings.TrimSpace(r.FormValue("recipient"))); rcpt != "" {
+		m.To = append(m.To, rcpt)
+	}
+	if strings.EqualFold(r.FormValue("X-Mailgun-Dkim-Check-Result"), "Pass") {
+		m.DKIMPass = true
+	}
+	return m, nil
+}
@@ -0,0 +1,395 @@
+// Package mailin turns emails forwarded to Tetora's inbound address into
+// work: a task board task, or a summary with a suggested reply sent to the
+// owner.
+//
+// Mail arrives from an IMAP mailbox that Poller checks (typically a folder a
+// filter rule moves forwarded mail into) or from a Mailgun route posting to
+// the daemon. Only allowlisted senders are accepted. The action comes from a
+// "+task" or "+reply" tag on the address, or the configured default.
+// Attachments are stored in the file manager, and each message is recorded
+// in mail_inbound so it is handled once.
+package mailin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// ErrSenderNotAllowed is returned for mail from a sender not in
+// allowedSenders, or without a passing DKIM result when one is required.
+var ErrSenderNotAllowed = errors.New("sender not allowed")
+
+// Message is a parsed inbound email.
+type Message struct {
+	MessageID   string
+	From        string // bare address
+	FromName    string
+	To          []string // recipients, including Delivered-To/X-Original-To
+	Subject     string
+	Date        time.Time
+	Text        string
+	DKIMPass    bool
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// maxTextChars bounds the body kept for tasks and prompts.
+const maxTextChars = 12000
+
+var wordDecoder = new(mime.WordDecoder)
+
+// Parse parses a raw RFC 5322 message.
+func Parse(raw []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+	h := msg.Header
+	m := &Message{
+		MessageID: strings.Trim(strings.TrimSpace(h.Get("Message-Id")), "<>"),
+		Subject:   decodeHeader(h.Get("Subject")),
+	}
+	if from, err := mail.ParseAddress(h.Get("From")); err == nil {
+		m.From, m.FromName = strings.ToLower(from.Address), from.Name
+	}
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		if list, err := h.AddressList(key); err == nil {
+			for _, a := range list {
+				m.To = append(m.To, strings.ToLower(a.Address))
+			}
+		}
+	}
+	if d, err := h.Date(); err == nil {
+		m.Date = d
+	}
+	for _, ar := range h["Authentication-Results"] {
+		if strings.Contains(strings.ToLower(ar), "dkim=pass") {
+			m.DKIMPass = true
+		}
+	}
+
+	var plain, htmlText string
+	if err := walkPart(textprotoHeader(h), msg.Body, m, &plain, &htmlText); err != nil {
+		return nil, err
+	}
+	m.Text = plain
+	if strings.TrimSpace(m.Text) == "" {
+		m.Text = htmlToText(htmlText)
+	}
+	m.Text = strings.TrimSpace(m.Text)
+	return m, nil
+}
+
+// partHeader is the subset of header access walkPart needs; it is satisfied
+// by both mail.Header and multipart part headers.
+type partHeader interface {
+	Get(key string) string
+}
+
+type textprotoHeader mail.Header
+
+func (h textprotoHeader) Get(key string) string { return mail.Header(h).Get(key) }
+
+// walkPart collects the text bodies and attachments of a MIME part.
+func walkPart(h partHeader, body io.Reader, m *Message, plain, htmlText *string) error {
+	ctype := h.Get("Content-Type")
+	if ctype == "" {
+		ctype = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read %s: %w", mediaType, err)
+			}
+			if err := walkPart(p.Header, p, m, plain, htmlText); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", mediaType, err)
+	}
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := decodeHeader(dparams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	switch {
+	case disposition == "attachment" || filename != "":
+		if filename == "" {
+			filename = "attachment"
+		}
+		m.Attachments = append(m.Attachments, Attachment{Filename: filename, ContentType: mediaType, Data: data})
+	case mediaType == "text/plain" && *plain == "":
+		*plain = string(data)
+	case mediaType == "text/html" && *htmlText == "":
+		*htmlText = string(data)
+	}
+	return nil
+}
+
+func decodeTransfer(enc string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(enc)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// newlineStripper drops CR and LF so base64 bodies wrapped at 76 columns
+// decode.
+type newlineStripper struct{ r io.Reader }
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+func decodeHeader(s string) string {
+	if d, err := wordDecoder.DecodeHeader(s); err == nil {
+		return d
+	}
+	return s
+}
+
+var (
+	scriptRe = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	breakRe  = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	tagRe    = regexp.MustCompile(`<[^>]+>`)
+	blankRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+func htmlToText(s string) string {
+	s = scriptRe.ReplaceAllString(s, "")
+	s = breakRe.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+	return blankRe.ReplaceAllString(strings.TrimSpace(s), "\n\n")
+}
+
+// forwardPrefix matches "Fwd:", "FW:" and similar subject prefixes.
+var forwardPrefix = regexp.MustCompile(`(?i)^\s*((fwd?|fw|tr|wg|rv)\s*:\s*)+`)
+
+// Title returns the subject without forwarding prefixes.
+func (m *Message) Title() string {
+	t := strings.TrimSpace(forwardPrefix.ReplaceAllString(m.Subject, ""))
+	if t == "" {
+		return "(no subject)"
+	}
+	return t
+}
+
+// key identifies the message for deduplication.
+func (m *Message) key() string {
+	if m.MessageID != "" {
+		return m.MessageID
+	}
+	sum := sha256.Sum256([]byte(m.From + "\n" + m.Subject + "\n" + m.Date.String() + "\n" + m.Text))
+	return "sha256:" + hex.EncodeToString(sum[:16])
+}
+
+// --- Service ---
+
+// Deps holds the root-package callbacks the service needs.
+type Deps struct {
+	// CreateTask adds a task to the task board and returns its ID.
+	CreateTask func(title, description string) (string, error)
+	// StoreFile saves an attachment in the file manager and returns its ID.
+	// Nil when the file manager is off; attachments are then only listed.
+	StoreFile func(filename string, data []byte, messageID string) (string, error)
+	// Summarize runs the reply-suggestion prompt on an agent.
+	Summarize func(ctx context.Context, prompt string) (string, error)
+	// Notify sends text to the owner.
+	Notify func(text string)
+}
+
+// Result is what a message was turned into.
+type Result struct {
+	MessageID string   `json:"messageId"`
+	Action    string   `json:"action"` // "task" or "reply"
+	TaskID    string   `json:"taskId,omitempty"`
+	Reply     string   `json:"reply,omitempty"`
+	Files     []string `json:"files,omitempty"` // file manager IDs of the attachments
+	Duplicate bool     `json:"duplicate,omitempty"`
+}
+
+// Service handles inbound messages.
+type Service struct {
+	cfg    config.MailInConfig
+	dbPath string
+	deps   Deps
+}
+
+// New creates the service.
+func New(cfg config.MailInConfig, dbPath string, deps Deps) *Service {
+	return &Service{cfg: cfg, dbPath: dbPath, deps: deps}
+}
+
+// InitDB creates the mail_inbound table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS mail_inbound (
+		message_id TEXT PRIMARY KEY,
+		sender TEXT NOT NULL,
+		subject TEXT DEFAULT '',
+		source TEXT DEFAULT '',
+		action TEXT DEFAULT '',
+		task_id TEXT DEFAULT '',
+		files TEXT DEFAULT '',
+		received_at TEXT NOT NULL
+	);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// ActionFor returns the action for m: a "+task" or "+reply" tag on a
+// recipient address, or the configured default.
+func (s *Service) ActionFor(m *Message) string {
+	for _, to := range m.To {
+		local, _, _ := strings.Cut(to, "@")
+		if _, tag, ok := strings.Cut(local, "+"); ok && (tag == "task" || tag == "reply") {
+			return tag
+		}
+	}
+	return s.cfg.ActionOrDefault()
+}
+
+// Handle turns a message from source ("imap" or "mailgun") into a task or a
+// reply suggestion. A message already handled returns a Result with
+// Duplicate set.
+func (s *Service) Handle(ctx context.Context, m *Message, source string) (*Result, error) {
+	if !s.cfg.SenderAllowed(m.From) || (s.cfg.RequireDKIM && !m.DKIMPass) {
+		log.Warn("mailin: rejected message", "from", m.From, "subject", m.Subject, "source", source, "dkim", m.DKIMPass)
+		return nil, fmt.Errorf("%w: %s", ErrSenderNotAllowed, m.From)
+	}
+	res := &Result{MessageID: m.key(), Action: s.ActionFor(m)}
+	rows, err := db.QueryArgs(s.dbPath, `SELECT action, task_id FROM mail_inbound WHERE message_id = ?`, res.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		res.Action, res.TaskID, res.Duplicate = db.Str(rows[0]["action"]), db.Str(rows[0]["task_id"]), true
+		return res, nil
+	}
+
+	var fileLines []string
+	for _, a := range m.Attachments {
+		line := fmt.Sprintf("- %s (%s, %d bytes)", a.Filename, a.ContentType, len(a.Data))
+		if s.deps.StoreFile != nil {
+			id, err := s.deps.StoreFile(a.Filename, a.Data, res.MessageID)
+			if err != nil {
+				log.Warn("mailin: store attachment failed", "file", a.Filename, "error", err)
+			} else {
+				res.Files = append(res.Files, id)
+				line += " file " + id
+			}
+		}
+		fileLines = append(fileLines, line)
+	}
+
+	switch res.Action {
+	case "reply":
+		if s.deps.Summarize == nil {
+			return nil, fmt.Errorf("reply suggestions are not available")
+		}
+		out, err := s.deps.Summarize(ctx, replyPrompt(m, fileLines))
+		if err != nil {
+			return nil, fmt.Errorf("summarize: %w", err)
+		}
+		res.Reply = out
+		if s.deps.Notify != nil {
+			s.deps.Notify(fmt.Sprintf("Email from %s: %s\n\n%s", m.From, m.Title(), out))
+		}
+	default:
+		if s.deps.CreateTask == nil {
+			return nil, fmt.Errorf("task board is not available")
+		}
+		if res.TaskID, err = s.deps.CreateTask(m.Title(), taskDescription(m, fileLines)); err != nil {
+			return nil, fmt.Errorf("create task: %w", err)
+		}
+	}
+
+	if err := db.ExecArgs(s.dbPath, `INSERT OR IGNORE INTO mail_inbound
+		(message_id, sender, subject, source, action, task_id, files, received_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		res.MessageID, m.From, m.Subject, source, res.Action, res.TaskID, strings.Join(res.Files, ","),
+		time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return res, err
+	}
+	log.Info("mailin: handled message", "from", m.From, "subject", m.Title(), "action", res.Action, "task", res.TaskID, "files", len(res.Files))
+	return res, nil
+}
+
+func clip(s string) string {
+	if utf8.RuneCountInString(s) <= maxTextChars {
+		return s
+	}
+	return string([]rune(s)[:maxTextChars]) + "\n[truncated]"
+}
+
+func taskDescription(m *Message, files []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Forwarded by %s", m.From)
+	if !m.Date.IsZero() {
+		fmt.Fprintf(&b, " on %s", m.Date.Format("2006-01-02 15:04"))
+	}
+	b.WriteString("\n\n" + clip(m.Text))
+	if len(files) > 0 {
+		b.WriteString("\n\nAttachments:\n" + strings.Join(files, "\n"))
+	}
+	return b.String()
+}
+
+func replyPrompt(m *Message, files []string) string {
+	var b strings.Builder
+	b.WriteString("The owner forwarded the email below. Summarize it in a few bullet points, ")
+	b.WriteString("say what (if anything) they need to do, then draft a short reply they could send. ")
+	b.WriteString("Answer in the language of the email.\n\n")
+	fmt.Fprintf(&b, "Subject: %s\n\n%s\n", m.Title(), clip(m.Text))
+	if len(files) > 0 {
+		b.WriteString("\nAttachments:\n" + strings.Join(files, "\n") + "\n")
+	}
+	return b.String()
+}
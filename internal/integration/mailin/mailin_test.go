@@ -0,0 +1,273 @@
+package mailin
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+const forwarded = "From: Alice <Alice@Example.com>\r\n" +
+	"To: tetora+reply@in.example.org\r\n" +
+	"Subject: =?UTF-8?Q?Fwd:_Invoice_=E2=82=AC12?=\r\n" +
+	"Message-ID: <m1@example.com>\r\n" +
+	"Date: Tue, 13 Oct 2026 09:30:00 +0000\r\n" +
+	"Authentication-Results: mx.example.org; dkim=pass header.d=example.com\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Please pay by Friday =E2=80=94 thanks.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>Please pay</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"invoice.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0x\r\nLjQ=\r\n" +
+	"--outer--\r\n"
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(forwarded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "alice@example.com" || m.FromName != "Alice" || m.MessageID != "m1@example.com" || !m.DKIMPass {
+		t.Errorf("headers = %+v", m)
+	}
+	if m.Title() != "Invoice €12" {
+		t.Errorf("title = %q", m.Title())
+	}
+	if m.Text != "Please pay by Friday — thanks." {
+		t.Errorf("text = %q", m.Text)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Filename != "invoice.pdf" || string(m.Attachments[0].Data) != "%PDF-1.4" {
+		t.Errorf("attachments = %+v", m.Attachments)
+	}
+
+	html, err := Parse([]byte("From: b@example.com\r\nContent-Type: text/html\r\n\r\n<div>Hi&amp;bye</div><script>x()</script>"))
+	if err != nil || html.Text != "Hi&bye" || html.Title() != "(no subject)" {
+		t.Errorf("html = %+v, %v", html, err)
+	}
+}
+
+func TestMailgun(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte(ts + "tok"))
+	sig := hex.EncodeToString(mac.Sum(nil))
+	if !VerifyMailgun("key", ts, "tok", sig, now) {
+		t.Error("valid signature rejected")
+	}
+	if VerifyMailgun("key", ts, "tok", sig, now.Add(time.Hour)) || VerifyMailgun("other", ts, "tok", sig, now) {
+		t.Error("stale or wrong-key signature accepted")
+	}
+
+	form := url.Values{
+		"from":                        {"Bob <bob@corp.example>"},
+		"recipient":                   {"tetora+task@in.example.org"},
+		"subject":                     {"FW: Contract"},
+		"body-plain":                  {"See below."},
+		"Message-Id":                  {"<x@corp>"},
+		"X-Mailgun-Dkim-Check-Result": {"Pass"},
+	}
+	r, _ := http.NewRequest("POST", "/api/mail/inbound", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m, err := ParseMailgun(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "bob@corp.example" || m.Title() != "Contract" || m.Text != "See below." || m.MessageID != "x@corp" || !m.DKIMPass {
+		t.Errorf("message = %+v", m)
+	}
+	s := New(config.MailInConfig{}, "", Deps{})
+	if s.ActionFor(m) != "task" {
+		t.Errorf("action = %q", s.ActionFor(m))
+	}
+}
+
+func newTestService(t *testing.T, cfg config.MailInConfig, deps Deps) *Service {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	return New(cfg, dbPath, deps)
+}
+
+func TestService_Handle(t *testing.T) {
+	var tasks, notes []string
+	var stored []string
+	s := newTestService(t, config.MailInConfig{AllowedSenders: []string{"@example.com"}, RequireDKIM: true}, Deps{
+		CreateTask: func(title, desc string) (string, error) {
+			tasks = append(tasks, title+"|"+desc)
+			return "task-1", nil
+		},
+		StoreFile: func(name string, data []byte, _ string) (string, error) {
+			stored = append(stored, name)
+			return "f1", nil
+		},
+		Summarize: func(_ context.Context, prompt string) (string, error) {
+			if !strings.Contains(prompt, "Please pay by Friday") {
+				t.Errorf("prompt = %q", prompt)
+			}
+			return "Pay the invoice.", nil
+		},
+		Notify: func(text string) { notes = append(notes, text) },
+	})
+	ctx := context.Background()
+
+	m, _ := Parse([]byte(forwarded))
+	res, err := s.Handle(ctx, m, "imap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Action != "reply" || res.Reply != "Pay the invoice." || len(notes) != 1 || len(stored) != 1 || len(res.Files) != 1 {
+		t.Errorf("reply result = %+v, notes %v, stored %v", res, notes, stored)
+	}
+	if again, err := s.Handle(ctx, m, "mailgun"); err != nil || !again.Duplicate || len(notes) != 1 {
+		t.Errorf("duplicate = %+v, %v", again, err)
+	}
+
+	task := &Message{MessageID: "t1", From: "carol@example.com", Subject: "Fwd: Renew passport", Text: "Expires soon", DKIMPass: true}
+	res, err = s.Handle(ctx, task, "mailgun")
+	if err != nil || res.TaskID != "task-1" || len(tasks) != 1 || !strings.HasPrefix(tasks[0], "Renew passport|Forwarded by carol@example.com") {
+		t.Errorf("task result = %+v, %v, tasks %v", res, err, tasks)
+	}
+
+	for _, bad := range []*Message{
+		{MessageID: "b1", From: "eve@evil.example", DKIMPass: true},
+		{MessageID: "b2", From: "carol@example.com"}, // no DKIM
+	} {
+		if _, err := s.Handle(ctx, bad, "imap"); !errors.Is(err, ErrSenderNotAllowed) {
+			t.Errorf("%s: err = %v", bad.From, err)
+		}
+	}
+}
+
+// fakeIMAP serves one session with the given messages as unseen.
+func fakeIMAP(t *testing.T, conn net.Conn, msgs map[string]string, seen *[]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if cmd != `LOGIN "me" "p\"w"` {
+				fmt.Fprintf(conn, "%s NO bad login\r\n", tag)
+				continue
+			}
+		case cmd == "UID SEARCH UNSEEN":
+			var uids []string
+			for uid := range msgs {
+				uids = append(uids, uid)
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			uid := strings.Fields(cmd)[2]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", uid, len(msgs[uid]), msgs[uid])
+		case strings.HasPrefix(cmd, "UID STORE"):
+			*seen = append(*seen, strings.Fields(cmd)[2])
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK bye\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func TestPoller(t *testing.T) {
+	var titles []string
+	s := newTestService(t, config.MailInConfig{AllowedSenders: []string{"alice@example.com"}}, Deps{
+		CreateTask: func(title, _ string) (string, error) {
+			titles = append(titles, title)
+			return "t", nil
+		},
+	})
+	msgs := map[string]string{
+		"7": strings.Replace(forwarded, "tetora+reply@", "tetora@", 1),
+		"8": "From: eve@evil.example\r\nSubject: spam\r\n\r\nbuy",
+	}
+	var seen []string
+	p := NewPoller(config.MailInIMAPConfig{Server: "fake:993", Username: "me", Password: `p"w`}, s)
+	done := make(chan struct{})
+	p.dial = func(context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			fakeIMAP(t, server, msgs, &seen)
+			close(done)
+		}()
+		return client, nil
+	}
+	n, err := p.Poll(context.Background())
+	<-done
+	if err != nil || n != 2 {
+		t.Fatalf("poll = %d, %v", n, err)
+	}
+	if len(titles) != 1 || titles[0] != "Invoice €12" {
+		t.Errorf("tasks = %v", titles)
+	}
+	if len(seen) != 2 {
+		t.Errorf("marked seen = %v", seen)
+	}
+}
+
+func TestPollerStartReturns(t *testing.T) {
+	p := NewPoller(config.MailInIMAPConfig{Server: "fake:993"}, nil)
+	polled := make(chan struct{}, 1)
+	p.dial = func(context.Context) (net.Conn, error) {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("offline")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Start runs inside the leader's WhenActive, so it must not block.
+	started := make(chan struct{})
+	go func() {
+		p.Start(ctx)
+		close(started)
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return")
+	}
+	select {
+	case <-polled:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not poll")
+	}
+}
@@ -0,0 +1,506 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	pktConnect    = 1
+	pktConnack    = 2
+	pktPublish    = 3
+	pktPuback     = 4
+	pktSubscribe  = 8
+	pktSuback     = 9
+	pktPingreq    = 12
+	pktPingresp   = 13
+	pktDisconnect = 14
+)
+
+// maxPacketBytes is the protocol's limit for a packet's remaining length.
+const maxPacketBytes = 256 << 20
+
+// ErrClosed is returned by calls on a closed connection.
+var ErrClosed = errors.New("mqtt connection closed")
+
+// Message is a message received on a subscribed topic.
+type Message struct {
+	Broker   string
+	Topic    string
+	Payload  []byte
+	Retained bool
+}
+
+// client is one MQTT 3.1.1 connection. QoS 0 and 1 are supported, which is
+// what IoT devices and Home Assistant use; subscriptions ask for at most
+// QoS 1, so the broker never sends QoS 2.
+type client struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	wmu       sync.Mutex
+	onMessage func(Message)
+	broker    string
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan []byte // packet ID → ack body
+	err     error
+	done    chan struct{}
+}
+
+// dial connects and logs in to the broker b, then serves the connection
+// until it fails or is closed.
+func dial(ctx context.Context, b config.MQTTBroker, onMessage func(Message)) (*client, error) {
+	conn, err := dialConn(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{
+		conn:      conn,
+		r:         bufio.NewReader(conn),
+		onMessage: onMessage,
+		broker:    b.Name,
+		pending:   map[uint16]chan []byte{},
+		done:      make(chan struct{}),
+	}
+	keepAlive := b.KeepAliveOrDefault()
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	if err := c.write(pktConnect<<4, connectBody(b, keepAlive)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+	typ, body, err := c.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: %w", err)
+	}
+	if typ>>4 != pktConnack || len(body) < 2 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect: unexpected packet type %d", typ>>4)
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt connect refused: %s", connackReason(body[1]))
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.readLoop(keepAlive)
+	go c.pingLoop(keepAlive)
+	return c, nil
+}
+
+func dialConn(ctx context.Context, b config.MQTTBroker) (net.Conn, error) {
+	u, err := url.Parse(b.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("mqtt broker %q: invalid url %q", b.Name, b.URL)
+	}
+	var secure bool
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		secure = true
+	default:
+		return nil, fmt.Errorf("mqtt broker %q: unsupported scheme %q", b.Name, u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	}
+	d := &net.Dialer{Timeout: 15 * time.Second}
+	if !secure {
+		return d.DialContext(ctx, "tcp", host)
+	}
+	tc, err := tlsConfig(b.TLS, u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("mqtt broker %q: %w", b.Name, err)
+	}
+	td := &tls.Dialer{NetDialer: d, Config: tc}
+	return td.DialContext(ctx, "tcp", host)
+}
+
+func tlsConfig(t config.MQTTTLS, serverName string) (*tls.Config, error) {
+	tc := &tls.Config{ServerName: serverName, InsecureSkipVerify: t.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read caFile: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("caFile %s holds no certificates", t.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func connectBody(b config.MQTTBroker, keepAlive time.Duration) []byte {
+	var flags byte = 0x02 // clean session
+	if b.Username != "" {
+		flags |= 0x80
+		if b.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(min(keepAlive/time.Second, 65535)))
+	body = appendString(body, b.ClientIDOrDefault())
+	if b.Username != "" {
+		body = appendString(body, b.Username)
+		if b.Password != "" {
+			body = appendString(body, b.Password)
+		}
+	}
+	return body
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
+
+// subscribe subscribes to the filters and waits for the broker to accept
+// them.
+func (c *client) subscribe(ctx context.Context, subs []config.MQTTSubscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	id, ack := c.track()
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, s := range subs {
+		body = appendString(body, s.Topic)
+		body = append(body, byte(min(max(s.QoS, 0), 1)))
+	}
+	if err := c.write(pktSubscribe<<4|0x02, body); err != nil {
+		c.untrack(id)
+		return err
+	}
+	codes, err := c.wait(ctx, id, ack)
+	if err != nil {
+		return err
+	}
+	for i, code := range codes {
+		if code == 0x80 && i < len(subs) {
+			return fmt.Errorf("mqtt subscribe %q refused", subs[i].Topic)
+		}
+	}
+	return nil
+}
+
+// publish sends a message. With QoS 1 it waits for the broker's PUBACK.
+func (c *client) publish(ctx context.Context, topic string, payload []byte, qos int, retain bool) error {
+	qos = min(max(qos, 0), 1)
+	flags := byte(qos << 1)
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos == 0 {
+		return c.write(pktPublish<<4|flags, append(body, payload...))
+	}
+	id, ack := c.track()
+	body = binary.BigEndian.AppendUint16(body, id)
+	if err := c.write(pktPublish<<4|flags, append(body, payload...)); err != nil {
+		c.untrack(id)
+		return err
+	}
+	_, err := c.wait(ctx, id, ack)
+	return err
+}
+
+// close disconnects cleanly.
+func (c *client) close() {
+	c.write(pktDisconnect<<4, nil)
+	c.fail(ErrClosed)
+}
+
+// closed is closed when the connection ends; error then says why.
+func (c *client) closed() <-chan struct{} { return c.done }
+
+func (c *client) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *client) track() (uint16, chan []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	ch := make(chan []byte, 1)
+	c.pending[c.nextID] = ch
+	return c.nextID, ch
+}
+
+func (c *client) untrack(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *client) wait(ctx context.Context, id uint16, ack chan []byte) ([]byte, error) {
+	defer c.untrack(id)
+	timer := time.NewTimer(30 * time.Second)
+	defer timer.Stop()
+	select {
+	case body := <-ack:
+		return body, nil
+	case <-c.done:
+		return nil, c.error()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, errors.New("mqtt: broker did not acknowledge")
+	}
+}
+
+func (c *client) ack(id uint16, body []byte) {
+	c.mu.Lock()
+	ch := c.pending[id]
+	c.mu.Unlock()
+	if ch != nil {
+		select {
+		case ch <- body:
+		default:
+		}
+	}
+}
+
+func (c *client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	c.mu.Unlock()
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *client) readLoop(keepAlive time.Duration) {
+	for {
+		// The broker answers pings, so a silent connection is a dead one.
+		c.conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		typ, body, err := c.readPacket()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch typ >> 4 {
+		case pktPublish:
+			c.handlePublish(typ, body)
+		case pktPuback:
+			if len(body) >= 2 {
+				c.ack(binary.BigEndian.Uint16(body), nil)
+			}
+		case pktSuback:
+			if len(body) >= 2 {
+				c.ack(binary.BigEndian.Uint16(body), body[2:])
+			}
+		case pktPingresp:
+		}
+	}
+}
+
+func (c *client) handlePublish(typ byte, body []byte) {
+	qos := (typ >> 1) & 0x03
+	topic, rest, ok := readString(body)
+	if !ok {
+		return
+	}
+	if qos > 0 {
+		if len(rest) < 2 {
+			return
+		}
+		id := rest[:2]
+		rest = rest[2:]
+		c.write(pktPuback<<4, id)
+	}
+	if c.onMessage != nil {
+		c.onMessage(Message{Broker: c.broker, Topic: topic, Payload: rest, Retained: typ&0x01 != 0})
+	}
+}
+
+func (c *client) pingLoop(keepAlive time.Duration) {
+	t := time.NewTicker(keepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if err := c.write(pktPingreq<<4, nil); err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *client) write(header byte, body []byte) error {
+	select {
+	case <-c.done:
+		return c.error()
+	default:
+	}
+	pkt := append([]byte{header}, remainingLength(len(body))...)
+	pkt = append(pkt, body...)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func (c *client) readPacket() (byte, []byte, error) {
+	return readPacket(c.r)
+}
+
+// --- Wire encoding ---
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+		mult *= 128
+	}
+	if n > maxPacketBytes {
+		return 0, nil, errors.New("mqtt: packet too large")
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
+
+func remainingLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, bool) {
+	if len(b) < 2 {
+		return "", nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, false
+	}
+	return string(b[2 : 2+n]), b[2+n:], true
+}
+
+// MatchTopic reports whether topic matches the subscription filter, which
+// may use "+" for one level and "#" for all remaining levels. As brokers do,
+// wildcards at the start of a filter do not match topics starting with "$".
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+// ValidFilter reports whether filter is a well-formed topic filter.
+func ValidFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, l := range levels {
+		if l == "#" && i != len(levels)-1 {
+			return false
+		}
+		if l != "#" && l != "+" && strings.ContainsAny(l, "#+") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,306 @@
+// Package mqtt connects Tetora to MQTT brokers, for IoT devices and other
+// systems outside Home Assistant. Messages on subscribed topics are handed
+// on as events, so workflow and proactive triggers can react to them, and
+// task results and notifications can be published to topics. Connections
+// speak MQTT 3.1.1 over TCP or TLS and reconnect on their own.
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+var (
+	// ErrUnknownBroker is returned for a broker name that is not configured.
+	ErrUnknownBroker = errors.New("unknown mqtt broker")
+	// ErrNotConnected is returned when publishing while the broker is down.
+	ErrNotConnected = errors.New("mqtt broker not connected")
+)
+
+// EventPrefix starts the type of events made from received messages:
+// "mqtt:" followed by the topic.
+const EventPrefix = "mqtt:"
+
+// EventType is the event type of a message received on topic.
+func EventType(topic string) string { return EventPrefix + topic }
+
+// EventData is the data of the event made from m: broker, topic, payload
+// and retained, plus the top-level fields of a JSON object payload.
+func (m Message) EventData() map[string]any {
+	data := map[string]any{}
+	var obj map[string]any
+	if json.Unmarshal(m.Payload, &obj) == nil {
+		for k, v := range obj {
+			if _, ok := v.(map[string]any); ok {
+				continue
+			}
+			if _, ok := v.([]any); ok {
+				continue
+			}
+			data[k] = v
+		}
+	}
+	data["broker"] = m.Broker
+	data["topic"] = m.Topic
+	data["payload"] = string(m.Payload)
+	data["retained"] = m.Retained
+	return data
+}
+
+// Status is the state of a broker connection.
+type Status struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Connected     bool     `json:"connected"`
+	Since         string   `json:"since,omitempty"` // when the connection came up or went down
+	Subscriptions []string `json:"subscriptions,omitempty"`
+	Received      int64    `json:"received"`
+	Published     int64    `json:"published"`
+	LastError     string   `json:"lastError,omitempty"`
+}
+
+// Service keeps a connection to every configured broker.
+type Service struct {
+	brokers   []*broker
+	onMessage func(Message)
+}
+
+type broker struct {
+	cfg config.MQTTBroker
+
+	mu        sync.Mutex
+	c         *client
+	since     time.Time
+	received  int64
+	published int64
+	lastError string
+}
+
+// New returns a service for cfg's brokers. onMessage is called for every
+// message received on a subscribed topic, from the connection's reader, so
+// it should not block.
+func New(cfg config.MQTTConfig, onMessage func(Message)) *Service {
+	s := &Service{onMessage: onMessage}
+	for _, b := range cfg.Brokers {
+		s.brokers = append(s.brokers, &broker{cfg: b})
+	}
+	return s
+}
+
+// Start connects to every broker and keeps reconnecting until ctx ends.
+func (s *Service) Start(ctx context.Context) {
+	for _, b := range s.brokers {
+		go b.run(ctx, s.onMessage)
+	}
+}
+
+func (b *broker) run(ctx context.Context, onMessage func(Message)) {
+	backoff := time.Second
+	for {
+		c, err := b.connect(ctx, onMessage)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.down(err)
+			log.Warn("mqtt connect failed", "broker", b.cfg.Name, "error", err, "retryIn", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+		log.Info("mqtt connected", "broker", b.cfg.Name, "subscriptions", len(b.cfg.Subscribe))
+
+		select {
+		case <-ctx.Done():
+			c.close()
+			b.down(nil)
+			return
+		case <-c.closed():
+			b.down(c.error())
+			log.Warn("mqtt connection lost", "broker", b.cfg.Name, "error", c.error())
+		}
+	}
+}
+
+func (b *broker) connect(ctx context.Context, onMessage func(Message)) (*client, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	c, err := dial(dialCtx, b.cfg, func(m Message) {
+		b.mu.Lock()
+		b.received++
+		b.mu.Unlock()
+		if onMessage != nil {
+			onMessage(m)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.subscribe(dialCtx, b.cfg.Subscribe); err != nil {
+		c.close()
+		return nil, err
+	}
+	b.mu.Lock()
+	b.c = c
+	b.since = time.Now()
+	b.lastError = ""
+	b.mu.Unlock()
+	return c, nil
+}
+
+func (b *broker) down(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c != nil {
+		b.since = time.Now()
+	}
+	b.c = nil
+	if err != nil {
+		b.lastError = err.Error()
+	}
+}
+
+func (b *broker) client() *client {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.c
+}
+
+// Publish sends payload to topic on the named broker.
+func (s *Service) Publish(ctx context.Context, brokerName, topic string, payload []byte, qos int, retain bool) error {
+	for _, b := range s.brokers {
+		if b.cfg.Name == brokerName {
+			return b.publish(ctx, topic, payload, qos, retain)
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownBroker, brokerName)
+}
+
+func (b *broker) publish(ctx context.Context, topic string, payload []byte, qos int, retain bool) error {
+	c := b.client()
+	if c == nil {
+		return fmt.Errorf("%w: %s", ErrNotConnected, b.cfg.Name)
+	}
+	if err := c.publish(ctx, topic, payload, qos, retain); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.published++
+	b.mu.Unlock()
+	return nil
+}
+
+// PublishResult publishes a finished task's result, as JSON, on every broker
+// whose results settings want its status. It does not wait for delivery.
+func (s *Service) PublishResult(status string, result any) {
+	var body []byte
+	for _, b := range s.brokers {
+		r := b.cfg.Results
+		if r == nil || r.Topic == "" || !wantsStatus(r.Events, status) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(result); err != nil {
+				return
+			}
+		}
+		topic := strings.ReplaceAll(r.Topic, "{status}", status)
+		go func(b *broker) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := b.publish(ctx, topic, body, r.QoS, r.Retain); err != nil {
+				log.Warn("mqtt publish result failed", "broker", b.cfg.Name, "topic", topic, "error", err)
+			}
+		}(b)
+	}
+}
+
+func wantsStatus(events []string, status string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == "all" || e == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Status reports every broker's connection.
+func (s *Service) Status() []Status {
+	out := make([]Status, 0, len(s.brokers))
+	for _, b := range s.brokers {
+		b.mu.Lock()
+		st := Status{
+			Name:      b.cfg.Name,
+			URL:       b.cfg.URL,
+			Connected: b.c != nil,
+			Received:  b.received,
+			Published: b.published,
+			LastError: b.lastError,
+		}
+		if !b.since.IsZero() {
+			st.Since = b.since.Format(time.RFC3339)
+		}
+		b.mu.Unlock()
+		for _, sub := range b.cfg.Subscribe {
+			st.Subscriptions = append(st.Subscriptions, sub.Topic)
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// --- Notifier ---
+
+// Notifier publishes notifications to a topic. It satisfies notify.Notifier.
+// Each notification opens its own short connection, under a client ID of
+// its own, so it never takes over the service's session.
+type Notifier struct {
+	broker config.MQTTBroker
+	topic  string
+}
+
+// NewNotifier returns a notifier publishing to topic on b.
+func NewNotifier(b config.MQTTBroker, topic string) (*Notifier, error) {
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return nil, fmt.Errorf("mqtt notification topic %q is not a plain topic", topic)
+	}
+	return &Notifier{broker: b, topic: topic}, nil
+}
+
+// Send publishes text with QoS 1.
+func (n *Notifier) Send(text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	b := n.broker
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	b.ClientID = b.ClientIDOrDefault() + "-notify-" + hex.EncodeToString(suffix)
+	c, err := dial(ctx, b, nil)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	return c.publish(ctx, n.topic, []byte(text), 1, false)
+}
+
+// Name returns the notifier's channel type.
+func (n *Notifier) Name() string { return "mqtt" }
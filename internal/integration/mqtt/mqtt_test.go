@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// fakeBroker accepts connections, acknowledges CONNECT, SUBSCRIBE and QoS 1
+// PUBLISH packets, and echoes every published message back to the sender.
+type fakeBroker struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	clientIDs []string
+	filters   []string
+	published []string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeBroker{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeBroker) url() string { return "mqtt://" + f.ln.Addr().String() }
+
+func (f *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	send := func(header byte, body []byte) {
+		conn.Write(append(append([]byte{header}, remainingLength(len(body))...), body...))
+	}
+	for {
+		typ, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch typ >> 4 {
+		case pktConnect:
+			// protocol name, level, flags, keep alive, then the client ID
+			_, rest, _ := readString(body)
+			id, _, _ := readString(rest[4:])
+			f.mu.Lock()
+			f.clientIDs = append(f.clientIDs, id)
+			f.mu.Unlock()
+			send(pktConnack<<4, []byte{0, 0})
+		case pktSubscribe:
+			rest := body[2:]
+			var codes []byte
+			for len(rest) > 0 {
+				var filter string
+				filter, rest, _ = readString(rest)
+				codes = append(codes, rest[0])
+				rest = rest[1:]
+				f.mu.Lock()
+				f.filters = append(f.filters, filter)
+				f.mu.Unlock()
+			}
+			send(pktSuback<<4, append(body[:2:2], codes...))
+		case pktPublish:
+			topic, rest, _ := readString(body)
+			if typ&0x06 != 0 {
+				send(pktPuback<<4, rest[:2])
+				rest = rest[2:]
+			}
+			f.mu.Lock()
+			f.published = append(f.published, topic+" "+string(rest))
+			f.mu.Unlock()
+			send(pktPublish<<4, append(appendString(nil, topic), rest...))
+		case pktPingreq:
+			send(pktPingresp<<4, nil)
+		case pktDisconnect:
+			return
+		}
+	}
+}
+
+func (f *fakeBroker) snapshot() (ids, filters, published []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.clientIDs...), append([]string(nil), f.filters...), append([]string(nil), f.published...)
+}
+
+func TestServicePublishSubscribe(t *testing.T) {
+	f := newFakeBroker(t)
+	got := make(chan Message, 4)
+	s := New(config.MQTTConfig{Brokers: []config.MQTTBroker{{
+		Name:      "home",
+		URL:       f.url(),
+		Subscribe: []config.MQTTSubscription{{Topic: "home/#", QoS: 1}},
+		Results:   &config.MQTTResults{Topic: "tetora/{status}", Events: []string{"error"}, QoS: 1},
+	}}}, func(m Message) { got <- m })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.Status()[0].Connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("not connected: %+v", s.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Publish(ctx, "home", "home/door", []byte(`{"state":"open","meta":{"x":1}}`), 1, false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-got:
+		data := m.EventData()
+		if m.Broker != "home" || data["topic"] != "home/door" || data["state"] != "open" {
+			t.Errorf("message = %+v, data = %v", m, data)
+		}
+		if _, ok := data["meta"]; ok {
+			t.Error("nested payload object should not be flattened")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	if err := s.Publish(ctx, "nope", "x", nil, 0, false); err == nil {
+		t.Error("publish to unknown broker succeeded")
+	}
+
+	s.PublishResult("success", map[string]string{"id": "t1"}) // not in events
+	s.PublishResult("error", map[string]string{"id": "t2"})
+	for time.Now().Before(deadline) {
+		if _, _, pub := f.snapshot(); len(pub) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ids, filters, pub := f.snapshot()
+	if len(pub) != 2 || pub[1] != `tetora/error {"id":"t2"}` {
+		t.Errorf("published = %q", pub)
+	}
+	if len(filters) != 1 || filters[0] != "home/#" {
+		t.Errorf("filters = %q", filters)
+	}
+	if len(ids) != 1 || ids[0] != "tetora-home" {
+		t.Errorf("client IDs = %q", ids)
+	}
+
+	st := s.Status()[0]
+	if st.Published != 2 || st.Received < 1 {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	f := newFakeBroker(t)
+	n, err := NewNotifier(config.MQTTBroker{Name: "home", URL: f.url()}, "tetora/notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, pub := f.snapshot()
+	if len(pub) != 1 || pub[0] != "tetora/notify hello" {
+		t.Errorf("published = %q", pub)
+	}
+	if _, err := NewNotifier(config.MQTTBroker{}, "tetora/#"); err == nil {
+		t.Error("wildcard notification topic accepted")
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"home/door", "home/door", true},
+		{"home/+", "home/door", true},
+		{"home/+", "home/door/state", false},
+		{"home/#", "home", true},
+		{"home/#", "home/door/state", true},
+		{"+/+/state", "home/door/state", true},
+		{"#", "$SYS/uptime", false},
+		{"home/door", "home/window", false},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+
+	for filter, want := range map[string]bool{
+		"home/#": true, "+/door": true, "home/#/x": false, "home/do+r": false, "": false,
+	} {
+		if got := ValidFilter(filter); got != want {
+			t.Errorf("ValidFilter(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
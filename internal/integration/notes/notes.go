@@ -0,0 +1,338 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LogFn is a structured logging function matching logInfo/logWarn/logDebug signatures.
+type LogFn func(msg string, keyvals ...any)
+
+// EmbedFn stores a note's content into semantic memory.
+// If nil, auto-embed is disabled.
+type EmbedFn func(ctx context.Context, name, content string, tags []string) error
+
+// Config holds notes settings. Notes live in an Obsidian-style vault
+// directory by default, or in a Notion workspace.
+type Config struct {
+	Enabled      bool         `json:"enabled"`
+	Backend      string       `json:"backend,omitempty"` // "obsidian" (default) or "notion"
+	VaultPath    string       `json:"vaultPath,omitempty"`
+	DefaultExt   string       `json:"defaultExt,omitempty"`
+	AutoEmbed    bool         `json:"autoEmbed,omitempty"`
+	IndexOnStart bool         `json:"indexOnStart,omitempty"`
+	Dedup        bool         `json:"dedup,omitempty"`
+	Notion       NotionConfig `json:"notion,omitempty"`
+}
+
+// NotionConfig points the notes at a Notion database or page. Each note is
+// a page: a row of the database, titled by its name, or a child page of the
+// page.
+type NotionConfig struct {
+	Token        string `json:"token,omitempty"`        // integration secret; $ENV_VAR supported
+	OAuthService string `json:"oauthService,omitempty"` // oauth service holding the token instead (e.g. "notion")
+	Database     string `json:"database,omitempty"`     // database ID
+	Page         string `json:"page,omitempty"`         // parent page ID, when no database is set
+	BaseURL      string `json:"baseUrl,omitempty"`      // API base (default https://api.notion.com/v1)
+}
+
+// BackendOrDefault returns the configured backend or "obsidian".
+func (c Config) BackendOrDefault() string {
+	if c.Backend != "" {
+		return c.Backend
+	}
+	return "obsidian"
+}
+
+// DefaultExtOrMd returns the configured default extension or ".md".
+func (c Config) DefaultExtOrMd() string {
+	if c.DefaultExt != "" {
+		return c.DefaultExt
+	}
+	return ".md"
+}
+
+// VaultPathResolved resolves the vault path, expanding ~ and relative paths against baseDir.
+func (c Config) VaultPathResolved(baseDir string) string {
+	p := c.VaultPath
+	if p == "" {
+		return filepath.Join(baseDir, "vault")
+	}
+	if strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		p = filepath.Join(home, p[2:])
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(baseDir, p)
+	}
+	return p
+}
+
+// NoteInfo describes a single note. Path is the file path in a vault and
+// the page URL in Notion.
+type NoteInfo struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Tags    []string  `json:"tags,omitempty"`
+	Links   []string  `json:"links,omitempty"`
+}
+
+// SearchResult from a notes search.
+type SearchResult struct {
+	Filename  string  `json:"filename"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score"`
+	LineStart int     `json:"lineStart"`
+}
+
+// ErrNotFound is returned when reading a note that does not exist.
+var ErrNotFound = errors.New("note not found")
+
+// backend stores notes. Names are validated before they reach it.
+type backend interface {
+	create(ctx context.Context, name, content string) error
+	read(ctx context.Context, name string) (string, error)
+	append(ctx context.Context, name, content string) error
+	list(ctx context.Context, prefix string) ([]NoteInfo, error)
+	search(ctx context.Context, query string, maxResults int) ([]SearchResult, error)
+}
+
+// Deps holds what the notes service needs from its host.
+type Deps struct {
+	// Embed stores notes into semantic memory when autoEmbed is on. Leave
+	// nil when embedding is disabled.
+	Embed EmbedFn
+	// Token returns the Notion access token, e.g. from an OAuth service.
+	// When nil, NotionConfig.Token is used.
+	Token func(ctx context.Context) (string, error)
+
+	LogInfo  LogFn
+	LogWarn  LogFn
+	LogDebug LogFn
+}
+
+// Service manages notes in the configured backend.
+type Service struct {
+	backend   backend
+	name      string
+	vaultPath string
+	autoEmbed bool
+	embedFn   EmbedFn
+	logInfo   LogFn
+	logWarn   LogFn
+	logDebug  LogFn
+}
+
+// New creates a notes Service for cfg's backend.
+func New(cfg Config, baseDir string, deps Deps) (*Service, error) {
+	noop := func(string, ...any) {}
+	if deps.LogInfo == nil {
+		deps.LogInfo = noop
+	}
+	if deps.LogWarn == nil {
+		deps.LogWarn = noop
+	}
+	if deps.LogDebug == nil {
+		deps.LogDebug = noop
+	}
+
+	svc := &Service{
+		name:      cfg.BackendOrDefault(),
+		autoEmbed: cfg.AutoEmbed && deps.Embed != nil,
+		embedFn:   deps.Embed,
+		logInfo:   deps.LogInfo,
+		logWarn:   deps.LogWarn,
+		logDebug:  deps.LogDebug,
+	}
+	switch svc.name {
+	case "obsidian":
+		v := newVault(cfg, baseDir, deps.LogWarn)
+		if cfg.IndexOnStart {
+			if err := v.idx.rebuild(); err != nil {
+				deps.LogWarn("notes index build failed", "error", err)
+			} else {
+				deps.LogInfo("notes index built", "docs", v.idx.totalDocs, "vault", v.path)
+			}
+		}
+		svc.backend, svc.vaultPath = v, v.path
+	case "notion":
+		n, err := newNotion(cfg, deps.Token)
+		if err != nil {
+			return nil, err
+		}
+		svc.backend = n
+	default:
+		return nil, fmt.Errorf("notes: unknown backend %q", cfg.Backend)
+	}
+	return svc, nil
+}
+
+// Backend returns the backend name: "obsidian" or "notion".
+func (svc *Service) Backend() string { return svc.name }
+
+// VaultPath returns the resolved vault path, or "" for Notion.
+func (svc *Service) VaultPath() string { return svc.vaultPath }
+
+// CreateNote creates a note, replacing one of the same name.
+func (svc *Service) CreateNote(ctx context.Context, name, content string) error {
+	if err := ValidateNoteName(name); err != nil {
+		return err
+	}
+	if err := svc.backend.create(ctx, name, content); err != nil {
+		return err
+	}
+	svc.logInfo("note created", "name", name, "backend", svc.name)
+	if svc.autoEmbed {
+		go svc.embedNote(name, content)
+	}
+	return nil
+}
+
+// ReadNote reads the content of a note as markdown.
+func (svc *Service) ReadNote(ctx context.Context, name string) (string, error) {
+	if err := ValidateNoteName(name); err != nil {
+		return "", err
+	}
+	return svc.backend.read(ctx, name)
+}
+
+// AppendNote appends content to an existing note, or creates it if it does not exist.
+func (svc *Service) AppendNote(ctx context.Context, name, content string) error {
+	if err := ValidateNoteName(name); err != nil {
+		return err
+	}
+	if err := svc.backend.append(ctx, name, content); err != nil {
+		return err
+	}
+	svc.logInfo("note appended", "name", name, "backend", svc.name)
+	if svc.autoEmbed {
+		go func() {
+			if full, err := svc.backend.read(context.Background(), name); err == nil {
+				svc.embedNote(name, full)
+			}
+		}()
+	}
+	return nil
+}
+
+// ListNotes returns notes matching an optional prefix filter.
+// Tags and wikilinks are extracted from each note's content.
+func (svc *Service) ListNotes(ctx context.Context, prefix string) ([]NoteInfo, error) {
+	return svc.backend.list(ctx, prefix)
+}
+
+// SearchNotes searches notes: TF-IDF over the vault, Notion's search in
+// Notion. maxResults <= 0 defaults to 5.
+func (svc *Service) SearchNotes(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	return svc.backend.search(ctx, query, maxResults)
+}
+
+// embedNote stores a note's content into semantic memory via the injected EmbedFn.
+func (svc *Service) embedNote(name, content string) {
+	if svc.embedFn == nil {
+		return
+	}
+	tags := ExtractTags(content)
+	if err := svc.embedFn(context.Background(), name, content, tags); err != nil {
+		svc.logWarn("notes auto-embed failed", "name", name, "error", err)
+		return
+	}
+	svc.logDebug("note embedded", "name", name)
+}
+
+// --- Exported helpers ---
+
+var wikilinkRe = regexp.MustCompile(`\[\[([^\]|]+)(?:\|[^\]]+)?\]\]`)
+var tagRe = regexp.MustCompile(`(?:^|\s)#([a-zA-Z][a-zA-Z0-9_/-]*)`)
+
+// ExtractWikilinks parses [[wikilink]] and [[wikilink|alias]] references from content.
+func ExtractWikilinks(content string) []string {
+	matches := wikilinkRe.FindAllStringSubmatch(content, -1)
+	seen := make(map[string]bool)
+	var links []string
+	for _, m := range matches {
+		link := strings.TrimSpace(m[1])
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// ExtractTags parses #tag references from content.
+func ExtractTags(content string) []string {
+	matches := tagRe.FindAllStringSubmatch(content, -1)
+	seen := make(map[string]bool)
+	var tags []string
+	for _, m := range matches {
+		tag := m[1]
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ValidateNoteName checks that a note name is safe (no path traversal, no hidden files).
+func ValidateNoteName(name string) error {
+	if name == "" {
+		return fmt.Errorf("note name is required")
+	}
+	if filepath.IsAbs(name) {
+		return fmt.Errorf("note name must not be an absolute path")
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("note name must not contain path traversal (..) components")
+	}
+	cleaned := filepath.Clean(name)
+	if strings.HasPrefix(filepath.Base(cleaned), ".") {
+		return fmt.Errorf("note name must not start with a dot")
+	}
+	return nil
+}
+
+// findBestMatchLine returns the 0-based index of the line with the most query token hits.
+func findBestMatchLine(lines []string, queryTokens []string) int {
+	bestLine := 0
+	bestCount := 0
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		count := 0
+		for _, qt := range queryTokens {
+			if strings.Contains(lower, qt) {
+				count++
+			}
+		}
+		if count > bestCount {
+			bestCount = count
+			bestLine = i
+		}
+	}
+	return bestLine
+}
+
+// buildSnippet extracts a snippet from lines around matchLine with contextLines on each side.
+func buildSnippet(lines []string, matchLine, contextLines int) string {
+	start := matchLine - contextLines
+	if start < 0 {
+		start = 0
+	}
+	end := matchLine + contextLines + 1
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return strings.Join(lines[start:end], "\n")
+}
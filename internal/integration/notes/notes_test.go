@@ -0,0 +1,791 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testService creates a Service backed by a temporary directory.
+func testService(t *testing.T) *Service {
+	t.Helper()
+	tmp := t.TempDir()
+	cfg := Config{
+		Enabled:    true,
+		VaultPath:  tmp,
+		DefaultExt: ".md",
+	}
+	svc, err := New(cfg, tmp, Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+// --- CRUD ---
+
+func TestCreateAndRead(t *testing.T) {
+	svc := testService(t)
+
+	const name = "hello"
+	const content = "Hello, world!"
+
+	if err := svc.CreateNote(context.Background(), name, content); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+
+	got, err := svc.ReadNote(context.Background(), name)
+	if err != nil {
+		t.Fatalf("ReadNote: %v", err)
+	}
+	if got != content {
+		t.Errorf("content mismatch: got %q, want %q", got, content)
+	}
+}
+
+func TestCreateWithExtension(t *testing.T) {
+	svc := testService(t)
+
+	// Name already has .md extension — must not double-append.
+	if err := svc.CreateNote(context.Background(), "explicit.md", "data"); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+	if _, err := svc.ReadNote(context.Background(), "explicit.md"); err != nil {
+		t.Fatalf("ReadNote: %v", err)
+	}
+
+	// A different extension must be preserved as-is.
+	if err := svc.CreateNote(context.Background(), "note.txt", "text data"); err != nil {
+		t.Fatalf("CreateNote txt: %v", err)
+	}
+	if _, err := svc.ReadNote(context.Background(), "note.txt"); err != nil {
+		t.Fatalf("ReadNote txt: %v", err)
+	}
+}
+
+func TestCreateNested(t *testing.T) {
+	svc := testService(t)
+
+	if err := svc.CreateNote(context.Background(), "projects/tetora/roadmap", "# Roadmap"); err != nil {
+		t.Fatalf("CreateNote nested: %v", err)
+	}
+	got, err := svc.ReadNote(context.Background(), "projects/tetora/roadmap")
+	if err != nil {
+		t.Fatalf("ReadNote nested: %v", err)
+	}
+	if got != "# Roadmap" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	svc := testService(t)
+
+	if err := svc.CreateNote(context.Background(), "journal", "line1\n"); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+	if err := svc.AppendNote(context.Background(), "journal", "line2\n"); err != nil {
+		t.Fatalf("AppendNote: %v", err)
+	}
+
+	got, err := svc.ReadNote(context.Background(), "journal")
+	if err != nil {
+		t.Fatalf("ReadNote: %v", err)
+	}
+	if !strings.Contains(got, "line1") || !strings.Contains(got, "line2") {
+		t.Errorf("expected both lines; got %q", got)
+	}
+}
+
+func TestAppendCreatesIfNotExists(t *testing.T) {
+	svc := testService(t)
+
+	if err := svc.AppendNote(context.Background(), "new-note", "auto-created"); err != nil {
+		t.Fatalf("AppendNote on non-existent: %v", err)
+	}
+	got, err := svc.ReadNote(context.Background(), "new-note")
+	if err != nil {
+		t.Fatalf("ReadNote: %v", err)
+	}
+	if got != "auto-created" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
+
+// --- List ---
+
+func TestListWithPrefix(t *testing.T) {
+	svc := testService(t)
+
+	notes := []string{"work/task1", "work/task2", "personal/diary"}
+	for _, n := range notes {
+		if err := svc.CreateNote(context.Background(), n, "content"); err != nil {
+			t.Fatalf("CreateNote %q: %v", n, err)
+		}
+	}
+
+	listed, err := svc.ListNotes(context.Background(), "work")
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("expected 2 notes with prefix 'work', got %d", len(listed))
+	}
+	for _, ni := range listed {
+		if !strings.HasPrefix(ni.Name, "work") {
+			t.Errorf("unexpected note outside prefix: %q", ni.Name)
+		}
+	}
+}
+
+func TestListMetadata(t *testing.T) {
+	svc := testService(t)
+
+	content := "# My Note\n\n#golang #testing\n\nSee also [[other-note]] and [[guide|Guide]]."
+	if err := svc.CreateNote(context.Background(), "meta", content); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+
+	listed, err := svc.ListNotes(context.Background(), "")
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(listed) != 1 {
+		t.Fatalf("expected 1 note, got %d", len(listed))
+	}
+
+	ni := listed[0]
+
+	// Tags
+	wantTags := map[string]bool{"golang": true, "testing": true}
+	for _, tag := range ni.Tags {
+		if !wantTags[tag] {
+			t.Errorf("unexpected tag %q", tag)
+		}
+		delete(wantTags, tag)
+	}
+	if len(wantTags) > 0 {
+		t.Errorf("missing tags: %v", wantTags)
+	}
+
+	// Links
+	wantLinks := map[string]bool{"other-note": true, "guide": true}
+	for _, link := range ni.Links {
+		if !wantLinks[link] {
+			t.Errorf("unexpected link %q", link)
+		}
+		delete(wantLinks, link)
+	}
+	if len(wantLinks) > 0 {
+		t.Errorf("missing links: %v", wantLinks)
+	}
+}
+
+// --- Search ---
+
+func TestSearchViaTFIDF(t *testing.T) {
+	svc := testService(t)
+
+	docs := map[string]string{
+		"golang":  "Golang concurrency channels goroutines",
+		"python":  "Python scripting dynamic typing lambda",
+		"systems": "Golang systems programming memory safety",
+	}
+	for name, content := range docs {
+		if err := svc.CreateNote(context.Background(), name, content); err != nil {
+			t.Fatalf("CreateNote %q: %v", name, err)
+		}
+	}
+
+	// Rebuild index synchronously so search results are deterministic.
+	svc.backend.(*vault).rebuildIndex()
+
+	results, err := svc.SearchNotes(context.Background(), "Golang concurrency", 5)
+	if err != nil {
+		t.Fatalf("SearchNotes: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected search results, got none")
+	}
+	// The "golang" note should score highest — it has both terms.
+	if results[0].Filename != "golang.md" {
+		t.Errorf("expected 'golang.md' as top result, got %q", results[0].Filename)
+	}
+	// Score must be positive.
+	if results[0].Score <= 0 {
+		t.Errorf("expected positive score, got %f", results[0].Score)
+	}
+	// LineStart must be 1-based.
+	if results[0].LineStart < 1 {
+		t.Errorf("LineStart should be >= 1, got %d", results[0].LineStart)
+	}
+}
+
+// --- Extract helpers ---
+
+func TestExtractWikilinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "simple",
+			content: "[[note-a]] and [[note-b]]",
+			want:    []string{"note-a", "note-b"},
+		},
+		{
+			name:    "with alias",
+			content: "[[real-page|display text]]",
+			want:    []string{"real-page"},
+		},
+		{
+			name:    "dedup",
+			content: "[[foo]] and [[foo]] again",
+			want:    []string{"foo"},
+		},
+		{
+			name:    "none",
+			content: "no links here",
+			want:    nil,
+		},
+		{
+			name:    "mixed",
+			content: "See [[alpha|Alpha Guide]] and [[beta]].",
+			want:    []string{"alpha", "beta"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ExtractWikilinks(tc.content)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i, g := range got {
+				if g != tc.want[i] {
+					t.Errorf("[%d] got %q, want %q", i, g, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "basic",
+			content: "#go #testing",
+			want:    []string{"go", "testing"},
+		},
+		{
+			name:    "inline",
+			content: "This is #important content with #todo items.",
+			want:    []string{"important", "todo"},
+		},
+		{
+			name:    "dedup",
+			content: "#go #go #go",
+			want:    []string{"go"},
+		},
+		{
+			name:    "hierarchical",
+			content: "#project/tetora is great",
+			want:    []string{"project/tetora"},
+		},
+		{
+			name:    "none",
+			content: "plain text no tags",
+			want:    nil,
+		},
+		{
+			name:    "must start with letter",
+			content: "#1invalid",
+			want:    nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ExtractTags(tc.content)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i, g := range got {
+				if g != tc.want[i] {
+					t.Errorf("[%d] got %q, want %q", i, g, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+// --- Security ---
+
+func TestPathTraversal(t *testing.T) {
+	svc := testService(t)
+
+	cases := []string{
+		"../escape",
+		"foo/../../etc/passwd",
+		"a/b/../../../etc",
+	}
+	for _, c := range cases {
+		if err := svc.CreateNote(context.Background(), c, "bad"); err == nil {
+			t.Errorf("expected error for traversal %q, got nil", c)
+		}
+		if _, err := svc.ReadNote(context.Background(), c); err == nil {
+			t.Errorf("expected error for traversal read %q, got nil", c)
+		}
+	}
+}
+
+func TestEmptyName(t *testing.T) {
+	svc := testService(t)
+
+	if err := svc.CreateNote(context.Background(), "", "data"); err == nil {
+		t.Error("expected error for empty name on CreateNote")
+	}
+	if _, err := svc.ReadNote(context.Background(), ""); err == nil {
+		t.Error("expected error for empty name on ReadNote")
+	}
+	if err := svc.AppendNote(context.Background(), "", "data"); err == nil {
+		t.Error("expected error for empty name on AppendNote")
+	}
+}
+
+func TestReadNotFound(t *testing.T) {
+	svc := testService(t)
+
+	_, err := svc.ReadNote(context.Background(), "nonexistent-note")
+	if err == nil {
+		t.Fatal("expected error for missing note, got nil")
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected 'not found' in error, got %q", err.Error())
+	}
+}
+
+// --- Config ---
+
+func TestConfigDefaults(t *testing.T) {
+	t.Run("DefaultExtOrMd empty", func(t *testing.T) {
+		c := Config{}
+		if c.DefaultExtOrMd() != ".md" {
+			t.Errorf("expected .md, got %q", c.DefaultExtOrMd())
+		}
+	})
+	t.Run("DefaultExtOrMd set", func(t *testing.T) {
+		c := Config{DefaultExt: ".org"}
+		if c.DefaultExtOrMd() != ".org" {
+			t.Errorf("expected .org, got %q", c.DefaultExtOrMd())
+		}
+	})
+	t.Run("VaultPathResolved empty uses baseDir/vault", func(t *testing.T) {
+		c := Config{}
+		got := c.VaultPathResolved("/base")
+		if got != "/base/vault" {
+			t.Errorf("got %q, want /base/vault", got)
+		}
+	})
+	t.Run("VaultPathResolved absolute unchanged", func(t *testing.T) {
+		c := Config{VaultPath: "/absolute/path"}
+		got := c.VaultPathResolved("/base")
+		if got != "/absolute/path" {
+			t.Errorf("got %q, want /absolute/path", got)
+		}
+	})
+	t.Run("VaultPathResolved relative joined to baseDir", func(t *testing.T) {
+		c := Config{VaultPath: "myvault"}
+		got := c.VaultPathResolved("/base")
+		if got != "/base/myvault" {
+			t.Errorf("got %q, want /base/myvault", got)
+		}
+	})
+	t.Run("VaultPathResolved tilde expansion", func(t *testing.T) {
+		home, _ := os.UserHomeDir()
+		c := Config{VaultPath: "~/notes"}
+		got := c.VaultPathResolved("/base")
+		want := filepath.Join(home, "notes")
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+// --- Index ---
+
+func TestIndexRebuild(t *testing.T) {
+	svc := testService(t)
+
+	notes := []struct{ name, content string }{
+		{"alpha", "alpha beta gamma"},
+		{"beta", "beta delta epsilon"},
+		{"gamma", "gamma zeta eta"},
+	}
+	for _, n := range notes {
+		if err := svc.CreateNote(context.Background(), n.name, n.content); err != nil {
+			t.Fatalf("CreateNote %q: %v", n.name, err)
+		}
+	}
+
+	v := svc.backend.(*vault)
+	v.rebuildIndex()
+
+	if v.idx.totalDocs != 3 {
+		t.Errorf("expected 3 indexed docs, got %d", v.idx.totalDocs)
+	}
+	if len(v.idx.idf) == 0 {
+		t.Error("expected non-empty IDF table after rebuild")
+	}
+}
+
+// --- ValidateNoteName ---
+
+func TestValidateNoteName(t *testing.T) {
+	valid := []string{
+		"simple",
+		"with-dashes",
+		"nested/path",
+		"deep/nested/note",
+		"note.md",
+		"note.txt",
+	}
+	for _, v := range valid {
+		if err := ValidateNoteName(v); err != nil {
+			t.Errorf("ValidateNoteName(%q) unexpected error: %v", v, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"../bad",
+		"foo/../bar",
+		"/absolute",
+		".hidden",
+		"dir/.hidden",
+	}
+	for _, inv := range invalid {
+		if err := ValidateNoteName(inv); err == nil {
+			t.Errorf("ValidateNoteName(%q) expected error, got nil", inv)
+		}
+	}
+}
+
+// --- Ln ---
+
+func TestLn(t *testing.T) {
+	cases := []struct {
+		x    float64
+		want float64
+	}{
+		{1.0, 0.0},
+		{math.E, 1.0},
+		{2.0, math.Log(2.0)},
+		{10.0, math.Log(10.0)},
+		{0.5, math.Log(0.5)},
+		{100.0, math.Log(100.0)},
+	}
+
+	const epsilon = 1e-9
+	for _, tc := range cases {
+		got := Ln(tc.x)
+		diff := got - tc.want
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > epsilon {
+			t.Errorf("Ln(%v) = %v, want %v (diff %e)", tc.x, got, tc.want, diff)
+		}
+	}
+
+	// Edge cases.
+	if Ln(0) != 0 {
+		t.Errorf("Ln(0) should return 0, got %v", Ln(0))
+	}
+	if Ln(-1) != 0 {
+		t.Errorf("Ln(-1) should return 0, got %v", Ln(-1))
+	}
+}
+
+// --- Notion ---
+
+const testDatabase = "0123456789abcdef0123456789abcdef"
+
+// fakeNotion is an in-memory Notion database behind the API routes the
+// backend uses.
+type fakeNotion struct {
+	mu     sync.Mutex
+	nextID int
+	pages  map[string]*fakePage
+	order  []string
+}
+
+type fakePage struct {
+	title  string
+	blocks []map[string]any
+}
+
+func (f *fakeNotion) id() string {
+	f.nextID++
+	return fmt.Sprintf("id%d", f.nextID)
+}
+
+func (f *fakeNotion) page(id string) map[string]any {
+	return map[string]any{
+		"id":               id,
+		"url":              "https://www.notion.so/" + id,
+		"last_edited_time": "2026-10-14T09:00:00.000Z",
+		"parent":           map[string]any{"type": "database_id", "database_id": "01234567-89ab-cdef-0123-456789abcdef"},
+		"properties": map[string]any{
+			"Name": map[string]any{"type": "title", "title": []map[string]any{{"plain_text": f.pages[id].title}}},
+		},
+	}
+}
+
+func (f *fakeNotion) text(id string) string {
+	var b strings.Builder
+	for _, blk := range f.pages[id].blocks {
+		data, _ := json.Marshal(blk)
+		b.Write(data)
+	}
+	return b.String()
+}
+
+func (f *fakeNotion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret_test" || r.Header.Get("Notion-Version") != notionVersion {
+		http.Error(w, `{"message":"API token is invalid."}`, http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var in map[string]any
+	json.NewDecoder(r.Body).Decode(&in)
+	reply := func(v any) { json.NewEncoder(w).Encode(v) }
+	results := func(ids []string) {
+		var out []map[string]any
+		for _, id := range ids {
+			out = append(out, f.page(id))
+		}
+		reply(map[string]any{"results": out, "has_more": false})
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "databases":
+		reply(map[string]any{"properties": map[string]any{
+			"Tags": map[string]any{"type": "multi_select"},
+			"Name": map[string]any{"type": "title"},
+		}})
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "query":
+		if parts[1] != testDatabase {
+			http.Error(w, `{"message":"no database"}`, http.StatusNotFound)
+			return
+		}
+		var ids []string
+		cond, _ := in["filter"].(map[string]any)
+		for _, id := range f.order {
+			title := f.pages[id].title
+			if cond != nil {
+				t := cond["title"].(map[string]any)
+				if eq, ok := t["equals"].(string); ok && title != eq {
+					continue
+				}
+				if pre, ok := t["starts_with"].(string); ok && !strings.HasPrefix(title, pre) {
+					continue
+				}
+			}
+			ids = append(ids, id)
+		}
+		results(ids)
+	case r.Method == http.MethodPost && r.URL.Path == "/pages":
+		props := in["properties"].(map[string]any)["Name"].(map[string]any)["title"].([]any)
+		id := f.id()
+		f.pages[id] = &fakePage{title: props[0].(map[string]any)["text"].(map[string]any)["content"].(string)}
+		f.order = append(f.order, id)
+		for _, c := range in["children"].([]any) {
+			blk := c.(map[string]any)
+			blk["id"] = f.id()
+			f.pages[id].blocks = append(f.pages[id].blocks, blk)
+		}
+		reply(f.page(id))
+	case len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		p := f.pages[parts[1]]
+		if p == nil {
+			http.Error(w, `{"message":"no page"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			children := in["children"].([]any)
+			if len(children) > 100 {
+				http.Error(w, `{"message":"too many children"}`, http.StatusBadRequest)
+				return
+			}
+			for _, c := range children {
+				blk := c.(map[string]any)
+				blk["id"] = f.id()
+				p.blocks = append(p.blocks, blk)
+			}
+		}
+		reply(map[string]any{"results": p.blocks, "has_more": false})
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "blocks":
+		for _, p := range f.pages {
+			for i, blk := range p.blocks {
+				if blk["id"] == parts[1] {
+					p.blocks = append(p.blocks[:i], p.blocks[i+1:]...)
+					reply(map[string]any{"id": parts[1]})
+					return
+				}
+			}
+		}
+		http.Error(w, `{"message":"no block"}`, http.StatusNotFound)
+	case r.Method == http.MethodPost && r.URL.Path == "/search":
+		q := strings.ToLower(in["query"].(string))
+		var ids []string
+		for _, id := range f.order {
+			if strings.Contains(strings.ToLower(f.pages[id].title+f.text(id)), q) {
+				ids = append(ids, id)
+			}
+		}
+		results(ids)
+	default:
+		http.Error(w, `{"message":"unexpected request"}`, http.StatusBadRequest)
+	}
+}
+
+func testNotion(t *testing.T) (*Service, *fakeNotion) {
+	t.Helper()
+	fake := &fakeNotion{pages: map[string]*fakePage{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	svc, err := New(Config{
+		Enabled: true,
+		Backend: "notion",
+		Notion:  NotionConfig{Token: "secret_test", Database: "01234567-89ab-cdef-0123-456789abcdef", BaseURL: srv.URL},
+	}, t.TempDir(), Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, fake
+}
+
+func TestNotionCreateReadAppend(t *testing.T) {
+	svc, fake := testNotion(t)
+	ctx := context.Background()
+
+	content := "# Standup\n\n- shipped sync\n- [x] review PR\n\nNotes line one\nline two\n\n```go\nfmt.Println(1)\n```\n"
+	if err := svc.CreateNote(ctx, "2026-10-14.md", content); err != nil {
+		t.Fatalf("CreateNote: %v", err)
+	}
+	if len(fake.order) != 1 || fake.pages[fake.order[0]].title != "2026-10-14" {
+		t.Fatalf("pages = %+v", fake.pages)
+	}
+	got, err := svc.ReadNote(ctx, "2026-10-14")
+	if err != nil {
+		t.Fatalf("ReadNote: %v", err)
+	}
+	want := "# Standup\n\n- shipped sync\n- [x] review PR\n\nNotes line one\nline two\n\n```go\nfmt.Println(1)\n```\n"
+	if got != want {
+		t.Errorf("ReadNote = %q, want %q", got, want)
+	}
+
+	if err := svc.AppendNote(ctx, "2026-10-14", "## Timeline\n"); err != nil {
+		t.Fatalf("AppendNote: %v", err)
+	}
+	if got, _ := svc.ReadNote(ctx, "2026-10-14"); !strings.HasSuffix(got, "```\n\n## Timeline\n") {
+		t.Errorf("after append = %q", got)
+	}
+
+	// Creating it again replaces the content of the same page.
+	if err := svc.CreateNote(ctx, "2026-10-14", "fresh"); err != nil {
+		t.Fatalf("CreateNote again: %v", err)
+	}
+	if got, _ := svc.ReadNote(ctx, "2026-10-14"); got != "fresh\n" || len(fake.order) != 1 {
+		t.Errorf("after replace = %q, %d pages", got, len(fake.order))
+	}
+
+	if _, err := svc.ReadNote(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadNote missing: %v", err)
+	}
+}
+
+func TestNotionLongNote(t *testing.T) {
+	svc, fake := testNotion(t)
+	ctx := context.Background()
+
+	var b strings.Builder
+	for i := range 150 {
+		fmt.Fprintf(&b, "- item %d\n", i)
+	}
+	b.WriteString(strings.Repeat("x", 4500))
+	if err := svc.AppendNote(ctx, "long", b.String()); err != nil {
+		t.Fatalf("AppendNote: %v", err)
+	}
+	blocks := fake.pages[fake.order[0]].blocks
+	if len(blocks) != 151 {
+		t.Fatalf("blocks = %d, want 151", len(blocks))
+	}
+	last := blocks[150]["paragraph"].(map[string]any)["rich_text"].([]any)
+	if len(last) != 3 {
+		t.Errorf("rich text chunks = %d, want 3", len(last))
+	}
+}
+
+func TestNotionListSearch(t *testing.T) {
+	svc, _ := testNotion(t)
+	ctx := context.Background()
+
+	for name, content := range map[string]string{
+		"work/roadmap":   "Ship the notion backend\nthen calendars",
+		"work/retro":     "What went well",
+		"personal/diary": "Rainy day",
+	} {
+		if err := svc.CreateNote(ctx, name, content); err != nil {
+			t.Fatalf("CreateNote %q: %v", name, err)
+		}
+	}
+
+	listed, err := svc.ListNotes(ctx, "work/")
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(listed) != 2 || listed[0].Name != "work/roadmap" || listed[0].Path == "" || listed[0].ModTime.IsZero() {
+		t.Errorf("listed = %+v", listed)
+	}
+
+	results, err := svc.SearchNotes(ctx, "notion", 5)
+	if err != nil {
+		t.Fatalf("SearchNotes: %v", err)
+	}
+	if len(results) != 1 || results[0].Filename != "work/roadmap" || results[0].LineStart != 1 || !strings.Contains(results[0].Snippet, "notion backend") {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestNotionConfig(t *testing.T) {
+	if _, err := New(Config{Backend: "notion", Notion: NotionConfig{Token: "x"}}, "", Deps{}); err == nil {
+		t.Error("expected error without database or page")
+	}
+	if _, err := New(Config{Backend: "notion", Notion: NotionConfig{Page: "p"}}, "", Deps{}); err == nil {
+		t.Error("expected error without token")
+	}
+	if _, err := New(Config{Backend: "evernote"}, t.TempDir(), Deps{}); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+	if got := notionID("https://www.notion.so/team/Daily-Notes-0123456789ABCDEF0123456789abcdef?v=42"); got != testDatabase {
+		t.Errorf("notionID = %q", got)
+	}
+}
@@ -0,0 +1,659 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notionVersion is the Notion API version the requests are written against.
+const notionVersion = "2022-06-28"
+
+var notionClient = &http.Client{Timeout: 30 * time.Second}
+
+// notion keeps notes as Notion pages: rows of a database, found by their
+// title property, or child pages of a parent page. Content is converted
+// between markdown and blocks; formatting Notion has no block for is kept as
+// plain text.
+type notion struct {
+	base     string
+	database string
+	page     string
+	ext      string
+	token    func(ctx context.Context) (string, error)
+
+	mu        sync.Mutex
+	titleProp string // the database's title property, looked up once
+}
+
+func newNotion(cfg Config, token func(ctx context.Context) (string, error)) (*notion, error) {
+	nc := cfg.Notion
+	if nc.Database == "" && nc.Page == "" {
+		return nil, fmt.Errorf("notes: notion needs a database or page")
+	}
+	if token == nil {
+		if nc.Token == "" {
+			return nil, fmt.Errorf("notes: notion needs a token or oauthService")
+		}
+		static := nc.Token
+		token = func(context.Context) (string, error) { return static, nil }
+	}
+	base := "https://api.notion.com/v1"
+	if nc.BaseURL != "" {
+		base = strings.TrimSuffix(nc.BaseURL, "/")
+	}
+	n := &notion{base: base, ext: cfg.DefaultExtOrMd(), token: token}
+	if nc.Database != "" {
+		n.database = notionID(nc.Database)
+	} else {
+		n.page = notionID(nc.Page)
+	}
+	return n, nil
+}
+
+// notionID takes an ID with or without dashes, or a page or database URL,
+// and returns the bare 32-character ID.
+func notionID(s string) string {
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.ReplaceAll(s, "-", "")
+	if len(s) > 32 {
+		s = s[len(s)-32:]
+	}
+	return strings.ToLower(s)
+}
+
+func (n *notion) do(ctx context.Context, method, path string, in, out any) error {
+	tok, err := n.token(ctx)
+	if err != nil {
+		return fmt.Errorf("notion token: %w", err)
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, n.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	req.Header.Set("Notion-Version", notionVersion)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := notionClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notion: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("notion %s: status %d: %s", path, resp.StatusCode, e.Message)
+		}
+		return fmt.Errorf("notion %s: status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("notion %s: %w", path, err)
+	}
+	return nil
+}
+
+type notionText struct {
+	PlainText string `json:"plain_text"`
+	Text      struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+func plainText(ts []notionText) string {
+	var b strings.Builder
+	for _, t := range ts {
+		if t.PlainText != "" {
+			b.WriteString(t.PlainText)
+		} else {
+			b.WriteString(t.Text.Content)
+		}
+	}
+	return b.String()
+}
+
+// richText splits s into text objects within Notion's 2000-character limit.
+func richText(s string) []map[string]any {
+	out := []map[string]any{}
+	r := []rune(s)
+	for len(r) > 0 {
+		k := min(len(r), 2000)
+		out = append(out, map[string]any{"type": "text", "text": map[string]any{"content": string(r[:k])}})
+		r = r[k:]
+	}
+	return out
+}
+
+type notionPage struct {
+	ID             string    `json:"id"`
+	URL            string    `json:"url"`
+	LastEditedTime time.Time `json:"last_edited_time"`
+	Parent         struct {
+		DatabaseID string `json:"database_id"`
+		PageID     string `json:"page_id"`
+	} `json:"parent"`
+	Properties map[string]struct {
+		Type  string       `json:"type"`
+		Title []notionText `json:"title"`
+	} `json:"properties"`
+}
+
+func (p notionPage) title() string {
+	for _, prop := range p.Properties {
+		if prop.Type == "title" {
+			return plainText(prop.Title)
+		}
+	}
+	return ""
+}
+
+type notionBlock struct {
+	ID             string
+	Type           string
+	LastEditedTime time.Time
+	RichText       []notionText
+	Checked        bool
+	Language       string
+	Title          string // child_page
+}
+
+func (b *notionBlock) UnmarshalJSON(data []byte) error {
+	var head struct {
+		ID             string    `json:"id"`
+		Type           string    `json:"type"`
+		LastEditedTime time.Time `json:"last_edited_time"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	var body struct {
+		RichText []notionText `json:"rich_text"`
+		Checked  bool         `json:"checked"`
+		Language string       `json:"language"`
+		Title    string       `json:"title"`
+	}
+	if raw, ok := all[head.Type]; ok {
+		json.Unmarshal(raw, &body)
+	}
+	*b = notionBlock{
+		ID: head.ID, Type: head.Type, LastEditedTime: head.LastEditedTime,
+		RichText: body.RichText, Checked: body.Checked, Language: body.Language, Title: body.Title,
+	}
+	return nil
+}
+
+// title is the page title for a note name: the name without the default
+// extension.
+func (n *notion) title(name string) string {
+	return strings.TrimSuffix(name, n.ext)
+}
+
+// titleProperty looks up the name of the database's title property.
+func (n *notion) titleProperty(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.titleProp != "" {
+		return n.titleProp, nil
+	}
+	var db struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+	if err := n.do(ctx, http.MethodGet, "/databases/"+n.database, nil, &db); err != nil {
+		return "", err
+	}
+	for name, prop := range db.Properties {
+		if prop.Type == "title" {
+			n.titleProp = name
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("notion: database %s has no title property", n.database)
+}
+
+// query returns the database pages matching filter, nil for all.
+func (n *notion) query(ctx context.Context, filter map[string]any) ([]notionPage, error) {
+	var all []notionPage
+	in := map[string]any{"page_size": 100}
+	if filter != nil {
+		in["filter"] = filter
+	}
+	for {
+		var out struct {
+			Results    []notionPage `json:"results"`
+			HasMore    bool         `json:"has_more"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		if err := n.do(ctx, http.MethodPost, "/databases/"+n.database+"/query", in, &out); err != nil {
+			return nil, err
+		}
+		all = append(all, out.Results...)
+		if !out.HasMore || out.NextCursor == "" {
+			return all, nil
+		}
+		in["start_cursor"] = out.NextCursor
+	}
+}
+
+// children returns the child blocks of a page or block.
+func (n *notion) children(ctx context.Context, id string) ([]notionBlock, error) {
+	var all []notionBlock
+	cursor := ""
+	for {
+		path := "/blocks/" + id + "/children?page_size=100"
+		if cursor != "" {
+			path += "&start_cursor=" + url.QueryEscape(cursor)
+		}
+		var out struct {
+			Results    []notionBlock `json:"results"`
+			HasMore    bool          `json:"has_more"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := n.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+			return nil, err
+		}
+		all = append(all, out.Results...)
+		if !out.HasMore || out.NextCursor == "" {
+			return all, nil
+		}
+		cursor = out.NextCursor
+	}
+}
+
+// find returns the ID of the page titled title, or "" when there is none.
+func (n *notion) find(ctx context.Context, title string) (string, error) {
+	if n.database != "" {
+		prop, err := n.titleProperty(ctx)
+		if err != nil {
+			return "", err
+		}
+		pages, err := n.query(ctx, map[string]any{"property": prop, "title": map[string]any{"equals": title}})
+		if err != nil || len(pages) == 0 {
+			return "", err
+		}
+		return pages[0].ID, nil
+	}
+	blocks, err := n.children(ctx, n.page)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range blocks {
+		if b.Type == "child_page" && b.Title == title {
+			return b.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// newPage creates a page titled title holding blocks.
+func (n *notion) newPage(ctx context.Context, title string, blocks []map[string]any) error {
+	in := map[string]any{}
+	if n.database != "" {
+		prop, err := n.titleProperty(ctx)
+		if err != nil {
+			return err
+		}
+		in["parent"] = map[string]any{"database_id": n.database}
+		in["properties"] = map[string]any{prop: map[string]any{"title": richText(title)}}
+	} else {
+		in["parent"] = map[string]any{"page_id": n.page}
+		in["properties"] = map[string]any{"title": map[string]any{"title": richText(title)}}
+	}
+	// A page is created with at most 100 blocks; the rest are appended.
+	first := blocks[:min(len(blocks), 100)]
+	in["children"] = first
+	var page notionPage
+	if err := n.do(ctx, http.MethodPost, "/pages", in, &page); err != nil {
+		return err
+	}
+	return n.appendBlocks(ctx, page.ID, blocks[len(first):])
+}
+
+func (n *notion) appendBlocks(ctx context.Context, id string, blocks []map[string]any) error {
+	for len(blocks) > 0 {
+		k := min(len(blocks), 100)
+		if err := n.do(ctx, http.MethodPatch, "/blocks/"+id+"/children", map[string]any{"children": blocks[:k]}, nil); err != nil {
+			return err
+		}
+		blocks = blocks[k:]
+	}
+	return nil
+}
+
+// create writes the note's page, replacing the content of an existing one.
+// Sub-pages of an existing page are kept.
+func (n *notion) create(ctx context.Context, name, content string) error {
+	title := n.title(name)
+	id, err := n.find(ctx, title)
+	if err != nil {
+		return err
+	}
+	blocks := toBlocks(content)
+	if id == "" {
+		return n.newPage(ctx, title, blocks)
+	}
+	old, err := n.children(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, b := range old {
+		if b.Type == "child_page" || b.Type == "child_database" {
+			continue
+		}
+		if err := n.do(ctx, http.MethodDelete, "/blocks/"+b.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return n.appendBlocks(ctx, id, blocks)
+}
+
+func (n *notion) read(ctx context.Context, name string) (string, error) {
+	id, err := n.find(ctx, n.title(name))
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	blocks, err := n.children(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return toMarkdown(blocks), nil
+}
+
+func (n *notion) append(ctx context.Context, name, content string) error {
+	title := n.title(name)
+	id, err := n.find(ctx, title)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return n.newPage(ctx, title, toBlocks(content))
+	}
+	return n.appendBlocks(ctx, id, toBlocks(content))
+}
+
+// list returns the notes by title. Pages are not read, so Size, Tags and
+// Links are left empty.
+func (n *notion) list(ctx context.Context, prefix string) ([]NoteInfo, error) {
+	var notes []NoteInfo
+	if n.database != "" {
+		var filter map[string]any
+		if prefix != "" {
+			prop, err := n.titleProperty(ctx)
+			if err != nil {
+				return nil, err
+			}
+			filter = map[string]any{"property": prop, "title": map[string]any{"starts_with": prefix}}
+		}
+		pages, err := n.query(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pages {
+			notes = append(notes, NoteInfo{Name: p.title(), Path: p.URL, ModTime: p.LastEditedTime})
+		}
+		return notes, nil
+	}
+	blocks, err := n.children(ctx, n.page)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.Type != "child_page" || !strings.HasPrefix(b.Title, prefix) {
+			continue
+		}
+		notes = append(notes, NoteInfo{
+			Name:    b.Title,
+			Path:    "https://www.notion.so/" + strings.ReplaceAll(b.ID, "-", ""),
+			ModTime: b.LastEditedTime,
+		})
+	}
+	return notes, nil
+}
+
+// search runs Notion's search over the workspace and keeps the notes'
+// pages, in Notion's order. Snippets come from reading the matched pages.
+func (n *notion) search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	var out struct {
+		Results []notionPage `json:"results"`
+	}
+	in := map[string]any{
+		"query":     query,
+		"filter":    map[string]any{"property": "object", "value": "page"},
+		"page_size": 100,
+	}
+	if err := n.do(ctx, http.MethodPost, "/search", in, &out); err != nil {
+		return nil, err
+	}
+	tokens := tokenize(query)
+	var results []SearchResult
+	for _, p := range out.Results {
+		if len(results) == maxResults {
+			break
+		}
+		if n.database != "" && notionID(p.Parent.DatabaseID) != n.database ||
+			n.database == "" && notionID(p.Parent.PageID) != n.page {
+			continue
+		}
+		blocks, err := n.children(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(toMarkdown(blocks), "\n")
+		best := findBestMatchLine(lines, tokens)
+		results = append(results, SearchResult{
+			Filename:  p.title(),
+			Snippet:   buildSnippet(lines, best, 1),
+			Score:     1 / float64(len(results)+1),
+			LineStart: best + 1,
+		})
+	}
+	return results, nil
+}
+
+// --- Markdown conversion ---
+
+var (
+	headingRe  = regexp.MustCompile(`^(#{1,6}) (.*)$`)
+	numberedRe = regexp.MustCompile(`^\d+[.)] (.*)$`)
+	todoRe     = regexp.MustCompile(`^[-*+] \[([ xX])\] (.*)$`)
+)
+
+func textBlock(typ, text string) map[string]any {
+	return map[string]any{"object": "block", "type": typ, typ: map[string]any{"rich_text": richText(text)}}
+}
+
+// toBlocks converts markdown to Notion blocks: headings, lists, to-dos,
+// quotes, dividers, code fences and paragraphs. Inline formatting is kept
+// as written.
+func toBlocks(md string) []map[string]any {
+	var out []map[string]any
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			out = append(out, textBlock("paragraph", strings.Join(para, "\n")))
+			para = nil
+		}
+	}
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, "```") {
+			flush()
+			lang := strings.TrimSpace(line[3:])
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b := textBlock("code", strings.Join(code, "\n"))
+			b["code"].(map[string]any)["language"] = notionLanguage(lang)
+			out = append(out, b)
+			continue
+		}
+		if line == "---" || line == "***" {
+			flush()
+			out = append(out, map[string]any{"object": "block", "type": "divider", "divider": map[string]any{}})
+			continue
+		}
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			flush()
+			out = append(out, textBlock(fmt.Sprintf("heading_%d", min(len(m[1]), 3)), m[2]))
+			continue
+		}
+		if m := todoRe.FindStringSubmatch(line); m != nil {
+			flush()
+			b := textBlock("to_do", m[2])
+			b["to_do"].(map[string]any)["checked"] = m[1] != " "
+			out = append(out, b)
+			continue
+		}
+		if len(line) > 2 && strings.ContainsRune("-*+", rune(line[0])) && line[1] == ' ' {
+			flush()
+			out = append(out, textBlock("bulleted_list_item", line[2:]))
+			continue
+		}
+		if m := numberedRe.FindStringSubmatch(line); m != nil {
+			flush()
+			out = append(out, textBlock("numbered_list_item", m[1]))
+			continue
+		}
+		if strings.HasPrefix(line, "> ") {
+			flush()
+			out = append(out, textBlock("quote", line[2:]))
+			continue
+		}
+		para = append(para, line)
+	}
+	flush()
+	return out
+}
+
+// toMarkdown converts Notion blocks back to markdown. Blocks of other types
+// are skipped; sub-pages become wikilinks.
+func toMarkdown(blocks []notionBlock) string {
+	var b strings.Builder
+	prev, num := "", 0
+	for _, blk := range blocks {
+		text := plainText(blk.RichText)
+		var line string
+		switch blk.Type {
+		case "paragraph":
+			line = text
+		case "heading_1":
+			line = "# " + text
+		case "heading_2":
+			line = "## " + text
+		case "heading_3":
+			line = "### " + text
+		case "bulleted_list_item":
+			line = "- " + text
+		case "numbered_list_item":
+			if prev != "numbered_list_item" {
+				num = 0
+			}
+			num++
+			line = fmt.Sprintf("%d. %s", num, text)
+		case "to_do":
+			mark := " "
+			if blk.Checked {
+				mark = "x"
+			}
+			line = "- [" + mark + "] " + text
+		case "quote":
+			line = "> " + text
+		case "divider":
+			line = "---"
+		case "code":
+			lang := blk.Language
+			if lang == "plain text" {
+				lang = ""
+			}
+			line = "```" + lang + "\n" + text + "\n```"
+		case "child_page":
+			line = "[[" + blk.Title + "]]"
+		default:
+			continue
+		}
+		if b.Len() > 0 {
+			if isListBlock(prev) && isListBlock(blk.Type) {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(line)
+		prev = blk.Type
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func isListBlock(typ string) bool {
+	return typ == "bulleted_list_item" || typ == "numbered_list_item" || typ == "to_do"
+}
+
+// notionLanguage maps a code fence's language to one Notion accepts.
+func notionLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	switch lang {
+	case "js":
+		return "javascript"
+	case "ts":
+		return "typescript"
+	case "py":
+		return "python"
+	case "golang":
+		return "go"
+	case "sh", "zsh":
+		return "shell"
+	case "yml":
+		return "yaml"
+	case "md":
+		return "markdown"
+	case "cpp":
+		return "c++"
+	case "bash", "c", "c++", "c#", "css", "diff", "docker", "go", "graphql", "html", "java",
+		"javascript", "json", "kotlin", "lua", "makefile", "markdown", "php", "python", "ruby",
+		"rust", "scala", "shell", "sql", "swift", "typescript", "xml", "yaml":
+		return lang
+	}
+	return "plain text"
+}
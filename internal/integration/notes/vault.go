@@ -0,0 +1,402 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// vault keeps notes as files in a directory, as Obsidian does, with a
+// TF-IDF index for search.
+type vault struct {
+	path    string
+	ext     string
+	idx     *index
+	logWarn LogFn
+}
+
+func newVault(cfg Config, baseDir string, logWarn LogFn) *vault {
+	path := cfg.VaultPathResolved(baseDir)
+	os.MkdirAll(path, 0o755)
+	return &vault{
+		path:    path,
+		ext:     cfg.DefaultExtOrMd(),
+		logWarn: logWarn,
+		idx: &index{
+			docs:      make(map[string]*docEntry),
+			idf:       make(map[string]float64),
+			vaultPath: path,
+		},
+	}
+}
+
+// fullPath returns the absolute path for a note name within the vault.
+func (v *vault) fullPath(name string) string {
+	return filepath.Join(v.path, v.ensureExt(name))
+}
+
+// ensureExt appends the default extension if the name has none.
+func (v *vault) ensureExt(name string) string {
+	if filepath.Ext(name) == "" {
+		return name + v.ext
+	}
+	return name
+}
+
+// create writes a note file, creating subdirectories as needed.
+func (v *vault) create(_ context.Context, name, content string) error {
+	p := v.fullPath(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write note: %w", err)
+	}
+	go v.rebuildIndex()
+	return nil
+}
+
+func (v *vault) read(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(v.fullPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", fmt.Errorf("read note: %w", err)
+	}
+	return string(data), nil
+}
+
+func (v *vault) append(_ context.Context, name, content string) error {
+	p := v.fullPath(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open note for append: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		return fmt.Errorf("append to note: %w", err)
+	}
+	go v.rebuildIndex()
+	return nil
+}
+
+func (v *vault) list(_ context.Context, prefix string) ([]NoteInfo, error) {
+	var notes []NoteInfo
+
+	err := filepath.Walk(v.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") && path != v.path {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(v.path, path)
+
+		if prefix != "" && !strings.HasPrefix(relPath, prefix) {
+			return nil
+		}
+
+		data, readErr := os.ReadFile(path)
+		var tags, links []string
+		if readErr == nil {
+			content := string(data)
+			tags = ExtractTags(content)
+			links = ExtractWikilinks(content)
+		}
+
+		notes = append(notes, NoteInfo{
+			Name:    relPath,
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Tags:    tags,
+			Links:   links,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk vault: %w", err)
+	}
+
+	return notes, nil
+}
+
+func (v *vault) search(_ context.Context, query string, maxResults int) ([]SearchResult, error) {
+	return v.idx.search(query, maxResults), nil
+}
+
+// rebuildIndex rebuilds the TF-IDF index, logging any error.
+func (v *vault) rebuildIndex() {
+	if err := v.idx.rebuild(); err != nil {
+		v.logWarn("notes index rebuild failed", "error", err)
+	}
+}
+
+// docEntry stores per-document TF-IDF data.
+type docEntry struct {
+	filename string
+	lines    []string
+	tf       map[string]float64
+	size     int64
+}
+
+// index is a TF-IDF index for notes that supports nested directories via filepath.Walk.
+type index struct {
+	mu        sync.RWMutex
+	docs      map[string]*docEntry // keyed by relative path from vaultPath
+	idf       map[string]float64
+	totalDocs int
+	vaultPath string
+}
+
+// --- index methods ---
+
+// rebuild scans the vault directory recursively and rebuilds the TF-IDF index.
+func (idx *index) rebuild() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	docs := make(map[string]*docEntry)
+	df := make(map[string]int)
+
+	err := filepath.Walk(idx.vaultPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+		if info.IsDir() {
+			// Skip hidden directories.
+			if strings.HasPrefix(info.Name(), ".") && path != idx.vaultPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Skip hidden files.
+		if strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(idx.vaultPath, path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil // skip unreadable files
+		}
+
+		content := string(data)
+		lines := strings.Split(content, "\n")
+		tokens := tokenize(content)
+
+		termCounts := make(map[string]int)
+		for _, tok := range tokens {
+			termCounts[tok]++
+		}
+		total := len(tokens)
+		tf := make(map[string]float64)
+		if total > 0 {
+			for term, count := range termCounts {
+				tf[term] = float64(count) / float64(total)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, tok := range tokens {
+			if !seen[tok] {
+				df[tok]++
+				seen[tok] = true
+			}
+		}
+
+		docs[relPath] = &docEntry{
+			filename: relPath,
+			lines:    lines,
+			tf:       tf,
+			size:     info.Size(),
+		}
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			idx.docs = make(map[string]*docEntry)
+			idx.idf = make(map[string]float64)
+			idx.totalDocs = 0
+			return nil
+		}
+		return err
+	}
+
+	totalDocs := len(docs)
+	idf := make(map[string]float64)
+	for term, docCount := range df {
+		idf[term] = logIDF(float64(totalDocs), float64(docCount))
+	}
+
+	idx.docs = docs
+	idx.idf = idf
+	idx.totalDocs = totalDocs
+	return nil
+}
+
+// search returns notes ranked by TF-IDF score for the given query.
+func (idx *index) search(query string, maxResults int) []SearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	queryTokens := tokenize(query)
+	if len(queryTokens) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		filename  string
+		score     float64
+		matchLine int
+	}
+
+	var results []scored
+	for _, doc := range idx.docs {
+		var score float64
+		for _, qt := range queryTokens {
+			tf, ok := doc.tf[qt]
+			if !ok {
+				continue
+			}
+			idf := idx.idf[qt]
+			score += tf * idf
+		}
+		if score <= 0 {
+			continue
+		}
+
+		bestLine := findBestMatchLine(doc.lines, queryTokens)
+		results = append(results, scored{
+			filename:  doc.filename,
+			score:     score,
+			matchLine: bestLine,
+		})
+	}
+
+	// Sort by score descending (insertion sort — avoids importing sort).
+	for i := 0; i < len(results); i++ {
+		for j := i + 1; j < len(results); j++ {
+			if results[j].score > results[i].score {
+				results[i], results[j] = results[j], results[i]
+			}
+		}
+	}
+
+	if maxResults > 0 && len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	var out []SearchResult
+	for _, r := range results {
+		doc := idx.docs[r.filename]
+		snippet := buildSnippet(doc.lines, r.matchLine, 1)
+		out = append(out, SearchResult{
+			Filename:  r.filename,
+			Snippet:   snippet,
+			Score:     r.score,
+			LineStart: r.matchLine + 1,
+		})
+	}
+	return out
+}
+
+// --- TF-IDF utilities ---
+
+// logIDF computes log(1 + totalDocs / (1 + df)) using the pure-Go ln function.
+func logIDF(totalDocs, docFreq float64) float64 {
+	x := 1.0 + totalDocs/(1.0+docFreq)
+	return Ln(x)
+}
+
+// Ln computes the natural logarithm of x using the identity ln(x) = 2*atanh((x-1)/(x+1))
+// with sufficient precision for TF-IDF scoring. Exported for test access.
+func Ln(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	// Reduce x to [1, 2) range: ln(x * 2^exp) = ln(x) + exp * ln(2).
+	exp := 0
+	for x >= 2.0 {
+		x /= 2.0
+		exp++
+	}
+	for x < 1.0 {
+		x *= 2.0
+		exp--
+	}
+	t := (x - 1.0) / (x + 1.0)
+	t2 := t * t
+	sum := t
+	term := t
+	for i := 3; i <= 21; i += 2 {
+		term *= t2
+		sum += term / float64(i)
+	}
+	const ln2 = 0.6931471805599453
+	return 2.0*sum + float64(exp)*ln2
+}
+
+// tokenize splits text into lowercase tokens, filtering tokens shorter than 2 runes
+// and common stop words.
+func tokenize(text string) []string {
+	var tokens []string
+	current := strings.Builder{}
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			current.WriteRune(r)
+		} else {
+			if current.Len() >= 2 {
+				w := current.String()
+				if !isStopWord(w) {
+					tokens = append(tokens, w)
+				}
+			}
+			current.Reset()
+		}
+	}
+	if current.Len() >= 2 {
+		w := current.String()
+		if !isStopWord(w) {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+func isStopWord(w string) bool {
+	switch w {
+	case "the", "be", "to", "of", "and", "in", "that", "have", "it",
+		"for", "not", "on", "with", "he", "as", "you", "do", "at",
+		"this", "but", "his", "by", "from", "they", "we", "say", "her",
+		"she", "or", "an", "will", "my", "one", "all", "would", "there",
+		"their", "what", "so", "up", "out", "if", "about", "who", "get",
+		"which", "go", "me", "when", "make", "can", "like", "time", "no",
+		"just", "him", "know", "take", "people", "into", "year", "your",
+		"good", "some", "could", "them", "see", "other", "than", "then",
+		"now", "look", "only", "come", "its", "over", "think", "also",
+		"back", "after", "use", "two", "how", "our", "work", "first",
+		"well", "way", "even", "new", "want", "because", "any", "these",
+		"give", "day", "most", "us", "is", "are", "was", "were", "been",
+		"being", "has", "had", "did", "does", "am":
+		return true
+	}
+	return false
+}
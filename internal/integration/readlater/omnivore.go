@@ -0,0 +1,188 @@
+package readlater
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// omnivore saves to and reads from a self-hosted Omnivore server through its
+// GraphQL API.
+type omnivore struct {
+	key  string
+	base string
+}
+
+func newOmnivore(cfg config.ReadLaterConfig) *omnivore {
+	base := strings.TrimSuffix(cfg.Server, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return &omnivore{key: cfg.Token, base: base}
+}
+
+func (o *omnivore) graphql(ctx context.Context, query string, vars map[string]any, out any) error {
+	data, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.base+"/api/graphql", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", o.key)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("omnivore: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("omnivore: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var env struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return fmt.Errorf("omnivore: %w", err)
+	}
+	if len(env.Errors) > 0 {
+		return fmt.Errorf("omnivore: %s", env.Errors[0].Message)
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+const omnivoreSave = `mutation SaveUrl($input: SaveUrlInput!) {
+  saveUrl(input: $input) {
+    ... on SaveSuccess { url clientRequestId }
+    ... on SaveError { errorCodes message }
+  }
+}`
+
+// Save adds the link to the library. Omnivore has no title override for
+// saved URLs, so title is not sent.
+func (o *omnivore) Save(ctx context.Context, link, _ string, tags []string) (string, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	labels := make([]map[string]string, 0, len(tags))
+	for _, t := range tags {
+		labels = append(labels, map[string]string{"name": t})
+	}
+	var out struct {
+		SaveURL struct {
+			ClientRequestID string   `json:"clientRequestId"`
+			ErrorCodes      []string `json:"errorCodes"`
+			Message         string   `json:"message"`
+		} `json:"saveUrl"`
+	}
+	err := o.graphql(ctx, omnivoreSave, map[string]any{"input": map[string]any{
+		"url": link, "clientRequestId": id, "source": "api", "labels": labels,
+	}}, &out)
+	if err != nil {
+		return "", err
+	}
+	if len(out.SaveURL.ErrorCodes) > 0 {
+		return "", fmt.Errorf("omnivore save: %s %s", strings.Join(out.SaveURL.ErrorCodes, ","), out.SaveURL.Message)
+	}
+	return out.SaveURL.ClientRequestID, nil
+}
+
+const omnivoreSearch = `query Search($after: String, $first: Int, $query: String) {
+  search(first: $first, after: $after, query: $query) {
+    ... on SearchSuccess {
+      edges { node {
+        id url originalArticleUrl title savedAt updatedAt readingProgressPercent isArchived
+        highlights { id quote annotation createdAt }
+      } }
+      pageInfo { hasNextPage endCursor }
+    }
+    ... on SearchError { errorCodes }
+  }
+}`
+
+// Documents pages through the library, most recently updated first, until
+// it reaches documents last updated before since.
+func (o *omnivore) Documents(ctx context.Context, since time.Time) ([]Document, error) {
+	var out []Document
+	after := ""
+	for {
+		var page struct {
+			Search struct {
+				Edges []struct {
+					Node struct {
+						ID                 string  `json:"id"`
+						URL                string  `json:"url"`
+						OriginalArticleURL string  `json:"originalArticleUrl"`
+						Title              string  `json:"title"`
+						SavedAt            string  `json:"savedAt"`
+						UpdatedAt          string  `json:"updatedAt"`
+						ReadingProgress    float64 `json:"readingProgressPercent"`
+						IsArchived         bool    `json:"isArchived"`
+						Highlights         []struct {
+							ID         string `json:"id"`
+							Quote      string `json:"quote"`
+							Annotation string `json:"annotation"`
+							CreatedAt  string `json:"createdAt"`
+						} `json:"highlights"`
+					} `json:"node"`
+				} `json:"edges"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				ErrorCodes []string `json:"errorCodes"`
+			} `json:"search"`
+		}
+		vars := map[string]any{"first": 50, "query": "in:all sort:updated-desc"}
+		if after != "" {
+			vars["after"] = after
+		}
+		if err := o.graphql(ctx, omnivoreSearch, vars, &page); err != nil {
+			return nil, err
+		}
+		if len(page.Search.ErrorCodes) > 0 {
+			return nil, fmt.Errorf("omnivore search: %s", strings.Join(page.Search.ErrorCodes, ","))
+		}
+		for _, e := range page.Search.Edges {
+			n := e.Node
+			if updated, err := time.Parse(time.RFC3339, n.UpdatedAt); err == nil && updated.Before(since) {
+				return out, nil
+			}
+			link := n.OriginalArticleURL
+			if link == "" {
+				link = n.URL
+			}
+			saved, _ := time.Parse(time.RFC3339, n.SavedAt)
+			d := Document{
+				RemoteID: n.ID,
+				URL:      link,
+				Title:    n.Title,
+				SavedAt:  saved,
+				Progress: n.ReadingProgress / 100,
+				Read:     n.IsArchived || n.ReadingProgress >= 90,
+			}
+			for _, h := range n.Highlights {
+				d.Highlights = append(d.Highlights, Highlight{ID: h.ID, URL: link, Text: h.Quote, Note: h.Annotation, CreatedAt: h.CreatedAt})
+			}
+			out = append(out, d)
+		}
+		if !page.Search.PageInfo.HasNextPage || page.Search.PageInfo.EndCursor == "" {
+			return out, nil
+		}
+		after = page.Search.PageInfo.EndCursor
+	}
+}
@@ -0,0 +1,583 @@
+// Package readlater saves links to a read-later service and pulls the
+// reading back.
+//
+// Links posted in chat (with autoSave) or saved by agents are sent to the
+// configured service (Readwise Reader, or a self-hosted Omnivore) and
+// recorded in the readlater_items table. Service polls the service for
+// reading progress and highlights; new highlights are stored in
+// readlater_highlights and written to the knowledge base, one file per
+// article. A weekly insight compares what was saved with what was read.
+package readlater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/cron"
+	"tetora/internal/db"
+	"tetora/internal/knowledge"
+	"tetora/internal/log"
+)
+
+// Document is an article in the read-later service.
+type Document struct {
+	RemoteID   string
+	URL        string // original article URL
+	Title      string
+	SavedAt    time.Time
+	Progress   float64 // 0..1
+	Read       bool    // finished or archived
+	Highlights []Highlight
+}
+
+// Highlight is a passage highlighted in a document.
+type Highlight struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Text      string `json:"text"`
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Client talks to one read-later service.
+type Client interface {
+	// Save adds a link to the reading list and returns its remote ID.
+	Save(ctx context.Context, link, title string, tags []string) (string, error)
+	// Documents returns the documents updated after since, with their
+	// highlights.
+	Documents(ctx context.Context, since time.Time) ([]Document, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// NewClient returns the client for cfg.Service.
+func NewClient(cfg config.ReadLaterConfig) (Client, error) {
+	switch cfg.Service {
+	case "readwise":
+		return newReadwise(cfg), nil
+	case "omnivore":
+		if cfg.Server == "" {
+			return nil, fmt.Errorf("readLater.server is required for omnivore (the hosted service has shut down)")
+		}
+		return newOmnivore(cfg), nil
+	case "pocket":
+		return nil, fmt.Errorf("pocket has shut down; use readwise or a self-hosted omnivore")
+	}
+	return nil, fmt.Errorf("readLater: unknown service %q (use \"readwise\" or \"omnivore\")", cfg.Service)
+}
+
+// --- Links ---
+
+var linkRe = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// Links returns the distinct http(s) links in chat text.
+func Links(text string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, m := range linkRe.FindAllString(text, -1) {
+		m = strings.TrimRight(m, ".,;:!?)]}*_|")
+		u, err := url.Parse(m)
+		if err != nil || u.Host == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	return out
+}
+
+// --- Service ---
+
+// Item is a saved link.
+type Item struct {
+	URL        string  `json:"url"`
+	Title      string  `json:"title,omitempty"`
+	Source     string  `json:"source"` // "discord", "telegram", "tool", "http" or "remote" (saved outside Tetora)
+	RemoteID   string  `json:"remoteId,omitempty"`
+	SavedAt    string  `json:"savedAt"`
+	ReadAt     string  `json:"readAt,omitempty"`
+	Progress   float64 `json:"progress"`
+	Highlights int     `json:"highlights"`
+	Knowledge  string  `json:"knowledge,omitempty"` // knowledge base file with the highlights
+}
+
+// Deps holds the root-package callbacks the service needs.
+type Deps struct {
+	// Notify sends the weekly insight to the owner.
+	Notify func(text string)
+	// WriteNote also keeps each link's highlights as a note, e.g. in a
+	// Notion workspace. May be nil.
+	WriteNote func(ctx context.Context, name, content string) error
+}
+
+// Service saves links and syncs reading progress and highlights.
+type Service struct {
+	cfg          config.ReadLaterConfig
+	dbPath       string
+	knowledgeDir string
+	client       Client
+	deps         Deps
+
+	syncMu sync.Mutex
+}
+
+// New creates the service for cfg. Call Start to sync on a schedule.
+func New(cfg config.ReadLaterConfig, dbPath, knowledgeDir string, deps Deps) (*Service, error) {
+	c, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(cfg, dbPath, knowledgeDir, c, deps), nil
+}
+
+// NewWithClient creates a service over an existing client.
+func NewWithClient(cfg config.ReadLaterConfig, dbPath, knowledgeDir string, c Client, deps Deps) *Service {
+	return &Service{cfg: cfg, dbPath: dbPath, knowledgeDir: knowledgeDir, client: c, deps: deps}
+}
+
+// InitDB creates the readlater tables.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS readlater_items (
+		url TEXT PRIMARY KEY,
+		title TEXT DEFAULT '',
+		source TEXT DEFAULT '',
+		remote_id TEXT DEFAULT '',
+		saved_at TEXT NOT NULL,
+		read_at TEXT DEFAULT '',
+		progress REAL DEFAULT 0,
+		highlights INTEGER DEFAULT 0,
+		knowledge_file TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_readlater_items_saved ON readlater_items(saved_at);
+	CREATE TABLE IF NOT EXISTS readlater_highlights (
+		id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		text TEXT NOT NULL,
+		note TEXT DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_readlater_highlights_url ON readlater_highlights(url);
+	CREATE TABLE IF NOT EXISTS readlater_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Save adds link to the reading list unless it is already there. It reports
+// whether the link was new.
+func (s *Service) Save(ctx context.Context, link, title, source string) (*Item, bool, error) {
+	if it, err := s.item(link); err != nil || it != nil {
+		return it, false, err
+	}
+	id, err := s.client.Save(ctx, link, title, s.cfg.TagsOrDefault())
+	if err != nil {
+		return nil, false, err
+	}
+	now := formatTime(time.Now())
+	if err := db.ExecArgs(s.dbPath, `INSERT OR IGNORE INTO readlater_items (url, title, source, remote_id, saved_at)
+		VALUES (?, ?, ?, ?, ?)`, link, title, source, id, now); err != nil {
+		return nil, false, err
+	}
+	log.Info("readlater: saved", "url", link, "source", source)
+	return &Item{URL: link, Title: title, Source: source, RemoteID: id, SavedAt: now}, true, nil
+}
+
+// SaveLinks saves every link in chat text and returns the ones that were new.
+func (s *Service) SaveLinks(ctx context.Context, text, source string) []string {
+	var saved []string
+	for _, link := range Links(text) {
+		_, added, err := s.Save(ctx, link, "", source)
+		if err != nil {
+			log.Warn("readlater: save failed", "url", link, "error", err)
+			continue
+		}
+		if added {
+			saved = append(saved, link)
+		}
+	}
+	return saved
+}
+
+func (s *Service) item(link string) (*Item, error) {
+	items, err := s.query(`SELECT * FROM readlater_items WHERE url = ?`, link)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// Items returns saved links, newest first, optionally only unread ones.
+func (s *Service) Items(unreadOnly bool, limit int) ([]Item, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	q := `SELECT * FROM readlater_items`
+	if unreadOnly {
+		q += ` WHERE read_at = ''`
+	}
+	return s.query(q+` ORDER BY saved_at DESC LIMIT ?`, limit)
+}
+
+func (s *Service) query(q string, args ...any) ([]Item, error) {
+	rows, err := db.QueryArgs(s.dbPath, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Item, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Item{
+			URL:        db.Str(r["url"]),
+			Title:      db.Str(r["title"]),
+			Source:     db.Str(r["source"]),
+			RemoteID:   db.Str(r["remote_id"]),
+			SavedAt:    db.Str(r["saved_at"]),
+			ReadAt:     db.Str(r["read_at"]),
+			Progress:   db.Float(r["progress"]),
+			Highlights: db.Int(r["highlights"]),
+			Knowledge:  db.Str(r["knowledge_file"]),
+		})
+	}
+	return out, nil
+}
+
+// --- Sync ---
+
+// SyncResult counts what a sync changed.
+type SyncResult struct {
+	Documents  int `json:"documents"`
+	Highlights int `json:"highlights"` // new highlights
+	Read       int `json:"read"`       // documents newly finished
+}
+
+// firstSync is how far back the first sync looks.
+const firstSync = 30 * 24 * time.Hour
+
+// Sync pulls reading progress and highlights changed since the last sync.
+func (s *Service) Sync(ctx context.Context) (SyncResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	var res SyncResult
+	start := time.Now()
+	since := start.Add(-firstSync)
+	if v, _ := s.state("lastSync"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			// Overlap a little so edits made during the last sync are not missed.
+			since = t.Add(-time.Hour)
+		}
+	}
+	docs, err := s.client.Documents(ctx, since)
+	if err != nil {
+		return res, err
+	}
+	for _, d := range docs {
+		if d.URL == "" {
+			continue
+		}
+		read, added, err := s.syncDocument(ctx, d, start)
+		if err != nil {
+			return res, err
+		}
+		res.Documents++
+		res.Highlights += added
+		if read {
+			res.Read++
+		}
+	}
+	if err := s.setState("lastSync", formatTime(start)); err != nil {
+		return res, err
+	}
+	if res.Highlights > 0 || res.Read > 0 {
+		log.Info("readlater: synced", "documents", res.Documents, "highlights", res.Highlights, "read", res.Read)
+	}
+	return res, nil
+}
+
+// syncDocument records one document and its new highlights. It reports
+// whether the document was newly read and how many highlights were new.
+func (s *Service) syncDocument(ctx context.Context, d Document, now time.Time) (newlyRead bool, added int, err error) {
+	it, err := s.item(d.URL)
+	if err != nil {
+		return false, 0, err
+	}
+	if it == nil {
+		savedAt := d.SavedAt
+		if savedAt.IsZero() {
+			savedAt = now
+		}
+		it = &Item{URL: d.URL, Source: "remote", SavedAt: formatTime(savedAt)}
+		if err := db.ExecArgs(s.dbPath, `INSERT OR IGNORE INTO readlater_items (url, title, source, remote_id, saved_at)
+			VALUES (?, ?, 'remote', ?, ?)`, d.URL, d.Title, d.RemoteID, it.SavedAt); err != nil {
+			return false, 0, err
+		}
+	}
+	readAt := it.ReadAt
+	if d.Read && readAt == "" {
+		readAt, newlyRead = formatTime(now), true
+	}
+	title := it.Title
+	if title == "" {
+		title = d.Title
+	}
+	if err := db.ExecArgs(s.dbPath, `UPDATE readlater_items SET title = ?, remote_id = ?, progress = ?, read_at = ? WHERE url = ?`,
+		title, d.RemoteID, d.Progress, readAt, d.URL); err != nil {
+		return false, 0, err
+	}
+
+	for _, h := range d.Highlights {
+		rows, err := db.QueryArgs(s.dbPath, `SELECT 1 AS seen FROM readlater_highlights WHERE id = ?`, h.ID)
+		if err != nil {
+			return newlyRead, added, err
+		}
+		if len(rows) > 0 || strings.TrimSpace(h.Text) == "" {
+			continue
+		}
+		if err := db.ExecArgs(s.dbPath, `INSERT OR IGNORE INTO readlater_highlights (id, url, text, note, created_at)
+			VALUES (?, ?, ?, ?, ?)`, h.ID, d.URL, h.Text, h.Note, h.CreatedAt); err != nil {
+			return newlyRead, added, err
+		}
+		added++
+	}
+	if added == 0 {
+		return newlyRead, 0, nil
+	}
+	file := it.Knowledge
+	if s.cfg.KnowledgeEnabled() && (s.knowledgeDir != "" || s.deps.WriteNote != nil) {
+		if file, err = s.writeKnowledge(ctx, d.URL, title, it.SavedAt); err != nil {
+			log.Warn("readlater: write highlights to knowledge base failed", "url", d.URL, "error", err)
+		}
+	}
+	err = db.ExecArgs(s.dbPath, `UPDATE readlater_items SET knowledge_file = ?,
+		highlights = (SELECT COUNT(*) FROM readlater_highlights WHERE url = ?) WHERE url = ?`, file, d.URL, d.URL)
+	return newlyRead, added, err
+}
+
+// Highlights returns the highlights of a saved link, oldest first.
+func (s *Service) Highlights(link string) ([]Highlight, error) {
+	rows, err := db.QueryArgs(s.dbPath, `SELECT * FROM readlater_highlights WHERE url = ? ORDER BY created_at, id`, link)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Highlight, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Highlight{
+			ID:        db.Str(r["id"]),
+			URL:       db.Str(r["url"]),
+			Text:      db.Str(r["text"]),
+			Note:      db.Str(r["note"]),
+			CreatedAt: db.Str(r["created_at"]),
+		})
+	}
+	return out, nil
+}
+
+// writeKnowledge rewrites the knowledge base file holding the highlights of
+// link, and the note of the same name when Deps.WriteNote is set, and
+// returns its name. The same URL always maps to the same file.
+func (s *Service) writeKnowledge(ctx context.Context, link, title, savedAt string) (string, error) {
+	hs, err := s.Highlights(link)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(link))
+	host := strings.NewReplacer(".", "-", ":", "-").Replace(u.Hostname())
+	name := fmt.Sprintf("readlater-%s-%s.md", host, hex.EncodeToString(sum[:4]))
+	if err := knowledge.ValidateFilename(name); err != nil {
+		return "", err
+	}
+	if title == "" {
+		title = link
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\nSource: %s\nSaved: %s\n\n## Highlights\n", title, link, dateOf(savedAt))
+	for _, h := range hs {
+		b.WriteString("\n> " + strings.ReplaceAll(strings.TrimSpace(h.Text), "\n", "\n> ") + "\n")
+		if h.Note != "" {
+			b.WriteString("\nNote: " + h.Note + "\n")
+		}
+	}
+	if s.knowledgeDir != "" {
+		if err := os.MkdirAll(s.knowledgeDir, 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(s.knowledgeDir, name), []byte(b.String()), 0o644); err != nil {
+			return "", err
+		}
+	}
+	if s.deps.WriteNote != nil {
+		if err := s.deps.WriteNote(ctx, strings.TrimSuffix(name, ".md"), b.String()); err != nil {
+			return name, fmt.Errorf("write note: %w", err)
+		}
+	}
+	return name, nil
+}
+
+// --- Insight ---
+
+// Insight compares what was saved with what was read over a period.
+type Insight struct {
+	Days        int    `json:"days"`
+	Saved       int    `json:"saved"`
+	Read        int    `json:"read"`        // finished in the period, whenever saved
+	ReadOfSaved int    `json:"readOfSaved"` // saved in the period and already finished
+	Highlights  int    `json:"highlights"`
+	Backlog     int    `json:"backlog"` // unread overall
+	Oldest      []Item `json:"oldest,omitempty"`
+}
+
+// Insight computes the insight for the days before now.
+func (s *Service) Insight(now time.Time, days int) (*Insight, error) {
+	if days <= 0 {
+		days = 7
+	}
+	since := formatTime(now.AddDate(0, 0, -days))
+	rows, err := db.QueryArgs(s.dbPath, `SELECT
+		(SELECT COUNT(*) FROM readlater_items WHERE saved_at >= ?) AS saved,
+		(SELECT COUNT(*) FROM readlater_items WHERE read_at != '' AND read_at >= ?) AS read,
+		(SELECT COUNT(*) FROM readlater_items WHERE saved_at >= ? AND read_at != '') AS read_of_saved,
+		(SELECT COUNT(*) FROM readlater_highlights WHERE created_at >= ?) AS highlights,
+		(SELECT COUNT(*) FROM readlater_items WHERE read_at = '') AS backlog`,
+		since, since, since, since)
+	if err != nil {
+		return nil, err
+	}
+	in := &Insight{Days: days}
+	if len(rows) > 0 {
+		r := rows[0]
+		in.Saved, in.Read, in.ReadOfSaved = db.Int(r["saved"]), db.Int(r["read"]), db.Int(r["read_of_saved"])
+		in.Highlights, in.Backlog = db.Int(r["highlights"]), db.Int(r["backlog"])
+	}
+	if in.Oldest, err = s.query(`SELECT * FROM readlater_items WHERE read_at = '' ORDER BY saved_at LIMIT 3`); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// Format renders the insight as a chat message.
+func (in *Insight) Format() string {
+	var b strings.Builder
+	period := "This week"
+	if in.Days != 7 {
+		period = fmt.Sprintf("Last %d days", in.Days)
+	}
+	fmt.Fprintf(&b, "%s in your reading list: saved %d, read %d", period, in.Saved, in.Read)
+	if in.Saved > 0 {
+		fmt.Fprintf(&b, " (%d of the %d new ones, %d%%)", in.ReadOfSaved, in.Saved, in.ReadOfSaved*100/in.Saved)
+	}
+	fmt.Fprintf(&b, ", %d highlight(s).\n%d unread in total.", in.Highlights, in.Backlog)
+	if len(in.Oldest) > 0 {
+		b.WriteString("\nOldest unread:")
+		for _, it := range in.Oldest {
+			title := it.Title
+			if title == "" {
+				title = it.URL
+			}
+			fmt.Fprintf(&b, "\n- %s (saved %s)", title, dateOf(it.SavedAt))
+		}
+	}
+	return b.String()
+}
+
+// --- Schedule ---
+
+// Start syncs every syncInterval and sends the insight on its schedule
+// until ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	var expr *cron.Expr
+	if spec := s.cfg.InsightOrDefault(); spec != "" {
+		e, err := cron.Parse(spec)
+		if err != nil {
+			log.Warn("readlater: invalid insight schedule, insight disabled", "insight", spec, "error", err)
+		} else {
+			expr = &e
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		var nextSync time.Time
+		for {
+			now := time.Now()
+			if !now.Before(nextSync) {
+				if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+					log.Warn("readlater: sync failed", "error", err)
+				}
+				nextSync = now.Add(s.cfg.SyncIntervalOrDefault())
+			}
+			if expr != nil {
+				s.maybeSendInsight(*expr, now)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// maybeSendInsight sends the insight when its schedule has come up since
+// the last one was sent.
+func (s *Service) maybeSendInsight(expr cron.Expr, now time.Time) {
+	last, _ := s.state("lastInsight")
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		// First run: start counting from now rather than sending at once.
+		s.setState("lastInsight", formatTime(now))
+		return
+	}
+	if now.Before(cron.NextRunAfter(expr, time.Local, t.In(time.Local))) {
+		return
+	}
+	if err := s.setState("lastInsight", formatTime(now)); err != nil {
+		log.Warn("readlater: record insight failed", "error", err)
+		return
+	}
+	in, err := s.Insight(now, 7)
+	if err != nil {
+		log.Warn("readlater: insight failed", "error", err)
+		return
+	}
+	if s.deps.Notify != nil {
+		s.deps.Notify(in.Format())
+	}
+}
+
+func (s *Service) state(key string) (string, error) {
+	rows, err := db.QueryArgs(s.dbPath, `SELECT value FROM readlater_state WHERE key = ?`, key)
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	return db.Str(rows[0]["value"]), nil
+}
+
+func (s *Service) setState(key, value string) error {
+	return db.ExecArgs(s.dbPath, `INSERT INTO readlater_state (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+}
+
+func formatTime(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+
+func dateOf(ts string) string {
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t.Local().Format("2006-01-02")
+	}
+	return ts
+}
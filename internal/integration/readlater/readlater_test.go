@@ -0,0 +1,199 @@
+package readlater
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+func TestReadwise_SaveAndDocuments(t *testing.T) {
+	var saved map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v3/save/":
+			json.NewDecoder(r.Body).Decode(&saved)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"d9","url":"https://read.readwise.io/read/d9"}`))
+		case r.URL.Path == "/api/v3/list/" && r.URL.Query().Get("id") == "d2":
+			w.Write([]byte(`{"results":[{"id":"d2","source_url":"https://b.example/","title":"B","category":"article","location":"later","reading_progress":0.3}]}`))
+		case r.URL.Path == "/api/v3/list/" && r.URL.Query().Get("pageCursor") == "":
+			w.Write([]byte(`{"nextPageCursor":"p2","results":[
+				{"id":"d1","source_url":"https://a.example/","title":"A","category":"article","location":"archive","reading_progress":0.5,"saved_at":"2026-10-01T10:00:00Z"},
+				{"id":"h1","category":"highlight","parent_id":"d1","content":"first","notes":"n1","created_at":"2026-10-02T10:00:00Z"}]}`))
+		case r.URL.Path == "/api/v3/list/":
+			w.Write([]byte(`{"results":[{"id":"h2","category":"highlight","parent_id":"d2","content":"second","created_at":"2026-10-03T10:00:00Z"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.ReadLaterConfig{Service: "readwise", Token: "tok", Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.Save(context.Background(), "https://c.example/", "C", []string{"tetora"})
+	if err != nil || id != "d9" {
+		t.Fatalf("save = %q, %v", id, err)
+	}
+	if saved["location"] != "later" || saved["title"] != "C" {
+		t.Errorf("save body = %v", saved)
+	}
+
+	docs, err := c.Documents(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("docs = %+v", docs)
+	}
+	a, b := docs[0], docs[1]
+	if !a.Read || a.URL != "https://a.example/" || len(a.Highlights) != 1 || a.Highlights[0].Note != "n1" {
+		t.Errorf("a = %+v", a)
+	}
+	if b.Read || b.Title != "B" || len(b.Highlights) != 1 || b.Highlights[0].Text != "second" {
+		t.Errorf("b = %+v", b)
+	}
+}
+
+func TestOmnivore_SaveAndDocuments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" || r.Header.Get("Authorization") != "key" {
+			http.NotFound(w, r)
+			return
+		}
+		var in struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		if strings.Contains(in.Query, "saveUrl") {
+			id := in.Variables["input"].(map[string]any)["clientRequestId"]
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"saveUrl": map[string]any{"url": "u", "clientRequestId": id}}})
+			return
+		}
+		w.Write([]byte(`{"data":{"search":{"edges":[
+			{"node":{"id":"o1","url":"https://omni/o1","originalArticleUrl":"https://a.example/","title":"A","updatedAt":"2026-10-10T00:00:00Z","readingProgressPercent":95,
+				"highlights":[{"id":"h1","quote":"q","annotation":"note","createdAt":"2026-10-09T00:00:00Z"}]}},
+			{"node":{"id":"o2","url":"https://b.example/","updatedAt":"2020-01-01T00:00:00Z"}}],
+			"pageInfo":{"hasNextPage":true,"endCursor":"c"}}}}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.ReadLaterConfig{Service: "omnivore", Token: "key", Server: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := c.Save(context.Background(), "https://a.example/", "", []string{"tetora"}); err != nil || id == "" {
+		t.Fatalf("save = %q, %v", id, err)
+	}
+	docs, err := c.Documents(context.Background(), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Paging stops at the document updated before since.
+	if len(docs) != 1 || !docs[0].Read || docs[0].URL != "https://a.example/" || docs[0].Highlights[0].Note != "note" {
+		t.Errorf("docs = %+v", docs)
+	}
+}
+
+type fakeClient struct {
+	saved []string
+	docs  []Document
+}
+
+func (f *fakeClient) Save(_ context.Context, link, _ string, _ []string) (string, error) {
+	f.saved = append(f.saved, link)
+	return "r" + link, nil
+}
+
+func (f *fakeClient) Documents(context.Context, time.Time) ([]Document, error) { return f.docs, nil }
+
+func TestService_SaveSyncInsight(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	fc := &fakeClient{}
+	notes := map[string]string{}
+	s := NewWithClient(config.ReadLaterConfig{Service: "readwise", Token: "t"}, dbPath, filepath.Join(dir, "knowledge"), fc, Deps{
+		WriteNote: func(_ context.Context, name, content string) error {
+			notes[name] = content
+			return nil
+		},
+	})
+	ctx := context.Background()
+
+	got := s.SaveLinks(ctx, "look at https://a.example/x. and <https://b.example/y>", "discord")
+	if len(got) != 2 || got[0] != "https://a.example/x" || got[1] != "https://b.example/y" {
+		t.Fatalf("saved = %v", got)
+	}
+	if again := s.SaveLinks(ctx, "https://a.example/x", "telegram"); len(again) != 0 || len(fc.saved) != 2 {
+		t.Errorf("duplicate link saved again: %v", fc.saved)
+	}
+
+	fc.docs = []Document{
+		{RemoteID: "1", URL: "https://a.example/x", Title: "Article A", Read: true, Progress: 1, Highlights: []Highlight{
+			{ID: "h1", Text: "a good line", Note: "remember", CreatedAt: time.Now().UTC().Format(time.RFC3339)},
+		}},
+		{RemoteID: "2", URL: "https://c.example/z", Title: "C", SavedAt: time.Now().Add(-30 * 24 * time.Hour)},
+	}
+	res, err := s.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Documents != 2 || res.Highlights != 1 || res.Read != 1 {
+		t.Errorf("sync = %+v", res)
+	}
+	if res, _ := s.Sync(ctx); res.Highlights != 0 || res.Read != 0 {
+		t.Errorf("second sync = %+v", res)
+	}
+
+	items, err := s.Items(false, 10)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("items = %+v, %v", items, err)
+	}
+	var a Item
+	for _, it := range items {
+		if it.URL == "https://a.example/x" {
+			a = it
+		}
+	}
+	if a.ReadAt == "" || a.Title != "Article A" || a.Highlights != 1 || a.Knowledge == "" {
+		t.Errorf("a = %+v", a)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "knowledge", a.Knowledge))
+	if err != nil || !strings.Contains(string(data), "> a good line") || !strings.Contains(string(data), "Note: remember") {
+		t.Errorf("knowledge file = %q, %v", data, err)
+	}
+	if note := notes[strings.TrimSuffix(a.Knowledge, ".md")]; note != string(data) {
+		t.Errorf("note = %q", note)
+	}
+
+	in, err := s.Insight(time.Now(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Saved != 2 || in.Read != 1 || in.ReadOfSaved != 1 || in.Highlights != 1 || in.Backlog != 2 {
+		t.Errorf("insight = %+v", in)
+	}
+	if len(in.Oldest) == 0 || in.Oldest[0].URL != "https://c.example/z" {
+		t.Errorf("oldest = %+v", in.Oldest)
+	}
+	if msg := in.Format(); !strings.Contains(msg, "saved 2, read 1 (1 of the 2 new ones, 50%)") {
+		t.Errorf("format = %q", msg)
+	}
+}
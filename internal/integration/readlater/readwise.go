@@ -0,0 +1,170 @@
+package readlater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// readwise saves to Readwise Reader and reads documents and highlights
+// back through the Reader list API, where highlights are child documents.
+type readwise struct {
+	token string
+	base  string
+}
+
+func newReadwise(cfg config.ReadLaterConfig) *readwise {
+	base := strings.TrimSuffix(cfg.Server, "/")
+	if base == "" {
+		base = "https://readwise.io"
+	}
+	return &readwise{token: cfg.Token, base: base}
+}
+
+func (r *readwise) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+r.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("readwise %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("readwise %s: status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Save adds the link to Reader's "later" list. Saving a link that is
+// already there returns the existing document.
+func (r *readwise) Save(ctx context.Context, link, title string, tags []string) (string, error) {
+	in := map[string]any{"url": link, "location": "later", "tags": tags, "saved_using": "tetora"}
+	if title != "" {
+		in["title"] = title
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/api/v3/save/", in, &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+type readerDoc struct {
+	ID              string  `json:"id"`
+	SourceURL       string  `json:"source_url"`
+	Title           string  `json:"title"`
+	Category        string  `json:"category"`
+	Location        string  `json:"location"`
+	ReadingProgress float64 `json:"reading_progress"`
+	SavedAt         string  `json:"saved_at"`
+	CreatedAt       string  `json:"created_at"`
+	ParentID        string  `json:"parent_id"`
+	Content         string  `json:"content"`
+	Notes           string  `json:"notes"`
+}
+
+func (r *readwise) list(ctx context.Context, query url.Values) ([]readerDoc, error) {
+	var docs []readerDoc
+	for {
+		var page struct {
+			Results        []readerDoc `json:"results"`
+			NextPageCursor string      `json:"nextPageCursor"`
+		}
+		if err := r.do(ctx, http.MethodGet, "/api/v3/list/?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		docs = append(docs, page.Results...)
+		if page.NextPageCursor == "" {
+			return docs, nil
+		}
+		query.Set("pageCursor", page.NextPageCursor)
+	}
+}
+
+// Documents returns the Reader documents updated after since. Highlights
+// made on documents that did not change themselves are attached to their
+// parent, which is fetched by ID.
+func (r *readwise) Documents(ctx context.Context, since time.Time) ([]Document, error) {
+	all, err := r.list(ctx, url.Values{"updatedAfter": {since.UTC().Format(time.RFC3339)}})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Document)
+	var order []string
+	add := func(d readerDoc) {
+		if _, ok := byID[d.ID]; ok {
+			return
+		}
+		saved, _ := time.Parse(time.RFC3339, d.SavedAt)
+		byID[d.ID] = &Document{
+			RemoteID: d.ID,
+			URL:      d.SourceURL,
+			Title:    d.Title,
+			SavedAt:  saved,
+			Progress: d.ReadingProgress,
+			Read:     d.Location == "archive" || d.ReadingProgress >= 0.9,
+		}
+		order = append(order, d.ID)
+	}
+	var highlights []readerDoc
+	for _, d := range all {
+		if d.Category == "highlight" || d.Category == "note" {
+			highlights = append(highlights, d)
+			continue
+		}
+		add(d)
+	}
+	for _, h := range highlights {
+		if h.ParentID == "" {
+			continue
+		}
+		if _, ok := byID[h.ParentID]; !ok {
+			parents, err := r.list(ctx, url.Values{"id": {h.ParentID}})
+			if err != nil {
+				return nil, err
+			}
+			if len(parents) == 0 {
+				continue
+			}
+			add(parents[0])
+		}
+		d := byID[h.ParentID]
+		d.Highlights = append(d.Highlights, Highlight{
+			ID:        h.ID,
+			URL:       d.URL,
+			Text:      h.Content,
+			Note:      h.Notes,
+			CreatedAt: h.CreatedAt,
+		})
+	}
+	out := make([]Document, 0, len(order))
+	for _, id := range order {
+		out = append(out, *byID[id])
+	}
+	return out, nil
+}
@@ -0,0 +1,270 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// blueskyPDS is the default personal data server.
+const blueskyPDS = "https://bsky.social"
+
+var linkRe = regexp.MustCompile(`https?://[^\s<>"]+[^\s<>".,;:!?)\]]`)
+
+// bluesky posts through the AT Protocol XRPC API with an app password.
+type bluesky struct {
+	acct config.SocialAccount
+	base string
+
+	mu     sync.Mutex
+	access string
+	did    string
+}
+
+func newBluesky(acct config.SocialAccount) *bluesky {
+	base := strings.TrimSuffix(acct.Server, "/")
+	if base == "" {
+		base = blueskyPDS
+	}
+	return &bluesky{acct: acct, base: base}
+}
+
+// xrpcError is an XRPC error response.
+type xrpcError struct {
+	Status  int
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *xrpcError) Error() string {
+	return fmt.Sprintf("bluesky: %s (status %d): %s", e.Code, e.Status, e.Message)
+}
+
+// session logs in with the app password unless a session is open.
+func (b *bluesky) session(ctx context.Context, renew bool) (string, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.access != "" && !renew {
+		return b.access, b.did, nil
+	}
+	var out struct {
+		AccessJwt string `json:"accessJwt"`
+		DID       string `json:"did"`
+	}
+	body := map[string]string{"identifier": b.acct.Handle, "password": b.acct.AppPassword}
+	if err := b.do(ctx, http.MethodPost, "com.atproto.server.createSession", "", nil, body, &out); err != nil {
+		return "", "", err
+	}
+	b.access, b.did = out.AccessJwt, out.DID
+	return b.access, b.did, nil
+}
+
+// call runs an authenticated XRPC call, logging in again once when the
+// session has expired.
+func (b *bluesky) call(ctx context.Context, method, nsid string, query url.Values, in, out any) error {
+	token, _, err := b.session(ctx, false)
+	if err != nil {
+		return err
+	}
+	err = b.do(ctx, method, nsid, token, query, in, out)
+	var xe *xrpcError
+	if errors.As(err, &xe) && (xe.Status == http.StatusUnauthorized || xe.Code == "ExpiredToken" || xe.Code == "InvalidToken") {
+		if token, _, err = b.session(ctx, true); err != nil {
+			return err
+		}
+		err = b.do(ctx, method, nsid, token, query, in, out)
+	}
+	return err
+}
+
+func (b *bluesky) do(ctx context.Context, method, nsid, token string, query url.Values, in, out any) error {
+	u := b.base + "/xrpc/" + nsid
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bluesky %s: %w", nsid, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusOK {
+		xe := &xrpcError{Status: resp.StatusCode}
+		if json.Unmarshal(data, xe) != nil || xe.Code == "" {
+			xe.Code, xe.Message = http.StatusText(resp.StatusCode), strings.TrimSpace(string(data))
+		}
+		return xe
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+type strongRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// Post creates an app.bsky.feed.post record. Links become link facets so
+// they are clickable; a reply names the thread's root and its parent.
+func (b *bluesky) Post(ctx context.Context, text, replyTo string) (*Post, error) {
+	if err := checkLength(b.acct, text); err != nil {
+		return nil, err
+	}
+	record := map[string]any{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if facets := linkFacets(text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+	if replyTo != "" {
+		reply, err := b.replyRef(ctx, replyTo)
+		if err != nil {
+			return nil, err
+		}
+		record["reply"] = reply
+	}
+	_, did, err := b.session(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	var out strongRef
+	if err := b.call(ctx, http.MethodPost, "com.atproto.repo.createRecord", nil, map[string]any{
+		"repo":       did,
+		"collection": "app.bsky.feed.post",
+		"record":     record,
+	}, &out); err != nil {
+		return nil, err
+	}
+	return &Post{ID: out.URI, URL: postURL(b.acct.Handle, out.URI)}, nil
+}
+
+// replyRef looks up the post uri replies to and the root of its thread.
+func (b *bluesky) replyRef(ctx context.Context, uri string) (map[string]strongRef, error) {
+	var out struct {
+		Posts []struct {
+			URI    string `json:"uri"`
+			CID    string `json:"cid"`
+			Record struct {
+				Reply *struct {
+					Root strongRef `json:"root"`
+				} `json:"reply"`
+			} `json:"record"`
+		} `json:"posts"`
+	}
+	if err := b.call(ctx, http.MethodGet, "app.bsky.feed.getPosts", url.Values{"uris": {uri}}, nil, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Posts) == 0 {
+		return nil, fmt.Errorf("bluesky: post %s not found", uri)
+	}
+	p := out.Posts[0]
+	parent := strongRef{URI: p.URI, CID: p.CID}
+	root := parent
+	if p.Record.Reply != nil && p.Record.Reply.Root.URI != "" {
+		root = p.Record.Reply.Root
+	}
+	return map[string]strongRef{"root": root, "parent": parent}, nil
+}
+
+// linkFacets marks the URLs in text, by UTF-8 byte offsets.
+func linkFacets(text string) []map[string]any {
+	var facets []map[string]any
+	for _, m := range linkRe.FindAllStringIndex(text, -1) {
+		facets = append(facets, map[string]any{
+			"index": map[string]int{"byteStart": m[0], "byteEnd": m[1]},
+			"features": []map[string]string{{
+				"$type": "app.bsky.richtext.facet#link",
+				"uri":   text[m[0]:m[1]],
+			}},
+		})
+	}
+	return facets
+}
+
+// Mentions lists the latest mention, reply and quote notifications.
+func (b *bluesky) Mentions(ctx context.Context) ([]Mention, error) {
+	var out struct {
+		Notifications []struct {
+			URI    string `json:"uri"`
+			Reason string `json:"reason"`
+			Author struct {
+				Handle      string `json:"handle"`
+				DisplayName string `json:"displayName"`
+			} `json:"author"`
+			Record struct {
+				Text      string `json:"text"`
+				CreatedAt string `json:"createdAt"`
+			} `json:"record"`
+			IndexedAt string `json:"indexedAt"`
+		} `json:"notifications"`
+	}
+	if err := b.call(ctx, http.MethodGet, "app.bsky.notification.listNotifications", url.Values{"limit": {"50"}}, nil, &out); err != nil {
+		return nil, err
+	}
+	var ms []Mention
+	for _, n := range out.Notifications {
+		if n.Reason != "mention" && n.Reason != "reply" && n.Reason != "quote" {
+			continue
+		}
+		ms = append(ms, Mention{
+			ID:           n.URI,
+			Author:       n.Author.DisplayName,
+			AuthorHandle: n.Author.Handle,
+			Kind:         n.Reason,
+			Text:         n.Record.Text,
+			PostID:       n.URI,
+			URL:          postURL(n.Author.Handle, n.URI),
+			CreatedAt:    cmpOr(n.Record.CreatedAt, n.IndexedAt),
+		})
+	}
+	return ms, nil
+}
+
+// postURL turns at://<did>/app.bsky.feed.post/<rkey> into a bsky.app link.
+func postURL(handle, uri string) string {
+	rkey := uri[strings.LastIndex(uri, "/")+1:]
+	if handle == "" || rkey == "" {
+		return ""
+	}
+	return "https://bsky.app/profile/" + handle + "/post/" + rkey
+}
+
+func cmpOr(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
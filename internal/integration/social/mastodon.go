@@ -0,0 +1,144 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"tetora/internal/config"
+)
+
+// mastodon posts through the Mastodon REST API with an access token.
+type mastodon struct {
+	acct config.SocialAccount
+	base string
+}
+
+func newMastodon(acct config.SocialAccount) *mastodon {
+	base := strings.TrimSuffix(acct.Server, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return &mastodon{acct: acct, base: base}
+}
+
+func (m *mastodon) do(ctx context.Context, method, path string, in map[string]any, out any) error {
+	var body io.Reader
+	var key string
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		sum := sha256.Sum256(data)
+		key = hex.EncodeToString(sum[:16])
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.acct.AccessToken)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+		// A retried request with the same body does not post twice.
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("mastodon %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("mastodon %s: status %d: %s", path, resp.StatusCode, e.Error)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Post publishes a status, as a reply to the status replyTo when set.
+func (m *mastodon) Post(ctx context.Context, text, replyTo string) (*Post, error) {
+	if err := checkLength(m.acct, text); err != nil {
+		return nil, err
+	}
+	in := map[string]any{"status": text}
+	if replyTo != "" {
+		in["in_reply_to_id"] = replyTo
+	}
+	var out struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := m.do(ctx, http.MethodPost, "/api/v1/statuses", in, &out); err != nil {
+		return nil, err
+	}
+	return &Post{ID: out.ID, URL: out.URL}, nil
+}
+
+// Mentions lists the latest mention notifications, which include replies.
+func (m *mastodon) Mentions(ctx context.Context) ([]Mention, error) {
+	var out []struct {
+		ID        string `json:"id"`
+		CreatedAt string `json:"created_at"`
+		Account   struct {
+			Acct        string `json:"acct"`
+			DisplayName string `json:"display_name"`
+		} `json:"account"`
+		Status *struct {
+			ID          string  `json:"id"`
+			URL         string  `json:"url"`
+			Content     string  `json:"content"`
+			InReplyToID *string `json:"in_reply_to_id"`
+		} `json:"status"`
+	}
+	if err := m.do(ctx, http.MethodGet, "/api/v1/notifications?types[]=mention&limit=40", nil, &out); err != nil {
+		return nil, err
+	}
+	var ms []Mention
+	for _, n := range out {
+		if n.Status == nil {
+			continue
+		}
+		kind := "mention"
+		if n.Status.InReplyToID != nil {
+			kind = "reply"
+		}
+		ms = append(ms, Mention{
+			ID:           n.ID,
+			Author:       n.Account.DisplayName,
+			AuthorHandle: n.Account.Acct,
+			Kind:         kind,
+			Text:         htmlToText(n.Status.Content),
+			PostID:       n.Status.ID,
+			URL:          n.Status.URL,
+			CreatedAt:    n.CreatedAt,
+		})
+	}
+	return ms, nil
+}
+
+var (
+	breakRe = regexp.MustCompile(`(?i)<br\s*/?>|</p>\s*<p[^>]*>`)
+	tagRe   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlToText turns status HTML into plain text, keeping line breaks.
+func htmlToText(s string) string {
+	s = breakRe.ReplaceAllString(s, "\n")
+	s = tagRe.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
@@ -0,0 +1,329 @@
+// Package social posts to Bluesky and Mastodon accounts and collects their
+// mentions.
+//
+// Each configured account gets a Client for its network. Agents post and
+// reply through the social_* tools, notification channels of type "bluesky"
+// or "mastodon" post through a Notifier, and Service polls every account's
+// mentions into the social inbox (the social_mentions table), where the
+// owner and agents read them and mark them read.
+package social
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// ErrUnknownAccount is returned for an account name that is not configured.
+var ErrUnknownAccount = errors.New("unknown social account")
+
+// Post is a published post.
+type Post struct {
+	ID  string `json:"id"` // Bluesky AT URI or Mastodon status ID; pass as replyTo
+	URL string `json:"url,omitempty"`
+}
+
+// Mention is a post that mentions or replies to an account.
+type Mention struct {
+	ID           string `json:"id"` // network-specific, unique per account
+	Account      string `json:"account"`
+	Network      string `json:"network"`
+	Author       string `json:"author,omitempty"`
+	AuthorHandle string `json:"authorHandle"`
+	Kind         string `json:"kind"` // "mention", "reply" or "quote"
+	Text         string `json:"text"`
+	PostID       string `json:"postId"` // reply with this
+	URL          string `json:"url,omitempty"`
+	CreatedAt    string `json:"createdAt"`
+	Read         bool   `json:"read"`
+}
+
+// Client talks to one account's network.
+type Client interface {
+	// Post publishes text, as a reply to the post replyTo when set.
+	Post(ctx context.Context, text, replyTo string) (*Post, error)
+	// Mentions returns the account's most recent mentions and replies.
+	Mentions(ctx context.Context) ([]Mention, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// NewClient returns the client for acct's network.
+func NewClient(acct config.SocialAccount) (Client, error) {
+	switch acct.Type {
+	case "bluesky":
+		if acct.Handle == "" || acct.AppPassword == "" {
+			return nil, fmt.Errorf("bluesky account %q needs handle and appPassword", acct.Name)
+		}
+		return newBluesky(acct), nil
+	case "mastodon":
+		if acct.Server == "" || acct.AccessToken == "" {
+			return nil, fmt.Errorf("mastodon account %q needs server and accessToken", acct.Name)
+		}
+		return newMastodon(acct), nil
+	}
+	return nil, fmt.Errorf("social account %q: unknown type %q (use \"bluesky\" or \"mastodon\")", acct.Name, acct.Type)
+}
+
+// checkLength refuses text longer than the account allows.
+func checkLength(acct config.SocialAccount, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("text is empty")
+	}
+	if n, limit := utf8.RuneCountInString(text), acct.MaxLengthOrDefault(); n > limit {
+		return fmt.Errorf("%s post is %d characters, over the limit of %d", acct.Type, n, limit)
+	}
+	return nil
+}
+
+// --- Service ---
+
+// Deps holds the root-package callbacks the service needs.
+type Deps struct {
+	// Notify tells the owner about new mentions of accounts with
+	// notifyMentions set.
+	Notify func(text string)
+}
+
+// Service holds a client per account and polls their mentions.
+type Service struct {
+	cfg    config.SocialConfig
+	dbPath string
+	deps   Deps
+
+	mu      sync.Mutex
+	clients map[string]Client
+}
+
+// New creates a service for cfg's accounts. Call Start to poll mentions.
+func New(cfg config.SocialConfig, dbPath string, deps Deps) *Service {
+	return &Service{cfg: cfg, dbPath: dbPath, deps: deps, clients: make(map[string]Client)}
+}
+
+// InitDB creates the social_mentions table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS social_mentions (
+		id TEXT NOT NULL,
+		account TEXT NOT NULL,
+		network TEXT NOT NULL,
+		author TEXT DEFAULT '',
+		author_handle TEXT DEFAULT '',
+		kind TEXT DEFAULT 'mention',
+		text TEXT DEFAULT '',
+		post_id TEXT DEFAULT '',
+		url TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		fetched_at TEXT NOT NULL,
+		read INTEGER DEFAULT 0,
+		PRIMARY KEY (account, id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_social_mentions_unread ON social_mentions(read, created_at);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Accounts returns the configured accounts.
+func (s *Service) Accounts() []config.SocialAccount { return s.cfg.Accounts }
+
+// Client returns the client of the named account, created on first use.
+func (s *Service) Client(name string) (Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[name]; ok {
+		return c, nil
+	}
+	acct, ok := s.cfg.Account(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAccount, name)
+	}
+	c, err := NewClient(acct)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[name] = c
+	return c, nil
+}
+
+// Post publishes text from the named account.
+func (s *Service) Post(ctx context.Context, account, text, replyTo string) (*Post, error) {
+	c, err := s.Client(account)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(ctx, text, replyTo)
+}
+
+// Start polls the mentions of every account with mentions enabled until
+// ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.PollIntervalOrDefault())
+		defer ticker.Stop()
+		for {
+			for _, a := range s.cfg.Accounts {
+				if !a.MentionsEnabled() || ctx.Err() != nil {
+					continue
+				}
+				if _, err := s.FetchMentions(ctx, a.Name); err != nil {
+					log.Warn("social: fetch mentions failed", "account", a.Name, "error", err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// FetchMentions adds the account's new mentions to the inbox and returns
+// them.
+func (s *Service) FetchMentions(ctx context.Context, account string) ([]Mention, error) {
+	c, err := s.Client(account)
+	if err != nil {
+		return nil, err
+	}
+	acct, _ := s.cfg.Account(account)
+	mentions, err := c.Mentions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var added []Mention
+	for _, m := range mentions {
+		rows, err := db.QueryArgs(s.dbPath, `SELECT 1 AS seen FROM social_mentions WHERE account = ? AND id = ?`, account, m.ID)
+		if err != nil {
+			return added, err
+		}
+		if len(rows) > 0 {
+			continue
+		}
+		m.Account, m.Network = account, acct.Type
+		if err := db.ExecArgs(s.dbPath, `INSERT OR IGNORE INTO social_mentions
+			(id, account, network, author, author_handle, kind, text, post_id, url, created_at, fetched_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, account, acct.Type, m.Author, m.AuthorHandle, m.Kind, m.Text, m.PostID, m.URL, m.CreatedAt, now); err != nil {
+			return added, err
+		}
+		added = append(added, m)
+	}
+	if len(added) > 0 {
+		log.Info("social: new mentions", "account", account, "count", len(added))
+		if acct.NotifyMentions && s.deps.Notify != nil {
+			s.deps.Notify(formatMentions(account, added))
+		}
+	}
+	return added, nil
+}
+
+func formatMentions(account string, ms []Mention) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %d new mention(s)", account, len(ms))
+	for i, m := range ms {
+		if i == 5 {
+			fmt.Fprintf(&b, "\n... and %d more", len(ms)-i)
+			break
+		}
+		text := m.Text
+		if utf8.RuneCountInString(text) > 200 {
+			text = string([]rune(text)[:200]) + "..."
+		}
+		fmt.Fprintf(&b, "\n@%s: %s", m.AuthorHandle, text)
+	}
+	return b.String()
+}
+
+// Mentions returns the inbox, newest first: all accounts or one, optionally
+// only unread mentions.
+func (s *Service) Mentions(account string, unreadOnly bool, limit int) ([]Mention, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	q := `SELECT * FROM social_mentions WHERE 1 = 1`
+	var args []any
+	if account != "" {
+		q += ` AND account = ?`
+		args = append(args, account)
+	}
+	if unreadOnly {
+		q += ` AND read = 0`
+	}
+	q += ` ORDER BY created_at DESC LIMIT ?`
+	rows, err := db.QueryArgs(s.dbPath, q, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Mention, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Mention{
+			ID:           db.Str(r["id"]),
+			Account:      db.Str(r["account"]),
+			Network:      db.Str(r["network"]),
+			Author:       db.Str(r["author"]),
+			AuthorHandle: db.Str(r["author_handle"]),
+			Kind:         db.Str(r["kind"]),
+			Text:         db.Str(r["text"]),
+			PostID:       db.Str(r["post_id"]),
+			URL:          db.Str(r["url"]),
+			CreatedAt:    db.Str(r["created_at"]),
+			Read:         db.Int(r["read"]) != 0,
+		})
+	}
+	return out, nil
+}
+
+// MarkRead marks mentions of an account read: the given IDs, or all of them
+// when ids is empty.
+func (s *Service) MarkRead(account string, ids []string) error {
+	if len(ids) == 0 {
+		return db.ExecArgs(s.dbPath, `UPDATE social_mentions SET read = 1 WHERE account = ?`, account)
+	}
+	for _, id := range ids {
+		if err := db.ExecArgs(s.dbPath, `UPDATE social_mentions SET read = 1 WHERE account = ? AND id = ?`, account, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Notifier ---
+
+// Notifier posts notifications from a social account. It satisfies
+// notify.Notifier.
+type Notifier struct {
+	acct   config.SocialAccount
+	client Client
+}
+
+// NewNotifier returns a notifier posting from acct.
+func NewNotifier(acct config.SocialAccount) (*Notifier, error) {
+	c, err := NewClient(acct)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{acct: acct, client: c}, nil
+}
+
+// Send posts text, shortened to the account's limit.
+func (n *Notifier) Send(text string) error {
+	if limit := n.acct.MaxLengthOrDefault(); utf8.RuneCountInString(text) > limit {
+		text = string([]rune(text)[:limit-3]) + "..."
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := n.client.Post(ctx, text, "")
+	return err
+}
+
+// Name returns the account's network.
+func (n *Notifier) Name() string { return n.acct.Type }
@@ -0,0 +1,155 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestBluesky_PostReplyRenewsSession(t *testing.T) {
+	var sessions int
+	var record map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			sessions++
+			json.NewEncoder(w).Encode(map[string]string{"accessJwt": "jwt" + string(rune('0'+sessions)), "did": "did:plc:me"})
+		case "/xrpc/app.bsky.feed.getPosts":
+			// The first session has expired by now.
+			if r.Header.Get("Authorization") == "Bearer jwt1" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"ExpiredToken","message":"Token has expired"}`))
+				return
+			}
+			w.Write([]byte(`{"posts":[{"uri":"at://did:plc:x/app.bsky.feed.post/p2","cid":"c2",
+				"record":{"reply":{"root":{"uri":"at://did:plc:x/app.bsky.feed.post/p1","cid":"c1"}}}}]}`))
+		case "/xrpc/com.atproto.repo.createRecord":
+			var in map[string]any
+			json.NewDecoder(r.Body).Decode(&in)
+			record = in["record"].(map[string]any)
+			w.Write([]byte(`{"uri":"at://did:plc:me/app.bsky.feed.post/abc","cid":"c3"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.SocialAccount{Name: "b", Type: "bluesky", Server: srv.URL, Handle: "me.bsky.social", AppPassword: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.Post(context.Background(), "café → https://example.com/x.", "at://did:plc:x/app.bsky.feed.post/p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != "https://bsky.app/profile/me.bsky.social/post/abc" || sessions != 2 {
+		t.Errorf("post = %+v, sessions = %d", p, sessions)
+	}
+
+	reply := record["reply"].(map[string]any)
+	if reply["root"].(map[string]any)["cid"] != "c1" || reply["parent"].(map[string]any)["cid"] != "c2" {
+		t.Errorf("reply = %v", reply)
+	}
+	facet := record["facets"].([]any)[0].(map[string]any)
+	index := facet["index"].(map[string]any)
+	// "café → " is 10 bytes; the trailing period is not part of the link.
+	if index["byteStart"] != 10.0 || index["byteEnd"] != 31.0 {
+		t.Errorf("facet index = %v", index)
+	}
+}
+
+func TestMastodon_PostAndMentions(t *testing.T) {
+	var status map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/statuses":
+			if r.Header.Get("Idempotency-Key") == "" {
+				t.Error("missing Idempotency-Key")
+			}
+			json.NewDecoder(r.Body).Decode(&status)
+			w.Write([]byte(`{"id":"42","url":"https://social.example/@me/42"}`))
+		case "/api/v1/notifications":
+			w.Write([]byte(`[{"id":"n1","type":"mention","created_at":"2026-01-02T03:04:05Z",
+				"account":{"acct":"alice@other.example","display_name":"Alice"},
+				"status":{"id":"s1","url":"https://other.example/@alice/s1","in_reply_to_id":"42",
+				"content":"<p><span class=\"h-card\"><a href=\"#\">@me</a></span> hi &amp; thanks</p><p>second</p>"}}]`))
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(config.SocialAccount{Name: "m", Type: "mastodon", Server: srv.URL, AccessToken: "tok"})
+	p, err := c.Post(context.Background(), "hello", "7")
+	if err != nil || p.ID != "42" || status["in_reply_to_id"] != "7" {
+		t.Fatalf("post = %+v %v, sent %v", p, err, status)
+	}
+	if _, err := c.Post(context.Background(), strings.Repeat("x", 501), ""); err == nil {
+		t.Error("expected over-length error")
+	}
+
+	ms, err := c.Mentions(context.Background())
+	if err != nil || len(ms) != 1 {
+		t.Fatalf("mentions = %v %v", ms, err)
+	}
+	if m := ms[0]; m.Text != "@me hi & thanks\nsecond" || m.Kind != "reply" || m.PostID != "s1" || m.AuthorHandle != "alice@other.example" {
+		t.Errorf("mention = %+v", m)
+	}
+}
+
+// fakeClient returns a fixed set of mentions.
+type fakeClient struct{ mentions []Mention }
+
+func (f *fakeClient) Post(context.Context, string, string) (*Post, error) { return &Post{ID: "p"}, nil }
+func (f *fakeClient) Mentions(context.Context) ([]Mention, error)         { return f.mentions, nil }
+
+func TestService_MentionsInbox(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	var notified []string
+	s := New(config.SocialConfig{Accounts: []config.SocialAccount{{Name: "m", Type: "mastodon", NotifyMentions: true}}},
+		dbPath, Deps{Notify: func(text string) { notified = append(notified, text) }})
+	fc := &fakeClient{mentions: []Mention{
+		{ID: "1", AuthorHandle: "a", Text: "first", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "2", AuthorHandle: "b", Text: "it's second", CreatedAt: "2026-01-02T00:00:00Z"},
+	}}
+	s.clients["m"] = fc
+
+	ctx := context.Background()
+	if added, err := s.FetchMentions(ctx, "m"); err != nil || len(added) != 2 {
+		t.Fatalf("first fetch = %v %v", added, err)
+	}
+	// Already seen mentions are not added or announced again.
+	if added, _ := s.FetchMentions(ctx, "m"); len(added) != 0 || len(notified) != 1 {
+		t.Fatalf("second fetch = %v, notified %d times", added, len(notified))
+	}
+
+	if err := s.MarkRead("m", []string{"2"}); err != nil {
+		t.Fatal(err)
+	}
+	unread, _ := s.Mentions("m", true, 0)
+	if len(unread) != 1 || unread[0].ID != "1" || unread[0].Network != "mastodon" {
+		t.Errorf("unread = %+v", unread)
+	}
+	all, _ := s.Mentions("", false, 0)
+	if len(all) != 2 || all[0].ID != "2" || !all[0].Read || all[0].Text != "it's second" {
+		t.Errorf("all = %+v", all)
+	}
+	s.MarkRead("m", nil)
+	if unread, _ = s.Mentions("m", true, 0); len(unread) != 0 {
+		t.Errorf("unread after mark all = %+v", unread)
+	}
+	if _, err := s.Client("nope"); err == nil {
+		t.Error("expected unknown account error")
+	}
+}
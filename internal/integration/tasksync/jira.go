@@ -0,0 +1,209 @@
+package tasksync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// jira files and reads issues through the Jira REST API v2, on Jira Cloud
+// (email and API token) or Server and Data Center (personal access token).
+type jira struct {
+	cfg  config.TaskSyncConfig
+	base string
+}
+
+func newJira(cfg config.TaskSyncConfig) *jira {
+	base := strings.TrimSuffix(cfg.URL, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return &jira{cfg: cfg, base: base}
+}
+
+var errNotFound = errors.New("not found")
+
+func (j *jira) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, j.base+path, body)
+	if err != nil {
+		return err
+	}
+	if j.cfg.Email != "" {
+		req.SetBasicAuth(j.cfg.Email, j.cfg.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.cfg.Token)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("jira: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("jira %s: %w", path, errNotFound)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("jira %s: status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("jira %s: %w", path, err)
+	}
+	return nil
+}
+
+type jiraStatus struct {
+	Name     string `json:"name"`
+	Category struct {
+		Key string `json:"key"` // new, indeterminate or done
+	} `json:"statusCategory"`
+}
+
+func (s jiraStatus) stage() string {
+	switch s.Category.Key {
+	case "indeterminate":
+		return StageDoing
+	case "done":
+		return StageDone
+	}
+	return StageTodo
+}
+
+type jiraIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string     `json:"summary"`
+		Status  jiraStatus `json:"status"`
+		Updated string     `json:"updated"`
+	} `json:"fields"`
+}
+
+func (j *jira) ticket(is jiraIssue) Ticket {
+	updated, _ := time.Parse("2006-01-02T15:04:05.000-0700", is.Fields.Updated)
+	return Ticket{
+		ID:      is.ID,
+		Key:     is.Key,
+		URL:     j.base + "/browse/" + is.Key,
+		Title:   is.Fields.Summary,
+		Status:  is.Fields.Status.Name,
+		Stage:   is.Fields.Status.stage(),
+		Updated: updated,
+	}
+}
+
+func (j *jira) get(ctx context.Context, id string) (Ticket, error) {
+	var is jiraIssue
+	if err := j.do(ctx, http.MethodGet, "/rest/api/2/issue/"+url.PathEscape(id)+"?fields=summary,status,updated", nil, &is); err != nil {
+		return Ticket{}, err
+	}
+	return j.ticket(is), nil
+}
+
+// Create files an issue in the project. New issues start in the workflow's
+// first status, so one for a task already under way is moved on at once.
+func (j *jira) Create(ctx context.Context, title, description, status string) (Ticket, error) {
+	in := map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": j.cfg.Project},
+		"summary":     title,
+		"description": description,
+		"issuetype":   map[string]string{"name": j.cfg.IssueTypeOrDefault()},
+	}}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue", in, &out); err != nil {
+		return Ticket{}, err
+	}
+	return j.Update(ctx, out.ID, "", status)
+}
+
+func (j *jira) Tickets(ctx context.Context, ids []string) (map[string]Ticket, error) {
+	out := make(map[string]Ticket, len(ids))
+	for _, id := range ids {
+		tk, err := j.get(ctx, id)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[id] = tk
+	}
+	return out, nil
+}
+
+// Update sets the summary and moves the issue to status. Jira changes
+// status only through the workflow's transitions, so the one leading to
+// the mapped status name, or else to a status in the stage's category, is
+// taken.
+func (j *jira) Update(ctx context.Context, id, title, status string) (Ticket, error) {
+	if title != "" {
+		in := map[string]any{"fields": map[string]string{"summary": title}}
+		if err := j.do(ctx, http.MethodPut, "/rest/api/2/issue/"+url.PathEscape(id), in, nil); err != nil {
+			return Ticket{}, err
+		}
+	}
+	tk, err := j.get(ctx, id)
+	if err != nil || status == "" {
+		return tk, err
+	}
+	name, stage := j.cfg.Statuses[status], Stage(status)
+	if strings.EqualFold(tk.Status, name) || (name == "" && tk.Stage == stage) {
+		return tk, nil
+	}
+	var ts struct {
+		Transitions []struct {
+			ID string     `json:"id"`
+			To jiraStatus `json:"to"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + url.PathEscape(id) + "/transitions"
+	if err := j.do(ctx, http.MethodGet, path, nil, &ts); err != nil {
+		return tk, err
+	}
+	pick := ""
+	for _, t := range ts.Transitions {
+		if name != "" && strings.EqualFold(t.To.Name, name) {
+			pick = t.ID
+			break
+		}
+		if name == "" && pick == "" && t.To.stage() == stage {
+			pick = t.ID
+		}
+	}
+	if pick == "" {
+		want := name
+		if want == "" {
+			want = stage
+		}
+		return tk, fmt.Errorf("jira: no transition from %q to %q on %s", tk.Status, want, tk.Key)
+	}
+	if err := j.do(ctx, http.MethodPost, path, map[string]any{"transition": map[string]string{"id": pick}}, nil); err != nil {
+		return tk, err
+	}
+	return j.get(ctx, id)
+}
@@ -0,0 +1,300 @@
+package tasksync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// linear files and reads issues through Linear's GraphQL API.
+type linear struct {
+	cfg      config.TaskSyncConfig
+	endpoint string
+
+	mu     sync.Mutex
+	teamID string
+	states []linearState
+}
+
+func newLinear(cfg config.TaskSyncConfig) *linear {
+	endpoint := "https://api.linear.app/graphql"
+	if cfg.URL != "" {
+		endpoint = strings.TrimSuffix(cfg.URL, "/") + "/graphql"
+	}
+	return &linear{cfg: cfg, endpoint: endpoint}
+}
+
+func (l *linear) graphql(ctx context.Context, query string, vars map[string]any, out any) error {
+	data, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", l.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("linear: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("linear: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var env struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return fmt.Errorf("linear: %w", err)
+	}
+	if len(env.Errors) > 0 {
+		return fmt.Errorf("linear: %s", env.Errors[0].Message)
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+type linearState struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"` // triage, backlog, unstarted, started, completed or canceled
+	Position float64 `json:"position"`
+}
+
+func (s linearState) stage() string {
+	switch s.Type {
+	case "started":
+		return StageDoing
+	case "completed", "canceled":
+		return StageDone
+	}
+	return StageTodo
+}
+
+// stateType returns the Linear state type a taskboard status goes to.
+func stateType(status string) string {
+	switch status {
+	case "idea", "needs-thought", "backlog":
+		return "backlog"
+	case "doing", "review":
+		return "started"
+	case "done", "partial-done":
+		return "completed"
+	case "failed":
+		return "canceled"
+	}
+	return "unstarted"
+}
+
+const linearTeam = `query Team($key: String!) {
+  teams(filter: {key: {eq: $key}}) {
+    nodes { id states { nodes { id name type position } } }
+  }
+}`
+
+// team looks up the team's ID and workflow states once.
+func (l *linear) team(ctx context.Context) (string, []linearState, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.teamID != "" {
+		return l.teamID, l.states, nil
+	}
+	var out struct {
+		Teams struct {
+			Nodes []struct {
+				ID     string `json:"id"`
+				States struct {
+					Nodes []linearState `json:"nodes"`
+				} `json:"states"`
+			} `json:"nodes"`
+		} `json:"teams"`
+	}
+	if err := l.graphql(ctx, linearTeam, map[string]any{"key": l.cfg.Project}, &out); err != nil {
+		return "", nil, err
+	}
+	if len(out.Teams.Nodes) == 0 {
+		return "", nil, fmt.Errorf("linear: no team with key %q", l.cfg.Project)
+	}
+	l.teamID, l.states = out.Teams.Nodes[0].ID, out.Teams.Nodes[0].States.Nodes
+	return l.teamID, l.states, nil
+}
+
+// state picks the workflow state for a taskboard status: the one named in
+// the config, or else the first of its type.
+func (l *linear) state(ctx context.Context, status string) (linearState, error) {
+	_, states, err := l.team(ctx)
+	if err != nil {
+		return linearState{}, err
+	}
+	if name := l.cfg.Statuses[status]; name != "" {
+		for _, s := range states {
+			if strings.EqualFold(s.Name, name) {
+				return s, nil
+			}
+		}
+		return linearState{}, fmt.Errorf("linear: team %s has no state %q", l.cfg.Project, name)
+	}
+	types := []string{stateType(status)}
+	if types[0] == "backlog" {
+		// Teams may turn the backlog off.
+		types = append(types, "unstarted")
+	}
+	for _, typ := range types {
+		var pick *linearState
+		for i, s := range states {
+			if s.Type == typ && (pick == nil || s.Position < pick.Position) {
+				pick = &states[i]
+			}
+		}
+		if pick != nil {
+			return *pick, nil
+		}
+	}
+	return linearState{}, fmt.Errorf("linear: team %s has no %s state", l.cfg.Project, types[0])
+}
+
+const linearIssueFields = `id identifier url title updatedAt state { id name type position }`
+
+type linearIssue struct {
+	ID         string      `json:"id"`
+	Identifier string      `json:"identifier"`
+	URL        string      `json:"url"`
+	Title      string      `json:"title"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+	State      linearState `json:"state"`
+}
+
+func (is linearIssue) ticket() Ticket {
+	return Ticket{
+		ID:      is.ID,
+		Key:     is.Identifier,
+		URL:     is.URL,
+		Title:   is.Title,
+		Status:  is.State.Name,
+		Stage:   is.State.stage(),
+		Updated: is.UpdatedAt,
+	}
+}
+
+const linearCreate = `mutation Create($input: IssueCreateInput!) {
+  issueCreate(input: $input) { success issue { ` + linearIssueFields + ` } }
+}`
+
+func (l *linear) Create(ctx context.Context, title, description, status string) (Ticket, error) {
+	teamID, _, err := l.team(ctx)
+	if err != nil {
+		return Ticket{}, err
+	}
+	st, err := l.state(ctx, status)
+	if err != nil {
+		return Ticket{}, err
+	}
+	var out struct {
+		IssueCreate struct {
+			Success bool        `json:"success"`
+			Issue   linearIssue `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	input := map[string]any{"teamId": teamID, "title": title, "description": description, "stateId": st.ID}
+	if err := l.graphql(ctx, linearCreate, map[string]any{"input": input}, &out); err != nil {
+		return Ticket{}, err
+	}
+	if !out.IssueCreate.Success {
+		return Ticket{}, fmt.Errorf("linear: issue not created")
+	}
+	return out.IssueCreate.Issue.ticket(), nil
+}
+
+const linearIssues = `query Issues($ids: [ID!]) {
+  issues(filter: {id: {in: $ids}}, first: 250) { nodes { ` + linearIssueFields + ` } }
+}`
+
+func (l *linear) issues(ctx context.Context, ids []string) ([]linearIssue, error) {
+	var all []linearIssue
+	for len(ids) > 0 {
+		n := min(len(ids), 250)
+		var out struct {
+			Issues struct {
+				Nodes []linearIssue `json:"nodes"`
+			} `json:"issues"`
+		}
+		if err := l.graphql(ctx, linearIssues, map[string]any{"ids": ids[:n]}, &out); err != nil {
+			return nil, err
+		}
+		all = append(all, out.Issues.Nodes...)
+		ids = ids[n:]
+	}
+	return all, nil
+}
+
+func (l *linear) Tickets(ctx context.Context, ids []string) (map[string]Ticket, error) {
+	issues, err := l.issues(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Ticket, len(issues))
+	for _, is := range issues {
+		out[is.ID] = is.ticket()
+	}
+	return out, nil
+}
+
+const linearUpdate = `mutation Update($id: String!, $input: IssueUpdateInput!) {
+  issueUpdate(id: $id, input: $input) { success issue { ` + linearIssueFields + ` } }
+}`
+
+// Update sets the title and state. An issue already in a state of the
+// right type keeps it, so a custom "In Review" is not reset to the first
+// started state.
+func (l *linear) Update(ctx context.Context, id, title, status string) (Ticket, error) {
+	input := map[string]any{}
+	if title != "" {
+		input["title"] = title
+	}
+	if status != "" {
+		st, err := l.state(ctx, status)
+		if err != nil {
+			return Ticket{}, err
+		}
+		issues, err := l.issues(ctx, []string{id})
+		if err != nil {
+			return Ticket{}, err
+		}
+		if len(issues) == 0 {
+			return Ticket{}, fmt.Errorf("linear: issue %s not found", id)
+		}
+		cur := issues[0].State
+		if cur.ID != st.ID && (l.cfg.Statuses[status] != "" || cur.Type != st.Type) {
+			input["stateId"] = st.ID
+		}
+		if len(input) == 0 {
+			return issues[0].ticket(), nil
+		}
+	}
+	var out struct {
+		IssueUpdate struct {
+			Success bool        `json:"success"`
+			Issue   linearIssue `json:"issue"`
+		} `json:"issueUpdate"`
+	}
+	if err := l.graphql(ctx, linearUpdate, map[string]any{"id": id, "input": input}, &out); err != nil {
+		return Ticket{}, err
+	}
+	if !out.IssueUpdate.Success {
+		return Ticket{}, fmt.Errorf("linear: issue %s not updated", id)
+	}
+	return out.IssueUpdate.Issue.ticket(), nil
+}
@@ -0,0 +1,553 @@
+// Package tasksync keeps taskboard tasks in step with Jira or Linear issues.
+//
+// Active tasks in the synced projects are filed as tickets, and the
+// tasksync_links table maps each task to its ticket. Every sync compares
+// both sides with the title and status seen at the last sync: a change on
+// one side is copied to the other, and a change on both is a conflict,
+// settled by the conflict policy and recorded on the link. Statuses are
+// matched by stage (to do, in progress, done) unless the config maps a
+// taskboard status to a tracker status by name.
+package tasksync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+	"tetora/internal/taskboard"
+)
+
+// Stages a status falls in, on either side.
+const (
+	StageTodo  = "todo"
+	StageDoing = "doing"
+	StageDone  = "done"
+)
+
+// Stage returns the stage of a taskboard status.
+func Stage(status string) string {
+	switch status {
+	case "doing", "review":
+		return StageDoing
+	case "done", "partial-done", "failed":
+		return StageDone
+	}
+	return StageTodo
+}
+
+// Ticket is an issue in the tracker.
+type Ticket struct {
+	ID      string // immutable ID used by the API
+	Key     string // human key, e.g. OPS-12 or ENG-34
+	URL     string // page in the tracker
+	Title   string
+	Status  string // tracker status name
+	Stage   string // StageTodo, StageDoing or StageDone
+	Updated time.Time
+}
+
+// Client talks to one tracker. Status arguments are taskboard statuses;
+// the client maps them to its own.
+type Client interface {
+	// Create files a ticket.
+	Create(ctx context.Context, title, description, status string) (Ticket, error)
+	// Tickets returns the tickets with the given IDs by ID. Deleted ones
+	// are left out.
+	Tickets(ctx context.Context, ids []string) (map[string]Ticket, error)
+	// Update changes a ticket's title and moves it to status. An empty
+	// argument leaves that field alone.
+	Update(ctx context.Context, id, title, status string) (Ticket, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// NewClient returns the client for cfg.Provider.
+func NewClient(cfg config.TaskSyncConfig) (Client, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("taskBoard.sync.token is required")
+	}
+	if cfg.Project == "" {
+		return nil, fmt.Errorf("taskBoard.sync.project is required")
+	}
+	switch cfg.Provider {
+	case "jira":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("taskBoard.sync.url is required for jira")
+		}
+		return newJira(cfg), nil
+	case "linear":
+		return newLinear(cfg), nil
+	}
+	return nil, fmt.Errorf("taskBoard.sync: unknown provider %q (use \"jira\" or \"linear\")", cfg.Provider)
+}
+
+// --- Service ---
+
+// Link maps a task to its ticket.
+type Link struct {
+	TaskID       string `json:"taskId"`
+	RemoteID     string `json:"remoteId"`
+	RemoteKey    string `json:"remoteKey"`
+	URL          string `json:"url"`
+	LocalTitle   string `json:"-"` // task title at the last sync
+	LocalStatus  string `json:"localStatus"`
+	RemoteTitle  string `json:"-"` // ticket title at the last sync
+	RemoteStatus string `json:"remoteStatus"`
+	CreatedAt    string `json:"createdAt"`
+	SyncedAt     string `json:"syncedAt"`
+	Error        string `json:"error,omitempty"`
+	Conflicts    int    `json:"conflicts,omitempty"`
+	Conflict     string `json:"conflict,omitempty"` // how the last conflict was settled
+	ConflictAt   string `json:"conflictAt,omitempty"`
+}
+
+// Result counts what a sync changed.
+type Result struct {
+	Created   int `json:"created"`   // tasks filed as tickets
+	Pushed    int `json:"pushed"`    // tickets updated from their task
+	Pulled    int `json:"pulled"`    // tasks updated from their ticket
+	Conflicts int `json:"conflicts"` // links changed on both sides
+	Errors    int `json:"errors"`
+	Unlinked  int `json:"unlinked"` // links dropped with their deleted task
+}
+
+// Status is the sync state shown by the API.
+type Status struct {
+	Provider  string `json:"provider"`
+	Project   string `json:"project"`
+	Conflict  string `json:"conflict"` // conflict policy
+	Running   bool   `json:"running"`
+	LastRun   string `json:"lastRun,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	Last      Result `json:"last"`
+	Linked    int    `json:"linked"`
+	Errors    []Link `json:"errors"`    // links whose last sync failed
+	Conflicts []Link `json:"conflicts"` // links with a conflict, latest first
+}
+
+// Service syncs the taskboard with a tracker.
+type Service struct {
+	cfg    config.TaskSyncConfig
+	dbPath string
+	board  *taskboard.Engine
+	client Client
+
+	syncMu sync.Mutex
+
+	mu      sync.Mutex
+	running bool
+	lastRun time.Time
+	lastErr string
+	last    Result
+}
+
+// New creates the service for cfg. Call Start to sync on a schedule.
+func New(cfg config.TaskSyncConfig, dbPath string, board *taskboard.Engine) (*Service, error) {
+	c, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(cfg, dbPath, board, c), nil
+}
+
+// NewWithClient creates a service over an existing client.
+func NewWithClient(cfg config.TaskSyncConfig, dbPath string, board *taskboard.Engine, c Client) *Service {
+	return &Service{cfg: cfg, dbPath: dbPath, board: board, client: c}
+}
+
+// InitDB creates the tasksync tables.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS tasksync_links (
+		task_id TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		remote_id TEXT NOT NULL,
+		remote_key TEXT DEFAULT '',
+		url TEXT DEFAULT '',
+		local_title TEXT DEFAULT '',
+		local_status TEXT DEFAULT '',
+		remote_title TEXT DEFAULT '',
+		remote_status TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		synced_at TEXT DEFAULT '',
+		error TEXT DEFAULT '',
+		conflicts INTEGER DEFAULT 0,
+		conflict TEXT DEFAULT '',
+		conflict_at TEXT DEFAULT ''
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_tasksync_links_remote ON tasksync_links(provider, remote_id);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Links returns the links to the configured tracker.
+func (s *Service) Links() ([]Link, error) {
+	return s.query(`SELECT * FROM tasksync_links WHERE provider = ? ORDER BY created_at`, s.cfg.Provider)
+}
+
+func (s *Service) query(q string, args ...any) ([]Link, error) {
+	rows, err := db.QueryArgs(s.dbPath, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Link, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Link{
+			TaskID:       db.Str(r["task_id"]),
+			RemoteID:     db.Str(r["remote_id"]),
+			RemoteKey:    db.Str(r["remote_key"]),
+			URL:          db.Str(r["url"]),
+			LocalTitle:   db.Str(r["local_title"]),
+			LocalStatus:  db.Str(r["local_status"]),
+			RemoteTitle:  db.Str(r["remote_title"]),
+			RemoteStatus: db.Str(r["remote_status"]),
+			CreatedAt:    db.Str(r["created_at"]),
+			SyncedAt:     db.Str(r["synced_at"]),
+			Error:        db.Str(r["error"]),
+			Conflicts:    db.Int(r["conflicts"]),
+			Conflict:     db.Str(r["conflict"]),
+			ConflictAt:   db.Str(r["conflict_at"]),
+		})
+	}
+	return out, nil
+}
+
+// Status returns the sync state.
+func (s *Service) Status() (Status, error) {
+	s.mu.Lock()
+	st := Status{
+		Provider:  s.cfg.Provider,
+		Project:   s.cfg.Project,
+		Conflict:  s.cfg.ConflictOrDefault(),
+		Running:   s.running,
+		LastError: s.lastErr,
+		Last:      s.last,
+	}
+	if !s.lastRun.IsZero() {
+		st.LastRun = formatTime(s.lastRun)
+	}
+	s.mu.Unlock()
+
+	links, err := s.Links()
+	if err != nil {
+		return st, err
+	}
+	st.Linked = len(links)
+	st.Errors, st.Conflicts = []Link{}, []Link{}
+	for _, l := range links {
+		if l.Error != "" {
+			st.Errors = append(st.Errors, l)
+		}
+		if l.ConflictAt != "" {
+			st.Conflicts = append(st.Conflicts, l)
+		}
+	}
+	// Latest conflicts first, and only the recent ones.
+	sort.SliceStable(st.Conflicts, func(i, j int) bool { return st.Conflicts[i].ConflictAt > st.Conflicts[j].ConflictAt })
+	if len(st.Conflicts) > 20 {
+		st.Conflicts = st.Conflicts[:20]
+	}
+	return st, nil
+}
+
+// --- Sync ---
+
+// Sync files new tasks as tickets and copies changes between linked tasks
+// and tickets. Failures on one link are recorded on it and do not stop the
+// others; the error is for failures that stop the whole sync.
+func (s *Service) Sync(ctx context.Context) (Result, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+
+	res, err := s.sync(ctx)
+
+	s.mu.Lock()
+	s.running, s.lastRun, s.last, s.lastErr = false, time.Now(), res, ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+	s.mu.Unlock()
+	if res != (Result{}) {
+		log.Info("tasksync: synced", "provider", s.cfg.Provider, "created", res.Created, "pushed", res.Pushed,
+			"pulled", res.Pulled, "conflicts", res.Conflicts, "errors", res.Errors)
+	}
+	return res, err
+}
+
+func (s *Service) sync(ctx context.Context) (Result, error) {
+	var res Result
+	tasks, err := s.tasks()
+	if err != nil {
+		return res, err
+	}
+	links, err := s.Links()
+	if err != nil {
+		return res, err
+	}
+	linked := make(map[string]bool, len(links))
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		linked[l.TaskID] = true
+		ids = append(ids, l.RemoteID)
+	}
+
+	// New tasks become tickets.
+	for _, t := range tasks {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if linked[t.ID] || Stage(t.Status) == StageDone || !s.cfg.Syncs(t.Project) {
+			continue
+		}
+		if err := s.create(ctx, t); err != nil {
+			log.Warn("tasksync: create ticket failed", "task", t.ID, "error", err)
+			res.Errors++
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			// The tracker is likely down or refusing us; try again next time.
+			return res, fmt.Errorf("create ticket for %s: %w", t.ID, err)
+		}
+		res.Created++
+	}
+
+	if len(links) == 0 {
+		return res, nil
+	}
+	tickets, err := s.client.Tickets(ctx, ids)
+	if err != nil {
+		return res, err
+	}
+	byID := make(map[string]taskboard.TaskBoard, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+	for _, l := range links {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		t, ok := byID[l.TaskID]
+		if !ok {
+			if err := db.ExecArgs(s.dbPath, `DELETE FROM tasksync_links WHERE task_id = ?`, l.TaskID); err != nil {
+				return res, err
+			}
+			res.Unlinked++
+			continue
+		}
+		tk, ok := tickets[l.RemoteID]
+		if !ok {
+			s.setError(l, "ticket "+l.RemoteKey+" not found in "+s.cfg.Provider)
+			res.Errors++
+			continue
+		}
+		if err := s.syncLink(ctx, l, t, tk, &res); err != nil {
+			s.setError(l, err.Error())
+			res.Errors++
+		}
+	}
+	return res, nil
+}
+
+// tasks returns every task on the board.
+func (s *Service) tasks() ([]taskboard.TaskBoard, error) {
+	var out []taskboard.TaskBoard
+	for page := 1; ; page++ {
+		r, err := s.board.ListTasksPaginated("", "", "", page, 200)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r.Tasks...)
+		if !r.Pagination.HasMore {
+			return out, nil
+		}
+	}
+}
+
+// create files a ticket for a task and links them.
+func (s *Service) create(ctx context.Context, t taskboard.TaskBoard) error {
+	desc := t.Description
+	if desc != "" {
+		desc += "\n\n"
+	}
+	desc += fmt.Sprintf("Tetora task %s", t.ID)
+	if t.Project != "" && t.Project != "default" {
+		desc += " (project " + t.Project + ")"
+	}
+	if t.Assignee != "" {
+		desc += ", assigned to agent " + t.Assignee
+	}
+	tk, err := s.client.Create(ctx, t.Title, desc, t.Status)
+	if err != nil {
+		return err
+	}
+	now := formatTime(time.Now())
+	if err := db.ExecArgs(s.dbPath, `INSERT INTO tasksync_links
+		(task_id, provider, remote_id, remote_key, url, local_title, local_status, remote_title, remote_status, created_at, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, s.cfg.Provider, tk.ID, tk.Key, tk.URL, t.Title, t.Status, tk.Title, tk.Status, now, now); err != nil {
+		return err
+	}
+	if _, err := s.board.AddComment(t.ID, "system", fmt.Sprintf("Filed as %s %s: %s", s.cfg.Provider, tk.Key, tk.URL), "system"); err != nil {
+		log.Warn("tasksync: comment on task failed", "task", t.ID, "error", err)
+	}
+	log.Info("tasksync: filed task", "task", t.ID, "ticket", tk.Key)
+	return nil
+}
+
+// syncLink copies changes between a task and its ticket.
+func (s *Service) syncLink(ctx context.Context, l Link, t taskboard.TaskBoard, tk Ticket, res *Result) error {
+	localTitle, remoteTitle := t.Title != l.LocalTitle, tk.Title != l.RemoteTitle
+	localStatus, remoteStatus := t.Status != l.LocalStatus, tk.Status != l.RemoteStatus
+	if !localTitle && !remoteTitle && !localStatus && !remoteStatus {
+		if l.Error != "" {
+			s.setError(l, "")
+		}
+		return nil
+	}
+
+	// When both sides changed the same field, the policy picks one.
+	var conflicts []string
+	keep := func(field string) (local bool) {
+		local = s.localWins(t, tk)
+		side := s.cfg.Provider
+		if local {
+			side = "tetora"
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s changed on both sides, kept %s", field, side))
+		return local
+	}
+	pushTitle, pullTitle := localTitle && !remoteTitle, remoteTitle && !localTitle
+	if localTitle && remoteTitle && t.Title != tk.Title {
+		if keep("title") {
+			pushTitle = true
+		} else {
+			pullTitle = true
+		}
+	}
+	pushStatus, pullStatus := localStatus && !remoteStatus, remoteStatus && !localStatus
+	if localStatus && remoteStatus && s.pulledStatus(t.Status, tk) != t.Status {
+		if keep("status") {
+			pushStatus = true
+		} else {
+			pullStatus = true
+		}
+	}
+
+	pulled := false
+	if pullTitle {
+		updated, err := s.board.UpdateTask(t.ID, map[string]any{"title": tk.Title})
+		if err != nil {
+			return fmt.Errorf("update task title: %w", err)
+		}
+		t, pulled = updated, true
+	}
+	if status := s.pulledStatus(t.Status, tk); pullStatus && status != t.Status {
+		moved, err := s.board.MoveTask(t.ID, status)
+		if err != nil {
+			return fmt.Errorf("move task to %s: %w", status, err)
+		}
+		t, pulled = moved, true
+	}
+	if pulled {
+		res.Pulled++
+	}
+	if pushTitle || pushStatus {
+		var title, status string
+		if pushTitle {
+			title = t.Title
+		}
+		if pushStatus {
+			status = t.Status
+		}
+		updated, err := s.client.Update(ctx, tk.ID, title, status)
+		if err != nil {
+			return fmt.Errorf("update %s: %w", tk.Key, err)
+		}
+		tk = updated
+		res.Pushed++
+	}
+
+	now := formatTime(time.Now())
+	if err := db.ExecArgs(s.dbPath, `UPDATE tasksync_links SET local_title = ?, local_status = ?, remote_title = ?, remote_status = ?,
+		remote_key = ?, url = ?, synced_at = ?, error = '' WHERE task_id = ?`,
+		t.Title, t.Status, tk.Title, tk.Status, tk.Key, tk.URL, now, t.ID); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		res.Conflicts++
+		msg := strings.Join(conflicts, "; ")
+		log.Info("tasksync: conflict", "task", t.ID, "ticket", tk.Key, "resolution", msg)
+		return db.ExecArgs(s.dbPath, `UPDATE tasksync_links SET conflicts = conflicts + 1, conflict = ?, conflict_at = ? WHERE task_id = ?`,
+			msg, now, t.ID)
+	}
+	return nil
+}
+
+// localWins reports whether the task's side of a conflict is kept.
+func (s *Service) localWins(t taskboard.TaskBoard, tk Ticket) bool {
+	switch s.cfg.ConflictOrDefault() {
+	case "tetora":
+		return true
+	case "tracker":
+		return false
+	}
+	updated, err := time.Parse(time.RFC3339, t.UpdatedAt)
+	return err == nil && updated.After(tk.Updated)
+}
+
+// pulledStatus returns the taskboard status a ticket's status maps to. A
+// status mapped by name wins; otherwise the task keeps its status while
+// it is in the ticket's stage, and moves to the stage's plain status when
+// it is not.
+func (s *Service) pulledStatus(current string, tk Ticket) string {
+	for local, remote := range s.cfg.Statuses {
+		if strings.EqualFold(remote, tk.Status) {
+			return local
+		}
+	}
+	if Stage(current) == tk.Stage {
+		return current
+	}
+	return tk.Stage
+}
+
+func (s *Service) setError(l Link, msg string) {
+	if err := db.ExecArgs(s.dbPath, `UPDATE tasksync_links SET error = ?, synced_at = ? WHERE task_id = ?`,
+		msg, formatTime(time.Now()), l.TaskID); err != nil {
+		log.Warn("tasksync: record error failed", "task", l.TaskID, "error", err)
+	}
+	if msg != "" {
+		log.Warn("tasksync: sync failed", "task", l.TaskID, "ticket", l.RemoteKey, "error", msg)
+	}
+}
+
+// --- Schedule ---
+
+// Start syncs every interval until ctx is done.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.IntervalOrDefault())
+		defer ticker.Stop()
+		for {
+			if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+				log.Warn("tasksync: sync failed", "provider", s.cfg.Provider, "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func formatTime(t time.Time) string { return t.UTC().Format(time.RFC3339) }
@@ -0,0 +1,305 @@
+package tasksync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/taskboard"
+)
+
+// fakeClient is an in-memory tracker.
+type fakeClient struct {
+	tickets map[string]Ticket
+	updates []string
+}
+
+func (f *fakeClient) Create(_ context.Context, title, _, status string) (Ticket, error) {
+	id := fmt.Sprintf("r%d", len(f.tickets)+1)
+	tk := Ticket{ID: id, Key: "OPS-" + id[1:], URL: "https://t/" + id, Title: title, Status: "To Do", Stage: StageTodo, Updated: time.Now()}
+	if Stage(status) != StageTodo {
+		tk.Status, tk.Stage = "In Progress", Stage(status)
+	}
+	f.tickets[id] = tk
+	return tk, nil
+}
+
+func (f *fakeClient) Tickets(_ context.Context, ids []string) (map[string]Ticket, error) {
+	out := map[string]Ticket{}
+	for _, id := range ids {
+		if tk, ok := f.tickets[id]; ok {
+			out[id] = tk
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeClient) Update(_ context.Context, id, title, status string) (Ticket, error) {
+	tk := f.tickets[id]
+	if title != "" {
+		tk.Title = title
+	}
+	if status != "" {
+		tk.Stage = Stage(status)
+		tk.Status = map[string]string{StageTodo: "To Do", StageDoing: "In Progress", StageDone: "Done"}[tk.Stage]
+	}
+	f.tickets[id] = tk
+	f.updates = append(f.updates, id+":"+title+":"+status)
+	return tk, nil
+}
+
+// edit changes a ticket as a person in the tracker would.
+func (f *fakeClient) edit(id, title, status, stage string) {
+	tk := f.tickets[id]
+	if title != "" {
+		tk.Title = title
+	}
+	if status != "" {
+		tk.Status, tk.Stage = status, stage
+	}
+	tk.Updated = time.Now().Add(time.Minute)
+	f.tickets[id] = tk
+}
+
+func TestService_Sync(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	board := taskboard.NewEngine(dbPath, config.TaskBoardConfig{}, nil)
+	if err := board.InitSchema(); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	if err := InitDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := board.CreateTask(taskboard.TaskBoard{Title: "Write report", Project: "ops"})
+	b, _ := board.CreateTask(taskboard.TaskBoard{Title: "Fix login", Project: "ops", Status: "doing"})
+	board.CreateTask(taskboard.TaskBoard{Title: "Private", Project: "home"})
+	board.CreateTask(taskboard.TaskBoard{Title: "Old", Project: "ops", Status: "done"})
+
+	fc := &fakeClient{tickets: map[string]Ticket{}}
+	cfg := config.TaskSyncConfig{Provider: "jira", Projects: []string{"ops"}, Statuses: map[string]string{"review": "In Review"}}
+	s := NewWithClient(cfg, dbPath, board, fc)
+	ctx := context.Background()
+
+	res, err := s.Sync(ctx)
+	if err != nil || res.Created != 2 || len(fc.tickets) != 2 {
+		t.Fatalf("first sync = %+v, %v; tickets %v", res, err, fc.tickets)
+	}
+	links, _ := s.Links()
+	if len(links) != 2 || links[0].TaskID != a.ID || links[1].TaskID != b.ID || links[1].RemoteStatus != "In Progress" {
+		t.Fatalf("links = %+v", links)
+	}
+	ra, rb := links[0].RemoteID, links[1].RemoteID
+	if comments, _ := board.GetThread(a.ID); len(comments) != 1 || !strings.Contains(comments[0].Content, "OPS-1") {
+		t.Errorf("comments = %+v", comments)
+	}
+
+	// Nothing changed: nothing to do.
+	if res, err := s.Sync(ctx); err != nil || res != (Result{}) {
+		t.Errorf("idle sync = %+v, %v", res, err)
+	}
+
+	// A local move is pushed; a tracker edit is pulled.
+	board.MoveTask(a.ID, "doing")
+	fc.edit(rb, "Fix login bug", "Done", StageDone)
+	res, err = s.Sync(ctx)
+	if err != nil || res.Pushed != 1 || res.Pulled != 1 || res.Conflicts != 0 {
+		t.Fatalf("sync = %+v, %v", res, err)
+	}
+	if fc.tickets[ra].Status != "In Progress" {
+		t.Errorf("ticket a = %+v", fc.tickets[ra])
+	}
+	if got, _ := board.GetTask(b.ID); got.Title != "Fix login bug" || got.Status != "done" {
+		t.Errorf("task b = %+v", got)
+	}
+
+	// A status mapped by name is pulled as that status, and a move within
+	// the same stage is not pushed back as a stage change.
+	fc.edit(ra, "", "In Review", StageDoing)
+	s.Sync(ctx)
+	if got, _ := board.GetTask(a.ID); got.Status != "review" {
+		t.Errorf("task a = %+v", got)
+	}
+
+	// Both sides renamed: the newest change wins and the conflict is kept.
+	board.UpdateTask(a.ID, map[string]any{"title": "Write Q3 report"})
+	fc.edit(ra, "Write the report", "", "")
+	res, _ = s.Sync(ctx)
+	if res.Conflicts != 1 {
+		t.Fatalf("sync = %+v", res)
+	}
+	if got, _ := board.GetTask(a.ID); got.Title != "Write the report" {
+		t.Errorf("task a = %+v", got)
+	}
+	st, err := s.Status()
+	if err != nil || st.Linked != 2 || len(st.Conflicts) != 1 || !strings.Contains(st.Conflicts[0].Conflict, "title changed on both sides, kept jira") {
+		t.Errorf("status = %+v, %v", st, err)
+	}
+
+	// With the tetora policy the task wins.
+	s.cfg.Conflict = "tetora"
+	board.UpdateTask(a.ID, map[string]any{"title": "Report"})
+	fc.edit(ra, "Reporting", "", "")
+	s.Sync(ctx)
+	if fc.tickets[ra].Title != "Report" {
+		t.Errorf("ticket a = %+v", fc.tickets[ra])
+	}
+
+	// A deleted ticket is flagged; a deleted task drops its link.
+	delete(fc.tickets, rb)
+	board.DeleteTask(a.ID)
+	res, _ = s.Sync(ctx)
+	if res.Unlinked != 1 || res.Errors != 1 {
+		t.Errorf("sync = %+v", res)
+	}
+	st, _ = s.Status()
+	if st.Linked != 1 || len(st.Errors) != 1 || !strings.Contains(st.Errors[0].Error, "not found") {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestJiraClient(t *testing.T) {
+	status := map[string]string{"name": "To Do", "key": "new"}
+	var created, transition map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "me@acme.io" || p != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		issue := func() {
+			fmt.Fprintf(w, `{"id": "100", "key": "OPS-1", "fields": {"summary": "Write report", "updated": "2026-10-15T10:00:00.000+0000",
+				"status": {"name": %q, "statusCategory": {"key": %q}}}}`, status["name"], status["key"])
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /rest/api/2/issue":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "100", "key": "OPS-1"}`))
+		case "GET /rest/api/2/issue/100":
+			issue()
+		case "GET /rest/api/2/issue/100/transitions":
+			w.Write([]byte(`{"transitions": [
+				{"id": "11", "to": {"name": "To Do", "statusCategory": {"key": "new"}}},
+				{"id": "21", "to": {"name": "In Progress", "statusCategory": {"key": "indeterminate"}}},
+				{"id": "31", "to": {"name": "In Review", "statusCategory": {"key": "indeterminate"}}}]}`))
+		case "POST /rest/api/2/issue/100/transitions":
+			json.NewDecoder(r.Body).Decode(&transition)
+			if id := transition["transition"].(map[string]any)["id"]; id == "31" {
+				status = map[string]string{"name": "In Review", "key": "indeterminate"}
+			} else {
+				status = map[string]string{"name": "In Progress", "key": "indeterminate"}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorMessages": ["Issue does not exist"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.TaskSyncConfig{Provider: "jira", URL: srv.URL, Email: "me@acme.io", Token: "tok", Project: "OPS",
+		Statuses: map[string]string{"review": "In Review"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tk, err := c.Create(ctx, "Write report", "details", "doing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := created["fields"].(map[string]any)
+	if fields["project"].(map[string]any)["key"] != "OPS" || fields["issuetype"].(map[string]any)["name"] != "Task" {
+		t.Errorf("create body = %v", created)
+	}
+	if tk.Key != "OPS-1" || tk.URL != srv.URL+"/browse/OPS-1" || tk.Status != "In Progress" || tk.Stage != StageDoing || tk.Updated.IsZero() {
+		t.Errorf("ticket = %+v", tk)
+	}
+
+	// review is in the same stage as doing, but mapped by name.
+	if tk, err := c.Update(ctx, "100", "", "review"); err != nil || tk.Status != "In Review" {
+		t.Errorf("update = %+v, %v", tk, err)
+	}
+	if _, err := c.Update(ctx, "100", "", "done"); err == nil || !strings.Contains(err.Error(), "no transition") {
+		t.Errorf("update to done err = %v", err)
+	}
+
+	got, err := c.Tickets(ctx, []string{"100", "404"})
+	if err != nil || len(got) != 1 || got["100"].Title != "Write report" {
+		t.Errorf("tickets = %+v, %v", got, err)
+	}
+}
+
+func TestLinearClient(t *testing.T) {
+	var inputs []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		issue := `{"id": "u1", "identifier": "ENG-7", "url": "https://linear.app/x/ENG-7", "title": "Fix login", "updatedAt": "2026-10-15T10:00:00.000Z",
+			"state": {"id": "s3", "name": "In Review", "type": "started", "position": 3}}`
+		switch {
+		case strings.Contains(req.Query, "teams("):
+			w.Write([]byte(`{"data": {"teams": {"nodes": [{"id": "team1", "states": {"nodes": [
+				{"id": "s1", "name": "Todo", "type": "unstarted", "position": 1},
+				{"id": "s2", "name": "In Progress", "type": "started", "position": 2},
+				{"id": "s3", "name": "In Review", "type": "started", "position": 3},
+				{"id": "s4", "name": "Done", "type": "completed", "position": 4}]}}]}}}`))
+		case strings.Contains(req.Query, "issueCreate"):
+			inputs = append(inputs, req.Variables["input"].(map[string]any))
+			w.Write([]byte(`{"data": {"issueCreate": {"success": true, "issue": ` + issue + `}}}`))
+		case strings.Contains(req.Query, "issueUpdate"):
+			inputs = append(inputs, req.Variables["input"].(map[string]any))
+			w.Write([]byte(`{"data": {"issueUpdate": {"success": true, "issue": ` + issue + `}}}`))
+		case strings.Contains(req.Query, "issues("):
+			w.Write([]byte(`{"data": {"issues": {"nodes": [` + issue + `]}}}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.TaskSyncConfig{Provider: "linear", URL: srv.URL, Token: "lin_key", Project: "ENG"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tk, err := c.Create(ctx, "Fix login", "", "backlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The team has no backlog state, so the first unstarted one is used.
+	if inputs[0]["teamId"] != "team1" || inputs[0]["stateId"] != "s1" {
+		t.Errorf("create input = %v", inputs[0])
+	}
+	if tk.Key != "ENG-7" || tk.Stage != StageDoing || tk.Status != "In Review" {
+		t.Errorf("ticket = %+v", tk)
+	}
+
+	// Already in a started state: only the title changes.
+	if _, err := c.Update(ctx, "u1", "Fix login bug", "doing"); err != nil {
+		t.Fatal(err)
+	}
+	if inputs[1]["title"] != "Fix login bug" || inputs[1]["stateId"] != nil {
+		t.Errorf("update input = %v", inputs[1])
+	}
+	c.Update(ctx, "u1", "", "done")
+	if inputs[2]["stateId"] != "s4" {
+		t.Errorf("update input = %v", inputs[2])
+	}
+
+	if got, err := c.Tickets(ctx, []string{"u1"}); err != nil || got["u1"].Key != "ENG-7" {
+		t.Errorf("tickets = %+v, %v", got, err)
+	}
+}
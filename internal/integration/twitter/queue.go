@@ -0,0 +1,522 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// Draft statuses.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPosting   = "posting"
+	StatusPosted    = "posted"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	tickInterval  = 30 * time.Second
+	statsInterval = time.Hour
+	statsWindow   = 30 * 24 * time.Hour
+	// minHourSamples is how many measured posts an hour of the day needs
+	// before its engagement is trusted over the configured posting times.
+	minHourSamples = 3
+)
+
+var (
+	// ErrNotFound is returned for an unknown draft ID.
+	ErrNotFound = errors.New("draft not found")
+	// ErrNotPending is returned when a draft that was posted or cancelled is
+	// scheduled, posted or cancelled again.
+	ErrNotPending = errors.New("draft already posted or cancelled")
+)
+
+// Draft is a tweet or thread waiting to be posted. Parts are posted in
+// order, each replying to the one before.
+type Draft struct {
+	ID          string   `json:"id"`
+	Parts       []string `json:"parts"`
+	ReplyTo     string   `json:"replyTo,omitempty"`
+	Status      string   `json:"status"`
+	ScheduledAt string   `json:"scheduledAt,omitempty"`
+	PostedIDs   []string `json:"postedIds,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	CreatedBy   string   `json:"createdBy,omitempty"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+// pending reports whether d may still be scheduled, posted or cancelled.
+func (d *Draft) pending() bool {
+	return d.Status == StatusDraft || d.Status == StatusScheduled || d.Status == StatusFailed
+}
+
+// PostStats are the last fetched metrics of a tweet posted through Tetora.
+type PostStats struct {
+	Metrics
+	DraftID   string `json:"draftId,omitempty"`
+	PostedAt  string `json:"postedAt"`
+	FetchedAt string `json:"fetchedAt,omitempty"`
+}
+
+// HourStat is the engagement of posts made in one local hour of the day.
+type HourStat struct {
+	Hour          int     `json:"hour"`
+	Posts         int     `json:"posts"`
+	AvgEngagement float64 `json:"avgEngagement"`
+}
+
+// poster is the part of Service the queue uses.
+type poster interface {
+	PostThread(ctx context.Context, parts []string, replyTo string) ([]Tweet, error)
+	TweetMetrics(ctx context.Context, ids []string) ([]Metrics, error)
+}
+
+// QueueDeps holds the root-package callbacks the queue needs.
+type QueueDeps struct {
+	NewID func() string
+
+	// Posted is called after the scheduler posted a draft, or failed to,
+	// for the audit log and the owner's notification.
+	Posted func(ctx context.Context, d *Draft, err error)
+}
+
+// Queue keeps drafts in the history DB, posts scheduled ones when they are
+// due and refreshes the engagement stats of what was posted.
+type Queue struct {
+	cfg    Config
+	dbPath string
+	svc    *Service
+	p      poster
+	deps   QueueDeps
+
+	mu sync.Mutex // one draft is posted at a time
+}
+
+// NewQueue creates a queue posting through svc. Call Start to post
+// scheduled drafts.
+func NewQueue(svc *Service, dbPath string, deps QueueDeps) *Queue {
+	return &Queue{cfg: svc.cfg, dbPath: dbPath, svc: svc, p: svc, deps: deps}
+}
+
+// Service returns the API client the queue posts through.
+func (q *Queue) Service() *Service { return q.svc }
+
+// InitDB creates the tweet_drafts and tweet_stats tables.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS tweet_drafts (
+		id TEXT PRIMARY KEY,
+		parts TEXT NOT NULL,
+		reply_to TEXT DEFAULT '',
+		status TEXT NOT NULL,
+		scheduled_at TEXT DEFAULT '',
+		posted_ids TEXT DEFAULT '[]',
+		error TEXT DEFAULT '',
+		created_by TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_tweet_drafts_due ON tweet_drafts(status, scheduled_at);
+	CREATE TABLE IF NOT EXISTS tweet_stats (
+		tweet_id TEXT PRIMARY KEY,
+		draft_id TEXT DEFAULT '',
+		posted_at TEXT NOT NULL,
+		impressions INTEGER DEFAULT 0,
+		likes INTEGER DEFAULT 0,
+		retweets INTEGER DEFAULT 0,
+		replies INTEGER DEFAULT 0,
+		quotes INTEGER DEFAULT 0,
+		bookmarks INTEGER DEFAULT 0,
+		fetched_at TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_tweet_stats_posted ON tweet_stats(posted_at);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// --- Drafts ---
+
+// Compose splits text into a thread (see SplitThread) and saves it as a
+// draft. Nothing is posted until the draft is scheduled or posted.
+func (q *Queue) Compose(text, replyTo, createdBy string, numbered bool) (*Draft, error) {
+	parts := q.Split(text, numbered)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("text is empty")
+	}
+	now := stamp(time.Now())
+	d := &Draft{
+		ID:        q.deps.NewID(),
+		Parts:     parts,
+		ReplyTo:   replyTo,
+		Status:    StatusDraft,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	partsJSON, _ := json.Marshal(parts)
+	if err := db.ExecArgs(q.dbPath, `INSERT INTO tweet_drafts (id, parts, reply_to, status, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, d.ID, string(partsJSON), replyTo, d.Status, createdBy, now, now); err != nil {
+		return nil, fmt.Errorf("save draft: %w", err)
+	}
+	return d, nil
+}
+
+// Split splits text into a thread within the configured tweet length.
+func (q *Queue) Split(text string, numbered bool) []string {
+	return SplitThread(text, q.cfg.MaxTweetLength(), numbered)
+}
+
+// Schedule sets when a draft is posted. A failed draft is retried from the
+// first part that was not posted.
+func (q *Queue) Schedule(id string, at time.Time) (*Draft, error) {
+	d, err := q.pendingDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	d.Status, d.ScheduledAt, d.Error, d.UpdatedAt = StatusScheduled, stamp(at), "", stamp(time.Now())
+	if err := db.ExecArgs(q.dbPath, `UPDATE tweet_drafts SET status = ?, scheduled_at = ?, error = '', updated_at = ? WHERE id = ?`,
+		d.Status, d.ScheduledAt, d.UpdatedAt, id); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Cancel drops a draft that has not been posted.
+func (q *Queue) Cancel(id string) (*Draft, error) {
+	d, err := q.pendingDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	d.Status, d.UpdatedAt = StatusCancelled, stamp(time.Now())
+	if err := db.ExecArgs(q.dbPath, `UPDATE tweet_drafts SET status = ?, updated_at = ? WHERE id = ?`,
+		d.Status, d.UpdatedAt, id); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// PostNow posts a draft right away.
+func (q *Queue) PostNow(ctx context.Context, id string) (*Draft, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d, err := q.pendingDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	return d, q.post(ctx, d)
+}
+
+func (q *Queue) pendingDraft(id string) (*Draft, error) {
+	d, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+	if !d.pending() {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotPending, id, d.Status)
+	}
+	return d, nil
+}
+
+// Get returns a draft, or nil when the ID is unknown.
+func (q *Queue) Get(id string) (*Draft, error) {
+	rows, err := db.QueryArgs(q.dbPath, `SELECT * FROM tweet_drafts WHERE id = ?`, id)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return draftFromRow(rows[0]), nil
+}
+
+// List returns the newest drafts, all of them or those with status.
+func (q *Queue) List(status string, limit int) ([]Draft, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var rows []map[string]any
+	var err error
+	if status == "" {
+		rows, err = db.QueryArgs(q.dbPath, `SELECT * FROM tweet_drafts ORDER BY created_at DESC LIMIT ?`, limit)
+	} else {
+		rows, err = db.QueryArgs(q.dbPath, `SELECT * FROM tweet_drafts WHERE status = ? ORDER BY created_at DESC LIMIT ?`, status, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Draft, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *draftFromRow(r))
+	}
+	return out, nil
+}
+
+func draftFromRow(r map[string]any) *Draft {
+	d := &Draft{
+		ID:          db.Str(r["id"]),
+		ReplyTo:     db.Str(r["reply_to"]),
+		Status:      db.Str(r["status"]),
+		ScheduledAt: db.Str(r["scheduled_at"]),
+		Error:       db.Str(r["error"]),
+		CreatedBy:   db.Str(r["created_by"]),
+		CreatedAt:   db.Str(r["created_at"]),
+		UpdatedAt:   db.Str(r["updated_at"]),
+	}
+	json.Unmarshal([]byte(db.Str(r["parts"])), &d.Parts)
+	json.Unmarshal([]byte(db.Str(r["posted_ids"])), &d.PostedIDs)
+	return d
+}
+
+// --- Posting ---
+
+// Start posts due drafts every 30 seconds and refreshes engagement stats
+// hourly until ctx is done. A draft left "posting" by a crash is marked
+// failed: rescheduling it posts only the parts that are missing.
+func (q *Queue) Start(ctx context.Context) {
+	if err := db.ExecArgs(q.dbPath, `UPDATE tweet_drafts SET status = ?, error = ?, updated_at = ? WHERE status = ?`,
+		StatusFailed, "interrupted while posting", stamp(time.Now()), StatusPosting); err != nil {
+		log.Warn("twitter: reset interrupted drafts failed", "error", err)
+	}
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		var lastStats time.Time
+		for {
+			q.postDue(ctx, time.Now())
+			if time.Since(lastStats) >= statsInterval {
+				if err := q.RefreshStats(ctx); err != nil {
+					log.Warn("twitter: refresh stats failed", "error", err)
+				}
+				lastStats = time.Now()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (q *Queue) postDue(ctx context.Context, now time.Time) {
+	rows, err := db.QueryArgs(q.dbPath, `SELECT * FROM tweet_drafts WHERE status = ? AND scheduled_at <= ? ORDER BY scheduled_at`,
+		StatusScheduled, stamp(now))
+	if err != nil {
+		log.Warn("twitter: list due drafts failed", "error", err)
+		return
+	}
+	for _, r := range rows {
+		if ctx.Err() != nil {
+			return
+		}
+		q.mu.Lock()
+		// Re-read: the draft may have been cancelled or posted meanwhile.
+		d, err := q.Get(db.Str(r["id"]))
+		if err == nil && d != nil && d.Status == StatusScheduled {
+			err = q.post(ctx, d)
+			if q.deps.Posted != nil {
+				q.deps.Posted(ctx, d, err)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// post publishes the parts of d that were not posted yet and records the
+// outcome. Callers hold q.mu.
+func (q *Queue) post(ctx context.Context, d *Draft) error {
+	q.setStatus(d, StatusPosting, "")
+	replyTo := d.ReplyTo
+	if n := len(d.PostedIDs); n > 0 {
+		replyTo = d.PostedIDs[n-1]
+	}
+	tweets, err := q.p.PostThread(ctx, d.Parts[len(d.PostedIDs):], replyTo)
+	for _, t := range tweets {
+		d.PostedIDs = append(d.PostedIDs, t.ID)
+	}
+	q.Record(d.ID, tweets, time.Now())
+	if err != nil {
+		q.setStatus(d, StatusFailed, err.Error())
+		log.Warn("twitter: posting draft failed", "draftId", d.ID, "posted", len(d.PostedIDs), "parts", len(d.Parts), "error", err)
+		return err
+	}
+	q.setStatus(d, StatusPosted, "")
+	log.Info("twitter: draft posted", "draftId", d.ID, "parts", len(d.Parts))
+	return nil
+}
+
+func (q *Queue) setStatus(d *Draft, status, errMsg string) {
+	d.Status, d.Error, d.UpdatedAt = status, errMsg, stamp(time.Now())
+	ids, _ := json.Marshal(d.PostedIDs)
+	if err := db.ExecArgs(q.dbPath, `UPDATE tweet_drafts SET status = ?, error = ?, posted_ids = ?, updated_at = ? WHERE id = ?`,
+		status, errMsg, string(ids), d.UpdatedAt, d.ID); err != nil {
+		log.Warn("twitter: update draft failed", "draftId", d.ID, "error", err)
+	}
+}
+
+// --- Engagement ---
+
+// Record adds posted tweets to the engagement stats. draftID is empty for
+// tweets posted directly.
+func (q *Queue) Record(draftID string, tweets []Tweet, at time.Time) {
+	for _, t := range tweets {
+		if err := db.ExecArgs(q.dbPath, `INSERT OR IGNORE INTO tweet_stats (tweet_id, draft_id, posted_at) VALUES (?, ?, ?)`,
+			t.ID, draftID, stamp(at)); err != nil {
+			log.Warn("twitter: record tweet failed", "tweetId", t.ID, "error", err)
+		}
+	}
+}
+
+// RefreshStats fetches the current metrics of the tweets posted in the
+// last 30 days.
+func (q *Queue) RefreshStats(ctx context.Context) error {
+	rows, err := db.QueryArgs(q.dbPath, `SELECT tweet_id FROM tweet_stats WHERE posted_at >= ? ORDER BY posted_at`,
+		stamp(time.Now().Add(-statsWindow)))
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, db.Str(r["tweet_id"]))
+	}
+	now := stamp(time.Now())
+	for batch := range slices.Chunk(ids, 100) {
+		metrics, err := q.p.TweetMetrics(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, m := range metrics {
+			if err := db.ExecArgs(q.dbPath, `UPDATE tweet_stats SET impressions = ?, likes = ?, retweets = ?, replies = ?,
+				quotes = ?, bookmarks = ?, fetched_at = ? WHERE tweet_id = ?`,
+				m.Impressions, m.Likes, m.Retweets, m.Replies, m.Quotes, m.Bookmarks, now, m.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stats returns the metrics of tweets posted in the last days (default 30),
+// newest first.
+func (q *Queue) Stats(days int) ([]PostStats, error) {
+	if days <= 0 {
+		days = 30
+	}
+	rows, err := db.QueryArgs(q.dbPath, `SELECT * FROM tweet_stats WHERE posted_at >= ? ORDER BY posted_at DESC`,
+		stamp(time.Now().AddDate(0, 0, -days)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PostStats, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, PostStats{
+			Metrics: Metrics{
+				ID:          db.Str(r["tweet_id"]),
+				Impressions: db.Int(r["impressions"]),
+				Likes:       db.Int(r["likes"]),
+				Retweets:    db.Int(r["retweets"]),
+				Replies:     db.Int(r["replies"]),
+				Quotes:      db.Int(r["quotes"]),
+				Bookmarks:   db.Int(r["bookmarks"]),
+			},
+			DraftID:   db.Str(r["draft_id"]),
+			PostedAt:  db.Str(r["posted_at"]),
+			FetchedAt: db.Str(r["fetched_at"]),
+		})
+	}
+	return out, nil
+}
+
+// EngagementByHour averages the engagement of measured posts per local hour
+// of the day they were posted, for the hours that have posts.
+func EngagementByHour(stats []PostStats, loc *time.Location) []HourStat {
+	var posts, total [24]int
+	for _, s := range stats {
+		at, err := time.Parse(time.RFC3339, s.PostedAt)
+		if err != nil || s.FetchedAt == "" {
+			continue
+		}
+		h := at.In(loc).Hour()
+		posts[h]++
+		total[h] += s.Engagement()
+	}
+	var out []HourStat
+	for h := range 24 {
+		if posts[h] > 0 {
+			out = append(out, HourStat{Hour: h, Posts: posts[h], AvgEngagement: float64(total[h]) / float64(posts[h])})
+		}
+	}
+	return out
+}
+
+// OptimalTime returns the next time after after to post: the start of the
+// local hour whose posts had the best average engagement, once an hour has
+// enough measured posts, otherwise the next configured posting time.
+func (q *Queue) OptimalTime(after time.Time) time.Time {
+	stats, err := q.Stats(int(statsWindow / (24 * time.Hour)))
+	if err != nil {
+		log.Warn("twitter: read stats failed", "error", err)
+	}
+	return optimalTime(EngagementByHour(stats, after.Location()), q.cfg.PostingTimesOrDefault(), after)
+}
+
+// ResolveTime parses a requested posting time: RFC3339, or "optimal" (or
+// empty) for OptimalTime.
+func (q *Queue) ResolveTime(at string, now time.Time) (time.Time, error) {
+	if at == "" || at == "optimal" {
+		return q.OptimalTime(now), nil
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("at must be RFC3339 or \"optimal\": %w", err)
+	}
+	if t.Before(now.Add(-time.Minute)) {
+		return time.Time{}, fmt.Errorf("at %s is in the past", at)
+	}
+	return t, nil
+}
+
+func optimalTime(hours []HourStat, slots []string, after time.Time) time.Time {
+	best := -1
+	var bestAvg float64
+	for _, h := range hours {
+		if h.Posts >= minHourSamples && (best < 0 || h.AvgEngagement > bestAvg) {
+			best, bestAvg = h.Hour, h.AvgEngagement
+		}
+	}
+	if best >= 0 {
+		slots = []string{fmt.Sprintf("%02d:00", best)}
+	}
+	var next time.Time
+	for _, slot := range slots {
+		t, err := time.Parse("15:04", slot)
+		if err != nil {
+			continue
+		}
+		at := time.Date(after.Year(), after.Month(), after.Day(), t.Hour(), t.Minute(), 0, 0, after.Location())
+		if !at.After(after) {
+			at = at.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return after
+	}
+	return next
+}
+
+// stamp formats t in UTC so stored times compare as strings.
+func stamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,104 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakePoster posts tweets in memory and fails once after failAfter posts.
+type fakePoster struct {
+	posted    []Tweet
+	replyTo   []string
+	failAfter int
+}
+
+func (f *fakePoster) PostThread(_ context.Context, parts []string, replyTo string) ([]Tweet, error) {
+	var out []Tweet
+	for _, p := range parts {
+		if f.failAfter > 0 && len(f.posted) == f.failAfter {
+			f.failAfter = 0
+			return out, errors.New("503 service unavailable")
+		}
+		t := Tweet{ID: fmt.Sprintf("t%d", len(f.posted)+1), Text: p}
+		f.posted = append(f.posted, t)
+		f.replyTo = append(f.replyTo, replyTo)
+		out = append(out, t)
+		replyTo = t.ID
+	}
+	return out, nil
+}
+
+func (f *fakePoster) TweetMetrics(_ context.Context, ids []string) ([]Metrics, error) {
+	var out []Metrics
+	for i, id := range ids {
+		out = append(out, Metrics{ID: id, Likes: 10 * (i + 1), Retweets: 1})
+	}
+	return out, nil
+}
+
+func newTestQueue(t *testing.T, p *fakePoster) *Queue {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	n := 0
+	q := NewQueue(New(Config{Enabled: true}, nil), dbPath, QueueDeps{NewID: func() string {
+		n++
+		return fmt.Sprintf("d%d", n)
+	}})
+	q.p = p
+	return q
+}
+
+func TestQueue_ScheduledThreadResumesAfterFailure(t *testing.T) {
+	p := &fakePoster{failAfter: 2}
+	q := newTestQueue(t, p)
+	ctx := context.Background()
+
+	d, err := q.Compose("one\n---\ntwo\n---\nthree", "root", "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Schedule(d.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	q.postDue(ctx, time.Now())
+	if len(p.posted) != 0 {
+		t.Fatalf("posted before due: %v", p.posted)
+	}
+
+	// The third part fails: the draft keeps what was posted.
+	q.postDue(ctx, time.Now().Add(2*time.Hour))
+	got, _ := q.Get(d.ID)
+	if got.Status != StatusFailed || !slices.Equal(got.PostedIDs, []string{"t1", "t2"}) || got.Error == "" {
+		t.Fatalf("after failure = %+v", got)
+	}
+
+	// Rescheduled, only the missing part is posted, replying to the last one.
+	q.Schedule(d.ID, time.Now())
+	q.postDue(ctx, time.Now().Add(time.Second))
+	got, _ = q.Get(d.ID)
+	if got.Status != StatusPosted || !slices.Equal(got.PostedIDs, []string{"t1", "t2", "t3"}) {
+		t.Fatalf("after resume = %+v", got)
+	}
+	if !slices.Equal(p.replyTo, []string{"root", "t1", "t2"}) || p.posted[2].Text != "three" {
+		t.Errorf("thread chain = %v %v", p.replyTo, p.posted)
+	}
+	if _, err := q.Cancel(d.ID); !errors.Is(err, ErrNotPending) {
+		t.Errorf("cancel posted draft: %v", err)
+	}
+
+	if err := q.RefreshStats(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := q.Stats(1)
+	if len(stats) != 3 || stats[0].DraftID != d.ID || stats[0].FetchedAt == "" || stats[0].Engagement() == 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
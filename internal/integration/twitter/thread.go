@@ -0,0 +1,172 @@
+package twitter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// urlWeight is what any URL counts for: X shortens links to t.co.
+const urlWeight = 23
+
+var (
+	urlRe = regexp.MustCompile(`https?://\S+`)
+
+	// breakRe is a manual thread break: a line of three or more dashes.
+	breakRe     = regexp.MustCompile(`\n[ \t]*-{3,}[ \t]*(\n|$)`)
+	paragraphRe = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	sentenceRe  = regexp.MustCompile(`[.!?…]+["')\]]*\s+|[。！？]+[」』）]*\s*`)
+	wordRe      = regexp.MustCompile(`\s+`)
+)
+
+// WeightedLength returns text's length as X counts it against the 280
+// limit: URLs count 23, Latin and most punctuation count 1, and other
+// characters (CJK, emoji) count 2.
+func WeightedLength(text string) int {
+	n := 0
+	last := 0
+	for _, m := range urlRe.FindAllStringIndex(text, -1) {
+		n += runeWeight(text[last:m[0]]) + urlWeight
+		last = m[1]
+	}
+	return n + runeWeight(text[last:])
+}
+
+func runeWeight(s string) int {
+	n := 0
+	for _, r := range s {
+		switch {
+		case r <= 0x10FF, r >= 0x2000 && r <= 0x200D, r >= 0x2010 && r <= 0x201F, r >= 0x2032 && r <= 0x2037:
+			n++
+		default:
+			n += 2
+		}
+	}
+	return n
+}
+
+// SplitThread splits text into tweets of at most max weighted characters.
+// A line of "---" forces a break; otherwise text is broken between
+// paragraphs, then sentences, then words, and only as a last resort inside a
+// word. When numbered, each part of a multi-part thread ends with " i/N".
+// Text that fits in one tweet is returned as is.
+func SplitThread(text string, max int, numbered bool) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	if WeightedLength(text) <= max && !breakRe.MatchString(text) {
+		return []string{text}
+	}
+	if !numbered {
+		return chunkThread(text, max)
+	}
+	// Reserve room for the widest suffix; widen it if the thread grows
+	// past 9 (or 99) parts.
+	reserve := len(" 1/1")
+	for {
+		parts := chunkThread(text, max-reserve)
+		n := len(parts)
+		if w := len(fmt.Sprintf(" %d/%d", n, n)); w > reserve {
+			reserve = w
+			continue
+		}
+		if n == 1 {
+			return parts
+		}
+		for i := range parts {
+			parts[i] += fmt.Sprintf(" %d/%d", i+1, n)
+		}
+		return parts
+	}
+}
+
+func chunkThread(text string, max int) []string {
+	var out []string
+	for _, section := range breakRe.Split(text, -1) {
+		out = append(out, pack(splitKeep(section, paragraphRe), max, 0)...)
+	}
+	return out
+}
+
+// levels are the boundaries pack falls back to, coarsest first, for a piece
+// that does not fit; past the last one a piece is cut between characters.
+var levels = []*regexp.Regexp{paragraphRe, sentenceRe, wordRe}
+
+// pack greedily joins pieces (which keep their trailing separators) into
+// chunks of at most max, breaking an oversized piece at the next level.
+func pack(pieces []string, max, level int) []string {
+	var out []string
+	cur := ""
+	flush := func() {
+		if s := strings.TrimSpace(cur); s != "" {
+			out = append(out, s)
+		}
+		cur = ""
+	}
+	for _, p := range pieces {
+		if WeightedLength(strings.TrimSpace(cur+p)) <= max {
+			cur += p
+			continue
+		}
+		flush()
+		if WeightedLength(strings.TrimSpace(p)) <= max {
+			cur = p
+			continue
+		}
+		var sub []string
+		if level+1 < len(levels) {
+			sub = pack(splitKeep(p, levels[level+1]), max, level+1)
+		} else {
+			sub = cutRunes(strings.TrimSpace(p), max)
+		}
+		// Later pieces may still fit after the last chunk.
+		if len(sub) > 0 {
+			out = append(out, sub[:len(sub)-1]...)
+			cur = sub[len(sub)-1] + trailingSpace(p)
+		}
+	}
+	flush()
+	return out
+}
+
+// splitKeep splits s after each match of re, keeping the match on the
+// piece before it, so the pieces concatenate back to s.
+func splitKeep(s string, re *regexp.Regexp) []string {
+	var out []string
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		if m[1] > last {
+			out = append(out, s[last:m[1]])
+			last = m[1]
+		}
+	}
+	if last < len(s) {
+		out = append(out, s[last:])
+	}
+	return out
+}
+
+func cutRunes(s string, max int) []string {
+	var out []string
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		w := runeWeight(string(r))
+		if n+w > max && b.Len() > 0 {
+			out = append(out, b.String())
+			b.Reset()
+			n = 0
+		}
+		b.WriteRune(r)
+		n += w
+	}
+	if b.Len() > 0 {
+		out = append(out, b.String())
+	}
+	return out
+}
+
+func trailingSpace(s string) string {
+	return s[len(strings.TrimRight(s, " \t\n")):]
+}
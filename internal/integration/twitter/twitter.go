@@ -1,3 +1,10 @@
+// Package twitter posts to and reads from Twitter/X through the API v2,
+// authenticated with the "twitter" OAuth service.
+//
+// Besides single posts it composes threads (SplitThread), keeps a queue of
+// drafts that are posted when their scheduled time comes (Queue), and
+// records the engagement of what was posted, which also picks the "optimal"
+// posting time.
 package twitter
 
 import (
@@ -21,6 +28,10 @@ type Config struct {
 	RateLimit   *bool  `json:"rateLimit,omitempty"`
 	MaxTweetLen int    `json:"maxTweetLen,omitempty"`
 	DefaultUser string `json:"defaultUser,omitempty"`
+
+	// PostingTimes are local "HH:MM" slots used as the optimal posting time
+	// until enough engagement stats have been collected.
+	PostingTimes []string `json:"postingTimes,omitempty"`
 }
 
 // RateLimitEnabled returns whether rate limiting is enabled (default true).
@@ -39,10 +50,19 @@ func (c Config) MaxTweetLength() int {
 	return 280
 }
 
+// PostingTimesOrDefault returns the configured posting slots, or 09:00,
+// 12:30 and 19:00.
+func (c Config) PostingTimesOrDefault() []string {
+	if len(c.PostingTimes) > 0 {
+		return c.PostingTimes
+	}
+	return []string{"09:00", "12:30", "19:00"}
+}
+
 // Service manages Twitter API v2 interactions.
 type Service struct {
 	cfg         Config
-	oauth       oauthif.TokenProvider
+	oauth       oauthif.Requester
 	rateLimiter map[string]*rateLimit
 	mu          sync.Mutex
 }
@@ -81,7 +101,7 @@ type User struct {
 }
 
 // New creates a new TwitterService.
-func New(cfg Config, oauth oauthif.TokenProvider) *Service {
+func New(cfg Config, oauth oauthif.Requester) *Service {
 	return &Service{
 		cfg:         cfg,
 		oauth:       oauth,
@@ -160,8 +180,8 @@ func (s *Service) doRequest(ctx context.Context, endpoint, method, reqURL string
 // PostTweet posts a new tweet.
 func (s *Service) PostTweet(ctx context.Context, text string, replyTo string) (*Tweet, error) {
 	maxLen := s.cfg.MaxTweetLength()
-	if len([]rune(text)) > maxLen {
-		return nil, fmt.Errorf("tweet text exceeds maximum length of %d characters", maxLen)
+	if WeightedLength(text) > maxLen {
+		return nil, fmt.Errorf("tweet text exceeds maximum length of %d characters (use a thread)", maxLen)
 	}
 
 	reqBody := map[string]any{"text": text}
@@ -202,6 +222,22 @@ func (s *Service) PostTweet(ctx context.Context, text string, replyTo string) (*
 	}, nil
 }
 
+// PostThread posts parts as a thread, each part replying to the one before
+// it; the first replies to replyTo when set. On failure it returns the
+// tweets already posted with the error, so the thread can be resumed.
+func (s *Service) PostThread(ctx context.Context, parts []string, replyTo string) ([]Tweet, error) {
+	posted := make([]Tweet, 0, len(parts))
+	for i, part := range parts {
+		t, err := s.PostTweet(ctx, part, replyTo)
+		if err != nil {
+			return posted, fmt.Errorf("part %d/%d: %w", i+1, len(parts), err)
+		}
+		posted = append(posted, *t)
+		replyTo = t.ID
+	}
+	return posted, nil
+}
+
 // ReadTimeline reads the authenticated user's home timeline.
 func (s *Service) ReadTimeline(ctx context.Context, maxResults int) ([]Tweet, error) {
 	if maxResults <= 0 {
@@ -338,8 +374,89 @@ func (s *Service) GetUserByUsername(ctx context.Context, username string) (*User
 	}, nil
 }
 
+// Metrics are a tweet's public engagement counts.
+type Metrics struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"createdAt,omitempty"`
+	Impressions int    `json:"impressions"`
+	Likes       int    `json:"likes"`
+	Retweets    int    `json:"retweets"`
+	Replies     int    `json:"replies"`
+	Quotes      int    `json:"quotes"`
+	Bookmarks   int    `json:"bookmarks"`
+}
+
+// Engagement is the number of interactions with the tweet.
+func (m Metrics) Engagement() int {
+	return m.Likes + m.Retweets + m.Replies + m.Quotes + m.Bookmarks
+}
+
+// TweetMetrics fetches the public metrics of up to 100 tweets. Deleted or
+// unavailable tweets are left out.
+func (s *Service) TweetMetrics(ctx context.Context, ids []string) ([]Metrics, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > 100 {
+		ids = ids[:100]
+	}
+	params := url.Values{}
+	params.Set("ids", strings.Join(ids, ","))
+	params.Set("tweet.fields", "created_at,public_metrics")
+
+	reqURL := fmt.Sprintf("%s/tweets?%s", BaseURL, params.Encode())
+	resp, err := s.doRequest(ctx, "GET /tweets", http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("twitter tweet metrics (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	return ParseMetricsResponse(resp.Body)
+}
+
 // --- Response Parsing ---
 
+// ParseMetricsResponse parses a Twitter API v2 tweets lookup response
+// requested with tweet.fields=public_metrics.
+func ParseMetricsResponse(body io.Reader) ([]Metrics, error) {
+	var resp struct {
+		Data []struct {
+			ID            string `json:"id"`
+			CreatedAt     string `json:"created_at"`
+			PublicMetrics struct {
+				Impressions int `json:"impression_count"`
+				Likes       int `json:"like_count"`
+				Retweets    int `json:"retweet_count"`
+				Replies     int `json:"reply_count"`
+				Quotes      int `json:"quote_count"`
+				Bookmarks   int `json:"bookmark_count"`
+			} `json:"public_metrics"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode metrics response: %w", err)
+	}
+	out := make([]Metrics, 0, len(resp.Data))
+	for _, d := range resp.Data {
+		pm := d.PublicMetrics
+		out = append(out, Metrics{
+			ID:          d.ID,
+			CreatedAt:   d.CreatedAt,
+			Impressions: pm.Impressions,
+			Likes:       pm.Likes,
+			Retweets:    pm.Retweets,
+			Replies:     pm.Replies,
+			Quotes:      pm.Quotes,
+			Bookmarks:   pm.Bookmarks,
+		})
+	}
+	return out, nil
+}
+
 // ParseTweetsResponse parses a Twitter API v2 tweets response with user expansions.
 func ParseTweetsResponse(body io.Reader) ([]Tweet, error) {
 	var resp struct {
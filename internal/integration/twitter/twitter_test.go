@@ -3,6 +3,7 @@ package twitter
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- Config.RateLimitEnabled ---
@@ -203,3 +204,79 @@ func TestParseTweetsResponse_InvalidJSON(t *testing.T) {
 		t.Error("ParseTweetsResponse() expected error for invalid JSON, got nil")
 	}
 }
+
+// --- Threads ---
+
+func TestWeightedLength(t *testing.T) {
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"hello", 5},
+		{"日本語", 6},
+		{"see https://example.com/a/very/long/path/that/goes/on/and/on", 4 + 23},
+		{"café — ok", 9},
+	}
+	for _, c := range cases {
+		if got := WeightedLength(c.text); got != c.want {
+			t.Errorf("WeightedLength(%q) = %d, want %d", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSplitThread(t *testing.T) {
+	if got := SplitThread("  short  ", 280, true); len(got) != 1 || got[0] != "short" {
+		t.Errorf("short text = %q", got)
+	}
+
+	sentence := strings.Repeat("word ", 19) + "end. " // 100 chars
+	text := sentence + sentence + sentence + "\n\n" + sentence + sentence
+	parts := SplitThread(text, 280, true)
+	if len(parts) != 3 {
+		t.Fatalf("parts = %d, want 3: %q", len(parts), parts)
+	}
+	for i, p := range parts {
+		if WeightedLength(p) > 280 {
+			t.Errorf("part %d is %d long", i, WeightedLength(p))
+		}
+		if want := []string{" 1/3", " 2/3", " 3/3"}[i]; !strings.HasSuffix(p, want) {
+			t.Errorf("part %d = %q, want suffix %q", i, p, want)
+		}
+	}
+	// The long paragraph is split between sentences; the next one is kept
+	// whole rather than filling the second tweet.
+	if strings.Count(parts[0], "end.") != 2 || strings.Count(parts[1], "end.") != 1 || strings.Count(parts[2], "end.") != 2 {
+		t.Errorf("split not at sentence and paragraph boundaries: %q", parts)
+	}
+
+	// Manual breaks, and CJK text without spaces is cut by weight.
+	parts = SplitThread("first\n---\n"+strings.Repeat("字", 200), 280, false)
+	if len(parts) != 3 || parts[0] != "first" || WeightedLength(parts[1]) != 280 || WeightedLength(parts[2]) != 120 {
+		t.Errorf("manual/CJK split = %d parts: %q", len(parts), parts)
+	}
+	if got := strings.Join(parts[1:], ""); got != strings.Repeat("字", 200) {
+		t.Error("CJK text not preserved across parts")
+	}
+}
+
+func TestOptimalTime(t *testing.T) {
+	loc := time.FixedZone("JST", 9*3600)
+	now := time.Date(2026, 5, 4, 13, 0, 0, 0, loc)
+	slots := []string{"09:00", "12:30", "19:00"}
+
+	if got := optimalTime(nil, slots, now); !got.Equal(time.Date(2026, 5, 4, 19, 0, 0, 0, loc)) {
+		t.Errorf("no stats: %v", got)
+	}
+	if got := optimalTime(nil, slots, now.Add(7*time.Hour)); !got.Equal(time.Date(2026, 5, 5, 9, 0, 0, 0, loc)) {
+		t.Errorf("after the last slot: %v", got)
+	}
+
+	hours := []HourStat{
+		{Hour: 8, Posts: 5, AvgEngagement: 40},
+		{Hour: 21, Posts: 2, AvgEngagement: 90}, // too few posts to trust
+		{Hour: 12, Posts: 3, AvgEngagement: 12},
+	}
+	if got := optimalTime(hours, slots, now); !got.Equal(time.Date(2026, 5, 5, 8, 0, 0, 0, loc)) {
+		t.Errorf("from stats: %v", got)
+	}
+}
@@ -0,0 +1,616 @@
+// Package intent answers short utterances with a skill or tool directly.
+//
+// Shortcuts map patterns such as "lights off" or "log {km:number}km run" to a
+// skill or tool call. A message that matches is handled before smart dispatch,
+// without an LLM round trip; anything else falls through as usual. With
+// learning on, the single tool call behind a short routed message is
+// recorded, and utterances whose routing the owner confirmed with a thumbs up
+// are suggested as new shortcuts.
+package intent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// Shortcut is one utterance→action mapping.
+type Shortcut = config.IntentShortcut
+
+// Match is a shortcut that matched a message, with its captured slots.
+type Match struct {
+	Shortcut Shortcut          `json:"shortcut"`
+	Pattern  string            `json:"pattern"`
+	Slots    map[string]string `json:"slots,omitempty"`
+}
+
+// Suggestion is an utterance the owner confirmed, routed to the same tool call
+// often enough to be worth a shortcut.
+type Suggestion struct {
+	Utterance string          `json:"utterance"`
+	Tool      string          `json:"tool"`
+	Input     json.RawMessage `json:"input"`
+	Count     int             `json:"count"`
+}
+
+// ErrUnavailable is returned by Deps.Check when a shortcut's skill or tool
+// cannot run here (missing, or gated behind the owner's approval). The
+// message then goes to smart dispatch instead.
+var ErrUnavailable = errors.New("shortcut target unavailable")
+
+// Deps runs shortcut targets. Check is optional.
+type Deps struct {
+	RunSkill func(ctx context.Context, name string, vars map[string]string) (string, error)
+	RunTool  func(ctx context.Context, name, agent string, input json.RawMessage) (string, error)
+	Check    func(sc Shortcut) error
+}
+
+// maxPendingTasks bounds the tool calls kept for routed tasks not yet observed.
+const maxPendingTasks = 200
+
+type compiled struct {
+	sc       Shortcut
+	patterns []*pattern
+}
+
+type toolCall struct {
+	name  string
+	input json.RawMessage
+}
+
+// Service matches messages against shortcuts, runs them and learns new ones.
+type Service struct {
+	dbPath string
+	cfg    config.IntentsConfig
+	deps   Deps
+
+	mu        sync.Mutex
+	shortcuts []compiled // config shortcuts first, then learned ones
+	calls     map[string][]toolCall
+	order     []string // task IDs in calls, oldest first
+}
+
+// New creates a service with the configured shortcuts and loads the learned
+// ones. Invalid shortcuts are logged and skipped.
+func New(dbPath string, cfg config.IntentsConfig, deps Deps) *Service {
+	s := &Service{
+		dbPath: dbPath,
+		cfg:    cfg,
+		deps:   deps,
+		calls:  make(map[string][]toolCall),
+	}
+	if err := s.reload(); err != nil {
+		log.Warn("intent: load shortcuts failed", "error", err)
+	}
+	return s
+}
+
+// Match returns the shortcut for text when it matches one that can run here.
+// Commands never match. A false result means the message should go to smart
+// dispatch.
+func (s *Service) Match(text string) (Match, bool) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "/") || strings.HasPrefix(text, "!") {
+		return Match{}, false
+	}
+	m, ok := s.Lookup(text)
+	if !ok {
+		return Match{}, false
+	}
+	if s.deps.Check != nil {
+		if err := s.deps.Check(m.Shortcut); err != nil {
+			log.Info("intent: shortcut unavailable, dispatching", "shortcut", m.Shortcut.Name, "error", err)
+			return Match{}, false
+		}
+	}
+	return m, true
+}
+
+// Execute runs a matched shortcut and returns the chat reply.
+func (s *Service) Execute(ctx context.Context, m Match) string {
+	out, err := s.Run(ctx, m)
+	if err != nil {
+		log.Warn("intent: shortcut failed", "shortcut", m.Shortcut.Name, "error", err)
+		return fmt.Sprintf("%s failed: %v", m.Shortcut.Name, err)
+	}
+	log.Info("intent: shortcut handled", "shortcut", m.Shortcut.Name, "pattern", m.Pattern)
+	return s.formatReply(m, out)
+}
+
+// Lookup returns the first shortcut matching text, without running it.
+func (s *Service) Lookup(text string) (Match, bool) {
+	norm := Normalize(text)
+	if norm == "" || len(strings.Fields(norm)) > s.cfg.MaxWordsOrDefault() {
+		return Match{}, false
+	}
+	s.mu.Lock()
+	shortcuts := s.shortcuts
+	s.mu.Unlock()
+	for _, c := range shortcuts {
+		for _, p := range c.patterns {
+			if slots, ok := p.match(norm); ok {
+				return Match{Shortcut: c.sc, Pattern: p.src, Slots: slots}, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// Run executes a matched shortcut and returns its raw output.
+func (s *Service) Run(ctx context.Context, m Match) (string, error) {
+	sc := m.Shortcut
+	types := slotTypes(sc.Patterns)
+	args, _ := expand(sc.Args, m.Slots, types).(map[string]any)
+	switch {
+	case sc.Skill != "":
+		if s.deps.RunSkill == nil {
+			return "", fmt.Errorf("skills cannot run here")
+		}
+		vars := make(map[string]string, len(m.Slots)+len(args))
+		for k, v := range m.Slots {
+			vars[k] = v
+		}
+		for k, v := range args {
+			if str, ok := v.(string); ok {
+				vars[k] = str
+			} else {
+				b, _ := json.Marshal(v)
+				vars[k] = string(b)
+			}
+		}
+		return s.deps.RunSkill(ctx, sc.Skill, vars)
+	case sc.Tool != "":
+		if s.deps.RunTool == nil {
+			return "", fmt.Errorf("tools cannot run here")
+		}
+		if args == nil {
+			args = map[string]any{}
+		}
+		input, err := json.Marshal(args)
+		if err != nil {
+			return "", err
+		}
+		return s.deps.RunTool(ctx, sc.Tool, sc.Agent, input)
+	}
+	return "", fmt.Errorf("shortcut %q has no target", sc.Name)
+}
+
+func (s *Service) formatReply(m Match, out string) string {
+	out = strings.TrimSpace(out)
+	tmpl := m.Shortcut.Reply
+	if tmpl == "" {
+		tmpl = "{output}"
+	}
+	reply := strings.ReplaceAll(tmpl, "{output}", out)
+	for k, v := range m.Slots {
+		reply = strings.ReplaceAll(reply, "{"+k+"}", v)
+	}
+	if reply = strings.TrimSpace(reply); reply == "" {
+		return "Done: " + m.Shortcut.Name
+	}
+	return reply
+}
+
+// --- Shortcuts ---
+
+// List returns the configured shortcuts followed by the learned ones.
+func (s *Service) List() []Shortcut {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Shortcut, 0, len(s.shortcuts))
+	for _, c := range s.shortcuts {
+		out = append(out, c.sc)
+	}
+	return out
+}
+
+// Add stores a learned shortcut.
+func (s *Service) Add(sc Shortcut) (Shortcut, error) {
+	sc.ID = 0
+	sc.Name = strings.TrimSpace(sc.Name)
+	c, err := compileShortcut(sc)
+	if err != nil {
+		return Shortcut{}, err
+	}
+	s.mu.Lock()
+	for _, other := range s.shortcuts {
+		if strings.EqualFold(other.sc.Name, sc.Name) {
+			s.mu.Unlock()
+			return Shortcut{}, fmt.Errorf("shortcut %q already exists", sc.Name)
+		}
+	}
+	s.mu.Unlock()
+
+	patterns, _ := json.Marshal(sc.Patterns)
+	args, _ := json.Marshal(sc.Args)
+	rows, err := db.QueryArgs(s.dbPath,
+		`INSERT INTO intent_shortcuts (name, patterns, skill, tool, args, agent, reply, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+		 SELECT last_insert_rowid() AS id;`,
+		sc.Name, string(patterns), sc.Skill, sc.Tool, string(args), sc.Agent, sc.Reply, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return Shortcut{}, err
+	}
+	if len(rows) == 0 {
+		return Shortcut{}, fmt.Errorf("insert returned no id")
+	}
+	c.sc.ID = db.Int(rows[0]["id"])
+	s.mu.Lock()
+	s.shortcuts = append(s.shortcuts, c)
+	s.mu.Unlock()
+	return c.sc, nil
+}
+
+// Delete removes a learned shortcut. Configured shortcuts are edited in the
+// config file.
+func (s *Service) Delete(id int) error {
+	s.mu.Lock()
+	idx := -1
+	for i, c := range s.shortcuts {
+		if id > 0 && c.sc.ID == id {
+			idx = i
+		}
+	}
+	s.mu.Unlock()
+	if idx < 0 {
+		return fmt.Errorf("learned shortcut %d not found", id)
+	}
+	if err := db.ExecArgs(s.dbPath, `DELETE FROM intent_shortcuts WHERE id = ?`, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for i, c := range s.shortcuts {
+		if c.sc.ID == id {
+			s.shortcuts = append(s.shortcuts[:i:i], s.shortcuts[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) reload() error {
+	var shortcuts []compiled
+	for _, sc := range s.cfg.Shortcuts {
+		sc.ID = 0
+		c, err := compileShortcut(sc)
+		if err != nil {
+			log.Warn("intent: shortcut skipped", "shortcut", sc.Name, "error", err)
+			continue
+		}
+		shortcuts = append(shortcuts, c)
+	}
+	var loadErr error
+	if s.dbPath != "" {
+		rows, err := db.Query(s.dbPath, `SELECT * FROM intent_shortcuts ORDER BY id`)
+		loadErr = err
+		for _, row := range rows {
+			sc := Shortcut{
+				ID:    db.Int(row["id"]),
+				Name:  db.Str(row["name"]),
+				Skill: db.Str(row["skill"]),
+				Tool:  db.Str(row["tool"]),
+				Agent: db.Str(row["agent"]),
+				Reply: db.Str(row["reply"]),
+			}
+			json.Unmarshal([]byte(db.Str(row["patterns"])), &sc.Patterns)
+			json.Unmarshal([]byte(db.Str(row["args"])), &sc.Args)
+			c, err := compileShortcut(sc)
+			if err != nil {
+				log.Warn("intent: learned shortcut skipped", "id", sc.ID, "error", err)
+				continue
+			}
+			shortcuts = append(shortcuts, c)
+		}
+	}
+	s.mu.Lock()
+	s.shortcuts = shortcuts
+	s.mu.Unlock()
+	return loadErr
+}
+
+func compileShortcut(sc Shortcut) (compiled, error) {
+	if sc.Name == "" {
+		return compiled{}, fmt.Errorf("name is required")
+	}
+	if (sc.Skill == "") == (sc.Tool == "") {
+		return compiled{}, fmt.Errorf("shortcut %q needs either a skill or a tool", sc.Name)
+	}
+	if len(sc.Patterns) == 0 {
+		return compiled{}, fmt.Errorf("shortcut %q has no patterns", sc.Name)
+	}
+	c := compiled{sc: sc}
+	for _, src := range sc.Patterns {
+		p, err := compilePattern(src)
+		if err != nil {
+			return compiled{}, fmt.Errorf("shortcut %q: %w", sc.Name, err)
+		}
+		c.patterns = append(c.patterns, p)
+	}
+	return c, nil
+}
+
+// --- Learning ---
+
+// RecordToolCall remembers a tool call made by a routed task, for Observe.
+func (s *Service) RecordToolCall(taskID, name string, input json.RawMessage) {
+	if !s.cfg.Learn || taskID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.calls[taskID]; !ok {
+		s.order = append(s.order, taskID)
+		if len(s.order) > maxPendingTasks {
+			delete(s.calls, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	s.calls[taskID] = append(s.calls[taskID], toolCall{name: name, input: input})
+}
+
+// Observe records which tool call answered utterance once its routed task is
+// done. Only short utterances answered by exactly one tool call, and not
+// already covered by a shortcut, are kept.
+func (s *Service) Observe(taskID, utterance string) {
+	s.mu.Lock()
+	calls, ok := s.calls[taskID]
+	if ok {
+		delete(s.calls, taskID)
+		for i, id := range s.order {
+			if id == taskID {
+				s.order = append(s.order[:i:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+	if !s.cfg.Learn || s.dbPath == "" || len(calls) != 1 {
+		return
+	}
+	norm := Normalize(utterance)
+	if norm == "" || len(strings.Fields(norm)) > s.cfg.MaxWordsOrDefault() {
+		return
+	}
+	if _, covered := s.Lookup(norm); covered {
+		return
+	}
+	input := compactJSON(calls[0].input)
+	if err := db.ExecArgs(s.dbPath,
+		`INSERT INTO intent_observations (task_id, utterance, tool, input, created_at) VALUES (?, ?, ?, ?, ?)`,
+		taskID, norm, calls[0].name, input, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		log.Warn("intent: record observation failed", "error", err)
+	}
+}
+
+// Suggest returns utterances routed to the same tool call at least
+// learnAfter times with a thumbs up from the owner, and never with a thumbs
+// down, that no shortcut covers yet.
+func (s *Service) Suggest() []Suggestion {
+	if s.dbPath == "" {
+		return nil
+	}
+	rows, err := db.Query(s.dbPath, fmt.Sprintf(
+		`SELECT o.utterance, o.tool, o.input,
+		        SUM(CASE WHEN f.score > 0 THEN 1 ELSE 0 END) AS ups,
+		        SUM(CASE WHEN f.score < 0 THEN 1 ELSE 0 END) AS downs,
+		        MAX(o.created_at) AS last_at
+		   FROM intent_observations o JOIN task_feedback f ON f.task_id = o.task_id
+		  GROUP BY o.utterance, o.tool, o.input
+		 HAVING ups >= %d AND downs = 0
+		  ORDER BY ups DESC, last_at DESC LIMIT 20`,
+		s.cfg.LearnAfterOrDefault()))
+	if err != nil {
+		log.Warn("intent: load suggestions failed", "error", err)
+		return nil
+	}
+	var out []Suggestion
+	for _, row := range rows {
+		sg := Suggestion{
+			Utterance: db.Str(row["utterance"]),
+			Tool:      db.Str(row["tool"]),
+			Input:     json.RawMessage(db.Str(row["input"])),
+			Count:     db.Int(row["ups"]),
+		}
+		if _, covered := s.Lookup(sg.Utterance); covered {
+			continue
+		}
+		out = append(out, sg)
+	}
+	return out
+}
+
+// Accept turns a suggestion into a learned shortcut that repeats the
+// confirmed tool call for the exact utterance.
+func (s *Service) Accept(sg Suggestion) (Shortcut, error) {
+	var args map[string]any
+	if len(sg.Input) > 0 {
+		if err := json.Unmarshal(sg.Input, &args); err != nil {
+			return Shortcut{}, fmt.Errorf("suggestion input: %w", err)
+		}
+	}
+	return s.Add(Shortcut{
+		Name:     sg.Utterance,
+		Patterns: []string{escapeSlots(sg.Utterance)},
+		Tool:     sg.Tool,
+		Args:     args,
+	})
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// --- Patterns ---
+
+// Normalize lowercases text, collapses whitespace and drops trailing
+// punctuation, the form patterns are matched against.
+func Normalize(text string) string {
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	return strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
+
+var slotRe = regexp.MustCompile(`\{(\w+)(?::(\w+))?\}`)
+
+type pattern struct {
+	src   string
+	re    *regexp.Regexp
+	slots []string
+}
+
+func compilePattern(src string) (*pattern, error) {
+	// Like Normalize, but a trailing slot's brace is kept.
+	norm := strings.Join(strings.Fields(strings.ToLower(src)), " ")
+	norm = strings.TrimRightFunc(norm, func(r rune) bool { return r != '}' && unicode.IsPunct(r) })
+	if norm == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	p := &pattern{src: src}
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range slotRe.FindAllStringSubmatchIndex(norm, -1) {
+		b.WriteString(regexp.QuoteMeta(norm[last:loc[0]]))
+		name := norm[loc[2]:loc[3]]
+		typ := ""
+		if loc[4] >= 0 {
+			typ = norm[loc[4]:loc[5]]
+		}
+		for _, seen := range p.slots {
+			if seen == name {
+				return nil, fmt.Errorf("pattern %q: slot {%s} used twice", src, name)
+			}
+		}
+		switch typ {
+		case "":
+			b.WriteString(`(.+?)`)
+		case "number":
+			b.WriteString(`(-?\d+(?:[.,]\d+)?)`)
+		case "word":
+			b.WriteString(`(\S+)`)
+		default:
+			return nil, fmt.Errorf("pattern %q: unknown slot type %q (use number or word)", src, typ)
+		}
+		p.slots = append(p.slots, name)
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(norm[last:]))
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", src, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+func (p *pattern) match(norm string) (map[string]string, bool) {
+	m := p.re.FindStringSubmatch(norm)
+	if m == nil {
+		return nil, false
+	}
+	var slots map[string]string
+	for i, name := range p.slots {
+		if slots == nil {
+			slots = make(map[string]string, len(p.slots))
+		}
+		slots[name] = strings.TrimSpace(m[i+1])
+	}
+	return slots, true
+}
+
+// slotTypes returns the declared type of each slot across patterns.
+func slotTypes(patterns []string) map[string]string {
+	types := make(map[string]string)
+	for _, src := range patterns {
+		for _, m := range slotRe.FindAllStringSubmatch(strings.ToLower(src), -1) {
+			types[m[1]] = m[2]
+		}
+	}
+	return types
+}
+
+// escapeSlots keeps literal braces in a learned utterance from being read as
+// slots.
+func escapeSlots(s string) string {
+	return slotRe.ReplaceAllStringFunc(s, func(m string) string {
+		return strings.NewReplacer("{", "(", "}", ")").Replace(m)
+	})
+}
+
+// expand replaces "{slot}" in the strings of v. A string that is exactly one
+// number slot becomes a JSON number.
+func expand(v any, slots map[string]string, types map[string]string) any {
+	switch x := v.(type) {
+	case string:
+		if m := slotRe.FindStringSubmatch(x); m != nil && m[0] == x && types[m[1]] == "number" {
+			if val, ok := slots[m[1]]; ok {
+				if n, err := strconv.ParseFloat(strings.Replace(val, ",", ".", 1), 64); err == nil {
+					return n
+				}
+			}
+		}
+		for k, val := range slots {
+			x = strings.ReplaceAll(x, "{"+k+"}", val)
+		}
+		return x
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, val := range x {
+			out[k] = expand(val, slots, types)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, val := range x {
+			out[i] = expand(val, slots, types)
+		}
+		return out
+	}
+	return v
+}
+
+// InitDB creates the learned shortcut and observation tables.
+func InitDB(dbPath string) error {
+	if dbPath == "" {
+		return nil
+	}
+	sql := `CREATE TABLE IF NOT EXISTS intent_shortcuts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  patterns TEXT NOT NULL,
+  skill TEXT DEFAULT '',
+  tool TEXT DEFAULT '',
+  args TEXT DEFAULT '',
+  agent TEXT DEFAULT '',
+  reply TEXT DEFAULT '',
+  created_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS intent_observations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id TEXT NOT NULL,
+  utterance TEXT NOT NULL,
+  tool TEXT NOT NULL,
+  input TEXT DEFAULT '',
+  created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_intent_observations_task ON intent_observations(task_id);`
+	return db.Exec(dbPath, sql)
+}
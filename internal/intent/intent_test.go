@@ -0,0 +1,209 @@
+package intent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/feedback"
+)
+
+type toolRun struct {
+	name, agent string
+	input       map[string]any
+}
+
+func newTestService(t *testing.T, cfg config.IntentsConfig) (*Service, *[]toolRun) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if err := feedback.InitDB(dbPath); err != nil {
+		t.Fatalf("feedback.InitDB: %v", err)
+	}
+	var runs []toolRun
+	deps := Deps{
+		RunTool: func(ctx context.Context, name, agent string, input json.RawMessage) (string, error) {
+			if name == "broken" {
+				return "", errors.New("device offline")
+			}
+			var m map[string]any
+			json.Unmarshal(input, &m)
+			runs = append(runs, toolRun{name, agent, m})
+			return "ok", nil
+		},
+		RunSkill: func(ctx context.Context, name string, vars map[string]string) (string, error) {
+			return name + " " + vars["km"] + " " + vars["note"], nil
+		},
+		Check: func(sc Shortcut) error {
+			if sc.Tool == "gated" {
+				return ErrUnavailable
+			}
+			return nil
+		},
+	}
+	return New(dbPath, cfg, deps), &runs
+}
+
+func TestPatterns(t *testing.T) {
+	for _, c := range []struct {
+		pattern, text string
+		slots         map[string]string
+		ok            bool
+	}{
+		{"lights off", "Lights  OFF!", nil, true},
+		{"lights off", "lights off please", nil, false},
+		{"log {km:number}km run", "log 2.5km run", map[string]string{"km": "2.5"}, true},
+		{"log {km:number}km run", "log far km run", nil, false},
+		{"play {song}", "Play Yellow Submarine.", map[string]string{"song": "yellow submarine"}, true},
+		{"set {room:word} to {temp:number}", "set kitchen to 21", map[string]string{"room": "kitchen", "temp": "21"}, true},
+		{"set {room:word} to {temp:number}", "set living room to 21", nil, false},
+		{"電気を消して", "電気を消して。", nil, true},
+	} {
+		p, err := compilePattern(c.pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", c.pattern, err)
+		}
+		slots, ok := p.match(Normalize(c.text))
+		if ok != c.ok || len(slots) != len(c.slots) {
+			t.Errorf("%q ~ %q = %v %v", c.pattern, c.text, slots, ok)
+			continue
+		}
+		for k, v := range c.slots {
+			if slots[k] != v {
+				t.Errorf("%q ~ %q: %s = %q, want %q", c.pattern, c.text, k, slots[k], v)
+			}
+		}
+	}
+	for _, bad := range []string{"{a} and {a}", "{x:date}", "  "} {
+		if _, err := compilePattern(bad); err == nil {
+			t.Errorf("%q compiled", bad)
+		}
+	}
+}
+
+// handle matches and runs text the way the chat bots do.
+func handle(s *Service, text string) (string, bool) {
+	m, ok := s.Match(text)
+	if !ok {
+		return "", false
+	}
+	return s.Execute(context.Background(), m), true
+}
+
+func TestMatchAndExecute(t *testing.T) {
+	s, runs := newTestService(t, config.IntentsConfig{
+		Enabled: true,
+		Shortcuts: []config.IntentShortcut{
+			{Name: "lights", Patterns: []string{"lights {state:word}"}, Tool: "ha_call_service", Agent: "home",
+				Args: map[string]any{"service": "light.turn_{state}", "data": map[string]any{"area": "all"}}},
+			{Name: "run", Patterns: []string{"log {km:number}km run", "ran {km:number}km"}, Tool: "habit_log",
+				Args: map[string]any{"distance": "{km}", "note": "{km}km"}, Reply: "Logged {km}km ({output})"},
+			{Name: "stretch", Patterns: []string{"stretch {note}"}, Skill: "workout"},
+			{Name: "alarm", Patterns: []string{"alarm"}, Tool: "gated"},
+			{Name: "garage", Patterns: []string{"open garage"}, Tool: "broken"},
+			{Name: "bad", Patterns: []string{"nothing"}},
+		},
+	})
+
+	if reply, ok := handle(s, "Lights off"); !ok || reply != "ok" {
+		t.Errorf("lights = %q %v", reply, ok)
+	}
+	if reply, ok := handle(s, "ran 5,5km"); !ok || reply != "Logged 5,5km (ok)" {
+		t.Errorf("run = %q %v", reply, ok)
+	}
+	if len(*runs) != 2 {
+		t.Fatalf("runs = %+v", *runs)
+	}
+	if r := (*runs)[0]; r.agent != "home" || r.input["service"] != "light.turn_off" || r.input["data"].(map[string]any)["area"] != "all" {
+		t.Errorf("lights input = %+v", r)
+	}
+	if r := (*runs)[1]; r.input["distance"] != 5.5 || r.input["note"] != "5,5km" {
+		t.Errorf("run input = %+v", r.input)
+	}
+	if reply, ok := handle(s, "stretch 10 minutes"); !ok || reply != "workout  10 minutes" {
+		t.Errorf("skill = %q %v", reply, ok)
+	}
+
+	// Unavailable targets and non-matches go to smart dispatch.
+	for _, text := range []string{"alarm", "lights off now please do it", "/lights off", "what's the weather", "nothing"} {
+		if reply, ok := handle(s, text); ok {
+			t.Errorf("%q handled: %q", text, reply)
+		}
+	}
+	if reply, ok := handle(s, "open garage"); !ok || reply != "garage failed: device offline" {
+		t.Errorf("failure = %q %v", reply, ok)
+	}
+	if n := len(s.List()); n != 5 {
+		t.Errorf("%d shortcuts loaded, want 5 (invalid one skipped)", n)
+	}
+}
+
+func TestLearning(t *testing.T) {
+	s, runs := newTestService(t, config.IntentsConfig{Enabled: true, Learn: true, LearnAfter: 2})
+	confirm := func(taskID, utterance string, score int, calls ...string) {
+		for _, c := range calls {
+			s.RecordToolCall(taskID, c, json.RawMessage(`{ "service": "light.turn_off" }`))
+		}
+		s.Observe(taskID, utterance)
+		db.ExecArgs(s.dbPath, `INSERT INTO task_feedback (task_id, score, created_at, updated_at) VALUES (?, ?, '', '')`, taskID, score)
+	}
+	confirm("t1", "Lights off!", 1, "ha_call_service")
+	if got := s.Suggest(); len(got) != 0 {
+		t.Fatalf("suggested after one confirmation: %+v", got)
+	}
+	confirm("t2", "lights off", 1, "ha_call_service")
+	confirm("t3", "dim the lights", 1, "ha_call_service")
+	confirm("t4", "dim the lights", -1, "ha_call_service")
+	confirm("t5", "good night", 1, "ha_call_service", "music_stop") // two calls: not learned
+	confirm("t6", "good night", 1, "ha_call_service", "music_stop")
+	confirm("t7", "please turn off every light in the house right now", 1, "ha_call_service")
+	confirm("t8", "please turn off every light in the house right now", 1, "ha_call_service")
+
+	got := s.Suggest()
+	if len(got) != 1 || got[0].Utterance != "lights off" || got[0].Tool != "ha_call_service" || got[0].Count != 2 ||
+		string(got[0].Input) != `{"service":"light.turn_off"}` {
+		t.Fatalf("suggestions = %+v", got)
+	}
+
+	sc, err := s.Accept(got[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Accept(got[0]); err == nil {
+		t.Error("duplicate shortcut accepted")
+	}
+	if got := s.Suggest(); len(got) != 0 {
+		t.Errorf("accepted suggestion still listed: %+v", got)
+	}
+	if reply, ok := handle(s, "Lights off."); !ok || reply != "ok" || (*runs)[0].input["service"] != "light.turn_off" {
+		t.Errorf("learned shortcut = %q %v", reply, ok)
+	}
+
+	// Learned shortcuts survive a restart and can be deleted.
+	s2 := New(s.dbPath, s.cfg, s.deps)
+	if _, ok := s2.Lookup("lights off"); !ok {
+		t.Error("learned shortcut not reloaded")
+	}
+	if err := s2.Delete(sc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s2.Lookup("lights off"); ok || s2.Delete(sc.ID) == nil {
+		t.Error("delete failed")
+	}
+}
+
+func TestRecordToolCallBounded(t *testing.T) {
+	s, _ := newTestService(t, config.IntentsConfig{Learn: true})
+	for i := 0; i < maxPendingTasks+10; i++ {
+		s.RecordToolCall(string(rune('a'+i%26))+string(rune(i)), "x", nil)
+	}
+	if len(s.calls) != maxPendingTasks || len(s.order) != maxPendingTasks {
+		t.Errorf("pending = %d/%d", len(s.calls), len(s.order))
+	}
+}
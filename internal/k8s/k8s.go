@@ -0,0 +1,275 @@
+// Package k8s runs kubectl for the k8s tools.
+//
+// Every call names a configured cluster and a namespace it allows. Resource
+// kinds are limited to workload, networking and node objects (never secrets
+// or config maps' contents), and names must be valid Kubernetes names, so
+// agent input cannot smuggle extra kubectl flags. Reads are always allowed;
+// the mutating calls (rollout restart, scale, pod deletion) require the
+// cluster's mutate flag and are approved by the owner before they run.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// maxOutput caps what one call returns.
+const maxOutput = 64 << 10
+
+var (
+	nameRe     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+	selectorRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/=,!-]*$`)
+	sinceRe    = regexp.MustCompile(`^[0-9]+[smh]$`)
+)
+
+// ReadKinds are the resource kinds the read tools accept.
+var ReadKinds = []string{
+	"pods", "deployments", "replicasets", "statefulsets", "daemonsets", "jobs", "cronjobs",
+	"services", "ingresses", "endpoints", "persistentvolumeclaims", "horizontalpodautoscalers",
+	"nodes", "namespaces",
+}
+
+// clusterScoped kinds take no namespace.
+var clusterScoped = map[string]bool{"nodes": true, "namespaces": true}
+
+// Target is a checked cluster and namespace.
+type Target struct {
+	Name      string
+	Cluster   config.KubeClusterConfig
+	Namespace string
+}
+
+// Resolve checks that cluster is configured and namespace allowed on it; an
+// empty namespace is the cluster's default.
+func Resolve(cfg config.KubernetesConfig, cluster, namespace string) (Target, error) {
+	c, ok := cfg.Clusters[cluster]
+	if !ok {
+		return Target{}, fmt.Errorf("unknown cluster %q", cluster)
+	}
+	if namespace == "" {
+		namespace = "default"
+		if len(c.Namespaces) > 0 {
+			namespace = c.Namespaces[0]
+		}
+	}
+	if !nameRe.MatchString(namespace) {
+		return Target{}, fmt.Errorf("invalid namespace %q", namespace)
+	}
+	if len(c.Namespaces) > 0 && !slices.Contains(c.Namespaces, namespace) {
+		return Target{}, fmt.Errorf("namespace %q is not allowed on cluster %q", namespace, cluster)
+	}
+	return Target{Name: cluster, Cluster: c, Namespace: namespace}, nil
+}
+
+// shortNames are kubectl's abbreviations for the accepted kinds.
+var shortNames = map[string]string{
+	"po": "pods", "deploy": "deployments", "rs": "replicasets", "sts": "statefulsets", "ds": "daemonsets",
+	"cj": "cronjobs", "svc": "services", "ing": "ingresses", "ep": "endpoints", "pvc": "persistentvolumeclaims",
+	"hpa": "horizontalpodautoscalers", "no": "nodes", "ns": "namespaces",
+}
+
+// CheckKind accepts one of kinds, also in singular or short form ("pod",
+// "deploy").
+func CheckKind(kind string, kinds []string) (string, error) {
+	kind = strings.ToLower(kind)
+	if long, ok := shortNames[kind]; ok {
+		kind = long
+	}
+	for _, k := range kinds {
+		if kind == k || kind+"s" == k || kind+"es" == k {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("kind %q is not allowed (use one of %s)", kind, strings.Join(kinds, ", "))
+}
+
+// CheckName rejects anything that is not a Kubernetes object name.
+func CheckName(what, name string) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid %s %q", what, name)
+	}
+	return nil
+}
+
+// Get lists objects of kind, or one named object, optionally by label
+// selector.
+func Get(ctx context.Context, cfg config.KubernetesConfig, t Target, kind, name, selector string) (string, error) {
+	kind, err := CheckKind(kind, ReadKinds)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"get", kind}
+	if name != "" {
+		if err := CheckName("name", name); err != nil {
+			return "", err
+		}
+		args = append(args, name)
+	}
+	if selector != "" {
+		if !selectorRe.MatchString(selector) {
+			return "", fmt.Errorf("invalid label selector %q", selector)
+		}
+		args = append(args, "-l", selector)
+	}
+	return Run(ctx, cfg, t, !clusterScoped[kind], append(args, "-o", "wide")...)
+}
+
+// Describe describes one object.
+func Describe(ctx context.Context, cfg config.KubernetesConfig, t Target, kind, name string) (string, error) {
+	kind, err := CheckKind(kind, ReadKinds)
+	if err != nil {
+		return "", err
+	}
+	if err := CheckName("name", name); err != nil {
+		return "", err
+	}
+	return Run(ctx, cfg, t, !clusterScoped[kind], "describe", kind, name)
+}
+
+// LogOptions selects a pod's log lines.
+type LogOptions struct {
+	Container string
+	Previous  bool   // the last terminated container, e.g. after a crash
+	Tail      int    // lines; default 200, at most 2000
+	Since     string // "10m", "2h"
+}
+
+// Logs returns a pod's recent log lines.
+func Logs(ctx context.Context, cfg config.KubernetesConfig, t Target, pod string, opts LogOptions) (string, error) {
+	if err := CheckName("pod", pod); err != nil {
+		return "", err
+	}
+	if opts.Tail <= 0 {
+		opts.Tail = 200
+	}
+	args := []string{"logs", pod, "--tail=" + strconv.Itoa(min(opts.Tail, 2000))}
+	if opts.Container != "" {
+		if err := CheckName("container", opts.Container); err != nil {
+			return "", err
+		}
+		args = append(args, "-c", opts.Container)
+	}
+	if opts.Previous {
+		args = append(args, "--previous")
+	}
+	if opts.Since != "" {
+		if !sinceRe.MatchString(opts.Since) {
+			return "", fmt.Errorf("invalid since %q (use e.g. 10m or 2h)", opts.Since)
+		}
+		args = append(args, "--since="+opts.Since)
+	}
+	return Run(ctx, cfg, t, true, args...)
+}
+
+// Events lists the namespace's events, oldest first, optionally only those
+// about the named object.
+func Events(ctx context.Context, cfg config.KubernetesConfig, t Target, name string) (string, error) {
+	args := []string{"get", "events", "--sort-by=.lastTimestamp"}
+	if name != "" {
+		if err := CheckName("name", name); err != nil {
+			return "", err
+		}
+		args = append(args, "--field-selector=involvedObject.name="+name)
+	}
+	return Run(ctx, cfg, t, true, args...)
+}
+
+// ErrReadOnly is returned for mutations on clusters without mutate.
+var ErrReadOnly = errors.New("cluster is read-only (set mutate to allow changes)")
+
+// RolloutRestart restarts a deployment, statefulset or daemonset.
+func RolloutRestart(ctx context.Context, cfg config.KubernetesConfig, t Target, kind, name string) (string, error) {
+	kind, err := checkMutation(t, kind, name, []string{"deployments", "statefulsets", "daemonsets"})
+	if err != nil {
+		return "", err
+	}
+	return Run(ctx, cfg, t, true, "rollout", "restart", kind+"/"+name)
+}
+
+// Scale sets a deployment's or statefulset's replica count.
+func Scale(ctx context.Context, cfg config.KubernetesConfig, t Target, kind, name string, replicas int) (string, error) {
+	kind, err := checkMutation(t, kind, name, []string{"deployments", "statefulsets"})
+	if err != nil {
+		return "", err
+	}
+	if replicas < 0 || replicas > 100 {
+		return "", fmt.Errorf("replicas must be between 0 and 100")
+	}
+	return Run(ctx, cfg, t, true, "scale", kind+"/"+name, "--replicas="+strconv.Itoa(replicas))
+}
+
+// DeletePod deletes a pod so its controller replaces it.
+func DeletePod(ctx context.Context, cfg config.KubernetesConfig, t Target, pod string) (string, error) {
+	if _, err := checkMutation(t, "pods", pod, []string{"pods"}); err != nil {
+		return "", err
+	}
+	return Run(ctx, cfg, t, true, "delete", "pod", pod, "--wait=false")
+}
+
+func checkMutation(t Target, kind, name string, kinds []string) (string, error) {
+	if !t.Cluster.Mutate {
+		return "", ErrReadOnly
+	}
+	kind, err := CheckKind(kind, kinds)
+	if err != nil {
+		return "", err
+	}
+	return kind, CheckName("name", name)
+}
+
+// Run runs kubectl with args against the target's cluster, in its namespace
+// when namespaced, and returns the combined output, capped. A failing
+// command returns its output in the error.
+func Run(ctx context.Context, cfg config.KubernetesConfig, t Target, namespaced bool, args ...string) (string, error) {
+	base := []string{"--request-timeout=" + cfg.TimeoutOrDefault().String()}
+	if t.Cluster.Kubeconfig != "" {
+		base = append(base, "--kubeconfig", expandHome(t.Cluster.Kubeconfig))
+	}
+	if t.Cluster.Context != "" {
+		base = append(base, "--context", t.Cluster.Context)
+	}
+	if namespaced {
+		base = append(base, "-n", t.Namespace)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.TimeoutOrDefault()+5*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, cfg.KubectlOrDefault(), append(base, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	text := out.String()
+	if len(text) > maxOutput {
+		text = text[len(text)-maxOutput:] + "\n... (truncated to the last 64KB)"
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("kubectl %s timed out", args[0])
+	}
+	if err != nil {
+		return "", fmt.Errorf("kubectl %s: %v: %s", args[0], err, strings.TrimSpace(text))
+	}
+	return text, nil
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
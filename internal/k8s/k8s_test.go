@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestResolve(t *testing.T) {
+	cfg := config.KubernetesConfig{Clusters: map[string]config.KubeClusterConfig{
+		"prod": {Namespaces: []string{"shop", "payments"}},
+		"dev":  {},
+	}}
+	for _, tc := range []struct {
+		cluster, ns, want, err string
+	}{
+		{"prod", "", "shop", ""},
+		{"prod", "payments", "payments", ""},
+		{"prod", "kube-system", "", "not allowed"},
+		{"dev", "", "default", ""},
+		{"dev", "anything", "anything", ""},
+		{"dev", "--all-namespaces", "", "invalid namespace"},
+		{"staging", "", "", "unknown cluster"},
+	} {
+		got, err := Resolve(cfg, tc.cluster, tc.ns)
+		if tc.err == "" && (err != nil || got.Namespace != tc.want) || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Resolve(%q, %q) = %q, %v", tc.cluster, tc.ns, got.Namespace, err)
+		}
+	}
+}
+
+func TestCheckKind(t *testing.T) {
+	for kind, want := range map[string]string{
+		"pods": "pods", "pod": "pods", "Deployment": "deployments", "deploy": "deployments",
+		"ingress": "ingresses", "svc": "services", "secrets": "", "configmaps": "", "-o": "",
+	} {
+		got, err := CheckKind(kind, ReadKinds)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("CheckKind(%q) = %q, %v", kind, got, err)
+		}
+	}
+	for _, name := range []string{"-n", "--kubeconfig=/x", "a b", "Web", "web;rm", ""} {
+		if CheckName("name", name) == nil {
+			t.Errorf("CheckName(%q) passed", name)
+		}
+	}
+	if err := CheckName("name", "web-7d9f8-abcde"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// The fake kubectl prints its arguments, and fails for "missing".
+	fake := filepath.Join(t.TempDir(), "kubectl")
+	os.WriteFile(fake, []byte(`#!/bin/sh
+case "$*" in *missing*) echo 'Error from server (NotFound): pods "missing" not found' >&2; exit 1 ;; esac
+echo "$*"
+`), 0o755)
+	cfg := config.KubernetesConfig{Kubectl: fake, Timeout: "5s", Clusters: map[string]config.KubeClusterConfig{
+		"prod": {Kubeconfig: "/kube/prod", Context: "prod-admin", Namespaces: []string{"shop"}},
+		"dev":  {Mutate: true},
+	}}
+	prod, _ := Resolve(cfg, "prod", "")
+	dev, _ := Resolve(cfg, "dev", "")
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		call func() (string, error)
+		want string
+	}{
+		{"get", func() (string, error) { return Get(ctx, cfg, prod, "pod", "", "app=web") },
+			"--request-timeout=5s --kubeconfig /kube/prod --context prod-admin -n shop get pods -l app=web -o wide"},
+		{"get nodes", func() (string, error) { return Get(ctx, cfg, prod, "nodes", "", "") },
+			"--request-timeout=5s --kubeconfig /kube/prod --context prod-admin get nodes -o wide"},
+		{"logs", func() (string, error) {
+			return Logs(ctx, cfg, prod, "web-1", LogOptions{Container: "app", Previous: true, Tail: 5000, Since: "10m"})
+		}, "-n shop logs web-1 --tail=2000 -c app --previous --since=10m"},
+		{"events", func() (string, error) { return Events(ctx, cfg, dev, "web-1") },
+			"-n default get events --sort-by=.lastTimestamp --field-selector=involvedObject.name=web-1"},
+		{"restart", func() (string, error) { return RolloutRestart(ctx, cfg, dev, "deploy", "web") },
+			"-n default rollout restart deployments/web"},
+		{"scale", func() (string, error) { return Scale(ctx, cfg, dev, "statefulset", "db", 2) },
+			"-n default scale statefulsets/db --replicas=2"},
+	} {
+		out, err := tc.call()
+		if err != nil || !strings.HasSuffix(strings.TrimSpace(out), tc.want) {
+			t.Errorf("%s = %q, %v; want suffix %q", tc.name, out, err, tc.want)
+		}
+	}
+
+	if _, err := Describe(ctx, cfg, prod, "pod", "missing"); err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Errorf("describe missing = %v", err)
+	}
+	if _, err := Logs(ctx, cfg, prod, "web-1", LogOptions{Since: "1d; rm"}); err == nil {
+		t.Error("bad since accepted")
+	}
+	if _, err := DeletePod(ctx, cfg, prod, "web-1"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("delete on read-only cluster = %v", err)
+	}
+	if _, err := Scale(ctx, cfg, dev, "daemonsets", "agent", 1); err == nil {
+		t.Error("scaled a daemonset")
+	}
+	if _, err := Scale(ctx, cfg, dev, "deployments", "web", 500); err == nil {
+		t.Error("scaled to 500")
+	}
+}
@@ -0,0 +1,122 @@
+package knowledge
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Citation names a knowledge file, and optionally the heading within it,
+// that an answer relies on. File is relative to the knowledge directory.
+type Citation struct {
+	File    string `json:"file"`
+	Heading string `json:"heading,omitempty"`
+}
+
+func (c Citation) String() string {
+	if c.Heading == "" {
+		return c.File
+	}
+	return c.File + " § " + c.Heading
+}
+
+// Marker returns the inline form agents are asked to write,
+// "[source: file § Heading]".
+func (c Citation) Marker() string {
+	return "[source: " + c.String() + "]"
+}
+
+var citationRe = regexp.MustCompile(`\[source:\s*([^\]§\n]+?)\s*(?:§\s*([^\]\n]*?)\s*)?\]`)
+
+// ParseCitations returns the source markers in text, in order of first
+// appearance. The files are as written and still need checking with Cite.
+func ParseCitations(text string) []Citation {
+	var out []Citation
+	seen := map[Citation]bool{}
+	for _, m := range citationRe.FindAllStringSubmatch(text, -1) {
+		c := Citation{File: m[1], Heading: m[2]}
+		if !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// sameFile reports whether a file as written in a marker names the indexed
+// file name: exactly, or as the tail of a longer path.
+func sameFile(written, name string) bool {
+	written = strings.TrimPrefix(strings.ReplaceAll(written, `\`, "/"), "./")
+	return written == name || strings.HasSuffix(written, "/"+name)
+}
+
+// Cite checks a citation against the index: the file must be indexed and
+// in scope. The heading is kept when the file has it, with the file's own
+// spelling, and dropped otherwise.
+func (idx *Index) Cite(c Citation, scope Scope) (Citation, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var doc *docEntry
+	for name, d := range idx.docs {
+		if sameFile(c.File, name) && scope.allows(d) && (doc == nil || len(name) > len(doc.filename)) {
+			doc = d
+		}
+	}
+	if doc == nil {
+		return Citation{}, false
+	}
+	out := Citation{File: doc.filename}
+	if c.Heading == "" {
+		return out, true
+	}
+	fenced := false
+	for _, line := range doc.lines {
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if h, ok := headingText(t); ok && !fenced && strings.EqualFold(h, strings.TrimLeft(c.Heading, "# ")) {
+			out.Heading = h
+			break
+		}
+	}
+	return out, true
+}
+
+// RenderSources numbers the cited sources for a chat reply: each marker of
+// one of cites becomes "[n]" and a Sources list follows the text. Markers
+// that cite something else are left as written.
+func RenderSources(text string, cites []Citation) string {
+	if len(cites) == 0 {
+		return text
+	}
+	text = citationRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := citationRe.FindStringSubmatch(m)
+		file, heading := sub[1], strings.TrimLeft(sub[2], "# ")
+		n := 0
+		for i, c := range cites {
+			if !sameFile(file, c.File) {
+				continue
+			}
+			if strings.EqualFold(heading, c.Heading) {
+				n = i + 1
+				break
+			}
+			if c.Heading == "" && n == 0 {
+				n = i + 1
+			}
+		}
+		if n == 0 {
+			return m
+		}
+		return fmt.Sprintf("[%d]", n)
+	})
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(text, "\n"))
+	b.WriteString("\n\nSources:")
+	for i, c := range cites {
+		fmt.Fprintf(&b, "\n[%d] %s", i+1, c)
+	}
+	return b.String()
+}
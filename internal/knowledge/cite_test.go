@@ -0,0 +1,50 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCitations(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "hr"), 0o755)
+	os.WriteFile(filepath.Join(dir, "hr", "leave.md"), []byte("# Leave policy\n\n## Carry-over\nUp to five days carry over.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "eng.md"), []byte("# Deploys\nFridays are frozen.\n"), 0o644)
+	idx, err := BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := "Five days carry over [source: hr/leave.md § carry-over], " +
+		"no Friday deploys [source: /home/me/.tetora/knowledge/eng.md § Releases] " +
+		"and a guess [source: made-up.md]. Again [source: hr/leave.md § carry-over]."
+	refs := ParseCitations(out)
+	if len(refs) != 3 || refs[0] != (Citation{File: "hr/leave.md", Heading: "carry-over"}) || refs[2].Heading != "" {
+		t.Fatalf("ParseCitations = %+v", refs)
+	}
+
+	var cites []Citation
+	for _, ref := range refs {
+		if c, ok := idx.Cite(ref, Scope{}); ok {
+			cites = append(cites, c)
+		}
+	}
+	want := []Citation{{File: "hr/leave.md", Heading: "Carry-over"}, {File: "eng.md"}}
+	if len(cites) != 2 || cites[0] != want[0] || cites[1] != want[1] {
+		t.Fatalf("Cite = %+v, want %+v", cites, want)
+	}
+	if _, ok := idx.Cite(refs[0], Scope{Tags: []string{"eng"}}); ok {
+		t.Error("citation outside the scope accepted")
+	}
+
+	got := RenderSources(out, cites)
+	wantText := "Five days carry over [1], no Friday deploys [2] and a guess [source: made-up.md]. Again [1].\n\n" +
+		"Sources:\n[1] hr/leave.md § Carry-over\n[2] eng.md"
+	if got != wantText {
+		t.Errorf("RenderSources =\n%s\nwant\n%s", got, wantText)
+	}
+	if RenderSources(out, nil) != out {
+		t.Error("output without citations changed")
+	}
+}
@@ -714,6 +714,39 @@ func ReindexAll(ctx context.Context, cfg EmbeddingConfig, dbPath, knowledgeDir s
 	return nil
 }
 
+// ReindexFile replaces the embeddings of one document, named name under
+// source, with those of content. Empty content only removes them.
+func ReindexFile(ctx context.Context, cfg EmbeddingConfig, dbPath, source, name, content string) error {
+	if !cfg.Enabled {
+		return fmt.Errorf("embedding not enabled")
+	}
+	// Chunk IDs are "<name>#chunk<n>", which sort between "<name>#chunk" and "<name>#chunl".
+	n := db.Escape(name)
+	del := fmt.Sprintf(`DELETE FROM embeddings WHERE source = '%s' AND (source_id = '%s' OR (source_id > '%s#chunk' AND source_id < '%s#chunl'))`,
+		db.Escape(source), n, n, n)
+	if err := db.Exec(dbPath, del); err != nil {
+		return fmt.Errorf("clear embeddings: %w", err)
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	chunks := ChunkText(content, 2000, 200)
+	vecs, err := GetEmbeddings(ctx, cfg, chunks)
+	if err != nil {
+		return err
+	}
+	for i, vec := range vecs {
+		sourceID := name
+		if len(chunks) > 1 {
+			sourceID = fmt.Sprintf("%s#chunk%d", name, i)
+		}
+		if err := StoreEmbedding(dbPath, source, sourceID, chunks[i], vec, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ChunkText(text string, maxChars, overlap int) []string {
 	if len(text) <= maxChars {
 		return []string{text}
@@ -4,6 +4,7 @@ package knowledge
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -109,18 +110,27 @@ func RemoveFile(knowledgeDir, name string) error {
 	return os.Remove(path)
 }
 
-// HasFiles returns true if the knowledge directory contains at least one non-hidden file.
+// HasFiles returns true if the knowledge directory or one of its
+// subdirectories contains at least one non-hidden file.
 func HasFiles(dir string) bool {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return false
-	}
-	for _, e := range entries {
-		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
-			return true
+	found := false
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
 		}
-	}
-	return false
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
 }
 
 // ValidateFilename checks that a filename is safe (no path traversal, no hidden files).
@@ -1,6 +1,7 @@
 package knowledge
 
 import (
+	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
@@ -10,14 +11,50 @@ import (
 	"unicode"
 )
 
-// SearchResult represents a matched knowledge chunk.
+// SearchResult represents a matched knowledge chunk. Filename is the path
+// relative to the knowledge directory, with forward slashes; Heading is the
+// nearest markdown heading above the match.
 type SearchResult struct {
 	Filename  string  `json:"filename"`
+	Heading   string  `json:"heading,omitempty"`
 	Snippet   string  `json:"snippet"`
 	Score     float64 `json:"score"`
 	LineStart int     `json:"lineStart"`
 }
 
+// Scope limits a search to files under some subdirectories or carrying some
+// frontmatter tags; a file matching either is in scope. The zero Scope
+// covers the whole index.
+type Scope struct {
+	Dirs []string
+	Tags []string
+}
+
+// IsZero reports whether the scope covers everything.
+func (s Scope) IsZero() bool {
+	return len(s.Dirs) == 0 && len(s.Tags) == 0
+}
+
+func (s Scope) allows(d *docEntry) bool {
+	if s.IsZero() {
+		return true
+	}
+	for _, dir := range s.Dirs {
+		dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+		if dir == "" || dir == "." || strings.HasPrefix(d.filename, dir+"/") {
+			return true
+		}
+	}
+	for _, t := range s.Tags {
+		for _, dt := range d.tags {
+			if strings.EqualFold(strings.TrimPrefix(t, "#"), dt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Index is an in-memory TF-IDF index.
 type Index struct {
 	mu        sync.RWMutex
@@ -28,12 +65,14 @@ type Index struct {
 
 type docEntry struct {
 	filename string
+	tags     []string
 	lines    []string
 	tf       map[string]float64
 	size     int64
 }
 
-// BuildIndex scans all files in dir and builds a TF-IDF index.
+// BuildIndex scans all files in dir and its subdirectories and builds a
+// TF-IDF index. Hidden files and directories are skipped.
 func BuildIndex(dir string) (*Index, error) {
 	idx := &Index{
 		docs: make(map[string]*docEntry),
@@ -72,12 +111,29 @@ func (idx *Index) IDFLen() int {
 	return len(idx.idf)
 }
 
+// UpdateFile re-reads one file of dir into the index, or drops it when it
+// no longer exists, without rescanning the directory.
+func (idx *Index) UpdateFile(dir, name string) error {
+	doc, err := loadDoc(dir, name)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	switch {
+	case os.IsNotExist(err):
+		delete(idx.docs, name)
+	case err != nil:
+		return err
+	default:
+		idx.docs[name] = doc
+	}
+	idx.recomputeIDF()
+	return nil
+}
+
 func (idx *Index) rebuild(dir string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
+	if _, err := os.Stat(dir); err != nil {
 		if os.IsNotExist(err) {
 			idx.docs = make(map[string]*docEntry)
 			idx.idf = make(map[string]float64)
@@ -88,70 +144,103 @@ func (idx *Index) rebuild(dir string) error {
 	}
 
 	docs := make(map[string]*docEntry)
-	df := make(map[string]int)
-
-	for _, e := range entries {
-		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
-			continue
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
 		}
-
-		info, err := e.Info()
-		if err != nil {
-			continue
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
 		}
-
-		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
 		if err != nil {
-			continue
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if doc, err := loadDoc(dir, name); err == nil {
+			docs[name] = doc
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		content := string(data)
-		lines := strings.Split(content, "\n")
-		tokens := Tokenize(content)
+	idx.docs = docs
+	idx.recomputeIDF()
+	return nil
+}
 
-		termCounts := make(map[string]int)
-		for _, tok := range tokens {
-			termCounts[tok]++
-		}
-		total := len(tokens)
-		tf := make(map[string]float64)
-		if total > 0 {
-			for term, count := range termCounts {
-				tf[term] = float64(count) / float64(total)
-			}
-		}
+// loadDoc reads and tokenizes one file; name is slash-separated and
+// relative to dir.
+func loadDoc(dir, name string) (*docEntry, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 
-		seen := make(map[string]bool)
-		for _, tok := range tokens {
-			if !seen[tok] {
-				df[tok]++
-				seen[tok] = true
-			}
+	content := string(data)
+	tokens := Tokenize(content)
+	termCounts := make(map[string]int)
+	for _, tok := range tokens {
+		termCounts[tok]++
+	}
+	total := len(tokens)
+	tf := make(map[string]float64)
+	if total > 0 {
+		for term, count := range termCounts {
+			tf[term] = float64(count) / float64(total)
 		}
+	}
+	lines := strings.Split(content, "\n")
+	return &docEntry{
+		filename: name,
+		tags:     frontmatterTags(lines),
+		lines:    lines,
+		tf:       tf,
+		size:     info.Size(),
+	}, nil
+}
 
-		docs[e.Name()] = &docEntry{
-			filename: e.Name(),
-			lines:    lines,
-			tf:       tf,
-			size:     info.Size(),
+// recomputeIDF derives document frequencies from the docs' term sets.
+// Caller must hold idx.mu.
+func (idx *Index) recomputeIDF() {
+	df := make(map[string]int)
+	for _, d := range idx.docs {
+		for term := range d.tf {
+			df[term]++
 		}
 	}
-
-	totalDocs := len(docs)
-
+	totalDocs := len(idx.docs)
 	idf := make(map[string]float64)
 	for term, docCount := range df {
 		idf[term] = math.Log(1.0 + float64(totalDocs)/float64(1+docCount))
 	}
-
-	idx.docs = docs
 	idx.idf = idf
 	idx.totalDocs = totalDocs
-	return nil
 }
 
 // Search returns documents ranked by TF-IDF score for the given query.
 func (idx *Index) Search(query string, maxResults int) []SearchResult {
+	return idx.SearchScope(query, maxResults, Scope{})
+}
+
+// SearchScope is Search limited to the documents in scope. Scores keep the
+// whole-index IDF, so they compare across scopes.
+func (idx *Index) SearchScope(query string, maxResults int, scope Scope) []SearchResult {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
@@ -169,6 +258,9 @@ func (idx *Index) Search(query string, maxResults int) []SearchResult {
 	var results []scored
 
 	for _, doc := range idx.docs {
+		if !scope.allows(doc) {
+			continue
+		}
 		var score float64
 		for _, qt := range queryTokens {
 			tf, ok := doc.tf[qt]
@@ -205,6 +297,7 @@ func (idx *Index) Search(query string, maxResults int) []SearchResult {
 		snippet := BuildSnippet(doc.lines, r.matchLine, 1)
 		out = append(out, SearchResult{
 			Filename:  r.filename,
+			Heading:   headingAt(doc.lines, r.matchLine),
 			Snippet:   snippet,
 			Score:     r.score,
 			LineStart: r.matchLine + 1,
@@ -311,3 +404,75 @@ func BuildSnippet(lines []string, matchLine, contextLines int) string {
 	}
 	return snippet
 }
+
+// frontmatterTags returns the tags of a YAML frontmatter block, written
+// either inline ("tags: [a, b]" or "tags: a, b") or as a "- a" list.
+func frontmatterTags(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil
+	}
+	var tags []string
+	inList := false
+	for _, line := range lines[1:] {
+		t := strings.TrimSpace(line)
+		if t == "---" || t == "..." {
+			break
+		}
+		if inList {
+			if item, ok := strings.CutPrefix(t, "- "); ok {
+				tags = append(tags, cleanTag(item))
+				continue
+			}
+			inList = false
+		}
+		v, ok := strings.CutPrefix(t, "tags:")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if v == "" {
+			inList = true
+			continue
+		}
+		for _, item := range strings.Split(strings.Trim(v, "[]"), ",") {
+			if item = cleanTag(item); item != "" {
+				tags = append(tags, item)
+			}
+		}
+	}
+	return tags
+}
+
+func cleanTag(s string) string {
+	return strings.TrimPrefix(strings.Trim(strings.TrimSpace(s), `"'`), "#")
+}
+
+// headingAt returns the text of the nearest markdown heading at or above
+// line, ignoring fenced code blocks.
+func headingAt(lines []string, line int) string {
+	heading := ""
+	fenced := false
+	for i := 0; i <= line && i < len(lines); i++ {
+		t := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if h, ok := headingText(t); ok && !fenced {
+			heading = h
+		}
+	}
+	return heading
+}
+
+// headingText returns the text of an ATX heading line.
+func headingText(line string) (string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimRight(line[level:], "# ")), true
+}
@@ -510,3 +510,81 @@ func TestFindBestMatchLine(t *testing.T) {
 		t.Errorf("findBestMatchLine = %d, want 2", best)
 	}
 }
+
+func TestIndexUpdateFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go-guide.md"), []byte("Go is a fast programming language."), 0o644)
+	os.WriteFile(filepath.Join(dir, "python.md"), []byte("Python is a versatile language."), 0o644)
+	idx, err := BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "rust.md"), []byte("Rust has a borrow checker."), 0o644)
+	if err := idx.UpdateFile(dir, "rust.md"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "python.md"), []byte("Python notebooks for data science."), 0o644)
+	idx.UpdateFile(dir, "python.md")
+	os.Remove(filepath.Join(dir, "go-guide.md"))
+	idx.UpdateFile(dir, "go-guide.md")
+
+	rebuilt, _ := BuildIndex(dir)
+	if idx.TotalDocs() != 2 || idx.HasDoc("go-guide.md") || !idx.HasDoc("rust.md") {
+		t.Errorf("docs after updates: total %d", idx.TotalDocs())
+	}
+	if len(idx.idf) != len(rebuilt.idf) || idx.idf["notebooks"] != rebuilt.idf["notebooks"] {
+		t.Errorf("incremental IDF differs from a rebuild")
+	}
+	if r := idx.Search("borrow", 5); len(r) != 1 || r[0].Filename != "rust.md" {
+		t.Errorf("search = %+v", r)
+	}
+	if r := idx.Search("versatile", 5); len(r) != 0 {
+		t.Errorf("stale content still matches: %+v", r)
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "hr", "2026"), 0o755)
+	os.MkdirAll(filepath.Join(dir, ".trash"), 0o755)
+	os.WriteFile(filepath.Join(dir, "hr", "2026", "leave.md"), []byte("# Leave\n## Carry-over\nUnused vacation days carry over.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "onboarding.md"), []byte("---\ntitle: Onboarding\ntags:\n  - people\n  - '#setup'\n---\n# First week\nAsk about vacation days.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "eng.md"), []byte("---\ntags: [eng, ops]\n---\nVacation days freeze deploys.\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".trash", "old.md"), []byte("vacation days"), 0o644)
+
+	idx, err := BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.TotalDocs() != 3 || !idx.HasDoc("hr/2026/leave.md") {
+		t.Fatalf("indexed %d docs", idx.TotalDocs())
+	}
+
+	names := func(rs []SearchResult) map[string]string {
+		m := map[string]string{}
+		for _, r := range rs {
+			m[r.Filename] = r.Heading
+		}
+		return m
+	}
+	all := names(idx.Search("vacation days", 10))
+	if len(all) != 3 || all["hr/2026/leave.md"] != "Carry-over" || all["onboarding.md"] != "First week" || all["eng.md"] != "" {
+		t.Errorf("Search = %v", all)
+	}
+	scoped := names(idx.SearchScope("vacation days", 10, Scope{Dirs: []string{"hr/"}, Tags: []string{"setup"}}))
+	if _, ok := scoped["eng.md"]; len(scoped) != 2 || ok {
+		t.Errorf("SearchScope = %v", scoped)
+	}
+	if got := names(idx.SearchScope("vacation", 10, Scope{Tags: []string{"OPS"}})); len(got) != 1 {
+		t.Errorf("tag scope = %v", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "hr", "2026", "leave.md"), []byte("Parental leave only.\n"), 0o644)
+	if err := idx.UpdateFile(dir, "hr/2026/leave.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := names(idx.Search("vacation", 10))["hr/2026/leave.md"]; ok {
+		t.Error("nested file not updated")
+	}
+}
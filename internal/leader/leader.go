@@ -0,0 +1,292 @@
+// Package leader elects the active daemon of an active/standby pair.
+//
+// Both daemons compete for one lease held in a Store: a file on storage both
+// can reach, or a row in the shared history DB. The holder is active and
+// renews the lease every heartbeat; the other daemon is a standby that keeps
+// trying to claim it. A lease not renewed for StaleAfter is taken over with
+// the next term, and the standby is promoted: the functions registered with
+// WhenActive (cron, queue drainers, bots) run at that point. An active daemon
+// that finds its lease taken, or cannot renew it for StaleAfter, steps down
+// and must stop doing work (see Lost).
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"tetora/internal/log"
+)
+
+// Lease is the record of the active daemon.
+type Lease struct {
+	Node       string    `json:"node"`
+	Instance   string    `json:"instance"` // unique per process, so a restarted node does not mistake its old lease for its own
+	PID        int       `json:"pid,omitempty"`
+	Hostname   string    `json:"hostname,omitempty"`
+	ListenAddr string    `json:"listenAddr,omitempty"`
+	Term       int64     `json:"term"`
+	RenewedAt  time.Time `json:"renewedAt"`
+}
+
+// Store holds the lease. Claim and Release must be atomic with respect to
+// other daemons using the same store.
+type Store interface {
+	// Claim renews the lease when me holds it, or takes it with the next
+	// term when it is free or was last renewed before staleBefore. It
+	// returns the lease in effect afterwards.
+	Claim(me Lease, staleBefore time.Time) (Lease, error)
+	// Release frees the lease if me still holds it.
+	Release(me Lease) error
+}
+
+// claim decides the outcome of me claiming cur; write reports whether the
+// store must record next.
+func claim(cur, me Lease, staleBefore time.Time) (next Lease, write bool) {
+	switch {
+	case cur.Instance != "" && cur.Instance == me.Instance:
+		me.Term = cur.Term
+	case cur.Instance == "" || cur.RenewedAt.Before(staleBefore):
+		me.Term = cur.Term + 1
+	default:
+		return cur, false
+	}
+	return me, true
+}
+
+// Config configures an Elector.
+type Config struct {
+	Node       string
+	ListenAddr string
+	Heartbeat  time.Duration
+	StaleAfter time.Duration
+}
+
+// Elector campaigns for the lease. A nil *Elector is always active, so
+// callers need not check whether HA is enabled.
+type Elector struct {
+	cfg   Config
+	store Store
+	self  Lease
+
+	mu        sync.Mutex
+	active    bool
+	current   Lease // last lease seen in the store
+	renewedAt time.Time
+	pending   []func()
+	replica   bool
+	lost      chan struct{}
+	stopped   bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// New returns an elector for cfg.Node. Call Campaign to start it.
+func New(cfg Config, store Store) *Elector {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Elector{
+		cfg:   cfg,
+		store: store,
+		self: Lease{
+			Node:       cfg.Node,
+			Instance:   cfg.Node + "/" + hex.EncodeToString(b),
+			PID:        os.Getpid(),
+			Hostname:   host,
+			ListenAddr: cfg.ListenAddr,
+		},
+		lost: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// NewReplica returns an elector for a read-only replica of primary: it never
+// campaigns and never becomes active, so functions registered with WhenActive
+// do not run, and the primary is reported as the leader.
+func NewReplica(node, primary string) *Elector {
+	return &Elector{
+		self:    Lease{Node: node},
+		current: Lease{Node: "primary", ListenAddr: primary},
+		replica: true,
+		lost:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Campaign claims the lease once, so the caller knows the starting role,
+// then keeps renewing or claiming it every heartbeat until ctx is done or
+// Resign is called. It reports whether this daemon starts active.
+func (e *Elector) Campaign(ctx context.Context) bool {
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.tick(time.Now())
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.cfg.Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if !e.tick(now) {
+					return
+				}
+			}
+		}
+	}()
+	return e.IsActive()
+}
+
+// tick claims the lease once. It returns false once this daemon has stepped
+// down, after which it no longer campaigns.
+func (e *Elector) tick(now time.Time) bool {
+	me := e.self
+	me.RenewedAt = now
+	cur, err := e.store.Claim(me, now.Add(-e.cfg.StaleAfter))
+
+	e.mu.Lock()
+	wasActive := e.active
+	switch {
+	case err != nil:
+		e.mu.Unlock()
+		log.Warn("leader: claim lease failed", "node", me.Node, "error", err)
+		if wasActive && now.Sub(e.lastRenewed()) > e.cfg.StaleAfter {
+			e.stepDown("lease not renewed for " + e.cfg.StaleAfter.String())
+			return false
+		}
+		return true
+	case cur.Instance == me.Instance:
+		e.current, e.renewedAt, e.active = cur, now, true
+	default:
+		e.current = cur
+	}
+	promoted := e.active && !wasActive
+	pending := e.pending
+	if promoted {
+		e.pending = nil
+	}
+	e.mu.Unlock()
+
+	switch {
+	case promoted:
+		log.Info("leader: this node is active", "node", me.Node, "term", cur.Term)
+		for _, fn := range pending {
+			fn()
+		}
+	case wasActive && cur.Instance != me.Instance:
+		e.stepDown("lease taken by " + cur.Node)
+		return false
+	}
+	return true
+}
+
+func (e *Elector) lastRenewed() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.renewedAt
+}
+
+func (e *Elector) stepDown(reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.active {
+		return
+	}
+	e.active = false
+	close(e.lost)
+	log.Error("leader: lost the lease, stepping down", "node", e.self.Node, "reason", reason)
+}
+
+// IsActive reports whether this daemon holds the lease.
+func (e *Elector) IsActive() bool {
+	if e == nil {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.active
+}
+
+// WhenActive runs fn now if this daemon is active, otherwise when it is
+// promoted. Functions registered before promotion run in order.
+func (e *Elector) WhenActive(fn func()) {
+	if e == nil {
+		fn()
+		return
+	}
+	e.mu.Lock()
+	if !e.active {
+		e.pending = append(e.pending, fn)
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+	fn()
+}
+
+// Lost is closed when an active daemon steps down. It is nil (never ready)
+// for a nil elector.
+func (e *Elector) Lost() <-chan struct{} {
+	if e == nil {
+		return nil
+	}
+	return e.lost
+}
+
+// Resign stops campaigning and releases the lease if held, so the standby
+// is promoted without waiting for the lease to go stale.
+func (e *Elector) Resign() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	e.stopped = true
+	e.mu.Unlock()
+	if e.cancel != nil {
+		e.cancel()
+		<-e.done
+	}
+	e.mu.Lock()
+	active := e.active
+	e.active = false
+	e.mu.Unlock()
+	if active {
+		if err := e.store.Release(e.self); err != nil {
+			log.Warn("leader: release lease failed", "error", err)
+		}
+	}
+}
+
+// Status describes this daemon's role and the current lease holder.
+type Status struct {
+	Node       string `json:"node"`
+	Role       string `json:"role"` // "active", "standby" or "replica"
+	Leader     string `json:"leader,omitempty"`
+	LeaderAddr string `json:"leaderAddr,omitempty"`
+	Term       int64  `json:"term"`
+	RenewedAt  string `json:"renewedAt,omitempty"`
+}
+
+// Status returns this daemon's role and the lease it last saw.
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := Status{Node: e.self.Node, Role: "standby", Leader: e.current.Node, LeaderAddr: e.current.ListenAddr, Term: e.current.Term}
+	if e.active {
+		st.Role = "active"
+	} else if e.replica {
+		st.Role = "replica"
+	}
+	if !e.current.RenewedAt.IsZero() {
+		st.RenewedAt = e.current.RenewedAt.UTC().Format(time.RFC3339)
+	}
+	return st
+}
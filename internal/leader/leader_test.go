@@ -0,0 +1,97 @@
+package leader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestElector(node string, store Store) *Elector {
+	return New(Config{Node: node, Heartbeat: time.Second, StaleAfter: 30 * time.Second}, store)
+}
+
+func testFailover(t *testing.T, store Store) {
+	t.Helper()
+	a, b := newTestElector("a", store), newTestElector("b", store)
+	now := time.Now()
+
+	a.tick(now)
+	b.tick(now)
+	if !a.IsActive() || b.IsActive() {
+		t.Fatalf("a active = %v, b active = %v", a.IsActive(), b.IsActive())
+	}
+	if st := b.Status(); st.Role != "standby" || st.Leader != "a" || st.Term != 1 {
+		t.Errorf("b status = %+v", st)
+	}
+
+	var started []string
+	b.WhenActive(func() { started = append(started, "cron") })
+	b.WhenActive(func() { started = append(started, "bots") })
+
+	// a keeps renewing: b stays standby.
+	a.tick(now.Add(20 * time.Second))
+	b.tick(now.Add(40 * time.Second))
+	if b.IsActive() || len(started) != 0 {
+		t.Fatalf("standby promoted while the lease was fresh: %v", started)
+	}
+
+	// a stops renewing: b takes over with the next term.
+	b.tick(now.Add(51 * time.Second))
+	if !b.IsActive() || len(started) != 2 || started[0] != "cron" {
+		t.Fatalf("b active = %v, started %v", b.IsActive(), started)
+	}
+	if st := b.Status(); st.Role != "active" || st.Term != 2 {
+		t.Errorf("b status after promotion = %+v", st)
+	}
+
+	// a comes back, finds its lease taken and steps down.
+	if a.tick(now.Add(52 * time.Second)) {
+		t.Error("a kept campaigning after losing the lease")
+	}
+	select {
+	case <-a.Lost():
+	default:
+		t.Error("a.Lost not closed")
+	}
+
+	// Resigning frees the lease at once.
+	c := newTestElector("c", store)
+	b.Resign()
+	c.tick(now.Add(53 * time.Second))
+	if !c.IsActive() || c.Status().Term != 3 {
+		t.Errorf("c after b resigned: %+v", c.Status())
+	}
+}
+
+func TestElector_FileStore(t *testing.T) {
+	testFailover(t, &FileStore{Path: filepath.Join(t.TempDir(), "leader.json")})
+}
+
+func TestElector_DBStore(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	testFailover(t, &DBStore{DBPath: dbPath})
+}
+
+func TestElector_NilIsActive(t *testing.T) {
+	var e *Elector
+	ran := false
+	e.WhenActive(func() { ran = true })
+	if !e.IsActive() || !ran || e.Lost() != nil {
+		t.Error("nil elector should be active")
+	}
+	e.Resign()
+}
+
+func TestElector_ReplicaNeverActive(t *testing.T) {
+	e := NewReplica("dmz:8991", "https://primary.example.com")
+	ran := false
+	e.WhenActive(func() { ran = true })
+	e.Resign()
+	st := e.Status()
+	if e.IsActive() || ran || st.Role != "replica" || st.LeaderAddr != "https://primary.example.com" {
+		t.Errorf("replica: active=%v ran=%v status=%+v", e.IsActive(), ran, st)
+	}
+}
@@ -0,0 +1,169 @@
+package leader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// --- File store ---
+
+// FileStore keeps the lease in a JSON file. Both daemons must see the same
+// file, e.g. on a shared volume. Updates are serialized by a lock file
+// created exclusively next to it.
+type FileStore struct {
+	Path string
+}
+
+// lockStale is how old a lock file must be before it is treated as left
+// behind by a crashed process. Updates hold it for milliseconds.
+const lockStale = 10 * time.Second
+
+// Claim implements Store.
+func (s *FileStore) Claim(me Lease, staleBefore time.Time) (Lease, error) {
+	var out Lease
+	err := s.update(func(cur Lease) (Lease, bool) {
+		next, write := claim(cur, me, staleBefore)
+		out = next
+		return next, write
+	})
+	return out, err
+}
+
+// Release implements Store.
+func (s *FileStore) Release(me Lease) error {
+	return s.update(func(cur Lease) (Lease, bool) {
+		if cur.Instance != me.Instance {
+			return cur, false
+		}
+		return Lease{Term: cur.Term}, true
+	})
+}
+
+// Read returns the lease in the file, or a zero lease when there is none.
+func (s *FileStore) Read() (Lease, error) {
+	var l Lease
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, fmt.Errorf("parse %s: %w", s.Path, err)
+	}
+	return l, nil
+}
+
+func (s *FileStore) update(fn func(cur Lease) (Lease, bool)) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cur, err := s.Read()
+	if err != nil {
+		return err
+	}
+	next, write := fn(cur)
+	if !write {
+		return nil
+	}
+	data, _ := json.MarshalIndent(next, "", "  ")
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *FileStore) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return nil, err
+	}
+	path := s.Path + ".lock"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if fi, serr := os.Stat(path); serr == nil && time.Since(fi.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lease file %s is locked", s.Path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// --- DB store ---
+
+// DBStore keeps the lease in the leader_lease table of a SQLite database
+// both daemons share. Each claim is a single conditional UPDATE, which
+// SQLite serializes.
+type DBStore struct {
+	DBPath string
+}
+
+// InitDB creates the leader_lease table and its single row.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS leader_lease (
+		name TEXT PRIMARY KEY,
+		node TEXT NOT NULL DEFAULT '',
+		instance TEXT NOT NULL DEFAULT '',
+		pid INTEGER NOT NULL DEFAULT 0,
+		hostname TEXT NOT NULL DEFAULT '',
+		listen_addr TEXT NOT NULL DEFAULT '',
+		term INTEGER NOT NULL DEFAULT 0,
+		renewed_at INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT OR IGNORE INTO leader_lease (name) VALUES ('daemon');`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Claim implements Store. renewed_at is in Unix milliseconds.
+func (s *DBStore) Claim(me Lease, staleBefore time.Time) (Lease, error) {
+	rows, err := db.QueryArgs(s.DBPath, `UPDATE leader_lease SET
+			term = CASE WHEN instance = ? THEN term ELSE term + 1 END,
+			node = ?, instance = ?, pid = ?, hostname = ?, listen_addr = ?, renewed_at = ?
+		WHERE name = 'daemon' AND (instance = ? OR instance = '' OR renewed_at < ?);
+		SELECT * FROM leader_lease WHERE name = 'daemon';`,
+		me.Instance, me.Node, me.Instance, me.PID, me.Hostname, me.ListenAddr, me.RenewedAt.UnixMilli(),
+		me.Instance, staleBefore.UnixMilli())
+	if err != nil {
+		return Lease{}, err
+	}
+	if len(rows) == 0 {
+		return Lease{}, fmt.Errorf("leader_lease has no row; run InitDB")
+	}
+	r := rows[0]
+	return Lease{
+		Node:       db.Str(r["node"]),
+		Instance:   db.Str(r["instance"]),
+		PID:        db.Int(r["pid"]),
+		Hostname:   db.Str(r["hostname"]),
+		ListenAddr: db.Str(r["listen_addr"]),
+		Term:       int64(db.Int(r["term"])),
+		RenewedAt:  time.UnixMilli(int64(db.Int(r["renewed_at"]))),
+	}, nil
+}
+
+// Release implements Store.
+func (s *DBStore) Release(me Lease) error {
+	return db.ExecArgs(s.DBPath, `UPDATE leader_lease SET node = '', instance = '', pid = 0, renewed_at = 0
+		WHERE name = 'daemon' AND instance = ?`, me.Instance)
+}
@@ -0,0 +1,316 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/ics"
+)
+
+// caldav is a calendar collection on a CalDAV server (RFC 4791). Events are
+// identified by the path of their resource. Recurring events are listed
+// once per occurrence, expanded by the server, but cannot be changed
+// through Tetora: rewriting the resource would drop the recurrence.
+type caldav struct {
+	base       *url.URL // the collection, ending in /
+	username   string
+	password   string
+	loc        *time.Location
+	maxResults int
+}
+
+func newCalDAV(acct config.CalendarAccount, loc *time.Location, maxResults int) (*caldav, error) {
+	u, err := url.Parse(acct.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("caldav account %q: url must be the http(s) URL of a calendar", acct.Name)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &caldav{base: u, username: acct.Username, password: acct.Password, loc: loc, maxResults: maxResults}, nil
+}
+
+func (c *caldav) do(ctx context.Context, method, target string, body []byte, header map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return resp, data, fmt.Errorf("caldav %s: HTTP %d: %s", method, resp.StatusCode, truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return resp, data, nil
+}
+
+// resource returns the URL of an event ID, which must be in the collection.
+func (c *caldav) resource(id string) (string, error) {
+	ref, err := url.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid event id %q", id)
+	}
+	u := c.base.ResolveReference(ref)
+	if u.Host != c.base.Host || !strings.HasPrefix(u.Path, c.base.Path) || u.Path == c.base.Path {
+		return "", fmt.Errorf("event id %q is not in this calendar", id)
+	}
+	return u.String(), nil
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				Data string `xml:"calendar-data"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func davTime(s string) (string, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %q (want RFC3339)", s)
+	}
+	return t.UTC().Format("20060102T150405Z"), nil
+}
+
+// ListEvents runs a calendar-query REPORT for the events overlapping the
+// range.
+func (c *caldav) ListEvents(ctx context.Context, timeMin, timeMax string, maxResults int) ([]Event, error) {
+	if maxResults <= 0 {
+		maxResults = c.maxResults
+	}
+	var rng, expand string
+	if timeMin != "" || timeMax != "" {
+		var attrs string
+		if timeMin != "" {
+			t, err := davTime(timeMin)
+			if err != nil {
+				return nil, err
+			}
+			attrs += ` start="` + t + `"`
+		}
+		if timeMax != "" {
+			t, err := davTime(timeMax)
+			if err != nil {
+				return nil, err
+			}
+			attrs += ` end="` + t + `"`
+		}
+		rng = `<C:time-range` + attrs + `/>`
+		if timeMin != "" && timeMax != "" {
+			expand = `<C:expand` + attrs + `/>`
+		}
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:calendar-data>` + expand + `</C:calendar-data></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">` + rng + `</C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`
+	_, data, err := c.do(ctx, "REPORT", c.base.String(), []byte(body), map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	var events []Event
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.Data == "" {
+				continue
+			}
+			parsed, err := ics.Parse([]byte(ps.Prop.Data))
+			if err != nil {
+				continue
+			}
+			for _, e := range parsed {
+				events = append(events, fromICS(r.Href, e, c.loc))
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return startTime(events[i], c.loc).Before(startTime(events[j], c.loc))
+	})
+	if len(events) > maxResults {
+		events = events[:maxResults]
+	}
+	return events, nil
+}
+
+// CreateEvent stores a new event resource named after its UID.
+func (c *caldav) CreateEvent(ctx context.Context, event EventInput) (*Event, error) {
+	e, err := toICS(event, c.loc)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	href := c.base.ResolveReference(&url.URL{Path: url.PathEscape(strings.TrimSuffix(e.UID, "@tetora")) + ".ics"})
+	if err := c.put(ctx, href.String(), e, map[string]string{"If-None-Match": "*"}); err != nil {
+		return nil, fmt.Errorf("create event: %w", err)
+	}
+	ev := fromICS(href.Path, *e, c.loc)
+	return &ev, nil
+}
+
+// UpdateEvent reads the event, changes the set fields and stores it back
+// if nobody changed it in between.
+func (c *caldav) UpdateEvent(ctx context.Context, eventID string, event EventInput) (*Event, error) {
+	target, err := c.resource(eventID)
+	if err != nil {
+		return nil, err
+	}
+	resp, data, err := c.do(ctx, "GET", target, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("update event: %w", err)
+	}
+	if bytes.Contains(data, []byte("\nRRULE")) || bytes.Contains(data, []byte("\nRECURRENCE-ID")) {
+		return nil, fmt.Errorf("update event: recurring events can only be changed in the calendar app")
+	}
+	parsed, err := ics.Parse(data)
+	if err != nil || len(parsed) == 0 {
+		return nil, fmt.Errorf("update event: unreadable event %s", eventID)
+	}
+	e := parsed[0]
+	if err := mergeInput(&e, event, c.loc); err != nil {
+		return nil, err
+	}
+	header := map[string]string{}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		header["If-Match"] = etag
+	}
+	if err := c.put(ctx, target, &e, header); err != nil {
+		return nil, fmt.Errorf("update event: %w", err)
+	}
+	ev := fromICS(eventID, e, c.loc)
+	return &ev, nil
+}
+
+// DeleteEvent removes the event resource.
+func (c *caldav) DeleteEvent(ctx context.Context, eventID string) error {
+	target, err := c.resource(eventID)
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.do(ctx, "DELETE", target, nil, nil); err != nil {
+		return fmt.Errorf("delete event: %w", err)
+	}
+	return nil
+}
+
+func (c *caldav) put(ctx context.Context, target string, e *ics.Event, header map[string]string) error {
+	data, err := e.MarshalObject(time.Now())
+	if err != nil {
+		return err
+	}
+	header["Content-Type"] = "text/calendar; charset=utf-8"
+	_, _, err = c.do(ctx, "PUT", target, data, header)
+	return err
+}
+
+// fromICS converts an iCalendar event. Timed events are shown in loc.
+func fromICS(id string, e ics.Event, loc *time.Location) Event {
+	ev := Event{
+		ID:          id,
+		Summary:     e.Title,
+		Description: e.Description,
+		Location:    e.Location,
+		Status:      "confirmed",
+		HtmlLink:    e.URL,
+		Attendees:   e.Attendees,
+		AllDay:      e.AllDay,
+	}
+	if e.AllDay {
+		ev.Start = e.Start.Format("2006-01-02")
+		ev.End = e.End.Format("2006-01-02")
+	} else {
+		ev.Start = e.Start.In(loc).Format(time.RFC3339)
+		ev.End = e.End.In(loc).Format(time.RFC3339)
+	}
+	return ev
+}
+
+// toICS builds a new iCalendar event from input.
+func toICS(in EventInput, loc *time.Location) (*ics.Event, error) {
+	if in.Start == "" {
+		return nil, fmt.Errorf("event start is required")
+	}
+	e := &ics.Event{}
+	if err := mergeInput(e, in, loc); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// mergeInput sets the fields of e that in sets. A new start without an end
+// keeps the event's length.
+func mergeInput(e *ics.Event, in EventInput, loc *time.Location) error {
+	if in.Summary != "" {
+		e.Title = in.Summary
+	}
+	if in.Description != "" {
+		e.Description = in.Description
+	}
+	if in.Location != "" {
+		e.Location = in.Location
+	}
+	if len(in.Attendees) > 0 {
+		e.Attendees = in.Attendees
+	}
+	if in.Start != "" {
+		length, wasAllDay := e.End.Sub(e.Start), e.AllDay
+		start, date, err := parseInput(in.Start, in.TimeZone, loc)
+		if err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+		e.AllDay = in.AllDay || date
+		if e.AllDay {
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		e.Start = start
+		e.End = time.Time{}
+		if in.End == "" && length > 0 && e.AllDay == wasAllDay {
+			e.End = start.Add(length)
+		}
+	}
+	if in.End != "" {
+		end, _, err := parseInput(in.End, in.TimeZone, loc)
+		if err != nil {
+			return fmt.Errorf("end: %w", err)
+		}
+		if e.AllDay {
+			end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		e.End = end
+	}
+	return nil
+}
@@ -0,0 +1,541 @@
+// Package calendar reads and writes the owner's calendars.
+//
+// Each configured account is a Google Calendar (through OAuth), a CalDAV
+// calendar such as Nextcloud, Fastmail or iCloud, or a read-only ICS feed.
+// Service holds a Client per account: reads merge every account unless one
+// is named, and writes go to the named account or the default one. Agents
+// use it through the calendar_* tools and smart scheduling reads busy time
+// from it.
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/oauthif"
+	"tetora/internal/log"
+)
+
+// ErrUnknownAccount is returned for an account name that is not configured.
+var ErrUnknownAccount = errors.New("unknown calendar account")
+
+// ErrReadOnly is returned for writes to an ICS feed or a readOnly account.
+var ErrReadOnly = errors.New("calendar account is read-only")
+
+// Event represents a parsed calendar event.
+type Event struct {
+	ID          string   `json:"id"`
+	Account     string   `json:"account,omitempty"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description,omitempty"`
+	Location    string   `json:"location,omitempty"`
+	Start       string   `json:"start"` // RFC3339, or YYYY-MM-DD for all-day events
+	End         string   `json:"end"`   // exclusive date for all-day events
+	Status      string   `json:"status"`
+	HtmlLink    string   `json:"htmlLink,omitempty"`
+	Attendees   []string `json:"attendees,omitempty"`
+	AllDay      bool     `json:"allDay,omitempty"`
+}
+
+// EventInput represents input for creating/updating an event.
+type EventInput struct {
+	Summary     string   `json:"summary"`
+	Description string   `json:"description,omitempty"`
+	Location    string   `json:"location,omitempty"`
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	TimeZone    string   `json:"timeZone,omitempty"`
+	Attendees   []string `json:"attendees,omitempty"`
+	AllDay      bool     `json:"allDay,omitempty"`
+}
+
+// Client talks to one calendar. Time bounds are RFC3339; empty is unbounded.
+type Client interface {
+	ListEvents(ctx context.Context, timeMin, timeMax string, maxResults int) ([]Event, error)
+	CreateEvent(ctx context.Context, event EventInput) (*Event, error)
+	UpdateEvent(ctx context.Context, eventID string, event EventInput) (*Event, error)
+	DeleteEvent(ctx context.Context, eventID string) error
+}
+
+// searcher is a Client that searches on the server. Other clients are
+// searched by listing the range and matching the text locally.
+type searcher interface {
+	SearchEvents(ctx context.Context, query, timeMin, timeMax string) ([]Event, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Service holds a client per account.
+type Service struct {
+	cfg   config.CalendarConfig
+	oauth oauthif.Requester
+	loc   *time.Location
+
+	mu      sync.Mutex
+	clients map[string]Client
+}
+
+// New creates a service for cfg's accounts. Google accounts make their
+// requests through oauth. The time zone defaults to the local one.
+func New(cfg config.CalendarConfig, oauth oauthif.Requester) *Service {
+	loc := time.Local
+	if cfg.TimeZone != "" {
+		if l, err := time.LoadLocation(cfg.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	return &Service{cfg: cfg, oauth: oauth, loc: loc, clients: make(map[string]Client)}
+}
+
+// NewClient returns the client for acct's type.
+func NewClient(acct config.CalendarAccount, oauth oauthif.Requester, timeZone string, maxResults int) (Client, error) {
+	if timeZone == "" {
+		timeZone = time.Now().Location().String()
+	}
+	if maxResults <= 0 {
+		maxResults = 10
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		loc = time.Local
+	}
+	switch acct.Type {
+	case "", "google":
+		g := &google{calendarID: acct.CalendarID, service: acct.OAuthService, timeZone: timeZone, maxResults: maxResults, oauth: oauth}
+		if g.calendarID == "" {
+			g.calendarID = "primary"
+		}
+		if g.service == "" {
+			g.service = "google"
+		}
+		return g, nil
+	case "caldav":
+		if acct.URL == "" {
+			return nil, fmt.Errorf("caldav account %q needs url", acct.Name)
+		}
+		return newCalDAV(acct, loc, maxResults)
+	case "ics":
+		if acct.URL == "" {
+			return nil, fmt.Errorf("ics account %q needs url", acct.Name)
+		}
+		return newFeed(acct, loc, maxResults), nil
+	}
+	return nil, fmt.Errorf("calendar account %q: unknown type %q (use \"google\", \"caldav\" or \"ics\")", acct.Name, acct.Type)
+}
+
+// Accounts returns the configured accounts.
+func (s *Service) Accounts() []config.CalendarAccount { return s.cfg.AccountsOrDefault() }
+
+// TimeZone returns the configured timezone string.
+func (s *Service) TimeZone() string { return s.loc.String() }
+
+// Client returns the client of the named account, created on first use.
+func (s *Service) Client(name string) (Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[name]; ok {
+		return c, nil
+	}
+	acct, ok := s.cfg.Account(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAccount, name)
+	}
+	c, err := NewClient(acct, s.oauth, s.TimeZone(), s.cfg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[name] = c
+	return c, nil
+}
+
+// writable returns the client of the account to write to: the named one,
+// or the default account when name is empty.
+func (s *Service) writable(name string) (string, Client, error) {
+	if name == "" {
+		name = s.cfg.Default
+	}
+	if name == "" {
+		for _, a := range s.Accounts() {
+			if a.Writable() {
+				name = a.Name
+				break
+			}
+		}
+		if name == "" {
+			return "", nil, fmt.Errorf("%w: no calendar account can be written to", ErrReadOnly)
+		}
+	}
+	acct, ok := s.cfg.Account(name)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q", ErrUnknownAccount, name)
+	}
+	if !acct.Writable() {
+		return "", nil, fmt.Errorf("%w: %q", ErrReadOnly, name)
+	}
+	c, err := s.Client(name)
+	return name, c, err
+}
+
+// ListEvents lists the events of the named account, or of every account
+// merged by start time when account is empty. An account that fails is
+// skipped unless all of them do.
+func (s *Service) ListEvents(ctx context.Context, account, timeMin, timeMax string, maxResults int) ([]Event, error) {
+	return s.collect(account, maxResults, func(c Client) ([]Event, error) {
+		return c.ListEvents(ctx, timeMin, timeMax, maxResults)
+	})
+}
+
+// SearchEvents returns the events whose title, description or location
+// contain query, in the named account or every account.
+func (s *Service) SearchEvents(ctx context.Context, account, query, timeMin, timeMax string) ([]Event, error) {
+	return s.collect(account, 0, func(c Client) ([]Event, error) {
+		if sc, ok := c.(searcher); ok {
+			return sc.SearchEvents(ctx, query, timeMin, timeMax)
+		}
+		events, err := c.ListEvents(ctx, timeMin, timeMax, 250)
+		if err != nil {
+			return nil, err
+		}
+		q := strings.ToLower(query)
+		var out []Event
+		for _, ev := range events {
+			if strings.Contains(strings.ToLower(ev.Summary+"\n"+ev.Description+"\n"+ev.Location), q) {
+				out = append(out, ev)
+			}
+		}
+		return out, nil
+	})
+}
+
+func (s *Service) collect(account string, maxResults int, fetch func(Client) ([]Event, error)) ([]Event, error) {
+	names := []string{account}
+	if account == "" {
+		names = names[:0]
+		for _, a := range s.Accounts() {
+			names = append(names, a.Name)
+		}
+	}
+	results := make([][]Event, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		c, err := s.Client(name)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fetch(c)
+		}()
+	}
+	wg.Wait()
+
+	out := []Event{}
+	var firstErr error
+	failed := 0
+	for i, name := range names {
+		if errs[i] != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = errs[i]
+				if len(names) > 1 {
+					firstErr = fmt.Errorf("%s: %w", name, errs[i])
+				}
+			}
+			continue
+		}
+		for _, ev := range results[i] {
+			ev.Account = name
+			out = append(out, ev)
+		}
+	}
+	if failed == len(names) {
+		return nil, firstErr
+	}
+	for i, name := range names {
+		if errs[i] != nil {
+			log.Warn("calendar: account skipped", "account", name, "error", errs[i])
+		}
+	}
+	if len(names) > 1 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return startTime(out[i], s.loc).Before(startTime(out[j], s.loc))
+		})
+	}
+	if maxResults > 0 && len(out) > maxResults {
+		out = out[:maxResults]
+	}
+	return out, nil
+}
+
+// CreateEvent creates an event in the named account or the default one.
+func (s *Service) CreateEvent(ctx context.Context, account string, event EventInput) (*Event, error) {
+	name, c, err := s.writable(account)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := c.CreateEvent(ctx, event)
+	if ev != nil {
+		ev.Account = name
+	}
+	return ev, err
+}
+
+// UpdateEvent changes the set fields of an event.
+func (s *Service) UpdateEvent(ctx context.Context, account, eventID string, event EventInput) (*Event, error) {
+	name, c, err := s.writable(account)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := c.UpdateEvent(ctx, eventID, event)
+	if ev != nil {
+		ev.Account = name
+	}
+	return ev, err
+}
+
+// DeleteEvent deletes an event.
+func (s *Service) DeleteEvent(ctx context.Context, account, eventID string) error {
+	_, c, err := s.writable(account)
+	if err != nil {
+		return err
+	}
+	return c.DeleteEvent(ctx, eventID)
+}
+
+// startTime returns when an event starts; dates are midnight in loc.
+func startTime(ev Event, loc *time.Location) time.Time {
+	t, _, err := parseInput(ev.Start, "", loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseInput reads a time given as RFC3339, a local date-time in zone (or
+// loc), or a date, which is an all-day value.
+func parseInput(s, zone string, loc *time.Location) (t time.Time, date bool, err error) {
+	if s == "" {
+		return time.Time{}, false, fmt.Errorf("time is empty")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if zone != "" {
+		if l, err := time.LoadLocation(zone); err == nil {
+			loc = l
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time %q (want RFC3339 or YYYY-MM-DD)", s)
+}
+
+// --- Natural Language Schedule Parser ---
+
+// ParseNaturalSchedule parses natural language scheduling input into an EventInput.
+func ParseNaturalSchedule(text string) (*EventInput, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty schedule input")
+	}
+
+	now := time.Now()
+	loc := now.Location()
+
+	if ev, ok := parseJaSchedule(text, now, loc); ok {
+		return ev, nil
+	}
+	if ev, ok := parseZhSchedule(text, now, loc); ok {
+		return ev, nil
+	}
+	if ev, ok := parseEnSchedule(text, now, loc); ok {
+		return ev, nil
+	}
+
+	return nil, fmt.Errorf("cannot parse schedule: %q", text)
+}
+
+func parseJaSchedule(text string, now time.Time, loc *time.Location) (*EventInput, bool) {
+	var baseDate time.Time
+	rest := text
+
+	if strings.HasPrefix(text, "明日") {
+		baseDate = now.AddDate(0, 0, 1)
+		rest = strings.TrimPrefix(text, "明日")
+	} else if strings.HasPrefix(text, "今日") {
+		baseDate = now
+		rest = strings.TrimPrefix(text, "今日")
+	} else if strings.HasPrefix(text, "明後日") {
+		baseDate = now.AddDate(0, 0, 2)
+		rest = strings.TrimPrefix(text, "明後日")
+	} else {
+		return nil, false
+	}
+
+	reTime := regexp.MustCompile(`^(\d{1,2})時(?:(\d{1,2})分)?`)
+	m := reTime.FindStringSubmatch(rest)
+	h, min := 9, 0
+	if m != nil {
+		h, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			min, _ = strconv.Atoi(m[2])
+		}
+		rest = rest[len(m[0]):]
+	}
+
+	summary := strings.TrimPrefix(rest, "の")
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		summary = "予定"
+	}
+
+	startTime := time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), h, min, 0, 0, loc)
+	endTime := startTime.Add(1 * time.Hour)
+
+	return &EventInput{
+		Summary:  summary,
+		Start:    startTime.Format(time.RFC3339),
+		End:      endTime.Format(time.RFC3339),
+		TimeZone: loc.String(),
+	}, true
+}
+
+func parseZhSchedule(text string, now time.Time, loc *time.Location) (*EventInput, bool) {
+	var baseDate time.Time
+	rest := text
+
+	if strings.HasPrefix(text, "明天") {
+		baseDate = now.AddDate(0, 0, 1)
+		rest = strings.TrimPrefix(text, "明天")
+	} else if strings.HasPrefix(text, "今天") {
+		baseDate = now
+		rest = strings.TrimPrefix(text, "今天")
+	} else if strings.HasPrefix(text, "後天") {
+		baseDate = now.AddDate(0, 0, 2)
+		rest = strings.TrimPrefix(text, "後天")
+	} else {
+		return nil, false
+	}
+
+	h, min := 9, 0
+	offset := 0
+
+	if strings.HasPrefix(rest, "下午") {
+		offset = 12
+		rest = strings.TrimPrefix(rest, "下午")
+	} else if strings.HasPrefix(rest, "上午") {
+		rest = strings.TrimPrefix(rest, "上午")
+	}
+
+	reTime := regexp.MustCompile(`^(\d{1,2})點(?:(\d{1,2})分)?`)
+	m := reTime.FindStringSubmatch(rest)
+	if m != nil {
+		h, _ = strconv.Atoi(m[1])
+		h += offset
+		if h == 24 {
+			h = 12
+		}
+		if m[2] != "" {
+			min, _ = strconv.Atoi(m[2])
+		}
+		rest = rest[len(m[0]):]
+	}
+
+	summary := strings.TrimPrefix(rest, "的")
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		summary = "活動"
+	}
+
+	startTime := time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), h, min, 0, 0, loc)
+	endTime := startTime.Add(1 * time.Hour)
+
+	return &EventInput{
+		Summary:  summary,
+		Start:    startTime.Format(time.RFC3339),
+		End:      endTime.Format(time.RFC3339),
+		TimeZone: loc.String(),
+	}, true
+}
+
+func parseEnSchedule(text string, now time.Time, loc *time.Location) (*EventInput, bool) {
+	lower := strings.ToLower(text)
+
+	var baseDate time.Time
+	dateFound := false
+
+	if strings.Contains(lower, "tomorrow") {
+		baseDate = now.AddDate(0, 0, 1)
+		dateFound = true
+	} else if strings.Contains(lower, "today") {
+		baseDate = now
+		dateFound = true
+	}
+
+	if !dateFound {
+		return nil, false
+	}
+
+	h, min := 9, 0
+	reAt := regexp.MustCompile(`at\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)?`)
+	if m := reAt.FindStringSubmatch(lower); m != nil {
+		h, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			min, _ = strconv.Atoi(m[2])
+		}
+		if m[3] == "pm" && h != 12 {
+			h += 12
+		} else if m[3] == "am" && h == 12 {
+			h = 0
+		}
+	}
+
+	summary := text
+	for _, w := range []string{"tomorrow", "today", "Tomorrow", "Today"} {
+		summary = strings.ReplaceAll(summary, w, "")
+	}
+	reAtFull := regexp.MustCompile(`(?i)at\s+\d{1,2}(?::\d{2})?\s*(?:am|pm)?`)
+	summary = reAtFull.ReplaceAllString(summary, "")
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		summary = "Event"
+	}
+
+	startTime := time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), h, min, 0, 0, loc)
+	endTime := startTime.Add(1 * time.Hour)
+
+	return &EventInput{
+		Summary:  summary,
+		Start:    startTime.Format(time.RFC3339),
+		End:      endTime.Format(time.RFC3339),
+		TimeZone: loc.String(),
+	}, true
+}
+
+// --- Helpers ---
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,182 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+// fakeDAV is a calendar collection at /cal/ that answers REPORT with every
+// stored resource.
+type fakeDAV struct {
+	mu        sync.Mutex
+	resources map[string]string // path → iCalendar
+	reports   []string
+}
+
+func (d *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, _ := r.BasicAuth(); user != "me" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	switch r.Method {
+	case "REPORT":
+		d.reports = append(d.reports, string(body))
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for p, data := range d.resources {
+			b.WriteString(`<d:response><d:href>` + p + `</d:href><d:propstat><d:prop><cal:calendar-data>` +
+				strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace(data) +
+				`</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+		}
+		b.WriteString(`</d:multistatus>`)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(b.String()))
+	case "PUT":
+		_, exists := d.resources[r.URL.Path]
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if strings.Contains(string(body), "METHOD:") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		d.resources[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	case "GET":
+		data, ok := d.resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Write([]byte(data))
+	case "DELETE":
+		delete(d.resources, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+const standup = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:standup\r\n" +
+	"DTSTART:20261020T010000Z\r\nDTEND:20261020T011500Z\r\nSUMMARY:Standup\r\nRRULE:FREQ=DAILY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestCalDAV(t *testing.T) {
+	dav := &fakeDAV{resources: map[string]string{"/cal/standup.ics": standup}}
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+
+	s := New(config.CalendarConfig{
+		TimeZone: "Asia/Tokyo",
+		Accounts: []config.CalendarAccount{
+			{Name: "work", Type: "caldav", URL: srv.URL + "/cal", Username: "me", Password: "secret"},
+		},
+	}, nil)
+	ctx := context.Background()
+
+	events, err := s.ListEvents(ctx, "", "2026-10-20T00:00:00+09:00", "2026-10-21T00:00:00+09:00", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Summary != "Standup" || events[0].Start != "2026-10-20T10:00:00+09:00" || events[0].Account != "work" || events[0].ID != "/cal/standup.ics" {
+		t.Fatalf("events = %+v", events)
+	}
+	if r := dav.reports[0]; !strings.Contains(r, `<C:time-range start="20261019T150000Z" end="20261020T150000Z"/>`) || !strings.Contains(r, "<C:expand") {
+		t.Errorf("report = %s", r)
+	}
+	if _, err := s.UpdateEvent(ctx, "work", "/cal/standup.ics", EventInput{Summary: "x"}); err == nil {
+		t.Error("recurring event updated")
+	}
+
+	created, err := s.CreateEvent(ctx, "", EventInput{Summary: "Review", Start: "2026-10-21T14:00", Attendees: []string{"ann@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Account != "work" || created.Start != "2026-10-21T14:00:00+09:00" || created.End != "2026-10-21T15:00:00+09:00" {
+		t.Fatalf("created = %+v", created)
+	}
+	moved, err := s.UpdateEvent(ctx, "work", created.ID, EventInput{Start: "2026-10-22T09:30:00+09:00", Location: "Room 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Summary != "Review" || moved.Location != "Room 2" || moved.End != "2026-10-22T10:30:00+09:00" || len(moved.Attendees) != 1 {
+		t.Errorf("moved = %+v", moved)
+	}
+
+	found, err := s.SearchEvents(ctx, "", "review", "", "")
+	if err != nil || len(found) != 1 || found[0].ID != created.ID {
+		t.Errorf("search = %+v, %v", found, err)
+	}
+	if err := s.DeleteEvent(ctx, "work", "https://evil.example/cal/x.ics"); err == nil {
+		t.Error("event outside the calendar accepted")
+	}
+	if err := s.DeleteEvent(ctx, "work", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(dav.resources) != 1 {
+		t.Errorf("resources after delete = %d", len(dav.resources))
+	}
+}
+
+func TestFeedAndMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "team.ics")
+	os.WriteFile(path, []byte("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\nUID:a\r\nDTSTART;VALUE=DATE:20261021\r\nSUMMARY:Offsite\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nUID:b\r\nDTSTART:20261020T060000Z\r\nDTEND:20261020T070000Z\r\nSUMMARY:Lunch\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nUID:c\r\nDTSTART:20261101T060000Z\r\nSUMMARY:Later\r\nEND:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o644)
+	dav := &fakeDAV{resources: map[string]string{"/cal/standup.ics": standup}}
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+
+	s := New(config.CalendarConfig{
+		TimeZone: "Asia/Tokyo",
+		Accounts: []config.CalendarAccount{
+			{Name: "team", Type: "ics", URL: path},
+			{Name: "broken", Type: "ics", URL: filepath.Join(t.TempDir(), "missing.ics")},
+			{Name: "work", Type: "caldav", URL: srv.URL + "/cal/", Username: "me", Password: "secret"},
+		},
+	}, nil)
+	ctx := context.Background()
+
+	events, err := s.ListEvents(ctx, "", "2026-10-20T00:00:00+09:00", "2026-10-23T00:00:00+09:00", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Account+":"+ev.Summary)
+	}
+	if strings.Join(got, ",") != "work:Standup,team:Lunch,team:Offsite" {
+		t.Errorf("merged = %v", got)
+	}
+	if events[2].Start != "2026-10-21" || !events[2].AllDay {
+		t.Errorf("all-day = %+v", events[2])
+	}
+
+	if _, err := s.ListEvents(ctx, "broken", "", "", 10); err == nil {
+		t.Error("missing feed listed")
+	}
+	if _, err := s.CreateEvent(ctx, "team", EventInput{Summary: "x", Start: "2026-10-21"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("write to feed: %v", err)
+	}
+	// The first writable account is the default.
+	if ev, err := s.CreateEvent(ctx, "", EventInput{Summary: "Trip", Start: "2026-10-24", AllDay: true}); err != nil || ev.Account != "work" || ev.End != "2026-10-25" {
+		t.Errorf("default write = %+v, %v", ev, err)
+	}
+	if _, err := s.ListEvents(ctx, "nope", "", "", 10); !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("unknown account: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/ics"
+)
+
+// feed is a published ICS calendar, fetched again once it is older than the
+// refresh interval. A failed fetch keeps the events of the last one.
+// Recurrence rules are not expanded, so a recurring event appears only at
+// its first occurrence.
+type feed struct {
+	source     string // URL or file path
+	refresh    time.Duration
+	loc        *time.Location
+	maxResults int
+
+	mu      sync.Mutex
+	events  []ics.Event
+	fetched time.Time
+}
+
+func newFeed(acct config.CalendarAccount, loc *time.Location, maxResults int) *feed {
+	source := acct.URL
+	if rest, ok := strings.CutPrefix(source, "webcal://"); ok {
+		source = "https://" + rest
+	}
+	return &feed{source: source, refresh: acct.RefreshOrDefault(), loc: loc, maxResults: maxResults}
+}
+
+func (f *feed) load(ctx context.Context) ([]ics.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.fetched.IsZero() && time.Since(f.fetched) < f.refresh {
+		return f.events, nil
+	}
+	data, err := f.fetch(ctx)
+	if err == nil {
+		var events []ics.Event
+		if events, err = ics.Parse(data); err == nil {
+			f.events, f.fetched = events, time.Now()
+			return events, nil
+		}
+	}
+	if f.fetched.IsZero() {
+		return nil, fmt.Errorf("fetch %s: %w", f.source, err)
+	}
+	// Keep serving the last copy; try again after the next interval.
+	f.fetched = time.Now()
+	return f.events, nil
+}
+
+func (f *feed) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(f.source, "http://") && !strings.HasPrefix(f.source, "https://") {
+		return os.ReadFile(f.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", f.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
+
+// ListEvents returns the feed's events overlapping the range.
+func (f *feed) ListEvents(ctx context.Context, timeMin, timeMax string, maxResults int) ([]Event, error) {
+	if maxResults <= 0 {
+		maxResults = f.maxResults
+	}
+	var lo, hi time.Time
+	var err error
+	if timeMin != "" {
+		if lo, _, err = parseInput(timeMin, "", f.loc); err != nil {
+			return nil, err
+		}
+	}
+	if timeMax != "" {
+		if hi, _, err = parseInput(timeMax, "", f.loc); err != nil {
+			return nil, err
+		}
+	}
+	all, err := f.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, e := range all {
+		start, end := e.Start, e.End
+		if e.AllDay {
+			// Dates are days in the calendar's zone.
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, f.loc)
+			end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, f.loc)
+		}
+		if (!lo.IsZero() && !end.After(lo)) || (!hi.IsZero() && !start.Before(hi)) {
+			continue
+		}
+		events = append(events, fromICS(e.UID, e, f.loc))
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return startTime(events[i], f.loc).Before(startTime(events[j], f.loc))
+	})
+	if len(events) > maxResults {
+		events = events[:maxResults]
+	}
+	return events, nil
+}
+
+func (f *feed) CreateEvent(context.Context, EventInput) (*Event, error) { return nil, ErrReadOnly }
+
+func (f *feed) UpdateEvent(context.Context, string, EventInput) (*Event, error) {
+	return nil, ErrReadOnly
+}
+
+func (f *feed) DeleteEvent(context.Context, string) error { return ErrReadOnly }
@@ -0,0 +1,269 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/integration/oauthif"
+)
+
+const BaseURL = "https://www.googleapis.com/calendar/v3/calendars"
+
+// google is a Google Calendar reached through an OAuth service.
+type google struct {
+	calendarID string
+	service    string // OAuth service name
+	timeZone   string
+	maxResults int
+	oauth      oauthif.Requester
+}
+
+func (g *google) do(ctx context.Context, method, reqURL string, body io.Reader) ([]byte, error) {
+	if g.oauth == nil {
+		return nil, fmt.Errorf("OAuth not configured")
+	}
+	resp, err := g.oauth.Request(ctx, g.service, method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return nil, fmt.Errorf("calendar API error %d: %s", resp.StatusCode, truncate(string(data), 500))
+	}
+	return data, nil
+}
+
+func (g *google) events(ctx context.Context, params url.Values) ([]Event, error) {
+	params.Set("singleEvents", "true")
+	params.Set("orderBy", "startTime")
+	reqURL := fmt.Sprintf("%s/%s/events?%s", BaseURL, url.PathEscape(g.calendarID), params.Encode())
+	body, err := g.do(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	items, _ := result["items"].([]any)
+	events := make([]Event, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]any); ok {
+			events = append(events, parseEvent(m))
+		}
+	}
+	return events, nil
+}
+
+// ListEvents lists events from the calendar within a time range.
+func (g *google) ListEvents(ctx context.Context, timeMin, timeMax string, maxResults int) ([]Event, error) {
+	if maxResults <= 0 {
+		maxResults = g.maxResults
+	}
+	params := url.Values{}
+	if timeMin != "" {
+		params.Set("timeMin", timeMin)
+	}
+	if timeMax != "" {
+		params.Set("timeMax", timeMax)
+	}
+	params.Set("maxResults", strconv.Itoa(maxResults))
+	events, err := g.events(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	return events, nil
+}
+
+// SearchEvents searches for events matching a query.
+func (g *google) SearchEvents(ctx context.Context, query, timeMin, timeMax string) ([]Event, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	if timeMin != "" {
+		params.Set("timeMin", timeMin)
+	}
+	if timeMax != "" {
+		params.Set("timeMax", timeMax)
+	}
+	params.Set("maxResults", strconv.Itoa(g.maxResults))
+	events, err := g.events(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("search events: %w", err)
+	}
+	return events, nil
+}
+
+func (g *google) write(ctx context.Context, method, reqURL string, event EventInput) (*Event, error) {
+	bodyBytes, err := json.Marshal(buildBody(event, g.timeZone))
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+	body, err := g.do(ctx, method, reqURL, strings.NewReader(string(bodyBytes)))
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	ev := parseEvent(result)
+	return &ev, nil
+}
+
+// CreateEvent creates a new calendar event.
+func (g *google) CreateEvent(ctx context.Context, event EventInput) (*Event, error) {
+	reqURL := fmt.Sprintf("%s/%s/events", BaseURL, url.PathEscape(g.calendarID))
+	ev, err := g.write(ctx, "POST", reqURL, event)
+	if err != nil {
+		return nil, fmt.Errorf("create event: %w", err)
+	}
+	return ev, nil
+}
+
+// UpdateEvent updates an existing calendar event.
+func (g *google) UpdateEvent(ctx context.Context, eventID string, event EventInput) (*Event, error) {
+	reqURL := fmt.Sprintf("%s/%s/events/%s", BaseURL, url.PathEscape(g.calendarID), url.PathEscape(eventID))
+	ev, err := g.write(ctx, "PATCH", reqURL, event)
+	if err != nil {
+		return nil, fmt.Errorf("update event: %w", err)
+	}
+	return ev, nil
+}
+
+// DeleteEvent deletes a calendar event.
+func (g *google) DeleteEvent(ctx context.Context, eventID string) error {
+	reqURL := fmt.Sprintf("%s/%s/events/%s", BaseURL, url.PathEscape(g.calendarID), url.PathEscape(eventID))
+	if _, err := g.do(ctx, "DELETE", reqURL, nil); err != nil {
+		return fmt.Errorf("delete event: %w", err)
+	}
+	return nil
+}
+
+func parseEvent(item map[string]any) Event {
+	ev := Event{}
+
+	if id, ok := item["id"].(string); ok {
+		ev.ID = id
+	}
+	if summary, ok := item["summary"].(string); ok {
+		ev.Summary = summary
+	}
+	if desc, ok := item["description"].(string); ok {
+		ev.Description = desc
+	}
+	if loc, ok := item["location"].(string); ok {
+		ev.Location = loc
+	}
+	if status, ok := item["status"].(string); ok {
+		ev.Status = status
+	}
+	if link, ok := item["htmlLink"].(string); ok {
+		ev.HtmlLink = link
+	}
+
+	if startObj, ok := item["start"].(map[string]any); ok {
+		if dt, ok := startObj["dateTime"].(string); ok {
+			ev.Start = dt
+		} else if d, ok := startObj["date"].(string); ok {
+			ev.Start = d
+			ev.AllDay = true
+		}
+	}
+
+	if endObj, ok := item["end"].(map[string]any); ok {
+		if dt, ok := endObj["dateTime"].(string); ok {
+			ev.End = dt
+		} else if d, ok := endObj["date"].(string); ok {
+			ev.End = d
+		}
+	}
+
+	if attendees, ok := item["attendees"].([]any); ok {
+		for _, a := range attendees {
+			if aMap, ok := a.(map[string]any); ok {
+				if email, ok := aMap["email"].(string); ok {
+					ev.Attendees = append(ev.Attendees, email)
+				}
+			}
+		}
+	}
+
+	return ev
+}
+
+func buildBody(input EventInput, defaultTZ string) map[string]any {
+	body := map[string]any{}
+
+	if input.Summary != "" {
+		body["summary"] = input.Summary
+	}
+	if input.Description != "" {
+		body["description"] = input.Description
+	}
+	if input.Location != "" {
+		body["location"] = input.Location
+	}
+
+	tz := input.TimeZone
+	if tz == "" {
+		tz = defaultTZ
+	}
+
+	if input.AllDay {
+		startDate := input.Start
+		endDate := input.End
+		if len(startDate) > 10 {
+			startDate = startDate[:10]
+		}
+		if endDate == "" {
+			if t, err := time.Parse("2006-01-02", startDate); err == nil {
+				endDate = t.AddDate(0, 0, 1).Format("2006-01-02")
+			} else {
+				endDate = startDate
+			}
+		}
+		if len(endDate) > 10 {
+			endDate = endDate[:10]
+		}
+		body["start"] = map[string]any{"date": startDate}
+		body["end"] = map[string]any{"date": endDate}
+	} else if input.Start != "" {
+		body["start"] = map[string]any{
+			"dateTime": input.Start,
+			"timeZone": tz,
+		}
+		endTime := input.End
+		if endTime == "" {
+			if t, err := time.Parse(time.RFC3339, input.Start); err == nil {
+				endTime = t.Add(1 * time.Hour).Format(time.RFC3339)
+			} else {
+				endTime = input.Start
+			}
+		}
+		body["end"] = map[string]any{
+			"dateTime": endTime,
+			"timeZone": tz,
+		}
+	}
+
+	if len(input.Attendees) > 0 {
+		attendees := make([]map[string]any, len(input.Attendees))
+		for i, email := range input.Attendees {
+			attendees[i] = map[string]any{"email": email}
+		}
+		body["attendees"] = attendees
+	}
+
+	return body
+}
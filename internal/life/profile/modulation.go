@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Response modulation: the per-user switch and the log of what was applied,
+// so users can see why a reply was shorter or gentler than usual.
+
+// ModulationEntry records the prompt adjustments applied to one task.
+type ModulationEntry struct {
+	ID        int      `json:"id"`
+	UserID    string   `json:"userId"`
+	TaskID    string   `json:"taskId,omitempty"`
+	Agent     string   `json:"agent,omitempty"`
+	Applied   []string `json:"applied"` // e.g. "brevity:short", "empathy:high"
+	Reasons   []string `json:"reasons,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// ModulationOn reports whether response modulation is on for userID. It is
+// on unless the user has turned it off.
+func (svc *Service) ModulationOn(userID string) bool {
+	prefs, err := svc.GetPreferences(userID, "settings")
+	if err != nil {
+		return true
+	}
+	for _, p := range prefs {
+		if p.Key == "modulation" {
+			return p.Value != "off"
+		}
+	}
+	return true
+}
+
+// SetModulation turns response modulation on or off for userID.
+func (svc *Service) SetModulation(userID string, on bool) error {
+	value := "on"
+	if !on {
+		value = "off"
+	}
+	return svc.ObservePreference(userID, "settings", "modulation", value)
+}
+
+// AverageMood returns the mean sentiment score of userID's messages over the
+// last days, and how many scored messages it is based on.
+func (svc *Service) AverageMood(userID string, days int) (float64, int) {
+	mood, err := svc.GetMoodTrend(userID, days)
+	if err != nil || len(mood) == 0 {
+		return 0, 0
+	}
+	var total float64
+	for _, m := range mood {
+		if s, ok := m["sentimentScore"].(float64); ok {
+			total += s
+		}
+	}
+	return total / float64(len(mood)), len(mood)
+}
+
+// RecordModulation logs the adjustments applied to a task.
+func (svc *Service) RecordModulation(e ModulationEntry) error {
+	if len(e.Applied) == 0 {
+		return nil
+	}
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if e.CreatedAt == "" {
+		e.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	sql := fmt.Sprintf(
+		`INSERT INTO user_modulation_log (user_id, task_id, agent, applied, reasons, created_at) VALUES ('%s','%s','%s','%s','%s','%s')`,
+		svc.db.Escape(e.UserID), svc.db.Escape(e.TaskID), svc.db.Escape(e.Agent),
+		svc.db.Escape(strings.Join(e.Applied, ",")), svc.db.Escape(strings.Join(e.Reasons, "; ")),
+		svc.db.Escape(e.CreatedAt))
+	cmd := exec.Command("sqlite3", svc.dbPath, sql)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("record modulation: %w: %s", err, string(out))
+	}
+	return nil
+}
+
+// Modulations returns the most recent modulation entries for userID.
+func (svc *Service) Modulations(userID string, limit int) ([]ModulationEntry, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := svc.db.Query(svc.dbPath, fmt.Sprintf(
+		`SELECT id, user_id, task_id, agent, applied, reasons, created_at FROM user_modulation_log WHERE user_id = '%s' ORDER BY id DESC LIMIT %d`,
+		svc.db.Escape(userID), limit))
+	if err != nil {
+		return nil, fmt.Errorf("get modulations: %w", err)
+	}
+
+	results := []ModulationEntry{}
+	for _, row := range rows {
+		e := ModulationEntry{
+			ID:        jsonInt(row["id"]),
+			UserID:    jsonStr(row["user_id"]),
+			TaskID:    jsonStr(row["task_id"]),
+			Agent:     jsonStr(row["agent"]),
+			Applied:   strings.Split(jsonStr(row["applied"]), ","),
+			CreatedAt: jsonStr(row["created_at"]),
+		}
+		if r := jsonStr(row["reasons"]); r != "" {
+			e.Reasons = strings.Split(r, "; ")
+		}
+		results = append(results, e)
+	}
+	return results, nil
+}
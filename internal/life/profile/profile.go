@@ -131,6 +131,17 @@ CREATE TABLE IF NOT EXISTS user_mood_log (
     created_at TEXT NOT NULL
 );
 CREATE INDEX IF NOT EXISTS idx_mood_user ON user_mood_log(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS user_modulation_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    task_id TEXT DEFAULT '',
+    agent TEXT DEFAULT '',
+    applied TEXT NOT NULL,
+    reasons TEXT DEFAULT '',
+    created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_modulation_user ON user_modulation_log(user_id, created_at);
 `
 	cmd := exec.Command("sqlite3", dbPath, schema)
 	out, err := cmd.CombinedOutput()
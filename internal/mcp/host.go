@@ -8,6 +8,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -55,6 +57,7 @@ type Server struct {
 	Cancel    context.CancelFunc
 	ParentCtx context.Context
 	ToolReg   *tools.Registry
+	ToolCosts map[string]float64 // per-call cost estimates from config
 
 	Pending    map[int]chan *JSONRPCResponse
 	PendingMu  sync.Mutex
@@ -90,17 +93,7 @@ func (h *Host) Start(ctx context.Context) error {
 			continue
 		}
 
-		server := &Server{
-			Name:      name,
-			Command:   serverCfg.Command,
-			Args:      serverCfg.Args,
-			Env:       serverCfg.Env,
-			Status:    "starting",
-			ParentCtx: h.Ctx,
-			ToolReg:   h.ToolReg,
-		}
-		server.Ctx, server.Cancel = context.WithCancel(h.Ctx)
-
+		server := h.newServer(name, serverCfg, h.ToolReg)
 		h.Servers[name] = server
 
 		wg.Add(1)
@@ -122,6 +115,143 @@ func (h *Host) Start(ctx context.Context) error {
 	return nil
 }
 
+// newServer builds a server for serverCfg under the host context. Must be
+// called after Start.
+func (h *Host) newServer(name string, serverCfg config.MCPServerConfig, toolReg *tools.Registry) *Server {
+	server := &Server{
+		Name:      name,
+		Command:   serverCfg.Command,
+		Args:      serverCfg.Args,
+		Env:       serverCfg.Env,
+		Status:    "starting",
+		ParentCtx: h.Ctx,
+		ToolReg:   toolReg,
+		ToolCosts: serverCfg.ToolCosts,
+	}
+	server.Ctx, server.Cancel = context.WithCancel(h.Ctx)
+	return server
+}
+
+// ReloadResult lists what a config reload changed.
+type ReloadResult struct {
+	Started   []string          `json:"started,omitempty"`
+	Stopped   []string          `json:"stopped,omitempty"`
+	Restarted []string          `json:"restarted,omitempty"`
+	Failed    map[string]string `json:"failed,omitempty"` // name -> error
+}
+
+func serverEnabled(c config.MCPServerConfig) bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Reload diffs cfg.MCPServers against the running config: removed or
+// disabled servers are stopped and their tools unregistered, new servers are
+// started, and changed servers are started alongside the old process and
+// swapped in once ready, replacing their tools in one registry update. A
+// changed server that fails to start leaves the old process serving.
+func (h *Host) Reload(cfg *config.Config) ReloadResult {
+	h.Mu.Lock()
+	old := h.Cfg
+	h.Cfg = cfg
+	started := h.Ctx != nil
+	h.Mu.Unlock()
+
+	var res ReloadResult
+	if !started {
+		return res
+	}
+
+	names := make(map[string]bool)
+	for name := range old.MCPServers {
+		names[name] = true
+	}
+	for name := range cfg.MCPServers {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		prev, had := old.MCPServers[name]
+		next, has := cfg.MCPServers[name]
+		wasOn, on := had && serverEnabled(prev), has && serverEnabled(next)
+		if wasOn == on && (!on || reflect.DeepEqual(prev, next)) {
+			continue
+		}
+
+		var fresh *Server
+		if on {
+			// Start without a registry so tools are only published by the swap.
+			fresh = h.newServer(name, next, nil)
+			if err := fresh.Start(fresh.Ctx); err != nil {
+				fresh.Cancel()
+				log.Error("MCP server %s failed to start on reload: %v", name, err)
+				if res.Failed == nil {
+					res.Failed = make(map[string]string)
+				}
+				res.Failed[name] = err.Error()
+				if wasOn {
+					continue
+				}
+				fresh.Status = "error"
+				fresh.LastError = err.Error()
+			}
+		}
+
+		h.Mu.Lock()
+		prevServer := h.Servers[name]
+		if fresh != nil {
+			h.Servers[name] = fresh
+		} else {
+			delete(h.Servers, name)
+		}
+		h.Mu.Unlock()
+
+		var remove []string
+		if prevServer != nil {
+			prevServer.Mu.Lock()
+			for _, t := range prevServer.Tools {
+				remove = append(remove, t.Name)
+			}
+			prevServer.Mu.Unlock()
+		}
+		var add []*tools.ToolDef
+		if fresh != nil && fresh.Status == "running" {
+			fresh.Mu.Lock()
+			for _, t := range fresh.Tools {
+				t := t
+				add = append(add, &t)
+			}
+			fresh.ToolReg = h.ToolReg
+			fresh.Mu.Unlock()
+			go fresh.MonitorHealth()
+		}
+		if h.ToolReg != nil {
+			h.ToolReg.Swap(remove, add)
+		}
+		if prevServer != nil {
+			prevServer.Stop()
+		}
+
+		switch {
+		case !on:
+			log.Info("MCP server %s removed on reload", name)
+			res.Stopped = append(res.Stopped, name)
+		case res.Failed[name] != "":
+		case wasOn:
+			log.Info("MCP server %s restarted on reload with %d tools", name, len(add))
+			res.Restarted = append(res.Restarted, name)
+		default:
+			log.Info("MCP server %s started on reload with %d tools", name, len(add))
+			res.Started = append(res.Started, name)
+		}
+	}
+	return res
+}
+
 // Stop shuts down all MCP servers. Safe to call multiple times.
 func (h *Host) Stop() {
 	h.stopOnce.Do(func() {
@@ -426,9 +556,19 @@ func (s *Server) CallTool(ctx context.Context, name string, args json.RawMessage
 		}
 	}
 
+	tools.ReportCost(ctx, s.Name, s.callCost(name, result.Meta))
 	return output, nil
 }
 
+// callCost is the cost of one call to tool: what the server reported in
+// the result's _meta.costUsd, or else the configured estimate.
+func (s *Server) callCost(tool string, meta *toolsCallMeta) float64 {
+	if meta != nil && meta.CostUSD != nil {
+		return *meta.CostUSD
+	}
+	return config.MCPServerConfig{ToolCosts: s.ToolCosts}.ToolCost(tool)
+}
+
 // SendRequest sends a JSON-RPC request and waits for the demuxed response.
 func (s *Server) SendRequest(ctx context.Context, method string, params interface{}) (*JSONRPCResponse, error) {
 	s.Mu.Lock()
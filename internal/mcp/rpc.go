@@ -59,4 +59,11 @@ type toolsCallResult struct {
 		Type string `json:"type"`
 		Text string `json:"text,omitempty"`
 	} `json:"content"`
+	Meta *toolsCallMeta `json:"_meta,omitempty"`
+}
+
+// toolsCallMeta is the optional metadata a server attaches to a result.
+// costUsd lets servers wrapping paid APIs report what a call cost.
+type toolsCallMeta struct {
+	CostUSD *float64 `json:"costUsd,omitempty"`
 }
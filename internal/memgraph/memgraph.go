@@ -0,0 +1,319 @@
+// Package memgraph builds a graph of what the agents know: memory keys,
+// knowledge base documents and contacts as nodes, and the references between
+// them as edges.
+//
+// An edge is either a link, written as [[name]] or as a Markdown link to a
+// knowledge file, or a mention, where one node's text contains another
+// node's name as a whole word. Nodes nothing points to and that point to
+// nothing are flagged as orphans, the first candidates when pruning.
+package memgraph
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Node types.
+const (
+	TypeMemory    = "memory"
+	TypeKnowledge = "knowledge"
+	TypeContact   = "contact"
+)
+
+// Edge kinds.
+const (
+	KindLink    = "link"
+	KindMention = "mention"
+)
+
+// Shortest names matched as mentions; shorter names ("go", "ai") match too
+// much prose to mean anything. CJK names carry more per character.
+const (
+	minMentionLen    = 4
+	minMentionLenCJK = 2
+)
+
+// Memory is one memory entry.
+type Memory struct {
+	Key          string
+	Value        string
+	Priority     string
+	UpdatedAt    string
+	LastAccessed string
+}
+
+// Doc is one knowledge base document. Text is empty for binary files.
+type Doc struct {
+	Name    string
+	Text    string
+	Size    int64
+	ModTime string
+}
+
+// Contact is one contact.
+type Contact struct {
+	ID       string
+	Name     string
+	Nickname string
+	Notes    string
+}
+
+// Input is everything the graph is built from.
+type Input struct {
+	Memories []Memory
+	Docs     []Doc
+	Contacts []Contact
+}
+
+// Node is one memory key, document or contact.
+type Node struct {
+	ID           string `json:"id"` // "<type>:<name>"
+	Type         string `json:"type"`
+	Label        string `json:"label"`
+	Size         int    `json:"size"` // bytes of text
+	Priority     string `json:"priority,omitempty"`
+	UpdatedAt    string `json:"updatedAt,omitempty"`
+	LastAccessed string `json:"lastAccessed,omitempty"`
+	Degree       int    `json:"degree"`
+	Orphan       bool   `json:"orphan,omitempty"`
+}
+
+// Edge is a reference from Source to Target.
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// Stats summarizes a graph.
+type Stats struct {
+	Nodes   map[string]int `json:"nodes"` // by type
+	Edges   int            `json:"edges"`
+	Orphans int            `json:"orphans"`
+}
+
+// Graph is the node/edge export.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	Stats Stats  `json:"stats"`
+}
+
+// vertex is a node with the text searched for references and the names it
+// is referenced by.
+type vertex struct {
+	node  Node
+	text  string
+	names []string // lowercased; names[0] is the canonical link target
+}
+
+// Build returns the graph of in, keeping only node types in types (all when
+// empty).
+func Build(in Input, types ...string) *Graph {
+	keep := func(t string) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, k := range types {
+			if k == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	var vs []*vertex
+	if keep(TypeMemory) {
+		for _, m := range in.Memories {
+			vs = append(vs, &vertex{
+				node: Node{ID: TypeMemory + ":" + m.Key, Type: TypeMemory, Label: m.Key, Size: len(m.Value),
+					Priority: m.Priority, UpdatedAt: m.UpdatedAt, LastAccessed: m.LastAccessed},
+				text:  m.Value,
+				names: memoryNames(m.Key),
+			})
+		}
+	}
+	if keep(TypeKnowledge) {
+		for _, d := range in.Docs {
+			stem := strings.TrimSuffix(d.Name, path.Ext(d.Name))
+			vs = append(vs, &vertex{
+				node:  Node{ID: TypeKnowledge + ":" + d.Name, Type: TypeKnowledge, Label: d.Name, Size: int(d.Size), UpdatedAt: d.ModTime},
+				text:  d.Text,
+				names: uniqueLower(d.Name, stem),
+			})
+		}
+	}
+	if keep(TypeContact) {
+		for _, c := range in.Contacts {
+			label := c.Name
+			if label == "" {
+				label = c.Nickname
+			}
+			vs = append(vs, &vertex{
+				node:  Node{ID: TypeContact + ":" + c.ID, Type: TypeContact, Label: label, Size: len(c.Notes)},
+				text:  c.Notes,
+				names: uniqueLower(c.Name, c.Nickname),
+			})
+		}
+	}
+
+	// Explicit link targets by name.
+	byName := make(map[string]*vertex)
+	for _, v := range vs {
+		for _, n := range v.names {
+			if _, taken := byName[n]; !taken {
+				byName[n] = v
+			}
+		}
+	}
+
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}, Stats: Stats{Nodes: map[string]int{}}}
+	seen := make(map[[2]string]bool)
+	addEdge := func(from, to *vertex, kind string) {
+		if from == to {
+			return
+		}
+		k := [2]string{from.node.ID, to.node.ID}
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		g.Edges = append(g.Edges, Edge{Source: from.node.ID, Target: to.node.ID, Kind: kind})
+		from.node.Degree++
+		to.node.Degree++
+	}
+
+	for _, from := range vs {
+		if from.text == "" {
+			continue
+		}
+		for _, target := range links(from.text) {
+			if to, ok := byName[strings.ToLower(target)]; ok {
+				addEdge(from, to, KindLink)
+			}
+		}
+		lower := strings.ToLower(from.text)
+		for _, to := range vs {
+			for _, n := range to.names {
+				if mentionable(n) && containsWord(lower, n) {
+					addEdge(from, to, KindMention)
+					break
+				}
+			}
+		}
+	}
+
+	for _, v := range vs {
+		v.node.Orphan = v.node.Degree == 0
+		if v.node.Orphan {
+			g.Stats.Orphans++
+		}
+		g.Stats.Nodes[v.node.Type]++
+		g.Nodes = append(g.Nodes, v.node)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+		return g.Edges[i].Target < g.Edges[j].Target
+	})
+	g.Stats.Edges = len(g.Edges)
+	return g
+}
+
+// memoryNames returns the names a memory key is referenced by: the key and,
+// for keys like "extract:deploy-steps", the part after the prefix with
+// separators read as spaces.
+func memoryNames(key string) []string {
+	names := []string{key}
+	if _, rest, ok := strings.Cut(key, ":"); ok && rest != "" {
+		names = append(names, rest)
+	}
+	for _, n := range names[:len(names):len(names)] {
+		if spaced := strings.NewReplacer("-", " ", "_", " ").Replace(n); spaced != n {
+			names = append(names, spaced)
+		}
+	}
+	return uniqueLower(names...)
+}
+
+func uniqueLower(names ...string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out
+}
+
+var (
+	wikiLinkRe = regexp.MustCompile(`\[\[([^\[\]|#]+)(?:[|#][^\[\]]*)?\]\]`)
+	mdLinkRe   = regexp.MustCompile(`\]\(([^()\s]+)\)`)
+)
+
+// links returns the targets of [[wiki links]] and of relative Markdown
+// links in text, e.g. "notes.md" in [notes](knowledge/notes.md).
+func links(text string) []string {
+	var out []string
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(text, -1) {
+		out = append(out, strings.TrimSpace(m[1]))
+	}
+	for _, m := range mdLinkRe.FindAllStringSubmatch(text, -1) {
+		target := m[1]
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") {
+			continue
+		}
+		target, _, _ = strings.Cut(target, "#")
+		if target = path.Base(target); target != "." && target != "/" {
+			out = append(out, target)
+		}
+	}
+	return out
+}
+
+func mentionable(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	if isCJK(r) {
+		return utf8.RuneCountInString(name) >= minMentionLenCJK
+	}
+	return utf8.RuneCountInString(name) >= minMentionLen
+}
+
+// containsWord reports whether word occurs in text (both lowercased) with
+// no letter or digit directly before or after it. CJK text has no spaces
+// between words, so a CJK edge of word needs no boundary.
+func containsWord(text, word string) bool {
+	first, _ := utf8.DecodeRuneInString(word)
+	last, _ := utf8.DecodeLastRuneInString(word)
+	for off := 0; ; {
+		i := strings.Index(text[off:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := off+i, off+i+len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (isCJK(first) || !isWordRune(before)) && (isCJK(last) || !isWordRune(after)) {
+			return true
+		}
+		off = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
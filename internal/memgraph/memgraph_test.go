@@ -0,0 +1,90 @@
+package memgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	in := Input{
+		Memories: []Memory{
+			{Key: "deploy", Value: "Steps are in [[runbook.md]]. Ask Alice before deploying.", Priority: "P0"},
+			{Key: "extract:staging-db", Value: "The staging db lives on host db2; see [notes](knowledge/infra.md#db)."},
+			{Key: "infra-notes", Value: "Host list. Deployment order follows the deploy memory."},
+			{Key: "old-idea", Value: "Nothing refers to this."},
+		},
+		Docs: []Doc{
+			{Name: "runbook.md", Text: "1. Check the staging db.\n2. Run deploy.", Size: 40},
+			{Name: "infra.md", Text: "Servers: db1, db2. Managed by 小明."},
+			{Name: "logo.png", Size: 2048},
+		},
+		Contacts: []Contact{
+			{ID: "c1", Name: "Alice Chen", Nickname: "Alice", Notes: "Owns releases."},
+			{ID: "c2", Name: "小明", Notes: ""},
+			{ID: "c3", Name: "Al"},
+		},
+	}
+	g := Build(in)
+
+	want := []Edge{
+		{"knowledge:infra.md", "contact:c2", KindMention},
+		{"knowledge:runbook.md", "memory:deploy", KindMention},
+		{"knowledge:runbook.md", "memory:extract:staging-db", KindMention},
+		{"memory:deploy", "contact:c1", KindMention},
+		{"memory:deploy", "knowledge:runbook.md", KindLink},
+		{"memory:extract:staging-db", "knowledge:infra.md", KindLink},
+		{"memory:infra-notes", "memory:deploy", KindMention},
+	}
+	if !reflect.DeepEqual(g.Edges, want) {
+		t.Errorf("edges:\n got %v\nwant %v", g.Edges, want)
+	}
+
+	nodes := make(map[string]Node)
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	if n := nodes["memory:deploy"]; n.Degree != 4 || n.Orphan || n.Priority != "P0" || n.Label != "deploy" {
+		t.Errorf("deploy node = %+v", n)
+	}
+	for _, id := range []string{"memory:old-idea", "knowledge:logo.png", "contact:c3"} {
+		if !nodes[id].Orphan {
+			t.Errorf("%s not an orphan: %+v", id, nodes[id])
+		}
+	}
+	if g.Stats.Orphans != 3 || g.Stats.Edges != 7 || g.Stats.Nodes[TypeMemory] != 4 || g.Stats.Nodes[TypeContact] != 3 {
+		t.Errorf("stats = %+v", g.Stats)
+	}
+
+	// Filtering by type drops the other nodes and their edges.
+	mem := Build(in, TypeMemory)
+	if len(mem.Nodes) != 4 || len(mem.Edges) != 1 || mem.Edges[0].Target != "memory:deploy" {
+		t.Errorf("memory-only graph = %+v", mem)
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		text, word string
+		want       bool
+	}{
+		{"ask alice first", "alice", true},
+		{"malice aforethought", "alice", false},
+		{"alice_b and alice.", "alice", true},
+		{"deployment", "deploy", false},
+		{"請問小明今天", "小明", true},
+		{"", "x", false},
+	}
+	for _, tt := range tests {
+		if got := containsWord(tt.text, tt.word); got != tt.want {
+			t.Errorf("containsWord(%q, %q) = %v", tt.text, tt.word, got)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	got := links("See [[Runbook.md|the runbook]], [[deploy#steps]], [docs](../kb/faq.md#top), [site](https://x.example/a.md) and [mail](mailto:a@b).")
+	want := []string{"Runbook.md", "deploy", "faq.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
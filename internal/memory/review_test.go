@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+func TestReviewerRun(t *testing.T) {
+	old := time.Now().AddDate(0, 0, -60)
+	entries := []Entry{
+		{Key: "office", Value: "Floor 3", Priority: "P0", Confidence: 1, CheckedAt: old},
+		{Key: "editor", Value: "Uses vim", Priority: "P1", Confidence: 1, CheckedAt: old.Add(time.Hour)},
+		{Key: "stack", Value: "Go 1.21", Priority: "P1", Confidence: 1, CheckedAt: old.Add(2 * time.Hour)},
+		{Key: "fresh", Value: "Just learned", Priority: "P1", Confidence: 1, CheckedAt: time.Now()},
+		{Key: "gone", Value: "Expired", Priority: "P1", Confidence: 1, CheckedAt: old, Expired: true},
+	}
+	var (
+		prompt   string
+		applied  []Verdict
+		notified []string
+	)
+	cfg := &config.Config{WorkspaceDir: t.TempDir()}
+	cfg.Memory.Review = config.MemoryReviewConfig{Enabled: true, Agent: "reviewer"}
+	r := NewReviewer(cfg, Deps{
+		Executor: dispatch.TaskExecutorFunc(func(_ context.Context, tk dispatch.Task, agent string) dispatch.TaskResult {
+			prompt = tk.Prompt
+			if agent != "reviewer" || tk.Model != "haiku" || tk.Budget != 0.10 {
+				t.Errorf("task agent=%s model=%s budget=%v", agent, tk.Model, tk.Budget)
+			}
+			return dispatch.TaskResult{Status: "success", CostUSD: 0.01, Output: "Here you go:\n```json\n" + `{"reviews": [
+				{"key": "office", "verdict": "update", "value": "Floor 4", "reason": "moved in May"},
+				{"key": "editor", "verdict": "keep"},
+				{"key": "stack", "verdict": "unsure", "confidence": 0.3},
+				{"key": "fresh", "verdict": "expire"}
+			]}` + "\n```"}
+		}),
+		List:   func() ([]Entry, error) { return entries, nil },
+		Apply:  func(v Verdict) error { applied = append(applied, v); return nil },
+		Notify: func(text string) { notified = append(notified, text) },
+	})
+
+	res, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(prompt, "Just learned") || strings.Contains(prompt, "Expired") || !strings.Contains(prompt, `key="office"`) {
+		t.Errorf("prompt should hold only due entries:\n%s", prompt)
+	}
+	if res.Reviewed != 3 || res.Applied != 2 || res.Proposed != 1 || res.CostUSD != 0.01 {
+		t.Errorf("result = %+v", res)
+	}
+	if len(applied) != 2 || applied[0].Key != "editor" || applied[0].Confidence != 1 || applied[1].Key != "stack" || applied[1].Confidence != 0.3 {
+		t.Errorf("applied = %+v", applied)
+	}
+	if len(notified) != 1 || !strings.Contains(notified[0], "office: update — moved in May") {
+		t.Errorf("notified = %q", notified)
+	}
+
+	pending := r.Pending()
+	if len(pending) != 1 || pending[0].Key != "office" || pending[0].Current != "Floor 3" || pending[0].Value != "Floor 4" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	// A pending entry is not reviewed again, and the run waits for the interval.
+	prompt = ""
+	r.runDue(context.Background())
+	if prompt != "" {
+		t.Error("runDue ran before the interval passed")
+	}
+
+	if err := r.Resolve("office", "maybe"); err == nil {
+		t.Error("unknown action accepted")
+	}
+	if err := r.Resolve("editor", "accept"); err != ErrNoProposal {
+		t.Errorf("resolve without proposal = %v", err)
+	}
+	applied = nil
+	if err := r.Resolve("office", "keep"); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Verdict != VerdictKeep || applied[0].Confidence != 1 || len(r.Pending()) != 0 {
+		t.Errorf("keep applied %+v, pending %+v", applied, r.Pending())
+	}
+}
+
+func TestParseVerdicts(t *testing.T) {
+	keys := map[string]bool{"a": true, "b": true, "c": true, "d": true}
+	got := parseVerdicts(`{"reviews": [
+		{"key": "a", "verdict": "update", "value": " "},
+		{"key": "a", "verdict": "keep"},
+		{"key": "b", "verdict": "expire", "value": "ignored", "confidence": 7},
+		{"key": "c", "verdict": "delete"},
+		{"key": "zzz", "verdict": "keep"},
+		{"key": "d", "verdict": "update", "value": "new", "confidence": 0.7}
+	]}`, keys)
+	want := []Verdict{
+		{Key: "a", Verdict: VerdictUnsure, Confidence: 0.4},
+		{Key: "b", Verdict: VerdictExpire},
+		{Key: "d", Verdict: VerdictUpdate, Value: "new", Confidence: 0.7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("verdict %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if parseVerdicts("no json here", keys) != nil {
+		t.Error("garbage parsed")
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	for in, want := range map[string]time.Duration{"90d": 90 * 24 * time.Hour, "1.5d": 36 * time.Hour, "12h": 12 * time.Hour} {
+		if got, err := ParseTTL(in); err != nil || got != want {
+			t.Errorf("ParseTTL(%q) = %v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "d", "-3d", "soon", "0h"} {
+		if _, err := ParseTTL(in); err == nil {
+			t.Errorf("ParseTTL(%q) accepted", in)
+		}
+	}
+}
@@ -103,4 +103,10 @@ type BotRuntime interface {
 	BuildFilePromptPrefix(filePaths []string) string
 	// AgentModels returns a map of agent names to their configured models.
 	AgentModels() map[string]string
+	// FeedbackButtons returns whether replies carry thumbs up/down buttons.
+	FeedbackButtons() bool
+	// RecordFeedback stores the owner's thumbs up (1) or down (-1) on a task result.
+	RecordFeedback(taskID string, score int, source, author string) error
+	// ChartsDir returns where chart_render saves the charts replies reference.
+	ChartsDir() string
 }
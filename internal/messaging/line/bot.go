@@ -128,6 +128,11 @@ func NewBot(cfg Config, rt messaging.BotRuntime) *Bot {
 	}
 }
 
+// SetHTTPClient replaces the client used for LINE API calls.
+func (b *Bot) SetHTTPClient(c *http.Client) {
+	b.httpClient = c
+}
+
 // SetTyping is a no-op; LINE Messaging API does not support typing indicators.
 func (b *Bot) SetTyping(_ context.Context, _ string) error {
 	return nil
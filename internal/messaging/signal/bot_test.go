@@ -72,6 +72,11 @@ func (m *mockRuntime) ProviderHasNativeSession(string) bool
 func (m *mockRuntime) DownloadFile(string, string, string) (string, error)                         { return "", nil }
 func (m *mockRuntime) BuildFilePromptPrefix([]string) string                                       { return "" }
 func (m *mockRuntime) AgentModels() map[string]string                                              { return nil }
+func (m *mockRuntime) FeedbackButtons() bool { return false }
+func (m *mockRuntime) ChartsDir() string { return "" }
+func (m *mockRuntime) RecordFeedback(taskID string, score int, source, author string) error {
+	return nil
+}
 
 var testRT = &mockRuntime{}
 
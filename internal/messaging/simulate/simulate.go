@@ -0,0 +1,463 @@
+// Package simulate feeds synthetic inbound events through the real webhook
+// handlers of the messaging bots, so routing and permission settings can be
+// checked without sending a platform message. Each event is signed with a
+// one-off test secret, and the bot's outbound API calls are recorded instead
+// of sent.
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/messaging"
+	"tetora/internal/messaging/line"
+	"tetora/internal/messaging/slack"
+	"tetora/internal/messaging/whatsapp"
+)
+
+// Channels lists the platforms that can be simulated.
+var Channels = []string{"slack", "line", "whatsapp"}
+
+// Request describes one synthetic inbound message.
+type Request struct {
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+	User    string `json:"user,omitempty"` // sender ID; default a placeholder
+	Chat    string `json:"chat,omitempty"` // Slack channel or LINE group ID
+	Live    bool   `json:"live,omitempty"` // run the agent instead of a stub reply
+}
+
+// Platforms holds the bot settings of each channel. A nil entry simulates
+// the channel with empty settings.
+type Platforms struct {
+	Slack    *slack.Config
+	LINE     *line.Config
+	WhatsApp *whatsapp.Config
+}
+
+// Task is a dispatch the bot made while handling the event.
+type Task struct {
+	Agent          string  `json:"agent"`
+	Model          string  `json:"model,omitempty"`
+	PermissionMode string  `json:"permissionMode,omitempty"`
+	Source         string  `json:"source,omitempty"`
+	Status         string  `json:"status"`
+	Output         string  `json:"output,omitempty"`
+	CostUSD        float64 `json:"costUsd,omitempty"`
+}
+
+// Call is an outbound platform API call the bot attempted.
+type Call struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Text   string          `json:"text,omitempty"` // reply text, when the call carries one
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Result reports what the bot did with the event.
+type Result struct {
+	Channel    string   `json:"channel"`
+	Path       string   `json:"path"`   // webhook path the event was posted to
+	Status     int      `json:"status"` // handler HTTP status
+	Live       bool     `json:"live"`
+	Routes     []string `json:"routes,omitempty"` // agents chosen by the router
+	Tasks      []Task   `json:"tasks,omitempty"`
+	Calls      []Call   `json:"calls,omitempty"`
+	TimedOut   bool     `json:"timedOut,omitempty"`
+	DurationMs int64    `json:"durationMs"`
+}
+
+// settle is how long the bot must stay idle before the simulation ends.
+var settle = 250 * time.Millisecond
+
+// Run posts req to the channel's webhook handler and waits up to timeout for
+// the bot to finish. rt is the daemon's bot runtime; unless req.Live is set,
+// dispatches are answered with a stub and sessions and history are left
+// untouched.
+func Run(ctx context.Context, p Platforms, rt messaging.BotRuntime, req Request, timeout time.Duration) (*Result, error) {
+	if !slices.Contains(Channels, req.Channel) {
+		return nil, fmt.Errorf("unsupported channel %q (supported: %s)", req.Channel, strings.Join(Channels, ", "))
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		return nil, fmt.Errorf("message is required")
+	}
+	secret, err := testSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &recorder{last: time.Now()}
+	srt := &runtime{BotRuntime: rt, live: req.Live, rec: rec}
+	client := &http.Client{Transport: rec}
+
+	var (
+		path    string
+		handler http.HandlerFunc
+		body    []byte
+		header  = http.Header{}
+	)
+	switch req.Channel {
+	case "slack":
+		var cfg slack.Config
+		if p.Slack != nil {
+			cfg = *p.Slack
+		}
+		cfg.SigningSecret, cfg.BotToken = secret, "simulated"
+		cfg.DefaultChannel = "" // no approval gate
+		bot := slack.NewBot(cfg, srt)
+		bot.SetHTTPClient(client)
+		path, handler = "/slack/events", bot.EventHandler
+		body = slackEvent(req)
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + ts + ":" + string(body)))
+		header.Set("X-Slack-Request-Timestamp", ts)
+		header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	case "line":
+		var cfg line.Config
+		if p.LINE != nil {
+			cfg = *p.LINE
+		}
+		cfg.ChannelSecret, cfg.ChannelAccessToken = secret, "simulated"
+		bot := line.NewBot(cfg, srt)
+		bot.SetHTTPClient(client)
+		path, handler = cfg.WebhookPathOrDefault(), bot.HandleWebhook
+		body = lineEvent(req)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	case "whatsapp":
+		var cfg whatsapp.Config
+		if p.WhatsApp != nil {
+			cfg = *p.WhatsApp
+		}
+		cfg.AppSecret, cfg.AccessToken = secret, "simulated"
+		bot := whatsapp.NewBot(cfg, srt)
+		bot.SetHTTPClient(client)
+		path, handler = "/api/whatsapp/webhook", bot.WebhookHandler
+		body = whatsappEvent(req, cfg.PhoneNumberID)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	start := time.Now()
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	r.Header = header
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	res := &Result{Channel: req.Channel, Path: path, Status: w.Code, Live: req.Live}
+	if w.Code == http.StatusOK {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		res.TimedOut = !rec.wait(ctx)
+	}
+	res.DurationMs = time.Since(start).Milliseconds()
+	rec.mu.Lock()
+	res.Routes, res.Tasks, res.Calls = rec.routes, rec.tasks, rec.calls
+	rec.mu.Unlock()
+	return res, nil
+}
+
+func testSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("test secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func msgID() string {
+	return "sim" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func orDefault(s, def string) string {
+	if s != "" {
+		return s
+	}
+	return def
+}
+
+// --- Platform payloads ---
+
+func slackEvent(req Request) []byte {
+	ts := fmt.Sprintf("%d.%06d", time.Now().Unix(), time.Now().Nanosecond()/1000)
+	b, _ := json.Marshal(map[string]any{
+		"type":     "event_callback",
+		"team_id":  "TSIMULATED",
+		"event_id": "Ev" + msgID(),
+		"event": map[string]any{
+			"type":    "message",
+			"text":    req.Message,
+			"user":    orDefault(req.User, "USIMULATED"),
+			"channel": orDefault(req.Chat, "CSIMULATED"),
+			"ts":      ts,
+		},
+	})
+	return b
+}
+
+func lineEvent(req Request) []byte {
+	source := map[string]string{"type": "user", "userId": orDefault(req.User, "Usimulated")}
+	if req.Chat != "" {
+		source["type"], source["groupId"] = "group", req.Chat
+	}
+	b, _ := json.Marshal(map[string]any{
+		"destination": "Usimulatedbot",
+		"events": []map[string]any{{
+			"type":       "message",
+			"timestamp":  time.Now().UnixMilli(),
+			"replyToken": "simulated-" + msgID(),
+			"source":     source,
+			"message":    map[string]string{"id": msgID(), "type": "text", "text": req.Message},
+		}},
+	})
+	return b
+}
+
+func whatsappEvent(req Request, phoneNumberID string) []byte {
+	b, _ := json.Marshal(map[string]any{
+		"object": "whatsapp_business_account",
+		"entry": []map[string]any{{
+			"id": "simulated",
+			"changes": []map[string]any{{
+				"field": "messages",
+				"value": map[string]any{
+					"messaging_product": "whatsapp",
+					"metadata":          map[string]string{"phone_number_id": phoneNumberID},
+					"messages": []map[string]any{{
+						"id":        "wamid." + msgID(),
+						"from":      orDefault(req.User, "15550000000"),
+						"timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+						"type":      "text",
+						"text":      map[string]string{"body": req.Message},
+					}},
+				},
+			}},
+		}},
+	})
+	return b
+}
+
+// --- Recording ---
+
+// recorder stands in for the platform APIs and tracks what the bot does.
+type recorder struct {
+	mu     sync.Mutex
+	routes []string
+	tasks  []Task
+	calls  []Call
+	busy   int       // routes and dispatches in flight
+	last   time.Time // last activity
+}
+
+// RoundTrip records an outbound API call and answers it with a generic
+// success body that satisfies every supported platform.
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	c := Call{Method: req.Method, URL: req.URL.String(), Text: replyText(body)}
+	if json.Valid(body) {
+		c.Body = body
+	}
+	r.mu.Lock()
+	r.calls = append(r.calls, c)
+	r.last = time.Now()
+	r.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"ts":"` + msgID() + `"}`)),
+		Request:    req,
+	}, nil
+}
+
+func (r *recorder) begin() {
+	r.mu.Lock()
+	r.busy++
+	r.mu.Unlock()
+}
+
+func (r *recorder) end() {
+	r.mu.Lock()
+	r.busy--
+	r.last = time.Now()
+	r.mu.Unlock()
+}
+
+// wait blocks until the bot has been idle for settle, reporting false when
+// ctx ends first.
+func (r *recorder) wait(ctx context.Context) bool {
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-tick.C:
+			r.mu.Lock()
+			idle := r.busy == 0 && time.Since(r.last) >= settle
+			r.mu.Unlock()
+			if idle {
+				return true
+			}
+		}
+	}
+}
+
+// replyText extracts the message text of a Slack, LINE or WhatsApp send call.
+func replyText(body []byte) string {
+	var v struct {
+		Text     json.RawMessage `json:"text"`
+		Messages []struct {
+			Text string `json:"text"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &v) != nil {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(v.Text, &s) == nil {
+		return s
+	}
+	var wa struct {
+		Body string `json:"body"`
+	}
+	if json.Unmarshal(v.Text, &wa) == nil && wa.Body != "" {
+		return wa.Body
+	}
+	var parts []string
+	for _, m := range v.Messages {
+		if m.Text != "" {
+			parts = append(parts, m.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// --- Runtime ---
+
+// runtime wraps the daemon's bot runtime. It records routing and dispatch
+// decisions and, outside live mode, keeps the event from running an agent or
+// touching sessions and history.
+type runtime struct {
+	messaging.BotRuntime
+	live bool
+	rec  *recorder
+}
+
+func (r *runtime) Route(ctx context.Context, prompt, source string) (string, error) {
+	r.rec.begin()
+	defer r.rec.end()
+	agent, err := r.BotRuntime.Route(ctx, prompt, source)
+	r.rec.mu.Lock()
+	r.rec.routes = append(r.rec.routes, agent)
+	r.rec.mu.Unlock()
+	return agent, err
+}
+
+func (r *runtime) Submit(ctx context.Context, req messaging.TaskRequest) (messaging.TaskResult, error) {
+	r.rec.begin()
+	defer r.rec.end()
+	var (
+		res messaging.TaskResult
+		err error
+	)
+	if r.live {
+		res, err = r.BotRuntime.Submit(ctx, req)
+	} else {
+		agent := orDefault(req.AgentRole, r.DefaultAgent())
+		res = messaging.TaskResult{
+			Status: "success",
+			Output: fmt.Sprintf("[simulated] %s would answer this message.", orDefault(agent, "the default agent")),
+			TaskID: msgID(),
+		}
+	}
+	t := Task{
+		Agent:          req.AgentRole,
+		Model:          orDefault(req.Model, res.Model),
+		PermissionMode: req.PermissionMode,
+		Source:         req.Meta["source"],
+		Status:         res.Status,
+		Output:         res.Output,
+		CostUSD:        res.CostUSD,
+	}
+	if err != nil {
+		t.Status, t.Output = "error", err.Error()
+	}
+	r.rec.mu.Lock()
+	r.rec.tasks = append(r.rec.tasks, t)
+	r.rec.mu.Unlock()
+	return res, err
+}
+
+func (r *runtime) GetOrCreateSession(platform, key, agent, title string) (string, error) {
+	if r.live {
+		return r.BotRuntime.GetOrCreateSession(platform, key, agent, title)
+	}
+	return "", nil // bots skip session bookkeeping without an ID
+}
+
+func (r *runtime) RecordHistory(taskID, name, source, agent, outputFile string, task, result interface{}) {
+	if r.live {
+		r.BotRuntime.RecordHistory(taskID, name, source, agent, outputFile, task, result)
+	}
+}
+
+func (r *runtime) PublishEvent(eventType string, data map[string]interface{}) {
+	if r.live {
+		r.BotRuntime.PublishEvent(eventType, data)
+	}
+}
+
+func (r *runtime) SendWebhooks(status string, payload map[string]interface{}) {
+	if r.live {
+		r.BotRuntime.SendWebhooks(status, payload)
+	}
+}
+
+func (r *runtime) SetMemory(agent, key, value string) {
+	if r.live {
+		r.BotRuntime.SetMemory(agent, key, value)
+	}
+}
+
+func (r *runtime) UpdateAgentModel(agent, model string) error {
+	if r.live {
+		return r.BotRuntime.UpdateAgentModel(agent, model)
+	}
+	return fmt.Errorf("not applied in a simulation")
+}
+
+func (r *runtime) ArchiveSession(channelKey string) error {
+	if r.live {
+		return r.BotRuntime.ArchiveSession(channelKey)
+	}
+	return nil
+}
+
+func (r *runtime) AuditLog(action, source, target, ip string) {
+	if r.live {
+		r.BotRuntime.AuditLog(action, source, target, ip)
+	}
+}
@@ -0,0 +1,136 @@
+package simulate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/messaging"
+	"tetora/internal/messaging/line"
+)
+
+// fakeRuntime implements the runtime calls the Slack, LINE and WhatsApp bots
+// make while handling a plain text message. Anything else panics.
+type fakeRuntime struct {
+	messaging.BotRuntime
+	submitted int
+	sessions  int
+}
+
+func (f *fakeRuntime) Route(ctx context.Context, prompt, source string) (string, error) {
+	if strings.Contains(prompt, "invoice") {
+		return "accountant", nil
+	}
+	return "generalist", nil
+}
+func (f *fakeRuntime) Submit(ctx context.Context, req messaging.TaskRequest) (messaging.TaskResult, error) {
+	f.submitted++
+	return messaging.TaskResult{Status: "success", Output: "live answer", TaskID: "t1"}, nil
+}
+func (f *fakeRuntime) GetOrCreateSession(platform, key, agent, title string) (string, error) {
+	f.sessions++
+	return "", nil
+}
+func (f *fakeRuntime) AgentConfig(agent string) (string, string, bool) {
+	return "sonnet", "plan", true
+}
+func (f *fakeRuntime) ApprovalGatesEnabled() bool               { return false }
+func (f *fakeRuntime) BuildFilePromptPrefix([]string) string    { return "" }
+func (f *fakeRuntime) DefaultAgent() string                     { return "generalist" }
+func (f *fakeRuntime) ExpandPrompt(prompt, agent string) string { return prompt }
+func (f *fakeRuntime) FeedbackButtons() bool                    { return false }
+func (f *fakeRuntime) FillTaskDefaults(agent *string, name *string, source string) string {
+	return "t1"
+}
+func (f *fakeRuntime) LoadAgentPrompt(agent string) (string, error)               { return "", nil }
+func (f *fakeRuntime) ProviderHasNativeSession(agent string) bool                 { return false }
+func (f *fakeRuntime) SessionContextLimit() int                                   { return 10 }
+func (f *fakeRuntime) SmartDispatchEnabled() bool                                 { return true }
+func (f *fakeRuntime) Truncate(s string, n int) string                            { return s }
+func (f *fakeRuntime) ChartsDir() string                                          { return "" }
+func (f *fakeRuntime) NewTraceID(source string) string                            { return "trace" }
+func (f *fakeRuntime) WithTraceID(ctx context.Context, id string) context.Context { return ctx }
+func (f *fakeRuntime) RecordHistory(string, string, string, string, string, interface{}, interface{}) {
+}
+func (f *fakeRuntime) PublishEvent(string, map[string]interface{})                {}
+func (f *fakeRuntime) SendWebhooks(string, map[string]interface{})                {}
+func (f *fakeRuntime) SetMemory(string, string, string)                           {}
+func (f *fakeRuntime) AuditLog(string, string, string, string)                    {}
+func (f *fakeRuntime) LogInfo(string, ...interface{})                             {}
+func (f *fakeRuntime) LogWarn(string, ...interface{})                             {}
+func (f *fakeRuntime) LogError(string, error, ...interface{})                     {}
+func (f *fakeRuntime) LogInfoCtx(context.Context, string, ...interface{})         {}
+func (f *fakeRuntime) LogErrorCtx(context.Context, string, error, ...interface{}) {}
+func (f *fakeRuntime) LogDebugCtx(context.Context, string, ...interface{})        {}
+
+func TestRun_DryRun(t *testing.T) {
+	for _, ch := range Channels {
+		t.Run(ch, func(t *testing.T) {
+			rt := &fakeRuntime{}
+			res, err := Run(context.Background(), Platforms{}, rt, Request{Channel: ch, Message: "pay the invoice"}, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != http.StatusOK || res.TimedOut {
+				t.Fatalf("status %d timedOut %v", res.Status, res.TimedOut)
+			}
+			if len(res.Routes) != 1 || res.Routes[0] != "accountant" {
+				t.Errorf("routes %v", res.Routes)
+			}
+			if len(res.Tasks) != 1 || res.Tasks[0].Agent != "accountant" || res.Tasks[0].PermissionMode != "plan" {
+				t.Errorf("tasks %+v", res.Tasks)
+			}
+			if rt.submitted != 0 || rt.sessions != 0 {
+				t.Errorf("dry run reached the runtime: submitted=%d sessions=%d", rt.submitted, rt.sessions)
+			}
+			var replied bool
+			for _, c := range res.Calls {
+				if strings.Contains(c.Text, "[simulated] accountant") {
+					replied = true
+				}
+			}
+			if !replied {
+				t.Errorf("no simulated reply recorded: %+v", res.Calls)
+			}
+		})
+	}
+}
+
+func TestRun_Live(t *testing.T) {
+	rt := &fakeRuntime{}
+	cfg := &line.Config{DefaultAgent: "concierge", WebhookPath: "/hooks/line"}
+	res, err := Run(context.Background(), Platforms{LINE: cfg}, rt, Request{Channel: "line", Message: "hi", Chat: "G1", Live: true}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path != "/hooks/line" || len(res.Routes) != 0 || rt.submitted != 1 || rt.sessions != 1 {
+		t.Errorf("result %+v submitted=%d sessions=%d", res, rt.submitted, rt.sessions)
+	}
+	if len(res.Tasks) != 1 || res.Tasks[0].Agent != "concierge" || res.Tasks[0].Output != "live answer" {
+		t.Errorf("tasks %+v", res.Tasks)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	if _, err := Run(context.Background(), Platforms{}, &fakeRuntime{}, Request{Channel: "discord", Message: "x"}, time.Second); err == nil {
+		t.Error("unsupported channel accepted")
+	}
+	if _, err := Run(context.Background(), Platforms{}, &fakeRuntime{}, Request{Channel: "slack", Message: " "}, time.Second); err == nil {
+		t.Error("empty message accepted")
+	}
+}
+
+func TestReplyText(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"channel":"C","text":"hi"}`: "hi",
+		`{"replyToken":"r","messages":[{"type":"text","text":"a"},{"type":"text","text":"b"}]}`: "a\nb",
+		`{"to":"1","type":"text","text":{"body":"wa"}}`:                                         "wa",
+		`not json`: "",
+	} {
+		if got := replyText([]byte(body)); got != want {
+			t.Errorf("replyText(%s) = %q, want %q", body, got, want)
+		}
+	}
+}
@@ -9,11 +9,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"tetora/internal/chart"
+	"tetora/internal/docgen"
 	"tetora/internal/messaging"
 )
 
@@ -61,14 +66,18 @@ type Bot struct {
 	processed     map[string]time.Time
 	processedSize int
 	approvalGate  *ApprovalGate
+
+	// httpClient for Web API calls (replaceable for testing and simulation).
+	httpClient *http.Client
 }
 
 // NewBot creates a new Slack bot.
 func NewBot(cfg Config, rt messaging.BotRuntime) *Bot {
 	b := &Bot{
-		cfg:       cfg,
-		rt:        rt,
-		processed: make(map[string]time.Time),
+		cfg:        cfg,
+		rt:         rt,
+		processed:  make(map[string]time.Time),
+		httpClient: http.DefaultClient,
 	}
 	if rt != nil && rt.ApprovalGatesEnabled() && cfg.DefaultChannel != "" {
 		b.approvalGate = NewApprovalGate(b, cfg.DefaultChannel, rt.ApprovalGatesAutoApproveTools())
@@ -76,6 +85,11 @@ func NewBot(cfg Config, rt messaging.BotRuntime) *Bot {
 	return b
 }
 
+// SetHTTPClient replaces the client used for Slack Web API calls.
+func (b *Bot) SetHTTPClient(c *http.Client) {
+	b.httpClient = c
+}
+
 // EventHandler handles incoming Slack Events API requests.
 // Register this at /slack/events in the HTTP server.
 func (b *Bot) EventHandler(w http.ResponseWriter, r *http.Request) {
@@ -357,8 +371,12 @@ func (b *Bot) handleRoute(ev event, prompt string) {
 	var text strings.Builder
 	fmt.Fprintf(&text, "*Route:* %s\n", role)
 
+	// Charts the reply references are uploaded as images after the text.
+	var charts []chart.Image
 	if result.Status == "success" {
-		fmt.Fprintf(&text, "\n%s", b.rt.Truncate(result.Output, 3000))
+		var output string
+		output, charts = chart.ExtractImages(result.Output, b.rt.ChartsDir())
+		fmt.Fprintf(&text, "\n%s", b.rt.Truncate(output, 3000))
 	} else {
 		fmt.Fprintf(&text, "\n*[%s]* %s", result.Status, b.rt.Truncate(result.Error, 500))
 	}
@@ -366,10 +384,55 @@ func (b *Bot) handleRoute(ev event, prompt string) {
 	dur := time.Duration(result.DurationMs) * time.Millisecond
 	fmt.Fprintf(&text, "\n\n_$%.2f | %s_", result.CostUSD, dur.Round(time.Second))
 
+	var blocks []map[string]any
+	if b.rt.FeedbackButtons() && result.Status == "success" && result.TaskID != "" {
+		blocks = feedbackBlocks(text.String(), result.TaskID)
+	}
 	if thinkingTS != "" {
-		b.UpdateMessage(ev.Channel, thinkingTS, text.String())
+		b.UpdateMessage(ev.Channel, thinkingTS, text.String(), blocks...)
 	} else {
-		b.Reply(ev.Channel, ts, text.String())
+		b.Reply(ev.Channel, ts, text.String(), blocks...)
+	}
+	b.sendCharts(ev.Channel, charts)
+}
+
+// sendCharts uploads chart images referenced by a reply.
+func (b *Bot) sendCharts(channel string, charts []chart.Image) {
+	for _, img := range charts {
+		data, err := os.ReadFile(img.Path)
+		if err != nil {
+			b.rt.LogWarn("slack read chart failed", "path", img.Path, "error", err)
+			continue
+		}
+		f := docgen.File{Name: filepath.Base(img.Path), ContentType: img.ContentType(), Data: data}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := docgen.Slack(ctx, b.cfg.BotToken, channel, img.Title, f); err != nil {
+			b.rt.LogWarn("slack send chart failed", "path", img.Path, "error", err)
+		}
+		cancel()
+	}
+}
+
+// feedbackBlocks lays out a result as a section followed by thumbs up/down
+// buttons, handled by InteractionHandler.
+func feedbackBlocks(text, taskID string) []map[string]any {
+	if r := []rune(text); len(r) > 3000 { // section text limit
+		text = string(r[:2997]) + "..."
+	}
+	button := func(label, actionID string) map[string]any {
+		return map[string]any{
+			"type":      "button",
+			"text":      map[string]any{"type": "plain_text", "text": label, "emoji": true},
+			"action_id": actionID,
+			"value":     taskID,
+		}
+	}
+	return []map[string]any{
+		{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}},
+		{"type": "actions", "block_id": "feedback", "elements": []map[string]any{
+			button("\U0001F44D", "feedback_up"),
+			button("\U0001F44E", "feedback_down"),
+		}},
 	}
 }
 
@@ -499,21 +562,25 @@ func (b *Bot) cmdHelp(ev event) {
 
 // --- Slack API ---
 
-// Reply sends a message to a Slack channel, optionally in a thread.
-func (b *Bot) Reply(channel, threadTS, text string) {
+// Reply sends a message to a Slack channel, optionally in a thread. Blocks,
+// when given, lay the message out and text becomes the notification fallback.
+func (b *Bot) Reply(channel, threadTS, text string, blocks ...map[string]any) {
 	token := b.cfg.BotToken
 	if token == "" {
 		b.rt.LogWarn("slack cannot send message, botToken is empty")
 		return
 	}
 
-	payload := map[string]string{
+	payload := map[string]any{
 		"channel": channel,
 		"text":    text,
 	}
 	if threadTS != "" {
 		payload["thread_ts"] = threadTS
 	}
+	if len(blocks) > 0 {
+		payload["blocks"] = blocks
+	}
 
 	body, _ := json.Marshal(payload)
 	req, err := http.NewRequest("POST", "https://slack.com/api/chat.postMessage",
@@ -525,7 +592,7 @@ func (b *Bot) Reply(channel, threadTS, text string) {
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.httpClient.Do(req)
 	if err != nil {
 		b.rt.LogError("slack send error", err)
 		return
@@ -562,7 +629,7 @@ func (b *Bot) PostMessage(channel, threadTS, text string) string {
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.httpClient.Do(req)
 	if err != nil {
 		return ""
 	}
@@ -579,18 +646,22 @@ func (b *Bot) PostMessage(channel, threadTS, text string) string {
 	return ""
 }
 
-// UpdateMessage updates a previously sent message with new text.
-func (b *Bot) UpdateMessage(channel, messageTS, text string) {
+// UpdateMessage updates a previously sent message with new text and,
+// optionally, blocks.
+func (b *Bot) UpdateMessage(channel, messageTS, text string, blocks ...map[string]any) {
 	token := b.cfg.BotToken
 	if token == "" {
 		return
 	}
 
-	payload := map[string]string{
+	payload := map[string]any{
 		"channel": channel,
 		"ts":      messageTS,
 		"text":    text,
 	}
+	if len(blocks) > 0 {
+		payload["blocks"] = blocks
+	}
 
 	body, _ := json.Marshal(payload)
 	req, err := http.NewRequest("POST", "https://slack.com/api/chat.update",
@@ -602,7 +673,7 @@ func (b *Bot) UpdateMessage(channel, messageTS, text string) {
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := b.httpClient.Do(req)
 	if err != nil {
 		b.rt.LogError("slack update error", err)
 		return
@@ -728,6 +799,89 @@ func (g *ApprovalGate) HandleCallback(reqID string, approved bool) {
 	}
 }
 
+// --- Interactivity ---
+
+// interactionPayload is the part of a Slack block_actions payload the
+// feedback buttons need.
+type interactionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// InteractionHandler handles Slack interactivity requests (button presses).
+// Register this at /slack/interactions in the HTTP server and set it as the
+// app's Interactivity Request URL.
+func (b *Bot) InteractionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if secret := b.cfg.SigningSecret; secret != "" && !VerifySignature(r, body, secret) {
+		b.rt.LogWarn("slack invalid signature", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var p interactionPayload
+	if err := json.Unmarshal([]byte(form.Get("payload")), &p); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if p.Type != "block_actions" {
+		return
+	}
+	for _, a := range p.Actions {
+		score := map[string]int{"feedback_up": 1, "feedback_down": -1}[a.ActionID]
+		if score == 0 || a.Value == "" {
+			continue
+		}
+		author := "slack:" + p.User.ID
+		if p.User.Username != "" {
+			author = "slack:@" + p.User.Username
+		}
+		text := "Thanks for the feedback."
+		if err := b.rt.RecordFeedback(a.Value, score, "slack", author); err != nil {
+			text = "Feedback failed: " + err.Error()
+		}
+		go b.respondEphemeral(p.ResponseURL, text)
+	}
+}
+
+// respondEphemeral shows text only to the user who pressed a button.
+func (b *Bot) respondEphemeral(responseURL, text string) {
+	if !strings.HasPrefix(responseURL, "https://hooks.slack.com/") {
+		return
+	}
+	body, _ := json.Marshal(map[string]any{"response_type": "ephemeral", "replace_original": false, "text": text})
+	resp, err := http.Post(responseURL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		b.rt.LogError("slack response_url error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
 // --- Signature Verification ---
 
 // VerifySignature verifies the HMAC-SHA256 signature from Slack.
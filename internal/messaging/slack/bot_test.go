@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -17,7 +18,9 @@ import (
 )
 
 // mockRuntime implements messaging.BotRuntime with no-op methods for testing.
-type mockRuntime struct{}
+type mockRuntime struct {
+	feedback []string // "taskID score author" per RecordFeedback call
+}
 
 func (m *mockRuntime) Submit(ctx context.Context, req messaging.TaskRequest) (messaging.TaskResult, error) {
 	return messaging.TaskResult{}, nil
@@ -85,6 +88,12 @@ func (m *mockRuntime) DownloadFile(url, filename, authHeader string) (string, er
 }
 func (m *mockRuntime) BuildFilePromptPrefix(filePaths []string) string     { return "" }
 func (m *mockRuntime) AgentModels() map[string]string                      { return nil }
+func (m *mockRuntime) FeedbackButtons() bool { return false }
+func (m *mockRuntime) ChartsDir() string { return "" }
+func (m *mockRuntime) RecordFeedback(taskID string, score int, source, author string) error {
+	m.feedback = append(m.feedback, fmt.Sprintf("%s %d %s", taskID, score, author))
+	return nil
+}
 
 // --- StripMentions ---
 
@@ -311,3 +320,35 @@ func TestSlackEventHandler_IgnoresBotMessages(t *testing.T) {
 		t.Errorf("expected 200, got %d", w.Code)
 	}
 }
+
+// --- InteractionHandler ---
+
+func TestSlackInteractionHandler_Feedback(t *testing.T) {
+	rt := &mockRuntime{}
+	b := NewBot(Config{Enabled: true, BotToken: "xoxb-test"}, rt)
+
+	payload := `{"type":"block_actions","user":{"id":"U1","username":"owner"},` +
+		`"actions":[{"action_id":"feedback_down","value":"task-1"}],"response_url":"http://example.com/"}`
+	req := httptest.NewRequest("POST", "/slack/interactions", strings.NewReader("payload="+url.QueryEscape(payload)))
+	w := httptest.NewRecorder()
+
+	b.InteractionHandler(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if len(rt.feedback) != 1 || rt.feedback[0] != "task-1 -1 slack:@owner" {
+		t.Errorf("feedback = %q", rt.feedback)
+	}
+}
+
+func TestFeedbackBlocks(t *testing.T) {
+	blocks := feedbackBlocks(strings.Repeat("x", 4000), "task-1")
+	text := blocks[0]["text"].(map[string]any)["text"].(string)
+	if len([]rune(text)) != 3000 {
+		t.Errorf("section text length = %d", len([]rune(text)))
+	}
+	if blocks[1]["type"] != "actions" {
+		t.Errorf("second block = %v", blocks[1])
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"tetora/internal/chart"
+	"tetora/internal/docgen"
 	"tetora/internal/messaging"
 	"tetora/internal/trace"
 )
@@ -30,6 +33,7 @@ type tgUpdate struct {
 type tgMessage struct {
 	MessageID int           `json:"message_id"`
 	Chat      tgChat        `json:"chat"`
+	From      *tgUser       `json:"from,omitempty"`
 	Text      string        `json:"text"`
 	Caption   string        `json:"caption,omitempty"`
 	Document  *tgDocument   `json:"document,omitempty"`
@@ -62,7 +66,16 @@ type tgCallbackQuery struct {
 }
 
 type tgUser struct {
-	ID int64 `json:"id"`
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// decider names the user who pressed a button, for audit trails.
+func (u tgUser) decider() string {
+	if u.Username != "" {
+		return "telegram:@" + u.Username
+	}
+	return fmt.Sprintf("telegram:%d", u.ID)
 }
 
 // Inline keyboard types for Telegram Bot API.
@@ -110,10 +123,16 @@ type Bot struct {
 	chatID           int64
 	pollTimeout      int
 	client           *http.Client
+	apiBase          string // "https://api.telegram.org"
+	offset           int    // next getUpdates offset
 	pendingEstimates map[string]*pendingEstimate
 	pendingSuggests  map[string]*pendingSuggest
 	pendingMu        sync.Mutex
 	approvalGate     *tgApprovalGate // P28.0: approval gate for this bot
+
+	// Webhook mode (see webhook.go).
+	webhookSecret string
+	updates       chan tgUpdate
 }
 
 // NewBot creates a new Telegram Bot instance.
@@ -126,8 +145,14 @@ func NewBot(cfg Config, rt TelegramRuntime) *Bot {
 		chatID:           cfg.ChatID,
 		pollTimeout:      pollTimeout,
 		client:           &http.Client{Timeout: time.Duration(pollTimeout+10) * time.Second},
+		apiBase:          "https://api.telegram.org",
 		pendingEstimates: make(map[string]*pendingEstimate),
 		pendingSuggests:  make(map[string]*pendingSuggest),
+		webhookSecret:    cfg.WebhookSecret,
+		updates:          make(chan tgUpdate, 100),
+	}
+	if b.webhookSecret == "" {
+		b.webhookSecret = newWebhookSecret()
 	}
 	// P28.0: Create approval gate if enabled.
 	if rt.ApprovalGatesEnabled() {
@@ -280,7 +305,6 @@ func (b *Bot) sendSuggestConfirm(chatID int64, result messaging.TaskResult, role
 
 // PollLoop runs the Telegram polling loop until ctx is cancelled.
 func (b *Bot) PollLoop(ctx context.Context) {
-	offset := 0
 	b.rt.LogInfo("telegram bot polling started", "chatID", b.chatID)
 
 	for {
@@ -290,8 +314,8 @@ func (b *Bot) PollLoop(ctx context.Context) {
 		default:
 		}
 
-		url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=%d",
-			b.token, offset, b.pollTimeout)
+		url := fmt.Sprintf("%s/bot%s/getUpdates?offset=%d&timeout=%d",
+			b.apiBase, b.token, b.offset, b.pollTimeout)
 
 		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 		resp, err := b.client.Do(req)
@@ -299,7 +323,7 @@ func (b *Bot) PollLoop(ctx context.Context) {
 			if ctx.Err() != nil {
 				return
 			}
-			b.rt.LogError("telegram poll error", err)
+			b.rt.LogError("telegram poll error", stripURL(err))
 			time.Sleep(5 * time.Second)
 			continue
 		}
@@ -307,32 +331,47 @@ func (b *Bot) PollLoop(ctx context.Context) {
 		var body struct {
 			OK     bool       `json:"ok"`
 			Result []tgUpdate `json:"result"`
+			apiError
 		}
 		json.NewDecoder(resp.Body).Decode(&body)
 		resp.Body.Close()
 
-		for _, u := range body.Result {
-			offset = u.UpdateID + 1
-
-			// Handle callback queries (inline keyboard button presses).
-			if u.CallbackQuery != nil {
-				if u.CallbackQuery.Message != nil && u.CallbackQuery.Message.Chat.ID == b.chatID {
-					b.handleCallback(ctx, u.CallbackQuery)
-				}
-				continue
+		// Back off on errors, for as long as Telegram asks when rate limited.
+		if !body.OK {
+			wait := body.retryAfter(5 * time.Second)
+			b.rt.LogWarn("telegram poll refused", "code", body.ErrorCode, "description", body.Description, "retryIn", wait)
+			if !sleepCtx(ctx, wait) {
+				return
 			}
+			continue
+		}
 
-			if u.Message == nil {
-				continue
-			}
-			if u.Message.Chat.ID != b.chatID {
-				continue
-			}
-			b.handleMessage(ctx, u.Message)
+		for _, u := range body.Result {
+			b.offset = u.UpdateID + 1
+			b.handleUpdate(ctx, u)
 		}
 	}
 }
 
+// handleUpdate handles one update from the allowed chat.
+func (b *Bot) handleUpdate(ctx context.Context, u tgUpdate) {
+	// Handle callback queries (inline keyboard button presses).
+	if u.CallbackQuery != nil {
+		if u.CallbackQuery.Message != nil && u.CallbackQuery.Message.Chat.ID == b.chatID {
+			b.handleCallback(ctx, u.CallbackQuery)
+		}
+		return
+	}
+
+	if u.Message == nil {
+		return
+	}
+	if u.Message.Chat.ID != b.chatID {
+		return
+	}
+	b.handleMessage(ctx, u.Message)
+}
+
 func (b *Bot) handleMessage(ctx context.Context, msg *tgMessage) {
 	// Lazy cleanup of expired pending estimates.
 	b.cleanupPendingEstimates()
@@ -395,6 +434,8 @@ func (b *Bot) handleMessage(ctx context.Context, msg *tgMessage) {
 		b.cmdStatus(msg)
 	case command == "/cancel":
 		b.cmdCancel(msg)
+	case command == "/emergency":
+		b.reply(msg.Chat.ID, b.rt.EmergencyCommand(args))
 	case command == "/cron" || command == "/jobs":
 		b.cmdCron(ctx, msg, args)
 	case command == "/cost":
@@ -415,13 +456,37 @@ func (b *Bot) handleMessage(ctx context.Context, msg *tgMessage) {
 		b.cmdRoute(ctx, msg, args)
 	case command == "/new":
 		b.cmdNew(msg, args)
+	case command == "/group":
+		b.reply(msg.Chat.ID, b.rt.GroupCommand(msg.Chat.ID, args))
+	case command == "/intents":
+		b.reply(msg.Chat.ID, b.rt.IntentsCommand(args))
+	case command == "/translate":
+		b.reply(msg.Chat.ID, b.rt.TranslateCommand(msg.Chat.ID, args))
 	case command == "/trust":
 		b.cmdTrust(msg)
 	case command == "/model":
 		b.cmdModel(msg, args)
+	case command == "/handovers":
+		b.cmdHandovers(msg)
+	case command == "/reply":
+		b.cmdReplyHandover(msg, args)
+	case command == "/release":
+		b.cmdReleaseHandover(msg, args)
+	case command == "/faq":
+		b.cmdFAQ(msg, args)
 	case command == "/help":
 		b.cmdHelp(msg)
 	default:
+		b.rt.CollectLinks(text)
+		// A bare link gets a summary instead of an agent run.
+		if link, ok := b.rt.UnfurlTarget(msg.Chat.ID, stripBotMention(text)); ok {
+			b.cmdUnfurl(ctx, msg, link)
+			return
+		}
+		// Group session: the chat's agents answer instead of a routed one.
+		if !strings.HasPrefix(text, "/") && b.answerGroup(ctx, msg, text) {
+			return
+		}
 		// Smart dispatch: route non-command messages if enabled.
 		if b.rt.SmartDispatchEnabled() && !strings.HasPrefix(text, "/") && text != "" {
 			// Strip @botname mentions before routing.
@@ -444,6 +509,43 @@ func stripBotMention(text string) string {
 	return strings.TrimSpace(text)
 }
 
+// --- Group sessions ---
+
+// answerGroup runs the message through the chat's group session, if any,
+// sending each agent's reply under its name.
+func (b *Bot) answerGroup(ctx context.Context, msg *tgMessage, text string) bool {
+	if !b.rt.InGroup(msg.Chat.ID) {
+		return false
+	}
+	b.sendTypingAction(msg.Chat.ID)
+	go func() {
+		for _, r := range b.rt.AnswerGroup(ctx, msg.Chat.ID, text) {
+			output := r.Task.Output
+			if r.Task.Status != "success" {
+				output = "Error: " + r.Task.Error
+			}
+			output, charts := chart.ExtractImages(output, b.rt.ChartsDir())
+			b.reply(msg.Chat.ID, truncate(fmt.Sprintf("@%s: %s", r.Agent, output), 4000))
+			b.sendCharts(msg.Chat.ID, charts)
+		}
+	}()
+	return true
+}
+
+// --- Link unfurl ---
+
+func (b *Bot) cmdUnfurl(ctx context.Context, msg *tgMessage, link string) {
+	b.sendTypingAction(msg.Chat.ID)
+	go func() {
+		reply, err := b.rt.UnfurlLink(ctx, msg.Chat.ID, link)
+		if err != nil {
+			b.rt.LogWarn("telegram unfurl failed", "url", link, "error", err)
+			reply = fmt.Sprintf("Couldn't summarize that link: %v", err)
+		}
+		b.reply(msg.Chat.ID, reply)
+	}()
+}
+
 // --- /dispatch ---
 
 // DispatchTaskJSON is the JSON payload for /dispatch.
@@ -805,6 +907,21 @@ func (b *Bot) cmdRoute(ctx context.Context, msg *tgMessage, prompt string) {
 		return
 	}
 
+	// FAQ: a canned answer skips routing and cost entirely.
+	if answer, ok := b.rt.AnswerFAQ(ctx, prompt); ok {
+		b.reply(msg.Chat.ID, answer)
+		return
+	}
+
+	// Intent shortcuts: a known short utterance runs its skill or tool directly.
+	if run, ok := b.rt.MatchIntent(prompt); ok {
+		b.sendTypingAction(msg.Chat.ID)
+		go func() {
+			b.reply(msg.Chat.ID, run(ctx))
+		}()
+		return
+	}
+
 	// Cost confirmation check.
 	if b.maybeCostConfirm(msg.Chat.ID, prompt, true) {
 		return
@@ -828,8 +945,12 @@ func (b *Bot) execRoute(ctx context.Context, msg *tgMessage, prompt string) {
 		traceID := b.rt.NewTraceID("tg")
 		routeCtx := b.rt.WithTraceID(ctx, traceID)
 
+		// Auto-translate: the agent sees the message in its language; the
+		// session keeps what the user wrote.
+		agentPrompt, translateBack := b.rt.TranslateIn(routeCtx, msg.Chat.ID, prompt)
+
 		// Step 1: Route to determine agent.
-		routedAgent, err := b.rt.Route(routeCtx, prompt, "telegram")
+		routedAgent, err := b.rt.Route(routeCtx, agentPrompt, "telegram")
 		if err != nil {
 			b.rt.LogErrorCtx(routeCtx, "telegram: route error", err)
 		}
@@ -845,7 +966,7 @@ func (b *Bot) execRoute(ctx context.Context, msg *tgMessage, prompt string) {
 		var sessionID string
 		var sessionMsgCount int
 		var sessionTotalTokensIn float64
-		contextPrompt := prompt
+		contextPrompt := agentPrompt
 		if sess != nil {
 			sessionID = sess.ID
 			sessionMsgCount = sess.MessageCount
@@ -853,7 +974,7 @@ func (b *Bot) execRoute(ctx context.Context, msg *tgMessage, prompt string) {
 
 			if !b.rt.ProviderHasNativeSession(routedAgent) {
 				sessionCtx := b.rt.BuildSessionContext(sessionID, b.rt.SessionContextLimit())
-				contextPrompt = b.rt.WrapWithContext(sessionCtx, prompt)
+				contextPrompt = b.rt.WrapWithContext(sessionCtx, agentPrompt)
 			}
 
 			// Record user message to session.
@@ -886,12 +1007,20 @@ func (b *Bot) execRoute(ctx context.Context, msg *tgMessage, prompt string) {
 		}
 
 		// Step 4: Run task via RouteAndRun (runtime handles routing details, agent config, expansion).
-		sdr := b.rt.RouteAndRun(routeCtx, prompt, "route:telegram", sessionID, contextPrompt)
+		var userID string
+		if msg.From != nil {
+			userID = strconv.FormatInt(msg.From.ID, 10)
+		}
+		chatID := strconv.FormatInt(msg.Chat.ID, 10)
+		sdr := b.rt.RouteAndRun(routeCtx, agentPrompt, "route:telegram", chatID, userID, sessionID, contextPrompt)
 
 		if sdr == nil {
 			b.reply(msg.Chat.ID, "Internal error: no result from route.")
 			return
 		}
+		if translateBack != nil && sdr.Task.Status == "success" {
+			sdr.Task.Output = translateBack(routeCtx, sdr.Task.Output)
+		}
 
 		// Stop progress updater.
 		if progressStopCh != nil {
@@ -966,10 +1095,14 @@ func (b *Bot) sendRouteResponse(chatID int64, result *SmartDispatchResult, skipO
 	lines = append(lines, fmt.Sprintf("\xf0\x9f\x8e\xaf Route \xe2\x86\x92 %s (%s, %s confidence)",
 		result.Route.Agent, result.Route.Method, result.Route.Confidence))
 
+	// Charts the reply references are sent as photos after the text.
+	var charts []chart.Image
 	if !skipOutput {
 		if result.Task.Status == "success" {
+			var output string
+			output, charts = chart.ExtractImages(result.Task.Output, b.rt.ChartsDir())
 			lines = append(lines, "")
-			lines = append(lines, truncate(result.Task.Output, 3000))
+			lines = append(lines, truncate(output, 3000))
 		} else {
 			lines = append(lines, fmt.Sprintf("\n\xe2\x9d\x8c [%s] %s",
 				result.Task.Status, truncate(result.Task.Error, 500)))
@@ -1001,9 +1134,37 @@ func (b *Bot) sendRouteResponse(chatID int64, result *SmartDispatchResult, skipO
 			},
 		}
 		b.replyWithKeyboard(chatID, responseText, keyboard)
+	} else if b.rt.FeedbackButtons() && result.Task.TaskID != "" {
+		b.replyWithKeyboard(chatID, responseText, feedbackKeyboard(result.Task.TaskID))
 	} else {
 		b.reply(chatID, responseText)
 	}
+	b.sendCharts(chatID, charts)
+}
+
+// sendCharts sends chart images referenced by a reply as photos.
+func (b *Bot) sendCharts(chatID int64, charts []chart.Image) {
+	for _, img := range charts {
+		data, err := os.ReadFile(img.Path)
+		if err != nil {
+			b.rt.LogWarn("telegram read chart failed", "path", img.Path, "error", err)
+			continue
+		}
+		f := docgen.File{Name: filepath.Base(img.Path), ContentType: img.ContentType(), Data: data}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := docgen.Telegram(ctx, b.token, chatID, img.Title, f); err != nil {
+			b.rt.LogWarn("telegram send chart failed", "path", img.Path, "error", err)
+		}
+		cancel()
+	}
+}
+
+// feedbackKeyboard holds the thumbs up/down buttons under a task's result.
+func feedbackKeyboard(taskID string) [][]InlineButton {
+	return [][]InlineButton{{
+		{Text: "\xf0\x9f\x91\x8d", CallbackData: "fb_up:" + taskID},
+		{Text: "\xf0\x9f\x91\x8e", CallbackData: "fb_down:" + taskID},
+	}}
 }
 
 // --- /help ---
@@ -1046,8 +1207,12 @@ func (b *Bot) cmdHelp(msg *tgMessage) {
 		"/route <task> - smart dispatch (auto-route to best agent)\n"+
 		"/ask <prompt> - quick question (no agent)\n"+
 		"/new [agent] - start fresh session (archives current)\n"+
+		"/group <agents...> [--moderator a] | off - share a session between agents\n"+
+		"/intents [suggest | learn <n> | rm <id>] - shortcuts that skip the agents\n"+
+		"/translate on [agent lang] [reply lang] | off - auto-translate this chat\n"+
 		"/status - check running tasks\n"+
 		"/cancel - cancel all running tasks\n"+
+		"/emergency [stop [reason] | resume [code]] - halt all execution until resumed\n"+
 		"/jobs - list cron jobs (with buttons)\n"+
 		"/cron enable/disable <id> - toggle job\n"+
 		"/cron run <id> - trigger job now\n"+
@@ -1059,6 +1224,10 @@ func (b *Bot) cmdHelp(msg *tgMessage) {
 		"/trust - show trust levels for all agents\n"+
 		"/model [model] [agent] - show/switch model\n"+
 		"/memory <keyword> - search memory files\n"+
+		"/handovers - conversations waiting for you\n"+
+		"/reply <id> <text> - answer a handed-over conversation\n"+
+		"/release <id> - hand a conversation back to the agents\n"+
+		"/faq [add|edit|rm|suggest] - canned answers\n"+
 		"/help - this message\n\n"+
 		"Messages are linked to persistent sessions per agent.\n"+
 		"Conversation history is automatically maintained.\n"+
@@ -1068,6 +1237,151 @@ func (b *Bot) cmdHelp(msg *tgMessage) {
 		"You can also send files/photos directly - they will be saved and analyzed by the agent.")
 }
 
+// --- /handovers, /reply, /release ---
+
+func (b *Bot) cmdHandovers(msg *tgMessage) {
+	list, err := b.rt.OpenHandovers()
+	if err != nil {
+		b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	if len(list) == 0 {
+		b.reply(msg.Chat.ID, "No open handovers.")
+		return
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Open handovers (%d):", len(list)))
+	for _, h := range list {
+		who := h.UserName
+		if who == "" {
+			who = h.ChatID
+		}
+		lines = append(lines, fmt.Sprintf("• %s — %s on %s (%s)\n  %s",
+			h.ID, who, h.Platform, h.RequestedAt, truncate(h.Reason, 120)))
+	}
+	lines = append(lines, "\nUse /reply <id> <text> or /release <id>.")
+	b.reply(msg.Chat.ID, strings.Join(lines, "\n"))
+}
+
+func (b *Bot) cmdReplyHandover(msg *tgMessage, args string) {
+	id, text, _ := strings.Cut(args, " ")
+	text = strings.TrimSpace(text)
+	if id == "" || text == "" {
+		b.reply(msg.Chat.ID, "Usage: /reply <handover-id> <text>")
+		return
+	}
+	delivered, err := b.rt.ReplyHandover(id, text)
+	if err != nil {
+		b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	if !delivered {
+		b.reply(msg.Chat.ID, fmt.Sprintf("Recorded on %s (this channel cannot receive replies).", id))
+		return
+	}
+	b.reply(msg.Chat.ID, fmt.Sprintf("Sent to %s.", id))
+}
+
+func (b *Bot) cmdReleaseHandover(msg *tgMessage, args string) {
+	if args == "" {
+		b.reply(msg.Chat.ID, "Usage: /release <handover-id>")
+		return
+	}
+	if err := b.rt.ReleaseHandover(args); err != nil {
+		b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	b.reply(msg.Chat.ID, fmt.Sprintf("Released %s — agents are back on.", args))
+}
+
+// --- /faq ---
+
+func (b *Bot) cmdFAQ(msg *tgMessage, args string) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+	usage := "Usage:\n/faq - list entries\n/faq add <question> | <answer>\n/faq edit <id> <question> | <answer>\n/faq rm <id>\n/faq suggest"
+
+	switch sub {
+	case "", "list":
+		list, err := b.rt.ListFAQ()
+		if err != nil {
+			b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+			return
+		}
+		if len(list) == 0 {
+			b.reply(msg.Chat.ID, "No FAQ entries.\n\n"+usage)
+			return
+		}
+		var lines []string
+		lines = append(lines, fmt.Sprintf("FAQ (%d):", len(list)))
+		for _, e := range list {
+			lines = append(lines, fmt.Sprintf("#%d (%d hits) %s\n  → %s",
+				e.ID, e.Hits, truncate(e.Question, 80), truncate(e.Answer, 120)))
+		}
+		b.reply(msg.Chat.ID, strings.Join(lines, "\n"))
+
+	case "add":
+		q, a, ok := strings.Cut(rest, "|")
+		if !ok {
+			b.reply(msg.Chat.ID, usage)
+			return
+		}
+		id, err := b.rt.AddFAQ(q, a)
+		if err != nil {
+			b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+			return
+		}
+		b.reply(msg.Chat.ID, fmt.Sprintf("Added FAQ #%d.", id))
+
+	case "edit":
+		idStr, qa, _ := strings.Cut(rest, " ")
+		id, err := strconv.Atoi(idStr)
+		q, a, ok := strings.Cut(qa, "|")
+		if err != nil || !ok {
+			b.reply(msg.Chat.ID, usage)
+			return
+		}
+		if err := b.rt.UpdateFAQ(id, q, a); err != nil {
+			b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+			return
+		}
+		b.reply(msg.Chat.ID, fmt.Sprintf("Updated FAQ #%d.", id))
+
+	case "rm", "delete":
+		id, err := strconv.Atoi(rest)
+		if err != nil {
+			b.reply(msg.Chat.ID, usage)
+			return
+		}
+		if err := b.rt.DeleteFAQ(id); err != nil {
+			b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+			return
+		}
+		b.reply(msg.Chat.ID, fmt.Sprintf("Removed FAQ #%d.", id))
+
+	case "suggest":
+		sugg, err := b.rt.FAQSuggestions()
+		if err != nil {
+			b.reply(msg.Chat.ID, fmt.Sprintf("Error: %v", err))
+			return
+		}
+		if len(sugg) == 0 {
+			b.reply(msg.Chat.ID, "No repeated unanswered questions.")
+			return
+		}
+		var lines []string
+		lines = append(lines, "Asked repeatedly without a canned answer:")
+		for _, sg := range sugg {
+			lines = append(lines, fmt.Sprintf("• (%dx) %s", sg.Count, truncate(sg.Question, 120)))
+		}
+		lines = append(lines, "\nAdd one with /faq add <question> | <answer>.")
+		b.reply(msg.Chat.ID, strings.Join(lines, "\n"))
+
+	default:
+		b.reply(msg.Chat.ID, usage)
+	}
+}
+
 // --- /trust ---
 
 func (b *Bot) cmdTrust(msg *tgMessage) {
@@ -1096,6 +1410,9 @@ func (b *Bot) cmdTrust(msg *tgMessage) {
 		if s.PromoteReady {
 			line += fmt.Sprintf(" -> %s ready", s.NextLevel)
 		}
+		if s.ToolViolations > 0 {
+			line += fmt.Sprintf(" [%d tool violations, 7d]", s.ToolViolations)
+		}
 		lines = append(lines, line)
 	}
 	b.reply(msg.Chat.ID, strings.Join(lines, "\n"))
@@ -1173,6 +1490,18 @@ func (b *Bot) handleCallback(ctx context.Context, cq *tgCallbackQuery) {
 		}()
 		return
 
+	case "fb_up", "fb_down":
+		score := 1
+		if action == "fb_down" {
+			score = -1
+		}
+		if err := b.rt.RecordFeedback(id, score, "telegram", cq.From.decider()); err != nil {
+			b.answerCallback(cq.ID, "Feedback failed: "+err.Error())
+			return
+		}
+		b.answerCallback(cq.ID, "Thanks for the feedback")
+		return
+
 	case "confirm_dispatch":
 		b.pendingMu.Lock()
 		pe, ok := b.pendingEstimates[id]
@@ -1242,7 +1571,7 @@ func (b *Bot) handleCallback(ctx context.Context, cq *tgCallbackQuery) {
 	case "gate_approve":
 		b.answerCallback(cq.ID, "Approved")
 		if b.approvalGate != nil {
-			b.approvalGate.handleGateCallback(id, true)
+			b.approvalGate.handleGateCallback(id, true, cq.From.decider())
 		}
 		return
 	case "gate_always":
@@ -1250,7 +1579,7 @@ func (b *Bot) handleCallback(ctx context.Context, cq *tgCallbackQuery) {
 		alwaysParts := strings.SplitN(id, ":", 2)
 		if len(alwaysParts) == 2 && b.approvalGate != nil {
 			b.approvalGate.AutoApprove(alwaysParts[1])
-			b.approvalGate.handleGateCallback(alwaysParts[0], true)
+			b.approvalGate.handleGateCallback(alwaysParts[0], true, cq.From.decider())
 			b.answerCallback(cq.ID, "Always approved: "+alwaysParts[1])
 		} else {
 			b.answerCallback(cq.ID, "Approved")
@@ -1259,7 +1588,7 @@ func (b *Bot) handleCallback(ctx context.Context, cq *tgCallbackQuery) {
 	case "gate_reject":
 		b.answerCallback(cq.ID, "Rejected")
 		if b.approvalGate != nil {
-			b.approvalGate.handleGateCallback(id, false)
+			b.approvalGate.handleGateCallback(id, false, cq.From.decider())
 		}
 		return
 	}
@@ -1504,15 +1833,21 @@ type tgApprovalGate struct {
 	bot          *Bot
 	chatID       int64
 	mu           sync.Mutex
-	pending      map[string]chan bool // requestID → response channel
-	autoApproved map[string]bool     // tool name → always approved
+	pending      map[string]chan gateDecision // requestID → response channel
+	autoApproved map[string]bool              // tool name → always approved
+}
+
+// gateDecision is a button press on an approval request.
+type gateDecision struct {
+	approved bool
+	by       string
 }
 
 func newTGApprovalGate(bot *Bot, chatID int64, autoApproveTools []string) *tgApprovalGate {
 	g := &tgApprovalGate{
 		bot:          bot,
 		chatID:       chatID,
-		pending:      make(map[string]chan bool),
+		pending:      make(map[string]chan gateDecision),
 		autoApproved: make(map[string]bool),
 	}
 	// Copy config-level auto-approve tools.
@@ -1536,7 +1871,7 @@ func (g *tgApprovalGate) IsAutoApproved(toolName string) bool {
 }
 
 func (g *tgApprovalGate) RequestApproval(ctx context.Context, req ApprovalRequest) (bool, error) {
-	ch := make(chan bool, 1)
+	ch := make(chan gateDecision, 1)
 	g.mu.Lock()
 	g.pending[req.ID] = ch
 	g.mu.Unlock()
@@ -1548,29 +1883,35 @@ func (g *tgApprovalGate) RequestApproval(ctx context.Context, req ApprovalReques
 
 	// Send message with approve/always/reject buttons.
 	text := fmt.Sprintf("Approval needed\n\nTool: %s\n%s", req.Tool, req.Summary)
-	keyboard := [][]InlineButton{{
-		{Text: "Approve", CallbackData: "gate_approve:" + req.ID},
-		{Text: "Always", CallbackData: "gate_always:" + req.ID + ":" + req.Tool},
-		{Text: "Reject", CallbackData: "gate_reject:" + req.ID},
-	}}
-	g.bot.replyWithKeyboard(g.chatID, text, keyboard)
+	if req.Preview != "" {
+		text += "\n```\n" + req.Preview + "\n```"
+	}
+	row := []InlineButton{{Text: "Approve", CallbackData: "gate_approve:" + req.ID}}
+	if !req.External {
+		row = append(row, InlineButton{Text: "Always", CallbackData: "gate_always:" + req.ID + ":" + req.Tool})
+	}
+	row = append(row, InlineButton{Text: "Reject", CallbackData: "gate_reject:" + req.ID})
+	g.bot.replyWithKeyboard(g.chatID, text, [][]InlineButton{row})
 
 	select {
-	case approved := <-ch:
-		return approved, nil
+	case d := <-ch:
+		if req.OnDecision != nil {
+			req.OnDecision(d.approved, d.by)
+		}
+		return d.approved, nil
 	case <-ctx.Done():
 		return false, fmt.Errorf("approval timed out: %v", ctx.Err())
 	}
 }
 
-// handleGateCallback processes gate_approve/gate_reject callbacks.
-func (g *tgApprovalGate) handleGateCallback(reqID string, approved bool) {
+// handleGateCallback processes gate_approve/gate_reject callbacks from user by.
+func (g *tgApprovalGate) handleGateCallback(reqID string, approved bool, by string) {
 	g.mu.Lock()
 	ch, ok := g.pending[reqID]
 	g.mu.Unlock()
 	if ok {
 		select {
-		case ch <- approved:
+		case ch <- gateDecision{approved: approved, by: by}:
 		default:
 		}
 	}
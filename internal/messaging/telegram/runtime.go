@@ -50,6 +50,12 @@ type SmartDispatchResult struct {
 	Review   string
 }
 
+// GroupReply is one agent's answer in a group session.
+type GroupReply struct {
+	Agent string
+	Task  messaging.TaskResult
+}
+
 // CostEstimateTask is a single task estimate.
 type CostEstimateTask struct {
 	Model            string
@@ -81,6 +87,7 @@ type TrustStatus struct {
 	ConsecutiveSuccess int
 	PromoteReady       bool
 	NextLevel          TrustLevel
+	ToolViolations     int // tool calls refused by policy or budget in the last 7 days
 }
 
 // CronJobInfo holds display info for a cron job.
@@ -113,9 +120,12 @@ type StuckTask struct {
 
 // ApprovalRequest describes a pending approval request.
 type ApprovalRequest struct {
-	ID      string
-	Tool    string
-	Summary string
+	ID         string
+	Tool       string
+	Summary    string
+	Preview    string
+	External   bool
+	OnDecision func(approved bool, by string)
 }
 
 // ApprovalGate processes pre-execution approval requests.
@@ -142,9 +152,20 @@ type TelegramRuntime interface {
 	// CancelDispatch cancels any active dispatch.
 	CancelDispatch()
 
-	// RouteAndRun routes a prompt to an agent and runs the task.
+	// RouteAndRun routes a prompt to an agent and runs the task. chatID and
+	// userID identify the sender for the routing table.
 	// Returns the full SmartDispatchResult.
-	RouteAndRun(ctx context.Context, prompt, source, sessionID, sessionCtx string) *SmartDispatchResult
+	RouteAndRun(ctx context.Context, prompt, source, chatID, userID, sessionID, sessionCtx string) *SmartDispatchResult
+
+	// GroupCommand starts, shows or ends the chat's group session and
+	// returns the reply.
+	GroupCommand(chatID int64, args string) string
+
+	// InGroup reports whether the chat has a group session.
+	InGroup(chatID int64) bool
+
+	// AnswerGroup lets the agents of the chat's group session answer text.
+	AnswerGroup(ctx context.Context, chatID int64, text string) []GroupReply
 
 	// RunAsk runs a single task with the default agent.
 	RunAsk(ctx context.Context, prompt, sessionID, sessionCtx string) messaging.TaskResult
@@ -273,6 +294,90 @@ type TelegramRuntime interface {
 
 	// RerouteTask re-dispatches a previously failed task via smart dispatch.
 	RerouteTask(ctx context.Context, taskID string) (*SmartDispatchResult, error)
+
+	// OpenHandovers returns conversations currently handed to the owner.
+	OpenHandovers() ([]HandoverInfo, error)
+
+	// ReplyHandover sends the owner's reply into a handed-over conversation.
+	// delivered is false when the platform cannot receive replies (webhooks).
+	ReplyHandover(id, text string) (delivered bool, err error)
+
+	// ReleaseHandover hands a conversation back to the agents.
+	ReleaseHandover(id string) error
+
+	// AnswerFAQ returns a canned answer when text matches an FAQ entry.
+	AnswerFAQ(ctx context.Context, text string) (answer string, ok bool)
+
+	// ListFAQ returns FAQ entries, most used first.
+	ListFAQ() ([]FAQInfo, error)
+
+	// AddFAQ stores a new FAQ entry and returns its ID.
+	AddFAQ(question, answer string) (int, error)
+
+	// UpdateFAQ replaces an entry's question and answer.
+	UpdateFAQ(id int, question, answer string) error
+
+	// DeleteFAQ removes an FAQ entry.
+	DeleteFAQ(id int) error
+
+	// FAQSuggestions returns repeated unanswered questions worth an entry.
+	FAQSuggestions() ([]FAQSuggestion, error)
+
+	// CollectLinks saves the links in a chat message to the reading list
+	// when read-later auto-save is on.
+	CollectLinks(text string)
+
+	// UnfurlTarget returns the link when text is a bare URL that should be
+	// summarized in this chat.
+	UnfurlTarget(chatID int64, text string) (link string, ok bool)
+
+	// UnfurlLink fetches and summarizes a link and returns the reply text.
+	UnfurlLink(ctx context.Context, chatID int64, link string) (string, error)
+
+	// MatchIntent returns the run function of the intent shortcut text
+	// matches, which replies without an agent.
+	MatchIntent(text string) (run func(ctx context.Context) string, ok bool)
+
+	// IntentsCommand lists intent shortcuts and learns suggested ones, and
+	// returns the reply.
+	IntentsCommand(args string) string
+
+	// EmergencyCommand shows, engages or releases the emergency stop and
+	// returns the reply.
+	EmergencyCommand(args string) string
+
+	// TranslateCommand shows or changes the chat's auto-translation and
+	// returns the reply.
+	TranslateCommand(chatID int64, args string) string
+
+	// TranslateIn translates a message for the agents when the chat is
+	// auto-translated. back translates the reply into the user's language;
+	// it is nil when nothing was translated.
+	TranslateIn(ctx context.Context, chatID int64, text string) (agentText string, back func(ctx context.Context, reply string) string)
+}
+
+// FAQInfo describes one FAQ entry.
+type FAQInfo struct {
+	ID       int
+	Question string
+	Answer   string
+	Hits     int
+}
+
+// FAQSuggestion is a question asked repeatedly without a canned answer.
+type FAQSuggestion struct {
+	Question string
+	Count    int
+}
+
+// HandoverInfo describes a conversation handed to the owner.
+type HandoverInfo struct {
+	ID          string
+	Platform    string
+	ChatID      string
+	UserName    string
+	Reason      string
+	RequestedAt string
 }
 
 // RetryResult holds the result of a retried task.
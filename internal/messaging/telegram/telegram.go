@@ -8,7 +8,11 @@ type Config struct {
 	ChatID      int64  `json:"chatID,omitempty"`      // allowed chat ID (0 = unrestricted)
 	PollTimeout int    `json:"pollTimeout,omitempty"` // long-poll timeout in seconds (default 30)
 	WebhookMode bool   `json:"webhookMode,omitempty"` // use webhook instead of polling
-	WebhookPath string `json:"webhookPath,omitempty"` // webhook URL path
+	WebhookPath string `json:"webhookPath,omitempty"` // webhook URL path (default "/api/telegram/webhook")
+	// WebhookURL is the public HTTPS base URL Telegram posts updates to, e.g.
+	// "https://tetora.example.com"; the webhook path is appended to it.
+	WebhookURL    string `json:"webhookURL,omitempty"`
+	WebhookSecret string `json:"webhookSecret,omitempty"` // secret token header ($ENV_VAR); random per start when empty
 }
 
 // PollTimeoutOrDefault returns the poll timeout or default 30 seconds.
@@ -18,3 +22,11 @@ func (c Config) PollTimeoutOrDefault() int {
 	}
 	return 30
 }
+
+// WebhookPathOrDefault returns the configured webhook path or default "/api/telegram/webhook".
+func (c Config) WebhookPathOrDefault() string {
+	if c.WebhookPath != "" {
+		return c.WebhookPath
+	}
+	return "/api/telegram/webhook"
+}
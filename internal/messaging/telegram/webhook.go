@@ -0,0 +1,285 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// --- Webhook Mode ---
+
+var (
+	// webhookCheckInterval is how often a registered webhook's delivery
+	// status is checked with getWebhookInfo.
+	webhookCheckInterval = 5 * time.Minute
+	// webhookRetryInterval is how long the bot long-polls after the webhook
+	// failed before it tries to register it again.
+	webhookRetryInterval = 15 * time.Minute
+)
+
+// apiError is the error part of a Bot API response.
+type apiError struct {
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// retryAfter returns how long Telegram asked to wait, or def.
+func (e apiError) retryAfter(def time.Duration) time.Duration {
+	if e.Parameters.RetryAfter > 0 {
+		return time.Duration(e.Parameters.RetryAfter) * time.Second
+	}
+	return def
+}
+
+type webhookInfo struct {
+	URL                string `json:"url"`
+	PendingUpdateCount int    `json:"pending_update_count"`
+	LastErrorDate      int64  `json:"last_error_date"`
+	LastErrorMessage   string `json:"last_error_message"`
+}
+
+// Run receives updates until ctx is cancelled. Without webhookMode it long-polls.
+// With webhookMode it registers the webhook and handles what WebhookHandler
+// receives. When the webhook cannot be registered, or Telegram reports that it
+// cannot deliver to the public URL, the bot removes the webhook and polls for
+// webhookRetryInterval before trying again.
+func (b *Bot) Run(ctx context.Context) {
+	if !b.cfg.WebhookMode {
+		// getUpdates is refused while a webhook from an earlier run is set.
+		if err := b.deleteWebhook(ctx); err != nil {
+			b.rt.LogWarn("telegram deleteWebhook failed", "error", err)
+		}
+		b.PollLoop(ctx)
+		return
+	}
+
+	go b.serveUpdates(ctx)
+	for ctx.Err() == nil {
+		if err := b.registerWebhook(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.rt.LogWarn("telegram webhook unavailable, polling instead", "error", err, "retryIn", webhookRetryInterval)
+			b.pollFor(ctx, webhookRetryInterval)
+			continue
+		}
+		b.rt.LogInfo("telegram webhook registered", "url", b.webhookURL())
+		reason := b.watchWebhook(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.rt.LogWarn("telegram webhook failing, polling instead", "reason", reason, "retryIn", webhookRetryInterval)
+		if err := b.deleteWebhook(ctx); err != nil {
+			b.rt.LogWarn("telegram deleteWebhook failed", "error", err)
+		}
+		b.pollFor(ctx, webhookRetryInterval)
+	}
+}
+
+// WebhookHandler receives updates posted by Telegram. Register it at
+// cfg.WebhookPathOrDefault(). Updates are queued and handled in order by Run.
+func (b *Bot) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Lets registerWebhook recognize this handler behind the public URL.
+	w.Header().Set("X-Tetora-Webhook", "telegram")
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.webhookSecret)) != 1 {
+		b.rt.LogWarn("telegram webhook invalid secret token", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var u tgUpdate
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&u); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	select {
+	case b.updates <- u:
+		w.WriteHeader(http.StatusOK)
+	default:
+		// Telegram redelivers the update later.
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}
+}
+
+// serveUpdates handles queued webhook updates one at a time, as PollLoop does.
+func (b *Bot) serveUpdates(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-b.updates:
+			b.handleUpdate(ctx, u)
+		}
+	}
+}
+
+func (b *Bot) webhookURL() string {
+	return strings.TrimRight(b.cfg.WebhookURL, "/") + b.cfg.WebhookPathOrDefault()
+}
+
+// registerWebhook checks that the public URL reaches this handler and points
+// the bot's webhook at it.
+func (b *Bot) registerWebhook(ctx context.Context) error {
+	if !strings.HasPrefix(b.cfg.WebhookURL, "https://") {
+		return fmt.Errorf("webhookURL must be an https URL")
+	}
+	if err := b.probeWebhook(ctx); err != nil {
+		return err
+	}
+	return b.call(ctx, "setWebhook", map[string]any{
+		"url":             b.webhookURL(),
+		"secret_token":    b.webhookSecret,
+		"allowed_updates": []string{"message", "callback_query"},
+		"max_connections": 10,
+	}, nil)
+}
+
+// probeWebhook requests the public webhook URL and expects this handler's
+// marker header, so a down tunnel or proxy is caught before registering.
+func (b *Bot) probeWebhook(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.webhookURL(), nil)
+	if err != nil {
+		return fmt.Errorf("public URL: %w", err)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("public URL unreachable: %w", stripURL(err))
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Tetora-Webhook") != "telegram" {
+		return fmt.Errorf("public URL does not reach the webhook handler (HTTP %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// watchWebhook checks the webhook every webhookCheckInterval and returns why
+// it should be given up, or "" when ctx is cancelled.
+func (b *Bot) watchWebhook(ctx context.Context) string {
+	t := time.NewTicker(webhookCheckInterval)
+	defer t.Stop()
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-t.C:
+		}
+		var info webhookInfo
+		if err := b.call(ctx, "getWebhookInfo", map[string]any{}, &info); err != nil {
+			b.rt.LogWarn("telegram getWebhookInfo failed", "error", err)
+			continue
+		}
+		if info.URL != b.webhookURL() {
+			return "webhook was removed or replaced"
+		}
+		// last_error_date has second precision.
+		if info.PendingUpdateCount > 0 && info.LastErrorDate >= since.Unix() {
+			return "delivery failing: " + info.LastErrorMessage
+		}
+		since = time.Now()
+	}
+}
+
+// deleteWebhook removes the webhook, keeping undelivered updates for getUpdates.
+func (b *Bot) deleteWebhook(ctx context.Context) error {
+	return b.call(ctx, "deleteWebhook", map[string]any{"drop_pending_updates": false}, nil)
+}
+
+// pollFor long-polls for d, or until ctx is cancelled.
+func (b *Bot) pollFor(ctx context.Context, d time.Duration) {
+	pctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	b.PollLoop(pctx)
+}
+
+// call invokes a Bot API method and decodes its result into result (if not
+// nil). Rate-limited calls are retried after the wait Telegram asks for.
+func (b *Bot) call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			fmt.Sprintf("%s/bot%s/%s", b.apiBase, b.token, method), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, stripURL(err))
+		}
+		var r struct {
+			OK     bool            `json:"ok"`
+			Result json.RawMessage `json:"result"`
+			apiError
+		}
+		err = json.NewDecoder(resp.Body).Decode(&r)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s: HTTP %d: %w", method, resp.StatusCode, err)
+		}
+		if r.OK {
+			if result != nil {
+				return json.Unmarshal(r.Result, result)
+			}
+			return nil
+		}
+		if r.ErrorCode == http.StatusTooManyRequests && attempt < 3 {
+			wait := r.retryAfter(time.Second)
+			b.rt.LogWarn("telegram rate limited", "method", method, "retryIn", wait)
+			if !sleepCtx(ctx, wait) {
+				return ctx.Err()
+			}
+			continue
+		}
+		return fmt.Errorf("%s: %d %s", method, r.ErrorCode, r.Description)
+	}
+}
+
+// stripURL drops the request URL, which holds the bot token, from err.
+func stripURL(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err
+	}
+	return err
+}
+
+// sleepCtx waits for d and reports false if ctx was cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
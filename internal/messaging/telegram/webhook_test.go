@@ -0,0 +1,211 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// quietRuntime implements what the bot needs to start and to drop updates
+// from other chats.
+type quietRuntime struct {
+	TelegramRuntime
+}
+
+func (quietRuntime) ApprovalGatesEnabled() bool             { return false }
+func (quietRuntime) LogInfo(string, ...interface{})         {}
+func (quietRuntime) LogWarn(string, ...interface{})         {}
+func (quietRuntime) LogError(string, error, ...interface{}) {}
+
+// fakeAPI serves the Bot API methods used by webhook mode and the bot's
+// webhook path, recording the methods called.
+type fakeAPI struct {
+	mu      sync.Mutex
+	calls   []string
+	polled  chan struct{}
+	info    webhookInfo
+	probeOK bool
+	limited int // setWebhook calls to answer with 429
+}
+
+func (f *fakeAPI) handler(b **Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/telegram/webhook" {
+			if !f.probeOK {
+				http.NotFound(w, r)
+				return
+			}
+			(*b).WebhookHandler(w, r)
+			return
+		}
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		f.mu.Lock()
+		f.calls = append(f.calls, method)
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch method {
+		case "setWebhook":
+			if f.limited > 0 {
+				f.limited--
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`))
+				return
+			}
+			var p struct {
+				URL    string `json:"url"`
+				Secret string `json:"secret_token"`
+			}
+			json.NewDecoder(r.Body).Decode(&p)
+			f.mu.Lock()
+			f.info.URL = p.URL
+			f.mu.Unlock()
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		case "getWebhookInfo":
+			f.mu.Lock()
+			info := f.info
+			f.mu.Unlock()
+			if info.LastErrorMessage != "" {
+				info.LastErrorDate = time.Now().Unix()
+			}
+			json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": info})
+		case "getUpdates":
+			select {
+			case f.polled <- struct{}{}:
+			default:
+			}
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		default:
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		}
+	}
+}
+
+func (f *fakeAPI) methods() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, m := range f.calls {
+		if len(out) == 0 || out[len(out)-1] != m {
+			out = append(out, m)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+func newWebhookBot(t *testing.T, api *fakeAPI) *Bot {
+	t.Helper()
+	var b *Bot
+	srv := httptest.NewTLSServer(api.handler(&b))
+	t.Cleanup(srv.Close)
+	b = NewBot(Config{BotToken: "tok", ChatID: 1, WebhookMode: true, WebhookURL: srv.URL}, quietRuntime{})
+	b.apiBase = srv.URL
+	b.client = srv.Client()
+	return b
+}
+
+func runUntilPolled(t *testing.T, b *Bot, api *fakeAPI) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-api.polled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("bot never fell back to polling; calls %s", api.methods())
+	}
+	cancel()
+	<-done
+}
+
+func TestWebhookFallsBackWhenDeliveryFails(t *testing.T) {
+	defer func(c, r time.Duration) { webhookCheckInterval, webhookRetryInterval = c, r }(webhookCheckInterval, webhookRetryInterval)
+	webhookCheckInterval, webhookRetryInterval = 20*time.Millisecond, time.Hour
+
+	api := &fakeAPI{polled: make(chan struct{}, 1), probeOK: true}
+	api.info = webhookInfo{PendingUpdateCount: 3, LastErrorMessage: "Connection timed out"}
+	b := newWebhookBot(t, api)
+	runUntilPolled(t, b, api)
+	if got := api.methods(); got != "setWebhook,getWebhookInfo,deleteWebhook,getUpdates" {
+		t.Errorf("calls %s", got)
+	}
+}
+
+func TestWebhookPollsWhenPublicURLUnreachable(t *testing.T) {
+	api := &fakeAPI{polled: make(chan struct{}, 1)}
+	b := newWebhookBot(t, api)
+	runUntilPolled(t, b, api)
+	if got := api.methods(); got != "getUpdates" {
+		t.Errorf("calls %s", got)
+	}
+}
+
+func TestCallWaitsOutRateLimit(t *testing.T) {
+	api := &fakeAPI{probeOK: true, limited: 1}
+	b := newWebhookBot(t, api)
+	start := time.Now()
+	if err := b.registerWebhook(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < time.Second {
+		t.Error("retried before retry_after")
+	}
+	if got := api.methods(); got != "setWebhook" || len(api.calls) != 2 {
+		t.Errorf("calls %v", api.calls)
+	}
+	if api.info.URL != b.webhookURL() {
+		t.Errorf("registered %q", api.info.URL)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	b := NewBot(Config{BotToken: "tok", WebhookSecret: "s3cret"}, quietRuntime{})
+	b.updates = make(chan tgUpdate, 1)
+	post := func(secret string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/telegram/webhook", strings.NewReader(`{"update_id":7,"message":{"message_id":1,"chat":{"id":5},"text":"hi"}}`))
+		if secret != "" {
+			r.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		}
+		w := httptest.NewRecorder()
+		b.WebhookHandler(w, r)
+		return w.Code
+	}
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Errorf("no secret: %d", code)
+	}
+	if code := post("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: %d", code)
+	}
+	if code := post("s3cret"); code != http.StatusOK {
+		t.Errorf("valid: %d", code)
+	}
+	if u := <-b.updates; u.UpdateID != 7 || u.Message.Text != "hi" {
+		t.Errorf("queued %+v", u)
+	}
+	b.updates <- tgUpdate{}
+	if code := post("s3cret"); code != http.StatusServiceUnavailable {
+		t.Errorf("full queue: %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	b.WebhookHandler(w, httptest.NewRequest(http.MethodGet, "/api/telegram/webhook", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("X-Tetora-Webhook") != "telegram" {
+		t.Errorf("probe: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestNewBotGeneratesWebhookSecret(t *testing.T) {
+	a := NewBot(Config{}, quietRuntime{})
+	b := NewBot(Config{}, quietRuntime{})
+	if len(a.webhookSecret) != 64 || a.webhookSecret == b.webhookSecret {
+		t.Errorf("secrets %q %q", a.webhookSecret, b.webhookSecret)
+	}
+}
@@ -20,6 +20,8 @@ type Config struct {
 	Filter   string `json:"filter,omitempty"`   // simple condition: "payload.action == 'opened'"
 	Workflow string `json:"workflow,omitempty"` // workflow name to trigger instead of dispatch
 	Enabled  *bool  `json:"enabled,omitempty"`  // default true
+
+	Transform map[string]string `json:"transform,omitempty"` // variable name → JMESPath or jq-style expression, as {{vars.name}}
 }
 
 // IsEnabled returns whether the webhook is enabled (default true).
@@ -67,31 +69,76 @@ func VerifyHMACSHA256(body []byte, secret, signatureHex string) bool {
 // ExpandTemplate replaces {{payload.xxx}} and {{payload.xxx.yyy}} placeholders
 // with values from the parsed JSON payload.
 func ExpandTemplate(template string, payload map[string]any) string {
-	re := regexp.MustCompile(`\{\{payload\.([a-zA-Z0-9_.]+)\}\}`)
-	return re.ReplaceAllStringFunc(template, func(match string) string {
-		// Extract the path: "payload.pull_request.title" -> "pull_request.title"
-		path := match[10 : len(match)-2] // strip "{{payload." and "}}"
-		val := GetNestedValue(payload, path)
+	return ExpandTemplateVars(template, payload, nil)
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{(payload|vars)\.([a-zA-Z0-9_.]+)\}\}`)
+
+// ExpandTemplateVars is ExpandTemplate with {{vars.xxx}} placeholders for the
+// variables of the transformation stage as well.
+func ExpandTemplateVars(template string, payload, vars map[string]any) string {
+	return templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		m := templatePlaceholder.FindStringSubmatch(match)
+		src := payload
+		if m[1] == "vars" {
+			src = vars
+		}
+		val := GetNestedValue(src, m[2])
 		if val == nil {
 			return match // keep original if not found
 		}
-		switch v := val.(type) {
-		case string:
-			return v
-		case float64:
-			if v == float64(int(v)) {
-				return fmt.Sprintf("%d", int(v))
-			}
-			return fmt.Sprintf("%g", v)
-		case bool:
-			return fmt.Sprintf("%v", v)
-		default:
-			b, _ := json.Marshal(v)
-			return string(b)
+		return FormatValue(val)
+	})
+}
+
+var responsePlaceholder = regexp.MustCompile(`\{\{(result|payload|vars)\.([a-zA-Z0-9_.]+)\}\}`)
+
+// RenderResponse expands {{result.xxx}}, {{payload.xxx}} and {{vars.xxx}}
+// placeholders in the response template of a sync webhook. Missing values
+// expand to "". With escapeJSON, values are escaped to sit inside a JSON
+// string literal, so a template like {"answer": "{{result.output}}"} stays
+// valid JSON.
+func RenderResponse(tmpl string, payload, vars, result map[string]any, escapeJSON bool) string {
+	return responsePlaceholder.ReplaceAllStringFunc(tmpl, func(match string) string {
+		m := responsePlaceholder.FindStringSubmatch(match)
+		src := payload
+		switch m[1] {
+		case "result":
+			src = result
+		case "vars":
+			src = vars
+		}
+		val := GetNestedValue(src, m[2])
+		if val == nil {
+			return ""
 		}
+		s := FormatValue(val)
+		if escapeJSON {
+			b, _ := json.Marshal(s)
+			s = string(b[1 : len(b)-1])
+		}
+		return s
 	})
 }
 
+// FormatValue renders a decoded JSON value for a template.
+func FormatValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		if v == float64(int(v)) {
+			return fmt.Sprintf("%d", int(v))
+		}
+		return fmt.Sprintf("%g", v)
+	case bool:
+		return fmt.Sprintf("%v", v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
 // GetNestedValue retrieves a value from a nested map using dot notation.
 func GetNestedValue(m map[string]any, path string) any {
 	parts := strings.Split(path, ".")
@@ -112,6 +159,24 @@ func GetNestedValue(m map[string]any, path string) any {
 // EvaluateFilter checks if a payload matches a simple filter expression.
 // Supported: "payload.key == 'value'", "payload.key != 'value'", "payload.key" (truthy check).
 func EvaluateFilter(filter string, payload map[string]any) bool {
+	return EvaluateFilterVars(filter, payload, nil)
+}
+
+// EvaluateFilterVars is EvaluateFilter where keys may also name a variable of
+// the transformation stage, as "vars.key". A filter starting with "." is a
+// jq-style transform expression evaluated against the payload; it matches
+// when its result is truthy and it does not fail.
+func EvaluateFilterVars(filter string, payload, vars map[string]any) bool {
+	if strings.HasPrefix(strings.TrimSpace(filter), ".") {
+		v, err := Query(filter, payload)
+		return err == nil && truthy(v)
+	}
+	lookup := func(key string) any {
+		if name, ok := strings.CutPrefix(key, "vars."); ok {
+			return GetNestedValue(vars, name)
+		}
+		return GetNestedValue(payload, strings.TrimPrefix(key, "payload."))
+	}
 	filter = strings.TrimSpace(filter)
 	if filter == "" {
 		return true // no filter = accept all
@@ -124,10 +189,7 @@ func EvaluateFilter(filter string, payload map[string]any) bool {
 			val := strings.TrimSpace(parts[1])
 			val = strings.Trim(val, "'\"")
 
-			// Strip "payload." prefix.
-			key = strings.TrimPrefix(key, "payload.")
-
-			actual := GetNestedValue(payload, key)
+			actual := lookup(key)
 			actualStr := fmt.Sprintf("%v", actual)
 
 			if op == "==" {
@@ -138,9 +200,7 @@ func EvaluateFilter(filter string, payload map[string]any) bool {
 	}
 
 	// Truthy check: "payload.key"
-	key := strings.TrimPrefix(filter, "payload.")
-	val := GetNestedValue(payload, key)
-	return IsTruthy(val)
+	return IsTruthy(lookup(filter))
 }
 
 // IsTruthy returns whether a value is considered truthy.
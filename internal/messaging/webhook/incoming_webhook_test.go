@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"net/http/httptest"
 	"testing"
 )
@@ -134,6 +135,26 @@ func TestExpandTemplate_Missing(t *testing.T) {
 	}
 }
 
+func TestRenderResponse(t *testing.T) {
+	payload := map[string]any{"form": map[string]any{"id": "f-9"}}
+	result := map[string]any{"status": "success", "output": "Line 1\n\"quoted\"", "costUsd": 0.25}
+
+	got := RenderResponse(`{"form":"{{payload.form.id}}","answer":"{{result.output}}","missing":"{{result.nope}}"}`, payload, nil, result, true)
+	want := `{"form":"f-9","answer":"Line 1\n\"quoted\"","missing":""}`
+	if got != want {
+		t.Errorf("json: got %s, want %s", got, want)
+	}
+	var v map[string]string
+	if err := json.Unmarshal([]byte(got), &v); err != nil || v["answer"] != "Line 1\n\"quoted\"" {
+		t.Errorf("rendered JSON does not round-trip: %v %v", err, v)
+	}
+
+	got = RenderResponse("{{result.status}} ({{result.costUsd}}): {{result.output}}", payload, nil, result, false)
+	if got != "success (0.25): Line 1\n\"quoted\"" {
+		t.Errorf("text: got %q", got)
+	}
+}
+
 func TestExpandTemplate_Types(t *testing.T) {
 	payload := map[string]any{
 		"count":  float64(42),
@@ -0,0 +1,1497 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements the transformation stage of incoming webhooks: named
+// variables extracted from the payload with JMESPath expressions.
+//
+// Supported: fields (a.b, "quoted-name"), indexes and slices ([0], [-1],
+// [1:3]), projections ([*], [], *, [?cond]), multi-selects ([a, b],
+// {x: a, y: b}), pipes, comparisons (== != < <= > >=), && || !, literals
+// ('raw', `json`, numbers), @, the functions listed in exprFuncs and
+// conditionals: if c then a elif d then b else e end.
+//
+// An expression holding a path that starts with "." is read jq-style: .a.b,
+// .items[0], .items[].name, "double-quoted" string literals with
+// "\(.expr)" interpolation, and, or, not and the alternative a // b.
+
+// Transform evaluates each named expression against payload. Variables whose
+// expression fails are left out and reported in the returned error.
+func Transform(exprs map[string]string, payload map[string]any) (map[string]any, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(exprs))
+	for name := range exprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make(map[string]any, len(exprs))
+	var errs []error
+	for _, name := range names {
+		v, err := Query(exprs[name], payload)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transform %s: %w", name, err))
+			continue
+		}
+		vars[name] = v
+	}
+	return vars, errors.Join(errs...)
+}
+
+// ValidateTransform checks that every expression parses.
+func ValidateTransform(exprs map[string]string) error {
+	var errs []error
+	for name, src := range exprs {
+		if _, err := Compile(src); err != nil {
+			errs = append(errs, fmt.Errorf("transform %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateFilter checks that an expression filter, one starting with ".",
+// parses. Simple filters always do.
+func ValidateFilter(filter string) error {
+	if !strings.HasPrefix(strings.TrimSpace(filter), ".") {
+		return nil
+	}
+	_, err := Compile(filter)
+	return err
+}
+
+// Query compiles src and evaluates it against data.
+func Query(src string, data any) (any, error) {
+	e, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(data)
+}
+
+// Expr is a compiled transformation expression.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// Compile parses a JMESPath or jq-style expression.
+func Compile(src string) (*Expr, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks, jq: isJQ(toks)}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates the expression against data, a decoded JSON value.
+func (e *Expr) Eval(data any) (any, error) {
+	return e.root.eval(data)
+}
+
+func (e *Expr) String() string { return e.src }
+
+// isJQ reports whether toks read jq-style: they hold a path starting with
+// "." or an interpolated string, neither of which JMESPath has.
+func isJQ(toks []token) bool {
+	for i, t := range toks {
+		if t.kind == tokInterp {
+			return true
+		}
+		if t.kind != tokPunct || t.text != "." {
+			continue
+		}
+		if i == 0 {
+			return true
+		}
+		switch prev := toks[i-1]; prev.kind {
+		case tokOp:
+			return true
+		case tokPunct:
+			if strings.Contains("([{,:|!?", prev.text) {
+				return true
+			}
+		case tokIdent:
+			// "then .a", not "a.b".
+			if prev.pos+len(prev.text) < t.pos {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// --- Lexer ---
+
+type tokKind int
+
+const (
+	tokEOF     tokKind = iota
+	tokIdent           // name
+	tokQuoted          // "name" (a field, or a string literal in jq mode)
+	tokRaw             // 'raw string'
+	tokLiteral         // `json`
+	tokNumber          // 12, -1
+	tokPunct           // . [ ] { } ( ) , : | @ * ? !
+	tokOp              // == != < <= > >= && || //
+	tokInterp          // "text \(.expr) text", val []any of strings and interpSrc
+)
+
+// interpSrc is an expression inside a "\(...)" interpolation.
+type interpSrc struct {
+	src string
+	pos int
+}
+
+type token struct {
+	kind tokKind
+	text string
+	val  any // value of quoted, raw, literal and number tokens
+	pos  int
+}
+
+func lexExpr(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' && j+1 < len(src) && src[j+1] >= '0' && src[j+1] <= '9') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %d", src[i:j], i)
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[i:j], val: n, pos: i})
+			i = j
+		case c == '"':
+			var parts []any
+			lit := i + 1 // start of the current literal segment
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' && j+1 < len(src) && src[j+1] == '(' {
+					end := interpEnd(src, j+2)
+					if end < 0 {
+						return nil, fmt.Errorf("unterminated \\( at %d", j)
+					}
+					parts = append(parts, src[lit:j], interpSrc{src: src[j+2 : end], pos: j + 2})
+					j = end + 1
+					lit = j
+					continue
+				}
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			parts = append(parts, src[lit:j])
+			for k, part := range parts {
+				seg, ok := part.(string)
+				if !ok {
+					continue
+				}
+				var s string
+				if err := json.Unmarshal([]byte(`"`+seg+`"`), &s); err != nil {
+					return nil, fmt.Errorf("bad string at %d: %v", i, err)
+				}
+				parts[k] = s
+			}
+			if len(parts) == 1 {
+				toks = append(toks, token{kind: tokQuoted, text: src[i : j+1], val: parts[0], pos: i})
+			} else {
+				toks = append(toks, token{kind: tokInterp, text: src[i : j+1], val: parts, pos: i})
+			}
+			i = j + 1
+		case c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '\''; j++ {
+				if src[j] == '\\' && j+1 < len(src) && (src[j+1] == '\'' || src[j+1] == '\\') {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{kind: tokRaw, text: src[i : j+1], val: b.String(), pos: i})
+			i = j + 1
+		case c == '`':
+			j := strings.IndexByte(src[i+1:], '`')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated literal at %d", i)
+			}
+			raw := strings.TrimSpace(src[i+1 : i+1+j])
+			var v any
+			if err := json.Unmarshal([]byte(raw), &v); err != nil {
+				v = raw // legacy JMESPath: an unquoted string
+			}
+			toks = append(toks, token{kind: tokLiteral, text: src[i : i+j+2], val: v, pos: i})
+			i += j + 2
+		default:
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||", "//":
+					toks = append(toks, token{kind: tokOp, text: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			switch c {
+			case '<', '>':
+				toks = append(toks, token{kind: tokOp, text: string(c), pos: i})
+			case '.', '[', ']', '{', '}', '(', ')', ',', ':', '|', '@', '*', '?', '!':
+				toks = append(toks, token{kind: tokPunct, text: string(c), pos: i})
+			default:
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, text: "end of expression", pos: len(src)}), nil
+}
+
+// interpEnd returns the index of the ")" closing an interpolation whose
+// expression starts at from, skipping strings inside it, or -1.
+func interpEnd(src string, from int) int {
+	depth := 1
+	for k := from; k < len(src); k++ {
+		switch src[k] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return k
+			}
+		case '"', '\'':
+			q := src[k]
+			for k++; k < len(src) && src[k] != q; k++ {
+				if src[k] == '\\' {
+					k++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// --- Parser ---
+
+type exprParser struct {
+	toks []token
+	i    int
+	jq   bool
+}
+
+func (p *exprParser) peek() token { return p.toks[p.i] }
+func (p *exprParser) peekAt(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+func (p *exprParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+func (p *exprParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokOp) && t.text == text
+}
+func (p *exprParser) isWord(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == word
+}
+func (p *exprParser) expectWord(word string) error {
+	if !p.isWord(word) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, found %q at %d", word, t.text, t.pos)
+	}
+	p.next()
+	return nil
+}
+func (p *exprParser) expect(text string) error {
+	if !p.is(text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, found %q at %d", text, t.text, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	left, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("|") {
+		p.next()
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAlt() (exprNode, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("//") {
+		p.next()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = altNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("||") || p.jq && p.isWord("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("&&") || p.jq && p.isWord("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.is("!") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	left, err := p.parseChain()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp && t.text != "&&" && t.text != "||" && t.text != "//" {
+		p.next()
+		right, err := p.parseChain()
+		if err != nil {
+			return nil, err
+		}
+		return cmpNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// parseChain parses a primary expression followed by field, index and
+// projection steps.
+func (p *exprParser) parseChain() (exprNode, error) {
+	var base exprNode = currentNode{}
+	var steps []step
+	t := p.peek()
+	switch {
+	case t.kind == tokIdent && p.peekAt(1).kind == tokPunct && p.peekAt(1).text == "(":
+		fn, err := p.parseCall()
+		if err != nil {
+			return nil, err
+		}
+		base = fn
+	case p.atIf():
+		n, err := p.parseIf()
+		if err != nil {
+			return nil, err
+		}
+		base = n
+	case t.kind == tokIdent && p.jq && (t.text == "true" || t.text == "false" || t.text == "null"):
+		p.next()
+		return literalNode{map[string]any{"true": true, "false": false, "null": nil}[t.text]}, nil
+	case t.kind == tokIdent && p.jq && t.text == "not":
+		// jq's not negates its input: .draft | not.
+		p.next()
+		return notNode{currentNode{}}, nil
+	case t.kind == tokInterp:
+		if !p.jq {
+			return nil, fmt.Errorf("string interpolation at %d needs a jq-style expression", t.pos)
+		}
+		p.next()
+		return parseInterp(t.val.([]any))
+	case t.kind == tokIdent:
+		p.next()
+		steps = append(steps, step{kind: stepField, name: t.text})
+	case t.kind == tokQuoted && !p.jq:
+		p.next()
+		steps = append(steps, step{kind: stepField, name: t.val.(string)})
+	case t.kind == tokQuoted, t.kind == tokRaw, t.kind == tokLiteral, t.kind == tokNumber:
+		p.next()
+		return literalNode{t.val}, nil
+	case p.is("@"):
+		p.next()
+	case p.is("."):
+		// jq: "." alone is the input, ".a" a field and ".[0]" an index.
+		p.next()
+		if n := p.peek(); n.kind == tokIdent || n.kind == tokQuoted {
+			p.next()
+			name := n.text
+			if n.kind == tokQuoted {
+				name = n.val.(string)
+			}
+			steps = append(steps, step{kind: stepField, name: name})
+		}
+	case p.is("*"):
+		p.next()
+		steps = append(steps, step{kind: stepValues})
+	case p.is("["):
+		if !p.bracketIsStep() {
+			n, err := p.parseMultiList()
+			if err != nil {
+				return nil, err
+			}
+			base = n
+		}
+	case p.is("{"):
+		n, err := p.parseMultiHash()
+		if err != nil {
+			return nil, err
+		}
+		base = n
+	case p.is("("):
+		p.next()
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		base = n
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	for {
+		switch {
+		case p.is("."):
+			p.next()
+			n := p.peek()
+			switch {
+			case n.kind == tokIdent && p.peekAt(1).kind == tokPunct && p.peekAt(1).text == "(":
+				fn, err := p.parseCall()
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step{kind: stepSub, node: fn})
+			case n.kind == tokIdent:
+				p.next()
+				steps = append(steps, step{kind: stepField, name: n.text})
+			case n.kind == tokQuoted:
+				p.next()
+				steps = append(steps, step{kind: stepField, name: n.val.(string)})
+			case p.is("*"):
+				p.next()
+				steps = append(steps, step{kind: stepValues})
+			case p.is("{"):
+				h, err := p.parseMultiHash()
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step{kind: stepSub, node: h})
+			case p.is("[") && p.bracketIsStep():
+				// jq's .items.[0] is the same as .items[0].
+			case p.is("["):
+				l, err := p.parseMultiList()
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step{kind: stepSub, node: l})
+			default:
+				return nil, fmt.Errorf("unexpected %q after '.' at %d", n.text, n.pos)
+			}
+		case p.is("[") && p.bracketIsStep():
+			s, err := p.parseBracketStep()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+		default:
+			if len(steps) == 0 {
+				return base, nil
+			}
+			return chainNode{base: base, steps: steps}, nil
+		}
+	}
+}
+
+// atIf reports whether the cursor is at the keyword of a conditional. In
+// JMESPath, "if" is a field name unless an expression follows it.
+func (p *exprParser) atIf() bool {
+	if !p.isWord("if") {
+		return false
+	}
+	if p.jq {
+		return true
+	}
+	switch n := p.peekAt(1); n.kind {
+	case tokEOF, tokOp:
+		return false
+	case tokPunct:
+		return n.text == "(" || n.text == "!" || n.text == "@" || n.text == "{"
+	}
+	return true
+}
+
+// parseIf parses if c then a (elif d then b)* (else e)? end. Without else,
+// a conditional whose conditions all fail gives its input, as in jq.
+func (p *exprParser) parseIf() (exprNode, error) {
+	p.next() // if
+	n := ifNode{els: currentNode{}}
+	for {
+		cond, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectWord("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		n.conds = append(n.conds, cond)
+		n.bodies = append(n.bodies, body)
+		if !p.isWord("elif") {
+			break
+		}
+		p.next()
+	}
+	if p.isWord("else") {
+		p.next()
+		els, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		n.els = els
+	}
+	return n, p.expectWord("end")
+}
+
+// parseInterp compiles the parts of an interpolated string.
+func parseInterp(parts []any) (exprNode, error) {
+	var n interpNode
+	for _, part := range parts {
+		src, ok := part.(interpSrc)
+		if !ok {
+			n = append(n, literalNode{part})
+			continue
+		}
+		toks, err := lexExpr(src.src)
+		if err != nil {
+			return nil, fmt.Errorf("in \\( at %d: %w", src.pos, err)
+		}
+		sub := &exprParser{toks: toks, jq: true}
+		e, err := sub.parseExpr()
+		if err == nil && sub.peek().kind != tokEOF {
+			err = fmt.Errorf("unexpected %q at %d", sub.peek().text, sub.peek().pos)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("in \\( at %d: %w", src.pos, err)
+		}
+		n = append(n, e)
+	}
+	return n, nil
+}
+
+// bracketIsStep reports whether the "[" at the cursor starts an index, slice,
+// projection or filter rather than a multi-select list.
+func (p *exprParser) bracketIsStep() bool {
+	n := p.peekAt(1)
+	if n.kind == tokNumber {
+		return true
+	}
+	if n.kind == tokPunct && (n.text == "*" || n.text == "]" || n.text == "?" || n.text == ":") {
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseBracketStep() (step, error) {
+	p.next() // [
+	switch {
+	case p.is("]"):
+		p.next()
+		return step{kind: stepFlatten}, nil
+	case p.is("*"):
+		p.next()
+		return step{kind: stepProject}, p.expect("]")
+	case p.is("?"):
+		p.next()
+		cond, err := p.parseExpr()
+		if err != nil {
+			return step{}, err
+		}
+		return step{kind: stepFilter, node: cond}, p.expect("]")
+	}
+	var parts [3]*int
+	n := 0
+	for {
+		if t := p.peek(); t.kind == tokNumber {
+			p.next()
+			v := int(t.val.(float64))
+			parts[n] = &v
+		}
+		if p.is("]") {
+			p.next()
+			break
+		}
+		if err := p.expect(":"); err != nil {
+			return step{}, err
+		}
+		if n++; n > 2 {
+			return step{}, errors.New("too many ':' in slice")
+		}
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return step{}, errors.New("empty index")
+		}
+		return step{kind: stepIndex, index: *parts[0]}, nil
+	}
+	if parts[2] != nil && *parts[2] == 0 {
+		return step{}, errors.New("slice step cannot be 0")
+	}
+	return step{kind: stepSlice, slice: parts}, nil
+}
+
+func (p *exprParser) parseMultiList() (exprNode, error) {
+	p.next() // [
+	var items []exprNode
+	for {
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if p.is(",") {
+			p.next()
+			continue
+		}
+		return multiListNode(items), p.expect("]")
+	}
+}
+
+func (p *exprParser) parseMultiHash() (exprNode, error) {
+	p.next() // {
+	var h multiHashNode
+	for {
+		t := p.next()
+		var key string
+		switch t.kind {
+		case tokIdent:
+			key = t.text
+		case tokQuoted, tokRaw:
+			key = t.val.(string)
+		default:
+			return nil, fmt.Errorf("expected a key, found %q at %d", t.text, t.pos)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		h.keys = append(h.keys, key)
+		h.vals = append(h.vals, n)
+		if p.is(",") {
+			p.next()
+			continue
+		}
+		return h, p.expect("}")
+	}
+}
+
+func (p *exprParser) parseCall() (exprNode, error) {
+	name := p.next()
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s() at %d", name.text, name.pos)
+	}
+	p.next() // (
+	var args []exprNode
+	for !p.is(")") {
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(args) < fn.min || fn.max >= 0 && len(args) > fn.max {
+		return nil, fmt.Errorf("%s() takes %s, got %d", name.text, fn.arity(), len(args))
+	}
+	return callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+// --- Evaluation ---
+
+type exprNode interface {
+	eval(cur any) (any, error)
+}
+
+type currentNode struct{}
+
+func (currentNode) eval(cur any) (any, error) { return cur, nil }
+
+type literalNode struct{ v any }
+
+func (n literalNode) eval(any) (any, error) { return n.v, nil }
+
+type pipeNode struct{ left, right exprNode }
+
+func (n pipeNode) eval(cur any) (any, error) {
+	v, err := n.left.eval(cur)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.eval(v)
+}
+
+type orNode struct{ left, right exprNode }
+
+func (n orNode) eval(cur any) (any, error) {
+	v, err := n.left.eval(cur)
+	if err != nil || truthy(v) {
+		return v, err
+	}
+	return n.right.eval(cur)
+}
+
+// altNode is jq's a // b: b when a fails or gives null or false.
+type altNode struct{ left, right exprNode }
+
+func (n altNode) eval(cur any) (any, error) {
+	v, err := n.left.eval(cur)
+	if err == nil && v != nil && v != false {
+		return v, nil
+	}
+	return n.right.eval(cur)
+}
+
+type andNode struct{ left, right exprNode }
+
+func (n andNode) eval(cur any) (any, error) {
+	v, err := n.left.eval(cur)
+	if err != nil || !truthy(v) {
+		return v, err
+	}
+	return n.right.eval(cur)
+}
+
+type notNode struct{ n exprNode }
+
+func (n notNode) eval(cur any) (any, error) {
+	v, err := n.n.eval(cur)
+	return !truthy(v), err
+}
+
+type ifNode struct {
+	conds, bodies []exprNode
+	els           exprNode
+}
+
+func (n ifNode) eval(cur any) (any, error) {
+	for i, cond := range n.conds {
+		v, err := cond.eval(cur)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(v) {
+			return n.bodies[i].eval(cur)
+		}
+	}
+	return n.els.eval(cur)
+}
+
+// interpNode joins the parts of an interpolated string. Null parts render
+// as "", like missing template values.
+type interpNode []exprNode
+
+func (n interpNode) eval(cur any) (any, error) {
+	var b strings.Builder
+	for _, part := range n {
+		v, err := part.eval(cur)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			b.WriteString(FormatValue(v))
+		}
+	}
+	return b.String(), nil
+}
+
+type cmpNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n cmpNode) eval(cur any) (any, error) {
+	a, err := n.left.eval(cur)
+	if err != nil {
+		return nil, err
+	}
+	b, err := n.right.eval(cur)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(a, b), nil
+	case "!=":
+		return !reflect.DeepEqual(a, b), nil
+	}
+	var c int
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return nil, nil
+		}
+		c = cmpFloat(x, y)
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return nil, nil
+		}
+		c = strings.Compare(x, y)
+	default:
+		return nil, nil
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+type multiListNode []exprNode
+
+func (n multiListNode) eval(cur any) (any, error) {
+	if cur == nil {
+		return nil, nil
+	}
+	out := make([]any, len(n))
+	for i, e := range n {
+		v, err := e.eval(cur)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+type multiHashNode struct {
+	keys []string
+	vals []exprNode
+}
+
+func (n multiHashNode) eval(cur any) (any, error) {
+	if cur == nil {
+		return nil, nil
+	}
+	out := make(map[string]any, len(n.keys))
+	for i, k := range n.keys {
+		v, err := n.vals[i].eval(cur)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+type stepKind int
+
+const (
+	stepField   stepKind = iota
+	stepIndex            // [n]
+	stepSub              // .{...}, .[...], .fn()
+	stepSlice            // [a:b:c], a projection
+	stepProject          // [*]
+	stepFlatten          // []
+	stepValues           // *
+	stepFilter           // [?cond]
+)
+
+type step struct {
+	kind  stepKind
+	name  string
+	index int
+	slice [3]*int
+	node  exprNode
+}
+
+type chainNode struct {
+	base  exprNode
+	steps []step
+}
+
+func (n chainNode) eval(cur any) (any, error) {
+	v, err := n.base.eval(cur)
+	if err != nil {
+		return nil, err
+	}
+	return applySteps(v, n.steps)
+}
+
+// applySteps walks steps from v. A projection applies the following steps to
+// each element and drops the null results.
+func applySteps(v any, steps []step) (any, error) {
+	for i, s := range steps {
+		if v == nil {
+			return nil, nil
+		}
+		var elems []any
+		switch s.kind {
+		case stepField:
+			m, _ := v.(map[string]any)
+			v = m[s.name]
+			continue
+		case stepIndex:
+			l, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			idx := s.index
+			if idx < 0 {
+				idx += len(l)
+			}
+			if idx < 0 || idx >= len(l) {
+				return nil, nil
+			}
+			v = l[idx]
+			continue
+		case stepSub:
+			var err error
+			if v, err = s.node.eval(v); err != nil {
+				return nil, err
+			}
+			continue
+		case stepSlice:
+			l, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			elems = sliceList(l, s.slice)
+		case stepProject:
+			l, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			elems = l
+		case stepFlatten:
+			l, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			for _, e := range l {
+				if sub, ok := e.([]any); ok {
+					elems = append(elems, sub...)
+				} else {
+					elems = append(elems, e)
+				}
+			}
+		case stepValues:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, nil
+			}
+			for _, k := range sortedKeys(m) {
+				elems = append(elems, m[k])
+			}
+		case stepFilter:
+			l, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			for _, e := range l {
+				keep, err := s.node.eval(e)
+				if err != nil {
+					return nil, err
+				}
+				if truthy(keep) {
+					elems = append(elems, e)
+				}
+			}
+		}
+		// A later [] ends this projection and flattens its result, so
+		// a[].b[] is one flat list.
+		rest, after := steps[i+1:], []step(nil)
+		for j, s := range rest {
+			if s.kind == stepFlatten {
+				rest, after = rest[:j], rest[j:]
+				break
+			}
+		}
+		out := []any{}
+		for _, e := range elems {
+			r, err := applySteps(e, rest)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				out = append(out, r)
+			}
+		}
+		if after != nil {
+			return applySteps(out, after)
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func sliceList(l []any, parts [3]*int) []any {
+	n := len(l)
+	stepBy := 1
+	if parts[2] != nil {
+		stepBy = *parts[2]
+	}
+	clamp := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		lo, hi := 0, n
+		if stepBy < 0 {
+			lo, hi = -1, n-1
+		}
+		return max(lo, min(i, hi))
+	}
+	var out []any
+	if stepBy > 0 {
+		for i := clamp(parts[0], 0); i < clamp(parts[1], n); i += stepBy {
+			out = append(out, l[i])
+		}
+	} else {
+		for i := clamp(parts[0], n-1); i > clamp(parts[1], -1); i += stepBy {
+			out = append(out, l[i])
+		}
+	}
+	return out
+}
+
+// truthy follows JMESPath: false, null and empty strings, lists and objects
+// are false; everything else, including 0, is true.
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// --- Functions ---
+
+type exprFunc struct {
+	min, max int // max -1: variadic
+	call     func(args []any) (any, error)
+}
+
+func (f exprFunc) arity() string {
+	switch {
+	case f.max < 0:
+		return fmt.Sprintf("at least %d arguments", f.min)
+	case f.min == f.max:
+		return fmt.Sprintf("%d arguments", f.min)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.min, f.max)
+}
+
+type callNode struct {
+	name string
+	fn   exprFunc
+	args []exprNode
+}
+
+func (n callNode) eval(cur any) (any, error) {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(cur)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return v, nil
+}
+
+func argErr(i int, want string, got any) error {
+	return fmt.Errorf("argument %d must be %s, got %s", i+1, want, typeName(got))
+}
+
+func numbers(v any) ([]float64, bool) {
+	l, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]float64, len(l))
+	for i, e := range l {
+		if out[i], ok = e.(float64); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+func stringsOf(v any) ([]string, bool) {
+	l, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, len(l))
+	for i, e := range l {
+		if out[i], ok = e.(string); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// exprFuncs are the JMESPath built-ins supported in transforms.
+var exprFuncs map[string]exprFunc
+
+func init() {
+	num := func(f func(float64) float64) exprFunc {
+		return exprFunc{1, 1, func(a []any) (any, error) {
+			x, ok := a[0].(float64)
+			if !ok {
+				return nil, argErr(0, "a number", a[0])
+			}
+			return f(x), nil
+		}}
+	}
+	exprFuncs = map[string]exprFunc{
+		"abs":   num(math.Abs),
+		"ceil":  num(math.Ceil),
+		"floor": num(math.Floor),
+		"length": {1, 1, func(a []any) (any, error) {
+			switch x := a[0].(type) {
+			case string:
+				return float64(utf8.RuneCountInString(x)), nil
+			case []any:
+				return float64(len(x)), nil
+			case map[string]any:
+				return float64(len(x)), nil
+			}
+			return nil, argErr(0, "a string, array or object", a[0])
+		}},
+		"keys": {1, 1, func(a []any) (any, error) {
+			m, ok := a[0].(map[string]any)
+			if !ok {
+				return nil, argErr(0, "an object", a[0])
+			}
+			out := []any{}
+			for _, k := range sortedKeys(m) {
+				out = append(out, k)
+			}
+			return out, nil
+		}},
+		"values": {1, 1, func(a []any) (any, error) {
+			m, ok := a[0].(map[string]any)
+			if !ok {
+				return nil, argErr(0, "an object", a[0])
+			}
+			out := []any{}
+			for _, k := range sortedKeys(m) {
+				out = append(out, m[k])
+			}
+			return out, nil
+		}},
+		"join": {2, 2, func(a []any) (any, error) {
+			sep, ok := a[0].(string)
+			if !ok {
+				return nil, argErr(0, "a string", a[0])
+			}
+			l, ok := stringsOf(a[1])
+			if !ok {
+				return nil, argErr(1, "an array of strings", a[1])
+			}
+			return strings.Join(l, sep), nil
+		}},
+		"contains": {2, 2, func(a []any) (any, error) {
+			switch x := a[0].(type) {
+			case string:
+				s, ok := a[1].(string)
+				return ok && strings.Contains(x, s), nil
+			case []any:
+				for _, e := range x {
+					if reflect.DeepEqual(e, a[1]) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return nil, argErr(0, "a string or array", a[0])
+		}},
+		"starts_with": {2, 2, func(a []any) (any, error) {
+			s, ok1 := a[0].(string)
+			p, ok2 := a[1].(string)
+			if !ok1 || !ok2 {
+				return nil, errors.New("arguments must be strings")
+			}
+			return strings.HasPrefix(s, p), nil
+		}},
+		"ends_with": {2, 2, func(a []any) (any, error) {
+			s, ok1 := a[0].(string)
+			p, ok2 := a[1].(string)
+			if !ok1 || !ok2 {
+				return nil, errors.New("arguments must be strings")
+			}
+			return strings.HasSuffix(s, p), nil
+		}},
+		"to_string": {1, 1, func(a []any) (any, error) {
+			if s, ok := a[0].(string); ok {
+				return s, nil
+			}
+			b, err := json.Marshal(a[0])
+			return string(b), err
+		}},
+		"to_number": {1, 1, func(a []any) (any, error) {
+			switch x := a[0].(type) {
+			case float64:
+				return x, nil
+			case string:
+				if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+					return f, nil
+				}
+			}
+			return nil, nil
+		}},
+		"type": {1, 1, func(a []any) (any, error) { return typeName(a[0]), nil }},
+		"not_null": {1, -1, func(a []any) (any, error) {
+			for _, v := range a {
+				if v != nil {
+					return v, nil
+				}
+			}
+			return nil, nil
+		}},
+		"reverse": {1, 1, func(a []any) (any, error) {
+			switch x := a[0].(type) {
+			case string:
+				r := []rune(x)
+				for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+					r[i], r[j] = r[j], r[i]
+				}
+				return string(r), nil
+			case []any:
+				out := make([]any, len(x))
+				for i, e := range x {
+					out[len(x)-1-i] = e
+				}
+				return out, nil
+			}
+			return nil, argErr(0, "a string or array", a[0])
+		}},
+		"sort": {1, 1, func(a []any) (any, error) {
+			if n, ok := numbers(a[0]); ok {
+				sort.Float64s(n)
+				out := make([]any, len(n))
+				for i, x := range n {
+					out[i] = x
+				}
+				return out, nil
+			}
+			if s, ok := stringsOf(a[0]); ok {
+				sort.Strings(s)
+				out := make([]any, len(s))
+				for i, x := range s {
+					out[i] = x
+				}
+				return out, nil
+			}
+			return nil, argErr(0, "an array of numbers or strings", a[0])
+		}},
+		"max": {1, 1, func(a []any) (any, error) { return extreme(a[0], 1) }},
+		"min": {1, 1, func(a []any) (any, error) { return extreme(a[0], -1) }},
+		"sum": {1, 1, func(a []any) (any, error) {
+			n, ok := numbers(a[0])
+			if !ok {
+				return nil, argErr(0, "an array of numbers", a[0])
+			}
+			var s float64
+			for _, x := range n {
+				s += x
+			}
+			return s, nil
+		}},
+		"avg": {1, 1, func(a []any) (any, error) {
+			n, ok := numbers(a[0])
+			if !ok {
+				return nil, argErr(0, "an array of numbers", a[0])
+			}
+			if len(n) == 0 {
+				return nil, nil
+			}
+			var s float64
+			for _, x := range n {
+				s += x
+			}
+			return s / float64(len(n)), nil
+		}},
+	}
+}
+
+// extreme returns the largest (sign 1) or smallest (sign -1) element of an
+// array of numbers or strings, or null for an empty array.
+func extreme(v any, sign int) (any, error) {
+	if n, ok := numbers(v); ok {
+		if len(n) == 0 {
+			return nil, nil
+		}
+		best := n[0]
+		for _, x := range n[1:] {
+			if cmpFloat(x, best) == sign {
+				best = x
+			}
+		}
+		return best, nil
+	}
+	if s, ok := stringsOf(v); ok {
+		if len(s) == 0 {
+			return nil, nil
+		}
+		best := s[0]
+		for _, x := range s[1:] {
+			if strings.Compare(x, best) == sign {
+				best = x
+			}
+		}
+		return best, nil
+	}
+	return nil, argErr(0, "an array of numbers or strings", v)
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const samplePayload = `{
+  "action": "opened",
+  "number": 42,
+  "pull_request": {
+    "title": "Add feature X",
+    "draft": false,
+    "additions": 120,
+    "user": {"login": "octo"},
+    "labels": [{"name": "bug"}, {"name": "ui"}, {"name": "p1"}],
+    "requested_reviewers": []
+  },
+  "commits": [
+    {"id": "a1", "message": "fix", "files": ["a.go", "b.go"]},
+    {"id": "b2", "message": "docs", "files": ["README.md"]}
+  ],
+  "headers": {"x-request-id": "r-1"}
+}`
+
+func decodeSample(t *testing.T) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(samplePayload), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestQuery(t *testing.T) {
+	payload := decodeSample(t)
+	cases := []struct {
+		expr string
+		want any
+	}{
+		// JMESPath
+		{"action", "opened"},
+		{"pull_request.user.login", "octo"},
+		{"pull_request.labels[0].name", "bug"},
+		{"pull_request.labels[-1].name", "p1"},
+		{"pull_request.labels[*].name", []any{"bug", "ui", "p1"}},
+		{"pull_request.labels[:2].name", []any{"bug", "ui"}},
+		{"pull_request.labels[::-1].name | [0]", "p1"},
+		{"commits[].files[]", []any{"a.go", "b.go", "README.md"}},
+		{"commits[?message=='docs'].id | [0]", "b2"},
+		{"join(', ', pull_request.labels[*].name)", "bug, ui, p1"},
+		{"length(commits)", 2.0},
+		{"contains(pull_request.labels[*].name, 'ui')", true},
+		{"pull_request.additions > `100` && !pull_request.draft", true},
+		{"pull_request.additions >= 500", false},
+		{"missing || 'none'", "none"},
+		{"missing.deeper[0]", nil},
+		{`headers."x-request-id"`, "r-1"},
+		{"{title: pull_request.title, n: number}", map[string]any{"title": "Add feature X", "n": 42.0}},
+		{"[action, number]", []any{"opened", 42.0}},
+		{"pull_request.requested_reviewers || `[\"nobody\"]`", []any{"nobody"}},
+		{"to_string(number)", "42"},
+		{"starts_with(action, 'open')", true},
+		{"max(commits[].files[] | [*].length(@))", 9.0},
+		{"not_null(missing, pull_request.user.login)", "octo"},
+		{"headers.*", []any{"r-1"}},
+		// jq-style
+		{".action", "opened"},
+		{".pull_request.labels[1].name", "ui"},
+		{".commits[].id", []any{"a1", "b2"}},
+		{`.action == "opened"`, true},
+		{".pull_request.draft == false", true},
+		{`.headers."x-request-id"`, "r-1"},
+		{".commits | length(@)", 2.0},
+		{".", payload},
+		// conditionals, alternatives and interpolation
+		{"if pull_request.draft then 'draft' elif pull_request.additions > `100` then 'large' else 'small' end", "large"},
+		{`if .pull_request.draft then "skip" else "review" end`, "review"},
+		{"if missing then 'x' end", payload},
+		{`.pull_request.milestone // "none"`, "none"},
+		{`.pull_request.draft // "none"`, "none"},
+		{`.pull_request.title // "none"`, "Add feature X"},
+		{`.pull_request.milestone.title // .pull_request.labels[0].name // "none"`, "bug"},
+		{`.action == "opened" and (.pull_request.draft | not)`, true},
+		{`.pull_request.draft or .number > 100`, false},
+		{`"PR #\(.number) \(.pull_request.title) by @\(.pull_request.user.login)"`, "PR #42 Add feature X by @octo"},
+		{`"[\(.pull_request.milestone)] \(if .pull_request.additions > 100 then "large" else "small" end)"`, "[] large"},
+		{`"\(.commits[].id)"`, `["a1","b2"]`},
+		{`{id: .number, size: (if .pull_request.additions > 100 then "L" else "S" end)}`, map[string]any{"id": 42.0, "size": "L"}},
+		{"if.x", nil},
+	}
+	for _, c := range cases {
+		got, err := Query(c.expr, payload)
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s = %#v, want %#v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"a[",
+		"a.",
+		"foo(bar)",
+		"length(a, b)",
+		"a == ",
+		"'unterminated",
+		"a ~ b",
+		"[0:1:0]",
+		"if a then b",
+		"if a 'b' end",
+		`"a \(.b"`,
+		`"a \(.b ==)"`,
+		`"\(if)"`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	payload := decodeSample(t)
+	vars, err := Transform(map[string]string{
+		"title":  "pull_request.title",
+		"labels": "join(',', pull_request.labels[*].name)",
+		"broken": "join(',', commits)",
+	}, payload)
+	if err == nil || !strings.Contains(err.Error(), "transform broken") {
+		t.Errorf("err = %v", err)
+	}
+	if vars["title"] != "Add feature X" || vars["labels"] != "bug,ui,p1" {
+		t.Errorf("vars = %v", vars)
+	}
+	if _, ok := vars["broken"]; ok {
+		t.Error("failed variable was set")
+	}
+
+	if err := ValidateTransform(map[string]string{"ok": "a.b", "bad": "a[?"}); err == nil || !strings.Contains(err.Error(), "transform bad") {
+		t.Errorf("ValidateTransform = %v", err)
+	}
+}
+
+func TestTemplateAndFilterWithVars(t *testing.T) {
+	payload := decodeSample(t)
+	vars := map[string]any{"labels": "bug,ui", "author": map[string]any{"login": "octo"}, "big": true}
+
+	got := ExpandTemplateVars("PR #{{payload.number}} by {{vars.author.login}} [{{vars.labels}}] {{vars.nope}}", payload, vars)
+	if got != "PR #42 by octo [bug,ui] {{vars.nope}}" {
+		t.Errorf("template = %q", got)
+	}
+	if !EvaluateFilterVars("vars.big", payload, vars) {
+		t.Error("truthy var filter failed")
+	}
+	if !EvaluateFilterVars("vars.author.login == 'octo'", payload, vars) {
+		t.Error("var equality filter failed")
+	}
+	if EvaluateFilterVars("vars.labels != 'bug,ui'", payload, vars) {
+		t.Error("var inequality filter passed")
+	}
+	if !EvaluateFilterVars("payload.action == 'opened'", payload, vars) {
+		t.Error("payload filter failed")
+	}
+	if !EvaluateFilterVars(`.action == "opened" and (.pull_request.labels[].name | contains(@, "bug"))`, payload, vars) {
+		t.Error("expression filter failed")
+	}
+	if EvaluateFilterVars(".pull_request.draft", payload, vars) || EvaluateFilterVars(".nope[", payload, vars) {
+		t.Error("expression filter passed")
+	}
+	if ValidateFilter(".nope[") == nil || ValidateFilter("payload.a == 'b'") != nil {
+		t.Error("ValidateFilter")
+	}
+}
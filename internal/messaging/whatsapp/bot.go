@@ -65,6 +65,9 @@ type Bot struct {
 	processed     map[string]time.Time
 	processedSize int
 	mu            sync.Mutex
+
+	// httpClient for Cloud API calls; nil uses a default client.
+	httpClient *http.Client
 }
 
 // NewBot creates a new WhatsApp bot with the given config and runtime.
@@ -76,6 +79,11 @@ func NewBot(cfg Config, rt messaging.BotRuntime) *Bot {
 	}
 }
 
+// SetHTTPClient replaces the client used for Cloud API calls.
+func (b *Bot) SetHTTPClient(c *http.Client) {
+	b.httpClient = c
+}
+
 // SetTyping is a no-op; WhatsApp Cloud API does not support typing indicators for bots.
 func (b *Bot) SetTyping(_ context.Context, _ string) error {
 	return nil
@@ -324,7 +332,7 @@ func (b *Bot) handleMessage(from, msgID string, textPtr *messageText, msgType st
 
 // sendMessage sends a text message via WhatsApp Cloud API.
 func (b *Bot) sendMessage(to, text string) error {
-	return SendMessage(b.cfg, to, text)
+	return sendText(b.httpClient, b.cfg, to, text)
 }
 
 // SendNotify sends a notification to a specific WhatsApp number.
@@ -339,6 +347,10 @@ func (b *Bot) SendNotify(to, text string) {
 
 // SendMessage sends a text message via WhatsApp Cloud API.
 func SendMessage(cfg Config, to string, text string) error {
+	return sendText(nil, cfg, to, text)
+}
+
+func sendText(client *http.Client, cfg Config, to, text string) error {
 	if text == "" {
 		return nil
 	}
@@ -358,7 +370,7 @@ func SendMessage(cfg Config, to string, text string) error {
 		},
 	}
 
-	return sendAPIRequest(cfg, payload)
+	return sendAPIRequest(client, cfg, payload)
 }
 
 // SendReply sends a reply to a specific message.
@@ -384,11 +396,12 @@ func SendReply(cfg Config, to string, text string, messageID string) error {
 		},
 	}
 
-	return sendAPIRequest(cfg, payload)
+	return sendAPIRequest(nil, cfg, payload)
 }
 
-// sendAPIRequest sends a request to WhatsApp Cloud API.
-func sendAPIRequest(cfg Config, payload interface{}) error {
+// sendAPIRequest sends a request to WhatsApp Cloud API. A nil client uses a
+// default one with a 10s timeout.
+func sendAPIRequest(client *http.Client, cfg Config, payload interface{}) error {
 	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages",
 		cfg.APIVersion_(), cfg.PhoneNumberID)
 
@@ -405,7 +418,9 @@ func sendAPIRequest(cfg Config, payload interface{}) error {
 	req.Header.Set("Authorization", "Bearer "+cfg.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("whatsapp: request failed: %w", err)
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	tlog "tetora/internal/log"
+
+	"tetora/internal/cfgfile"
 )
 
 // CurrentConfigVersion is the latest config schema version.
@@ -150,7 +152,7 @@ func GetConfigVersion(raw map[string]json.RawMessage) int {
 // MigrateConfig reads a config file, detects its version, and applies
 // all pending migrations in order. If dryRun is true, the file is not modified.
 func MigrateConfig(configPath string, dryRun bool) ([]string, error) {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
@@ -184,7 +186,11 @@ func MigrateConfig(configPath string, dryRun bool) ([]string, error) {
 	}
 
 	backupPath := configPath + ".backup." + time.Now().Format("20060102-150405")
-	if err := os.WriteFile(backupPath, data, 0o600); err != nil {
+	orig, err := os.ReadFile(configPath)
+	if err == nil {
+		err = os.WriteFile(backupPath, orig, 0o600)
+	}
+	if err != nil {
 		return applied, fmt.Errorf("create backup: %w", err)
 	}
 
@@ -192,7 +198,7 @@ func MigrateConfig(configPath string, dryRun bool) ([]string, error) {
 	if err != nil {
 		return applied, fmt.Errorf("marshal config: %w", err)
 	}
-	if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return applied, fmt.Errorf("write config: %w", err)
 	}
 
@@ -201,7 +207,7 @@ func MigrateConfig(configPath string, dryRun bool) ([]string, error) {
 
 // AutoMigrateConfig checks the config version and applies migrations if needed.
 func AutoMigrateConfig(configPath string) {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return
 	}
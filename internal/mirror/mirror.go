@@ -0,0 +1,634 @@
+// Package mirror keeps agents, prompts, skills and workflows the same on two
+// Tetora instances, such as a desktop and a server, over the HTTP API.
+//
+// Every item has a hash of its content. The instance with a peer configured
+// starts each sync: it fetches the peer's items and compares each with its
+// own and with the hash both sides last agreed on, kept per peer in the
+// history database. An item changed on one side only is copied to the
+// other; one changed on both is a conflict, reported and left alone unless
+// a side is preferred. Pushed items carry the hash the initiator expects
+// the peer to have, so a change made on the peer in the meantime turns
+// into a conflict instead of being overwritten.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// ListResponse answers GET /api/mirror/items.
+type ListResponse struct {
+	Node  string   `json:"node"`
+	Kinds []string `json:"kinds"` // the kinds the instance mirrors
+	Items []Item   `json:"items"`
+}
+
+// ApplyRequest is the body of POST /api/mirror/items.
+type ApplyRequest struct {
+	Node  string `json:"node"`
+	Items []Item `json:"items"`
+}
+
+// ApplyResponse answers an ApplyRequest with one result per item, in order.
+type ApplyResponse struct {
+	Node    string        `json:"node"`
+	Results []ApplyResult `json:"results"`
+}
+
+// ApplyResult is what became of one pushed item: "applied", "unchanged"
+// (it already had the content), "conflict" (it no longer had the base),
+// "skipped" (a kind it does not mirror) or "error".
+type ApplyResult struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Hash   string `json:"hash,omitempty"` // its hash of the item after the request
+	Error  string `json:"error,omitempty"`
+}
+
+// Conflict is an item changed on both sides since they last agreed.
+type Conflict struct {
+	Item   string `json:"item"`
+	Local  string `json:"local"`  // "" = deleted here
+	Remote string `json:"remote"` // "" = deleted on the peer
+	Base   string `json:"base,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Result describes one sync.
+type Result struct {
+	At        string     `json:"at"`
+	Peer      string     `json:"peer,omitempty"`
+	Direction string     `json:"direction"`
+	DryRun    bool       `json:"dryRun,omitempty"`
+	InSync    int        `json:"inSync"`
+	Pushed    []string   `json:"pushed,omitempty"` // items copied (or deleted) on the peer
+	Pulled    []string   `json:"pulled,omitempty"` // items copied (or deleted) here
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	Failed    []string   `json:"failed,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Options override the configuration for one sync.
+type Options struct {
+	Direction string   // "both", "push" or "pull"; "" = config
+	Conflict  string   // "skip", "local" or "remote"; "" = config
+	Kinds     []string // a subset of the configured kinds; nil = all
+	DryRun    bool     // plan only
+}
+
+// Service syncs with the peer and answers its syncs.
+type Service struct {
+	cfg     config.MirrorConfig
+	store   *Store
+	dbPath  string
+	node    string
+	onApply func(kinds []string)
+	client  *http.Client
+
+	mu   sync.Mutex // one sync at a time
+	last *Result
+
+	applyMu sync.Mutex // writes to the store, ours and the peer's
+}
+
+// New creates the service. onApply, if set, is called with the kinds of
+// the items written here after each sync or push from the peer.
+func New(cfg config.MirrorConfig, store *Store, dbPath, node string, onApply func(kinds []string)) (*Service, error) {
+	for _, k := range cfg.KindsOrDefault() {
+		if !slices.Contains(Kinds, k) {
+			return nil, fmt.Errorf("unknown mirror kind %q", k)
+		}
+	}
+	if err := checkOptions(Options{Direction: cfg.Direction, Conflict: cfg.Conflict}); err != nil {
+		return nil, err
+	}
+	return &Service{
+		cfg:     cfg,
+		store:   store,
+		dbPath:  dbPath,
+		node:    node,
+		onApply: onApply,
+		client:  &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+func checkOptions(o Options) error {
+	switch o.Direction {
+	case "", "both", "push", "pull":
+	default:
+		return fmt.Errorf("invalid mirror direction %q (want both, push or pull)", o.Direction)
+	}
+	switch o.Conflict {
+	case "", "skip", "local", "remote":
+	default:
+		return fmt.Errorf("invalid mirror conflict policy %q (want skip, local or remote)", o.Conflict)
+	}
+	return nil
+}
+
+// InitDB creates the table of hashes last agreed with each peer.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS mirror_state (
+		peer TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		synced_at TEXT NOT NULL,
+		PRIMARY KEY (peer, kind, name)
+	);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// Start launches the loop that syncs with the peer every interval until ctx
+// is cancelled. Without a peer or an interval it does nothing; the service
+// then only answers, or syncs on demand.
+func (s *Service) Start(ctx context.Context) {
+	interval := s.cfg.IntervalOrDefault()
+	if s.cfg.Peer == "" || interval == 0 {
+		return
+	}
+	log.Info("mirror: syncing with peer", "peer", s.cfg.Peer, "direction", s.cfg.DirectionOrDefault(), "interval", interval)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if res, err := s.Run(ctx, Options{}); err != nil {
+				log.Warn("mirror: sync failed", "peer", s.cfg.Peer, "error", err)
+			} else {
+				if len(res.Pushed)+len(res.Pulled) > 0 {
+					log.Info("mirror: synced with peer", "peer", s.cfg.Peer, "pushed", len(res.Pushed), "pulled", len(res.Pulled))
+				}
+				if len(res.Conflicts) > 0 {
+					log.Warn("mirror: items changed on both sides", "peer", s.cfg.Peer, "conflicts", len(res.Conflicts))
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Last returns the result of the last sync started here, or nil.
+func (s *Service) Last() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Kinds returns the mirrored kinds of item.
+func (s *Service) Kinds() []string { return s.cfg.KindsOrDefault() }
+
+// Items answers the peer's listing: the items of the requested kinds that
+// this instance mirrors (all of them for none).
+func (s *Service) Items(kinds []string) (*ListResponse, error) {
+	mine := s.Kinds()
+	if len(kinds) > 0 {
+		kinds = intersect(mine, kinds)
+	} else {
+		kinds = mine
+	}
+	items, err := s.store.List(kinds)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []Item{}
+	}
+	return &ListResponse{Node: s.node, Kinds: mine, Items: items}, nil
+}
+
+// Apply answers a push from the peer. Each item is written only if this
+// instance still has its base.
+func (s *Service) Apply(req *ApplyRequest) *ApplyResponse {
+	resp := &ApplyResponse{Node: s.node, Results: make([]ApplyResult, 0, len(req.Items))}
+	var changed []string
+	for i := range req.Items {
+		it := &req.Items[i]
+		r := ApplyResult{Key: it.Key()}
+		if !slices.Contains(s.Kinds(), it.Kind) {
+			r.Status = "skipped"
+			resp.Results = append(resp.Results, r)
+			continue
+		}
+		cur, err := s.put(it, it.Base)
+		r.Hash = cur
+		switch {
+		case errors.Is(err, errConflict):
+			r.Status = "conflict"
+		case errors.Is(err, errUnchanged):
+			r.Status = "unchanged"
+		case err != nil:
+			r.Status = "error"
+			r.Error = err.Error()
+		default:
+			r.Status = "applied"
+			changed = appendKind(changed, it.Kind)
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	if len(changed) > 0 {
+		log.Info("mirror: applied items from peer", "peer", req.Node, "kinds", strings.Join(changed, ","))
+		if s.onApply != nil {
+			s.onApply(changed)
+		}
+	}
+	return resp
+}
+
+var (
+	errConflict  = errors.New("changed since the last sync")
+	errUnchanged = errors.New("already up to date")
+)
+
+// put writes it if the store still has the base hash, returning the hash
+// the store has afterwards.
+func (s *Service) put(it *Item, base string) (string, error) {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	cur, err := s.store.Get(it.Kind, it.Name)
+	if err != nil {
+		return "", err
+	}
+	have, want := hashOf(cur), hashOf(it)
+	if have == want {
+		return have, errUnchanged
+	}
+	if have != base {
+		return have, errConflict
+	}
+	if err := s.store.Put(it); err != nil {
+		return have, err
+	}
+	return want, nil
+}
+
+// Run syncs once with the peer.
+func (s *Service) Run(ctx context.Context, opts Options) (*Result, error) {
+	if s.cfg.Peer == "" {
+		return nil, errors.New("mirror.peer is not set")
+	}
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+	if opts.Direction == "" {
+		opts.Direction = s.cfg.DirectionOrDefault()
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = s.cfg.ConflictOrDefault()
+	}
+	kinds := s.Kinds()
+	if len(opts.Kinds) > 0 {
+		kinds = intersect(kinds, opts.Kinds)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &Result{At: time.Now().UTC().Format(time.RFC3339), Peer: s.cfg.Peer, Direction: opts.Direction, DryRun: opts.DryRun}
+	err := s.sync(ctx, kinds, opts, res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if !opts.DryRun {
+		s.last = res
+	}
+	return res, err
+}
+
+// step is the planned fate of one item.
+type step struct {
+	key           string
+	local, remote *Item
+	base          string
+	action        string // "push", "pull" or "" (in sync)
+}
+
+func (s *Service) sync(ctx context.Context, kinds []string, opts Options, res *Result) error {
+	var remote ListResponse
+	q := url.Values{"kinds": {strings.Join(kinds, ",")}}
+	if err := s.call(ctx, http.MethodGet, "/api/mirror/items?"+q.Encode(), nil, &remote); err != nil {
+		return err
+	}
+	kinds = intersect(kinds, remote.Kinds)
+	if len(kinds) == 0 {
+		return errors.New("the peer mirrors none of these kinds")
+	}
+	local, err := s.store.List(kinds)
+	if err != nil {
+		return err
+	}
+	steps, err := s.plan(kinds, local, remote.Items, opts, res)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	var push []Item
+	var pushed []*step
+	var pulled []string
+	for _, st := range steps {
+		switch st.action {
+		case "":
+			s.setBase(st)
+		case "push":
+			it := Item{Kind: kindOf(st.key), Name: nameOf(st.key), Deleted: st.local == nil}
+			if st.local != nil {
+				it = *st.local
+			}
+			it.Base = hashOf(st.remote)
+			push = append(push, it)
+			pushed = append(pushed, st)
+		case "pull":
+			it := Item{Kind: kindOf(st.key), Name: nameOf(st.key), Deleted: st.remote == nil}
+			if st.remote != nil {
+				it = *st.remote
+			}
+			if cur, err := s.put(&it, hashOf(st.local)); err != nil && !errors.Is(err, errUnchanged) {
+				if errors.Is(err, errConflict) {
+					res.Conflicts = append(res.Conflicts, Conflict{Item: st.key, Local: cur, Remote: hashOf(st.remote), Base: st.base, Reason: "changed here during the sync"})
+				} else {
+					res.Failed = append(res.Failed, st.key+": "+err.Error())
+				}
+				continue
+			}
+			st.local = st.remote
+			s.setBase(st)
+			res.Pulled = append(res.Pulled, st.key)
+			pulled = appendKind(pulled, it.Kind)
+		}
+	}
+	if len(pulled) > 0 && s.onApply != nil {
+		s.onApply(pulled)
+	}
+	if len(push) == 0 {
+		return nil
+	}
+
+	var resp ApplyResponse
+	if err := s.call(ctx, http.MethodPost, "/api/mirror/items", ApplyRequest{Node: s.node, Items: push}, &resp); err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+	for i, st := range pushed {
+		if i >= len(resp.Results) {
+			res.Failed = append(res.Failed, st.key+": no result from the peer")
+			continue
+		}
+		r := resp.Results[i]
+		switch r.Status {
+		case "applied", "unchanged":
+			st.remote = st.local
+			s.setBase(st)
+			res.Pushed = append(res.Pushed, st.key)
+		case "conflict":
+			res.Conflicts = append(res.Conflicts, Conflict{Item: st.key, Local: hashOf(st.local), Remote: r.Hash, Base: st.base, Reason: "changed on the peer during the sync"})
+		case "skipped":
+		default:
+			res.Failed = append(res.Failed, st.key+": "+r.Error)
+		}
+	}
+	return nil
+}
+
+// plan compares local and remote items with the bases. Items changed on
+// one side are copied to the other as the direction allows; conflicts go
+// to the preferred side, or into res. In-sync items are counted, and
+// planned copies listed in res when it is a dry run.
+func (s *Service) plan(kinds []string, local, remote []Item, opts Options, res *Result) ([]*step, error) {
+	bases, err := s.bases()
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]*step{}
+	var keys []string
+	get := func(key string) *step {
+		st, ok := byKey[key]
+		if !ok {
+			st = &step{key: key, base: bases[key]}
+			byKey[key] = st
+			keys = append(keys, key)
+		}
+		return st
+	}
+	for i := range local {
+		get(local[i].Key()).local = &local[i]
+	}
+	for i := range remote {
+		if slices.Contains(kinds, remote[i].Kind) && validName(remote[i].Name) {
+			get(remote[i].Key()).remote = &remote[i]
+		}
+	}
+	// Items deleted on both sides only have a base left.
+	for key := range bases {
+		if slices.Contains(kinds, kindOf(key)) {
+			get(key)
+		}
+	}
+	sort.Strings(keys)
+
+	canPush := opts.Direction != "pull"
+	canPull := opts.Direction != "push"
+	var steps []*step
+	for _, key := range keys {
+		st := byKey[key]
+		l, r := hashOf(st.local), hashOf(st.remote)
+		switch {
+		case l == r:
+			if l != "" {
+				res.InSync++
+			}
+			if l != st.base {
+				steps = append(steps, st)
+			}
+			continue
+		case r == st.base:
+			if canPush {
+				st.action = "push"
+			}
+		case l == st.base:
+			if canPull {
+				st.action = "pull"
+			}
+		default:
+			c := Conflict{Item: key, Local: l, Remote: r, Base: st.base}
+			switch {
+			case opts.Conflict == "local" && canPush:
+				st.action = "push"
+			case opts.Conflict == "remote" && canPull:
+				st.action = "pull"
+			default:
+				res.Conflicts = append(res.Conflicts, c)
+			}
+		}
+		if st.action != "" {
+			steps = append(steps, st)
+		}
+	}
+	steps = guardDeletes(steps, local, remote, res)
+	if opts.DryRun {
+		for _, st := range steps {
+			switch st.action {
+			case "push":
+				res.Pushed = append(res.Pushed, st.key)
+			case "pull":
+				res.Pulled = append(res.Pulled, st.key)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// guardDeletes drops deletions that would leave a side with none of a kind
+// it has items of: an emptied or misconfigured directory looks like every
+// item was deleted, and should not spread to the other instance.
+func guardDeletes(steps []*step, local, remote []Item, res *Result) []*step {
+	count := func(items []Item) map[string]int {
+		n := map[string]int{}
+		for _, it := range items {
+			n[it.Kind]++
+		}
+		return n
+	}
+	have := map[string]map[string]int{"push": count(remote), "pull": count(local)}
+	deletes := map[string]map[string]int{"push": {}, "pull": {}}
+	for _, st := range steps {
+		if st.action == "push" && st.local == nil && st.remote != nil {
+			deletes["push"][kindOf(st.key)]++
+		}
+		if st.action == "pull" && st.remote == nil && st.local != nil {
+			deletes["pull"][kindOf(st.key)]++
+		}
+	}
+	out := steps[:0]
+	for _, st := range steps {
+		kind := kindOf(st.key)
+		deleting := (st.action == "push" && st.local == nil && st.remote != nil) ||
+			(st.action == "pull" && st.remote == nil && st.local != nil)
+		if deleting && deletes[st.action][kind] == have[st.action][kind] {
+			side := "on the peer"
+			if st.action == "pull" {
+				side = "here"
+			}
+			res.Failed = append(res.Failed, fmt.Sprintf("%s: not deleted: it would delete every %s %s", st.key, strings.TrimSuffix(kind, "s"), side))
+			continue
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// bases returns the hashes last agreed with the peer, by item key.
+func (s *Service) bases() (map[string]string, error) {
+	rows, err := db.QueryArgs(s.dbPath, `SELECT kind, name, hash FROM mirror_state WHERE peer = ?`, s.cfg.Peer)
+	if err != nil {
+		return nil, fmt.Errorf("read mirror state: %w", err)
+	}
+	out := make(map[string]string, len(rows))
+	for _, row := range rows {
+		out[db.Str(row["kind"])+"/"+db.Str(row["name"])] = db.Str(row["hash"])
+	}
+	return out, nil
+}
+
+// setBase records that both sides agree on the step's item (both having
+// deleted it forgets the item).
+func (s *Service) setBase(st *step) {
+	kind, name, hash := kindOf(st.key), nameOf(st.key), hashOf(st.local)
+	var err error
+	if hash == "" {
+		err = db.ExecArgs(s.dbPath, `DELETE FROM mirror_state WHERE peer = ? AND kind = ? AND name = ?`, s.cfg.Peer, kind, name)
+	} else {
+		err = db.ExecArgs(s.dbPath, `INSERT OR REPLACE INTO mirror_state (peer, kind, name, hash, synced_at) VALUES (?, ?, ?, ?, ?)`,
+			s.cfg.Peer, kind, name, hash, time.Now().UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		log.Warn("mirror: save state failed", "item", st.key, "error", err)
+	}
+}
+
+// maxMessage bounds a response from the peer.
+const maxMessage = 64 << 20
+
+// call sends a request to the peer and decodes its JSON response into out.
+func (s *Service) call(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.cfg.Peer, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.cfg.PeerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.PeerToken)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer: status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("peer: %w", err)
+	}
+	return nil
+}
+
+func kindOf(key string) string {
+	kind, _, _ := strings.Cut(key, "/")
+	return kind
+}
+
+func nameOf(key string) string {
+	_, name, _ := strings.Cut(key, "/")
+	return name
+}
+
+// intersect returns the elements of a that are also in b, in a's order.
+func intersect(a, b []string) []string {
+	var out []string
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func appendKind(kinds []string, kind string) []string {
+	if slices.Contains(kinds, kind) {
+		return kinds
+	}
+	return append(kinds, kind)
+}
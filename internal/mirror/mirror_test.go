@@ -0,0 +1,275 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	s := &Store{
+		ConfigPath:   filepath.Join(dir, "config.json"),
+		AgentsDir:    filepath.Join(dir, "agents"),
+		PromptsDir:   filepath.Join(dir, "prompts"),
+		SkillsDir:    filepath.Join(dir, "workspace", "skills"),
+		WorkflowsDir: filepath.Join(dir, "workflows"),
+	}
+	writeTestFile(t, s.ConfigPath, `{"listenAddr": ":8991", "agents": {}}`)
+	return s
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func setAgent(t *testing.T, s *Store, name string, entry map[string]any) {
+	t.Helper()
+	raw, err := s.readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw["agents"].(map[string]any)[name] = entry
+	data, _ := json.Marshal(raw)
+	writeTestFile(t, s.ConfigPath, string(data))
+}
+
+func agentEntry(t *testing.T, s *Store, name string) map[string]any {
+	t.Helper()
+	agents, err := s.agents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := agents[name].(map[string]any)
+	return entry
+}
+
+// newTestPair returns a service syncing from a local store with a peer
+// serving the remote store.
+func newTestPair(t *testing.T, cfg config.MirrorConfig) (svc *Service, local, remote *Store) {
+	t.Helper()
+	local, remote = newTestStore(t), newTestStore(t)
+	peer, err := New(config.MirrorConfig{Enabled: true}, remote, "", "server", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var out any
+		if r.Method == http.MethodPost {
+			var req ApplyRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			out = peer.Apply(&req)
+		} else {
+			list, err := peer.Items(strings.Split(r.URL.Query().Get("kinds"), ","))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			out = list
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	cfg.Enabled, cfg.Peer, cfg.PeerToken = true, srv.URL, "tok"
+	svc, err = New(cfg, local, dbPath, "desktop", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, local, remote
+}
+
+func run(t *testing.T, svc *Service, opts Options) *Result {
+	t.Helper()
+	res, err := svc.Run(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestMirrorSync(t *testing.T) {
+	svc, local, remote := newTestPair(t, config.MirrorConfig{})
+
+	setAgent(t, local, "ruri", map[string]any{"model": "opus", "workspace": "/home/me/ws"})
+	writeTestFile(t, filepath.Join(local.AgentsDir, "ruri", "SOUL.md"), "# Ruri")
+	writeTestFile(t, filepath.Join(local.AgentsDir, "ruri", "todos", "today.md"), "runtime state")
+	writeTestFile(t, filepath.Join(local.PromptsDir, "review.md"), "Review this.")
+	writeTestFile(t, filepath.Join(remote.WorkflowsDir, "daily.json"), `{"name": "daily"}`)
+	skillDir := filepath.Join(remote.SkillsDir, "fetch")
+	writeTestFile(t, filepath.Join(skillDir, "metadata.json"),
+		`{"name": "fetch", "command": "`+filepath.Join(skillDir, "run.sh")+`", "approved": true, "usageCount": 7}`)
+	writeTestFile(t, filepath.Join(skillDir, "run.sh"), "#!/bin/sh\necho hi\n")
+	os.Chmod(filepath.Join(skillDir, "run.sh"), 0o755)
+
+	res := run(t, svc, Options{DryRun: true})
+	if len(res.Pushed) != 2 || len(res.Pulled) != 2 {
+		t.Fatalf("dry run = %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(remote.PromptsDir, "review.md")); err == nil {
+		t.Fatal("dry run pushed")
+	}
+	if svc.Last() != nil {
+		t.Error("dry run recorded as last sync")
+	}
+
+	res = run(t, svc, Options{})
+	if strings.Join(res.Pushed, " ") != "agents/ruri prompts/review" || strings.Join(res.Pulled, " ") != "skills/fetch workflows/daily" {
+		t.Fatalf("first sync = %+v", res)
+	}
+	if e := agentEntry(t, remote, "ruri"); e["model"] != "opus" || e["workspace"] != nil {
+		t.Errorf("remote agent = %v", e)
+	}
+	if readTestFile(filepath.Join(remote.AgentsDir, "ruri", "SOUL.md")) != "# Ruri" {
+		t.Error("soul not pushed")
+	}
+	if _, err := os.Stat(filepath.Join(remote.AgentsDir, "ruri", "todos")); err == nil {
+		t.Error("agent todos pushed")
+	}
+	if readTestFile(filepath.Join(remote.PromptsDir, "review.md")) != "Review this." {
+		t.Error("prompt not pushed")
+	}
+	if readTestFile(filepath.Join(local.WorkflowsDir, "daily.json")) != `{"name": "daily"}` {
+		t.Error("workflow not pulled")
+	}
+	var meta map[string]any
+	localSkill := filepath.Join(local.SkillsDir, "fetch")
+	json.Unmarshal([]byte(readTestFile(filepath.Join(localSkill, "metadata.json"))), &meta)
+	if meta["command"] != filepath.Join(localSkill, "run.sh") || meta["approved"] != false || meta["usageCount"] != nil {
+		t.Errorf("pulled skill metadata = %v", meta)
+	}
+	if fi, err := os.Stat(filepath.Join(localSkill, "run.sh")); err != nil || fi.Mode()&0o100 == 0 {
+		t.Errorf("pulled skill script: %v, %v", fi, err)
+	}
+
+	// Local state differs but the content is the same.
+	res = run(t, svc, Options{})
+	if res.InSync != 4 || len(res.Pushed)+len(res.Pulled)+len(res.Conflicts) != 0 {
+		t.Fatalf("second sync = %+v", res)
+	}
+
+	// A change on one side is copied; local keys stay.
+	setAgent(t, remote, "ruri", map[string]any{"model": "sonnet", "workspace": "/srv/ws"})
+	writeTestFile(t, filepath.Join(local.PromptsDir, "review.md"), "Review this carefully.")
+	res = run(t, svc, Options{})
+	if strings.Join(res.Pulled, " ") != "agents/ruri" || strings.Join(res.Pushed, " ") != "prompts/review" {
+		t.Fatalf("one-sided changes = %+v", res)
+	}
+	if e := agentEntry(t, local, "ruri"); e["model"] != "sonnet" || e["workspace"] != "/home/me/ws" {
+		t.Errorf("pulled agent = %v", e)
+	}
+
+	// A change on both sides is a conflict, left alone unless a side wins.
+	writeTestFile(t, filepath.Join(local.PromptsDir, "review.md"), "mine")
+	writeTestFile(t, filepath.Join(remote.PromptsDir, "review.md"), "theirs")
+	res = run(t, svc, Options{})
+	if len(res.Conflicts) != 1 || res.Conflicts[0].Item != "prompts/review" || len(res.Pushed)+len(res.Pulled) != 0 {
+		t.Fatalf("conflict = %+v", res)
+	}
+	res = run(t, svc, Options{Conflict: "local", Direction: "push"})
+	if strings.Join(res.Pushed, " ") != "prompts/review" || readTestFile(filepath.Join(remote.PromptsDir, "review.md")) != "mine" {
+		t.Fatalf("prefer local = %+v", res)
+	}
+
+	// Deletions are copied, but not one that empties a kind.
+	os.Remove(filepath.Join(local.PromptsDir, "review.md"))
+	writeTestFile(t, filepath.Join(remote.PromptsDir, "other.md"), "other")
+	res = run(t, svc, Options{})
+	if !slices.Contains(res.Pushed, "prompts/review") || !slices.Contains(res.Pulled, "prompts/other") {
+		t.Fatalf("delete = %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(remote.PromptsDir, "review.md")); err == nil {
+		t.Error("deletion not pushed")
+	}
+	os.Remove(filepath.Join(local.WorkflowsDir, "daily.json"))
+	res = run(t, svc, Options{})
+	if len(res.Failed) != 1 || !strings.Contains(res.Failed[0], "every workflow") {
+		t.Errorf("guard = %+v", res)
+	}
+	if readTestFile(filepath.Join(remote.WorkflowsDir, "daily.json")) == "" {
+		t.Error("last workflow deleted on the peer")
+	}
+}
+
+func TestMirrorApplyConflict(t *testing.T) {
+	store := newTestStore(t)
+	svc, err := New(config.MirrorConfig{Kinds: []string{"prompts"}}, store, "", "server", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(store.PromptsDir, "a.md"), "current")
+	cur, _ := store.Get("prompts", "a")
+
+	item := func(name, content, base string) Item {
+		it := Item{Kind: "prompts", Name: name, Files: []File{{Path: name + ".md", Data: []byte(content)}}}
+		it.Hash = computeHash(&it)
+		it.Base = base
+		return it
+	}
+	bad := item("b", "x", "")
+	bad.Files[0].Data = []byte("tampered")
+	escape := item("c", "x", "")
+	escape.Files[0].Path = "../c.md"
+	escape.Hash = computeHash(&escape)
+
+	resp := svc.Apply(&ApplyRequest{Node: "desktop", Items: []Item{
+		item("a", "stale", "0000"),
+		item("a", "current", ""),
+		item("new", "hello", ""),
+		bad,
+		escape,
+		{Kind: "workflows", Name: "w"},
+	}})
+	var got []string
+	for _, r := range resp.Results {
+		got = append(got, r.Status)
+	}
+	if strings.Join(got, " ") != "conflict unchanged applied error error skipped" {
+		t.Errorf("statuses = %v (%+v)", got, resp.Results)
+	}
+	if resp.Results[0].Hash != cur.Hash {
+		t.Errorf("conflict hash = %q, want %q", resp.Results[0].Hash, cur.Hash)
+	}
+	if readTestFile(filepath.Join(store.PromptsDir, "a.md")) != "current" || readTestFile(filepath.Join(store.PromptsDir, "new.md")) != "hello" {
+		t.Error("store not as expected")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(store.PromptsDir), "c.md")); err == nil {
+		t.Error("wrote outside the prompts dir")
+	}
+
+	if _, err := New(config.MirrorConfig{Kinds: []string{"souls"}}, store, "", "", nil); err == nil {
+		t.Error("unknown kind accepted")
+	}
+	if _, err := New(config.MirrorConfig{Conflict: "newest"}, store, "", "", nil); err == nil {
+		t.Error("unknown conflict policy accepted")
+	}
+}
@@ -0,0 +1,611 @@
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tetora/internal/cfgfile"
+	"tetora/internal/log"
+	"tetora/internal/version"
+)
+
+// Kinds lists the kinds of item that can be mirrored.
+var Kinds = []string{"agents", "prompts", "skills", "workflows"}
+
+// Item is one mirrored thing: an agent (its config entry and the files in
+// its agent directory), a prompt, a skill directory or a workflow.
+type Item struct {
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	Hash    string          `json:"hash"`              // of the content; "" for a deletion
+	Base    string          `json:"base,omitempty"`    // pushed items: the hash the receiver must still have
+	Deleted bool            `json:"deleted,omitempty"` // pushed items: delete it
+	Config  json.RawMessage `json:"config,omitempty"`  // agents: the agents.<name> entry, without local keys
+	Files   []File          `json:"files,omitempty"`
+}
+
+// File is a file of an item, by its path relative to the item.
+type File struct {
+	Path string `json:"path"`
+	Exec bool   `json:"exec,omitempty"`
+	Data []byte `json:"data"`
+}
+
+// Key identifies the item across instances ("agents/ruri").
+func (it *Item) Key() string { return it.Kind + "/" + it.Name }
+
+// hashOf returns the item's hash, "" for a missing item.
+func hashOf(it *Item) string {
+	if it == nil || it.Deleted {
+		return ""
+	}
+	return it.Hash
+}
+
+// computeHash hashes the content of an item: its kind, name, config and
+// files. Local state (usage counts, skill approval, machine paths) is not
+// part of the content.
+func computeHash(it *Item) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", it.Kind, it.Name, len(it.Config))
+	h.Write(it.Config)
+	files := slices.Clone(it.Files)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for _, f := range files {
+		fmt.Fprintf(h, "\x00%s\x00%t\x00%d\x00", f.Path, f.Exec, len(f.Data))
+		h.Write(f.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// localAgentKeys are agent config keys that hold machine-specific paths.
+// They are neither sent nor overwritten.
+var localAgentKeys = []string{"allowedDirs", "workspace"}
+
+// localSkillKeys are skill metadata.json keys kept by each instance: its
+// own approval and usage.
+var localSkillKeys = []string{"approved", "usageCount", "lastUsedAt"}
+
+// skillDirRef stands for the skill's own directory in a mirrored command,
+// since packaged skills record the full path of their script.
+const skillDirRef = "{skillDir}/"
+
+// maxFile bounds the files that are mirrored; larger ones are left out.
+const maxFile = 4 << 20
+
+// Store reads and writes the mirrored items of this instance.
+type Store struct {
+	ConfigPath   string // agents are entries of its "agents"
+	AgentsDir    string
+	PromptsDir   string
+	SkillsDir    string
+	WorkflowsDir string
+	HistoryDB    string // versions of changed config, prompts and workflows; "" = none
+}
+
+// List returns every item of the given kinds, with content.
+func (s *Store) List(kinds []string) ([]Item, error) {
+	var out []Item
+	for _, kind := range kinds {
+		names, err := s.names(kind)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kind, err)
+		}
+		for _, name := range names {
+			it, err := s.Get(kind, name)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", kind, name, err)
+			}
+			if it != nil {
+				out = append(out, *it)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) names(kind string) ([]string, error) {
+	switch kind {
+	case "agents":
+		agents, err := s.agents()
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(agents))
+		for name := range agents {
+			if validName(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	case "prompts":
+		return fileNames(s.PromptsDir, ".md")
+	case "workflows":
+		return fileNames(s.WorkflowsDir, ".json")
+	case "skills":
+		entries, err := os.ReadDir(s.SkillsDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, e := range entries {
+			if e.IsDir() && validName(e.Name()) {
+				names = append(names, e.Name())
+			}
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("unknown kind %q", kind)
+}
+
+// fileNames lists the names of the files in dir with extension ext.
+func fileNames(dir, ext string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ext)
+		if ok && e.Type().IsRegular() && validName(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// validName reports whether name can be an item name: a single path
+// element that is not hidden.
+func validName(name string) bool {
+	return name != "" && len(name) <= 128 && filepath.IsLocal(name) && filepath.Base(name) == name &&
+		!strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// Get returns an item, or nil if this instance does not have it.
+func (s *Store) Get(kind, name string) (*Item, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid name %q", name)
+	}
+	it := &Item{Kind: kind, Name: name}
+	switch kind {
+	case "agents":
+		agents, err := s.agents()
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := agents[name].(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		entry = maps.Clone(entry)
+		for _, k := range localAgentKeys {
+			delete(entry, k)
+		}
+		if it.Config, err = json.Marshal(entry); err != nil {
+			return nil, err
+		}
+		if it.Files, err = readFiles(filepath.Join(s.AgentsDir, name), false); err != nil {
+			return nil, err
+		}
+	case "prompts", "workflows":
+		dir, ext := s.fileDir(kind)
+		f, err := readFile(dir, name+ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		it.Files = []File{*f}
+	case "skills":
+		dir := filepath.Join(s.SkillsDir, name)
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return nil, nil
+		}
+		files, err := readFiles(dir, true)
+		if err != nil {
+			return nil, err
+		}
+		for i, f := range files {
+			if f.Path == "metadata.json" {
+				files[i].Data = sendSkillMeta(f.Data, dir)
+			}
+		}
+		it.Files = files
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	it.Hash = computeHash(it)
+	return it, nil
+}
+
+func (s *Store) fileDir(kind string) (dir, ext string) {
+	if kind == "prompts" {
+		return s.PromptsDir, ".md"
+	}
+	return s.WorkflowsDir, ".json"
+}
+
+// agents returns the agents of the config file, as written.
+func (s *Store) agents() (map[string]any, error) {
+	raw, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	agents, _ := configAgents(raw)
+	return agents, nil
+}
+
+func (s *Store) readConfig() (map[string]any, error) {
+	data, err := cfgfile.ReadJSON(s.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return raw, nil
+}
+
+// configAgents returns the agents map of a config and its key ("roles" in
+// configs from before the rename).
+func configAgents(raw map[string]any) (map[string]any, string) {
+	if agents, ok := raw["agents"].(map[string]any); ok {
+		return agents, "agents"
+	}
+	if roles, ok := raw["roles"].(map[string]any); ok {
+		return roles, "roles"
+	}
+	return nil, "agents"
+}
+
+func readFile(dir, rel string) (*File, error) {
+	path := filepath.Join(dir, rel)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() > maxFile {
+		return nil, fs.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: filepath.ToSlash(rel), Exec: fi.Mode()&0o100 != 0, Data: data}, nil
+}
+
+// readFiles reads the regular files of dir, recursively or only the top
+// level. Hidden files and directories are skipped, as are files over
+// maxFile. A missing dir has none.
+func readFiles(dir string, recursive bool) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || (d.IsDir() && !recursive) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		f, err := readFile(dir, rel)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, *f)
+		return nil
+	})
+	return files, err
+}
+
+// sendSkillMeta is metadata.json as mirrored: without local keys, and with
+// a command inside the skill directory made relative to it.
+func sendSkillMeta(data []byte, dir string) []byte {
+	var meta map[string]any
+	if json.Unmarshal(data, &meta) != nil {
+		return data
+	}
+	for _, k := range localSkillKeys {
+		delete(meta, k)
+	}
+	if cmd, ok := meta["command"].(string); ok {
+		if rel, ok := strings.CutPrefix(cmd, dir+string(filepath.Separator)); ok {
+			meta["command"] = skillDirRef + filepath.ToSlash(rel)
+		}
+	}
+	out, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// receiveSkillMeta is the inverse of sendSkillMeta: it adds this instance's
+// approval and usage from its current metadata (old; nil for a new skill,
+// which arrives unapproved) and resolves the command in dir.
+func receiveSkillMeta(data, old []byte, dir string) []byte {
+	var meta map[string]any
+	if json.Unmarshal(data, &meta) != nil {
+		return data
+	}
+	meta["approved"] = false
+	var prev map[string]any
+	if old != nil && json.Unmarshal(old, &prev) == nil {
+		for _, k := range localSkillKeys {
+			if v, ok := prev[k]; ok {
+				meta[k] = v
+			} else {
+				delete(meta, k)
+			}
+		}
+	}
+	if cmd, ok := meta["command"].(string); ok {
+		if rel, ok := strings.CutPrefix(cmd, skillDirRef); ok {
+			meta["command"] = filepath.Join(dir, filepath.FromSlash(rel))
+		}
+	}
+	out, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// checkItem validates a received item before it is written.
+func checkItem(it *Item) error {
+	if !slices.Contains(Kinds, it.Kind) {
+		return fmt.Errorf("unknown kind %q", it.Kind)
+	}
+	if !validName(it.Name) {
+		return fmt.Errorf("invalid name %q", it.Name)
+	}
+	if it.Deleted {
+		return nil
+	}
+	if it.Hash != computeHash(it) {
+		return errors.New("content does not match its hash")
+	}
+	for _, f := range it.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) || strings.HasPrefix(f.Path, ".") || strings.Contains(f.Path, "/.") {
+			return fmt.Errorf("invalid file path %q", f.Path)
+		}
+		if it.Kind == "agents" && strings.Contains(f.Path, "/") {
+			return fmt.Errorf("invalid file path %q: agent files are not in subdirectories", f.Path)
+		}
+	}
+	switch it.Kind {
+	case "agents":
+		var entry map[string]any
+		if err := json.Unmarshal(it.Config, &entry); err != nil || entry == nil {
+			return errors.New("agent config is not an object")
+		}
+	case "prompts", "workflows":
+		ext := ".md"
+		if it.Kind == "workflows" {
+			ext = ".json"
+		}
+		if len(it.Files) != 1 || it.Files[0].Path != it.Name+ext {
+			return fmt.Errorf("want the single file %s", it.Name+ext)
+		}
+		if it.Kind == "workflows" && !json.Valid(it.Files[0].Data) {
+			return errors.New("workflow is not valid JSON")
+		}
+	}
+	return nil
+}
+
+// Put writes a received item, or deletes it.
+func (s *Store) Put(it *Item) error {
+	if err := checkItem(it); err != nil {
+		return err
+	}
+	reason := "mirror " + it.Key()
+	switch it.Kind {
+	case "agents":
+		if err := s.putAgentConfig(it); err != nil {
+			return err
+		}
+		if err := syncFiles(filepath.Join(s.AgentsDir, it.Name), it.Files, false, it.Deleted); err != nil {
+			return err
+		}
+		if s.HistoryDB != "" {
+			if err := version.SnapshotConfig(s.HistoryDB, s.ConfigPath, "mirror", reason); err != nil {
+				log.Warn("mirror: config snapshot failed", "error", err)
+			}
+		}
+	case "prompts", "workflows":
+		dir, ext := s.fileDir(it.Kind)
+		path := filepath.Join(dir, it.Name+ext)
+		if it.Deleted {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		if err := writeFile(path, it.Files[0]); err != nil {
+			return err
+		}
+		if s.HistoryDB != "" {
+			content := string(it.Files[0].Data)
+			var err error
+			if it.Kind == "prompts" {
+				err = version.SnapshotPrompt(s.HistoryDB, it.Name, content, "mirror", reason)
+			} else {
+				err = version.SnapshotWorkflow(s.HistoryDB, it.Name, content, "mirror", reason)
+			}
+			if err != nil {
+				log.Warn("mirror: version snapshot failed", "item", it.Key(), "error", err)
+			}
+		}
+	case "skills":
+		return s.putSkill(it)
+	}
+	return nil
+}
+
+// putAgentConfig sets or removes the agent's config entry, keeping the
+// local keys of an existing entry.
+func (s *Store) putAgentConfig(it *Item) error {
+	raw, err := s.readConfig()
+	if err != nil {
+		return err
+	}
+	agents, key := configAgents(raw)
+	if agents == nil {
+		if it.Deleted {
+			return nil
+		}
+		agents = map[string]any{}
+		raw[key] = agents
+	}
+	if it.Deleted {
+		delete(agents, it.Name)
+	} else {
+		var entry map[string]any
+		if err := json.Unmarshal(it.Config, &entry); err != nil {
+			return err
+		}
+		for _, k := range localAgentKeys {
+			delete(entry, k)
+		}
+		if old, ok := agents[it.Name].(map[string]any); ok {
+			for _, k := range localAgentKeys {
+				if v, ok := old[k]; ok {
+					entry[k] = v
+				}
+			}
+		}
+		agents[it.Name] = entry
+	}
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return cfgfile.WriteJSON(s.ConfigPath, append(out, '\n'), 0o600)
+}
+
+// putSkill replaces the skill directory with the received files. They are
+// written to a hidden directory first, which skill loading skips, and
+// swapped in.
+func (s *Store) putSkill(it *Item) error {
+	dir := filepath.Join(s.SkillsDir, it.Name)
+	if it.Deleted {
+		return os.RemoveAll(dir)
+	}
+	old, _ := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	tmp := filepath.Join(s.SkillsDir, ".mirror-"+it.Name)
+	os.RemoveAll(tmp)
+	for _, f := range it.Files {
+		if f.Path == "metadata.json" {
+			f.Data = receiveSkillMeta(f.Data, old, dir)
+		}
+		if err := writeFile(filepath.Join(tmp, filepath.FromSlash(f.Path)), f); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+	prev := filepath.Join(s.SkillsDir, ".mirror-old-"+it.Name)
+	os.RemoveAll(prev)
+	if err := os.Rename(dir, prev); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.Rename(prev, dir)
+		return err
+	}
+	return os.RemoveAll(prev)
+}
+
+// syncFiles makes the top level (or, recursively, all) of dir hold exactly
+// files: missing and changed files are written, and files not in the list
+// removed. Hidden files are left alone. With remove, the files are removed
+// and dir too if that leaves it empty.
+func syncFiles(dir string, files []File, recursive, remove bool) error {
+	current, err := readFiles(dir, recursive)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	if !remove {
+		for _, f := range files {
+			keep[f.Path] = true
+			if err := writeFile(filepath.Join(dir, filepath.FromSlash(f.Path)), f); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range current {
+		if !keep[f.Path] {
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(f.Path))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	if remove {
+		os.Remove(dir) // only if empty
+	}
+	return nil
+}
+
+// writeFile writes f atomically, unless path already holds the same.
+func writeFile(path string, f File) error {
+	mode := os.FileMode(0o644)
+	if f.Exec {
+		mode = 0o755
+	}
+	if cur, err := os.ReadFile(path); err == nil && bytes.Equal(cur, f.Data) {
+		return os.Chmod(path, mode)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".mirror-" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, f.Data, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
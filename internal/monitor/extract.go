@@ -0,0 +1,606 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tetora/internal/tool"
+)
+
+// ErrNoMatch is returned when a selector or path matches nothing, which
+// usually means the page layout or API shape changed.
+var ErrNoMatch = errors.New("matched nothing")
+
+// --- HTML ---
+
+// node is an element or text node of a parsed HTML document.
+type node struct {
+	tag      string // lower-case element name; "" for text
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextTags hold text that is not parsed for markup.
+var rawTextTags = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// hiddenTags never contribute to extracted text.
+var hiddenTags = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "head": true}
+
+// autoClose lists, for elements whose end tag may be omitted, the open
+// elements a new start tag closes.
+var autoClose = map[string][]string{
+	"p": {"p"}, "li": {"li"}, "dt": {"dt", "dd"}, "dd": {"dt", "dd"}, "tr": {"tr", "td", "th"},
+	"td": {"td", "th"}, "th": {"td", "th"}, "option": {"option"},
+	"div": {"p"}, "ul": {"p"}, "ol": {"p"}, "table": {"p"}, "h1": {"p"}, "h2": {"p"}, "h3": {"p"},
+	"h4": {"p"}, "h5": {"p"}, "h6": {"p"}, "section": {"p"}, "article": {"p"}, "pre": {"p"},
+}
+
+// parseHTML builds a forgiving element tree: unknown end tags are ignored,
+// omitted end tags are inferred for the common cases, and nothing fails.
+func parseHTML(doc string) *node {
+	root := &node{tag: "#document"}
+	cur := root
+	appendChild := func(n *node) {
+		n.parent = cur
+		cur.children = append(cur.children, n)
+	}
+
+	for i := 0; i < len(doc); {
+		if doc[i] != '<' {
+			j := strings.IndexByte(doc[i:], '<')
+			if j < 0 {
+				j = len(doc) - i
+			}
+			appendChild(&node{text: html.UnescapeString(doc[i : i+j])})
+			i += j
+			continue
+		}
+		rest := doc[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			for n := cur; n != root; n = n.parent {
+				if n.tag == name {
+					cur = n.parent
+					break
+				}
+			}
+			i += end + 1
+		case len(rest) > 1 && isLetter(rest[1]):
+			n, size, selfClose := parseTag(rest)
+			for _, closes := range autoClose[n.tag] {
+				if cur.tag == closes {
+					cur = cur.parent
+					break
+				}
+			}
+			appendChild(n)
+			i += size
+			if rawTextTags[n.tag] {
+				end := strings.Index(strings.ToLower(doc[i:]), "</"+n.tag)
+				if end < 0 {
+					end = len(doc) - i
+				}
+				text := doc[i : i+end]
+				if n.tag == "title" || n.tag == "textarea" {
+					text = html.UnescapeString(text)
+				}
+				n.children = []*node{{text: text, parent: n}}
+				i += end
+				if gt := strings.IndexByte(doc[i:], '>'); gt >= 0 {
+					i += gt + 1
+				}
+				continue
+			}
+			if !selfClose && !voidTags[n.tag] {
+				cur = n
+			}
+		default:
+			appendChild(&node{text: "<"})
+			i++
+		}
+	}
+	return root
+}
+
+// parseTag reads a start tag at the beginning of s and returns the element,
+// the tag's length and whether it was written self-closing.
+func parseTag(s string) (n *node, size int, selfClose bool) {
+	i := 1
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	n = &node{tag: strings.ToLower(s[1:i]), attrs: map[string]string{}}
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return n, i + 1, selfClose
+		}
+		if s[i] == '/' {
+			selfClose = true
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		val := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				val = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				val = s[start:i]
+			}
+		}
+		if name != "" {
+			n.attrs[name] = html.UnescapeString(val)
+		}
+		selfClose = false
+	}
+	return n, len(s), selfClose
+}
+
+func isLetter(c byte) bool { return c|0x20 >= 'a' && c|0x20 <= 'z' }
+func isSpace(c byte) bool  { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+
+// textOf returns the visible text under n with whitespace collapsed.
+func textOf(n *node) string {
+	var parts []string
+	var walk func(*node)
+	walk = func(n *node) {
+		if n.tag == "" {
+			parts = append(parts, n.text)
+			return
+		}
+		if hiddenTags[n.tag] {
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+		if !voidTags[n.tag] {
+			parts = append(parts, " ")
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(strings.Join(parts, "")), " ")
+}
+
+// selectHTML returns the text of every element matching selector, one per
+// line, or the whole visible text when selector is empty.
+func selectHTML(doc, selector string) (string, error) {
+	root := parseHTML(doc)
+	if strings.TrimSpace(selector) == "" {
+		return textOf(root), nil
+	}
+	groups, err := parseSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	var walk func(*node)
+	walk = func(n *node) {
+		if n.tag != "" && n.tag != "#document" {
+			for _, chain := range groups {
+				if matchChain(chain, n) {
+					if t := textOf(n); t != "" {
+						lines = append(lines, t)
+					}
+					break
+				}
+			}
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(root)
+	if len(lines) == 0 {
+		return "", fmt.Errorf("selector %q %w", selector, ErrNoMatch)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// --- CSS selectors ---
+
+// compound is one simple-selector sequence, e.g. div.price#main[data-x].
+// comb is its relation to the compound on its left: ' ' (descendant) or '>'
+// (child).
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSel
+	comb    byte
+}
+
+type attrSel struct {
+	name, op, val string
+}
+
+// parseSelector supports type, universal, #id, .class and attribute
+// selectors ([a], [a=v], [a~=v], [a^=v], [a$=v], [a*=v]), descendant and
+// child combinators, and comma-separated groups.
+func parseSelector(sel string) ([][]compound, error) {
+	var groups [][]compound
+	for _, group := range splitTopLevel(sel, ',') {
+		var chain []compound
+		comb := byte(' ')
+		rest := strings.TrimSpace(group)
+		for rest != "" {
+			if rest[0] == '>' {
+				comb = '>'
+				rest = strings.TrimSpace(rest[1:])
+				continue
+			}
+			end := compoundEnd(rest)
+			c, err := parseCompound(rest[:end])
+			if err != nil {
+				return nil, fmt.Errorf("selector %q: %w", sel, err)
+			}
+			c.comb = comb
+			chain = append(chain, c)
+			comb = ' '
+			rest = strings.TrimSpace(rest[end:])
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("selector %q: empty group", sel)
+		}
+		groups = append(groups, chain)
+	}
+	return groups, nil
+}
+
+// splitTopLevel splits s on sep outside brackets and quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, quote, start := 0, byte(0), 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// compoundEnd returns where the compound selector at the start of s ends.
+func compoundEnd(s string) int {
+	depth, quote := 0, byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && (isSpace(c) || c == '>'):
+			return i
+		}
+	}
+	return len(s)
+}
+
+func parseCompound(s string) (compound, error) {
+	var c compound
+	i := 0
+	readIdent := func() string {
+		start := i
+		for i < len(s) && (isLetter(s[i]) || s[i] >= '0' && s[i] <= '9' || s[i] == '-' || s[i] == '_') {
+			i++
+		}
+		return s[start:i]
+	}
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else {
+		c.tag = strings.ToLower(readIdent())
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '#':
+			i++
+			c.id = readIdent()
+		case '.':
+			i++
+			c.classes = append(c.classes, readIdent())
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return c, fmt.Errorf("unclosed [")
+			}
+			a, err := parseAttrSel(s[i+1 : i+end])
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, a)
+			i += end + 1
+		default:
+			return c, fmt.Errorf("unsupported syntax at %q", s[i:])
+		}
+	}
+	return c, nil
+}
+
+func parseAttrSel(s string) (attrSel, error) {
+	for _, op := range []string{"~=", "^=", "$=", "*=", "="} {
+		if k := strings.Index(s, op); k > 0 {
+			val := strings.TrimSpace(s[k+len(op):])
+			if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+				val = val[1 : len(val)-1]
+			}
+			return attrSel{name: strings.ToLower(strings.TrimSpace(s[:k])), op: op, val: val}, nil
+		}
+	}
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return attrSel{}, fmt.Errorf("empty attribute selector")
+	}
+	return attrSel{name: name}, nil
+}
+
+func (c compound) match(n *node) bool {
+	if n == nil || n.tag == "" || n.tag == "#document" {
+		return false
+	}
+	if c.tag != "" && c.tag != n.tag {
+		return false
+	}
+	if c.id != "" && n.attrs["id"] != c.id {
+		return false
+	}
+	classes := strings.Fields(n.attrs["class"])
+	for _, want := range c.classes {
+		found := false
+		for _, have := range classes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		v, ok := n.attrs[a.name]
+		if !ok {
+			return false
+		}
+		switch a.op {
+		case "=":
+			ok = v == a.val
+		case "~=":
+			ok = false
+			for _, f := range strings.Fields(v) {
+				ok = ok || f == a.val
+			}
+		case "^=":
+			ok = strings.HasPrefix(v, a.val)
+		case "$=":
+			ok = strings.HasSuffix(v, a.val)
+		case "*=":
+			ok = strings.Contains(v, a.val)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchChain reports whether n matches the rightmost compound of chain with
+// ancestors matching the rest.
+func matchChain(chain []compound, n *node) bool {
+	last := len(chain) - 1
+	return chain[last].match(n) && matchAncestors(chain[:last], chain[last].comb, n)
+}
+
+func matchAncestors(chain []compound, comb byte, n *node) bool {
+	if len(chain) == 0 {
+		return true
+	}
+	last := len(chain) - 1
+	if comb == '>' {
+		p := n.parent
+		return chain[last].match(p) && matchAncestors(chain[:last], chain[last].comb, p)
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		if chain[last].match(p) && matchAncestors(chain[:last], chain[last].comb, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- JSON ---
+
+// selectJSON evaluates a JSONPath subset against doc: $, .key, ['key'],
+// [index] (negative counts from the end) and the [*] / .* wildcards. A
+// single scalar result is returned as-is; anything else as indented JSON,
+// one result after another.
+func selectJSON(doc []byte, path string) (string, error) {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	current := []any{root}
+	for _, step := range steps {
+		var next []any
+		for _, v := range current {
+			switch v := v.(type) {
+			case map[string]any:
+				if step == "*" {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if child, ok := v[step]; ok {
+					next = append(next, child)
+				}
+			case []any:
+				if step == "*" {
+					next = append(next, v...)
+				} else if idx, err := strconv.Atoi(step); err == nil {
+					if idx < 0 {
+						idx += len(v)
+					}
+					if idx >= 0 && idx < len(v) {
+						next = append(next, v[idx])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	if len(current) == 0 {
+		return "", fmt.Errorf("path %q %w", path, ErrNoMatch)
+	}
+	out := make([]string, len(current))
+	for i, v := range current {
+		out[i] = renderJSON(v)
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+func parseJSONPath(path string) ([]string, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	var steps []string
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+			steps = append(steps, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: unclosed [", path)
+			}
+			key := strings.TrimSpace(p[1:end])
+			if len(key) >= 2 && (key[0] == '\'' || key[0] == '"') && key[len(key)-1] == key[0] {
+				key = key[1 : len(key)-1]
+			}
+			steps = append(steps, key)
+			p = p[end+1:]
+		default:
+			// Bare "a.b" without the leading "$.".
+			p = "." + p
+		}
+	}
+	return steps, nil
+}
+
+func renderJSON(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case float64, bool:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
+
+// --- Feeds ---
+
+// feedObservation counts the items of an RSS or Atom feed. The item titles
+// travel along as detail so a change summary can say what was added.
+func feedObservation(doc []byte) (observation, error) {
+	_, items := tool.ParseFeedBytes(doc)
+	if len(items) == 0 {
+		return observation{}, fmt.Errorf("not an RSS or Atom feed, or the feed is empty")
+	}
+	lines := make([]string, len(items))
+	for i, it := range items {
+		lines[i] = "- " + it.Title
+		if it.Link != "" {
+			lines[i] += " (" + it.Link + ")"
+		}
+	}
+	return observation{
+		Value:  fmt.Sprintf("%d items", len(items)),
+		Detail: strings.Join(lines, "\n"),
+	}, nil
+}
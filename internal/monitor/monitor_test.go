@@ -0,0 +1,300 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head><title>Shop</title><script>var price = "<span class=price>$1</span>";</script></head>
+<body>
+<!-- <div class="price">$0</div> -->
+<div id=main class="card featured">
+  <h1>Widget &amp; Co</h1>
+  <span class="price sale">$19.99</span>
+  <p>In stock<p>Ships <b>today</b>
+</div>
+<ul class=list>
+  <li><a href="/item/1">First</a>
+  <li><a href="/item/2" data-new>Second</a>
+  <li><a href="/about">About</a>
+</ul>
+<img src=logo.png alt=logo>
+</body></html>`
+
+func TestSelectHTML(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{".price", "$19.99"},
+		{"span.price.sale", "$19.99"},
+		{"#main > p", "In stock\nShips today"},
+		{"ul.list li a[href^='/item']", "First\nSecond"},
+		{"a[data-new]", "Second"},
+		{"div.featured h1, title", "Shop\nWidget & Co"},
+		{"body > h1", ""},
+	}
+	for _, tt := range tests {
+		got, err := selectHTML(testPage, tt.selector)
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoMatch) {
+				t.Errorf("selectHTML(%q) = %q, %v; want ErrNoMatch", tt.selector, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("selectHTML(%q) = %q, %v; want %q", tt.selector, got, err, tt.want)
+		}
+	}
+
+	whole, err := selectHTML(testPage, "")
+	if err != nil || !strings.HasPrefix(whole, "Widget & Co $19.99 In stock Ships today") || strings.Contains(whole, "var price") {
+		t.Errorf("whole page = %q, %v", whole, err)
+	}
+	if _, err := selectHTML(testPage, "a:hover"); err == nil || errors.Is(err, ErrNoMatch) {
+		t.Errorf("unsupported selector error = %v", err)
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	doc := []byte(`{"data":{"count":2,"items":[{"name":"a","price":1.5,"tags":["x"]},{"name":"b","price":2,"tags":[]}]}}`)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$.data.count", "2"},
+		{"$.data.items[0].price", "1.5"},
+		{"$.data.items[-1].name", "b"},
+		{"$['data'].items[*].name", "a\nb"},
+		{"data.items.1.price", "2"},
+		{"$.data.items[0].tags", "[\n  \"x\"\n]"},
+	}
+	for _, tt := range tests {
+		got, err := selectJSON(doc, tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("selectJSON(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := selectJSON(doc, "$.data.missing"); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("missing path error = %v", err)
+	}
+	if _, err := selectJSON([]byte("<html>"), "$.a"); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff("a\nb\nc", "a\nc\nd")
+	if got != "- b\n+ d" {
+		t.Errorf("lineDiff = %q", got)
+	}
+	if got := lineDiff("", "x"); got != "+ x" {
+		t.Errorf("lineDiff from empty = %q", got)
+	}
+}
+
+// fakeSite serves whatever body the test sets.
+type fakeSite struct {
+	mu     sync.Mutex
+	body   string
+	status int
+}
+
+func (f *fakeSite) set(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Api-Key") != "k" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(f.status)
+	w.Write([]byte(f.body))
+}
+
+func newTestService(t *testing.T, targets ...config.MonitorTarget) (*Service, *[]string, *[]string, *dispatch.Task) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	cfg := &config.Config{
+		HistoryDB:     dbPath,
+		SmartDispatch: config.SmartDispatchConfig{DefaultAgent: "helper"},
+		Monitors:      config.MonitorsConfig{Enabled: true, Watch: targets},
+	}
+	var owner, discord []string
+	var task dispatch.Task
+	svc := New(cfg, Deps{
+		Executor: dispatch.TaskExecutorFunc(func(_ context.Context, tk dispatch.Task, agent string) dispatch.TaskResult {
+			task = tk
+			return dispatch.TaskResult{Status: "success", Output: "The price dropped.", CostUSD: 0.001}
+		}),
+		FillDefaults: func(_ *config.Config, t *dispatch.Task) { t.Model = "opus"; t.Budget = 5 },
+		Notify:       func(text string) { owner = append(owner, text) },
+	})
+	svc.RegisterSender("discord", func(dest, text string) error {
+		discord = append(discord, dest+"|"+text)
+		return nil
+	})
+	return svc, &owner, &discord, &task
+}
+
+func TestCheckReportsChanges(t *testing.T) {
+	site := &fakeSite{}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	svc, owner, discord, task := newTestService(t, config.MonitorTarget{
+		Name:    "price",
+		Type:    "json",
+		URL:     srv.URL,
+		Path:    "$.price",
+		Headers: map[string]string{"X-Api-Key": "k"},
+		Notify:  []string{"owner", "discord:42"},
+		Prompt:  "Say whether it is under $15.",
+	})
+	ctx := context.Background()
+
+	site.set(200, `{"price": 19.99}`)
+	res, err := svc.Check(ctx, "price")
+	if err != nil || !res.Baseline || res.Changed || res.Value != "19.99" {
+		t.Fatalf("first check = %+v, %v", res, err)
+	}
+	res, err = svc.Check(ctx, "price")
+	if err != nil || res.Baseline || res.Changed {
+		t.Fatalf("unchanged check = %+v, %v", res, err)
+	}
+	if len(*owner)+len(*discord) != 0 {
+		t.Fatalf("notified without a change: %v %v", *owner, *discord)
+	}
+
+	site.set(200, `{"price": 14.5}`)
+	res, err = svc.Check(ctx, "price")
+	if err != nil || !res.Changed || res.Change == nil {
+		t.Fatalf("changed check = %+v, %v", res, err)
+	}
+	if res.Change.Diff != "- 19.99\n+ 14.5" || res.Change.Summary != "The price dropped." {
+		t.Errorf("change = %+v", res.Change)
+	}
+	if task.Model != "haiku" || task.Budget != 0.05 || task.Agent != "helper" || task.Source != "monitor" ||
+		!strings.Contains(task.Prompt, "under $15") || !strings.Contains(task.Prompt, "+ 14.5") {
+		t.Errorf("summary task = model %q budget %v agent %q source %q", task.Model, task.Budget, task.Agent, task.Source)
+	}
+	if len(*owner) != 1 || !strings.Contains((*owner)[0], "[monitor price] changed\nThe price dropped.") {
+		t.Errorf("owner notifications = %q", *owner)
+	}
+	if len(*discord) != 1 || !strings.HasPrefix((*discord)[0], "42|[monitor price]") {
+		t.Errorf("discord notifications = %q", *discord)
+	}
+
+	changes, err := Changes(svc.dbPath, "price", 10)
+	if err != nil || len(changes) != 1 || changes[0].ID != res.Change.ID || changes[0].CostUSD != 0.001 {
+		t.Errorf("Changes = %+v, %v", changes, err)
+	}
+
+	// Repeated failures alert the owner once, on the third.
+	site.set(500, "")
+	for i := 0; i < 4; i++ {
+		if _, err := svc.Check(ctx, "price"); err == nil {
+			t.Fatal("check of a failing site succeeded")
+		}
+	}
+	if len(*owner) != 2 || !strings.Contains((*owner)[1], "failed 3 checks in a row") {
+		t.Errorf("owner notifications after failures = %q", *owner)
+	}
+	st := svc.Status()
+	if len(st) != 1 || st[0].Failures != 4 || !strings.Contains(st[0].LastError, "500") || st[0].LastChanged == "" {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Recovery resets the failure count without counting as a change.
+	site.set(200, `{"price": 14.5}`)
+	if res, err := svc.Check(ctx, "price"); err != nil || res.Changed {
+		t.Errorf("recovered check = %+v, %v", res, err)
+	}
+	if st := svc.Status(); st[0].Failures != 0 || st[0].LastError != "" {
+		t.Errorf("status after recovery = %+v", st[0])
+	}
+}
+
+func TestCheckFeedAndBrowser(t *testing.T) {
+	site := &fakeSite{}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+	feed := func(titles ...string) string {
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>`)
+		for _, title := range titles {
+			b.WriteString("<item><title>" + title + "</title><link>https://blog.example/" + title + "</link></item>")
+		}
+		b.WriteString("</channel></rss>")
+		return b.String()
+	}
+
+	svc, owner, _, _ := newTestService(t,
+		config.MonitorTarget{Name: "blog", Type: "rss", URL: srv.URL, Headers: map[string]string{"X-Api-Key": "k"}},
+		config.MonitorTarget{Name: "status", URL: "https://status.example", Selector: "#state", Browser: true},
+	)
+	ctx := context.Background()
+
+	site.set(200, feed("one"))
+	if _, err := svc.Check(ctx, "blog"); err != nil {
+		t.Fatal(err)
+	}
+	site.set(200, feed("two", "one"))
+	res, err := svc.Check(ctx, "blog")
+	if err != nil || !res.Changed {
+		t.Fatalf("feed check = %+v, %v", res, err)
+	}
+	if want := "1 items -> 2 items\n+ - two (https://blog.example/two)"; res.Change.Diff != want {
+		t.Errorf("feed diff = %q, want %q", res.Change.Diff, want)
+	}
+
+	if _, err := svc.Check(ctx, "status"); err == nil || !strings.Contains(err.Error(), "browser plugin not available") {
+		t.Errorf("browser check without plugin = %v", err)
+	}
+	state := "operational"
+	svc.deps.Browser = func(_ context.Context, url, selector string) (string, error) {
+		if url != "https://status.example" || selector != "#state" {
+			t.Errorf("browser called with %q %q", url, selector)
+		}
+		return state, nil
+	}
+	svc.Check(ctx, "status")
+	state = "degraded"
+	if res, err := svc.Check(ctx, "status"); err != nil || !res.Changed || res.Change.Diff != "- operational\n+ degraded" {
+		t.Errorf("browser change = %+v, %v", res, err)
+	}
+	if len(*owner) != 2 {
+		t.Errorf("owner notifications = %q", *owner)
+	}
+}
+
+func TestIntervalFor(t *testing.T) {
+	c := config.MonitorsConfig{Interval: "30m"}
+	if got := c.IntervalFor(config.MonitorTarget{}); got.String() != "30m0s" {
+		t.Errorf("default interval = %v", got)
+	}
+	if got := c.IntervalFor(config.MonitorTarget{Interval: "5s"}); got.String() != "1m0s" {
+		t.Errorf("short interval = %v", got)
+	}
+	if got := (config.MonitorsConfig{}).IntervalFor(config.MonitorTarget{Interval: "bogus"}); got.String() != "1h0m0s" {
+		t.Errorf("fallback interval = %v", got)
+	}
+}
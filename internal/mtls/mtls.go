@@ -0,0 +1,150 @@
+// Package mtls serves the HTTP API over TLS with optional client certificate
+// authentication. The server certificate, client CA bundle and identity map
+// can be swapped at runtime, so a config reload rotates them without a restart.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"tetora/internal/config"
+)
+
+// Server holds the current TLS settings.
+type Server struct {
+	cur atomic.Pointer[state]
+}
+
+type state struct {
+	cert   *tls.Certificate
+	tls    *tls.Config
+	cfg    config.TLSConfig
+	mutual bool
+}
+
+// New loads the certificate, key and client CA bundle in cfg.
+func New(cfg config.TLSConfig) (*Server, error) {
+	s := &Server{}
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the certificate, client CAs and identity map. On error the
+// previous settings stay in use. Connections already open keep their
+// handshake; new connections use the new files.
+func (s *Server) Reload(cfg config.TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls cert: %w", err)
+	}
+	st := &state{cert: &cert, cfg: cfg, mutual: cfg.MutualTLS()}
+	st.tls = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if st.mutual {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read tls.clientCAFile: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls.clientCAFile %s: no PEM certificates", cfg.ClientCAFile)
+		}
+		// Verified when given; Authenticate decides which paths require one,
+		// so /healthz stays reachable by probes without a certificate.
+		st.tls.ClientAuth = tls.VerifyClientCertIfGiven
+		st.tls.ClientCAs = pool
+	}
+	s.cur.Store(st)
+	return nil
+}
+
+// TLSConfig returns the config to serve with. Each handshake picks up the
+// settings current at that time.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.cur.Load().cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.cur.Load().tls, nil
+		},
+	}
+}
+
+// Mutual reports whether client certificates are required.
+func (s *Server) Mutual() bool {
+	return s.cur.Load().mutual
+}
+
+// ErrNoCertificate is returned by Authenticate for a connection without a
+// verified client certificate.
+var ErrNoCertificate = errors.New("client certificate required")
+
+// Exempt reports whether path is served without a client certificate.
+func (s *Server) Exempt(path string) bool {
+	if path == "/healthz" {
+		return true
+	}
+	for _, p := range s.cur.Load().cfg.ClientCertExempt {
+		if p == path || (strings.HasSuffix(p, "*") && strings.HasPrefix(path, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the access identity of a connection's verified client
+// certificate. With clientIdentities set, the first of the certificate's
+// names found in the map decides it, and a certificate with no mapped name is
+// refused. Otherwise the identity is the certificate's first name.
+func (s *Server) Authenticate(cs *tls.ConnectionState) (string, error) {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return "", ErrNoCertificate
+	}
+	names := Names(cs.VerifiedChains[0][0])
+	ids := s.cur.Load().cfg.ClientIdentities
+	if len(ids) == 0 {
+		if len(names) == 0 {
+			return "", errors.New("client certificate has no subject name")
+		}
+		return names[0], nil
+	}
+	for _, n := range names {
+		if id, ok := ids[n]; ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("client certificate %s is not in tls.clientIdentities", strings.Join(names, ", "))
+}
+
+// Names lists a certificate's subject CN and SANs as "cn:", "dns:", "email:",
+// "uri:" and "ip:" names, in that order.
+func Names(c *x509.Certificate) []string {
+	var names []string
+	if c.Subject.CommonName != "" {
+		names = append(names, "cn:"+c.Subject.CommonName)
+	}
+	for _, d := range c.DNSNames {
+		names = append(names, "dns:"+d)
+	}
+	for _, e := range c.EmailAddresses {
+		names = append(names, "email:"+e)
+	}
+	for _, u := range c.URIs {
+		names = append(names, "uri:"+u.String())
+	}
+	for _, ip := range c.IPAddresses {
+		names = append(names, "ip:"+ip.String())
+	}
+	return names
+}
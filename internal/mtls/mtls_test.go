@@ -0,0 +1,228 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM cert and key signed by ca.
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+}
+
+func (ca *testCA) client(t *testing.T, tmpl *x509.Certificate) tls.Certificate {
+	t.Helper()
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	c, k := ca.issue(t, tmpl)
+	cert, err := tls.X509KeyPair(c, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// setup writes a server cert from serverCA and clientCA's bundle, and returns
+// the TLS config pointing at them.
+func setup(t *testing.T, serverCA, clientCA *testCA) config.TLSConfig {
+	t.Helper()
+	dir := t.TempDir()
+	c, k := serverCA.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "tetora"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return config.TLSConfig{
+		CertFile:     writeFile(t, dir, "server.crt", c),
+		KeyFile:      writeFile(t, dir, "server.key", k),
+		ClientCAFile: writeFile(t, dir, "clients.pem", clientCA.pem),
+	}
+}
+
+// serve starts an HTTPS server that reports Authenticate's result.
+func serve(t *testing.T, s *Server) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.Authenticate(r.TLS)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(id))
+	}))
+	srv.TLS = s.TLSConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server, roots *testCA, cert *tls.Certificate) (int, string, error) {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(roots.cert)
+	tc := &tls.Config{RootCAs: pool}
+	if cert != nil {
+		tc.Certificates = []tls.Certificate{*cert}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 512)
+	n, _ := resp.Body.Read(buf)
+	return resp.StatusCode, string(buf[:n]), nil
+}
+
+func TestClientCertificateIdentity(t *testing.T) {
+	serverCA, clientCA, otherCA := newCA(t, "server CA"), newCA(t, "client CA"), newCA(t, "other CA")
+	cfg := setup(t, serverCA, clientCA)
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Mutual() {
+		t.Fatal("mutual TLS not on")
+	}
+	srv := serve(t, s)
+
+	ops := clientCA.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ops-laptop"}, DNSNames: []string{"ops.example.com"}})
+	if code, body, err := get(t, srv, serverCA, &ops); err != nil || code != 200 || body != "cn:ops-laptop" {
+		t.Errorf("ops cert: %d %q %v", code, body, err)
+	}
+	if code, body, _ := get(t, srv, serverCA, nil); code != 401 {
+		t.Errorf("no cert: %d %q", code, body)
+	}
+	stranger := otherCA.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}})
+	if code, _, err := get(t, srv, serverCA, &stranger); err == nil && code != 401 {
+		t.Errorf("cert from an unknown CA: %d", code)
+	}
+
+	// With an identity map, only mapped names are let in.
+	cfg.ClientIdentities = map[string]string{"dns:ops.example.com": "ops"}
+	if err := s.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if code, body, _ := get(t, srv, serverCA, &ops); code != 200 || body != "ops" {
+		t.Errorf("mapped cert: %d %q", code, body)
+	}
+	ci := clientCA.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ci"}})
+	if code, body, _ := get(t, srv, serverCA, &ci); code != 401 {
+		t.Errorf("unmapped cert: %d %q", code, body)
+	}
+}
+
+func TestReloadRotatesCertificates(t *testing.T) {
+	serverCA, clientCA := newCA(t, "server CA"), newCA(t, "client CA")
+	s, err := New(setup(t, serverCA, clientCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serve(t, s)
+	old := clientCA.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "old"}})
+
+	newServerCA, newClientCA := newCA(t, "server CA 2"), newCA(t, "client CA 2")
+	next := setup(t, newServerCA, newClientCA)
+	if err := s.Reload(next); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, err := get(t, srv, newServerCA, &old); err == nil && code != 401 {
+		t.Errorf("cert from the rotated-out CA: %d", code)
+	}
+	fresh := newClientCA.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "new"}})
+	if code, body, err := get(t, srv, newServerCA, &fresh); err != nil || body != "cn:new" {
+		t.Errorf("new cert: %d %q %v", code, body, err)
+	}
+
+	// A broken reload keeps the current settings.
+	bad := next
+	bad.ClientCAFile = filepath.Join(t.TempDir(), "missing.pem")
+	if err := s.Reload(bad); err == nil {
+		t.Error("reload with a missing CA file succeeded")
+	}
+	if code, _, err := get(t, srv, newServerCA, &fresh); err != nil || code != 200 {
+		t.Errorf("after failed reload: %d %v", code, err)
+	}
+}
+
+func TestExempt(t *testing.T) {
+	serverCA := newCA(t, "server CA")
+	cfg := setup(t, serverCA, newCA(t, "client CA"))
+	cfg.ClientCertExempt = []string{"/api/telegram/webhook", "/slack/*"}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"/healthz":              true,
+		"/api/telegram/webhook": true,
+		"/slack/events":         true,
+		"/api/tasks":            false,
+		"/dispatch":             false,
+	} {
+		if got := s.Exempt(path); got != want {
+			t.Errorf("Exempt(%q) = %v", path, got)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package nlp
+
+import "strings"
+
+var urgencyKeywordsEN = []string{
+	"asap", "urgent", "urgently", "hurry", "quickly", "quick question",
+	"right now", "immediately", "in a rush", "no time",
+}
+
+var urgencyKeywordsCJK = []string{
+	"至急", "急いで", "今すぐ", "時間がない",
+	"紧急", "赶紧", "马上", "快点", "来不及",
+}
+
+// Urgency returns the time-pressure cues found in text, such as "asap" or
+// "至急". An empty result means no sign that the user is in a hurry.
+func Urgency(text string) []string {
+	lower := strings.ToLower(text)
+	var cues []string
+	for _, kw := range urgencyKeywordsEN {
+		if ContainsWord(lower, kw) {
+			cues = append(cues, kw)
+		}
+	}
+	for _, kw := range urgencyKeywordsCJK {
+		if strings.Contains(text, kw) {
+			cues = append(cues, kw)
+		}
+	}
+	return cues
+}
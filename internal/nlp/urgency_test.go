@@ -0,0 +1,23 @@
+package nlp
+
+import "testing"
+
+func TestUrgency(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want int
+	}{
+		{"Can you fix this ASAP?", 1},
+		{"quick question: where is the config?", 1},
+		{"I need this right now, hurry", 2},
+		{"至急お願いします", 1},
+		{"赶紧帮我看一下", 1},
+		{"the wasap client is down", 0},
+		{"tell me about the urgency of climate policy", 0},
+		{"", 0},
+	} {
+		if got := Urgency(c.text); len(got) != c.want {
+			t.Errorf("Urgency(%q) = %v, want %d cues", c.text, got, c.want)
+		}
+	}
+}
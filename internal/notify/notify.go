@@ -9,7 +9,10 @@ import (
 	"sync"
 	"time"
 
+	"tetora/internal/audit"
 	"tetora/internal/config"
+	"tetora/internal/integration/mqtt"
+	"tetora/internal/integration/social"
 	"tetora/internal/log"
 	"tetora/internal/messaging/whatsapp"
 )
@@ -108,6 +111,75 @@ func BuildDiscordNotifierByName(cfg *config.Config, name string) *DiscordNotifie
 	return nil
 }
 
+// BuildNotifierByName returns a Notifier for the named channel (from cfg.Notifications), or nil.
+func BuildNotifierByName(cfg *config.Config, name string) Notifier {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, ch := range cfg.Notifications {
+		if ch.Name != name {
+			continue
+		}
+		switch ch.Type {
+		case "slack":
+			if ch.WebhookURL != "" {
+				return &SlackNotifier{WebhookURL: ch.WebhookURL, client: client}
+			}
+		case "discord":
+			if ch.WebhookURL != "" {
+				return &DiscordNotifier{WebhookURL: ch.WebhookURL, client: client}
+			}
+		case "bluesky", "mastodon":
+			if n := socialNotifier(cfg, ch); n != nil {
+				return n
+			}
+		case "mqtt":
+			if n := mqttNotifier(cfg, ch); n != nil {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// socialNotifier returns a notifier posting from the social account the
+// channel names (its account, or else its name), or nil.
+func socialNotifier(cfg *config.Config, ch config.NotificationChannel) Notifier {
+	name := ch.Account
+	if name == "" {
+		name = ch.Name
+	}
+	acct, ok := cfg.Social.Account(name)
+	if !ok || acct.Type != ch.Type {
+		log.Warn("notification channel names no matching social account", "channel", ch.Name, "account", name)
+		return nil
+	}
+	n, err := social.NewNotifier(acct)
+	if err != nil {
+		log.Warn("social notification channel disabled", "channel", ch.Name, "error", err)
+		return nil
+	}
+	return n
+}
+
+// mqttNotifier returns a notifier publishing to the channel's topic on the
+// MQTT broker it names (its account, or else its name), or nil.
+func mqttNotifier(cfg *config.Config, ch config.NotificationChannel) Notifier {
+	name := ch.Account
+	if name == "" {
+		name = ch.Name
+	}
+	b, ok := cfg.MQTT.Broker(name)
+	if !ok {
+		log.Warn("notification channel names no configured mqtt broker", "channel", ch.Name, "broker", name)
+		return nil
+	}
+	n, err := mqtt.NewNotifier(b, ch.Topic)
+	if err != nil {
+		log.Warn("mqtt notification channel disabled", "channel", ch.Name, "error", err)
+		return nil
+	}
+	return n
+}
+
 // BuildNotifiers creates Notifier instances from config.
 func BuildNotifiers(cfg *config.Config) []Notifier {
 	var notifiers []Notifier
@@ -122,6 +194,14 @@ func BuildNotifiers(cfg *config.Config) []Notifier {
 			if ch.WebhookURL != "" {
 				notifiers = append(notifiers, &DiscordNotifier{WebhookURL: ch.WebhookURL, client: client})
 			}
+		case "bluesky", "mastodon":
+			if n := socialNotifier(cfg, ch); n != nil {
+				notifiers = append(notifiers, n)
+			}
+		case "mqtt":
+			if n := mqttNotifier(cfg, ch); n != nil {
+				notifiers = append(notifiers, n)
+			}
 		default:
 			log.Warn("unknown notification type", "type", ch.Type)
 		}
@@ -193,8 +273,14 @@ func (m Message) DedupKey() string {
 
 // --- Notification Engine ---
 
+// AuditAction is the audit_log action recording each notification the engine
+// accepts, with the event type as source and "<priority>: <text>" as detail.
+// The day timeline lists them.
+const AuditAction = "notify.send"
+
 // Engine manages prioritized notification delivery with batching and dedup.
 type Engine struct {
+	historyDB     string // notifications are audited here; "" = not recorded
 	mu            sync.Mutex
 	channels      []notifyChannel
 	batchInterval time.Duration
@@ -219,6 +305,7 @@ type notifyChannel struct {
 // NewEngine creates a new notification engine.
 func NewEngine(cfg *config.Config, notifiers []Notifier, fallbackFn func(string)) *Engine {
 	ne := &Engine{
+		historyDB:  cfg.HistoryDB,
 		dedupSeen:  make(map[string]time.Time),
 		stopCh:     make(chan struct{}),
 		fallbackFn: fallbackFn,
@@ -267,10 +354,17 @@ func (ne *Engine) Stop() {
 	close(ne.stopCh)
 }
 
+// RedactFn scrubs personal data from notification text before delivery;
+// agent is the message's agent. Nil = sent as is.
+var RedactFn func(agent, text string) string
+
 // Notify sends a prioritized notification.
 // Critical and high priority messages are delivered immediately.
 // Normal and low priority messages are buffered for batch delivery.
 func (ne *Engine) Notify(msg Message) {
+	if RedactFn != nil {
+		msg.Text = RedactFn(msg.Agent, msg.Text)
+	}
 	if msg.Timestamp.IsZero() {
 		msg.Timestamp = time.Now()
 	}
@@ -282,6 +376,7 @@ func (ne *Engine) Notify(msg Message) {
 
 	// Critical/High: deliver immediately to eligible channels.
 	if rank >= PriorityRank(PriorityHigh) {
+		ne.record(msg)
 		ne.deliverImmediate(msg)
 		return
 	}
@@ -300,6 +395,16 @@ func (ne *Engine) Notify(msg Message) {
 	}
 	ne.dedupSeen[key] = msg.Timestamp
 	ne.buffer = append(ne.buffer, msg)
+	ne.record(msg)
+}
+
+// record audits a notification accepted for delivery.
+func (ne *Engine) record(msg Message) {
+	source := msg.EventType
+	if source == "" {
+		source = "notify"
+	}
+	audit.Log(ne.historyDB, AuditAction, source, msg.Priority+": "+msg.Text, "")
 }
 
 // NotifyText is a convenience method for backward compatibility.
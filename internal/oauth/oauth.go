@@ -28,6 +28,10 @@ var EncryptFn func(plaintext, key string) (string, error)
 // DecryptFn decrypts ciphertext after DB retrieval. Nil = no decryption.
 var DecryptFn func(ciphertext, key string) (string, error)
 
+// KeylessFn reports whether EncryptFn encrypts even without
+// oauth.encryptionKey, as envelope encryption does. Nil = it does not.
+var KeylessFn func() bool
+
 // --- Config Types (aliased from internal/config) ---
 
 type OAuthConfig = config.OAuthConfig
@@ -94,6 +98,31 @@ var OAuthTemplates = map[string]OAuthServiceConfig{
 		AuthURL:  "https://twitter.com/i/oauth2/authorize",
 		TokenURL: "https://api.twitter.com/2/oauth2/token",
 	},
+	"notion": {
+		AuthURL:     "https://api.notion.com/v1/oauth/authorize",
+		TokenURL:    "https://api.notion.com/v1/oauth/token",
+		ExtraParams: map[string]string{"owner": "user"},
+		TokenAuth:   "basic",
+	},
+}
+
+// tokenRequest builds a POST of form data to the token endpoint, with the
+// client credentials in the body or, for TokenAuth "basic", in the header.
+func tokenRequest(svcCfg *OAuthServiceConfig, data url.Values) (*http.Request, error) {
+	if svcCfg.TokenAuth != "basic" {
+		data.Set("client_id", svcCfg.ClientID)
+		data.Set("client_secret", svcCfg.ClientSecret)
+	}
+	req, err := http.NewRequest("POST", svcCfg.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	if svcCfg.TokenAuth == "basic" {
+		req.SetBasicAuth(svcCfg.ClientID, svcCfg.ClientSecret)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
 }
 
 // NewOAuthManager creates a new OAuthManager from explicit parameters.
@@ -292,11 +321,13 @@ func (m *OAuthManager) refreshTokenIfNeeded(serviceName string) (*OAuthToken, er
 	data := url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {token.RefreshToken},
-		"client_id":     {svcCfg.ClientID},
-		"client_secret": {svcCfg.ClientSecret},
 	}
 
-	resp, err := http.PostForm(svcCfg.TokenURL, data)
+	req, err := tokenRequest(svcCfg, data)
+	if err != nil {
+		return nil, fmt.Errorf("refresh request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("refresh request: %w", err)
 	}
@@ -352,6 +383,10 @@ func (m *OAuthManager) Request(ctx context.Context, serviceName, method, reqURL
 		tokenType = "Bearer"
 	}
 	req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	if body != nil {
+		// Provider APIs called through here (Twitter/X, Google) take JSON bodies.
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	return http.DefaultClient.Do(req)
 }
@@ -485,20 +520,16 @@ func (m *OAuthManager) HandleCallback(w http.ResponseWriter, r *http.Request, se
 
 	// Exchange code for token.
 	data := url.Values{
-		"grant_type":    {"authorization_code"},
-		"code":          {code},
-		"redirect_uri":  {redirectURL},
-		"client_id":     {svcCfg.ClientID},
-		"client_secret": {svcCfg.ClientSecret},
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
 	}
 
-	req, err := http.NewRequest("POST", svcCfg.TokenURL, strings.NewReader(data.Encode()))
+	req, err := tokenRequest(svcCfg, data)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"request creation: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -559,7 +590,7 @@ func (m *OAuthManager) HandleCallback(w http.ResponseWriter, r *http.Request, se
 	}
 
 	// Store token.
-	if m.encryptionKey == "" {
+	if m.encryptionKey == "" && (KeylessFn == nil || !KeylessFn()) {
 		slog.Warn("oauth storing token WITHOUT encryption — set oauth.encryptionKey for security", "service", serviceName)
 	}
 	if err := StoreOAuthToken(m.dbPath, token, m.encryptionKey); err != nil {
@@ -595,6 +626,7 @@ func (m *OAuthManager) ResolveServiceConfig(name string) (*OAuthServiceConfig, e
 	if hasTmpl {
 		result.AuthURL = tmpl.AuthURL
 		result.TokenURL = tmpl.TokenURL
+		result.TokenAuth = tmpl.TokenAuth
 		if tmpl.ExtraParams != nil {
 			result.ExtraParams = make(map[string]string)
 			for k, v := range tmpl.ExtraParams {
@@ -623,6 +655,9 @@ func (m *OAuthManager) ResolveServiceConfig(name string) (*OAuthServiceConfig, e
 		if userCfg.RedirectURL != "" {
 			result.RedirectURL = userCfg.RedirectURL
 		}
+		if userCfg.TokenAuth != "" {
+			result.TokenAuth = userCfg.TokenAuth
+		}
 		if userCfg.ExtraParams != nil {
 			if result.ExtraParams == nil {
 				result.ExtraParams = make(map[string]string)
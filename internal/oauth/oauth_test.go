@@ -288,7 +288,7 @@ func TestOAuthTemplates(t *testing.T) {
 	}
 
 	// Verify known templates exist.
-	for _, name := range []string{"google", "github", "twitter"} {
+	for _, name := range []string{"google", "github", "twitter", "notion"} {
 		if _, ok := OAuthTemplates[name]; !ok {
 			t.Errorf("missing template: %s", name)
 		}
@@ -0,0 +1,396 @@
+// Package objstore reads and writes objects in S3-compatible buckets (AWS
+// S3, Backblaze B2, Cloudflare R2, MinIO) and Google Cloud Storage through
+// their XML APIs. Requests are signed with AWS Signature V4, or carry a
+// bearer token for GCS.
+package objstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// ErrNotFound is returned when reading an object that does not exist.
+var ErrNotFound = errors.New("object not found")
+
+var httpClient = &http.Client{Timeout: 30 * time.Minute}
+
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Object describes a stored object. Key is relative to the client's prefix.
+type Object struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Client reads and writes objects under one bucket and prefix.
+type Client struct {
+	cfg      config.ObjectStoreConfig
+	provider string
+	endpoint *url.URL
+	virtual  bool // bucket in the host name rather than the path
+	region   string
+	prefix   string
+	token    func(ctx context.Context) (string, error)
+	now      func() time.Time
+}
+
+// New creates a client for cfg. token supplies a bearer token for GCS when
+// no HMAC keys are configured; it may be nil otherwise.
+func New(cfg config.ObjectStoreConfig, token func(ctx context.Context) (string, error)) (*Client, error) {
+	c := &Client{cfg: cfg, provider: cfg.Provider, region: cfg.Region, token: token, now: time.Now}
+	if c.provider == "" {
+		c.provider = "s3"
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("objstore: bucket is required")
+	}
+	endpoint := cfg.Endpoint
+	switch c.provider {
+	case "s3":
+		if c.region == "" {
+			c.region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = "https://s3." + c.region + ".amazonaws.com"
+			c.virtual = true
+		}
+	case "b2":
+		if endpoint == "" {
+			if c.region == "" {
+				return nil, fmt.Errorf("objstore: b2 needs a region (e.g. us-west-004) or an endpoint")
+			}
+			endpoint = "https://s3." + c.region + ".backblazeb2.com"
+		}
+	case "gcs":
+		if c.region == "" {
+			c.region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		if cfg.AccessKey == "" && token == nil {
+			return nil, fmt.Errorf("objstore: gcs needs HMAC keys or an oauth service")
+		}
+	default:
+		return nil, fmt.Errorf("objstore: unknown provider %q", cfg.Provider)
+	}
+	if c.provider != "gcs" && (cfg.AccessKey == "" || cfg.SecretKey == "") {
+		return nil, fmt.Errorf("objstore: accessKey and secretKey are required")
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("objstore: invalid endpoint %q", endpoint)
+	}
+	c.endpoint = u
+
+	switch cfg.Encryption {
+	case "", "AES256", "aws:kms":
+	default:
+		return nil, fmt.Errorf("objstore: unknown encryption %q", cfg.Encryption)
+	}
+	if cfg.CustomerKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.CustomerKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("objstore: customerKey must be a base64 256-bit key")
+		}
+		if cfg.Encryption != "" || cfg.KMSKeyID != "" {
+			return nil, fmt.Errorf("objstore: customerKey cannot be combined with encryption or kmsKeyId")
+		}
+	}
+
+	if p := strings.Trim(cfg.Prefix, "/"); p != "" {
+		c.prefix = p + "/"
+	}
+	return c, nil
+}
+
+// String returns the bucket and prefix as a URL, e.g. "s3://backups/tetora/".
+func (c *Client) String() string {
+	return c.provider + "://" + c.cfg.Bucket + "/" + c.prefix
+}
+
+// Put uploads size bytes from r to key.
+func (c *Client) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	h := http.Header{}
+	h.Set("Content-Type", "application/octet-stream")
+	c.encryptionHeaders(h, true)
+	resp, err := c.do(ctx, http.MethodPut, c.prefix+key, nil, r, size, h)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get opens key for reading. The caller closes the returned body.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	h := http.Header{}
+	c.encryptionHeaders(h, false)
+	resp, err := c.do(ctx, http.MethodGet, c.prefix+key, nil, nil, 0, h)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.prefix+key, nil, nil, 0, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the objects whose keys start with prefix, in key order.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var out []Object
+	marker := ""
+	for {
+		q := url.Values{"prefix": {c.prefix + prefix}}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", q, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			IsTruncated bool   `xml:"IsTruncated"`
+			NextMarker  string `xml:"NextMarker"`
+			Contents    []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+		}
+		err = xml.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("objstore: list: %w", err)
+		}
+		for _, o := range page.Contents {
+			out = append(out, Object{Key: strings.TrimPrefix(o.Key, c.prefix), Size: o.Size, Modified: o.LastModified})
+		}
+		if !page.IsTruncated || len(page.Contents) == 0 {
+			return out, nil
+		}
+		// NextMarker is only sent with a delimiter; the last key works everywhere.
+		marker = page.NextMarker
+		if marker == "" {
+			marker = page.Contents[len(page.Contents)-1].Key
+		}
+	}
+}
+
+// encryptionHeaders adds the server-side encryption headers. Customer keys
+// go on every request for the object; the others only when writing it.
+func (c *Client) encryptionHeaders(h http.Header, write bool) {
+	if c.cfg.CustomerKey != "" {
+		key, _ := base64.StdEncoding.DecodeString(c.cfg.CustomerKey)
+		if c.provider == "gcs" {
+			sum := sha256.Sum256(key)
+			h.Set("x-goog-encryption-algorithm", "AES256")
+			h.Set("x-goog-encryption-key", c.cfg.CustomerKey)
+			h.Set("x-goog-encryption-key-sha256", base64.StdEncoding.EncodeToString(sum[:]))
+		} else {
+			sum := md5.Sum(key)
+			h.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+			h.Set("x-amz-server-side-encryption-customer-key", c.cfg.CustomerKey)
+			h.Set("x-amz-server-side-encryption-customer-key-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		return
+	}
+	if !write {
+		return
+	}
+	if c.provider == "gcs" {
+		// GCS always encrypts; a KMS key name picks a customer-managed key.
+		if c.cfg.KMSKeyID != "" {
+			h.Set("x-goog-encryption-kms-key-name", c.cfg.KMSKeyID)
+		}
+		return
+	}
+	if c.cfg.Encryption != "" {
+		h.Set("x-amz-server-side-encryption", c.cfg.Encryption)
+		if c.cfg.Encryption == "aws:kms" && c.cfg.KMSKeyID != "" {
+			h.Set("x-amz-server-side-encryption-aws-kms-key-id", c.cfg.KMSKeyID)
+		}
+	}
+}
+
+// do sends a request for key (the bucket itself when key is empty) and
+// returns the response when its status is 2xx.
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, h http.Header) (*http.Response, error) {
+	u := *c.endpoint
+	plain, raw := "", ""
+	if !c.virtual {
+		plain, raw = "/"+c.cfg.Bucket, "/"+uriEncode(c.cfg.Bucket, true)
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	}
+	if key != "" || c.virtual {
+		plain, raw = plain+"/"+key, raw+"/"+uriEncode(key, false)
+	}
+	u.Path = c.endpoint.Path + plain
+	u.RawPath = c.endpoint.EscapedPath() + raw
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if err := c.authorize(ctx, req, body != nil); err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("objstore: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrNotFound
+	}
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	msg := strings.TrimSpace(string(data))
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		msg = e.Code + ": " + e.Message
+	}
+	return nil, fmt.Errorf("objstore: %s %s: status %d: %s", method, c.String()+strings.TrimPrefix(key, c.prefix), resp.StatusCode, msg)
+}
+
+// authorize signs req with SigV4, or sets the bearer token for GCS without
+// HMAC keys. Request bodies are streamed unsigned.
+func (c *Client) authorize(ctx context.Context, req *http.Request, hasBody bool) error {
+	if c.cfg.AccessKey == "" {
+		tok, err := c.token(ctx)
+		if err != nil {
+			return fmt.Errorf("objstore: token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+		return nil
+	}
+
+	payload := emptyHash
+	if hasBody {
+		payload = "UNSIGNED-PAYLOAD"
+	}
+	now := c.now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", stamp)
+	req.Header.Set("x-amz-content-sha256", payload)
+
+	names := []string{"host"}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || strings.HasPrefix(lk, "x-goog-") || lk == "content-type" {
+			names = append(names, lk)
+		}
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, n := range names {
+		v := req.Host
+		if n != "host" {
+			v = strings.TrimSpace(req.Header.Get(n))
+		}
+		headers.WriteString(n + ":" + v + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + c.cfg.SecretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKey, scope, signed, sig))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery encodes q sorted by key, as SigV4 requires.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and '/'
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
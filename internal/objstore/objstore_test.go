@@ -0,0 +1,283 @@
+package objstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// fakeBucket is an in-memory path-style bucket that records the headers of
+// the last request.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	last    http.Header
+	page    int // objects per list page
+}
+
+func (f *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last = r.Header.Clone()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") &&
+		!strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "<Error><Code>AccessDenied</Code><Message>no auth</Message></Error>", http.StatusForbidden)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "bkt" {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code><Message>no bucket</Message></Error>", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet && key == "":
+		prefix, marker := r.URL.Query().Get("prefix"), r.URL.Query().Get("marker")
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, prefix) && k > marker {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		truncated := f.page > 0 && len(keys) > f.page
+		if truncated {
+			keys = keys[:f.page]
+		}
+		fmt.Fprintf(w, "<ListBucketResult><IsTruncated>%v</IsTruncated>", truncated)
+		for _, k := range keys {
+			fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-01-02T03:04:05.000Z</LastModified></Contents>",
+				xmlEscape(k), len(f.objects[k]))
+		}
+		fmt.Fprint(w, "</ListBucketResult>")
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func newFake(t *testing.T, cfg config.ObjectStoreConfig) (*fakeBucket, *Client) {
+	t.Helper()
+	fake := &fakeBucket{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	cfg.Endpoint = srv.URL
+	cfg.Bucket = "bkt"
+	if cfg.AccessKey == "" {
+		cfg.AccessKey, cfg.SecretKey = "AKID", "secret"
+	}
+	c, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, c
+}
+
+func TestPutGetListDelete(t *testing.T) {
+	fake, c := newFake(t, config.ObjectStoreConfig{Prefix: "/tetora/", Region: "eu-west-1"})
+	ctx := context.Background()
+
+	for _, key := range []string{"backups/a b.db.bak", "backups/b+c.db.bak", "outputs/x.txt"} {
+		if err := c.Put(ctx, key, strings.NewReader("data:"+key), int64(len("data:"+key))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	if _, ok := fake.objects["tetora/backups/a b.db.bak"]; !ok {
+		t.Fatalf("objects = %v, want prefixed keys", fake.objects)
+	}
+	auth := fake.last.Get("Authorization")
+	if !strings.Contains(auth, "Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+		t.Errorf("authorization = %q", auth)
+	}
+	if fake.last.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		t.Errorf("content sha = %q", fake.last.Get("X-Amz-Content-Sha256"))
+	}
+
+	body, err := c.Get(ctx, "backups/b+c.db.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "data:backups/b+c.db.bak" {
+		t.Errorf("get = %q", data)
+	}
+	if _, err := c.Get(ctx, "backups/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get missing: %v", err)
+	}
+
+	fake.page = 1
+	objs, err := c.List(ctx, "backups/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 || objs[0].Key != "backups/a b.db.bak" || objs[1].Key != "backups/b+c.db.bak" {
+		t.Fatalf("list = %+v", objs)
+	}
+	if !objs[0].Modified.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("modified = %v", objs[0].Modified)
+	}
+
+	if err := c.Delete(ctx, "backups/a b.db.bak"); err != nil {
+		t.Fatal(err)
+	}
+	if objs, _ := c.List(ctx, ""); len(objs) != 2 {
+		t.Errorf("after delete: %+v", objs)
+	}
+}
+
+func TestEncryptionHeaders(t *testing.T) {
+	ctx := context.Background()
+
+	fake, c := newFake(t, config.ObjectStoreConfig{Encryption: "aws:kms", KMSKeyID: "key-1"})
+	if err := c.Put(ctx, "k", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if fake.last.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || fake.last.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "key-1" {
+		t.Errorf("sse headers = %v", fake.last)
+	}
+	if !strings.Contains(fake.last.Get("Authorization"), "x-amz-server-side-encryption;") {
+		t.Errorf("sse header not signed: %s", fake.last.Get("Authorization"))
+	}
+
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	fake, c = newFake(t, config.ObjectStoreConfig{CustomerKey: key})
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+	if fake.last.Get("X-Amz-Server-Side-Encryption-Customer-Key") != key || fake.last.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") == "" {
+		t.Errorf("sse-c headers on get = %v", fake.last)
+	}
+
+	fake, c = newFake(t, config.ObjectStoreConfig{Provider: "gcs", CustomerKey: key})
+	c.Put(ctx, "k", strings.NewReader("x"), 1)
+	if fake.last.Get("X-Goog-Encryption-Key") != key || fake.last.Get("X-Goog-Encryption-Key-Sha256") == "" {
+		t.Errorf("gcs csek headers = %v", fake.last)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	fake := &fakeBucket{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c, err := New(config.ObjectStoreConfig{Provider: "gcs", Endpoint: srv.URL, Bucket: "bkt"},
+		func(context.Context) (string, error) { return "tok", nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(context.Background(), "k", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.last.Get("Authorization"); got != "Bearer tok" {
+		t.Errorf("authorization = %q", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	bad := []config.ObjectStoreConfig{
+		{AccessKey: "a", SecretKey: "s"},
+		{Bucket: "b"},
+		{Bucket: "b", Provider: "azure", AccessKey: "a", SecretKey: "s"},
+		{Bucket: "b", Provider: "b2", AccessKey: "a", SecretKey: "s"},
+		{Bucket: "b", Provider: "gcs"},
+		{Bucket: "b", AccessKey: "a", SecretKey: "s", Encryption: "rot13"},
+		{Bucket: "b", AccessKey: "a", SecretKey: "s", CustomerKey: "short"},
+		{Bucket: "b", AccessKey: "a", SecretKey: "s", CustomerKey: key, Encryption: "AES256"},
+	}
+	for i, cfg := range bad {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, cfg)
+		}
+	}
+
+	c, err := New(config.ObjectStoreConfig{Bucket: "b", Region: "us-west-2", AccessKey: "a", SecretKey: "s", Prefix: "x"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.virtual || c.endpoint.Host != "s3.us-west-2.amazonaws.com" || c.String() != "s3://b/x/" {
+		t.Errorf("aws client = %+v", c)
+	}
+	c, err = New(config.ObjectStoreConfig{Provider: "b2", Bucket: "b", Region: "us-west-004", AccessKey: "a", SecretKey: "s"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.virtual || c.endpoint.Host != "s3.us-west-004.backblazeb2.com" {
+		t.Errorf("b2 client = %+v", c)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if got := uriEncode("backups/a b+c~.db", false); got != "backups/a%20b%2Bc~.db" {
+		t.Errorf("uriEncode = %q", got)
+	}
+	if got := uriEncode("a/b", true); got != "a%2Fb" {
+		t.Errorf("uriEncode slash = %q", got)
+	}
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	d := NewDir(t.TempDir())
+
+	if objs, err := d.List(ctx, ""); err != nil || len(objs) != 0 {
+		t.Fatalf("empty list = %v, %v", objs, err)
+	}
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		if err := d.Put(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Put(ctx, "../escape", strings.NewReader("x"), 1); err == nil {
+		t.Error("expected error for key outside the directory")
+	}
+	objs, err := d.List(ctx, "a/")
+	if err != nil || len(objs) != 2 || objs[1].Key != "a/2" || objs[1].Size != 3 {
+		t.Fatalf("list = %+v, %v", objs, err)
+	}
+	body, err := d.Get(ctx, "b/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "b/1" {
+		t.Errorf("get = %q", data)
+	}
+	if err := d.Delete(ctx, "b/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(ctx, "b/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted: %v", err)
+	}
+	if err := d.Delete(ctx, "b/1"); err != nil {
+		t.Errorf("delete missing: %v", err)
+	}
+}
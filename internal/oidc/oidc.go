@@ -0,0 +1,561 @@
+// Package oidc implements OpenID Connect single sign-on for the dashboard.
+//
+// Login uses the authorization code flow with PKCE. The ID token is verified
+// against the provider's published keys, its claims are mapped to a dashboard
+// role (viewer, operator or admin), and a server-side session is created so
+// that logging out invalidates it immediately.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// Dashboard roles, from least to most privileged.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is a dashboard role.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// ErrForbidden is returned when a user authenticates but may not use the dashboard.
+var ErrForbidden = errors.New("not allowed to use the dashboard")
+
+// stateTTL bounds how long a login may take at the provider.
+const stateTTL = 10 * time.Minute
+
+// Session is a signed-in dashboard user.
+type Session struct {
+	ID        string    `json:"-"` // cookie value; empty for sessions restored from the database
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Role      string    `json:"role"`
+	Groups    []string  `json:"groups,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	idToken   string
+}
+
+type loginState struct {
+	verifier    string
+	nonce       string
+	redirectURL string
+	returnTo    string
+	createdAt   time.Time
+}
+
+type discovery struct {
+	Issuer             string `json:"issuer"`
+	AuthorizationURL   string `json:"authorization_endpoint"`
+	TokenURL           string `json:"token_endpoint"`
+	JWKSURL            string `json:"jwks_uri"`
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+}
+
+// Manager runs logins against one provider and holds dashboard sessions.
+type Manager struct {
+	cfg    config.OIDCConfig
+	dbPath string
+	client *http.Client
+
+	mu          sync.Mutex
+	states      map[string]loginState
+	sessions    map[string]*Session
+	disc        *discovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// NewManager creates a manager and restores unexpired sessions from dbPath.
+func NewManager(cfg config.OIDCConfig, dbPath string) *Manager {
+	m := &Manager{
+		cfg:      cfg,
+		dbPath:   dbPath,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		states:   make(map[string]loginState),
+		sessions: make(map[string]*Session),
+	}
+	m.loadSessions()
+	return m
+}
+
+// InitDB creates the dashboard_sessions table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS dashboard_sessions (
+		id TEXT PRIMARY KEY,
+		subject TEXT NOT NULL,
+		email TEXT DEFAULT '',
+		name TEXT DEFAULT '',
+		role TEXT NOT NULL,
+		groups TEXT DEFAULT '[]',
+		id_token TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		revoked_at TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_dashboard_sessions_subject ON dashboard_sessions(subject);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(fmt.Sprintf("oidc: read random: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// --- Login flow ---
+
+// AuthURL starts a login and returns the provider URL to redirect to and the
+// state value, which the caller should also bind to the browser (a cookie)
+// and compare on callback. returnTo is the local path to land on afterwards.
+func (m *Manager) AuthURL(ctx context.Context, redirectURL, returnTo string) (authURL, state string, err error) {
+	d, err := m.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	st := loginState{
+		verifier:    randomString(32),
+		nonce:       randomString(16),
+		redirectURL: redirectURL,
+		returnTo:    returnTo,
+		createdAt:   time.Now(),
+	}
+	state = randomString(16)
+
+	m.mu.Lock()
+	for k, v := range m.states {
+		if time.Since(v.createdAt) > stateTTL {
+			delete(m.states, k)
+		}
+	}
+	m.states[state] = st
+	m.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(st.verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {m.cfg.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(m.cfg.ScopesOrDefault(), " ")},
+		"state":                 {state},
+		"nonce":                 {st.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationURL, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationURL + sep + params.Encode(), state, nil
+}
+
+// Exchange completes a login: it redeems code, verifies the ID token, maps
+// the user to a role and opens a session. It returns the session and the
+// local path the login started from.
+func (m *Manager) Exchange(ctx context.Context, state, code string) (*Session, string, error) {
+	m.mu.Lock()
+	st, ok := m.states[state]
+	delete(m.states, state)
+	m.mu.Unlock()
+	if !ok || time.Since(st.createdAt) > stateTTL {
+		return nil, "", fmt.Errorf("invalid or expired login state")
+	}
+
+	d, err := m.discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {st.redirectURL},
+		"client_id":     {m.cfg.ClientID},
+		"code_verifier": {st.verifier},
+	}
+	if m.cfg.ClientSecret != "" {
+		form.Set("client_secret", m.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil || tok.IDToken == "" {
+		return nil, "", fmt.Errorf("token response has no id_token")
+	}
+
+	claims, err := verifyJWT(ctx, tok.IDToken, m.key)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateClaims(claims, d.Issuer, m.cfg.ClientID, st.nonce, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	sess := &Session{
+		ID:        randomString(32),
+		CreatedAt: time.Now().UTC(),
+		idToken:   tok.IDToken,
+	}
+	sess.Subject, _ = claims["sub"].(string)
+	sess.Email, _ = claims["email"].(string)
+	sess.Name, _ = claims["name"].(string)
+	sess.Groups = stringList(claims[m.cfg.GroupsClaimOrDefault()])
+	// Anyone can put an address they do not own on some providers' accounts,
+	// so the email only maps to a role once the provider has verified it.
+	mapped := ""
+	if emailVerified(claims) {
+		mapped = sess.Email
+	}
+	sess.Role, err = m.roleFor(mapped, sess.Groups)
+	if err != nil {
+		return nil, "", err
+	}
+	sess.ExpiresAt = sess.CreatedAt.Add(m.cfg.SessionTTLOrDefault())
+	if err := m.saveSession(sess); err != nil {
+		log.Warn("oidc: persist session failed", "error", err)
+	}
+	m.mu.Lock()
+	m.sessions[hashID(sess.ID)] = sess
+	m.mu.Unlock()
+	return sess, st.returnTo, nil
+}
+
+// roleFor applies allowedGroups and the roles mapping. The most privileged
+// role matched by any group (or the verified email) wins.
+func (m *Manager) roleFor(email string, groups []string) (string, error) {
+	if len(m.cfg.AllowedGroups) > 0 {
+		allowed := false
+		for _, g := range groups {
+			if containsString(m.cfg.AllowedGroups, g) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", ErrForbidden
+		}
+	}
+	role := ""
+	for _, key := range append(append([]string{}, groups...), email) {
+		if r := m.cfg.Roles[key]; key != "" && roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	if role == "" {
+		role = m.cfg.DefaultRoleOrDefault()
+	}
+	if !ValidRole(role) {
+		return "", ErrForbidden
+	}
+	return role, nil
+}
+
+// --- Sessions ---
+
+// Session returns a live session by ID.
+func (m *Manager) Session(id string) (*Session, bool) {
+	if id == "" {
+		return nil, false
+	}
+	key := hashID(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(s.ExpiresAt) {
+		delete(m.sessions, key)
+		return nil, false
+	}
+	return s, true
+}
+
+// Logout revokes a session and returns the provider's end-session URL (empty
+// when the provider has none), which signs the user out there as well.
+func (m *Manager) Logout(ctx context.Context, id, postLogoutURL string) string {
+	key := hashID(id)
+	m.mu.Lock()
+	s, ok := m.sessions[key]
+	delete(m.sessions, key)
+	m.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	if m.dbPath != "" {
+		if err := db.ExecArgs(m.dbPath, `UPDATE dashboard_sessions SET revoked_at = ? WHERE id = ?`,
+			time.Now().UTC().Format(time.RFC3339), key); err != nil {
+			log.Warn("oidc: revoke session failed", "error", err)
+		}
+	}
+
+	d, err := m.discover(ctx)
+	if err != nil || d.EndSessionEndpoint == "" {
+		return ""
+	}
+	params := url.Values{"client_id": {m.cfg.ClientID}}
+	if s.idToken != "" {
+		params.Set("id_token_hint", s.idToken)
+	}
+	if postLogoutURL != "" {
+		params.Set("post_logout_redirect_uri", postLogoutURL)
+	}
+	return d.EndSessionEndpoint + "?" + params.Encode()
+}
+
+// hashID keys sessions in memory and in the database, so a leaked database
+// cannot be replayed as cookies.
+func hashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Manager) saveSession(s *Session) error {
+	if m.dbPath == "" {
+		return nil
+	}
+	groups, _ := json.Marshal(s.Groups)
+	return db.ExecArgs(m.dbPath, `INSERT INTO dashboard_sessions
+		(id, subject, email, name, role, groups, id_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		hashID(s.ID), s.Subject, s.Email, s.Name, s.Role, string(groups), s.idToken,
+		s.CreatedAt.Format(time.RFC3339), s.ExpiresAt.Format(time.RFC3339))
+}
+
+// loadSessions restores sessions persisted by a previous daemon.
+func (m *Manager) loadSessions() {
+	if m.dbPath == "" {
+		return
+	}
+	rows, err := db.QueryArgs(m.dbPath, `SELECT id, subject, email, name, role, groups, id_token, created_at, expires_at
+		FROM dashboard_sessions WHERE revoked_at = '' AND expires_at > ?`, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		s := &Session{
+			Subject: db.Str(row["subject"]),
+			Email:   db.Str(row["email"]),
+			Name:    db.Str(row["name"]),
+			Role:    db.Str(row["role"]),
+			idToken: db.Str(row["id_token"]),
+		}
+		json.Unmarshal([]byte(db.Str(row["groups"])), &s.Groups)
+		s.CreatedAt, _ = time.Parse(time.RFC3339, db.Str(row["created_at"]))
+		s.ExpiresAt, _ = time.Parse(time.RFC3339, db.Str(row["expires_at"]))
+		m.sessions[db.Str(row["id"])] = s
+	}
+}
+
+// --- Provider metadata ---
+
+// discover fetches and caches the provider's OpenID configuration.
+func (m *Manager) discover(ctx context.Context) (*discovery, error) {
+	m.mu.Lock()
+	d := m.disc
+	m.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	issuer := strings.TrimRight(m.cfg.Issuer, "/")
+	if issuer == "" {
+		return nil, fmt.Errorf("oidc issuer not configured")
+	}
+	d = &discovery{}
+	if err := m.getJSON(ctx, issuer+"/.well-known/openid-configuration", d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if d.AuthorizationURL == "" || d.TokenURL == "" || d.JWKSURL == "" {
+		return nil, fmt.Errorf("oidc discovery: incomplete provider metadata")
+	}
+	if d.Issuer == "" {
+		d.Issuer = issuer
+	}
+
+	m.mu.Lock()
+	m.disc = d
+	m.mu.Unlock()
+	return d, nil
+}
+
+// keyRefetchInterval limits JWKS refetches triggered by unknown key IDs.
+const keyRefetchInterval = time.Minute
+
+// key returns the provider's signing key for kid. The key set is refetched
+// when kid is unknown, so provider key rotation needs no restart.
+func (m *Manager) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	m.mu.Lock()
+	k, ok := m.lookupKey(kid)
+	stale := time.Since(m.keysFetched) > keyRefetchInterval
+	m.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	d, err := m.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := m.getJSON(ctx, d.JWKSURL, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		pub, err := j.publicKey()
+		if err != nil {
+			log.Debug("oidc: skipping signing key", "kid", j.Kid, "error", err)
+			continue
+		}
+		keys[j.Kid] = pub
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = keys
+	m.keysFetched = time.Now()
+	if k, ok := m.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds kid in the cached key set. A token without a kid matches
+// when the provider publishes exactly one key. Callers hold m.mu.
+func (m *Manager) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if k, ok := m.keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(m.keys) == 1 {
+		for _, k := range m.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (m *Manager) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// --- Authorization ---
+
+// adminPrefixes are paths whose mutations change daemon configuration,
+// credentials or stored data. Operators may read them but not write.
+var adminPrefixes = []string{
+	"/api/admin/", "/api/config", "/config", "/api/setup/", "/api/settings",
+	"/api/plugins", "/api/mcp", "/mcp", "/api/hooks/install", "/api/hooks/remove",
+	"/api/pairing/", "/api/oauth/", "/api/claude-mcp/toggle", "/api/provider-test",
+	"/api/inference-mode", "/api/workspace/file", "/data/", "/retention", "/backup",
+	"/trust", "/stats/ab/promote", "/api/mirror/",
+}
+
+// adminReads are paths that expose data sensitive enough to hide from
+// viewers and operators entirely. /config/... serves stored config versions
+// and lints of the files on disk, which carry apiToken and other secrets;
+// /api/mirror/items serves every agent's and skill's full content.
+var adminReads = []string{"/data/export", "/audit", "/traces/", "/config", "/api/mirror/items"}
+
+// selfService are paths any signed-in role may use to manage its own login,
+// such as enrolling a second factor.
+var selfService = []string{"/api/auth/"}
+
+// Allows reports whether role may make a request with method to path.
+// Viewers are read-only, operators may run tasks, cron jobs and workflows
+// and pause or resume the budget but not change configuration, and admins
+// may do anything.
+func Allows(role, method, path string) bool {
+	switch role {
+	case RoleAdmin:
+		return true
+	case RoleOperator, RoleViewer:
+	default:
+		return false
+	}
+	if hasPrefix(path, adminReads) {
+		return false
+	}
+	if hasPrefix(path, selfService) {
+		return true
+	}
+	safe := method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	if safe {
+		return true
+	}
+	if role == RoleViewer {
+		return false
+	}
+	return !hasPrefix(path, adminPrefixes)
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// fakeProvider is a minimal OpenID provider that issues ID tokens carrying
+// whatever claims the test sets.
+type fakeProvider struct {
+	t      *testing.T
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+
+	// Captured from the last authorize redirect.
+	challenge string
+	nonce     string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.srv.URL,
+			"authorization_endpoint": p.srv.URL + "/authorize",
+			"token_endpoint":         p.srv.URL + "/token",
+			"jwks_uri":               p.srv.URL + "/jwks",
+			"end_session_endpoint":   p.srv.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims := map[string]any{
+			"iss": p.srv.URL, "aud": "tetora", "sub": "user-1", "nonce": p.nonce,
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(claims)})
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *fakeProvider) sign(claims map[string]any) string {
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := enc(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// login runs the flow through AuthURL and Exchange as the browser would.
+func (p *fakeProvider) login(m *Manager) (*Session, string, error) {
+	p.t.Helper()
+	authURL, state, err := m.AuthURL(context.Background(), "https://tetora.example/dashboard/oidc/callback", "/dashboard")
+	if err != nil {
+		p.t.Fatalf("AuthURL: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("state") != state || q.Get("client_id") != "tetora" {
+		p.t.Fatalf("authorize URL = %s", authURL)
+	}
+	p.challenge, p.nonce = q.Get("code_challenge"), q.Get("nonce")
+	return m.Exchange(context.Background(), state, "code-1")
+}
+
+func testConfig(issuer string) config.OIDCConfig {
+	return config.OIDCConfig{
+		Enabled:  true,
+		Issuer:   issuer,
+		ClientID: "tetora",
+		Roles:    map[string]string{"eng": "operator", "sre": "admin", "boss@example.com": "admin"},
+	}
+}
+
+func TestLoginMapsRoles(t *testing.T) {
+	p := newFakeProvider(t)
+	m := NewManager(testConfig(p.srv.URL), "")
+
+	tests := []struct {
+		claims map[string]any
+		role   string
+	}{
+		{map[string]any{"groups": []string{"eng"}}, RoleOperator},
+		{map[string]any{"groups": []string{"eng", "sre"}}, RoleAdmin},
+		{map[string]any{"email": "boss@example.com", "email_verified": true}, RoleAdmin},
+		{map[string]any{"email": "boss@example.com", "email_verified": "true"}, RoleAdmin},
+		{map[string]any{"email": "boss@example.com"}, RoleViewer},
+		{map[string]any{"email": "boss@example.com", "email_verified": false, "groups": []string{"eng"}}, RoleOperator},
+		{map[string]any{"groups": "other"}, RoleViewer},
+	}
+	for _, tt := range tests {
+		p.claims = tt.claims
+		sess, returnTo, err := p.login(m)
+		if err != nil {
+			t.Fatalf("login %v: %v", tt.claims, err)
+		}
+		if sess.Role != tt.role || returnTo != "/dashboard" {
+			t.Errorf("claims %v: role %q returnTo %q, want %q", tt.claims, sess.Role, returnTo, tt.role)
+		}
+		if got, ok := m.Session(sess.ID); !ok || got != sess {
+			t.Errorf("Session(%q) not found", sess.ID)
+		}
+	}
+}
+
+func TestLoginRejects(t *testing.T) {
+	p := newFakeProvider(t)
+	cfg := testConfig(p.srv.URL)
+	cfg.AllowedGroups = []string{"eng", "sre"}
+	m := NewManager(cfg, "")
+
+	p.claims = map[string]any{"groups": []string{"marketing"}}
+	if _, _, err := p.login(m); !errors.Is(err, ErrForbidden) {
+		t.Errorf("login outside allowedGroups = %v", err)
+	}
+
+	cfg.AllowedGroups = nil
+	cfg.DefaultRole = "none"
+	m = NewManager(cfg, "")
+	if _, _, err := p.login(m); !errors.Is(err, ErrForbidden) {
+		t.Errorf("login with no mapped role = %v", err)
+	}
+
+	p.claims = map[string]any{"groups": []string{"eng"}, "aud": "someone-else"}
+	if _, _, err := p.login(m); err == nil || !strings.Contains(err.Error(), "client") {
+		t.Errorf("login with wrong audience = %v", err)
+	}
+
+	if _, _, err := m.Exchange(context.Background(), "unknown-state", "code"); err == nil {
+		t.Error("Exchange accepted an unknown state")
+	}
+}
+
+func TestLogoutAndPersistence(t *testing.T) {
+	p := newFakeProvider(t)
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	m := NewManager(testConfig(p.srv.URL), dbPath)
+
+	p.claims = map[string]any{"groups": []string{"sre"}, "email": "ops@example.com"}
+	kept, _, err := p.login(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, _, err := p.login(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end := m.Logout(context.Background(), dropped.ID, "https://tetora.example/dashboard/login")
+	if !strings.HasPrefix(end, p.srv.URL+"/logout?") || !strings.Contains(end, "id_token_hint=") {
+		t.Errorf("end-session URL = %q", end)
+	}
+	if _, ok := m.Session(dropped.ID); ok {
+		t.Error("session still valid after logout")
+	}
+
+	// A restarted daemon restores the live session and not the revoked one.
+	m2 := NewManager(testConfig(p.srv.URL), dbPath)
+	got, ok := m2.Session(kept.ID)
+	if !ok || got.Role != RoleAdmin || got.Email != "ops@example.com" {
+		t.Errorf("restored session = %+v, %v", got, ok)
+	}
+	if _, ok := m2.Session(dropped.ID); ok {
+		t.Error("revoked session restored")
+	}
+	if end := m2.Logout(context.Background(), kept.ID, ""); !strings.Contains(end, "id_token_hint=") {
+		t.Errorf("end-session URL after restore = %q", end)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role, method, path string
+		want               bool
+	}{
+		{RoleViewer, "GET", "/tasks", true},
+		{RoleViewer, "POST", "/dispatch", false},
+		{RoleViewer, "GET", "/audit", false},
+		{RoleOperator, "GET", "/traces/http-a1b2c3d4", false},
+		{RoleOperator, "POST", "/dispatch", true},
+		{RoleOperator, "GET", "/api/config/summary", true},
+		{RoleViewer, "GET", "/config/versions/1", false},
+		{RoleOperator, "GET", "/config/versions/1/diff/2", false},
+		{RoleOperator, "GET", "/config/validate", false},
+		{RoleAdmin, "GET", "/config/versions/1", true},
+		{RoleOperator, "POST", "/api/mirror/items", false},
+		{RoleOperator, "POST", "/api/mirror/run", false},
+		{RoleOperator, "GET", "/api/mirror/items", false},
+		{RoleOperator, "GET", "/api/mirror/status", true},
+		{RoleAdmin, "POST", "/api/mirror/run", true},
+		{RoleOperator, "POST", "/api/config/toggle", false},
+		{RoleOperator, "POST", "/budget/pause", true},
+		{RoleViewer, "POST", "/budget/pause", false},
+		{RoleViewer, "POST", "/cron/j1/trigger", false},
+		{RoleOperator, "POST", "/api/plugins/reload", false},
+		{RoleOperator, "GET", "/data/export", false},
+		{RoleAdmin, "POST", "/data/purge", true},
+		{RoleViewer, "POST", "/api/auth/totp/setup", true},
+		{"", "GET", "/tasks", false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.role, tt.method, tt.path); got != tt.want {
+			t.Errorf("Allows(%q, %s, %s) = %v, want %v", tt.role, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
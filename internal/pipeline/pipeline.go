@@ -0,0 +1,261 @@
+// Package pipeline runs lightweight scheduled data pipelines.
+//
+// A pipeline covers the common "pull data, then ask an agent about it"
+// pattern without a full workflow: one fetch (an HTTP endpoint, a feed or a
+// configured database), an optional transformation (a JMESPath/jq expression,
+// named variables and a text template), and one dispatch, either a task for
+// an agent whose answer is sent to the pipeline's destinations or the
+// rendered text sent as is. Pipelines are stored in the history DB, managed
+// through /api/pipelines and run on a cron schedule or on demand.
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"tetora/internal/cron"
+	"tetora/internal/db"
+	"tetora/internal/messaging/webhook"
+)
+
+// Errors returned by the store.
+var (
+	ErrNotFound = errors.New("pipeline not found")
+	ErrExists   = errors.New("pipeline already exists")
+)
+
+// Pipeline is one stored pipeline definition.
+type Pipeline struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Schedule    string    `json:"schedule,omitempty"` // 5-field cron expression; empty runs on demand only
+	TZ          string    `json:"tz,omitempty"`       // schedule time zone (default local)
+	Enabled     *bool     `json:"enabled,omitempty"`  // default true
+	Fetch       Fetch     `json:"fetch"`
+	Transform   Transform `json:"transform,omitempty"`
+	Dispatch    Dispatch  `json:"dispatch"`
+	CreatedAt   string    `json:"createdAt,omitempty"`
+	UpdatedAt   string    `json:"updatedAt,omitempty"`
+}
+
+// Fetch is where a pipeline's data comes from.
+type Fetch struct {
+	Type    string            `json:"type,omitempty"`    // "http" (default), "feed" or "database"
+	URL     string            `json:"url,omitempty"`     // http, feed
+	Method  string            `json:"method,omitempty"`  // http: default GET
+	Headers map[string]string `json:"headers,omitempty"` // http, feed: values support $ENV_VAR
+	Body    string            `json:"body,omitempty"`    // http: request body
+	Limit   int               `json:"limit,omitempty"`   // feed: newest items kept (default 20)
+
+	Connection string `json:"connection,omitempty"` // database: name under databases.connections
+	Query      string `json:"query,omitempty"`      // database: read-only SQL
+}
+
+// Transform shapes the fetched data into the text that is dispatched.
+type Transform struct {
+	Expr      string            `json:"expr,omitempty"`      // JMESPath or jq-style expression applied to the data
+	Vars      map[string]string `json:"vars,omitempty"`      // named expressions evaluated against the (transformed) data
+	Template  string            `json:"template,omitempty"`  // text template (default the data as JSON)
+	SkipEmpty bool              `json:"skipEmpty,omitempty"` // skip the dispatch when the data is empty
+}
+
+// Dispatch is what happens with the rendered text.
+type Dispatch struct {
+	Type     string   `json:"type,omitempty"`     // "agent" (default) or "notify"
+	Agent    string   `json:"agent,omitempty"`    // agent: default pipelines.agent
+	Prompt   string   `json:"prompt,omitempty"`   // agent: task prompt; the text is appended unless it uses {{text}}
+	Model    string   `json:"model,omitempty"`    // agent: overrides pipelines.model
+	Budget   float64  `json:"budget,omitempty"`   // agent: overrides pipelines.budget
+	Notify   []string `json:"notify,omitempty"`   // "owner", "discord:<channelId>", "telegram:<chatId>", "webhook:<notification name>", "dashboard"
+	OnChange bool     `json:"onChange,omitempty"` // skip the dispatch when the text equals the last dispatched text
+}
+
+// IsEnabled reports whether the pipeline runs on its schedule (default true).
+func (p Pipeline) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// FetchType returns the fetch type, or "http".
+func (f Fetch) FetchType() string {
+	if f.Type != "" {
+		return f.Type
+	}
+	return "http"
+}
+
+// DispatchType returns the dispatch type, or "agent".
+func (d Dispatch) DispatchType() string {
+	if d.Type != "" {
+		return d.Type
+	}
+	return "agent"
+}
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// Validate checks a definition before it is stored.
+func (p Pipeline) Validate() error {
+	if !nameRe.MatchString(p.Name) {
+		return fmt.Errorf("invalid name %q (letters, digits, '_', '.', '-'; at most 64)", p.Name)
+	}
+	if p.Schedule != "" {
+		if _, err := cron.Parse(p.Schedule); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+	}
+	if p.TZ != "" {
+		if _, err := time.LoadLocation(p.TZ); err != nil {
+			return fmt.Errorf("tz: %w", err)
+		}
+	}
+	switch f := p.Fetch; f.FetchType() {
+	case "http", "feed":
+		if !strings.HasPrefix(f.URL, "http://") && !strings.HasPrefix(f.URL, "https://") {
+			return fmt.Errorf("fetch.url must be an http(s) URL")
+		}
+	case "database":
+		if f.Connection == "" || strings.TrimSpace(f.Query) == "" {
+			return fmt.Errorf("fetch.connection and fetch.query are required for a database fetch")
+		}
+	default:
+		return fmt.Errorf("unknown fetch.type %q (use http, feed or database)", f.Type)
+	}
+	if p.Transform.Expr != "" {
+		if _, err := webhook.Compile(p.Transform.Expr); err != nil {
+			return fmt.Errorf("transform.expr: %w", err)
+		}
+	}
+	if err := webhook.ValidateTransform(p.Transform.Vars); err != nil {
+		return err
+	}
+	switch p.Dispatch.DispatchType() {
+	case "agent":
+		if strings.TrimSpace(p.Dispatch.Prompt) == "" {
+			return fmt.Errorf("dispatch.prompt is required for an agent dispatch")
+		}
+	case "notify":
+	default:
+		return fmt.Errorf("unknown dispatch.type %q (use agent or notify)", p.Dispatch.Type)
+	}
+	return nil
+}
+
+// --- Store ---
+
+// InitDB creates the pipelines and pipeline_runs tables.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS pipelines (
+		name TEXT PRIMARY KEY,
+		definition TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS pipeline_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		triggered_by TEXT DEFAULT '',
+		status TEXT NOT NULL,
+		error TEXT DEFAULT '',
+		text TEXT DEFAULT '',
+		output TEXT DEFAULT '',
+		hash TEXT DEFAULT '',
+		task_id TEXT DEFAULT '',
+		cost_usd REAL DEFAULT 0,
+		started_at TEXT NOT NULL,
+		finished_at TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_pipeline_runs_name ON pipeline_runs(name, id);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// List returns every stored pipeline, by name.
+func List(dbPath string) ([]Pipeline, error) {
+	rows, err := db.Query(dbPath, `SELECT definition, created_at, updated_at FROM pipelines ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Pipeline, 0, len(rows))
+	for _, r := range rows {
+		p, err := decodeRow(r)
+		if err != nil {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Get returns the pipeline called name.
+func Get(dbPath, name string) (Pipeline, error) {
+	rows, err := db.QueryArgs(dbPath, `SELECT definition, created_at, updated_at FROM pipelines WHERE name = ?`, name)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	if len(rows) == 0 {
+		return Pipeline{}, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return decodeRow(rows[0])
+}
+
+func decodeRow(r map[string]any) (Pipeline, error) {
+	var p Pipeline
+	if err := json.Unmarshal([]byte(db.Str(r["definition"])), &p); err != nil {
+		return Pipeline{}, fmt.Errorf("decode pipeline: %w", err)
+	}
+	p.CreatedAt = db.Str(r["created_at"])
+	p.UpdatedAt = db.Str(r["updated_at"])
+	return p, nil
+}
+
+// Create validates and stores a new pipeline.
+func Create(dbPath string, p Pipeline) (Pipeline, error) {
+	if err := p.Validate(); err != nil {
+		return Pipeline{}, err
+	}
+	if _, err := Get(dbPath, p.Name); err == nil {
+		return Pipeline{}, fmt.Errorf("%w: %q", ErrExists, p.Name)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	p.CreatedAt, p.UpdatedAt = now, now
+	return p, save(dbPath, p, `INSERT INTO pipelines (definition, name, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		p.Name, now, now)
+}
+
+// Update validates and replaces an existing pipeline.
+func Update(dbPath string, p Pipeline) (Pipeline, error) {
+	if err := p.Validate(); err != nil {
+		return Pipeline{}, err
+	}
+	old, err := Get(dbPath, p.Name)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	p.CreatedAt = old.CreatedAt
+	p.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return p, save(dbPath, p, `UPDATE pipelines SET definition = ?, updated_at = ? WHERE name = ?`,
+		p.UpdatedAt, p.Name)
+}
+
+// save runs query with the encoded definition as its first argument.
+func save(dbPath string, p Pipeline, query string, args ...any) error {
+	def := p
+	def.CreatedAt, def.UpdatedAt = "", ""
+	b, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	return db.ExecArgs(dbPath, query, append([]any{string(b)}, args...)...)
+}
+
+// Delete removes a pipeline and its run history.
+func Delete(dbPath, name string) error {
+	if _, err := Get(dbPath, name); err != nil {
+		return err
+	}
+	return db.ExecArgs(dbPath, `DELETE FROM pipelines WHERE name = ?; DELETE FROM pipeline_runs WHERE name = ?`, name, name)
+}
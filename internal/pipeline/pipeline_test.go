@@ -0,0 +1,261 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+func TestValidate(t *testing.T) {
+	ok := Pipeline{
+		Name:     "daily-sales",
+		Schedule: "0 9 * * 1-5",
+		Fetch:    Fetch{URL: "https://api.example/sales"},
+		Dispatch: Dispatch{Prompt: "Summarize yesterday's sales."},
+	}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid pipeline: %v", err)
+	}
+	tests := []struct {
+		name   string
+		mutate func(p *Pipeline)
+		want   string
+	}{
+		{"name", func(p *Pipeline) { p.Name = "bad name" }, "invalid name"},
+		{"schedule", func(p *Pipeline) { p.Schedule = "every day" }, "schedule"},
+		{"tz", func(p *Pipeline) { p.TZ = "Mars/Olympus" }, "tz"},
+		{"url", func(p *Pipeline) { p.Fetch.URL = "file:///etc/passwd" }, "fetch.url"},
+		{"database", func(p *Pipeline) { p.Fetch = Fetch{Type: "database", Connection: "main"} }, "fetch.query"},
+		{"fetch type", func(p *Pipeline) { p.Fetch.Type = "ftp" }, "fetch.type"},
+		{"expr", func(p *Pipeline) { p.Transform.Expr = "items[" }, "transform.expr"},
+		{"vars", func(p *Pipeline) { p.Transform.Vars = map[string]string{"n": "length("} }, "transform n"},
+		{"prompt", func(p *Pipeline) { p.Dispatch.Prompt = " " }, "dispatch.prompt"},
+		{"dispatch type", func(p *Pipeline) { p.Dispatch.Type = "workflow" }, "dispatch.type"},
+	}
+	for _, tt := range tests {
+		p := ok
+		tt.mutate(&p)
+		if err := p.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	notify := Pipeline{Name: "n", Fetch: Fetch{Type: "feed", URL: "https://blog.example/rss"}, Dispatch: Dispatch{Type: "notify"}}
+	if err := notify.Validate(); err != nil {
+		t.Errorf("notify pipeline without a prompt: %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	values := map[string]any{
+		"data": map[string]any{
+			"total": 3.0,
+			"items": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}},
+		},
+		"vars":     map[string]any{"top": "b"},
+		"pipeline": "sales",
+		"text":     "report",
+	}
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"{{data.total}} items", "3 items"},
+		{"first {{data.items.0.name}}, last {{ data.items.-1.name }}", "first a, last b"},
+		{"{{vars.top}} in {{pipeline}}: {{text}}", "b in sales: report"},
+		{"[{{data.missing}}] [{{data.items.9}}] [{{vars.none}}]", "[] [] []"},
+		{"{{data.items.0}}", "{\n  \"name\": \"a\"\n}"},
+		{"{{other}}", "{{other}}"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.tmpl, values); got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+
+	if got := buildPrompt("Rank them:\n{{text}}", values); got != "Rank them:\nreport" {
+		t.Errorf("prompt with {{text}} = %q", got)
+	}
+	if got := buildPrompt("Rank them.", values); !strings.HasPrefix(got, "Rank them.\n\n") || !strings.Contains(got, "<data>\nreport\n</data>") {
+		t.Errorf("prompt without {{text}} = %q", got)
+	}
+}
+
+// fakeAPI serves whatever body the test sets.
+type fakeAPI struct {
+	mu   sync.Mutex
+	body string
+}
+
+func (f *fakeAPI) set(body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.body = body
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer k" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Write([]byte(f.body))
+}
+
+func newTestService(t *testing.T) (*Service, *[]string, *[]dispatch.Task) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	cfg := &config.Config{
+		HistoryDB:     dbPath,
+		SmartDispatch: config.SmartDispatchConfig{DefaultAgent: "helper"},
+		Pipelines:     config.PipelinesConfig{Enabled: true, Model: "haiku"},
+	}
+	var sent []string
+	var tasks []dispatch.Task
+	svc := New(cfg, Deps{
+		Executor: dispatch.TaskExecutorFunc(func(_ context.Context, tk dispatch.Task, agent string) dispatch.TaskResult {
+			tasks = append(tasks, tk)
+			return dispatch.TaskResult{Status: "success", Output: "Sales are up.", CostUSD: 0.002}
+		}),
+		NewID:        func() string { return "task-1" },
+		FillDefaults: func(_ *config.Config, t *dispatch.Task) { t.Model = "opus"; t.Budget = 5 },
+		Notify:       func(text string) { sent = append(sent, "owner|"+text) },
+	})
+	svc.RegisterSender("dashboard", func(dest, text string) error {
+		sent = append(sent, "dashboard|"+text)
+		return nil
+	})
+	return svc, &sent, &tasks
+}
+
+func TestRunAgentPipeline(t *testing.T) {
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	svc, sent, tasks := newTestService(t)
+	ctx := context.Background()
+
+	p, err := Create(svc.dbPath, Pipeline{
+		Name:  "sales",
+		Fetch: Fetch{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer k"}},
+		Transform: Transform{
+			Expr:     "orders[?status == 'paid']",
+			Vars:     map[string]string{"count": "length(@)", "total": "sum([*].amount)"},
+			Template: "{{vars.count}} paid orders, {{vars.total}} total. Largest: {{data.0.id}}",
+		},
+		Dispatch: Dispatch{Prompt: "Is this a good day for {{pipeline}}?", Notify: []string{"dashboard"}, OnChange: true},
+	})
+	if err != nil || p.CreatedAt == "" {
+		t.Fatalf("Create = %+v, %v", p, err)
+	}
+	if _, err := Create(svc.dbPath, p); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate Create = %v", err)
+	}
+
+	api.set(`{"orders":[{"id":"o2","status":"paid","amount":30},{"id":"o1","status":"refunded","amount":5},{"id":"o3","status":"paid","amount":12}]}`)
+	preview, err := svc.Run(ctx, "sales", true)
+	if err != nil || preview.Status != StatusPreview || preview.Vars["count"] != 2.0 || !strings.Contains(preview.Prompt, "good day for sales?") {
+		t.Fatalf("dry run = %+v, %v", preview, err)
+	}
+	if len(*tasks)+len(*sent) != 0 {
+		t.Fatal("dry run dispatched")
+	}
+
+	run, err := svc.Run(ctx, "sales", false)
+	if err != nil || run.Status != StatusSuccess || run.ID == 0 {
+		t.Fatalf("run = %+v, %v", run, err)
+	}
+	if run.Text != "2 paid orders, 42 total. Largest: o2" || run.Output != "Sales are up." || run.TaskID != "task-1" {
+		t.Errorf("run = %+v", run)
+	}
+	if len(*tasks) != 1 {
+		t.Fatalf("tasks = %d", len(*tasks))
+	}
+	task := (*tasks)[0]
+	if task.Agent != "helper" || task.Source != "pipeline" || task.Model != "haiku" || task.Budget != 5 ||
+		!strings.Contains(task.Prompt, "<data>\n2 paid orders") {
+		t.Errorf("task = agent %q source %q model %q budget %v prompt %q", task.Agent, task.Source, task.Model, task.Budget, task.Prompt)
+	}
+	if len(*sent) != 1 || (*sent)[0] != "dashboard|[pipeline sales]\nSales are up." {
+		t.Errorf("sent = %q", *sent)
+	}
+
+	// Same data: onChange skips the dispatch.
+	if run, _ := svc.Run(ctx, "sales", false); run.Status != StatusUnchanged || len(*tasks) != 1 {
+		t.Errorf("unchanged run = %+v", run)
+	}
+	api.set(`{"orders":[{"id":"o4","status":"paid","amount":7}]}`)
+	if run, _ := svc.Run(ctx, "sales", false); run.Status != StatusSuccess || len(*tasks) != 2 {
+		t.Errorf("changed run = %+v", run)
+	}
+
+	runs, err := Runs(svc.dbPath, "sales", 10)
+	if err != nil || len(runs) != 3 || runs[0].Status != StatusSuccess || runs[1].Status != StatusUnchanged || runs[2].CostUSD != 0.002 {
+		t.Errorf("Runs = %+v, %v", runs, err)
+	}
+
+	if err := Delete(svc.dbPath, "sales"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Run(ctx, "sales", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("run of deleted pipeline = %v", err)
+	}
+	if runs, _ := Runs(svc.dbPath, "sales", 10); len(runs) != 0 {
+		t.Errorf("runs survived delete: %d", len(runs))
+	}
+}
+
+func TestRunNotifyPipeline(t *testing.T) {
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	svc, sent, tasks := newTestService(t)
+	ctx := context.Background()
+
+	_, err := Create(svc.dbPath, Pipeline{
+		Name:      "blog",
+		Fetch:     Fetch{Type: "feed", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer k"}, Limit: 1},
+		Transform: Transform{Template: "New: {{data.0.title}} {{data.0.link}}", SkipEmpty: true},
+		Dispatch:  Dispatch{Type: "notify"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.set(`<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>` +
+		`<item><title>Two</title><link>https://blog.example/2</link></item>` +
+		`<item><title>One</title><link>https://blog.example/1</link></item></channel></rss>`)
+	run, err := svc.Run(ctx, "blog", false)
+	if err != nil || run.Status != StatusSuccess || run.Text != "New: Two https://blog.example/2" {
+		t.Fatalf("run = %+v, %v", run, err)
+	}
+	if len(*tasks) != 0 || len(*sent) != 1 || (*sent)[0] != "owner|[pipeline blog]\nNew: Two https://blog.example/2" {
+		t.Errorf("tasks = %d, sent = %q", len(*tasks), *sent)
+	}
+
+	// Empty data skips the dispatch; a fetch failure is recorded.
+	p, _ := Get(svc.dbPath, "blog")
+	p.Fetch = Fetch{URL: srv.URL, Headers: p.Fetch.Headers}
+	p.Transform.Expr = "items"
+	if _, err := Update(svc.dbPath, p); err != nil {
+		t.Fatal(err)
+	}
+	api.set(`{"items":[]}`)
+	if run, _ := svc.Run(ctx, "blog", false); run.Status != StatusSkipped || len(*sent) != 1 {
+		t.Errorf("empty run = %+v", run)
+	}
+	p.Fetch.Headers = nil
+	Update(svc.dbPath, p)
+	if run, _ := svc.Run(ctx, "blog", false); run.Status != StatusError || !strings.Contains(run.Error, "401") {
+		t.Errorf("failing run = %+v", run)
+	}
+}
@@ -0,0 +1,531 @@
+package plugin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// --- Packages ---
+
+// Package is a plugin release manifest. It is published on its own (a
+// manifest URL) or as one entry of a registry index.
+type Package struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`    // plugin type; default "tool"
+	Runtime     string   `json:"runtime,omitempty"` // "process" (default) or "wasm"
+	Tools       []string `json:"tools,omitempty"`
+	// Permissions are what the plugin says it needs ("exec", "network",
+	// "filesystem:~/Downloads", ...). They are shown before installing;
+	// wasm capabilities are still enforced by the module's own manifest.
+	Permissions []string `json:"permissions,omitempty"`
+	// Platforms maps "GOOS/GOARCH" (or "wasm") to the artifact to download.
+	Platforms map[string]Artifact `json:"platforms"`
+}
+
+// Artifact is one downloadable build of a package.
+type Artifact struct {
+	URL    string `json:"url"` // absolute, or relative to the manifest/index
+	SHA256 string `json:"sha256"`
+	// Bin is the executable (or .wasm module) inside the archive. Default:
+	// the package name, plus ".exe" on Windows or ".wasm" for wasm.
+	Bin string `json:"bin,omitempty"`
+}
+
+// Index is a registry listing of packages, possibly several versions each.
+type Index struct {
+	Plugins []Package `json:"plugins"`
+}
+
+// Installed is the record written next to an installed plugin binary.
+type Installed struct {
+	Package     Package `json:"package"`
+	Source      string  `json:"source"`
+	Pinned      bool    `json:"pinned,omitempty"`
+	Path        string  `json:"path"`   // absolute path of the binary or module
+	SHA256      string  `json:"sha256"` // of the file at Path
+	InstalledAt string  `json:"installedAt"`
+}
+
+// installedFile is the record's name inside each version directory.
+const installedFile = "installed.json"
+
+// maxDownloadBytes bounds manifests, indexes and artifacts.
+const maxDownloadBytes = 256 << 20
+
+// Platform returns the platform key this package installs for.
+func (p *Package) Platform() string {
+	if p.Runtime == "wasm" {
+		return "wasm"
+	}
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+func (p *Package) validate() error {
+	if p.Name == "" || p.Version == "" {
+		return fmt.Errorf("manifest must set name and version")
+	}
+	if strings.ContainsAny(p.Name, `/\`) || p.Name == "." || p.Name == ".." {
+		return fmt.Errorf("invalid plugin name %q", p.Name)
+	}
+	if strings.ContainsAny(p.Version, `/\`) || p.Version == "." || p.Version == ".." {
+		return fmt.Errorf("invalid version %q", p.Version)
+	}
+	return nil
+}
+
+// Resolve finds the package to install. source is a manifest URL or path
+// (ending in .json), or a plugin name looked up in the registry index.
+// version selects a release; empty means the newest.
+func Resolve(ctx context.Context, source, version, registry string) (*Package, error) {
+	if strings.HasSuffix(source, ".json") || strings.Contains(source, "://") {
+		data, base, err := fetch(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		var pkg Package
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, fmt.Errorf("parse manifest %s: %w", source, err)
+		}
+		if err := pkg.validate(); err != nil {
+			return nil, err
+		}
+		if version != "" && pkg.Version != version {
+			return nil, fmt.Errorf("manifest %s is version %s, not %s", source, pkg.Version, version)
+		}
+		pkg.absolutize(base)
+		return &pkg, nil
+	}
+
+	if registry == "" {
+		return nil, fmt.Errorf("%q is not a manifest and no pluginRegistry is configured", source)
+	}
+	data, base, err := fetch(ctx, registry)
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parse registry index: %w", err)
+	}
+	var best *Package
+	for i := range idx.Plugins {
+		p := &idx.Plugins[i]
+		if p.Name != source || (version != "" && p.Version != version) {
+			continue
+		}
+		if best == nil || CompareVersions(p.Version, best.Version) > 0 {
+			best = p
+		}
+	}
+	if best == nil {
+		if version != "" {
+			return nil, fmt.Errorf("plugin %s@%s not found in registry", source, version)
+		}
+		return nil, fmt.Errorf("plugin %q not found in registry", source)
+	}
+	if err := best.validate(); err != nil {
+		return nil, err
+	}
+	best.absolutize(base)
+	return best, nil
+}
+
+// absolutize resolves relative artifact URLs against the manifest location.
+func (p *Package) absolutize(base string) {
+	for k, a := range p.Platforms {
+		a.URL = resolveRef(base, a.URL)
+		p.Platforms[k] = a
+	}
+}
+
+// Install downloads the package's artifact for this platform, verifies its
+// checksum and unpacks it into dir/<name>/<version>. An existing install of
+// the same version is replaced.
+func Install(ctx context.Context, pkg *Package, dir, source string, pinned bool) (*Installed, error) {
+	platform := pkg.Platform()
+	art, ok := pkg.Platforms[platform]
+	if !ok {
+		var have []string
+		for k := range pkg.Platforms {
+			have = append(have, k)
+		}
+		sort.Strings(have)
+		return nil, fmt.Errorf("%s %s has no build for %s (available: %s)",
+			pkg.Name, pkg.Version, platform, strings.Join(have, ", "))
+	}
+	if art.SHA256 == "" {
+		return nil, fmt.Errorf("%s %s: artifact for %s has no sha256", pkg.Name, pkg.Version, platform)
+	}
+
+	data, _, err := fetch(ctx, art.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, art.SHA256) {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", art.URL, got, art.SHA256)
+	}
+
+	bin := art.Bin
+	if bin == "" {
+		bin = pkg.Name
+		switch {
+		case pkg.Runtime == "wasm":
+			bin += ".wasm"
+		case runtime.GOOS == "windows":
+			bin += ".exe"
+		}
+	}
+
+	dest := filepath.Join(dir, pkg.Name, pkg.Version)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), "."+pkg.Version+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := unpack(data, tmp, bin); err != nil {
+		return nil, fmt.Errorf("unpack %s: %w", art.URL, err)
+	}
+	binPath := filepath.Join(tmp, filepath.FromSlash(bin))
+	info, err := os.Stat(binPath)
+	if err != nil || info.IsDir() {
+		return nil, fmt.Errorf("%s not found in artifact", bin)
+	}
+	if pkg.Runtime != "wasm" {
+		os.Chmod(binPath, info.Mode()|0o755)
+	}
+	binSum, err := fileSHA256(binPath)
+	if err != nil {
+		return nil, err
+	}
+
+	os.RemoveAll(dest)
+	if err := os.Rename(tmp, dest); err != nil {
+		return nil, err
+	}
+	abs, _ := filepath.Abs(filepath.Join(dest, filepath.FromSlash(bin)))
+	rec := &Installed{
+		Package:     *pkg,
+		Source:      source,
+		Pinned:      pinned,
+		Path:        abs,
+		SHA256:      binSum,
+		InstalledAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	out, _ := json.MarshalIndent(rec, "", "  ")
+	if err := os.WriteFile(filepath.Join(dest, installedFile), out, 0o644); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// PluginConfig returns the config entry that runs the installed plugin.
+// Args and env from a previous entry are kept.
+func (in *Installed) PluginConfig(prev config.PluginConfig) config.PluginConfig {
+	pcfg := config.PluginConfig{
+		Type:      in.Package.Type,
+		Args:      prev.Args,
+		Env:       prev.Env,
+		AutoStart: true,
+		Tools:     in.Package.Tools,
+		Version:   in.Package.Version,
+		Source:    in.Source,
+		Pinned:    in.Pinned,
+	}
+	if pcfg.Type == "" {
+		pcfg.Type = "tool"
+	}
+	if in.Package.Runtime == "wasm" {
+		pcfg.Runtime = "wasm"
+		pcfg.Module = in.Path
+	} else {
+		pcfg.Command = in.Path
+	}
+	return pcfg
+}
+
+// Verify re-hashes an installed plugin's binary against its install record.
+func Verify(pcfg config.PluginConfig) (*Installed, error) {
+	path := pcfg.Command
+	if pcfg.IsWasm() {
+		path = pcfg.Module
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no command or module")
+	}
+	// The record sits in the version directory, at or above the binary.
+	var data []byte
+	for dir, i := filepath.Dir(path), 0; i < 8; dir, i = filepath.Dir(dir), i+1 {
+		if b, err := os.ReadFile(filepath.Join(dir, installedFile)); err == nil {
+			data = b
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	if data == nil {
+		return nil, fmt.Errorf("not installed by tetora plugin install (no %s)", installedFile)
+	}
+	var rec Installed
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", installedFile, err)
+	}
+	got, err := fileSHA256(path)
+	if err != nil {
+		return &rec, err
+	}
+	if got != rec.SHA256 {
+		return &rec, fmt.Errorf("checksum mismatch: %s is %s, installed as %s", path, got, rec.SHA256)
+	}
+	return &rec, nil
+}
+
+// Ping starts the plugin on its own, checks that it answers a ping, and
+// stops it again.
+func Ping(name string, pcfg config.PluginConfig) error {
+	cfg := &config.Config{Plugins: map[string]config.PluginConfig{name: pcfg}}
+	h := NewHost(cfg, nil)
+	if err := h.Start(name); err != nil {
+		return err
+	}
+	defer h.StopAll()
+	health := h.Health(name)
+	if health["healthy"] != true {
+		if msg, ok := health["error"].(string); ok {
+			return fmt.Errorf("health ping failed: %s", msg)
+		}
+		return fmt.Errorf("health ping failed: %v", health["status"])
+	}
+	return nil
+}
+
+// CompareVersions compares dotted versions numerically ("1.10.0" > "1.9.2").
+// A leading "v" is ignored; non-numeric parts compare as strings.
+func CompareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var sa, sb string
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+		na, errA := strconv.Atoi(sa)
+		nb, errB := strconv.Atoi(sb)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case sa != sb:
+			return strings.Compare(sa, sb)
+		}
+	}
+	return 0
+}
+
+// --- Download and unpack ---
+
+// fetch reads an http(s) URL, file:// URL or local path. base is returned
+// for resolving relative references found in the content.
+func fetch(ctx context.Context, ref string) ([]byte, string, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("download %s: %w", ref, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("download %s: HTTP %d", ref, resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+		if err != nil {
+			return nil, "", fmt.Errorf("download %s: %w", ref, err)
+		}
+		if len(data) > maxDownloadBytes {
+			return nil, "", fmt.Errorf("download %s: larger than %d MB", ref, maxDownloadBytes>>20)
+		}
+		return data, ref, nil
+	}
+
+	path := strings.TrimPrefix(ref, "file://")
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	abs, _ := filepath.Abs(path)
+	return data, abs, nil
+}
+
+// resolveRef resolves ref against base, a URL or an absolute file path.
+func resolveRef(base, ref string) string {
+	if ref == "" || strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+		return ref
+	}
+	if strings.Contains(base, "://") {
+		if b, err := url.Parse(base); err == nil {
+			if r, err := url.Parse(ref); err == nil {
+				return b.ResolveReference(r).String()
+			}
+		}
+		return ref
+	}
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref))
+}
+
+// unpack extracts a .tar.gz or .zip into dir. Anything else is taken to be
+// the binary itself and written as bin.
+func unpack(data []byte, dir, bin string) error {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return untarGz(data, dir)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unzip(data, dir)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(bin))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o755)
+}
+
+// safeJoin joins an archive entry name to dir, rejecting paths that escape it.
+func safeJoin(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes the install directory", name)
+	}
+	return path, nil
+}
+
+func untarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := safeJoin(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeEntry(path, tr, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		}
+		// Links and special files are skipped.
+	}
+}
+
+func unzip(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		path, err := safeJoin(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(path, rc, f.Mode())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEntry(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.LimitReader(r, maxDownloadBytes)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
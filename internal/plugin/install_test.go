@@ -0,0 +1,229 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func tarGz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sha(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeWasmPackage publishes the echo module as a versioned package in dir
+// and returns the manifest path.
+func writeWasmPackage(t *testing.T, dir, version string) string {
+	t.Helper()
+	module, err := os.ReadFile(buildEchoWasm(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tarGz(t, map[string][]byte{"bin/echo.wasm": module})
+	name := "echo-" + version + ".tar.gz"
+	os.WriteFile(filepath.Join(dir, name), archive, 0o644)
+
+	pkg := Package{
+		Name: "echo", Version: version, Runtime: "wasm", Tools: []string{"echo"},
+		Platforms: map[string]Artifact{"wasm": {URL: name, SHA256: sha(archive), Bin: "bin/echo.wasm"}},
+	}
+	data, _ := json.Marshal(pkg)
+	manifest := filepath.Join(dir, "echo-"+version+".json")
+	os.WriteFile(manifest, data, 0o644)
+	return manifest
+}
+
+func TestInstallVerifyPing(t *testing.T) {
+	src := t.TempDir()
+	manifest := writeWasmPackage(t, src, "1.0.0")
+
+	pkg, err := Resolve(context.Background(), manifest, "", "")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	dir := t.TempDir()
+	rec, err := Install(context.Background(), pkg, dir, manifest, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if want := filepath.Join(dir, "echo", "1.0.0", "bin", "echo.wasm"); rec.Path != want {
+		t.Errorf("Path = %s, want %s", rec.Path, want)
+	}
+
+	pcfg := rec.PluginConfig(config.PluginConfig{Env: map[string]string{"GREETING": "hi"}})
+	if !pcfg.IsWasm() || pcfg.Module != rec.Path || pcfg.Version != "1.0.0" || pcfg.Env["GREETING"] != "hi" {
+		t.Errorf("PluginConfig = %+v", pcfg)
+	}
+	if err := Ping("echo", pcfg); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	if _, err := Verify(pcfg); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	os.WriteFile(rec.Path, []byte("tampered"), 0o644)
+	if _, err := Verify(pcfg); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Verify after tampering = %v", err)
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	data := []byte("#!/bin/sh\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	pkg := &Package{Name: "bad", Version: "1.0.0", Platforms: map[string]Artifact{}}
+	pkg.Platforms[pkg.Platform()] = Artifact{URL: srv.URL + "/bad", SHA256: strings.Repeat("0", 64)}
+
+	dir := t.TempDir()
+	if _, err := Install(context.Background(), pkg, dir, "test", false); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Install = %v, want checksum mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad", "1.0.0")); !os.IsNotExist(err) {
+		t.Error("nothing should be installed after a checksum mismatch")
+	}
+
+	pkg.Platforms = map[string]Artifact{"plan9/mips": {URL: srv.URL, SHA256: sha(data)}}
+	if _, err := Install(context.Background(), pkg, dir, "test", false); err == nil || !strings.Contains(err.Error(), "no build for") {
+		t.Errorf("Install without platform build = %v", err)
+	}
+}
+
+func TestInstallRejectsPathTraversal(t *testing.T) {
+	archive := tarGz(t, map[string][]byte{"../../evil": []byte("x")})
+	src := filepath.Join(t.TempDir(), "evil.tar.gz")
+	os.WriteFile(src, archive, 0o644)
+
+	pkg := &Package{Name: "evil", Version: "1.0.0", Platforms: map[string]Artifact{}}
+	pkg.Platforms[pkg.Platform()] = Artifact{URL: src, SHA256: sha(archive)}
+	if _, err := Install(context.Background(), pkg, t.TempDir(), "test", false); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("Install = %v, want path traversal error", err)
+	}
+}
+
+func TestResolveRegistry(t *testing.T) {
+	idx := Index{Plugins: []Package{
+		{Name: "echo", Version: "1.2.0"},
+		{Name: "echo", Version: "1.10.0"},
+		{Name: "other", Version: "9.0.0"},
+	}}
+	data, _ := json.Marshal(idx)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if pkg, err := Resolve(ctx, "echo", "", srv.URL+"/index.json"); err != nil || pkg.Version != "1.10.0" {
+		t.Errorf("latest = %v, %v", pkg, err)
+	}
+	if pkg, err := Resolve(ctx, "echo", "1.2.0", srv.URL+"/index.json"); err != nil || pkg.Version != "1.2.0" {
+		t.Errorf("pinned = %v, %v", pkg, err)
+	}
+	if _, err := Resolve(ctx, "echo", "3.0.0", srv.URL+"/index.json"); err == nil {
+		t.Error("missing version should fail")
+	}
+	if _, err := Resolve(ctx, "echo", "", ""); err == nil {
+		t.Error("a bare name without a registry should fail")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.2", 1},
+		{"v1.2.0", "1.2.0", 0},
+		{"1.2", "1.2.1", -1},
+		{"2.0.0", "10.0.0", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// swapRecorder is a ToolRegistrar that tracks the registered tool set.
+type swapRecorder struct {
+	tools map[string]string // tool -> plugin
+	swaps int
+}
+
+func (r *swapRecorder) SwapPluginTools(pluginName string, remove, add []string, _ func(string, any) (json.RawMessage, error)) {
+	r.swaps++
+	for _, name := range remove {
+		delete(r.tools, name)
+	}
+	for _, name := range add {
+		r.tools[name] = pluginName
+	}
+}
+
+func TestHostReload(t *testing.T) {
+	module := buildEchoWasm(t)
+	cfg := &config.Config{Plugins: map[string]config.PluginConfig{}}
+	reg := &swapRecorder{tools: map[string]string{}}
+	h := NewHost(cfg, reg)
+	t.Cleanup(h.StopAll)
+
+	echo := config.PluginConfig{Type: "tool", Runtime: "wasm", Module: module, Tools: []string{"echo"}, AutoStart: true, Version: "1.0.0"}
+	res := h.Reload(&config.Config{Plugins: map[string]config.PluginConfig{"echo": echo}})
+	if !h.running("echo") || len(res.Started) != 1 {
+		t.Fatalf("new autoStart plugin should start on reload: %+v", res)
+	}
+	if reg.tools["echo"] != "echo" {
+		t.Errorf("tools after start = %v", reg.tools)
+	}
+
+	// An unchanged config is a no-op.
+	swaps := reg.swaps
+	if res := h.Reload(&config.Config{Plugins: map[string]config.PluginConfig{"echo": echo}}); len(res.Restarted) != 0 || reg.swaps != swaps {
+		t.Errorf("unchanged reload = %+v, swaps %d -> %d", res, swaps, reg.swaps)
+	}
+
+	echo.Env = map[string]string{"GREETING": "hi"}
+	if res := h.Reload(&config.Config{Plugins: map[string]config.PluginConfig{"echo": echo}}); len(res.Restarted) != 1 {
+		t.Errorf("changed plugin should restart: %+v", res)
+	}
+	if !h.running("echo") || reg.tools["echo"] != "echo" {
+		t.Errorf("after restart running=%v tools=%v", h.running("echo"), reg.tools)
+	}
+
+	res = h.Reload(&config.Config{Plugins: map[string]config.PluginConfig{}})
+	if h.running("echo") || len(res.Stopped) != 1 {
+		t.Errorf("removed plugin should stop on reload: %+v", res)
+	}
+	if len(reg.tools) != 0 {
+		t.Errorf("tools of removed plugin still registered: %v", reg.tools)
+	}
+}
@@ -2,10 +2,13 @@ package plugin
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os/exec"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -53,7 +56,9 @@ type jsonRPCNotification struct {
 
 // ToolRegistrar is implemented by callers that can register plugin-provided tools.
 type ToolRegistrar interface {
-	RegisterPluginTool(toolName, pluginName string, call func(method string, params any) (json.RawMessage, error))
+	// SwapPluginTools unregisters remove and registers add for a plugin in
+	// one step. call forwards a JSON-RPC call to the plugin.
+	SwapPluginTools(pluginName string, remove, add []string, call func(method string, params any) (json.RawMessage, error))
 }
 
 // --- Plugin Process ---
@@ -280,38 +285,61 @@ func (p *Process) IsRunning() bool {
 
 // --- Plugin Host ---
 
-// Host manages all plugin processes.
+// Host manages all plugin processes and WASM modules.
 type Host struct {
 	Mu       sync.RWMutex
 	Plugins  map[string]*Process
+	Wasm     map[string]*WasmPlugin
 	cfg      *config.Config
 	registrar ToolRegistrar
+	tools     map[string][]string // plugin name -> tools currently registered
 }
 
 // NewHost creates a new plugin host. registrar may be nil if no tool plugins are used.
 func NewHost(cfg *config.Config, registrar ToolRegistrar) *Host {
 	return &Host{
 		Plugins:   make(map[string]*Process),
+		Wasm:      make(map[string]*WasmPlugin),
 		cfg:       cfg,
 		registrar: registrar,
+		tools:     make(map[string][]string),
 	}
 }
 
+// ReloadResult lists what a config reload changed.
+type ReloadResult struct {
+	Started   []string          `json:"started,omitempty"`
+	Stopped   []string          `json:"stopped,omitempty"`
+	Restarted []string          `json:"restarted,omitempty"`
+	Failed    map[string]string `json:"failed,omitempty"` // name -> error
+}
+
+func (r *ReloadResult) fail(name string, err error) {
+	if r.Failed == nil {
+		r.Failed = make(map[string]string)
+	}
+	r.Failed[name] = err.Error()
+}
+
 // Start starts a named plugin from config.
 func (h *Host) Start(name string) error {
-	pcfg, ok := h.cfg.Plugins[name]
+	pcfg, ok := h.config().Plugins[name]
 	if !ok {
 		return fmt.Errorf("plugin %q not found in config", name)
 	}
 
-	if pcfg.Command == "" {
-		return fmt.Errorf("plugin %q has no command", name)
-	}
-
 	if !ValidPluginTypes[pcfg.Type] {
 		return fmt.Errorf("plugin %q has invalid type %q", name, pcfg.Type)
 	}
 
+	if pcfg.IsWasm() {
+		return h.startWasm(name, pcfg)
+	}
+
+	if pcfg.Command == "" {
+		return fmt.Errorf("plugin %q has no command", name)
+	}
+
 	h.Mu.Lock()
 	if existing, ok := h.Plugins[name]; ok && existing.IsRunning() {
 		h.Mu.Unlock()
@@ -335,23 +363,151 @@ func (h *Host) Start(name string) error {
 
 	log.Info("plugin started", "name", name, "type", pcfg.Type, "command", pcfg.Command)
 
-	if pcfg.Type == "tool" && len(pcfg.Tools) > 0 && h.registrar != nil {
-		for _, toolName := range pcfg.Tools {
-			pluginName := name
-			tName := toolName
-			h.registrar.RegisterPluginTool(tName, pluginName, func(method string, params any) (json.RawMessage, error) {
-				return h.Call(pluginName, method, params)
-			})
-		}
-		log.Info("plugin tools registered", "plugin", name, "tools", len(pcfg.Tools))
+	if pcfg.Type == "tool" {
+		h.registerTools(name, pcfg.Tools)
+	}
+
+	return nil
+}
+
+// startWasm compiles a WASM plugin and registers its tools.
+func (h *Host) startWasm(name string, pcfg config.PluginConfig) error {
+	if pcfg.Module == "" {
+		return fmt.Errorf("plugin %q has no module", name)
+	}
+	if pcfg.Type != "tool" {
+		return fmt.Errorf("plugin %q: wasm runtime supports tool plugins only", name)
+	}
+
+	h.Mu.Lock()
+	if _, ok := h.Wasm[name]; ok {
+		h.Mu.Unlock()
+		return fmt.Errorf("plugin %q is already running", name)
+	}
+	h.Mu.Unlock()
+
+	wp, err := newWasmPlugin(context.Background(), name, pcfg)
+	if err != nil {
+		return fmt.Errorf("start plugin %s: %w", name, err)
 	}
 
+	h.Mu.Lock()
+	h.Wasm[name] = wp
+	h.Mu.Unlock()
+
+	caps := wp.Manifest.Capabilities
+	log.Info("wasm plugin started", "name", name, "module", pcfg.Module,
+		"fsGrants", len(caps.Filesystem), "httpHosts", len(caps.HTTP))
+
+	h.registerTools(name, wp.tools())
 	return nil
 }
 
+// registerTools replaces whatever tools the plugin registered before with
+// tools, in one registry update.
+func (h *Host) registerTools(name string, tools []string) {
+	h.Mu.Lock()
+	prev := h.tools[name]
+	if len(tools) == 0 {
+		delete(h.tools, name)
+	} else {
+		h.tools[name] = tools
+	}
+	h.Mu.Unlock()
+	if h.registrar == nil || (len(prev) == 0 && len(tools) == 0) {
+		return
+	}
+	h.registrar.SwapPluginTools(name, prev, tools, func(method string, params any) (json.RawMessage, error) {
+		return h.Call(name, method, params)
+	})
+	if len(tools) > 0 {
+		log.Info("plugin tools registered", "plugin", name, "tools", len(tools))
+	}
+}
+
+func (h *Host) config() *config.Config {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return h.cfg
+}
+
+// running reports whether a plugin is currently loaded.
+func (h *Host) running(name string) bool {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	if _, ok := h.Wasm[name]; ok {
+		return true
+	}
+	proc, ok := h.Plugins[name]
+	return ok && proc.IsRunning()
+}
+
+// Reload diffs the plugin config against cfg and applies it: plugins removed
+// from the config are stopped and their tools unregistered, running plugins
+// whose config changed are restarted, and new autoStart plugins are started.
+func (h *Host) Reload(cfg *config.Config) ReloadResult {
+	h.Mu.Lock()
+	old := h.cfg
+	h.cfg = cfg
+	h.Mu.Unlock()
+
+	var res ReloadResult
+	for _, name := range sortedNames(old.Plugins) {
+		prev := old.Plugins[name]
+		pcfg, ok := cfg.Plugins[name]
+		switch {
+		case !ok:
+			if h.running(name) {
+				log.Info("plugin removed from config, stopping", "name", name)
+				h.Stop(name)
+				res.Stopped = append(res.Stopped, name)
+			}
+			h.registerTools(name, nil)
+		case !reflect.DeepEqual(pcfg, prev) && h.running(name):
+			log.Info("plugin config changed, restarting", "name", name, "version", pcfg.Version)
+			h.Stop(name)
+			if err := h.Start(name); err != nil {
+				log.Warn("restart plugin failed", "name", name, "error", err)
+				h.registerTools(name, nil)
+				res.fail(name, err)
+				continue
+			}
+			res.Restarted = append(res.Restarted, name)
+		}
+	}
+	for _, name := range sortedNames(cfg.Plugins) {
+		if _, existed := old.Plugins[name]; existed || !cfg.Plugins[name].AutoStart || h.running(name) {
+			continue
+		}
+		if err := h.Start(name); err != nil {
+			log.Warn("auto-start plugin failed", "name", name, "error", err)
+			res.fail(name, err)
+			continue
+		}
+		res.Started = append(res.Started, name)
+	}
+	return res
+}
+
+func sortedNames(m map[string]config.PluginConfig) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Stop stops a named plugin.
 func (h *Host) Stop(name string) error {
 	h.Mu.Lock()
+	if wp, ok := h.Wasm[name]; ok {
+		delete(h.Wasm, name)
+		h.Mu.Unlock()
+		log.Info("plugin stopping", "name", name)
+		wp.close()
+		return nil
+	}
 	proc, ok := h.Plugins[name]
 	if !ok {
 		h.Mu.Unlock()
@@ -367,10 +523,13 @@ func (h *Host) Stop(name string) error {
 // StopAll stops all running plugins.
 func (h *Host) StopAll() {
 	h.Mu.Lock()
-	names := make([]string, 0, len(h.Plugins))
+	names := make([]string, 0, len(h.Plugins)+len(h.Wasm))
 	for name := range h.Plugins {
 		names = append(names, name)
 	}
+	for name := range h.Wasm {
+		names = append(names, name)
+	}
 	h.Mu.Unlock()
 
 	for _, name := range names {
@@ -384,8 +543,18 @@ func (h *Host) StopAll() {
 func (h *Host) Call(name, method string, params any) (json.RawMessage, error) {
 	h.Mu.RLock()
 	proc, ok := h.Plugins[name]
+	wp, isWasm := h.Wasm[name]
 	h.Mu.RUnlock()
 
+	timeout := 30 * time.Second
+	if t := h.config().Tools.Timeout; t > 0 {
+		timeout = time.Duration(t) * time.Second
+	}
+
+	if isWasm {
+		return wp.call(method, params, timeout)
+	}
+
 	if !ok {
 		return nil, fmt.Errorf("plugin %q is not running", name)
 	}
@@ -394,11 +563,6 @@ func (h *Host) Call(name, method string, params any) (json.RawMessage, error) {
 		return nil, fmt.Errorf("plugin %q process has exited", name)
 	}
 
-	timeout := 30 * time.Second
-	if h.cfg.Tools.Timeout > 0 {
-		timeout = time.Duration(h.cfg.Tools.Timeout) * time.Second
-	}
-
 	return proc.call(method, params, timeout)
 }
 
@@ -406,8 +570,13 @@ func (h *Host) Call(name, method string, params any) (json.RawMessage, error) {
 func (h *Host) Notify(name, method string, params any) error {
 	h.Mu.RLock()
 	proc, ok := h.Plugins[name]
+	_, isWasm := h.Wasm[name]
 	h.Mu.RUnlock()
 
+	if isWasm {
+		return fmt.Errorf("plugin %q: wasm plugins do not accept notifications", name)
+	}
+
 	if !ok {
 		return fmt.Errorf("plugin %q is not running", name)
 	}
@@ -436,6 +605,15 @@ func (h *Host) List() []map[string]any {
 		if len(pcfg.Tools) > 0 {
 			entry["tools"] = pcfg.Tools
 		}
+		if pcfg.IsWasm() {
+			entry["runtime"] = "wasm"
+			entry["module"] = pcfg.Module
+			if wp, ok := h.Wasm[name]; ok {
+				entry["status"] = "running"
+				entry["tools"] = wp.tools()
+				entry["capabilities"] = wp.Manifest.Capabilities
+			}
+		}
 		result = append(result, entry)
 	}
 	return result
@@ -445,8 +623,16 @@ func (h *Host) List() []map[string]any {
 func (h *Host) Health(name string) map[string]any {
 	h.Mu.RLock()
 	proc, ok := h.Plugins[name]
+	wp, isWasm := h.Wasm[name]
 	h.Mu.RUnlock()
 
+	if isWasm {
+		if _, err := wp.call("ping", nil, 5*time.Second); err != nil {
+			return map[string]any{"name": name, "status": "running", "runtime": "wasm", "healthy": false, "error": err.Error()}
+		}
+		return map[string]any{"name": name, "status": "running", "runtime": "wasm", "healthy": true}
+	}
+
 	if !ok {
 		return map[string]any{"name": name, "status": "not_running", "healthy": false}
 	}
@@ -465,7 +651,7 @@ func (h *Host) Health(name string) map[string]any {
 
 // AutoStart starts all plugins with autoStart=true.
 func (h *Host) AutoStart() {
-	for name, pcfg := range h.cfg.Plugins {
+	for name, pcfg := range h.config().Plugins {
 		if pcfg.AutoStart {
 			if err := h.Start(name); err != nil {
 				log.Warn("auto-start plugin failed", "name", name, "error", err)
@@ -0,0 +1,100 @@
+//go:build wasip1
+
+// wasmecho is a test WASM tool plugin. It reads JSON-RPC requests from stdin
+// like a process plugin and uses the tetora host module for HTTP.
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o echo.wasm ./internal/plugin/testdata/wasmecho
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+//go:wasmimport tetora http_fetch
+func httpFetch(ptr unsafe.Pointer, size uint32) uint32
+
+//go:wasmimport tetora http_response
+func httpResponse(ptr unsafe.Pointer) uint32
+
+// fetch sends a JSON request through the host and returns the JSON response.
+func fetch(req any) json.RawMessage {
+	data, _ := json.Marshal(req)
+	n := httpFetch(unsafe.Pointer(&data[0]), uint32(len(data)))
+	if n == 0 {
+		return nil
+	}
+	buf := make([]byte, n)
+	httpResponse(unsafe.Pointer(&buf[0]))
+	return buf
+}
+
+type request struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func reply(id int, result any, errMsg string) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if errMsg != "" {
+		resp["error"] = map[string]any{"code": -32000, "message": errMsg}
+	} else {
+		resp["result"] = result
+	}
+	data, _ := json.Marshal(resp)
+	fmt.Println(string(data))
+}
+
+func main() {
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var req request
+		if json.Unmarshal(sc.Bytes(), &req) != nil {
+			continue
+		}
+		switch req.Method {
+		case "ping":
+			reply(req.ID, map[string]any{"pong": true}, "")
+		case "tool/execute":
+			var p struct {
+				Name  string `json:"name"`
+				Input struct {
+					Text string `json:"text"`
+					Path string `json:"path"`
+					URL  string `json:"url"`
+				} `json:"input"`
+			}
+			json.Unmarshal(req.Params, &p)
+			switch p.Name {
+			case "echo":
+				reply(req.ID, map[string]any{"text": p.Input.Text, "env": os.Getenv("GREETING")}, "")
+			case "read_file":
+				data, err := os.ReadFile(p.Input.Path)
+				if err != nil {
+					reply(req.ID, nil, err.Error())
+					continue
+				}
+				reply(req.ID, map[string]any{"content": string(data)}, "")
+			case "write_file":
+				if err := os.WriteFile(p.Input.Path, []byte(p.Input.Text), 0o644); err != nil {
+					reply(req.ID, nil, err.Error())
+					continue
+				}
+				reply(req.ID, map[string]any{"ok": true}, "")
+			case "fetch":
+				reply(req.ID, fetch(map[string]any{"url": p.Input.URL}), "")
+			case "spin":
+				for {
+				}
+			default:
+				reply(req.ID, nil, "unknown tool "+p.Name)
+			}
+		default:
+			reply(req.ID, nil, "method not found: "+req.Method)
+		}
+	}
+}
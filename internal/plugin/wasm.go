@@ -0,0 +1,429 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+// --- WASM Plugins ---
+//
+// A WASM plugin is a WASI (preview1) command module speaking the same
+// JSON-RPC contract as a process plugin: each call instantiates the module
+// with the request line on stdin and reads the response from stdout, so a
+// process plugin's stdin loop compiled with GOOS=wasip1 works unchanged.
+//
+// The module sees nothing of the host except what its manifest grants:
+// directories mounted into its filesystem, and outbound HTTP to listed hosts
+// through the "tetora" host module:
+//
+//	http_fetch(reqPtr, reqLen i32) -> respLen i32   // request/response are JSON
+//	http_response(ptr i32) -> n i32                  // copy the response into guest memory
+
+const (
+	defaultWasmMemoryMB  = 128
+	maxWasmOutputBytes   = 4 << 20
+	maxWasmHTTPBodyBytes = 4 << 20
+	wasmHostModule       = "tetora"
+)
+
+// Manifest declares the tools a WASM plugin provides and the capabilities
+// it is granted.
+type Manifest struct {
+	Name         string       `json:"name,omitempty"`
+	Tools        []string     `json:"tools,omitempty"`
+	Capabilities Capabilities `json:"capabilities"`
+	MaxMemoryMB  int          `json:"maxMemoryMB,omitempty"` // default 128
+}
+
+// Capabilities are the host resources a WASM plugin may reach.
+type Capabilities struct {
+	Filesystem []FSGrant `json:"filesystem,omitempty"`
+	// HTTP lists hosts the plugin may fetch from: "api.example.com",
+	// "*.example.com" (subdomains), or "host:port" to pin a port. IPv6
+	// addresses are written "::1", or "[::1]:8080" with a port.
+	HTTP []string `json:"http,omitempty"`
+}
+
+// FSGrant mounts a host directory into the plugin's filesystem.
+type FSGrant struct {
+	Path     string `json:"path"`            // host directory, relative to the manifest
+	Mount    string `json:"mount,omitempty"` // guest path; default = path
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// LoadManifest reads a manifest and resolves its filesystem grants to
+// absolute host directories.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i, g := range m.Capabilities.Filesystem {
+		if g.Path == "" {
+			return nil, fmt.Errorf("manifest %s: filesystem grant %d has no path", path, i)
+		}
+		host := g.Path
+		if strings.HasPrefix(host, "~/") {
+			home, _ := os.UserHomeDir()
+			host = filepath.Join(home, host[2:])
+		} else if !filepath.IsAbs(host) {
+			host = filepath.Join(dir, host)
+		}
+		if fi, err := os.Stat(host); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("manifest %s: filesystem grant %q is not a directory", path, g.Path)
+		}
+		if g.Mount == "" {
+			g.Mount = g.Path
+		}
+		g.Path = host
+		m.Capabilities.Filesystem[i] = g
+	}
+	return &m, nil
+}
+
+// AllowsHost reports whether the manifest grants HTTP access to u.
+func (m *Manifest) AllowsHost(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, g := range m.Capabilities.HTTP {
+		g = strings.ToLower(strings.TrimSpace(g))
+		if gh, gp, err := net.SplitHostPort(g); err == nil {
+			if gh == host && gp == port {
+				return true
+			}
+			continue
+		}
+		// A bare IPv6 address, bracketed or not.
+		g = strings.TrimSuffix(strings.TrimPrefix(g, "["), "]")
+		if strings.HasPrefix(g, "*.") {
+			if strings.HasSuffix(host, g[1:]) {
+				return true
+			}
+			continue
+		}
+		if g == host {
+			return true
+		}
+	}
+	return false
+}
+
+// WasmPlugin is a loaded WASM plugin. The module is compiled once; each call
+// runs in a fresh instance.
+type WasmPlugin struct {
+	Name     string
+	Type     string
+	Config   config.PluginConfig
+	Manifest *Manifest
+
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	client   *http.Client
+}
+
+func manifestPath(pcfg config.PluginConfig) string {
+	if pcfg.Manifest != "" {
+		return pcfg.Manifest
+	}
+	return strings.TrimSuffix(pcfg.Module, ".wasm") + ".json"
+}
+
+func newWasmPlugin(ctx context.Context, name string, pcfg config.PluginConfig) (*WasmPlugin, error) {
+	code, err := os.ReadFile(pcfg.Module)
+	if err != nil {
+		return nil, fmt.Errorf("read wasm module: %w", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath(pcfg))
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist) && pcfg.Manifest == "":
+		manifest = &Manifest{} // no manifest: no capabilities
+	default:
+		return nil, err
+	}
+	memMB := manifest.MaxMemoryMB
+	if memMB <= 0 {
+		memMB = defaultWasmMemoryMB
+	}
+
+	p := &WasmPlugin{
+		Name:     name,
+		Type:     pcfg.Type,
+		Config:   pcfg,
+		Manifest: manifest,
+	}
+	p.client = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !p.Manifest.AllowsHost(req.URL) {
+				return fmt.Errorf("capability denied: redirect to host %q not granted", req.URL.Host)
+			}
+			return nil
+		},
+	}
+
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(memMB)*16)) // 64 KiB pages
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("instantiate wasi: %w", err)
+	}
+	_, err = rt.NewHostModuleBuilder(wasmHostModule).
+		NewFunctionBuilder().WithFunc(p.hostHTTPFetch).Export("http_fetch").
+		NewFunctionBuilder().WithFunc(p.hostHTTPResponse).Export("http_response").
+		Instantiate(ctx)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("instantiate host module: %w", err)
+	}
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("compile wasm module: %w", err)
+	}
+	p.runtime = rt
+	p.compiled = compiled
+	return p, nil
+}
+
+func (p *WasmPlugin) close() {
+	if p.runtime != nil {
+		p.runtime.Close(context.Background())
+	}
+}
+
+// tools returns the configured tool names, falling back to the manifest.
+func (p *WasmPlugin) tools() []string {
+	if len(p.Config.Tools) > 0 {
+		return p.Config.Tools
+	}
+	return p.Manifest.Tools
+}
+
+// wasmCall carries per-instance state for host functions.
+type wasmCall struct {
+	resp []byte
+}
+
+type wasmCallKey struct{}
+
+func (p *WasmPlugin) call(method string, params any, timeout time.Duration) (json.RawMessage, error) {
+	line, err := json.Marshal(jsonRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, wasmCallKey{}, &wasmCall{})
+
+	stdout := &limitedBuffer{max: maxWasmOutputBytes}
+	stderr := &limitedBuffer{max: 64 << 10}
+	mc := wazero.NewModuleConfig().
+		WithName(""). // anonymous: concurrent calls get separate instances
+		WithArgs(p.Name).
+		WithStdin(bytes.NewReader(append(line, '\n'))).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	for k, v := range p.Config.Env {
+		mc = mc.WithEnv(k, v)
+	}
+	fs := wazero.NewFSConfig()
+	for _, g := range p.Manifest.Capabilities.Filesystem {
+		if g.ReadOnly {
+			fs = fs.WithReadOnlyDirMount(g.Path, g.Mount)
+		} else {
+			fs = fs.WithDirMount(g.Path, g.Mount)
+		}
+	}
+	mc = mc.WithFSConfig(fs)
+
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, mc)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s: timeout waiting for response (method=%s)", p.Name, method)
+		}
+		var exitErr *sys.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("exit code %d", exitErr.ExitCode())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, lastLine(msg))
+		}
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		log.Debug("wasm plugin stderr", "plugin", p.Name, "stderr", lastLine(msg))
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(stdout.String()))
+	sc.Buffer(make([]byte, 0, 64*1024), maxWasmOutputBytes)
+	for sc.Scan() {
+		var resp jsonRPCResponse
+		if err := json.Unmarshal(sc.Bytes(), &resp); err != nil || resp.ID != 1 {
+			continue
+		}
+		if resp.Error != nil {
+			return json.Marshal(map[string]any{
+				"error":   resp.Error.Message,
+				"code":    resp.Error.Code,
+				"isError": true,
+			})
+		}
+		return resp.Result, nil
+	}
+	return nil, fmt.Errorf("plugin %s: no response (method=%s)", p.Name, method)
+}
+
+// --- Host Functions ---
+
+type wasmHTTPRequest struct {
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+type wasmHTTPResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// hostHTTPFetch performs a granted HTTP request and stashes the JSON
+// response for hostHTTPResponse. It returns the response length.
+func (p *WasmPlugin) hostHTTPFetch(ctx context.Context, m api.Module, reqPtr, reqLen uint32) uint32 {
+	state, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	if state == nil {
+		return 0
+	}
+	var resp wasmHTTPResponse
+	if data, ok := m.Memory().Read(reqPtr, reqLen); !ok {
+		resp.Error = "request out of bounds"
+	} else {
+		resp = p.fetch(ctx, data)
+	}
+	state.resp, _ = json.Marshal(resp)
+	return uint32(len(state.resp))
+}
+
+// hostHTTPResponse copies the last response into guest memory at ptr.
+func (p *WasmPlugin) hostHTTPResponse(ctx context.Context, m api.Module, ptr uint32) uint32 {
+	state, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	if state == nil || !m.Memory().Write(ptr, state.resp) {
+		return 0
+	}
+	n := uint32(len(state.resp))
+	state.resp = nil
+	return n
+}
+
+func (p *WasmPlugin) fetch(ctx context.Context, data []byte) wasmHTTPResponse {
+	var req wasmHTTPRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return wasmHTTPResponse{Error: "invalid request: " + err.Error()}
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return wasmHTTPResponse{Error: "invalid url: " + err.Error()}
+	}
+	if !p.Manifest.AllowsHost(u) {
+		log.Warn("wasm plugin http denied", "plugin", p.Name, "host", u.Host)
+		return wasmHTTPResponse{Error: fmt.Sprintf("capability denied: host %q not granted", u.Host)}
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	hreq, err := http.NewRequestWithContext(ctx, req.Method, u.String(), strings.NewReader(req.Body))
+	if err != nil {
+		return wasmHTTPResponse{Error: err.Error()}
+	}
+	for k, v := range req.Headers {
+		hreq.Header.Set(k, v)
+	}
+	hresp, err := p.client.Do(hreq)
+	if err != nil {
+		return wasmHTTPResponse{Error: err.Error()}
+	}
+	defer hresp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(hresp.Body, maxWasmHTTPBodyBytes))
+	if err != nil {
+		return wasmHTTPResponse{Error: "read body: " + err.Error()}
+	}
+	headers := make(map[string]string, len(hresp.Header))
+	for k := range hresp.Header {
+		headers[k] = hresp.Header.Get(k)
+	}
+	return wasmHTTPResponse{Status: hresp.StatusCode, Headers: headers, Body: string(body)}
+}
+
+// --- Helpers ---
+
+// limitedBuffer keeps at most max bytes and silently drops the rest.
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string { return b.buf.String() }
+
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
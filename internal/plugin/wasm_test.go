@@ -0,0 +1,178 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+var (
+	echoWasmOnce sync.Once
+	echoWasmPath string
+	echoWasmErr  error
+)
+
+// buildEchoWasm compiles testdata/wasmecho for wasip1 once per test run.
+func buildEchoWasm(t *testing.T) string {
+	t.Helper()
+	echoWasmOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "tetora-wasm-")
+		if err != nil {
+			echoWasmErr = err
+			return
+		}
+		echoWasmPath = filepath.Join(dir, "echo.wasm")
+		cmd := exec.Command("go", "build", "-o", echoWasmPath, "./testdata/wasmecho")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			echoWasmErr = fmt.Errorf("%v: %s", err, out)
+		}
+	})
+	if echoWasmErr != nil {
+		t.Skipf("cannot build wasm test module: %v", echoWasmErr)
+	}
+	return echoWasmPath
+}
+
+// startEcho loads the echo module with the given manifest into a new host.
+func startEcho(t *testing.T, manifest string) *Host {
+	t.Helper()
+	dir := t.TempDir()
+	module := filepath.Join(dir, "echo.wasm")
+	data, err := os.ReadFile(buildEchoWasm(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(module, data, 0o644)
+	if manifest != "" {
+		os.WriteFile(filepath.Join(dir, "echo.json"), []byte(manifest), 0o644)
+	}
+
+	cfg := &config.Config{Plugins: map[string]config.PluginConfig{
+		"echo": {Type: "tool", Runtime: "wasm", Module: module, Env: map[string]string{"GREETING": "hi"}},
+	}}
+	cfg.Tools.Timeout = 5
+	h := NewHost(cfg, nil)
+	if err := h.Start("echo"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(h.StopAll)
+	return h
+}
+
+func execTool(t *testing.T, h *Host, name string, input map[string]any) map[string]any {
+	t.Helper()
+	raw, err := h.Call("echo", "tool/execute", map[string]any{"name": name, "input": input})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	var out map[string]any
+	json.Unmarshal(raw, &out)
+	return out
+}
+
+func TestWasmPluginCall(t *testing.T) {
+	h := startEcho(t, "")
+
+	if health := h.Health("echo"); health["healthy"] != true {
+		t.Fatalf("health = %v", health)
+	}
+	out := execTool(t, h, "echo", map[string]any{"text": "hello"})
+	if out["text"] != "hello" || out["env"] != "hi" {
+		t.Errorf("echo = %v", out)
+	}
+	if out := execTool(t, h, "nope", nil); out["isError"] != true {
+		t.Errorf("unknown tool should be an error, got %v", out)
+	}
+}
+
+func TestWasmPluginFilesystemGrants(t *testing.T) {
+	data := t.TempDir()
+	os.WriteFile(filepath.Join(data, "note.txt"), []byte("granted"), 0o644)
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(secret, []byte("nope"), 0o644)
+
+	h := startEcho(t, fmt.Sprintf(`{"capabilities":{"filesystem":[{"path":%q,"mount":"/data","readOnly":true}]}}`, data))
+
+	if out := execTool(t, h, "read_file", map[string]any{"path": "/data/note.txt"}); out["content"] != "granted" {
+		t.Errorf("read granted file = %v", out)
+	}
+	if out := execTool(t, h, "read_file", map[string]any{"path": secret}); out["isError"] != true {
+		t.Errorf("read outside grant should fail, got %v", out)
+	}
+	if out := execTool(t, h, "write_file", map[string]any{"path": "/data/new.txt", "text": "x"}); out["isError"] != true {
+		t.Errorf("write to read-only grant should fail, got %v", out)
+	}
+}
+
+func TestWasmPluginHTTPGrants(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "pong")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	h := startEcho(t, fmt.Sprintf(`{"capabilities":{"http":[%q]}}`, u.Host))
+
+	out := execTool(t, h, "fetch", map[string]any{"url": srv.URL})
+	if out["status"] != float64(200) || out["body"] != "pong" {
+		t.Errorf("granted fetch = %v", out)
+	}
+	denied := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	out = execTool(t, h, "fetch", map[string]any{"url": denied})
+	if msg, _ := out["error"].(string); !strings.Contains(msg, "capability denied") {
+		t.Errorf("ungranted fetch = %v", out)
+	}
+}
+
+func TestWasmPluginTimeout(t *testing.T) {
+	h := startEcho(t, "")
+	h.cfg.Tools.Timeout = 1
+
+	start := time.Now()
+	_, err := h.Call("echo", "tool/execute", map[string]any{"name": "spin"})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("runaway module was not stopped promptly (%s)", time.Since(start))
+	}
+}
+
+func TestManifestAllowsHost(t *testing.T) {
+	m := &Manifest{Capabilities: Capabilities{HTTP: []string{"api.example.com", "*.github.com", "127.0.0.1:8080", "[::1]:8080", "fe80::1", "[2001:db8::2]"}}}
+	tests := map[string]bool{
+		"https://api.example.com/v1":  true,
+		"https://evil.example.com/":   false,
+		"https://raw.github.com/x":    true,
+		"https://github.com.evil.io/": false,
+		"http://127.0.0.1:8080/":      true,
+		"http://127.0.0.1:9090/":      false,
+		"file:///etc/passwd":          false,
+		"ftp://api.example.com/":      false,
+		"http://[::1]:8080/":          true,
+		"http://[::1]:9090/":          false,
+		"http://[::1]/":               false,
+		"http://[fe80::1]/":           true,
+		"https://[fe80::1]:8443/":     true,
+		"http://[2001:db8::2]/":       true,
+		"http://[2001:db8::3]/":       false,
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := m.AllowsHost(u); got != want {
+			t.Errorf("AllowsHost(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -37,6 +37,10 @@ type Deps struct {
 	// NotifyFn sends a notification string via the configured notification chain
 	// (e.g. Discord). May be nil if no notifier is wired up.
 	NotifyFn func(string)
+
+	// Halted reports whether the emergency stop is engaged. While it is,
+	// no rule fires. May be nil.
+	Halted func() bool
 }
 
 // cooldownEntry tracks when a rule was last triggered and how long the cooldown lasts.
@@ -270,7 +274,7 @@ func (e *Engine) HandleEvent(event dispatch.SSEEvent) {
 			continue
 		}
 
-		if rule.Trigger.Event == event.Type {
+		if matchesEvent(rule.Trigger.Event, event.Type) {
 			if e.CheckCooldown(rule.Name) {
 				continue
 			}
@@ -284,6 +288,15 @@ func (e *Engine) HandleEvent(event dispatch.SSEEvent) {
 	}
 }
 
+// matchesEvent reports whether an event type matches a rule's event, exactly
+// or by a prefix ending in "*" (e.g. "mqtt:home/*"), as workflow triggers do.
+func matchesEvent(pattern, eventType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return pattern == eventType
+}
+
 // --- Schedule Matching ---
 
 // matchesSchedule checks if a schedule rule should fire now.
@@ -553,6 +566,10 @@ func (e *Engine) getSkippedConcurrentToday() (float64, error) {
 
 // executeAction performs the action defined in a rule.
 func (e *Engine) executeAction(ctx context.Context, rule config.ProactiveRule) error {
+	if e.deps.Halted != nil && e.deps.Halted() {
+		return fmt.Errorf("emergency stop engaged")
+	}
+
 	// Set cooldown.
 	if rule.Cooldown != "" {
 		duration, err := time.ParseDuration(rule.Cooldown)
@@ -466,3 +466,21 @@ func TestResolveTemplate_SkippedToday(t *testing.T) {
 		t.Errorf("SkippedToday wrong in %q", result)
 	}
 }
+
+func TestMatchesEvent(t *testing.T) {
+	tests := []struct {
+		pattern, event string
+		want           bool
+	}{
+		{"task_done", "task_done", true},
+		{"task_done", "task_done_late", false},
+		{"mqtt:home/door/*", "mqtt:home/door/contact", true},
+		{"mqtt:home/door/*", "mqtt:home/window", false},
+		{"*", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := matchesEvent(tt.pattern, tt.event); got != tt.want {
+			t.Errorf("matchesEvent(%q, %q) = %v, want %v", tt.pattern, tt.event, got, tt.want)
+		}
+	}
+}
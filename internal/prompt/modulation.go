@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UserState is what is known about the user a task answers: the sentiment of
+// the current message, their recent average mood and signs of time pressure.
+type UserState struct {
+	Sentiment   float64  // current message, -1 to 1
+	Mood        float64  // average over recent messages, -1 to 1
+	MoodSamples int      // messages the average is based on
+	Urgency     []string // time-pressure cues in the current message
+}
+
+// Modulation adjusts the response style for the user's state.
+type Modulation struct {
+	Brevity   string   `json:"brevity,omitempty"`   // "short"
+	Empathy   string   `json:"empathy,omitempty"`   // "high"
+	Formality string   `json:"formality,omitempty"` // "formal", "casual"
+	Reasons   []string `json:"reasons,omitempty"`
+}
+
+// moodSamplesMin is how many scored messages a recent mood needs before it
+// counts on its own, without the current message agreeing.
+const moodSamplesMin = 3
+
+// Modulate derives the response style from s. The zero Modulation means no
+// adjustment.
+func Modulate(s UserState) Modulation {
+	var m Modulation
+	if len(s.Urgency) > 0 {
+		m.Brevity = "short"
+		m.Reasons = append(m.Reasons, "time pressure: "+strings.Join(s.Urgency, ", "))
+	}
+	recent := s.MoodSamples >= moodSamplesMin
+	switch {
+	case s.Sentiment <= -0.3 || (recent && s.Mood <= -0.3 && s.Sentiment <= 0):
+		m.Empathy, m.Formality = "high", "formal"
+		m.Reasons = append(m.Reasons, moodReason("negative", s, recent))
+	case s.Sentiment >= 0.5 || (recent && s.Mood >= 0.5 && s.Sentiment >= 0):
+		m.Formality = "casual"
+		m.Reasons = append(m.Reasons, moodReason("positive", s, recent))
+	}
+	return m
+}
+
+func moodReason(label string, s UserState, recent bool) string {
+	r := fmt.Sprintf("%s sentiment (message %.2f", label, s.Sentiment)
+	if recent {
+		r += fmt.Sprintf(", recent average %.2f over %d messages", s.Mood, s.MoodSamples)
+	}
+	return r + ")"
+}
+
+// Applied lists the adjustments as "dimension:level", e.g. "brevity:short".
+func (m Modulation) Applied() []string {
+	var out []string
+	if m.Brevity != "" {
+		out = append(out, "brevity:"+m.Brevity)
+	}
+	if m.Empathy != "" {
+		out = append(out, "empathy:"+m.Empathy)
+	}
+	if m.Formality != "" {
+		out = append(out, "formality:"+m.Formality)
+	}
+	return out
+}
+
+// Instructions is the system prompt block for m, or "" when m is empty.
+func (m Modulation) Instructions() string {
+	var lines []string
+	if m.Brevity == "short" {
+		lines = append(lines, "- The user is pressed for time: lead with the answer, keep it to a few sentences, and skip preamble and options they did not ask for.")
+	}
+	if m.Empathy == "high" {
+		lines = append(lines, "- The user seems frustrated or upset: briefly acknowledge it, stay calm and patient, and focus on what will help.")
+	}
+	switch m.Formality {
+	case "formal":
+		lines = append(lines, "- Keep a polite, plain tone without jokes or emoji.")
+	case "casual":
+		lines = append(lines, "- The user is in a good mood: a relaxed, friendly tone is fine.")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "## Response Style\n" + strings.Join(lines, "\n")
+}
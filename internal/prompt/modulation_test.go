@@ -0,0 +1,72 @@
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+func TestModulate(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		st   UserState
+		want []string
+	}{
+		{"neutral", UserState{}, nil},
+		{"urgent", UserState{Urgency: []string{"asap"}}, []string{"brevity:short"}},
+		{"upset", UserState{Sentiment: -1}, []string{"empathy:high", "formality:formal"}},
+		{"upset and rushed", UserState{Sentiment: -0.5, Urgency: []string{"hurry"}}, []string{"brevity:short", "empathy:high", "formality:formal"}},
+		{"happy", UserState{Sentiment: 1}, []string{"formality:casual"}},
+		{"low mood, neutral message", UserState{Mood: -0.6, MoodSamples: 4}, []string{"empathy:high", "formality:formal"}},
+		{"low mood, too few samples", UserState{Mood: -0.6, MoodSamples: 1}, nil},
+		{"low mood, cheerful message", UserState{Sentiment: 0.2, Mood: -0.6, MoodSamples: 4}, nil},
+	} {
+		m := Modulate(c.st)
+		if got := m.Applied(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: applied %v, want %v", c.name, got, c.want)
+		}
+		if (len(c.want) == 0) != (m.Instructions() == "") {
+			t.Errorf("%s: instructions %q", c.name, m.Instructions())
+		}
+		if len(c.want) > 0 && len(m.Reasons) == 0 {
+			t.Errorf("%s: no reasons recorded", c.name)
+		}
+	}
+}
+
+func TestBuildTieredPrompt_Modulation(t *testing.T) {
+	cfg := minimalCfg()
+	deps := minimalDeps("openai")
+	deps.LoadSoulFile = func(_ *config.Config, _ string) string { return "You are Ruri." }
+	deps.Modulate = func(_ *config.Config, task *dispatch.Task, _ string) *Modulation {
+		if task.SessionID == "" {
+			return nil
+		}
+		m := Modulate(UserState{Urgency: []string{"asap"}})
+		return &m
+	}
+
+	task := &dispatch.Task{ID: "m1", Prompt: "need it asap", SessionID: "s1"}
+	m := BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if !strings.HasPrefix(task.SystemPrompt, "You are Ruri.\n\n## Response Style\n") {
+		t.Errorf("system prompt %q", task.SystemPrompt)
+	}
+	var items []string
+	for _, s := range m.Sections {
+		if s.Name == "modulation" {
+			items = s.Items
+		}
+	}
+	if !reflect.DeepEqual(items, []string{"brevity:short"}) {
+		t.Errorf("manifest modulation items %v, sections %+v", items, m.Sections)
+	}
+
+	task = &dispatch.Task{ID: "m2", Prompt: "hi"}
+	BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if strings.Contains(task.SystemPrompt, "Response Style") {
+		t.Errorf("unmodulated system prompt %q", task.SystemPrompt)
+	}
+}
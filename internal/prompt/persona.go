@@ -0,0 +1,109 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/scheduling"
+)
+
+// PersonaContext is the situation a persona is chosen for.
+type PersonaContext struct {
+	Time    time.Time
+	Source  string // task source, e.g. "route:telegram", "cron"
+	Channel string // chat session key, e.g. "discord:123"; empty outside chats
+}
+
+// SelectPersona returns the first of the agent's personas whose conditions
+// all hold in pc, or nil.
+func SelectPersona(ac config.AgentConfig, pc PersonaContext) *config.AgentPersonaConfig {
+	for i := range ac.Personas {
+		if personaMatches(ac.Personas[i], pc) {
+			return &ac.Personas[i]
+		}
+	}
+	return nil
+}
+
+func personaMatches(p config.AgentPersonaConfig, pc PersonaContext) bool {
+	if len(p.Sources) > 0 && !matchAny(p.Sources, pc.Source) {
+		return false
+	}
+	if len(p.Channels) > 0 && (pc.Channel == "" || !matchAny(p.Channels, pc.Channel)) {
+		return false
+	}
+	if len(p.Windows) == 0 {
+		return true
+	}
+	loc := time.Local
+	if p.TZ != "" {
+		if l, err := time.LoadLocation(p.TZ); err == nil {
+			loc = l
+		}
+	}
+	windows := make([]scheduling.Window, 0, len(p.Windows))
+	for _, w := range p.Windows {
+		windows = append(windows, scheduling.Window{Days: w.Days, Start: w.Start, End: w.End})
+	}
+	return scheduling.InWindows(windows, pc.Time.In(loc))
+}
+
+// matchAny reports whether s equals one of patterns, where a trailing "*"
+// matches any suffix.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		} else if p == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPersonaFragment returns the persona's text: its inline fragment, or the
+// contents of its file. path is the file read, if any.
+func LoadPersonaFragment(cfg *config.Config, agentName string, p *config.AgentPersonaConfig) (text, path string, err error) {
+	if p.Fragment != "" {
+		return strings.TrimSpace(p.Fragment), "", nil
+	}
+	if p.File == "" {
+		return "", "", fmt.Errorf("persona %q has no fragment or file", p.Name)
+	}
+	path = p.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.AgentsDir, agentName, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", path, err
+	}
+	return strings.TrimSpace(string(data)), path, nil
+}
+
+// ValidatePersona reports configuration errors in p.
+func ValidatePersona(p config.AgentPersonaConfig) error {
+	if p.Name == "" {
+		return fmt.Errorf("persona has no name")
+	}
+	if p.Fragment == "" && p.File == "" {
+		return fmt.Errorf("persona %q has no fragment or file", p.Name)
+	}
+	if p.TZ != "" {
+		if _, err := time.LoadLocation(p.TZ); err != nil {
+			return fmt.Errorf("persona %q: invalid tz %q", p.Name, p.TZ)
+		}
+	}
+	for _, w := range p.Windows {
+		if err := scheduling.ValidateWindow(scheduling.Window{Days: w.Days, Start: w.Start, End: w.End}); err != nil {
+			return fmt.Errorf("persona %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
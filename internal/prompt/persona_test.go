@@ -0,0 +1,95 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+func TestSelectPersona(t *testing.T) {
+	ac := config.AgentConfig{Personas: []config.AgentPersonaConfig{
+		{Name: "family", Channels: []string{"discord:42", "tg:group:*"}},
+		{Name: "work", TZ: "UTC", Windows: []config.AgentScheduleWindow{{Days: []string{"weekdays"}, Start: "09:00", End: "18:00"}}},
+		{Name: "cron", Sources: []string{"cron"}},
+		{Name: "evening", TZ: "UTC", Sources: []string{"route:*"}, Windows: []config.AgentScheduleWindow{{Start: "18:00", End: "02:00"}}},
+	}}
+	wed10 := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	wed23 := time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC)
+	sat10 := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		pc   PersonaContext
+		want string
+	}{
+		{PersonaContext{Time: wed10, Source: "route:discord", Channel: "discord:42"}, "family"},
+		{PersonaContext{Time: wed23, Source: "route:telegram", Channel: "tg:group:-100"}, "family"},
+		{PersonaContext{Time: wed10, Source: "route:discord", Channel: "discord:7"}, "work"},
+		{PersonaContext{Time: wed23, Source: "route:discord"}, "evening"},
+		{PersonaContext{Time: wed23.Add(4 * time.Hour), Source: "route:discord"}, ""},
+		{PersonaContext{Time: wed23, Source: "dispatch"}, ""},
+		{PersonaContext{Time: sat10, Source: "cron"}, "cron"},
+	} {
+		got := ""
+		if p := SelectPersona(ac, c.pc); p != nil {
+			got = p.Name
+		}
+		if got != c.want {
+			t.Errorf("%+v: persona %q, want %q", c.pc, got, c.want)
+		}
+	}
+
+	for _, bad := range []config.AgentPersonaConfig{
+		{Fragment: "x"},
+		{Name: "empty"},
+		{Name: "tz", Fragment: "x", TZ: "Mars/Olympus"},
+		{Name: "win", Fragment: "x", Windows: []config.AgentScheduleWindow{{Start: "25:00"}}},
+	} {
+		if ValidatePersona(bad) == nil {
+			t.Errorf("%+v validated", bad)
+		}
+	}
+}
+
+func TestBuildTieredPrompt_Persona(t *testing.T) {
+	cfg := minimalCfg()
+	cfg.AgentsDir = t.TempDir()
+	os.MkdirAll(filepath.Join(cfg.AgentsDir, "ruri"), 0o755)
+	os.WriteFile(filepath.Join(cfg.AgentsDir, "ruri", "family.md"), []byte("Be warm and playful.\n"), 0o644)
+	cfg.Agents = map[string]config.AgentConfig{"ruri": {Personas: []config.AgentPersonaConfig{
+		{Name: "family", File: "family.md", Channels: []string{"discord:42"}},
+		{Name: "default", Fragment: "Be concise."},
+	}}}
+	deps := minimalDeps("openai")
+	deps.LoadSoulFile = func(_ *config.Config, _ string) string { return "You are Ruri." }
+	deps.ChannelKey = func(_ *config.Config, sessionID string) string {
+		if sessionID == "s-family" {
+			return "discord:42"
+		}
+		return ""
+	}
+
+	task := &dispatch.Task{ID: "p1", Prompt: "hi", SessionID: "s-family", Source: "route:discord"}
+	m := BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if !strings.HasPrefix(task.SystemPrompt, "You are Ruri.\n\nBe warm and playful.") {
+		t.Errorf("family system prompt %q", task.SystemPrompt)
+	}
+	found := false
+	for _, s := range m.Sections {
+		if s.Name == "persona" {
+			found = strings.HasSuffix(s.Path, "family.md")
+		}
+	}
+	if !found {
+		t.Errorf("persona section missing from manifest: %+v", m.Sections)
+	}
+
+	task = &dispatch.Task{ID: "p2", Prompt: "hi", SessionID: "s-other"}
+	BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if !strings.HasPrefix(task.SystemPrompt, "You are Ruri.\n\nBe concise.") || strings.Contains(task.SystemPrompt, "playful") {
+		t.Errorf("default system prompt %q", task.SystemPrompt)
+	}
+}
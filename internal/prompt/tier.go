@@ -6,8 +6,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
-	
 	"tetora/internal/config"
 	"tetora/internal/dispatch"
 	"tetora/internal/knowledge"
@@ -63,6 +63,12 @@ type Deps struct {
 	CollectSkillAllowedTools   func(cfg *config.Config, task dispatch.Task) []string
 	InjectWorkspaceContent     func(cfg *config.Config, task *dispatch.Task, agentName string)
 	EstimateDirSize            func(dir string) int
+	// ChannelKey is optional. If non-nil, it returns the chat session key of
+	// sessionID so personas can be chosen per channel.
+	ChannelKey func(cfg *config.Config, sessionID string) string
+	// Modulate is optional. If non-nil, it returns the response style for the
+	// user the task answers, or nil to leave the prompt as is.
+	Modulate func(cfg *config.Config, task *dispatch.Task, agentName string) *Modulation
 }
 
 // BuildScopeBlock returns the SCOPE HEADER text for a given scope_boundary value.
@@ -144,12 +150,32 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 
 	manifest := NewManifest(task, complexity.String(), pName, providerType, agentName)
 
+	// A/B variant assigned at dispatch: its soul file and model replace the agent's.
+	var variant *config.AgentVariantConfig
+	if exp := cfg.Agents[agentName].Experiment; agentName != "" && exp != nil && task.Variant != "" {
+		variant = exp.Variant(task.Variant)
+	}
+
 	// --- 1. Soul/Agent prompt (always loaded) ---
 	if agentName != "" {
-		soulPrompt := deps.LoadSoulFile(cfg, agentName)
-		soulPath := ""
-		if soulPrompt != "" {
-			soulPath = filepath.Join(cfg.BaseDir, "agents", agentName, "SOUL.md")
+		soulPrompt, soulPath := "", ""
+		if variant != nil && variant.SoulFile != "" {
+			soulPath = variant.SoulFile
+			if !filepath.IsAbs(soulPath) {
+				soulPath = filepath.Join(cfg.AgentsDir, agentName, soulPath)
+			}
+			if data, err := os.ReadFile(soulPath); err == nil {
+				soulPrompt = string(data)
+			} else {
+				log.Warn("variant soul file unreadable, using the agent's", "agent", agentName, "variant", variant.Name, "error", err)
+				soulPath = ""
+			}
+		}
+		if soulPrompt == "" {
+			soulPrompt = deps.LoadSoulFile(cfg, agentName)
+			if soulPrompt != "" {
+				soulPath = filepath.Join(cfg.BaseDir, "agents", agentName, "SOUL.md")
+			}
 		}
 		if soulPrompt == "" {
 			if sp, err := deps.LoadAgentPrompt(cfg, agentName); err == nil {
@@ -171,6 +197,40 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 				HashOf(injected),
 			)
 		}
+
+		// Persona fragment for the time of day and context, after the soul so
+		// truncation never cuts it.
+		pc := PersonaContext{Time: time.Now(), Source: task.Source}
+		if deps.ChannelKey != nil && task.SessionID != "" && len(cfg.Agents[agentName].Personas) > 0 {
+			pc.Channel = deps.ChannelKey(cfg, task.SessionID)
+		}
+		if p := SelectPersona(cfg.Agents[agentName], pc); p != nil {
+			fragment, path, err := LoadPersonaFragment(cfg, agentName, p)
+			if err != nil {
+				log.Warn("persona fragment unreadable", "agent", agentName, "persona", p.Name, "error", err)
+			} else if fragment != "" {
+				if task.SystemPrompt != "" {
+					task.SystemPrompt += "\n\n" + fragment
+				} else {
+					task.SystemPrompt = fragment
+				}
+				manifest.Record("persona", "system_prompt", len(fragment), Path(path), HashOf(fragment))
+			}
+		}
+	}
+
+	// Response style for the user's mood and time pressure.
+	if deps.Modulate != nil {
+		if m := deps.Modulate(cfg, task, agentName); m != nil {
+			if block := m.Instructions(); block != "" {
+				if task.SystemPrompt != "" {
+					task.SystemPrompt += "\n\n" + block
+				} else {
+					task.SystemPrompt = block
+				}
+				manifest.Record("modulation", "system_prompt", len(block), Items(m.Applied()), HashOf(block))
+			}
+		}
 	}
 
 	// --- 2. Workspace directory setup (always) ---
@@ -204,6 +264,9 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 			if task.Model == cfg.DefaultModel && rc.Model != "" {
 				task.Model = rc.Model
 			}
+			if variant != nil && variant.Model != "" && (task.Model == cfg.DefaultModel || task.Model == rc.Model) {
+				task.Model = variant.Model
+			}
 			if task.PermissionMode == cfg.DefaultPermissionMode && rc.PermissionMode != "" {
 				task.PermissionMode = rc.PermissionMode
 			}
@@ -263,6 +326,12 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 	// If provider is claude-code or codex-cli, append skill extraction hint to user prompt and return.
 	// These providers read project files (CLAUDE.md, workspace) natively; system prompt is not used.
 	if providerType == "claude-code" || providerType == "codex-cli" {
+		if complexity != dispatch.Simple {
+			if block := buildKnowledgeSourceBlock(cfg, agentName, "tetora_knowledge_search"); block != "" {
+				task.Prompt += block
+				manifest.Record("knowledge_sources", "user_prompt", len(block))
+			}
+		}
 		preLen := len(task.Prompt)
 		task.Prompt += skillExtractionSection
 		manifest.Record("skill_extraction", "user_prompt", len(task.Prompt)-preLen)
@@ -272,11 +341,14 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 	}
 
 	// --- 5. Knowledge dir ---
-	// Simple: skip. Standard/Complex: inject if exists and < 50KB.
+	// Simple: skip. Standard/Complex: inject the agent's knowledge dirs if
+	// they exist and are < 50KB.
 	if complexity != dispatch.Simple {
-		if cfg.KnowledgeDir != "" && knowledge.HasFiles(cfg.KnowledgeDir) && deps.EstimateDirSize(cfg.KnowledgeDir) <= 50*1024 {
-			task.AddDirs = append(task.AddDirs, cfg.KnowledgeDir)
-			manifest.Record("knowledge_dir", "add_dirs", deps.EstimateDirSize(cfg.KnowledgeDir), Path(cfg.KnowledgeDir))
+		for _, dir := range knowledgeDirs(cfg, agentName) {
+			if knowledge.HasFiles(dir) && deps.EstimateDirSize(dir) <= 50*1024 {
+				task.AddDirs = append(task.AddDirs, dir)
+				manifest.Record("knowledge_dir", "add_dirs", deps.EstimateDirSize(dir), Path(dir))
+			}
 		}
 	}
 
@@ -333,6 +405,12 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 		task.SystemPrompt += block
 		manifest.Record("citation", "system_prompt", len(block))
 	}
+	if complexity != dispatch.Simple {
+		if block := buildKnowledgeSourceBlock(cfg, agentName, "knowledge_search"); block != "" {
+			task.SystemPrompt += block
+			manifest.Record("knowledge_sources", "system_prompt", len(block))
+		}
+	}
 
 	// --- 8.5. Skills injection (with doc tier) ---
 	var skillsPrompt string
@@ -427,6 +505,57 @@ func BuildTieredPrompt(cfg *config.Config, task *dispatch.Task, agentName string
 	return manifest
 }
 
+// knowledgeDirs returns the knowledge directories an agent reads: its
+// scope's subdirectories, none when it is scoped by tags alone, or the
+// whole knowledgeDir.
+func knowledgeDirs(cfg *config.Config, agentName string) []string {
+	if cfg.KnowledgeDir == "" {
+		return nil
+	}
+	ks := cfg.Agents[agentName].Knowledge
+	if ks == nil || (len(ks.Dirs) == 0 && len(ks.Tags) == 0) {
+		return []string{cfg.KnowledgeDir}
+	}
+	var dirs []string
+	for _, d := range ks.Dirs {
+		d = filepath.Clean(filepath.FromSlash(d))
+		if d == "." || filepath.IsAbs(d) || strings.HasPrefix(d, "..") {
+			continue
+		}
+		dirs = append(dirs, filepath.Join(cfg.KnowledgeDir, d))
+	}
+	return dirs
+}
+
+// buildKnowledgeSourceBlock asks the agent to mark each knowledge-grounded
+// statement with its source, which dispatch turns into the result's
+// citations. Empty when there is no knowledge to cite.
+func buildKnowledgeSourceBlock(cfg *config.Config, agentName, searchTool string) string {
+	if cfg.KnowledgeDir == "" || !knowledge.HasFiles(cfg.KnowledgeDir) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Knowledge Sources\n")
+	b.WriteString("When a statement rests on a knowledge base file, put a marker right after it naming the file, " +
+		"relative to the knowledge directory, and the nearest heading above the passage: [source: path/file.md § Heading]. " +
+		"Leave out \"§ Heading\" when the file has no headings. The citation field of " + searchTool + " results is the marker to use. " +
+		"Only cite files you actually read.")
+	if ks := cfg.Agents[agentName].Knowledge; ks != nil && (len(ks.Dirs) > 0 || len(ks.Tags) > 0) {
+		var parts []string
+		if len(ks.Dirs) > 0 {
+			parts = append(parts, "files under "+strings.Join(ks.Dirs, ", "))
+		}
+		if len(ks.Tags) > 0 {
+			parts = append(parts, "files tagged "+strings.Join(ks.Tags, ", "))
+		}
+		b.WriteString("\nYour knowledge is limited to " + strings.Join(parts, " and ") + "; citations outside it are dropped.")
+		if searchTool == "tetora_knowledge_search" {
+			b.WriteString(" Pass agent \"" + sanitizeAgentName(agentName) + "\" to " + searchTool + " to search only there.")
+		}
+	}
+	return b.String()
+}
+
 // recordScopeBoundary prepends the SCOPE HEADER and records it in the manifest.
 func recordScopeBoundary(task *dispatch.Task, scope string, manifest *Manifest) {
 	block := BuildScopeBlock(scope)
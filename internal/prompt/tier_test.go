@@ -392,3 +392,93 @@ func TestNeedsWorkspaceRuleInjection(t *testing.T) {
 		})
 	}
 }
+
+// TestBuildTieredPrompt_Variant checks that an A/B variant's soul file and
+// model replace the agent's, and that other variants keep the agent's own.
+func TestBuildTieredPrompt_Variant(t *testing.T) {
+	cfg := minimalCfg()
+	cfg.AgentsDir = t.TempDir()
+	cfg.DefaultModel = "sonnet"
+	os.MkdirAll(filepath.Join(cfg.AgentsDir, "ruri"), 0o755)
+	os.WriteFile(filepath.Join(cfg.AgentsDir, "ruri", "SOUL.terse.md"), []byte("You are terse."), 0o644)
+	cfg.Agents = map[string]config.AgentConfig{"ruri": {Model: "sonnet", Experiment: &config.AgentExperimentConfig{
+		Variants: []config.AgentVariantConfig{{Name: "control"}, {Name: "terse", SoulFile: "SOUL.terse.md", Model: "haiku"}},
+	}}}
+	deps := minimalDeps("openai")
+	deps.LoadSoulFile = func(_ *config.Config, _ string) string { return "You are Ruri." }
+
+	task := &dispatch.Task{ID: "v1", Prompt: "hi", Model: "sonnet", Variant: "terse"}
+	BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if !strings.HasPrefix(task.SystemPrompt, "You are terse.") || task.Model != "haiku" {
+		t.Errorf("terse variant: model %q, system prompt %q", task.Model, task.SystemPrompt)
+	}
+
+	task = &dispatch.Task{ID: "v2", Prompt: "hi", Model: "sonnet", Variant: "control"}
+	BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if !strings.HasPrefix(task.SystemPrompt, "You are Ruri.") || task.Model != "sonnet" {
+		t.Errorf("control variant: model %q, system prompt %q", task.Model, task.SystemPrompt)
+	}
+
+	// A model the caller chose explicitly is kept.
+	task = &dispatch.Task{ID: "v3", Prompt: "hi", Model: "opus", Variant: "terse"}
+	BuildTieredPrompt(cfg, task, "ruri", dispatch.Simple, deps)
+	if task.Model != "opus" {
+		t.Errorf("explicit model replaced: %q", task.Model)
+	}
+}
+
+func TestBuildTieredPrompt_KnowledgeScope(t *testing.T) {
+	kDir := t.TempDir()
+	os.MkdirAll(filepath.Join(kDir, "policies"), 0o755)
+	os.WriteFile(filepath.Join(kDir, "policies", "leave.md"), []byte("# Leave\n"), 0o644)
+	cfg := minimalCfg()
+	cfg.KnowledgeDir = kDir
+	cfg.Agents = map[string]config.AgentConfig{
+		"hr":    {Knowledge: &config.AgentKnowledgeConfig{Dirs: []string{"policies", "../outside"}, Tags: []string{"people"}}},
+		"tags":  {Knowledge: &config.AgentKnowledgeConfig{Tags: []string{"people"}}},
+		"plain": {},
+	}
+
+	has := func(dirs []string, dir string) bool {
+		for _, d := range dirs {
+			if d == dir {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Knowledge dirs are only kept for complex tasks.
+	task := &dispatch.Task{Prompt: "how much leave?"}
+	BuildTieredPrompt(cfg, task, "hr", dispatch.Complex, minimalDeps("openai"))
+	if !has(task.AddDirs, filepath.Join(kDir, "policies")) || has(task.AddDirs, kDir) {
+		t.Errorf("AddDirs = %v, want the policies dir only", task.AddDirs)
+	}
+	if !strings.Contains(task.SystemPrompt, "[source: path/file.md § Heading]") || !strings.Contains(task.SystemPrompt, "files under policies, ../outside and files tagged people") {
+		t.Errorf("source rule missing or unscoped:\n%s", task.SystemPrompt)
+	}
+
+	task = &dispatch.Task{Prompt: "q"}
+	BuildTieredPrompt(cfg, task, "tags", dispatch.Complex, minimalDeps("openai"))
+	if has(task.AddDirs, kDir) {
+		t.Errorf("tag-scoped AddDirs = %v, want none", task.AddDirs)
+	}
+
+	task = &dispatch.Task{Prompt: "q"}
+	BuildTieredPrompt(cfg, task, "plain", dispatch.Complex, minimalDeps("openai"))
+	if !has(task.AddDirs, kDir) || strings.Contains(task.SystemPrompt, "limited to") {
+		t.Errorf("unscoped agent: AddDirs = %v", task.AddDirs)
+	}
+
+	task = &dispatch.Task{Prompt: "q"}
+	BuildTieredPrompt(cfg, task, "hr", dispatch.Standard, minimalDeps("claude-code"))
+	if !strings.Contains(task.Prompt, `Pass agent "hr" to tetora_knowledge_search`) {
+		t.Errorf("claude-code prompt lacks the search hint:\n%s", task.Prompt)
+	}
+
+	task = &dispatch.Task{Prompt: "q"}
+	BuildTieredPrompt(cfg, task, "hr", dispatch.Simple, minimalDeps("openai"))
+	if strings.Contains(task.SystemPrompt, "Knowledge Sources") {
+		t.Error("simple task got the source rule")
+	}
+}
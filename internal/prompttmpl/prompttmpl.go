@@ -0,0 +1,499 @@
+// Package prompttmpl renders prompt library files as templates.
+//
+// A prompt may start with a frontmatter block setting its model, the role
+// (agent) it is written for, its required variables and their defaults:
+//
+//	---
+//	model: sonnet
+//	role: ruri
+//	required: [topic]
+//	defaults:
+//	  tone: friendly
+//	---
+//
+// The body supports {{var}} substitution, {{#if var}}…{{else}}…{{/if}} and
+// {{#unless var}}…{{/unless}} blocks, and {{> other}} includes of other
+// prompts. A variable that is neither passed nor defaulted is left as is, so
+// the runtime variables expanded later ({{date}}, {{memory.key}}, …) keep
+// working in templates.
+package prompttmpl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Meta is a prompt's frontmatter.
+type Meta struct {
+	Model       string            `json:"model,omitempty"`
+	Role        string            `json:"role,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// Error is a problem found in a prompt.
+type Error struct {
+	Prompt  string `json:"prompt"`
+	Line    int    `json:"line,omitempty"` // 1-based line in the prompt file
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Prompt, e.Line, e.Message)
+	}
+	return e.Prompt + ": " + e.Message
+}
+
+// Errors lists the problems of a prompt and its includes.
+type Errors []*Error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Loader returns the content of the named prompt.
+type Loader func(name string) (string, error)
+
+// maxIncludeDepth bounds nested includes.
+const maxIncludeDepth = 10
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	varNode
+	ifNode
+	includeNode
+)
+
+type node struct {
+	kind nodeKind
+	text string // text, or the variable, condition or include name
+	raw  string // the original tag of a variable
+	not  bool   // #unless or #if !var
+	then []node
+	els  []node
+	line int
+}
+
+// Template is a parsed prompt.
+type Template struct {
+	Name  string
+	Meta  Meta
+	nodes []node
+}
+
+var (
+	varRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.:\-]*$`)
+	nameRe = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+)
+
+// Parse parses a prompt. Problems are returned as Errors along with the
+// template, which is usable only when there are none.
+func Parse(name, content string) (*Template, error) {
+	t := &Template{Name: name}
+	var errs Errors
+	add := func(line int, format string, args ...any) {
+		errs = append(errs, &Error{Prompt: name, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	body, bodyLine := t.parseFrontmatter(content, add)
+	t.nodes = parseBody(body, bodyLine, add)
+	if len(errs) > 0 {
+		return t, errs
+	}
+	return t, nil
+}
+
+// parseFrontmatter fills t.Meta and returns the body and its first line.
+func (t *Template) parseFrontmatter(content string, add func(int, string, ...any)) (string, int) {
+	if !strings.HasPrefix(content, "---\n") {
+		return content, 1
+	}
+	lines := strings.Split(content, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], " \t") == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		add(1, "frontmatter is not closed with ---")
+		return content, 1
+	}
+	section := "" // "required" or "defaults" while reading their indented lines
+	for i := 1; i < end; i++ {
+		line, n := lines[i], i+1
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		switch {
+		case section == "required" && strings.HasPrefix(trimmed, "- "):
+			t.Meta.Required = append(t.Meta.Required, unquote(strings.TrimPrefix(trimmed, "- ")))
+			continue
+		case indented && section == "defaults":
+			k, v, ok := strings.Cut(trimmed, ":")
+			if !ok || !varRe.MatchString(strings.TrimSpace(k)) {
+				add(n, "invalid default %q", trimmed)
+				continue
+			}
+			if t.Meta.Defaults == nil {
+				t.Meta.Defaults = make(map[string]string)
+			}
+			t.Meta.Defaults[strings.TrimSpace(k)] = unquote(v)
+			continue
+		case indented:
+			add(n, "unexpected indented line %q", trimmed)
+			continue
+		}
+		section = ""
+		k, v, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			add(n, "expected key: value, got %q", trimmed)
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.TrimSpace(k) {
+		case "model":
+			t.Meta.Model = unquote(v)
+		case "role":
+			t.Meta.Role = unquote(v)
+		case "description":
+			t.Meta.Description = unquote(v)
+		case "required":
+			if v == "" {
+				section = "required"
+			} else {
+				t.Meta.Required = append(t.Meta.Required, parseList(v)...)
+			}
+		case "defaults":
+			if v != "" {
+				add(n, "defaults must be an indented block of name: value lines")
+			}
+			section = "defaults"
+		default:
+			add(n, "unknown frontmatter key %q (use model, role, description, required, defaults)", strings.TrimSpace(k))
+		}
+	}
+	for _, r := range t.Meta.Required {
+		if !varRe.MatchString(r) {
+			add(1, "invalid required variable %q", r)
+		}
+	}
+	return strings.Join(lines[end+1:], "\n"), end + 2
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseList parses an inline "[a, b]" list or a bare comma-separated one.
+func parseList(s string) []string {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = unquote(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// frame is an open block while parsing.
+type frame struct {
+	n      node
+	tag    string // "if" or "unless"
+	inElse bool
+	parent *[]node
+}
+
+// parseBody parses the template body. Block tags and includes alone on their
+// line take the whole line, so they leave no blank lines behind.
+func parseBody(src string, firstLine int, add func(int, string, ...any)) []node {
+	var root []node
+	cur := &root
+	var stack []*frame
+	lineAt := func(pos int) int { return firstLine + strings.Count(src[:pos], "\n") }
+
+	textStart := 0
+	for textStart <= len(src) {
+		i := strings.Index(src[textStart:], "{{")
+		if i < 0 {
+			break
+		}
+		i += textStart
+		j := strings.Index(src[i:], "}}")
+		if j < 0 {
+			add(lineAt(i), "unclosed {{")
+			break
+		}
+		j += i + 2
+		tag := strings.TrimSpace(src[i+2 : j-2])
+		line := lineAt(i)
+
+		block := strings.HasPrefix(tag, "#") || strings.HasPrefix(tag, "/") || strings.HasPrefix(tag, ">") || tag == "else"
+		textEnd, next := i, j
+		if block {
+			lineStart := max(strings.LastIndexByte(src[:i], '\n')+1, textStart)
+			lineEnd := strings.IndexByte(src[j:], '\n')
+			rest := src[j:]
+			if lineEnd >= 0 {
+				rest = src[j : j+lineEnd]
+			}
+			if strings.TrimSpace(src[lineStart:i]) == "" && strings.TrimSpace(rest) == "" &&
+				(lineStart == 0 || src[lineStart-1] == '\n') {
+				textEnd = lineStart
+				next = j + len(rest)
+				if lineEnd >= 0 {
+					next++
+				}
+			}
+		}
+		if textEnd > textStart {
+			*cur = append(*cur, node{kind: textNode, text: src[textStart:textEnd]})
+		}
+		textStart = next
+
+		switch {
+		case strings.HasPrefix(tag, "#"):
+			kind, cond, _ := strings.Cut(tag[1:], " ")
+			cond = strings.TrimSpace(cond)
+			if kind != "if" && kind != "unless" {
+				add(line, "unknown block {{#%s}} (use #if or #unless)", kind)
+				continue
+			}
+			f := &frame{n: node{kind: ifNode, not: kind == "unless", line: line}, tag: kind, parent: cur}
+			if kind == "if" && strings.HasPrefix(cond, "!") {
+				f.n.not, cond = true, strings.TrimSpace(cond[1:])
+			}
+			if !varRe.MatchString(cond) {
+				add(line, "{{#%s}} needs a variable name, got %q", kind, cond)
+			}
+			f.n.text = cond
+			stack = append(stack, f)
+			cur = &f.n.then
+		case tag == "else":
+			if len(stack) == 0 {
+				add(line, "{{else}} outside a block")
+				continue
+			}
+			f := stack[len(stack)-1]
+			if f.inElse {
+				add(line, "second {{else}} in {{#%s}} from line %d", f.tag, f.n.line)
+				continue
+			}
+			f.inElse = true
+			cur = &f.n.els
+		case strings.HasPrefix(tag, "/"):
+			kind := strings.TrimSpace(tag[1:])
+			if len(stack) == 0 {
+				add(line, "{{/%s}} without an open block", kind)
+				continue
+			}
+			f := stack[len(stack)-1]
+			if kind != f.tag {
+				add(line, "{{/%s}} closes {{#%s}} from line %d", kind, f.tag, f.n.line)
+			}
+			stack = stack[:len(stack)-1]
+			cur = f.parent
+			*cur = append(*cur, f.n)
+		case strings.HasPrefix(tag, ">"):
+			name := strings.TrimSuffix(strings.TrimSpace(tag[1:]), ".md")
+			if !nameRe.MatchString(name) {
+				add(line, "invalid include name %q", name)
+				continue
+			}
+			*cur = append(*cur, node{kind: includeNode, text: name, line: line})
+		case varRe.MatchString(tag):
+			*cur = append(*cur, node{kind: varNode, text: tag, raw: src[i:j], line: line})
+		default:
+			// Not ours (e.g. {{reflection.context:…}}): kept for later expansion.
+			*cur = append(*cur, node{kind: textNode, text: src[i:j]})
+		}
+	}
+	if textStart < len(src) {
+		*cur = append(*cur, node{kind: textNode, text: src[textStart:]})
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		add(stack[i].n.line, "{{#%s}} is not closed", stack[i].tag)
+	}
+	return root
+}
+
+// set collects the templates reachable from a prompt.
+type set struct {
+	load   Loader
+	parsed map[string]*Template
+	errs   Errors
+}
+
+// get parses a prompt once. content overrides the loader for the root.
+func (s *set) get(name string) (*Template, bool) {
+	if t, ok := s.parsed[name]; ok {
+		return t, t != nil
+	}
+	content, err := s.load(name)
+	if err != nil {
+		s.parsed[name] = nil
+		return nil, false
+	}
+	return s.add(name, content), true
+}
+
+func (s *set) add(name, content string) *Template {
+	t, err := Parse(name, content)
+	if err != nil {
+		s.errs = append(s.errs, err.(Errors)...)
+	}
+	s.parsed[name] = t
+	return t
+}
+
+// check follows the includes of t, reporting missing prompts and cycles.
+func (s *set) check(t *Template, path []string) {
+	path = append(path, t.Name)
+	var walk func(nodes []node)
+	walk = func(nodes []node) {
+		for _, n := range nodes {
+			switch n.kind {
+			case ifNode:
+				walk(n.then)
+				walk(n.els)
+			case includeNode:
+				switch {
+				case slices.Contains(path, n.text):
+					s.errs = append(s.errs, &Error{Prompt: t.Name, Line: n.line,
+						Message: "include cycle: " + strings.Join(append(path, n.text), " -> ")})
+				case len(path) >= maxIncludeDepth:
+					s.errs = append(s.errs, &Error{Prompt: t.Name, Line: n.line, Message: fmt.Sprintf("includes nested deeper than %d", maxIncludeDepth)})
+				default:
+					inc, ok := s.get(n.text)
+					if !ok {
+						s.errs = append(s.errs, &Error{Prompt: t.Name, Line: n.line, Message: fmt.Sprintf("included prompt %q not found", n.text)})
+						continue
+					}
+					s.check(inc, path)
+				}
+			}
+		}
+	}
+	walk(t.nodes)
+}
+
+// Validate checks a prompt's content and the prompts it includes, loaded
+// with load.
+func Validate(name, content string, load Loader) Errors {
+	s := &set{load: load, parsed: make(map[string]*Template)}
+	t := s.add(name, content)
+	s.check(t, nil)
+	return s.errs
+}
+
+// Result is a rendered prompt.
+type Result struct {
+	Text     string   `json:"text"`
+	Model    string   `json:"model,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Includes []string `json:"includes,omitempty"` // prompts included, in order
+}
+
+// Render loads the named prompt and renders it with vars. Invalid templates
+// and missing required variables are returned as Errors.
+func Render(name string, load Loader, vars map[string]string) (*Result, error) {
+	content, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+	return RenderContent(name, content, load, vars)
+}
+
+// RenderContent renders content as the named prompt; includes are loaded
+// with load.
+func RenderContent(name, content string, load Loader, vars map[string]string) (*Result, error) {
+	s := &set{load: load, parsed: make(map[string]*Template)}
+	t := s.add(name, content)
+	s.check(t, nil)
+	if len(s.errs) > 0 {
+		return nil, s.errs
+	}
+	res := &Result{Model: t.Meta.Model, Role: t.Meta.Role}
+	var b strings.Builder
+	s.render(&b, t, vars, res)
+	if len(s.errs) > 0 {
+		return nil, s.errs
+	}
+	res.Text = b.String()
+	return res, nil
+}
+
+// render writes t with the caller's vars over t's defaults. Missing required
+// variables are added to s.errs.
+func (s *set) render(b *strings.Builder, t *Template, vars map[string]string, res *Result) {
+	scope := make(map[string]string, len(vars)+len(t.Meta.Defaults))
+	for k, v := range t.Meta.Defaults {
+		scope[k] = v
+	}
+	for k, v := range vars {
+		scope[k] = v
+	}
+	for _, r := range t.Meta.Required {
+		if _, ok := scope[r]; !ok {
+			s.errs = append(s.errs, &Error{Prompt: t.Name, Message: fmt.Sprintf("missing required variable %q", r)})
+		}
+	}
+	var walk func(nodes []node)
+	walk = func(nodes []node) {
+		for _, n := range nodes {
+			switch n.kind {
+			case textNode:
+				b.WriteString(n.text)
+			case varNode:
+				if v, ok := scope[n.text]; ok {
+					b.WriteString(v)
+				} else {
+					b.WriteString(n.raw)
+				}
+			case ifNode:
+				if truthy(scope, n.text) != n.not {
+					walk(n.then)
+				} else {
+					walk(n.els)
+				}
+			case includeNode:
+				inc := s.parsed[n.text]
+				res.Includes = append(res.Includes, n.text)
+				s.render(b, inc, scope, res)
+			}
+		}
+	}
+	walk(t.nodes)
+}
+
+// truthy reports whether a condition variable is set to something other than
+// an empty or false-like value.
+func truthy(scope map[string]string, name string) bool {
+	v, ok := scope[name]
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "false", "0", "no", "off":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package prompttmpl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loader(prompts map[string]string) Loader {
+	return func(name string) (string, error) {
+		if c, ok := prompts[name]; ok {
+			return c, nil
+		}
+		return "", fmt.Errorf("prompt %q not found", name)
+	}
+}
+
+func TestParse_Frontmatter(t *testing.T) {
+	tmpl, err := Parse("brief", "---\nmodel: sonnet\nrole: \"ruri\"\nrequired:\n  - topic\n  - audience\ndefaults:\n  tone: friendly\n---\nHi {{topic}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Meta{Model: "sonnet", Role: "ruri", Required: []string{"topic", "audience"}, Defaults: map[string]string{"tone": "friendly"}}
+	if !reflect.DeepEqual(tmpl.Meta, want) {
+		t.Errorf("meta = %+v", tmpl.Meta)
+	}
+
+	_, err = Parse("bad", "---\nmodel: sonnet\ntemperature: 2\n---\n")
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 3 || !strings.Contains(errs[0].Message, "temperature") {
+		t.Errorf("unknown key: %v", err)
+	}
+	if _, err := Parse("open", "---\nmodel: x\n"); err == nil {
+		t.Error("unclosed frontmatter accepted")
+	}
+}
+
+func TestRender(t *testing.T) {
+	prompts := map[string]string{
+		"report": "---\nmodel: haiku\nrequired: [topic]\ndefaults:\n  tone: neutral\n---\n" +
+			"Write a {{tone}} report on {{topic}} for {{date}}.\n" +
+			"{{#if detailed}}\n" +
+			"Include sources.\n" +
+			"{{else}}\n" +
+			"Keep it short.\n" +
+			"{{/if}}\n" +
+			"{{> footer}}\n",
+		"footer": "---\nrequired: [signoff]\n---\n{{#unless quiet}}— {{signoff}}{{/unless}}\n",
+	}
+	load := loader(prompts)
+
+	res, err := Render("report", load, map[string]string{"topic": "rain", "signoff": "Tetora", "detailed": "yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Write a neutral report on rain for {{date}}.\nInclude sources.\n— Tetora\n"
+	if res.Text != want || res.Model != "haiku" || !reflect.DeepEqual(res.Includes, []string{"footer"}) {
+		t.Errorf("render = %q, %+v", res.Text, res)
+	}
+
+	res, err = Render("report", load, map[string]string{"topic": "rain", "signoff": "x", "tone": "cheerful", "detailed": "false", "quiet": "1"})
+	if err != nil || res.Text != "Write a cheerful report on rain for {{date}}.\nKeep it short.\n\n" {
+		t.Errorf("render = %q, %v", res.Text, err)
+	}
+
+	_, err = Render("report", load, map[string]string{"topic": "rain"})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Prompt != "footer" || !strings.Contains(errs[0].Message, "signoff") {
+		t.Errorf("missing var: %v", err)
+	}
+
+	plain, err := Render("plain", loader(map[string]string{"plain": "Summarize {{memory.todo}} {{reflection.context:ruri}}"}), nil)
+	if err != nil || plain.Text != "Summarize {{memory.todo}} {{reflection.context:ruri}}" {
+		t.Errorf("plain = %+v, %v", plain, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	load := loader(map[string]string{
+		"a": "{{> b}}",
+		"b": "{{> a}}",
+	})
+	for content, want := range map[string]string{
+		"{{#if x}}open":                    "{{#if}} is not closed",
+		"{{/if}}":                          "without an open block",
+		"{{#if x}}{{/unless}}":             "closes {{#if}}",
+		"{{#each items}}{{/each}}":         "unknown block",
+		"{{#if x}}{{else}}{{else}}{{/if}}": "second {{else}}",
+		"{{> missing}}":                    `"missing" not found`,
+		"{{> a}}":                          "include cycle: root -> a -> b -> a",
+		"text {{oops":                      "unclosed {{",
+	} {
+		errs := Validate("root", content, load)
+		if len(errs) == 0 || !strings.Contains(errs.Error(), want) {
+			t.Errorf("%q: errors = %v, want %q", content, errs, want)
+		}
+	}
+	if errs := Validate("ok", "line 1\n{{#if x}}\n{{y}}\n{{/if}}", load); len(errs) != 0 {
+		t.Errorf("valid template: %v", errs)
+	}
+	errs := Validate("lines", "---\nmodel: x\n---\nfirst\n\n{{/if}}", load)
+	if len(errs) != 1 || errs[0].Line != 6 {
+		t.Errorf("line numbers: %v", errs)
+	}
+}
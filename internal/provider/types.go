@@ -103,6 +103,7 @@ func IsTransientError(errMsg string) bool {
 		"connection refused", "connection reset",
 		"eof", "broken pipe",
 		"http 5", "status 5",
+		"http 429", "status 429", "overloaded",
 		"temporarily unavailable", "service unavailable",
 		"too many requests", "rate limit",
 		"hit your limit", // Claude CLI quota exhaustion
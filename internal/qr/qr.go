@@ -0,0 +1,486 @@
+// Package qr encodes text as a QR code (ISO/IEC 18004) and draws it as PNG
+// or SVG, with the standard library alone.
+//
+// Text is encoded in byte mode as UTF-8 with error correction level M
+// (about 15% of the symbol can be damaged), in versions 1 to 20: up to 666
+// bytes, plenty for links, pairing URIs and short messages.
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// MaxBytes is the longest text that fits in the largest supported version.
+const MaxBytes = 666
+
+// Code is an encoded QR symbol.
+type Code struct {
+	Size    int // modules per side, 21 to 97
+	Version int
+	modules [][]bool // [y][x], true is dark
+}
+
+// Dark reports whether the module at x, y is dark. Outside the symbol is
+// light.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// blocks describes the error correction of a version at level M: the EC
+// codewords per block, and the number and data length of the blocks in
+// each of the two groups.
+type blocks struct {
+	ec     int
+	n1, d1 int
+	n2, d2 int
+}
+
+var levelM = [21]blocks{
+	1:  {10, 1, 16, 0, 0},
+	2:  {16, 1, 28, 0, 0},
+	3:  {26, 1, 44, 0, 0},
+	4:  {18, 2, 32, 0, 0},
+	5:  {24, 2, 43, 0, 0},
+	6:  {16, 4, 27, 0, 0},
+	7:  {18, 4, 31, 0, 0},
+	8:  {22, 2, 38, 2, 39},
+	9:  {22, 3, 36, 2, 37},
+	10: {26, 4, 43, 1, 44},
+	11: {30, 1, 50, 4, 51},
+	12: {22, 6, 36, 2, 37},
+	13: {22, 8, 37, 1, 38},
+	14: {24, 4, 40, 5, 41},
+	15: {24, 5, 41, 5, 42},
+	16: {28, 7, 45, 3, 46},
+	17: {28, 10, 46, 1, 47},
+	18: {26, 9, 43, 4, 44},
+	19: {26, 3, 44, 11, 45},
+	20: {26, 3, 41, 13, 42},
+}
+
+func (b blocks) data() int { return b.n1*b.d1 + b.n2*b.d2 }
+
+// alignment holds the alignment pattern centers of each version.
+var alignment = [21][]int{
+	2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+	11: {6, 30, 54}, 12: {6, 32, 58}, 13: {6, 34, 62},
+	14: {6, 26, 46, 66}, 15: {6, 26, 48, 70}, 16: {6, 26, 50, 74},
+	17: {6, 30, 54, 78}, 18: {6, 30, 56, 82}, 19: {6, 30, 58, 86}, 20: {6, 34, 62, 90},
+}
+
+// Encode makes the smallest QR code that holds text.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= 20; v++ {
+		if 4+countBits(v)+8*len(data) <= levelM[v].data()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("qr: text is %d bytes; at most %d fit", len(data), MaxBytes)
+	}
+
+	// Mode, length, data, terminator and padding.
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := levelM[version].data() * 8
+	bits.append(0, min(4, capacity-bits.n))
+	bits.append(0, (8-bits.n%8)%8)
+	for pad := 0xEC; bits.n < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c := newCode(version)
+	c.place(interleave(bits.bytes, levelM[version]))
+	c.applyBestMask()
+	return &c.Code, nil
+}
+
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+type bitBuffer struct {
+	bytes []byte
+	n     int
+}
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if v>>i&1 == 1 {
+			b.bytes[b.n/8] |= 0x80 >> (b.n % 8)
+		}
+		b.n++
+	}
+}
+
+// interleave splits data into blocks, adds each block's error correction
+// and interleaves the codewords.
+func interleave(data []byte, bl blocks) []byte {
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < bl.n1+bl.n2; i++ {
+		n := bl.d1
+		if i >= bl.n1 {
+			n = bl.d2
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomon(data[:n], bl.ec))
+		data = data[n:]
+	}
+	var out []byte
+	for i := 0; i < max(bl.d1, bl.d2); i++ {
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < bl.ec; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// --- Reed-Solomon over GF(256) with the polynomial 0x11D ---
+
+var gfExp, gfLog = func() (exp [512]byte, log [256]byte) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// reedSolomon returns the n error correction codewords of data.
+func reedSolomon(data []byte, n int) []byte {
+	// Generator (x - a^0)(x - a^1)...(x - a^(n-1)), highest term first
+	// and implied.
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	rem := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+// --- Symbol layout ---
+
+type builder struct {
+	Code
+	function [][]bool // modules that are not data
+}
+
+func newCode(version int) *builder {
+	size := version*4 + 17
+	c := &builder{Code: Code{Size: size, Version: version}}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.finder(3, 3)
+	c.finder(size-4, 3)
+	c.finder(3, size-4)
+	pos := alignment[version]
+	for i, y := range pos {
+		for j, x := range pos {
+			if i == 0 && j == 0 || i == 0 && j == len(pos)-1 || i == len(pos)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	c.format(0) // reserve the format areas; drawn again with the mask
+	if version >= 7 {
+		bits := version<<12 | bch(version, 0x1F25, 12)
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			c.set(a, b, bits>>i&1 == 1)
+			c.set(b, a, bits>>i&1 == 1)
+		}
+	}
+	return c
+}
+
+func (c *builder) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *builder) finder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x >= 0 && y >= 0 && x < c.Size && y < c.Size {
+				d := max(abs(dx), abs(dy))
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+}
+
+// formatBits returns the 15 format bits for level M and a mask.
+func formatBits(mask int) int {
+	data := 0b00<<3 | mask // level M is 00
+	return (data<<10 | bch(data, 0x537, 10)) ^ 0x5412
+}
+
+// bch returns the remainder of v shifted by n bits, divided by poly.
+func bch(v, poly, n int) int {
+	rem := v
+	for i := 0; i < n; i++ {
+		rem = rem<<1 ^ (rem>>(n-1))*poly
+	}
+	return rem & (1<<n - 1)
+}
+
+func (c *builder) format(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true) // the dark module
+}
+
+// place lays the codewords out in the zigzag column pairs, right to left.
+func (c *builder) place(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.function[y][x] && i < len(codewords)*8 {
+					c.modules[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+var masks = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+func (c *builder) mask(m int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masks[m](x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty score.
+func (c *builder) applyBestMask() {
+	best, bestScore := 0, -1
+	for m := range masks {
+		c.mask(m)
+		c.format(m)
+		if s := c.penalty(); bestScore < 0 || s < bestScore {
+			best, bestScore = m, s
+		}
+		c.mask(m) // masking twice undoes it
+	}
+	c.mask(best)
+	c.format(best)
+}
+
+// penalty scores the symbol by the four rules of the standard: runs of one
+// color, 2x2 blocks, finder-like patterns and an unbalanced dark ratio.
+func (c *builder) penalty() int {
+	score, dark := 0, 0
+	line := func(get func(i int) bool) {
+		run := 0
+		for i := 0; i < c.Size; i++ {
+			if i > 0 && get(i) == get(i-1) {
+				run++
+			} else {
+				run = 1
+			}
+			if run == 5 {
+				score += 3
+			} else if run > 5 {
+				score++
+			}
+		}
+		// 1:1:3:1:1 dark-light ratio next to four light modules.
+		pattern := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= c.Size; i++ {
+			match := true
+			for k, v := range pattern {
+				if get(i+k) != v {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			lightBefore, lightAfter := true, true
+			for k := 1; k <= 4; k++ {
+				lightBefore = lightBefore && !get(i-k)
+				lightAfter = lightAfter && !get(i+6+k)
+			}
+			if lightBefore || lightAfter {
+				score += 40
+			}
+		}
+	}
+	for y := 0; y < c.Size; y++ {
+		line(func(i int) bool { return c.Dark(i, y) })
+	}
+	for x := 0; x < c.Size; x++ {
+		line(func(i int) bool { return c.Dark(x, i) })
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			d := c.modules[y][x]
+			if d {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size && d == c.modules[y][x+1] && d == c.modules[y+1][x] && d == c.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+	total := c.Size * c.Size
+	score += abs(dark*20-total*10) / total * 10
+	return score
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// --- Output ---
+
+// quiet is the light border, in modules, that readers need around a code.
+const quiet = 4
+
+// PNG draws the code with each module scale pixels wide.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	scale = min(max(scale, 1), 32)
+	side := (c.Size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			if c.Dark(x/scale-quiet, y/scale-quiet) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG draws the code as one path, size pixels wide.
+func (c *Code) SVG(size int) []byte {
+	n := c.Size + 2*quiet
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, n, n)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>` + "\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,206 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" as 1-M in alphanumeric mode, the standard worked example.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("reedSolomon = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0b101010000010010 {
+		t.Errorf("format M/0 = %015b", got)
+	}
+	if got := formatBits(5); got != 0b100000011001110 {
+		t.Errorf("format M/5 = %015b", got)
+	}
+	if got := 7<<12 | bch(7, 0x1F25, 12); got != 0x07C94 {
+		t.Errorf("version 7 = %#x", got)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	for v := 1; v <= 20; v++ {
+		b := levelM[v]
+		size := v*4 + 17
+		c := newCode(v)
+		free := 0
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if !c.function[y][x] {
+					free++
+				}
+			}
+		}
+		total := b.data() + (b.n1+b.n2)*b.ec
+		if free/8 != total {
+			t.Errorf("version %d: %d data modules for %d codewords", v, free, total)
+		}
+	}
+	if _, err := Encode(strings.Repeat("x", MaxBytes)); err != nil {
+		t.Error(err)
+	}
+	if _, err := Encode(strings.Repeat("x", MaxBytes+1)); err == nil {
+		t.Error("oversized text accepted")
+	}
+}
+
+// decode reads the text back from a code, checking the format bits and
+// every block's error correction on the way.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	b := newCode(c.Version)
+	fmtBits := 0
+	read := func(x, y, i int) {
+		if c.Dark(x, y) {
+			fmtBits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		read(8, i, i)
+	}
+	read(8, 7, 6)
+	read(8, 8, 7)
+	read(7, 8, 8)
+	for i := 9; i < 15; i++ {
+		read(14-i, 8, i)
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == fmtBits {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("bad format bits %015b", fmtBits)
+	}
+
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !b.function[y][x] {
+					v := 0
+					if c.Dark(x, y) != masks[mask](x, y) {
+						v = 1
+					}
+					bits.append(v, 1)
+				}
+			}
+		}
+	}
+
+	bl := levelM[c.Version]
+	n := bl.n1 + bl.n2
+	blocks := make([][]byte, n)
+	words := bits.bytes
+	for i := 0; i < max(bl.d1, bl.d2); i++ {
+		for k := range blocks {
+			if i < bl.d1 || k >= bl.n1 {
+				blocks[k] = append(blocks[k], words[0])
+				words = words[1:]
+			}
+		}
+	}
+	ec := make([][]byte, n)
+	for i := 0; i < bl.ec; i++ {
+		for k := range ec {
+			ec[k] = append(ec[k], words[0])
+			words = words[1:]
+		}
+	}
+	var data []byte
+	for k := range blocks {
+		if !bytes.Equal(reedSolomon(blocks[k], bl.ec), ec[k]) {
+			t.Fatalf("block %d: error correction mismatch", k)
+		}
+		data = append(data, blocks[k]...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		t.Fatalf("mode %04b", data[0]>>4)
+	}
+	get := func(pos, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(data[(pos+i)/8]>>(7-(pos+i)%8)&1)
+		}
+		return v
+	}
+	length := get(4, countBits(c.Version))
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(get(4+countBits(c.Version)+8*i, 8))
+	}
+	return string(out)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"https://example.com",
+		"otpauth://totp/Tetora:admin?secret=JBSWY3DPEHPK3PXP&issuer=Tetora",
+		"日本語のテキスト",
+		strings.Repeat("0123456789", 30),
+		strings.Repeat("z", MaxBytes),
+	} {
+		c, err := Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != c.Version*4+17 {
+			t.Errorf("size %d for version %d", c.Size, c.Version)
+		}
+		// Finder pattern corners and timing.
+		if !c.Dark(0, 0) || !c.Dark(c.Size-1, 0) || !c.Dark(0, c.Size-1) || c.Dark(7, 7) || !c.Dark(8, 6) || c.Dark(9, 6) {
+			t.Errorf("%q: function patterns wrong", text)
+		}
+		if got := decode(t, c); got != text {
+			t.Errorf("decoded %q, want %q", got, text)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	c, err := Encode("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.PNG(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if side := (c.Size + 8) * 4; img.Bounds().Dx() != side {
+		t.Errorf("width = %d, want %d", img.Bounds().Dx(), side)
+	}
+	if r, _, _, _ := img.At(16, 16).RGBA(); r != 0 {
+		t.Error("top-left finder not dark")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("quiet zone not light")
+	}
+	if svg := c.SVG(200); !bytes.HasPrefix(svg, []byte("<svg")) || !bytes.Contains(svg, []byte("M4 4h1v1h-1z")) {
+		t.Errorf("svg = %s", svg)
+	}
+}
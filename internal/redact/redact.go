@@ -0,0 +1,207 @@
+// Package redact scrubs personal data and secrets (email addresses, phone
+// numbers, API keys, credit card numbers and configured patterns) from text
+// before it is stored or sent out.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tetora/internal/config"
+)
+
+// Detector is a named rule. Valid, if set, confirms a regex match (e.g. a
+// Luhn check) so lookalikes are left alone.
+type Detector struct {
+	Name  string
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+// Builtins are the detectors available by name in security.redaction.detectors.
+var Builtins = []Detector{
+	{Name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{Name: "apiKey", re: regexp.MustCompile(strings.Join([]string{
+		`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`, // Anthropic, OpenAI
+		`\bgh[pousr]_[A-Za-z0-9]{36,}`,           // GitHub
+		`\bgithub_pat_[A-Za-z0-9_]{22,}`,
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`, // Slack
+		`\bAKIA[0-9A-Z]{16}\b`,           // AWS access key
+		`\bAIza[0-9A-Za-z_-]{35}`,        // Google
+		`\b[rs]k_live_[0-9A-Za-z]{16,}`,  // Stripe
+		`(?i:\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*)`,
+		`(?i:\b(?:api[_-]?key|secret|token|password|passwd)\b["']?\s*[:=]\s*["']?[^\s"',;]{8,})`,
+	}, "|"))},
+	{Name: "creditCard", re: regexp.MustCompile(`\b[2-6](?:[ -]?\d){12,18}\b`), valid: luhn}, // card networks start with 2-6; chat IDs mostly with 1
+	{Name: "phone", re: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{1,4}\)[\s.-]?)?\d{2,4}[\s.-]\d{3,4}[\s.-]?\d{3,4}\b`), valid: phoneDigits},
+}
+
+// Match is one piece of text a detector found.
+type Match struct {
+	Detector string `json:"detector"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// Redactor applies a set of detectors.
+type Redactor struct {
+	detectors []Detector
+	bypass    map[string]bool
+}
+
+// New builds a redactor from cfg plus extra legacy patterns (named "pii").
+// Unknown detector names and invalid patterns are returned as errors and
+// skipped; the redactor is usable either way.
+func New(cfg config.RedactionConfig, extra []string) (*Redactor, []error) {
+	r := &Redactor{bypass: make(map[string]bool)}
+	var errs []error
+	if len(cfg.Detectors) == 0 {
+		r.detectors = append(r.detectors, Builtins...)
+	}
+	for _, name := range cfg.Detectors {
+		d, ok := builtin(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown detector %q", name))
+			continue
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	patterns := append([]config.RedactionPattern(nil), cfg.Patterns...)
+	for _, p := range extra {
+		patterns = append(patterns, config.RedactionPattern{Name: "pii", Pattern: p})
+	}
+	for _, p := range patterns {
+		d, err := Compile(p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	for _, a := range cfg.Bypass {
+		r.bypass[a] = true
+	}
+	return r, errs
+}
+
+// Compile turns a configured pattern into a detector.
+func Compile(p config.RedactionPattern) (Detector, error) {
+	name := p.Name
+	if name == "" {
+		name = "custom"
+	}
+	re, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return Detector{}, fmt.Errorf("pattern %q: %w", name, err)
+	}
+	if re.MatchString("") {
+		return Detector{}, fmt.Errorf("pattern %q matches empty text", name)
+	}
+	return Detector{Name: name, re: re}, nil
+}
+
+func builtin(name string) (Detector, bool) {
+	for _, d := range Builtins {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Detector{}, false
+}
+
+// Bypassed reports whether agent's content is kept unredacted.
+func (r *Redactor) Bypassed(agent string) bool {
+	return agent != "" && r.bypass[agent]
+}
+
+// For redacts text produced by or for agent, unless the agent is bypassed.
+func (r *Redactor) For(agent, text string) string {
+	if r == nil || r.Bypassed(agent) {
+		return text
+	}
+	return r.Redact(text)
+}
+
+// Redact replaces every match with "[REDACTED:<detector>]".
+func (r *Redactor) Redact(text string) string {
+	matches := r.Find(text)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString("[REDACTED:" + m.Detector + "]")
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Find returns the non-overlapping matches in text, in order. Where two
+// detectors overlap, the earlier and then longer match wins.
+func (r *Redactor) Find(text string) []Match {
+	if r == nil || text == "" {
+		return nil
+	}
+	var all []Match
+	for _, d := range r.detectors {
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			if d.valid != nil && !d.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			all = append(all, Match{Detector: d.Name, Start: loc[0], End: loc[1]})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].End > all[j].End
+	})
+	out := all[:0]
+	end := 0
+	for _, m := range all {
+		if m.Start < end {
+			continue
+		}
+		out = append(out, m)
+		end = m.End
+	}
+	return out
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// phoneDigits keeps matches with a plausible number of digits, so dates and
+// version strings are not taken for phone numbers.
+func phoneDigits(s string) bool {
+	n := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n >= 9 && n <= 15
+}
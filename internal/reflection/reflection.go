@@ -14,6 +14,7 @@ import (
 	"tetora/internal/config"
 	"tetora/internal/db"
 	"tetora/internal/dispatch"
+	"tetora/internal/feedback"
 )
 
 // Query limit caps, shared by reflection/lesson-event helpers and the
@@ -452,8 +453,9 @@ func GetReflection(dbPath, taskID string) (*Result, error) {
 	}, nil
 }
 
-// BuildContext formats recent reflections as a text block suitable
-// for injection into agent prompts. Returns empty string if no reflections exist.
+// BuildContext formats recent reflections and the owner's commented feedback
+// as a text block suitable for injection into agent prompts. Returns empty
+// string if there is neither.
 func BuildContext(dbPath, role string, limit int) string {
 	if dbPath == "" || role == "" {
 		return ""
@@ -462,15 +464,37 @@ func BuildContext(dbPath, role string, limit int) string {
 		limit = 5
 	}
 
-	refs, err := Query(dbPath, role, limit)
-	if err != nil || len(refs) == 0 {
+	refs, _ := Query(dbPath, role, limit)
+	// The owner's commented verdicts on the agent's results; the table is
+	// absent when feedback was never initialized.
+	var comments []feedback.Entry
+	if entries, err := feedback.List(dbPath, feedback.Filter{Agent: role, Limit: 20}); err == nil {
+		for _, e := range entries {
+			if e.Comment != "" && len(comments) < limit {
+				comments = append(comments, e)
+			}
+		}
+	}
+	if len(refs) == 0 && len(comments) == 0 {
 		return ""
 	}
 
 	var b strings.Builder
-	b.WriteString(fmt.Sprintf("Recent self-assessments for agent %s:\n", role))
-	for _, ref := range refs {
-		b.WriteString(fmt.Sprintf("- Score: %d/5 - %s\n", ref.Score, ref.Improvement))
+	if len(refs) > 0 {
+		b.WriteString(fmt.Sprintf("Recent self-assessments for agent %s:\n", role))
+		for _, ref := range refs {
+			b.WriteString(fmt.Sprintf("- Score: %d/5 - %s\n", ref.Score, ref.Improvement))
+		}
+	}
+	if len(comments) > 0 {
+		b.WriteString(fmt.Sprintf("Recent owner feedback for agent %s:\n", role))
+		for _, e := range comments {
+			verdict := "good"
+			if e.Score < 0 {
+				verdict = "bad"
+			}
+			b.WriteString(fmt.Sprintf("- %s - %s\n", verdict, e.Comment))
+		}
 	}
 	return b.String()
 }
@@ -6,6 +6,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"tetora/internal/db"
+	"tetora/internal/feedback"
 )
 
 // --- ExtractAutoLesson tests ---
@@ -145,3 +148,28 @@ func TestExtractAutoLesson_EmptyImprovement(t *testing.T) {
 		t.Error("auto-lessons.md should not be created for empty improvement")
 	}
 }
+
+// --- BuildContext tests ---
+
+func TestBuildContext_OwnerFeedback(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	if err := feedback.InitDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if BuildContext(dbPath, "hisui", 5) != "" {
+		t.Error("context without reflections or feedback")
+	}
+
+	db.Exec(dbPath, `CREATE TABLE job_runs (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id TEXT, agent TEXT);
+		INSERT INTO job_runs (job_id, agent) VALUES ('t1', 'hisui'), ('t2', 'hisui');`)
+	feedback.Record(dbPath, feedback.Entry{TaskID: "t1", Score: -1, Comment: "cite the source"})
+	feedback.Record(dbPath, feedback.Entry{TaskID: "t2", Score: 1})
+
+	got := BuildContext(dbPath, "hisui", 5)
+	if !strings.Contains(got, "Recent owner feedback for agent hisui:\n- bad - cite the source\n") || strings.Contains(got, "good") {
+		t.Errorf("context = %q", got)
+	}
+}
@@ -0,0 +1,278 @@
+// Package replica keeps a read-only copy of a primary daemon's history DB.
+//
+// The primary serves consistent snapshots of its database (VACUUM INTO),
+// tagged with a version that changes whenever the database files do. A
+// replica polls for a new version and swaps the downloaded copy in
+// atomically, so its dashboard, history and stats follow the primary with at
+// most one poll interval of lag. When the primary cannot be reached, the last
+// copy keeps being served and the lag shows in Status.
+package replica
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// SnapshotAtHeader carries the time the primary took a snapshot.
+const SnapshotAtHeader = "X-Tetora-Snapshot-At"
+
+// Version identifies the current state of the database at dbPath from the
+// size and modification time of its files. It is empty if the database does
+// not exist.
+func Version(dbPath string) string {
+	h := sha256.New()
+	found := false
+	for _, p := range []string{dbPath, dbPath + "-wal"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		found = true
+		fmt.Fprintf(h, "%s:%d:%d;", filepath.Base(p), info.Size(), info.ModTime().UnixNano())
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Snapshot writes a consistent copy of the database at dbPath to dst, which
+// must not exist.
+func Snapshot(dbPath, dst string) error {
+	return db.Exec(dbPath, "VACUUM INTO '"+db.Escape(dst)+"'")
+}
+
+// ServeSnapshot answers a replica's snapshot request for the database at
+// dbPath: 304 when its If-None-Match matches the current version, otherwise
+// a fresh snapshot tagged with the version it was taken at.
+func ServeSnapshot(w http.ResponseWriter, r *http.Request, dbPath string) {
+	version := Version(dbPath)
+	if version == "" {
+		http.Error(w, "no history DB", http.StatusNotFound)
+		return
+	}
+	etag := `"` + version + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	dir, err := os.MkdirTemp("", "tetora-snapshot-")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	takenAt := time.Now().UTC().Format(time.RFC3339)
+	path := filepath.Join(dir, "history.db")
+	if err := Snapshot(dbPath, path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	w.Header().Set("ETag", etag)
+	w.Header().Set(SnapshotAtHeader, takenAt)
+	io.Copy(w, file)
+}
+
+// Status describes the replica's copy.
+type Status struct {
+	Primary     string `json:"primary"`
+	Version     string `json:"version,omitempty"`     // primary version of the current copy
+	SnapshotAt  string `json:"snapshotAt,omitempty"`  // when the primary took the current copy
+	SyncedAt    string `json:"syncedAt,omitempty"`    // when the current copy was installed
+	CheckedAt   string `json:"checkedAt,omitempty"`   // last successful check with the primary
+	LagSeconds  int    `json:"lagSeconds"`            // age of the primary state served, -1 before the first copy
+	Bytes       int64  `json:"bytes,omitempty"`       // size of the current copy
+	Error       string `json:"error,omitempty"`       // error of the last check
+	FailedSince string `json:"failedSince,omitempty"` // first failed check since the last success
+}
+
+// Follower polls the primary and installs new copies of its database.
+type Follower struct {
+	cfg    config.ReplicaConfig
+	dbPath string
+	client *http.Client
+
+	mu      sync.Mutex
+	status  Status
+	checked time.Time
+}
+
+// New creates a follower installing copies at dbPath.
+func New(cfg config.ReplicaConfig, dbPath string) *Follower {
+	return &Follower{
+		cfg:    cfg,
+		dbPath: dbPath,
+		client: &http.Client{Timeout: 10 * time.Minute},
+		status: Status{Primary: cfg.Primary},
+	}
+}
+
+// Start syncs now and then every poll interval until ctx is cancelled.
+func (f *Follower) Start(ctx context.Context) {
+	interval := f.cfg.PollIntervalOrDefault()
+	log.Info("replica: following primary", "primary", f.cfg.Primary, "poll", interval)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if changed, err := f.Sync(ctx); err != nil {
+			log.Warn("replica: sync failed", "primary", f.cfg.Primary, "error", err)
+		} else if changed {
+			st := f.Status()
+			log.Info("replica: installed new copy", "snapshotAt", st.SnapshotAt, "bytes", st.Bytes)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Status returns the state of the copy.
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := f.status
+	st.LagSeconds = -1
+	if at, err := time.Parse(time.RFC3339, st.SnapshotAt); err == nil {
+		// Unchanged versions mean the copy was current at the last check.
+		ref := at
+		if f.checked.After(ref) {
+			ref = f.checked
+		}
+		st.LagSeconds = int(time.Since(ref).Seconds())
+	}
+	return st
+}
+
+// Sync checks the primary for a new version and installs it. It reports
+// whether a new copy was installed.
+func (f *Follower) Sync(ctx context.Context) (bool, error) {
+	changed, err := f.sync(ctx)
+	now := time.Now().UTC()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.status.Error = err.Error()
+		if f.status.FailedSince == "" {
+			f.status.FailedSince = now.Format(time.RFC3339)
+		}
+		return false, err
+	}
+	f.status.Error, f.status.FailedSince = "", ""
+	f.status.CheckedAt = now.Format(time.RFC3339)
+	if !changed {
+		f.checked = now
+	}
+	return changed, nil
+}
+
+func (f *Follower) sync(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(f.cfg.Primary, "/")+"/api/replica/snapshot", nil)
+	if err != nil {
+		return false, err
+	}
+	if f.cfg.PrimaryToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.cfg.PrimaryToken)
+	}
+	f.mu.Lock()
+	version := f.status.Version
+	f.mu.Unlock()
+	if version != "" {
+		req.Header.Set("If-None-Match", `"`+version+`"`)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("primary: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	tmp := f.dbPath + ".replica"
+	os.Remove(tmp)
+	out, err := os.Create(tmp)
+	if err != nil {
+		return false, err
+	}
+	n, err := io.Copy(out, resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = fmt.Errorf("short snapshot: %d of %d bytes", n, resp.ContentLength)
+	}
+	if err == nil {
+		err = checkDB(tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	// A WAL left by local writes must not be replayed onto the new copy.
+	os.Remove(f.dbPath + "-wal")
+	os.Remove(f.dbPath + "-shm")
+	if err := os.Rename(tmp, f.dbPath); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	snapshotAt := resp.Header.Get(SnapshotAtHeader)
+	if snapshotAt == "" {
+		snapshotAt = now
+	}
+	f.mu.Lock()
+	f.status.Version = strings.Trim(resp.Header.Get("ETag"), `"`)
+	f.status.SnapshotAt = snapshotAt
+	f.status.SyncedAt = now
+	f.status.Bytes = n
+	f.checked = time.Time{}
+	f.mu.Unlock()
+	return true, nil
+}
+
+// checkDB verifies that path is an intact SQLite database.
+func checkDB(path string) error {
+	rows, err := db.Query(path, "PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("snapshot check: %w", err)
+	}
+	if len(rows) == 0 || db.Str(rows[0]["quick_check"]) != "ok" {
+		return errors.New("snapshot check: database is damaged")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package replica
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+)
+
+func TestFollower(t *testing.T) {
+	dir := t.TempDir()
+	primaryDB := filepath.Join(dir, "primary.db")
+	if err := db.Exec(primaryDB, `CREATE TABLE job_runs (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO job_runs (name) VALUES ('brief');`); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/replica/snapshot" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ServeSnapshot(w, r, primaryDB)
+	}))
+	defer srv.Close()
+
+	replicaDB := filepath.Join(dir, "replica.db")
+	f := New(config.ReplicaConfig{Primary: srv.URL, PrimaryToken: "tok"}, replicaDB)
+	if st := f.Status(); st.LagSeconds != -1 {
+		t.Errorf("lag before first copy = %d", st.LagSeconds)
+	}
+	ctx := context.Background()
+	count := func() int {
+		rows, _ := db.Query(replicaDB, `SELECT COUNT(*) AS n FROM job_runs`)
+		if len(rows) == 0 {
+			return -1
+		}
+		return db.Int(rows[0]["n"])
+	}
+
+	if changed, err := f.Sync(ctx); err != nil || !changed || count() != 1 {
+		t.Fatalf("first sync = %v, %v, rows %d", changed, err, count())
+	}
+	if changed, err := f.Sync(ctx); err != nil || changed {
+		t.Errorf("unchanged sync = %v, %v", changed, err)
+	}
+
+	time.Sleep(10 * time.Millisecond) // distinct modification time
+	db.Exec(primaryDB, `INSERT INTO job_runs (name) VALUES ('digest');`)
+	if changed, err := f.Sync(ctx); err != nil || !changed || count() != 2 {
+		t.Errorf("sync after write = %v, %v, rows %d", changed, err, count())
+	}
+	st := f.Status()
+	if st.Error != "" || st.SnapshotAt == "" || st.LagSeconds < 0 || st.Bytes == 0 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// An unreachable primary keeps the copy and reports the failure.
+	srv.Close()
+	if _, err := f.Sync(ctx); err == nil {
+		t.Error("sync with primary down succeeded")
+	}
+	if st := f.Status(); st.Error == "" || st.FailedSince == "" || count() != 2 {
+		t.Errorf("status after failure = %+v, rows %d", st, count())
+	}
+}
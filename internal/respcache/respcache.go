@@ -0,0 +1,281 @@
+// Package respcache caches successful task responses so that repeated runs of
+// the same prompt (typically cron jobs re-asking the same question) are
+// answered without calling a provider.
+//
+// Entries are keyed by the normalized prompt, the model, the agent and the
+// tools the task could use, kept in the response_cache table of the history
+// DB with a per-entry expiry, and bounded to a maximum count with the least
+// recently used entries evicted first.
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// KeyInput is everything a cached response depends on.
+type KeyInput struct {
+	Prompt         string
+	Model          string
+	Agent          string
+	Tools          []string // allowed tools; order does not matter
+	MCP            string
+	PermissionMode string
+}
+
+// Key returns the cache key of in. Prompts differing only in whitespace share
+// a key.
+func Key(in KeyInput) string {
+	tools := slices.Clone(in.Tools)
+	slices.Sort(tools)
+	h := sha256.New()
+	for _, part := range []string{
+		strings.Join(strings.Fields(in.Prompt), " "),
+		in.Model,
+		in.Agent,
+		strings.Join(tools, ","),
+		in.MCP,
+		in.PermissionMode,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Entry is a cached response.
+type Entry struct {
+	Key       string  `json:"key"`
+	Agent     string  `json:"agent,omitempty"`
+	Model     string  `json:"model,omitempty"`
+	Source    string  `json:"source,omitempty"` // source of the task that filled it
+	Prompt    string  `json:"prompt"`           // first 200 characters
+	Output    string  `json:"output,omitempty"`
+	CostUSD   float64 `json:"costUsd"` // cost of the original run, saved on every hit
+	TokensIn  int     `json:"tokensIn,omitempty"`
+	TokensOut int     `json:"tokensOut,omitempty"`
+	Hits      int     `json:"hits"`
+	CreatedAt string  `json:"createdAt"`
+	ExpiresAt string  `json:"expiresAt"`
+	LastHitAt string  `json:"lastHitAt,omitempty"`
+}
+
+// Stats summarizes the cache.
+type Stats struct {
+	Entries  int                    `json:"entries"`
+	Hits     int                    `json:"hits"`     // lookups served since start
+	Misses   int                    `json:"misses"`   // lookups not served since start
+	HitRate  float64                `json:"hitRate"`  // hits / lookups since start
+	SavedUSD float64                `json:"savedUsd"` // cost of all hits on current entries
+	ByAgent  map[string]*AgentStats `json:"byAgent,omitempty"`
+}
+
+// AgentStats counts one agent's lookups since start.
+type AgentStats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// Cache is the response cache over a history DB.
+type Cache struct {
+	dbPath     string
+	maxEntries int
+
+	mu      sync.Mutex
+	lookups map[string]*AgentStats
+}
+
+// New creates a cache keeping at most maxEntries responses.
+func New(dbPath string, maxEntries int) *Cache {
+	return &Cache{dbPath: dbPath, maxEntries: maxEntries, lookups: make(map[string]*AgentStats)}
+}
+
+// InitDB creates the response_cache table.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS response_cache (
+		key TEXT PRIMARY KEY,
+		agent TEXT DEFAULT '',
+		model TEXT DEFAULT '',
+		source TEXT DEFAULT '',
+		prompt TEXT DEFAULT '',
+		output TEXT NOT NULL,
+		cost_usd REAL DEFAULT 0,
+		tokens_in INTEGER DEFAULT 0,
+		tokens_out INTEGER DEFAULT 0,
+		hits INTEGER DEFAULT 0,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		last_used_at TEXT NOT NULL,
+		last_hit_at TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_response_cache_agent ON response_cache(agent);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+const entryColumns = `key, agent, model, source, prompt, output, cost_usd, tokens_in, tokens_out, hits, created_at, expires_at, last_hit_at`
+
+func entryFromRow(r map[string]any) Entry {
+	return Entry{
+		Key:       db.Str(r["key"]),
+		Agent:     db.Str(r["agent"]),
+		Model:     db.Str(r["model"]),
+		Source:    db.Str(r["source"]),
+		Prompt:    db.Str(r["prompt"]),
+		Output:    db.Str(r["output"]),
+		CostUSD:   db.Float(r["cost_usd"]),
+		TokensIn:  db.Int(r["tokens_in"]),
+		TokensOut: db.Int(r["tokens_out"]),
+		Hits:      db.Int(r["hits"]),
+		CreatedAt: db.Str(r["created_at"]),
+		ExpiresAt: db.Str(r["expires_at"]),
+		LastHitAt: db.Str(r["last_hit_at"]),
+	}
+}
+
+// Get returns the live entry for key and counts the lookup for agent. An
+// expired entry is a miss.
+func (c *Cache) Get(key, agent string, now time.Time) (*Entry, bool) {
+	ts := now.UTC().Format(time.RFC3339)
+	rows, err := db.QueryArgs(c.dbPath, `SELECT `+entryColumns+` FROM response_cache WHERE key = ? AND expires_at > ?`, key, ts)
+	if err != nil || len(rows) == 0 {
+		c.count(agent, false)
+		return nil, false
+	}
+	e := entryFromRow(rows[0])
+	e.Hits++
+	e.LastHitAt = ts
+	db.ExecArgs(c.dbPath, `UPDATE response_cache SET hits = hits + 1, last_hit_at = ?, last_used_at = ? WHERE key = ?`, ts, ts, key)
+	c.count(agent, true)
+	return &e, true
+}
+
+func (c *Cache) count(agent string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.lookups[agent]
+	if s == nil {
+		s = &AgentStats{}
+		c.lookups[agent] = s
+	}
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+}
+
+// Put stores e for ttl, then drops expired entries and evicts the least
+// recently used ones beyond the maximum.
+func (c *Cache) Put(e Entry, ttl time.Duration, now time.Time) error {
+	if runes := []rune(e.Prompt); len(runes) > 200 {
+		e.Prompt = string(runes[:200])
+	}
+	ts := now.UTC().Format(time.RFC3339)
+	err := db.ExecArgs(c.dbPath, `INSERT OR REPLACE INTO response_cache
+		(key, agent, model, source, prompt, output, cost_usd, tokens_in, tokens_out, hits, created_at, expires_at, last_used_at, last_hit_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, '')`,
+		e.Key, e.Agent, e.Model, e.Source, e.Prompt, e.Output, e.CostUSD, e.TokensIn, e.TokensOut,
+		ts, now.Add(ttl).UTC().Format(time.RFC3339), ts)
+	if err != nil {
+		return err
+	}
+	return db.ExecArgs(c.dbPath, fmt.Sprintf(`DELETE FROM response_cache WHERE expires_at <= ?
+		OR key NOT IN (SELECT key FROM response_cache ORDER BY last_used_at DESC, rowid DESC LIMIT %d)`, max(c.maxEntries, 1)), ts)
+}
+
+// Filter selects entries to list or invalidate. The zero Filter selects
+// everything.
+type Filter struct {
+	Key    string
+	Agent  string
+	Prompt string // entries whose stored prompt starts with this
+}
+
+func (f Filter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if f.Key != "" {
+		conds, args = append(conds, "key = ?"), append(args, f.Key)
+	}
+	if f.Agent != "" {
+		conds, args = append(conds, "agent = ?"), append(args, f.Agent)
+	}
+	if f.Prompt != "" {
+		conds, args = append(conds, "instr(prompt, ?) = 1"), append(args, f.Prompt)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// Entries lists the entries matching f, most recently used first, without
+// their output.
+func (c *Cache) Entries(f Filter, limit int) ([]Entry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	where, args := f.where()
+	rows, err := db.QueryArgs(c.dbPath, `SELECT `+strings.Replace(entryColumns, "output, ", "", 1)+
+		` FROM response_cache`+where+fmt.Sprintf(` ORDER BY last_used_at DESC, rowid DESC LIMIT %d`, limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, entryFromRow(r))
+	}
+	return out, nil
+}
+
+// Invalidate deletes the entries matching f and returns how many there were.
+func (c *Cache) Invalidate(f Filter) (int, error) {
+	where, args := f.where()
+	rows, err := db.QueryArgs(c.dbPath, `SELECT COUNT(*) AS n FROM response_cache`+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	if len(rows) > 0 {
+		n = db.Int(rows[0]["n"])
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, db.ExecArgs(c.dbPath, `DELETE FROM response_cache`+where, args...)
+}
+
+// Stats returns entry counts, savings and the lookups since start.
+func (c *Cache) Stats() (Stats, error) {
+	rows, err := db.Query(c.dbPath, `SELECT COUNT(*) AS n, COALESCE(SUM(cost_usd * hits), 0) AS saved FROM response_cache`)
+	if err != nil {
+		return Stats{}, err
+	}
+	var st Stats
+	if len(rows) > 0 {
+		st.Entries, st.SavedUSD = db.Int(rows[0]["n"]), db.Float(rows[0]["saved"])
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.lookups) > 0 {
+		st.ByAgent = make(map[string]*AgentStats, len(c.lookups))
+	}
+	for agent, s := range c.lookups {
+		cp := *s
+		st.ByAgent[agent] = &cp
+		st.Hits += s.Hits
+		st.Misses += s.Misses
+	}
+	if total := st.Hits + st.Misses; total > 0 {
+		st.HitRate = float64(st.Hits) / float64(total)
+	}
+	return st, nil
+}
@@ -0,0 +1,76 @@
+package respcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	base := KeyInput{Prompt: "Summarize  the news\n", Model: "sonnet", Agent: "ruri", Tools: []string{"web_search", "memory_get"}}
+	same := KeyInput{Prompt: " Summarize the\tnews", Model: "sonnet", Agent: "ruri", Tools: []string{"memory_get", "web_search"}}
+	if Key(base) != Key(same) {
+		t.Error("whitespace or tool order changed the key")
+	}
+	for name, in := range map[string]KeyInput{
+		"model":  {Prompt: base.Prompt, Model: "haiku", Agent: "ruri", Tools: base.Tools},
+		"tools":  {Prompt: base.Prompt, Model: "sonnet", Agent: "ruri", Tools: []string{"web_search"}},
+		"agent":  {Prompt: base.Prompt, Model: "sonnet", Agent: "kokuyou", Tools: base.Tools},
+		"prompt": {Prompt: "Summarize the weather", Model: "sonnet", Agent: "ruri", Tools: base.Tools},
+	} {
+		if Key(in) == Key(base) {
+			t.Errorf("%s did not change the key", name)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	c := New(dbPath, 2)
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	if _, ok := c.Get("a", "ruri", now); ok {
+		t.Fatal("hit on empty cache")
+	}
+	if err := c.Put(Entry{Key: "a", Agent: "ruri", Prompt: "daily brief", Output: "it's 'sunny'?", CostUSD: 0.25}, time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := c.Get("a", "ruri", now.Add(time.Minute))
+	if !ok || e.Output != "it's 'sunny'?" || e.Hits != 1 {
+		t.Fatalf("get = %+v, %v", e, ok)
+	}
+	if _, ok := c.Get("a", "ruri", now.Add(2*time.Hour)); ok {
+		t.Error("expired entry served")
+	}
+
+	// b and c push the cache past two entries; a was used most recently
+	// before c arrived, so b is evicted.
+	c.Put(Entry{Key: "b", Agent: "kokuyou", Prompt: "weekly report"}, time.Hour, now.Add(2*time.Minute))
+	c.Get("a", "ruri", now.Add(3*time.Minute))
+	c.Put(Entry{Key: "c", Agent: "kokuyou", Prompt: "weekly digest"}, time.Hour, now.Add(4*time.Minute))
+	entries, err := c.Entries(Filter{}, 0)
+	if err != nil || len(entries) != 2 || entries[0].Key != "c" || entries[1].Key != "a" {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	if entries[1].Output != "" {
+		t.Error("listing included output")
+	}
+
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Entries != 2 || st.Hits != 2 || st.Misses != 2 || st.SavedUSD != 0.5 || st.ByAgent["ruri"].Hits != 2 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	if n, err := c.Invalidate(Filter{Agent: "kokuyou", Prompt: "weekly"}); err != nil || n != 1 {
+		t.Errorf("invalidate = %d, %v", n, err)
+	}
+	if n, _ := c.Invalidate(Filter{}); n != 1 {
+		t.Errorf("invalidate all = %d", n)
+	}
+}
@@ -0,0 +1,109 @@
+package retention
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/history"
+	"tetora/internal/log"
+)
+
+// jobPlan is what the cron jobs' own retention settings decide for their
+// runs, worked out from job_runs before any of it is cleaned up.
+type jobPlan struct {
+	keepFiles   map[string]bool // output files kept regardless of retention.outputs
+	removeFiles []string        // output files past their job's shorter retention
+	pinnedRuns  []int           // job_runs rows kept past retention.history
+}
+
+// planJobs applies each job's retention to its runs. Runs are pinned when
+// they are the job's last success or a failure, started within the job's
+// pinMonths. Pinned runs keep their row and output file; the output files of
+// other runs are kept for the job's outputs days.
+func planJobs(dbPath string, jobs map[string]config.JobRetention, outputDays int, now time.Time) jobPlan {
+	plan := jobPlan{keepFiles: map[string]bool{}}
+	if dbPath == "" {
+		return plan
+	}
+	for jobID, jr := range jobs {
+		if jr.Outputs <= 0 && jr.PinMonths <= 0 {
+			continue
+		}
+		rows, err := db.Query(dbPath, fmt.Sprintf(
+			`SELECT id, status, started_at, COALESCE(output_file,'') as output_file
+			 FROM job_runs WHERE job_id = '%s' ORDER BY id DESC`, db.Escape(jobID)))
+		if err != nil {
+			log.Warn("retention: query job runs failed", "job", jobID, "error", err)
+			continue
+		}
+		outputCutoff := now.AddDate(0, 0, -Days(jr.Outputs, outputDays))
+		pinCutoff := now.AddDate(0, -jr.PinMonths, 0)
+		lastSuccess := false
+		for _, row := range rows {
+			status := jsonStr(row["status"])
+			started, _ := time.Parse(time.RFC3339, jsonStr(row["started_at"]))
+			pinned := false
+			switch status {
+			case "success":
+				pinned = !lastSuccess
+				lastSuccess = true
+			case history.StatusSkippedConcurrentLimit:
+			default:
+				pinned = true
+			}
+			pinned = pinned && jr.PinMonths > 0 && started.After(pinCutoff)
+			if pinned {
+				plan.pinnedRuns = append(plan.pinnedRuns, jsonInt(row["id"]))
+			}
+
+			file := jsonStr(row["output_file"])
+			if file == "" {
+				continue
+			}
+			if pinned || started.After(outputCutoff) {
+				plan.keepFiles[file] = true
+			} else {
+				plan.removeFiles = append(plan.removeFiles, file)
+			}
+		}
+	}
+	return plan
+}
+
+// jobRetention returns the retention of the cron jobs that set one.
+func jobRetention(h Hooks) map[string]config.JobRetention {
+	if h.JobRetention == nil {
+		return nil
+	}
+	return h.JobRetention()
+}
+
+// CleanupHistory removes job runs past retention.history, except the runs
+// pinned by their cron job.
+func CleanupHistory(cfg *config.Config, h Hooks) error {
+	plan := planJobs(cfg.HistoryDB, jobRetention(h), Days(cfg.Retention.Outputs, 30), time.Now())
+	return history.Cleanup(cfg.HistoryDB, Days(cfg.Retention.History, 90), plan.pinnedRuns...)
+}
+
+// CleanupOutputs removes output files past retention.outputs, except those
+// their cron job keeps longer, and job output files past their job's own
+// retention. It returns how many files were removed early for their job.
+func CleanupOutputs(cfg *config.Config, h Hooks) int {
+	plan := planJobs(cfg.HistoryDB, jobRetention(h), Days(cfg.Retention.Outputs, 30), time.Now())
+	return cleanupOutputs(cfg, h, plan)
+}
+
+func cleanupOutputs(cfg *config.Config, h Hooks, plan jobPlan) int {
+	h.CleanupOutputs(cfg.BaseDir, Days(cfg.Retention.Outputs, 30), plan.keepFiles)
+	removed := 0
+	for _, name := range plan.removeFiles {
+		if os.Remove(filepath.Join(cfg.BaseDir, "outputs", filepath.Base(name))) == nil {
+			removed++
+		}
+	}
+	return removed
+}
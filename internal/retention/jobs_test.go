@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/history"
+)
+
+func TestJobRetention(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not found")
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "history.db")
+	if err := history.InitDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "outputs"), 0o755)
+
+	now := time.Now()
+	run := func(job, status, file string, daysAgo int) {
+		t.Helper()
+		at := now.AddDate(0, 0, -daysAgo)
+		ts := at.Format(time.RFC3339)
+		if err := history.InsertRun(dbPath, history.JobRun{JobID: job, Name: job, Source: "cron",
+			StartedAt: ts, FinishedAt: ts, Status: status, OutputFile: file}); err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(dir, "outputs", file)
+		os.WriteFile(p, []byte("{}"), 0o644)
+		os.Chtimes(p, at, at)
+	}
+	run("report", "success", "report-old-ok.json", 200)
+	run("report", "error", "report-fail.json", 120)
+	run("report", "success", "report-last-ok.json", 100)
+	run("report", "error", "report-ancient-fail.json", 400)
+	run("scratch", "success", "scratch-week.json", 8)
+	run("scratch", "success", "scratch-new.json", 1)
+	run("other", "success", "other.json", 60)
+
+	cfg := &config.Config{HistoryDB: dbPath, BaseDir: dir,
+		Retention: config.RetentionConfig{History: 90, Outputs: 30}}
+	h := Hooks{
+		CleanupOutputs: func(baseDir string, days int, keep map[string]bool) {
+			cutoff := now.AddDate(0, 0, -days)
+			entries, _ := os.ReadDir(filepath.Join(baseDir, "outputs"))
+			for _, e := range entries {
+				info, _ := e.Info()
+				if !keep[e.Name()] && info.ModTime().Before(cutoff) {
+					os.Remove(filepath.Join(baseDir, "outputs", e.Name()))
+				}
+			}
+		},
+		JobRetention: func() map[string]config.JobRetention {
+			return map[string]config.JobRetention{
+				"report":  {PinMonths: 6},
+				"scratch": {Outputs: 3},
+			}
+		},
+	}
+
+	if err := CleanupHistory(cfg, h); err != nil {
+		t.Fatal(err)
+	}
+	if n := CleanupOutputs(cfg, h); n != 1 {
+		t.Errorf("removed early = %d, want 1", n)
+	}
+
+	for file, want := range map[string]bool{
+		"report-last-ok.json":      true,  // last success, pinned
+		"report-fail.json":         true,  // failure, pinned
+		"report-old-ok.json":       false, // superseded success
+		"report-ancient-fail.json": false, // failure older than pinMonths
+		"scratch-new.json":         true,
+		"scratch-week.json":        false, // past the job's 3 days
+		"other.json":               false, // past retention.outputs
+	} {
+		_, err := os.Stat(filepath.Join(dir, "outputs", file))
+		if got := err == nil; got != want {
+			t.Errorf("%s kept = %v, want %v", file, got, want)
+		}
+	}
+
+	runs, err := history.Query(dbPath, "report", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].OutputFile != "report-last-ok.json" || runs[1].OutputFile != "report-fail.json" {
+		t.Errorf("report runs left = %+v", runs)
+	}
+}
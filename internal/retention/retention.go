@@ -16,6 +16,7 @@ import (
 	"tetora/internal/history"
 	"tetora/internal/log"
 	"tetora/internal/session"
+	"tetora/internal/trace"
 	
 	"tetora/internal/version"
 
@@ -64,8 +65,12 @@ type Hooks struct {
 	CleanupSessions func(dbPath string, days int) error
 	// CleanupOldQueueItems removes completed/expired queue items older than N days.
 	CleanupOldQueueItems func(dbPath string, days int)
-	// CleanupOutputs removes output files older than N days.
-	CleanupOutputs func(baseDir string, days int)
+	// CleanupOutputs removes output files older than N days, except the
+	// file names in keep.
+	CleanupOutputs func(baseDir string, days int, keep map[string]bool)
+	// JobRetention returns the retention of cron jobs that set one, by job ID.
+	// Optional.
+	JobRetention func() map[string]config.JobRetention
 	// ListMemory returns all memory entries for the workspace.
 	ListMemory func(workspaceDir string) ([]MemoryEntry, error)
 	// QuerySessions returns sessions matching the given limit.
@@ -444,10 +449,13 @@ func Run(cfg *config.Config, h Hooks) []Result {
 	var results []Result
 	dbPath := cfg.HistoryDB
 
+	// Cron jobs' own retention, decided before their runs are cleaned up.
+	plan := planJobs(dbPath, jobRetention(h), Days(cfg.Retention.Outputs, 30), time.Now())
+
 	if dbPath != "" {
 		// job_runs
 		days := Days(cfg.Retention.History, 90)
-		if err := history.Cleanup(dbPath, days); err != nil {
+		if err := history.Cleanup(dbPath, days, plan.pinnedRuns...); err != nil {
 			results = append(results, Result{Table: "job_runs", Error: err.Error()})
 		} else {
 			results = append(results, Result{Table: "job_runs", Deleted: -1})
@@ -514,6 +522,14 @@ func Run(cfg *config.Config, h Hooks) []Result {
 			results = append(results, Result{Table: "trust_events", Deleted: n})
 		}
 
+		// trace_events
+		days = Days(cfg.Retention.Traces, 14)
+		if err := trace.Cleanup(dbPath, days); err != nil {
+			results = append(results, Result{Table: "trace_events", Error: err.Error()})
+		} else {
+			results = append(results, Result{Table: "trace_events", Deleted: -1})
+		}
+
 		// config_versions
 		days = Days(cfg.Retention.Versions, 180)
 		version.Cleanup(dbPath, days)
@@ -521,12 +537,11 @@ func Run(cfg *config.Config, h Hooks) []Result {
 	}
 
 	// Output files
-	days := Days(cfg.Retention.Outputs, 30)
-	h.CleanupOutputs(cfg.BaseDir, days)
+	cleanupOutputs(cfg, h, plan)
 	results = append(results, Result{Table: "outputs", Deleted: -1})
 
 	// Upload files
-	days = Days(cfg.Retention.Uploads, 7)
+	days := Days(cfg.Retention.Uploads, 7)
 	results = append(results, Result{Table: "uploads", Deleted: -1})
 
 	// Log files
@@ -600,7 +615,7 @@ func QueryStats(dbPath string) map[string]int {
 	tables := []string{
 		"job_runs", "audit_log", "sessions", "session_messages",
 		"workflow_runs", "handoffs", "agent_messages",
-		"reflections", "sla_checks", "trust_events",
+		"reflections", "sla_checks", "trust_events", "trace_events",
 		"config_versions", "agent_memory", "offline_queue",
 	}
 	for _, t := range tables {
@@ -697,6 +712,7 @@ func PurgeBefore(dbPath, before string) ([]Result, error) {
 		{"reflections", "created_at"},
 		{"sla_checks", "checked_at"},
 		{"trust_events", "created_at"},
+		{"trace_events", "started_at"},
 		{"config_versions", "created_at"},
 		{"offline_queue", "created_at"},
 	}
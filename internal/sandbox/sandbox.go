@@ -213,6 +213,46 @@ func (sm *SandboxManager) PluginName() string {
 	return sm.plugin
 }
 
+// RuntimeInfo describes the container runtime the sandbox plugin drives, as
+// reported by its sandbox/health method.
+type RuntimeInfo struct {
+	Available     bool   `json:"available"`
+	Runtime       string `json:"runtime,omitempty"` // "docker" or "podman"
+	Version       string `json:"version,omitempty"`
+	Rootless      bool   `json:"rootless"`
+	CgroupVersion int    `json:"cgroupVersion,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Runtime asks the plugin which container runtime it is using. Plugins that
+// predate runtime reporting only return dockerVersion; that is mapped to docker.
+func (sm *SandboxManager) Runtime() (RuntimeInfo, error) {
+	if sm == nil || sm.host == nil {
+		return RuntimeInfo{}, fmt.Errorf("sandbox manager not initialized")
+	}
+	if sm.plugin == "" {
+		return RuntimeInfo{}, fmt.Errorf("no sandbox plugin configured")
+	}
+
+	result, err := sm.host.Call(sm.plugin, "sandbox/health", nil)
+	if err != nil {
+		return RuntimeInfo{}, fmt.Errorf("sandbox/health failed: %w", err)
+	}
+	var resp struct {
+		RuntimeInfo
+		DockerVersion string `json:"dockerVersion"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return RuntimeInfo{}, fmt.Errorf("parse sandbox/health response: %w", err)
+	}
+	info := resp.RuntimeInfo
+	if info.Runtime == "" && resp.DockerVersion != "" {
+		info.Runtime = "docker"
+		info.Version = resp.DockerVersion
+	}
+	return info, nil
+}
+
 // EnsureSandbox creates or returns an existing sandbox for the given session.
 // workspace is the host directory to mount inside the sandbox.
 func (sm *SandboxManager) EnsureSandbox(sessionID, workspace string) (string, error) {
@@ -345,21 +385,28 @@ func (sm *SandboxManager) ExecInSandbox(sandboxID, command string) (string, erro
 		return "", fmt.Errorf("no sandbox plugin configured")
 	}
 
-	result, err := sm.host.Call(sm.plugin, "sandbox/exec", map[string]any{
+	params := map[string]any{
 		"sandboxId": sandboxID,
 		"command":   command,
 		"timeout":   120, // default 2 minute timeout
-	})
+	}
+	if sm.cfg.Sandbox.MaxOutput > 0 {
+		params["maxOutputBytes"] = sm.cfg.Sandbox.MaxOutput
+	}
+	result, err := sm.host.Call(sm.plugin, "sandbox/exec", params)
 	if err != nil {
 		return "", fmt.Errorf("sandbox/exec failed: %w", err)
 	}
 
 	var resp struct {
-		Stdout   string `json:"stdout"`
-		Stderr   string `json:"stderr"`
-		ExitCode int    `json:"exitCode"`
-		IsError  bool   `json:"isError"`
-		Error    string `json:"error"`
+		Stdout          string `json:"stdout"`
+		Stderr          string `json:"stderr"`
+		ExitCode        int    `json:"exitCode"`
+		Killed          string `json:"killed"` // "oom" or "timeout"
+		StdoutTruncated int64  `json:"stdoutTruncated"`
+		StderrTruncated int64  `json:"stderrTruncated"`
+		IsError         bool   `json:"isError"`
+		Error           string `json:"error"`
 	}
 	if err := json.Unmarshal(result, &resp); err != nil {
 		return "", fmt.Errorf("parse sandbox/exec response: %w", err)
@@ -369,9 +416,18 @@ func (sm *SandboxManager) ExecInSandbox(sandboxID, command string) (string, erro
 	}
 
 	output := resp.Stdout
+	if resp.StdoutTruncated > 0 {
+		output += fmt.Sprintf("\n[stdout truncated: %d bytes dropped]", resp.StdoutTruncated)
+	}
 	if resp.Stderr != "" {
 		output += "\n[stderr]\n" + resp.Stderr
 	}
+	if resp.StderrTruncated > 0 {
+		output += fmt.Sprintf("\n[stderr truncated: %d bytes dropped]", resp.StderrTruncated)
+	}
+	if resp.Killed != "" {
+		return output, fmt.Errorf("killed: %s", resp.Killed)
+	}
 	if resp.ExitCode != 0 {
 		return output, fmt.Errorf("exit code %d", resp.ExitCode)
 	}
@@ -379,6 +435,47 @@ func (sm *SandboxManager) ExecInSandbox(sandboxID, command string) (string, erro
 	return output, nil
 }
 
+// ContainerStats is a sandbox's live resource usage, as reported by the
+// plugin's sandbox/stats method.
+type ContainerStats struct {
+	SandboxID        string  `json:"sandboxId"`
+	SessionID        string  `json:"sessionId,omitempty"`
+	CPUPercent       float64 `json:"cpuPercent"`
+	MemoryBytes      int64   `json:"memoryBytes"`
+	MemoryLimitBytes int64   `json:"memoryLimitBytes,omitempty"`
+	MemoryPercent    float64 `json:"memoryPercent"`
+	PIDs             int     `json:"pids"`
+	DiskBytes        int64   `json:"diskBytes"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// Stats returns live resource usage for one sandbox, or for every sandbox the
+// plugin manages when sandboxID is empty.
+func (sm *SandboxManager) Stats(sandboxID string) ([]ContainerStats, error) {
+	if sm == nil || sm.host == nil {
+		return nil, fmt.Errorf("sandbox manager not initialized")
+	}
+	if sm.plugin == "" {
+		return nil, fmt.Errorf("no sandbox plugin configured")
+	}
+
+	params := map[string]any{}
+	if sandboxID != "" {
+		params["sandboxId"] = sandboxID
+	}
+	result, err := sm.host.Call(sm.plugin, "sandbox/stats", params)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox/stats failed: %w", err)
+	}
+	var resp struct {
+		Containers []ContainerStats `json:"containers"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parse sandbox/stats response: %w", err)
+	}
+	return resp.Containers, nil
+}
+
 // DestroySandbox removes a sandbox container and cleans up the session mapping.
 func (sm *SandboxManager) DestroySandbox(sandboxID string) error {
 	if sm == nil || sm.host == nil {
@@ -21,6 +21,7 @@ type BackupConfig struct {
 	EscapeSQL  func(string) string
 	LogInfo    LogFunc
 	LogWarn    LogFunc
+	Remotes    []Remote
 }
 
 // BackupScheduler manages periodic database backups.
@@ -30,10 +31,11 @@ type BackupScheduler struct {
 
 // BackupResult describes the result of a backup operation.
 type BackupResult struct {
-	Filename   string `json:"filename"`
-	SizeBytes  int64  `json:"sizeBytes"`
-	DurationMs int64  `json:"durationMs"`
-	CreatedAt  string `json:"createdAt"`
+	Filename   string         `json:"filename"`
+	SizeBytes  int64          `json:"sizeBytes"`
+	DurationMs int64          `json:"durationMs"`
+	CreatedAt  string         `json:"createdAt"`
+	Remotes    []RemoteResult `json:"remotes,omitempty"`
 }
 
 // BackupInfo describes a stored backup file.
@@ -211,8 +213,10 @@ func (bs *BackupScheduler) Start(ctx context.Context) {
 		case <-time.After(30 * time.Second):
 		}
 
-		if _, err := bs.RunBackup(); err != nil {
+		if res, err := bs.RunBackup(); err != nil {
 			bs.cfg.LogWarn("initial backup failed", "error", err)
+		} else {
+			bs.Upload(ctx, res.Filename)
 		}
 		bs.CleanOldBackups()
 
@@ -223,8 +227,10 @@ func (bs *BackupScheduler) Start(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				if _, err := bs.RunBackup(); err != nil {
+				if res, err := bs.RunBackup(); err != nil {
 					bs.cfg.LogWarn("periodic backup failed", "error", err)
+				} else {
+					bs.Upload(ctx, res.Filename)
 				}
 				bs.CleanOldBackups()
 			}
@@ -0,0 +1,214 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/ics"
+)
+
+// --- Business Days ---
+
+// googleHolidayRegions maps ISO country codes to the region names of
+// Google's public holiday calendars.
+var googleHolidayRegions = map[string]string{
+	"AU": "australian", "BR": "brazilian", "CA": "canadian", "CN": "china",
+	"DE": "german", "ES": "spain", "FR": "french", "GB": "uk", "UK": "uk",
+	"HK": "hong_kong", "ID": "indonesian", "IE": "irish", "IN": "indian",
+	"IT": "italian", "JP": "japanese", "KR": "south_korea", "MX": "mexican",
+	"MY": "malaysia", "NL": "dutch", "NZ": "new_zealand", "PH": "philippines",
+	"PL": "polish", "SE": "swedish", "SG": "singapore", "TH": "thai",
+	"TW": "taiwan", "US": "usa", "VN": "vietnamese",
+}
+
+// HolidaySource resolves a holidays setting — a country code ("JP"), an ICS
+// URL or the path of an .ics file — to the URL or path to read.
+func HolidaySource(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return "", fmt.Errorf("empty holiday calendar")
+	case strings.HasPrefix(s, "https://"), strings.HasPrefix(s, "http://"):
+		return s, nil
+	case strings.HasPrefix(s, "webcal://"):
+		return "https://" + strings.TrimPrefix(s, "webcal://"), nil
+	case strings.HasSuffix(strings.ToLower(s), ".ics"):
+		return s, nil
+	}
+	region, ok := googleHolidayRegions[strings.ToUpper(s)]
+	if !ok {
+		return "", fmt.Errorf("unknown holiday calendar %q (want a country code such as JP or US, an ICS URL or an .ics file)", s)
+	}
+	return "https://calendar.google.com/calendar/ical/en." + region + "%23holiday%40group.v.calendar.google.com/public/basic.ics", nil
+}
+
+// IsWeekend reports whether wd is one of the weekend days ("mon".."sun",
+// "weekdays", "weekends"). An empty list means Saturday and Sunday.
+func IsWeekend(weekend []string, wd time.Weekday) bool {
+	if len(weekend) == 0 {
+		weekend = []string{"weekends"}
+	}
+	return dayMatches(weekend, wd)
+}
+
+// ValidateDays reports whether every entry names a day or a day group.
+func ValidateDays(days []string) error {
+	for _, d := range days {
+		if _, err := expandDay(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QuietUntil reports whether t falls inside the daily start-end range and,
+// if so, when that range ends. End before start wraps past midnight.
+func QuietUntil(start, end string, t time.Time) (time.Time, bool) {
+	w := Window{Start: start, End: end}
+	_, endMin, ok := windowBounds(w)
+	if !ok || !InWindows([]Window{w}, t) {
+		return time.Time{}, false
+	}
+	until := time.Date(t.Year(), t.Month(), t.Day(), endMin/60, endMin%60, 0, 0, t.Location())
+	if !until.After(t) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// HolidayCalendar caches the holidays of each source it is asked about.
+// Sources are refreshed after Refresh; a failed refresh keeps the previous
+// days and is retried after an hour.
+type HolidayCalendar struct {
+	Refresh time.Duration
+	// Fetch reads a resolved source; nil reads URLs over HTTP and
+	// anything else from disk.
+	Fetch func(ctx context.Context, src string) ([]byte, error)
+
+	mu   sync.Mutex
+	sets map[string]*holidaySet
+}
+
+type holidaySet struct {
+	days    map[string]string // "2006-01-02" → holiday name
+	fetched time.Time
+	retry   time.Time
+}
+
+// NewHolidayCalendar returns an empty cache. A zero refresh means daily.
+func NewHolidayCalendar(refresh time.Duration) *HolidayCalendar {
+	if refresh <= 0 {
+		refresh = 24 * time.Hour
+	}
+	return &HolidayCalendar{Refresh: refresh, sets: map[string]*holidaySet{}}
+}
+
+// Ensure loads source, or reloads it when it is stale. Callers that must not
+// block on the network should call Ensure before Holiday, outside any lock.
+func (h *HolidayCalendar) Ensure(ctx context.Context, source string) error {
+	src, err := HolidaySource(source)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	h.mu.Lock()
+	set := h.sets[src]
+	if set != nil && (now.Sub(set.fetched) < h.Refresh || now.Before(set.retry)) {
+		h.mu.Unlock()
+		return nil
+	}
+	h.mu.Unlock()
+
+	days, err := h.load(ctx, src)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sets == nil {
+		h.sets = map[string]*holidaySet{}
+	}
+	if set = h.sets[src]; set == nil {
+		set = &holidaySet{}
+		h.sets[src] = set
+	}
+	if err != nil {
+		set.retry = now.Add(time.Hour)
+		return fmt.Errorf("holiday calendar %s: %w", source, err)
+	}
+	set.days, set.fetched, set.retry = days, now, time.Time{}
+	return nil
+}
+
+// Holiday returns the name of the holiday on day's date, as seen in day's
+// location, from what Ensure last loaded for source.
+func (h *HolidayCalendar) Holiday(source string, day time.Time) (string, bool) {
+	src, err := HolidaySource(source)
+	if err != nil {
+		return "", false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	set := h.sets[src]
+	if set == nil {
+		return "", false
+	}
+	name, ok := set.days[day.Format("2006-01-02")]
+	return name, ok
+}
+
+func (h *HolidayCalendar) load(ctx context.Context, src string) (map[string]string, error) {
+	fetch := h.Fetch
+	if fetch == nil {
+		fetch = fetchHolidays
+	}
+	data, err := fetch(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	events, err := ics.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	days := map[string]string{}
+	for _, e := range events {
+		// Google's feeds also list observances such as Valentine's Day.
+		if strings.HasPrefix(e.Description, "Observance") {
+			continue
+		}
+		start, end := e.Start, e.End
+		if !e.AllDay {
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			end = start.AddDate(0, 0, 1)
+		}
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			days[d.Format("2006-01-02")] = e.Title
+		}
+	}
+	return days, nil
+}
+
+func fetchHolidays(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		return os.ReadFile(src)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+}
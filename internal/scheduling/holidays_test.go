@@ -0,0 +1,99 @@
+package scheduling
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const holidayFeed = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261103\r\nDTEND;VALUE=DATE:20261104\r\nSUMMARY:Culture Day\r\nDESCRIPTION:Public holiday\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261230\r\nDTEND;VALUE=DATE:20270102\r\nSUMMARY:Year-end break\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261225\r\nDTEND;VALUE=DATE:20261226\r\nSUMMARY:Christmas\r\nDESCRIPTION:Observance\\nTo hide observances...\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestHolidaySource(t *testing.T) {
+	src, err := HolidaySource("jp")
+	if err != nil || !strings.Contains(src, "en.japanese%23holiday") {
+		t.Errorf("HolidaySource(jp) = %q, %v", src, err)
+	}
+	if src, _ := HolidaySource("webcal://example.com/h.ics"); src != "https://example.com/h.ics" {
+		t.Errorf("webcal = %q", src)
+	}
+	if _, err := HolidaySource("XX"); err == nil {
+		t.Error("unknown country accepted")
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	calls := 0
+	fail := false
+	h := NewHolidayCalendar(time.Hour)
+	h.Fetch = func(ctx context.Context, src string) ([]byte, error) {
+		calls++
+		if fail {
+			return nil, errors.New("offline")
+		}
+		return []byte(holidayFeed), nil
+	}
+	ctx := context.Background()
+	if err := h.Ensure(ctx, "JP"); err != nil {
+		t.Fatal(err)
+	}
+	h.Ensure(ctx, "JP")
+	if calls != 1 {
+		t.Errorf("fetched %d times, want cached", calls)
+	}
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	day := func(mo time.Month, d int) time.Time { return time.Date(2026, mo, d, 9, 0, 0, 0, tokyo) }
+	if name, ok := h.Holiday("JP", day(11, 3)); !ok || name != "Culture Day" {
+		t.Errorf("Nov 3 = %q, %v", name, ok)
+	}
+	if _, ok := h.Holiday("JP", day(12, 31)); !ok {
+		t.Error("multi-day holiday not covered")
+	}
+	if _, ok := h.Holiday("JP", day(12, 25)); ok {
+		t.Error("observance treated as holiday")
+	}
+	if _, ok := h.Holiday("US", day(11, 3)); ok {
+		t.Error("unloaded source reported a holiday")
+	}
+
+	// A failed refresh keeps the old days.
+	h.sets[mustSource(t, "JP")].fetched = time.Now().Add(-2 * time.Hour)
+	fail = true
+	if err := h.Ensure(ctx, "JP"); err == nil {
+		t.Error("fetch failure not reported")
+	}
+	if _, ok := h.Holiday("JP", day(11, 3)); !ok {
+		t.Error("failed refresh dropped cached holidays")
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 15, h, m, 0, 0, time.UTC) }
+	if until, ok := QuietUntil("22:00", "07:30", at(23, 0)); !ok || !until.Equal(time.Date(2026, 10, 16, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("overnight = %v, %v", until, ok)
+	}
+	if until, ok := QuietUntil("22:00", "07:30", at(6, 0)); !ok || !until.Equal(at(7, 30)) {
+		t.Errorf("early morning = %v, %v", until, ok)
+	}
+	if _, ok := QuietUntil("22:00", "07:30", at(12, 0)); ok {
+		t.Error("midday reported quiet")
+	}
+	if !IsWeekend(nil, time.Saturday) || IsWeekend([]string{"fri", "sat"}, time.Sunday) {
+		t.Error("IsWeekend")
+	}
+}
+
+func mustSource(t *testing.T, s string) string {
+	t.Helper()
+	src, err := HolidaySource(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
@@ -0,0 +1,256 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/objstore"
+)
+
+// Remote is an object-storage bucket each backup is copied to. Backups go
+// under "backups/" and the mirrored outputs directory under "outputs/".
+type Remote struct {
+	Name              string
+	Store             *objstore.Client
+	RetainDays        int
+	OutputsDir        string // mirrored when set
+	OutputsRetainDays int    // 0 keeps mirrored outputs
+}
+
+// RemoteResult describes copying a backup to one remote.
+type RemoteResult struct {
+	Remote  string `json:"remote"`
+	Key     string `json:"key,omitempty"`
+	Pruned  int    `json:"pruned,omitempty"`
+	Outputs int    `json:"outputs,omitempty"` // output files uploaded
+	Error   string `json:"error,omitempty"`
+}
+
+// Upload copies the backup at path to every remote, then applies each
+// remote's retention and mirrors the outputs directory. Failures are logged
+// and reported per remote; they never undo the local backup.
+func (bs *BackupScheduler) Upload(ctx context.Context, path string) []RemoteResult {
+	var results []RemoteResult
+	for _, r := range bs.cfg.Remotes {
+		res := RemoteResult{Remote: r.Name}
+		key, err := r.put(ctx, path)
+		if err != nil {
+			res.Error = err.Error()
+			bs.cfg.LogWarn("remote backup failed", "remote", r.Name, "error", err)
+			results = append(results, res)
+			continue
+		}
+		res.Key = key
+		if res.Pruned, err = r.prune(ctx, "backups/", r.RetainDays, key); err != nil {
+			bs.cfg.LogWarn("remote backup retention failed", "remote", r.Name, "error", err)
+		}
+		if r.OutputsDir != "" {
+			if res.Outputs, err = r.syncOutputs(ctx); err != nil {
+				res.Error = err.Error()
+				bs.cfg.LogWarn("remote outputs sync failed", "remote", r.Name, "error", err)
+			}
+		}
+		bs.cfg.LogInfo("remote backup complete", "remote", r.Name, "key", key, "pruned", res.Pruned, "outputs", res.Outputs)
+		results = append(results, res)
+	}
+	return results
+}
+
+// Remote returns the remote with the given name, or the first one when name
+// is empty.
+func (bs *BackupScheduler) Remote(name string) (Remote, error) {
+	if len(bs.cfg.Remotes) == 0 {
+		return Remote{}, fmt.Errorf("no backup remotes configured (ops.backupRemotes)")
+	}
+	if name == "" {
+		return bs.cfg.Remotes[0], nil
+	}
+	for _, r := range bs.cfg.Remotes {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Remote{}, fmt.Errorf("backup remote %q not found", name)
+}
+
+func (r Remote) put(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	key := "backups/" + filepath.Base(path)
+	if err := r.Store.Put(ctx, key, f, info.Size()); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// prune deletes objects under prefix older than retainDays, never keep.
+func (r Remote) prune(ctx context.Context, prefix string, retainDays int, keep string) (int, error) {
+	if retainDays <= 0 {
+		return 0, nil
+	}
+	objs, err := r.Store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -retainDays)
+	removed := 0
+	for _, o := range objs {
+		if o.Key == keep || !o.Modified.Before(cutoff) {
+			continue
+		}
+		if err := r.Store.Delete(ctx, o.Key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// syncOutputs uploads output files the remote lacks or holds at another
+// size, then prunes mirrored outputs past their retention.
+func (r Remote) syncOutputs(ctx context.Context) (int, error) {
+	objs, err := r.Store.List(ctx, "outputs/")
+	if err != nil {
+		return 0, err
+	}
+	have := make(map[string]int64, len(objs))
+	for _, o := range objs {
+		have[o.Key] = o.Size
+	}
+	uploaded := 0
+	err = filepath.WalkDir(r.OutputsDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.OutputsDir, p)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		key := path.Join("outputs", filepath.ToSlash(rel))
+		if size, ok := have[key]; ok && size == info.Size() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		if err := r.Store.Put(ctx, key, f, info.Size()); err != nil {
+			return err
+		}
+		uploaded++
+		return nil
+	})
+	if err != nil {
+		return uploaded, err
+	}
+	_, err = r.prune(ctx, "outputs/", r.OutputsRetainDays, "")
+	return uploaded, err
+}
+
+// ListBackups returns the backups stored on the remote, newest first.
+func (r Remote) ListBackups(ctx context.Context) ([]BackupInfo, error) {
+	objs, err := r.Store.List(ctx, "backups/")
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupInfo{}
+	for _, o := range objs {
+		name := strings.TrimPrefix(o.Key, "backups/")
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".db.bak") {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Filename:  name,
+			SizeBytes: o.Size,
+			CreatedAt: o.Modified.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Filename > backups[j].Filename
+	})
+	return backups, nil
+}
+
+// Download fetches the named backup from the remote into dir and returns
+// its local path.
+func (r Remote) Download(ctx context.Context, name, dir string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".db.bak") {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	body, err := r.Store.Get(ctx, "backups/"+name)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, name)
+	tmp := dst + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return dst, os.Rename(tmp, dst)
+}
+
+// RestoreDB replaces the database at dbPath with the backup at backupPath
+// after checking its integrity. The current database is first kept in
+// backupDir as "<time>_pre-restore.db.bak", whose path is returned. The
+// daemon must not be running.
+func RestoreDB(backupPath, dbPath, backupDir string) (string, error) {
+	if err := VerifyDBBackup(backupPath); err != nil {
+		return "", fmt.Errorf("backup verification failed: %w", err)
+	}
+	saved := ""
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.MkdirAll(backupDir, 0o755); err != nil {
+			return "", fmt.Errorf("create backup dir: %w", err)
+		}
+		saved = filepath.Join(backupDir, time.Now().UTC().Format("20060102-150405")+"_pre-restore.db.bak")
+		if err := CopyFile(dbPath, saved); err != nil {
+			return "", fmt.Errorf("save current database: %w", err)
+		}
+	}
+	tmp := dbPath + ".restore"
+	if err := CopyFile(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return saved, fmt.Errorf("copy backup: %w", err)
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		os.Remove(tmp)
+		return saved, fmt.Errorf("replace database: %w", err)
+	}
+	// The old WAL belongs to the replaced database.
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+	return saved, nil
+}
@@ -0,0 +1,171 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/objstore"
+)
+
+type fakeObject struct {
+	data     []byte
+	modified time.Time
+}
+
+// newFakeRemote serves an in-memory path-style bucket "bkt".
+func newFakeRemote(t *testing.T, objects map[string]fakeObject) Remote {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/bkt/")
+		switch {
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[key] = fakeObject{data: data, modified: time.Now()}
+		case r.Method == http.MethodGet && r.URL.Path == "/bkt":
+			prefix := r.URL.Query().Get("prefix")
+			var keys []string
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, k := range keys {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified></Contents>",
+					k, len(objects[k].data), objects[k].modified.UTC().Format(time.RFC3339))
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+		case r.Method == http.MethodGet:
+			o, ok := objects[key]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(o.data)
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	store, err := objstore.New(config.ObjectStoreConfig{
+		Endpoint: srv.URL, Bucket: "bkt", AccessKey: "a", SecretKey: "s",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Remote{Name: "fake", Store: store, RetainDays: 7}
+}
+
+func TestBackupScheduler_Upload(t *testing.T) {
+	dir := t.TempDir()
+	outputs := filepath.Join(dir, "outputs")
+	os.MkdirAll(filepath.Join(outputs, "job"), 0o755)
+	os.WriteFile(filepath.Join(outputs, "job", "new.txt"), []byte("new"), 0o644)
+	os.WriteFile(filepath.Join(outputs, "same.txt"), []byte("same"), 0o644)
+	backup := filepath.Join(dir, "20260110-000000_tetora.db.bak")
+	os.WriteFile(backup, []byte("db"), 0o644)
+
+	old := time.Now().AddDate(0, 0, -30)
+	objects := map[string]fakeObject{
+		"backups/20251201-000000_tetora.db.bak": {data: []byte("old"), modified: old},
+		"backups/20260109-000000_tetora.db.bak": {data: []byte("recent"), modified: time.Now()},
+		"outputs/same.txt":                      {data: []byte("same"), modified: old},
+	}
+	remote := newFakeRemote(t, objects)
+	remote.OutputsDir = outputs
+
+	bs := NewBackupScheduler(BackupConfig{Remotes: []Remote{remote}})
+	results := bs.Upload(context.Background(), backup)
+	if len(results) != 1 || results[0].Error != "" {
+		t.Fatalf("results = %+v", results)
+	}
+	res := results[0]
+	if res.Key != "backups/20260110-000000_tetora.db.bak" || res.Pruned != 1 || res.Outputs != 1 {
+		t.Errorf("result = %+v", res)
+	}
+	if _, ok := objects["backups/20251201-000000_tetora.db.bak"]; ok {
+		t.Error("expired backup was not pruned")
+	}
+	if string(objects["outputs/job/new.txt"].data) != "new" {
+		t.Errorf("outputs not mirrored: %v", objects)
+	}
+
+	backups, err := remote.ListBackups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Filename != "20260110-000000_tetora.db.bak" {
+		t.Errorf("remote backups = %+v", backups)
+	}
+
+	if _, err := bs.Remote("other"); err == nil {
+		t.Error("expected error for unknown remote")
+	}
+	if r, err := bs.Remote(""); err != nil || r.Name != "fake" {
+		t.Errorf("default remote = %v, %v", r.Name, err)
+	}
+}
+
+func TestRemoteDownloadAndRestoreDB(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	if err := exec.Command("sqlite3", src, "CREATE TABLE t(v TEXT); INSERT INTO t VALUES('restored');").Run(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(src)
+	remote := newFakeRemote(t, map[string]fakeObject{
+		"backups/20260110-000000_tetora.db.bak": {data: data, modified: time.Now()},
+	})
+
+	if _, err := remote.Download(context.Background(), "../x.db.bak", dir); err == nil {
+		t.Error("expected error for path in backup name")
+	}
+	local, err := remote.Download(context.Background(), "20260110-000000_tetora.db.bak", filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(dir, "history.db")
+	exec.Command("sqlite3", dbPath, "CREATE TABLE t(v TEXT); INSERT INTO t VALUES('current');").Run()
+	saved, err := RestoreDB(local, dbPath, filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(saved, "_pre-restore.db.bak") {
+		t.Errorf("saved = %q", saved)
+	}
+	out, _ := exec.Command("sqlite3", dbPath, "SELECT v FROM t").Output()
+	if strings.TrimSpace(string(out)) != "restored" {
+		t.Errorf("restored db has %q", out)
+	}
+	out, _ = exec.Command("sqlite3", saved, "SELECT v FROM t").Output()
+	if strings.TrimSpace(string(out)) != "current" {
+		t.Errorf("pre-restore copy has %q", out)
+	}
+
+	bad := filepath.Join(dir, "bad.db.bak")
+	os.WriteFile(bad, []byte("not a database"), 0o644)
+	if _, err := RestoreDB(bad, dbPath, dir); err == nil {
+		t.Error("expected error restoring a corrupt backup")
+	}
+}
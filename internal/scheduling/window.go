@@ -0,0 +1,154 @@
+package scheduling
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tetora/internal/quiet"
+)
+
+// --- Run Windows ---
+
+// Window is a recurring weekly time range during which work may run.
+// A window whose End is earlier than its Start wraps past midnight and
+// belongs to the day it starts on (e.g. Fri 23:00-02:00 ends Sat 02:00).
+type Window struct {
+	Days  []string // "mon".."sun", "weekdays", "weekends"; empty = every day
+	Start string   // "HH:MM"; empty = 00:00
+	End   string   // "HH:MM", exclusive; empty = end of day
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ValidateWindow reports whether a window has parseable days and times.
+func ValidateWindow(w Window) error {
+	for _, d := range w.Days {
+		if _, err := expandDay(d); err != nil {
+			return err
+		}
+	}
+	for _, s := range []string{w.Start, w.End} {
+		if s == "" {
+			continue
+		}
+		if h, _ := quiet.ParseHHMM(s); h < 0 {
+			return fmt.Errorf("invalid time %q (want HH:MM)", s)
+		}
+	}
+	return nil
+}
+
+// InWindows reports whether t falls inside any of the windows.
+// t should already be in the schedule's location. No windows means always allowed.
+func InWindows(windows []Window, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	nowMin := t.Hour()*60 + t.Minute()
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	for _, w := range windows {
+		start, end, ok := windowBounds(w)
+		if !ok {
+			continue
+		}
+		if start < end {
+			if dayMatches(w.Days, t.Weekday()) && nowMin >= start && nowMin < end {
+				return true
+			}
+			continue
+		}
+		// Overnight: the tail of yesterday's window, or the head of today's.
+		if dayMatches(w.Days, t.Weekday()) && nowMin >= start {
+			return true
+		}
+		if dayMatches(w.Days, yesterday) && nowMin < end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextWindowStart returns the earliest window start strictly after t, or the
+// zero time when no valid window exists. The result is in t's location.
+func NextWindowStart(windows []Window, t time.Time) time.Time {
+	var next time.Time
+	for offset := 0; offset <= 7; offset++ {
+		day := t.AddDate(0, 0, offset)
+		for _, w := range windows {
+			start, _, ok := windowBounds(w)
+			if !ok || !dayMatches(w.Days, day.Weekday()) {
+				continue
+			}
+			cand := time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, t.Location())
+			if cand.After(t) && (next.IsZero() || cand.Before(next)) {
+				next = cand
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
+
+// windowBounds returns start/end in minutes since midnight. End of day is 1440.
+func windowBounds(w Window) (int, int, bool) {
+	start, end := 0, 24*60
+	if w.Start != "" {
+		h, m := quiet.ParseHHMM(w.Start)
+		if h < 0 {
+			return 0, 0, false
+		}
+		start = h*60 + m
+	}
+	if w.End != "" {
+		h, m := quiet.ParseHHMM(w.End)
+		if h < 0 {
+			return 0, 0, false
+		}
+		end = h*60 + m
+	}
+	if start == end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+func dayMatches(days []string, wd time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		expanded, err := expandDay(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range expanded {
+			if e == wd {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func expandDay(d string) ([]time.Weekday, error) {
+	d = strings.ToLower(strings.TrimSpace(d))
+	switch d {
+	case "weekdays":
+		return []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, nil
+	case "weekends":
+		return []time.Weekday{time.Saturday, time.Sunday}, nil
+	}
+	if len(d) > 3 {
+		d = d[:3]
+	}
+	if wd, ok := weekdayNames[d]; ok {
+		return []time.Weekday{wd}, nil
+	}
+	return nil, fmt.Errorf("invalid day %q", d)
+}
@@ -0,0 +1,73 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInWindows(t *testing.T) {
+	nightly := []Window{{Start: "02:00", End: "05:00"}}
+	weekdays := []Window{{Days: []string{"weekdays"}}}
+	overnight := []Window{{Days: []string{"fri"}, Start: "23:00", End: "02:00"}}
+
+	// 2026-10-16 is a Friday.
+	at := func(day, h, m int) time.Time { return time.Date(2026, 10, day, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		windows []Window
+		t       time.Time
+		want    bool
+	}{
+		{"no windows", nil, at(16, 12, 0), true},
+		{"inside nightly", nightly, at(16, 3, 0), true},
+		{"nightly end exclusive", nightly, at(16, 5, 0), false},
+		{"outside nightly", nightly, at(16, 12, 0), false},
+		{"weekday", weekdays, at(16, 12, 0), true},
+		{"weekend", weekdays, at(17, 12, 0), false},
+		{"overnight head", overnight, at(16, 23, 30), true},
+		{"overnight tail", overnight, at(17, 1, 30), true},
+		{"overnight wrong day", overnight, at(16, 1, 30), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InWindows(tt.windows, tt.t); got != tt.want {
+				t.Errorf("InWindows(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextWindowStart(t *testing.T) {
+	// Saturday noon → next weekday window opens Monday 09:00.
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	windows := []Window{{Days: []string{"weekdays"}, Start: "09:00", End: "18:00"}}
+	want := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	if got := NextWindowStart(windows, now); !got.Equal(want) {
+		t.Errorf("NextWindowStart = %v, want %v", got, want)
+	}
+
+	// Same day later start.
+	windows = []Window{{Start: "02:00", End: "05:00"}}
+	now = time.Date(2026, 10, 17, 1, 0, 0, 0, time.UTC)
+	want = time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC)
+	if got := NextWindowStart(windows, now); !got.Equal(want) {
+		t.Errorf("NextWindowStart = %v, want %v", got, want)
+	}
+
+	if got := NextWindowStart([]Window{{Days: []string{"bogus"}}}, now); !got.IsZero() {
+		t.Errorf("NextWindowStart with no valid days = %v, want zero", got)
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	if err := ValidateWindow(Window{Days: []string{"Mon", "weekends"}, Start: "02:00", End: "05:00"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateWindow(Window{Days: []string{"someday"}}); err == nil {
+		t.Error("expected error for invalid day")
+	}
+	if err := ValidateWindow(Window{Start: "25:00"}); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
@@ -75,13 +75,7 @@ func QueryLastAssistantMessage(dbPath, sessionID string) string {
 		return ""
 	}
 	content, _ := rows[0]["content"].(string)
-	// Decrypt if needed.
-	if k := getEncryptionKey(); k != "" && DecryptFn != nil {
-		if dec, err := DecryptFn(content, k); err == nil {
-			content = dec
-		}
-	}
-	return content
+	return decryptContent(content)
 }
 
 // RecordCorrection writes a correction-based lesson to workspace/rules/conversation-corrections.md.
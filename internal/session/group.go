@@ -0,0 +1,163 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"tetora/internal/db"
+)
+
+// --- Group Sessions ---
+
+// Group is a session shared by several agents. Each message may address
+// agents with @name; otherwise the moderator, when there is one, picks who
+// answers. All agents see the same conversation history.
+type Group struct {
+	SessionID string   `json:"sessionId"`
+	Agents    []string `json:"agents"`
+	Moderator string   `json:"moderator,omitempty"`
+}
+
+// SetParticipants replaces the agents taking part in a session. An empty
+// list turns the session back into a single-agent one. The moderator, when
+// set, must be one of the agents.
+func SetParticipants(dbPath, sessionID string, agents []string, moderator string) error {
+	seen := map[string]bool{}
+	var uniq []string
+	for _, a := range agents {
+		if a = strings.TrimSpace(a); a != "" && !seen[a] {
+			seen[a] = true
+			uniq = append(uniq, a)
+		}
+	}
+	if moderator != "" && !seen[moderator] {
+		return fmt.Errorf("moderator %q is not a participant", moderator)
+	}
+	now := time.Now().Format(time.RFC3339)
+	stmts := []string{fmt.Sprintf(`DELETE FROM session_participants WHERE session_id = '%s';`, db.Escape(sessionID))}
+	for _, a := range uniq {
+		mod := 0
+		if a == moderator {
+			mod = 1
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			`INSERT INTO session_participants (session_id, agent, moderator, added_at) VALUES ('%s','%s',%d,'%s');`,
+			db.Escape(sessionID), db.Escape(a), mod, now))
+	}
+	return db.Exec(dbPath, "BEGIN;\n"+strings.Join(stmts, "\n")+"\nCOMMIT;")
+}
+
+// QueryGroup returns the session's participants, or nil when it is not a
+// group session.
+func QueryGroup(dbPath, sessionID string) (*Group, error) {
+	rows, err := db.Query(dbPath, fmt.Sprintf(
+		`SELECT agent, moderator FROM session_participants WHERE session_id = '%s' ORDER BY added_at ASC, rowid ASC`,
+		db.Escape(sessionID)))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	g := &Group{SessionID: sessionID}
+	for _, row := range rows {
+		a := db.Str(row["agent"])
+		g.Agents = append(g.Agents, a)
+		if db.Int(row["moderator"]) == 1 {
+			g.Moderator = a
+		}
+	}
+	return g, nil
+}
+
+// Has reports whether agent takes part in the group.
+func (g *Group) Has(agent string) bool {
+	for _, a := range g.Agents {
+		if strings.EqualFold(a, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mentions returns the participants addressed with @name in text, in the
+// order they first appear. Matching ignores case; "@all" addresses everyone.
+func (g *Group) Mentions(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || (i > 0 && isNameRune(rune(text[i-1]))) {
+			continue
+		}
+		j := i + 1
+		for j < len(text) && (isNameRune(rune(text[j])) || text[j] >= 0x80) {
+			j++
+		}
+		name := strings.TrimRight(text[i+1:j], ".-_")
+		if strings.EqualFold(name, "all") {
+			return append([]string(nil), g.Agents...)
+		}
+		for _, a := range g.Agents {
+			if strings.EqualFold(a, name) && !seen[a] {
+				seen[a] = true
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.'
+}
+
+// ModeratorPrompt asks the moderator which participants should answer the
+// latest message.
+func (g *Group) ModeratorPrompt(message string) string {
+	var others []string
+	for _, a := range g.Agents {
+		if a != g.Moderator {
+			others = append(others, a)
+		}
+	}
+	return fmt.Sprintf(
+		"You are moderating a group conversation between these agents: %s (and yourself, %s).\n"+
+			"Decide who should answer the latest user message. Reply with only the agent names, "+
+			"comma-separated, most relevant first — at most two. Reply with your own name to answer it yourself.\n\n"+
+			"Latest message:\n%s",
+		strings.Join(others, ", "), g.Moderator, message)
+}
+
+// ParseModeratorChoice reads the agents named in a moderator's reply,
+// falling back to the moderator itself when it names nobody known.
+func (g *Group) ParseModeratorChoice(reply string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range strings.FieldsFunc(reply, func(r rune) bool { return !isNameRune(r) && r < 0x80 }) {
+		f = strings.Trim(f, ".-_")
+		for _, a := range g.Agents {
+			if strings.EqualFold(a, f) && !seen[a] {
+				seen[a] = true
+				out = append(out, a)
+			}
+		}
+		if len(out) == 2 {
+			break
+		}
+	}
+	if len(out) == 0 && g.Moderator != "" {
+		out = []string{g.Moderator}
+	}
+	return out
+}
+
+// speaker labels a message in the shared context, naming the agent behind
+// each reply in a group session.
+func speaker(m SessionMessage) string {
+	if m.Role == "assistant" && m.Agent != "" {
+		return m.Role + " @" + m.Agent
+	}
+	return m.Role
+}
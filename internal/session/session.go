@@ -14,15 +14,36 @@ import (
 
 // --- Encryption hooks (set by root wire file) ---
 
-// EncryptFn encrypts content before DB storage. Nil = no encryption.
+// EncryptFn encrypts content before DB storage. It is called with the key
+// from EncryptionKeyFn, which may be empty when it needs none. Nil = no
+// encryption.
 var EncryptFn func(plaintext, key string) (string, error)
 
 // DecryptFn decrypts content after DB retrieval. Nil = no decryption.
 var DecryptFn func(ciphertext, key string) (string, error)
 
-// EncryptionKeyFn returns the current encryption key. Nil or "" = no encryption.
+// EncryptionKeyFn returns the key passed to EncryptFn and DecryptFn.
 var EncryptionKeyFn func() string
 
+// RedactFn scrubs personal data from message content before storage; agent
+// is the replying agent, or the session's. Nil = stored as is.
+var RedactFn func(agent, text string) string
+
+// redactContent applies RedactFn to msg's content.
+func redactContent(dbPath string, msg SessionMessage) string {
+	if RedactFn == nil || msg.Content == "" {
+		return msg.Content
+	}
+	agent := msg.Agent
+	if agent == "" {
+		rows, err := db.Query(dbPath, fmt.Sprintf(`SELECT %s AS agent FROM sessions WHERE id = '%s'`, sessionAgentCol, db.Escape(msg.SessionID)))
+		if err == nil && len(rows) > 0 {
+			agent = db.Str(rows[0]["agent"])
+		}
+	}
+	return RedactFn(agent, msg.Content)
+}
+
 // sessionAgentCol is the actual column name for the agent field in the sessions table.
 // Old schemas use "role", new schemas use "agent". Detected once at init time.
 var sessionAgentCol = "agent"
@@ -71,6 +92,7 @@ type SessionMessage struct {
 	TokensOut int     `json:"tokensOut"`
 	Model     string  `json:"model"`
 	TaskID    string  `json:"taskId"`
+	Agent     string  `json:"agent,omitempty"` // replying agent in a group session
 	Archived  bool    `json:"archived"`
 	CreatedAt string  `json:"createdAt"`
 }
@@ -139,12 +161,21 @@ CREATE TABLE IF NOT EXISTS session_messages (
   tokens_out INTEGER DEFAULT 0,
   model TEXT DEFAULT '',
   task_id TEXT DEFAULT '',
+  agent TEXT NOT NULL DEFAULT '',
   archived INTEGER NOT NULL DEFAULT 0,
   created_at TEXT NOT NULL
 );
 CREATE INDEX IF NOT EXISTS idx_session_messages_session ON session_messages(session_id);
 CREATE INDEX IF NOT EXISTS idx_session_messages_created ON session_messages(created_at);
 CREATE INDEX IF NOT EXISTS idx_session_messages_active ON session_messages(session_id, archived);
+
+CREATE TABLE IF NOT EXISTS session_participants (
+  session_id TEXT NOT NULL,
+  agent TEXT NOT NULL,
+  moderator INTEGER NOT NULL DEFAULT 0,
+  added_at TEXT NOT NULL,
+  PRIMARY KEY (session_id, agent)
+);
 `
 	if err := db.Exec(dbPath, sql); err != nil {
 		return fmt.Errorf("init session db: %w", err)
@@ -176,6 +207,13 @@ CREATE INDEX IF NOT EXISTS idx_session_messages_active ON session_messages(sessi
 	}
 	db.Exec(dbPath, `CREATE INDEX IF NOT EXISTS idx_session_messages_active ON session_messages(session_id, archived);`)
 
+	// group sessions: attribute assistant messages to the agent that wrote them
+	if !tableColumns(dbPath, "session_messages")["agent"] {
+		if err := db.Exec(dbPath, `ALTER TABLE session_messages ADD COLUMN agent TEXT NOT NULL DEFAULT '';`); err != nil {
+			slog.Warn("session migration failed", "column", "agent", "error", err)
+		}
+	}
+
 	ensureSystemLogSession(dbPath)
 
 	return nil
@@ -267,17 +305,11 @@ func CreateSession(dbPath string, s Session) error {
 }
 
 func AddSessionMessage(dbPath string, msg SessionMessage) error {
-	content := msg.Content
-	if k := getEncryptionKey(); k != "" {
-		if EncryptFn != nil {
-			if enc, err := EncryptFn(content, k); err == nil {
-				content = enc
-			}
-		}
-	}
+	content := redactContent(dbPath, msg)
+	content = encryptContent(content)
 	sql := fmt.Sprintf(
-		`INSERT INTO session_messages (session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, created_at)
-		 VALUES ('%s','%s','%s',%f,%d,%d,'%s','%s','%s')`,
+		`INSERT INTO session_messages (session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, agent, created_at)
+		 VALUES ('%s','%s','%s',%f,%d,%d,'%s','%s','%s','%s')`,
 		db.Escape(msg.SessionID),
 		db.Escape(msg.Role),
 		db.Escape(content),
@@ -286,6 +318,7 @@ func AddSessionMessage(dbPath string, msg SessionMessage) error {
 		msg.TokensOut,
 		db.Escape(msg.Model),
 		db.Escape(msg.TaskID),
+		db.Escape(msg.Agent),
 		db.Escape(msg.CreatedAt),
 	)
 	return db.Exec(dbPath, sql)
@@ -338,17 +371,11 @@ func CreateSessionCtx(ctx context.Context, dbPath string, s Session) error {
 
 // AddSessionMessageCtx is like AddSessionMessage but respects context cancellation.
 func AddSessionMessageCtx(ctx context.Context, dbPath string, msg SessionMessage) error {
-	content := msg.Content
-	if k := getEncryptionKey(); k != "" {
-		if EncryptFn != nil {
-			if enc, err := EncryptFn(content, k); err == nil {
-				content = enc
-			}
-		}
-	}
+	content := redactContent(dbPath, msg)
+	content = encryptContent(content)
 	sql := fmt.Sprintf(
-		`INSERT INTO session_messages (session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, created_at)
-		 VALUES ('%s','%s','%s',%f,%d,%d,'%s','%s','%s')`,
+		`INSERT INTO session_messages (session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, agent, created_at)
+		 VALUES ('%s','%s','%s',%f,%d,%d,'%s','%s','%s','%s')`,
 		db.Escape(msg.SessionID),
 		db.Escape(msg.Role),
 		db.Escape(content),
@@ -357,6 +384,7 @@ func AddSessionMessageCtx(ctx context.Context, dbPath string, msg SessionMessage
 		msg.TokensOut,
 		db.Escape(msg.Model),
 		db.Escape(msg.TaskID),
+		db.Escape(msg.Agent),
 		db.Escape(msg.CreatedAt),
 	)
 	return db.ExecContext(ctx, dbPath, sql)
@@ -498,7 +526,7 @@ func QuerySessionsByPrefix(dbPath, prefix string) ([]Session, error) {
 // Use QueryActiveSessionMessages for the active-only view that context-building expects.
 func QuerySessionMessages(dbPath, sessionID string) ([]SessionMessage, error) {
 	sql := fmt.Sprintf(
-		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, archived, created_at
+		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, agent, archived, created_at
 		 FROM session_messages WHERE session_id = '%s' ORDER BY id ASC`,
 		db.Escape(sessionID))
 	rows, err := db.Query(dbPath, sql)
@@ -532,7 +560,7 @@ func SearchSessionHistory(dbPath, sessionID, query string, limit int) ([]Session
 		where += fmt.Sprintf(" AND content LIKE '%%%s%%' ESCAPE '\\'", safe)
 	}
 	sql := fmt.Sprintf(
-		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, archived, created_at
+		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, agent, archived, created_at
 		 FROM session_messages WHERE %s ORDER BY id DESC LIMIT %d`,
 		where, limit)
 	rows, err := db.Query(dbPath, sql)
@@ -552,7 +580,7 @@ func SearchSessionHistory(dbPath, sessionID, query string, limit int) ([]Session
 // this filter keeps the active window tight while preserving history.
 func QueryActiveSessionMessages(dbPath, sessionID string) ([]SessionMessage, error) {
 	sql := fmt.Sprintf(
-		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, archived, created_at
+		`SELECT id, session_id, role, content, cost_usd, tokens_in, tokens_out, model, task_id, agent, archived, created_at
 		 FROM session_messages WHERE session_id = '%s' AND archived = 0 ORDER BY id ASC`,
 		db.Escape(sessionID))
 	rows, err := db.Query(dbPath, sql)
@@ -645,6 +673,7 @@ func CleanupSessions(dbPath string, days int) error {
 	if err := db.Exec(dbPath, msgSQL); err != nil {
 		slog.Warn("cleanup session messages failed", "error", err)
 	}
+	db.Exec(dbPath, strings.Replace(msgSQL, "session_messages", "session_participants", 1))
 
 	sessSQL := fmt.Sprintf(
 		`DELETE FROM sessions WHERE status IN ('completed','archived')
@@ -708,6 +737,7 @@ func CleanupSessionsWithStats(dbPath string, days int, dryRun bool) (CleanupSess
 	if err := db.Exec(dbPath, msgDelSQL); err != nil {
 		slog.Warn("cleanup session messages failed", "error", err)
 	}
+	db.Exec(dbPath, strings.Replace(msgDelSQL, "session_messages", "session_participants", 1))
 
 	sessDelSQL := fmt.Sprintf(
 		`DELETE FROM sessions WHERE status IN ('completed','archived')
@@ -777,13 +807,7 @@ func sessionFromRow(row map[string]any) Session {
 
 func SessionMessageFromRow(row map[string]any) SessionMessage {
 	content := db.Str(row["content"])
-	if k := getEncryptionKey(); k != "" {
-		if DecryptFn != nil {
-			if dec, err := DecryptFn(content, k); err == nil {
-				content = dec
-			}
-		}
-	}
+	content = decryptContent(content)
 	return SessionMessage{
 		ID:        db.Int(row["id"]),
 		SessionID: db.Str(row["session_id"]),
@@ -794,6 +818,7 @@ func SessionMessageFromRow(row map[string]any) SessionMessage {
 		TokensOut: db.Int(row["tokens_out"]),
 		Model:     db.Str(row["model"]),
 		TaskID:    db.Str(row["task_id"]),
+		Agent:     db.Str(row["agent"]),
 		Archived:  db.Int(row["archived"]) == 1,
 		CreatedAt: db.Str(row["created_at"]),
 	}
@@ -888,6 +913,41 @@ func ArchiveChannelSession(dbPath, chKey string) error {
 
 // --- Context Building ---
 
+// SummaryPrefix marks the system message that holds a session's rolling
+// summary. Compaction replaces it rather than stacking summaries, and it is
+// pinned to the top of the context whatever the message window.
+const SummaryPrefix = "[Context Summary] "
+
+// IsSummary reports whether m is a compaction summary ("[COMPACTED]" is
+// written by "tetora compact").
+func IsSummary(m SessionMessage) bool {
+	return m.Role == "system" && (strings.HasPrefix(m.Content, SummaryPrefix) || strings.HasPrefix(m.Content, "[COMPACTED] "))
+}
+
+// SplitSummary separates the latest summary from the other messages.
+func SplitSummary(msgs []SessionMessage) (summary *SessionMessage, rest []SessionMessage) {
+	for i := range msgs {
+		if IsSummary(msgs[i]) {
+			summary = &msgs[i]
+		} else {
+			rest = append(rest, msgs[i])
+		}
+	}
+	return summary, rest
+}
+
+// EstimateTokens estimates the tokens the messages take up in a prompt, at
+// four characters per token.
+func EstimateTokens(msgs []SessionMessage) int {
+	n := 0
+	for _, m := range msgs {
+		n += (len(m.Role) + len(m.Content) + 4) / 4
+	}
+	return n
+}
+
+// BuildSessionContext renders the last maxMessages active messages, after
+// the session summary if there is one.
 func BuildSessionContext(dbPath, sessionID string, maxMessages int) string {
 	if dbPath == "" || sessionID == "" {
 		return ""
@@ -897,17 +957,21 @@ func BuildSessionContext(dbPath, sessionID string, maxMessages int) string {
 		return ""
 	}
 
+	summary, msgs := SplitSummary(msgs)
 	if maxMessages > 0 && len(msgs) > maxMessages {
 		msgs = msgs[len(msgs)-maxMessages:]
 	}
 
 	var lines []string
+	if summary != nil {
+		lines = append(lines, fmt.Sprintf("[%s] %s", summary.Role, summary.Content))
+	}
 	for _, m := range msgs {
 		content := m.Content
 		if len(content) > 2000 {
 			content = content[:2000] + "..."
 		}
-		lines = append(lines, fmt.Sprintf("[%s] %s", m.Role, content))
+		lines = append(lines, fmt.Sprintf("[%s] %s", speaker(m), content))
 	}
 	return strings.Join(lines, "\n\n")
 }
@@ -942,6 +1006,28 @@ func getEncryptionKey() string {
 	return EncryptionKeyFn()
 }
 
+// encryptContent applies EncryptFn, storing content as is if it fails.
+func encryptContent(content string) string {
+	if EncryptFn == nil || content == "" {
+		return content
+	}
+	if enc, err := EncryptFn(content, getEncryptionKey()); err == nil {
+		return enc
+	}
+	return content
+}
+
+// decryptContent applies DecryptFn, returning content as is if it fails.
+func decryptContent(content string) string {
+	if DecryptFn == nil || content == "" {
+		return content
+	}
+	if dec, err := DecryptFn(content, getEncryptionKey()); err == nil {
+		return dec
+	}
+	return content
+}
+
 // NewUUID generates a random UUID v4.
 func NewUUID() string {
 	var b [16]byte
@@ -456,6 +456,36 @@ func TestBuildSessionContext(t *testing.T) {
 	}
 }
 
+func TestBuildSessionContextPinsSummary(t *testing.T) {
+	skipIfNoSQLite(t)
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	InitSessionDB(dbPath)
+
+	now := time.Now().Format(time.RFC3339)
+	CreateSession(dbPath, Session{ID: "ctx-002", Agent: "翡翠", Status: "active", CreatedAt: now, UpdatedAt: now})
+	for _, m := range []SessionMessage{
+		{Role: "user", Content: "kept question"},
+		{Role: "assistant", Content: "kept answer"},
+		{Role: "system", Content: SummaryPrefix + "we chose Postgres"},
+		{Role: "user", Content: "latest question"},
+	} {
+		m.SessionID, m.CreatedAt = "ctx-002", now
+		AddSessionMessage(dbPath, m)
+	}
+
+	ctx := BuildSessionContext(dbPath, "ctx-002", 2)
+	want := "[system] [Context Summary] we chose Postgres\n\n[assistant] kept answer\n\n[user] latest question"
+	if ctx != want {
+		t.Errorf("context =\n%s\nwant\n%s", ctx, want)
+	}
+
+	msgs, _ := QueryActiveSessionMessages(dbPath, "ctx-002")
+	summary, rest := SplitSummary(msgs)
+	if summary == nil || len(rest) != 3 || EstimateTokens(rest) == 0 {
+		t.Errorf("SplitSummary = %v, %d messages", summary, len(rest))
+	}
+}
+
 func TestWrapWithContext(t *testing.T) {
 	got := WrapWithContext("", "Hello world")
 	if got != "Hello world" {
@@ -693,3 +723,74 @@ func TestQuerySessionByIDCtxCancellation(t *testing.T) {
 		t.Error("expected error with cancelled context, got nil")
 	}
 }
+
+func TestGroupSession(t *testing.T) {
+	skipIfNoSQLite(t)
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	InitSessionDB(dbPath)
+
+	if g, err := QueryGroup(dbPath, "sess-group"); err != nil || g != nil {
+		t.Fatalf("QueryGroup before setup = %v, %v", g, err)
+	}
+	if err := SetParticipants(dbPath, "sess-group", []string{"coder", "reviewer"}, "lead"); err == nil {
+		t.Error("moderator outside the group accepted")
+	}
+	if err := SetParticipants(dbPath, "sess-group", []string{"lead", "coder", "reviewer", "coder"}, "lead"); err != nil {
+		t.Fatalf("SetParticipants: %v", err)
+	}
+	g, err := QueryGroup(dbPath, "sess-group")
+	if err != nil || g == nil {
+		t.Fatalf("QueryGroup = %v, %v", g, err)
+	}
+	if strings.Join(g.Agents, ",") != "lead,coder,reviewer" || g.Moderator != "lead" {
+		t.Errorf("group = %+v", g)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	AddSessionMessage(dbPath, SessionMessage{SessionID: "sess-group", Role: "user", Content: "@coder write it", CreatedAt: now})
+	AddSessionMessage(dbPath, SessionMessage{SessionID: "sess-group", Role: "assistant", Agent: "coder", Content: "done", CreatedAt: now})
+	msgs, _ := QuerySessionMessages(dbPath, "sess-group")
+	if len(msgs) != 2 || msgs[1].Agent != "coder" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if ctx := BuildSessionContext(dbPath, "sess-group", 10); !strings.Contains(ctx, "[assistant @coder] done") {
+		t.Errorf("context = %q", ctx)
+	}
+
+	if err := SetParticipants(dbPath, "sess-group", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := QueryGroup(dbPath, "sess-group"); g != nil {
+		t.Errorf("group not cleared: %+v", g)
+	}
+}
+
+func TestGroupMentions(t *testing.T) {
+	g := &Group{Agents: []string{"coder", "reviewer", "琥珀"}, Moderator: "reviewer"}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"@Reviewer check what @coder wrote, then @reviewer again", "reviewer,coder"},
+		{"over to @coder.", "coder"},
+		{"mail me at me@coder.dev", ""},
+		{"@nobody hi", ""},
+		{"@琥珀 こんにちは", "琥珀"},
+		{"@all status?", "coder,reviewer,琥珀"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(g.Mentions(tt.text), ","); got != tt.want {
+			t.Errorf("Mentions(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	if got := strings.Join(g.ParseModeratorChoice("Coder, then reviewer."), ","); got != "coder,reviewer" {
+		t.Errorf("choice = %q", got)
+	}
+	if got := strings.Join(g.ParseModeratorChoice("nobody fits"), ","); got != "reviewer" {
+		t.Errorf("fallback choice = %q", got)
+	}
+	if p := g.ModeratorPrompt("hello"); !strings.Contains(p, "coder, 琥珀") || !strings.Contains(p, "yourself, reviewer") {
+		t.Errorf("prompt = %q", p)
+	}
+}
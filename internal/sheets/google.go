@@ -0,0 +1,139 @@
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"tetora/internal/integration/oauthif"
+)
+
+// GoogleAPI is the Sheets API base URL; tests point it at a fake server.
+var GoogleAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// Google reads and writes Google Sheets as the owner, through an OAuth
+// service whose scopes include
+// https://www.googleapis.com/auth/spreadsheets.
+type Google struct {
+	OAuth   oauthif.Requester
+	Service string // OAuth service name, e.g. "google"
+}
+
+func (g Google) call(ctx context.Context, method, u string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		r = strings.NewReader(string(data))
+	}
+	resp, err := g.OAuth.Request(ctx, g.Service, method, u, r)
+	if err != nil {
+		return fmt.Errorf("google sheets: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("google sheets: %s", e.Error.Message)
+		}
+		return fmt.Errorf("google sheets: HTTP %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// SheetNames lists a spreadsheet's sheets in order.
+func (g Google) SheetNames(ctx context.Context, id string) ([]string, error) {
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := g.call(ctx, http.MethodGet, GoogleAPI+"/"+url.PathEscape(id)+"?fields=sheets.properties.title", nil, &meta); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(meta.Sheets))
+	for _, s := range meta.Sheets {
+		names = append(names, s.Properties.Title)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("google sheets: spreadsheet has no sheets")
+	}
+	return names, nil
+}
+
+// rangeOrFirst defaults an empty range to the first sheet.
+func rangeOrFirst(rng string, names []string) string {
+	if strings.TrimSpace(rng) == "" {
+		return QuoteSheet(names[0])
+	}
+	return rng
+}
+
+// Read reads a range as formatted values; an empty range reads the first
+// sheet. At most maxRows rows are returned.
+func (g Google) Read(ctx context.Context, id, rng string, maxRows int) (*Grid, error) {
+	names, err := g.SheetNames(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rng = rangeOrFirst(rng, names)
+	var vr struct {
+		Range  string     `json:"range"`
+		Values [][]string `json:"values"`
+	}
+	u := fmt.Sprintf("%s/%s/values/%s?valueRenderOption=FORMATTED_VALUE", GoogleAPI, url.PathEscape(id), url.PathEscape(rng))
+	if err := g.call(ctx, http.MethodGet, u, nil, &vr); err != nil {
+		return nil, err
+	}
+	r, _ := ParseRange(vr.Range)
+	g2 := &Grid{Sheets: names, Sheet: r.Sheet, Row: max(r.Row1, 1), Col: max(r.Col1, 1), Rows: vr.Values}
+	if maxRows > 0 && len(g2.Rows) > maxRows {
+		g2.Rows, g2.Truncated = g2.Rows[:maxRows], true
+	}
+	return g2, nil
+}
+
+// Append adds rows after the table found in the range, as if typed by the
+// owner (formulas and numbers are parsed). It returns the range written.
+func (g Google) Append(ctx context.Context, id, rng string, rows [][]any) (string, error) {
+	if strings.TrimSpace(rng) == "" {
+		names, err := g.SheetNames(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		rng = rangeOrFirst(rng, names)
+	}
+	var out struct {
+		Updates struct {
+			UpdatedRange string `json:"updatedRange"`
+		} `json:"updates"`
+	}
+	u := fmt.Sprintf("%s/%s/values/%s:append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS", GoogleAPI, url.PathEscape(id), url.PathEscape(rng))
+	if err := g.call(ctx, http.MethodPost, u, map[string]any{"values": rows}, &out); err != nil {
+		return "", err
+	}
+	return out.Updates.UpdatedRange, nil
+}
+
+// Update writes values starting at the range's top-left cell, as if typed
+// by the owner. It returns the range written.
+func (g Google) Update(ctx context.Context, id, rng string, values [][]any) (string, error) {
+	var out struct {
+		UpdatedRange string `json:"updatedRange"`
+	}
+	u := fmt.Sprintf("%s/%s/values/%s?valueInputOption=USER_ENTERED", GoogleAPI, url.PathEscape(id), url.PathEscape(rng))
+	if err := g.call(ctx, http.MethodPut, u, map[string]any{"values": values}, &out); err != nil {
+		return "", err
+	}
+	return out.UpdatedRange, nil
+}
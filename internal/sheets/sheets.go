@@ -0,0 +1,235 @@
+// Package sheets reads and writes spreadsheets for the sheet tools: Google
+// Sheets through the OAuth framework and local .xlsx files, which are
+// handled with the standard library alone (an xlsx file is a zip of XML
+// parts).
+//
+// Both backends address cells in A1 notation ("Tracker!A2:D20", "Tracker",
+// "B3") and exchange rows of values; reads come back as a Grid.
+package sheets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Grid is a block of cell values read from a sheet.
+type Grid struct {
+	Sheets    []string   // all sheet names in the spreadsheet
+	Sheet     string     // the sheet read
+	Row, Col  int        // 1-based position of Rows[0][0]
+	Rows      [][]string // formatted values; rows may be ragged
+	Truncated bool       // rows beyond the limit were left out
+}
+
+// Range is a parsed A1 range. Zero bounds are open: "Sheet1!B:D" has no
+// rows, "Sheet1" no bounds at all.
+type Range struct {
+	Sheet      string
+	Col1, Row1 int
+	Col2, Row2 int
+}
+
+var cellRe = regexp.MustCompile(`^\$?([A-Za-z]{0,3})\$?([0-9]*)$`)
+
+// ParseRange parses "Sheet!A1:C10", "'My sheet'!B2", "A1:C", "Sheet" and
+// the like. A bare name that is not a cell reference is a sheet name.
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+	var r Range
+	cells := s
+	if i := strings.LastIndex(s, "!"); i >= 0 {
+		r.Sheet, cells = unquoteSheet(s[:i]), s[i+1:]
+	} else if !isCells(s) {
+		r.Sheet = unquoteSheet(s)
+		return r, nil
+	}
+	if cells == "" {
+		return r, nil
+	}
+	first, last, isSpan := strings.Cut(cells, ":")
+	var err error
+	if r.Col1, r.Row1, err = parseCell(first); err != nil {
+		return Range{}, err
+	}
+	if isSpan {
+		if r.Col2, r.Row2, err = parseCell(last); err != nil {
+			return Range{}, err
+		}
+	} else {
+		r.Col2, r.Row2 = r.Col1, r.Row1
+	}
+	return r, nil
+}
+
+func isCells(s string) bool {
+	first, last, isSpan := strings.Cut(s, ":")
+	if s == "" || !cellRe.MatchString(first) || isSpan && !cellRe.MatchString(last) {
+		return false
+	}
+	// A short word ("Log") also fits the column pattern, so a bare name is
+	// a cell reference only with a row number or a span.
+	return strings.ContainsAny(first, "0123456789") || isSpan
+}
+
+func unquoteSheet(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+func parseCell(s string) (col, row int, err error) {
+	m := cellRe.FindStringSubmatch(s)
+	if m == nil || m[1] == "" && m[2] == "" {
+		return 0, 0, fmt.Errorf("invalid cell reference %q", s)
+	}
+	if m[1] != "" {
+		col = ColIndex(m[1])
+	}
+	if m[2] != "" {
+		row, _ = strconv.Atoi(m[2])
+		if row == 0 {
+			return 0, 0, fmt.Errorf("invalid cell reference %q", s)
+		}
+	}
+	return col, row, nil
+}
+
+// ColIndex returns the 1-based index of a column name ("A" = 1, "AB" = 28).
+func ColIndex(name string) int {
+	n := 0
+	for _, c := range strings.ToUpper(name) {
+		n = n*26 + int(c-'A'+1)
+	}
+	return n
+}
+
+// ColName returns the name of a 1-based column index.
+func ColName(n int) string {
+	var b []byte
+	for n > 0 {
+		n--
+		b = append([]byte{byte('A' + n%26)}, b...)
+		n /= 26
+	}
+	return string(b)
+}
+
+// CellRef returns the A1 reference of a 1-based column and row.
+func CellRef(col, row int) string {
+	return ColName(col) + strconv.Itoa(row)
+}
+
+// QuoteSheet quotes a sheet name for use in a range when needed.
+func QuoteSheet(name string) string {
+	if plainSheetRe.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+var (
+	plainSheetRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	googleIDRe   = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+	googleURLRe  = regexp.MustCompile(`/spreadsheets/d/([A-Za-z0-9_-]+)`)
+)
+
+// IsLocal reports whether spreadsheet names a local .xlsx file rather than a
+// Google Sheets ID or URL.
+func IsLocal(spreadsheet string) bool {
+	return strings.EqualFold(filepath.Ext(spreadsheet), ".xlsx")
+}
+
+// GoogleID extracts the spreadsheet ID from a Google Sheets URL or ID.
+func GoogleID(spreadsheet string) (string, error) {
+	if m := googleURLRe.FindStringSubmatch(spreadsheet); m != nil {
+		return m[1], nil
+	}
+	if googleIDRe.MatchString(spreadsheet) {
+		return spreadsheet, nil
+	}
+	return "", fmt.Errorf("%q is neither a Google Sheets URL or ID nor an .xlsx path", spreadsheet)
+}
+
+// ResolvePath returns the absolute path of a local .xlsx file, which must
+// lie inside one of dirs. Relative paths are taken from the first dir.
+func ResolvePath(dirs []string, p string) (string, error) {
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("no spreadsheet directories configured")
+	}
+	p = expandHome(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(expandHome(dirs[0]), p)
+	}
+	p = filepath.Clean(p)
+	// Resolve symlinks in the existing part of the path, so a link inside
+	// an allowed directory cannot point outside it.
+	real := p
+	if r, err := filepath.EvalSymlinks(p); err == nil {
+		real = r
+	} else if r, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+		real = filepath.Join(r, filepath.Base(p))
+	}
+	for _, d := range dirs {
+		d = filepath.Clean(expandHome(d))
+		if r, err := filepath.EvalSymlinks(d); err == nil {
+			d = r
+		}
+		if rel, err := filepath.Rel(d, real); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside the spreadsheet directories (%s)", p, strings.Join(dirs, ", "))
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
+
+// Format renders a grid as a markdown table headed by column letters, with
+// each row's sheet row number, so cells can be addressed in later calls.
+func Format(g *Grid) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sheet %q", g.Sheet)
+	if len(g.Sheets) > 1 {
+		fmt.Fprintf(&b, " (sheets: %s)", strings.Join(g.Sheets, ", "))
+	}
+	width := 0
+	for _, row := range g.Rows {
+		width = max(width, len(row))
+	}
+	if len(g.Rows) == 0 || width == 0 {
+		b.WriteString(": no values in range.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, ", %s:%s", CellRef(g.Col, g.Row), CellRef(g.Col+width-1, g.Row+len(g.Rows)-1))
+	if g.Truncated {
+		b.WriteString(", truncated")
+	}
+	b.WriteString("\n\n| row |")
+	for c := 0; c < width; c++ {
+		b.WriteString(" " + ColName(g.Col+c) + " |")
+	}
+	b.WriteString("\n|---|" + strings.Repeat("---|", width) + "\n")
+	for i, row := range g.Rows {
+		fmt.Fprintf(&b, "| %d |", g.Row+i)
+		for c := 0; c < width; c++ {
+			v := ""
+			if c < len(row) {
+				v = strings.NewReplacer("|", `\|`, "\n", " ").Replace(row[c])
+			}
+			b.WriteString(" " + v + " |")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,202 @@
+package sheets
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	for in, want := range map[string]Range{
+		"Sheet1!A1:C10":    {Sheet: "Sheet1", Col1: 1, Row1: 1, Col2: 3, Row2: 10},
+		"'My ''list'''!B2": {Sheet: "My 'list'", Col1: 2, Row1: 2, Col2: 2, Row2: 2},
+		"Tasks":            {Sheet: "Tasks"},
+		"Log!B:D":          {Sheet: "Log", Col1: 2, Col2: 4},
+		"A2:C":             {Col1: 1, Row1: 2, Col2: 3},
+		"AA10":             {Col1: 27, Row1: 10, Col2: 27, Row2: 10},
+		"":                 {},
+	} {
+		if got, err := ParseRange(in); err != nil || got != want {
+			t.Errorf("ParseRange(%q) = %+v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseRange("Sheet1!A0"); err == nil {
+		t.Error("row 0 accepted")
+	}
+	if ColName(28) != "AB" || ColIndex("AB") != 28 {
+		t.Errorf("ColName(28) = %s", ColName(28))
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := ResolvePath([]string{dir}, "budget.xlsx"); err != nil || p != filepath.Join(dir, "budget.xlsx") {
+		t.Errorf("relative = %q, %v", p, err)
+	}
+	if _, err := ResolvePath([]string{dir}, "../budget.xlsx"); err == nil {
+		t.Error("escape accepted")
+	}
+	outside := t.TempDir()
+	os.Symlink(outside, filepath.Join(dir, "link"))
+	if _, err := ResolvePath([]string{dir}, "link/budget.xlsx"); err == nil {
+		t.Error("symlink escape accepted")
+	}
+	if id, _ := GoogleID("https://docs.google.com/spreadsheets/d/1AbC_def-GHIjklMNOpqrSTUvwxYZ/edit#gid=0"); id != "1AbC_def-GHIjklMNOpqrSTUvwxYZ" {
+		t.Errorf("GoogleID = %q", id)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "chores.xlsx")
+
+	got, err := AppendXLSX(p, "Chores", [][]any{{"Task", "Who", "Done"}, {"Dishes", "Mia", false}})
+	if err != nil || got != "Chores!A1:C2" {
+		t.Fatalf("append to new file = %q, %v", got, err)
+	}
+	if got, err = AppendXLSX(p, "Chores", [][]any{{"Laundry", "Leo", true, 2.5}}); err != nil || got != "Chores!A3:D3" {
+		t.Fatalf("append = %q, %v", got, err)
+	}
+	if got, err = UpdateXLSX(p, "Chores!B2", [][]any{{"Leo & Mia", "=1+1"}}); err != nil || got != "Chores!B2:C2" {
+		t.Fatalf("update = %q, %v", got, err)
+	}
+
+	g, err := ReadXLSX(p, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"Task", "Who", "Done"}, {"Dishes", "Leo & Mia"}, {"Laundry", "Leo", "TRUE", "2.5"}}
+	if !reflect.DeepEqual(g.Rows, want) || g.Sheet != "Chores" || g.Row != 1 || g.Col != 1 {
+		t.Errorf("read = %+v", g)
+	}
+	if g, _ = ReadXLSX(p, "B2:C3", 1); !reflect.DeepEqual(g.Rows, [][]string{{"Leo & Mia"}}) || !g.Truncated || g.Col != 2 {
+		t.Errorf("read range = %+v", g)
+	}
+	out := Format(g)
+	if !strings.Contains(out, "| row | B |") || !strings.Contains(out, "| 2 | Leo & Mia |") {
+		t.Errorf("format:\n%s", out)
+	}
+	if _, err := ReadXLSX(p, "Other", 0); err == nil || !strings.Contains(err.Error(), "sheets: Chores") {
+		t.Errorf("unknown sheet: %v", err)
+	}
+}
+
+// TestXLSXKeepsParts edits a workbook with shared strings, styles and a
+// calculation chain, as spreadsheet apps write them.
+func TestXLSXKeepsParts(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "budget.xlsx")
+	f, _ := os.Create(p)
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{
+		"[Content_Types].xml":        `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Override PartName="/xl/calcChain.xml" ContentType="x"/></Types>`,
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Budget" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId9" Target="calcChain.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>Rent</t></si><si><r><t>Gro</t></r><r><t>ceries</t></r></si></sst>`,
+		"xl/styles.xml":              `<styleSheet/>`,
+		"xl/calcChain.xml":           `<calcChain/>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:x14ac="http://schemas.microsoft.com/office/spreadsheetml/2009/9/ac">` +
+			`<cols><col min="1" max="1" width="20"/></cols><sheetData>` +
+			`<row r="1" spans="1:2" x14ac:dyDescent="0.25"><c r="A1" t="s"><v>0</v></c><c r="B1" s="3"><v>1200</v></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2" s="3"><v>300</v></c></row>` +
+			`<row r="3"><c r="B3" s="3"><f>SUM(B1:B2)</f><v>1500</v></c></row>` +
+			`</sheetData><mergeCells count="0"/></worksheet>`,
+	} {
+		w, _ := zw.Create(name)
+		io.WriteString(w, body)
+	}
+	zw.Close()
+	f.Close()
+
+	if _, err := UpdateXLSX(p, "Budget!B2", [][]any{{"350"}}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := ReadXLSX(p, "Budget", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Rows, [][]string{{"Rent", "1200"}, {"Groceries", "350"}, {"", "1500"}}) {
+		t.Errorf("rows = %q", g.Rows)
+	}
+
+	zr, _ := zip.OpenReader(p)
+	defer zr.Close()
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(data)
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{`<cols><col min="1" max="1" width="20"/></cols>`, `<c r="B2" s="3"><v>350</v></c>`, `<f>SUM(B1:B2)</f>`, `<mergeCells count="0"/>`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lost %s:\n%s", want, sheet)
+		}
+	}
+	if _, ok := parts["xl/calcChain.xml"]; ok || strings.Contains(parts["xl/_rels/workbook.xml.rels"], "calcChain") || strings.Contains(parts["[Content_Types].xml"], "calcChain") {
+		t.Error("calcChain kept")
+	}
+	if parts["xl/styles.xml"] != `<styleSheet/>` {
+		t.Error("styles lost")
+	}
+}
+
+type httpRequester struct{ calls []string }
+
+func (h *httpRequester) Request(ctx context.Context, service, method, u string, body io.Reader) (*http.Response, error) {
+	b := ""
+	if body != nil {
+		data, _ := io.ReadAll(body)
+		b = " " + string(data)
+	}
+	h.calls = append(h.calls, service+" "+method+" "+u+b)
+	req, _ := http.NewRequestWithContext(ctx, method, u, nil)
+	return http.DefaultClient.Do(req)
+}
+
+func TestGoogle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("fields") != "":
+			json.NewEncoder(w).Encode(map[string]any{"sheets": []any{map[string]any{"properties": map[string]any{"title": "Groceries list"}}}})
+		case strings.HasSuffix(r.URL.Path, ":append"):
+			json.NewEncoder(w).Encode(map[string]any{"updates": map[string]any{"updatedRange": "'Groceries list'!A4:B4"}})
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]any{"range": "'Groceries list'!A1:Z1000", "values": [][]string{{"Item", "Qty"}, {"Milk", "2"}}})
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"error":{"message":"The caller does not have permission"}}`)
+		}
+	}))
+	defer srv.Close()
+	old := GoogleAPI
+	GoogleAPI = srv.URL
+	defer func() { GoogleAPI = old }()
+
+	oauth := &httpRequester{}
+	g := Google{OAuth: oauth, Service: "google"}
+	ctx := context.Background()
+	grid, err := g.Read(ctx, "sheet-id", "", 0)
+	if err != nil || grid.Sheet != "Groceries list" || len(grid.Rows) != 2 {
+		t.Fatalf("read = %+v, %v", grid, err)
+	}
+	if !strings.Contains(oauth.calls[1], "/values/%27Groceries%20list%27?") {
+		t.Errorf("read call = %s", oauth.calls[1])
+	}
+	if got, err := g.Append(ctx, "sheet-id", "", [][]any{{"Eggs", 12}}); err != nil || got != "'Groceries list'!A4:B4" {
+		t.Errorf("append = %q, %v", got, err)
+	}
+	if !strings.HasSuffix(oauth.calls[3], `{"values":[["Eggs",12]]}`) {
+		t.Errorf("append call = %s", oauth.calls[3])
+	}
+	if _, err := g.Update(ctx, "sheet-id", "A1", [][]any{{"x"}}); err == nil || !strings.Contains(err.Error(), "does not have permission") {
+		t.Errorf("update error = %v", err)
+	}
+}
@@ -0,0 +1,573 @@
+package sheets
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPart caps one decompressed part of an xlsx file.
+const maxPart = 64 << 20
+
+// workbook is an xlsx file held in memory: its parts in their original
+// order, and where each sheet's XML lives.
+type workbook struct {
+	names  []string          // part names in archive order
+	parts  map[string][]byte // part name → content
+	sheets []string          // sheet names in workbook order
+	paths  map[string]string // sheet name → part name
+}
+
+type xWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xRichText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xRichText) text() string {
+	s := t.T
+	for _, r := range t.R {
+		s += r.T
+	}
+	return s
+}
+
+type xSST struct {
+	SI []xRichText `xml:"si"`
+}
+
+type xSheetData struct {
+	XMLName xml.Name `xml:"sheetData"`
+	Rows    []xRow   `xml:"row"`
+}
+
+type xRow struct {
+	R     int        `xml:"r,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+	Cells []xCell    `xml:"c"`
+}
+
+type xCell struct {
+	R     string     `xml:"r,attr"`
+	S     string     `xml:"s,attr,omitempty"`
+	T     string     `xml:"t,attr,omitempty"`
+	Attrs []xml.Attr `xml:",any,attr"`
+	F     *xFormula  `xml:"f"`
+	V     *string    `xml:"v"`
+	IS    *xInner    `xml:"is"`
+}
+
+type xFormula struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+	Text  string     `xml:",chardata"`
+}
+
+type xInner struct {
+	XML string `xml:",innerxml"`
+}
+
+func openWorkbook(p string) (*workbook, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	wb := &workbook{parts: map[string][]byte{}, paths: map[string]string{}}
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > maxPart {
+			return nil, fmt.Errorf("%s: part %s is too large", p, f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxPart+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		wb.names = append(wb.names, f.Name)
+		wb.parts[f.Name] = data
+	}
+
+	var w xWorkbook
+	var rels xRels
+	if err := xml.Unmarshal(wb.parts["xl/workbook.xml"], &w); err != nil {
+		return nil, fmt.Errorf("%s is not an xlsx workbook: %v", p, err)
+	}
+	xml.Unmarshal(wb.parts["xl/_rels/workbook.xml.rels"], &rels)
+	targets := map[string]string{}
+	for _, r := range rels.Rels {
+		t := r.Target
+		if strings.HasPrefix(t, "/") {
+			t = strings.TrimPrefix(t, "/")
+		} else {
+			t = path.Join("xl", t)
+		}
+		targets[r.ID] = t
+	}
+	for _, s := range w.Sheets {
+		if t, ok := targets[s.RID]; ok && wb.parts[t] != nil {
+			wb.sheets = append(wb.sheets, s.Name)
+			wb.paths[s.Name] = t
+		}
+	}
+	if len(wb.sheets) == 0 {
+		return nil, fmt.Errorf("%s has no worksheets", p)
+	}
+	return wb, nil
+}
+
+// sheet picks the named sheet, or the first one.
+func (wb *workbook) sheet(name string) (string, error) {
+	if name == "" {
+		return wb.sheets[0], nil
+	}
+	for _, s := range wb.sheets {
+		if strings.EqualFold(s, name) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("no sheet %q (sheets: %s)", name, strings.Join(wb.sheets, ", "))
+}
+
+func (wb *workbook) sharedStrings() []string {
+	var sst xSST
+	xml.Unmarshal(wb.parts["xl/sharedStrings.xml"], &sst)
+	out := make([]string, len(sst.SI))
+	for i, si := range sst.SI {
+		out[i] = si.text()
+	}
+	return out
+}
+
+// rows decodes a sheet's cells, giving every row and cell its reference.
+func (wb *workbook) rows(sheet string) ([]xRow, error) {
+	var ws struct {
+		Rows []xRow `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(wb.parts[wb.paths[sheet]], &ws); err != nil {
+		return nil, fmt.Errorf("sheet %q: %v", sheet, err)
+	}
+	prev := 0
+	for i := range ws.Rows {
+		row := &ws.Rows[i]
+		if row.R == 0 {
+			row.R = prev + 1
+		}
+		prev = row.R
+		col := 0
+		for j := range row.Cells {
+			c := &row.Cells[j]
+			if cc, _, err := parseCell(c.R); err == nil && cc > 0 {
+				col = cc
+			} else {
+				col++
+			}
+			c.R = CellRef(col, row.R)
+		}
+	}
+	return ws.Rows, nil
+}
+
+func cellValue(c xCell, shared []string) string {
+	v := ""
+	if c.V != nil {
+		v = *c.V
+	}
+	switch c.T {
+	case "s":
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "inlineStr":
+		if c.IS != nil {
+			var t xRichText
+			xml.Unmarshal([]byte("<is>"+c.IS.XML+"</is>"), &t)
+			return t.text()
+		}
+		return ""
+	case "b":
+		if v == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return v
+}
+
+// ReadXLSX reads a range of a local xlsx file; an empty range or one naming
+// only a sheet reads the sheet's used cells. At most maxRows rows are
+// returned.
+func ReadXLSX(p, rng string, maxRows int) (*Grid, error) {
+	r, err := ParseRange(rng)
+	if err != nil {
+		return nil, err
+	}
+	wb, err := openWorkbook(p)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := wb.sheet(r.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := wb.rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	shared := wb.sharedStrings()
+
+	// Collect the values inside the range, then trim to the used block.
+	inside := func(col, row int) bool {
+		return (r.Col1 == 0 || col >= r.Col1) && (r.Col2 == 0 || col <= r.Col2) &&
+			(r.Row1 == 0 || row >= r.Row1) && (r.Row2 == 0 || row <= r.Row2)
+	}
+	values := map[[2]int]string{}
+	minC, minR, maxC, maxR := 0, 0, 0, 0
+	for _, row := range rows {
+		for _, c := range row.Cells {
+			col, _, _ := parseCell(c.R)
+			v := cellValue(c, shared)
+			if v == "" || !inside(col, row.R) {
+				continue
+			}
+			values[[2]int{row.R, col}] = v
+			if minC == 0 || col < minC {
+				minC = col
+			}
+			if minR == 0 || row.R < minR {
+				minR = row.R
+			}
+			maxC, maxR = max(maxC, col), max(maxR, row.R)
+		}
+	}
+	g := &Grid{Sheets: wb.sheets, Sheet: sheet, Row: max(r.Row1, 1), Col: max(r.Col1, 1)}
+	if len(values) == 0 {
+		return g, nil
+	}
+	// An explicit start keeps its position; an open one starts at the data.
+	if r.Row1 == 0 {
+		g.Row = minR
+	}
+	if r.Col1 == 0 {
+		g.Col = minC
+	}
+	if maxRows > 0 && maxR-g.Row+1 > maxRows {
+		maxR, g.Truncated = g.Row+maxRows-1, true
+	}
+	for rr := g.Row; rr <= maxR; rr++ {
+		var line []string
+		for cc := g.Col; cc <= maxC; cc++ {
+			line = append(line, values[[2]int{rr, cc}])
+		}
+		for len(line) > 0 && line[len(line)-1] == "" {
+			line = line[:len(line)-1]
+		}
+		g.Rows = append(g.Rows, line)
+	}
+	return g, nil
+}
+
+// AppendXLSX adds rows below the last used row of a sheet, starting in the
+// range's first column (default A). A missing file is created with one
+// sheet named after the range (default "Sheet1"). It returns the range
+// written.
+func AppendXLSX(p, rng string, rows [][]any) (string, error) {
+	r, err := ParseRange(rng)
+	if err != nil {
+		return "", err
+	}
+	return writeXLSX(p, r.Sheet, func(existing []xRow) (int, int) {
+		last := 0
+		for _, row := range existing {
+			for _, c := range row.Cells {
+				if c.V != nil || c.IS != nil || c.F != nil {
+					last = max(last, row.R)
+				}
+			}
+		}
+		return max(r.Col1, 1), last + 1
+	}, rows)
+}
+
+// UpdateXLSX writes values into a sheet starting at the range's top-left
+// cell (default A1), replacing what was there. A missing file is created.
+// It returns the range written.
+func UpdateXLSX(p, rng string, values [][]any) (string, error) {
+	r, err := ParseRange(rng)
+	if err != nil {
+		return "", err
+	}
+	return writeXLSX(p, r.Sheet, func([]xRow) (int, int) {
+		return max(r.Col1, 1), max(r.Row1, 1)
+	}, values)
+}
+
+var sheetDataRe = regexp.MustCompile(`(?s)<sheetData\b[^>]*?(?:/>|>.*?</sheetData>)`)
+
+// writeXLSX places values at the position start picks and saves the file
+// atomically. Cell styles are kept; the calculation chain is dropped so
+// spreadsheet apps rebuild it.
+func writeXLSX(p, sheetName string, start func([]xRow) (col, row int), values [][]any) (string, error) {
+	if len(values) == 0 {
+		return "", fmt.Errorf("no values to write")
+	}
+	wb, err := openWorkbook(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		if sheetName == "" {
+			sheetName = "Sheet1"
+		}
+		wb = newWorkbook(sheetName)
+	} else if err != nil {
+		return "", err
+	}
+	sheet, err := wb.sheet(sheetName)
+	if err != nil {
+		return "", err
+	}
+	rows, err := wb.rows(sheet)
+	if err != nil {
+		return "", err
+	}
+	col0, row0 := start(rows)
+
+	byRow := map[int]*xRow{}
+	for i := range rows {
+		byRow[rows[i].R] = &rows[i]
+	}
+	width := 0
+	for i, vals := range values {
+		width = max(width, len(vals))
+		rn := row0 + i
+		row := byRow[rn]
+		if row == nil {
+			rows = append(rows, xRow{R: rn})
+			row = &rows[len(rows)-1]
+			// Appending may move the backing array; rebuild the index.
+			for j := range rows {
+				byRow[rows[j].R] = &rows[j]
+			}
+		}
+		for j, v := range vals {
+			ref := CellRef(col0+j, rn)
+			k := cellIndex(row.Cells, ref)
+			style := ""
+			if k >= 0 {
+				style = row.Cells[k].S
+			}
+			c := newCell(ref, style, v)
+			if k >= 0 {
+				row.Cells[k] = c
+			} else {
+				row.Cells = append(row.Cells, c)
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].R < rows[j].R })
+	for i := range rows {
+		cells := rows[i].Cells
+		sort.SliceStable(cells, func(a, b int) bool {
+			ca, _, _ := parseCell(cells[a].R)
+			cb, _, _ := parseCell(cells[b].R)
+			return ca < cb
+		})
+		// spans is a hint on the row's first and last column, stale once
+		// cells are added.
+		rows[i].Attrs = slices.DeleteFunc(plainAttrs(rows[i].Attrs), func(a xml.Attr) bool { return a.Name.Local == "spans" })
+		for j := range cells {
+			cells[j].Attrs = plainAttrs(cells[j].Attrs)
+			if cells[j].F != nil {
+				cells[j].F.Attrs = plainAttrs(cells[j].F.Attrs)
+			}
+		}
+	}
+
+	data, err := xml.Marshal(xSheetData{Rows: rows})
+	if err != nil {
+		return "", err
+	}
+	part := wb.paths[sheet]
+	src := wb.parts[part]
+	loc := sheetDataRe.FindIndex(src)
+	if loc == nil {
+		return "", fmt.Errorf("sheet %q: no sheetData element (namespace-prefixed sheets are not supported)", sheet)
+	}
+	wb.parts[part] = append(append(append([]byte{}, src[:loc[0]]...), data...), src[loc[1]:]...)
+	wb.dropCalcChain()
+	if err := wb.save(p); err != nil {
+		return "", err
+	}
+	return QuoteSheet(sheet) + "!" + CellRef(col0, row0) + ":" + CellRef(col0+max(width, 1)-1, row0+len(values)-1), nil
+}
+
+func cellIndex(cells []xCell, ref string) int {
+	for i, c := range cells {
+		if c.R == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// plainAttrs drops namespaced attributes (e.g. x14ac:dyDescent), which
+// encoding/xml cannot write back under their original prefix.
+func plainAttrs(attrs []xml.Attr) []xml.Attr {
+	out := attrs[:0]
+	for _, a := range attrs {
+		if a.Name.Space == "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// newCell builds a cell for a JSON value: numbers and numeric strings are
+// numbers, booleans booleans, strings starting with "=" formulas and other
+// strings inline text, as a spreadsheet app would take typed input.
+func newCell(ref, style string, v any) xCell {
+	c := xCell{R: ref, S: style}
+	num := func(s string) xCell {
+		c.V = &s
+		return c
+	}
+	switch v := v.(type) {
+	case nil:
+		return c
+	case float64:
+		return num(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		c.T = "b"
+		if v {
+			return num("1")
+		}
+		return num("0")
+	case string:
+		if f, ok := strings.CutPrefix(v, "="); ok && f != "" {
+			c.F = &xFormula{Text: f}
+			return c
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == v {
+			return num(v)
+		}
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(v))
+		c.T = "inlineStr"
+		c.IS = &xInner{XML: `<t xml:space="preserve">` + b.String() + `</t>`}
+		return c
+	default:
+		return newCell(ref, style, fmt.Sprint(v))
+	}
+}
+
+var (
+	calcChainRelRe  = regexp.MustCompile(`<Relationship\b[^>]*Target="[^"]*calcChain\.xml"[^>]*/>`)
+	calcChainTypeRe = regexp.MustCompile(`<Override\b[^>]*PartName="/xl/calcChain\.xml"[^>]*/>`)
+)
+
+func (wb *workbook) dropCalcChain() {
+	if _, ok := wb.parts["xl/calcChain.xml"]; !ok {
+		return
+	}
+	delete(wb.parts, "xl/calcChain.xml")
+	wb.parts["xl/_rels/workbook.xml.rels"] = calcChainRelRe.ReplaceAll(wb.parts["xl/_rels/workbook.xml.rels"], nil)
+	wb.parts["[Content_Types].xml"] = calcChainTypeRe.ReplaceAll(wb.parts["[Content_Types].xml"], nil)
+}
+
+// save writes the workbook to a temporary file next to p and renames it
+// into place.
+func (wb *workbook) save(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".xlsx-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	zw := zip.NewWriter(tmp)
+	for _, name := range wb.names {
+		data, ok := wb.parts[name]
+		if !ok {
+			continue
+		}
+		w, err := zw.Create(name)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(p); err == nil {
+		os.Chmod(tmp.Name(), fi.Mode().Perm())
+	} else {
+		os.Chmod(tmp.Name(), 0o644)
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// newWorkbook is an empty workbook with one sheet.
+func newWorkbook(sheet string) *workbook {
+	var name bytes.Buffer
+	xml.EscapeText(&name, []byte(sheet))
+	wb := &workbook{
+		sheets: []string{sheet},
+		paths:  map[string]string{sheet: "xl/worksheets/sheet1.xml"},
+		parts: map[string][]byte{
+			"[Content_Types].xml": []byte(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+				`<Default Extension="xml" ContentType="application/xml"/>` +
+				`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+				`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+				`</Types>`),
+			"_rels/.rels": []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+				`</Relationships>`),
+			"xl/workbook.xml": []byte(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`),
+			"xl/_rels/workbook.xml.rels": []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+				`</Relationships>`),
+			"xl/worksheets/sheet1.xml": []byte(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`),
+		},
+	}
+	wb.names = []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"}
+	return wb
+}
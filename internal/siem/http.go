@@ -0,0 +1,113 @@
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"tetora/internal/config"
+)
+
+// httpTransport posts batches as a JSON array, Splunk HEC events or an
+// Elasticsearch _bulk body.
+type httpTransport struct {
+	cfg    config.AuditSinkConfig
+	client *http.Client
+	host   string
+}
+
+func (t *httpTransport) index() string {
+	if t.cfg.Index != "" {
+		return t.cfg.Index
+	}
+	return "tetora-audit"
+}
+
+func (t *httpTransport) send(ctx context.Context, batch []Event) error {
+	body, contentType, err := t.encode(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post: HTTP %d: %s", resp.StatusCode, truncate(string(respBody), 200))
+	}
+	if t.cfg.Format == "elastic" {
+		// _bulk answers 200 even when items were rejected.
+		var r struct {
+			Errors bool `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &r) == nil && r.Errors {
+			return fmt.Errorf("post: bulk request had rejected items")
+		}
+	}
+	return nil
+}
+
+func (t *httpTransport) encode(batch []Event) ([]byte, string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	switch t.cfg.Format {
+	case "splunk":
+		for _, ev := range batch {
+			err := enc.Encode(map[string]any{
+				"time":       float64(ev.Time.UnixMilli()) / 1000,
+				"host":       ev.Host,
+				"source":     "tetora",
+				"sourcetype": t.index(),
+				"event":      ev,
+			})
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		return b.Bytes(), "application/json", nil
+	case "elastic":
+		for _, ev := range batch {
+			enc.Encode(map[string]any{"index": map[string]string{"_index": t.index()}})
+			err := enc.Encode(map[string]any{
+				"@timestamp": ev.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+				"action":     ev.Action,
+				"source":     ev.Source,
+				"detail":     ev.Detail,
+				"ip":         ev.IP,
+				"identity":   ev.Identity,
+				"host":       ev.Host,
+			})
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		return b.Bytes(), "application/x-ndjson", nil
+	default:
+		if err := enc.Encode(batch); err != nil {
+			return nil, "", err
+		}
+		return b.Bytes(), "application/json", nil
+	}
+}
+
+func (t *httpTransport) close() {}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,417 @@
+// Package siem forwards audit events to external collectors: syslog servers
+// (RFC 5424 or CEF over TCP/TLS) and HTTP bulk endpoints such as Splunk HEC
+// or Elasticsearch. Each sink batches events, retries failed deliveries and
+// spools them to disk while the collector is down.
+package siem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+// Event is one audit log entry as sent to collectors.
+type Event struct {
+	Time     time.Time `json:"timestamp"`
+	Action   string    `json:"action"`
+	Source   string    `json:"source,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Identity string    `json:"identity,omitempty"`
+	Host     string    `json:"host,omitempty"`
+}
+
+// Options are the runtime settings shared by all sinks.
+type Options struct {
+	SpoolDir   string
+	Host       string       // reported hostname; default os.Hostname
+	Version    string       // product version in CEF headers
+	HTTPClient *http.Client // default: 30s timeout
+}
+
+// SinkStatus reports a sink's delivery counters.
+type SinkStatus struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Sent        int64  `json:"sent"`
+	Failed      int64  `json:"failed"`  // delivery attempts that failed
+	Dropped     int64  `json:"dropped"` // events lost to a full queue or spool
+	SpoolBytes  int64  `json:"spoolBytes"`
+	LastSentAt  string `json:"lastSentAt,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	LastErrorAt string `json:"lastErrorAt,omitempty"`
+}
+
+// transport delivers one batch to a collector.
+type transport interface {
+	send(ctx context.Context, batch []Event) error
+	close()
+}
+
+// retryDelays are the waits between delivery attempts of a batch.
+var retryDelays = []time.Duration{time.Second, 4 * time.Second}
+
+const sendTimeout = 30 * time.Second
+
+// Exporter fans audit events out to the configured sinks.
+type Exporter struct {
+	sinks []*sink
+	host  string
+}
+
+// New starts a worker for each sink in cfg. Sinks with invalid settings are
+// returned as errors and skipped.
+func New(cfg config.AuditExportConfig, opts Options) (*Exporter, []error) {
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: sendTimeout}
+	}
+	e := &Exporter{host: opts.Host}
+	var errs []error
+	seen := make(map[string]bool)
+	for _, sc := range cfg.Sinks {
+		if err := Validate(sc); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if seen[sc.Name] {
+			errs = append(errs, fmt.Errorf("sink %q: duplicate name", sc.Name))
+			continue
+		}
+		seen[sc.Name] = true
+		t, err := newTransport(sc, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %q: %w", sc.Name, err))
+			continue
+		}
+		s := newSink(sc, t, opts.SpoolDir)
+		go s.run()
+		e.sinks = append(e.sinks, s)
+	}
+	return e, errs
+}
+
+var sinkNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Validate checks a sink's settings.
+func Validate(sc config.AuditSinkConfig) error {
+	if !sinkNameRe.MatchString(sc.Name) {
+		return fmt.Errorf("sink %q: name must be letters, digits, '.', '_' or '-'", sc.Name)
+	}
+	switch sc.Type {
+	case "syslog":
+		if sc.Address == "" {
+			return fmt.Errorf("sink %q: address is required", sc.Name)
+		}
+		if sc.Format != "" && sc.Format != "rfc5424" && sc.Format != "cef" {
+			return fmt.Errorf("sink %q: syslog format must be rfc5424 or cef", sc.Name)
+		}
+		if sc.Facility < 0 || sc.Facility > 23 {
+			return fmt.Errorf("sink %q: facility must be 0-23", sc.Name)
+		}
+	case "http":
+		if !strings.HasPrefix(sc.URL, "https://") && !strings.HasPrefix(sc.URL, "http://") {
+			return fmt.Errorf("sink %q: url must be http(s)", sc.Name)
+		}
+		if sc.Format != "" && sc.Format != "json" && sc.Format != "splunk" && sc.Format != "elastic" {
+			return fmt.Errorf("sink %q: http format must be json, splunk or elastic", sc.Name)
+		}
+	default:
+		return fmt.Errorf("sink %q: type must be syslog or http", sc.Name)
+	}
+	if sc.FlushInterval != "" {
+		if d, err := time.ParseDuration(sc.FlushInterval); err != nil || d <= 0 {
+			return fmt.Errorf("sink %q: invalid flushInterval %q", sc.Name, sc.FlushInterval)
+		}
+	}
+	return nil
+}
+
+func newTransport(sc config.AuditSinkConfig, opts Options) (transport, error) {
+	if sc.Type == "syslog" {
+		return newSyslogTransport(sc, opts)
+	}
+	return &httpTransport{cfg: sc, client: opts.HTTPClient, host: opts.Host}, nil
+}
+
+// Send queues ev for every sink whose filters match. It never blocks; when a
+// sink's queue is full the event is dropped and counted.
+func (e *Exporter) Send(ev Event) {
+	if e == nil {
+		return
+	}
+	if ev.Host == "" {
+		ev.Host = e.host
+	}
+	for _, s := range e.sinks {
+		if !s.wants(ev.Action) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			s.mu.Lock()
+			s.status.Dropped++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close flushes queued events, spooling what cannot be delivered, and waits
+// up to timeout for the sinks to finish.
+func (e *Exporter) Close(timeout time.Duration) {
+	if e == nil {
+		return
+	}
+	for _, s := range e.sinks {
+		close(s.ch)
+	}
+	deadline := time.After(timeout)
+	for _, s := range e.sinks {
+		select {
+		case <-s.done:
+		case <-deadline:
+			log.Warn("audit export: sink did not finish flushing", "sink", s.cfg.Name)
+			return
+		}
+	}
+}
+
+// Status returns the counters of every sink.
+func (e *Exporter) Status() []SinkStatus {
+	if e == nil {
+		return nil
+	}
+	out := make([]SinkStatus, 0, len(e.sinks))
+	for _, s := range e.sinks {
+		s.mu.Lock()
+		st := s.status
+		s.mu.Unlock()
+		if fi, err := os.Stat(s.spoolPath); err == nil {
+			st.SpoolBytes = fi.Size()
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// --- Sink worker ---
+
+type sink struct {
+	cfg       config.AuditSinkConfig
+	t         transport
+	ch        chan Event
+	done      chan struct{}
+	spoolPath string
+	maxSpool  int64
+	batchSize int
+	interval  time.Duration
+
+	mu     sync.Mutex
+	status SinkStatus
+}
+
+func newSink(sc config.AuditSinkConfig, t transport, spoolDir string) *sink {
+	s := &sink{
+		cfg:       sc,
+		t:         t,
+		ch:        make(chan Event, 1024),
+		done:      make(chan struct{}),
+		spoolPath: filepath.Join(spoolDir, sc.Name+".jsonl"),
+		maxSpool:  64 << 20,
+		batchSize: 100,
+		interval:  5 * time.Second,
+		status:    SinkStatus{Name: sc.Name, Type: sc.Type},
+	}
+	if sc.MaxSpoolMB > 0 {
+		s.maxSpool = int64(sc.MaxSpoolMB) << 20
+	}
+	if sc.BatchSize > 0 {
+		s.batchSize = sc.BatchSize
+	}
+	if d, err := time.ParseDuration(sc.FlushInterval); err == nil && d > 0 {
+		s.interval = d
+	}
+	return s
+}
+
+func (s *sink) wants(action string) bool {
+	for _, p := range s.cfg.ExcludeActions {
+		if strings.HasPrefix(action, p) {
+			return false
+		}
+	}
+	if len(s.cfg.Actions) == 0 {
+		return true
+	}
+	for _, p := range s.cfg.Actions {
+		if strings.HasPrefix(action, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sink) run() {
+	defer close(s.done)
+	defer s.t.close()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var buf []Event
+	for {
+		select {
+		case ev, ok := <-s.ch:
+			if !ok {
+				s.flush(buf, false)
+				return
+			}
+			buf = append(buf, ev)
+			if len(buf) >= s.batchSize {
+				s.flush(buf, true)
+				buf = nil
+			}
+		case <-ticker.C:
+			s.flush(buf, true)
+			buf = nil
+		}
+	}
+}
+
+// flush delivers buf after any spooled events, so the collector receives
+// them in order. Whatever cannot be delivered is spooled.
+func (s *sink) flush(buf []Event, retry bool) {
+	if !s.replay() {
+		s.spool(buf)
+		return
+	}
+	if len(buf) == 0 {
+		return
+	}
+	attempts := 1
+	if retry {
+		attempts += len(retryDelays)
+	}
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(retryDelays[i-1])
+		}
+		if s.deliver(buf) {
+			return
+		}
+	}
+	s.spool(buf)
+}
+
+func (s *sink) deliver(batch []Event) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	err := s.t.send(ctx, batch)
+	now := time.Now().UTC().Format(time.RFC3339)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.status.Failed++
+		s.status.LastError = err.Error()
+		s.status.LastErrorAt = now
+		return false
+	}
+	s.status.Sent += int64(len(batch))
+	s.status.LastSentAt = now
+	return true
+}
+
+// replay resends spooled events. It reports whether the spool is now empty.
+func (s *sink) replay() bool {
+	data, err := os.ReadFile(s.spoolPath)
+	if err != nil || len(data) == 0 {
+		return true
+	}
+	var events []Event
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	for sc.Scan() {
+		var ev Event
+		if json.Unmarshal(sc.Bytes(), &ev) == nil {
+			events = append(events, ev)
+		}
+	}
+	for len(events) > 0 {
+		n := min(s.batchSize, len(events))
+		if !s.deliver(events[:n]) {
+			s.rewriteSpool(events)
+			return false
+		}
+		events = events[n:]
+	}
+	os.Remove(s.spoolPath)
+	return true
+}
+
+func (s *sink) rewriteSpool(events []Event) {
+	var b bytes.Buffer
+	for _, ev := range events {
+		line, _ := json.Marshal(ev)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(s.spoolPath, b.Bytes(), 0o600); err != nil {
+		log.Warn("audit export: rewrite spool failed", "sink", s.cfg.Name, "error", err)
+	}
+}
+
+// spool appends events to the sink's spool file, dropping them once the
+// file has reached its size limit.
+func (s *sink) spool(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	var b bytes.Buffer
+	for _, ev := range events {
+		line, _ := json.Marshal(ev)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	var size int64
+	if fi, err := os.Stat(s.spoolPath); err == nil {
+		size = fi.Size()
+	}
+	if size+int64(b.Len()) > s.maxSpool {
+		s.drop(len(events), "spool full")
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.spoolPath), 0o700); err != nil {
+		s.drop(len(events), err.Error())
+		return
+	}
+	f, err := os.OpenFile(s.spoolPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.drop(len(events), err.Error())
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(b.Bytes()); err != nil {
+		s.drop(len(events), err.Error())
+	}
+}
+
+func (s *sink) drop(n int, reason string) {
+	s.mu.Lock()
+	s.status.Dropped += int64(n)
+	s.mu.Unlock()
+	log.Warn("audit export: events dropped", "sink", s.cfg.Name, "count", n, "reason", reason)
+}
@@ -0,0 +1,209 @@
+package siem
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+var t0 = time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+
+func TestFormatRFC5424(t *testing.T) {
+	ev := Event{Time: t0, Action: "tool.violation", Source: "agent", Detail: `denied "bash"`, IP: "10.0.0.1", Host: "nas box"}
+	got := FormatRFC5424(ev, 13, structuredData(ev), ev.Detail)
+	want := `<108>1 2026-10-15T09:30:00.000Z nas_box tetora - tool.violation [tetora@32473 action="tool.violation" source="agent" ip="10.0.0.1"] denied "bash"`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	ev = Event{Time: t0, Action: "config.update", Source: "x]y"}
+	if got := FormatRFC5424(ev, 13, structuredData(ev), ""); !strings.HasPrefix(got, "<109>1 ") || !strings.Contains(got, `source="x\]y"]`) || !strings.Contains(got, " - config.update ") {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestCEF(t *testing.T) {
+	ev := Event{Time: t0, Action: "login|fail", Source: "http", Detail: "user=a\nb", IP: "192.0.2.7", Host: "h"}
+	got := CEF(ev, "2.1")
+	want := `CEF:0|Tetora|Tetora|2.1|login\|fail|login\|fail|7|rt=` + strconv.FormatInt(t0.UnixMilli(), 10) +
+		` act=login|fail src=192.0.2.7 dvchost=h cs1Label=source cs1=http msg=user\=a\nb`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if strings.Contains(CEF(Event{Time: t0, Action: "x", IP: "not-an-ip"}, ""), "src=") {
+		t.Error("non-IP source address included")
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			lenStr, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			frames <- string(buf)
+		}
+	}()
+
+	x, errs := New(config.AuditExportConfig{Sinks: []config.AuditSinkConfig{{
+		Name: "syslog", Type: "syslog", Address: ln.Addr().String(), Format: "cef",
+		Actions: []string{"tool.", "login"}, ExcludeActions: []string{"tool.call"},
+		FlushInterval: "20ms",
+	}}}, Options{SpoolDir: t.TempDir(), Host: "h", Version: "1.0"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	x.Send(Event{Time: t0, Action: "tool.call"})
+	x.Send(Event{Time: t0, Action: "config.update"})
+	x.Send(Event{Time: t0, Action: "tool.violation", Detail: "bash"})
+	x.Close(5 * time.Second)
+
+	select {
+	case f := <-frames:
+		if !strings.HasPrefix(f, "<108>1 2026-10-15T09:30:00.000Z h tetora - tool.violation - CEF:0|Tetora|Tetora|1.0|tool.violation|") {
+			t.Errorf("frame %q", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no syslog frame received")
+	}
+	select {
+	case f := <-frames:
+		t.Errorf("filtered event sent: %q", f)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if st := x.Status(); len(st) != 1 || st[0].Sent != 1 {
+		t.Errorf("status %+v", st)
+	}
+}
+
+func TestHTTPSink_SpoolAndReplay(t *testing.T) {
+	defer func(d []time.Duration) { retryDelays = d }(retryDelays)
+	retryDelays = []time.Duration{time.Millisecond}
+
+	var mu sync.Mutex
+	up := false
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Splunk tok" {
+			t.Errorf("authorization %q", r.Header.Get("Authorization"))
+		}
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var hec struct {
+				Sourcetype string `json:"sourcetype"`
+				Event      Event  `json:"event"`
+			}
+			if err := dec.Decode(&hec); err != nil {
+				t.Errorf("decode: %v", err)
+				return
+			}
+			got = append(got, hec.Sourcetype+":"+hec.Event.Action)
+		}
+	}))
+	defer srv.Close()
+
+	spool := t.TempDir()
+	sc := config.AuditSinkConfig{
+		Name: "hec", Type: "http", URL: srv.URL, Format: "splunk",
+		Headers: map[string]string{"Authorization": "Splunk tok"}, BatchSize: 2, FlushInterval: "1h",
+	}
+	x, errs := New(config.AuditExportConfig{Sinks: []config.AuditSinkConfig{sc}}, Options{SpoolDir: spool})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	x.Send(Event{Time: t0, Action: "a1"})
+	x.Send(Event{Time: t0, Action: "a2"})
+	x.Close(5 * time.Second)
+
+	data, err := os.ReadFile(filepath.Join(spool, "hec.jsonl"))
+	if err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("spool = %q, %v", data, err)
+	}
+	// Two attempts for the full batch, one more to replay at close.
+	if st := x.Status(); st[0].Failed != 3 || st[0].Sent != 0 || st[0].SpoolBytes == 0 {
+		t.Errorf("status while down %+v", st[0])
+	}
+
+	// The collector is back: spooled events go out first, in order.
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	x, _ = New(config.AuditExportConfig{Sinks: []config.AuditSinkConfig{sc}}, Options{SpoolDir: spool})
+	x.Send(Event{Time: t0, Action: "a3"})
+	x.Close(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(got, ",") != "tetora-audit:a1,tetora-audit:a2,tetora-audit:a3" {
+		t.Errorf("delivered %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(spool, "hec.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("spool not cleared: %v", err)
+	}
+}
+
+func TestHTTPSink_ElasticBulk(t *testing.T) {
+	tr := &httpTransport{cfg: config.AuditSinkConfig{Format: "elastic", Index: "sec"}}
+	body, ct, err := tr.encode([]Event{{Time: t0, Action: "a", Host: "h"}})
+	if err != nil || ct != "application/x-ndjson" {
+		t.Fatalf("ct %q err %v", ct, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 || lines[0] != `{"index":{"_index":"sec"}}` || !strings.Contains(lines[1], `"@timestamp":"2026-10-15T09:30:00.000Z"`) {
+		t.Errorf("bulk body %q", body)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, sc := range []config.AuditSinkConfig{
+		{Name: "", Type: "http", URL: "https://x"},
+		{Name: "a/b", Type: "http", URL: "https://x"},
+		{Name: "s", Type: "syslog"},
+		{Name: "s", Type: "syslog", Address: "x:514", Format: "json"},
+		{Name: "h", Type: "http", URL: "ftp://x"},
+		{Name: "h", Type: "http", URL: "https://x", FlushInterval: "soon"},
+		{Name: "k", Type: "kafka"},
+	} {
+		if Validate(sc) == nil {
+			t.Errorf("%+v validated", sc)
+		}
+	}
+	if err := Validate(config.AuditSinkConfig{Name: "siem-1", Type: "syslog", Address: "siem:6514", TLS: true, Format: "cef"}); err != nil {
+		t.Error(err)
+	}
+}
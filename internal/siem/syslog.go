@@ -0,0 +1,205 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// sdID is the structured data ID of RFC 5424 messages. 32473 is the private
+// enterprise number reserved for documentation (RFC 5612).
+const sdID = "tetora@32473"
+
+// syslogTransport writes RFC 5424 messages with octet-counting framing
+// (RFC 6587) over one long-lived TCP or TLS connection.
+type syslogTransport struct {
+	cfg     config.AuditSinkConfig
+	tls     *tls.Config
+	version string
+	conn    net.Conn
+}
+
+func newSyslogTransport(sc config.AuditSinkConfig, opts Options) (*syslogTransport, error) {
+	t := &syslogTransport{cfg: sc, version: opts.Version}
+	if sc.TLS {
+		host, _, err := net.SplitHostPort(sc.Address)
+		if err != nil {
+			return nil, fmt.Errorf("address: %w", err)
+		}
+		t.tls = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if sc.CAFile != "" {
+			pem, err := os.ReadFile(sc.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("caFile: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("caFile: no certificates found")
+			}
+			t.tls.RootCAs = pool
+		}
+	}
+	return t, nil
+}
+
+func (t *syslogTransport) send(ctx context.Context, batch []Event) error {
+	if t.conn == nil {
+		d := &net.Dialer{Timeout: 10 * time.Second}
+		var conn net.Conn
+		var err error
+		if t.tls != nil {
+			conn, err = (&tls.Dialer{NetDialer: d, Config: t.tls}).DialContext(ctx, "tcp", t.cfg.Address)
+		} else {
+			conn, err = d.DialContext(ctx, "tcp", t.cfg.Address)
+		}
+		if err != nil {
+			return fmt.Errorf("syslog dial: %w", err)
+		}
+		t.conn = conn
+	}
+	var b strings.Builder
+	for _, ev := range batch {
+		msg := t.format(ev)
+		b.WriteString(strconv.Itoa(len(msg)))
+		b.WriteByte(' ')
+		b.WriteString(msg)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		t.conn.SetWriteDeadline(dl)
+	}
+	if _, err := t.conn.Write([]byte(b.String())); err != nil {
+		t.close()
+		return fmt.Errorf("syslog write: %w", err)
+	}
+	return nil
+}
+
+func (t *syslogTransport) close() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func (t *syslogTransport) format(ev Event) string {
+	facility := t.cfg.Facility
+	if facility == 0 {
+		facility = 13 // log audit
+	}
+	if t.cfg.Format == "cef" {
+		return FormatRFC5424(ev, facility, "-", CEF(ev, t.version))
+	}
+	return FormatRFC5424(ev, facility, structuredData(ev), ev.Detail)
+}
+
+// FormatRFC5424 renders ev as an RFC 5424 syslog message with the given
+// structured data ("-" for none) and message text.
+func FormatRFC5424(ev Event, facility int, sd, msg string) string {
+	host := headerField(ev.Host, 255)
+	msgID := headerField(ev.Action, 32)
+	s := fmt.Sprintf("<%d>1 %s %s tetora - %s %s", facility*8+syslogSeverity(ev.Action),
+		ev.Time.UTC().Format("2006-01-02T15:04:05.000Z"), host, msgID, sd)
+	if msg != "" {
+		s += " " + msg
+	}
+	return s
+}
+
+func structuredData(ev Event) string {
+	var b strings.Builder
+	b.WriteString("[" + sdID)
+	for _, p := range [][2]string{{"action", ev.Action}, {"source", ev.Source}, {"ip", ev.IP}, {"identity", ev.Identity}} {
+		if p[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, p[0], sdEscape.Replace(p[1]))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+var sdEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// headerField makes s a valid RFC 5424 header field: printable ASCII without
+// spaces, at most n bytes, or "-" when empty.
+func headerField(s string, n int) string {
+	var b strings.Builder
+	for i := 0; i < len(s) && b.Len() < n; i++ {
+		if c := s[i]; c > 32 && c < 127 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// isAlert reports whether an action records something a security team would
+// want to look at, such as a refusal or a failure.
+func isAlert(action string) bool {
+	for _, w := range []string{"denied", "violation", "fail", "reject", "blocked", "unauthorized"} {
+		if strings.Contains(action, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func syslogSeverity(action string) int {
+	if isAlert(action) {
+		return 4 // warning
+	}
+	return 5 // notice
+}
+
+// CEF renders ev as an ArcSight Common Event Format record.
+func CEF(ev Event, version string) string {
+	if version == "" {
+		version = "dev"
+	}
+	sev := 3
+	if isAlert(ev.Action) {
+		sev = 7
+	}
+	ext := []string{
+		"rt=" + strconv.FormatInt(ev.Time.UnixMilli(), 10),
+		"act=" + cefExt.Replace(ev.Action),
+	}
+	if net.ParseIP(ev.IP) != nil {
+		ext = append(ext, "src="+ev.IP)
+	}
+	if ev.Host != "" {
+		ext = append(ext, "dvchost="+cefExt.Replace(ev.Host))
+	}
+	if ev.Identity != "" {
+		ext = append(ext, "suser="+cefExt.Replace(ev.Identity))
+	}
+	if ev.Source != "" {
+		ext = append(ext, "cs1Label=source", "cs1="+cefExt.Replace(ev.Source))
+	}
+	if ev.Detail != "" {
+		ext = append(ext, "msg="+cefExt.Replace(ev.Detail))
+	}
+	return fmt.Sprintf("CEF:0|Tetora|Tetora|%s|%s|%s|%d|%s",
+		cefHeader.Replace(version), cefHeader.Replace(ev.Action), cefHeader.Replace(ev.Action), sev,
+		strings.Join(ext, " "))
+}
+
+var (
+	cefHeader = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExt    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
@@ -0,0 +1,215 @@
+// Package simulate asks an agent what it would do with a prompt without
+// letting it do anything. The agent answers in plan-only mode with the tool
+// calls and other actions it intends, and each step is then checked against
+// the agent's tool policy and trust settings, so a new role or trust level
+// can be reviewed before it is given real work.
+package simulate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"tetora/internal/reflection"
+	"tetora/internal/trust"
+)
+
+// Tool is a tool the agent may plan to call.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+}
+
+// Outcome is what would happen to a planned step if the agent ran for real.
+type Outcome string
+
+const (
+	Run         Outcome = "run"          // executes without asking
+	Approval    Outcome = "approval"     // waits for the owner's approval
+	Observe     Outcome = "observe"      // logged but not executed (observe trust)
+	Denied      Outcome = "denied"       // refused by the agent's tool policy
+	OverBudget  Outcome = "over-budget"  // past the agent's tool-call budget
+	UnknownTool Outcome = "unknown-tool" // no such tool is registered
+)
+
+// Step is one thing the agent plans to do: a tool call, or another action
+// such as running a command or editing a file.
+type Step struct {
+	Tool    string          `json:"tool,omitempty"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Action  string          `json:"action,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+	Outcome Outcome         `json:"outcome"`
+	Note    string          `json:"note,omitempty"`
+}
+
+// Plan is the agent's answer in plan-only mode.
+type Plan struct {
+	Understanding string   `json:"understanding"`
+	Steps         []Step   `json:"steps"`
+	Response      string   `json:"response,omitempty"` // the reply it would send, assuming the steps succeed
+	Questions     []string `json:"questions,omitempty"`
+}
+
+// Result is a finished simulation. Plan is nil when the agent did not
+// answer with a plan; Output then holds its reply.
+type Result struct {
+	Agent      string          `json:"agent"`
+	TrustLevel string          `json:"trustLevel"`
+	Model      string          `json:"model,omitempty"`
+	Plan       *Plan           `json:"plan"`
+	Outcomes   map[Outcome]int `json:"outcomes"`
+	Output     string          `json:"output,omitempty"`
+	CostUSD    float64         `json:"costUsd"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// Policy decides the outcome of planned steps. Nil funcs are treated as
+// "yes" for Known and Allowed, and "auto" for Trust.
+type Policy struct {
+	AgentTrust string                 // the agent's trust level, for actions that are not tool calls
+	Known      func(tool string) bool // tool is registered
+	Allowed    func(tool string) bool // agent's tool policy permits it
+	Trust      func(tool string) string
+	Approval   func(tool string) bool // external action or approval gate
+	MaxCalls   int                    // tool-call budget for one task; 0 = none
+}
+
+// Prompt wraps a request in plan-only instructions listing the tools the
+// agent could call.
+func Prompt(request string, tools []Tool) string {
+	var b strings.Builder
+	b.WriteString(`You are in SIMULATION mode. Nothing you plan will be carried out: do not call any tools, edit files or run commands. Work out how you would handle the request below, exactly as you normally would, and describe it as a plan.
+
+Request:
+`)
+	b.WriteString(request)
+	b.WriteString("\n\n")
+	if len(tools) > 0 {
+		b.WriteString("Tools you could call (* = required argument):\n")
+		for _, t := range tools {
+			desc, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+			fmt.Fprintf(&b, "- %s(%s): %s\n", t.Name, strings.Join(params(t.InputSchema), ", "), desc)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(`Reply with ONLY one JSON object:
+{"understanding": "<what you are being asked, in one or two sentences>",
+ "steps": [
+  {"tool": "<tool name>", "input": {<arguments>}, "reason": "<why>"},
+  {"action": "<anything else you would do, such as running a command or editing a file>", "reason": "<why>"}
+ ],
+ "response": "<the reply you would send at the end, assuming every step succeeds>",
+ "questions": ["<anything you would ask before starting>"]}
+List the steps in the order you would take them.`)
+	return b.String()
+}
+
+// params lists the argument names of a JSON schema, required ones marked
+// with *.
+func params(schema json.RawMessage) []string {
+	var s struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if json.Unmarshal(schema, &s) != nil {
+		return nil
+	}
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	out := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		if required[name] {
+			name += "*"
+		}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ErrNoPlan is returned when the agent's reply holds no plan.
+var ErrNoPlan = errors.New("reply is not a plan")
+
+// Parse reads the plan out of the agent's reply.
+func Parse(output string) (*Plan, error) {
+	raw := reflection.ExtractJSON(output)
+	if raw == "" {
+		return nil, ErrNoPlan
+	}
+	var p Plan
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoPlan, err)
+	}
+	steps := p.Steps[:0]
+	for _, s := range p.Steps {
+		s.Tool = strings.TrimSpace(s.Tool)
+		s.Action = strings.TrimSpace(s.Action)
+		if s.Tool != "" || s.Action != "" {
+			steps = append(steps, s)
+		}
+	}
+	p.Steps = steps
+	if p.Steps == nil {
+		p.Steps = []Step{}
+	}
+	return &p, nil
+}
+
+// Review sets the outcome of every step, checking tool calls in the order
+// the task runner does: registration, tool policy, budget, trust level, then
+// approval. It returns how many steps have each outcome.
+func Review(p *Plan, pol Policy) map[Outcome]int {
+	counts := map[Outcome]int{}
+	calls := 0
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		s.Outcome, s.Note = pol.review(s, &calls)
+		counts[s.Outcome]++
+	}
+	return counts
+}
+
+func (pol Policy) review(s *Step, calls *int) (Outcome, string) {
+	if s.Tool == "" {
+		// Actions outside the tool registry are governed by the agent's
+		// trust level alone: observe runs read-only, suggest holds the
+		// output for approval.
+		switch pol.AgentTrust {
+		case trust.Observe:
+			return Observe, "observe trust runs read-only; side effects are not carried out"
+		case trust.Suggest:
+			return Approval, "suggest trust holds the result for approval"
+		}
+		return Run, ""
+	}
+	if pol.Known != nil && !pol.Known(s.Tool) {
+		return UnknownTool, fmt.Sprintf("no tool named %q", s.Tool)
+	}
+	if pol.Allowed != nil && !pol.Allowed(s.Tool) {
+		return Denied, "not allowed by the agent's tool policy"
+	}
+	if pol.MaxCalls > 0 && *calls >= pol.MaxCalls {
+		return OverBudget, fmt.Sprintf("tool-call budget of %d is spent", pol.MaxCalls)
+	}
+	*calls++
+	level := trust.Auto
+	if pol.Trust != nil {
+		level = pol.Trust(s.Tool)
+	}
+	switch level {
+	case trust.Observe:
+		return Observe, "trust level observe: the call is logged, not executed"
+	case trust.Suggest:
+		return Approval, "trust level suggest: the call needs approval"
+	}
+	if pol.Approval != nil && pol.Approval(s.Tool) {
+		return Approval, "approval gate: the owner confirms each call"
+	}
+	return Run, ""
+}
@@ -0,0 +1,93 @@
+package simulate
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrompt(t *testing.T) {
+	p := Prompt("Summarize my inbox", []Tool{{
+		Name:        "email_inbox",
+		Description: "List recent messages.\nMore detail.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"limit":{},"account":{}},"required":["account"]}`),
+	}})
+	if !strings.Contains(p, "Summarize my inbox") || !strings.Contains(p, "- email_inbox(account*, limit): List recent messages.\n") {
+		t.Errorf("prompt = %s", p)
+	}
+	if strings.Contains(p, "More detail") {
+		t.Error("only the first line of a description should be listed")
+	}
+	if strings.Contains(Prompt("x", nil), "Tools you could call") {
+		t.Error("no tool list without tools")
+	}
+}
+
+func TestParse(t *testing.T) {
+	out := "Here is my plan:\n```json\n" + `{"understanding": "send a summary",
+ "steps": [
+  {"tool": "email_inbox", "input": {"limit": 10}, "reason": "read mail"},
+  {"tool": " ", "reason": "nothing"},
+  {"action": "write notes.md", "reason": "keep a copy"}
+ ],
+ "response": "Done."}` + "\n```"
+	p, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Understanding != "send a summary" || p.Response != "Done." || len(p.Steps) != 2 {
+		t.Fatalf("plan = %+v", p)
+	}
+	if string(p.Steps[0].Input) != `{"limit": 10}` || p.Steps[1].Action != "write notes.md" {
+		t.Errorf("steps = %+v", p.Steps)
+	}
+
+	if _, err := Parse("I would check the inbox first."); !errors.Is(err, ErrNoPlan) {
+		t.Errorf("err = %v", err)
+	}
+	if p, _ := Parse(`{"understanding": "nothing to do"}`); p == nil || p.Steps == nil {
+		t.Error("a plan without steps should have an empty step list")
+	}
+}
+
+func TestReview(t *testing.T) {
+	p := &Plan{Steps: []Step{
+		{Tool: "ghost"},
+		{Tool: "exec"},
+		{Tool: "web_search"},
+		{Tool: "email_send"},
+		{Tool: "note_write"},
+		{Tool: "web_search"},
+		{Action: "edit config.json"},
+	}}
+	pol := Policy{
+		AgentTrust: "auto",
+		Known:      func(tool string) bool { return tool != "ghost" },
+		Allowed:    func(tool string) bool { return tool != "exec" },
+		Trust: func(tool string) string {
+			if tool == "note_write" {
+				return "observe"
+			}
+			return "auto"
+		},
+		Approval: func(tool string) bool { return tool == "email_send" },
+		MaxCalls: 3,
+	}
+	counts := Review(p, pol)
+	want := []Outcome{UnknownTool, Denied, Run, Approval, Observe, OverBudget, Run}
+	for i, s := range p.Steps {
+		if s.Outcome != want[i] {
+			t.Errorf("step %d (%s%s) = %s, want %s", i, s.Tool, s.Action, s.Outcome, want[i])
+		}
+	}
+	if counts[Run] != 2 || counts[OverBudget] != 1 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	pol.AgentTrust = "suggest"
+	Review(p, pol)
+	if p.Steps[6].Outcome != Approval || p.Steps[6].Note == "" {
+		t.Errorf("action under suggest = %+v", p.Steps[6])
+	}
+}
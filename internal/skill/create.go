@@ -54,6 +54,9 @@ type SkillStoreConfig struct {
 	AutoApprove bool `json:"autoApprove,omitempty"` // skip approval for agent-created skills
 	Sandbox     bool `json:"sandbox,omitempty"`     // default to sandbox execution for created skills
 	MaxSkills   int  `json:"maxSkills,omitempty"`   // max file-based skills (default 50)
+	// Indexes are skill index URLs or paths for "tetora skill install <name>";
+	// see market.go for the format.
+	Indexes []string `json:"indexes,omitempty"`
 }
 
 // maxSkillsOrDefault returns the configured max skills limit (default 50).
@@ -182,7 +185,8 @@ func LoadFileSkills(cfg *AppConfig) []SkillConfig {
 
 	var skills []SkillConfig
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// Hidden directories hold package installs in progress.
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 		skillDir := filepath.Join(dir, entry.Name())
@@ -528,7 +532,7 @@ func LoadAllFileSkillMetas(cfg *AppConfig) []SkillMetadata {
 
 	var metas []SkillMetadata
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 		metaPath := filepath.Join(dir, entry.Name(), "metadata.json")
@@ -587,8 +591,12 @@ func ApproveSkill(cfg *AppConfig, name string) error {
 
 	meta.Approved = true
 
-	// Make script executable.
-	scriptPath := filepath.Join(SkillsDir(cfg), name, scriptFilename(meta.Command))
+	// Make script executable. Packaged skills record their command's full path.
+	dir := filepath.Join(SkillsDir(cfg), name)
+	scriptPath := filepath.Join(dir, scriptFilename(meta.Command))
+	if filepath.IsAbs(meta.Command) && strings.HasPrefix(meta.Command, dir+string(filepath.Separator)) {
+		scriptPath = meta.Command
+	}
 	if err := os.Chmod(scriptPath, 0o755); err != nil {
 		logWarn("chmod script failed", "path", scriptPath, "error", err)
 	}
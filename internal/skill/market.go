@@ -0,0 +1,938 @@
+package skill
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Skill Marketplace: packages, indexes, install/upgrade/pin ---
+
+// Manifest is a skill package's skill.yaml. The package directory holds the
+// manifest next to the skill's assets (scripts, SKILL.md, data files).
+type Manifest struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Description  string   `json:"description,omitempty"`
+	Command      string   `json:"command,omitempty"` // relative paths resolve inside the package
+	Args         []string `json:"args,omitempty"`
+	Triggers     []string `json:"triggers,omitempty"`
+	AllowedTools []string `json:"allowedTools,omitempty"`
+	Test         string   `json:"test,omitempty"` // post-install check, run in the package dir
+}
+
+// IndexEntry is one release of a skill in an index. A release is an archive
+// (url) or a git checkout (git + ref).
+type IndexEntry struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	URL         string   `json:"url,omitempty"`  // .tar.gz or .zip, absolute or relative to the index
+	Git         string   `json:"git,omitempty"`  // repository to fetch instead of url
+	Ref         string   `json:"ref,omitempty"`  // git tag, branch or commit; default HEAD
+	Path        string   `json:"path,omitempty"` // package directory inside the archive or repository
+	// SHA256 covers the archive file, or for git the tree digest of the
+	// package directory, as printed by "tetora skill pack".
+	SHA256 string `json:"sha256"`
+}
+
+// Index is a static listing of skill releases, several versions per skill.
+type Index struct {
+	Skills []IndexEntry `json:"skills"`
+}
+
+// Installed is the record written into a skill directory installed from a
+// package.
+type Installed struct {
+	Release     IndexEntry `json:"release"`
+	Source      string     `json:"source"` // skill name (index), archive or git URL
+	Pinned      bool       `json:"pinned,omitempty"`
+	Risk        string     `json:"risk"`
+	Test        string     `json:"test"` // "passed", "skipped" (pending approval) or "none"
+	InstalledAt string     `json:"installedAt"`
+}
+
+// InstallOptions controls InstallPackage.
+type InstallOptions struct {
+	Source  string // recorded for upgrades; default the release name
+	Pinned  bool   // upgrades leave the skill alone
+	Approve bool   // approve a skill that scans as safe
+}
+
+// UpgradeResult reports what Upgrade did for one skill.
+type UpgradeResult struct {
+	Name   string `json:"name"`
+	From   string `json:"from"`
+	To     string `json:"to,omitempty"`
+	Status string `json:"status"` // "upgraded", "current", "pinned", "skipped", "error"
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	manifestFile  = "skill.yaml"
+	installedFile = "installed.json"
+	// maxPackageBytes bounds indexes and archives.
+	maxPackageBytes = 64 << 20
+	// testTimeout bounds the post-install test.
+	testTimeout = 60 * time.Second
+)
+
+// ParseManifest reads a skill.yaml. It accepts the flat subset used by
+// SKILL.md frontmatter: scalars, inline lists and "- item" lists. Unknown
+// keys are ignored so newer packages still install.
+func ParseManifest(data []byte) (*Manifest, error) {
+	kv := make(map[string]string)
+	lists := make(map[string][]string)
+	last := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") && last != "" {
+			lists[last] = append(lists[last], unquote(strings.TrimSpace(trimmed[2:])))
+			continue
+		}
+		key, val, ok := strings.Cut(trimmed, ":")
+		if !ok || line != strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("%s line %d: expected \"key: value\"", manifestFile, i+1)
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		last = ""
+		switch {
+		case val == "":
+			last = key
+			lists[key] = nil
+		case strings.HasPrefix(val, "["):
+			lists[key] = parseFrontmatterList(val)
+		default:
+			kv[key] = unquote(val)
+		}
+	}
+
+	m := &Manifest{
+		Name:         kv["name"],
+		Version:      kv["version"],
+		Description:  kv["description"],
+		Command:      kv["command"],
+		Args:         lists["args"],
+		Triggers:     lists["triggers"],
+		AllowedTools: lists["allowed-tools"],
+		Test:         kv["test"],
+	}
+	if m.Name == "" || m.Version == "" {
+		return nil, fmt.Errorf("%s must set name and version", manifestFile)
+	}
+	if !IsValidSkillName(m.Name) {
+		return nil, fmt.Errorf("invalid skill name %q in %s", m.Name, manifestFile)
+	}
+	if strings.ContainsAny(m.Version, `/\ `) || m.Version == "." || m.Version == ".." {
+		return nil, fmt.Errorf("invalid version %q in %s", m.Version, manifestFile)
+	}
+	return m, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// IsPackageSource reports whether source names a package directly (an archive
+// URL or path, or a git repository) rather than a skill in an index.
+func IsPackageSource(source string) bool {
+	return isGitSource(source) || strings.Contains(source, "://") ||
+		strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz") || strings.HasSuffix(source, ".zip")
+}
+
+// isGitSource matches "git+https://…", "git@host:…" and URLs ending in ".git"
+// (optionally followed by "#ref").
+func isGitSource(source string) bool {
+	repo, _, _ := strings.Cut(source, "#")
+	return strings.HasPrefix(source, "git+") || strings.HasPrefix(source, "git@") || strings.HasSuffix(repo, ".git")
+}
+
+// Resolve finds the release to install. source is an archive URL or path, a
+// git repository ("…/repo.git#ref"), or a skill name looked up in indexes.
+// version selects a release by name; empty means the newest. Direct sources
+// carry no checksum unless sum is given.
+func Resolve(ctx context.Context, indexes []string, source, version, sum string) (*IndexEntry, error) {
+	if isGitSource(source) {
+		repo, ref, _ := strings.Cut(strings.TrimPrefix(source, "git+"), "#")
+		return &IndexEntry{Git: repo, Ref: ref, SHA256: sum}, nil
+	}
+	if IsPackageSource(source) {
+		return &IndexEntry{URL: source, SHA256: sum}, nil
+	}
+
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("%q is not a package and no skillStore.indexes are configured", source)
+	}
+	var best *IndexEntry
+	for _, e := range loadIndexes(ctx, indexes) {
+		if e.Name != source || (version != "" && e.Version != version) {
+			continue
+		}
+		if best == nil || compareVersions(e.Version, best.Version) > 0 {
+			e := e
+			best = &e
+		}
+	}
+	if best == nil {
+		if version != "" {
+			return nil, fmt.Errorf("skill %s@%s not found in indexes", source, version)
+		}
+		return nil, fmt.Errorf("skill %q not found in indexes", source)
+	}
+	if best.SHA256 == "" {
+		return nil, fmt.Errorf("%s %s: index entry has no sha256", best.Name, best.Version)
+	}
+	return best, nil
+}
+
+// Search lists the newest release of every indexed skill whose name,
+// description or tags contain query (case-insensitive; empty matches all).
+func Search(ctx context.Context, indexes []string, query string) []IndexEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+	newest := make(map[string]IndexEntry)
+	for _, e := range loadIndexes(ctx, indexes) {
+		text := strings.ToLower(e.Name + " " + e.Description + " " + strings.Join(e.Tags, " "))
+		if query != "" && !strings.Contains(text, query) {
+			continue
+		}
+		if cur, ok := newest[e.Name]; !ok || compareVersions(e.Version, cur.Version) > 0 {
+			newest[e.Name] = e
+		}
+	}
+	out := make([]IndexEntry, 0, len(newest))
+	for _, e := range newest {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// loadIndexes reads every index, resolving relative URLs against it.
+// Unreadable indexes are logged and skipped.
+func loadIndexes(ctx context.Context, indexes []string) []IndexEntry {
+	var out []IndexEntry
+	for _, ref := range indexes {
+		data, base, err := fetch(ctx, ref)
+		if err != nil {
+			logWarn("skill index unavailable", "index", ref, "error", err)
+			continue
+		}
+		var idx Index
+		if err := json.Unmarshal(data, &idx); err != nil {
+			logWarn("skill index unreadable", "index", ref, "error", err)
+			continue
+		}
+		for _, e := range idx.Skills {
+			if e.URL != "" {
+				e.URL = resolveRef(base, e.URL)
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// InstallPackage fetches a release, verifies its checksum, scans it, runs its
+// test and moves it into the skills directory, replacing an earlier install
+// of the same skill. Nothing changes unless every step succeeds. The test
+// only runs for approved skills; pending ones run nothing until approved.
+func InstallPackage(ctx context.Context, cfg *AppConfig, rel *IndexEntry, opts InstallOptions) (*Installed, error) {
+	skillsDir := SkillsDir(cfg)
+	if err := os.MkdirAll(skillsDir, 0o755); err != nil {
+		return nil, err
+	}
+	// Staging happens in a hidden directory next to the skills so the final
+	// rename stays on one filesystem; LoadFileSkills skips hidden entries.
+	stage, err := os.MkdirTemp(skillsDir, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stage)
+
+	src := filepath.Join(stage, "src")
+	sum, err := fetchRelease(ctx, rel, src)
+	if err != nil {
+		return nil, err
+	}
+	pkgDir, err := packageDir(src, rel.Path)
+	if err != nil {
+		return nil, err
+	}
+	if rel.Git != "" {
+		if sum, err = TreeDigest(pkgDir); err != nil {
+			return nil, err
+		}
+	}
+	if rel.SHA256 != "" && !strings.EqualFold(sum, rel.SHA256) {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", rel.location(), sum, rel.SHA256)
+	}
+
+	data, err := os.ReadFile(filepath.Join(pkgDir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s: no %s in package", rel.location(), manifestFile)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	if (rel.Name != "" && rel.Name != m.Name) || (rel.Version != "" && rel.Version != m.Version) {
+		return nil, fmt.Errorf("index lists %s %s but the package is %s %s", rel.Name, rel.Version, m.Name, m.Version)
+	}
+	if m.Command == "" {
+		if _, err := os.Stat(filepath.Join(pkgDir, "SKILL.md")); err != nil {
+			return nil, fmt.Errorf("%s has neither a command nor a SKILL.md", m.Name)
+		}
+	}
+
+	dest := filepath.Join(skillsDir, m.Name)
+	var prev SkillMetadata
+	if _, err := os.Stat(dest); err == nil {
+		if _, err := readInstalled(dest); err != nil {
+			return nil, fmt.Errorf("skill %q already exists and was not installed from a package; delete it first", m.Name)
+		}
+		prev = readMetadata(dest)
+	} else if n := len(LoadFileSkills(cfg)); n >= cfg.SkillStore.maxSkillsOrDefault() {
+		return nil, fmt.Errorf("max skills limit reached (%d)", cfg.SkillStore.maxSkillsOrDefault())
+	}
+
+	report := scanPackage(m.Name, pkgDir)
+	if report.OverallRisk == "dangerous" {
+		notifySentoriResult(cfg, report)
+		return nil, fmt.Errorf("%s %s refused: scored as dangerous (score %d, %d findings)",
+			m.Name, m.Version, report.Score, len(report.Findings))
+	}
+	approved := report.OverallRisk == "safe" && (opts.Approve || cfg.SkillStore.AutoApprove || prev.Approved)
+
+	command := m.Command
+	if isRelativePath(command) {
+		// Point the command at its final location; chmod it in place now.
+		path := filepath.Join(pkgDir, filepath.FromSlash(command))
+		perm := os.FileMode(0o644)
+		if approved {
+			perm = 0o755
+		}
+		if err := os.Chmod(path, perm); err != nil {
+			return nil, fmt.Errorf("command %s: %w", m.Command, err)
+		}
+		command = filepath.Join(dest, filepath.FromSlash(command))
+	}
+
+	testStatus := "none"
+	switch {
+	case m.Test != "" && !approved:
+		testStatus = "skipped"
+	case m.Test != "":
+		if out, err := runPackageTest(ctx, pkgDir, m.Test); err != nil {
+			return nil, fmt.Errorf("%s %s failed its test (%s): %v\n%s", m.Name, m.Version, m.Test, err, strings.TrimSpace(out))
+		}
+		testStatus = "passed"
+	}
+
+	meta := SkillMetadata{
+		Name:         m.Name,
+		Description:  m.Description,
+		Command:      command,
+		Args:         m.Args,
+		AllowedTools: m.AllowedTools,
+		Approved:     approved,
+		CreatedBy:    "skill install",
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		UsageCount:   prev.UsageCount,
+		LastUsedAt:   prev.LastUsedAt,
+	}
+	if len(m.Triggers) > 0 {
+		meta.Matcher = &SkillMatcher{Keywords: m.Triggers}
+	}
+	source := opts.Source
+	if source == "" {
+		source = m.Name
+	}
+	release := *rel
+	release.Name, release.Version, release.SHA256 = m.Name, m.Version, sum
+	if release.Description == "" {
+		release.Description = m.Description
+	}
+	rec := &Installed{
+		Release:     release,
+		Source:      source,
+		Pinned:      opts.Pinned,
+		Risk:        report.OverallRisk,
+		Test:        testStatus,
+		InstalledAt: meta.CreatedAt,
+	}
+	for file, v := range map[string]any{"metadata.json": meta, installedFile: rec, "sentori-report.json": report} {
+		out, _ := json.MarshalIndent(v, "", "  ")
+		if err := os.WriteFile(filepath.Join(pkgDir, file), out, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	// Swap the new release in; the previous one is restored on failure.
+	old := filepath.Join(stage, "previous")
+	hadPrev := os.Rename(dest, old) == nil
+	if err := os.Rename(pkgDir, dest); err != nil {
+		if hadPrev {
+			os.Rename(old, dest)
+		}
+		return nil, err
+	}
+
+	invalidateSkillsCache(cfg)
+	notifySentoriResult(cfg, report)
+	logInfo("skill package installed", "name", m.Name, "version", m.Version, "risk", report.OverallRisk,
+		"approved", approved, "test", testStatus)
+	return rec, nil
+}
+
+// ListInstalled returns the install records of packaged skills by name.
+func ListInstalled(cfg *AppConfig) []Installed {
+	entries, err := os.ReadDir(SkillsDir(cfg))
+	if err != nil {
+		return nil
+	}
+	var out []Installed
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if rec, err := readInstalled(filepath.Join(SkillsDir(cfg), e.Name())); err == nil {
+			out = append(out, *rec)
+		}
+	}
+	return out
+}
+
+// SetPinned pins or unpins an installed skill at its current version.
+func SetPinned(cfg *AppConfig, name string, pinned bool) error {
+	if !IsValidSkillName(name) {
+		return fmt.Errorf("invalid skill name %q", name)
+	}
+	dir := filepath.Join(SkillsDir(cfg), name)
+	rec, err := readInstalled(dir)
+	if err != nil {
+		return fmt.Errorf("skill %q was not installed from a package", name)
+	}
+	rec.Pinned = pinned
+	out, _ := json.MarshalIndent(rec, "", "  ")
+	return os.WriteFile(filepath.Join(dir, installedFile), out, 0o644)
+}
+
+// Upgrade moves installed skills to the newest indexed release. With a name
+// only that skill is considered, and with a version it moves there even if
+// pinned (and stays pinned). Skills installed from an archive or git URL are
+// skipped: reinstall them to change version.
+func Upgrade(ctx context.Context, cfg *AppConfig, name, version string) []UpgradeResult {
+	var results []UpgradeResult
+	for _, rec := range ListInstalled(cfg) {
+		if name != "" && rec.Release.Name != name {
+			continue
+		}
+		r := UpgradeResult{Name: rec.Release.Name, From: rec.Release.Version}
+		switch {
+		case rec.Source != rec.Release.Name:
+			r.Status, r.Error = "skipped", "installed from "+rec.Source
+		case rec.Pinned && version == "":
+			r.Status = "pinned"
+		default:
+			rel, err := Resolve(ctx, cfg.SkillStore.Indexes, rec.Source, version, "")
+			switch {
+			case err != nil:
+				r.Status, r.Error = "error", err.Error()
+			case rel.Version == rec.Release.Version && rel.SHA256 == rec.Release.SHA256:
+				r.Status = "current"
+			case version == "" && compareVersions(rel.Version, rec.Release.Version) < 0:
+				r.Status, r.Error = "skipped", fmt.Sprintf("installed %s is newer than %s", rec.Release.Version, rel.Version)
+			default:
+				r.To = rel.Version
+				if _, err := InstallPackage(ctx, cfg, rel, InstallOptions{Source: rec.Source, Pinned: version != "" || rec.Pinned}); err != nil {
+					r.Status, r.Error = "error", err.Error()
+				} else {
+					r.Status = "upgraded"
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	if name != "" && len(results) == 0 {
+		results = append(results, UpgradeResult{Name: name, Status: "error", Error: "not installed from a package"})
+	}
+	return results
+}
+
+// Pack writes dir as <name>-<version>.tar.gz into outDir and returns the
+// index entry for it, with the archive's sha256. treeDigest is what a git
+// entry of the same directory would list as sha256.
+func Pack(dir, outDir string) (entry *IndexEntry, archive, treeDigest string, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("no %s in %s", manifestFile, dir)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, "", "", err
+	}
+	files, err := packageFiles(dir)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", "", err
+		}
+		// Fixed mtimes keep the archive (and its checksum) reproducible.
+		hdr := &tar.Header{Name: rel, Mode: int64(info.Mode().Perm()), Size: int64(len(content)), Typeflag: tar.TypeReg, ModTime: time.Unix(0, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, "", "", err
+		}
+		tw.Write(content)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	gz.Close()
+
+	archive = filepath.Join(outDir, m.Name+"-"+m.Version+".tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		return nil, "", "", err
+	}
+	digest, err := TreeDigest(dir)
+	if err != nil {
+		return nil, "", "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &IndexEntry{
+		Name:        m.Name,
+		Version:     m.Version,
+		Description: m.Description,
+		URL:         filepath.Base(archive),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}, archive, digest, nil
+}
+
+// TreeDigest hashes the files of a package directory (paths and contents,
+// .git excluded). It is the checksum of git releases.
+func TreeDigest(dir string) (string, error) {
+	files, err := packageFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, rel := range files {
+		sum, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s  %s\n", sum, rel)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packageFiles lists the regular files under dir as sorted slash paths.
+func packageFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func (e *IndexEntry) location() string {
+	if e.Git != "" {
+		if e.Ref != "" {
+			return e.Git + "#" + e.Ref
+		}
+		return e.Git
+	}
+	return e.URL
+}
+
+// fetchRelease puts the release's files in dir and returns the archive's
+// checksum. Git releases are checksummed later, by their package directory.
+func fetchRelease(ctx context.Context, rel *IndexEntry, dir string) (string, error) {
+	if rel.Git != "" {
+		return "", gitCheckout(ctx, rel.Git, rel.Ref, dir)
+	}
+	data, _, err := fetch(ctx, rel.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := unpack(data, dir); err != nil {
+		return "", fmt.Errorf("unpack %s: %w", rel.URL, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// gitCheckout fetches one ref of repo, without history, into dir. Fetching
+// the ref directly works for tags, branches and (on most hosts) commits.
+func gitCheckout(ctx context.Context, repo, ref, dir string) error {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"fetch", "-q", "--depth", "1", repo, ref},
+		{"checkout", "-q", "FETCH_HEAD"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return os.RemoveAll(filepath.Join(dir, ".git"))
+}
+
+// packageDir locates skill.yaml: at sub, at the root, or inside a single
+// top-level directory as in release archives generated by git hosts.
+func packageDir(root, sub string) (string, error) {
+	if sub != "" {
+		dir, err := safeJoin(root, sub)
+		if err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+	if _, err := os.Stat(filepath.Join(root, manifestFile)); err == nil {
+		return root, nil
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(root, entries[0].Name()), nil
+	}
+	return root, nil
+}
+
+// scanPackage runs the Sentori scan over every non-Markdown file of a package
+// and merges the findings into one report.
+func scanPackage(name, dir string) *SentoriReport {
+	merged := &SentoriReport{SkillName: name, ScannedAt: time.Now().UTC().Format(time.RFC3339), Findings: []SentoriFinding{}}
+	files, _ := packageFiles(dir)
+	for _, rel := range files {
+		if strings.HasSuffix(rel, ".md") || rel == manifestFile {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			continue // binary files are not scanned
+		}
+		for _, f := range SentoriScan(name, string(data)).Findings {
+			f.Description = rel + ": " + f.Description
+			merged.Findings = append(merged.Findings, f)
+		}
+	}
+	for _, f := range merged.Findings {
+		merged.Score += severityScore(f.Severity)
+	}
+	merged.Score = min(merged.Score, 100)
+	switch {
+	case merged.Score <= 20:
+		merged.OverallRisk = "safe"
+	case merged.Score <= 50:
+		merged.OverallRisk = "review"
+	default:
+		merged.OverallRisk = "dangerous"
+	}
+	return merged
+}
+
+// runPackageTest runs a package's test command in dir.
+func runPackageTest(ctx context.Context, dir, test string) (string, error) {
+	fields := strings.Fields(test)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	if isRelativePath(fields[0]) {
+		fields[0] = filepath.Join(dir, filepath.FromSlash(fields[0]))
+		if info, err := os.Stat(fields[0]); err == nil {
+			os.Chmod(fields[0], info.Mode()|0o755)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, testTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", testTimeout)
+	}
+	return string(out), err
+}
+
+// isRelativePath reports whether a command names a file in the package
+// ("./run.sh", "bin/tool") rather than a program on PATH.
+func isRelativePath(command string) bool {
+	return command != "" && !filepath.IsAbs(command) && strings.ContainsAny(command, `/\`)
+}
+
+func readInstalled(dir string) (*Installed, error) {
+	data, err := os.ReadFile(filepath.Join(dir, installedFile))
+	if err != nil {
+		return nil, err
+	}
+	var rec Installed
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func readMetadata(dir string) SkillMetadata {
+	var meta SkillMetadata
+	if data, err := os.ReadFile(filepath.Join(dir, "metadata.json")); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// compareVersions compares dotted versions numerically ("1.10.0" > "1.9.2").
+// A leading "v" is ignored; non-numeric parts compare as strings.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var sa, sb string
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+		na, errA := strconv.Atoi(sa)
+		nb, errB := strconv.Atoi(sb)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case sa != sb:
+			return strings.Compare(sa, sb)
+		}
+	}
+	return 0
+}
+
+// --- Download and unpack ---
+
+// fetch reads an http(s) URL, file:// URL or local path. base is returned
+// for resolving relative references found in the content.
+func fetch(ctx context.Context, ref string) ([]byte, string, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("download %s: %w", ref, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("download %s: HTTP %d", ref, resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxPackageBytes+1))
+		if err != nil {
+			return nil, "", fmt.Errorf("download %s: %w", ref, err)
+		}
+		if len(data) > maxPackageBytes {
+			return nil, "", fmt.Errorf("download %s: larger than %d MB", ref, maxPackageBytes>>20)
+		}
+		return data, ref, nil
+	}
+
+	path := strings.TrimPrefix(ref, "file://")
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	abs, _ := filepath.Abs(path)
+	return data, abs, nil
+}
+
+// resolveRef resolves ref against base, a URL or an absolute file path.
+func resolveRef(base, ref string) string {
+	if ref == "" || strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+		return ref
+	}
+	if strings.Contains(base, "://") {
+		if b, err := url.Parse(base); err == nil {
+			if r, err := url.Parse(ref); err == nil {
+				return b.ResolveReference(r).String()
+			}
+		}
+		return ref
+	}
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref))
+}
+
+// unpack extracts a .tar.gz or .zip archive into dir.
+func unpack(data []byte, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return untarGz(data, dir)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unzip(data, dir)
+	}
+	return fmt.Errorf("not a .tar.gz or .zip archive")
+}
+
+// safeJoin joins an archive entry name to dir, rejecting paths that escape it.
+func safeJoin(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes the package directory", name)
+	}
+	return path, nil
+}
+
+func untarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := safeJoin(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeEntry(path, tr, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		}
+		// Links and special files are skipped.
+	}
+}
+
+func unzip(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		path, err := safeJoin(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(path, rc, f.Mode())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEntry(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.LimitReader(r, maxPackageBytes)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,256 @@
+package skill
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(`# weather skill
+name: weather
+version: "1.2.0"
+description: Forecasts from api.weather.gov
+command: ./run.sh
+args: [--city, "{{city}}"]
+triggers:
+  - weather
+  - forecast
+homepage: https://example.com
+test: ./test.sh --quick
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Manifest{
+		Name: "weather", Version: "1.2.0", Description: "Forecasts from api.weather.gov",
+		Command: "./run.sh", Args: []string{"--city", "{{city}}"}, Triggers: []string{"weather", "forecast"},
+		Test: "./test.sh --quick",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	for content, msg := range map[string]string{
+		"name: weather\n":                 "must set name and version",
+		"name: ../x\nversion: 1\n":        "invalid skill name",
+		"name: x\nversion: 1\n  bad: y\n": "line 3",
+	} {
+		if _, err := ParseManifest([]byte(content)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: err = %v, want %q", content, err, msg)
+		}
+	}
+}
+
+// skillArchive builds a .tar.gz of a package under a top-level directory,
+// the way git hosts generate release archives.
+func skillArchive(t *testing.T, version, test string) []byte {
+	t.Helper()
+	files := map[string]string{
+		"skill.yaml": "name: weather\nversion: " + version + "\ncommand: ./run.sh\ntest: " + test + "\ntriggers: [weather]\n",
+		"run.sh":     "#!/bin/sh\necho sunny\n",
+		"test.sh":    "#!/bin/sh\n[ \"$(./run.sh)\" = sunny ]\n",
+		"fail.sh":    "#!/bin/sh\necho broken; exit 1\n",
+		"SKILL.md":   "# Weather\n",
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: "weather-" + version + "/" + name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sum(data []byte) string {
+	s := sha256.Sum256(data)
+	return hex.EncodeToString(s[:])
+}
+
+func TestInstallPackage_IndexUpgradePin(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	archives := map[string][]byte{
+		"/weather-1.0.0.tar.gz": skillArchive(t, "1.0.0", "./test.sh"),
+		"/weather-1.1.0.tar.gz": skillArchive(t, "1.1.0", "./test.sh"),
+		"/weather-1.2.0.tar.gz": skillArchive(t, "1.2.0", "./fail.sh"),
+	}
+	idx := Index{Skills: []IndexEntry{
+		{Name: "weather", Version: "1.0.0", Tags: []string{"forecast"}, URL: "weather-1.0.0.tar.gz", SHA256: sum(archives["/weather-1.0.0.tar.gz"])},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.json" {
+			json.NewEncoder(w).Encode(idx)
+			return
+		}
+		w.Write(archives[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	cfg := &AppConfig{BaseDir: t.TempDir(), SkillStore: SkillStoreConfig{Indexes: []string{srv.URL + "/index.json"}}}
+	ctx := context.Background()
+
+	if res := Search(ctx, cfg.SkillStore.Indexes, "FORECAST"); len(res) != 1 || res[0].Name != "weather" {
+		t.Fatalf("search = %+v", res)
+	}
+	rel, err := Resolve(ctx, cfg.SkillStore.Indexes, "weather", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := InstallPackage(ctx, cfg, rel, InstallOptions{Approve: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Release.Version != "1.0.0" || rec.Test != "passed" || rec.Risk != "safe" || rec.Source != "weather" {
+		t.Errorf("installed = %+v", rec)
+	}
+	s := GetSkill(cfg, "weather")
+	if s == nil || s.Command != filepath.Join(SkillsDir(cfg), "weather", "run.sh") || s.DocPath == "" {
+		t.Fatalf("skill = %+v", s)
+	}
+	if res, err := ExecuteSkill(ctx, *s, nil); err != nil || strings.TrimSpace(res.Output) != "sunny" {
+		t.Errorf("run = %+v, %v", res, err)
+	}
+
+	// A newer release is picked up by upgrade; the approval carries over.
+	idx.Skills = append(idx.Skills, IndexEntry{Name: "weather", Version: "1.1.0", URL: "weather-1.1.0.tar.gz", SHA256: sum(archives["/weather-1.1.0.tar.gz"])})
+	if res := Upgrade(ctx, cfg, "", ""); len(res) != 1 || res[0].Status != "upgraded" || res[0].To != "1.1.0" {
+		t.Fatalf("upgrade = %+v", res)
+	}
+	if s := GetSkill(cfg, "weather"); s == nil {
+		t.Error("upgraded skill lost its approval")
+	}
+	if res := Upgrade(ctx, cfg, "weather", ""); res[0].Status != "current" {
+		t.Errorf("second upgrade = %+v", res)
+	}
+
+	// A release failing its test leaves the installed one in place.
+	idx.Skills = append(idx.Skills, IndexEntry{Name: "weather", Version: "1.2.0", URL: "weather-1.2.0.tar.gz", SHA256: sum(archives["/weather-1.2.0.tar.gz"])})
+	if res := Upgrade(ctx, cfg, "", ""); res[0].Status != "error" || !strings.Contains(res[0].Error, "failed its test") {
+		t.Errorf("failing upgrade = %+v", res)
+	}
+	if recs := ListInstalled(cfg); len(recs) != 1 || recs[0].Release.Version != "1.1.0" {
+		t.Errorf("installed after failed upgrade = %+v", recs)
+	}
+
+	// Pinned skills only move when given a version.
+	if err := SetPinned(cfg, "weather", true); err != nil {
+		t.Fatal(err)
+	}
+	if res := Upgrade(ctx, cfg, "", ""); res[0].Status != "pinned" {
+		t.Errorf("pinned upgrade = %+v", res)
+	}
+	if res := Upgrade(ctx, cfg, "weather", "1.0.0"); res[0].Status != "upgraded" {
+		t.Errorf("upgrade to version = %+v", res)
+	}
+	if recs := ListInstalled(cfg); recs[0].Release.Version != "1.0.0" || !recs[0].Pinned {
+		t.Errorf("after move = %+v", recs)
+	}
+
+	entries, _ := os.ReadDir(SkillsDir(cfg))
+	if len(entries) != 1 {
+		t.Errorf("skills dir has leftovers: %v", entries)
+	}
+}
+
+func TestInstallPackage_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &AppConfig{BaseDir: dir}
+	ctx := context.Background()
+	archive := filepath.Join(dir, "weather.tar.gz")
+	os.WriteFile(archive, skillArchive(t, "1.0.0", "./test.sh"), 0o644)
+
+	rel, _ := Resolve(ctx, nil, archive, "", strings.Repeat("0", 64))
+	if _, err := InstallPackage(ctx, cfg, rel, InstallOptions{}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("bad checksum: %v", err)
+	}
+	if _, err := Resolve(ctx, nil, "weather", "", ""); err == nil {
+		t.Error("name resolved without indexes")
+	}
+
+	// Without approval nothing runs: the test is skipped and the skill waits.
+	rel, _ = Resolve(ctx, nil, archive, "", "")
+	rec, err := InstallPackage(ctx, cfg, rel, InstallOptions{Source: archive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Test != "skipped" || GetSkill(cfg, "weather") != nil || len(ListPendingSkills(cfg)) != 1 {
+		t.Errorf("unapproved install = %+v", rec)
+	}
+	if res := Upgrade(ctx, cfg, "", ""); res[0].Status != "skipped" {
+		t.Errorf("upgrade of URL install = %+v", res)
+	}
+
+	// A hand-made skill of the same name is not replaced.
+	os.MkdirAll(filepath.Join(SkillsDir(cfg), "other"), 0o755)
+	other := filepath.Join(dir, "other")
+	os.MkdirAll(other, 0o755)
+	os.WriteFile(filepath.Join(other, "skill.yaml"), []byte("name: other\nversion: 1\n"), 0o644)
+	os.WriteFile(filepath.Join(other, "SKILL.md"), []byte("# Other\n"), 0o644)
+	_, archivePath, _, err := Pack(other, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, _ = Resolve(ctx, nil, archivePath, "", "")
+	if _, err := InstallPackage(ctx, cfg, rel, InstallOptions{}); err == nil || !strings.Contains(err.Error(), "not installed from a package") {
+		t.Errorf("overwrite: %v", err)
+	}
+}
+
+func TestInstallPackage_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "weather.git")
+	os.MkdirAll(filepath.Join(repo, "skills", "weather"), 0o755)
+	os.WriteFile(filepath.Join(repo, "skills", "weather", "skill.yaml"), []byte("name: weather\nversion: 2.0.0\n"), 0o644)
+	os.WriteFile(filepath.Join(repo, "skills", "weather", "SKILL.md"), []byte("# Weather\n"), 0o644)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "-m", "v2"},
+		{"tag", "v2.0.0"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	digest, err := TreeDigest(filepath.Join(repo, "skills", "weather"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &AppConfig{BaseDir: dir}
+	ctx := context.Background()
+	rel, _ := Resolve(ctx, nil, repo+"#v2.0.0", "", digest)
+	if _, err := InstallPackage(ctx, cfg, rel, InstallOptions{Approve: true}); err == nil {
+		t.Error("installed without the package path")
+	}
+	rel.Path = "skills/weather"
+	rec, err := InstallPackage(ctx, cfg, rel, InstallOptions{Approve: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Release.Git != repo || rec.Release.Ref != "v2.0.0" || rec.Test != "none" || GetSkill(cfg, "weather") == nil {
+		t.Errorf("git install = %+v", rec)
+	}
+}
@@ -0,0 +1,212 @@
+// Package sshexec runs allowlisted commands on configured remote hosts for
+// the ssh_exec tool.
+//
+// Commands go through the system ssh client with key authentication only
+// (BatchMode, so it never prompts) and strict host key checking: a host must
+// already be in known_hosts. The command line is checked against the host's
+// allowlist and may not contain shell metacharacters, so an allowed prefix
+// cannot be extended into a second command. Each run's output is written to
+// an artifact log under the outputs directory.
+package sshexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+)
+
+// maxOutput caps how much of each stream is kept.
+const maxOutput = 1 << 20
+
+// sshBin is the ssh client; tests replace it.
+var sshBin = "ssh"
+
+// Result is the outcome of one remote command.
+type Result struct {
+	Host       string `json:"host"`
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Artifact   string `json:"artifact,omitempty"`
+}
+
+// Check returns the named host if it exists and allows command.
+func Check(cfg config.SSHConfig, host, command string) (config.SSHHostConfig, error) {
+	h, ok := cfg.Hosts[host]
+	if !ok {
+		return h, fmt.Errorf("unknown ssh host %q", host)
+	}
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return h, errors.New("command is required")
+	}
+	if strings.ContainsAny(command, ";&|`$<>()\\\n\r\x00") {
+		return h, errors.New("command may not contain shell metacharacters")
+	}
+	if !Allowed(h, command) {
+		return h, fmt.Errorf("command not in the allowlist for ssh host %q", host)
+	}
+	return h, nil
+}
+
+// Allowed reports whether command matches one of the host's allow entries:
+// the exact command line, or a prefix ending in "*".
+func Allowed(h config.SSHHostConfig, command string) bool {
+	for _, pattern := range h.Allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(command, prefix) {
+				return true
+			}
+		} else if pattern == command {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentAllowed reports whether agent may use the host.
+func AgentAllowed(h config.SSHHostConfig, agent string) bool {
+	return len(h.Agents) == 0 || slices.Contains(h.Agents, agent)
+}
+
+// Target renders the host as user@host:port for messages.
+func Target(h config.SSHHostConfig) string {
+	return fmt.Sprintf("%s@%s:%d", h.User, h.Host, h.PortOrDefault())
+}
+
+// Args returns the ssh client arguments for running command on h.
+func Args(h config.SSHHostConfig, command string) []string {
+	args := []string{
+		"-i", expandHome(h.KeyFile),
+		"-p", strconv.Itoa(h.PortOrDefault()),
+		"-l", h.User,
+		"-o", "BatchMode=yes",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "ConnectTimeout=10",
+	}
+	if h.KnownHosts != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+expandHome(h.KnownHosts))
+	}
+	return append(args, "--", h.Host, command)
+}
+
+// Run executes command on the named host after Check, and writes the output
+// to an artifact log. A non-zero exit is reported in the Result, not as an
+// error; errors mean the command could not be run (not allowed, no
+// connection, timed out).
+func Run(ctx context.Context, cfg *config.Config, host, command string) (*Result, error) {
+	command = strings.TrimSpace(command)
+	h, err := Check(cfg.SSH, host, command)
+	if err != nil {
+		return nil, err
+	}
+	if h.Host == "" || h.User == "" || h.KeyFile == "" || strings.HasPrefix(h.Host, "-") {
+		return nil, fmt.Errorf("ssh host %q needs host, user and keyFile", host)
+	}
+
+	timeout := cfg.SSH.TimeoutOrDefault(h)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, sshBin, Args(h, command)...)
+	var stdout, stderr capBuffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	runErr := cmd.Run()
+	res := &Result{
+		Host:       host,
+		Command:    command,
+		DurationMs: time.Since(start).Milliseconds(),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Truncated:  stdout.truncated || stderr.truncated,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		res.ExitCode = -1
+		err = fmt.Errorf("command on %s timed out after %s", host, timeout)
+	case errors.As(runErr, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+		if res.ExitCode == 255 {
+			// ssh itself exits 255 on connection, auth and host key failures.
+			err = fmt.Errorf("ssh to %s failed: %s", host, strings.TrimSpace(res.Stderr))
+		}
+	case runErr != nil:
+		return nil, fmt.Errorf("run ssh: %w", runErr)
+	}
+
+	if path, werr := writeArtifact(cfg, h, res, start); werr == nil {
+		res.Artifact = path
+	} else if err == nil {
+		err = fmt.Errorf("write artifact: %w", werr)
+	}
+	return res, err
+}
+
+// writeArtifact saves the run to outputs/ssh/<host>/<time>.log.
+func writeArtifact(cfg *config.Config, h config.SSHHostConfig, res *Result, start time.Time) (string, error) {
+	outDir := cfg.Device.OutputDir
+	if outDir == "" {
+		outDir = filepath.Join(cfg.BaseDir, "outputs")
+	}
+	dir := filepath.Join(outDir, "ssh", filepath.Base(res.Host))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "host: %s (%s)\ncommand: %s\nstarted: %s\nduration: %dms\nexit: %d\n",
+		res.Host, Target(h), res.Command, start.UTC().Format(time.RFC3339), res.DurationMs, res.ExitCode)
+	if res.Truncated {
+		fmt.Fprintf(&b, "truncated: output over %d bytes\n", maxOutput)
+	}
+	fmt.Fprintf(&b, "\n--- stdout ---\n%s\n--- stderr ---\n%s", res.Stdout, res.Stderr)
+
+	path := filepath.Join(dir, start.Format("20060102_150405.000000000")+".log")
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// capBuffer keeps the first maxOutput bytes written to it.
+type capBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - c.Len(); room < len(p) {
+		c.truncated = true
+		if room > 0 {
+			c.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
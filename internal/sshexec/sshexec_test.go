@@ -0,0 +1,109 @@
+package sshexec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+func TestCheck(t *testing.T) {
+	cfg := config.SSHConfig{Hosts: map[string]config.SSHHostConfig{
+		"nas": {Allow: []string{"uptime", "df *", "docker ps*"}},
+	}}
+	for cmd, want := range map[string]string{
+		"uptime":               "",
+		"df -h /volume1":       "",
+		"docker ps -a":         "",
+		"uptime -p":            "not in the allowlist",
+		"df":                   "not in the allowlist",
+		"df -h; rm -rf /":      "metacharacters",
+		"df -h && reboot":      "metacharacters",
+		"df $(reboot)":         "metacharacters",
+		"docker ps\nreboot":    "metacharacters",
+		"df -h > /etc/passwd":  "metacharacters",
+		"  ":                   "required",
+		"docker ps | sh":       "metacharacters",
+		"df `reboot`":          "metacharacters",
+		"df -h /volume1 \\; x": "metacharacters",
+	} {
+		_, err := Check(cfg, "nas", cmd)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("Check(%q) = %v, want %q", cmd, err, want)
+		}
+	}
+	if _, err := Check(cfg, "pi", "uptime"); err == nil {
+		t.Error("unknown host passed")
+	}
+	if !AgentAllowed(config.SSHHostConfig{}, "ruri") || AgentAllowed(config.SSHHostConfig{Agents: []string{"kokuyou"}}, "ruri") {
+		t.Error("AgentAllowed")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	got := Args(config.SSHHostConfig{Host: "nas.local", User: "ops", KeyFile: "/keys/nas", KnownHosts: "/keys/known"}, "df -h")
+	want := []string{
+		"-i", "/keys/nas", "-p", "22", "-l", "ops",
+		"-o", "BatchMode=yes", "-o", "IdentitiesOnly=yes", "-o", "StrictHostKeyChecking=yes", "-o", "ConnectTimeout=10",
+		"-o", "UserKnownHostsFile=/keys/known", "--", "nas.local", "df -h",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %q", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	// The fake client echoes the remote command and fails the way ssh does.
+	fake := filepath.Join(dir, "ssh")
+	os.WriteFile(fake, []byte(`#!/bin/sh
+for a; do last=$a; done
+case "$last" in
+uptime) echo "up 3 days" ;;
+"df -h") echo "disk full" >&2; exit 1 ;;
+"df -x") echo "Host key verification failed." >&2; exit 255 ;;
+"df -s") sleep 5 ;;
+esac
+`), 0o755)
+	old := sshBin
+	sshBin = fake
+	defer func() { sshBin = old }()
+
+	cfg := &config.Config{BaseDir: dir, SSH: config.SSHConfig{Hosts: map[string]config.SSHHostConfig{
+		"nas": {Host: "nas.local", User: "ops", KeyFile: "/keys/nas", Allow: []string{"uptime", "df *"}, Timeout: "200ms"},
+	}}}
+	ctx := context.Background()
+
+	res, err := Run(ctx, cfg, "nas", "uptime")
+	if err != nil || res.ExitCode != 0 || res.Stdout != "up 3 days\n" {
+		t.Fatalf("run = %+v, %v", res, err)
+	}
+	if filepath.Dir(res.Artifact) != filepath.Join(dir, "outputs", "ssh", "nas") {
+		t.Errorf("artifact = %s", res.Artifact)
+	}
+	if data, _ := os.ReadFile(res.Artifact); !strings.Contains(string(data), "command: uptime\n") || !strings.Contains(string(data), "up 3 days") {
+		t.Errorf("artifact content = %q", data)
+	}
+
+	// A failing command is a result; ssh's own failures are errors.
+	if res, err := Run(ctx, cfg, "nas", "df -h"); err != nil || res.ExitCode != 1 || res.Stderr != "disk full\n" {
+		t.Errorf("failing command = %+v, %v", res, err)
+	}
+	if _, err := Run(ctx, cfg, "nas", "df -x"); err == nil || !strings.Contains(err.Error(), "Host key verification failed") {
+		t.Errorf("connection failure = %v", err)
+	}
+	if res, err := Run(ctx, cfg, "nas", "df -s"); err == nil || res.ExitCode != -1 || res.Artifact == "" {
+		t.Errorf("timeout = %+v, %v", res, err)
+	}
+	if _, err := Run(ctx, cfg, "nas", "reboot"); err == nil {
+		t.Error("unlisted command ran")
+	}
+}
@@ -0,0 +1,352 @@
+package statesync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tetora/internal/db"
+)
+
+// datasetNames lists the datasets that can be synced.
+var datasetNames = []string{"memory", "profile", "contacts", "habits"}
+
+// --- Memory ---
+
+// memoryEntry is a row of the sync_memory index.
+type memoryEntry struct {
+	hash    string
+	updated string
+	deleted bool
+}
+
+func (s *Service) memoryIndex() (map[string]memoryEntry, error) {
+	rows, err := db.Query(s.dbPath, `SELECT key, hash, updated_at, deleted FROM sync_memory`)
+	if err != nil {
+		return nil, err
+	}
+	idx := make(map[string]memoryEntry, len(rows))
+	for _, r := range rows {
+		idx[db.Str(r["key"])] = memoryEntry{hash: db.Str(r["hash"]), updated: db.Str(r["updated_at"]), deleted: db.Int(r["deleted"]) == 1}
+	}
+	return idx, nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func upsertMemorySQL(key string, e memoryEntry) string {
+	del := 0
+	if e.deleted {
+		del = 1
+	}
+	return fmt.Sprintf(`INSERT OR REPLACE INTO sync_memory (key, hash, updated_at, deleted) VALUES ('%s', '%s', '%s', %d);`,
+		db.Escape(key), db.Escape(e.hash), db.Escape(e.updated), del)
+}
+
+// scanMemory brings the index up to date with the memory directory. A changed
+// file takes its modification time (or now, if that is not after the indexed
+// time); a file that disappeared becomes a tombstone at now.
+func (s *Service) scanMemory(now time.Time) error {
+	if !s.enabled("memory") {
+		return nil
+	}
+	idx, err := s.memoryIndex()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.memoryDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	nowTS := now.UTC().Format(time.RFC3339)
+	seen := make(map[string]bool, len(entries))
+	var stmts []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".md") {
+			continue
+		}
+		key := strings.TrimSuffix(name, ".md")
+		seen[key] = true
+		data, err := os.ReadFile(filepath.Join(s.memoryDir, name))
+		if err != nil {
+			continue
+		}
+		hash := hashBytes(data)
+		old, ok := idx[key]
+		if ok && !old.deleted && old.hash == hash {
+			continue
+		}
+		updated := nowTS
+		if info, err := e.Info(); err == nil {
+			mod := info.ModTime().UTC().Format(time.RFC3339)
+			if !ok || newer(mod, old.updated) {
+				updated = mod
+			}
+		}
+		stmts = append(stmts, upsertMemorySQL(key, memoryEntry{hash: hash, updated: updated}))
+	}
+	for key, old := range idx {
+		if !old.deleted && !seen[key] {
+			stmts = append(stmts, upsertMemorySQL(key, memoryEntry{updated: nowTS, deleted: true}))
+		}
+	}
+	if len(stmts) == 0 {
+		return nil
+	}
+	return db.Exec(s.dbPath, "BEGIN;\n"+strings.Join(stmts, "\n")+"\nCOMMIT;")
+}
+
+func (s *Service) collectMemory(since string) ([]Record, error) {
+	q := `SELECT key, updated_at, deleted FROM sync_memory`
+	var args []any
+	if since != "" {
+		q += ` WHERE updated_at >= ?`
+		args = append(args, since)
+	}
+	rows, err := db.QueryArgs(s.dbPath, q+` ORDER BY updated_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for _, r := range rows {
+		rec := Record{Dataset: "memory", Key: db.Str(r["key"]), Updated: db.Str(r["updated_at"]), Deleted: db.Int(r["deleted"]) == 1}
+		if !rec.Deleted {
+			data, err := os.ReadFile(filepath.Join(s.memoryDir, rec.Key+".md"))
+			if err != nil {
+				continue // removed since the scan; the next scan records it
+			}
+			rec.Data, _ = json.Marshal(string(data))
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// validMemoryKey rejects keys that would leave the memory directory.
+func validMemoryKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, ".") && !strings.ContainsAny(key, "/\\\x00") && !strings.Contains(key, "..")
+}
+
+// applyMemory writes or removes a memory file if the record is newer than
+// the local copy. On a tie the local copy stays.
+func (s *Service) applyMemory(r Record) (bool, error) {
+	if !validMemoryKey(r.Key) {
+		return false, fmt.Errorf("invalid memory key")
+	}
+	rows, err := db.QueryArgs(s.dbPath, `SELECT updated_at FROM sync_memory WHERE key = ?`, r.Key)
+	if err != nil {
+		return false, err
+	}
+	if len(rows) > 0 && !newer(r.Updated, db.Str(rows[0]["updated_at"])) {
+		return false, nil
+	}
+	path := filepath.Join(s.memoryDir, r.Key+".md")
+	entry := memoryEntry{updated: r.Updated, deleted: r.Deleted}
+	if r.Deleted {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	} else {
+		var content string
+		if err := json.Unmarshal(r.Data, &content); err != nil {
+			return false, err
+		}
+		if err := os.MkdirAll(s.memoryDir, 0o755); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return false, err
+		}
+		if t, err := time.Parse(time.RFC3339, r.Updated); err == nil {
+			os.Chtimes(path, t, t)
+		}
+		entry.hash = hashBytes([]byte(content))
+	}
+	return true, db.Exec(s.dbPath, upsertMemorySQL(r.Key, entry))
+}
+
+// --- Tables ---
+
+// tableSpec describes how a table is synced. Tables come from the archived
+// life-stack modules and are synced only where they exist.
+type tableSpec struct {
+	dataset    string
+	name       string
+	keys       []string // columns identifying a row across instances
+	ts         string   // SQL expression of the row's last change
+	exclude    []string // columns not synced (local autoincrement ids)
+	appendOnly bool     // merged: rows are only ever added
+}
+
+var tableSpecs = []tableSpec{
+	{dataset: "profile", name: "user_profiles", keys: []string{"id"}, ts: "updated_at"},
+	{dataset: "profile", name: "user_preferences", keys: []string{"user_id", "category", "key"}, ts: "last_observed", exclude: []string{"id"}},
+	{dataset: "profile", name: "user_mood_log", keys: []string{"user_id", "channel", "created_at"}, ts: "created_at", exclude: []string{"id"}, appendOnly: true},
+	{dataset: "contacts", name: "contacts", keys: []string{"id"}, ts: "updated_at"},
+	{dataset: "contacts", name: "contact_interactions", keys: []string{"id"}, ts: "created_at", appendOnly: true},
+	{dataset: "habits", name: "habits", keys: []string{"id"}, ts: "COALESCE(NULLIF(archived_at, ''), created_at)"},
+	{dataset: "habits", name: "habit_logs", keys: []string{"id"}, ts: "logged_at", appendOnly: true},
+	{dataset: "habits", name: "health_data", keys: []string{"id"}, ts: "recorded_at", appendOnly: true},
+}
+
+// tables returns the specs of enabled datasets whose tables exist here.
+func (s *Service) tables() []tableSpec {
+	rows, err := db.Query(s.dbPath, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return nil
+	}
+	have := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		have[db.Str(r["name"])] = true
+	}
+	var out []tableSpec
+	for _, t := range tableSpecs {
+		if have[t.name] && s.enabled(t.dataset) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// tsExpr normalizes a timestamp column to RFC3339 UTC, whichever of the
+// formats SQLite understands it was stored in.
+func tsExpr(expr string) string {
+	return "strftime('%Y-%m-%dT%H:%M:%SZ', " + expr + ")"
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (s *Service) collectTable(t tableSpec, since string) ([]Record, error) {
+	q := `SELECT *, ` + tsExpr(t.ts) + ` AS _ts FROM ` + quoteIdent(t.name)
+	var args []any
+	if since != "" {
+		q += ` WHERE ` + tsExpr(t.ts) + ` >= ?`
+		args = append(args, since)
+	}
+	rows, err := db.QueryArgs(s.dbPath, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Record, 0, len(rows))
+	for _, r := range rows {
+		updated := db.Str(r["_ts"])
+		delete(r, "_ts")
+		for _, c := range t.exclude {
+			delete(r, c)
+		}
+		key := make([]any, len(t.keys))
+		for i, c := range t.keys {
+			key[i] = r[c]
+		}
+		k, _ := json.Marshal(key)
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Record{Dataset: t.dataset, Table: t.name, Key: string(k), Updated: updated, Data: data})
+	}
+	return out, nil
+}
+
+// sqlLiteral renders a decoded JSON value as an SQL literal.
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		return "'" + db.Escape(v) + "'"
+	default:
+		b, _ := json.Marshal(v)
+		return "'" + db.Escape(string(b)) + "'"
+	}
+}
+
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func (s *Service) columns(table string) (map[string]bool, error) {
+	rows, err := db.Query(s.dbPath, `PRAGMA table_info(`+quoteIdent(table)+`)`)
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		cols[db.Str(r["name"])] = true
+	}
+	return cols, nil
+}
+
+// applyRow inserts a received row. An append-only row is added only if no
+// row has its key; another row replaces the local one only if it is newer.
+func (s *Service) applyRow(t tableSpec, r Record) (bool, error) {
+	var key []any
+	if err := decodeJSON([]byte(r.Key), &key); err != nil || len(key) != len(t.keys) {
+		return false, errors.New("invalid row key")
+	}
+	conds := make([]string, len(t.keys))
+	for i, c := range t.keys {
+		if key[i] == nil {
+			conds[i] = quoteIdent(c) + " IS NULL"
+		} else {
+			conds[i] = quoteIdent(c) + " = " + sqlLiteral(key[i])
+		}
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	rows, err := db.Query(s.dbPath, `SELECT `+tsExpr(t.ts)+` AS _ts FROM `+quoteIdent(t.name)+where)
+	if err != nil {
+		return false, err
+	}
+	if len(rows) > 0 && (t.appendOnly || !newer(r.Updated, db.Str(rows[0]["_ts"]))) {
+		return false, nil
+	}
+
+	var row map[string]any
+	if err := decodeJSON(r.Data, &row); err != nil {
+		return false, err
+	}
+	cols, err := s.columns(t.name)
+	if err != nil {
+		return false, err
+	}
+	var names, values []string
+	for c, v := range row {
+		if cols[c] && !slices.Contains(t.exclude, c) {
+			names = append(names, quoteIdent(c))
+			values = append(values, sqlLiteral(v))
+		}
+	}
+	if len(names) == 0 {
+		return false, errors.New("no known columns")
+	}
+	sql := "BEGIN;\n"
+	if len(rows) > 0 {
+		sql += `DELETE FROM ` + quoteIdent(t.name) + where + ";\n"
+	}
+	sql += `INSERT INTO ` + quoteIdent(t.name) + ` (` + strings.Join(names, ", ") + `) VALUES (` + strings.Join(values, ", ") + ");\nCOMMIT;"
+	return true, db.Exec(s.dbPath, sql)
+}
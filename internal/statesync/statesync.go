@@ -0,0 +1,393 @@
+// Package statesync keeps memory and personal data in step between two
+// Tetora instances, such as a server and a laptop.
+//
+// The instance with a peer configured starts each exchange: it posts the
+// records that changed since its last push and asks for the peer's changes
+// since its last pull. Both directions travel sealed with AES-256-GCM under a
+// key derived from a passphrase only the two instances know, so a proxy or
+// relay in between sees neither the data nor which keys changed.
+//
+// Memory files and tables that are edited in place are last-writer-wins per
+// key; append-only tables (logs) are merged. Memory deletions are carried as
+// tombstones; deleted table rows are not.
+package statesync
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+// Record is one synced item: a memory file, or a table row.
+type Record struct {
+	Dataset string          `json:"dataset"`
+	Table   string          `json:"table,omitempty"` // empty for memory
+	Key     string          `json:"key"`             // memory key, or the JSON array of the row's key columns
+	Updated string          `json:"updated"`         // RFC3339, the writer's clock
+	Deleted bool            `json:"deleted,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"` // memory: file content as a JSON string; tables: the row
+}
+
+// request is what the initiating instance sends.
+type request struct {
+	Node    string   `json:"node"`
+	Records []Record `json:"records,omitempty"` // pushed changes
+	Pull    bool     `json:"pull,omitempty"`
+	Since   string   `json:"since,omitempty"` // pull changes after this time on the peer's clock
+}
+
+// response is the peer's answer.
+type response struct {
+	Node    string   `json:"node"`
+	Now     string   `json:"now"` // the peer's clock before collecting, the next pull's since
+	Applied int      `json:"applied"`
+	Records []Record `json:"records,omitempty"`
+}
+
+// Result describes one exchange.
+type Result struct {
+	At      string `json:"at"`
+	Peer    string `json:"peer,omitempty"`
+	Pushed  int    `json:"pushed"`  // records sent
+	Pulled  int    `json:"pulled"`  // records received
+	Applied int    `json:"applied"` // received records that changed local state
+	Error   string `json:"error,omitempty"`
+}
+
+// Service syncs with the peer and answers its exchanges.
+type Service struct {
+	cfg       config.SyncConfig
+	dbPath    string
+	memoryDir string
+	node      string
+	aead      cipher.AEAD
+	client    *http.Client
+
+	mu   sync.Mutex // one exchange at a time
+	last *Result
+}
+
+// New creates the service. memoryDir is the workspace memory directory.
+func New(cfg config.SyncConfig, dbPath, memoryDir, node string) (*Service, error) {
+	if cfg.Key == "" {
+		return nil, errors.New("sync.key is required")
+	}
+	for _, d := range cfg.DatasetsOrDefault() {
+		if !slices.Contains(datasetNames, d) {
+			return nil, fmt.Errorf("unknown sync dataset %q", d)
+		}
+	}
+	key, err := pbkdf2.Key(sha256.New, cfg.Key, []byte("tetora-sync"), 200_000, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		cfg:       cfg,
+		dbPath:    dbPath,
+		memoryDir: memoryDir,
+		node:      node,
+		aead:      aead,
+		client:    &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// InitDB creates the sync bookkeeping tables: the memory index that turns
+// file changes and deletions into timestamped records, and the watermarks.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS sync_memory (
+		key TEXT PRIMARY KEY,
+		hash TEXT DEFAULT '',
+		updated_at TEXT NOT NULL,
+		deleted INTEGER DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS sync_state (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`
+	_, err := db.Query(dbPath, sql)
+	return err
+}
+
+// sealAD binds ciphertexts to this protocol version.
+var sealAD = []byte("tetora-sync/1")
+
+func (s *Service) seal(v any) ([]byte, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plain)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, sealAD), nil
+}
+
+// ErrBadKey means a message could not be opened: the other instance uses a
+// different sync.key, or the message was altered.
+var ErrBadKey = errors.New("cannot decrypt sync message (sync.key differs?)")
+
+func (s *Service) open(data []byte, v any) error {
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return ErrBadKey
+	}
+	plain, err := s.aead.Open(nil, data[:n], data[n:], sealAD)
+	if err != nil {
+		return ErrBadKey
+	}
+	return json.Unmarshal(plain, v)
+}
+
+// Start launches the loop that syncs with the peer every interval until ctx
+// is cancelled. Without a peer it does nothing; the service then only
+// answers.
+func (s *Service) Start(ctx context.Context) {
+	if s.cfg.Peer == "" {
+		return
+	}
+	interval := s.cfg.IntervalOrDefault()
+	log.Info("sync: syncing with peer", "peer", s.cfg.Peer, "direction", s.cfg.DirectionOrDefault(), "interval", interval)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if res, err := s.Run(ctx); err != nil {
+				log.Warn("sync: exchange failed", "peer", s.cfg.Peer, "error", err)
+			} else if res.Pushed+res.Pulled > 0 {
+				log.Info("sync: exchanged with peer", "peer", s.cfg.Peer, "pushed", res.Pushed, "pulled", res.Pulled, "applied", res.Applied)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Last returns the result of the last exchange started here, or nil.
+func (s *Service) Last() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Run performs one exchange with the peer.
+func (s *Service) Run(ctx context.Context) (*Result, error) {
+	if s.cfg.Peer == "" {
+		return nil, errors.New("sync.peer is not set")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	res := &Result{At: now.Format(time.RFC3339), Peer: s.cfg.Peer}
+	err := s.exchange(ctx, now, res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	s.last = res
+	return res, err
+}
+
+func (s *Service) exchange(ctx context.Context, now time.Time, res *Result) error {
+	dir := s.cfg.DirectionOrDefault()
+	req := request{Node: s.node}
+	if dir != "pull" {
+		if err := s.scanMemory(now); err != nil {
+			return fmt.Errorf("scan memory: %w", err)
+		}
+		recs, err := s.collect(s.state("push_since"))
+		if err != nil {
+			return fmt.Errorf("collect: %w", err)
+		}
+		req.Records = recs
+	}
+	if dir != "push" {
+		req.Pull = true
+		req.Since = s.state("pull_since")
+	}
+
+	body, err := s.seal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.cfg.Peer, "/")+"/api/sync", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/octet-stream")
+	if s.cfg.PeerToken != "" {
+		hreq.Header.Set("Authorization", "Bearer "+s.cfg.PeerToken)
+	}
+	hresp, err := s.client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer hresp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(hresp.Body, maxMessage))
+	if err != nil {
+		return err
+	}
+	if hresp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer: status %d: %s", hresp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var resp response
+	if err := s.open(data, &resp); err != nil {
+		return err
+	}
+
+	res.Pushed = len(req.Records)
+	if dir != "pull" {
+		s.setState("push_since", now.Format(time.RFC3339))
+	}
+	if dir != "push" {
+		res.Pulled = len(resp.Records)
+		res.Applied = s.apply(resp.Records)
+		s.setState("pull_since", resp.Now)
+	}
+	return nil
+}
+
+// maxMessage bounds a sealed exchange.
+const maxMessage = 64 << 20
+
+// Handle answers an exchange from the peer: it returns the changes the peer
+// asked for, collected before the pushed records are applied so they are not
+// echoed back, then applies those records.
+func (s *Service) Handle(body []byte) ([]byte, *Result, error) {
+	var req request
+	if err := s.open(body, &req); err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	res := &Result{At: now.Format(time.RFC3339), Peer: req.Node, Pulled: len(req.Records)}
+	resp := response{Node: s.node, Now: now.Format(time.RFC3339)}
+	if err := s.scanMemory(now); err != nil {
+		return nil, nil, fmt.Errorf("scan memory: %w", err)
+	}
+	if req.Pull {
+		recs, err := s.collect(req.Since)
+		if err != nil {
+			return nil, nil, fmt.Errorf("collect: %w", err)
+		}
+		resp.Records = recs
+		res.Pushed = len(recs)
+	}
+	resp.Applied = s.apply(req.Records)
+	res.Applied = resp.Applied
+	out, err := s.seal(resp)
+	return out, res, err
+}
+
+func (s *Service) state(name string) string {
+	rows, err := db.QueryArgs(s.dbPath, `SELECT value FROM sync_state WHERE name = ?`, name)
+	if err != nil || len(rows) == 0 {
+		return ""
+	}
+	return db.Str(rows[0]["value"])
+}
+
+func (s *Service) setState(name, value string) {
+	if err := db.ExecArgs(s.dbPath, `INSERT OR REPLACE INTO sync_state (name, value) VALUES (?, ?)`, name, value); err != nil {
+		log.Warn("sync: save state failed", "name", name, "error", err)
+	}
+}
+
+// enabled reports whether dataset is synced by this instance.
+func (s *Service) enabled(dataset string) bool {
+	return slices.Contains(s.cfg.DatasetsOrDefault(), dataset)
+}
+
+// collect returns the records of every enabled dataset changed after since
+// (all of them when since is empty).
+func (s *Service) collect(since string) ([]Record, error) {
+	var out []Record
+	if s.enabled("memory") {
+		recs, err := s.collectMemory(since)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, recs...)
+	}
+	for _, t := range s.tables() {
+		recs, err := s.collectTable(t, since)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		out = append(out, recs...)
+	}
+	return out, nil
+}
+
+// apply applies received records and returns how many changed local state.
+// Records of datasets not synced here, or of tables this database does not
+// have, are ignored.
+func (s *Service) apply(recs []Record) int {
+	tables := map[string]tableSpec{}
+	for _, t := range s.tables() {
+		tables[t.name] = t
+	}
+	n := 0
+	for _, r := range recs {
+		if !s.enabled(r.Dataset) {
+			continue
+		}
+		var changed bool
+		var err error
+		if r.Table == "" {
+			changed, err = s.applyMemory(r)
+		} else if t, ok := tables[r.Table]; ok && t.dataset == r.Dataset {
+			changed, err = s.applyRow(t, r)
+		}
+		if err != nil {
+			log.Warn("sync: apply failed", "dataset", r.Dataset, "table", r.Table, "key", r.Key, "error", err)
+			continue
+		}
+		if changed {
+			n++
+		}
+	}
+	return n
+}
+
+// newer reports whether timestamp a is after b. An unparseable a is never
+// newer; an unparseable b always loses.
+func newer(a, b string) bool {
+	ta, err := time.Parse(time.RFC3339, a)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339, b)
+	if err != nil {
+		return true
+	}
+	return ta.After(tb)
+}
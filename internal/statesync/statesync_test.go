@@ -0,0 +1,174 @@
+package statesync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/db"
+)
+
+func newTestService(t *testing.T, cfg config.SyncConfig, node string) *Service {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Skipf("sqlite3 not available: %v", err)
+	}
+	if cfg.Key == "" {
+		cfg.Key = "correct horse"
+	}
+	s, err := New(cfg, dbPath, filepath.Join(dir, "memory"), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSeal(t *testing.T) {
+	a, _ := New(config.SyncConfig{Key: "k1"}, "", "", "a")
+	b, _ := New(config.SyncConfig{Key: "k2"}, "", "", "b")
+	msg, err := a.seal(request{Node: "a", Since: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got request
+	if err := a.open(msg, &got); err != nil || got.Node != "a" || got.Since != "x" {
+		t.Errorf("open = %+v, %v", got, err)
+	}
+	if err := b.open(msg, &got); !errors.Is(err, ErrBadKey) {
+		t.Errorf("wrong key: %v", err)
+	}
+	msg[len(msg)-1] ^= 1
+	if err := a.open(msg, &got); !errors.Is(err, ErrBadKey) {
+		t.Errorf("tampered: %v", err)
+	}
+	if _, err := New(config.SyncConfig{}, "", "", "a"); err == nil {
+		t.Error("empty key accepted")
+	}
+}
+
+func writeMemory(t *testing.T, s *Service, key, content string, mod time.Time) {
+	t.Helper()
+	os.MkdirAll(s.memoryDir, 0o755)
+	path := filepath.Join(s.memoryDir, key+".md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, mod, mod)
+}
+
+func readMemory(s *Service, key string) string {
+	data, _ := os.ReadFile(filepath.Join(s.memoryDir, key+".md"))
+	return string(data)
+}
+
+func TestExchange(t *testing.T) {
+	server := newTestService(t, config.SyncConfig{}, "server")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", 401)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		out, _, err := server.Handle(body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.Write(out)
+	}))
+	defer srv.Close()
+	laptop := newTestService(t, config.SyncConfig{Peer: srv.URL, PeerToken: "tok"}, "laptop")
+
+	t0 := time.Now().Add(-time.Hour)
+	writeMemory(t, server, "groceries", "milk", t0)
+	writeMemory(t, server, "trip", "kyoto (server)", t0)
+	writeMemory(t, laptop, "trip", "kyoto (laptop)", t0.Add(time.Minute))
+	writeMemory(t, laptop, "ideas", "sync", t0)
+
+	ctx := context.Background()
+	res, err := laptop.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pushed != 2 || res.Pulled != 2 {
+		t.Errorf("first run = %+v", res)
+	}
+	for _, s := range []*Service{server, laptop} {
+		if readMemory(s, "trip") != "kyoto (laptop)" || readMemory(s, "groceries") != "milk" || readMemory(s, "ideas") != "sync" {
+			t.Errorf("%s: trip=%q groceries=%q ideas=%q", s.node, readMemory(s, "trip"), readMemory(s, "groceries"), readMemory(s, "ideas"))
+		}
+	}
+
+	// A deletion on the server reaches the laptop.
+	os.Remove(filepath.Join(server.memoryDir, "groceries.md"))
+	if _, err := laptop.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(laptop.memoryDir, "groceries.md")); !os.IsNotExist(err) {
+		t.Error("deletion not synced")
+	}
+
+	// Push-only leaves the server's changes on the server.
+	writeMemory(t, server, "news", "server only", time.Now())
+	laptop.cfg.Direction = "push"
+	if res, err := laptop.Run(ctx); err != nil || res.Pulled != 0 || readMemory(laptop, "news") != "" {
+		t.Errorf("push-only run = %+v, %v", res, err)
+	}
+
+	// A different key fails loudly.
+	other := newTestService(t, config.SyncConfig{Peer: srv.URL, PeerToken: "tok", Key: "wrong"}, "other")
+	if _, err := other.Run(ctx); err == nil {
+		t.Error("exchange with wrong key succeeded")
+	}
+	if last := other.Last(); last == nil || last.Error == "" {
+		t.Errorf("last = %+v", last)
+	}
+}
+
+func TestTables(t *testing.T) {
+	a := newTestService(t, config.SyncConfig{Datasets: []string{"profile"}}, "a")
+	b := newTestService(t, config.SyncConfig{Datasets: []string{"profile"}}, "b")
+	schema := `CREATE TABLE user_profiles (id TEXT PRIMARY KEY, display_name TEXT, timezone TEXT, updated_at TEXT);
+		CREATE TABLE user_mood_log (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id TEXT, channel TEXT, sentiment REAL, created_at TEXT);`
+	for _, s := range []*Service{a, b} {
+		if err := db.Exec(s.dbPath, schema); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec(a.dbPath, `INSERT INTO user_profiles VALUES ('u1', 'Taku', NULL, '2026-10-15T10:00:00Z');
+		INSERT INTO user_mood_log (user_id, channel, sentiment, created_at) VALUES ('u1', 'tg', 0.5, '2026-10-15 09:00:00');`)
+	db.Exec(b.dbPath, `INSERT INTO user_profiles VALUES ('u1', 'Old', 'UTC', '2026-10-14T10:00:00Z');
+		INSERT INTO user_mood_log (user_id, channel, sentiment, created_at) VALUES ('u1', 'tg', 0.5, '2026-10-15 09:00:00');
+		INSERT INTO user_mood_log (user_id, channel, sentiment, created_at) VALUES ('u1', 'slack', -0.25, '2026-10-15 11:00:00');`)
+
+	recs, err := a.collect("")
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("collect = %+v, %v", recs, err)
+	}
+	if recs2, _ := a.collect("2026-10-15T09:30:00Z"); len(recs2) != 1 {
+		t.Errorf("collect since = %+v", recs2)
+	}
+	if n := b.apply(recs); n != 1 {
+		t.Errorf("applied %d, want 1 (the newer profile)", n)
+	}
+	rows, _ := db.Query(b.dbPath, `SELECT display_name, timezone FROM user_profiles WHERE id = 'u1'`)
+	if len(rows) != 1 || db.Str(rows[0]["display_name"]) != "Taku" || rows[0]["timezone"] != nil {
+		t.Errorf("profile = %+v", rows)
+	}
+
+	back, _ := b.collect("")
+	a.apply(back)
+	rows, _ = db.Query(a.dbPath, `SELECT channel, sentiment FROM user_mood_log ORDER BY created_at`)
+	if len(rows) != 2 || db.Str(rows[1]["channel"]) != "slack" || db.Float(rows[1]["sentiment"]) != -0.25 {
+		t.Errorf("mood log = %+v", rows)
+	}
+}
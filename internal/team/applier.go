@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+
+	"tetora/internal/cfgfile"
 )
 
 // ApplyOptions controls how a team is applied to config.
@@ -32,6 +34,9 @@ func Apply(teamName string, store *Storage, configPath, agentsDir string, opts A
 	if err := os.WriteFile(backupPath, configData, 0o600); err != nil {
 		return fmt.Errorf("backup config: %w", err)
 	}
+	if configData, err = cfgfile.ReadJSON(configPath); err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
 
 	// Step 2: Parse config as raw map to preserve unknown fields.
 	var raw map[string]json.RawMessage
@@ -133,13 +138,8 @@ func Apply(teamName string, store *Storage, configPath, agentsDir string, opts A
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	tmp := configPath + ".tmp"
-	if err := os.WriteFile(tmp, append(out, '\n'), 0o600); err != nil {
-		return fmt.Errorf("write config tmp: %w", err)
-	}
-	if err := os.Rename(tmp, configPath); err != nil {
-		os.Remove(tmp)
-		return fmt.Errorf("rename config: %w", err)
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
 	}
 
 	// Step 6: Create SOUL.md files.
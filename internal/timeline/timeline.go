@@ -0,0 +1,330 @@
+// Package timeline replays a day: the tasks Tetora ran, the notifications it
+// sent, the memory it wrote, the lessons it drew and the reminders that
+// fired, in the order they happened, with the day's spend. It backs
+// GET /timeline and the timeline section of the daily note.
+package timeline
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/db"
+	"tetora/internal/history"
+	"tetora/internal/notify"
+)
+
+// Event kinds.
+const (
+	KindTask         = "task"
+	KindNotification = "notification"
+	KindMemory       = "memory"
+	KindLesson       = "lesson"
+	KindReminder     = "reminder"
+)
+
+// Event is one thing that happened during the day.
+type Event struct {
+	Time    string  `json:"time"` // RFC3339, local time
+	Kind    string  `json:"kind"`
+	Title   string  `json:"title"`
+	Detail  string  `json:"detail,omitempty"`
+	Agent   string  `json:"agent,omitempty"`
+	Source  string  `json:"source,omitempty"`
+	Status  string  `json:"status,omitempty"`
+	CostUSD float64 `json:"costUsd,omitempty"`
+	Ref     string  `json:"ref,omitempty"` // task ID, memory key or reminder ID
+
+	at time.Time
+}
+
+// Summary counts the day's events and totals its spend.
+type Summary struct {
+	Tasks         int                `json:"tasks"`
+	Succeeded     int                `json:"succeeded"`
+	Failed        int                `json:"failed"`
+	Notifications int                `json:"notifications"`
+	MemoryWrites  int                `json:"memoryWrites"`
+	Lessons       int                `json:"lessons"`
+	Reminders     int                `json:"reminders"`
+	CostUSD       float64            `json:"costUsd"`     // model spend of the day's tasks
+	ToolCostUSD   float64            `json:"toolCostUsd"` // MCP and plugin tool spend
+	CostByAgent   map[string]float64 `json:"costByAgent,omitempty"`
+}
+
+// Day is the replay of one date.
+type Day struct {
+	Date    string  `json:"date"` // YYYY-MM-DD
+	Summary Summary `json:"summary"`
+	Events  []Event `json:"events"`
+}
+
+// Options says where the day's records are.
+type Options struct {
+	HistoryDB string
+	MemoryDir string // workspace memory directory; "" skips memory writes
+}
+
+// ParseDate reads a date query: "" or "today", "yesterday", or YYYY-MM-DD
+// in local time.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be YYYY-MM-DD, today or yesterday")
+	}
+	return d, nil
+}
+
+// Build replays date from the history DB and the memory directory. Tasks
+// are required; the other records are skipped when their table does not
+// exist. Memory writes are files last modified on date, so a memory
+// rewritten since shows on its latest day only.
+func Build(opts Options, date time.Time) (*Day, error) {
+	day := &Day{Date: date.Format("2006-01-02"), Events: []Event{}}
+	if opts.HistoryDB == "" {
+		return nil, fmt.Errorf("historyDB not configured")
+	}
+	onDay := func(col string) string {
+		return fmt.Sprintf("date(%s,'localtime') = '%s'", col, day.Date)
+	}
+
+	rows, err := db.Query(opts.HistoryDB,
+		`SELECT job_id, name, source, started_at, status, cost_usd, COALESCE(agent,'') as agent,
+		 COALESCE(error,'') as error, COALESCE(output_summary,'') as output_summary
+		 FROM job_runs WHERE `+onDay("started_at")+` ORDER BY started_at`)
+	if err != nil {
+		return nil, fmt.Errorf("query job runs: %w", err)
+	}
+	for _, r := range rows {
+		e := Event{
+			Kind:    KindTask,
+			Title:   db.Str(r["name"]),
+			Agent:   db.Str(r["agent"]),
+			Source:  db.Str(r["source"]),
+			Status:  db.Str(r["status"]),
+			CostUSD: db.Float(r["cost_usd"]),
+			Ref:     db.Str(r["job_id"]),
+		}
+		e.Detail = db.Str(r["error"])
+		if e.Detail == "" {
+			e.Detail = db.Str(r["output_summary"])
+		}
+		e.Detail = clip(e.Detail, 300)
+		day.add(e, db.Str(r["started_at"]))
+	}
+
+	if rows, err := db.Query(opts.HistoryDB, fmt.Sprintf(
+		`SELECT timestamp, source, detail FROM audit_log WHERE action = '%s' AND %s ORDER BY id`,
+		notify.AuditAction, onDay("timestamp"))); err == nil {
+		for _, r := range rows {
+			priority, text, ok := strings.Cut(db.Str(r["detail"]), ": ")
+			if !ok {
+				priority, text = "", priority
+			}
+			day.add(Event{Kind: KindNotification, Title: clip(text, 300), Source: db.Str(r["source"]), Status: priority},
+				db.Str(r["timestamp"]))
+		}
+	}
+
+	if rows, err := db.Query(opts.HistoryDB,
+		`SELECT task_id, agent, score, improvement, created_at FROM reflections
+		 WHERE improvement != '' AND `+onDay("created_at")+` ORDER BY created_at`); err == nil {
+		for _, r := range rows {
+			day.add(Event{Kind: KindLesson, Title: clip(db.Str(r["improvement"]), 300), Agent: db.Str(r["agent"]),
+				Detail: fmt.Sprintf("score %d/5", db.Int(r["score"])), Ref: db.Str(r["task_id"])}, db.Str(r["created_at"]))
+		}
+	}
+
+	if rows, err := db.Query(opts.HistoryDB,
+		`SELECT id, text, due_at, status FROM reminders
+		 WHERE status IN ('fired','pending') AND `+onDay("due_at")+` ORDER BY due_at`); err == nil {
+		for _, r := range rows {
+			day.add(Event{Kind: KindReminder, Title: db.Str(r["text"]), Status: db.Str(r["status"]), Ref: db.Str(r["id"])},
+				db.Str(r["due_at"]))
+		}
+	}
+
+	if rows, err := db.Query(opts.HistoryDB,
+		`SELECT agent, COALESCE(SUM(cost_usd),0) as cost FROM tool_costs WHERE `+onDay("created_at")+` GROUP BY agent`); err == nil {
+		for _, r := range rows {
+			cost := db.Float(r["cost"])
+			day.Summary.ToolCostUSD += cost
+			day.addAgentCost(db.Str(r["agent"]), cost)
+		}
+	}
+
+	if opts.MemoryDir != "" {
+		day.addMemory(opts.MemoryDir)
+	}
+
+	sort.SliceStable(day.Events, func(i, j int) bool { return day.Events[i].at.Before(day.Events[j].at) })
+	return day, nil
+}
+
+// add appends e at timestamp ts and counts it in the summary.
+func (d *Day) add(e Event, ts string) {
+	at, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		at, _ = time.ParseInLocation("2006-01-02 15:04:05", ts, time.Local)
+	}
+	e.at = at.Local()
+	e.Time = e.at.Format(time.RFC3339)
+
+	s := &d.Summary
+	switch e.Kind {
+	case KindTask:
+		s.Tasks++
+		switch e.Status {
+		case "success":
+			s.Succeeded++
+		case history.StatusSkippedConcurrentLimit:
+		default:
+			s.Failed++
+		}
+		s.CostUSD += e.CostUSD
+		d.addAgentCost(e.Agent, e.CostUSD)
+	case KindNotification:
+		s.Notifications++
+	case KindMemory:
+		s.MemoryWrites++
+	case KindLesson:
+		s.Lessons++
+	case KindReminder:
+		s.Reminders++
+	}
+	d.Events = append(d.Events, e)
+}
+
+func (d *Day) addAgentCost(agent string, usd float64) {
+	if usd == 0 {
+		return
+	}
+	if agent == "" {
+		agent = "(none)"
+	}
+	if d.Summary.CostByAgent == nil {
+		d.Summary.CostByAgent = map[string]float64{}
+	}
+	d.Summary.CostByAgent[agent] += usd
+}
+
+// addMemory adds the memory files last written on the day. A file whose
+// created_at is the same day was created; others were updated.
+func (d *Day) addMemory(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Local().Format("2006-01-02") != d.Date {
+			continue
+		}
+		key := strings.TrimSuffix(entry.Name(), ".md")
+		status := "updated"
+		if created := memoryCreatedAt(filepath.Join(dir, entry.Name())); !created.IsZero() &&
+			created.Local().Format("2006-01-02") == d.Date {
+			status = "created"
+		}
+		d.add(Event{Kind: KindMemory, Title: key, Status: status, Ref: key}, info.ModTime().Format(time.RFC3339))
+	}
+}
+
+// memoryCreatedAt reads created_at from a memory file's frontmatter.
+func memoryCreatedAt(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() || sc.Text() != "---" {
+		return time.Time{}
+	}
+	for sc.Scan() && sc.Text() != "---" {
+		if v, ok := strings.CutPrefix(sc.Text(), "created_at: "); ok {
+			t, _ := time.Parse(time.RFC3339, strings.TrimSpace(v))
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Markdown renders the day as a daily note section.
+func (d *Day) Markdown() string {
+	var sb strings.Builder
+	s := d.Summary
+	fmt.Fprintf(&sb, "## Timeline — %s\n\n", d.Date)
+	fmt.Fprintf(&sb, "%d tasks (%d ok, %d failed) · %d notifications · %d memory writes · %d lessons · %d reminders · $%.4f",
+		s.Tasks, s.Succeeded, s.Failed, s.Notifications, s.MemoryWrites, s.Lessons, s.Reminders, s.CostUSD+s.ToolCostUSD)
+	if s.ToolCostUSD > 0 {
+		fmt.Fprintf(&sb, " (tools $%.4f)", s.ToolCostUSD)
+	}
+	sb.WriteString("\n\n")
+	if len(d.Events) == 0 {
+		sb.WriteString("Nothing happened on this day.\n")
+		return sb.String()
+	}
+	for _, e := range d.Events {
+		clock := e.at.Format("15:04")
+		switch e.Kind {
+		case KindTask:
+			mark := "✅"
+			switch e.Status {
+			case "success":
+			case history.StatusSkippedConcurrentLimit:
+				mark = "⏭️"
+			default:
+				mark = "❌"
+			}
+			fmt.Fprintf(&sb, "- %s %s **%s**", clock, mark, e.Title)
+			if e.Agent != "" {
+				fmt.Fprintf(&sb, " (%s)", e.Agent)
+			}
+			if e.CostUSD > 0 {
+				fmt.Fprintf(&sb, " $%.4f", e.CostUSD)
+			}
+			if e.Status != "success" && e.Detail != "" {
+				fmt.Fprintf(&sb, " — %s", oneLine(e.Detail, 120))
+			}
+		case KindNotification:
+			fmt.Fprintf(&sb, "- %s 🔔 %s", clock, oneLine(e.Title, 160))
+		case KindMemory:
+			fmt.Fprintf(&sb, "- %s 🧠 memory %s: `%s`", clock, e.Status, e.Title)
+		case KindLesson:
+			fmt.Fprintf(&sb, "- %s 💡 %s", clock, oneLine(e.Title, 160))
+			if e.Agent != "" {
+				fmt.Fprintf(&sb, " (%s)", e.Agent)
+			}
+		case KindReminder:
+			fmt.Fprintf(&sb, "- %s ⏰ %s", clock, oneLine(e.Title, 160))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func clip(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+func oneLine(s string, n int) string {
+	return clip(strings.Join(strings.Fields(s), " "), n)
+}
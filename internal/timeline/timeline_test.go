@@ -0,0 +1,125 @@
+package timeline
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/audit"
+	"tetora/internal/db"
+	"tetora/internal/history"
+)
+
+func TestBuild(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not found")
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "history.db")
+	if err := history.InitDB(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Init(dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) string {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute).Format(time.RFC3339)
+	}
+
+	for _, r := range []history.JobRun{
+		{JobID: "t1", Name: "morning-brief", Agent: "ruri", Status: "success", CostUSD: 0.02, StartedAt: at(8, 0)},
+		{JobID: "t2", Name: "deploy-check", Agent: "kokuyou", Status: "error", Error: "timeout talking to host", CostUSD: 0.01, StartedAt: at(11, 30)},
+		{JobID: "t3", Name: "yesterday", Status: "success", CostUSD: 5, StartedAt: day.Add(-time.Hour).Format(time.RFC3339)},
+	} {
+		if err := history.InsertRun(dbPath, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := history.InsertToolCost(dbPath, history.ToolCost{Server: "search", Tool: "query", Agent: "ruri", CostUSD: 0.5, CreatedAt: at(8, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(dbPath, `INSERT INTO audit_log (timestamp, action, source, detail, ip) VALUES
+		('`+day.Add(9*time.Hour).UTC().Format(time.RFC3339)+`', 'notify.send', 'task.complete', 'high: deploy-check failed', '')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(dbPath, `CREATE TABLE reflections (task_id TEXT, agent TEXT, score INTEGER, improvement TEXT, created_at TEXT);
+		INSERT INTO reflections VALUES ('t2', 'kokuyou', 2, 'Check host reachability first', '`+at(11, 40)+`');`); err != nil {
+		t.Fatal(err)
+	}
+
+	memDir := filepath.Join(dir, "memory")
+	os.MkdirAll(memDir, 0o755)
+	writeMem := func(key, content string, mod time.Time) {
+		p := filepath.Join(memDir, key+".md")
+		os.WriteFile(p, []byte(content), 0o644)
+		os.Chtimes(p, mod, mod)
+	}
+	writeMem("deploy-host", "---\ncreated_at: "+at(11, 45)+"\n---\nhost is behind VPN", day.Add(11*time.Hour+45*time.Minute))
+	writeMem("prefs", "---\ncreated_at: 2025-01-01T00:00:00Z\n---\nlikes tea", day.Add(12*time.Hour))
+	writeMem("old", "stale", day.AddDate(0, 0, -3))
+
+	d, err := Build(Options{HistoryDB: dbPath, MemoryDir: memDir}, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, e := range d.Events {
+		kinds = append(kinds, e.Kind+":"+e.Title)
+	}
+	want := []string{
+		"task:morning-brief",
+		"notification:deploy-check failed",
+		"task:deploy-check",
+		"lesson:Check host reachability first",
+		"memory:deploy-host",
+		"memory:prefs",
+	}
+	if strings.Join(kinds, "|") != strings.Join(want, "|") {
+		t.Errorf("events = %q, want %q", kinds, want)
+	}
+
+	s := d.Summary
+	if s.Tasks != 2 || s.Succeeded != 1 || s.Failed != 1 || s.Notifications != 1 || s.Lessons != 1 || s.MemoryWrites != 2 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.CostUSD < 0.0299 || s.CostUSD > 0.0301 || s.ToolCostUSD != 0.5 {
+		t.Errorf("costs = %v model, %v tools", s.CostUSD, s.ToolCostUSD)
+	}
+	if c := s.CostByAgent["ruri"]; c < 0.5199 || c > 0.5201 {
+		t.Errorf("ruri cost = %v", c)
+	}
+	if d.Events[4].Status != "created" || d.Events[5].Status != "updated" {
+		t.Errorf("memory statuses = %q, %q", d.Events[4].Status, d.Events[5].Status)
+	}
+
+	md := d.Markdown()
+	for _, s := range []string{"## Timeline — 2026-03-14", "❌ **deploy-check** (kokuyou)", "timeout talking to host", "🧠 memory created: `deploy-host`", "(tools $0.5000)"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("markdown missing %q:\n%s", s, md)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 4, 5, 0, time.Local)
+	for in, want := range map[string]string{
+		"":           "2026-03-14",
+		"today":      "2026-03-14",
+		"yesterday":  "2026-03-13",
+		"2026-01-02": "2026-01-02",
+	} {
+		d, err := ParseDate(in, now)
+		if err != nil || d.Format("2006-01-02") != want {
+			t.Errorf("ParseDate(%q) = %v, %v; want %s", in, d, err, want)
+		}
+	}
+	if _, err := ParseDate("14/03/2026", now); err == nil {
+		t.Error("ParseDate accepted a bad date")
+	}
+}
@@ -36,51 +36,72 @@ func Translate(ctx context.Context, provider, apiKey string, input json.RawMessa
 	if args.From == "" {
 		args.From = "auto"
 	}
-
-	prov := strings.ToLower(provider)
-	if prov == "" {
-		prov = "lingva"
+	text, detected, err := TranslateText(ctx, provider, apiKey, args.Text, args.From, args.To)
+	if err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("[%s -> %s] %s", coalesceLang(detected, args.From), args.To, text), nil
+}
 
-	switch prov {
+// TranslateText translates text with a machine translation API ("lingva",
+// the default, or "deepl"). from may be "auto"; detected is the source
+// language the API reports, when it reports one.
+func TranslateText(ctx context.Context, provider, apiKey, text, from, to string) (translated, detected string, err error) {
+	if from == "" {
+		from = "auto"
+	}
+	switch strings.ToLower(provider) {
 	case "deepl":
-		return translateDeepL(args.Text, args.From, args.To, apiKey)
+		return translateDeepL(ctx, text, from, to, apiKey)
 	default:
-		return translateLingva(args.Text, args.From, args.To)
+		return translateLingva(ctx, text, from, to)
 	}
 }
 
-func translateLingva(text, from, to string) (string, error) {
+func coalesceLang(detected, from string) string {
+	if detected != "" {
+		return detected
+	}
+	return from
+}
+
+func translateLingva(ctx context.Context, text, from, to string) (string, string, error) {
 	apiURL := fmt.Sprintf("%s/api/v1/%s/%s/%s",
 		LingvaBaseURL,
 		url.PathEscape(strings.ToLower(from)),
 		url.PathEscape(strings.ToLower(to)),
 		url.PathEscape(text))
 
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("create request error: %w", err)
+	}
 	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(apiURL)
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("lingva API error: %w", err)
+		return "", "", fmt.Errorf("lingva API error: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return "", fmt.Errorf("lingva API returned %d: %s", resp.StatusCode, string(body))
+		return "", "", fmt.Errorf("lingva API returned %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
 		Translation string `json:"translation"`
+		Info        struct {
+			DetectedSource string `json:"detectedSource"`
+		} `json:"info"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decode error: %w", err)
+		return "", "", fmt.Errorf("decode error: %w", err)
 	}
-
-	return fmt.Sprintf("[%s -> %s] %s", from, to, result.Translation), nil
+	return result.Translation, strings.ToLower(result.Info.DetectedSource), nil
 }
 
-func translateDeepL(text, from, to, apiKey string) (string, error) {
+func translateDeepL(ctx context.Context, text, from, to, apiKey string) (string, string, error) {
 	if apiKey == "" {
-		return "", fmt.Errorf("DeepL API key required (set translate.apiKey in config)")
+		return "", "", fmt.Errorf("DeepL API key required (set translate.apiKey in config)")
 	}
 
 	form := url.Values{}
@@ -91,9 +112,9 @@ func translateDeepL(text, from, to, apiKey string) (string, error) {
 	}
 
 	apiURL := DeeplBaseURL + "/v2/translate"
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("create request error: %w", err)
+		return "", "", fmt.Errorf("create request error: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Authorization", "DeepL-Auth-Key "+apiKey)
@@ -101,12 +122,12 @@ func translateDeepL(text, from, to, apiKey string) (string, error) {
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("deepl API error: %w", err)
+		return "", "", fmt.Errorf("deepl API error: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return "", fmt.Errorf("deepl API returned %d: %s", resp.StatusCode, string(body))
+		return "", "", fmt.Errorf("deepl API returned %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -116,18 +137,14 @@ func translateDeepL(text, from, to, apiKey string) (string, error) {
 		} `json:"translations"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decode error: %w", err)
+		return "", "", fmt.Errorf("decode error: %w", err)
 	}
 	if len(result.Translations) == 0 {
-		return "", fmt.Errorf("no translation returned")
+		return "", "", fmt.Errorf("no translation returned")
 	}
 
 	t := result.Translations[0]
-	srcLang := from
-	if t.DetectedSourceLang != "" {
-		srcLang = strings.ToLower(t.DetectedSourceLang)
-	}
-	return fmt.Sprintf("[%s -> %s] %s", srcLang, to, t.Text), nil
+	return t.Text, strings.ToLower(t.DetectedSourceLang), nil
 }
 
 func DetectLanguage(ctx context.Context, provider, apiKey string, input json.RawMessage) (string, error) {
@@ -191,6 +208,20 @@ func detectLanguageDeepL(text, apiKey string) (string, error) {
 }
 
 func DetectLanguageHeuristic(text string) string {
+	code, name := HeuristicLanguage(text)
+	switch {
+	case name == "":
+		return "Detected language: unknown (no text content)"
+	case code == "":
+		return "Detected language: unknown — heuristic"
+	}
+	return fmt.Sprintf("Detected language: %s (%s) — heuristic", code, name)
+}
+
+// HeuristicLanguage guesses a language from the script text is written in.
+// Latin script is reported as English. code is empty when the script is
+// mixed or unknown, and name is empty too when text has no letters.
+func HeuristicLanguage(text string) (code, name string) {
 	var (
 		cjk      int
 		hiragana int
@@ -230,34 +261,26 @@ func DetectLanguageHeuristic(text string) string {
 		}
 	}
 
-	if total == 0 {
-		return "Detected language: unknown (no text content)"
-	}
-
+	switch {
+	case total == 0:
+		return "", ""
 	// Japanese: has hiragana/katakana.
-	if hiragana+katakana > 0 {
-		return "Detected language: ja (Japanese) — heuristic"
-	}
+	case hiragana+katakana > 0:
+		return "ja", "Japanese"
 	// Korean: has hangul.
-	if hangul > total/3 {
-		return "Detected language: ko (Korean) — heuristic"
-	}
+	case hangul > total/3:
+		return "ko", "Korean"
 	// Chinese: CJK without kana.
-	if cjk > total/3 {
-		return "Detected language: zh (Chinese) — heuristic"
-	}
-	if cyrillic > total/3 {
-		return "Detected language: ru (Russian/Cyrillic) — heuristic"
-	}
-	if arabic > total/3 {
-		return "Detected language: ar (Arabic) — heuristic"
-	}
-	if thai > total/3 {
-		return "Detected language: th (Thai) — heuristic"
-	}
-	if latin > total/2 {
-		return "Detected language: en (English/Latin script) — heuristic"
-	}
-
-	return "Detected language: unknown — heuristic"
+	case cjk > total/3:
+		return "zh", "Chinese"
+	case cyrillic > total/3:
+		return "ru", "Russian/Cyrillic"
+	case arabic > total/3:
+		return "ar", "Arabic"
+	case thai > total/3:
+		return "th", "Thai"
+	case latin > total/2:
+		return "en", "English/Latin script"
+	}
+	return "", "unknown"
 }
@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/life/calendar"
+)
+
+// CalendarDeps holds external dependencies for the calendar tool handlers.
+type CalendarDeps struct {
+	// Service returns the calendar service. Nil until the daemon has started it.
+	Service func(ctx context.Context) *calendar.Service
+}
+
+// RegisterCalendarTools registers the calendar tools when the calendar is
+// enabled. Creating and changing events only touches the owner's own
+// calendars; deleting one needs approval.
+func RegisterCalendarTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps CalendarDeps) {
+	if !cfg.Calendar.Enabled {
+		return
+	}
+	var names []string
+	for _, a := range cfg.Calendar.AccountsOrDefault() {
+		kind := a.Type
+		if !a.Writable() {
+			kind += ", read-only"
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", a.Name, kind))
+	}
+	accounts := strings.Join(names, ", ")
+	keywords := []string{"calendar", "event", "meeting", "appointment", "schedule", "agenda", "caldav"}
+	add := func(name, desc, schema string, h func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error), auth bool) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				s := deps.Service(ctx)
+				if s == nil {
+					return "", fmt.Errorf("calendar is not running")
+				}
+				return h(ctx, s, input)
+			},
+			Builtin:     true,
+			RequireAuth: auth,
+		})
+	}
+
+	add("calendar_list", "List calendar events within a time range, from every account unless one is named. Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account name (default all)"},
+			"timeMin": {"type": "string", "description": "Start of time range (RFC3339, default: now)"},
+			"timeMax": {"type": "string", "description": "End of time range (RFC3339, default: days after timeMin)"},
+			"days": {"type": "number", "description": "Convenience: list events for next N days (default 7)"},
+			"maxResults": {"type": "number", "description": "Maximum number of events to return (default 10)"}
+		}
+	}`, func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account    string `json:"account"`
+			TimeMin    string `json:"timeMin"`
+			TimeMax    string `json:"timeMax"`
+			Days       int    `json:"days"`
+			MaxResults int    `json:"maxResults"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		from := time.Now()
+		if args.TimeMin != "" {
+			t, err := time.Parse(time.RFC3339, args.TimeMin)
+			if err != nil {
+				return "", fmt.Errorf("timeMin must be RFC3339")
+			}
+			from = t
+		}
+		if args.Days <= 0 {
+			args.Days = 7
+		}
+		if args.TimeMax == "" {
+			args.TimeMax = from.AddDate(0, 0, args.Days).Format(time.RFC3339)
+		}
+		events, err := s.ListEvents(ctx, args.Account, from.Format(time.RFC3339), args.TimeMax, args.MaxResults)
+		if err != nil {
+			return "", err
+		}
+		if len(events) == 0 {
+			return "No events in that range.", nil
+		}
+		return marshalIndent(events)
+	}, false)
+
+	add("calendar_create", "Create a calendar event, in the named account or the default one. Accepts structured input or natural language (Japanese/English/Chinese). Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account name (default the configured default)"},
+			"summary": {"type": "string", "description": "Event title"},
+			"description": {"type": "string", "description": "Event description"},
+			"location": {"type": "string", "description": "Event location"},
+			"start": {"type": "string", "description": "Start time (RFC3339 or date YYYY-MM-DD)"},
+			"end": {"type": "string", "description": "End time (RFC3339 or date; default: 1 hour after start)"},
+			"timeZone": {"type": "string", "description": "Time zone (e.g. Asia/Tokyo)"},
+			"attendees": {"type": "array", "items": {"type": "string"}, "description": "Attendee email addresses"},
+			"allDay": {"type": "boolean", "description": "Create as all-day event"},
+			"text": {"type": "string", "description": "Natural language schedule (e.g. '明日2時の会議', 'meeting tomorrow at 2pm')"}
+		}
+	}`, func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			calendar.EventInput
+			Account string `json:"account"`
+			Text    string `json:"text"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		in := args.EventInput
+		if args.Text != "" && in.Start == "" {
+			parsed, err := calendar.ParseNaturalSchedule(args.Text)
+			if err != nil {
+				return "", err
+			}
+			in = *parsed
+		}
+		if in.Summary == "" || in.Start == "" {
+			return "", fmt.Errorf("summary and start (or text) are required")
+		}
+		ev, err := s.CreateEvent(ctx, args.Account, in)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(ev)
+	}, false)
+
+	add("calendar_update", "Update an existing calendar event; only the given fields change. Use the id and account from calendar_list.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account of the event"},
+			"eventId": {"type": "string", "description": "Event ID to update"},
+			"summary": {"type": "string", "description": "New event title"},
+			"description": {"type": "string", "description": "New description"},
+			"location": {"type": "string", "description": "New location"},
+			"start": {"type": "string", "description": "New start time (RFC3339)"},
+			"end": {"type": "string", "description": "New end time (RFC3339)"},
+			"timeZone": {"type": "string", "description": "Time zone"},
+			"attendees": {"type": "array", "items": {"type": "string"}, "description": "Updated attendee emails"},
+			"allDay": {"type": "boolean", "description": "All-day event flag"}
+		},
+		"required": ["eventId"]
+	}`, func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			calendar.EventInput
+			Account string `json:"account"`
+			EventID string `json:"eventId"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.EventID == "" {
+			return "", fmt.Errorf("eventId is required")
+		}
+		ev, err := s.UpdateEvent(ctx, args.Account, args.EventID, args.EventInput)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(ev)
+	}, false)
+
+	add("calendar_delete", "Delete a calendar event by ID. Use the id and account from calendar_list.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account of the event"},
+			"eventId": {"type": "string", "description": "Event ID to delete"}
+		},
+		"required": ["eventId"]
+	}`, func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account string `json:"account"`
+			EventID string `json:"eventId"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.EventID == "" {
+			return "", fmt.Errorf("eventId is required")
+		}
+		if err := s.DeleteEvent(ctx, args.Account, args.EventID); err != nil {
+			return "", err
+		}
+		return "Event deleted.", nil
+	}, true)
+
+	add("calendar_search", "Search calendar events by text, in every account unless one is named.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account name (default all)"},
+			"query": {"type": "string", "description": "Text to find in titles, descriptions and locations"},
+			"timeMin": {"type": "string", "description": "Start of time range (RFC3339, default: 30 days ago)"},
+			"timeMax": {"type": "string", "description": "End of time range (RFC3339, default: 90 days from now)"}
+		},
+		"required": ["query"]
+	}`, func(ctx context.Context, s *calendar.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account string `json:"account"`
+			Query   string `json:"query"`
+			TimeMin string `json:"timeMin"`
+			TimeMax string `json:"timeMax"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if strings.TrimSpace(args.Query) == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		now := time.Now()
+		if args.TimeMin == "" {
+			args.TimeMin = now.AddDate(0, 0, -30).Format(time.RFC3339)
+		}
+		if args.TimeMax == "" {
+			args.TimeMax = now.AddDate(0, 0, 90).Format(time.RFC3339)
+		}
+		events, err := s.SearchEvents(ctx, args.Account, args.Query, args.TimeMin, args.TimeMax)
+		if err != nil {
+			return "", err
+		}
+		if len(events) == 0 {
+			return "No matching events.", nil
+		}
+		return marshalIndent(events)
+	}, false)
+}
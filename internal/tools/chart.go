@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tetora/internal/chart"
+	"tetora/internal/config"
+)
+
+// RegisterChartTools registers chart_render. Charts are drawn locally and
+// saved under the charts directory; the Markdown image the tool returns is
+// shown by the dashboard and uploaded as an image by the chat bots.
+func RegisterChartTools(r *Registry, cfg *config.Config, enabled func(string) bool) {
+	if !enabled("chart_render") {
+		return
+	}
+	r.Register(&ToolDef{
+		Name:        "chart_render",
+		Description: "Draw a line, bar or pie chart from tabular data as a PNG (default) or SVG image. Pass labels and series, or a CSV table whose first row names the series and first column holds the labels. Returns a Markdown image line; put it in your reply as-is to show the chart. PNG labels are ASCII; use SVG for other scripts.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"type": {"type": "string", "enum": ["line", "bar", "pie"], "description": "Default bar"},
+				"title": {"type": "string", "description": "Chart title"},
+				"labels": {"type": "array", "items": {"type": "string"}, "description": "X-axis categories, or pie slices"},
+				"series": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "values": {"type": "array", "items": {"type": "number"}}}, "required": ["values"]}, "description": "One value per label; a pie chart draws the first series"},
+				"table": {"type": "string", "description": "CSV or tab-separated table instead of labels and series"},
+				"format": {"type": "string", "enum": ["png", "svg"], "description": "Default png"},
+				"width": {"type": "integer", "description": "Pixels, default 800"},
+				"height": {"type": "integer", "description": "Pixels, default 450"}
+			}
+		}`),
+		Keywords: []string{"chart", "graph", "plot", "trend", "visualize", "report", "briefing"},
+		Handler:  toolChartRender,
+		Builtin:  true,
+	})
+}
+
+func toolChartRender(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	var args struct {
+		Type   string         `json:"type"`
+		Title  string         `json:"title"`
+		Labels []string       `json:"labels"`
+		Series []chart.Series `json:"series"`
+		Table  string         `json:"table"`
+		Format string         `json:"format"`
+		Width  int            `json:"width"`
+		Height int            `json:"height"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	c := chart.Chart{Kind: chart.Kind(args.Type), Title: args.Title, Labels: args.Labels, Series: args.Series, Width: args.Width, Height: args.Height}
+	if args.Table != "" {
+		var err error
+		if c.Labels, c.Series, err = chart.ParseTable(args.Table); err != nil {
+			return "", err
+		}
+	}
+
+	var data []byte
+	var err error
+	switch args.Format {
+	case "", "png":
+		args.Format = "png"
+		data, err = c.PNG()
+	case "svg":
+		data, err = c.SVG()
+	default:
+		return "", fmt.Errorf("format must be png or svg")
+	}
+	if err != nil {
+		return "", err
+	}
+	name := args.Title
+	if name == "" {
+		name = "chart"
+	}
+	rel, err := chart.Save(cfg.ChartsDir(), name, args.Format, data, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("save chart: %w", err)
+	}
+	alt := strings.NewReplacer("[", "(", "]", ")", "\n", " ").Replace(args.Title)
+	if alt == "" {
+		alt = "chart"
+	}
+	return fmt.Sprintf("Saved %s (%d bytes). Include this line in your reply to show the chart:\n\n![%s](%s)",
+		filepath.Join(cfg.ChartsDir(), rel), len(data), alt, chart.URL(rel)), nil
+}
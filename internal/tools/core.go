@@ -186,17 +186,22 @@ func RegisterCoreTools(r *Registry, cfg *config.Config, enabled func(string) boo
 	if enabled("cron_create") {
 		r.Register(&ToolDef{
 			Name:        "cron_create",
-			Description: "Create or update a cron job",
+			Description: "Create or update a cron job or a one-off reminder. Tell the user how the schedule was read.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
 					"name": {"type": "string", "description": "Job name"},
-					"schedule": {"type": "string", "description": "Cron schedule or interval (e.g., '@hourly', '*/5m')"},
+					"schedule": {"type": "string", "description": "5-field cron expression (e.g., '0 9 * * 1-5')"},
+					"when": {"type": "string", "description": "Schedule in plain English instead of a cron expression (e.g., 'every weekday at 9am', 'in 20 minutes', 'first Monday of the month'); one-off times run once"},
 					"prompt": {"type": "string", "description": "Task prompt"},
 					"agent": {"type": "string", "description": "Agent name (optional)"},
-					"role": {"type": "string", "description": "Deprecated alias for agent"}
+					"role": {"type": "string", "description": "Deprecated alias for agent"},
+					"businessDaysOnly": {"type": "boolean", "description": "Skip weekends and public holidays of the work calendar"},
+					"holidays": {"type": "string", "description": "Skip holidays of this calendar: country code (e.g., 'JP', 'US') or ICS URL"},
+					"quietHours": {"type": "object", "description": "Hold notifications during this daily range", "properties": {"start": {"type": "string", "description": "HH:MM"}, "end": {"type": "string", "description": "HH:MM"}}},
+					"user": {"type": "string", "description": "Family member the job belongs to; applies their calendar and quiet hours"}
 				},
-				"required": ["name", "schedule", "prompt"]
+				"required": ["name", "prompt"]
 			}`),
 			Handler:     deps.CronCreateHandler,
 			Builtin:     true,
@@ -24,10 +24,11 @@ type DailyDeps struct {
 	RSSList Handler
 
 	// Translate
-	Translate      Handler
-	DetectLanguage Handler
+	Translate         Handler
+	TranslateGlossary Handler
+	DetectLanguage    Handler
 
-	// Notes / Obsidian
+	// Notes (Obsidian vault or Notion)
 	NoteCreate Handler
 	NoteRead   Handler
 	NoteAppend Handler
@@ -151,7 +152,7 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 	if enabled("translate") && cfg.Translate.Enabled {
 		r.Register(&ToolDef{
 			Name:        "translate",
-			Description: "Translate text between languages (via Lingva or DeepL)",
+			Description: "Translate text between languages (via Lingva, DeepL or an LLM). Glossary terms are kept or translated as the user defined them.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -165,6 +166,25 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 			Builtin: true,
 		})
 	}
+	if enabled("translate_glossary") && cfg.Translate.Enabled {
+		r.Register(&ToolDef{
+			Name:        "translate_glossary",
+			Description: "Manage the translation glossary: names and terms that must be kept as is or always translated a certain way",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"action": {"type": "string", "enum": ["list", "set", "delete"], "description": "Action (default list)"},
+					"id": {"type": "integer", "description": "Entry ID (for delete)"},
+					"term": {"type": "string", "description": "Source term (for set)"},
+					"lang": {"type": "string", "description": "Target language the entry applies to; empty for all (for set)"},
+					"translation": {"type": "string", "description": "Required translation; empty keeps the term as is (for set)"},
+					"note": {"type": "string", "description": "Optional note"}
+				}
+			}`),
+			Handler: deps.TranslateGlossary,
+			Builtin: true,
+		})
+	}
 	if enabled("detect_language") && cfg.Translate.Enabled {
 		r.Register(&ToolDef{
 			Name:        "detect_language",
@@ -183,11 +203,11 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 
 	// Image generation tools are registered by RegisterImageGenTools in registerBuiltins.
 
-	// --- Notes/Obsidian Integration ---
-	if enabled("note_create") && false {
+	// --- Notes Integration (Obsidian vault or Notion) ---
+	if enabled("note_create") && cfg.Notes.Enabled {
 		r.Register(&ToolDef{
 			Name:        "note_create",
-			Description: "Create a new note in the Obsidian vault. Supports nested paths (e.g. 'daily/2024-01-15'). Auto-appends .md if no extension given.",
+			Description: "Create a note, replacing one of the same name: a file in the Obsidian vault, or a page in Notion with notes.backend notion. Supports nested paths (e.g. 'daily/2024-01-15'). Auto-appends .md if no extension given.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -201,10 +221,10 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 		})
 	}
 
-	if enabled("note_read") && false {
+	if enabled("note_read") && cfg.Notes.Enabled {
 		r.Register(&ToolDef{
 			Name:        "note_read",
-			Description: "Read a note from the Obsidian vault. Returns content, tags, and wikilinks.",
+			Description: "Read a note as markdown. Returns content, tags, and wikilinks.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -217,7 +237,7 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 		})
 	}
 
-	if enabled("note_append") && false {
+	if enabled("note_append") && cfg.Notes.Enabled {
 		r.Register(&ToolDef{
 			Name:        "note_append",
 			Description: "Append content to an existing note (creates if not exists).",
@@ -234,10 +254,10 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 		})
 	}
 
-	if enabled("note_list") && false {
+	if enabled("note_list") && cfg.Notes.Enabled {
 		r.Register(&ToolDef{
 			Name:        "note_list",
-			Description: "List notes in the vault. Optionally filter by path prefix.",
+			Description: "List notes. Optionally filter by path prefix (title prefix in Notion).",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -249,10 +269,10 @@ func RegisterDailyTools(r *Registry, cfg *config.Config, enabled func(string) bo
 		})
 	}
 
-	if enabled("note_search") && false {
+	if enabled("note_search") && cfg.Notes.Enabled {
 		r.Register(&ToolDef{
 			Name:        "note_search",
-			Description: "Search notes using TF-IDF full-text search. Returns ranked results with snippets.",
+			Description: "Search notes: TF-IDF full-text search over the vault, or Notion search. Returns ranked results with snippets.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"tetora/internal/audit"
+	"tetora/internal/config"
+	"tetora/internal/dbquery"
+)
+
+// RegisterDBQueryTools registers db_query when database connections are
+// configured. Agent restrictions are applied by the dispatcher; the
+// handler enforces read-only queries and audits every attempt.
+func RegisterDBQueryTools(r *Registry, cfg *config.Config, enabled func(string) bool) {
+	if len(cfg.Databases.Connections) == 0 || !enabled("db_query") {
+		return
+	}
+	names := make([]string, 0, len(cfg.Databases.Connections))
+	for name := range cfg.Databases.Connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "Run one read-only SQL query (SELECT, WITH, EXPLAIN, SHOW) on a configured database. Returns at most %d rows as a table with a summary of numeric columns; aggregate in SQL rather than fetching raw rows. Connections:", cfg.Databases.MaxRowsOrDefault())
+	for _, name := range names {
+		c := cfg.Databases.Connections[name]
+		fmt.Fprintf(&desc, "\n- %s (%s)", name, c.Driver)
+		if c.Description != "" {
+			desc.WriteString(": " + c.Description)
+		}
+	}
+	namesJSON, _ := json.Marshal(names)
+
+	r.Register(&ToolDef{
+		Name:        "db_query",
+		Description: desc.String(),
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"connection": {"type": "string", "enum": ` + string(namesJSON) + `, "description": "Configured connection name"},
+				"query": {"type": "string", "description": "A single read-only SQL statement in the database's dialect"}
+			},
+			"required": ["connection", "query"]
+		}`),
+		Keywords:    []string{"sql", "database", "query", "data", "table", "report", "analytics"},
+		Handler:     toolDBQuery,
+		Builtin:     true,
+		RequireAuth: true,
+	})
+}
+
+func toolDBQuery(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	var args struct {
+		Connection string `json:"connection"`
+		Query      string `json:"query"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	detail := fmt.Sprintf("connection=%s query=%s", args.Connection, strings.TrimSpace(args.Query))
+
+	c, ok := cfg.Databases.Connections[args.Connection]
+	if !ok {
+		return "", fmt.Errorf("unknown connection %q", args.Connection)
+	}
+	if _, err := dbquery.Check(c.Driver, args.Query); err != nil {
+		audit.LogCtx(ctx, cfg.HistoryDB, "db.denied", "tool", detail+" reason="+err.Error(), "")
+		return "", err
+	}
+
+	res, err := dbquery.Query(ctx, cfg.Databases, args.Connection, args.Query)
+	if err != nil {
+		audit.LogCtx(ctx, cfg.HistoryDB, "db.query", "tool", detail+" error="+err.Error(), "")
+		return "", err
+	}
+	audit.LogCtx(ctx, cfg.HistoryDB, "db.query", "tool",
+		fmt.Sprintf("%s rows=%d truncated=%v duration=%dms", detail, len(res.Rows), res.Truncated, res.DurationMs), "")
+	return dbquery.Format(res), nil
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tetora/internal/audit"
+	"tetora/internal/config"
+	"tetora/internal/docgen"
+	"tetora/internal/integration/oauthif"
+)
+
+// DocumentDeps holds the dependencies of the document tools.
+type DocumentDeps struct {
+	// TaskOutput returns the Markdown output of a task's last run.
+	TaskOutput func(ctx context.Context, taskID string) (string, error)
+	// StoreFile adds a rendered document to the file manager and returns its
+	// ID. Nil when the file manager is off.
+	StoreFile func(ctx context.Context, name string, data []byte, taskID string) (string, error)
+	// OAuth returns the requester used to send email.
+	OAuth func(ctx context.Context) oauthif.Requester
+}
+
+// RegisterDocumentTools registers document_render and document_send when
+// documents are enabled. document_send delivers to other people and is an
+// external action.
+func RegisterDocumentTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps DocumentDeps) {
+	if !cfg.Documents.Enabled {
+		return
+	}
+	keywords := []string{"pdf", "docx", "document", "invoice", "report", "brief", "export", "attachment"}
+
+	if enabled("document_render") {
+		templates := make([]string, 0, len(cfg.Documents.Templates))
+		for name := range cfg.Documents.Templates {
+			templates = append(templates, name)
+		}
+		desc := "Render Markdown, or the output of a task's last run, as a styled PDF or DOCX (invoices, reports, briefs) and save it as an artifact. Returns the path to pass to document_send. PDFs cover Western European text; use DOCX for other scripts."
+		if len(templates) > 0 {
+			sort.Strings(templates)
+			desc += " Templates: " + strings.Join(templates, ", ") + "."
+		}
+		r.Register(&ToolDef{
+			Name:        "document_render",
+			Description: desc,
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"markdown": {"type": "string", "description": "Markdown to render"},
+					"taskId": {"type": "string", "description": "Render the output of this task's last run instead"},
+					"format": {"type": "string", "enum": ["pdf", "docx"], "description": "Default pdf"},
+					"title": {"type": "string", "description": "Document title; default the first heading"},
+					"template": {"type": "string", "description": "Letterhead template name; default the configured default"},
+					"filename": {"type": "string", "description": "File name without extension; default from the title"}
+				}
+			}`),
+			Keywords: keywords,
+			Handler:  toolDocumentRender(deps),
+			Builtin:  true,
+		})
+	}
+	if enabled("document_send") {
+		r.Register(&ToolDef{
+			Name:        "document_send",
+			Description: "Send a document made by document_render by email (as an attachment from the owner's Gmail) and/or to a chat channel: telegram, discord, slack, or discord:<channelId> / slack:<channelId>. Needs the owner's approval.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path returned by document_render"},
+					"email": {"type": "array", "items": {"type": "string"}, "description": "Recipient addresses"},
+					"channel": {"type": "string", "description": "telegram, discord, slack, discord:<channelId> or slack:<channelId>"},
+					"subject": {"type": "string", "description": "Email subject; default the file name"},
+					"message": {"type": "string", "description": "Email body or channel caption"}
+				},
+				"required": ["path"]
+			}`),
+			Keywords:    keywords,
+			Handler:     toolDocumentSend(deps),
+			Builtin:     true,
+			RequireAuth: true,
+		})
+	}
+}
+
+// documentStyle builds the rendering style from a configured template.
+func documentStyle(cfg *config.Config, name, title string) (docgen.Style, error) {
+	t, err := cfg.Documents.Template(name)
+	if err != nil {
+		return docgen.Style{}, err
+	}
+	st := docgen.Style{Title: title, Header: t.Header, Footer: t.Footer, Accent: t.Accent, PageSize: t.PageSize}
+	if t.Logo != "" {
+		p := t.Logo
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, p[2:])
+			}
+		}
+		if st.Logo, err = os.ReadFile(p); err != nil {
+			return st, fmt.Errorf("template logo: %w", err)
+		}
+	}
+	return st, nil
+}
+
+func toolDocumentRender(deps DocumentDeps) Handler {
+	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+		var args struct {
+			Markdown string `json:"markdown"`
+			TaskID   string `json:"taskId"`
+			Format   string `json:"format"`
+			Title    string `json:"title"`
+			Template string `json:"template"`
+			Filename string `json:"filename"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		format, err := docgen.ParseFormat(args.Format)
+		if err != nil {
+			return "", err
+		}
+		md := args.Markdown
+		if strings.TrimSpace(md) == "" {
+			if args.TaskID == "" {
+				return "", fmt.Errorf("markdown or taskId is required")
+			}
+			if deps.TaskOutput == nil {
+				return "", fmt.Errorf("task outputs are not available")
+			}
+			if md, err = deps.TaskOutput(ctx, args.TaskID); err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(md) == "" {
+				return "", fmt.Errorf("task %s has no output", args.TaskID)
+			}
+		}
+		st, err := documentStyle(cfg, args.Template, args.Title)
+		if err != nil {
+			return "", err
+		}
+		data, err := docgen.Render(format, md, st)
+		if err != nil {
+			return "", err
+		}
+
+		name := args.Filename
+		if name == "" {
+			name = args.Title
+		}
+		if name == "" {
+			name = docgen.Title(docgen.Parse(md))
+		}
+		path, err := docgen.Save(cfg.Documents.DirOrDefault(cfg.BaseDir), name, format, data, time.Now())
+		if err != nil {
+			return "", err
+		}
+		result := fmt.Sprintf("Rendered %s (%d KB).", path, (len(data)+1023)/1024)
+		if deps.StoreFile != nil {
+			if id, err := deps.StoreFile(ctx, filepath.Base(path), data, args.TaskID); err == nil {
+				result += " File manager ID: " + id + "."
+			}
+		}
+		audit.LogCtx(ctx, cfg.HistoryDB, "document.render", "tool",
+			fmt.Sprintf("path=%s task=%s template=%s bytes=%d", path, args.TaskID, args.Template, len(data)), "")
+		return result, nil
+	}
+}
+
+func toolDocumentSend(deps DocumentDeps) Handler {
+	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+		var args struct {
+			Path    string   `json:"path"`
+			Email   []string `json:"email"`
+			Channel string   `json:"channel"`
+			Subject string   `json:"subject"`
+			Message string   `json:"message"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if len(args.Email) == 0 && args.Channel == "" {
+			return "", fmt.Errorf("email or channel is required")
+		}
+		path, err := docgen.ResolvePath(cfg.Documents.DirOrDefault(cfg.BaseDir), args.Path)
+		if err != nil {
+			return "", err
+		}
+		format, err := docgen.FormatOf(path)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		f := docgen.File{Name: filepath.Base(path), ContentType: format.ContentType(), Data: data}
+
+		var sent []string
+		send := func(to string, err error) error {
+			detail := fmt.Sprintf("path=%s to=%s", path, to)
+			if err != nil {
+				audit.LogCtx(ctx, cfg.HistoryDB, "document.send", "tool", detail+" error="+err.Error(), "")
+				return err
+			}
+			audit.LogCtx(ctx, cfg.HistoryDB, "document.send", "tool", detail, "")
+			sent = append(sent, to)
+			return nil
+		}
+		if len(args.Email) > 0 {
+			if deps.OAuth == nil {
+				return "", fmt.Errorf("email: OAuth is not available")
+			}
+			subject := args.Subject
+			if subject == "" {
+				subject = f.Name
+			}
+			to := strings.Join(args.Email, ", ")
+			if err := send(to, docgen.Email(ctx, deps.OAuth(ctx), cfg.Documents.OAuthServiceOrDefault(), args.Email, subject, args.Message, f)); err != nil {
+				return "", err
+			}
+		}
+		if args.Channel != "" {
+			if err := send(args.Channel, sendToChannel(ctx, cfg, args.Channel, args.Message, f)); err != nil {
+				if len(sent) > 0 {
+					return "", fmt.Errorf("sent to %s, but %s failed: %w", strings.Join(sent, ", "), args.Channel, err)
+				}
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Sent %s to %s.", f.Name, strings.Join(sent, " and ")), nil
+	}
+}
+
+// sendToChannel posts a document to "telegram", "discord[:id]" or
+// "slack[:id]" with the configured bot.
+func sendToChannel(ctx context.Context, cfg *config.Config, channel, caption string, f docgen.File) error {
+	kind, id, _ := strings.Cut(channel, ":")
+	switch strings.ToLower(kind) {
+	case "telegram":
+		if cfg.Telegram.BotToken == "" || cfg.Telegram.ChatID == 0 {
+			return fmt.Errorf("telegram: botToken and chatID are not configured")
+		}
+		return docgen.Telegram(ctx, cfg.Telegram.BotToken, cfg.Telegram.ChatID, caption, f)
+	case "discord":
+		if id == "" {
+			id = cfg.Discord.ChannelID
+		}
+		if cfg.Discord.BotToken == "" || id == "" {
+			return fmt.Errorf("discord: botToken and a channel are not configured")
+		}
+		return docgen.Discord(ctx, cfg.Discord.BotToken, id, caption, f)
+	case "slack":
+		if id == "" {
+			id = cfg.Slack.DefaultChannel
+		}
+		if cfg.Slack.BotToken == "" || id == "" {
+			return fmt.Errorf("slack: botToken and a channel are not configured")
+		}
+		return docgen.Slack(ctx, cfg.Slack.BotToken, id, caption, f)
+	}
+	return fmt.Errorf("unknown channel %q: use telegram, discord or slack", channel)
+}
@@ -0,0 +1,296 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/forge"
+)
+
+// ForgeDeps holds external dependencies for the code forge tool handlers.
+type ForgeDeps struct {
+	// Forges returns the forge service. Nil until the daemon has created it.
+	Forges func(ctx context.Context) *forge.Service
+}
+
+// RegisterForgeTools registers the code forge tools when a GitHub, GitLab
+// or Gitea account is configured. Each call goes to the account serving
+// its repo. Reading issues, pull requests, diffs and checks is free;
+// commenting and creating branches and pull requests are held for the
+// owner's approval as external actions.
+func RegisterForgeTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps ForgeDeps) {
+	accounts := cfg.ForgeAccounts()
+	if len(accounts) == 0 {
+		return
+	}
+	var served []string
+	for _, a := range accounts {
+		repos := "any repo"
+		if len(a.Repos) > 0 {
+			repos = strings.Join(a.Repos, ", ")
+		}
+		served = append(served, fmt.Sprintf("%s (%s): %s", a.Name, a.Type, repos))
+	}
+	repos := strings.Join(served, "; ")
+	keywords := []string{"github", "gitlab", "gitea", "forgejo", "issue", "pull request", "merge request", "pr", "mr", "review", "diff", "branch", "ci", "checks"}
+	add := func(name, desc, schema string, h func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error), auth bool) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				s := deps.Forges(ctx)
+				if s == nil {
+					return "", fmt.Errorf("forges are not running")
+				}
+				var args struct {
+					Account string `json:"account"`
+					Repo    string `json:"repo"`
+				}
+				if err := json.Unmarshal(input, &args); err != nil {
+					return "", fmt.Errorf("invalid input: %w", err)
+				}
+				c, acct, repo, err := s.Open(args.Account, args.Repo)
+				if err != nil {
+					return "", err
+				}
+				return h(ctx, c, acct, repo, input)
+			},
+			Builtin:     true,
+			RequireAuth: auth,
+		})
+	}
+
+	add("forge_issues", "List a repo's issues and pull requests, most recently updated first. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"state": {"type": "string", "enum": ["open", "closed", "all"], "description": "Default open"},
+			"kind": {"type": "string", "enum": ["issue", "pr"], "description": "Only issues or only pull requests (default both)"},
+			"labels": {"type": "array", "items": {"type": "string"}, "description": "Only items with all these labels"},
+			"limit": {"type": "number", "description": "Maximum items (default 20)"}
+		}
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		var args struct {
+			State  string   `json:"state"`
+			Kind   string   `json:"kind"`
+			Labels []string `json:"labels"`
+			Limit  int      `json:"limit"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		items, err := c.ListIssues(ctx, repo, forge.ListOptions{State: args.State, Kind: args.Kind, Labels: args.Labels, Limit: args.Limit})
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "No matching issues or pull requests.", nil
+		}
+		return marshalIndent(items)
+	}, false)
+
+	add("forge_issue", "Read an issue or pull request (GitLab: merge request) with its comments. Pull requests include their branches, head commit and change counts. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"number": {"type": "number", "description": "Issue or pull request number"},
+			"kind": {"type": "string", "enum": ["issue", "pr"], "description": "pr for a GitLab merge request, numbered apart from issues (default issue)"}
+		},
+		"required": ["number"]
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		number, err := issueNumber(input)
+		if err != nil {
+			return "", err
+		}
+		is, comments, err := c.Issue(ctx, repo, number, isPR(input))
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(map[string]any{"repo": repo, "issue": is, "comments": comments})
+	}, false)
+
+	add("forge_pr_diff", "Read a pull request's unified diff, to review it. Long diffs are cut. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"number": {"type": "number", "description": "Pull request number"}
+		},
+		"required": ["number"]
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		number, err := issueNumber(input)
+		if err != nil {
+			return "", err
+		}
+		diff, err := c.Diff(ctx, repo, number)
+		if err != nil {
+			return "", err
+		}
+		limit := acct.MaxDiffBytesOrDefault()
+		if diff, truncated := forge.CutDiff(diff, limit); truncated {
+			return diff + fmt.Sprintf("\n\n[diff truncated at %d bytes; read the remaining files with forge_issue and the repository]", limit), nil
+		}
+		return diff, nil
+	}, false)
+
+	add("forge_checks", "List the CI checks of a pull request's head commit, or of a branch, tag or commit SHA. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"number": {"type": "number", "description": "Pull request number"},
+			"ref": {"type": "string", "description": "Branch, tag or commit SHA, instead of number"}
+		}
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		var args struct {
+			Number int    `json:"number"`
+			Ref    string `json:"ref"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		ref := args.Ref
+		if args.Number > 0 {
+			is, _, err := c.Issue(ctx, repo, args.Number, true)
+			if err != nil {
+				return "", err
+			}
+			if !is.PR {
+				return "", fmt.Errorf("#%d is an issue, not a pull request", args.Number)
+			}
+			ref = is.HeadSHA
+		}
+		if ref == "" {
+			return "", fmt.Errorf("number or ref is required")
+		}
+		runs, err := c.Checks(ctx, repo, ref)
+		if err != nil {
+			return "", err
+		}
+		if len(runs) == 0 {
+			return "No check runs for " + ref + ".", nil
+		}
+		return marshalIndent(map[string]any{"ref": ref, "checkRuns": runs})
+	}, false)
+
+	add("forge_comment", "Comment on an issue or pull request, e.g. to post a review. Markdown is supported. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"number": {"type": "number", "description": "Issue or pull request number"},
+			"body": {"type": "string", "description": "Comment text (Markdown)"},
+			"kind": {"type": "string", "enum": ["issue", "pr"], "description": "pr for a GitLab merge request, numbered apart from issues (default issue)"}
+		},
+		"required": ["number", "body"]
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		var args struct {
+			Number int    `json:"number"`
+			Body   string `json:"body"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.Number <= 0 {
+			return "", fmt.Errorf("number is required")
+		}
+		cm, err := c.Comment(ctx, repo, args.Number, isPR(input), args.Body)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(cm)
+	}, true)
+
+	add("forge_create_branch", "Create a branch in a repo from the head of another branch (default the repo's default branch). Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"branch": {"type": "string", "description": "New branch name"},
+			"from": {"type": "string", "description": "Branch to start from (optional)"}
+		},
+		"required": ["branch"]
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		var args struct {
+			Branch string `json:"branch"`
+			From   string `json:"from"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		sha, err := c.CreateBranch(ctx, repo, args.Branch, args.From)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Created branch %s in %s at %s.", args.Branch, repo, sha), nil
+	}, true)
+
+	add("forge_create_pr", "Open a pull request (GitLab: merge request). Files given are committed to the branch as one commit first, and the branch is created from base when it does not exist, so agent output can become a pull request in one call. Accounts: "+repos+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Forge account (optional; picked by repo)"},
+			"repo": {"type": "string", "description": "owner/name or URL (optional)"},
+			"title": {"type": "string", "description": "Pull request title"},
+			"body": {"type": "string", "description": "Pull request description (Markdown)"},
+			"branch": {"type": "string", "description": "Head branch"},
+			"base": {"type": "string", "description": "Target branch (default the repo's default branch)"},
+			"message": {"type": "string", "description": "Commit message for files (default the title)"},
+			"files": {"type": "array", "items": {"type": "object", "properties": {"path": {"type": "string"}, "content": {"type": "string"}}, "required": ["path", "content"]}, "description": "Files to write, with their full new content"},
+			"draft": {"type": "boolean", "description": "Open as a draft"}
+		},
+		"required": ["title", "branch"]
+	}`, func(ctx context.Context, c forge.Client, acct config.ForgeAccount, repo string, input json.RawMessage) (string, error) {
+		var args struct {
+			Title   string       `json:"title"`
+			Body    string       `json:"body"`
+			Branch  string       `json:"branch"`
+			Base    string       `json:"base"`
+			Message string       `json:"message"`
+			Files   []forge.File `json:"files"`
+			Draft   bool         `json:"draft"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		pr, err := c.CreatePR(ctx, repo, forge.PullRequest{
+			Title: args.Title, Body: args.Body, Branch: args.Branch, Base: args.Base,
+			Message: args.Message, Files: args.Files, Draft: args.Draft,
+		})
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(pr)
+	}, true)
+}
+
+func issueNumber(input json.RawMessage) (int, error) {
+	var args struct {
+		Number int `json:"number"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return 0, fmt.Errorf("invalid input: %w", err)
+	}
+	if args.Number <= 0 {
+		return 0, fmt.Errorf("number is required")
+	}
+	return args.Number, nil
+}
+
+// isPR reports whether a tool call names a pull request with kind "pr".
+func isPR(input json.RawMessage) bool {
+	var args struct {
+		Kind string `json:"kind"`
+	}
+	json.Unmarshal(input, &args)
+	return args.Kind == "pr"
+}
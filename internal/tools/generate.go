@@ -0,0 +1,328 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tetora/internal/audit"
+	"tetora/internal/chart"
+	"tetora/internal/config"
+	"tetora/internal/docgen"
+	"tetora/internal/ics"
+	"tetora/internal/integration/oauthif"
+	"tetora/internal/qr"
+)
+
+// GenerateDeps holds the dependencies of the generation tools.
+type GenerateDeps struct {
+	// OAuth returns the requester used to email invitations.
+	OAuth func(ctx context.Context) oauthif.Requester
+}
+
+// RegisterGenerateTools registers qr_code, calendar_invite and
+// calendar_invite_send. QR codes are saved with the charts, so replies show
+// them the same way; invitations are saved under the invites directory and
+// sending them is an external action.
+func RegisterGenerateTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps GenerateDeps) {
+	if enabled("qr_code") {
+		r.Register(&ToolDef{
+			Name:        "qr_code",
+			Description: fmt.Sprintf("Make a QR code image for a link, pairing URI or short text (up to %d bytes). Returns a Markdown image line; put it in your reply as-is to show the code.", qr.MaxBytes),
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"text": {"type": "string", "description": "Link or text to encode"},
+					"title": {"type": "string", "description": "Caption and file name"},
+					"format": {"type": "string", "enum": ["png", "svg"], "description": "Default png"}
+				},
+				"required": ["text"]
+			}`),
+			Keywords: []string{"qr", "qrcode", "pairing", "link", "scan"},
+			Handler:  toolQRCode,
+			Builtin:  true,
+		})
+	}
+	keywords := []string{"calendar", "invite", "invitation", "meeting", "event", "ics", "schedule"}
+	if enabled("calendar_invite") {
+		r.Register(&ToolDef{
+			Name:        "calendar_invite",
+			Description: "Create a calendar invitation (.ics) for an event, which any calendar can import. With an organizer and attendees, invitees can accept or decline. Returns the path to pass to calendar_invite_send.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"title": {"type": "string"},
+					"start": {"type": "string", "description": "RFC 3339, or 2006-01-02T15:04 in timeZone, or 2006-01-02 for an all-day event"},
+					"end": {"type": "string", "description": "Same forms as start; default one hour (or one day) later"},
+					"timeZone": {"type": "string", "description": "IANA zone for times without an offset; default the calendar's"},
+					"location": {"type": "string"},
+					"description": {"type": "string"},
+					"url": {"type": "string", "description": "Meeting link"},
+					"organizer": {"type": "string", "description": "The owner's email address, which invitees answer"},
+					"attendees": {"type": "array", "items": {"type": "string"}, "description": "Invitee email addresses"},
+					"reminderMinutes": {"type": "integer", "description": "Alert this many minutes before"}
+				},
+				"required": ["title", "start"]
+			}`),
+			Keywords: keywords,
+			Handler:  toolCalendarInvite,
+			Builtin:  true,
+		})
+	}
+	if enabled("calendar_invite_send") {
+		r.Register(&ToolDef{
+			Name:        "calendar_invite_send",
+			Description: "Send an invitation made by calendar_invite as an attachment: by email from the owner's Gmail (to its attendees by default) and/or to a chat channel: telegram, discord, slack, or discord:<channelId> / slack:<channelId>. Needs the owner's approval.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path returned by calendar_invite"},
+					"email": {"type": "array", "items": {"type": "string"}, "description": "Recipients; default the invitation's attendees"},
+					"channel": {"type": "string", "description": "telegram, discord, slack, discord:<channelId> or slack:<channelId>"},
+					"message": {"type": "string", "description": "Email body or channel caption"}
+				},
+				"required": ["path"]
+			}`),
+			Keywords:    keywords,
+			Handler:     toolCalendarInviteSend(deps),
+			Builtin:     true,
+			RequireAuth: true,
+		})
+	}
+}
+
+func toolQRCode(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	var args struct {
+		Text   string `json:"text"`
+		Title  string `json:"title"`
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	if args.Text == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	code, err := qr.Encode(args.Text)
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	switch args.Format {
+	case "", "png":
+		args.Format = "png"
+		if data, err = code.PNG(8); err != nil {
+			return "", err
+		}
+	case "svg":
+		data = code.SVG(320)
+	default:
+		return "", fmt.Errorf("format must be png or svg")
+	}
+	name := args.Title
+	if name == "" {
+		name = "qr code"
+	}
+	rel, err := chart.Save(cfg.ChartsDir(), name, args.Format, data, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("save qr code: %w", err)
+	}
+	alt := strings.NewReplacer("[", "(", "]", ")", "\n", " ").Replace(name)
+	return fmt.Sprintf("Saved %s. Include this line in your reply to show the code:\n\n![%s](%s)",
+		filepath.Join(cfg.ChartsDir(), rel), alt, chart.URL(rel)), nil
+}
+
+// parseEventTime reads RFC 3339, a local date-time in loc, or a date.
+func parseEventTime(s string, loc *time.Location) (t time.Time, date bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("time %q: use RFC 3339, 2006-01-02T15:04 or 2006-01-02", s)
+}
+
+func toolCalendarInvite(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	var args struct {
+		Title           string   `json:"title"`
+		Start           string   `json:"start"`
+		End             string   `json:"end"`
+		TimeZone        string   `json:"timeZone"`
+		Location        string   `json:"location"`
+		Description     string   `json:"description"`
+		URL             string   `json:"url"`
+		Organizer       string   `json:"organizer"`
+		Attendees       []string `json:"attendees"`
+		ReminderMinutes int      `json:"reminderMinutes"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	zone := args.TimeZone
+	if zone == "" {
+		zone = cfg.Calendar.TimeZone
+	}
+	loc := time.Local
+	if zone != "" {
+		l, err := time.LoadLocation(zone)
+		if err != nil {
+			return "", fmt.Errorf("time zone %q: %w", zone, err)
+		}
+		loc = l
+	}
+	ev := ics.Event{
+		Title:       args.Title,
+		Description: args.Description,
+		Location:    args.Location,
+		URL:         args.URL,
+		Organizer:   args.Organizer,
+		Attendees:   args.Attendees,
+		Reminder:    time.Duration(args.ReminderMinutes) * time.Minute,
+	}
+	var err error
+	if ev.Start, ev.AllDay, err = parseEventTime(args.Start, loc); err != nil {
+		return "", err
+	}
+	if args.End != "" {
+		var endDate bool
+		if ev.End, endDate, err = parseEventTime(args.End, loc); err != nil {
+			return "", err
+		}
+		if endDate != ev.AllDay {
+			return "", fmt.Errorf("start and end must both be dates or both be times")
+		}
+		if ev.AllDay {
+			ev.End = ev.End.AddDate(0, 0, 1) // the end date is inclusive for the caller
+		}
+	}
+	now := time.Now()
+	data, err := ev.Marshal(now)
+	if err != nil {
+		return "", err
+	}
+	path, err := docgen.Save(cfg.InvitesDir(), args.Title, docgen.Format("ics"), data, now)
+	if err != nil {
+		return "", fmt.Errorf("save invitation: %w", err)
+	}
+
+	when := ev.Start.In(loc).Format("Mon 2006-01-02 15:04 MST")
+	if ev.AllDay {
+		when = ev.Start.Format("Mon 2006-01-02") + " (all day)"
+	}
+	out := fmt.Sprintf("Saved %s\nEvent: %s, %s\nUID: %s\n", path, ev.Title, when, ev.UID)
+	if ev.Method() == "REQUEST" {
+		out += fmt.Sprintf("Invitees (%s) can accept or decline; send it with calendar_invite_send.", strings.Join(ev.Attendees, ", "))
+	} else {
+		out += "Without an organizer and attendees it is an event to add, not an invitation to answer."
+	}
+	return out, nil
+}
+
+func toolCalendarInviteSend(deps GenerateDeps) Handler {
+	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+		var args struct {
+			Path    string   `json:"path"`
+			Email   []string `json:"email"`
+			Channel string   `json:"channel"`
+			Message string   `json:"message"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		path, err := docgen.ResolvePath(cfg.InvitesDir(), args.Path)
+		if err != nil {
+			return "", err
+		}
+		if filepath.Ext(path) != ".ics" {
+			return "", fmt.Errorf("%s is not an invitation", filepath.Base(path))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		to := args.Email
+		if len(to) == 0 && args.Channel == "" {
+			to = inviteAttendees(data)
+			if len(to) == 0 {
+				return "", fmt.Errorf("the invitation has no attendees; give email or channel")
+			}
+		}
+		f := docgen.File{Name: "invite.ics", ContentType: ics.ContentType(data), Data: data}
+		subject := "Invitation: " + inviteSummary(data)
+
+		var sent []string
+		send := func(dest string, err error) error {
+			detail := fmt.Sprintf("path=%s to=%s", path, dest)
+			if err != nil {
+				audit.LogCtx(ctx, cfg.HistoryDB, "invite.send", "tool", detail+" error="+err.Error(), "")
+				return err
+			}
+			audit.LogCtx(ctx, cfg.HistoryDB, "invite.send", "tool", detail, "")
+			sent = append(sent, dest)
+			return nil
+		}
+		if len(to) > 0 {
+			if deps.OAuth == nil {
+				return "", fmt.Errorf("email: OAuth is not available")
+			}
+			body := args.Message
+			if body == "" {
+				body = subject + "\n\nOpen the attached invitation to add it to your calendar."
+			}
+			if err := send(strings.Join(to, ", "), docgen.Email(ctx, deps.OAuth(ctx), cfg.Documents.OAuthServiceOrDefault(), to, subject, body, f)); err != nil {
+				return "", err
+			}
+		}
+		if args.Channel != "" {
+			caption := args.Message
+			if caption == "" {
+				caption = subject
+			}
+			if err := send(args.Channel, sendToChannel(ctx, cfg, args.Channel, caption, f)); err != nil {
+				if len(sent) > 0 {
+					return "", fmt.Errorf("sent to %s, but %s failed: %w", strings.Join(sent, ", "), args.Channel, err)
+				}
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Sent %q to %s.", inviteSummary(data), strings.Join(sent, " and ")), nil
+	}
+}
+
+// inviteAttendees returns the attendee addresses of a saved invitation.
+func inviteAttendees(data []byte) []string {
+	var out []string
+	for _, l := range strings.Split(unfoldICS(data), "\n") {
+		if strings.HasPrefix(l, "ATTENDEE") {
+			if i := strings.Index(strings.ToLower(l), ":mailto:"); i >= 0 {
+				out = append(out, strings.TrimSpace(l[i+len(":mailto:"):]))
+			}
+		}
+	}
+	return out
+}
+
+// inviteSummary returns the title of a saved invitation.
+func inviteSummary(data []byte) string {
+	for _, l := range strings.Split(unfoldICS(data), "\n") {
+		if s, ok := strings.CutPrefix(strings.TrimSpace(l), "SUMMARY:"); ok {
+			return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(s)
+		}
+	}
+	return "event"
+}
+
+func unfoldICS(data []byte) string {
+	return strings.ReplaceAll(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n ", "")
+}
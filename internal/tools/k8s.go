@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"tetora/internal/audit"
+	"tetora/internal/config"
+	"tetora/internal/k8s"
+)
+
+// k8sOutputLimit keeps tool results short; kubectl's own cap is larger.
+const k8sOutputLimit = 8000
+
+// RegisterK8sTools registers the read-only k8s tools when clusters are
+// configured, and the mutating ones when at least one cluster allows
+// mutations. The mutating tools always need the owner's approval, which
+// the dispatcher asks for before the handler runs.
+func RegisterK8sTools(r *Registry, cfg *config.Config, enabled func(string) bool) {
+	if len(cfg.Kubernetes.Clusters) == 0 {
+		return
+	}
+	names := make([]string, 0, len(cfg.Kubernetes.Clusters))
+	var mutable []string
+	for name, c := range cfg.Kubernetes.Clusters {
+		names = append(names, name)
+		if c.Mutate {
+			mutable = append(mutable, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(mutable)
+	var clusters strings.Builder
+	for _, name := range names {
+		c := cfg.Kubernetes.Clusters[name]
+		ns := "any namespace"
+		if len(c.Namespaces) > 0 {
+			ns = "namespaces " + strings.Join(c.Namespaces, ", ")
+		}
+		fmt.Fprintf(&clusters, "\n- %s (%s)", name, ns)
+	}
+	clusterProp := func(names []string) string {
+		b, _ := json.Marshal(names)
+		return `"cluster": {"type": "string", "enum": ` + string(b) + `, "description": "Configured cluster name"},
+				"namespace": {"type": "string", "description": "Namespace; defaults to the cluster's first allowed namespace"}`
+	}
+	kinds := strings.Join(k8s.ReadKinds, ", ")
+	keywords := []string{"kubernetes", "k8s", "kubectl", "cluster", "pod", "deployment"}
+
+	read := []*ToolDef{
+		{
+			Name:        "k8s_get",
+			Description: "List Kubernetes objects of a kind (or get one by name) with kubectl get -o wide. Kinds: " + kinds + ". Clusters:" + clusters.String(),
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(names) + `,
+				"kind": {"type": "string", "description": "Resource kind, e.g. pods or deployments"},
+				"name": {"type": "string", "description": "Object name (optional)"},
+				"selector": {"type": "string", "description": "Label selector, e.g. app=web (optional)"}
+			},
+			"required": ["cluster", "kind"]
+		}`),
+			Handler: toolK8sGet,
+		},
+		{
+			Name:        "k8s_describe",
+			Description: "Describe one Kubernetes object (status, conditions, recent events). Kinds: " + kinds + ".",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(names) + `,
+				"kind": {"type": "string", "description": "Resource kind"},
+				"name": {"type": "string", "description": "Object name"}
+			},
+			"required": ["cluster", "kind", "name"]
+		}`),
+			Handler: toolK8sDescribe,
+		},
+		{
+			Name:        "k8s_logs",
+			Description: "Read a pod's recent log lines. Use previous=true for the last crashed container.",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(names) + `,
+				"pod": {"type": "string", "description": "Pod name"},
+				"container": {"type": "string", "description": "Container name, for pods with several"},
+				"previous": {"type": "boolean", "description": "Logs of the previous (terminated) container"},
+				"tail": {"type": "integer", "description": "Lines from the end (default 200, max 2000)"},
+				"since": {"type": "string", "description": "Only newer lines, e.g. 10m or 2h"}
+			},
+			"required": ["cluster", "pod"]
+		}`),
+			Handler: toolK8sLogs,
+		},
+		{
+			Name:        "k8s_events",
+			Description: "List a namespace's events, oldest first, optionally only those about one object.",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(names) + `,
+				"name": {"type": "string", "description": "Only events about this object (optional)"}
+			},
+			"required": ["cluster"]
+		}`),
+			Handler: toolK8sEvents,
+		},
+	}
+	for _, def := range read {
+		if enabled(def.Name) {
+			def.Keywords, def.Builtin = keywords, true
+			r.Register(def)
+		}
+	}
+	if len(mutable) == 0 {
+		return
+	}
+
+	mutate := []*ToolDef{
+		{
+			Name:        "k8s_rollout_restart",
+			Description: "Restart a deployment, statefulset or daemonset with a rolling restart. Needs the owner's approval.",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(mutable) + `,
+				"kind": {"type": "string", "enum": ["deployments", "statefulsets", "daemonsets"]},
+				"name": {"type": "string", "description": "Object name"}
+			},
+			"required": ["cluster", "kind", "name"]
+		}`),
+			Handler: toolK8sRolloutRestart,
+		},
+		{
+			Name:        "k8s_scale",
+			Description: "Set a deployment's or statefulset's replica count (0-100). Needs the owner's approval.",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(mutable) + `,
+				"kind": {"type": "string", "enum": ["deployments", "statefulsets"]},
+				"name": {"type": "string", "description": "Object name"},
+				"replicas": {"type": "integer", "description": "Desired replicas"}
+			},
+			"required": ["cluster", "kind", "name", "replicas"]
+		}`),
+			Handler: toolK8sScale,
+		},
+		{
+			Name:        "k8s_delete_pod",
+			Description: "Delete a pod so its controller recreates it. Needs the owner's approval.",
+			InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				` + clusterProp(mutable) + `,
+				"pod": {"type": "string", "description": "Pod name"}
+			},
+			"required": ["cluster", "pod"]
+		}`),
+			Handler: toolK8sDeletePod,
+		},
+	}
+	for _, def := range mutate {
+		if enabled(def.Name) {
+			def.Keywords, def.Builtin, def.RequireAuth = keywords, true, true
+			r.Register(def)
+		}
+	}
+}
+
+// k8sArgs is the input shared by the k8s tools.
+type k8sArgs struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Selector  string `json:"selector"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Previous  bool   `json:"previous"`
+	Tail      int    `json:"tail"`
+	Since     string `json:"since"`
+	Replicas  *int   `json:"replicas"`
+}
+
+func parseK8sArgs(cfg *config.Config, input json.RawMessage) (k8sArgs, k8s.Target, error) {
+	var args k8sArgs
+	if err := json.Unmarshal(input, &args); err != nil {
+		return args, k8s.Target{}, fmt.Errorf("invalid input: %w", err)
+	}
+	t, err := k8s.Resolve(cfg.Kubernetes, args.Cluster, args.Namespace)
+	return args, t, err
+}
+
+func toolK8sGet(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.Get(ctx, cfg.Kubernetes, t, args.Kind, args.Name, args.Selector)
+	return truncateK8sOutput(out), err
+}
+
+func toolK8sDescribe(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.Describe(ctx, cfg.Kubernetes, t, args.Kind, args.Name)
+	return truncateK8sOutput(out), err
+}
+
+func toolK8sLogs(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.Logs(ctx, cfg.Kubernetes, t, args.Pod, k8s.LogOptions{
+		Container: args.Container, Previous: args.Previous, Tail: args.Tail, Since: args.Since,
+	})
+	return truncateK8sOutput(out), err
+}
+
+func toolK8sEvents(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.Events(ctx, cfg.Kubernetes, t, args.Name)
+	return truncateK8sOutput(out), err
+}
+
+func toolK8sRolloutRestart(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.RolloutRestart(ctx, cfg.Kubernetes, t, args.Kind, args.Name)
+	auditK8sMutation(ctx, cfg, t, fmt.Sprintf("rollout restart %s/%s", args.Kind, args.Name), err)
+	return out, err
+}
+
+func toolK8sScale(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	if args.Replicas == nil {
+		return "", fmt.Errorf("replicas is required")
+	}
+	out, err := k8s.Scale(ctx, cfg.Kubernetes, t, args.Kind, args.Name, *args.Replicas)
+	auditK8sMutation(ctx, cfg, t, fmt.Sprintf("scale %s/%s replicas=%d", args.Kind, args.Name, *args.Replicas), err)
+	return out, err
+}
+
+func toolK8sDeletePod(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	args, t, err := parseK8sArgs(cfg, input)
+	if err != nil {
+		return "", err
+	}
+	out, err := k8s.DeletePod(ctx, cfg.Kubernetes, t, args.Pod)
+	auditK8sMutation(ctx, cfg, t, "delete pod "+args.Pod, err)
+	return out, err
+}
+
+func auditK8sMutation(ctx context.Context, cfg *config.Config, t k8s.Target, action string, err error) {
+	detail := fmt.Sprintf("cluster=%s namespace=%s %s", t.Name, t.Namespace, action)
+	if err != nil {
+		detail += " error=" + err.Error()
+	}
+	audit.LogCtx(ctx, cfg.HistoryDB, "k8s.mutate", "tool", detail, "")
+}
+
+func truncateK8sOutput(s string) string {
+	if len(s) <= k8sOutputLimit {
+		return s
+	}
+	return "... (truncated to the last 8000 bytes)\n" + s[len(s)-k8sOutputLimit:]
+}
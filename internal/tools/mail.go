@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/mail"
+)
+
+// MailDeps holds external dependencies for the email tool handlers.
+type MailDeps struct {
+	// Service returns the mail service. Nil until the daemon has started it.
+	Service func(ctx context.Context) *mail.Service
+}
+
+// RegisterMailTools registers the email tools when mail is enabled. Reading
+// never marks a message read; sending needs approval.
+func RegisterMailTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps MailDeps) {
+	if !cfg.Mail.Enabled || len(cfg.Mail.Accounts) == 0 {
+		return
+	}
+	var names []string
+	for _, a := range cfg.Mail.Accounts {
+		kind := a.Address
+		if !a.CanSend() {
+			kind += ", read-only"
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", a.Name, kind))
+	}
+	accounts := strings.Join(names, ", ")
+	keywords := []string{"email", "mail", "inbox", "message", "unread", "imap", "smtp"}
+	add := func(name, desc, schema string, h func(ctx context.Context, s *mail.Service, input json.RawMessage) (string, error), auth bool) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				s := deps.Service(ctx)
+				if s == nil {
+					return "", fmt.Errorf("mail is not running")
+				}
+				return h(ctx, s, input)
+			},
+			Builtin:     true,
+			RequireAuth: auth,
+		})
+	}
+
+	add("email_inbox", "Summarize unread mail: the unread count and newest unread messages with a snippet, for every account unless one is named. Use it for briefings. Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account name (default all)"},
+			"maxResults": {"type": "number", "description": "Newest unread messages to show per account (default 10)"}
+		}
+	}`, func(ctx context.Context, s *mail.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account    string `json:"account"`
+			MaxResults int    `json:"maxResults"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		overviews, err := s.Inbox(ctx, args.Account, args.MaxResults)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(overviews)
+	}, false)
+
+	add("email_search", "Search mail by text in headers and body, newest first, in every account unless one is named. Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account name (default all)"},
+			"query": {"type": "string", "description": "Text to find (empty lists the newest messages)"},
+			"unread": {"type": "boolean", "description": "Unread messages only"},
+			"days": {"type": "number", "description": "Only messages from the last N days"},
+			"maxResults": {"type": "number", "description": "Maximum number of results (default mail.maxResults, 20)"}
+		}
+	}`, func(ctx context.Context, s *mail.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account    string `json:"account"`
+			Query      string `json:"query"`
+			Unread     bool   `json:"unread"`
+			Days       int    `json:"days"`
+			MaxResults int    `json:"maxResults"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		q := mail.Query{Text: args.Query, Unread: args.Unread, Max: args.MaxResults}
+		if args.Days > 0 {
+			q.Since = time.Now().AddDate(0, 0, -args.Days)
+		}
+		msgs, err := s.Search(ctx, args.Account, q)
+		if err != nil {
+			return "", err
+		}
+		if len(msgs) == 0 {
+			return "No matching messages.", nil
+		}
+		return marshalIndent(msgs)
+	}, false)
+
+	add("email_read", "Read a message in full. Use the id and account from email_search or email_inbox; the message stays unread.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account of the message"},
+			"id": {"type": "string", "description": "Message ID"}
+		},
+		"required": ["id"]
+	}`, func(ctx context.Context, s *mail.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account string `json:"account"`
+			ID      string `json:"id"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.ID == "" {
+			return "", fmt.Errorf("id is required")
+		}
+		m, err := s.Read(ctx, args.Account, args.ID)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(m)
+	}, false)
+
+	add("email_send", "Send a plain-text email from the named account or the default one. Set replyTo to a message id from the same account to answer it in its thread.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Account to send from (default the configured default)"},
+			"to": {"type": "array", "items": {"type": "string"}, "description": "Recipient addresses"},
+			"cc": {"type": "array", "items": {"type": "string"}, "description": "CC recipients"},
+			"bcc": {"type": "array", "items": {"type": "string"}, "description": "BCC recipients"},
+			"subject": {"type": "string", "description": "Subject (default Re: the original when replying)"},
+			"body": {"type": "string", "description": "Email body (plain text)"},
+			"replyTo": {"type": "string", "description": "ID of the message being answered"}
+		},
+		"required": ["to", "body"]
+	}`, func(ctx context.Context, s *mail.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account string   `json:"account"`
+			To      []string `json:"to"`
+			Cc      []string `json:"cc"`
+			Bcc     []string `json:"bcc"`
+			Subject string   `json:"subject"`
+			Body    string   `json:"body"`
+			ReplyTo string   `json:"replyTo"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		id, err := s.Send(ctx, args.Account, mail.Outgoing{
+			To: args.To, Cc: args.Cc, Bcc: args.Bcc,
+			Subject: args.Subject, Body: args.Body, ReplyTo: args.ReplyTo,
+		})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Sent (Message-ID %s).", id), nil
+	}, true)
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"tetora/internal/config"
-	"tetora/internal/db"
+	"tetora/internal/knowledge"
 )
 
 // MemoryEntry represents a single memory key/value pair.
@@ -23,6 +24,12 @@ type MemoryDeps struct {
 	SetMemory    func(cfg *config.Config, role, key, value string) error
 	DeleteMemory func(cfg *config.Config, role, key string) error
 	SearchMemory func(cfg *config.Config, role, query string) ([]MemoryEntry, error)
+	// SetMemoryAging sets an entry's expiry (ttl from now) and confidence;
+	// zero values leave the current setting. Optional.
+	SetMemoryAging func(cfg *config.Config, key string, ttl time.Duration, confidence float64) error
+	// SearchKnowledge searches knowledgeDir within the agent's knowledge
+	// scope; an empty agent searches everything.
+	SearchKnowledge func(cfg *config.Config, agent, query string, limit int) ([]knowledge.SearchResult, error)
 }
 
 // RegisterMemoryTools registers memory and knowledge tools into the registry.
@@ -122,18 +129,20 @@ func RegisterMemoryTools(r *Registry, cfg *config.Config, enabled func(string) b
 					"value": {"type": "string", "description": "Memory content"},
 					"scope": {"type": "string", "description": "Agent name or empty for global (optional)"},
 					"source": {"type": "string", "description": "Origin identifier (optional)"},
-					"ttlDays": {"type": "number", "description": "Auto-expire after N days, 0=never (optional)"}
+					"ttlDays": {"type": "number", "description": "Auto-expire after N days, 0=never (optional)"},
+					"confidence": {"type": "number", "description": "How sure you are this is true, 0-1; low-confidence memories are not recalled into prompts (optional)"}
 				},
 				"required": ["namespace", "key", "value"]
 			}`),
 			Handler: func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
 				var args struct {
-					Namespace string `json:"namespace"`
-					Key       string `json:"key"`
-					Value     string `json:"value"`
-					Scope     string `json:"scope"`
-					Source    string `json:"source"`
-					TTLDays   int    `json:"ttlDays"`
+					Namespace  string  `json:"namespace"`
+					Key        string  `json:"key"`
+					Value      string  `json:"value"`
+					Scope      string  `json:"scope"`
+					Source     string  `json:"source"`
+					TTLDays    int     `json:"ttlDays"`
+					Confidence float64 `json:"confidence"`
 				}
 				if err := json.Unmarshal(input, &args); err != nil {
 					return "", fmt.Errorf("invalid input: %w", err)
@@ -142,9 +151,19 @@ func RegisterMemoryTools(r *Registry, cfg *config.Config, enabled func(string) b
 					return "", fmt.Errorf("namespace, key, and value are required")
 				}
 
+				if args.TTLDays < 0 || args.Confidence < 0 || args.Confidence > 1 {
+					return "", fmt.Errorf("ttlDays must be >= 0 and confidence between 0 and 1")
+				}
+
 				if err := deps.SetMemory(cfg, args.Scope, args.Key, args.Value); err != nil {
 					return "", fmt.Errorf("store failed: %w", err)
 				}
+				if deps.SetMemoryAging != nil {
+					ttl := time.Duration(args.TTLDays) * 24 * time.Hour
+					if err := deps.SetMemoryAging(cfg, args.Key, ttl, args.Confidence); err != nil {
+						return "", fmt.Errorf("store failed: %w", err)
+					}
+				}
 
 				result := map[string]any{"action": "stored", "key": args.Key}
 				b, _ := json.Marshal(result)
@@ -320,7 +339,7 @@ func RegisterMemoryTools(r *Registry, cfg *config.Config, enabled func(string) b
 	if enabled("knowledge_search") {
 		r.Register(&ToolDef{
 			Name:        "knowledge_search",
-			Description: "Search knowledge base using TF-IDF",
+			Description: "Search the knowledge base using TF-IDF. Results name the file and section; cite them as [source: file § Heading] when you use them.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -329,40 +348,7 @@ func RegisterMemoryTools(r *Registry, cfg *config.Config, enabled func(string) b
 				},
 				"required": ["query"]
 			}`),
-			Handler: func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
-				var args struct {
-					Query string `json:"query"`
-					Limit int    `json:"limit"`
-				}
-				if err := json.Unmarshal(input, &args); err != nil {
-					return "", fmt.Errorf("invalid input: %w", err)
-				}
-				if args.Query == "" {
-					return "", fmt.Errorf("query is required")
-				}
-				if args.Limit <= 0 {
-					args.Limit = 5
-				}
-
-				// Use existing knowledge search function if available, otherwise fallback to simple DB query.
-				query := fmt.Sprintf(`SELECT filename, snippet FROM knowledge WHERE content LIKE '%%%s%%' ORDER BY indexed_at DESC LIMIT %d`,
-					db.Escape(args.Query), args.Limit)
-				rows, err := db.Query(cfg.HistoryDB, query)
-				if err != nil {
-					return "", fmt.Errorf("query failed: %w", err)
-				}
-
-				var results []map[string]any
-				for _, row := range rows {
-					results = append(results, map[string]any{
-						"filename": fmt.Sprintf("%v", row["filename"]),
-						"snippet":  fmt.Sprintf("%v", row["snippet"]),
-					})
-				}
-
-				b, _ := json.Marshal(results)
-				return string(b), nil
-			},
+			Handler: MakeKnowledgeSearchHandler(deps),
 			Builtin: true,
 		})
 	}
@@ -421,7 +407,8 @@ func MakeMemoryGetHandler(deps MemoryDeps) Handler {
 }
 
 // MakeKnowledgeSearchHandler returns a standalone knowledge_search handler.
-func MakeKnowledgeSearchHandler() Handler {
+// Results are limited to the calling agent's knowledge scope.
+func MakeKnowledgeSearchHandler(deps MemoryDeps) Handler {
 	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
 		var args struct {
 			Query string `json:"query"`
@@ -436,17 +423,21 @@ func MakeKnowledgeSearchHandler() Handler {
 		if args.Limit <= 0 {
 			args.Limit = 5
 		}
-		query := fmt.Sprintf(`SELECT filename, snippet FROM knowledge WHERE content LIKE '%%%s%%' ORDER BY indexed_at DESC LIMIT %d`,
-			db.Escape(args.Query), args.Limit)
-		rows, err := db.Query(cfg.HistoryDB, query)
+		if deps.SearchKnowledge == nil {
+			return "", fmt.Errorf("knowledge search is not available")
+		}
+		found, err := deps.SearchKnowledge(cfg, AgentFromContext(ctx), args.Query, args.Limit)
 		if err != nil {
-			return "", fmt.Errorf("query failed: %w", err)
+			return "", fmt.Errorf("search failed: %w", err)
 		}
-		var results []map[string]any
-		for _, row := range rows {
+		results := []map[string]any{}
+		for _, r := range found {
 			results = append(results, map[string]any{
-				"filename": fmt.Sprintf("%v", row["filename"]),
-				"snippet":  fmt.Sprintf("%v", row["snippet"]),
+				"file":     r.Filename,
+				"heading":  r.Heading,
+				"line":     r.LineStart,
+				"snippet":  r.Snippet,
+				"citation": knowledge.Citation{File: r.Filename, Heading: r.Heading}.Marker(),
 			})
 		}
 		b, _ := json.Marshal(results)
@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/readlater"
+)
+
+// ReadLaterDeps holds external dependencies for the read-later tool handlers.
+type ReadLaterDeps struct {
+	// Service returns the read-later service. Nil until the daemon has started it.
+	Service func(ctx context.Context) *readlater.Service
+}
+
+// RegisterReadLaterTools registers the read-later tools when a service is
+// configured. Saving only adds to the owner's own reading list, so it is not
+// an external action.
+func RegisterReadLaterTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps ReadLaterDeps) {
+	if !cfg.ReadLater.Enabled() {
+		return
+	}
+	keywords := []string{"read later", "reading list", "readwise", "omnivore", "highlights", "save link", "article"}
+	add := func(name, desc, schema string, h func(ctx context.Context, s *readlater.Service, input json.RawMessage) (string, error)) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				s := deps.Service(ctx)
+				if s == nil {
+					return "", fmt.Errorf("read-later service is not running")
+				}
+				return h(ctx, s, input)
+			},
+			Builtin: true,
+		})
+	}
+
+	add("readlater_save", "Save a link to the owner's read-later list ("+cfg.ReadLater.Service+").", `{
+		"type": "object",
+		"properties": {
+			"url": {"type": "string", "description": "Link to save"},
+			"title": {"type": "string", "description": "Title (optional)"}
+		},
+		"required": ["url"]
+	}`, func(ctx context.Context, s *readlater.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			URL   string `json:"url"`
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		links := readlater.Links(args.URL)
+		if len(links) != 1 {
+			return "", fmt.Errorf("url must be a single http(s) link")
+		}
+		it, added, err := s.Save(ctx, links[0], args.Title, "tool")
+		if err != nil {
+			return "", err
+		}
+		if !added {
+			return fmt.Sprintf("Already in the reading list (saved %s).", it.SavedAt), nil
+		}
+		return "Saved to the reading list.", nil
+	})
+
+	add("readlater_list", "List the reading list, newest first, with reading progress and highlight counts. Set sync to pull progress and highlights first, and url to get one article's highlights.", `{
+		"type": "object",
+		"properties": {
+			"unread_only": {"type": "boolean", "description": "Only unread articles"},
+			"limit": {"type": "number", "description": "Maximum articles to return (default 20)"},
+			"sync": {"type": "boolean", "description": "Sync with the read-later service first"},
+			"url": {"type": "string", "description": "Return this article's highlights instead"}
+		}
+	}`, func(ctx context.Context, s *readlater.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			UnreadOnly bool   `json:"unread_only"`
+			Limit      int    `json:"limit"`
+			Sync       bool   `json:"sync"`
+			URL        string `json:"url"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.Sync {
+			if _, err := s.Sync(ctx); err != nil {
+				return "", fmt.Errorf("sync: %w", err)
+			}
+		}
+		if args.URL != "" {
+			hs, err := s.Highlights(args.URL)
+			if err != nil {
+				return "", err
+			}
+			if len(hs) == 0 {
+				return "No highlights for that article.", nil
+			}
+			return marshalIndent(hs)
+		}
+		if args.Limit <= 0 {
+			args.Limit = 20
+		}
+		items, err := s.Items(args.UnreadOnly, args.Limit)
+		if err != nil {
+			return "", err
+		}
+		if len(items) == 0 {
+			return "The reading list is empty.", nil
+		}
+		return marshalIndent(items)
+	})
+
+	add("readlater_insight", "Compare what was saved to the reading list with what was read over the last days (default 7).", `{
+		"type": "object",
+		"properties": {
+			"days": {"type": "number", "description": "Period in days (default 7)"}
+		}
+	}`, func(ctx context.Context, s *readlater.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Days int `json:"days"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		in, err := s.Insight(time.Now(), args.Days)
+		if err != nil {
+			return "", err
+		}
+		return in.Format(), nil
+	})
+}
@@ -39,6 +39,56 @@ type Result struct {
 // Handler is a function that executes a tool.
 type Handler func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error)
 
+type agentKey struct{}
+
+// WithAgent returns ctx carrying the name of the agent a tool call runs for.
+func WithAgent(ctx context.Context, agent string) context.Context {
+	return context.WithValue(ctx, agentKey{}, agent)
+}
+
+// AgentFromContext returns the agent set by WithAgent, or "" outside an
+// agent's tool call.
+func AgentFromContext(ctx context.Context) string {
+	agent, _ := ctx.Value(agentKey{}).(string)
+	return agent
+}
+
+// CostMeter collects the costs a tool handler reports for one call.
+type CostMeter struct {
+	mu      sync.Mutex
+	server  string
+	costUSD float64
+}
+
+type costMeterKey struct{}
+
+// WithCostMeter returns ctx carrying a fresh CostMeter for one tool call.
+func WithCostMeter(ctx context.Context) (context.Context, *CostMeter) {
+	m := &CostMeter{}
+	return context.WithValue(ctx, costMeterKey{}, m), m
+}
+
+// ReportCost records that a tool call spent usd on a paid service behind
+// server (an MCP server or plugin name). Calls outside a metered context
+// are ignored.
+func ReportCost(ctx context.Context, server string, usd float64) {
+	m, _ := ctx.Value(costMeterKey{}).(*CostMeter)
+	if m == nil || usd <= 0 {
+		return
+	}
+	m.mu.Lock()
+	m.server = server
+	m.costUSD += usd
+	m.mu.Unlock()
+}
+
+// Cost returns the reporting server and the total cost reported so far.
+func (m *CostMeter) Cost() (server string, usd float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.server, m.costUSD
+}
+
 // Registry manages available tools.
 type Registry struct {
 	mu         sync.RWMutex
@@ -74,6 +124,21 @@ func (r *Registry) Register(tool *ToolDef) {
 	r.mu.Unlock()
 }
 
+// Swap removes and adds tools under a single lock, so a reloaded plugin or
+// MCP server never leaves its tools half-registered. Names in both lists end
+// up registered with the new definition.
+func (r *Registry) Swap(remove []string, add []*ToolDef) {
+	r.mu.Lock()
+	for _, name := range remove {
+		delete(r.tools, name)
+	}
+	for _, t := range add {
+		r.tools[t.Name] = t
+	}
+	r.bm25Index = nil
+	r.mu.Unlock()
+}
+
 // rebuildBM25IndexLocked rebuilds the BM25 index from all registered tools.
 // Must be called with r.mu held (write lock).
 func (r *Registry) rebuildBM25IndexLocked() {
@@ -251,6 +316,41 @@ func (r *Registry) ListForProvider() []map[string]any {
 	return result
 }
 
+// --- Integrations ---
+
+// integrationPrefixes maps tool name prefixes to the external integration
+// the tools call, for integration health scoring. Tools served by MCP
+// servers and plugins are not listed.
+var integrationPrefixes = []struct{ prefix, name string }{
+	{"calendar_", "calendar"},
+	{"email_", "mail"},
+	{"tweet_", "twitter"},
+	{"social_", "social"},
+	{"readlater_", "readLater"},
+	{"forge_", "forges"},
+	{"note_", "notes"},
+	{"sheet_", "spreadsheets"},
+	{"k8s_", "kubernetes"},
+	{"ssh_", "ssh"},
+	{"image_generate", "imageGen"},
+	{"weather_", "weather"},
+	{"currency_", "currency"},
+	{"rss_", "rss"},
+	{"translate", "translate"},
+	{"web_search", "webSearch"},
+}
+
+// IntegrationOf returns the integration a tool calls, or "" for tools that
+// work inside Tetora or fetch arbitrary URLs.
+func IntegrationOf(tool string) string {
+	for _, p := range integrationPrefixes {
+		if strings.HasPrefix(tool, p.prefix) {
+			return p.name
+		}
+	}
+	return ""
+}
+
 // --- Tool Profiles ---
 
 // ProfileSets defines which tools are included in each profile.
@@ -309,3 +309,40 @@ func TestGetUsage(t *testing.T) {
 		t.Errorf("expected 0 for unknown tool, got %d", r.GetUsage("nonexistent"))
 	}
 }
+
+func TestRegistrySwap(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&ToolDef{Name: "mcp:fs:read", Description: "old read"})
+	r.Register(&ToolDef{Name: "mcp:fs:write", Description: "old write"})
+	r.Register(&ToolDef{Name: "exec"})
+
+	r.Swap([]string{"mcp:fs:read", "mcp:fs:write"}, []*ToolDef{
+		{Name: "mcp:fs:read", Description: "new read"},
+		{Name: "mcp:fs:list"},
+	})
+
+	if td, ok := r.Get("mcp:fs:read"); !ok || td.Description != "new read" {
+		t.Errorf("read = %+v, %v", td, ok)
+	}
+	if _, ok := r.Get("mcp:fs:write"); ok {
+		t.Error("write should be removed")
+	}
+	if _, ok := r.Get("mcp:fs:list"); !ok {
+		t.Error("list should be added")
+	}
+	if _, ok := r.Get("exec"); !ok {
+		t.Error("unrelated tools must survive a swap")
+	}
+}
+
+func TestReportCost(t *testing.T) {
+	ReportCost(context.Background(), "maps", 1) // no meter: ignored
+
+	ctx, m := WithCostMeter(context.Background())
+	ReportCost(ctx, "maps", 0.25)
+	ReportCost(ctx, "maps", 0)
+	ReportCost(ctx, "maps", 0.5)
+	if server, usd := m.Cost(); server != "maps" || usd != 0.75 {
+		t.Errorf("cost = %q, %v", server, usd)
+	}
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"tetora/internal/audit"
+	"tetora/internal/config"
+	"tetora/internal/integration/oauthif"
+	"tetora/internal/sheets"
+)
+
+// SheetsDeps holds the dependencies of the spreadsheet tools.
+type SheetsDeps struct {
+	// OAuth returns the requester used for Google Sheets.
+	OAuth func(ctx context.Context) oauthif.Requester
+}
+
+// RegisterSheetsTools registers sheet_read, sheet_append and sheet_update
+// when spreadsheets are enabled. Writes change the owner's own documents,
+// so they are audited but not approval-gated.
+func RegisterSheetsTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps SheetsDeps) {
+	if !cfg.Spreadsheets.Enabled {
+		return
+	}
+	where := fmt.Sprintf("A spreadsheet is a Google Sheets URL or ID, or the path of a local .xlsx file (relative paths are in %s).",
+		cfg.Spreadsheets.DirsOrDefault(cfg.BaseDir, cfg.FileManager)[0])
+	keywords := []string{"spreadsheet", "sheet", "google sheets", "excel", "xlsx", "tracker", "table", "report"}
+
+	if enabled("sheet_read") {
+		r.Register(&ToolDef{
+			Name: "sheet_read",
+			Description: fmt.Sprintf("Read a range of a spreadsheet as a table with row numbers and column letters, so cells can be addressed in sheet_update. %s Returns at most %d rows and lists the sheet names.",
+				where, cfg.Spreadsheets.MaxRowsOrDefault()),
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"spreadsheet": {"type": "string", "description": "Google Sheets URL or ID, or .xlsx path"},
+					"range": {"type": "string", "description": "A1 range, e.g. 'Tracker!A1:D50' or 'Tracker'; default the whole first sheet"}
+				},
+				"required": ["spreadsheet"]
+			}`),
+			Keywords: keywords,
+			Handler:  toolSheetRead(deps),
+			Builtin:  true,
+		})
+	}
+	if enabled("sheet_append") {
+		r.Register(&ToolDef{
+			Name:        "sheet_append",
+			Description: "Append rows after the last row of a sheet's table, e.g. a new entry in a tracker. " + where + " Values starting with = are formulas; a missing .xlsx file is created.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"spreadsheet": {"type": "string", "description": "Google Sheets URL or ID, or .xlsx path"},
+					"range": {"type": "string", "description": "Sheet name or the table's columns, e.g. 'Tracker' or 'Tracker!A:D'; default the first sheet"},
+					"rows": {"type": "array", "items": {"type": "array", "items": {}}, "description": "Rows of cell values (strings, numbers, booleans)"}
+				},
+				"required": ["spreadsheet", "rows"]
+			}`),
+			Keywords:    keywords,
+			Handler:     toolSheetWrite(deps, true),
+			Builtin:     true,
+			RequireAuth: true,
+		})
+	}
+	if enabled("sheet_update") {
+		r.Register(&ToolDef{
+			Name:        "sheet_update",
+			Description: "Overwrite cells starting at the top-left cell of a range, e.g. to tick off a task or correct an amount. " + where + " Values starting with = are formulas; an empty string clears a cell.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"spreadsheet": {"type": "string", "description": "Google Sheets URL or ID, or .xlsx path"},
+					"range": {"type": "string", "description": "A1 range whose top-left cell is written first, e.g. 'Tracker!C5'"},
+					"values": {"type": "array", "items": {"type": "array", "items": {}}, "description": "Rows of cell values (strings, numbers, booleans)"}
+				},
+				"required": ["spreadsheet", "range", "values"]
+			}`),
+			Keywords:    keywords,
+			Handler:     toolSheetWrite(deps, false),
+			Builtin:     true,
+			RequireAuth: true,
+		})
+	}
+}
+
+type sheetInput struct {
+	Spreadsheet string  `json:"spreadsheet"`
+	Range       string  `json:"range"`
+	Rows        [][]any `json:"rows"`
+	Values      [][]any `json:"values"`
+}
+
+// sheetTarget resolves the spreadsheet argument to a local path or a Google
+// client and spreadsheet ID.
+func sheetTarget(ctx context.Context, cfg *config.Config, deps SheetsDeps, spreadsheet string) (path string, g sheets.Google, id string, err error) {
+	spreadsheet = strings.TrimSpace(spreadsheet)
+	if spreadsheet == "" {
+		return "", g, "", fmt.Errorf("spreadsheet is required")
+	}
+	if sheets.IsLocal(spreadsheet) {
+		path, err = sheets.ResolvePath(cfg.Spreadsheets.DirsOrDefault(cfg.BaseDir, cfg.FileManager), spreadsheet)
+		return path, g, "", err
+	}
+	if id, err = sheets.GoogleID(spreadsheet); err != nil {
+		return "", g, "", err
+	}
+	if deps.OAuth == nil {
+		return "", g, "", fmt.Errorf("google sheets: OAuth is not available")
+	}
+	return "", sheets.Google{OAuth: deps.OAuth(ctx), Service: cfg.Spreadsheets.OAuthServiceOrDefault()}, id, nil
+}
+
+func toolSheetRead(deps SheetsDeps) Handler {
+	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+		var args sheetInput
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		path, g, id, err := sheetTarget(ctx, cfg, deps, args.Spreadsheet)
+		if err != nil {
+			return "", err
+		}
+		var grid *sheets.Grid
+		if path != "" {
+			grid, err = sheets.ReadXLSX(path, args.Range, cfg.Spreadsheets.MaxRowsOrDefault())
+		} else {
+			grid, err = g.Read(ctx, id, args.Range, cfg.Spreadsheets.MaxRowsOrDefault())
+		}
+		if err != nil {
+			return "", err
+		}
+		return sheets.Format(grid), nil
+	}
+}
+
+func toolSheetWrite(deps SheetsDeps, appendRows bool) Handler {
+	return func(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+		var args sheetInput
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		values, verb := args.Values, "update"
+		if appendRows {
+			values, verb = args.Rows, "append"
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("no values to write")
+		}
+		if !appendRows && strings.TrimSpace(args.Range) == "" {
+			return "", fmt.Errorf("range is required")
+		}
+		path, g, id, err := sheetTarget(ctx, cfg, deps, args.Spreadsheet)
+		if err != nil {
+			return "", err
+		}
+
+		var written string
+		switch {
+		case path != "" && appendRows:
+			written, err = sheets.AppendXLSX(path, args.Range, values)
+		case path != "":
+			written, err = sheets.UpdateXLSX(path, args.Range, values)
+		case appendRows:
+			written, err = g.Append(ctx, id, args.Range, values)
+		default:
+			written, err = g.Update(ctx, id, args.Range, values)
+		}
+		target := path
+		if target == "" {
+			target = id
+		}
+		detail := fmt.Sprintf("%s spreadsheet=%s range=%s rows=%d", verb, target, args.Range, len(values))
+		if err != nil {
+			audit.LogCtx(ctx, cfg.HistoryDB, "sheets.write", "tool", detail+" error="+err.Error(), "")
+			return "", err
+		}
+		audit.LogCtx(ctx, cfg.HistoryDB, "sheets.write", "tool", detail+" written="+written, "")
+		return fmt.Sprintf("Wrote %d row(s) to %s.", len(values), written), nil
+	}
+}
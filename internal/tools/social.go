@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/social"
+)
+
+// SocialDeps holds external dependencies for the Bluesky/Mastodon tool
+// handlers.
+type SocialDeps struct {
+	// Service returns the social service. Nil until the daemon has started it.
+	Service func(ctx context.Context) *social.Service
+}
+
+// RegisterSocialTools registers the Bluesky/Mastodon tools when social
+// accounts are configured. social_post and social_reply publish and are held
+// for the owner's approval as external actions; reading the inbox is not.
+func RegisterSocialTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps SocialDeps) {
+	if len(cfg.Social.Accounts) == 0 {
+		return
+	}
+	var names []string
+	for _, a := range cfg.Social.Accounts {
+		names = append(names, fmt.Sprintf("%s (%s)", a.Name, a.Type))
+	}
+	accounts := strings.Join(names, ", ")
+	keywords := []string{"bluesky", "mastodon", "fediverse", "post", "social media", "mention"}
+	add := func(name, desc, schema string, h func(ctx context.Context, s *social.Service, input json.RawMessage) (string, error), auth bool) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				s := deps.Service(ctx)
+				if s == nil {
+					return "", fmt.Errorf("social accounts are not running")
+				}
+				return h(ctx, s, input)
+			},
+			Builtin:     true,
+			RequireAuth: auth,
+		})
+	}
+
+	add("social_post", "Post to a Bluesky or Mastodon account. Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Configured account name"},
+			"text": {"type": "string", "description": "Post text (Bluesky 300, Mastodon 500 characters unless configured)"},
+			"reply_to": {"type": "string", "description": "Post to reply to: Bluesky AT URI or Mastodon status ID (optional)"}
+		},
+		"required": ["account", "text"]
+	}`, socialPost, true)
+
+	add("social_reply", "Reply to a Bluesky or Mastodon post, e.g. a mention from social_mentions. Accounts: "+accounts+".", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Configured account name"},
+			"post_id": {"type": "string", "description": "postId of the post to reply to"},
+			"text": {"type": "string", "description": "Reply text"}
+		},
+		"required": ["account", "post_id", "text"]
+	}`, func(ctx context.Context, s *social.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account string `json:"account"`
+			PostID  string `json:"post_id"`
+			Text    string `json:"text"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.PostID == "" {
+			return "", fmt.Errorf("post_id is required")
+		}
+		in, _ := json.Marshal(map[string]string{"account": args.Account, "text": args.Text, "reply_to": args.PostID})
+		return socialPost(ctx, s, in)
+	}, true)
+
+	add("social_mentions", "Read the Bluesky/Mastodon mentions inbox, newest first. Set refresh to fetch new mentions first and mark_read to mark the returned ones read.", `{
+		"type": "object",
+		"properties": {
+			"account": {"type": "string", "description": "Only this account (optional)"},
+			"unread_only": {"type": "boolean", "description": "Only unread mentions (default true)"},
+			"limit": {"type": "number", "description": "Maximum mentions to return (default 20)"},
+			"refresh": {"type": "boolean", "description": "Fetch new mentions from the network first"},
+			"mark_read": {"type": "boolean", "description": "Mark the returned mentions read"}
+		}
+	}`, func(ctx context.Context, s *social.Service, input json.RawMessage) (string, error) {
+		var args struct {
+			Account    string `json:"account"`
+			UnreadOnly *bool  `json:"unread_only"`
+			Limit      int    `json:"limit"`
+			Refresh    bool   `json:"refresh"`
+			MarkRead   bool   `json:"mark_read"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.Refresh {
+			for _, a := range s.Accounts() {
+				if args.Account != "" && a.Name != args.Account {
+					continue
+				}
+				if _, err := s.FetchMentions(ctx, a.Name); err != nil {
+					return "", fmt.Errorf("refresh %s: %w", a.Name, err)
+				}
+			}
+		}
+		if args.Limit <= 0 {
+			args.Limit = 20
+		}
+		ms, err := s.Mentions(args.Account, args.UnreadOnly == nil || *args.UnreadOnly, args.Limit)
+		if err != nil {
+			return "", err
+		}
+		if len(ms) == 0 {
+			return "No mentions.", nil
+		}
+		if args.MarkRead {
+			ids := make(map[string][]string)
+			for _, m := range ms {
+				ids[m.Account] = append(ids[m.Account], m.ID)
+			}
+			for account, list := range ids {
+				if err := s.MarkRead(account, list); err != nil {
+					return "", err
+				}
+			}
+		}
+		return marshalIndent(ms)
+	}, false)
+}
+
+func socialPost(ctx context.Context, s *social.Service, input json.RawMessage) (string, error) {
+	var args struct {
+		Account string `json:"account"`
+		Text    string `json:"text"`
+		ReplyTo string `json:"reply_to"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	if args.Account == "" {
+		return "", fmt.Errorf("account is required")
+	}
+	p, err := s.Post(ctx, args.Account, args.Text, args.ReplyTo)
+	if err != nil {
+		return "", err
+	}
+	return marshalIndent(p)
+}
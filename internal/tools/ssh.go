@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"tetora/internal/audit"
+	"tetora/internal/config"
+	"tetora/internal/sshexec"
+)
+
+// sshOutputLimit keeps each stream in the tool result short; the artifact
+// log has the full output.
+const sshOutputLimit = 4000
+
+// RegisterSSHTools registers ssh_exec when ssh hosts are configured. Agent
+// restrictions and approval for "suggest" hosts are applied by the
+// dispatcher before the handler runs; the handler enforces the allowlist
+// and observe-only hosts, and audits every attempt.
+func RegisterSSHTools(r *Registry, cfg *config.Config, enabled func(string) bool) {
+	if len(cfg.SSH.Hosts) == 0 || !enabled("ssh_exec") {
+		return
+	}
+	names := make([]string, 0, len(cfg.SSH.Hosts))
+	for name := range cfg.SSH.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var desc strings.Builder
+	desc.WriteString("Run an allowlisted command on a configured remote host over SSH. Output is saved as an artifact log. Hosts and allowed commands (\"*\" ends a prefix):")
+	for _, name := range names {
+		h := cfg.SSH.Hosts[name]
+		fmt.Fprintf(&desc, "\n- %s (%s): %s", name, h.TrustOrDefault(), strings.Join(h.Allow, ", "))
+	}
+	hostsJSON, _ := json.Marshal(names)
+
+	r.Register(&ToolDef{
+		Name:        "ssh_exec",
+		Description: desc.String(),
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"host": {"type": "string", "enum": ` + string(hostsJSON) + `, "description": "Configured host name"},
+				"command": {"type": "string", "description": "Command line; must match the host's allowlist and may not use shell operators"}
+			},
+			"required": ["host", "command"]
+		}`),
+		Keywords:    []string{"ssh", "remote", "server", "nas", "command", "host"},
+		Handler:     toolSSHExec,
+		Builtin:     true,
+		RequireAuth: true,
+	})
+}
+
+func toolSSHExec(ctx context.Context, cfg *config.Config, input json.RawMessage) (string, error) {
+	var args struct {
+		Host    string `json:"host"`
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	args.Command = strings.TrimSpace(args.Command)
+	detail := fmt.Sprintf("host=%s command=%s", args.Host, args.Command)
+
+	h, err := sshexec.Check(cfg.SSH, args.Host, args.Command)
+	if err != nil {
+		audit.LogCtx(ctx, cfg.HistoryDB, "ssh.denied", "tool", detail+" reason="+err.Error(), "")
+		return "", err
+	}
+	if h.TrustOrDefault() == "observe" {
+		audit.LogCtx(ctx, cfg.HistoryDB, "ssh.observe", "tool", detail, "")
+		return fmt.Sprintf("[OBSERVE] %s is observe-only; would run on %s: %s", args.Host, sshexec.Target(h), args.Command), nil
+	}
+
+	res, err := sshexec.Run(ctx, cfg, args.Host, args.Command)
+	if res != nil {
+		detail += fmt.Sprintf(" exit=%d duration=%dms artifact=%s", res.ExitCode, res.DurationMs, res.Artifact)
+	}
+	if err != nil {
+		detail += " error=" + err.Error()
+	}
+	audit.LogCtx(ctx, cfg.HistoryDB, "ssh.exec", "tool", detail, "")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "exit %d on %s in %dms\nartifact: %s\n", res.ExitCode, args.Host, res.DurationMs, res.Artifact)
+	if res.Stdout != "" {
+		fmt.Fprintf(&b, "\nstdout:\n%s\n", truncateSSHOutput(res.Stdout))
+	}
+	if res.Stderr != "" {
+		fmt.Fprintf(&b, "\nstderr:\n%s\n", truncateSSHOutput(res.Stderr))
+	}
+	return b.String(), nil
+}
+
+func truncateSSHOutput(s string) string {
+	if len(s) <= sshOutputLimit {
+		return s
+	}
+	return s[:sshOutputLimit] + "\n... (truncated; see the artifact)"
+}
@@ -0,0 +1,343 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/integration/twitter"
+)
+
+// TwitterDeps holds external dependencies for the Twitter/X tool handlers.
+type TwitterDeps struct {
+	// Queue returns the draft queue, whose Service posts and reads tweets.
+	// Nil until the daemon has started it.
+	Queue func(ctx context.Context) *twitter.Queue
+}
+
+// RegisterTwitterTools registers the Twitter/X tools when twitter.enabled.
+// Every tool that publishes (tweet_post, tweet_reply, tweet_dm,
+// tweet_post_thread, tweet_schedule) is held for the owner's approval as an
+// external action; composing and listing drafts is not.
+func RegisterTwitterTools(r *Registry, cfg *config.Config, enabled func(string) bool, deps TwitterDeps) {
+	if !cfg.Twitter.Enabled {
+		return
+	}
+	keywords := []string{"twitter", "x", "tweet", "post", "social media"}
+	add := func(name, desc, schema string, h func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error), auth bool) {
+		if !enabled(name) {
+			return
+		}
+		r.Register(&ToolDef{
+			Name:        name,
+			Description: desc,
+			InputSchema: json.RawMessage(schema),
+			Keywords:    keywords,
+			Handler: func(ctx context.Context, _ *config.Config, input json.RawMessage) (string, error) {
+				q := deps.Queue(ctx)
+				if q == nil {
+					return "", fmt.Errorf("twitter is not running")
+				}
+				return h(ctx, q, input)
+			},
+			Builtin:     true,
+			RequireAuth: auth,
+		})
+	}
+
+	add("tweet_post", "Post a tweet on Twitter/X. Optionally reply to an existing tweet. Longer text must go through tweet_post_thread.", `{
+		"type": "object",
+		"properties": {
+			"text": {"type": "string", "description": "Tweet text content"},
+			"reply_to": {"type": "string", "description": "Tweet ID to reply to (optional)"}
+		},
+		"required": ["text"]
+	}`, tweetPost, true)
+
+	add("tweet_reply", "Reply to a specific tweet on Twitter/X", `{
+		"type": "object",
+		"properties": {
+			"tweet_id": {"type": "string", "description": "ID of the tweet to reply to"},
+			"text": {"type": "string", "description": "Reply text content"}
+		},
+		"required": ["tweet_id", "text"]
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			TweetID string `json:"tweet_id"`
+			Text    string `json:"text"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.TweetID == "" {
+			return "", fmt.Errorf("tweet_id is required")
+		}
+		in, _ := json.Marshal(map[string]string{"text": args.Text, "reply_to": args.TweetID})
+		return tweetPost(ctx, q, in)
+	}, true)
+
+	add("tweet_dm", "Send a direct message to a Twitter/X user", `{
+		"type": "object",
+		"properties": {
+			"recipient_id": {"type": "string", "description": "Twitter user ID of the recipient"},
+			"text": {"type": "string", "description": "Message text"}
+		},
+		"required": ["recipient_id", "text"]
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			RecipientID string `json:"recipient_id"`
+			Text        string `json:"text"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.RecipientID == "" || strings.TrimSpace(args.Text) == "" {
+			return "", fmt.Errorf("recipient_id and text are required")
+		}
+		if err := q.Service().SendDM(ctx, args.RecipientID, args.Text); err != nil {
+			return "", err
+		}
+		return "Direct message sent.", nil
+	}, true)
+
+	add("tweet_read_timeline", "Read the authenticated user's home timeline (reverse chronological)", `{
+		"type": "object",
+		"properties": {
+			"max_results": {"type": "number", "description": "Maximum number of tweets to return (default 10, max 100)"}
+		}
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			MaxResults int `json:"max_results"`
+		}
+		json.Unmarshal(input, &args)
+		tweets, err := q.Service().ReadTimeline(ctx, args.MaxResults)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(tweets)
+	}, false)
+
+	add("tweet_search", "Search recent tweets on Twitter/X matching a query", `{
+		"type": "object",
+		"properties": {
+			"query": {"type": "string", "description": "Search query (supports Twitter search operators)"},
+			"max_results": {"type": "number", "description": "Maximum number of tweets to return (default 10, max 100)"}
+		},
+		"required": ["query"]
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			Query      string `json:"query"`
+			MaxResults int    `json:"max_results"`
+		}
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid input: %w", err)
+		}
+		if args.Query == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		tweets, err := q.Service().SearchTweets(ctx, args.Query, args.MaxResults)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(tweets)
+	}, false)
+
+	add("tweet_draft", "Compose a tweet or thread without posting it. Long text is split into numbered tweets (a line of --- forces a break); returns the draft ID and the parts as they will be posted.", `{
+		"type": "object",
+		"properties": {
+			"text": {"type": "string", "description": "Full text; split into a thread when it exceeds one tweet"},
+			"reply_to": {"type": "string", "description": "Tweet ID the thread replies to (optional)"},
+			"numbered": {"type": "boolean", "description": "Append i/N to each part of a thread (default true)"}
+		},
+		"required": ["text"]
+	}`, func(_ context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		d, err := composeDraft(q, input)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(d)
+	}, false)
+
+	add("tweet_schedule", "Schedule a draft (or new text) to be posted later, at an RFC3339 time or at the optimal time derived from past engagement. Needs the owner's approval.", `{
+		"type": "object",
+		"properties": {
+			"draft_id": {"type": "string", "description": "Draft to schedule (from tweet_draft)"},
+			"text": {"type": "string", "description": "Text to compose and schedule instead of a draft"},
+			"reply_to": {"type": "string", "description": "With text: tweet ID the thread replies to"},
+			"numbered": {"type": "boolean", "description": "With text: append i/N to each part (default true)"},
+			"at": {"type": "string", "description": "RFC3339 time, or \"optimal\" (default)"}
+		}
+	}`, func(_ context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			At string `json:"at"`
+		}
+		json.Unmarshal(input, &args)
+		at, err := q.ResolveTime(args.At, time.Now())
+		if err != nil {
+			return "", err
+		}
+		d, err := draftFor(q, input)
+		if err != nil {
+			return "", err
+		}
+		if d, err = q.Schedule(d.ID, at); err != nil {
+			return "", err
+		}
+		return marshalIndent(d)
+	}, true)
+
+	add("tweet_post_thread", "Post a draft (or new text) now as a thread, each tweet replying to the previous one. Needs the owner's approval.", `{
+		"type": "object",
+		"properties": {
+			"draft_id": {"type": "string", "description": "Draft to post (from tweet_draft)"},
+			"text": {"type": "string", "description": "Text to compose and post instead of a draft"},
+			"reply_to": {"type": "string", "description": "With text: tweet ID the thread replies to"},
+			"numbered": {"type": "boolean", "description": "With text: append i/N to each part (default true)"}
+		}
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		d, err := draftFor(q, input)
+		if err != nil {
+			return "", err
+		}
+		d, err = q.PostNow(ctx, d.ID)
+		if d == nil {
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("posted %d of %d parts of draft %s: %w", len(d.PostedIDs), len(d.Parts), d.ID, err)
+		}
+		return fmt.Sprintf("Thread posted (%d tweets): %s", len(d.PostedIDs), strings.Join(d.PostedIDs, ", ")), nil
+	}, true)
+
+	add("tweet_drafts", "List tweet drafts and scheduled posts", `{
+		"type": "object",
+		"properties": {
+			"status": {"type": "string", "enum": ["draft", "scheduled", "posted", "failed", "cancelled"], "description": "Only drafts with this status"},
+			"limit": {"type": "number", "description": "Maximum drafts (default 20)"}
+		}
+	}`, func(_ context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			Status string `json:"status"`
+			Limit  int    `json:"limit"`
+		}
+		json.Unmarshal(input, &args)
+		if args.Limit <= 0 {
+			args.Limit = 20
+		}
+		drafts, err := q.List(args.Status, args.Limit)
+		if err != nil {
+			return "", err
+		}
+		return marshalIndent(drafts)
+	}, false)
+
+	add("tweet_draft_cancel", "Cancel a tweet draft or scheduled post", `{
+		"type": "object",
+		"properties": {
+			"draft_id": {"type": "string", "description": "Draft to cancel"}
+		},
+		"required": ["draft_id"]
+	}`, func(_ context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			DraftID string `json:"draft_id"`
+		}
+		json.Unmarshal(input, &args)
+		if _, err := q.Cancel(args.DraftID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Draft %s cancelled.", args.DraftID), nil
+	}, false)
+
+	add("tweet_stats", "Engagement stats (impressions, likes, retweets, replies) of tweets posted through Tetora, the average engagement per hour of day, and the next optimal posting time", `{
+		"type": "object",
+		"properties": {
+			"days": {"type": "number", "description": "Look-back window in days (default 30)"},
+			"refresh": {"type": "boolean", "description": "Fetch current metrics from Twitter/X first"}
+		}
+	}`, func(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+		var args struct {
+			Days    int  `json:"days"`
+			Refresh bool `json:"refresh"`
+		}
+		json.Unmarshal(input, &args)
+		if args.Refresh {
+			if err := q.RefreshStats(ctx); err != nil {
+				return "", fmt.Errorf("refresh stats: %w", err)
+			}
+		}
+		stats, err := q.Stats(args.Days)
+		if err != nil {
+			return "", err
+		}
+		now := time.Now()
+		return marshalIndent(map[string]any{
+			"posts":       stats,
+			"byHour":      twitter.EngagementByHour(stats, now.Location()),
+			"optimalNext": q.OptimalTime(now).Format(time.RFC3339),
+		})
+	}, false)
+}
+
+// tweetPost posts one tweet and adds it to the engagement stats.
+func tweetPost(ctx context.Context, q *twitter.Queue, input json.RawMessage) (string, error) {
+	var args struct {
+		Text    string `json:"text"`
+		ReplyTo string `json:"reply_to"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	if strings.TrimSpace(args.Text) == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	t, err := q.Service().PostTweet(ctx, args.Text, args.ReplyTo)
+	if err != nil {
+		return "", err
+	}
+	q.Record("", []twitter.Tweet{*t}, time.Now())
+	return fmt.Sprintf("Tweet posted: %s", t.ID), nil
+}
+
+// composeDraft saves the text of a tweet_draft-style input as a draft.
+func composeDraft(q *twitter.Queue, input json.RawMessage) (*twitter.Draft, error) {
+	var args struct {
+		Text     string `json:"text"`
+		ReplyTo  string `json:"reply_to"`
+		Numbered *bool  `json:"numbered"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+	return q.Compose(args.Text, args.ReplyTo, "agent", args.Numbered == nil || *args.Numbered)
+}
+
+// draftFor returns the pending draft named by draft_id, or composes one from
+// text.
+func draftFor(q *twitter.Queue, input json.RawMessage) (*twitter.Draft, error) {
+	var args struct {
+		DraftID string `json:"draft_id"`
+	}
+	json.Unmarshal(input, &args)
+	if args.DraftID == "" {
+		return composeDraft(q, input)
+	}
+	d, err := q.Get(args.DraftID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("%w: %q", twitter.ErrNotFound, args.DraftID)
+	}
+	return d, nil
+}
+
+func marshalIndent(v any) (string, error) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,327 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as a
+// second factor for dashboard password logins.
+//
+// A login enrolls by generating a secret, adding it to an authenticator app
+// (the otpauth:// URI is the QR code payload) and confirming with a first
+// code. Confirmation issues single-use recovery codes, stored only as hashes.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/db"
+	"tetora/internal/log"
+)
+
+const (
+	period        = 30 // seconds per code
+	digits        = 6
+	skew          = 1 // steps accepted either side of now, for clock drift
+	recoveryCount = 10
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Errors returned by Manager.
+var (
+	ErrInvalidCode = errors.New("invalid authentication code")
+	ErrNotEnrolled = errors.New("two-factor authentication is not enabled for this login")
+	ErrEnrolled    = errors.New("two-factor authentication is already enabled for this login")
+	ErrNoPending   = errors.New("no pending enrollment; start setup first")
+)
+
+// NewSecret returns a random 160-bit secret, base32-encoded.
+func NewSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("totp: read random: %v", err))
+	}
+	return b32.EncodeToString(b)
+}
+
+// Code returns the code for secret at time t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("totp: bad secret: %w", err)
+	}
+	return code(key, t.Unix()/period), nil
+}
+
+func code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, n%1_000_000)
+}
+
+// match returns the time step code is valid for within the allowed skew,
+// or -1.
+func match(secret, c string, now time.Time) int64 {
+	key, err := b32.DecodeString(secret)
+	if err != nil || len(c) != digits {
+		return -1
+	}
+	cur := now.Unix() / period
+	for d := int64(-skew); d <= skew; d++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, cur+d)), []byte(c)) == 1 {
+			return cur + d
+		}
+	}
+	return -1
+}
+
+// URI is the otpauth:// key URI authenticator apps import, usually as a QR code.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(digits))
+	q.Set("period", fmt.Sprint(period))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Status describes one login's enrollment.
+type Status struct {
+	Enabled       bool   `json:"enabled"`
+	Pending       bool   `json:"pending"`
+	RecoveryCodes int    `json:"recoveryCodes"` // unused codes left
+	EnabledAt     string `json:"enabledAt,omitempty"`
+}
+
+type enrollment struct {
+	secret   string
+	lastStep int64 // last accepted step; a code is never accepted twice
+}
+
+// Manager holds the enrollments of dashboard logins. A login is identified
+// by its account name, "" being the main password login.
+type Manager struct {
+	dbPath string
+	issuer string
+	now    func() time.Time
+
+	mu       sync.Mutex
+	enrolled map[string]*enrollment
+}
+
+// InitDB creates the dashboard_totp and dashboard_totp_recovery tables.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS dashboard_totp (
+		login TEXT PRIMARY KEY,
+		secret TEXT NOT NULL,
+		enabled INTEGER DEFAULT 0,
+		last_step INTEGER DEFAULT 0,
+		created_at TEXT NOT NULL,
+		enabled_at TEXT DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS dashboard_totp_recovery (
+		login TEXT NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at TEXT DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_dashboard_totp_recovery_login ON dashboard_totp_recovery(login);`
+	return db.Exec(dbPath, sql)
+}
+
+// NewManager loads enabled enrollments from dbPath. issuer names the
+// service in authenticator apps (default "Tetora").
+func NewManager(dbPath, issuer string) *Manager {
+	if issuer == "" {
+		issuer = "Tetora"
+	}
+	m := &Manager{dbPath: dbPath, issuer: issuer, now: time.Now, enrolled: make(map[string]*enrollment)}
+	rows, err := db.Query(dbPath, `SELECT login, secret, last_step FROM dashboard_totp WHERE enabled = 1`)
+	if err != nil {
+		log.Warn("totp: load enrollments failed", "error", err)
+		return m
+	}
+	for _, row := range rows {
+		m.enrolled[db.Str(row["login"])] = &enrollment{secret: db.Str(row["secret"]), lastStep: int64(db.Int(row["last_step"]))}
+	}
+	return m
+}
+
+// Secret returns the secret of an enrolled login, or "" when the login has
+// no second factor. Callers mix it into session signing keys so that a
+// password alone cannot produce a session. Safe on a nil Manager.
+func (m *Manager) Secret(login string) string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.enrolled[login]; e != nil {
+		return e.secret
+	}
+	return ""
+}
+
+// Enabled reports whether login must pass a second factor. Safe on a nil Manager.
+func (m *Manager) Enabled(login string) bool {
+	return m.Secret(login) != ""
+}
+
+// Status reports login's enrollment.
+func (m *Manager) Status(login string) Status {
+	var st Status
+	rows, err := db.QueryArgs(m.dbPath,
+		`SELECT enabled, enabled_at, (SELECT COUNT(*) FROM dashboard_totp_recovery r WHERE r.login = t.login AND r.used_at = '') AS remaining
+		 FROM dashboard_totp t WHERE login = ?`, login)
+	if err != nil || len(rows) == 0 {
+		return st
+	}
+	st.Enabled = db.Int(rows[0]["enabled"]) == 1
+	st.Pending = !st.Enabled
+	if st.Enabled {
+		st.EnabledAt = db.Str(rows[0]["enabled_at"])
+		st.RecoveryCodes = db.Int(rows[0]["remaining"])
+	}
+	return st
+}
+
+// Setup starts enrollment for login with a fresh secret and returns it with
+// its otpauth:// URI. account labels the entry in the authenticator app.
+// An unconfirmed setup replaces the previous one.
+func (m *Manager) Setup(login, account string) (secret, uri string, err error) {
+	if m.Enabled(login) {
+		return "", "", ErrEnrolled
+	}
+	secret = NewSecret()
+	err = db.ExecArgs(m.dbPath,
+		`INSERT OR REPLACE INTO dashboard_totp (login, secret, enabled, last_step, created_at, enabled_at) VALUES (?, ?, 0, 0, ?, '')`,
+		login, secret, m.now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", "", err
+	}
+	return secret, URI(m.issuer, account, secret), nil
+}
+
+// Confirm completes a pending enrollment with a first code from the
+// authenticator app and returns the recovery codes, shown only this once.
+func (m *Manager) Confirm(login, c string) ([]string, error) {
+	if m.Enabled(login) {
+		return nil, ErrEnrolled
+	}
+	rows, err := db.QueryArgs(m.dbPath, `SELECT secret FROM dashboard_totp WHERE login = ? AND enabled = 0`, login)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoPending
+	}
+	secret := db.Str(rows[0]["secret"])
+	step := match(secret, normalize(c), m.now())
+	if step < 0 {
+		return nil, ErrInvalidCode
+	}
+	codes := make([]string, recoveryCount)
+	ql := db.Escape(login)
+	stmts := []string{
+		fmt.Sprintf(`UPDATE dashboard_totp SET enabled = 1, last_step = %d, enabled_at = '%s' WHERE login = '%s'`,
+			step, m.now().UTC().Format(time.RFC3339), ql),
+		fmt.Sprintf(`DELETE FROM dashboard_totp_recovery WHERE login = '%s'`, ql),
+	}
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		stmts = append(stmts, fmt.Sprintf(`INSERT INTO dashboard_totp_recovery (login, code_hash) VALUES ('%s', '%s')`,
+			ql, hashCode(codes[i])))
+	}
+	if err := db.Exec(m.dbPath, strings.Join(stmts, ";\n")); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.enrolled[login] = &enrollment{secret: secret, lastStep: step}
+	m.mu.Unlock()
+	return codes, nil
+}
+
+// Verify checks a code from the authenticator app, or failing that an
+// unused recovery code, which is then spent. recovery reports which one
+// matched.
+func (m *Manager) Verify(login, c string) (recovery bool, err error) {
+	c = normalize(c)
+	m.mu.Lock()
+	e := m.enrolled[login]
+	if e == nil {
+		m.mu.Unlock()
+		return false, ErrNotEnrolled
+	}
+	if step := match(e.secret, c, m.now()); step > e.lastStep {
+		e.lastStep = step
+		m.mu.Unlock()
+		if err := db.ExecArgs(m.dbPath, `UPDATE dashboard_totp SET last_step = ? WHERE login = ?`, step, login); err != nil {
+			log.Warn("totp: save last step failed", "error", err)
+		}
+		return false, nil
+	}
+	m.mu.Unlock()
+
+	if len(c) == digits {
+		return false, ErrInvalidCode
+	}
+	// Spend the code in the same statement that checks it is unused, so two
+	// logins racing on one recovery code cannot both succeed.
+	rows, err := db.QueryArgs(m.dbPath,
+		`UPDATE dashboard_totp_recovery SET used_at = ? WHERE login = ? AND code_hash = ? AND used_at = ''; SELECT changes() AS changed;`,
+		m.now().UTC().Format(time.RFC3339), login, hashCode(c))
+	if err != nil {
+		return false, err
+	}
+	if len(rows) == 0 || db.Int(rows[0]["changed"]) == 0 {
+		return false, ErrInvalidCode
+	}
+	return true, nil
+}
+
+// Disable removes login's enrollment and recovery codes.
+func (m *Manager) Disable(login string) error {
+	err := db.ExecArgs(m.dbPath,
+		`DELETE FROM dashboard_totp WHERE login = ?; DELETE FROM dashboard_totp_recovery WHERE login = ?`, login, login)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.enrolled, login)
+	m.mu.Unlock()
+	return nil
+}
+
+// normalize strips the spaces and dashes people type into codes.
+func normalize(c string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(c)))
+}
+
+// newRecoveryCode returns a code like "k3x9-2mfq-8hzt", 60 bits of entropy.
+func newRecoveryCode() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("totp: read random: %v", err))
+	}
+	s := strings.ToLower(b32.EncodeToString(b))[:12]
+	return s[:4] + "-" + s[4:8] + "-" + s[8:]
+}
+
+func hashCode(c string) string {
+	sum := sha256.Sum256([]byte(normalize(c)))
+	return hex.EncodeToString(sum[:])
+}
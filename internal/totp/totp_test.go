@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCode_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1 key, truncated to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Tetora", "admin", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Tetora:admin?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Tetora") {
+		t.Errorf("URI = %s", uri)
+	}
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	m := NewManager(dbPath, "")
+	m.now = func() time.Time { return now }
+
+	if m.Enabled("ops") {
+		t.Fatal("enabled before setup")
+	}
+	if _, err := m.Confirm("ops", "123456"); !errors.Is(err, ErrNoPending) {
+		t.Fatalf("Confirm without setup: %v", err)
+	}
+	secret, uri, err := m.Setup("ops", "ops")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if !strings.Contains(uri, "Tetora:ops") {
+		t.Errorf("uri = %s", uri)
+	}
+	if st := m.Status("ops"); !st.Pending || st.Enabled {
+		t.Errorf("status after setup = %+v", st)
+	}
+	c, _ := Code(secret, now)
+	wrong := c[:5] + string('0'+(c[5]-'0'+1)%10)
+	if _, err := m.Confirm("ops", wrong); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("Confirm with wrong code: %v", err)
+	}
+	codes, err := m.Confirm("ops", c)
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if len(codes) != recoveryCount || !m.Enabled("ops") || m.Secret("ops") != secret {
+		t.Fatalf("after confirm: %d codes, enabled=%v", len(codes), m.Enabled("ops"))
+	}
+	if st := m.Status("ops"); !st.Enabled || st.RecoveryCodes != recoveryCount {
+		t.Errorf("status after confirm = %+v", st)
+	}
+
+	// The confirming code cannot be replayed at login.
+	if _, err := m.Verify("ops", c); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("replayed code: %v", err)
+	}
+	now = now.Add(period * time.Second)
+	c, _ = Code(secret, now)
+	if rec, err := m.Verify("ops", c[:3]+" "+c[3:]); err != nil || rec {
+		t.Errorf("Verify = %v, %v", rec, err)
+	}
+
+	// Enrollments survive a restart.
+	m2 := NewManager(dbPath, "")
+	m2.now = m.now
+	if !m2.Enabled("ops") {
+		t.Fatal("enrollment not reloaded")
+	}
+
+	// A recovery code works once, in any case and with or without dashes.
+	if rec, err := m2.Verify("ops", strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))); err != nil || !rec {
+		t.Errorf("recovery Verify = %v, %v", rec, err)
+	}
+	if _, err := m2.Verify("ops", codes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("spent recovery code: %v", err)
+	}
+	if st := m2.Status("ops"); st.RecoveryCodes != recoveryCount-1 {
+		t.Errorf("recovery codes left = %d", st.RecoveryCodes)
+	}
+
+	// Concurrent attempts with one recovery code: exactly one is accepted.
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec, err := m2.Verify("ops", codes[2]); err == nil && rec {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := accepted.Load(); n != 1 {
+		t.Errorf("racing recovery code accepted %d times, want 1", n)
+	}
+	if _, _, err := m2.Setup("ops", "ops"); !errors.Is(err, ErrEnrolled) {
+		t.Errorf("Setup while enrolled: %v", err)
+	}
+
+	if err := m2.Disable("ops"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if m2.Enabled("ops") || m2.Status("ops") != (Status{}) {
+		t.Errorf("still enrolled after Disable: %+v", m2.Status("ops"))
+	}
+	if _, err := m2.Verify("ops", codes[1]); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify after Disable: %v", err)
+	}
+
+	var nilManager *Manager
+	if nilManager.Enabled("ops") || nilManager.Secret("ops") != "" {
+		t.Error("nil Manager reports an enrollment")
+	}
+}
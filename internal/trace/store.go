@@ -0,0 +1,216 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tetora/internal/db"
+	tlog "tetora/internal/log"
+)
+
+// Event kinds recorded on a trace timeline.
+const (
+	KindHTTP    = "http"
+	KindAudit   = "audit"
+	KindTask    = "task"
+	KindTool    = "tool"
+	KindNotify  = "notify"
+	KindWebhook = "webhook"
+)
+
+// timeFormat has fixed-width milliseconds so stored times sort as text.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Event is one entry on a trace timeline: a point event when DurationMs is 0,
+// otherwise a span starting at StartedAt.
+type Event struct {
+	ID         int    `json:"id"`
+	TraceID    string `json:"traceId"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Status     string `json:"status,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+var (
+	storePath  atomic.Value // string; "" until InitDB succeeds
+	events     = make(chan Event, 512)
+	writerOnce sync.Once
+)
+
+// InitDB creates the trace_events table, makes dbPath the store for Record
+// and starts the batched writer. Until it is called, recording is a no-op.
+func InitDB(dbPath string) error {
+	sql := `CREATE TABLE IF NOT EXISTS trace_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  trace_id TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  status TEXT DEFAULT '',
+  detail TEXT DEFAULT '',
+  started_at TEXT NOT NULL,
+  duration_ms INTEGER DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_trace_events_trace ON trace_events(trace_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_trace_events_started ON trace_events(started_at);`
+	if err := db.Exec(dbPath, sql); err != nil {
+		return err
+	}
+	storePath.Store(dbPath)
+	writerOnce.Do(func() { go writer() })
+	return nil
+}
+
+func currentStore() string {
+	s, _ := storePath.Load().(string)
+	return s
+}
+
+// Record adds e to the timeline of the trace in ctx (or e.TraceID when set).
+// Non-blocking: events are dropped when there is no trace ID, no store, or
+// the write queue is full.
+func Record(ctx context.Context, e Event) {
+	if e.TraceID == "" {
+		e.TraceID = IDFromContext(ctx)
+	}
+	if e.TraceID == "" || currentStore() == "" {
+		return
+	}
+	if e.StartedAt == "" {
+		e.StartedAt = time.Now().UTC().Format(timeFormat)
+	}
+	select {
+	case events <- e:
+	default:
+		tlog.Debug("trace event dropped, queue full", "traceId", e.TraceID, "kind", e.Kind)
+	}
+}
+
+// Start opens a span on the trace in ctx and returns the function that closes
+// it with a final status and detail. Without a trace ID the returned function
+// does nothing.
+func Start(ctx context.Context, kind, name string) func(status, detail string) {
+	traceID := IDFromContext(ctx)
+	if traceID == "" || currentStore() == "" {
+		return func(string, string) {}
+	}
+	start := time.Now()
+	return func(status, detail string) {
+		Record(ctx, Event{
+			TraceID:    traceID,
+			Kind:       kind,
+			Name:       name,
+			Status:     status,
+			Detail:     detail,
+			StartedAt:  start.UTC().Format(timeFormat),
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+func writer() {
+	const maxBatch = 50
+	const flushInterval = 500 * time.Millisecond
+
+	buf := make([]Event, 0, maxBatch)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-events:
+			buf = append(buf, e)
+			if len(buf) >= maxBatch {
+				flush(currentStore(), buf)
+				buf = buf[:0]
+			}
+		case <-ticker.C:
+			if len(buf) > 0 {
+				flush(currentStore(), buf)
+				buf = buf[:0]
+			}
+		}
+	}
+}
+
+// flush writes a batch of events in a single sqlite3 call.
+func flush(dbPath string, batch []Event) {
+	if dbPath == "" || len(batch) == 0 {
+		return
+	}
+	stmts := make([]string, 0, len(batch))
+	for _, e := range batch {
+		stmts = append(stmts, fmt.Sprintf(
+			`INSERT INTO trace_events (trace_id, kind, name, status, detail, started_at, duration_ms) VALUES ('%s','%s','%s','%s','%s','%s',%d)`,
+			db.Escape(e.TraceID), db.Escape(e.Kind), db.Escape(db.Truncate(e.Name, 200)),
+			db.Escape(e.Status), db.Escape(db.Truncate(e.Detail, 500)), db.Escape(e.StartedAt), e.DurationMs,
+		))
+	}
+	if err := db.Exec(dbPath, strings.Join(stmts, ";\n")); err != nil {
+		tlog.Error("trace event batch insert failed", "count", len(batch), "error", err)
+	}
+}
+
+// Events returns the timeline of one trace, oldest first.
+func Events(dbPath, traceID string) ([]Event, error) {
+	rows, err := db.Query(dbPath, fmt.Sprintf(
+		`SELECT id, trace_id, kind, name, status, detail, started_at, duration_ms
+		 FROM trace_events WHERE trace_id = '%s' ORDER BY started_at, id`, db.Escape(traceID)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Event{
+			ID:         db.Int(row["id"]),
+			TraceID:    db.Str(row["trace_id"]),
+			Kind:       db.Str(row["kind"]),
+			Name:       db.Str(row["name"]),
+			Status:     db.Str(row["status"]),
+			Detail:     db.Str(row["detail"]),
+			StartedAt:  db.Str(row["started_at"]),
+			DurationMs: int64(db.Int(row["duration_ms"])),
+		})
+	}
+	return out, nil
+}
+
+// Cleanup removes trace events older than the given number of days.
+func Cleanup(dbPath string, days int) error {
+	return db.Exec(dbPath, fmt.Sprintf(
+		`DELETE FROM trace_events WHERE datetime(started_at) < datetime('now','-%d days')`, days))
+}
+
+// statusWriter captures the response status for the request span.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,93 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitEvents polls until the batched writer has stored n events for traceID.
+func waitEvents(t *testing.T, dbPath, traceID string, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, err := Events(dbPath, traceID)
+		if err != nil {
+			t.Fatalf("Events: %v", err)
+		}
+		if len(events) >= n || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRecordAndEvents(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	ctx := WithID(context.Background(), "test-trace01")
+
+	// Without a trace ID nothing is recorded.
+	Record(context.Background(), Event{Kind: KindAudit, Name: "ignored"})
+	Start(context.Background(), KindTask, "ignored")("success", "")
+
+	end := Start(ctx, KindTask, "build (kokuyou)")
+	time.Sleep(5 * time.Millisecond)
+	Record(ctx, Event{Kind: KindAudit, Name: "dispatch", Detail: "1 task"})
+	end("success", "cost=$0.0100")
+
+	events := waitEvents(t, dbPath, "test-trace01", 2)
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want 2", events)
+	}
+	// The span started first, so it sorts first.
+	task, audit := events[0], events[1]
+	if task.Kind != KindTask || task.Name != "build (kokuyou)" || task.Status != "success" || task.DurationMs < 5 {
+		t.Errorf("task span = %+v", task)
+	}
+	if audit.Kind != KindAudit || audit.Name != "dispatch" || audit.Detail != "1 task" || audit.DurationMs != 0 {
+		t.Errorf("audit event = %+v", audit)
+	}
+	if other, _ := Events(dbPath, ""); len(other) != 0 {
+		t.Errorf("events without trace ID were stored: %+v", other)
+	}
+
+	flush(dbPath, []Event{{TraceID: "old-trace", Kind: KindTask, StartedAt: "2020-01-01T00:00:00.000Z"}})
+	if err := Cleanup(dbPath, 30); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if old, _ := Events(dbPath, "old-trace"); len(old) != 0 {
+		t.Errorf("Cleanup kept %d old events", len(old))
+	}
+	if kept, _ := Events(dbPath, "test-trace01"); len(kept) != 2 {
+		t.Errorf("Cleanup removed recent events, %d left", len(kept))
+	}
+}
+
+func TestMiddleware_RecordsMutations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	get := httptest.NewRecorder()
+	handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	post := httptest.NewRecorder()
+	handler.ServeHTTP(post, httptest.NewRequest(http.MethodPost, "/dispatch", nil))
+
+	events := waitEvents(t, dbPath, post.Header().Get("X-Trace-Id"), 1)
+	if len(events) != 1 || events[0].Kind != KindHTTP || events[0].Name != "POST /dispatch" || events[0].Status != "202" {
+		t.Errorf("POST events = %+v", events)
+	}
+	if events, _ := Events(dbPath, get.Header().Get("X-Trace-Id")); len(events) != 0 {
+		t.Errorf("GET recorded %+v", events)
+	}
+}
@@ -53,11 +53,24 @@ func IDFromContext(ctx context.Context) string {
 
 // Middleware is HTTP middleware that generates a trace ID for each request
 // and injects it into the request context. Also sets X-Trace-Id response header.
+// Requests other than GET, HEAD and OPTIONS are recorded as the first span of
+// their trace timeline.
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		traceID := NewID("http")
 		ctx := WithID(r.Context(), traceID)
 		w.Header().Set("X-Trace-Id", traceID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		end := Start(ctx, KindHTTP, r.Method+" "+r.URL.Path)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		end(fmt.Sprint(sw.status), "")
 	})
 }
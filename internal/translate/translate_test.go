@@ -0,0 +1,160 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+// newTestService returns an llm-provider service whose model "translates" by
+// tagging the text with the target language and reporting the source as ja.
+func newTestService(t *testing.T) (*Service, *[]string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	if err := InitDB(dbPath); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	cfg := &config.Config{HistoryDB: dbPath}
+	cfg.Translate = config.TranslateConfig{Enabled: true, Provider: "llm"}
+	var prompts []string
+	exec := dispatch.TaskExecutorFunc(func(ctx context.Context, task dispatch.Task, agent string) dispatch.TaskResult {
+		prompts = append(prompts, task.Prompt)
+		text := task.Prompt[strings.Index(task.Prompt, "<text>\n")+7 : strings.LastIndex(task.Prompt, "\n</text>")]
+		to := strings.Fields(strings.TrimPrefix(task.Prompt, "Translate the text below into "))[0]
+		to = strings.TrimSuffix(to, ".")
+		out, _ := json.Marshal(map[string]string{"from": "ja", "text": to + ":" + text})
+		return dispatch.TaskResult{Status: "success", Output: "```json\n" + string(out) + "\n```", CostUSD: 0.001}
+	})
+	return New(cfg, Deps{Executor: exec}), &prompts
+}
+
+func TestGlossary(t *testing.T) {
+	s, prompts := newTestService(t)
+	if _, err := s.SetTerm("Tetora", "", "", "product name"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetTerm("お弁当", "en", "bento", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetTerm("お弁当", "en", "packed lunch", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetTerm("x", "not a lang!", "", ""); err == nil {
+		t.Error("bad language accepted")
+	}
+	terms := s.Glossary()
+	if len(terms) != 2 {
+		t.Fatalf("glossary = %+v", terms)
+	}
+
+	r, err := s.Translate(context.Background(), "tetoraでお弁当を注文", "", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "en:tetoraでお弁当を注文" || r.From != "ja" || r.CostUSD != 0.001 {
+		t.Errorf("result = %+v", r)
+	}
+	p := (*prompts)[0]
+	if !strings.Contains(p, `"Tetora": keep as is`) || !strings.Contains(p, `"お弁当": write "packed lunch"`) {
+		t.Errorf("glossary missing from prompt:\n%s", p)
+	}
+	// Entries for another language are left out.
+	s.Translate(context.Background(), "お弁当", "", "fr")
+	if strings.Contains((*prompts)[1], "packed lunch") {
+		t.Error("en entry used for fr")
+	}
+
+	for _, term := range terms {
+		if err := s.DeleteTerm(term.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.Glossary()) != 0 || s.DeleteTerm(terms[0].ID) == nil {
+		t.Error("delete failed")
+	}
+}
+
+func TestProtect(t *testing.T) {
+	terms := []Term{{Term: "New York"}, {Term: "New York Times", Translation: "NYT"}, {Term: "bento", Translation: "お弁当"}}
+	text, restore := protect("Read the new york times over a Bento in New York", terms)
+	if strings.Contains(strings.ToLower(text), "york") || strings.Contains(strings.ToLower(text), "bento") {
+		t.Fatalf("terms left in %q", text)
+	}
+	// APIs sometimes add spaces inside the placeholder.
+	got := restore(strings.Replace(text, "⟦0⟧", "⟦ 0 ⟧", 1))
+	if got != "Read the NYT over a お弁当 in New York" {
+		t.Errorf("restored %q", got)
+	}
+}
+
+func TestSameLang(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"en", "EN", true},
+		{"en", "en-GB", true},
+		{"pt_BR", "pt-br", true},
+		{"zh", "zh-TW", true},
+		{"zh-TW", "zh-CN", false},
+		{"zh-tw", "zh-hant", true},
+		{"ja", "en", false},
+	} {
+		if got := SameLang(c.a, c.b); got != c.want {
+			t.Errorf("SameLang(%q, %q) = %v", c.a, c.b, got)
+		}
+	}
+}
+
+func TestChannelTranslation(t *testing.T) {
+	s, prompts := newTestService(t)
+	ctx := context.Background()
+
+	if text, turn := s.In(ctx, "discord", "42", "こんにちは"); turn != nil || text != "こんにちは" {
+		t.Errorf("translated while off: %q %+v", text, turn)
+	}
+	if reply := s.Command("discord", "42", "on en"); !strings.Contains(reply, "on") {
+		t.Errorf("on: %q", reply)
+	}
+	if reply := s.Command("discord", "42", "on english"); !strings.Contains(reply, "not a language") {
+		t.Errorf("bad language: %q", reply)
+	}
+
+	text, turn := s.In(ctx, "discord", "42", "こんにちは")
+	if turn == nil || turn.UserLang != "ja" || turn.AgentLang != "en" {
+		t.Fatalf("turn = %+v", turn)
+	}
+	if text != "[Translated from ja]\nen:こんにちは" {
+		t.Errorf("incoming = %q", text)
+	}
+	if got := s.Out(ctx, turn, "Hello!"); got != "ja:Hello!" {
+		t.Errorf("reply = %q", got)
+	}
+	if got := s.Out(ctx, nil, "Hello!"); got != "Hello!" {
+		t.Errorf("untranslated reply = %q", got)
+	}
+
+	// A message in the agents' own script is not sent for translation.
+	n := len(*prompts)
+	s.Command("discord", "7", "on ja")
+	if _, turn := s.In(ctx, "discord", "7", "こんにちは"); turn != nil || len(*prompts) != n {
+		t.Error("Japanese message translated for Japanese agents")
+	}
+
+	// Settings survive a restart; "off" wins over the config.
+	on := true
+	s.cfg.Translate.Channels = map[string]config.TranslateChannelConfig{"discord": {Enabled: &on}}
+	s.Command("discord", "42", "off")
+	s2 := New(s.cfg, s.deps)
+	if ch := s2.ChannelSetting("discord", "42"); ch.Enabled || ch.Source != "chat" {
+		t.Errorf("channel 42 = %+v", ch)
+	}
+	if ch := s2.ChannelSetting("discord", "99"); !ch.Enabled || ch.AgentLang != "en" || ch.Source != "config" {
+		t.Errorf("channel 99 = %+v", ch)
+	}
+}
@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/config"
 	"tetora/internal/db"
 	"tetora/internal/log"
@@ -67,8 +67,13 @@ type Status struct {
 	NextLevel          string `json:"nextLevel,omitempty"` // next level to promote to
 	TotalTasks         int    `json:"totalTasks"`
 	LastUpdated        string `json:"lastUpdated,omitempty"`
+	ToolViolations     int    `json:"toolViolations"`          // tool calls refused by policy or budget in the last 7 days
+	LastViolation      string `json:"lastViolation,omitempty"` // note of the most recent one
 }
 
+// ViolationWindow is how far back Status.ToolViolations counts.
+const ViolationWindow = 7 * 24 * time.Hour
+
 // --- DB Init ---
 
 // InitDB creates the trust_events table in the given SQLite database.
@@ -196,6 +201,24 @@ func QueryEvents(dbPath, role string, limit int) ([]map[string]any, error) {
 	return db.Query(dbPath, sql)
 }
 
+// QueryViolations counts tool violations (tool_denied and tool_budget events)
+// recorded for role since the given time, and returns the latest note.
+func QueryViolations(dbPath, role string, since time.Time) (int, string) {
+	if dbPath == "" {
+		return 0, ""
+	}
+	sql := fmt.Sprintf(
+		`SELECT note FROM trust_events
+		 WHERE agent = '%s' AND event_type IN ('tool_denied', 'tool_budget') AND created_at >= '%s'
+		 ORDER BY id DESC`,
+		db.Escape(role), since.Format(time.RFC3339))
+	rows, err := db.Query(dbPath, sql)
+	if err != nil || len(rows) == 0 {
+		return 0, ""
+	}
+	return len(rows), jsonStr(rows[0]["note"])
+}
+
 // --- Trust Status Queries ---
 
 // GetStatus returns the trust status for a single role.
@@ -221,6 +244,8 @@ func GetStatus(cfg *config.Config, role string) Status {
 		lastUpdated = jsonStr(events[0]["created_at"])
 	}
 
+	violations, lastViolation := QueryViolations(cfg.HistoryDB, role, time.Now().Add(-ViolationWindow))
+
 	return Status{
 		Agent:              role,
 		Level:              level,
@@ -229,6 +254,8 @@ func GetStatus(cfg *config.Config, role string) Status {
 		NextLevel:          next,
 		TotalTasks:         totalTasks,
 		LastUpdated:        lastUpdated,
+		ToolViolations:     violations,
+		LastViolation:      lastViolation,
 	}
 }
 
@@ -367,7 +394,7 @@ func SaveAgentLevel(configPath, agentName, newLevel string) error {
 
 // UpdateConfigField reads config.json, applies a mutation, and writes it back.
 func UpdateConfigField(configPath string, mutate func(raw map[string]any)) error {
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -383,7 +410,7 @@ func UpdateConfigField(configPath string, mutate func(raw map[string]any)) error
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	return os.WriteFile(configPath, append(out, '\n'), 0o600)
+	return cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600)
 }
 
 // --- JSON helpers (private) ---
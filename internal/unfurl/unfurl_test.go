@@ -0,0 +1,204 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"https://example.com/post?id=1", "https://example.com/post?id=1"},
+		{"  <https://example.com/a>  ", "https://example.com/a"},
+		{"look at https://example.com", ""},
+		{"ftp://example.com/file", ""},
+		{"example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, _ := Match(tt.text)
+		if got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRobots(t *testing.T) {
+	rules := parseRobots(`
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public-*
+Disallow: /*.pdf$
+`, "tetora")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/blog/post", true},
+		{"/private/notes", false},
+		{"/private/public-page", true},
+		{"/docs/file.pdf", false},
+		{"/docs/file.pdf?x=1", true},
+	}
+	for _, tt := range tests {
+		if got := robotsAllowed(rules, tt.path); got != tt.want {
+			t.Errorf("robotsAllowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// A group naming Tetora replaces the "*" group.
+	own := parseRobots("User-agent: *\nDisallow: /\n\nUser-agent: Tetora\nDisallow: /admin\n", "tetora")
+	if !robotsAllowed(own, "/blog") || robotsAllowed(own, "/admin/x") {
+		t.Errorf("own group rules = %+v", own)
+	}
+}
+
+func newTestSite(t *testing.T, robots string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(robots))
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Go &amp; SQLite</title>
+<meta name="description" content="Notes on embedding SQLite.">
+<script>var tracking = 1;</script><style>p{}</style></head>
+<body><!-- nav --><p>SQLite is  a small,
+fast database.</p></body></html>`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("word ", 1000)))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetch(t *testing.T) {
+	srv := newTestSite(t, "User-agent: *\nDisallow: /private\n")
+	f := &Fetcher{Client: srv.Client()}
+	ctx := context.Background()
+
+	page, err := f.Fetch(ctx, srv.URL+"/article", 1<<20)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if page.Title != "Go & SQLite" || page.Description != "Notes on embedding SQLite." {
+		t.Errorf("title=%q description=%q", page.Title, page.Description)
+	}
+	if page.Text != "SQLite is a small, fast database." {
+		t.Errorf("text = %q", page.Text)
+	}
+
+	page, err = f.Fetch(ctx, srv.URL+"/big", 100)
+	if err != nil || !page.Truncated || len(page.Text) > 100 {
+		t.Errorf("capped fetch: truncated=%v len=%d err=%v", page != nil && page.Truncated, len(page.Text), err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/private/doc", 1<<20); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("robots-disallowed fetch = %v", err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/image", 1<<20); err == nil || !strings.Contains(err.Error(), "content type") {
+		t.Errorf("binary fetch = %v", err)
+	}
+
+	if _, err := NewFetcher().Fetch(ctx, srv.URL+"/article", 1<<20); err == nil || !strings.Contains(err.Error(), "internal address") {
+		t.Errorf("loopback fetch with default client = %v", err)
+	}
+}
+
+func TestRunSummarizesAndSaves(t *testing.T) {
+	srv := newTestSite(t, "")
+	cfg := &config.Config{
+		KnowledgeDir:  t.TempDir(),
+		SmartDispatch: config.SmartDispatchConfig{DefaultAgent: "helper"},
+	}
+
+	var got dispatch.Task
+	deps := Deps{
+		Executor: dispatch.TaskExecutorFunc(func(_ context.Context, task dispatch.Task, agent string) dispatch.TaskResult {
+			got = task
+			return dispatch.TaskResult{Status: "success", CostUSD: 0.001,
+				Output: "```json\n{\"summary\":\"SQLite is a small database.\",\"actions\":[\"Compare with Postgres\",\"Find benchmarks\"]}\n```"}
+		}),
+		FillDefaults: func(_ *config.Config, t *dispatch.Task) { t.Model = "opus"; t.Budget = 5 },
+	}
+
+	sum, err := Run(context.Background(), cfg, &Fetcher{Client: srv.Client()}, srv.URL+"/article", true, deps)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got.Agent != "helper" || got.Model != "haiku" || got.Budget != 0.02 || got.Source != "unfurl" {
+		t.Errorf("task = agent %q model %q budget %v source %q", got.Agent, got.Model, got.Budget, got.Source)
+	}
+	if !strings.Contains(got.Prompt, "SQLite is a small, fast database.") {
+		t.Error("page text missing from prompt")
+	}
+	if sum.Summary != "SQLite is a small database." || len(sum.Actions) != 2 {
+		t.Errorf("summary = %+v", sum)
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.KnowledgeDir, sum.Saved))
+	if err != nil {
+		t.Fatalf("saved file: %v", err)
+	}
+	if !strings.Contains(string(data), "# Go & SQLite") || !strings.Contains(string(data), "Source: "+srv.URL+"/article") {
+		t.Errorf("saved file = %s", data)
+	}
+
+	reply := Format(sum)
+	if !strings.Contains(reply, "Follow-ups:\n- Compare with Postgres") || !strings.Contains(reply, sum.Saved) {
+		t.Errorf("reply = %s", reply)
+	}
+}
+
+func TestForChannel(t *testing.T) {
+	on, off := true, false
+	c := config.UnfurlConfig{
+		Enabled: true,
+		Channels: map[string]config.UnfurlChannelConfig{
+			"telegram":      {Enabled: &off},
+			"telegram:42":   {Enabled: &on, Knowledge: &on},
+			"discord:noisy": {Enabled: &off},
+		},
+	}
+	tests := []struct {
+		platform, chat     string
+		enabled, knowledge bool
+	}{
+		{"discord", "general", true, false},
+		{"discord", "noisy", false, false},
+		{"telegram", "7", false, false},
+		{"telegram", "42", true, true},
+	}
+	for _, tt := range tests {
+		enabled, knowledge := c.ForChannel(tt.platform, tt.chat)
+		if enabled != tt.enabled || knowledge != tt.knowledge {
+			t.Errorf("ForChannel(%s, %s) = %v, %v", tt.platform, tt.chat, enabled, knowledge)
+		}
+	}
+}
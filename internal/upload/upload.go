@@ -169,3 +169,87 @@ func Coalesce(ss ...string) string {
 	}
 	return ""
 }
+
+// SealedExt is appended to the name of an upload encrypted at rest.
+const SealedExt = ".enc"
+
+// Seal encrypts the uploads older than age with seal, replacing each with
+// <name>.enc. Modification times are kept so that Cleanup still applies.
+// It returns how many files were sealed.
+func Seal(uploadDir string, age time.Duration, seal func([]byte) ([]byte, error)) (int, error) {
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), SealedExt) || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		path := filepath.Join(uploadDir, e.Name())
+		if err := rewrite(path, path+SealedExt, info.ModTime(), seal); err != nil {
+			return n, fmt.Errorf("seal %s: %w", e.Name(), err)
+		}
+		os.Remove(path)
+		n++
+	}
+	return n, nil
+}
+
+// Reseal rewrites every sealed upload with fn, e.g. to encrypt it with a
+// new key. It returns how many files were rewritten.
+func Reseal(uploadDir string, fn func([]byte) ([]byte, error)) (int, error) {
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), SealedExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(uploadDir, e.Name())
+		if err := rewrite(path, path, info.ModTime(), fn); err != nil {
+			return n, fmt.Errorf("reseal %s: %w", e.Name(), err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// rewrite writes fn(contents of src) to dst atomically, with modTime. The
+// temporary file is hidden so that Seal skips it.
+func rewrite(src, dst string, modTime time.Time, fn func([]byte) ([]byte, error)) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := fn(data)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err := os.WriteFile(tmp, out, 0o600); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp, modTime, modTime); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"strings"
+
+	"tetora/internal/skill"
+)
+
+// checkSkill checks a SKILL.md draft: its frontmatter as the skill loader
+// reads it, and the body agents are given.
+func checkSkill(content string) *Result {
+	t := newText(content)
+	r := &Result{Tokens: estimateTokens(content)}
+	add := func(d Diagnostic) { r.Diagnostics = append(r.Diagnostics, d) }
+
+	if strings.TrimSpace(content) == "" {
+		add(t.at(Error, "empty", 0, 0, "skill is empty"))
+		return r
+	}
+	body := 0
+	if !strings.HasPrefix(content, "---") {
+		add(t.at(Warning, "no-frontmatter", 0, 0,
+			"no frontmatter: the skill is named after its directory, has no description and is offered to every task"))
+	} else if end := strings.Index(content[3:], "\n---"); end < 0 {
+		add(t.at(Error, "unclosed-frontmatter", 0, 3, "frontmatter is never closed with ---"))
+		return r
+	} else {
+		body = 3 + end + len("\n---")
+		fields := map[string]int{} // key -> offset of its line
+		off := 3
+		for _, line := range strings.SplitAfter(content[3:3+end], "\n") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case trimmed == "", strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "- "):
+			case !strings.Contains(trimmed, ":"):
+				add(t.at(Warning, "frontmatter-syntax", off, off+len(strings.TrimRight(line, "\r\n")),
+					"expected \"key: value\"; this line is ignored"))
+			default:
+				key, _, _ := strings.Cut(trimmed, ":")
+				fields[strings.TrimSpace(key)] = off
+			}
+			off += len(line)
+		}
+		value := func(key string) string {
+			o, ok := fields[key]
+			if !ok {
+				return ""
+			}
+			line := content[o:]
+			if i := strings.IndexByte(line, '\n'); i >= 0 {
+				line = line[:i]
+			}
+			_, v, _ := strings.Cut(line, ":")
+			return strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+		if name := value("name"); name == "" {
+			add(t.at(Warning, "missing-name", 0, 3, "no name: the skill is named after its directory"))
+		} else if !skill.IsValidSkillName(name) {
+			o := fields["name"]
+			add(t.at(Error, "invalid-name", o, o+len("name"), "invalid skill name %q: use letters, digits and hyphens, at most 64 characters", name))
+		}
+		if value("description") == "" {
+			add(t.at(Warning, "missing-description", 0, 3, "no description: agents see the skill listed without one"))
+		}
+		if _, ok := fields["triggers"]; !ok && !strings.EqualFold(value("mandatory"), "true") {
+			add(t.at(Info, "no-triggers", 0, 3, "no triggers: the skill is offered to every task (about %d tokens)", r.Tokens))
+		}
+	}
+	if strings.TrimSpace(content[body:]) == "" {
+		add(t.at(Warning, "empty-body", body, body, "the skill has no instructions below the frontmatter"))
+	}
+	for _, m := range varRe.FindAllStringIndex(content[body:], -1) {
+		add(t.at(Warning, "unexpanded-variable", body+m[0], body+m[1],
+			"skill documents are not templated; %s reaches the agent as written", content[body+m[0]:body+m[1]]))
+	}
+	r.Diagnostics = append(r.Diagnostics, unclosedFence(t)...)
+	return r
+}
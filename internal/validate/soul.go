@@ -0,0 +1,53 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// simpleSoulMax is how much of a soul simple tasks keep, as in the prompt
+// builder.
+const simpleSoulMax = 4000
+
+// checkSoul checks a SOUL.md. Souls are sent as the system prompt as
+// written, so placeholders are never filled in, and the prompt budget cuts
+// long ones short.
+func checkSoul(content, agent string, env Env) *Result {
+	t := newText(content)
+	r := &Result{Tokens: estimateTokens(content)}
+	add := func(d Diagnostic) { r.Diagnostics = append(r.Diagnostics, d) }
+
+	if agent != "" && !env.Agents[agent] {
+		add(Diagnostic{Severity: Error, Code: "unknown-agent", Message: fmt.Sprintf("agent %q is not configured", agent)})
+	}
+	if strings.TrimSpace(content) == "" {
+		add(t.at(Error, "empty", 0, 0, "soul is empty"))
+		return r
+	}
+	for _, m := range varRe.FindAllStringSubmatchIndex(content, -1) {
+		add(t.at(Warning, "unexpanded-variable", m[0], m[1],
+			"souls are not templated; %s reaches the model as written", content[m[0]:m[1]]))
+	}
+	if off := runeOffset(content, env.SoulMax); env.SoulMax > 0 && off >= 0 {
+		add(t.at(Warning, "truncated", off, len(content),
+			"only the first %d characters are used (promptBudget.soulMax); the rest is dropped", env.SoulMax))
+	} else if off := runeOffset(content, simpleSoulMax); off >= 0 {
+		add(t.at(Info, "truncated-simple", off, len(content),
+			"simple tasks use only the first %d characters", simpleSoulMax))
+	}
+	r.Diagnostics = append(r.Diagnostics, unclosedFence(t)...)
+	return r
+}
+
+// runeOffset returns the byte offset of the n-th character of s, or -1
+// when s has no more than n characters.
+func runeOffset(s string, n int) int {
+	i := 0
+	for off := range s {
+		if i == n {
+			return off
+		}
+		i++
+	}
+	return -1
+}
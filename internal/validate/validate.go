@@ -0,0 +1,170 @@
+// Package validate checks souls, workflows and skill drafts as they are
+// edited. Problems come back as diagnostics with line and column positions,
+// the way a language server reports them, so the dashboard editor can mark
+// them inline before anything is saved.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+)
+
+// Severity is how serious a diagnostic is. Only errors make a draft invalid.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+)
+
+// Diagnostic is one problem in a draft. Positions are 1-based; columns count
+// characters, not bytes. A problem outside the draft itself, such as a
+// trigger configured for the workflow, has no position.
+type Diagnostic struct {
+	Severity  Severity `json:"severity"`
+	Code      string   `json:"code"`
+	Message   string   `json:"message"`
+	Line      int      `json:"line,omitempty"`
+	Column    int      `json:"column,omitempty"`
+	EndLine   int      `json:"endLine,omitempty"`
+	EndColumn int      `json:"endColumn,omitempty"`
+	Path      string   `json:"path,omitempty"` // JSON path of the field in a workflow, e.g. "steps[1].agent"
+}
+
+// Result is the outcome of checking a draft.
+type Result struct {
+	Kind        string       `json:"kind"`
+	Valid       bool         `json:"valid"` // no errors
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Tokens      int          `json:"tokens"` // estimated prompt tokens the draft adds
+}
+
+// Env is what a draft is checked against.
+type Env struct {
+	Agents   map[string]bool                // configured agents
+	Skills   map[string]bool                // known skills; nil skips skill checks
+	Tools    map[string]bool                // registered tools; nil skips tool checks
+	Triggers []config.WorkflowTriggerConfig // configured workflow triggers
+	SoulMax  int                            // characters of a soul kept in the system prompt
+}
+
+// Kinds lists the kinds of draft Check accepts.
+var Kinds = []string{"soul", "workflow", "skill"}
+
+// Check validates content as a draft of the given kind. agent names the
+// agent a soul belongs to and may be empty.
+func Check(kind, content, agent string, env Env) (*Result, error) {
+	var r *Result
+	switch kind {
+	case "soul":
+		r = checkSoul(content, agent, env)
+	case "workflow":
+		r = checkWorkflow(content, env)
+	case "skill":
+		r = checkSkill(content)
+	default:
+		return nil, fmt.Errorf("unknown kind %q (want %s)", kind, strings.Join(Kinds, ", "))
+	}
+	r.Kind = kind
+	// Checks walk maps, so order by position for a stable result; problems
+	// without one come last.
+	sort.SliceStable(r.Diagnostics, func(i, j int) bool {
+		a, b := r.Diagnostics[i], r.Diagnostics[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Message < b.Message
+	})
+	r.Valid = true
+	for _, d := range r.Diagnostics {
+		if d.Severity == Error {
+			r.Valid = false
+		}
+	}
+	if r.Diagnostics == nil {
+		r.Diagnostics = []Diagnostic{}
+	}
+	return r, nil
+}
+
+// estimateTokens estimates the tokens text takes up in a prompt, at four
+// bytes per token like the session context estimate.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// varRe matches {{...}} placeholders.
+var varRe = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// text locates positions in a plain-text draft.
+type text struct {
+	s     string
+	lines []int // byte offset of each line start
+}
+
+func newText(s string) *text {
+	t := &text{s: s, lines: []int{0}}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			t.lines = append(t.lines, i+1)
+		}
+	}
+	return t
+}
+
+// pos returns the 1-based line and column of a byte offset.
+func (t *text) pos(off int) (int, int) {
+	if off > len(t.s) {
+		off = len(t.s)
+	}
+	line := 0
+	for line+1 < len(t.lines) && t.lines[line+1] <= off {
+		line++
+	}
+	return line + 1, utf8.RuneCountInString(t.s[t.lines[line]:off]) + 1
+}
+
+// at returns a diagnostic spanning the bytes [start, end).
+func (t *text) at(sev Severity, code string, start, end int, format string, args ...any) Diagnostic {
+	d := Diagnostic{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...)}
+	d.Line, d.Column = t.pos(start)
+	if end > start {
+		d.EndLine, d.EndColumn = t.pos(end)
+	}
+	return d
+}
+
+// unclosedFence reports a Markdown code fence that is never closed; the
+// rest of the file would be read as code.
+func unclosedFence(t *text) []Diagnostic {
+	open := -1
+	for i, start := range t.lines {
+		end := len(t.s)
+		if i+1 < len(t.lines) {
+			end = t.lines[i+1]
+		}
+		if strings.HasPrefix(strings.TrimSpace(t.s[start:end]), "```") {
+			if open < 0 {
+				open = start
+			} else {
+				open = -1
+			}
+		}
+	}
+	if open < 0 {
+		return nil
+	}
+	return []Diagnostic{t.at(Warning, "unclosed-fence", open, open+3, "code block is never closed; everything after it reads as code")}
+}
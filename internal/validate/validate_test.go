@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"tetora/internal/config"
+)
+
+var testEnv = Env{
+	Agents:  map[string]bool{"ruri": true, "kokuyou": true},
+	Skills:  map[string]bool{"deploy": true},
+	Tools:   map[string]bool{"web_search": true},
+	SoulMax: 100,
+	Triggers: []config.WorkflowTriggerConfig{
+		{Name: "nightly", WorkflowName: "review", Trigger: config.TriggerSpec{Type: "cron", Cron: "0 25 * * *"}, Variables: map[string]string{"repo": "tetora"}},
+	},
+}
+
+// find returns the diagnostics with the given code.
+func find(r *Result, code string) []Diagnostic {
+	var out []Diagnostic
+	for _, d := range r.Diagnostics {
+		if d.Code == code {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func TestCheckSoul(t *testing.T) {
+	soul := "# Ruri\n\nToday is {{date}}.\n" + strings.Repeat("x", 120) + "\n```go\n"
+	r, err := Check("soul", soul, "ghost", testEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid {
+		t.Error("soul for an unknown agent should be invalid")
+	}
+	if d := find(r, "unexpanded-variable"); len(d) != 1 || d[0].Line != 3 || d[0].Column != 10 || d[0].EndColumn != 18 {
+		t.Errorf("variable = %+v", d)
+	}
+	if d := find(r, "truncated"); len(d) != 1 || d[0].Line != 4 {
+		t.Errorf("truncated = %+v", d)
+	}
+	if d := find(r, "unclosed-fence"); len(d) != 1 || d[0].Line != 5 {
+		t.Errorf("fence = %+v", d)
+	}
+	if len(find(r, "unknown-agent")) != 1 || r.Tokens != (len(soul)+3)/4 {
+		t.Errorf("result = %+v", r)
+	}
+
+	r, _ = Check("soul", "You are Ruri, 日本語 OK.", "ruri", testEnv)
+	if !r.Valid || len(r.Diagnostics) != 0 {
+		t.Errorf("clean soul = %+v", r)
+	}
+}
+
+const testWorkflow = `{
+  "name": "review",
+  "variables": {"branch": "main"},
+  "steps": [
+    {"id": "draft", "agent": "ruri", "prompt": "Review {{branch}} of {{repo}} for {{owner}}"},
+    {"id": "check", "agent": "ghost", "prompt": "{{steps.draft.outputs}}", "dependsOn": ["draft"]},
+    {"id": "run", "type": "skill", "skill": "nope", "dependsOn": ["check"]},
+    {"id": "wait", "type": "delay", "delay": "soon", "dependsOn": ["run"]}
+  ]
+}`
+
+func TestCheckWorkflow(t *testing.T) {
+	r, err := Check("workflow", testWorkflow, "", testEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid {
+		t.Error("workflow should be invalid")
+	}
+	if d := find(r, "unknown-variable"); len(d) != 1 || !strings.Contains(d[0].Message, "{{owner}}") || d[0].Line != 5 || d[0].Path != "steps[0].prompt" {
+		t.Errorf("unknown variable = %+v", d)
+	}
+	if d := find(r, "unknown-field"); len(d) != 1 || d[0].Line != 6 {
+		t.Errorf("unknown field = %+v", d)
+	}
+	if d := find(r, "unknown-agent"); len(d) != 1 || d[0].Path != "steps[1].agent" || d[0].Line != 6 || d[0].Column != 30 {
+		t.Errorf("unknown agent = %+v", d)
+	}
+	if d := find(r, "unknown-skill"); len(d) != 1 || d[0].Line != 7 {
+		t.Errorf("unknown skill = %+v", d)
+	}
+	if d := find(r, "workflow"); len(d) != 1 || d[0].Path != "steps[3].delay" || d[0].Line != 8 {
+		t.Errorf("engine validation = %+v", d)
+	}
+	if d := find(r, "schedule"); len(d) != 1 || d[0].Line != 0 || !strings.Contains(d[0].Message, "nightly") {
+		t.Errorf("trigger schedule = %+v", d)
+	}
+	if last := r.Diagnostics[len(r.Diagnostics)-1]; last.Code != "schedule" {
+		t.Errorf("positionless diagnostics should come last: %+v", r.Diagnostics)
+	}
+	if r.Tokens == 0 {
+		t.Error("tokens not estimated")
+	}
+}
+
+func TestCheckWorkflowSyntax(t *testing.T) {
+	r, _ := Check("workflow", "{\n  \"name\": \"x\",\n  \"steps\": [}\n}", "", testEnv)
+	if len(r.Diagnostics) != 1 || r.Diagnostics[0].Code != "syntax" || r.Diagnostics[0].Line != 3 {
+		t.Errorf("syntax = %+v", r.Diagnostics)
+	}
+	r, _ = Check("workflow", `{"name": "x", "steps": "nope"}`, "", testEnv)
+	if len(r.Diagnostics) != 1 || r.Diagnostics[0].Code != "type" || r.Diagnostics[0].Path != "steps" {
+		t.Errorf("type = %+v", r.Diagnostics)
+	}
+}
+
+func TestCheckSkill(t *testing.T) {
+	draft := "---\nname: Bad_Name\ntriggers: [deploy]\noops\n---\nRun {{target}}.\n"
+	r, err := Check("skill", draft, "", testEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid {
+		t.Error("invalid name should fail")
+	}
+	if d := find(r, "invalid-name"); len(d) != 1 || d[0].Line != 2 {
+		t.Errorf("name = %+v", d)
+	}
+	if d := find(r, "frontmatter-syntax"); len(d) != 1 || d[0].Line != 4 {
+		t.Errorf("frontmatter = %+v", d)
+	}
+	if len(find(r, "missing-description")) != 1 || len(find(r, "no-triggers")) != 0 {
+		t.Errorf("diagnostics = %+v", r.Diagnostics)
+	}
+	if d := find(r, "unexpanded-variable"); len(d) != 1 || d[0].Line != 6 || d[0].Column != 5 {
+		t.Errorf("variable = %+v", d)
+	}
+
+	r, _ = Check("skill", "---\nname: deploy\ndescription: Deploy the site\n", "", testEnv)
+	if len(find(r, "unclosed-frontmatter")) != 1 {
+		t.Errorf("unclosed = %+v", r.Diagnostics)
+	}
+	if _, err := Check("recipe", "", "", testEnv); err == nil {
+		t.Error("unknown kind should fail")
+	}
+}
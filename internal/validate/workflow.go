@@ -0,0 +1,319 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"tetora/internal/cron"
+	iwf "tetora/internal/workflow"
+)
+
+// checkWorkflow checks a workflow definition: its structure as the workflow
+// engine validates it on save, the agents, skills and tools it names, the
+// variables its templates use, and the schedules of triggers that run it.
+func checkWorkflow(content string, env Env) *Result {
+	t := newText(content)
+	r := &Result{}
+	var w iwf.Workflow
+	if err := json.Unmarshal([]byte(content), &w); err != nil {
+		var syn *json.SyntaxError
+		var typ *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syn):
+			r.Diagnostics = append(r.Diagnostics, t.at(Error, "syntax", int(syn.Offset)-1, int(syn.Offset), "%s", syn.Error()))
+		case errors.As(err, &typ):
+			d := t.at(Error, "type", int(typ.Offset)-1, int(typ.Offset), "%s should be %s, not %s", typ.Field, typ.Type, typ.Value)
+			d.Path = typ.Field
+			r.Diagnostics = append(r.Diagnostics, d)
+		default:
+			r.Diagnostics = append(r.Diagnostics, t.at(Error, "syntax", 0, 0, "%s", err.Error()))
+		}
+		return r
+	}
+	c := &wfCheck{t: t, idx: indexJSON([]byte(content)), env: env, w: &w, stepPath: map[string]string{}}
+	c.walkSteps(w.Steps, "steps", "", func(s iwf.WorkflowStep, path, id string) {
+		c.stepPath[id] = path
+		r.Tokens += estimateTokens(s.Prompt) + estimateTokens(s.ReviewPrompt) + estimateTokens(s.HumanPrompt)
+	})
+
+	for _, msg := range iwf.ValidateWorkflow(&w) {
+		c.add(Error, "workflow", c.pathFor(msg), "%s", msg)
+	}
+	c.checkRefs()
+	c.checkVariables()
+	c.checkTriggers()
+	r.Diagnostics = c.diags
+	return r
+}
+
+type wfCheck struct {
+	t        *text
+	idx      map[string]int // JSON path -> byte offset of its value
+	env      Env
+	w        *iwf.Workflow
+	stepPath map[string]string // step ID ("parent/child" for parallel sub-steps) -> JSON path
+	diags    []Diagnostic
+}
+
+// walkSteps calls fn for every step, parallel sub-steps included.
+func (c *wfCheck) walkSteps(steps []iwf.WorkflowStep, prefix, parent string, fn func(s iwf.WorkflowStep, path, id string)) {
+	for i, s := range steps {
+		path := fmt.Sprintf("%s[%d]", prefix, i)
+		id := s.ID
+		if parent != "" {
+			id = parent + "/" + s.ID
+		}
+		fn(s, path, id)
+		c.walkSteps(s.Parallel, path+".parallel", s.ID, fn)
+	}
+}
+
+// add records a diagnostic at the JSON path, or the closest enclosing value
+// that exists in the draft.
+func (c *wfCheck) add(sev Severity, code, path string, format string, args ...any) {
+	d := Diagnostic{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...), Path: path}
+	for p := path; p != ""; p = parentPath(p) {
+		if off, ok := c.idx[p]; ok {
+			d.Line, d.Column = c.t.pos(off)
+			break
+		}
+	}
+	c.diags = append(c.diags, d)
+}
+
+// addIn records a diagnostic on the text match inside the string at path,
+// falling back to the field itself.
+func (c *wfCheck) addIn(sev Severity, code, path, match string, format string, args ...any) {
+	off, ok := c.idx[path]
+	if ok {
+		end := strings.IndexByte(c.t.s[off:], '\n')
+		if end < 0 {
+			end = len(c.t.s) - off
+		}
+		if i := strings.Index(c.t.s[off:off+end], match); i >= 0 {
+			d := c.t.at(sev, code, off+i, off+i+len(match), format, args...)
+			d.Path = path
+			c.diags = append(c.diags, d)
+			return
+		}
+	}
+	c.add(sev, code, path, format, args...)
+}
+
+var (
+	stepMsgRe  = regexp.MustCompile(`^step "([^"]+)"(?: field "([^"]+)")?`)
+	fieldMsgRe = regexp.MustCompile(`^step "[^"]+": (?:invalid )?'?(\w+)'? `)
+	stepsMsgRe = regexp.MustCompile(`^steps "([^"]+)" and "([^"]+)"`)
+)
+
+// pathFor finds the step a workflow validation message is about.
+func (c *wfCheck) pathFor(msg string) string {
+	if m := stepMsgRe.FindStringSubmatch(msg); m != nil {
+		if p, ok := c.stepPath[m[1]]; ok {
+			if m[2] != "" {
+				return p + "." + m[2]
+			}
+			// "step "x": invalid delay ...", "step "x": 'then' references ..."
+			if f := fieldMsgRe.FindStringSubmatch(msg); f != nil {
+				if _, ok := c.idx[p+"."+f[1]]; ok {
+					return p + "." + f[1]
+				}
+			}
+			return p
+		}
+	}
+	if m := stepsMsgRe.FindStringSubmatch(msg); m != nil {
+		return c.stepPath[m[2]]
+	}
+	switch {
+	case strings.HasPrefix(msg, "workflow name"), strings.HasPrefix(msg, "invalid workflow name"):
+		return "name"
+	case strings.HasPrefix(msg, "invalid timeout"):
+		return "timeout"
+	case strings.HasPrefix(msg, "workflow must have"):
+		return "steps"
+	}
+	return ""
+}
+
+// checkRefs reports agents, skills and tools that do not exist. Templated
+// names are resolved at run time and skipped.
+func (c *wfCheck) checkRefs() {
+	c.walkSteps(c.w.Steps, "steps", "", func(s iwf.WorkflowStep, path, id string) {
+		for field, name := range map[string]string{"agent": s.Agent, "reviewer": s.Reviewer} {
+			if name != "" && !strings.Contains(name, "{{") && !c.env.Agents[name] {
+				c.add(Error, "unknown-agent", path+"."+field, "step %q: agent %q is not configured", id, name)
+			}
+		}
+		if s.Skill != "" && c.env.Skills != nil && !c.env.Skills[s.Skill] {
+			c.add(Error, "unknown-skill", path+".skill", "step %q: skill %q does not exist or is not approved", id, s.Skill)
+		}
+		if s.ToolName != "" && c.env.Tools != nil && !c.env.Tools[s.ToolName] {
+			c.add(Error, "unknown-tool", path+".toolName", "step %q: tool %q is not registered", id, s.ToolName)
+		}
+		for field, v := range map[string]string{"retryDelay": s.RetryDelay, "timeout": s.Timeout} {
+			if v != "" && !strings.Contains(v, "{{") {
+				if _, err := time.ParseDuration(v); err != nil {
+					c.add(Error, "duration", path+"."+field, "step %q: invalid %s %q", id, field, v)
+				}
+			}
+		}
+	})
+}
+
+// stepFields are the step fields the engine expands templates in.
+func stepFields(s iwf.WorkflowStep) map[string]string {
+	f := map[string]string{
+		"prompt": s.Prompt, "if": s.If, "notifyMsg": s.NotifyMsg, "humanPrompt": s.HumanPrompt,
+		"humanAssignee": s.HumanAssignee, "externalUrl": s.ExternalURL, "externalRawBody": s.ExternalRawBody,
+		"callbackKey": s.CallbackKey, "agent": s.Agent, "reviewer": s.Reviewer,
+		"reviewPrompt": s.ReviewPrompt, "artifact": s.Artifact, "model": s.Model,
+	}
+	for i, a := range s.SkillArgs {
+		f[fmt.Sprintf("skillArgs[%d]", i)] = a
+	}
+	for prefix, m := range map[string]map[string]string{
+		"externalHeaders": s.ExternalHeaders, "externalBody": s.ExternalBody,
+		"toolInput": s.ToolInput, "humanContext": s.HumanContext,
+	} {
+		for k, v := range m {
+			f[prefix+"."+k] = v
+		}
+	}
+	return f
+}
+
+// triggerVars are the variables triggers of each type pass to the workflow
+// they run, besides their configured ones.
+var triggerVars = map[string][]string{
+	"cron":    {"_trigger_name", "_trigger_type", "_trigger_time"},
+	"event":   {"_trigger_name", "_trigger_type", "_trigger_time", "event_type", "task_id", "session_id"},
+	"webhook": {"_trigger_name", "_trigger_type", "_trigger_time"},
+	"file":    {"_trigger_name", "_trigger_type", "_trigger_time", "file_path", "file_name", "file_dir", "file_event", "file_size"},
+}
+
+// checkVariables reports template variables that resolve to nothing: input
+// variables neither declared in the workflow nor passed by a trigger, and
+// step results other than output, status and error. References to unknown
+// steps are already reported by the engine's validation.
+func (c *wfCheck) checkVariables() {
+	known := map[string]bool{}
+	eventData := false // event triggers pass the event's fields as event_<field>
+	for k := range c.w.Variables {
+		known[k] = true
+	}
+	for _, tr := range c.env.Triggers {
+		if tr.WorkflowName == c.w.Name {
+			for k := range tr.Variables {
+				known[k] = true
+			}
+			for _, k := range triggerVars[tr.Trigger.Type] {
+				known[k] = true
+			}
+			if tr.Trigger.Type == "event" {
+				eventData = true
+			}
+		}
+	}
+	c.walkSteps(c.w.Steps, "steps", "", func(s iwf.WorkflowStep, path, id string) {
+		for field, v := range stepFields(s) {
+			for _, m := range varRe.FindAllStringSubmatch(v, -1) {
+				expr := m[1]
+				parts := strings.SplitN(expr, ".", 3)
+				switch {
+				case parts[0] == "env":
+				case parts[0] == "steps" && len(parts) == 3:
+					if f := parts[2]; f != "output" && f != "status" && f != "error" {
+						c.addIn(Warning, "unknown-field", path+"."+field, m[0],
+							"%s: steps have output, status and error; %q is always empty", m[0], f)
+					}
+				case known[expr], eventData && strings.HasPrefix(expr, "event_"):
+				default:
+					c.addIn(Warning, "unknown-variable", path+"."+field, m[0],
+						"%s is not declared in variables and is empty unless the run passes it", m[0])
+				}
+			}
+		}
+	})
+}
+
+// checkTriggers reports schedule errors in the triggers that run this
+// workflow. Triggers live in the config, so these have no position.
+func (c *wfCheck) checkTriggers() {
+	for _, tr := range c.env.Triggers {
+		if tr.WorkflowName != c.w.Name || tr.Trigger.Type != "cron" {
+			continue
+		}
+		if _, err := cron.Parse(tr.Trigger.Cron); err != nil {
+			c.diags = append(c.diags, Diagnostic{Severity: Error, Code: "schedule",
+				Message: fmt.Sprintf("trigger %q: invalid cron %q: %v", tr.Name, tr.Trigger.Cron, err)})
+		}
+		if tz := tr.Trigger.TZ; tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				c.diags = append(c.diags, Diagnostic{Severity: Error, Code: "schedule",
+					Message: fmt.Sprintf("trigger %q: unknown time zone %q", tr.Name, tz)})
+			}
+		}
+	}
+}
+
+// parentPath drops the last element of a JSON path.
+func parentPath(p string) string {
+	i := strings.LastIndexAny(p, ".[")
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+// indexJSON maps the JSON path of every value in data to the byte offset
+// where the value starts. data must be valid JSON.
+func indexJSON(data []byte) map[string]int {
+	idx := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		off := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		for off < len(data) && strings.IndexByte(" \t\r\n:,", data[off]) >= 0 {
+			off++
+		}
+		idx[path] = off
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := k.(string)
+				child := key
+				if path != "" {
+					child = path + "." + key
+				}
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	walk("")
+	return idx
+}
@@ -6,11 +6,11 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 	"time"
 
+	"tetora/internal/cfgfile"
 	"tetora/internal/db"
 )
 
@@ -66,19 +66,45 @@ func NewID() string {
 
 // --- Snapshot Functions ---
 
-// SnapshotConfig takes a snapshot of the current config.json content.
+// SnapshotConfig takes a snapshot of the current config file content.
 // It computes a diff against the previous version and stores both.
+// YAML and TOML configs are stored as JSON, so versions stay comparable
+// when the file changes format.
 func SnapshotConfig(dbPath, configPath, changedBy, reason string) error {
 	if dbPath == "" {
 		return nil
 	}
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config for snapshot: %w", err)
 	}
 	return SnapshotEntity(dbPath, "config", "config.json", string(data), changedBy, reason)
 }
 
+// EffectiveConfigType is the entity type of effective config versions: the
+// config file with its config.d, profile and .local overlays merged in, as
+// the daemon runs it. There is one entity per profile.
+const EffectiveConfigType = "config-effective"
+
+// EffectiveConfigName returns the entity name of a profile's effective
+// config versions.
+func EffectiveConfigName(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
+
+// SnapshotEffectiveConfig takes a snapshot of a profile's effective config,
+// given as JSON. Effective versions can be listed and diffed like config
+// versions, but not rolled back: they are not a file.
+func SnapshotEffectiveConfig(dbPath, profile, content, changedBy, reason string) error {
+	if dbPath == "" {
+		return nil
+	}
+	return SnapshotEntity(dbPath, EffectiveConfigType, EffectiveConfigName(profile), content, changedBy, reason)
+}
+
 // SnapshotWorkflow takes a snapshot of a workflow definition.
 func SnapshotWorkflow(dbPath, workflowName, content, changedBy, reason string) error {
 	if dbPath == "" {
@@ -259,19 +285,23 @@ func versionFromRow(row map[string]any) Version {
 
 // --- Restore Functions ---
 
-// RestoreConfig restores config.json to a saved version.
-// Returns the previous content for undo purposes.
+// RestoreConfig restores the config file to a saved version, written in
+// the file's current format. Returns the previous content, as JSON, for
+// undo purposes.
 func RestoreConfig(dbPath, configPath, versionID string) (string, error) {
 	ver, err := QueryByID(dbPath, versionID)
 	if err != nil {
 		return "", err
 	}
+	if ver.EntityType == EffectiveConfigType {
+		return "", fmt.Errorf("version %q is an effective config, which merges several files; roll back config.json or edit the overlay instead", versionID)
+	}
 	if ver.EntityType != "config" {
 		return "", fmt.Errorf("version %q is a %s, not a config", versionID, ver.EntityType)
 	}
 
 	// Read current config for backup snapshot.
-	current, err := os.ReadFile(configPath)
+	current, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return "", fmt.Errorf("read current config: %w", err)
 	}
@@ -286,7 +316,7 @@ func RestoreConfig(dbPath, configPath, versionID string) (string, error) {
 	}
 
 	// Write restored content.
-	if err := os.WriteFile(configPath, []byte(ver.ContentJSON), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, []byte(ver.ContentJSON), 0o600); err != nil {
 		return "", fmt.Errorf("write restored config: %w", err)
 	}
 
@@ -0,0 +1,193 @@
+package walship
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generation is a snapshot and the WAL segments shipped after it.
+type Generation struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"` // time of the last segment, or Start
+	Segments int       `json:"segments"`
+	Size     int64     `json:"size"`
+
+	snapshot string
+	segments []segment
+	keys     []string
+}
+
+type segment struct {
+	key  string
+	seq  int
+	time time.Time
+}
+
+// Generations lists the generations in s, oldest first. Generations whose
+// snapshot never finished uploading are included, without a Start.
+func Generations(ctx context.Context, s Store) ([]Generation, error) {
+	objs, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*Generation{}
+	var names []string
+	for _, o := range objs {
+		name, rest, ok := strings.Cut(strings.TrimPrefix(o.Key, prefix), "/")
+		if !ok {
+			continue
+		}
+		g := byName[name]
+		if g == nil {
+			g = &Generation{Name: name}
+			byName[name] = g
+			names = append(names, name)
+		}
+		g.keys = append(g.keys, o.Key)
+		g.Size += o.Size
+		switch {
+		case rest == "snapshot.db.gz":
+			if t, err := time.Parse(genLayout, name); err == nil {
+				g.snapshot, g.Start = o.Key, t
+			}
+		case strings.HasPrefix(rest, "wal/") && strings.HasSuffix(rest, ".wal.gz"):
+			seqStr, msStr, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(rest, "wal/"), ".wal.gz"), "-")
+			seq, err1 := strconv.Atoi(seqStr)
+			ms, err2 := strconv.ParseInt(msStr, 10, 64)
+			if err1 == nil && err2 == nil {
+				g.segments = append(g.segments, segment{key: o.Key, seq: seq, time: time.UnixMilli(ms).UTC()})
+			}
+		}
+	}
+	sort.Strings(names)
+	out := make([]Generation, 0, len(names))
+	for _, name := range names {
+		g := byName[name]
+		sort.Slice(g.segments, func(i, j int) bool { return g.segments[i].seq < g.segments[j].seq })
+		g.Segments = len(g.segments)
+		g.End = g.Start
+		if n := len(g.segments); n > 0 {
+			g.End = g.segments[n-1].time
+		}
+		out = append(out, *g)
+	}
+	return out, nil
+}
+
+// prune deletes generations no longer needed to restore to any time within
+// the retention window: those followed by a generation that started before
+// it.
+func (r *Replicator) prune(ctx context.Context) error {
+	if r.cfg.Retain <= 0 {
+		return nil
+	}
+	gens, err := Generations(ctx, r.cfg.Store)
+	if err != nil {
+		return err
+	}
+	cutoff := r.now().Add(-r.cfg.Retain)
+	for i := 0; i+1 < len(gens); i++ {
+		next := gens[i+1]
+		if next.snapshot == "" || next.Start.After(cutoff) {
+			break
+		}
+		for _, key := range gens[i].keys {
+			if err := r.cfg.Store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		r.cfg.LogInfo("replication generation expired", "generation", gens[i].Name)
+	}
+	return nil
+}
+
+// Restored describes a point-in-time restore.
+type Restored struct {
+	Generation string    `json:"generation"`
+	Segments   int       `json:"segments"`
+	At         time.Time `json:"at"` // time of the last change applied
+}
+
+// Restore rebuilds the database as of at, or its latest state when at is
+// zero, and writes it to dst.
+func Restore(ctx context.Context, s Store, at time.Time, dst string) (Restored, error) {
+	gens, err := Generations(ctx, s)
+	if err != nil {
+		return Restored{}, err
+	}
+	var g *Generation
+	for i := range gens {
+		if gens[i].snapshot != "" && (at.IsZero() || !gens[i].Start.After(at)) {
+			g = &gens[i]
+		}
+	}
+	if g == nil {
+		if at.IsZero() {
+			return Restored{}, fmt.Errorf("no replicated generations")
+		}
+		return Restored{}, fmt.Errorf("no replicated generation starts before %s", at.Format(time.RFC3339))
+	}
+	res := Restored{Generation: g.Name, At: g.Start}
+
+	tmp := dst + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return res, err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+	if err := getGzip(ctx, s, g.snapshot, f); err != nil {
+		return res, fmt.Errorf("snapshot: %w", err)
+	}
+	pageSize, err := dbPageSize(f)
+	if err != nil {
+		return res, err
+	}
+	for i, seg := range g.segments {
+		if !at.IsZero() && seg.time.After(at) {
+			break
+		}
+		if seg.seq != i {
+			return res, fmt.Errorf("segment %d of generation %s is missing", i, g.Name)
+		}
+		var data bytes.Buffer
+		if err := getGzip(ctx, s, seg.key, &data); err != nil {
+			return res, fmt.Errorf("segment %d: %w", seg.seq, err)
+		}
+		if err := applyFrames(f, data.Bytes(), pageSize); err != nil {
+			return res, fmt.Errorf("segment %d: %w", seg.seq, err)
+		}
+		res.Segments++
+		res.At = seg.time
+	}
+	if err := f.Sync(); err != nil {
+		return res, err
+	}
+	if err := f.Close(); err != nil {
+		return res, err
+	}
+	return res, os.Rename(tmp, dst)
+}
+
+func getGzip(ctx context.Context, s Store, key string, w io.Writer) error {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, zr)
+	return err
+}
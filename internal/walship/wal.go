@@ -0,0 +1,128 @@
+package walship
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WAL file format: https://www.sqlite.org/fileformat2.html#walformat
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+var errNoWAL = errors.New("no valid WAL header")
+
+// walHeader is the header of a WAL file. Its salt identifies the WAL's
+// current contents: it changes each time the WAL is restarted.
+type walHeader struct {
+	bigEndian bool // checksums are computed over big-endian words
+	pageSize  int
+	salt      [8]byte
+	checksum  [2]uint32
+}
+
+func readWALHeader(b []byte) (walHeader, error) {
+	var h walHeader
+	if len(b) < walHeaderSize {
+		return h, errNoWAL
+	}
+	magic := binary.BigEndian.Uint32(b[0:4])
+	if magic != 0x377f0682 && magic != 0x377f0683 {
+		return h, errNoWAL
+	}
+	h.bigEndian = magic&1 == 1
+	h.pageSize = int(binary.BigEndian.Uint32(b[8:12]))
+	if h.pageSize == 1 {
+		h.pageSize = 65536
+	}
+	copy(h.salt[:], b[16:24])
+	h.checksum = [2]uint32{binary.BigEndian.Uint32(b[24:28]), binary.BigEndian.Uint32(b[28:32])}
+	if walChecksum(h.bigEndian, [2]uint32{}, b[:24]) != h.checksum {
+		return h, errNoWAL
+	}
+	return h, nil
+}
+
+// walChecksum continues the WAL's running checksum s over b.
+func walChecksum(bigEndian bool, s [2]uint32, b []byte) [2]uint32 {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// scanFrames walks the frames in b, which starts at a frame boundary whose
+// running checksum is ck. It stops at the first frame that is torn or left
+// over from an earlier WAL, and returns the length of b up to the last
+// commit frame, the number of frames in it and the checksum after them.
+func scanFrames(b []byte, h walHeader, ck [2]uint32) (end, frames int, after [2]uint32) {
+	size := walFrameHeaderSize + h.pageSize
+	after = ck
+	n := 0
+	for off := 0; off+size <= len(b); off += size {
+		fh := b[off : off+walFrameHeaderSize]
+		if !bytes.Equal(fh[8:16], h.salt[:]) {
+			break
+		}
+		ck = walChecksum(h.bigEndian, ck, fh[:8])
+		ck = walChecksum(h.bigEndian, ck, b[off+walFrameHeaderSize:off+size])
+		if ck[0] != binary.BigEndian.Uint32(fh[16:20]) || ck[1] != binary.BigEndian.Uint32(fh[20:24]) {
+			break
+		}
+		n++
+		if binary.BigEndian.Uint32(fh[4:8]) != 0 {
+			end, frames, after = off+size, n, ck
+		}
+	}
+	return end, frames, after
+}
+
+// applyFrames writes the pages of a shipped segment into the database file
+// f, truncating it to the database size recorded at each commit.
+func applyFrames(f *os.File, seg []byte, pageSize int) error {
+	size := walFrameHeaderSize + pageSize
+	if len(seg)%size != 0 {
+		return fmt.Errorf("segment is not a whole number of %d-byte frames", size)
+	}
+	for off := 0; off < len(seg); off += size {
+		pgno := binary.BigEndian.Uint32(seg[off:])
+		commit := binary.BigEndian.Uint32(seg[off+4:])
+		if pgno == 0 {
+			return fmt.Errorf("frame at %d has no page number", off)
+		}
+		if _, err := f.WriteAt(seg[off+walFrameHeaderSize:off+size], int64(pgno-1)*int64(pageSize)); err != nil {
+			return err
+		}
+		if commit != 0 {
+			if err := f.Truncate(int64(commit) * int64(pageSize)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dbPageSize reads the page size from a database file header.
+func dbPageSize(f *os.File) (int, error) {
+	b := make([]byte, 2)
+	if _, err := f.ReadAt(b, 16); err != nil {
+		return 0, fmt.Errorf("read database header: %w", err)
+	}
+	ps := int(binary.BigEndian.Uint16(b))
+	if ps == 1 {
+		ps = 65536
+	}
+	if ps < 512 || ps&(ps-1) != 0 {
+		return 0, fmt.Errorf("invalid database page size %d", ps)
+	}
+	return ps, nil
+}
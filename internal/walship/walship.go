@@ -0,0 +1,426 @@
+// Package walship continuously replicates a SQLite database in WAL mode to
+// a bucket or directory, Litestream-style, so that it can be restored as of
+// any point in time.
+//
+// A long-lived sqlite3 process holds a read transaction open, which stops
+// other connections from restarting or deleting the WAL behind the
+// replicator's back. Each generation starts with a copy of the database
+// file. After it, every transaction committed to the WAL is shipped as a
+// compressed segment, and replaying a generation's segments over its
+// snapshot in order rebuilds the database as of any segment. Once its frames
+// are shipped, the replicator checkpoints the WAL itself so that it does not
+// grow without bound. Objects are laid out as
+//
+//	replication/<generation>/snapshot.db.gz
+//	replication/<generation>/wal/<seq>-<unix ms>.wal.gz
+//
+// where the generation is its start time, e.g. 20261015T030000Z.
+package walship
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tetora/internal/objstore"
+)
+
+// Store holds the replicated generations; *objstore.Client and
+// *objstore.Dir implement it.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]objstore.Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LogFn is a structured logging function.
+type LogFn func(msg string, keyvals ...any)
+
+// Config configures a Replicator.
+type Config struct {
+	DBPath           string
+	Store            Store
+	Interval         time.Duration // how often new transactions are shipped
+	SnapshotInterval time.Duration // how often a new generation starts
+	Retain           time.Duration // how far back restores can go; 0 keeps everything
+	LogInfo          LogFn
+	LogWarn          LogFn
+}
+
+// Status describes the replicator for /healthz.
+type Status struct {
+	Target     string    `json:"target"`
+	Generation string    `json:"generation,omitempty"`
+	Segments   int       `json:"segments"` // shipped since start
+	LastSync   time.Time `json:"lastSync,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+const (
+	prefix = "replication/"
+	// genLayout names generations by their start time.
+	genLayout = "20060102T150405Z"
+
+	// The replicator checkpoints the WAL after this many shipped frames or
+	// this long, whichever comes first.
+	checkpointFrames = 1000
+	checkpointEvery  = time.Minute
+)
+
+// Replicator ships a database's WAL to a Store.
+type Replicator struct {
+	cfg Config
+	now func() time.Time
+
+	conn     *reader
+	gen      string
+	genStart time.Time
+	seq      int
+
+	// The WAL being shipped, identified by its header's salt.
+	hdr    walHeader
+	active bool
+	offset int64     // next byte to ship
+	frames int       // frames shipped
+	ck     [2]uint32 // running checksum at offset
+	done   [8]byte   // salt of the last WAL fully shipped and checkpointed
+
+	lastCheckpoint time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a replicator for cfg. Zero intervals take their defaults.
+func New(cfg Config) *Replicator {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = 24 * time.Hour
+	}
+	if cfg.LogInfo == nil {
+		cfg.LogInfo = func(string, ...any) {}
+	}
+	if cfg.LogWarn == nil {
+		cfg.LogWarn = func(string, ...any) {}
+	}
+	return &Replicator{cfg: cfg, now: time.Now, status: Status{Target: fmt.Sprint(cfg.Store)}}
+}
+
+// Start replicates every interval until ctx is done, then ships what is
+// left and lets go of the database.
+func (r *Replicator) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(r.cfg.Interval)
+		defer t.Stop()
+		for {
+			r.step(ctx)
+			select {
+			case <-ctx.Done():
+				final, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				r.step(final)
+				cancel()
+				r.close()
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// step runs one Sync and records the outcome. After a failure the next
+// Sync starts a new generation, since transactions may have been missed.
+func (r *Replicator) step(ctx context.Context) {
+	err := r.Sync(ctx)
+	r.mu.Lock()
+	prev := r.status.Error
+	r.status.Generation = r.gen
+	if err != nil {
+		r.status.Error = err.Error()
+	} else {
+		r.status.Error = ""
+		r.status.LastSync = r.now()
+	}
+	r.mu.Unlock()
+	if err != nil {
+		if err.Error() != prev {
+			r.cfg.LogWarn("replication failed", "error", err)
+		}
+		r.close()
+	}
+}
+
+// Status returns the replicator's state.
+func (r *Replicator) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Sync ships the transactions committed since the last call, starting a new
+// generation first when one is due. It must not be called concurrently.
+func (r *Replicator) Sync(ctx context.Context) error {
+	if r.conn == nil {
+		c, err := openReader(r.cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		r.conn, r.gen = c, ""
+	}
+	if r.gen == "" || r.now().Sub(r.genStart) >= r.cfg.SnapshotInterval {
+		if err := r.snapshot(ctx); err != nil {
+			return err
+		}
+		if err := r.prune(ctx); err != nil {
+			r.cfg.LogWarn("replication retention failed", "error", err)
+		}
+	}
+	if err := r.ship(ctx); err != nil {
+		return err
+	}
+	if r.frames > 0 && (r.frames >= checkpointFrames || r.now().Sub(r.lastCheckpoint) >= checkpointEvery) {
+		return r.checkpoint(ctx)
+	}
+	return nil
+}
+
+func (r *Replicator) close() {
+	if r.conn != nil {
+		r.conn.close()
+		r.conn = nil
+	}
+	r.gen = ""
+}
+
+// snapshot starts a new generation with a copy of the database file. The
+// read transaction keeps the WAL from restarting meanwhile, so pages a
+// checkpoint changes during the copy are replayed from the WAL on restore.
+func (r *Replicator) snapshot(ctx context.Context) error {
+	now := r.now().UTC()
+	gen := now.Format(genLayout)
+	f, err := os.Open(r.cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tmp, err := os.CreateTemp("", "walship-*.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, f); err != nil {
+		return fmt.Errorf("copy database: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := r.cfg.Store.Put(ctx, prefix+gen+"/snapshot.db.gz", tmp, size); err != nil {
+		return fmt.Errorf("upload snapshot: %w", err)
+	}
+
+	// Ship the WAL from its start: its frames may be newer than the copy.
+	r.gen, r.genStart, r.seq = gen, now, 0
+	r.active, r.done = false, [8]byte{}
+	r.lastCheckpoint = now
+	r.cfg.LogInfo("replication generation started", "generation", gen, "snapshotBytes", size)
+	return nil
+}
+
+// ship uploads the transactions committed to the WAL since the last call.
+func (r *Replicator) ship(ctx context.Context) error {
+	f, err := os.Open(r.cfg.DBPath + "-wal")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !r.active {
+		b := make([]byte, walHeaderSize)
+		if _, err := io.ReadFull(f, b); err != nil {
+			return nil // empty until the next write
+		}
+		h, err := readWALHeader(b)
+		if err != nil || h.salt == r.done {
+			return nil // not restarted yet since the last checkpoint
+		}
+		r.hdr, r.active = h, true
+		r.offset, r.frames, r.ck = walHeaderSize, 0, h.checksum
+	}
+
+	buf := make([]byte, 4<<20)
+	for {
+		if len(buf) < walFrameHeaderSize+r.hdr.pageSize {
+			buf = make([]byte, walFrameHeaderSize+r.hdr.pageSize)
+		}
+		n, err := f.ReadAt(buf, r.offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		end, frames, ck := scanFrames(buf[:n], r.hdr, r.ck)
+		if end == 0 {
+			if n < len(buf) {
+				return nil
+			}
+			// A transaction larger than the buffer.
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		key := fmt.Sprintf("%s%s/wal/%08d-%d.wal.gz", prefix, r.gen, r.seq, r.now().UnixMilli())
+		if err := putGzip(ctx, r.cfg.Store, key, buf[:end]); err != nil {
+			return fmt.Errorf("upload wal: %w", err)
+		}
+		r.seq++
+		r.offset += int64(end)
+		r.frames += frames
+		r.ck = ck
+		r.mu.Lock()
+		r.status.Segments++
+		r.mu.Unlock()
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+// checkpoint copies the shipped WAL into the database and restarts it.
+// Transactions committed after the last ship are still in the WAL until a
+// writer reuses it, so they are shipped right after; if one was lost, a new
+// generation starts.
+func (r *Replicator) checkpoint(ctx context.Context) error {
+	out, err := r.conn.exec("COMMIT;\n.timeout 2000\nPRAGMA wal_checkpoint(RESTART);\n.timeout 30000\nBEGIN; SELECT count(*) FROM sqlite_master;")
+	if err != nil {
+		return err
+	}
+	r.lastCheckpoint = r.now()
+	var busy, logFrames int
+	if len(out) > 0 {
+		parts := strings.Split(out[0], "|")
+		if len(parts) == 3 {
+			busy, _ = strconv.Atoi(parts[0])
+			logFrames, _ = strconv.Atoi(parts[1])
+		}
+	}
+	if busy != 0 {
+		return nil // readers still need the WAL; try again later
+	}
+	if err := r.ship(ctx); err != nil {
+		return err
+	}
+	if r.frames < logFrames {
+		r.cfg.LogWarn("replication missed WAL frames at checkpoint; starting a new generation",
+			"shipped", r.frames, "frames", logFrames)
+		r.gen = ""
+		return nil
+	}
+	r.done, r.active = r.hdr.salt, false
+	return nil
+}
+
+func putGzip(ctx context.Context, s Store, key string, data []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return s.Put(ctx, key, &buf, int64(buf.Len()))
+}
+
+// reader is a sqlite3 process holding a read transaction on the database.
+type reader struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	stderr bytes.Buffer
+	n      int
+}
+
+func openReader(dbPath string) (*reader, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+	c := &reader{cmd: exec.Command("sqlite3", "-batch", "-bail", dbPath)}
+	c.cmd.Stderr = &c.stderr
+	in, err := c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start sqlite3: %w", err)
+	}
+	c.in, c.out = in, bufio.NewReader(out)
+
+	mode, err := c.exec(".timeout 30000\nPRAGMA journal_mode;")
+	if err != nil {
+		return nil, err
+	}
+	if len(mode) == 0 || mode[0] != "wal" {
+		c.close()
+		return nil, fmt.Errorf("%s is not in WAL mode", dbPath)
+	}
+	if _, err := c.exec("BEGIN; SELECT count(*) FROM sqlite_master;"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// exec runs sql and returns its output lines. On error the process is
+// gone.
+func (c *reader) exec(sql string) ([]string, error) {
+	c.n++
+	mark := fmt.Sprintf("walship-%d", c.n)
+	fmt.Fprintf(c.in, "%s\nSELECT '%s';\n", sql, mark)
+	var lines []string
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			c.close()
+			if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+				return nil, fmt.Errorf("sqlite3: %s", msg)
+			}
+			return nil, fmt.Errorf("sqlite3: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == mark {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// close ends the process, which rolls back the read transaction.
+func (c *reader) close() {
+	if c.in != nil {
+		c.in.Close()
+		c.in = nil
+		c.cmd.Wait()
+	}
+}
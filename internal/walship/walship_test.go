@@ -0,0 +1,114 @@
+package walship
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tetora/internal/objstore"
+)
+
+func sqlite(t *testing.T, db, sql string) string {
+	t.Helper()
+	out, err := exec.Command("sqlite3", db, sql).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 %q: %v: %s", sql, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestReplicateAndRestore(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	dir := t.TempDir()
+	db := filepath.Join(dir, "history.db")
+	sqlite(t, db, "PRAGMA journal_mode=WAL; CREATE TABLE t(v INTEGER);")
+
+	store := objstore.NewDir(filepath.Join(dir, "replica"))
+	r := New(Config{DBPath: db, Store: store})
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+	defer r.close()
+	ctx := context.Background()
+
+	sync := func() {
+		t.Helper()
+		clock = clock.Add(time.Second)
+		if err := r.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sync()
+	sqlite(t, db, "INSERT INTO t VALUES(1);")
+	sync()
+	afterFirst := clock
+	sqlite(t, db, "INSERT INTO t VALUES(2);")
+	sync()
+
+	// Force a checkpoint, then keep writing into the restarted WAL.
+	r.lastCheckpoint = clock.Add(-time.Hour)
+	sync()
+	if r.active {
+		t.Fatal("WAL still active after checkpoint")
+	}
+	sqlite(t, db, "INSERT INTO t VALUES(3);")
+	sync()
+
+	gens, err := Generations(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gens) != 1 || gens[0].Segments < 3 {
+		t.Fatalf("generations = %+v", gens)
+	}
+
+	restore := func(at time.Time) string {
+		t.Helper()
+		dst := filepath.Join(t.TempDir(), "restored.db")
+		if _, err := Restore(ctx, store, at, dst); err != nil {
+			t.Fatal(err)
+		}
+		return sqlite(t, dst, "PRAGMA integrity_check; SELECT group_concat(v) FROM t;")
+	}
+	if got := restore(time.Time{}); got != "ok\n1,2,3" {
+		t.Errorf("latest restore = %q", got)
+	}
+	if got := restore(afterFirst); got != "ok\n1" {
+		t.Errorf("restore at %s = %q", afterFirst, got)
+	}
+	if _, err := Restore(ctx, store, clock.AddDate(0, 0, -1), filepath.Join(dir, "x.db")); err == nil {
+		t.Error("expected error restoring before the first generation")
+	}
+
+	// A generation expires once the next one started before the retention
+	// window.
+	r.cfg.Retain = time.Hour
+	clock = clock.Add(48 * time.Hour)
+	sync()
+	second := clock
+	clock = clock.Add(2 * time.Hour)
+	r.gen = ""
+	sync()
+	gens, _ = Generations(ctx, store)
+	if len(gens) != 2 || gens[0].Start != second {
+		t.Errorf("generations after retention = %+v", gens)
+	}
+	if got := restore(time.Time{}); got != "ok\n1,2,3" {
+		t.Errorf("restore from new generation = %q", got)
+	}
+}
+
+func TestOpenReaderRequiresWAL(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	db := filepath.Join(t.TempDir(), "rollback.db")
+	sqlite(t, db, "CREATE TABLE t(v INTEGER);")
+	if _, err := openReader(db); err == nil || !strings.Contains(err.Error(), "WAL") {
+		t.Errorf("openReader = %v, want WAL mode error", err)
+	}
+}
@@ -3,11 +3,15 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	tlog "tetora/internal/log"
+	"tetora/internal/trace"
 )
 
 // Config defines a single outgoing webhook endpoint.
@@ -36,6 +40,12 @@ type Payload struct {
 // Non-blocking: each delivery runs in a goroutine with a 5s timeout.
 // Failures are logged but never returned to the caller.
 func Send(webhooks []Config, event string, payload Payload) {
+	SendCtx(context.Background(), webhooks, event, payload)
+}
+
+// SendCtx is Send with each delivery recorded on the timeline of the
+// trace in ctx.
+func SendCtx(ctx context.Context, webhooks []Config, event string, payload Payload) {
 	if len(webhooks) == 0 {
 		return
 	}
@@ -58,9 +68,11 @@ func Send(webhooks []Config, event string, payload Payload) {
 		}
 
 		go func(wh Config, body []byte) {
+			end := trace.Start(ctx, trace.KindWebhook, event+" "+redactURL(wh.URL))
 			req, err := http.NewRequest("POST", wh.URL, bytes.NewReader(body))
 			if err != nil {
 				tlog.Error("webhook request creation failed", "url", wh.URL, "error", err)
+				end("error", err.Error())
 				return
 			}
 			req.Header.Set("Content-Type", "application/json")
@@ -71,6 +83,7 @@ func Send(webhooks []Config, event string, payload Payload) {
 			resp, err := client.Do(req)
 			if err != nil {
 				tlog.Error("webhook POST failed", "url", wh.URL, "error", err)
+				end("error", err.Error())
 				return
 			}
 			resp.Body.Close()
@@ -78,10 +91,19 @@ func Send(webhooks []Config, event string, payload Payload) {
 			if resp.StatusCode >= 400 {
 				tlog.Warn("webhook POST returned error status", "url", wh.URL, "status", resp.StatusCode)
 			}
+			end(strconv.Itoa(resp.StatusCode), "job "+payload.JobID)
 		}(wh, body)
 	}
 }
 
+// redactURL drops the query string, which often carries tokens.
+func redactURL(raw string) string {
+	if i := strings.IndexByte(raw, '?'); i >= 0 {
+		return raw[:i]
+	}
+	return raw
+}
+
 // MatchesEvent reports whether the webhook should fire for the given event.
 func MatchesEvent(wh Config, event string) bool {
 	if len(wh.Events) == 0 {
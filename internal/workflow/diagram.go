@@ -0,0 +1,302 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	dtypes "tetora/internal/dispatch"
+)
+
+// Diagram formats accepted by RunDiagram.
+const (
+	DiagramMermaid = "mermaid"
+	DiagramDot     = "dot"
+)
+
+// diagramNode is one step drawn in a run diagram.
+type diagramNode struct {
+	id     string // node ID in the output, safe for both formats
+	stepID string
+	lines  []string
+	status string
+}
+
+// diagramEdge connects two steps. Dashed edges are handoffs.
+type diagramEdge struct {
+	from, to string // step IDs
+	label    string
+	dashed   bool
+}
+
+// statusColors maps a step status to fill and stroke colors.
+var statusColors = map[string][2]string{
+	"success":       {"#d1fae5", "#059669"},
+	"error":         {"#fee2e2", "#dc2626"},
+	"timeout":       {"#fee2e2", "#dc2626"},
+	"running":       {"#dbeafe", "#2563eb"},
+	"waiting":       {"#fef3c7", "#d97706"},
+	"waiting_human": {"#fef3c7", "#d97706"},
+	"cancelled":     {"#ffedd5", "#ea580c"},
+	"skipped":       {"#f3f4f6", "#9ca3af"},
+	"pending":       {"#ffffff", "#9ca3af"},
+}
+
+// RunDiagram draws a workflow run as a Mermaid flowchart or a Graphviz
+// digraph: one node per step with its agent, status, duration and cost,
+// dependency and branch edges from the workflow definition, and dashed
+// edges for handoffs. wf may be nil when the workflow has since been
+// deleted; steps are then taken from the run alone.
+func RunDiagram(format string, wf *Workflow, run *WorkflowRun, handoffs []dtypes.Handoff) (string, error) {
+	if format != DiagramMermaid && format != DiagramDot {
+		return "", fmt.Errorf("unknown diagram format %q (use mermaid or dot)", format)
+	}
+	nodes, edges := diagramGraph(wf, run, handoffs)
+	title := diagramTitle(run)
+	if format == DiagramDot {
+		return renderDot(title, nodes, edges), nil
+	}
+	return renderMermaid(title, nodes, edges), nil
+}
+
+func diagramGraph(wf *Workflow, run *WorkflowRun, handoffs []dtypes.Handoff) ([]*diagramNode, []diagramEdge) {
+	var nodes []*diagramNode
+	byStep := make(map[string]*diagramNode)
+	add := func(stepID string, s *WorkflowStep) {
+		if byStep[stepID] != nil {
+			return
+		}
+		n := &diagramNode{id: fmt.Sprintf("s%d", len(nodes)), stepID: stepID, status: "pending"}
+		n.lines = append(n.lines, stepID)
+		if s != nil {
+			switch t := StepType(s); {
+			case t == "dispatch" && s.Agent != "":
+				n.lines = append(n.lines, "agent: "+s.Agent)
+			case t == "skill" && s.Skill != "":
+				n.lines = append(n.lines, "skill: "+s.Skill)
+			case t == "tool_call" && s.ToolName != "":
+				n.lines = append(n.lines, "tool: "+s.ToolName)
+			case t == "review":
+				n.lines = append(n.lines, "review: "+s.Agent+" ⇄ "+s.Reviewer)
+			case t != "dispatch":
+				n.lines = append(n.lines, t)
+			}
+		}
+		if r := run.StepResults[stepID]; r != nil {
+			n.status = r.Status
+			n.lines = append(n.lines, stepSummary(r))
+		} else {
+			n.lines = append(n.lines, "pending")
+		}
+		nodes = append(nodes, n)
+		byStep[stepID] = n
+	}
+
+	var edges []diagramEdge
+	edgeAt := make(map[[2]string]int)
+	addEdge := func(e diagramEdge) {
+		if byStep[e.from] == nil || byStep[e.to] == nil {
+			return
+		}
+		key := [2]string{e.from, e.to}
+		if i, ok := edgeAt[key]; ok {
+			// A recorded handoff carries more detail than the definition.
+			if e.dashed {
+				edges[i] = e
+			}
+			return
+		}
+		edgeAt[key] = len(edges)
+		edges = append(edges, e)
+	}
+
+	if wf != nil {
+		var walk func(steps []WorkflowStep)
+		walk = func(steps []WorkflowStep) {
+			for i := range steps {
+				add(steps[i].ID, &steps[i])
+				walk(steps[i].Parallel)
+			}
+		}
+		walk(wf.Steps)
+	}
+	// Steps the run recorded but the current definition no longer has.
+	var extra []*StepRunResult
+	for id, r := range run.StepResults {
+		if byStep[id] == nil && r != nil {
+			extra = append(extra, r)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		a, b := extra[i].StartedAt, extra[j].StartedAt
+		if a != b {
+			// Steps that never started go last.
+			return b == "" || (a != "" && a < b)
+		}
+		return extra[i].StepID < extra[j].StepID
+	})
+	for _, r := range extra {
+		add(r.StepID, nil)
+	}
+
+	if wf != nil {
+		var walk func(steps []WorkflowStep)
+		walk = func(steps []WorkflowStep) {
+			for _, s := range steps {
+				for _, dep := range s.DependsOn {
+					addEdge(diagramEdge{from: dep, to: s.ID})
+				}
+				for _, sub := range s.Parallel {
+					addEdge(diagramEdge{from: s.ID, to: sub.ID, label: "parallel"})
+				}
+				if s.Then != "" {
+					addEdge(diagramEdge{from: s.ID, to: s.Then, label: "then"})
+				}
+				if s.Else != "" {
+					addEdge(diagramEdge{from: s.ID, to: s.Else, label: "else"})
+				}
+				if s.HandoffFrom != "" {
+					addEdge(diagramEdge{from: s.HandoffFrom, to: s.ID, label: "handoff", dashed: true})
+				}
+				walk(s.Parallel)
+			}
+		}
+		walk(wf.Steps)
+	}
+	for _, h := range handoffs {
+		if h.FromStepID == h.ToStepID {
+			continue // review rounds, summarised on the node
+		}
+		label := "handoff"
+		if h.FromAgent != "" || h.ToAgent != "" {
+			label += "\n" + h.FromAgent + " → " + h.ToAgent
+		}
+		addEdge(diagramEdge{from: h.FromStepID, to: h.ToStepID, label: label, dashed: true})
+	}
+	return nodes, edges
+}
+
+// stepSummary is the status line of a step: status, duration, cost, retries.
+func stepSummary(r *StepRunResult) string {
+	parts := []string{r.Status}
+	if r.DurationMs > 0 {
+		parts = append(parts, formatDiagramDuration(r.DurationMs))
+	}
+	if r.CostUSD > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f", r.CostUSD))
+	}
+	if r.Retries > 0 {
+		parts = append(parts, fmt.Sprintf("%d retries", r.Retries))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func diagramTitle(run *WorkflowRun) string {
+	id := run.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	parts := []string{run.WorkflowName, "run " + id, run.Status}
+	if run.DurationMs > 0 {
+		parts = append(parts, formatDiagramDuration(run.DurationMs))
+	}
+	if run.TotalCost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f", run.TotalCost))
+	}
+	if run.StartedAt != "" {
+		parts = append(parts, run.StartedAt)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func formatDiagramDuration(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
+
+func renderMermaid(title string, nodes []*diagramNode, edges []diagramEdge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\nflowchart TD\n", mermaidText(title))
+	ids := make(map[string]string, len(nodes))
+	classes := make(map[string][]string)
+	for _, n := range nodes {
+		ids[n.stepID] = n.id
+		lines := make([]string, len(n.lines))
+		for i, l := range n.lines {
+			lines[i] = mermaidText(l)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, strings.Join(lines, "<br/>"))
+		if _, ok := statusColors[n.status]; ok {
+			classes[n.status] = append(classes[n.status], n.id)
+		}
+	}
+	for _, e := range edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label == "" {
+			fmt.Fprintf(&b, "  %s %s %s\n", ids[e.from], arrow, ids[e.to])
+			continue
+		}
+		label := mermaidText(strings.ReplaceAll(e.label, "\n", " "))
+		fmt.Fprintf(&b, "  %s %s|\"%s\"| %s\n", ids[e.from], arrow, label, ids[e.to])
+	}
+	statuses := make([]string, 0, len(classes))
+	for s := range classes {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	for _, s := range statuses {
+		c := statusColors[s]
+		fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:%s\n", s, c[0], c[1])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[s], ","), s)
+	}
+	return b.String()
+}
+
+// mermaidText escapes text for a quoted Mermaid label.
+var mermaidText = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", " ").Replace
+
+func renderDot(title string, nodes []*diagramNode, edges []diagramEdge) string {
+	var b strings.Builder
+	b.WriteString("digraph workflow_run {\n")
+	fmt.Fprintf(&b, "  label=%s;\n  labelloc=t;\n  rankdir=TB;\n", dotString(title))
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	ids := make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		ids[n.stepID] = n.id
+		c, ok := statusColors[n.status]
+		if !ok {
+			c = statusColors["pending"]
+		}
+		fmt.Fprintf(&b, "  %s [label=%s, fillcolor=%q, color=%q];\n",
+			n.id, dotString(strings.Join(n.lines, "\n")), c[0], c[1])
+	}
+	for _, e := range edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotString(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(&b, "  %s -> %s;\n", ids[e.from], ids[e.to])
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", ids[e.from], ids[e.to], strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotString quotes s as a DOT string, keeping line breaks.
+func dotString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	dtypes "tetora/internal/dispatch"
+)
+
+func diagramFixture() (*Workflow, *WorkflowRun, []dtypes.Handoff) {
+	wf := &Workflow{
+		Name: "release",
+		Steps: []WorkflowStep{
+			{ID: "plan", Agent: "ruri"},
+			{ID: "check", Type: "condition", If: "{{steps.plan.output}}", Then: "build", Else: "stop", DependsOn: []string{"plan"}},
+			{ID: "build", Agent: "kokuyou", HandoffFrom: "plan"},
+			{ID: "stop", Type: "notify"},
+			{ID: "review", Agent: "hisui", DependsOn: []string{"build"}},
+		},
+	}
+	run := &WorkflowRun{
+		ID:           "0123456789abcdef",
+		WorkflowName: "release",
+		Status:       "error",
+		DurationMs:   95000,
+		TotalCost:    0.12,
+		StepResults: map[string]*StepRunResult{
+			"plan":   {StepID: "plan", Status: "success", DurationMs: 12000, CostUSD: 0.02},
+			"check":  {StepID: "check", Status: "success", DurationMs: 5},
+			"build":  {StepID: "build", Status: "error", DurationMs: 83000, CostUSD: 0.1, Retries: 2},
+			"stop":   {StepID: "stop", Status: "skipped"},
+			"legacy": {StepID: "legacy", Status: "success", StartedAt: "2026-01-01T00:00:00Z"},
+		},
+	}
+	handoffs := []dtypes.Handoff{{FromAgent: "ruri", ToAgent: "kokuyou", FromStepID: "plan", ToStepID: "build"}}
+	return wf, run, handoffs
+}
+
+func TestRunDiagramMermaid(t *testing.T) {
+	wf, run, handoffs := diagramFixture()
+	out, err := RunDiagram(DiagramMermaid, wf, run, handoffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"title: release · run 01234567 · error · 1m35s · $0.1200",
+		"flowchart TD",
+		`s0["plan<br/>agent: ruri<br/>success · 12s · $0.0200"]`,
+		`s1["check<br/>condition<br/>success · 5ms"]`,
+		`s2["build<br/>agent: kokuyou<br/>error · 1m23s · $0.1000 · 2 retries"]`,
+		`s4["review<br/>agent: hisui<br/>pending"]`,
+		`s5["legacy<br/>success"]`,
+		"s0 --> s1",
+		`s1 -->|"then"| s2`,
+		`s1 -->|"else"| s3`,
+		`s0 -.->|"handoff ruri → kokuyou"| s2`,
+		"s2 --> s4",
+		"classDef error fill:#fee2e2,stroke:#dc2626",
+		"class s0,s1,s5 success",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("mermaid output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "s0 -") != 2 {
+		t.Errorf("handoff edge drawn twice:\n%s", out)
+	}
+}
+
+func TestRunDiagramDot(t *testing.T) {
+	wf, run, handoffs := diagramFixture()
+	out, err := RunDiagram(DiagramDot, wf, run, handoffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"digraph workflow_run {",
+		`s2 [label="build\nagent: kokuyou\nerror · 1m23s · $0.1000 · 2 retries", fillcolor="#fee2e2", color="#dc2626"];`,
+		`s0 -> s2 [label="handoff\nruri → kokuyou", style=dashed];`,
+		`s1 -> s3 [label="else"];`,
+		"s2 -> s4;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dot output missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "}\n") {
+		t.Errorf("dot output not closed:\n%s", out)
+	}
+}
+
+func TestRunDiagramWithoutWorkflow(t *testing.T) {
+	_, run, _ := diagramFixture()
+	out, err := RunDiagram(DiagramMermaid, nil, run, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Steps come from the run alone, started ones first, then by ID.
+	if !strings.Contains(out, `s0["legacy<br/>success"]`) || !strings.Contains(out, `s1["build<br/>error`) {
+		t.Errorf("run-only diagram:\n%s", out)
+	}
+	if _, err := RunDiagram("svg", nil, run, nil); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -6,7 +6,7 @@
 //   - HTTP helpers: httpPostWithRetry with exponential backoff
 //   - JSON helpers: extractJSONPath, applyResponseMapping
 //   - HMAC helpers: callbackSignatureSecret, verifyCallbackSignature
-//   - WorkflowTriggerEngine: cron/event/webhook trigger dispatch
+//   - WorkflowTriggerEngine: cron/event/webhook/file trigger dispatch
 //
 // Root-only concerns (kept in root shim workflow_events.go):
 //   - callbackMgr singleton, runCancellers
@@ -1280,9 +1280,13 @@ type TriggerDeps struct {
 
 	// LoadWorkflowByName loads a workflow by name. If nil, uses the package-level LoadWorkflowByName.
 	LoadWorkflowByName func(cfg *config.Config, name string) (*Workflow, error)
+
+	// Halted reports whether the emergency stop is engaged. While it is,
+	// no trigger fires. May be nil.
+	Halted func() bool
 }
 
-// WorkflowTriggerEngine manages workflow triggers: cron-based, event-based, and webhook-based.
+// WorkflowTriggerEngine manages workflow triggers: cron-based, event-based, webhook-based and file-based.
 type WorkflowTriggerEngine struct {
 	cfg       *config.Config
 	deps      TriggerDeps
@@ -1290,6 +1294,7 @@ type WorkflowTriggerEngine struct {
 	triggers  []config.WorkflowTriggerConfig
 	cooldowns map[string]time.Time // trigger name -> cooldown expiry
 	lastFired map[string]time.Time // trigger name -> last fire time
+	files     map[string]*fileWatch // trigger name -> watched files, for file triggers
 	mu        sync.RWMutex
 	parentCtx context.Context    // parent context from Start(), preserved for ReloadTriggers
 	ctx       context.Context    // engine-scoped context, cancelled on Stop
@@ -1329,6 +1334,7 @@ func (e *WorkflowTriggerEngine) Start(ctx context.Context) {
 
 	hasCron := false
 	hasEvent := false
+	hasFile := false
 	for _, t := range e.triggers {
 		if t.Trigger.Type == "cron" {
 			hasCron = true
@@ -1336,6 +1342,9 @@ func (e *WorkflowTriggerEngine) Start(ctx context.Context) {
 		if t.Trigger.Type == "event" {
 			hasEvent = true
 		}
+		if t.Trigger.Type == "file" {
+			hasFile = true
+		}
 	}
 
 	if hasCron {
@@ -1354,6 +1363,19 @@ func (e *WorkflowTriggerEngine) Start(ctx context.Context) {
 		}()
 	}
 
+	if hasFile {
+		// Watched files are recorded afresh, so files that changed while
+		// the triggers were being reloaded do not fire.
+		e.mu.Lock()
+		e.files = make(map[string]*fileWatch)
+		e.mu.Unlock()
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.fileLoop(e.ctx)
+		}()
+	}
+
 	// Init trigger runs table.
 	InitTriggerRunsTable(e.cfg.HistoryDB)
 
@@ -1363,7 +1385,7 @@ func (e *WorkflowTriggerEngine) Start(ctx context.Context) {
 			enabled++
 		}
 	}
-	log.Info("workflow trigger engine started", "total", len(e.triggers), "enabled", enabled, "cron", hasCron, "event", hasEvent)
+	log.Info("workflow trigger engine started", "total", len(e.triggers), "enabled", enabled, "cron", hasCron, "event", hasEvent, "file", hasFile)
 }
 
 // Stop gracefully shuts down the trigger engine.
@@ -1561,6 +1583,9 @@ func MatchEventType(eventType, pattern string) bool {
 
 // HandleWebhookTrigger fires a webhook trigger by name with the given payload.
 func (e *WorkflowTriggerEngine) HandleWebhookTrigger(triggerName string, payload map[string]string) error {
+	if e.halted() {
+		return fmt.Errorf("emergency stop engaged")
+	}
 	e.mu.Lock()
 	var found *config.WorkflowTriggerConfig
 	for i := range e.triggers {
@@ -1606,6 +1631,10 @@ func (e *WorkflowTriggerEngine) HandleWebhookTrigger(triggerName string, payload
 
 // executeTrigger loads the workflow, merges variables, and executes it via deps.ExecuteWorkflow.
 func (e *WorkflowTriggerEngine) executeTrigger(ctx context.Context, trigger config.WorkflowTriggerConfig, extraVars map[string]string) {
+	if e.halted() {
+		log.Warn("workflow trigger skipped: emergency stop engaged", "trigger", trigger.Name)
+		return
+	}
 	startedAt := time.Now()
 
 	// Update last fired and cooldown.
@@ -1683,6 +1712,10 @@ func (e *WorkflowTriggerEngine) executeTrigger(ctx context.Context, trigger conf
 	}
 }
 
+func (e *WorkflowTriggerEngine) halted() bool {
+	return e.deps.Halted != nil && e.deps.Halted()
+}
+
 // checkCooldown returns true if the trigger is past its cooldown period.
 func (e *WorkflowTriggerEngine) checkCooldown(triggerName string) bool {
 	e.mu.RLock()
@@ -1763,6 +1796,9 @@ func (e *WorkflowTriggerEngine) FireTrigger(name string) error {
 	if !found.IsEnabled() {
 		return fmt.Errorf("trigger %q is disabled", name)
 	}
+	if e.halted() {
+		return fmt.Errorf("emergency stop engaged")
+	}
 
 	log.Info("workflow trigger manual fire", "trigger", name, "workflow", found.WorkflowName)
 	go e.executeTrigger(e.ctx, *found, map[string]string{
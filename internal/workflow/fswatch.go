@@ -0,0 +1,290 @@
+package workflow
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/log"
+)
+
+// File trigger events.
+const (
+	FileCreate = "create"
+	FileModify = "modify"
+	FileDelete = "delete"
+)
+
+// fileTriggerInterval is how often file triggers poll their paths. Paths are
+// polled rather than watched through OS notifications so triggers behave
+// the same on every platform and on network drives.
+const fileTriggerInterval = time.Second
+
+// FileEvent is a settled change to a file matched by a file trigger.
+type FileEvent struct {
+	Path string // absolute
+	Kind string // FileCreate, FileModify or FileDelete
+	Size int64
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+type pendingFile struct {
+	kind  string
+	stamp fileStamp
+	since time.Time // last time the file was seen changing
+}
+
+// fileWatch is the state of one file trigger between polls.
+type fileWatch struct {
+	primed  bool
+	files   map[string]fileStamp
+	pending map[string]*pendingFile
+}
+
+func newFileWatch() *fileWatch {
+	return &fileWatch{files: map[string]fileStamp{}, pending: map[string]*pendingFile{}}
+}
+
+// poll scans the trigger's paths and returns the changes that have been
+// quiet for the debounce window. The first poll only records what exists,
+// so files already present when the daemon starts do not fire.
+func (w *fileWatch) poll(spec config.TriggerSpec, now time.Time) []FileEvent {
+	files := scanGlobs(spec.Paths)
+	if !w.primed {
+		w.primed = true
+		w.files = files
+		return nil
+	}
+	for p, st := range files {
+		old, had := w.files[p]
+		pend := w.pending[p]
+		switch {
+		case pend != nil && pend.kind == FileDelete:
+			// Deleted and back again: replaced.
+			w.pending[p] = &pendingFile{kind: FileModify, stamp: st, since: now}
+		case pend != nil:
+			if pend.stamp != st {
+				pend.stamp = st
+				pend.since = now
+			}
+		case !had:
+			w.pending[p] = &pendingFile{kind: FileCreate, stamp: st, since: now}
+		case old != st:
+			w.pending[p] = &pendingFile{kind: FileModify, stamp: st, since: now}
+		}
+	}
+	for p := range w.files {
+		if _, ok := files[p]; ok {
+			continue
+		}
+		switch pend := w.pending[p]; {
+		case pend == nil:
+			w.pending[p] = &pendingFile{kind: FileDelete, since: now}
+		case pend.kind == FileCreate:
+			delete(w.pending, p) // came and went within the window
+		case pend.kind != FileDelete:
+			w.pending[p] = &pendingFile{kind: FileDelete, since: now}
+		}
+	}
+	w.files = files
+
+	debounce := spec.DebounceOrDefault()
+	var out []FileEvent
+	for p, pend := range w.pending {
+		if now.Sub(pend.since) < debounce {
+			continue
+		}
+		delete(w.pending, p)
+		if wantsFileEvent(spec.Events, pend.kind) {
+			out = append(out, FileEvent{Path: p, Kind: pend.kind, Size: pend.stamp.size})
+		}
+	}
+	return out
+}
+
+// wantsFileEvent reports whether a trigger listening for events fires on
+// kind. No events means create and modify.
+func wantsFileEvent(events []string, kind string) bool {
+	if len(events) == 0 {
+		return kind == FileCreate || kind == FileModify
+	}
+	for _, e := range events {
+		if strings.EqualFold(e, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanGlobs lists the files matching any of the globs. Globs use
+// filepath.Match syntax per path element, plus "**" for any number of
+// directories, and may start with "~/". Hidden files and directories are
+// skipped unless the glob names them.
+func scanGlobs(globs []string) map[string]fileStamp {
+	files := map[string]fileStamp{}
+	for _, g := range globs {
+		g = filepath.ToSlash(filepath.Clean(expandHome(g)))
+		root, rest := globRoot(g)
+		if rest == "" {
+			// A plain path.
+			if info, err := os.Stat(root); err == nil && !info.IsDir() {
+				files[filepath.FromSlash(root)] = fileStamp{info.Size(), info.ModTime()}
+			}
+			continue
+		}
+		depth := strings.Count(rest, "/") + 1
+		deep := strings.Contains(rest, "**")
+		base := filepath.FromSlash(root)
+		filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || p == base {
+				return nil
+			}
+			rel, _ := filepath.Rel(base, p)
+			rel = filepath.ToSlash(rel)
+			if strings.HasPrefix(d.Name(), ".") && !strings.Contains(rest, "/.") && !strings.HasPrefix(rest, ".") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if !deep && strings.Count(rel, "/")+1 >= depth {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !matchGlob(rest, rel) {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files[p] = fileStamp{info.Size(), info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// globRoot splits a slash-separated glob into the directory before its
+// first wildcard and the pattern below it.
+func globRoot(g string) (root, rest string) {
+	parts := strings.Split(g, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "*?[") {
+			root = strings.Join(parts[:i], "/")
+			if root == "" {
+				root = "/"
+			}
+			return root, strings.Join(parts[i:], "/")
+		}
+	}
+	return g, ""
+}
+
+// matchGlob matches a slash-separated path against a glob where "**"
+// stands for zero or more directories.
+func matchGlob(pattern, name string) bool {
+	pp := strings.Split(pattern, "/")
+	np := strings.Split(name, "/")
+	var match func(pi, ni int) bool
+	match = func(pi, ni int) bool {
+		for pi < len(pp) {
+			if pp[pi] == "**" {
+				for k := ni; k <= len(np); k++ {
+					if match(pi+1, k) {
+						return true
+					}
+				}
+				return false
+			}
+			if ni >= len(np) {
+				return false
+			}
+			if ok, _ := path.Match(pp[pi], np[ni]); !ok {
+				return false
+			}
+			pi++
+			ni++
+		}
+		return ni == len(np)
+	}
+	return match(0, 0)
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
+
+// fileLoop polls file triggers until the engine stops.
+func (e *WorkflowTriggerEngine) fileLoop(ctx context.Context) {
+	ticker := time.NewTicker(fileTriggerInterval)
+	defer ticker.Stop()
+	e.checkFileTriggers(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopCh:
+			return
+		case now := <-ticker.C:
+			e.checkFileTriggers(ctx, now)
+		}
+	}
+}
+
+func (e *WorkflowTriggerEngine) checkFileTriggers(ctx context.Context, now time.Time) {
+	e.mu.RLock()
+	triggers := e.triggers
+	e.mu.RUnlock()
+
+	for _, t := range triggers {
+		if !t.IsEnabled() || t.Trigger.Type != "file" || len(t.Trigger.Paths) == 0 {
+			continue
+		}
+		e.mu.Lock()
+		w := e.files[t.Name]
+		if w == nil {
+			w = newFileWatch()
+			e.files[t.Name] = w
+		}
+		e.mu.Unlock()
+
+		for _, ev := range w.poll(t.Trigger, now) {
+			if !e.checkCooldown(t.Name) {
+				log.Debug("workflow trigger cooldown active", "trigger", t.Name, "file", ev.Path)
+				continue
+			}
+			log.Info("workflow trigger file firing", "trigger", t.Name, "workflow", t.WorkflowName, "file", ev.Path, "event", ev.Kind)
+			go e.executeTrigger(ctx, t, fileVars(ev))
+		}
+	}
+}
+
+// fileVars are the variables a file trigger passes to its workflow.
+func fileVars(ev FileEvent) map[string]string {
+	vars := map[string]string{
+		"file_path":  ev.Path,
+		"file_name":  filepath.Base(ev.Path),
+		"file_dir":   filepath.Dir(ev.Path),
+		"file_event": ev.Kind,
+	}
+	if ev.Kind != FileDelete {
+		vars["file_size"] = strconv.FormatInt(ev.Size, 10)
+	}
+	return vars
+}
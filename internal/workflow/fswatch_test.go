@@ -0,0 +1,136 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.pdf", "a.pdf", true},
+		{"*.pdf", "sub/a.pdf", false},
+		{"**/*.pdf", "a.pdf", true},
+		{"**/*.pdf", "x/y/a.pdf", true},
+		{"in/**/done/*.csv", "in/done/a.csv", true},
+		{"in/**/done/*.csv", "in/2026/done/a.csv", true},
+		{"in/**/done/*.csv", "in/2026/a.csv", false},
+		{"[ab]?.txt", "b1.txt", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v", c.pattern, c.name, got)
+		}
+	}
+}
+
+func TestFileWatchPoll(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string, mod time.Time) {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(p, mod, mod)
+	}
+	t0 := time.Now()
+	write("old.pdf", "x", t0.Add(-time.Hour))
+	spec := config.TriggerSpec{
+		Type:     "file",
+		Paths:    []string{filepath.Join(dir, "*.pdf"), filepath.Join(dir, "deep", "**", "*.csv")},
+		Events:   []string{FileCreate, FileModify, FileDelete},
+		Debounce: "5s",
+	}
+	w := newFileWatch()
+	if ev := w.poll(spec, t0); ev != nil {
+		t.Fatalf("first poll should only record files, got %v", ev)
+	}
+
+	write("new.pdf", "a", t0)
+	write("deep/x/y/rows.csv", "1", t0)
+	write("note.txt", "ignored", t0)
+	write(".hidden.pdf", "ignored", t0)
+	if ev := w.poll(spec, t0.Add(time.Second)); len(ev) != 0 {
+		t.Fatalf("changes should wait for the debounce window: %v", ev)
+	}
+	// Still being written: the window starts over.
+	write("new.pdf", "ab", t0.Add(2*time.Second))
+	if ev := w.poll(spec, t0.Add(3*time.Second)); len(ev) != 0 {
+		t.Fatalf("unexpected %v", ev)
+	}
+	ev := w.poll(spec, t0.Add(7*time.Second))
+	if len(ev) != 1 || ev[0].Kind != FileCreate || filepath.Base(ev[0].Path) != "rows.csv" {
+		t.Fatalf("after the window = %v", ev)
+	}
+	ev = w.poll(spec, t0.Add(8*time.Second))
+	if len(ev) != 1 || ev[0].Kind != FileCreate || filepath.Base(ev[0].Path) != "new.pdf" || ev[0].Size != 2 {
+		t.Fatalf("debounced create = %v", ev)
+	}
+
+	write("old.pdf", "changed", t0.Add(9*time.Second))
+	os.Remove(filepath.Join(dir, "new.pdf"))
+	w.poll(spec, t0.Add(10*time.Second))
+	ev = w.poll(spec, t0.Add(20*time.Second))
+	kinds := map[string]string{}
+	for _, e := range ev {
+		kinds[filepath.Base(e.Path)] = e.Kind
+	}
+	if len(ev) != 2 || kinds["old.pdf"] != FileModify || kinds["new.pdf"] != FileDelete {
+		t.Fatalf("modify and delete = %v", ev)
+	}
+
+	// Created and gone again inside the window: nothing fires.
+	write("blip.pdf", "x", t0.Add(21*time.Second))
+	w.poll(spec, t0.Add(21*time.Second))
+	os.Remove(filepath.Join(dir, "blip.pdf"))
+	w.poll(spec, t0.Add(22*time.Second))
+	if ev := w.poll(spec, t0.Add(40*time.Second)); len(ev) != 0 {
+		t.Fatalf("blip = %v", ev)
+	}
+
+	// Only the listed events fire.
+	spec.Events = nil
+	write("gone.pdf", "x", t0.Add(41*time.Second))
+	w.poll(spec, t0.Add(41*time.Second))
+	w.poll(spec, t0.Add(50*time.Second))
+	os.Remove(filepath.Join(dir, "gone.pdf"))
+	w.poll(spec, t0.Add(51*time.Second))
+	if ev := w.poll(spec, t0.Add(60*time.Second)); len(ev) != 0 {
+		t.Fatalf("delete fired without being listed: %v", ev)
+	}
+}
+
+func TestFileVars(t *testing.T) {
+	p := filepath.Join("data", "in", "report.pdf")
+	v := fileVars(FileEvent{Path: p, Kind: FileCreate, Size: 42})
+	if v["file_path"] != p || v["file_name"] != "report.pdf" || v["file_dir"] != filepath.Join("data", "in") ||
+		v["file_event"] != FileCreate || v["file_size"] != "42" {
+		t.Errorf("vars = %v", v)
+	}
+	if _, ok := fileVars(FileEvent{Path: p, Kind: FileDelete})["file_size"]; ok {
+		t.Error("deleted files have no size")
+	}
+}
+
+func TestValidateFileTrigger(t *testing.T) {
+	tr := config.WorkflowTriggerConfig{Name: "drop", WorkflowName: "ingest",
+		Trigger: config.TriggerSpec{Type: "file", Paths: []string{"~/Drop/*.pdf"}, Events: []string{"create"}, Debounce: "3s"}}
+	if errs := ValidateTriggerConfig(tr, nil); len(errs) != 0 {
+		t.Errorf("valid trigger: %v", errs)
+	}
+	tr.Trigger = config.TriggerSpec{Type: "file", Paths: []string{"/in/[x"}, Events: []string{"rename"}, Debounce: "soon"}
+	if errs := ValidateTriggerConfig(tr, nil); len(errs) != 3 {
+		t.Errorf("errors = %v", errs)
+	}
+	tr.Trigger = config.TriggerSpec{Type: "file"}
+	if errs := ValidateTriggerConfig(tr, nil); len(errs) != 1 {
+		t.Errorf("errors = %v", errs)
+	}
+}
@@ -0,0 +1,88 @@
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// --- Review Steps ---
+
+// A review step has its agent draft an answer to the prompt and a reviewer
+// agent critique it. The author revises until the reviewer approves or the
+// rounds run out. Each exchange is recorded as a handoff.
+
+const (
+	DefaultReviewRounds = 3
+	MaxReviewRounds     = 10
+)
+
+// ReviewRounds returns the number of review rounds for a step.
+func ReviewRounds(s *WorkflowStep) int {
+	if s.MaxRounds <= 0 {
+		return DefaultReviewRounds
+	}
+	return min(s.MaxRounds, MaxReviewRounds)
+}
+
+// ReviewerPrompt asks the reviewer to judge a draft. The first line of the
+// reply carries the verdict so ParseReviewVerdict can read it.
+func ReviewerPrompt(task, draft, criteria string, round, rounds int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are reviewing a draft (round %d of %d).\n\n", round, rounds)
+	b.WriteString("[Task]\n" + task + "\n\n")
+	if criteria != "" {
+		b.WriteString("[Review Criteria]\n" + criteria + "\n\n")
+	}
+	b.WriteString("[Draft]\n" + draft + "\n\n")
+	b.WriteString("Start your reply with a single line: APPROVED if the draft is ready as it is, " +
+		"or CHANGES REQUESTED otherwise. Then give specific, actionable feedback.")
+	return b.String()
+}
+
+// RevisionPrompt asks the author to revise a draft after a critique.
+func RevisionPrompt(task, draft, critique string, round int) string {
+	return fmt.Sprintf("[Task]\n%s\n\n[Your Previous Draft]\n%s\n\n[Reviewer Feedback — round %d]\n%s\n\n"+
+		"Revise the draft to address the feedback. Reply with the complete revised draft only.",
+		task, draft, round, critique)
+}
+
+// ParseReviewVerdict reads a reviewer's reply. The draft is approved when the
+// first non-empty line says APPROVED (or LGTM); the critique is the rest.
+func ParseReviewVerdict(reply string) (approved bool, critique string) {
+	reply = strings.TrimSpace(reply)
+	first, rest, _ := strings.Cut(reply, "\n")
+	verdict := strings.ToUpper(strings.Trim(first, " \t*#_`:.-"))
+	switch {
+	case strings.HasPrefix(verdict, "APPROVED"), strings.HasPrefix(verdict, "APPROVE "),
+		verdict == "APPROVE", strings.HasPrefix(verdict, "LGTM"):
+		approved = true
+	case strings.HasPrefix(verdict, "CHANGES REQUESTED"), strings.HasPrefix(verdict, "REQUEST CHANGES"),
+		strings.HasPrefix(verdict, "NOT APPROVED"), strings.HasPrefix(verdict, "REJECTED"):
+	default:
+		// No verdict line: the whole reply is feedback.
+		return false, reply
+	}
+	if critique = strings.TrimSpace(rest); critique == "" {
+		critique = strings.TrimSpace(first)
+	}
+	return approved, critique
+}
+
+// ReviewArtifactName returns the file name a review step writes its final
+// draft to: the step's artifact when set, else one derived from the run.
+func ReviewArtifactName(s *WorkflowStep, workflowName, runID string) string {
+	if s.Artifact != "" {
+		return s.Artifact
+	}
+	if len(runID) > 8 {
+		runID = runID[:8]
+	}
+	return fmt.Sprintf("%s-%s-%s.md", workflowName, s.ID, runID)
+}
+
+// ValidArtifactName reports whether name is safe to write under the outputs
+// directory: a plain file name with no path separators.
+func ValidArtifactName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name && !strings.ContainsAny(name, `/\`)
+}
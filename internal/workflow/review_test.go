@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReviewVerdict(t *testing.T) {
+	cases := []struct {
+		reply    string
+		approved bool
+		critique string
+	}{
+		{"APPROVED\nClear and complete.", true, "Clear and complete."},
+		{"**Approved.**", true, "**Approved.**"},
+		{"LGTM", true, "LGTM"},
+		{"CHANGES REQUESTED\n- tighten the intro\n- cite sources", false, "- tighten the intro\n- cite sources"},
+		{"## Changes requested:\nToo long.", false, "Too long."},
+		{"NOT APPROVED\nMissing a summary.", false, "Missing a summary."},
+		{"The draft is fine but the second section repeats itself.", false, "The draft is fine but the second section repeats itself."},
+		{"\n\n  approved  \n", true, "approved"},
+	}
+	for _, c := range cases {
+		approved, critique := ParseReviewVerdict(c.reply)
+		if approved != c.approved || critique != c.critique {
+			t.Errorf("ParseReviewVerdict(%q) = %v, %q; want %v, %q", c.reply, approved, critique, c.approved, c.critique)
+		}
+	}
+}
+
+func TestReviewRoundsAndArtifact(t *testing.T) {
+	s := &WorkflowStep{ID: "essay"}
+	if got := ReviewRounds(s); got != DefaultReviewRounds {
+		t.Errorf("default rounds = %d", got)
+	}
+	s.MaxRounds = 50
+	if got := ReviewRounds(s); got != MaxReviewRounds {
+		t.Errorf("capped rounds = %d", got)
+	}
+	if got := ReviewArtifactName(s, "blog", "0123456789abcdef"); got != "blog-essay-01234567.md" {
+		t.Errorf("artifact = %q", got)
+	}
+	s.Artifact = "final.md"
+	if got := ReviewArtifactName(s, "blog", "run"); got != "final.md" {
+		t.Errorf("artifact = %q", got)
+	}
+	for name, want := range map[string]bool{"final.md": true, "../x.md": false, "a/b.md": false, "..": false, "": false} {
+		if ValidArtifactName(name) != want {
+			t.Errorf("ValidArtifactName(%q) = %v", name, !want)
+		}
+	}
+	p := ReviewerPrompt("Write a haiku", "old pond", "5-7-5", 2, 3)
+	if !strings.Contains(p, "round 2 of 3") || !strings.Contains(p, "5-7-5") || !strings.Contains(p, "APPROVED") {
+		t.Errorf("reviewer prompt = %q", p)
+	}
+}
+
+func TestValidateReviewStep(t *testing.T) {
+	ids := map[string]bool{"r": true}
+	if errs := ValidateStep(WorkflowStep{ID: "r", Type: "review", Agent: "writer", Reviewer: "editor", Prompt: "draft"}, ids); len(errs) != 0 {
+		t.Errorf("valid review step: %v", errs)
+	}
+	errs := ValidateStep(WorkflowStep{ID: "r", Type: "review", Agent: "writer", Reviewer: "writer", MaxRounds: 11, Artifact: "../out.md"}, ids)
+	want := []string{"differ", "prompt", "maxRounds", "artifact"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v", errs)
+	}
+	for i, w := range want {
+		if !strings.Contains(errs[i], w) {
+			t.Errorf("error %d = %q, want %q", i, errs[i], w)
+		}
+	}
+}
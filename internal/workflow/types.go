@@ -24,7 +24,7 @@ type Workflow struct {
 // WorkflowStep is a single step in a workflow.
 type WorkflowStep struct {
 	ID        string   `json:"id"`
-	Type      string   `json:"type,omitempty"`      // "dispatch" (default), "skill", "condition", "parallel"
+	Type      string   `json:"type,omitempty"`      // "dispatch" (default), "skill", "condition", "parallel", "handoff", "review", ...
 	Agent     string   `json:"agent,omitempty"`     // agent role for dispatch steps
 	Prompt    string   `json:"prompt,omitempty"`    // for dispatch steps
 	Skill     string   `json:"skill,omitempty"`     // skill name for skill steps
@@ -46,6 +46,14 @@ type WorkflowStep struct {
 	// Handoff step fields.
 	HandoffFrom string `json:"handoffFrom,omitempty"` // source step ID whose output becomes context
 
+	// Review step fields (type="review"): Agent drafts from Prompt, Reviewer
+	// critiques, and the two iterate until approval or MaxRounds.
+	Reviewer        string `json:"reviewer,omitempty"`        // agent that critiques the draft
+	ReviewPrompt    string `json:"reviewPrompt,omitempty"`    // review criteria (supports {{var}})
+	MaxRounds       int    `json:"maxRounds,omitempty"`       // review rounds (default 3, max 10)
+	RequireApproval bool   `json:"requireApproval,omitempty"` // fail the step if never approved
+	Artifact        string `json:"artifact,omitempty"`        // file name under outputs/reviews/ (supports {{var}})
+
 	// Parallel step fields.
 	Parallel []WorkflowStep `json:"parallel,omitempty"` // sub-steps to run in parallel
 
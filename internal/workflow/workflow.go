@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -270,6 +271,9 @@ func checkStepTemplateRefs(s WorkflowStep, stepIDs map[string]bool, parentID str
 	checkField("externalRawBody", s.ExternalRawBody)
 	checkField("callbackKey", s.CallbackKey)
 	checkField("agent", s.Agent)
+	checkField("reviewer", s.Reviewer)
+	checkField("reviewPrompt", s.ReviewPrompt)
+	checkField("artifact", s.Artifact)
 	checkField("model", s.Model)
 
 	for i, arg := range s.SkillArgs {
@@ -348,6 +352,24 @@ func ValidateStep(s WorkflowStep, allIDs map[string]bool) []string {
 		if s.Agent == "" {
 			errs = append(errs, fmt.Sprintf("step %q: handoff step requires a target 'agent'", s.ID))
 		}
+	case "review":
+		if s.Agent == "" {
+			errs = append(errs, fmt.Sprintf("step %q: review step requires an author 'agent'", s.ID))
+		}
+		if s.Reviewer == "" {
+			errs = append(errs, fmt.Sprintf("step %q: review step requires a 'reviewer' agent", s.ID))
+		} else if s.Reviewer == s.Agent {
+			errs = append(errs, fmt.Sprintf("step %q: reviewer must differ from the author agent", s.ID))
+		}
+		if s.Prompt == "" {
+			errs = append(errs, fmt.Sprintf("step %q: review step requires a prompt", s.ID))
+		}
+		if s.MaxRounds < 0 || s.MaxRounds > MaxReviewRounds {
+			errs = append(errs, fmt.Sprintf("step %q: maxRounds must be between 1 and %d", s.ID, MaxReviewRounds))
+		}
+		if s.Artifact != "" && !strings.Contains(s.Artifact, "{{") && !ValidArtifactName(s.Artifact) {
+			errs = append(errs, fmt.Sprintf("step %q: artifact %q must be a plain file name", s.ID, s.Artifact))
+		}
 	case "parallel":
 		if len(s.Parallel) == 0 {
 			errs = append(errs, fmt.Sprintf("step %q: parallel step requires sub-steps", s.ID))
@@ -429,7 +451,7 @@ func ValidateStep(s WorkflowStep, allIDs map[string]bool) []string {
 			}
 		}
 	default:
-		errs = append(errs, fmt.Sprintf("step %q: unknown type %q (use dispatch, skill, condition, parallel, handoff, tool_call, delay, notify, external, human)", s.ID, stepType))
+		errs = append(errs, fmt.Sprintf("step %q: unknown type %q (use dispatch, skill, condition, parallel, handoff, review, tool_call, delay, notify, external, human)", s.ID, stepType))
 	}
 
 	// Validate dependency references.
@@ -770,8 +792,27 @@ func ValidateTriggerConfig(t config.WorkflowTriggerConfig, existingNames map[str
 		if t.Trigger.Webhook == "" {
 			errs = append(errs, "webhook ID required for webhook trigger")
 		}
+	case "file":
+		if len(t.Trigger.Paths) == 0 {
+			errs = append(errs, "paths required for file trigger")
+		}
+		for _, e := range t.Trigger.Events {
+			if e != FileCreate && e != FileModify && e != FileDelete {
+				errs = append(errs, fmt.Sprintf("unknown file event %q (want create, modify, delete)", e))
+			}
+		}
+		for _, g := range t.Trigger.Paths {
+			if _, err := path.Match(g, ""); err != nil {
+				errs = append(errs, fmt.Sprintf("bad path glob %q", g))
+			}
+		}
+		if t.Trigger.Debounce != "" {
+			if d, err := time.ParseDuration(t.Trigger.Debounce); err != nil || d <= 0 {
+				errs = append(errs, fmt.Sprintf("invalid debounce %q", t.Trigger.Debounce))
+			}
+		}
 	case "":
-		errs = append(errs, "trigger type is required (cron, event, webhook)")
+		errs = append(errs, "trigger type is required (cron, event, webhook, file)")
 	default:
 		errs = append(errs, fmt.Sprintf("unknown trigger type: %s", t.Trigger.Type))
 	}
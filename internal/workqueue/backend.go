@@ -0,0 +1,332 @@
+package workqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"tetora/internal/dispatch"
+)
+
+// Job statuses.
+const (
+	StatusPending = "pending"
+	StatusLeased  = "leased"
+	StatusDone    = "done"
+	StatusDead    = "dead" // lease ran out on every attempt
+)
+
+// Job is one task in the shared queue.
+type Job struct {
+	ID         string        `json:"id"`
+	Task       dispatch.Task `json:"task"`
+	Agent      string        `json:"agent,omitempty"`
+	Status     string        `json:"status"`
+	Node       string        `json:"node,omitempty"` // holding the lease, or that ran it
+	EnqueuedBy string        `json:"enqueuedBy,omitempty"`
+	Attempts   int           `json:"attempts"`
+	EnqueuedAt string        `json:"enqueuedAt"`
+	ClaimedAt  string        `json:"claimedAt,omitempty"`
+	LeaseUntil string        `json:"leaseUntil,omitempty"`
+	FinishedAt string        `json:"finishedAt,omitempty"`
+	Result     string        `json:"result,omitempty"` // the task's status: success, error, timeout, ...
+	Error      string        `json:"error,omitempty"`
+	CostUSD    float64       `json:"costUsd,omitempty"`
+	Output     string        `json:"output,omitempty"`
+}
+
+// Stats is a snapshot of the queue.
+type Stats struct {
+	Node    string            `json:"node"`    // the node answering
+	Pending int               `json:"pending"` // waiting to be claimed
+	Leased  map[string]int    `json:"leased"`  // node → tasks it holds
+	Nodes   map[string]string `json:"nodes"`   // live node → last heartbeat (RFC3339)
+}
+
+// backend stores the queue. Every method that changes a job is atomic, and
+// extend, complete and release only act while node still holds the lease.
+type backend interface {
+	ping(ctx context.Context) error
+	enqueue(ctx context.Context, j *Job) error
+	// claim leases the oldest pending job to node until the given time, or
+	// returns nil when nothing is pending.
+	claim(ctx context.Context, node string, now, until time.Time) (*Job, error)
+	extend(ctx context.Context, id, node string, until time.Time) (bool, error)
+	complete(ctx context.Context, j *Job, ttl time.Duration) (bool, error)
+	// release gives a job back unfinished, without counting the attempt.
+	release(ctx context.Context, id, node string) (bool, error)
+	// reap requeues jobs whose lease ran out, or marks them dead after
+	// maxAttempts deliveries.
+	reap(ctx context.Context, now time.Time, maxAttempts int, ttl time.Duration) (requeued, dead []string, err error)
+	get(ctx context.Context, id string) (*Job, error)
+	beat(ctx context.Context, node string, now time.Time) error
+	stats(ctx context.Context, liveSince time.Time) (Stats, error)
+}
+
+// --- Redis ---
+
+// redisBackend keeps the queue in Redis:
+//
+//	<prefix>:pending   list of job IDs, pushed left and claimed right
+//	<prefix>:leases    sorted set of leased job IDs by lease deadline (ms)
+//	<prefix>:job:<id>  hash with the job's fields
+//	<prefix>:nodes     sorted set of node names by last heartbeat (ms)
+//
+// State changes run as Lua scripts so concurrent nodes never see a job
+// half-claimed.
+type redisBackend struct {
+	rc     *redisClient
+	prefix string
+}
+
+func (b *redisBackend) key(parts ...string) string {
+	k := b.prefix
+	for _, p := range parts {
+		k += ":" + p
+	}
+	return k
+}
+
+func (b *redisBackend) eval(ctx context.Context, script string, keys []string, args ...string) (any, error) {
+	cmd := append([]string{"EVAL", script, strconv.Itoa(len(keys))}, keys...)
+	return b.rc.Do(ctx, append(cmd, args...)...)
+}
+
+func (b *redisBackend) ping(ctx context.Context) error {
+	_, err := b.rc.Do(ctx, "PING")
+	return err
+}
+
+const enqueueScript = `
+redis.call('HSET', KEYS[2], 'task', ARGV[2], 'agent', ARGV[3], 'status', 'pending',
+  'attempts', 0, 'enqueuedBy', ARGV[4], 'enqueuedAt', ARGV[5])
+redis.call('LPUSH', KEYS[1], ARGV[1])
+return 1`
+
+func (b *redisBackend) enqueue(ctx context.Context, j *Job) error {
+	task, err := json.Marshal(j.Task)
+	if err != nil {
+		return err
+	}
+	_, err = b.eval(ctx, enqueueScript, []string{b.key("pending"), b.key("job", j.ID)},
+		j.ID, string(task), j.Agent, j.EnqueuedBy, j.EnqueuedAt)
+	return err
+}
+
+// Jobs whose hash expired or was deleted are skipped.
+const claimScript = `
+while true do
+  local id = redis.call('RPOP', KEYS[1])
+  if not id then return false end
+  local key = ARGV[5] .. id
+  if redis.call('EXISTS', key) == 1 then
+    redis.call('HSET', key, 'status', 'leased', 'node', ARGV[1], 'claimedAt', ARGV[2], 'leaseUntil', ARGV[3])
+    redis.call('HINCRBY', key, 'attempts', 1)
+    redis.call('ZADD', KEYS[2], ARGV[4], id)
+    local fields = redis.call('HGETALL', key)
+    table.insert(fields, 'id')
+    table.insert(fields, id)
+    return fields
+  end
+end`
+
+func (b *redisBackend) claim(ctx context.Context, node string, now, until time.Time) (*Job, error) {
+	reply, err := b.eval(ctx, claimScript, []string{b.key("pending"), b.key("leases")},
+		node, now.Format(time.RFC3339), until.Format(time.RFC3339), msec(until), b.key("job", ""))
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	return jobFromFields(reply)
+}
+
+const extendScript = `
+local key = ARGV[5] .. ARGV[1]
+if redis.call('HGET', key, 'node') ~= ARGV[2] or redis.call('HGET', key, 'status') ~= 'leased' then
+  return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+redis.call('HSET', key, 'leaseUntil', ARGV[4])
+return 1`
+
+func (b *redisBackend) extend(ctx context.Context, id, node string, until time.Time) (bool, error) {
+	reply, err := b.eval(ctx, extendScript, []string{b.key("leases")},
+		id, node, msec(until), until.Format(time.RFC3339), b.key("job", ""))
+	return reply == int64(1), err
+}
+
+const completeScript = `
+local key = ARGV[9] .. ARGV[1]
+if redis.call('HGET', key, 'node') ~= ARGV[2] or redis.call('HGET', key, 'status') ~= 'leased' then
+  return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HSET', key, 'status', 'done', 'finishedAt', ARGV[3], 'result', ARGV[4],
+  'error', ARGV[5], 'costUsd', ARGV[6], 'output', ARGV[7])
+redis.call('HDEL', key, 'leaseUntil')
+redis.call('EXPIRE', key, ARGV[8])
+return 1`
+
+func (b *redisBackend) complete(ctx context.Context, j *Job, ttl time.Duration) (bool, error) {
+	reply, err := b.eval(ctx, completeScript, []string{b.key("leases")},
+		j.ID, j.Node, j.FinishedAt, j.Result, j.Error,
+		strconv.FormatFloat(j.CostUSD, 'f', -1, 64), j.Output, seconds(ttl), b.key("job", ""))
+	return reply == int64(1), err
+}
+
+const releaseScript = `
+local key = ARGV[3] .. ARGV[1]
+if redis.call('HGET', key, 'node') ~= ARGV[2] or redis.call('HGET', key, 'status') ~= 'leased' then
+  return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HSET', key, 'status', 'pending')
+redis.call('HDEL', key, 'node', 'leaseUntil')
+redis.call('HINCRBY', key, 'attempts', -1)
+redis.call('RPUSH', KEYS[1], ARGV[1])
+return 1`
+
+func (b *redisBackend) release(ctx context.Context, id, node string) (bool, error) {
+	reply, err := b.eval(ctx, releaseScript, []string{b.key("pending"), b.key("leases")},
+		id, node, b.key("job", ""))
+	return reply == int64(1), err
+}
+
+// A requeued job goes to the claiming end of the list so it runs next.
+const reapScript = `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+local requeued, dead = {}, {}
+for _, id in ipairs(ids) do
+  redis.call('ZREM', KEYS[2], id)
+  local key = ARGV[3] .. id
+  if redis.call('EXISTS', key) == 1 then
+    local node = redis.call('HGET', key, 'node') or ''
+    local attempts = tonumber(redis.call('HGET', key, 'attempts') or '0')
+    if attempts >= tonumber(ARGV[2]) then
+      redis.call('HSET', key, 'status', 'dead', 'finishedAt', ARGV[4],
+        'error', 'lease expired on ' .. node .. ' after ' .. attempts .. ' attempt(s)')
+      redis.call('HDEL', key, 'leaseUntil')
+      redis.call('EXPIRE', key, ARGV[5])
+      table.insert(dead, id)
+    else
+      redis.call('HSET', key, 'status', 'pending', 'error', 'lease expired on ' .. node)
+      redis.call('HDEL', key, 'node', 'leaseUntil')
+      redis.call('RPUSH', KEYS[1], id)
+      table.insert(requeued, id)
+    end
+  end
+end
+return {requeued, dead}`
+
+func (b *redisBackend) reap(ctx context.Context, now time.Time, maxAttempts int, ttl time.Duration) ([]string, []string, error) {
+	reply, err := b.eval(ctx, reapScript, []string{b.key("pending"), b.key("leases")},
+		msec(now), strconv.Itoa(maxAttempts), b.key("job", ""), now.Format(time.RFC3339), seconds(ttl))
+	if err != nil {
+		return nil, nil, err
+	}
+	parts, _ := reply.([]any)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("redis: unexpected reap reply %v", reply)
+	}
+	return stringList(parts[0]), stringList(parts[1]), nil
+}
+
+func (b *redisBackend) get(ctx context.Context, id string) (*Job, error) {
+	reply, err := b.rc.Do(ctx, "HGETALL", b.key("job", id))
+	if err != nil {
+		return nil, err
+	}
+	if fields, _ := reply.([]any); len(fields) == 0 {
+		return nil, nil
+	}
+	j, err := jobFromFields(reply)
+	if j != nil {
+		j.ID = id
+	}
+	return j, err
+}
+
+func (b *redisBackend) beat(ctx context.Context, node string, now time.Time) error {
+	_, err := b.rc.Do(ctx, "ZADD", b.key("nodes"), msec(now), node)
+	return err
+}
+
+const statsScript = `
+local leased = {}
+for _, id in ipairs(redis.call('ZRANGE', KEYS[2], 0, -1)) do
+  table.insert(leased, redis.call('HGET', ARGV[2] .. id, 'node') or '')
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', '(' .. ARGV[1])
+return {redis.call('LLEN', KEYS[1]), leased, redis.call('ZRANGE', KEYS[3], 0, -1, 'WITHSCORES')}`
+
+func (b *redisBackend) stats(ctx context.Context, liveSince time.Time) (Stats, error) {
+	st := Stats{Leased: map[string]int{}, Nodes: map[string]string{}}
+	reply, err := b.eval(ctx, statsScript, []string{b.key("pending"), b.key("leases"), b.key("nodes")},
+		msec(liveSince), b.key("job", ""))
+	if err != nil {
+		return st, err
+	}
+	parts, _ := reply.([]any)
+	if len(parts) != 3 {
+		return st, fmt.Errorf("redis: unexpected stats reply %v", reply)
+	}
+	n, _ := parts[0].(int64)
+	st.Pending = int(n)
+	for _, node := range stringList(parts[1]) {
+		st.Leased[node]++
+	}
+	nodes := stringList(parts[2])
+	for i := 0; i+1 < len(nodes); i += 2 {
+		ms, _ := strconv.ParseFloat(nodes[i+1], 64)
+		st.Nodes[nodes[i]] = time.UnixMilli(int64(ms)).Format(time.RFC3339)
+	}
+	return st, nil
+}
+
+// jobFromFields decodes a flat HGETALL-style field/value array.
+func jobFromFields(reply any) (*Job, error) {
+	fields := stringList(reply)
+	m := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		m[fields[i]] = fields[i+1]
+	}
+	j := &Job{
+		ID:         m["id"],
+		Agent:      m["agent"],
+		Status:     m["status"],
+		Node:       m["node"],
+		EnqueuedBy: m["enqueuedBy"],
+		EnqueuedAt: m["enqueuedAt"],
+		ClaimedAt:  m["claimedAt"],
+		LeaseUntil: m["leaseUntil"],
+		FinishedAt: m["finishedAt"],
+		Result:     m["result"],
+		Error:      m["error"],
+		Output:     m["output"],
+	}
+	j.Attempts, _ = strconv.Atoi(m["attempts"])
+	j.CostUSD, _ = strconv.ParseFloat(m["costUsd"], 64)
+	if err := json.Unmarshal([]byte(m["task"]), &j.Task); err != nil {
+		return j, fmt.Errorf("job %s: bad task: %w", j.ID, err)
+	}
+	return j, nil
+}
+
+func stringList(v any) []string {
+	arr, _ := v.([]any)
+	out := make([]string, 0, len(arr))
+	for _, x := range arr {
+		switch x := x.(type) {
+		case string:
+			out = append(out, x)
+		case int64:
+			out = append(out, strconv.FormatInt(x, 10))
+		}
+	}
+	return out
+}
+
+func msec(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }
+
+func seconds(d time.Duration) string { return strconv.Itoa(max(int(d/time.Second), 1)) }
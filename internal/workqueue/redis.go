@@ -0,0 +1,206 @@
+package workqueue
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisError is an error reply from the server (RESP "-ERR ...").
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisClient is a minimal RESP2 client: enough for the few commands and Lua
+// scripts the queue runs. Connections are pooled; one that fails on I/O is
+// dropped and the next call dials again.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	useTLS   bool
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+type redisConn struct {
+	c  net.Conn
+	br *bufio.Reader
+}
+
+const maxIdleConns = 4
+
+func newRedisClient(addr, password string, db int, useTLS bool) *redisClient {
+	return &redisClient{addr: addr, password: password, db: db, useTLS: useTLS, timeout: 10 * time.Second}
+}
+
+// Do sends one command and returns its reply: string for simple and bulk
+// strings, int64 for integers, nil for a nil reply, []any for arrays, and a
+// redisError for an error reply.
+func (r *redisClient) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, r.timeout, args)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		conn.c.Close()
+		return nil, err
+	}
+	r.put(conn)
+	return reply, err
+}
+
+// Close closes the idle connections.
+func (r *redisClient) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.idle {
+		c.c.Close()
+	}
+	r.idle = nil
+}
+
+func (r *redisClient) get(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	d := &net.Dialer{Timeout: r.timeout}
+	var nc net.Conn
+	var err error
+	if r.useTLS {
+		host, _, _ := net.SplitHostPort(r.addr)
+		nc, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis dial %s: %w", r.addr, err)
+	}
+	c := &redisConn{c: nc, br: bufio.NewReader(nc)}
+	if r.password != "" {
+		if _, err := c.do(ctx, r.timeout, []string{"AUTH", r.password}); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.do(ctx, r.timeout, []string{"SELECT", strconv.Itoa(r.db)}); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *redisClient) put(c *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.idle) >= maxIdleConns {
+		c.c.Close()
+		return
+	}
+	r.idle = append(r.idle, c)
+}
+
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.c.SetDeadline(deadline)
+	if _, err := c.c.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(c.br)
+}
+
+// encodeCommand encodes args as a RESP array of bulk strings.
+func encodeCommand(args []string) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readReply reads one RESP2 reply. An error reply is returned as the error
+// with a nil value; the connection stays usable.
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]any, n)
+		var firstErr error
+		for i := range out {
+			v, err := readReply(br)
+			var rerr redisError
+			if err != nil && !errors.As(err, &rerr) {
+				return nil, err
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			out[i] = v
+		}
+		return out, firstErr
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,264 @@
+// Package workqueue shares tasks between several daemons.
+//
+// Any node can enqueue a task into a queue kept in Redis. Idle nodes claim
+// tasks under a lease that a heartbeat renews while the task runs. When a node
+// dies, its lease runs out and the task is handed to another node, so delivery
+// is at least once: a task whose node stalled past its lease may run twice. A
+// task is dropped as dead after workQueue.maxAttempts expired leases.
+package workqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+	"tetora/internal/log"
+)
+
+// ErrLeaseLost is the cancellation cause of a task whose lease was taken
+// over by another node.
+var ErrLeaseLost = errors.New("work queue lease lost")
+
+const (
+	pollInterval  = time.Second
+	errorBackoff  = 5 * time.Second
+	maxOutputKept = 4000
+)
+
+// Deps holds the root-package callbacks the service needs.
+type Deps struct {
+	Executor dispatch.TaskExecutor
+	NewID    func() string
+
+	// Finished is called on the node that ran a job, after the run, with the
+	// job as it was completed. Used for the audit log.
+	Finished func(ctx context.Context, j *Job, res dispatch.TaskResult)
+}
+
+// Service enqueues tasks and runs this node's workers.
+type Service struct {
+	cfg  config.WorkQueueConfig
+	node string
+	b    backend
+	deps Deps
+	poll time.Duration
+
+	wg sync.WaitGroup
+}
+
+// New creates a service for cfg. Call Start to begin claiming tasks.
+func New(cfg config.WorkQueueConfig, deps Deps) (*Service, error) {
+	if cfg.Backend != "" && cfg.Backend != "redis" {
+		return nil, fmt.Errorf("workQueue.backend %q is not supported (use \"redis\")", cfg.Backend)
+	}
+	rc := newRedisClient(cfg.AddrOrDefault(), cfg.Password, cfg.DB, cfg.TLS)
+	return newService(cfg, &redisBackend{rc: rc, prefix: cfg.PrefixOrDefault()}, deps), nil
+}
+
+func newService(cfg config.WorkQueueConfig, b backend, deps Deps) *Service {
+	return &Service{cfg: cfg, node: cfg.NodeOrDefault(), b: b, deps: deps, poll: pollInterval}
+}
+
+// Node returns this node's name.
+func (s *Service) Node() string { return s.node }
+
+// Ping checks that the queue backend is reachable.
+func (s *Service) Ping(ctx context.Context) error { return s.b.ping(ctx) }
+
+// Enqueue adds a task for any node to run.
+func (s *Service) Enqueue(ctx context.Context, task dispatch.Task, agent string) (*Job, error) {
+	j := &Job{
+		ID:         s.deps.NewID(),
+		Task:       task,
+		Agent:      agent,
+		Status:     StatusPending,
+		EnqueuedBy: s.node,
+		EnqueuedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := s.b.enqueue(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Get returns a job, or nil when it is unknown or its result has expired.
+func (s *Service) Get(ctx context.Context, id string) (*Job, error) {
+	return s.b.get(ctx, id)
+}
+
+// Stats returns the queue's size, leases per node and the live nodes.
+func (s *Service) Stats(ctx context.Context) (Stats, error) {
+	st, err := s.b.stats(ctx, time.Now().Add(-3*s.cfg.HeartbeatOrDefault()))
+	st.Node = s.node
+	return st, err
+}
+
+// Start runs workQueue.concurrency workers and the heartbeat loop until ctx
+// is done. Tasks still running at shutdown are handed back to the queue.
+func (s *Service) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.heartbeat(ctx)
+	for range s.cfg.ConcurrencyOrDefault() {
+		s.wg.Add(1)
+		go s.worker(ctx)
+	}
+}
+
+// Wait blocks until the goroutines started by Start have returned, so tasks
+// cancelled at shutdown are handed back, or until timeout. It reports whether
+// they returned.
+func (s *Service) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() { s.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// heartbeat marks the node alive and requeues tasks whose lease ran out.
+// Every node reaps, so tasks of a dead node are recovered by the others.
+func (s *Service) heartbeat(ctx context.Context) {
+	defer s.wg.Done()
+	t := time.NewTicker(s.cfg.HeartbeatOrDefault())
+	defer t.Stop()
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Service) tick(ctx context.Context) {
+	now := time.Now()
+	if err := s.b.beat(ctx, s.node, now); err != nil {
+		log.Warn("workqueue: heartbeat failed", "node", s.node, "error", err)
+		return
+	}
+	requeued, dead, err := s.b.reap(ctx, now, s.cfg.MaxAttemptsOrDefault(), s.cfg.ResultTTLOrDefault())
+	if err != nil {
+		log.Warn("workqueue: reap failed", "error", err)
+		return
+	}
+	for _, id := range requeued {
+		log.Warn("workqueue: lease expired, task requeued", "jobId", id)
+	}
+	for _, id := range dead {
+		log.Error("workqueue: lease expired too often, task dropped", "jobId", id,
+			"maxAttempts", s.cfg.MaxAttemptsOrDefault())
+	}
+}
+
+func (s *Service) worker(ctx context.Context) {
+	defer s.wg.Done()
+	lease := s.cfg.LeaseOrDefault()
+	for ctx.Err() == nil {
+		now := time.Now()
+		j, err := s.b.claim(ctx, s.node, now, now.Add(lease))
+		wait := time.Duration(0)
+		switch {
+		case err != nil && j == nil:
+			log.Warn("workqueue: claim failed", "node", s.node, "error", err)
+			wait = errorBackoff
+		case j == nil:
+			wait = s.poll
+		default:
+			if err != nil {
+				// Claimed but unreadable: finish it so it is not redelivered forever.
+				j.Node, j.Result, j.Error = s.node, "error", err.Error()
+				j.FinishedAt = time.Now().Format(time.RFC3339)
+				s.b.complete(ctx, j, s.cfg.ResultTTLOrDefault())
+				continue
+			}
+			s.run(ctx, j)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+// run executes a claimed job, renewing its lease until it finishes.
+func (s *Service) run(ctx context.Context, j *Job) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go s.keepLease(runCtx, cancel, j.ID)
+
+	log.InfoCtx(ctx, "workqueue: running task", "jobId", j.ID, "node", s.node,
+		"name", j.Task.Name, "agent", j.Agent, "attempt", j.Attempts)
+	res := s.deps.Executor.RunTask(runCtx, j.Task, j.Agent)
+
+	if ctx.Err() != nil && context.Cause(runCtx) != ErrLeaseLost {
+		// Shutting down: hand the task to another node rather than record a
+		// cancellation.
+		rctx, rcancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer rcancel()
+		if ok, err := s.b.release(rctx, j.ID, s.node); err != nil || !ok {
+			log.Warn("workqueue: release at shutdown failed, task waits for its lease", "jobId", j.ID, "error", err)
+		}
+		return
+	}
+
+	j.Status = StatusDone
+	j.FinishedAt = time.Now().Format(time.RFC3339)
+	j.Result, j.Error, j.CostUSD = res.Status, res.Error, res.CostUSD
+	j.Output = truncate(res.Output, maxOutputKept)
+	j.LeaseUntil = ""
+	ok, err := s.b.complete(ctx, j, s.cfg.ResultTTLOrDefault())
+	switch {
+	case err != nil:
+		log.Warn("workqueue: complete failed", "jobId", j.ID, "error", err)
+	case !ok:
+		// The lease ran out and the task was handed on: it may run again.
+		log.Warn("workqueue: lease lost before completion", "jobId", j.ID, "node", s.node)
+	}
+	if s.deps.Finished != nil {
+		s.deps.Finished(ctx, j, res)
+	}
+}
+
+// keepLease renews j's lease every heartbeat and cancels the run once the
+// lease belongs to someone else.
+func (s *Service) keepLease(ctx context.Context, cancel context.CancelCauseFunc, id string) {
+	t := time.NewTicker(s.cfg.HeartbeatOrDefault())
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		ok, err := s.b.extend(ctx, id, s.node, time.Now().Add(s.cfg.LeaseOrDefault()))
+		if err != nil {
+			// Keep running: the lease may still be renewed before it expires.
+			log.Warn("workqueue: lease renewal failed", "jobId", id, "error", err)
+			continue
+		}
+		if !ok {
+			log.Warn("workqueue: lease taken over, cancelling task", "jobId", id, "node", s.node)
+			cancel(ErrLeaseLost)
+			return
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
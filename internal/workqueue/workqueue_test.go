@@ -0,0 +1,334 @@
+package workqueue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tetora/internal/config"
+	"tetora/internal/dispatch"
+)
+
+// memBackend is an in-memory backend with the Redis scripts' semantics.
+type memBackend struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending []string // claimed from the end
+	leases  map[string]time.Time
+	nodes   map[string]time.Time
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{jobs: map[string]*Job{}, leases: map[string]time.Time{}, nodes: map[string]time.Time{}}
+}
+
+func (m *memBackend) ping(context.Context) error { return nil }
+
+func (m *memBackend) enqueue(_ context.Context, j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *j
+	m.jobs[j.ID] = &c
+	m.pending = append([]string{j.ID}, m.pending...)
+	return nil
+}
+
+func (m *memBackend) claim(_ context.Context, node string, now, until time.Time) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pending) == 0 {
+		return nil, nil
+	}
+	id := m.pending[len(m.pending)-1]
+	m.pending = m.pending[:len(m.pending)-1]
+	j := m.jobs[id]
+	j.Status, j.Node, j.Attempts = StatusLeased, node, j.Attempts+1
+	j.ClaimedAt, j.LeaseUntil = now.Format(time.RFC3339), until.Format(time.RFC3339)
+	m.leases[id] = until
+	c := *j
+	return &c, nil
+}
+
+func (m *memBackend) owned(id, node string) *Job {
+	if j := m.jobs[id]; j != nil && j.Node == node && j.Status == StatusLeased {
+		return j
+	}
+	return nil
+}
+
+func (m *memBackend) extend(_ context.Context, id, node string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owned(id, node) == nil {
+		return false, nil
+	}
+	m.leases[id] = until
+	return true, nil
+}
+
+func (m *memBackend) complete(_ context.Context, j *Job, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := m.owned(j.ID, j.Node)
+	if cur == nil {
+		return false, nil
+	}
+	delete(m.leases, j.ID)
+	cur.Status, cur.FinishedAt, cur.Result, cur.Error = StatusDone, j.FinishedAt, j.Result, j.Error
+	cur.CostUSD, cur.Output, cur.LeaseUntil = j.CostUSD, j.Output, ""
+	return true, nil
+}
+
+func (m *memBackend) release(_ context.Context, id, node string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.owned(id, node)
+	if j == nil {
+		return false, nil
+	}
+	delete(m.leases, id)
+	j.Status, j.Node, j.LeaseUntil = StatusPending, "", ""
+	j.Attempts--
+	m.pending = append(m.pending, id)
+	return true, nil
+}
+
+func (m *memBackend) reap(_ context.Context, now time.Time, maxAttempts int, _ time.Duration) (requeued, dead []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, until := range m.leases {
+		if until.After(now) {
+			continue
+		}
+		delete(m.leases, id)
+		j := m.jobs[id]
+		if j.Attempts >= maxAttempts {
+			j.Status, j.Error = StatusDead, "lease expired on "+j.Node
+			dead = append(dead, id)
+		} else {
+			j.Status, j.Node, j.Error = StatusPending, "", "lease expired on "+j.Node
+			m.pending = append(m.pending, id)
+			requeued = append(requeued, id)
+		}
+	}
+	return requeued, dead, nil
+}
+
+func (m *memBackend) get(_ context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j := m.jobs[id]; j != nil {
+		c := *j
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (m *memBackend) beat(_ context.Context, node string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes[node] = now
+	return nil
+}
+
+func (m *memBackend) stats(_ context.Context, liveSince time.Time) (Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := Stats{Pending: len(m.pending), Leased: map[string]int{}, Nodes: map[string]string{}}
+	for id := range m.leases {
+		st.Leased[m.jobs[id].Node]++
+	}
+	for n, t := range m.nodes {
+		if !t.Before(liveSince) {
+			st.Nodes[n] = t.Format(time.RFC3339)
+		}
+	}
+	return st, nil
+}
+
+// testNode returns a service named node on b whose executor is run.
+func testNode(b backend, node string, run func(ctx context.Context, t dispatch.Task) dispatch.TaskResult, finished chan<- *Job) *Service {
+	n := 0
+	var mu sync.Mutex
+	s := newService(config.WorkQueueConfig{Node: node, Lease: "5s", Heartbeat: "10ms", MaxAttempts: 2}, b, Deps{
+		Executor: dispatch.TaskExecutorFunc(func(ctx context.Context, t dispatch.Task, _ string) dispatch.TaskResult {
+			return run(ctx, t)
+		}),
+		NewID: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			n++
+			return fmt.Sprintf("%s-%d", node, n)
+		},
+		Finished: func(_ context.Context, j *Job, _ dispatch.TaskResult) {
+			if finished != nil {
+				finished <- j
+			}
+		},
+	})
+	s.poll = 5 * time.Millisecond
+	return s
+}
+
+func succeed(_ context.Context, t dispatch.Task) dispatch.TaskResult {
+	return dispatch.TaskResult{Status: "success", Output: "did " + t.Name, CostUSD: 0.01}
+}
+
+func TestService_RedeliversExpiredLease(t *testing.T) {
+	b := newMemBackend()
+	ctx := context.Background()
+
+	// Node a claims the task and dies: its lease is never renewed.
+	a := testNode(b, "a", succeed, nil)
+	job, err := a.Enqueue(ctx, dispatch.Task{Name: "report"}, "kokuyou")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if j, _ := b.claim(ctx, "a", now, now.Add(-time.Second)); j == nil || j.ID != job.ID {
+		t.Fatalf("claim = %+v", j)
+	}
+	if st, _ := a.Stats(ctx); st.Leased["a"] != 1 || st.Pending != 0 {
+		t.Errorf("stats while leased = %+v", st)
+	}
+
+	// Node b reaps the expired lease and runs the task itself.
+	finished := make(chan *Job, 1)
+	bNode := testNode(b, "b", succeed, finished)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer func() { cancel(); bNode.Wait(5 * time.Second) }()
+	bNode.Start(runCtx)
+
+	select {
+	case j := <-finished:
+		if j.Node != "b" || j.Result != "success" || j.Attempts != 2 {
+			t.Errorf("finished job = %+v", j)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("task not redelivered")
+	}
+	got, _ := bNode.Get(ctx, job.ID)
+	if got.Status != StatusDone || got.Node != "b" || got.Output != "did report" || got.EnqueuedBy != "a" {
+		t.Errorf("stored job = %+v", got)
+	}
+	if st, _ := bNode.Stats(ctx); st.Nodes["b"] == "" || st.Nodes["a"] != "" {
+		t.Errorf("live nodes = %v", st.Nodes)
+	}
+}
+
+func TestService_DeadAfterMaxAttempts(t *testing.T) {
+	b := newMemBackend()
+	ctx := context.Background()
+	s := testNode(b, "a", succeed, nil)
+	job, _ := s.Enqueue(ctx, dispatch.Task{Name: "flaky"}, "")
+	for range 2 {
+		now := time.Now()
+		b.claim(ctx, "a", now, now.Add(-time.Second))
+		s.tick(ctx)
+	}
+	if got, _ := s.Get(ctx, job.ID); got.Status != StatusDead || !strings.Contains(got.Error, "lease expired") {
+		t.Errorf("job after max attempts = %+v", got)
+	}
+	if j, _ := b.claim(ctx, "a", time.Now(), time.Now().Add(time.Minute)); j != nil {
+		t.Errorf("dead job claimed again: %+v", j)
+	}
+}
+
+func TestService_LeaseLostCancelsRun(t *testing.T) {
+	b := newMemBackend()
+	ctx := context.Background()
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	s := testNode(b, "a", func(ctx context.Context, _ dispatch.Task) dispatch.TaskResult {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return dispatch.TaskResult{Status: "cancelled"}
+	}, nil)
+	job, _ := s.Enqueue(ctx, dispatch.Task{Name: "long"}, "")
+	now := time.Now()
+	j, _ := b.claim(ctx, "a", now, now.Add(time.Minute))
+
+	done := make(chan struct{})
+	go func() { s.run(ctx, j); close(done) }()
+	<-started
+	// Another node took the task over.
+	b.mu.Lock()
+	b.jobs[job.ID].Node = "b"
+	b.mu.Unlock()
+
+	select {
+	case err := <-cause:
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("cause = %v, want ErrLeaseLost", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("run not cancelled after losing the lease")
+	}
+	<-done
+	if got, _ := s.Get(ctx, job.ID); got.Node != "b" || got.Status != StatusLeased {
+		t.Errorf("lost lease overwritten: %+v", got)
+	}
+}
+
+func TestService_ReleaseOnShutdown(t *testing.T) {
+	b := newMemBackend()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	s := testNode(b, "a", func(ctx context.Context, _ dispatch.Task) dispatch.TaskResult {
+		close(started)
+		<-ctx.Done()
+		return dispatch.TaskResult{Status: "cancelled"}
+	}, nil)
+	job, _ := s.Enqueue(ctx, dispatch.Task{Name: "long"}, "")
+	s.Start(ctx)
+	<-started
+	cancel()
+	if !s.Wait(5 * time.Second) {
+		t.Fatal("workers still running after shutdown")
+	}
+
+	got, _ := s.Get(context.Background(), job.ID)
+	if got.Status != StatusPending || got.Attempts != 0 || !slices.Contains(b.pending, job.ID) {
+		t.Errorf("job after shutdown = %+v", got)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	raw := "*4\r\n$5\r\nhello\r\n:42\r\n$-1\r\n*2\r\n+OK\r\n$0\r\n\r\n" +
+		"-ERR wrong type\r\n"
+	br := bufio.NewReader(strings.NewReader(raw))
+	v, err := readReply(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprint([]any{"hello", int64(42), nil, []any{"OK", ""}})
+	if got := fmt.Sprint(v); got != want {
+		t.Errorf("reply = %s, want %s", got, want)
+	}
+	var rerr redisError
+	if _, err := readReply(br); !errors.As(err, &rerr) || !strings.Contains(err.Error(), "wrong type") {
+		t.Errorf("error reply = %v", err)
+	}
+
+	if got := string(encodeCommand([]string{"GET", "k€y"})); got != "*2\r\n$3\r\nGET\r\n$5\r\nk€y\r\n" {
+		t.Errorf("encodeCommand = %q", got)
+	}
+}
+
+func TestJobFromFields(t *testing.T) {
+	j, err := jobFromFields([]any{"task", `{"name":"digest","prompt":"hi"}`, "agent", "kokuyou",
+		"status", "done", "node", "b", "attempts", "2", "costUsd", "0.25", "id", "j1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.ID != "j1" || j.Task.Name != "digest" || j.Attempts != 2 || j.CostUSD != 0.25 || j.Node != "b" {
+		t.Errorf("job = %+v", j)
+	}
+}
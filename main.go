@@ -2,16 +2,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,17 +23,44 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"tetora/internal/abtest"
 	"tetora/internal/audit"
+	"tetora/internal/cfgfile"
 	"tetora/internal/circuit"
 	"tetora/internal/cli"
 	"tetora/internal/completion"
 	"tetora/internal/config"
 	"tetora/internal/cost"
 	"tetora/internal/db"
+	"tetora/internal/emergency"
 	"tetora/internal/export"
+	"tetora/internal/faq"
+	"tetora/internal/feedback"
+	"tetora/internal/handover"
+	"tetora/internal/integration/forge"
+	"tetora/internal/integration/health"
+	"tetora/internal/integration/mail"
+	"tetora/internal/integration/mailin"
+	"tetora/internal/integration/mqtt"
+	"tetora/internal/integration/notes"
+	"tetora/internal/integration/readlater"
+	"tetora/internal/integration/tasksync"
+	"tetora/internal/integration/social"
+	"tetora/internal/integration/twitter"
+	"tetora/internal/docwatch"
+	"tetora/internal/monitor"
+	"tetora/internal/memory"
+	"tetora/internal/pipeline"
+	"tetora/internal/translate"
 	"tetora/internal/history"
+	"tetora/internal/instancelock"
+	"tetora/internal/httpapi"
 	"tetora/internal/hooks"
+	"tetora/internal/intent"
 	"tetora/internal/knowledge"
+	"tetora/internal/leader"
+	"tetora/internal/life/calendar"
+	"tetora/internal/life/profile"
 	"tetora/internal/log"
 	"tetora/internal/messaging/gchat"
 	"tetora/internal/messaging/groupchat"
@@ -39,18 +70,32 @@ import (
 	slackbot "tetora/internal/messaging/slack"
 	teamsbot "tetora/internal/messaging/teams"
 	tgbot "tetora/internal/messaging/telegram"
+	"tetora/internal/messaging/webhook"
 	"tetora/internal/messaging/whatsapp"
 	"tetora/internal/metrics"
 	"tetora/internal/migrate"
+	"tetora/internal/gitops"
+	"tetora/internal/mirror"
+	"tetora/internal/mtls"
+	"tetora/internal/objstore"
+	"tetora/internal/oidc"
+	"tetora/internal/prompt"
+	"tetora/internal/replica"
+	"tetora/internal/respcache"
 	"tetora/internal/sandbox"
 	"tetora/internal/scheduling"
+	"tetora/internal/siem"
 	"tetora/internal/sla"
+	"tetora/internal/statesync"
 	"tetora/internal/storage"
 	"tetora/internal/telemetry"
 	"tetora/internal/tools"
+	"tetora/internal/totp"
 	"tetora/internal/trace"
 	"tetora/internal/upload"
 	"tetora/internal/version"
+	"tetora/internal/walship"
+	"tetora/internal/workqueue"
 	imessagebot "tetora/internal/messaging/imessage"
 )
 
@@ -76,6 +121,10 @@ func main() {
 	// Set CLI version before routing.
 	cli.TetoraVersion = tetoraVersion
 
+	// --profile works with every command; it is passed on through the
+	// environment so daemons and services started from here inherit it.
+	os.Args = applyProfileFlag(os.Args)
+
 	// Subcommand routing.
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -101,6 +150,12 @@ func main() {
 		case "status":
 			cli.CmdStatus(os.Args[2:])
 			return
+		case "top":
+			cli.CmdTop(os.Args[2:])
+			return
+		case "chat":
+			cli.CmdChat(os.Args[2:])
+			return
 		case "dispatch":
 			cli.CmdDispatch(os.Args[2:])
 			return
@@ -151,22 +206,39 @@ func main() {
 			return
 		case "webhook":
 			cli.CmdWebhook(os.Args[2:])
+		case "channel":
+			cli.CmdChannel(os.Args[2:])
+			return
+		case "handover":
+			cli.CmdHandover(os.Args[2:])
+			return
+		case "faq":
+			cli.CmdFAQ(os.Args[2:])
+			return
+		case "feedback":
+			cli.CmdFeedback(os.Args[2:])
+			return
+		case "monitor":
+			cli.CmdMonitor(os.Args[2:])
+			return
+		case "pipeline":
+			cli.CmdPipeline(os.Args[2:])
+			return
+		case "automation":
+			cli.CmdAutomation(os.Args[2:])
 			return
 		case "data":
-			cli.CmdData(os.Args[2:])
+			cli.CmdData(os.Args[2:], userDataDeps())
 			return
 		case "oauth":
 			cli.CmdOAuth(os.Args[2:])
 			return
-		case "backup":
-			cli.CmdBackup(os.Args[2:])
-			return
-		case "restore":
-			cli.CmdRestore(os.Args[2:])
-			return
 		case "mirror":
 			cli.CmdMirror(os.Args[2:])
 			return
+		case "gitops":
+			cli.CmdGitOps(os.Args[2:])
+			return
 		case "discord":
 			cli.CmdDiscord(os.Args[2:])
 			return
@@ -244,6 +316,9 @@ func main() {
 		case "team":
 			cli.CmdTeam(os.Args[2:])
 			return
+		case "workspace":
+			cli.CmdWorkspace(os.Args[2:])
+			return
 		case "time-savings":
 			cmdTimeSavings(os.Args[2:])
 			return
@@ -253,6 +328,15 @@ func main() {
 		case "dashboard":
 			cmdOpenDashboard()
 			return
+		case "backup":
+			cmdBackup(os.Args[2:])
+			return
+		case "restore":
+			cmdRestore(os.Args[2:])
+			return
+		case "encryption":
+			cmdEncryption(os.Args[2:])
+			return
 		case "migrate":
 			if len(os.Args) > 2 && os.Args[2] == "encrypt" {
 				cmdMigrateEncrypt()
@@ -298,12 +382,18 @@ func main() {
 	filePath := flag.String("file", "", "tasks JSON file path")
 	notify := flag.Bool("notify", false, "send Telegram notification on completion")
 	serve := flag.Bool("serve", false, "run as daemon (Telegram bot + HTTP + cron)")
+	takeover := flag.Bool("takeover", false, "with --serve: drain a daemon already running on this base dir and take over")
 	flag.Parse()
 
 	cfg := loadConfig(*configPath)
 
 	// P27.2: Set global encryption key for standalone functions.
 	setGlobalEncryptionKey(resolveEncryptionKey(cfg))
+	if err := initAtRestEncryption(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: encryption: %v\n", err)
+		os.Exit(1)
+	}
+	installRedaction(cfg)
 
 	// Initialize structured logger from config.
 	// Backward compat: cfg.Log=true with no explicit level → debug.
@@ -347,6 +437,14 @@ func main() {
 	// backfills global vars for callers that haven't migrated yet.
 	app := &App{Cfg: cfg}
 
+	// Emergency stop: a stop engaged before a restart still holds. The
+	// global is set now since cron replays missed jobs as soon as it starts.
+	app.Emergency = emergency.Open(filepath.Join(cfg.BaseDir, "emergency-stop.json"))
+	globalEmergency = app.Emergency
+	if st := app.Emergency.State(); st.Engaged {
+		log.Warn("EMERGENCY STOP engaged: nothing will run until it is released", "since", st.Since, "by", st.By, "reason", st.Reason)
+	}
+
 	// Initialize hooks event receiver.
 	hookRecv := newHookReceiver(state.broker, cfg)
 	cfg.Runtime.HookRecv = hookRecv
@@ -359,6 +457,20 @@ func main() {
 		// --- Daemon mode ---
 		log.Info("tetora v2 starting", "maxConcurrent", cfg.MaxConcurrent, "childConcurrent", childSemConcurrentOrDefault(cfg))
 
+		// Refuse to share the base dir with another daemon. In HA mode two
+		// daemons may share it: the leader lease decides which one works.
+		if !cfg.HA.Enabled {
+			instLock, err := acquireInstanceLock(cfg, *takeover)
+			if err != nil {
+				log.Error("cannot start daemon", "error", err)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer instLock.Release()
+		}
+
+		setRouteTable(cfg)
+
 		// Track degraded services for health reporting.
 		var degradedServices []string
 
@@ -393,7 +505,7 @@ func main() {
 				}
 
 				// Cleanup records using retention config.
-				if err := history.Cleanup(cfg.HistoryDB, retentionDays(cfg.Retention.History, 90)); err != nil {
+				if err := cleanupJobHistory(cfg); err != nil {
 					log.Warn("cleanup history failed", "error", err)
 				}
 			}
@@ -402,7 +514,13 @@ func main() {
 				log.Warn("init audit_log failed", "error", err)
 			}
 			audit.StartWriter()
+			installAuditExport(cfg)
 			audit.Cleanup(cfg.HistoryDB, retentionDays(cfg.Retention.AuditLog, 365))
+			// Init trace timeline table; spans are recorded from here on.
+			if err := trace.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init trace_events failed", "error", err)
+			}
+			trace.Cleanup(cfg.HistoryDB, retentionDays(cfg.Retention.Traces, 14))
 			// Init agent memory table.
 			if err := initMemoryDB(cfg.HistoryDB); err != nil {
 				log.Warn("init agent_memory failed", "error", err)
@@ -417,16 +535,111 @@ func main() {
 			if err := initQueueDB(cfg.HistoryDB); err != nil {
 				log.Warn("init offline_queue failed", "error", err)
 			}
+			// Init deferred tasks table (agent schedule windows).
+			if err := initDeferredDB(cfg.HistoryDB); err != nil {
+				log.Warn("init deferred_tasks failed", "error", err)
+			}
 			// Init reflections table.
 			if err := initReflectionDB(cfg.HistoryDB); err != nil {
 				log.Warn("init reflections failed", "error", err)
 			}
 			// Init trust events table.
 			initTrustDB(cfg.HistoryDB)
+			// Init spend approvals table (estimate vs actual reconciliation).
+			if err := cost.InitSpendApprovalDB(cfg.HistoryDB); err != nil {
+				log.Warn("init spend_approvals failed", "error", err)
+			}
+			// Init handover tables.
+			if err := handover.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init handovers failed", "error", err)
+			}
+			// Init FAQ tables.
+			if err := faq.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init faq failed", "error", err)
+			}
+			// Init intent shortcut tables.
+			if err := intent.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init intents failed", "error", err)
+			}
+			// Init user profile, mood and modulation tables.
+			if err := profile.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init user profiles failed", "error", err)
+			}
+			// Init translation glossary and channel settings tables.
+			if err := translate.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init translate failed", "error", err)
+			}
+			// Init watchlist monitor tables.
+			if err := monitor.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init monitors failed", "error", err)
+			}
+			// Init data pipeline tables.
+			if err := pipeline.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init pipelines failed", "error", err)
+			}
+			// Init tweet drafts and engagement stats tables.
+			if err := twitter.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init tweet_drafts failed", "error", err)
+			}
+			// Init the Bluesky/Mastodon mentions inbox.
+			if err := social.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init social_mentions failed", "error", err)
+			}
+			// Init the read-later list and highlights.
+			if err := readlater.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init readlater failed", "error", err)
+			}
+			// Init the taskboard to Jira/Linear link table.
+			if err := tasksync.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init tasksync_links failed", "error", err)
+			}
+			// Init the response cache.
+			if err := respcache.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init response_cache failed", "error", err)
+			}
+			// Init the A/B experiment results.
+			if err := abtest.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init ab_runs failed", "error", err)
+			}
+			// Init the owner's feedback on task results.
+			if err := feedback.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init task_feedback failed", "error", err)
+			}
+			// Init the inbound email log.
+			if err := mailin.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init mail_inbound failed", "error", err)
+			}
+			// Init the state sync index and watermarks.
+			if cfg.Sync.Enabled {
+				if err := statesync.InitDB(cfg.HistoryDB); err != nil {
+					log.Warn("init sync tables failed", "error", err)
+				}
+			}
+			// Init the hashes last agreed with the mirror peer.
+			if cfg.Mirror.Enabled {
+				if err := mirror.InitDB(cfg.HistoryDB); err != nil {
+					log.Warn("init mirror_state failed", "error", err)
+				}
+			}
+			// Init the HA leader lease.
+			if cfg.HA.Enabled && cfg.HA.Lock == "db" {
+				if err := leader.InitDB(cfg.HistoryDB); err != nil {
+					log.Warn("init leader_lease failed", "error", err)
+				}
+			}
+			// Init dashboard SSO sessions.
+			if err := oidc.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init dashboard_sessions failed", "error", err)
+			}
+			// Init dashboard two-factor tables.
+			if err := totp.InitDB(cfg.HistoryDB); err != nil {
+				log.Warn("init dashboard_totp failed", "error", err)
+			}
 			// Init config versioning table.
 			if err := version.InitDB(cfg.HistoryDB); err != nil {
 				log.Warn("init config_versions failed", "error", err)
 			}
+			snapshotEffectiveConfig(cfg, "startup")
 			// Init agent communication table.
 			if err := initAgentCommDB(cfg.HistoryDB); err != nil {
 				log.Warn("init agent_messages failed", "error", err)
@@ -472,6 +685,13 @@ func main() {
 			}
 		}
 
+		// Calendars: Google, CalDAV and ICS feed accounts, read by smart
+		// scheduling and the calendar_* tools.
+		if cfg.Calendar.Enabled {
+			app.Calendar = newCalendarService(cfg)
+			log.Info("calendar enabled", "accounts", len(cfg.Calendar.AccountsOrDefault()))
+		}
+
 		// --- P24.4: Smart Scheduling ---
 		app.Scheduling = newSchedulingService(cfg)
 		log.Info("scheduling service initialized")
@@ -485,7 +705,7 @@ func main() {
 
 		// Init outputs directory + cleanup.
 		os.MkdirAll(filepath.Join(cfg.BaseDir, "outputs"), 0o755)
-		cleanupOutputs(cfg.BaseDir, retentionDays(cfg.Retention.Outputs, 30))
+		cleanupOutputFiles(cfg)
 
 		// Init uploads directory + cleanup.
 		uploadDir := upload.InitDir(cfg.BaseDir)
@@ -511,44 +731,85 @@ func main() {
 		ctx = withApp(ctx, app)
 		defer cancel()
 
+		// HA: a standby serves the API read-only; everything that does work
+		// (cron, drainers, bots) is started through app.Leader.WhenActive,
+		// which defers it until this daemon holds the lease.
+		if cfg.HA.Enabled {
+			app.Leader = newLeaderElector(cfg)
+			if app.Leader.Campaign(ctx) {
+				log.Info("ha: starting as active", "node", app.Leader.Status().Node)
+			} else {
+				st := app.Leader.Status()
+				log.Info("ha: starting as standby (read-only)", "node", st.Node, "leader", st.Leader, "leaderAddr", st.LeaderAddr)
+			}
+		} else if cfg.Replica.Enabled() && cfg.HistoryDB != "" {
+			// Replica: a standby that is never promoted. The primary's
+			// history DB is copied over the local one on every change.
+			app.Leader = leader.NewReplica(cfg.HA.NodeOrDefault(cfg.ListenAddr), cfg.Replica.Primary)
+			app.Replica = replica.New(cfg.Replica, cfg.HistoryDB)
+			go app.Replica.Start(ctx)
+			log.Info("replica: starting read-only", "primary", cfg.Replica.Primary)
+		}
+		startedActive := app.Leader.IsActive()
+
 		// --- P23.7: Reliability & Operations --- Start background services.
 		if cfg.Ops.MessageQueue.Enabled && cfg.HistoryDB != "" {
 			mqEngine := newMessageQueueEngine(cfg)
-			mqEngine.Start(ctx)
+			app.Leader.WhenActive(func() { mqEngine.Start(ctx) })
 			log.Info("message queue started")
 		}
 		if cfg.Ops.BackupSchedule != "" && cfg.HistoryDB != "" {
-			bsched := scheduling.NewBackupScheduler(scheduling.BackupConfig{
-			DBPath:     cfg.HistoryDB,
-			BackupDir:  cfg.Ops.BackupDirResolved(cfg.BaseDir),
-			RetainDays: cfg.Ops.BackupRetainOrDefault(),
-			EscapeSQL:  db.Escape,
-			LogInfo:    log.Info,
-			LogWarn:    log.Warn,
-		})
-			bsched.Start(ctx)
-			log.Info("backup scheduler started", "schedule", cfg.Ops.BackupSchedule, "retain", cfg.Ops.BackupRetainOrDefault())
+			bsched := newBackupScheduler(cfg)
+			app.Leader.WhenActive(func() { bsched.Start(ctx) })
+			log.Info("backup scheduler started", "schedule", cfg.Ops.BackupSchedule, "retain", cfg.Ops.BackupRetainOrDefault(), "remotes", len(cfg.Ops.BackupRemotes))
+		}
+		if cfg.Ops.Replication.Enabled && cfg.HistoryDB != "" {
+			if rep, err := newReplicator(cfg); err != nil {
+				log.Warn("replication disabled", "error", err)
+			} else {
+				app.Replication = rep
+				app.Leader.WhenActive(func() { rep.Start(ctx) })
+				log.Info("replication started", "target", rep.Status().Target, "interval", cfg.Ops.Replication.IntervalOrDefault())
+			}
+		}
+		if atRestKeyring() != nil {
+			// Uploads are sealed once the task that received them is done
+			// with them.
+			go func() {
+				ticker := time.NewTicker(10 * time.Minute)
+				defer ticker.Stop()
+				for {
+					sealUploads(cfg)
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
 		}
 
 		// Periodic cleanup (daily): uses retention config for all tables.
-		go func() {
-			ticker := time.NewTicker(24 * time.Hour)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					results := runRetention(cfg)
-					for _, r := range results {
-						if r.Error != "" {
-							log.Warn("retention cleanup error", "table", r.Table, "error", r.Error)
+		app.Leader.WhenActive(func() {
+			go func() {
+				ticker := time.NewTicker(24 * time.Hour)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						results := runRetention(cfg)
+						for _, r := range results {
+							if r.Error != "" {
+								log.Warn("retention cleanup error", "table", r.Table, "error", r.Error)
+							}
 						}
+						cleanupExpiredCallbacks(cfg.HistoryDB)
 					}
-					cleanupExpiredCallbacks(cfg.HistoryDB)
 				}
-			}
-		}()
+			}()
+		})
 
 		// Notification setup.
 		var bot *tgbot.Bot
@@ -602,8 +863,10 @@ func main() {
 			registerDailyNotesJob(ctx, cfg, cron)
 			// Register war room auto-updater job if enabled.
 			registerWarRoomAutoUpdateJob(ctx, cfg, cron)
-			cron.Start(ctx)
-			cron.StartupReplay(ctx)
+			app.Leader.WhenActive(func() {
+				cron.Start(ctx)
+				cron.StartupReplay(ctx)
+			})
 		}
 
 		// Startup disk check.
@@ -686,35 +949,39 @@ func main() {
 
 		// Start SLA monitor + budget alert goroutine.
 		if cfg.SLA.Enabled {
-			go func() {
-				ticker := time.NewTicker(30 * time.Second) // check eligibility every 30s
-				defer ticker.Stop()
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case <-ticker.C:
-						slaCheck.tick(ctx)
+			app.Leader.WhenActive(func() {
+				go func() {
+					ticker := time.NewTicker(30 * time.Second) // check eligibility every 30s
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+							slaCheck.tick(ctx)
+						}
 					}
-				}
-			}()
+				}()
+			})
 			log.Info("SLA monitor enabled", "interval", cfg.SLA.CheckIntervalOrDefault().String(), "window", cfg.SLA.WindowOrDefault().String())
 		}
 
 		// Start budget alert goroutine.
 		if cfg.Budgets.Global.Daily > 0 || cfg.Budgets.Global.Weekly > 0 || cfg.Budgets.Global.Monthly > 0 || len(cfg.Budgets.Agents) > 0 {
-			go func() {
-				ticker := time.NewTicker(5 * time.Minute) // check every 5m
-				defer ticker.Stop()
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case <-ticker.C:
-						cost.CheckAndNotifyBudgetAlerts(cfg.Budgets, cfg.HistoryDB, notifyFn, budgetTracker)
+			app.Leader.WhenActive(func() {
+				go func() {
+					ticker := time.NewTicker(5 * time.Minute) // check every 5m
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+							cost.CheckAndNotifyBudgetAlerts(cfg.Budgets, cfg.HistoryDB, notifyFn, budgetTracker)
+						}
 					}
-				}
-			}()
+				}()
+			})
 			log.Info("budget governance enabled",
 				"daily", cfg.Budgets.Global.Daily,
 				"weekly", cfg.Budgets.Global.Weekly,
@@ -724,20 +991,22 @@ func main() {
 		}
 
 		// Start zombie workflow janitor — resets stale running/resumed rows on a 30-min tick.
-		go func() {
-			ticker := time.NewTicker(30 * time.Minute)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					if count := resetZombieWorkflowRuns(cfg.HistoryDB, 4*time.Hour); count > 0 {
-						notifyFn(fmt.Sprintf("zombie janitor: terminated %d stale workflow(s)", count))
+		app.Leader.WhenActive(func() {
+			go func() {
+				ticker := time.NewTicker(30 * time.Minute)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						if count := resetZombieWorkflowRuns(cfg.HistoryDB, 4*time.Hour); count > 0 {
+							notifyFn(fmt.Sprintf("zombie janitor: terminated %d stale workflow(s)", count))
+						}
 					}
 				}
-			}
-		}()
+			}()
+		})
 
 		// Start offline queue drainer.
 		if cfg.OfflineQueue.Enabled {
@@ -749,10 +1018,38 @@ func main() {
 				notifyFn: notifyFn,
 				ttl:      cfg.OfflineQueue.TtlOrDefault(),
 			}
-			go drainer.run(ctx)
+			app.Leader.WhenActive(func() { go drainer.run(ctx) })
 			log.Info("offline queue enabled", "ttl", drainer.ttl.String(), "maxItems", cfg.OfflineQueue.MaxItemsOrDefault())
 		}
 
+		// Start the shared work queue: claim tasks enqueued by any node.
+		if cfg.WorkQueue.Enabled {
+			svc, err := newWorkQueueService(cfg, sem, childSem)
+			if err != nil {
+				log.Error("work queue disabled", "error", err)
+			} else {
+				history.SetNode(svc.Node())
+				if err := svc.Ping(ctx); err != nil {
+					log.Warn("work queue backend unreachable, will keep retrying", "addr", cfg.WorkQueue.AddrOrDefault(), "error", err)
+				}
+				app.WorkQueue = svc
+				app.Leader.WhenActive(func() { svc.Start(ctx) })
+				log.Info("work queue enabled", "node", svc.Node(), "concurrency", cfg.WorkQueue.ConcurrencyOrDefault(),
+					"lease", cfg.WorkQueue.LeaseOrDefault().String())
+			}
+		}
+
+		// Start deferred task drainer (agent schedule windows).
+		if cfg.HistoryDB != "" {
+			deferred := &deferredDrainer{
+				cfg:      cfg,
+				sem:      sem,
+				childSem: childSem,
+				notifyFn: notifyFn,
+			}
+			app.Leader.WhenActive(func() { go deferred.run(ctx) })
+		}
+
 		// Initialize Slack bot (uses HTTP push, no polling needed).
 		var slackBot *slackbot.Bot
 		if cfg.Slack.Enabled && cfg.Slack.BotToken != "" {
@@ -771,6 +1068,14 @@ func main() {
 			}
 		}
 
+		// Approval manager: routes spend approvals to the owner's channels.
+		app.Approvals = newApprovalManager()
+
+		// Response cache: agents and cron jobs opt in with responseCache.
+		if cfg.HistoryDB != "" {
+			app.ResponseCache = respcache.New(cfg.HistoryDB, cfg.ResponseCache.MaxEntriesOrDefault())
+		}
+
 		// Initialize Discord bot.
 		var discordBot *DiscordBot
 		if cfg.Discord.Enabled && cfg.Discord.BotToken != "" {
@@ -778,6 +1083,9 @@ func main() {
 			state.discordBot = discordBot       // P14.1: store for interaction handler
 			cfg.Runtime.DiscordBot = discordBot // provider approval routing
 			log.Info("discord bot enabled")
+			if discordBot.approvalGate != nil {
+				app.Approvals.RegisterGate("discord", discordBot.approvalGate)
+			}
 
 			// Wire Discord into notification chain.
 			prevNotifyFn2 := notifyFn
@@ -793,23 +1101,258 @@ func main() {
 		// Wire notifyFn into config for skill install scan notifications.
 		cfg.RuntimeNotifyFn = notifyFn
 
+		// Integration health: an integration whose tool calls keep failing
+		// is disabled for a while and the owner is told how to re-enable it.
+		if cfg.IntegrationHealth.EnabledOrDefault() {
+			app.IntegrationHealth = newIntegrationHealth(cfg, notifyFn)
+			globalIntegrationHealth = app.IntegrationHealth
+		}
+
+		// Handover desk: conversations handed to the owner; relayed via notifyFn.
+		if cfg.Handover.Enabled {
+			app.Handovers = handover.NewDesk(cfg.HistoryDB, cfg.Handover, notifyFn)
+			if discordBot != nil {
+				app.Handovers.RegisterSender("discord", func(chatID, text string) error {
+					_, err := discordBot.sendMessageReturningID(chatID, text)
+					return err
+				})
+			}
+			log.Info("handover desk enabled", "triggers", len(cfg.Handover.Triggers))
+		}
+
+		// FAQ: canned answers checked before smart dispatch; suggestions via notifyFn.
+		if cfg.FAQ.Enabled {
+			var embed faq.EmbedFunc
+			if cfg.Embedding.Enabled {
+				embed = func(ctx context.Context, text string) ([]float32, error) {
+					return getEmbedding(ctx, cfg, text)
+				}
+			}
+			app.FAQ = faq.New(cfg.HistoryDB, cfg.FAQ, embed, notifyFn)
+			log.Info("faq enabled", "entries", len(app.FAQ.List()), "semantic", cfg.FAQ.Semantic && embed != nil)
+		}
+
+		// Intent shortcuts: short utterances mapped straight to a skill or tool.
+		if cfg.Intents.Enabled {
+			app.Intents = newIntentService(cfg)
+			log.Info("intent shortcuts enabled", "shortcuts", len(app.Intents.List()), "learn", cfg.Intents.Learn)
+		}
+
+		// User profiles: per-chat identity, mood tracking and response modulation.
+		if cfg.UserProfile.Enabled {
+			app.UserProfiles = newProfileService(cfg)
+			log.Info("user profiles enabled", "sentiment", cfg.UserProfile.SentimentEnabled, "adaptPersonality", cfg.UserProfile.AdaptPersonality)
+		}
+
+		// Translation: glossary-aware translate tool and per-channel auto-translate.
+		if cfg.Translate.Enabled {
+			app.Translator = newTranslateService(cfg, sem, childSem)
+			log.Info("translation enabled", "provider", cfg.Translate.Provider, "glossary", len(app.Translator.Glossary()))
+		}
+
+		// Watchlist monitors: checked on schedule, changes summarized and sent
+		// to each monitor's notify targets ("owner" goes through notifyFn).
+		if cfg.Monitors.Enabled {
+			app.Monitors = newMonitorService(cfg, sem, childSem, notifyFn)
+			if discordBot != nil {
+				app.Monitors.RegisterSender("discord", func(channelID, text string) error {
+					_, err := discordBot.sendMessageReturningID(channelID, text)
+					return err
+				})
+			}
+			app.Monitors.RegisterSender("dashboard", func(_, text string) error {
+				state.broker.Publish(SSEDashboardKey, SSEEvent{Type: "monitor_change", Data: text})
+				return nil
+			})
+			app.Leader.WhenActive(func() { app.Monitors.Start(ctx) })
+			log.Info("monitors enabled", "targets", len(cfg.Monitors.Watch))
+		}
+
+		// Knowledge watch: changed files in knowledgeDir and the vault are
+		// reindexed one by one, and matching rules notify or run a workflow.
+		if cfg.KnowledgeWatch.Enabled {
+			if idx, err := knowledge.BuildIndex(cfg.KnowledgeDir); err != nil {
+				log.Warn("knowledge watch: initial index failed", "error", err)
+			} else {
+				globalKnowledgeIndex = idx
+			}
+			app.KnowledgeWatch = newKnowledgeWatchService(cfg, state, sem, childSem, notifyFn)
+			if discordBot != nil {
+				app.KnowledgeWatch.RegisterSender("discord", func(channelID, text string) error {
+					_, err := discordBot.sendMessageReturningID(channelID, text)
+					return err
+				})
+			}
+			app.Leader.WhenActive(func() { app.KnowledgeWatch.Start(ctx) })
+			log.Info("knowledge watch enabled", "rules", len(cfg.KnowledgeWatch.Rules))
+		}
+
+		// Data pipelines: fetch → transform → dispatch, run on their cron
+		// schedules or on demand through /api/pipelines.
+		if cfg.Pipelines.Enabled {
+			app.Pipelines = newPipelineService(cfg, sem, childSem, notifyFn)
+			if discordBot != nil {
+				app.Pipelines.RegisterSender("discord", func(channelID, text string) error {
+					_, err := discordBot.sendMessageReturningID(channelID, text)
+					return err
+				})
+			}
+			app.Pipelines.RegisterSender("dashboard", func(_, text string) error {
+				state.broker.Publish(SSEDashboardKey, SSEEvent{Type: "pipeline_result", Data: text})
+				return nil
+			})
+			app.Leader.WhenActive(func() { app.Pipelines.Start(ctx) })
+			log.Info("pipelines enabled")
+		}
+
+		// Memory staleness review: an agent re-validates old entries on
+		// schedule; changes to important ones wait for the owner.
+		if cfg.Memory.Review.Enabled && cfg.WorkspaceDir != "" {
+			app.MemoryReview = newMemoryReviewer(cfg, sem, childSem, notifyFn)
+			app.Leader.WhenActive(func() { app.MemoryReview.Start(ctx) })
+			log.Info("memory review enabled", "interval", cfg.Memory.Review.IntervalOrDefault().String())
+		}
+
+		// Twitter/X: scheduled drafts are posted when due (their approval was
+		// given when they were scheduled) and engagement stats refreshed hourly.
+		if cfg.Twitter.Enabled {
+			app.Twitter = newTwitterQueue(cfg, notifyFn)
+			app.Leader.WhenActive(func() { app.Twitter.Start(ctx) })
+			log.Info("twitter enabled", "postingTimes", strings.Join(cfg.Twitter.PostingTimesOrDefault(), ","))
+		}
+
+		// Bluesky/Mastodon: mentions of every account are polled into the inbox.
+		if len(cfg.Social.Accounts) > 0 {
+			app.Social = newSocialService(cfg, notifyFn)
+			app.Leader.WhenActive(func() { app.Social.Start(ctx) })
+			log.Info("social accounts enabled", "accounts", len(cfg.Social.Accounts), "poll", cfg.Social.PollIntervalOrDefault().String())
+		}
+
+		// Notes: the note_* tools, and with the notion backend daily notes,
+		// cron job notes and read-later highlights go to Notion pages.
+		if cfg.Notes.Enabled {
+			if svc, err := newNotesService(cfg); err != nil {
+				log.Warn("notes disabled", "error", err)
+			} else {
+				app.Notes = svc
+				log.Info("notes enabled", "backend", svc.Backend(), "vault", svc.VaultPath())
+			}
+		}
+
+		// Read-later: progress and highlights are synced on a schedule, and the
+		// weekly saved-vs-read insight goes to the owner.
+		if cfg.ReadLater.Enabled() {
+			if svc, err := newReadLaterService(cfg, notifyFn); err != nil {
+				log.Warn("read-later disabled", "error", err)
+			} else {
+				app.ReadLater = svc
+				app.Leader.WhenActive(func() { app.ReadLater.Start(ctx) })
+				log.Info("read-later enabled", "service", cfg.ReadLater.Service, "autoSave", cfg.ReadLater.AutoSave, "sync", cfg.ReadLater.SyncIntervalOrDefault().String())
+			}
+		}
+
+		// Inbound email: forwarded mail becomes tasks or reply suggestions.
+		// Mailgun posts to /api/mail/inbound; an IMAP mailbox is polled.
+		if cfg.MailIn.Enabled {
+			app.MailIn = newMailInService(cfg, app.FileManager, sem, childSem, notifyFn)
+			if cfg.MailIn.IMAP.Enabled() {
+				poller := mailin.NewPoller(cfg.MailIn.IMAP, app.MailIn)
+				app.Leader.WhenActive(func() { poller.Start(ctx) })
+			}
+			log.Info("inbound email enabled", "address", cfg.MailIn.Address, "imap", cfg.MailIn.IMAP.Enabled(), "mailgun", cfg.MailIn.Mailgun.SigningKey != "")
+		}
+
+		// Mailboxes agents read and send from with the email_* tools.
+		if cfg.Mail.Enabled {
+			app.Mail = newMailService(cfg)
+			log.Info("mail enabled", "accounts", len(cfg.Mail.Accounts))
+		}
+
+		// Code forges: the forge_* tools, and /api/forge/<account>/webhook
+		// deliveries become "<type>:<event>.<action>" events for triggers.
+		if accounts := cfg.ForgeAccounts(); len(accounts) > 0 {
+			app.Forges = forge.New(accounts)
+			for _, a := range accounts {
+				log.Info("forge enabled", "account", a.Name, "type", a.Type, "repos", len(a.Repos), "webhook", a.WebhookSecret != "")
+			}
+		}
+
+		// Task sync: taskboard tasks become Jira or Linear tickets, and
+		// title and status changes flow both ways on a schedule.
+		if cfg.TaskBoard.Enabled && cfg.TaskBoard.Sync.Enabled() {
+			if svc, err := newTaskSyncService(cfg); err != nil {
+				log.Warn("task sync disabled", "error", err)
+			} else {
+				app.TaskSync = svc
+				app.Leader.WhenActive(func() { app.TaskSync.Start(ctx) })
+				log.Info("task sync enabled", "provider", cfg.TaskBoard.Sync.Provider, "project", cfg.TaskBoard.Sync.Project, "interval", cfg.TaskBoard.Sync.IntervalOrDefault().String())
+			}
+		}
+
+		// State sync: memory and personal data are exchanged with the peer
+		// instance, sealed with the shared key. Without a peer this instance
+		// only answers the peer's exchanges on /api/sync.
+		if cfg.Sync.Enabled {
+			if svc, err := newSyncService(cfg); err != nil {
+				log.Warn("state sync disabled", "error", err)
+			} else {
+				app.Sync = svc
+				app.Leader.WhenActive(func() { app.Sync.Start(ctx) })
+				log.Info("state sync enabled", "peer", cfg.Sync.Peer, "direction", cfg.Sync.DirectionOrDefault(), "datasets", strings.Join(cfg.Sync.DatasetsOrDefault(), ","))
+			}
+		}
+
+		// Mirror: agents, prompts, skills and workflows are kept the same as
+		// on the peer instance. Without a peer this instance only answers the
+		// peer's syncs on /api/mirror/items. The mirror and GitOps signal
+		// filesReload when they write files the config is read from.
+		filesReload := make(chan struct{}, 1)
+		if cfg.Mirror.Enabled {
+			if svc, err := newMirrorService(cfg, filesReload); err != nil {
+				log.Warn("mirror disabled", "error", err)
+			} else {
+				app.Mirror = svc
+				app.Leader.WhenActive(func() { app.Mirror.Start(ctx) })
+				log.Info("mirror enabled", "peer", cfg.Mirror.Peer, "direction", cfg.Mirror.DirectionOrDefault(), "kinds", strings.Join(cfg.Mirror.KindsOrDefault(), ","))
+			}
+		}
+
+		// GitOps: config, souls, prompts, skills and workflows are reconciled
+		// from a git repo, polled and on POST /api/gitops/webhook.
+		if cfg.GitOps.Enabled {
+			if svc, err := newGitOpsService(cfg, filesReload); err != nil {
+				log.Warn("gitops disabled", "error", err)
+			} else {
+				app.GitOps = svc
+				app.Leader.WhenActive(func() { app.GitOps.Start(ctx) })
+				log.Info("gitops enabled", "repo", cfg.GitOps.Repo, "branch", cfg.GitOps.BranchOrDefault())
+			}
+		}
+
 		// Proactive engine — initialized after Discord so notifyFn includes Discord delivery.
 		var proactiveEngine *ProactiveEngine
 		if cfg.Proactive.Enabled {
 			proactiveEngine = newProactiveEngine(cfg, state.broker, sem, childSem, notifyFn)
-			proactiveEngine.Start(ctx)
+			app.Leader.WhenActive(func() { proactiveEngine.Start(ctx) })
 			log.Info("proactive engine started", "rules", len(cfg.Proactive.Rules))
 		}
 
-		// Start MCP host.
-		var mcpHost *MCPHost
-		if len(cfg.MCPServers) > 0 {
-			mcpHost = newMCPHost(cfg, cfg.Runtime.ToolRegistry.(*ToolRegistry))
-			if err := mcpHost.Start(ctx); err != nil {
-				log.Error("MCP host start failed: %v", err)
-			} else {
-				log.Info("MCP host started", "servers", len(cfg.MCPServers))
-			}
+		// MQTT: messages on subscribed topics become "mqtt:<topic>" events for
+		// workflow and proactive triggers; results and notifications can be
+		// published to topics.
+		if cfg.MQTT.Enabled {
+			app.MQTT = newMQTTService(cfg, state.broker, proactiveEngine)
+			app.Leader.WhenActive(func() { app.MQTT.Start(ctx) })
+			log.Info("mqtt enabled", "brokers", len(cfg.MQTT.Brokers))
+		}
+
+		// Start MCP host. It is created even without servers so that servers
+		// added later can be started by a config reload.
+		mcpHost := newMCPHost(cfg, cfg.Runtime.ToolRegistry.(*ToolRegistry))
+		if err := mcpHost.Start(ctx); err != nil {
+			log.Error("MCP host start failed: %v", err)
+		} else if len(cfg.MCPServers) > 0 {
+			log.Info("MCP host started", "servers", len(cfg.MCPServers))
 		}
 
 		// Initialize metrics registry.
@@ -824,6 +1367,8 @@ func main() {
 		metricsGlobal.RegisterGauge("tetora_session_active", "Active session count", []string{"role"})
 		metricsGlobal.RegisterGauge("tetora_queue_depth", "Offline queue depth", nil)
 		metricsGlobal.RegisterCounter("tetora_cron_runs_total", "Cron job executions", []string{"status"})
+		metricsGlobal.RegisterCounter("tetora_response_cache_total", "Response cache lookups", []string{"role", "result"})
+		metricsGlobal.RegisterCounter("tetora_response_cache_saved_usd", "Cost of responses served from the cache", []string{"role"})
 
 		// Initialize WhatsApp bot.
 		var whatsappBot *whatsapp.Bot
@@ -844,9 +1389,9 @@ func main() {
 		initAgentCommDB(cfg.HistoryDB)
 
 		// --- P13.1: Plugin System --- Initialize plugin host.
-		var pluginHost *PluginHost
+		// Always created so plugins added later can be started by a config reload.
+		pluginHost := NewPluginHost(cfg)
 		if len(cfg.Plugins) > 0 {
-			pluginHost = NewPluginHost(cfg)
 			pluginHost.AutoStart()
 			log.Info("plugin host initialized", "plugins", len(cfg.Plugins))
 		}
@@ -856,7 +1401,11 @@ func main() {
 			sm := sandbox.NewSandboxManager(cfg, pluginHost)
 			if sm.PluginName() != "" {
 				state.sandboxMgr = sm
-				log.Info("sandbox manager initialized", "plugin", sm.PluginName())
+				if rt, err := sm.Runtime(); err == nil && rt.Available {
+					log.Info("sandbox manager initialized", "plugin", sm.PluginName(), "runtime", rt.Runtime, "version", rt.Version, "rootless", rt.Rootless)
+				} else {
+					log.Info("sandbox manager initialized", "plugin", sm.PluginName())
+				}
 			}
 		}
 
@@ -890,7 +1439,7 @@ func main() {
 			rt := newMessagingRuntime(cfg, state, sem, childSem)
 			signalBot = signalbot.NewBot(cfg.Signal, rt)
 			if cfg.Signal.PollingMode {
-				signalBot.Start()
+				app.Leader.WhenActive(signalBot.Start)
 				log.Info("signal bot enabled (polling mode)", "interval", cfg.Signal.PollIntervalOrDefault())
 			} else {
 				log.Info("signal bot enabled", "endpoint", cfg.Signal.WebhookPathOrDefault())
@@ -914,7 +1463,7 @@ func main() {
 		// Always create engine (even if no triggers) so HTTP handlers can use it.
 		triggerEngine := newWorkflowTriggerEngine(cfg, state, sem, childSem, state.broker)
 		if len(cfg.WorkflowTriggers) > 0 {
-			triggerEngine.Start(ctx)
+			app.Leader.WhenActive(func() { triggerEngine.Start(ctx) })
 		}
 
 
@@ -953,11 +1502,13 @@ func main() {
 		// --- P21.6: Chrome Extension Relay ---
 		if cfg.BrowserRelay.Enabled {
 			app.Browser = newBrowserRelay(&cfg.BrowserRelay)
-			go func() {
-				if err := app.Browser.Start(ctx); err != nil && err != http.ErrServerClosed {
-					log.Warn("browser relay stopped", "error", err)
-				}
-			}()
+			app.Leader.WhenActive(func() {
+				go func() {
+					if err := app.Browser.Start(ctx); err != nil && err != http.ErrServerClosed {
+						log.Warn("browser relay stopped", "error", err)
+					}
+				}()
+			})
 			log.Info("browser relay enabled", "port", cfg.BrowserRelay.Port)
 		}
 
@@ -976,39 +1527,53 @@ func main() {
 		// Initialize callback manager for external workflow steps.
 		callbackMgr = newCallbackManager(cfg.HistoryDB)
 
+		// Load per-workspace configs (isolated agents and DBs under clients/<id>/).
+		app.Workspaces = newWorkspaceSet()
+		if len(cfg.Workspaces) > 0 {
+			app.Workspaces.replace(loadWorkspaceConfigs(*configPath, cfg))
+		}
+
 		// Backfill global vars from App for callers that haven't migrated yet.
 		app.SyncToGlobals()
 
+		// Create the Telegram bot before the HTTP server, which serves its webhook.
+		if cfg.Telegram.Enabled && cfg.Telegram.BotToken != "" {
+			bot = tgbot.NewBot(cfg.Telegram, newTelegramRuntime(cfg, state, sem, childSem, cron))
+		}
+
 		// HTTP server.
 		drainCh := make(chan struct{}, 1)
 		srvInstance := &Server{
 			cfg: cfg, app: app, state: state, sem: sem, childSem: childSem, dispatchMgr: dispatchMgr, cron: cron, secMon: secMon, mcpHost: mcpHost,
 			proactiveEngine: proactiveEngine, groupChatEngine: groupChatEngine, voiceEngine: voiceEngine,
-			slackBot: slackBot, whatsappBot: whatsappBot, pluginHost: pluginHost,
+			slackBot: slackBot, whatsappBot: whatsappBot, telegramBot: bot, pluginHost: pluginHost,
 			lineBot: lineBot, teamsBot: teamsBot, signalBot: signalBot, gchatBot: gchatBot, imessageBot: imessageBot, matrixBot: matrixBot,
 			heartbeatMonitor: heartbeatMon,
 			hookReceiver:     hookRecv,
 			triggerEngine:    triggerEngine,
 			DegradedServices: degradedServices,
 			drainCh:          drainCh,
+			configPath:       *configPath,
 		}
 		srv := startHTTPServer(srvInstance)
 
-		// Cleanup expired callbacks/human-gates BEFORE recovery so that recovery only
-		// picks up gates that are genuinely still within their timeout window.
-		cleanupExpiredCallbacks(cfg.HistoryDB)
+		app.Leader.WhenActive(func() {
+			// Cleanup expired callbacks/human-gates BEFORE recovery so that recovery only
+			// picks up gates that are genuinely still within their timeout window.
+			cleanupExpiredCallbacks(cfg.HistoryDB)
 
-		// Recover pending external step workflows (gates still within timeout window).
-		go recoverPendingWorkflows(cfg, state, sem, childSem)
+			// Recover pending external step workflows (gates still within timeout window).
+			go recoverPendingWorkflows(cfg, state, sem, childSem)
 
-		// Cleanup zombie sessions AFTER the HTTP server starts.
-		// Delayed so that if port binding fails (os.Exit in goroutine),
-		// the process dies before this runs, avoiding destructive cleanup
-		// during crash loops (launchd KeepAlive restart cycles).
-		go func() {
-			time.Sleep(2 * time.Second)
-			cleanupZombieSessions(cfg.HistoryDB)
-		}()
+			// Cleanup zombie sessions AFTER the HTTP server starts.
+			// Delayed so that if port binding fails (os.Exit in goroutine),
+			// the process dies before this runs, avoiding destructive cleanup
+			// during crash loops (launchd KeepAlive restart cycles).
+			go func() {
+				time.Sleep(2 * time.Second)
+				cleanupZombieSessions(cfg.HistoryDB)
+			}()
+		})
 
 		// Periodic cleanup of stale hook workers.
 		go func() {
@@ -1035,45 +1600,24 @@ func main() {
 		go func() {
 			for range sighupCh {
 				log.Info("received SIGHUP, reloading config")
-				newCfg, err := tryLoadConfig(*configPath)
-				if err != nil {
+				if _, err := srvInstance.reloadConfigFile(); err != nil {
 					log.Error("config reload failed", "error", err)
-					continue
-				}
-				// Preserve runtime-only field not set by tryLoadConfig.
-				srvInstance.cfgMu.RLock()
-				oldCfg := srvInstance.cfg
-				srvInstance.cfgMu.RUnlock()
-				newCfg.Runtime.ToolRegistry = oldCfg.Runtime.ToolRegistry
-
-				// Rebuild ProviderRegistry if providers config changed.
-				if providersChanged(oldCfg, newCfg) {
-					log.Info("providers config changed, rebuilding provider registry")
-					newReg := initProviders(newCfg)
-					newCfg.Runtime.ProviderRegistry = newReg
-				} else {
-					newCfg.Runtime.ProviderRegistry = oldCfg.Runtime.ProviderRegistry
 				}
-
-				// Log config diff.
-				logConfigDiff(oldCfg, newCfg)
-
-				// Atomic swap.
-				srvInstance.ReloadConfig(newCfg)
-
-				// Reload workflow triggers.
-				if srvInstance.triggerEngine != nil {
-					srvInstance.triggerEngine.ReloadTriggers(newCfg.WorkflowTriggers)
+			}
+		}()
+		// Config, agents and workflows written by the mirror or GitOps apply
+		// like a SIGHUP.
+		go func() {
+			for range filesReload {
+				log.Info("config files changed, reloading config")
+				if _, err := srvInstance.reloadConfigFile(); err != nil {
+					log.Error("config reload failed", "error", err)
 				}
-
-				log.Info("config reloaded successfully")
 			}
 		}()
 
 		// Start Telegram bot.
-		if cfg.Telegram.Enabled && cfg.Telegram.BotToken != "" {
-			tgrt := newTelegramRuntime(cfg, state, sem, childSem, cron)
-			bot = tgbot.NewBot(cfg.Telegram, tgrt)
+		if bot != nil {
 			// Wire up keyboard notification for approval gate.
 			cron.SetTelegramKeyboardFn(func(text string, keyboard any) {
 				if kb, ok := keyboard.([][]tgInlineButton); ok {
@@ -1084,19 +1628,53 @@ func main() {
 			if app.Presence != nil {
 				app.Presence.RegisterSetter("telegram", bot)
 			}
-			go bot.PollLoop(ctx)
+			if gate := bot.ApprovalGate(); gate != nil {
+				app.Approvals.RegisterGate("telegram", tgApprovalGateAdapter{gate: gate})
+			}
+			telegramSend := func(dest, text string) error {
+				chatID := bot.ChatID()
+				if dest != "" {
+					id, err := strconv.ParseInt(dest, 10, 64)
+					if err != nil {
+						return fmt.Errorf("invalid telegram chat id %q", dest)
+					}
+					chatID = id
+				}
+				bot.ReplyWithKeyboard(chatID, text, nil)
+				return nil
+			}
+			if app.Monitors != nil {
+				app.Monitors.RegisterSender("telegram", telegramSend)
+			}
+			if app.Pipelines != nil {
+				app.Pipelines.RegisterSender("telegram", telegramSend)
+			}
+			if app.KnowledgeWatch != nil {
+				app.KnowledgeWatch.RegisterSender("telegram", telegramSend)
+			}
+			app.Leader.WhenActive(func() { go bot.Run(ctx) })
 		} else {
 			log.Info("telegram disabled or no bot token, HTTP-only mode")
 		}
 
 		// Start Discord bot.
 		if discordBot != nil {
-			go discordBot.Run(ctx)
+			app.Leader.WhenActive(func() { go discordBot.Run(ctx) })
 		}
 
 		// --- P15.2: Matrix Channel --- Start Matrix bot.
 		if matrixBot != nil {
-			go matrixBot.Run(ctx)
+			app.Leader.WhenActive(func() { go matrixBot.Run(ctx) })
+		}
+
+		// A standby tells the owner when it takes over. Registered last, so
+		// it runs after every service above has started.
+		if !startedActive {
+			app.Leader.WhenActive(func() {
+				st := app.Leader.Status()
+				audit.Log(cfg.HistoryDB, "ha.promoted", "system", fmt.Sprintf("node=%s term=%d", st.Node, st.Term), "")
+				notifyFn(fmt.Sprintf("Tetora %s was standby and is now active (term %d): the previous active daemon stopped renewing its lease.", st.Node, st.Term))
+			})
 		}
 
 		log.Info("tetora ready", "healthz", fmt.Sprintf("http://%s/healthz", cfg.ListenAddr))
@@ -1105,10 +1683,16 @@ func main() {
 		// letting launchd/systemd restart the process.
 		startWatchdog(ctx, cfg.Watchdog, cfg.ListenAddr)
 
-		// Wait for shutdown signal or drain request.
+		// Wait for shutdown signal, drain request or loss of the HA lease.
+		lostLease := false
 		select {
 		case <-sigCh:
 			log.Info("shutting down")
+		case <-app.Leader.Lost():
+			// Another daemon holds the lease now: stop working at once and
+			// exit non-zero, so the supervisor restarts this one as standby.
+			log.Error("ha: lease lost, shutting down")
+			lostLease = true
 		case <-drainCh:
 			log.Info("drain requested: waiting for active agents to complete")
 			// Wait for all running tasks to finish (poll with ticker).
@@ -1178,11 +1762,20 @@ func main() {
 		// Stop cron scheduler (waits for running jobs up to 30s).
 		cron.Stop()
 
+		// Hand tasks claimed from the work queue back for other nodes.
+		if app.WorkQueue != nil && !app.WorkQueue.Wait(10*time.Second) {
+			log.Warn("work queue tasks still running at shutdown; their leases will expire")
+		}
+
 		// Stop MCP host.
 		if mcpHost != nil {
 			mcpHost.Stop()
 		}
 
+		// Release the HA lease so the standby takes over without waiting for
+		// it to go stale.
+		app.Leader.Resign()
+
 		// --- P13.2: Sandbox Plugin --- Destroy all active sandboxes.
 		if state.sandboxMgr != nil {
 			state.sandboxMgr.DestroyAll()
@@ -1217,7 +1810,13 @@ func main() {
 			return true
 		})
 
+		// Flush audit events still queued for external sinks.
+		closeAuditExport()
+
 		log.Info("tetora stopped")
+		if lostLease {
+			os.Exit(1)
+		}
 
 	} else {
 		// --- CLI mode ---
@@ -1378,18 +1977,49 @@ type App struct {
 
 	// Life services
 	FileManager *storage.Service
+	Calendar    *calendar.Service
 	Scheduling  *scheduling.Service
 
 	// Integration services
-	OAuth    *OAuthManager
-	Browser  *BrowserRelay
-	IMessage *imessagebot.Bot
+	OAuth     *OAuthManager
+	Browser   *BrowserRelay
+	IMessage  *imessagebot.Bot
+	Twitter   *twitter.Queue
+	Social    *social.Service
+	ReadLater *readlater.Service
+	Notes     *notes.Service
+	MailIn    *mailin.Service
+	Mail      *mail.Service
+	Sync      *statesync.Service
+	Mirror    *mirror.Service
+	GitOps    *gitops.Service
+	MQTT      *mqtt.Service
+	Forges    *forge.Service
+	TaskSync  *tasksync.Service
 
 	// Infrastructure
 	SpawnTracker        *spawnTracker
 	JudgeCache          *judgeCache
 	ImageGenLimiter     *tools.ImageGenLimiter
 	Presence            *presenceManager
+	Approvals           *ApprovalManager
+	Handovers           *handover.Desk
+	FAQ                 *faq.Service
+	Intents             *intent.Service
+	UserProfiles        *profile.Service
+	Translator          *translate.Service
+	Monitors            *monitor.Service
+	KnowledgeWatch      *docwatch.Service
+	Pipelines           *pipeline.Service
+	MemoryReview        *memory.Reviewer
+	WorkQueue           *workqueue.Service
+	Workspaces          *workspaceSet
+	Leader              *leader.Elector // nil (always active) unless ha.enabled or a replica
+	Replica             *replica.Follower
+	Replication         *walship.Replicator
+	ResponseCache       *respcache.Cache
+	Emergency           *emergency.Switch
+	IntegrationHealth   *health.Tracker
 }
 
 // SyncToGlobals sets all global singletons from App fields.
@@ -1397,6 +2027,9 @@ func (a *App) SyncToGlobals() {
 	if a.FileManager != nil {
 		globalFileManager = a.FileManager
 	}
+	if a.Calendar != nil {
+		globalCalendarService = a.Calendar
+	}
 	if a.Scheduling != nil {
 		globalSchedulingService = a.Scheduling
 	}
@@ -1409,18 +2042,99 @@ func (a *App) SyncToGlobals() {
 	if a.IMessage != nil {
 		globalIMessageBot = a.IMessage
 	}
+	if a.Twitter != nil {
+		globalTwitter = a.Twitter
+	}
+	if a.Social != nil {
+		globalSocial = a.Social
+	}
+	if a.ReadLater != nil {
+		globalReadLater = a.ReadLater
+	}
+	if a.Notes != nil {
+		globalNotes = a.Notes
+	}
+	if a.MailIn != nil {
+		globalMailIn = a.MailIn
+	}
+	if a.Mail != nil {
+		globalMailService = a.Mail
+	}
+	if a.Sync != nil {
+		globalSync = a.Sync
+	}
+	if a.Mirror != nil {
+		globalMirror = a.Mirror
+	}
+	if a.GitOps != nil {
+		globalGitOps = a.GitOps
+	}
+	if a.MQTT != nil {
+		globalMQTT = a.MQTT
+	}
+	if a.Forges != nil {
+		globalForges = a.Forges
+	}
+	if a.TaskSync != nil {
+		globalTaskSync = a.TaskSync
+	}
+	if a.Emergency != nil {
+		globalEmergency = a.Emergency
+	}
+	if a.IntegrationHealth != nil {
+		globalIntegrationHealth = a.IntegrationHealth
+	}
 	if a.SpawnTracker != nil {
 		globalSpawnTracker = a.SpawnTracker
 	}
 	if a.JudgeCache != nil {
 		globalJudgeCache = a.JudgeCache
 	}
+	if a.ResponseCache != nil {
+		globalResponseCache = a.ResponseCache
+	}
 	if a.ImageGenLimiter != nil {
 		globalImageGenLimiter = a.ImageGenLimiter
 	}
 	if a.Presence != nil {
 		globalPresence = a.Presence
 	}
+	if a.Approvals != nil {
+		globalApprovalManager = a.Approvals
+	}
+	if a.Handovers != nil {
+		globalHandoverDesk = a.Handovers
+	}
+	if a.FAQ != nil {
+		globalFAQ = a.FAQ
+	}
+	if a.Intents != nil {
+		globalIntents = a.Intents
+	}
+	if a.UserProfiles != nil {
+		globalUserProfiles = a.UserProfiles
+	}
+	if a.Translator != nil {
+		globalTranslator = a.Translator
+	}
+	if a.Monitors != nil {
+		globalMonitors = a.Monitors
+	}
+	if a.KnowledgeWatch != nil {
+		globalKnowledgeWatch = a.KnowledgeWatch
+	}
+	if a.Pipelines != nil {
+		globalPipelines = a.Pipelines
+	}
+	if a.MemoryReview != nil {
+		globalMemoryReviewer = a.MemoryReview
+	}
+	if a.WorkQueue != nil {
+		globalWorkQueue = a.WorkQueue
+	}
+	if a.Workspaces != nil {
+		globalWorkspaces = a.Workspaces
+	}
 }
 
 // Server holds all dependencies for the HTTP server.
@@ -1438,6 +2152,7 @@ type Server struct {
 	voiceEngine     *VoiceEngine
 	slackBot        *slackbot.Bot
 	whatsappBot     *whatsapp.Bot
+	telegramBot     *tgbot.Bot
 	pluginHost      *PluginHost
 	lineBot         *linebot.Bot
 	teamsBot        *teamsbot.Bot
@@ -1458,9 +2173,13 @@ type Server struct {
 	startTime           time.Time
 	limiter             *loginLimiter
 	apiLimiter          *apiRateLimiter
+	sso                 *oidc.Manager // dashboard SSO; nil unless dashboardAuth.oidc.enabled
+	tlsServer           *mtls.Server  // certificates and client CAs; nil without TLS
 
 	// Config hot-reload support
-	cfgMu sync.RWMutex
+	cfgMu      sync.RWMutex
+	reloadMu   sync.Mutex // serializes reloadConfigFile
+	configPath string
 
 	// DegradedServices tracks services that failed to initialize.
 	DegradedServices []string
@@ -1479,30 +2198,321 @@ func (s *Server) Cfg() *Config {
 // ReloadConfig atomically swaps the config pointer.
 func (s *Server) ReloadConfig(newCfg *Config) {
 	s.cfgMu.Lock()
-	defer s.cfgMu.Unlock()
 	s.cfg = newCfg
+	installRedaction(newCfg)
+	s.cfgMu.Unlock()
+	// Restarting audit sinks waits for them to flush; keep it out of the lock.
+	installAuditExport(newCfg)
+}
+
+// configReloadResult reports what a config reload changed in the plugin and
+// MCP hosts.
+type configReloadResult struct {
+	Plugins PluginReloadResult `json:"plugins"`
+	MCP     MCPReloadResult    `json:"mcp"`
+}
+
+// reloadConfigFile re-reads the config file and applies it to the running
+// daemon: the config swap, route table, plugin and MCP processes, workspaces
+// and workflow triggers. Used by SIGHUP and POST /api/plugins/reload.
+func (s *Server) reloadConfigFile() (*configReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	newCfg, err := tryLoadConfig(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	// Preserve runtime-only field not set by tryLoadConfig.
+	s.cfgMu.RLock()
+	oldCfg := s.cfg
+	s.cfgMu.RUnlock()
+	newCfg.Runtime.ToolRegistry = oldCfg.Runtime.ToolRegistry
+
+	// Rebuild ProviderRegistry if providers config changed.
+	if providersChanged(oldCfg, newCfg) {
+		log.Info("providers config changed, rebuilding provider registry")
+		newReg := initProviders(newCfg)
+		newCfg.Runtime.ProviderRegistry = newReg
+	} else {
+		newCfg.Runtime.ProviderRegistry = oldCfg.Runtime.ProviderRegistry
+	}
+
+	// Log config diff.
+	logConfigDiff(oldCfg, newCfg)
+
+	// Atomic swap.
+	s.ReloadConfig(newCfg)
+	setRouteTable(newCfg)
+	snapshotEffectiveConfig(newCfg, "reload")
+
+	res := &configReloadResult{}
+	if s.pluginHost != nil {
+		res.Plugins = s.pluginHost.Reload(newCfg)
+	}
+	if s.mcpHost != nil {
+		res.MCP = s.mcpHost.Reload(newCfg)
+	}
+	s.app.Workspaces.replace(loadWorkspaceConfigs(s.configPath, newCfg))
+
+	// Reload workflow triggers.
+	if s.triggerEngine != nil {
+		s.triggerEngine.ReloadTriggers(newCfg.WorkflowTriggers)
+	}
+	if s.app.Monitors != nil {
+		s.app.Monitors.SetConfig(newCfg)
+	}
+	if s.app.KnowledgeWatch != nil {
+		s.app.KnowledgeWatch.SetConfig(newCfg)
+	}
+	if s.app.Pipelines != nil {
+		s.app.Pipelines.SetConfig(newCfg)
+	}
+	if s.app.MemoryReview != nil {
+		s.app.MemoryReview.SetConfig(newCfg)
+	}
+	// Rotate certificates and client CAs. Turning TLS on or off needs a restart.
+	if s.tlsServer != nil && newCfg.TLSEnabled {
+		if err := s.tlsServer.Reload(newCfg.TLS); err != nil {
+			log.Error("tls reload failed, keeping current certificates", "error", err)
+		} else {
+			log.Info("tls certificates reloaded", "mutualTLS", s.tlsServer.Mutual())
+		}
+	}
+	// Trusted proxies apply at once; a new http.basePath needs a restart.
+	if err := httpapi.SetTrustedProxies(newCfg.HTTP.TrustedProxies); err != nil {
+		log.Warn("http.trustedProxies", "error", err)
+	}
+	if newCfg.HTTP.Prefix() != oldCfg.HTTP.Prefix() {
+		log.Warn("http.basePath changed; restart to apply", "basePath", newCfg.HTTP.Prefix())
+	}
+
+	log.Info("config reloaded successfully")
+	return res, nil
+}
+
+// resolveClientDispatch returns the dispatch state and semaphores for a given client ID.
+// If the server has a dispatchManager, it resolves per-client; otherwise falls back to default.
+func (s *Server) resolveClientDispatch(clientID string) (*dispatchState, chan struct{}, chan struct{}) {
+	if s.dispatchMgr != nil {
+		return s.dispatchMgr.getOrCreate(clientID)
+	}
+	return s.state, s.sem, s.childSem
+}
+
+// cronForClient returns the cron engine whose jobs a client may list and
+// cancel. Cron jobs belong to the main config, so only the default client
+// sees them; workspace clients get nil.
+func (s *Server) cronForClient(clientID string) *CronEngine {
+	if clientID != s.Cfg().DefaultClientID {
+		return nil
+	}
+	return s.cron
+}
+
+// resolveHistoryDB returns the history DB path for a given client ID.
+// For the default client, returns cfg.HistoryDB (existing behavior).
+// For other clients, returns the per-client DB path and ensures the directory exists.
+func (s *Server) resolveHistoryDB(cfg *Config, clientID string) string {
+	if clientID == cfg.DefaultClientID {
+		return cfg.HistoryDB
+	}
+	dbPath := cfg.HistoryDBFor(clientID)
+	// Ensure parent directory exists for new clients.
+	os.MkdirAll(filepath.Dir(dbPath), 0o755)
+	return dbPath
+}
+
+// --- Instance Lock ---
+
+// takeoverTimeout bounds how long --takeover waits for the previous daemon;
+// it covers that daemon's own 10-minute drain deadline.
+const takeoverTimeout = 11 * time.Minute
+
+// acquireInstanceLock takes the base-dir lock. With takeover set, a running
+// holder is asked to drain via its API and the lock is retried until it exits.
+func acquireInstanceLock(cfg *Config, takeover bool) (*instancelock.Lock, error) {
+	info := instancelock.Info{ListenAddr: cfg.ListenAddr}
+	lock, stale, err := instancelock.Acquire(cfg.BaseDir, info)
+
+	var held *instancelock.HeldError
+	if errors.As(err, &held) && takeover {
+		addr := held.Holder.ListenAddr
+		if addr == "" {
+			addr = cfg.ListenAddr
+		}
+		log.Info("takeover: requesting drain of running daemon", "pid", held.Holder.PID, "addr", addr)
+		if derr := requestDrain(addr, cfg.APIToken); derr != nil {
+			return nil, fmt.Errorf("takeover: %w", derr)
+		}
+		deadline := time.Now().Add(takeoverTimeout)
+		for errors.As(err, &held) && time.Now().Before(deadline) {
+			time.Sleep(time.Second)
+			lock, stale, err = instancelock.Acquire(cfg.BaseDir, info)
+		}
+		if err == nil {
+			log.Info("takeover: previous daemon exited, lock acquired", "pid", held.Holder.PID)
+		}
+	}
+	if errors.As(err, &held) {
+		return nil, fmt.Errorf("%w; stop it with `tetora stop` or start with --takeover to drain it and take over", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stale != nil {
+		log.Warn("replaced stale instance lock", "pid", stale.PID, "startedAt", stale.StartedAt)
+	}
+	return lock, nil
+}
+
+// requestDrain asks the daemon at addr to stop accepting work and exit.
+func requestDrain(addr, token string) error {
+	api := cli.NewAPIClient(addr, token)
+	resp, err := api.Post("/api/admin/drain", "")
+	if err != nil {
+		return fmt.Errorf("cannot reach running daemon at %s: %w", addr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("drain request rejected (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// cfgForClient returns the config a client's requests run under: the
+// workspace config for workspace clients, the main config otherwise.
+func (s *Server) cfgForClient(clientID string) *Config {
+	if s.app != nil {
+		if wsCfg := s.app.Workspaces.get(clientID); wsCfg != nil {
+			return wsCfg
+		}
+	}
+	return s.Cfg()
+}
+
+// --- Workspaces ---
+
+// globalWorkspaces is the package-level workspace set, initialized in daemon mode.
+var globalWorkspaces *workspaceSet
+
+// workspaceSet holds the effective config of each named workspace, keyed by
+// client ID. It is swapped wholesale on config reload.
+type workspaceSet struct {
+	mu   sync.RWMutex
+	cfgs map[string]*Config
+}
+
+func newWorkspaceSet() *workspaceSet {
+	return &workspaceSet{cfgs: make(map[string]*Config)}
+}
+
+// get returns the config for a workspace client, or nil if clientID is not a workspace.
+func (ws *workspaceSet) get(clientID string) *Config {
+	if ws == nil {
+		return nil
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.cfgs[clientID]
+}
+
+func (ws *workspaceSet) replace(cfgs map[string]*Config) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.cfgs = cfgs
+}
+
+// channelWorkspace returns the config and client ID for a channel bound to a
+// workspace. Unbound channels get base and an empty client ID.
+func channelWorkspace(base *Config, platform, channelID string) (*Config, string) {
+	name, ok := base.WorkspaceByChannel(platform, channelID)
+	if !ok {
+		return base, ""
+	}
+	clientID := base.WorkspaceClientID(name)
+	if wsCfg := globalWorkspaces.get(clientID); wsCfg != nil {
+		return wsCfg, clientID
+	}
+	return base, ""
+}
+
+// loadWorkspaceConfigs builds the config of every workspace declared in base.
+// A workspace that fails to load is logged and skipped so the others keep serving.
+func loadWorkspaceConfigs(path string, base *Config) map[string]*Config {
+	out := make(map[string]*Config, len(base.Workspaces))
+	for name := range base.Workspaces {
+		clientID := base.WorkspaceClientID(name)
+		if !isValidClientID(clientID) {
+			log.Warn("workspace has invalid client id, skipping", "workspace", name, "clientId", clientID)
+			continue
+		}
+		wsCfg, err := loadWorkspaceConfig(path, base, clientID)
+		if err != nil {
+			log.Warn("workspace config load failed", "workspace", name, "error", err)
+			continue
+		}
+		out[clientID] = wsCfg
+		log.Info("workspace loaded", "workspace", name, "clientId", clientID, "agents", len(wsCfg.Agents))
+	}
+	return out
 }
 
-// resolveClientDispatch returns the dispatch state and semaphores for a given client ID.
-// If the server has a dispatchManager, it resolves per-client; otherwise falls back to default.
-func (s *Server) resolveClientDispatch(clientID string) (*dispatchState, chan struct{}, chan struct{}) {
-	if s.dispatchMgr != nil {
-		return s.dispatchMgr.getOrCreate(clientID)
+// loadWorkspaceConfig layers a workspace's config.json over the main config
+// and moves its data (history DB, agents, workspace, knowledge) under the
+// workspace's client directory. Agents come from the overlay alone rather
+// than merging with the main agents, so roles never leak across workspaces.
+func loadWorkspaceConfig(path string, base *Config, clientID string) (*Config, error) {
+	overlayPath := base.WorkspaceConfigPath(clientID)
+	cfg, err := tryLoadConfigOverlay(path, overlayPath)
+	if err != nil {
+		return nil, err
+	}
+	var overlay Config
+	if data, err := os.ReadFile(overlayPath); err == nil {
+		if err := json.Unmarshal(data, &overlay); err != nil {
+			return nil, fmt.Errorf("parse workspace config: %w", err)
+		}
+	}
+	cfg.Agents = overlay.Agents
+	if _, ok := cfg.Agents[cfg.SmartDispatch.Coordinator]; !ok {
+		cfg.SmartDispatch.Coordinator = ""
+	}
+	if _, ok := cfg.Agents[cfg.SmartDispatch.DefaultAgent]; !ok {
+		cfg.SmartDispatch.DefaultAgent = ""
+	}
+	for k := range cfg.Agents {
+		if cfg.SmartDispatch.Coordinator == "" {
+			cfg.SmartDispatch.Coordinator = k
+		}
+		if cfg.SmartDispatch.DefaultAgent == "" {
+			cfg.SmartDispatch.DefaultAgent = k
+		}
+		break
 	}
-	return s.state, s.sem, s.childSem
-}
 
-// resolveHistoryDB returns the history DB path for a given client ID.
-// For the default client, returns cfg.HistoryDB (existing behavior).
-// For other clients, returns the per-client DB path and ensures the directory exists.
-func (s *Server) resolveHistoryDB(cfg *Config, clientID string) string {
-	if clientID == cfg.DefaultClientID {
-		return cfg.HistoryDB
+	dir := base.ClientDir(clientID)
+	cfg.ClientID = clientID
+	cfg.ClientsDir = base.ClientsDir
+	cfg.DefaultClientID = base.DefaultClientID
+	cfg.Workspaces = nil
+	cfg.HistoryDB = base.HistoryDBFor(clientID)
+	cfg.AgentsDir = filepath.Join(dir, "agents")
+	cfg.WorkspaceDir = filepath.Join(dir, "workspace")
+	cfg.KnowledgeDir = filepath.Join(dir, "knowledge")
+	if err := os.MkdirAll(filepath.Dir(cfg.HistoryDB), 0o755); err != nil {
+		return nil, fmt.Errorf("create workspace dir: %w", err)
 	}
-	dbPath := cfg.HistoryDBFor(clientID)
-	// Ensure parent directory exists for new clients.
-	os.MkdirAll(filepath.Dir(dbPath), 0o755)
-	return dbPath
+	if err := history.InitDB(cfg.HistoryDB); err != nil {
+		return nil, fmt.Errorf("init workspace history db: %w", err)
+	}
+
+	cfg.Runtime.ToolRegistry = base.Runtime.ToolRegistry
+	cfg.Runtime.SlotPressureGuard = base.Runtime.SlotPressureGuard
+	cfg.Runtime.DiscordBot = base.Runtime.DiscordBot
+	cfg.Runtime.HookRecv = base.Runtime.HookRecv
+	return cfg, nil
 }
 
 // --- from config.go ---
@@ -1534,7 +2544,11 @@ type AgentConfig = config.AgentConfig
 type ProviderConfig = config.ProviderConfig
 type CostAlertConfig = config.CostAlertConfig
 type DashboardAuthConfig = config.DashboardAuthConfig
+type OIDCConfig = config.OIDCConfig
+type DashboardAccount = config.DashboardAccount
+type DashboardTOTPConfig = config.DashboardTOTPConfig
 type QuietHoursConfig = config.QuietHoursConfig
+type QuietWindow = config.QuietWindow
 type DigestConfig = config.DigestConfig
 type NotificationChannel = config.NotificationChannel
 type RateLimitConfig = config.RateLimitConfig
@@ -1543,6 +2557,8 @@ type SecurityAlertConfig = config.SecurityAlertConfig
 type SmartDispatchConfig = config.SmartDispatchConfig
 type RoutingRule = config.RoutingRule
 type RoutingBinding = config.RoutingBinding
+type RoutingConfig = config.RoutingConfig
+type RouteRule = config.RouteRule
 type EstimateConfig = config.EstimateConfig
 type ToolConfig = config.ToolConfig
 type WebSearchConfig = config.WebSearchConfig
@@ -1582,6 +2598,9 @@ type NotesConfig = config.NotesConfig
 
 // Other type aliases from internal/config.
 type AgentToolPolicy = config.AgentToolPolicy
+type AgentScheduleConfig = config.AgentScheduleConfig
+type AgentScheduleWindow = config.AgentScheduleWindow
+type TenantConfig = config.TenantConfig
 type CompactionConfig = config.CompactionConfig
 type ToolProfile = config.ToolProfile
 type WorkspaceConfig = config.WorkspaceConfig
@@ -1607,9 +2626,11 @@ type ReflectionConfig = config.ReflectionConfig
 type DeepMemoryExtractConfig = config.DeepMemoryExtractConfig
 type NotifyIntelConfig = config.NotifyIntelConfig
 type IncomingWebhookConfig = config.IncomingWebhookConfig
+type IncomingWebhookResponse = config.IncomingWebhookResponse
 type RetentionConfig = config.RetentionConfig
 type AccessControlConfig = config.AccessControlConfig
 type SlotPressureConfig = config.SlotPressureConfig
+type TaskPriorityConfig = config.TaskPriorityConfig
 type CanvasConfig = config.CanvasConfig
 type DailyNotesConfig = config.DailyNotesConfig
 type UsageConfig = config.UsageConfig
@@ -1653,40 +2674,35 @@ type VoiceWakeConfig = config.VoiceWakeConfig
 type VoiceRealtimeConfig = config.VoiceRealtimeConfig
 
 // deepMergeJSON merges two JSON documents. Values in override take precedence.
-// Objects are merged recursively (key-level); all other types are replaced.
 func deepMergeJSON(baseJSON, overrideJSON []byte) ([]byte, error) {
-	var base, override map[string]any
-	if err := json.Unmarshal(baseJSON, &base); err != nil {
-		return nil, fmt.Errorf("unmarshal base: %w", err)
-	}
-	if err := json.Unmarshal(overrideJSON, &override); err != nil {
-		return nil, fmt.Errorf("unmarshal override: %w", err)
-	}
-	deepMergeMaps(base, override)
-	return json.Marshal(base)
+	return config.MergeJSON(baseJSON, overrideJSON)
 }
 
-// deepMergeMaps recursively merges src into dst. src values win; nested maps
-// are merged at key level rather than replaced wholesale.
-func deepMergeMaps(dst, src map[string]any) {
-	for k, srcVal := range src {
-		dstVal, exists := dst[k]
-		if !exists {
-			dst[k] = srcVal
-			continue
-		}
-		srcMap, srcOK := srcVal.(map[string]any)
-		dstMap, dstOK := dstVal.(map[string]any)
-		if srcOK && dstOK {
-			deepMergeMaps(dstMap, srcMap)
-		} else {
-			dst[k] = srcVal
+// --- Config Loading ---
+
+// applyProfileFlag removes "--profile <name>" (or "--profile=<name>") from
+// args and exports the name as TETORA_PROFILE. Arguments after "--" are left
+// alone.
+func applyProfileFlag(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			return append(out, args[i:]...)
+		case (a == "--profile" || a == "-profile") && i+1 < len(args):
+			os.Setenv(config.ProfileEnv, args[i+1])
+			i++
+		case strings.HasPrefix(a, "--profile=") || strings.HasPrefix(a, "-profile="):
+			_, name, _ := strings.Cut(a, "=")
+			os.Setenv(config.ProfileEnv, name)
+		default:
+			out = append(out, a)
 		}
 	}
+	return out
 }
 
-// --- Config Loading ---
-
 func loadConfig(path string) *Config {
 	cfg, err := tryLoadConfig(path)
 	if err != nil {
@@ -1700,16 +2716,25 @@ func loadConfig(path string) *Config {
 // of calling os.Exit. Used by SIGHUP hot-reload so a bad config doesn't kill
 // the daemon.
 func tryLoadConfig(path string) (*Config, error) {
+	return tryLoadConfigOverlay(path, "")
+}
+
+// tryLoadConfigOverlay is tryLoadConfig with an extra config file deep-merged
+// on top of the config file and its .local override. A missing overlay is
+// ignored.
+func tryLoadConfigOverlay(path, overlayPath string) (*Config, error) {
 	if path == "" {
 		// Binary at ~/.tetora/bin/tetora → config at ~/.tetora/config.json
+		// (or config.yaml / config.toml).
 		if exe, err := os.Executable(); err == nil {
-			candidate := filepath.Join(filepath.Dir(exe), "..", "config.json")
-			if abs, err := filepath.Abs(candidate); err == nil {
-				candidate = abs
-			}
-			if _, err := os.Stat(candidate); err == nil {
-				path = candidate
+			dir := filepath.Join(filepath.Dir(exe), "..")
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
 			}
+			path = cfgfile.Find(dir, "config")
+		}
+		if path == "" {
+			path = cfgfile.Find(".", "config")
 		}
 		if path == "" {
 			path = "config.json"
@@ -1719,31 +2744,68 @@ func tryLoadConfig(path string) (*Config, error) {
 	// Auto-migrate config if version is outdated.
 	migrate.AutoMigrateConfig(path)
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("read config: %w", err)
+	// Load .env first so ${VAR} references in the config files resolve.
+	if err := config.LoadDotEnv(filepath.Join(filepath.Dir(path), ".env")); err != nil {
+		log.Warn("failed to load .env file", "error", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	data, err := readConfigDoc(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	var cfg Config
+	json.Unmarshal(data, &cfg)
 
-	// Load local config override (config.local.json) and deep merge.
-	localPath := strings.TrimSuffix(path, ".json") + ".local.json"
-	if localData, err := os.ReadFile(localPath); err == nil {
-		merged, mergeErr := deepMergeJSON(data, localData)
+	// Deep-merge the overlays: config.d/*, the profile's config.d/<profile>/*
+	// and finally config.local.json.
+	profile := config.ActiveProfile()
+	layers, err := config.Layers(path, profile)
+	if err != nil {
+		return nil, fmt.Errorf("config overlays: %w", err)
+	}
+	for _, layerPath := range layers {
+		layerData, err := readConfigDoc(layerPath)
+		if err != nil {
+			return nil, fmt.Errorf("parse config overlay: %w", err)
+		}
+		merged, mergeErr := deepMergeJSON(data, layerData)
 		if mergeErr != nil {
-			return nil, fmt.Errorf("merge local config: %w", mergeErr)
+			return nil, fmt.Errorf("merge config overlay %s: %w", filepath.Base(layerPath), mergeErr)
 		}
 		cfg = Config{} // reset before re-unmarshal
 		if err := json.Unmarshal(merged, &cfg); err != nil {
 			return nil, fmt.Errorf("parse merged config: %w", err)
 		}
-		log.Info("loaded local config override", "path", localPath)
+		log.Info("loaded config overlay", "path", layerPath)
+		data = merged
+	}
+
+	// Load workspace overlay and deep merge.
+	if overlayPath != "" {
+		if _, err := os.Stat(overlayPath); err == nil {
+			overlayData, err := readConfigDoc(overlayPath)
+			if err != nil {
+				return nil, fmt.Errorf("parse workspace config: %w", err)
+			}
+			merged, mergeErr := deepMergeJSON(data, overlayData)
+			if mergeErr != nil {
+				return nil, fmt.Errorf("merge workspace config: %w", mergeErr)
+			}
+			cfg = Config{}
+			if err := json.Unmarshal(merged, &cfg); err != nil {
+				return nil, fmt.Errorf("parse workspace config: %w", err)
+			}
+		}
 	}
 
 	cfg.BaseDir = filepath.Dir(path)
+	cfg.ConfigPath = path
+	cfg.Profile = profile
+	cfg.ConfigLayers = layers
 
 	// Defaults.
 	if cfg.MaxConcurrent <= 0 {
@@ -1893,12 +2955,10 @@ func tryLoadConfig(path string) (*Config, error) {
 	if cfg.TLS.KeyFile != "" && !filepath.IsAbs(cfg.TLS.KeyFile) {
 		cfg.TLS.KeyFile = filepath.Join(cfg.BaseDir, cfg.TLS.KeyFile)
 	}
-	cfg.TLSEnabled = cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
-
-	// Load .env file before resolving secrets so $ENV_VAR references work.
-	if err := config.LoadDotEnv(filepath.Join(cfg.BaseDir, ".env")); err != nil {
-		log.Warn("failed to load .env file", "error", err)
+	if cfg.TLS.ClientCAFile != "" && !filepath.IsAbs(cfg.TLS.ClientCAFile) {
+		cfg.TLS.ClientCAFile = filepath.Join(cfg.BaseDir, cfg.TLS.ClientCAFile)
 	}
+	cfg.TLSEnabled = cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
 
 	// Resolve $ENV_VAR references in secret fields.
 	config.ResolveSecrets(&cfg)
@@ -1923,6 +2983,64 @@ func tryLoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// readConfigDoc reads a config document in any supported format as JSON,
+// with ${VAR} references expanded, and lints it. Warnings are logged; if the
+// document does not parse or decode, its lint errors, which give the line
+// and the field, are returned.
+func readConfigDoc(path string) ([]byte, error) {
+	data, raw, err := config.ReadDoc(path)
+	if raw == nil {
+		return nil, err
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &Config{})
+	}
+	if err := lintConfigDoc(path, raw, err); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// snapshotEffectiveConfig records the merged config the daemon runs with as
+// an effective config version, so overlay and profile changes show up in
+// config history. Without overlays the config file's own versions cover it.
+func snapshotEffectiveConfig(cfg *Config, reason string) {
+	if len(cfg.ConfigLayers) == 0 || cfg.HistoryDB == "" {
+		return
+	}
+	data, _, err := config.ReadEffective(cfg.ConfigFile(), cfg.Profile, false)
+	if err != nil {
+		log.Warn("effective config snapshot failed", "error", err)
+		return
+	}
+	if err := version.SnapshotEffectiveConfig(cfg.HistoryDB, cfg.Profile, string(data), "system", reason); err != nil {
+		log.Warn("effective config snapshot failed", "error", err)
+	}
+}
+
+// lintConfigDoc logs the warnings config.LintDoc finds in a config document,
+// such as misspelled keys that would otherwise be silently ignored. If the
+// document failed to parse, its lint errors are returned in place of
+// parseErr.
+func lintConfigDoc(path string, data []byte, parseErr error) error {
+	var errs []string
+	for _, i := range config.LintDoc(data, cfgfile.FormatOf(path)) {
+		if i.Level == "error" {
+			i.File = filepath.Base(path)
+			errs = append(errs, i.String())
+			continue
+		}
+		log.Warn("config: "+i.Message, "file", path, "line", i.Line, "key", i.Path)
+	}
+	if parseErr == nil {
+		return nil
+	}
+	if len(errs) == 0 {
+		return parseErr
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
 // validateConfig checks config values and logs warnings for common mistakes.
 func validateConfig(cfg *Config) {
 	// Check claude binary exists.
@@ -1956,6 +3074,75 @@ func validateConfig(cfg *Config) {
 		log.Warn("maxConcurrent is very high, claude sessions are resource-intensive", "maxConcurrent", cfg.MaxConcurrent)
 	}
 
+	// A replica cannot also take part in an active/standby pair.
+	if cfg.Replica.Enabled() && cfg.HA.Enabled {
+		log.Warn("replica.primary is ignored while ha.enabled is set", "primary", cfg.Replica.Primary)
+	} else if cfg.Replica.Enabled() && cfg.HistoryDB == "" {
+		log.Warn("replica.primary needs a historyDB to copy the primary into")
+	}
+
+	// ssh_exec hosts need key auth and an allowlist to be usable.
+	for name, h := range cfg.SSH.Hosts {
+		switch {
+		case h.Host == "" || h.User == "" || h.KeyFile == "":
+			log.Warn("ssh host needs host, user and keyFile", "host", name)
+		case len(h.Allow) == 0:
+			log.Warn("ssh host allows no commands", "host", name)
+		}
+		if t := h.TrustOrDefault(); t != "observe" && t != "suggest" && t != "auto" {
+			log.Warn("ssh host trust must be observe, suggest or auto", "host", name, "trust", h.Trust)
+		}
+	}
+
+	// k8s tools shell out to kubectl with each cluster's kubeconfig.
+	if len(cfg.Kubernetes.Clusters) > 0 {
+		if _, err := exec.LookPath(cfg.Kubernetes.KubectlOrDefault()); err != nil {
+			log.Warn("kubernetes clusters configured but kubectl not found", "kubectl", cfg.Kubernetes.KubectlOrDefault())
+		}
+	}
+	for name, c := range cfg.Kubernetes.Clusters {
+		if c.Kubeconfig != "" && !strings.HasPrefix(c.Kubeconfig, "~") {
+			if _, err := os.Stat(c.Kubeconfig); err != nil {
+				log.Warn("kubernetes cluster kubeconfig not found", "cluster", name, "path", c.Kubeconfig)
+			}
+		}
+	}
+
+	// db_query connections need a known driver and somewhere to connect.
+	for name, c := range cfg.Databases.Connections {
+		switch {
+		case c.Driver != "sqlite" && c.Driver != "postgres" && c.Driver != "mysql":
+			log.Warn("database connection driver must be sqlite, postgres or mysql", "connection", name, "driver", c.Driver)
+		case c.Driver == "sqlite" && c.DSN == "":
+			log.Warn("sqlite database connection needs dsn (the database file)", "connection", name)
+		case c.Driver == "mysql" && c.Host == "":
+			log.Warn("mysql database connection needs host", "connection", name)
+		}
+	}
+
+	// A/B experiments need distinct variant names and readable soul files.
+	for name, ac := range cfg.Agents {
+		if ac.Experiment == nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, v := range ac.Experiment.Variants {
+			switch {
+			case v.Name == "" || seen[v.Name]:
+				log.Warn("agent experiment variants need distinct names", "agent", name, "variant", v.Name)
+			case v.SoulFile != "":
+				path := v.SoulFile
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(cfg.AgentsDir, name, path)
+				}
+				if _, err := os.Stat(path); err != nil {
+					log.Warn("agent variant soul file not found", "agent", name, "variant", v.Name, "path", path)
+				}
+			}
+			seen[v.Name] = true
+		}
+	}
+
 	// Warn if API token is empty.
 	if cfg.APIToken == "" {
 		log.Warn("apiToken is empty, API endpoints are unauthenticated")
@@ -1976,6 +3163,13 @@ func validateConfig(cfg *Config) {
 		if _, err := os.Stat(cfg.TLS.KeyFile); err != nil {
 			log.Warn("tls.keyFile does not exist", "path", cfg.TLS.KeyFile)
 		}
+		if cfg.TLS.ClientCAFile != "" {
+			if _, err := os.Stat(cfg.TLS.ClientCAFile); err != nil {
+				log.Warn("tls.clientCAFile does not exist", "path", cfg.TLS.ClientCAFile)
+			}
+		}
+	} else if cfg.TLS.ClientCAFile != "" {
+		log.Warn("tls.clientCAFile ignored: mutual TLS needs tls.certFile and tls.keyFile")
 	}
 
 	// Validate providers.
@@ -2034,6 +3228,328 @@ func validateConfig(cfg *Config) {
 		}
 	}
 
+	// Validate routing table.
+	for i, rule := range cfg.Routing.Rules {
+		if rule.Agent == "" {
+			log.Warn("routing rule has no agent and is ignored", "rule", rule.Label(i))
+		} else if _, ok := cfg.Agents[rule.Agent]; !ok {
+			log.Warn("routing rule references unknown agent", "rule", rule.Label(i), "agent", rule.Agent)
+		}
+	}
+
+	// Validate agent schedule windows.
+	for name, rc := range cfg.Agents {
+		if rc.Schedule == nil {
+			continue
+		}
+		if rc.Schedule.TZ != "" {
+			if _, err := time.LoadLocation(rc.Schedule.TZ); err != nil {
+				log.Warn("agent schedule tz is invalid, using local time", "agent", name, "tz", rc.Schedule.TZ)
+			}
+		}
+		for _, w := range rc.Schedule.Windows {
+			if err := scheduling.ValidateWindow(scheduling.Window{Days: w.Days, Start: w.Start, End: w.End}); err != nil {
+				log.Warn("agent schedule window is invalid", "agent", name, "error", err)
+			}
+		}
+	}
+
+	// Validate knowledge watch rules.
+	for i, r := range cfg.KnowledgeWatch.Rules {
+		if _, err := path.Match(r.Match, ""); err != nil || r.Match == "" {
+			log.Warn("knowledgeWatch rule has an invalid match pattern", "rule", i+1, "match", r.Match)
+		}
+		if r.Root != "" && r.Root != docwatch.RootKnowledge && r.Root != docwatch.RootVault {
+			log.Warn("knowledgeWatch rule root must be knowledge or vault", "rule", i+1, "root", r.Root)
+		}
+		for _, e := range r.Events {
+			if e != docwatch.Created && e != docwatch.Modified && e != docwatch.Removed {
+				log.Warn("knowledgeWatch rule has an unknown event", "rule", i+1, "event", e)
+			}
+		}
+	}
+
+	// Validate the work calendar used by businessDaysOnly cron jobs.
+	wc := cfg.WorkCalendar
+	if wc.Holidays != "" {
+		if _, err := scheduling.HolidaySource(wc.Holidays); err != nil {
+			log.Warn("workCalendar.holidays is invalid", "error", err)
+		}
+	}
+	if err := scheduling.ValidateDays(wc.Weekend); err != nil {
+		log.Warn("workCalendar.weekend is invalid", "error", err)
+	}
+	for user, u := range wc.Users {
+		if u.Holidays != "" {
+			if _, err := scheduling.HolidaySource(u.Holidays); err != nil {
+				log.Warn("workCalendar user holidays is invalid", "user", user, "error", err)
+			}
+		}
+		if err := scheduling.ValidateDays(u.Weekend); err != nil {
+			log.Warn("workCalendar user weekend is invalid", "user", user, "error", err)
+		}
+		if q := u.QuietHours; q != nil {
+			if err := scheduling.ValidateWindow(scheduling.Window{Start: q.Start, End: q.End}); err != nil || q.Start == "" || q.End == "" {
+				log.Warn("workCalendar user quietHours is invalid (want start and end as HH:MM)", "user", user)
+			}
+		}
+	}
+	if len(wc.Users) > 0 && !cfg.Family.Enabled {
+		log.Warn("workCalendar.users is ignored unless family.enabled is set")
+	}
+
+	// Validate calendar accounts.
+	if cfg.Calendar.Enabled {
+		seen := map[string]bool{}
+		for _, a := range cfg.Calendar.Accounts {
+			if a.Name == "" || seen[a.Name] {
+				log.Warn("calendar account needs a unique name", "name", a.Name)
+			}
+			seen[a.Name] = true
+			if _, err := calendar.NewClient(a, nil, cfg.Calendar.TimeZone, 0); err != nil {
+				log.Warn("calendar account is invalid", "error", err)
+			}
+		}
+		if d := cfg.Calendar.Default; d != "" {
+			if a, ok := cfg.Calendar.Account(d); !ok || !a.Writable() {
+				log.Warn("calendar.default must name a writable account", "default", d)
+			}
+		}
+	}
+
+	// Validate mail accounts.
+	if cfg.Mail.Enabled {
+		seen := map[string]bool{}
+		for _, a := range cfg.Mail.Accounts {
+			if a.Name == "" || seen[a.Name] {
+				log.Warn("mail account needs a unique name", "name", a.Name)
+			}
+			seen[a.Name] = true
+			if a.Address == "" || (a.IMAP == "" && a.SMTP == "") {
+				log.Warn("mail account needs an address and an imap or smtp server", "name", a.Name)
+			}
+			if a.Password == "" && a.OAuthService == "" {
+				log.Warn("mail account needs a password or oauthService", "name", a.Name)
+			}
+			if a.OAuthService != "" {
+				if _, ok := cfg.OAuth.Services[a.OAuthService]; !ok {
+					log.Warn("mail account oauthService is not configured under oauth.services", "name", a.Name, "service", a.OAuthService)
+				}
+			}
+		}
+		if d := cfg.Mail.Default; d != "" {
+			if a, ok := cfg.Mail.Account(d); !ok || !a.CanSend() {
+				log.Warn("mail.default must name an account that can send", "default", d)
+			}
+		}
+	}
+
+	// Validate MQTT brokers and notification channels.
+	if cfg.MQTT.Enabled {
+		seen := map[string]bool{}
+		for _, b := range cfg.MQTT.Brokers {
+			if b.Name == "" || seen[b.Name] {
+				log.Warn("mqtt broker needs a unique name", "name", b.Name)
+			}
+			seen[b.Name] = true
+			if b.URL == "" {
+				log.Warn("mqtt broker needs a url", "name", b.Name)
+			}
+			for _, sub := range b.Subscribe {
+				if !mqtt.ValidFilter(sub.Topic) {
+					log.Warn("mqtt subscription topic is invalid", "broker", b.Name, "topic", sub.Topic)
+				}
+			}
+			if r := b.Results; r != nil && (r.Topic == "" || strings.ContainsAny(r.Topic, "+#")) {
+				log.Warn("mqtt results topic must be set and have no wildcards", "broker", b.Name, "topic", r.Topic)
+			}
+		}
+	}
+	for _, ch := range cfg.Notifications {
+		if ch.Type == "mqtt" && !cfg.MQTT.Enabled {
+			log.Warn("mqtt notification channel needs mqtt.enabled", "channel", ch.Name)
+		}
+	}
+
+	// Validate code forge accounts.
+	forgeNames := map[string]bool{}
+	for _, a := range cfg.ForgeAccounts() {
+		if a.Name == "" || strings.Contains(a.Name, "/") || forgeNames[a.Name] {
+			log.Warn("forge account name must be unique and have no slash", "account", a.Name)
+		}
+		forgeNames[a.Name] = true
+		switch a.Type {
+		case "github":
+			if a.Token == "" && a.AppID == 0 {
+				log.Warn("github needs a token or a GitHub App (appId, installationId, privateKeyFile)", "account", a.Name)
+			}
+		case "gitlab":
+			if a.Token == "" {
+				log.Warn("gitlab needs a token", "account", a.Name)
+			}
+		case "gitea", "forgejo":
+			if a.URL == "" || a.Token == "" {
+				log.Warn("gitea needs url and token", "account", a.Name)
+			}
+		default:
+			log.Warn("forge account type must be github, gitlab or gitea", "account", a.Name, "type", a.Type)
+		}
+		for _, r := range a.Repos {
+			if owner, name, ok := strings.Cut(r, "/"); !ok || owner == "" || name == "" {
+				log.Warn("forge repo must be owner/name", "account", a.Name, "repo", r)
+			}
+		}
+	}
+
+	// Validate task sync.
+	if ts := cfg.TaskBoard.Sync; ts.Enabled() {
+		if !cfg.TaskBoard.Enabled {
+			log.Warn("taskBoard.sync needs taskBoard.enabled")
+		}
+		if ts.Provider != "jira" && ts.Provider != "linear" {
+			log.Warn("taskBoard.sync.provider must be jira or linear", "provider", ts.Provider)
+		}
+		if ts.Token == "" || ts.Project == "" || (ts.Provider == "jira" && ts.URL == "") {
+			log.Warn("taskBoard.sync needs token and project (and url for jira)", "provider", ts.Provider)
+		}
+		if c := ts.Conflict; c != "" && c != "newest" && c != "tetora" && c != "tracker" {
+			log.Warn("taskBoard.sync.conflict must be newest, tetora or tracker", "conflict", c)
+		}
+		for status := range ts.Statuses {
+			if !slices.Contains([]string{"idea", "needs-thought", "backlog", "todo", "doing", "review", "done", "partial-done", "failed"}, status) {
+				log.Warn("taskBoard.sync.statuses key is not a taskboard status", "status", status)
+			}
+		}
+	}
+
+	// Validate notes.
+	if n := cfg.Notes; n.Enabled {
+		switch n.BackendOrDefault() {
+		case "obsidian":
+		case "notion":
+			if n.Notion.Database == "" && n.Notion.Page == "" {
+				log.Warn("notes.notion needs database or page")
+			}
+			if n.Notion.Token == "" && n.Notion.OAuthService == "" {
+				log.Warn("notes.notion needs token or oauthService")
+			}
+		default:
+			log.Warn("notes.backend must be obsidian or notion", "backend", n.Backend)
+		}
+	}
+
+	// Validate backup remotes.
+	remoteNames := map[string]bool{}
+	for _, rc := range cfg.Ops.BackupRemotes {
+		name := rc.NameOrBucket()
+		if remoteNames[name] {
+			log.Warn("ops.backupRemotes names must be unique", "remote", name)
+		}
+		remoteNames[name] = true
+		var token func(context.Context) (string, error)
+		if rc.OAuthService != "" {
+			token = func(context.Context) (string, error) { return "", nil }
+		}
+		if _, err := objstore.New(rc.ObjectStoreConfig, token); err != nil {
+			log.Warn("ops.backupRemotes entry is invalid", "remote", name, "error", err)
+		}
+	}
+	if len(cfg.Ops.BackupRemotes) > 0 && cfg.Ops.BackupSchedule == "" {
+		log.Warn("ops.backupRemotes are only used by manual backups without ops.backupSchedule")
+	}
+	if rep := cfg.Ops.Replication; rep.Enabled {
+		if rep.Remote == "" && rep.Path == "" {
+			log.Warn("ops.replication needs remote or path")
+		} else if rep.Remote != "" && rep.Path != "" {
+			log.Warn("ops.replication.path is ignored when remote is set")
+		}
+		if rep.Remote != "" && !remoteNames[rep.Remote] {
+			log.Warn("ops.replication.remote is not in ops.backupRemotes", "remote", rep.Remote)
+		}
+		for key, v := range map[string]string{"interval": rep.Interval, "snapshotInterval": rep.SnapshotInterval} {
+			if d, err := time.ParseDuration(v); v != "" && (err != nil || d <= 0) {
+				log.Warn("ops.replication."+key+" is not a valid duration", key, v)
+			}
+		}
+	}
+
+	// Validate encryption at rest.
+	if e := cfg.Encryption; e.MasterKey != "" && e.MasterKeyFile != "" {
+		log.Warn("encryption.masterKeyFile is ignored when masterKey is set")
+	}
+	if v := cfg.Encryption.SealUploadsAfter; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			log.Warn("encryption.sealUploadsAfter is not a valid duration", "sealUploadsAfter", v, "example", "1h")
+		}
+	}
+
+	// Validate integration health time outs.
+	for key, v := range map[string]string{"disableFor": cfg.IntegrationHealth.DisableFor, "maxDisableFor": cfg.IntegrationHealth.MaxDisableFor} {
+		if d, err := time.ParseDuration(v); v != "" && (err != nil || d <= 0) {
+			log.Warn("integrationHealth."+key+" is not a valid duration", key, v, "example", "15m, 6h")
+		}
+	}
+	if s := cfg.IntegrationHealth.MinScore; s < 0 || s > 100 {
+		log.Warn("integrationHealth.minScore must be between 0 and 100", "minScore", s)
+	}
+
+	// Validate agent personas and knowledge scopes.
+	for name, rc := range cfg.Agents {
+		for _, p := range rc.Personas {
+			if err := prompt.ValidatePersona(p); err != nil {
+				log.Warn("agent persona is invalid", "agent", name, "error", err)
+			}
+		}
+		if ks := rc.Knowledge; ks != nil {
+			for _, d := range ks.Dirs {
+				clean := filepath.Clean(filepath.FromSlash(d))
+				if filepath.IsAbs(clean) || clean == "." || strings.HasPrefix(clean, "..") {
+					log.Warn("agent knowledge.dirs must be subdirectories of knowledgeDir", "agent", name, "dir", d)
+				} else if fi, err := os.Stat(filepath.Join(cfg.KnowledgeDir, clean)); err != nil || !fi.IsDir() {
+					log.Warn("agent knowledge dir not found", "agent", name, "dir", d)
+				}
+			}
+		}
+	}
+
+	// Validate redaction targets and bypass agents.
+	if rc := cfg.Security.Redaction; rc.Enabled {
+		for _, t := range rc.Targets {
+			if !slices.Contains(config.RedactionTargets, t) {
+				log.Warn("security.redaction.targets: unknown target", "target", t)
+			}
+		}
+		for _, a := range rc.Bypass {
+			if _, ok := cfg.Agents[a]; !ok {
+				log.Warn("security.redaction.bypass: unknown agent", "agent", a)
+			}
+		}
+	}
+
+	// Validate audit export sinks.
+	if ae := cfg.Security.AuditExport; ae.Enabled {
+		for _, sc := range ae.Sinks {
+			if err := siem.Validate(sc); err != nil {
+				log.Warn("security.auditExport: invalid sink", "error", err)
+			}
+		}
+	}
+
+	// Validate Telegram webhook mode.
+	if tg := cfg.Telegram; tg.Enabled && tg.WebhookMode && !strings.HasPrefix(tg.WebhookURL, "https://") {
+		log.Warn("telegram.webhookMode needs an https webhookURL, will poll instead", "webhookURL", tg.WebhookURL)
+	}
+
+	// Validate incoming webhook transforms.
+	for name, wh := range cfg.IncomingWebhooks {
+		if err := webhook.ValidateTransform(wh.Transform); err != nil {
+			log.Warn("incomingWebhooks: invalid transform", "webhook", name, "error", err)
+		}
+		if err := webhook.ValidateFilter(wh.Filter); err != nil {
+			log.Warn("incomingWebhooks: invalid filter", "webhook", name, "error", err)
+		}
+	}
+
 	// Validate Docker sandbox config.
 	if cfg.Docker.Enabled {
 		if cfg.Docker.Image == "" {
@@ -2057,7 +3573,7 @@ func updateConfigMCPs(configPath, mcpName string, mcpConfig json.RawMessage) err
 	configFileMu.Lock()
 	defer configFileMu.Unlock()
 
-	data, err := os.ReadFile(configPath)
+	data, err := cfgfile.ReadJSON(configPath)
 	if err != nil {
 		return fmt.Errorf("read config: %w", err)
 	}
@@ -2089,7 +3605,7 @@ func updateConfigMCPs(configPath, mcpName string, mcpConfig json.RawMessage) err
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(configPath, append(out, '\n'), 0o600); err != nil {
+	if err := cfgfile.WriteJSON(configPath, append(out, '\n'), 0o600); err != nil {
 		return err
 	}
 	// Auto-snapshot config version after MCP change.
@@ -2148,6 +3664,8 @@ Commands:
   doctor             Setup checks and diagnostics
   health             Runtime health (daemon, workers, taskboard, disk)
   status             Quick overview (daemon, jobs, cost)
+  top                Live console: running tasks, queue, cron, cost, channels
+  chat               Chat with an agent in the terminal (--role, --session to resume)
   drain              Graceful shutdown: stop new tasks, wait for running agents to finish
   service <action>   Manage launchd service (install|uninstall|status)
   job <action>       Manage cron jobs (list|add|enable|disable|remove|trigger)
@@ -2155,8 +3673,8 @@ Commands:
   history <action>   View execution history (list|show|cost)
   config <action>    Manage config (show|set|validate|migrate)
   logs               View daemon logs ([-f] [-n N] [--err] [--trace ID] [--json])
-  prompt <action>    Manage prompt templates (list|show|add|edit|remove)
-  memory <action>    Manage agent memory (list|get|set|delete [--agent AGENT])
+  prompt <action>    Manage prompt templates (list|show|render|add|edit|remove)
+  memory <action>    Manage agent memory (list|get|set|delete|review|export|import [--agent AGENT])
   mcp <action>       Manage MCP configs (list|show|add|remove|test)
   session <action>   View agent sessions (list|show)
   knowledge <action> Manage knowledge base (list|add|remove|path)
@@ -2165,19 +3683,32 @@ Commands:
   task <action>      Persistent taskboard (list|create|show|update|move|assign|comment|thread)
   budget <action>    Cost governance (show|pause|resume)
   webhook <action>   Manage incoming webhooks (list|show|test)
+  channel simulate   Test channel routing with a signed synthetic message (slack|line|whatsapp)
+  handover <action>  Conversations handed to the owner (list|reply|release)
+  faq <action>       Canned answers checked before dispatch (list|add|edit|rm|suggest)
+  feedback <action>  Rate task results (<task> up|down [comment] | list [agent] [up|down])
+  monitor <action>   Watchlist monitors for pages, JSON APIs and feeds (list|check|history)
+  pipeline <action>  Scheduled fetch → transform → dispatch pipelines (list|run|runs)
+  automation <action> Bundle cron jobs, webhooks and workflow triggers (export|import)
   data <action>      Data retention & privacy (status|cleanup|export|purge)
   security <action>  Security scanning (scan|baseline)
   plugin <action>    Manage external plugins (list|start|stop)
   access <action>    Manage agent directory access (list|add|remove)
+  workspace <action> Manage isolated workspaces (list|create|switch)
   import <source>    Import data (config)
+  gitops <action>    Reconcile config from a git repo (status|sync|drift)
   release            Build, tag, and publish a release (atomic pipeline)
   upgrade [--force]  Upgrade to the latest release version
-  backup             Create backup of tetora data
-  restore            Restore from a backup file
+  encryption <action> Encryption at rest (status|rotate|open)
+  backup [action]    Back up the history DB, also to remote buckets (now|list|restore)
+  restore <file>     Restore the history DB from a backup (--from-remote, --pitr <time>)
   dashboard          Open web dashboard in browser
   completion <shell> Generate shell completion (bash|zsh|fish)
   version            Show version
 
+Options:
+  --profile <name>   Apply the config.d/<name>/ overlays (or set TETORA_PROFILE)
+
 Examples:
   tetora init                          Create config interactively
   tetora serve                         Start daemon
@@ -2197,11 +3728,15 @@ Examples:
   tetora task create --title="..." --assignee=hisui --description="..."
                                        Create a persistent cross-session ticket
   tetora config migrate --dry-run      Preview config migrations
+  tetora --profile prod config show --effective
+                                       Show the merged config for a profile
   tetora session list                  List recent sessions
   tetora session list --agent <name>   List sessions for a specific agent
   tetora session show <id>            Show session conversation
-  tetora backup                        Create backup
-  tetora restore backup.tar.gz         Restore from backup
+  tetora backup                        Back up now and copy to remotes
+  tetora backup list --from-remote     List backups in the first remote bucket
+  tetora restore <file> --from-remote  Restore the history DB from a remote backup
+  tetora restore --pitr "<time>"       Restore the history DB as of a time (point-in-time)
   tetora service install               Install as launchd service
 
 `, tetoraVersion)
@@ -2687,17 +4222,18 @@ func (c *apiClient) postJSON(path string, v any) (*http.Response, error) {
 
 func findConfigPath() string {
 	if exe, err := os.Executable(); err == nil {
-		candidate := filepath.Join(filepath.Dir(exe), "..", "config.json")
-		if abs, err := filepath.Abs(candidate); err == nil {
-			if _, err := os.Stat(abs); err == nil {
-				return abs
+		if abs, err := filepath.Abs(filepath.Join(filepath.Dir(exe), "..")); err == nil {
+			if p := cfgfile.Find(abs, "config"); p != "" {
+				return p
 			}
 		}
 	}
 	home, _ := os.UserHomeDir()
-	candidate := filepath.Join(home, ".tetora", "config.json")
-	if _, err := os.Stat(candidate); err == nil {
-		return candidate
+	if p := cfgfile.Find(filepath.Join(home, ".tetora"), "config"); p != "" {
+		return p
+	}
+	if p := cfgfile.Find(".", "config"); p != "" {
+		return p
 	}
 	return "config.json"
 }
@@ -3048,23 +4584,14 @@ func getSystemHealth(cfg *Config) map[string]any {
 	}
 
 	// Active integrations.
-	integrations := map[string]bool{
-		"telegram":  cfg.Telegram.Enabled,
-		"slack":     cfg.Slack.Enabled,
-		"discord":   cfg.Discord.Enabled,
-		"whatsapp":  cfg.WhatsApp.Enabled,
-		"line":      cfg.LINE.Enabled,
-		"matrix":    cfg.Matrix.Enabled,
-		"teams":     cfg.Teams.Enabled,
-		"signal":    cfg.Signal.Enabled,
-		"gchat":     cfg.GoogleChat.Enabled,
-		"gmail":     false,
-		"calendar":  cfg.Calendar.Enabled,
-		"twitter":   false,
-		"imessage":  cfg.IMessage.Enabled,
-		"homeassistant": false,
+	integrations := integrationsEnabled(cfg)
+	for name, on := range channelsEnabled(cfg) {
+		integrations[name] = on
 	}
 	health["integrations"] = integrations
+	if st := globalIntegrationHealth.Statuses(); len(st) > 0 {
+		health["integrationHealth"] = st
+	}
 
 	// Count unhealthy channels.
 	unhealthyCount := 0
@@ -3091,6 +4618,43 @@ func getSystemHealth(cfg *Config) map[string]any {
 	return health
 }
 
+// channelsEnabled reports which chat channels are configured.
+func channelsEnabled(cfg *Config) map[string]bool {
+	return map[string]bool{
+		"telegram": cfg.Telegram.Enabled,
+		"slack":    cfg.Slack.Enabled,
+		"discord":  cfg.Discord.Enabled,
+		"whatsapp": cfg.WhatsApp.Enabled,
+		"line":     cfg.LINE.Enabled,
+		"matrix":   cfg.Matrix.Enabled,
+		"teams":    cfg.Teams.Enabled,
+		"signal":   cfg.Signal.Enabled,
+		"gchat":    cfg.GoogleChat.Enabled,
+		"imessage": cfg.IMessage.Enabled,
+	}
+}
+
+// integrationsEnabled reports which external integrations are configured.
+func integrationsEnabled(cfg *Config) map[string]bool {
+	return map[string]bool{
+		"gmail":         false,
+		"mail":          cfg.Mail.Enabled,
+		"calendar":      cfg.Calendar.Enabled,
+		"twitter":       cfg.Twitter.Enabled,
+		"social":        len(cfg.Social.Accounts) > 0,
+		"readLater":     cfg.ReadLater.Enabled(),
+		"mailIn":        cfg.MailIn.Enabled,
+		"sync":          cfg.Sync.Enabled,
+		"mirror":        cfg.Mirror.Enabled,
+		"gitops":        cfg.GitOps.Enabled,
+		"mqtt":          cfg.MQTT.Enabled,
+		"forges":        len(cfg.ForgeAccounts()) > 0,
+		"taskSync":      cfg.TaskBoard.Enabled && cfg.TaskBoard.Sync.Enabled(),
+		"notes":         cfg.Notes.Enabled,
+		"homeassistant": false,
+	}
+}
+
 // boolToHealthy returns "healthy" or "offline" based on a bool.
 func boolToHealthy(ok bool) string {
 	if ok {
@@ -3101,20 +4665,14 @@ func boolToHealthy(ok bool) string {
 
 // --- Tool Handlers for P23.7 ---
 
-// toolBackupNow triggers an immediate backup.
+// toolBackupNow triggers an immediate backup and copies it to the remotes.
 func toolBackupNow(ctx context.Context, cfg *Config, input json.RawMessage) (string, error) {
-	bs := scheduling.NewBackupScheduler(scheduling.BackupConfig{
-		DBPath:     cfg.HistoryDB,
-		BackupDir:  cfg.Ops.BackupDirResolved(cfg.BaseDir),
-		RetainDays: cfg.Ops.BackupRetainOrDefault(),
-		EscapeSQL:  db.Escape,
-		LogInfo:    log.Info,
-		LogWarn:    log.Warn,
-	})
+	bs := newBackupScheduler(cfg)
 	result, err := bs.RunBackup()
 	if err != nil {
 		return "", fmt.Errorf("backup failed: %w", err)
 	}
+	result.Remotes = bs.Upload(ctx, result.Filename)
 	b, _ := json.Marshal(result)
 	return string(b), nil
 }
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,6 +20,7 @@ import (
 	"tetora/internal/config"
 	"tetora/internal/db"
 	"tetora/internal/export"
+	"tetora/internal/instancelock"
 	"tetora/internal/log"
 	"tetora/internal/migrate"
 	"tetora/internal/scheduling"
@@ -390,6 +393,61 @@ func TestAgenticLoop_RoleFiltering(t *testing.T) {
 	}
 }
 
+func TestAgenticLoop_AllowlistAndCallBudget(t *testing.T) {
+	var counter atomic.Int64
+	echo := func(id string) ToolCall {
+		return ToolCall{ID: id, Name: "echo", Input: json.RawMessage(`{"msg":"` + id + `"}`)}
+	}
+	provider := &mockToolProvider{
+		name: "mock",
+		results: []*ProviderResult{
+			{StopReason: "tool_use", ToolCalls: []ToolCall{echo("tc1"), {ID: "tc2", Name: "counter", Input: json.RawMessage(`{}`)}}},
+			{StopReason: "tool_use", ToolCalls: []ToolCall{echo("tc3"), echo("tc4"), echo("tc5")}},
+			{Output: "done", StopReason: "end_turn"},
+		},
+	}
+
+	dbPath := setupTrustTestDB(t)
+	cfg := testConfigWithTools(echoTool(), counterTool(&counter))
+	cfg.HistoryDB = dbPath
+	cfg.Agents = map[string]AgentConfig{
+		"capped": {ToolPolicy: AgentToolPolicy{
+			Allow:     []string{"echo", "counter"},
+			Allowlist: []string{"ec*"},
+			MaxCalls:  2,
+		}},
+	}
+	if allowed := resolveAllowedTools(cfg, "capped"); !allowed["echo"] || allowed["counter"] {
+		t.Fatalf("allowed = %v", allowed)
+	}
+
+	task := Task{ID: "t-cap", Prompt: "test budget", Provider: "mock", Agent: "capped"}
+	result := executeWithProviderAndTools(context.Background(), cfg, task, "capped", testRegistry(provider), nil, nil)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.Error)
+	}
+	if counter.Load() != 0 {
+		t.Errorf("counter ran despite the allowlist")
+	}
+
+	// One denial and one exhausted budget (recorded once for tc4 and tc5).
+	status := getTrustStatus(cfg, "capped")
+	if status.ToolViolations != 2 || !strings.Contains(status.LastViolation, "budget of 2") {
+		t.Errorf("violations = %d, last %q", status.ToolViolations, status.LastViolation)
+	}
+
+	// -1 lifts the global ceiling for one agent.
+	cfg.Tools.MaxCallsPerTask = 5
+	if n := toolCallLimit(cfg, "capped"); n != 2 {
+		t.Errorf("limit = %d, want agent's 2", n)
+	}
+	cfg.Agents["other"] = AgentConfig{}
+	cfg.Agents["free"] = AgentConfig{ToolPolicy: AgentToolPolicy{MaxCalls: -1}}
+	if toolCallLimit(cfg, "other") != 5 || toolCallLimit(cfg, "free") != 0 {
+		t.Errorf("limits = %d, %d", toolCallLimit(cfg, "other"), toolCallLimit(cfg, "free"))
+	}
+}
+
 func TestDispatchConcurrent_Race(t *testing.T) {
 	// Run 5 concurrent executeWithProviderAndTools calls to detect data races.
 	cfg := testConfigWithTools(echoTool())
@@ -2180,3 +2238,45 @@ func TestSnapshotEntityEmptyDB(t *testing.T) {
 		t.Errorf("expected nil error for empty dbPath, got %v", err)
 	}
 }
+
+func TestAcquireInstanceLock_Takeover(t *testing.T) {
+	dir := t.TempDir()
+
+	// The "running daemon" holds the lock and releases it when drained.
+	old, _, err := instancelock.Acquire(dir, instancelock.Info{})
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	var drained atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/drain" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		drained.Store(true)
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			old.Release()
+		}()
+		w.Write([]byte(`{"status":"draining"}`))
+	}))
+	defer srv.Close()
+
+	cfg := &Config{BaseDir: dir, APIToken: "tok", ListenAddr: strings.TrimPrefix(srv.URL, "http://")}
+
+	if _, err := acquireInstanceLock(cfg, false); err == nil || !strings.Contains(err.Error(), "--takeover") {
+		t.Fatalf("without takeover: err = %v, want hint about --takeover", err)
+	}
+	if drained.Load() {
+		t.Fatal("drain requested without --takeover")
+	}
+
+	lock, err := acquireInstanceLock(cfg, true)
+	if err != nil {
+		t.Fatalf("takeover: %v", err)
+	}
+	defer lock.Release()
+	if !drained.Load() {
+		t.Error("takeover did not request a drain")
+	}
+}
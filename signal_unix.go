@@ -8,3 +8,8 @@ import "syscall"
 func signalSelfReload() {
 	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
 }
+
+// signalReload sends SIGHUP to a running daemon so it reloads its config.
+func signalReload(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
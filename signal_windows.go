@@ -2,5 +2,12 @@
 
 package main
 
+import "fmt"
+
 // signalSelfReload is a no-op on Windows (SIGHUP not supported).
 func signalSelfReload() {}
+
+// signalReload is not supported on Windows; the daemon must be restarted.
+func signalReload(pid int) error {
+	return fmt.Errorf("config reload by signal is not supported on Windows")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -13,12 +14,17 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"tetora/internal/audit"
+	"tetora/internal/cron"
 	"tetora/internal/db"
+	"tetora/internal/dbquery"
 	"tetora/internal/log"
 	"tetora/internal/provider"
+	"tetora/internal/sshexec"
 	"tetora/internal/tools"
 	"tetora/internal/trace"
 	"time"
+	"unicode/utf8"
 	dtypes "tetora/internal/dispatch"
 )
 
@@ -65,6 +71,19 @@ func registerBuiltins(r *ToolRegistry, cfg *Config) {
 	tools.RegisterTaskboardTools(r, cfg, enabled, buildTaskboardDeps(cfg))
 	tools.RegisterReflectionTools(r, cfg, enabled, buildReflectionDeps(cfg))
 	tools.RegisterImageGenTools(r, cfg, enabled, buildImageGenDeps())
+	tools.RegisterTwitterTools(r, cfg, enabled, buildTwitterDeps())
+	tools.RegisterSocialTools(r, cfg, enabled, buildSocialDeps())
+	tools.RegisterReadLaterTools(r, cfg, enabled, buildReadLaterDeps())
+	tools.RegisterCalendarTools(r, cfg, enabled, buildCalendarDeps())
+	tools.RegisterMailTools(r, cfg, enabled, buildMailDeps())
+	tools.RegisterForgeTools(r, cfg, enabled, buildForgeDeps())
+	tools.RegisterSSHTools(r, cfg, enabled)
+	tools.RegisterK8sTools(r, cfg, enabled)
+	tools.RegisterDBQueryTools(r, cfg, enabled)
+	tools.RegisterSheetsTools(r, cfg, enabled, buildSheetsDeps(cfg))
+	tools.RegisterDocumentTools(r, cfg, enabled, buildDocumentDeps(cfg))
+	tools.RegisterChartTools(r, cfg, enabled)
+	tools.RegisterGenerateTools(r, cfg, enabled, buildGenerateDeps(cfg))
 }
 
 // registerAdminTools registers admin/ops tools (backup, export, health,
@@ -94,7 +113,7 @@ func registerAdminTools(r *ToolRegistry, cfg *Config, enabled func(string) bool)
 	if enabled("backup_now") {
 		r.Register(&ToolDef{
 			Name:        "backup_now",
-			Description: "Trigger an immediate backup of the database",
+			Description: "Trigger an immediate backup of the database and copy it to the configured remote buckets",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {}
@@ -217,6 +236,7 @@ var memoryDepsForTest = tools.MemoryDeps{
 		}
 		return result, nil
 	},
+	SearchKnowledge: searchKnowledge,
 }
 
 func toolMemorySearch(ctx context.Context, cfg *Config, input json.RawMessage) (string, error) {
@@ -230,7 +250,7 @@ func toolMemoryGet(ctx context.Context, cfg *Config, input json.RawMessage) (str
 }
 
 func toolKnowledgeSearch(ctx context.Context, cfg *Config, input json.RawMessage) (string, error) {
-	h := tools.MakeKnowledgeSearchHandler()
+	h := tools.MakeKnowledgeSearchHandler(memoryDepsForTest)
 	return h(ctx, cfg, input)
 }
 
@@ -361,7 +381,7 @@ func getAgentToolPolicy(cfg *Config, agentName string) AgentToolPolicy {
 }
 
 // resolveAllowedTools returns the set of tool names allowed for an agent.
-// Resolution order: profile → +allow → -deny
+// Resolution order: profile → +allow → -deny → ∩allowlist
 func resolveAllowedTools(cfg *Config, agentName string) map[string]bool {
 	policy := getAgentToolPolicy(cfg, agentName)
 	profile := getProfile(cfg, policy.Profile)
@@ -395,9 +415,61 @@ func resolveAllowedTools(cfg *Config, agentName string) map[string]bool {
 		delete(allowed, toolName)
 	}
 
+	// Keep only allowlisted tools.
+	if len(policy.Allowlist) > 0 {
+		for toolName := range allowed {
+			if !inToolAllowlist(policy.Allowlist, toolName) {
+				delete(allowed, toolName)
+			}
+		}
+	}
+
 	return allowed
 }
 
+// inToolAllowlist reports whether toolName matches an allowlist entry, exact
+// or "prefix*".
+func inToolAllowlist(allowlist []string, toolName string) bool {
+	for _, p := range allowlist {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(toolName, prefix) {
+				return true
+			}
+		} else if p == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+// toolCallLimit returns the most tool calls agentName may make in one task,
+// or 0 for no limit.
+func toolCallLimit(cfg *Config, agentName string) int {
+	switch n := getAgentToolPolicy(cfg, agentName).MaxCalls; {
+	case n < 0:
+		return 0
+	case n > 0:
+		return n
+	}
+	if cfg.Tools.MaxCallsPerTask > 0 {
+		return cfg.Tools.MaxCallsPerTask
+	}
+	return 0
+}
+
+// recordToolViolation audits a tool call refused by the agent's tool policy
+// or budget and records it as a trust event, so it shows in trust status.
+// kind is "denied" or "budget".
+func recordToolViolation(ctx context.Context, cfg *Config, task Task, kind, toolName, note string) {
+	log.WarnCtx(ctx, "tool call refused", "kind", kind, "tool", toolName, "agent", task.Agent, "taskId", task.ID)
+	audit.LogCtx(ctx, historyDBForTask(cfg, task), "tool.violation", "dispatch",
+		fmt.Sprintf("kind=%s agent=%s tool=%s task=%s", kind, task.Agent, toolName, task.ID), "")
+	if task.Agent != "" {
+		level := resolveTrustLevel(cfg, task.Agent)
+		recordTrustEvent(cfg.HistoryDB, task.Agent, "tool_"+kind, level, level, 0, note)
+	}
+}
+
 // isToolAllowed checks if a tool is allowed for an agent.
 func isToolAllowed(cfg *Config, agentName, toolName string) bool {
 	allowed := resolveAllowedTools(cfg, agentName)
@@ -679,11 +751,7 @@ func needsApproval(cfg *Config, toolName string) bool {
 
 // requestToolApproval sends approval request and blocks until response.
 func requestToolApproval(ctx context.Context, cfg *Config, task Task, tc ToolCall) (bool, error) {
-	timeout := cfg.ApprovalGates.Timeout
-	if timeout <= 0 {
-		timeout = 120
-	}
-	gateCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	gateCtx, cancel := context.WithTimeout(ctx, cfg.ApprovalGates.TimeoutOrDefault())
 	defer cancel()
 
 	req := ApprovalRequest{
@@ -711,8 +779,47 @@ func summarizeToolCall(tc ToolCall) string {
 		return fmt.Sprintf("Send email to: %s", jsonStr(args["to"]))
 	case "tweet_post":
 		return fmt.Sprintf("Post tweet: %s", truncateJSON(tc.Input, 80))
+	case "tweet_schedule":
+		return fmt.Sprintf("Schedule tweet: %s", truncateJSON(tc.Input, 80))
+	case "tweet_post_thread":
+		return fmt.Sprintf("Post thread: %s", truncateJSON(tc.Input, 80))
 	case "delete":
 		return fmt.Sprintf("Delete: %s", jsonStr(args["path"]))
+	case "ssh_exec":
+		return fmt.Sprintf("Run on %s: %s", jsonStr(args["host"]), jsonStr(args["command"]))
+	case "k8s_rollout_restart":
+		return fmt.Sprintf("Restart %s/%s on %s", jsonStr(args["kind"]), jsonStr(args["name"]), jsonStr(args["cluster"]))
+	case "k8s_scale":
+		return fmt.Sprintf("Scale %s/%s on %s to %v replicas", jsonStr(args["kind"]), jsonStr(args["name"]), jsonStr(args["cluster"]), args["replicas"])
+	case "k8s_delete_pod":
+		return fmt.Sprintf("Delete pod %s on %s", jsonStr(args["pod"]), jsonStr(args["cluster"]))
+	case "document_send":
+		var to []string
+		if emails, ok := args["email"].([]any); ok {
+			for _, e := range emails {
+				to = append(to, jsonStr(e))
+			}
+		}
+		if ch := jsonStr(args["channel"]); ch != "" {
+			to = append(to, ch)
+		}
+		return fmt.Sprintf("Send %s to %s", filepath.Base(jsonStr(args["path"])), strings.Join(to, ", "))
+	case "calendar_invite_send":
+		var to []string
+		if emails, ok := args["email"].([]any); ok {
+			for _, e := range emails {
+				to = append(to, jsonStr(e))
+			}
+		}
+		if ch := jsonStr(args["channel"]); ch != "" {
+			to = append(to, ch)
+		}
+		if len(to) == 0 {
+			to = append(to, "its attendees")
+		}
+		return fmt.Sprintf("Send invitation %s to %s", filepath.Base(jsonStr(args["path"])), strings.Join(to, ", "))
+	case "db_query":
+		return fmt.Sprintf("Query %s: %s", jsonStr(args["connection"]), truncate(jsonStr(args["query"]), 200))
 	default:
 		return fmt.Sprintf("Execute %s with %s", tc.Name, truncateJSON(tc.Input, 100))
 	}
@@ -729,6 +836,285 @@ func gateReason(err error, approved bool) string {
 	return "approved"
 }
 
+// publishingTools post as the owner and are external actions whether or not
+// approvalGates.externalActions lists them. Approving tweet_schedule also
+// approves the later post.
+var publishingTools = map[string]bool{
+	"tweet_post":           true,
+	"tweet_reply":          true,
+	"tweet_dm":             true,
+	"tweet_post_thread":    true,
+	"tweet_schedule":       true,
+	"social_post":          true,
+	"social_reply":         true,
+	"document_send":        true,
+	"calendar_invite_send": true,
+}
+
+// clusterMutationTools change a live Kubernetes cluster and, like the
+// publishing tools, always need the owner's approval.
+var clusterMutationTools = map[string]bool{
+	"k8s_rollout_restart": true,
+	"k8s_scale":           true,
+	"k8s_delete_pod":      true,
+}
+
+// isExternalAction reports whether toolName publishes as the owner, mutates
+// a cluster, or is listed in approvalGates.externalActions, by name or
+// "prefix*".
+func isExternalAction(cfg *Config, toolName string) bool {
+	if publishingTools[toolName] || clusterMutationTools[toolName] {
+		return true
+	}
+	for _, pattern := range cfg.ApprovalGates.ExternalActions {
+		if pattern == toolName || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(toolName, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExternalAction holds an external action until the owner approves it
+// from the rendered payload. It applies whatever the agent's trust level and
+// ignores "Always" approvals; without a reachable approval channel the
+// action is refused. Every decision, and who made it, is audited.
+func checkExternalAction(ctx context.Context, cfg *Config, task Task, tc ToolCall) error {
+	external := isExternalAction(cfg, tc.Name)
+	switch tc.Name {
+	case "ssh_exec":
+		suggest, err := checkSSHExec(ctx, cfg, task, tc)
+		if err != nil {
+			return err
+		}
+		external = external || suggest
+	case "db_query":
+		if err := checkDBQuery(ctx, cfg, task, tc); err != nil {
+			return err
+		}
+	}
+	if !external {
+		return nil
+	}
+	approvals := globalApprovalManager
+	if appCtx := appFromCtx(ctx); appCtx != nil && appCtx.Approvals != nil {
+		approvals = appCtx.Approvals
+	}
+	channel, gate := approvals.Resolve(cfg.ApprovalGates.Channel, task.ApprovalGate)
+	preview, complete := previewToolCall(tc)
+
+	var by, outcome string
+	var err error
+	switch {
+	case !complete:
+		// The owner can only approve what they can read, so a payload
+		// cut to fit the approval message is never sent.
+		outcome = "too_large"
+		err = fmt.Errorf("%s payload is too long to show for approval (over %d characters); shorten it or split it into several calls", tc.Name, previewLimit)
+	case gate == nil:
+		outcome = "unavailable"
+		err = fmt.Errorf("%s needs approval and no approval channel is available", tc.Name)
+	default:
+		gateCtx, cancel := context.WithTimeout(ctx, cfg.ApprovalGates.TimeoutOrDefault())
+		approved, gateErr := gate.RequestApproval(gateCtx, ApprovalRequest{
+			ID:         trace.NewID("action"),
+			Tool:       tc.Name,
+			Input:      tc.Input,
+			Summary:    summarizeToolCall(tc),
+			Preview:    preview,
+			External:   true,
+			TaskID:     task.ID,
+			Role:       task.Agent,
+			OnDecision: func(_ bool, who string) { by = who },
+		})
+		cancel()
+		switch {
+		case gateErr != nil:
+			outcome = "timeout"
+			err = fmt.Errorf("%s not approved: %v", tc.Name, gateErr)
+		case !approved:
+			outcome = "rejected"
+			err = fmt.Errorf("%s rejected by %s", tc.Name, cmp.Or(by, "the owner"))
+		default:
+			outcome = "approved"
+		}
+	}
+
+	detail := fmt.Sprintf("tool=%s task=%s agent=%s by=%s\n%s", tc.Name, task.ID, task.Agent, by, preview)
+	audit.LogCtx(ctx, historyDBForTask(cfg, task), "tool.external."+outcome, cmp.Or(channel, "none"), detail, "")
+	log.InfoCtx(ctx, "external action approval", "tool", tc.Name, "taskId", task.ID, "channel", channel,
+		"decision", outcome, "by", by)
+	return err
+}
+
+// checkSSHExec applies an ssh_exec host's dispatch policy: agents outside
+// the host's agents list are refused, and it reports whether the host's
+// trust is "suggest" so the command waits for the owner. Commands the host
+// does not allow are left to the tool to refuse, without asking.
+func checkSSHExec(ctx context.Context, cfg *Config, task Task, tc ToolCall) (bool, error) {
+	var args struct {
+		Host    string `json:"host"`
+		Command string `json:"command"`
+	}
+	json.Unmarshal(tc.Input, &args)
+	h, err := sshexec.Check(cfg.SSH, args.Host, args.Command)
+	if err != nil {
+		return false, nil
+	}
+	if !sshexec.AgentAllowed(h, task.Agent) {
+		detail := fmt.Sprintf("host=%s command=%s task=%s agent=%s reason=agent not allowed", args.Host, args.Command, task.ID, task.Agent)
+		audit.LogCtx(ctx, historyDBForTask(cfg, task), "ssh.denied", "dispatch", detail, "")
+		return false, fmt.Errorf("agent %q may not use ssh host %q", task.Agent, args.Host)
+	}
+	return h.TrustOrDefault() == "suggest", nil
+}
+
+// checkDBQuery refuses db_query calls from agents outside the connection's
+// agents list.
+func checkDBQuery(ctx context.Context, cfg *Config, task Task, tc ToolCall) error {
+	var args struct {
+		Connection string `json:"connection"`
+	}
+	json.Unmarshal(tc.Input, &args)
+	c, ok := cfg.Databases.Connections[args.Connection]
+	if !ok || dbquery.AgentAllowed(c, task.Agent) {
+		return nil
+	}
+	detail := fmt.Sprintf("connection=%s task=%s agent=%s reason=agent not allowed", args.Connection, task.ID, task.Agent)
+	audit.LogCtx(ctx, historyDBForTask(cfg, task), "db.denied", "dispatch", detail, "")
+	return fmt.Errorf("agent %q may not query connection %q", task.Agent, args.Connection)
+}
+
+// previewLimit keeps a previewed payload, in characters, within chat
+// message limits (Discord allows 2000 characters).
+const previewLimit = 1500
+
+// previewToolCall renders an external action's payload for its approval
+// message: mail headers and body, a post's text, otherwise indented JSON.
+// Fields without a rendering of their own are listed after it so nothing
+// the tool will send is hidden. complete is false when the rendering had to
+// be cut to previewLimit.
+func previewToolCall(tc ToolCall) (preview string, complete bool) {
+	var args map[string]any
+	if err := json.Unmarshal(tc.Input, &args); err != nil || args == nil {
+		return fencePreview(string(tc.Input))
+	}
+	var fields []string
+	var text string
+	switch {
+	case strings.HasPrefix(tc.Name, "email_"):
+		fields, text = []string{"to", "cc", "bcc", "subject"}, "body"
+	case tc.Name == "tweet_schedule" || tc.Name == "tweet_post_thread":
+		return fencePreview(previewTweetThread(tc.Name, args))
+	case strings.HasPrefix(tc.Name, "tweet_"):
+		fields, text = []string{"reply_to", "tweet_id", "recipient_id"}, "text"
+	case strings.HasPrefix(tc.Name, "social_"):
+		fields, text = []string{"account", "reply_to", "post_id"}, "text"
+	default:
+		out, _ := json.MarshalIndent(args, "", "  ")
+		return fencePreview(string(out))
+	}
+
+	var b strings.Builder
+	for _, k := range fields {
+		if v, ok := args[k]; ok {
+			fmt.Fprintf(&b, "%s: %s\n", k, previewValue(v))
+			delete(args, k)
+		}
+	}
+	if v, ok := args[text]; ok {
+		fmt.Fprintf(&b, "\n%s\n", previewValue(v))
+		delete(args, text)
+	}
+	if len(args) > 0 {
+		rest, _ := json.MarshalIndent(args, "", "  ")
+		fmt.Fprintf(&b, "\n%s", rest)
+	}
+	return fencePreview(b.String())
+}
+
+func previewValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		parts := make([]string, len(v))
+		for i, x := range v {
+			parts[i] = previewValue(x)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+}
+
+// fencePreview trims a preview so it fits in a code block, cutting it to
+// previewLimit characters on a rune boundary. complete reports whether
+// nothing was cut.
+func fencePreview(s string) (preview string, complete bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "```", "'''")
+	if utf8.RuneCountInString(s) <= previewLimit {
+		return s, true
+	}
+	r := []rune(s)
+	return string(r[:previewLimit]) + "...", false
+}
+
+// ApprovalManager routes approval requests that are not tied to a single
+// chat (e.g. spend approval for HTTP/cron tasks) to the owner's channels.
+type ApprovalManager struct {
+	mu    sync.RWMutex
+	gates map[string]ApprovalGate // keyed by channel name
+	order []string                // registration order, used as fallback preference
+}
+
+// globalApprovalManager is the package-level approval manager, initialized in daemon mode.
+var globalApprovalManager *ApprovalManager
+
+func newApprovalManager() *ApprovalManager {
+	return &ApprovalManager{gates: make(map[string]ApprovalGate)}
+}
+
+// RegisterGate registers a channel's approval gate. Nil gates are ignored.
+func (m *ApprovalManager) RegisterGate(channel string, gate ApprovalGate) {
+	if gate == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.gates[channel]; !exists {
+		m.order = append(m.order, channel)
+	}
+	m.gates[channel] = gate
+	log.Debug("approval: registered gate", "channel", channel)
+}
+
+// Channels returns registered channel names in preference order.
+func (m *ApprovalManager) Channels() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.order...)
+}
+
+// Resolve picks a gate: the preferred channel if registered, then the
+// fallback gate (usually the task's own channel), then the first registered.
+func (m *ApprovalManager) Resolve(preferred string, fallback ApprovalGate) (string, ApprovalGate) {
+	if m != nil {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if g, ok := m.gates[preferred]; ok {
+			return preferred, g
+		}
+	}
+	if fallback != nil {
+		return "task", fallback
+	}
+	if m != nil && len(m.order) > 0 {
+		return m.order[0], m.gates[m.order[0]]
+	}
+	return "", nil
+}
+
 // getToolPolicySummary returns a human-readable summary of an agent's tool policy.
 func getToolPolicySummary(cfg *Config, agentName string) string {
 	policy := getAgentToolPolicy(cfg, agentName)
@@ -756,6 +1142,16 @@ func getToolPolicySummary(cfg *Config, agentName string) string {
 		parts = append(parts, fmt.Sprintf("Denied: %s", strings.Join(policy.Deny, ", ")))
 	}
 
+	// Allowlist.
+	if len(policy.Allowlist) > 0 {
+		parts = append(parts, fmt.Sprintf("Only: %s", strings.Join(policy.Allowlist, ", ")))
+	}
+
+	// Budget.
+	if n := toolCallLimit(cfg, agentName); n > 0 {
+		parts = append(parts, fmt.Sprintf("Max calls: %d per task", n))
+	}
+
 	return strings.Join(parts, " | ")
 }
 
@@ -1161,9 +1557,15 @@ func toolCronCreate(ctx context.Context, cfg *Config, input json.RawMessage) (st
 	var args struct {
 		Name     string `json:"name"`
 		Schedule string `json:"schedule"`
+		When     string `json:"when"`
 		Prompt   string `json:"prompt"`
 		Agent    string `json:"agent"`
 		Role     string `json:"role"` // backward compat
+
+		BusinessDaysOnly bool                `json:"businessDaysOnly"`
+		Holidays         string              `json:"holidays"`
+		QuietHours       *QuietWindow        `json:"quietHours"`
+		User             string              `json:"user"`
 	}
 	if err := json.Unmarshal(input, &args); err != nil {
 		return "", fmt.Errorf("invalid input: %w", err)
@@ -1171,8 +1573,20 @@ func toolCronCreate(ctx context.Context, cfg *Config, input json.RawMessage) (st
 	if args.Agent == "" {
 		args.Agent = args.Role
 	}
-	if args.Name == "" || args.Schedule == "" || args.Prompt == "" {
-		return "", fmt.Errorf("name, schedule, and prompt are required")
+	if args.Name == "" || (args.Schedule == "" && args.When == "") || args.Prompt == "" {
+		return "", fmt.Errorf("name, schedule or when, and prompt are required")
+	}
+	if err := cron.ValidateCalendar(CronJobConfig{Holidays: args.Holidays, QuietHours: args.QuietHours}); err != nil {
+		return "", err
+	}
+	once, interpreted := false, ""
+	if args.When != "" {
+		w, source, err := parseWhen(ctx, cfg, args.When, "")
+		if err != nil {
+			return "", fmt.Errorf("when: %w", err)
+		}
+		args.Schedule, once = w.Cron, w.Once()
+		interpreted = describeWhen(w, source, "")
 	}
 
 	jobs, err := loadCronJobs(cfg.JobsFile)
@@ -1188,6 +1602,11 @@ func toolCronCreate(ctx context.Context, cfg *Config, input json.RawMessage) (st
 			jobs[i].Task.Prompt = args.Prompt
 			jobs[i].Agent = args.Agent
 			jobs[i].Enabled = true
+			jobs[i].Once = once
+			jobs[i].BusinessDaysOnly = args.BusinessDaysOnly
+			jobs[i].Holidays = args.Holidays
+			jobs[i].QuietHours = args.QuietHours
+			jobs[i].User = args.User
 			found = true
 			break
 		}
@@ -1200,9 +1619,14 @@ func toolCronCreate(ctx context.Context, cfg *Config, input json.RawMessage) (st
 			Schedule: args.Schedule,
 			Enabled:  true,
 			Agent:    args.Agent,
+			Once:     once,
 			Task: CronTaskConfig{
 				Prompt: args.Prompt,
 			},
+			BusinessDaysOnly: args.BusinessDaysOnly,
+			Holidays:         args.Holidays,
+			QuietHours:       args.QuietHours,
+			User:             args.User,
 		}
 		jobs = append(jobs, newJob)
 	}
@@ -1215,6 +1639,9 @@ func toolCronCreate(ctx context.Context, cfg *Config, input json.RawMessage) (st
 	if found {
 		msg = "updated"
 	}
+	if interpreted != "" {
+		return fmt.Sprintf("cron job %q %s: %s", args.Name, msg, interpreted), nil
+	}
 	return fmt.Sprintf("cron job %q %s", args.Name, msg), nil
 }
 
@@ -34,14 +34,22 @@ import (
 	"tetora/internal/config"
 	dtypes "tetora/internal/dispatch"
 	"tetora/internal/cost"
+	"tetora/internal/cron"
+	tcrypto "tetora/internal/crypto"
 	"tetora/internal/db"
 	"tetora/internal/estimate"
 	"tetora/internal/history"
+	"tetora/internal/integration/notes"
 	"tetora/internal/knowledge"
+	"tetora/internal/memory"
+	"tetora/internal/life/calendar"
+	"tetora/internal/life/profile"
 	"tetora/internal/metrics"
+	"tetora/internal/notify"
 	"tetora/internal/provider"
 	"tetora/internal/retention"
 	"tetora/internal/scheduling"
+	"tetora/internal/session"
 	"tetora/internal/sla"
 	"tetora/internal/storage"
 	"tetora/internal/telemetry"
@@ -1528,6 +1536,78 @@ func TestMCPHostRestartServer(t *testing.T) {
 	host.Stop()
 }
 
+// TestMCPHostReload verifies that a config reload starts added servers,
+// swaps the tools of changed ones and unregisters removed ones.
+func TestMCPHostReload(t *testing.T) {
+	if os.Getenv("GO_TEST_HELPER_PROCESS") == "1" {
+		t.Skip("running as helper process")
+	}
+
+	helper := MCPServerConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^$"},
+		Env:     map[string]string{"GO_TEST_HELPER_PROCESS": "1"},
+	}
+	cfg := &Config{}
+	toolReg := NewToolRegistry(cfg)
+	host := newMCPHost(cfg, toolReg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := host.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer host.Stop()
+
+	res := host.Reload(&Config{MCPServers: map[string]MCPServerConfig{"live": helper}})
+	if len(res.Started) != 1 || len(res.Failed) != 0 {
+		t.Fatalf("add: %+v", res)
+	}
+	if _, ok := toolReg.Get("mcp:live:mock_tool"); !ok {
+		t.Fatal("tool of added server not registered")
+	}
+	first := host.GetServer("live")
+
+	changed := helper
+	changed.Env = map[string]string{"GO_TEST_HELPER_PROCESS": "1", "MODE": "changed"}
+	res = host.Reload(&Config{MCPServers: map[string]MCPServerConfig{"live": changed}})
+	if len(res.Restarted) != 1 {
+		t.Fatalf("change: %+v", res)
+	}
+	if host.GetServer("live") == first {
+		t.Error("changed server should be replaced")
+	}
+	first.Mu.Lock()
+	oldStatus := first.Status
+	first.Mu.Unlock()
+	if oldStatus != "stopped" {
+		t.Errorf("old process status = %q, want stopped", oldStatus)
+	}
+	if tool, ok := toolReg.Get("mcp:live:mock_tool"); !ok {
+		t.Fatal("tool of changed server missing")
+	} else if out, err := tool.Handler(ctx, cfg, json.RawMessage(`{}`)); err != nil || !strings.Contains(out, "mock result") {
+		t.Errorf("tool call after swap = %q, %v", out, err)
+	}
+
+	bad := helper
+	bad.Command = filepath.Join(t.TempDir(), "missing")
+	res = host.Reload(&Config{MCPServers: map[string]MCPServerConfig{"live": bad}})
+	if res.Failed["live"] == "" {
+		t.Fatalf("broken change should fail: %+v", res)
+	}
+	if _, ok := toolReg.Get("mcp:live:mock_tool"); !ok {
+		t.Error("a failed restart should keep the old server's tools")
+	}
+
+	res = host.Reload(&Config{})
+	if len(res.Stopped) != 1 || host.GetServer("live") != nil {
+		t.Fatalf("remove: %+v", res)
+	}
+	if _, ok := toolReg.Get("mcp:live:mock_tool"); ok {
+		t.Error("tool of removed server should be unregistered")
+	}
+}
+
 // --- from oauth_test.go ---
 
 // --- P18.2: OAuth 2.0 Framework Tests ---
@@ -8199,6 +8279,94 @@ func TestMemoryCreatedAtPreservedOnUpdate(t *testing.T) {
 	}
 }
 
+func TestMemoryAging(t *testing.T) {
+	cfg := tempMemoryCfg(t)
+	dir := filepath.Join(cfg.WorkspaceDir, "memory")
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	setMemory(cfg, "", "office", "The office is on floor 3.", "P0")
+	if err := setMemoryAging(cfg, "office", 48*time.Hour, 0.8); err != nil {
+		t.Fatalf("setMemoryAging: %v", err)
+	}
+	setMemory(cfg, "", "office", "The office is on floor 4.")
+	m := parseMemoryMeta(mustRead(t, filepath.Join(dir, "office.md")))
+	if m.Priority != "P0" || m.Confidence != 0.8 || m.ExpiresAt == "" || m.CreatedAt == "" || m.Body != "The office is on floor 4." {
+		t.Errorf("meta not preserved on update: %+v", m)
+	}
+	if err := setMemoryAging(cfg, "missing", time.Hour, 0); err == nil {
+		t.Error("aging a missing entry succeeded")
+	}
+
+	os.WriteFile(filepath.Join(dir, "old_plan.md"), []byte("---\nexpires_at: "+past+"\n---\nalpha plan"), 0o644)
+	os.WriteFile(filepath.Join(dir, "rumor.md"), []byte("---\nconfidence: 0.2\n---\nalpha rumor"), 0o644)
+	os.WriteFile(filepath.Join(dir, "fact.md"), []byte("alpha fact"), 0o644)
+
+	results, _ := searchMemoryFS(cfg, "", "alpha")
+	if len(results) != 1 || results[0].Key != "fact" {
+		t.Errorf("search should skip expired and low-confidence entries: %+v", results)
+	}
+	if got := expandPrompt("{{memory.old_plan}}|{{memory.rumor}}|{{memory.fact}}", "", "", "amber", "", cfg); got != "||alpha fact" {
+		t.Errorf("template expansion = %q", got)
+	}
+	entries, _ := listMemory(cfg, "")
+	for _, e := range entries {
+		if e.Key == "old_plan" && !e.Expired || e.Key == "rumor" && e.Confidence != 0.2 || e.Key == "fact" && e.Confidence != 1 {
+			t.Errorf("listed entry %+v", e)
+		}
+	}
+
+	// Rewriting an expired entry revives it.
+	setMemory(cfg, "", "old_plan", "alpha plan, renewed")
+	if got := getMemoryForPrompt(cfg, "", "old_plan"); got != "alpha plan, renewed" {
+		t.Errorf("revived entry = %q", got)
+	}
+
+	applyMemoryVerdict(cfg, memory.Verdict{Key: "fact", Verdict: memory.VerdictExpire})
+	applyMemoryVerdict(cfg, memory.Verdict{Key: "rumor", Verdict: memory.VerdictUpdate, Value: "alpha confirmed", Confidence: 0.9})
+	if getMemoryForPrompt(cfg, "", "fact") != "" {
+		t.Error("expire verdict did not expire the entry")
+	}
+	m = parseMemoryMeta(mustRead(t, filepath.Join(dir, "rumor.md")))
+	if m.Body != "alpha confirmed" || m.Confidence != 0.9 || m.ReviewedAt == "" {
+		t.Errorf("update verdict: %+v", m)
+	}
+}
+
+func TestMemoryRoleVisibility(t *testing.T) {
+	cfg := tempMemoryCfg(t)
+	dir := filepath.Join(cfg.WorkspaceDir, "memory")
+	os.WriteFile(filepath.Join(dir, "diary.md"), []byte("---\nrole: hisui\nnamespace: personal\n---\nbeta diary"), 0o644)
+	os.WriteFile(filepath.Join(dir, "shared.md"), []byte("beta shared"), 0o644)
+
+	if got := getMemoryForPrompt(cfg, "kokuyou", "diary"); got != "" {
+		t.Errorf("other agent sees owned entry: %q", got)
+	}
+	if got := getMemoryForPrompt(cfg, "hisui", "diary"); got != "beta diary" {
+		t.Errorf("owner sees %q", got)
+	}
+	if results, _ := searchMemoryFS(cfg, "kokuyou", "beta"); len(results) != 1 || results[0].Key != "shared" {
+		t.Errorf("search for other agent = %+v", results)
+	}
+	entries, _ := listMemory(cfg, "hisui")
+	if len(entries) != 2 {
+		t.Fatalf("owner lists %+v", entries)
+	}
+	for _, e := range entries {
+		if e.Key == "diary" && (e.Role != "hisui" || e.Namespace != "personal") {
+			t.Errorf("diary entry = %+v", e)
+		}
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
 func TestSearchMemoryDecayOrdering(t *testing.T) {
 	cfg := tempMemoryCfg(t)
 
@@ -10262,6 +10430,88 @@ func TestQueueMaxItems(t *testing.T) {
 	}
 }
 
+func TestEnqueueItemDedup(t *testing.T) {
+	dbPath := tempQueueDB(t)
+	item := func(prio int) QueueItem {
+		return QueueItem{TaskJSON: `{"name":"digest"}`, AgentName: "琉璃", Source: "cron",
+			Priority: prio, DedupKey: dtypes.QueueDedupKey("琉璃", "summarize")}
+	}
+
+	id, dup, err := dtypes.EnqueueItem(dbPath, item(-1), 10*time.Minute)
+	if err != nil || dup {
+		t.Fatalf("first EnqueueItem = %d, %v, %v", id, dup, err)
+	}
+	// The same prompt again folds into the pending item and raises its priority.
+	id2, dup, err := dtypes.EnqueueItem(dbPath, item(1), 10*time.Minute)
+	if err != nil || !dup || id2 != id {
+		t.Fatalf("duplicate EnqueueItem = %d, %v, %v (first id %d)", id2, dup, err, id)
+	}
+	pending := queryQueue(dbPath, "pending")
+	if len(pending) != 1 || pending[0].Priority != 1 {
+		t.Fatalf("pending = %+v, want one item with priority 1", pending)
+	}
+	// A lower priority does not lower it again.
+	dtypes.EnqueueItem(dbPath, item(-1), 10*time.Minute)
+	if got := queryQueueItem(dbPath, id); got.Priority != 1 {
+		t.Errorf("priority = %d after lower-priority duplicate", got.Priority)
+	}
+
+	// Another agent, or dedup disabled, queues a new item.
+	other := item(0)
+	other.AgentName, other.DedupKey = "黒曜", dtypes.QueueDedupKey("黒曜", "summarize")
+	if _, dup, _ := dtypes.EnqueueItem(dbPath, other, 10*time.Minute); dup {
+		t.Error("other agent's task deduplicated")
+	}
+	if _, dup, _ := dtypes.EnqueueItem(dbPath, item(0), 0); dup {
+		t.Error("deduplicated with window 0")
+	}
+	if n := len(queryQueue(dbPath, "pending")); n != 3 {
+		t.Errorf("pending = %d, want 3", n)
+	}
+}
+
+func TestDequeueNotBeforeAndBusyAgents(t *testing.T) {
+	dbPath := tempQueueDB(t)
+	enqueueTask(dbPath, Task{Name: "later", Source: "test"}, "琉璃", 10)
+	enqueueTask(dbPath, Task{Name: "busy", Source: "test"}, "黒曜", 5)
+	enqueueTask(dbPath, Task{Name: "now", Source: "test"}, "翡翠", 0)
+
+	later := queryQueue(dbPath, "pending")[0]
+	if !taskNameFromJSON(later.TaskJSON, "later") {
+		t.Fatalf("first pending = %v", taskNameFromQueueItem(&later))
+	}
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if err := rescheduleQueueItem(dbPath, later.ID, later.Priority, future); err != nil {
+		t.Fatal(err)
+	}
+
+	// "later" is not due and 黒曜 is at its limit, so "now" goes first.
+	item := dtypes.DequeueNextExcept(dbPath, []string{"黒曜"})
+	if item == nil || !taskNameFromJSON(item.TaskJSON, "now") {
+		t.Fatalf("dequeue = %v, want now", taskNameFromQueueItem(item))
+	}
+	if item := dtypes.DequeueNextExcept(dbPath, []string{"黒曜"}); item != nil {
+		t.Fatalf("dequeue = %v, want nothing", taskNameFromQueueItem(item))
+	}
+	item = dequeueNext(dbPath)
+	if item == nil || !taskNameFromJSON(item.TaskJSON, "busy") {
+		t.Fatalf("dequeue = %v, want busy", taskNameFromQueueItem(item))
+	}
+
+	// A deferred item stays pending until its backoff has passed.
+	dtypes.DeferQueueItem(dbPath, item.ID, time.Now().Add(time.Hour), "still down")
+	if got := queryQueueItem(dbPath, item.ID); got.Status != "pending" || got.RetryCount != 1 || got.NotBefore == "" {
+		t.Errorf("deferred item = %+v", got)
+	}
+	if item := dequeueNext(dbPath); item != nil {
+		t.Errorf("dequeued %v before notBefore", taskNameFromQueueItem(item))
+	}
+	rescheduleQueueItem(dbPath, later.ID, later.Priority, "")
+	if item := dequeueNext(dbPath); item == nil || item.ID != later.ID {
+		t.Errorf("dequeue after clearing notBefore = %v", taskNameFromQueueItem(item))
+	}
+}
+
 func TestIsAllProvidersUnavailable(t *testing.T) {
 	tests := []struct {
 		err  string
@@ -12417,6 +12667,59 @@ func TestWriteDailyNote(t *testing.T) {
 	}
 }
 
+func TestToolNoteAndJobNote(t *testing.T) {
+	tmpDir := t.TempDir()
+	svc, err := notes.New(NotesConfig{Enabled: true, VaultPath: tmpDir}, tmpDir, notes.Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := globalNotes
+	globalNotes = svc
+	defer func() { globalNotes = old }()
+	ctx := context.Background()
+
+	if _, err := toolNote(ctx, "create", json.RawMessage(`{"name":"ideas","content":"#go see [[roadmap]]"}`)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	out, err := toolNote(ctx, "read", json.RawMessage(`{"name":"ideas"}`))
+	if err != nil || !strings.Contains(out, `"tags":["go"]`) || !strings.Contains(out, `"links":["roadmap"]`) {
+		t.Errorf("read = %s, %v", out, err)
+	}
+	if _, err := toolNote(ctx, "read", json.RawMessage(`{"name":"../etc/passwd"}`)); err == nil {
+		t.Error("read outside the vault succeeded")
+	}
+
+	if err := saveJobNote(ctx, "briefings/{{date}}", "Morning briefing", "3 meetings today\n"); err != nil {
+		t.Fatalf("saveJobNote: %v", err)
+	}
+	name := "briefings/" + time.Now().Format("2006-01-02")
+	content, err := svc.ReadNote(ctx, name)
+	if err != nil || !strings.HasPrefix(content, "## Morning briefing — ") || !strings.Contains(content, "\n\n3 meetings today\n") {
+		t.Errorf("job note = %q, %v", content, err)
+	}
+	out, err = toolNote(ctx, "list", json.RawMessage(`{"prefix":"briefings"}`))
+	if err != nil || !strings.Contains(out, `"count":1`) {
+		t.Errorf("list = %s, %v", out, err)
+	}
+}
+
+func TestSetNoteTimeline(t *testing.T) {
+	section := "## Timeline — 2026-03-14\n\n- 08:00 ✅ **brief**\n"
+	tests := []struct {
+		note, want string
+	}{
+		{"", section},
+		{"# Daily Summary\n\nText.\n", "# Daily Summary\n\nText.\n\n" + section},
+		{"# Daily\n\n## Timeline — old\n- stale\n## Notes\nmine\n", "# Daily\n\n" + section + "## Notes\nmine\n"},
+		{"# Daily\n\n## Timeline — old\n- stale\n", "# Daily\n\n" + section},
+	}
+	for _, tt := range tests {
+		if got := setNoteTimeline(tt.note, section); got != tt.want {
+			t.Errorf("setNoteTimeline(%q) = %q, want %q", tt.note, got, tt.want)
+		}
+	}
+}
+
 func dailyNoteContains(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 && len(s) >= len(substr) && dailyNoteFindSubstring(s, substr)
 }
@@ -12628,6 +12931,44 @@ func TestKnowledgeDirHasFiles(t *testing.T) {
 	}
 }
 
+func TestKnowledgeSearchAgentScope(t *testing.T) {
+	kDir := t.TempDir()
+	os.MkdirAll(filepath.Join(kDir, "hr"), 0o755)
+	os.WriteFile(filepath.Join(kDir, "hr", "leave.md"), []byte("# Leave\n## Carry-over\nFive vacation days carry over.\n"), 0o644)
+	os.WriteFile(filepath.Join(kDir, "eng.md"), []byte("# Deploys\nNo deploys during vacation weeks.\n"), 0o644)
+	cfg := &Config{
+		KnowledgeDir: kDir,
+		Agents: map[string]AgentConfig{
+			"hr": {Knowledge: &config.AgentKnowledgeConfig{Dirs: []string{"hr"}}},
+		},
+	}
+
+	search := func(agent string) string {
+		ctx := withToolAgent(context.Background(), agent)
+		out, err := toolKnowledgeSearch(ctx, cfg, json.RawMessage(`{"query":"vacation"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if out := search("hr"); !strings.Contains(out, `"citation":"[source: hr/leave.md § Carry-over]"`) || strings.Contains(out, "eng.md") {
+		t.Errorf("scoped search = %s", out)
+	}
+	if out := search(""); !strings.Contains(out, "eng.md") || !strings.Contains(out, "hr/leave.md") {
+		t.Errorf("unscoped search = %s", out)
+	}
+
+	output := "Five days [source: hr/leave.md § Carry-over]; no deploys [source: eng.md § Deploys]."
+	cites := extractCitations(cfg, "hr", output)
+	if len(cites) != 1 || cites[0].File != "hr/leave.md" || cites[0].Heading != "Carry-over" {
+		t.Fatalf("citations = %+v", cites)
+	}
+	reply := chatReply(TaskResult{Output: output, Citations: cites})
+	if !strings.HasPrefix(reply, "Five days [1]; no deploys [source: eng.md § Deploys].") || !strings.HasSuffix(reply, "Sources:\n[1] hr/leave.md § Carry-over") {
+		t.Errorf("reply = %q", reply)
+	}
+}
+
 func TestKnowledgeDirHasFilesNonExistent(t *testing.T) {
 	if knowledge.HasFiles("/nonexistent/knowledge") {
 		t.Error("expected false for nonexistent dir")
@@ -13431,30 +13772,44 @@ func TestResolvePromptFile(t *testing.T) {
 	writePrompt(cfg, "my-prompt", "resolved content here")
 
 	// With .md extension.
-	content, err := resolvePromptFile(cfg, "my-prompt.md")
+	res, err := resolvePromptFile(cfg, "my-prompt.md", nil)
 	if err != nil {
 		t.Fatalf("resolvePromptFile with .md: %v", err)
 	}
-	if content != "resolved content here" {
-		t.Errorf("got %q", content)
+	if res.Text != "resolved content here" {
+		t.Errorf("got %q", res.Text)
 	}
 
 	// Without .md extension.
-	content, err = resolvePromptFile(cfg, "my-prompt")
+	res, err = resolvePromptFile(cfg, "my-prompt", nil)
 	if err != nil {
 		t.Fatalf("resolvePromptFile without .md: %v", err)
 	}
-	if content != "resolved content here" {
-		t.Errorf("got %q", content)
+	if res.Text != "resolved content here" {
+		t.Errorf("got %q", res.Text)
 	}
 
 	// Empty promptFile.
-	content, err = resolvePromptFile(cfg, "")
+	res, err = resolvePromptFile(cfg, "", nil)
 	if err != nil {
 		t.Fatalf("resolvePromptFile empty: %v", err)
 	}
-	if content != "" {
-		t.Errorf("expected empty, got %q", content)
+	if res.Text != "" {
+		t.Errorf("expected empty, got %q", res.Text)
+	}
+
+	// Templates get the job's variables, includes and frontmatter model.
+	writePrompt(cfg, "sig", "-- {{name}}")
+	writePrompt(cfg, "templated", "---\nmodel: haiku\nrequired: [name]\n---\nHello {{name}} on {{date}}\n{{> sig}}")
+	res, err = resolvePromptFile(cfg, "templated", map[string]string{"name": "Ruri"})
+	if err != nil {
+		t.Fatalf("resolvePromptFile template: %v", err)
+	}
+	if res.Text != "Hello Ruri on {{date}}\n-- Ruri" || res.Model != "haiku" {
+		t.Errorf("got %+v", res)
+	}
+	if _, err := resolvePromptFile(cfg, "templated", nil); err == nil {
+		t.Error("expected error for missing required variable")
 	}
 }
 
@@ -14038,12 +14393,13 @@ CREATE TABLE IF NOT EXISTS job_runs (
   tokens_in INTEGER DEFAULT 0, tokens_out INTEGER DEFAULT 0,
   agent TEXT DEFAULT '', parent_id TEXT DEFAULT '',
   provider TEXT DEFAULT '',
-  prompt_manifest_file TEXT DEFAULT ''
+  prompt_manifest_file TEXT DEFAULT '',
+  node TEXT DEFAULT ''
 );
 CREATE TABLE IF NOT EXISTS audit_log (
   id INTEGER PRIMARY KEY AUTOINCREMENT,
   timestamp TEXT NOT NULL, action TEXT NOT NULL,
-  source TEXT NOT NULL DEFAULT '', detail TEXT DEFAULT '', ip TEXT DEFAULT ''
+  source TEXT NOT NULL DEFAULT '', detail TEXT DEFAULT '', ip TEXT DEFAULT '', identity TEXT DEFAULT ''
 );
 CREATE TABLE IF NOT EXISTS sessions (
   id TEXT PRIMARY KEY, agent TEXT NOT NULL DEFAULT '',
@@ -14101,6 +14457,12 @@ CREATE TABLE IF NOT EXISTS trust_events (
   consecutive_success INTEGER DEFAULT 0,
   created_at TEXT NOT NULL, note TEXT DEFAULT ''
 );
+CREATE TABLE IF NOT EXISTS trace_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  trace_id TEXT NOT NULL, kind TEXT NOT NULL, name TEXT NOT NULL DEFAULT '',
+  status TEXT DEFAULT '', detail TEXT DEFAULT '',
+  started_at TEXT NOT NULL, duration_ms INTEGER DEFAULT 0
+);
 CREATE TABLE IF NOT EXISTS config_versions (
   id INTEGER PRIMARY KEY AUTOINCREMENT,
   entity_type TEXT NOT NULL, entity_name TEXT NOT NULL DEFAULT '',
@@ -14917,6 +15279,35 @@ func TestToolScheduleView_Defaults(t *testing.T) {
 	}
 }
 
+func TestToolScheduleView_Calendar(t *testing.T) {
+	_, cleanup := setupSchedulingTest(t)
+	defer cleanup()
+
+	start := time.Date(2026, 3, 15, 10, 0, 0, 0, time.Local).UTC()
+	feed := filepath.Join(t.TempDir(), "work.ics")
+	os.WriteFile(feed, []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:r1\r\n"+
+		"DTSTART:"+start.Format("20060102T150405Z")+"\r\nDTEND:"+start.Add(time.Hour).Format("20060102T150405Z")+"\r\n"+
+		"SUMMARY:Design review\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), 0o644)
+	oldCal := globalCalendarService
+	globalCalendarService = calendar.New(CalendarConfig{
+		Enabled:  true,
+		Accounts: []config.CalendarAccount{{Name: "work", Type: "ics", URL: feed}},
+	}, nil)
+	defer func() { globalCalendarService = oldCal }()
+
+	result, err := toolScheduleView(context.Background(), &Config{}, json.RawMessage(`{"date": "2026-03-15"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var schedules []DaySchedule
+	if err := json.Unmarshal([]byte(result), &schedules); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(schedules) != 1 || len(schedules[0].Events) != 1 || schedules[0].Events[0].Title != "Design review" {
+		t.Errorf("schedule = %+v", schedules)
+	}
+}
+
 func TestToolScheduleView_NotInitialized(t *testing.T) {
 	old := globalSchedulingService
 	globalSchedulingService = nil
@@ -16454,8 +16845,8 @@ func TestCompletionSubActions(t *testing.T) {
 		{"workflow", []string{"list", "show", "validate", "create", "delete", "run", "runs", "status", "messages", "history", "rollback", "diff"}},
 		{"knowledge", []string{"list", "add", "remove", "path", "search"}},
 		{"history", []string{"list", "show", "cost"}},
-		{"config", []string{"show", "set", "validate", "migrate", "history", "rollback", "diff", "snapshot", "show-version", "versions"}},
-		{"data", []string{"status", "cleanup", "export", "purge"}},
+		{"config", []string{"show", "set", "validate", "lint", "schema", "convert", "profiles", "migrate", "history", "rollback", "diff", "snapshot", "show-version", "versions"}},
+		{"data", []string{"status", "cleanup", "export", "erase", "verify", "purge"}},
 		{"prompt", []string{"list", "show", "add", "edit", "remove"}},
 		{"memory", []string{"list", "get", "set", "delete"}},
 		{"mcp", []string{"list", "show", "add", "remove", "test"}},
@@ -17496,6 +17887,25 @@ func TestSessionConfigDefaults(t *testing.T) {
 	}
 }
 
+func TestSessionCompactionPolicy(t *testing.T) {
+	c := SessionConfig{CompactKeep: 6, Compaction: CompactionConfig{
+		TriggerTokens: 8000,
+		Agents: map[string]config.CompactionPolicy{
+			"kokuyou": {KeepTurns: 8},
+			"hisui":   {TriggerTokens: 2000, Disabled: true},
+		},
+	}}
+	if p := c.CompactionPolicy("ruri"); p.TriggerTokens != 8000 || p.Disabled || c.CompactKeepFor("ruri") != 6 {
+		t.Errorf("default policy = %+v keep %d", p, c.CompactKeepFor("ruri"))
+	}
+	if p := c.CompactionPolicy("kokuyou"); p.TriggerTokens != 8000 || c.CompactKeepFor("kokuyou") != 16 {
+		t.Errorf("kokuyou policy = %+v keep %d", p, c.CompactKeepFor("kokuyou"))
+	}
+	if p := c.CompactionPolicy("hisui"); p.TriggerTokens != 2000 || !p.Disabled {
+		t.Errorf("hisui policy = %+v", p)
+	}
+}
+
 func TestChannelKeyInQuerySessions(t *testing.T) {
 	skipIfNoSQLite(t)
 	dbPath := filepath.Join(t.TempDir(), "test.db")
@@ -17978,3 +18388,379 @@ func TestRecordSessionActivityCtxGoroutineBudget(t *testing.T) {
 		t.Errorf("goroutine leak: before=%d after=%d (ctx was cancelled 500ms ago)", before, after)
 	}
 }
+
+func TestRedactFor_TargetsAndBypass(t *testing.T) {
+	cfg := &Config{}
+	cfg.Security.Redaction = config.RedactionConfig{
+		Enabled: true,
+		Targets: []string{"history", "notifications"},
+		Bypass:  []string{"kokuyou"},
+	}
+	cfg.Retention.PIIPatterns = []string{`ACME-\d+`}
+	installRedaction(cfg)
+	defer installRedaction(&Config{})
+
+	in := "reach jane@example.com about ACME-42"
+	want := "reach [REDACTED:email] about [REDACTED:pii]"
+	if got := history.RedactFn("ruri", in); got != want {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	if got := notify.RedactFn("ruri", in); got != want {
+		t.Errorf("notifications = %q, want %q", got, want)
+	}
+	if got := session.RedactFn("ruri", in); got != in {
+		t.Errorf("sessions not targeted but redacted: %q", got)
+	}
+	if got := history.RedactFn("kokuyou", in); got != in {
+		t.Errorf("bypassed agent redacted: %q", got)
+	}
+}
+
+func TestModulateResponse(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "modulation.db")
+	if err := initSessionDB(dbPath); err != nil {
+		t.Fatalf("initSessionDB: %v", err)
+	}
+	if err := profile.InitDB(dbPath); err != nil {
+		t.Fatalf("profile.InitDB: %v", err)
+	}
+	now := time.Now().Format(time.RFC3339)
+	createSession(dbPath, Session{
+		ID: "sess-mod", Agent: "ruri", Source: "discord", Status: "active",
+		ChannelKey: "discord:42", CreatedAt: now, UpdatedAt: now,
+	})
+
+	cfg := &Config{HistoryDB: dbPath}
+	cfg.UserProfile = config.UserProfileConfig{Enabled: true, SentimentEnabled: true, AdaptPersonality: true}
+	globalUserProfiles = newProfileService(cfg)
+	defer func() { globalUserProfiles = nil }()
+
+	task := &Task{ID: "t1", SessionID: "sess-mod", Prompt: "this is terrible, I need it fixed asap"}
+	m := modulateResponse(cfg, task, "ruri")
+	if m == nil || m.Brevity != "short" || m.Empathy != "high" {
+		t.Fatalf("modulation = %+v", m)
+	}
+	userID, _ := globalUserProfiles.ResolveUser("discord:42")
+	logged, err := globalUserProfiles.Modulations(userID, 5)
+	if err != nil || len(logged) != 1 || logged[0].TaskID != "t1" || len(logged[0].Reasons) == 0 {
+		t.Errorf("modulation log = %+v, %v", logged, err)
+	}
+	if mood, n := globalUserProfiles.AverageMood(userID, 1); n != 1 || mood >= 0 {
+		t.Errorf("mood = %v over %d messages", mood, n)
+	}
+
+	// The user turns it off: no modulation, but mood is still tracked.
+	if reply := modulationCommand("discord:42", "!style", "off"); !strings.Contains(reply, "no longer") {
+		t.Errorf("off reply %q", reply)
+	}
+	if m := modulateResponse(cfg, &Task{ID: "t2", SessionID: "sess-mod", Prompt: "awful, hurry"}, "ruri"); m != nil {
+		t.Errorf("modulated after opting out: %+v", m)
+	}
+	if _, n := globalUserProfiles.AverageMood(userID, 1); n != 2 {
+		t.Errorf("mood samples = %d, want 2", n)
+	}
+
+	// Tasks outside a chat session are left alone.
+	if m := modulateResponse(cfg, &Task{ID: "t3", Prompt: "asap"}, "ruri"); m != nil {
+		t.Errorf("modulated a task without a session: %+v", m)
+	}
+}
+
+func TestToolCronCreateWhen(t *testing.T) {
+	cfg := &Config{JobsFile: filepath.Join(t.TempDir(), "jobs.json")}
+	ctx := context.Background()
+
+	out, err := toolCronCreate(ctx, cfg, json.RawMessage(`{"name":"standup","when":"every weekday at 9am","prompt":"Post the standup"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "every weekday at 09:00 (0 9 * * 1-5), next run") {
+		t.Errorf("confirmation = %q", out)
+	}
+	out, err = toolCronCreate(ctx, cfg, json.RawMessage(`{"name":"tea","when":"in 20 minutes","prompt":"Remind me the tea is ready"}`))
+	if err != nil || !strings.Contains(out, "once on ") {
+		t.Fatalf("one-off = %q, %v", out, err)
+	}
+	if _, err := toolCronCreate(ctx, cfg, json.RawMessage(`{"name":"x","when":"every 7 minutes","prompt":"p"}`)); err == nil {
+		t.Error("unschedulable phrase accepted")
+	}
+
+	jobs, err := loadCronJobs(cfg.JobsFile)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("jobs = %+v, %v", jobs, err)
+	}
+	if jobs[0].Schedule != "0 9 * * 1-5" || jobs[0].Once {
+		t.Errorf("standup job = %+v", jobs[0])
+	}
+	if !jobs[1].Once {
+		t.Errorf("one-off job not marked once: %+v", jobs[1])
+	}
+	if _, err := cron.Parse(jobs[1].Schedule); err != nil {
+		t.Errorf("one-off schedule %q: %v", jobs[1].Schedule, err)
+	}
+}
+
+func TestNewBackupSchedulerRemotes(t *testing.T) {
+	cfg := &Config{BaseDir: t.TempDir(), HistoryDB: "history.db"}
+	cfg.Ops.BackupRetain = 14
+	cfg.Ops.BackupRemotes = []config.BackupRemoteConfig{
+		{ObjectStoreConfig: config.ObjectStoreConfig{Bucket: "b1", AccessKey: "a", SecretKey: "s"}, Outputs: true},
+		{Name: "bad", ObjectStoreConfig: config.ObjectStoreConfig{Bucket: "b2", Provider: "b2"}},
+		{Name: "gcs", ObjectStoreConfig: config.ObjectStoreConfig{Bucket: "b3", Provider: "gcs", OAuthService: "google"}, RetainDays: 30},
+	}
+	bs := newBackupScheduler(cfg)
+
+	r, err := bs.Remote("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "b1" || r.RetainDays != 14 || r.OutputsDir != filepath.Join(cfg.BaseDir, "outputs") {
+		t.Errorf("default remote = %+v", r)
+	}
+	if _, err := bs.Remote("bad"); err == nil {
+		t.Error("invalid remote should be left out")
+	}
+	if r, err := bs.Remote("gcs"); err != nil || r.RetainDays != 30 || r.OutputsDir != "" {
+		t.Errorf("gcs remote = %+v, %v", r, err)
+	}
+
+	if got := flagValue([]string{"list", "--from-remote", "--remote=gcs"}, "--remote"); got != "gcs" {
+		t.Errorf("flagValue = %q", got)
+	}
+}
+
+func TestNewReplicationStore(t *testing.T) {
+	cfg := &Config{BaseDir: t.TempDir()}
+	cfg.Ops.BackupRemotes = []config.BackupRemoteConfig{
+		{ObjectStoreConfig: config.ObjectStoreConfig{Bucket: "b1", AccessKey: "a", SecretKey: "s"}},
+	}
+	if _, err := newReplicationStore(cfg); err == nil {
+		t.Error("expected error without remote or path")
+	}
+	cfg.Ops.Replication.Path = filepath.Join(cfg.BaseDir, "replica")
+	if s, err := newReplicationStore(cfg); err != nil || fmt.Sprint(s) != cfg.Ops.Replication.Path {
+		t.Errorf("path store = %v, %v", s, err)
+	}
+	cfg.Ops.Replication.Remote = "b1"
+	if s, err := newReplicationStore(cfg); err != nil || fmt.Sprint(s) != "s3://b1/" {
+		t.Errorf("remote store = %v, %v", s, err)
+	}
+	cfg.Ops.Replication.Remote = "missing"
+	if _, err := newReplicationStore(cfg); err == nil {
+		t.Error("expected error for unknown remote")
+	}
+
+	if at, err := parsePITR("latest"); err != nil || !at.IsZero() {
+		t.Errorf("latest = %v, %v", at, err)
+	}
+	if at, err := parsePITR("2026-10-15T03:00:00Z"); err != nil || !at.Equal(time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("rfc3339 = %v, %v", at, err)
+	}
+	if at, err := parsePITR("2026-10-15 03:00"); err != nil || !at.Equal(time.Date(2026, 10, 15, 3, 0, 0, 0, time.Local)) {
+		t.Errorf("local = %v, %v", at, err)
+	}
+	if _, err := parsePITR("yesterday"); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestEncryptionAtRestRotate(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	dir := t.TempDir()
+	cfg := &Config{BaseDir: dir, WorkspaceDir: filepath.Join(dir, "workspace"), HistoryDB: filepath.Join(dir, "history.db")}
+	cfg.EncryptionKey = "legacy"
+	cfg.Encryption.MasterKey = "master-1"
+	t.Cleanup(func() { setAtRestKeyring(nil) })
+
+	legacy, _ := tcrypto.Encrypt("from legacy", "legacy")
+	if err := db.Exec(cfg.HistoryDB, fmt.Sprintf(`CREATE TABLE session_messages (id INTEGER PRIMARY KEY, content TEXT);
+		INSERT INTO session_messages (content) VALUES ('%s'), ('plain text');`, legacy)); err != nil {
+		t.Fatal(err)
+	}
+	if err := initAtRestEncryption(cfg); err != nil {
+		t.Fatal(err)
+	}
+	first := atRestKeyring().ActiveID()
+	if first == "" {
+		t.Fatal("no data key created")
+	}
+
+	// New values are sealed; legacy and plaintext values still read.
+	sealed, err := encryptAtRest("new message", "legacy")
+	if err != nil || tcrypto.SealedKeyID(sealed) != first {
+		t.Fatalf("encryptAtRest = %q, %v", sealed, err)
+	}
+	if got, _ := decryptAtRest(legacy, "legacy"); got != "from legacy" {
+		t.Errorf("legacy value = %q", got)
+	}
+	db.Exec(cfg.HistoryDB, fmt.Sprintf(`INSERT INTO session_messages (content) VALUES ('%s')`, sealed))
+
+	if err := setMemory(cfg, "", "pin", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(cfg.WorkspaceDir, "memory", "pin.md"))
+	if strings.Contains(string(raw), "1234") {
+		t.Errorf("memory file holds the value in the clear: %s", raw)
+	}
+	if v, _ := getMemory(cfg, "", "pin"); v != "1234" {
+		t.Errorf("getMemory = %q", v)
+	}
+
+	uploads := upload.InitDir(dir)
+	old := filepath.Join(uploads, "20260101-000000_scan.pdf")
+	os.WriteFile(old, []byte("%PDF"), 0o644)
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(old, past, past)
+	os.WriteFile(filepath.Join(uploads, "fresh.txt"), []byte("fresh"), 0o644)
+	sealUploads(cfg)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("old upload was not replaced")
+	}
+	if _, err := os.Stat(filepath.Join(uploads, "fresh.txt")); err != nil {
+		t.Error("fresh upload was sealed too early")
+	}
+
+	// Rotating under a replaced master key moves everything to a new key.
+	t.Setenv("TETORA_OLD_MASTER", "master-1")
+	cfg.Encryption.MasterKey = "master-2"
+	if _, err := openKeyring(cfg); err == nil {
+		t.Fatal("expected error opening the keyring with the new master key")
+	}
+	cmdEncryptionRotate(cfg, []string{"--old-master-key=TETORA_OLD_MASTER"})
+
+	keys, _ := loadDataKeys(cfg.HistoryDB)
+	if len(keys) != 1 || keys[0].ID == first || !keys[0].Active {
+		t.Fatalf("keys after rotate = %+v", keys)
+	}
+	kr, err := openKeyring(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setAtRestKeyring(kr)
+	rows, _ := db.Query(cfg.HistoryDB, `SELECT content FROM session_messages ORDER BY id`)
+	var got []string
+	for _, row := range rows {
+		v := db.Str(row["content"])
+		if tcrypto.SealedKeyID(v) != keys[0].ID {
+			t.Errorf("value not sealed with the new key: %q", v)
+		}
+		plain, _ := decryptAtRest(v, "")
+		got = append(got, plain)
+	}
+	if strings.Join(got, "|") != "from legacy|plain text|new message" {
+		t.Errorf("messages after rotate = %q", got)
+	}
+	if v, _ := getMemory(cfg, "", "pin"); v != "1234" {
+		t.Errorf("memory after rotate = %q", v)
+	}
+	data, _ := os.ReadFile(old + upload.SealedExt)
+	if plain, err := kr.OpenBytes(data); err != nil || string(plain) != "%PDF" {
+		t.Errorf("upload after rotate = %q, %v", plain, err)
+	}
+
+	cfg.Encryption.MasterKey = ""
+	if err := initAtRestEncryption(cfg); err == nil {
+		t.Error("expected error starting without the master key once data is sealed")
+	}
+}
+
+func TestLoadConfigReportsSchemaErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte("{\n  \"configVersion\": 99,\n  \"maxConcurrent\": \"4\"\n}"), 0o600)
+	_, err := tryLoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "config.json:3:20: error: maxConcurrent: expected an integer") {
+		t.Errorf("load error = %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"configVersion": 99, "maxConcurent": 4}`), 0o600)
+	if _, err := tryLoadConfig(path); err != nil {
+		t.Errorf("unknown keys should only warn: %v", err)
+	}
+}
+
+func TestLoadConfigYAMLAndTOML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(`configVersion: 99
+maxConcurrent: 3
+apiToken: ${TETORA_TEST_TOKEN}
+defaultModel: ${TETORA_TEST_MODEL:-haiku}
+agents:
+  ruri:
+    model: sonnet
+`), 0o600)
+	os.WriteFile(filepath.Join(dir, "config.local.toml"), []byte("maxConcurrent = 5\n\n[agents.ruri]\nmodel = \"opus\"\n"), 0o600)
+	t.Setenv("TETORA_TEST_TOKEN", "s3cret")
+
+	cfg, err := tryLoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIToken != "s3cret" || cfg.DefaultModel != "haiku" || cfg.MaxConcurrent != 5 || cfg.Agents["ruri"].Model != "opus" {
+		t.Errorf("cfg = token %q, model %q, max %d, agents %v", cfg.APIToken, cfg.DefaultModel, cfg.MaxConcurrent, cfg.Agents)
+	}
+	if cfg.ConfigFile() != path {
+		t.Errorf("ConfigFile = %q", cfg.ConfigFile())
+	}
+
+	os.WriteFile(path, []byte("configVersion: 99\nagents:\n  ruri:\n    model: [sonnet]\n"), 0o600)
+	if _, err := tryLoadConfig(path); err == nil || !strings.Contains(err.Error(), "config.yaml:4: error: agents.ruri.model: expected a string") {
+		t.Errorf("load error = %v", err)
+	}
+	os.WriteFile(path, []byte("configVersion: 99\nagents:\n\truri: {}\n"), 0o600)
+	if _, err := tryLoadConfig(path); err == nil || !strings.Contains(err.Error(), "config.yaml:3: error: (root): tabs are not allowed") {
+		t.Errorf("load error = %v", err)
+	}
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"configVersion": 99, "maxConcurrent": 2, "defaultModel": "sonnet", "agents": {"ruri": {"model": "sonnet"}}}`), 0o600)
+	os.MkdirAll(filepath.Join(dir, "config.d", "prod"), 0o755)
+	os.WriteFile(filepath.Join(dir, "config.d", "10-base.yaml"), []byte("maxConcurrent: 4\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "config.d", "20-agents.json"), []byte(`{"maxConcurrent": 6, "agents": {"ruri": {"description": "shared"}}}`), 0o600)
+	os.WriteFile(filepath.Join(dir, "config.d", "README.md"), []byte("not config"), 0o600)
+	os.WriteFile(filepath.Join(dir, "config.d", "prod", "model.toml"), []byte("[agents.ruri]\nmodel = \"opus\"\n"), 0o600)
+
+	t.Setenv(config.ProfileEnv, "")
+	cfg, err := tryLoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxConcurrent != 6 || cfg.Agents["ruri"].Model != "sonnet" || cfg.Agents["ruri"].Description != "shared" {
+		t.Errorf("no profile: max %d, ruri %+v", cfg.MaxConcurrent, cfg.Agents["ruri"])
+	}
+	if len(cfg.ConfigLayers) != 2 {
+		t.Errorf("layers = %v", cfg.ConfigLayers)
+	}
+
+	t.Setenv(config.ProfileEnv, "prod")
+	cfg, err = tryLoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profile != "prod" || cfg.Agents["ruri"].Model != "opus" || cfg.Agents["ruri"].Description != "shared" {
+		t.Errorf("prod: profile %q, ruri %+v", cfg.Profile, cfg.Agents["ruri"])
+	}
+
+	t.Setenv(config.ProfileEnv, "staging")
+	if _, err := tryLoadConfig(path); err == nil || !strings.Contains(err.Error(), `profile "staging"`) {
+		t.Errorf("unknown profile error = %v", err)
+	}
+}
+
+func TestApplyProfileFlag(t *testing.T) {
+	t.Setenv(config.ProfileEnv, "")
+	got := applyProfileFlag([]string{"tetora", "--profile", "dev", "config", "show"})
+	if strings.Join(got, " ") != "tetora config show" || os.Getenv(config.ProfileEnv) != "dev" {
+		t.Errorf("args = %v, profile %q", got, os.Getenv(config.ProfileEnv))
+	}
+	got = applyProfileFlag([]string{"tetora", "serve", "--profile=prod", "--", "--profile", "x"})
+	if strings.Join(got, " ") != "tetora serve -- --profile x" || os.Getenv(config.ProfileEnv) != "prod" {
+		t.Errorf("args = %v, profile %q", got, os.Getenv(config.ProfileEnv))
+	}
+}
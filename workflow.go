@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	neturl "net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -921,6 +922,9 @@ func (e *workflowExecutor) runStepOnce(ctx context.Context, step *WorkflowStep,
 		case "handoff":
 			e.runHandoffStepDryRun(step, result, wCtx)
 			return
+		case "review":
+			e.runReviewStepDryRun(step, result, wCtx)
+			return
 		case "condition":
 			// Conditions evaluate normally in dry-run.
 			e.runConditionStep(step, result, wCtx)
@@ -971,6 +975,10 @@ func (e *workflowExecutor) runStepOnce(ctx context.Context, step *WorkflowStep,
 		e.runHandoffStepShadow(ctx, step, result, wCtx)
 		return
 	}
+	if e.mode == WorkflowModeShadow && st == "review" {
+		e.runReviewStep(ctx, step, result, wCtx, true)
+		return
+	}
 
 	switch st {
 	case "dispatch":
@@ -979,6 +987,8 @@ func (e *workflowExecutor) runStepOnce(ctx context.Context, step *WorkflowStep,
 		e.runSkillStep(ctx, step, result, wCtx)
 	case "handoff":
 		e.runHandoffStep(ctx, step, result, wCtx)
+	case "review":
+		e.runReviewStep(ctx, step, result, wCtx, false)
 	case "condition":
 		e.runConditionStep(step, result, wCtx)
 	case "parallel":
@@ -1364,6 +1374,202 @@ func (e *workflowExecutor) runHandoffStep(ctx context.Context, step *WorkflowSte
 	log.DebugCtx(ctx, "handoff completed", "from", fromAgent, "to", step.Agent, "workflow", e.workflow.Name, "step", step.ID, "status", result.Status)
 }
 
+// runReviewStep has the step's agent draft an answer to the prompt and the
+// reviewer critique it, revising until the reviewer approves or the rounds
+// run out. Each draft and critique passed between them is recorded as a
+// handoff, and the final draft is the step output, also written to
+// outputs/reviews/. Shadow runs record and write nothing.
+func (e *workflowExecutor) runReviewStep(ctx context.Context, step *WorkflowStep,
+	result *StepRunResult, wCtx *WorkflowContext, shadow bool) {
+
+	author := resolveTemplate(step.Agent, wCtx)
+	reviewer := resolveTemplate(step.Reviewer, wCtx)
+	prompt := resolveTemplate(step.Prompt, wCtx)
+	criteria := resolveTemplate(step.ReviewPrompt, wCtx)
+	rounds := iwf.ReviewRounds(step)
+	source := fmt.Sprintf("workflow:%s:review", e.workflow.Name)
+
+	// turn runs one agent's part of the exchange. When from is set, the
+	// exchange is recorded as a handoff carrying handoffCtx.
+	prevSessionID := ""
+	turn := func(agent, from, label, taskPrompt, handoffCtx, instruction string) TaskResult {
+		task := Task{
+			ID:             newUUID(),
+			Name:           fmt.Sprintf("%s/%s (review:%s)", e.workflow.Name, step.ID, label),
+			Prompt:         taskPrompt,
+			Agent:          agent,
+			Timeout:        resolveTemplate(step.Timeout, wCtx),
+			Budget:         step.Budget,
+			PermissionMode: resolveTemplate(step.PermissionMode, wCtx),
+			Source:         source,
+			SessionID:      newUUID(),
+		}
+		if agent == author {
+			task.Model = resolveTemplate(step.Model, wCtx)
+			task.Provider = resolveTemplate(step.Provider, wCtx)
+		}
+		fillDefaults(e.cfg, &task)
+		if e.worktreeDir != "" {
+			task.Workdir = e.worktreeDir
+		}
+		if result.TaskID == "" {
+			result.TaskID = task.ID
+			result.SessionID = task.SessionID
+		}
+		if shadow {
+			return runSingleTaskNoRecord(ctx, e.cfg, task, e.sem, e.childSem, agent)
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		handoffID := ""
+		if from != "" {
+			handoffID = newUUID()
+			recordHandoff(e.cfg.HistoryDB, Handoff{
+				ID:            handoffID,
+				WorkflowRunID: e.run.ID,
+				FromAgent:     from,
+				ToAgent:       agent,
+				FromStepID:    step.ID,
+				ToStepID:      step.ID,
+				FromSessionID: prevSessionID,
+				ToSessionID:   task.SessionID,
+				Context:       truncateStr(handoffCtx, e.cfg.PromptBudget.ContextMaxOrDefault()),
+				Instruction:   instruction,
+				Status:        "active",
+				CreatedAt:     now,
+			})
+			sendAgentMessage(e.cfg.HistoryDB, AgentMessage{
+				WorkflowRunID: e.run.ID,
+				FromAgent:     from,
+				ToAgent:       agent,
+				Type:          "handoff",
+				Content:       fmt.Sprintf("Review %s from %s: %s", label, from, truncate(instruction, 200)),
+				RefID:         handoffID,
+				CreatedAt:     now,
+			})
+			e.publishEvent("handoff", map[string]any{
+				"runId":      e.run.ID,
+				"handoffId":  handoffID,
+				"fromAgent":  from,
+				"toAgent":    agent,
+				"fromStepId": step.ID,
+				"toStepId":   step.ID,
+			})
+		}
+		createSession(e.cfg.HistoryDB, Session{
+			ID:        task.SessionID,
+			Agent:     agent,
+			Source:    source,
+			Status:    "active",
+			Title:     fmt.Sprintf("Review: %s / %s (%s)", e.workflow.Name, step.ID, label),
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if e.broker != nil {
+			task.SSEBroker = e.broker
+		}
+		task.WorkflowRunID = e.run.ID
+
+		tr := runSingleTask(ctx, e.cfg, task, e.sem, e.childSem, agent)
+		recordSessionActivity(e.cfg.HistoryDB, task, tr, agent)
+		prevSessionID = task.SessionID
+
+		if handoffID != "" {
+			status := "completed"
+			if tr.Status != "success" {
+				status = "error"
+			}
+			updateHandoffStatus(e.cfg.HistoryDB, handoffID, status)
+			sendAgentMessage(e.cfg.HistoryDB, AgentMessage{
+				WorkflowRunID: e.run.ID,
+				FromAgent:     agent,
+				ToAgent:       from,
+				Type:          "response",
+				Content:       truncateStr(tr.Output, 2000),
+				RefID:         handoffID,
+				CreatedAt:     time.Now().Format(time.RFC3339),
+			})
+		}
+		return tr
+	}
+	// ok folds a turn's cost into the step and stops it on failure.
+	ok := func(tr TaskResult, label string) bool {
+		result.CostUSD += tr.CostUSD
+		if tr.Status == "success" {
+			return true
+		}
+		result.Status = "error"
+		if tr.Status == "timeout" {
+			result.Status = "timeout"
+		}
+		result.Error = fmt.Sprintf("%s: %s", label, tr.Error)
+		return false
+	}
+
+	tr := turn(author, "", "draft", prompt, "", "")
+	if !ok(tr, "draft") {
+		return
+	}
+	draft := tr.Output
+
+	approved := false
+	round := 1
+	for ; ; round++ {
+		label := fmt.Sprintf("round %d", round)
+		instruction := criteria
+		if instruction == "" {
+			instruction = "Review the draft."
+		}
+		tr = turn(reviewer, author, label, iwf.ReviewerPrompt(prompt, draft, criteria, round, rounds), draft, instruction)
+		if !ok(tr, label+" review") {
+			return
+		}
+		var critique string
+		approved, critique = iwf.ParseReviewVerdict(tr.Output)
+		e.publishEvent("review_round", map[string]any{
+			"runId":    e.run.ID,
+			"stepId":   step.ID,
+			"round":    round,
+			"approved": approved,
+		})
+		if approved || round >= rounds {
+			break
+		}
+		tr = turn(author, reviewer, label+" revision", iwf.RevisionPrompt(prompt, draft, critique, round), critique, "Revise the draft to address the feedback.")
+		if !ok(tr, label+" revision") {
+			return
+		}
+		draft = tr.Output
+	}
+
+	result.Output = draft
+	result.Status = "success"
+	if !approved && step.RequireApproval {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("reviewer %s did not approve after %d rounds", reviewer, round)
+	}
+	if shadow {
+		return
+	}
+
+	name := resolveTemplate(iwf.ReviewArtifactName(step, e.workflow.Name, e.run.ID), wCtx)
+	if !iwf.ValidArtifactName(name) {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("invalid review artifact name %q", name)
+		return
+	}
+	path := filepath.Join(e.cfg.ReviewsDir(), name)
+	err := os.MkdirAll(e.cfg.ReviewsDir(), 0o755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(draft), 0o644)
+	}
+	if err != nil {
+		log.Warn("review artifact write failed", "workflow", e.workflow.Name, "step", step.ID, "error", err)
+	}
+	log.InfoCtx(ctx, "review completed", "workflow", e.workflow.Name, "step", step.ID,
+		"author", author, "reviewer", reviewer, "rounds", round, "approved", approved, "artifact", path)
+}
+
 // --- P18.3: New Step Type Implementations ---
 
 // runToolCallStep executes a registered tool.
@@ -1379,7 +1585,7 @@ func (e *workflowExecutor) runToolCallStep(ctx context.Context, step *WorkflowSt
 	tool, ok := e.cfg.Runtime.ToolRegistry.(*ToolRegistry).Get(step.ToolName)
 	if !ok {
 		result.Status = "error"
-		result.Error = fmt.Sprintf("tool %q not found", step.ToolName)
+		result.Error = toolNotFound(step.ToolName).Error()
 		return
 	}
 
@@ -1387,7 +1593,15 @@ func (e *workflowExecutor) runToolCallStep(ctx context.Context, step *WorkflowSt
 	expandedInput := expandToolInput(step.ToolInput, wCtx.Input)
 	inputJSON := toolInputToJSON(expandedInput)
 
+	actionTask := Task{Name: step.ID, Source: "workflow:" + e.workflow.Name}
+	if err := checkExternalAction(ctx, e.cfg, actionTask, ToolCall{Name: step.ToolName, Input: inputJSON}); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return
+	}
+
 	output, err := tool.Handler(ctx, e.cfg, inputJSON)
+	recordIntegrationCall(step.ToolName, err)
 	if err != nil {
 		result.Status = "error"
 		result.Error = fmt.Sprintf("tool %q error: %v", step.ToolName, err)
@@ -1523,6 +1737,43 @@ func (e *workflowExecutor) runHandoffStepDryRun(step *WorkflowStep,
 		step.ID, resolvedAgent, est.Model, est.EstimatedCostUSD)
 }
 
+// runReviewStepDryRun estimates a review step at its worst case: a draft,
+// then a review and a revision for every round.
+func (e *workflowExecutor) runReviewStepDryRun(step *WorkflowStep,
+	result *StepRunResult, wCtx *WorkflowContext) {
+
+	author := resolveTemplate(step.Agent, wCtx)
+	reviewer := resolveTemplate(step.Reviewer, wCtx)
+	prompt := resolveTemplate(step.Prompt, wCtx)
+	rounds := iwf.ReviewRounds(step)
+
+	draft := Task{
+		ID:       newUUID(),
+		Name:     fmt.Sprintf("%s/%s (review:draft)", e.workflow.Name, step.ID),
+		Prompt:   prompt,
+		Agent:    author,
+		Model:    resolveTemplate(step.Model, wCtx),
+		Provider: resolveTemplate(step.Provider, wCtx),
+		Budget:   step.Budget,
+		Source:   fmt.Sprintf("workflow:%s:review", e.workflow.Name),
+	}
+	fillDefaults(e.cfg, &draft)
+	review := draft
+	review.Agent = reviewer
+	review.Model, review.Provider = "", ""
+	review.Prompt = iwf.ReviewerPrompt(prompt, prompt, resolveTemplate(step.ReviewPrompt, wCtx), 1, rounds)
+	fillDefaults(e.cfg, &review)
+
+	authorEst := estimateTaskCost(e.cfg, draft, author)
+	reviewEst := estimateTaskCost(e.cfg, review, reviewer)
+	result.TaskID = draft.ID
+	result.SessionID = draft.SessionID
+	result.CostUSD = authorEst.EstimatedCostUSD*float64(rounds) + reviewEst.EstimatedCostUSD*float64(rounds)
+	result.Status = "success"
+	result.Output = fmt.Sprintf("[DRY-RUN] step=%s author=%s reviewer=%s rounds=%d estimated_cost=$%.4f (review)",
+		step.ID, author, reviewer, rounds, result.CostUSD)
+}
+
 // --- Shadow Step Implementations ---
 
 // runDispatchStepShadow executes the dispatch step but skips history/session recording.
@@ -1935,6 +2186,37 @@ func queryWorkflowRunByID(dbPath, id string) (*WorkflowRun, error) {
 	return run, nil
 }
 
+// workflowRunDiagram renders a run with its workflow definition and recorded
+// handoffs. A workflow that has since been deleted is drawn from the run alone.
+func workflowRunDiagram(cfg *Config, dbPath, runID, format string) (string, error) {
+	run, err := queryWorkflowRunByID(dbPath, runID)
+	if err != nil {
+		return "", err
+	}
+	wf, err := loadWorkflowByName(cfg, run.WorkflowName)
+	if err != nil {
+		wf = nil
+	}
+	handoffs, _ := queryHandoffs(dbPath, runID)
+
+	r := &iwf.WorkflowRun{
+		ID:           run.ID,
+		WorkflowName: run.WorkflowName,
+		Status:       run.Status,
+		StartedAt:    run.StartedAt,
+		FinishedAt:   run.FinishedAt,
+		DurationMs:   run.DurationMs,
+		TotalCost:    run.TotalCost,
+		StepResults:  make(map[string]*iwf.StepRunResult, len(run.StepResults)),
+	}
+	for id, sr := range run.StepResults {
+		if sr != nil {
+			r.StepResults[id] = (*iwf.StepRunResult)(sr)
+		}
+	}
+	return iwf.RunDiagram(format, wf, r, handoffs)
+}
+
 // =============================================================================
 // Type aliases — re-export from internal/workflow for root-package callers
 // =============================================================================
@@ -1987,6 +2269,7 @@ func newWorkflowTriggerEngine(cfg *Config, state *dispatchState, sem, childSem c
 		LoadWorkflowByName: func(c *Config, name string) (*Workflow, error) {
 			return loadWorkflowByName(c, name)
 		},
+		Halted: emergencyHalted,
 	}
 	return iwf.NewWorkflowTriggerEngine(cfg, deps, broker)
 }